@@ -9,8 +9,10 @@ import (
 	"io"
 	"mime/multipart"
 	"strings"
+	"sync/atomic"
 
 	"github.com/ipfs/go-unixfs"
+	"github.com/ipfs/ipfs-cluster/adder/encryption"
 	"github.com/ipfs/ipfs-cluster/adder/ipfsadd"
 	"github.com/ipfs/ipfs-cluster/api"
 	"github.com/ipld/go-car"
@@ -26,6 +28,10 @@ import (
 
 var logger = logging.Logger("adder")
 
+// ErrUnexpectedCid is returned when the root of the added content does not
+// match the ExpectedCid from the add parameters.
+var ErrUnexpectedCid = errors.New("the resulting CID is not the expected one")
+
 // go-merkledag does this, but it may be moved.
 // We include for explicitness.
 func init() {
@@ -44,6 +50,19 @@ type ClusterDAGService interface {
 	Finalize(ctx context.Context, ipfsRoot cid.Cid) (cid.Cid, error)
 }
 
+// SizeSetter is implemented by ClusterDAGServices which can record the
+// size of the added content in the pins created by Finalize.
+type SizeSetter interface {
+	SetExpectedSize(size uint64)
+}
+
+// Aborter is implemented by ClusterDAGServices which commit part of the
+// content, like shard pins, before Finalize. Abort undoes it when the
+// adding fails.
+type Aborter interface {
+	Abort(ctx context.Context) error
+}
+
 // A dagFormatter can create dags from files.Node. It can keep state
 // to add several files to the same dag.
 type dagFormatter interface {
@@ -56,7 +75,7 @@ type Adder struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 
-	dgs ClusterDAGService
+	dgs *countingDAGService
 
 	params *api.AddParams
 
@@ -65,6 +84,9 @@ type Adder struct {
 	// about the block, the CID, the Name etc. and are mostly
 	// meant to be streamed back to the user.
 	output chan *api.AddedOutput
+
+	verify  func() error
+	reserve func(size uint64) (func(), error)
 }
 
 // New returns a new Adder with the given ClusterDAGService, add options and a
@@ -83,12 +105,35 @@ func New(ds ClusterDAGService, p *api.AddParams, out chan *api.AddedOutput) *Add
 	}
 
 	return &Adder{
-		dgs:    ds,
+		dgs:    &countingDAGService{ClusterDAGService: ds},
 		params: p,
 		output: out,
 	}
 }
 
+// SetVerifier sets a function which is called once all the content has
+// been read, before finalizing the adding operation. When it returns an
+// error, the adding fails with it and nothing is pinned.
+func (a *Adder) SetVerifier(verify func() error) {
+	a.verify = verify
+}
+
+// SetReserver sets a function which is called with the size of the added
+// blocks once all the content has been read and verified, right before
+// pinning it. It can reject the content by returning an error. Otherwise,
+// the function it returns is called once the pinning has finished.
+func (a *Adder) SetReserver(reserve func(size uint64) (func(), error)) {
+	a.reserve = reserve
+}
+
+// SetBlockChecker sets a function which is called with the CID of every
+// block before it is added. When it returns an error, the block is not
+// added and the adding fails with it. It allows rejecting content, like
+// denylisted CIDs, before it is ingested.
+func (a *Adder) SetBlockChecker(check func(ctx context.Context, c cid.Cid) error) {
+	a.dgs.check = check
+}
+
 func (a *Adder) setContext(ctx context.Context) {
 	if a.ctx == nil { // only allows first context
 		ctxc, cancel := context.WithCancel(ctx)
@@ -111,8 +156,9 @@ func (a *Adder) FromMultipart(ctx context.Context, r *multipart.Reader) (cid.Cid
 }
 
 // FromFiles adds content from a files.Directory. The adder will no longer
-// be usable after calling this method.
-func (a *Adder) FromFiles(ctx context.Context, f files.Directory) (cid.Cid, error) {
+// be usable after calling this method. When adding fails, whatever the
+// ClusterDAGService committed is undone if it is an Aborter.
+func (a *Adder) FromFiles(ctx context.Context, f files.Directory) (root cid.Cid, err error) {
 	logger.Debug("adding from files")
 	a.setContext(ctx)
 
@@ -122,9 +168,13 @@ func (a *Adder) FromFiles(ctx context.Context, f files.Directory) (cid.Cid, erro
 
 	defer a.cancel()
 	defer close(a.output)
+	defer func() {
+		if err != nil {
+			a.abort()
+		}
+	}()
 
 	var dagFmtr dagFormatter
-	var err error
 	switch a.params.Format {
 	case "", "unixfs":
 		dagFmtr, err = newIpfsAdder(ctx, a.dgs, a.params, a.output)
@@ -145,6 +195,13 @@ func (a *Adder) FromFiles(ctx context.Context, f files.Directory) (cid.Cid, erro
 		)
 	}
 
+	if a.params.Encrypt != "" {
+		if len(a.params.EncryptionKey) == 0 {
+			return cid.Undef, fmt.Errorf("no encryption key for '%s'", a.params.Encrypt)
+		}
+		f = encryption.Directory(f, a.params.EncryptionKey)
+	}
+
 	it := f.Entries()
 	var adderRoot cid.Cid
 	for it.Next() {
@@ -170,6 +227,34 @@ func (a *Adder) FromFiles(ctx context.Context, f files.Directory) (cid.Cid, erro
 		return cid.Undef, it.Err()
 	}
 
+	if a.verify != nil {
+		if err := a.verify(); err != nil {
+			logger.Error(err)
+			return cid.Undef, err
+		}
+	}
+
+	if expected := a.params.ExpectedCid; expected.Defined() && !expected.Equals(adderRoot) {
+		err := fmt.Errorf("%w: got %s, expected %s", ErrUnexpectedCid, adderRoot, expected)
+		logger.Error(err)
+		return cid.Undef, err
+	}
+
+	// The pins record the actual size of the content rather than the
+	// expected one.
+	size := a.dgs.Size()
+	if ss, ok := a.dgs.ClusterDAGService.(SizeSetter); ok {
+		ss.SetExpectedSize(size)
+	}
+	if a.reserve != nil {
+		release, err := a.reserve(size)
+		if err != nil {
+			logger.Error(err)
+			return cid.Undef, err
+		}
+		defer release()
+	}
+
 	clusterRoot, err := a.dgs.Finalize(a.ctx, adderRoot)
 	if err != nil {
 		logger.Error("error finalizing adder:", err)
@@ -179,6 +264,67 @@ func (a *Adder) FromFiles(ctx context.Context, f files.Directory) (cid.Cid, erro
 	return clusterRoot, nil
 }
 
+// abort undoes what the ClusterDAGService has committed, if anything. It
+// does not use the context of the adding, which may be cancelled already.
+func (a *Adder) abort() {
+	aborter, ok := a.dgs.ClusterDAGService.(Aborter)
+	if !ok {
+		return
+	}
+	if err := aborter.Abort(context.Background()); err != nil {
+		logger.Errorf("error undoing a failed add: %s", err)
+	}
+}
+
+// countingDAGService counts the bytes of the blocks added through a
+// ClusterDAGService. It checks them first when a block checker is set.
+type countingDAGService struct {
+	ClusterDAGService
+	size  uint64
+	check func(ctx context.Context, c cid.Cid) error
+}
+
+func (dgs *countingDAGService) checkNodes(ctx context.Context, nodes ...ipld.Node) error {
+	if dgs.check == nil {
+		return nil
+	}
+	for _, node := range nodes {
+		if err := dgs.check(ctx, node.Cid()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (dgs *countingDAGService) Add(ctx context.Context, node ipld.Node) error {
+	if err := dgs.checkNodes(ctx, node); err != nil {
+		return err
+	}
+	if err := dgs.ClusterDAGService.Add(ctx, node); err != nil {
+		return err
+	}
+	atomic.AddUint64(&dgs.size, uint64(len(node.RawData())))
+	return nil
+}
+
+func (dgs *countingDAGService) AddMany(ctx context.Context, nodes []ipld.Node) error {
+	if err := dgs.checkNodes(ctx, nodes...); err != nil {
+		return err
+	}
+	if err := dgs.ClusterDAGService.AddMany(ctx, nodes); err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		atomic.AddUint64(&dgs.size, uint64(len(node.RawData())))
+	}
+	return nil
+}
+
+// Size returns the total size of the added blocks.
+func (dgs *countingDAGService) Size() uint64 {
+	return atomic.LoadUint64(&dgs.size)
+}
+
 // A wrapper around the ipfsadd.Adder to satisfy the dagFormatter interface.
 type ipfsAdder struct {
 	*ipfsadd.Adder
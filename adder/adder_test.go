@@ -3,17 +3,21 @@ package adder
 import (
 	"bytes"
 	"context"
+	"errors"
+	"io/ioutil"
 	"mime/multipart"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/ipfs/ipfs-cluster/adder/encryption"
 	"github.com/ipfs/ipfs-cluster/api"
 	"github.com/ipfs/ipfs-cluster/test"
 	"github.com/ipld/go-car"
 
 	cid "github.com/ipfs/go-cid"
 	files "github.com/ipfs/go-ipfs-files"
+	unixfile "github.com/ipfs/go-unixfs/file"
 )
 
 type mockCDAGServ struct {
@@ -67,6 +71,134 @@ func TestAdder(t *testing.T) {
 	}
 }
 
+func TestAdder_ExpectedCid(t *testing.T) {
+	sth := test.NewShardingTestHelper()
+	defer sth.Clean(t)
+
+	add := func(expected cid.Cid) (cid.Cid, error) {
+		mr, closer := sth.GetTreeMultiReader(t)
+		defer closer.Close()
+		r := multipart.NewReader(mr, mr.Boundary())
+		p := api.DefaultAddParams()
+		p.ExpectedCid = expected
+		adder := New(newMockCDAGServ(), p, nil)
+		return adder.FromMultipart(context.Background(), r)
+	}
+
+	expected, _ := cid.Decode(test.ShardingDirBalancedRootCID)
+	root, err := add(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !root.Equals(expected) {
+		t.Error("expected the right content root")
+	}
+
+	_, err = add(test.Cid1)
+	if !errors.Is(err, ErrUnexpectedCid) {
+		t.Error("expected ErrUnexpectedCid:", err)
+	}
+}
+
+func TestAdder_Verifier(t *testing.T) {
+	sth := test.NewShardingTestHelper()
+	defer sth.Clean(t)
+
+	add := func(verify func() error) (cid.Cid, error) {
+		mr, closer := sth.GetTreeMultiReader(t)
+		defer closer.Close()
+		r := multipart.NewReader(mr, mr.Boundary())
+		adder := New(newMockCDAGServ(), api.DefaultAddParams(), nil)
+		adder.SetVerifier(verify)
+		return adder.FromMultipart(context.Background(), r)
+	}
+
+	called := false
+	root, err := add(func() error {
+		called = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !called || root.String() != test.ShardingDirBalancedRootCID {
+		t.Error("expected the verifier to be called and the right root")
+	}
+
+	errBad := errors.New("bad upload")
+	if _, err := add(func() error { return errBad }); err != errBad {
+		t.Error("expected the verifier error:", err)
+	}
+}
+
+func TestAdder_Reserver(t *testing.T) {
+	sth := test.NewShardingTestHelper()
+	defer sth.Clean(t)
+
+	add := func(dags *mockCDAGServ, reserve func(uint64) (func(), error)) (cid.Cid, error) {
+		mr, closer := sth.GetTreeMultiReader(t)
+		defer closer.Close()
+		r := multipart.NewReader(mr, mr.Boundary())
+		adder := New(dags, api.DefaultAddParams(), nil)
+		adder.SetReserver(reserve)
+		return adder.FromMultipart(context.Background(), r)
+	}
+
+	dags := newMockCDAGServ()
+	var size uint64
+	released := false
+	_, err := add(dags, func(s uint64) (func(), error) {
+		size = s
+		return func() { released = true }, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var blocks uint64
+	for _, n := range dags.Nodes {
+		blocks += uint64(len(n.RawData()))
+	}
+	if size == 0 || size < blocks {
+		t.Errorf("expected the size of the added blocks (%d), got %d", blocks, size)
+	}
+	if !released {
+		t.Error("expected the reservation to be released")
+	}
+
+	errQuota := errors.New("over quota")
+	_, err = add(newMockCDAGServ(), func(uint64) (func(), error) { return nil, errQuota })
+	if err != errQuota {
+		t.Error("expected the reserver error:", err)
+	}
+}
+
+func TestAdder_BlockChecker(t *testing.T) {
+	sth := test.NewShardingTestHelper()
+	defer sth.Clean(t)
+
+	mr, closer := sth.GetTreeMultiReader(t)
+	defer closer.Close()
+	r := multipart.NewReader(mr, mr.Boundary())
+
+	root, _ := cid.Decode(test.ShardingDirBalancedRootCID)
+	errDenied := errors.New("denied")
+	dags := newMockCDAGServ()
+	adder := New(dags, api.DefaultAddParams(), nil)
+	adder.SetBlockChecker(func(ctx context.Context, c cid.Cid) error {
+		if c.Equals(root) {
+			return errDenied
+		}
+		return nil
+	})
+	_, err := adder.FromMultipart(context.Background(), r)
+	if !errors.Is(err, errDenied) {
+		t.Fatal("expected the block checker error:", err)
+	}
+	if _, ok := dags.Nodes[root]; ok {
+		t.Error("the rejected block should not have been added")
+	}
+}
+
 func TestAdder_DoubleStart(t *testing.T) {
 	sth := test.NewShardingTestHelper()
 	defer sth.Clean(t)
@@ -183,3 +315,70 @@ func TestAdder_CAR(t *testing.T) {
 	}
 
 }
+
+func TestAdder_Encrypt(t *testing.T) {
+	ctx := context.Background()
+	key := bytes.Repeat([]byte{7}, encryption.KeySize)
+	contents := map[string][]byte{
+		"a": bytes.Repeat([]byte("ipfs-cluster "), 20000),
+		"b": []byte("small file"),
+	}
+
+	p := api.DefaultAddParams()
+	p.Encrypt = api.UserEncryptionKey
+	dir := files.NewMapDirectory(map[string]files.Node{
+		"dir": files.NewMapDirectory(map[string]files.Node{
+			"a": files.NewBytesFile(contents["a"]),
+			"b": files.NewBytesFile(contents["b"]),
+		}),
+	})
+	_, err := New(newMockCDAGServ(), p, nil).FromFiles(ctx, dir)
+	if err == nil {
+		t.Fatal("expected an error without key")
+	}
+
+	p.EncryptionKey = key
+	dags := newMockCDAGServ()
+	dir = files.NewMapDirectory(map[string]files.Node{
+		"dir": files.NewMapDirectory(map[string]files.Node{
+			"a": files.NewBytesFile(contents["a"]),
+			"b": files.NewBytesFile(contents["b"]),
+		}),
+	})
+	root, err := New(dags, p, nil).FromFiles(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nd, err := dags.Get(ctx, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := unixfile.NewUnixfsFile(ctx, dags, nd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	it := node.(files.Directory).Entries()
+	n := 0
+	for it.Next() {
+		n++
+		f := it.Node().(files.File)
+		encrypted, err := ioutil.ReadAll(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(encrypted, contents["b"]) {
+			t.Errorf("%s is not encrypted", it.Name())
+		}
+		plain, err := ioutil.ReadAll(encryption.NewDecryptReader(bytes.NewReader(encrypted), key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(plain, contents[it.Name()]) {
+			t.Errorf("%s was not decrypted correctly", it.Name())
+		}
+	}
+	if n != len(contents) {
+		t.Errorf("expected %d files, got %d", len(contents), n)
+	}
+}
@@ -6,9 +6,12 @@ import (
 	"encoding/json"
 	"mime/multipart"
 	"net/http"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/ipfs/ipfs-cluster/adder"
+	"github.com/ipfs/ipfs-cluster/adder/erasure"
 	"github.com/ipfs/ipfs-cluster/adder/sharding"
 	"github.com/ipfs/ipfs-cluster/adder/single"
 	"github.com/ipfs/ipfs-cluster/api"
@@ -20,10 +23,17 @@ import (
 
 var logger = logging.Logger("adder")
 
+// How often a tracked add checks whether its operation has been canceled.
+var operationPollInterval = time.Second
+
 // AddMultipartHTTPHandler is a helper function to add content
 // uploaded using a multipart request. The outputTransform parameter
 // allows to customize the http response output format to something
-// else than api.AddedOutput objects.
+// else than api.AddedOutput objects. The verify function, when not nil,
+// is called after reading the upload and before pinning it (see
+// adder.SetVerifier). The reserve function, when not nil, is called with the
+// size of the upload right before pinning it (see adder.SetReserver). Blocks
+// denylisted in the local peer are rejected before they are added.
 func AddMultipartHTTPHandler(
 	ctx context.Context,
 	rpc *rpc.Client,
@@ -31,12 +41,36 @@ func AddMultipartHTTPHandler(
 	reader *multipart.Reader,
 	w http.ResponseWriter,
 	outputTransform func(*api.AddedOutput) interface{},
+	verify func() error,
+	reserve func(size uint64) (func(), error),
+) (cid.Cid, error) {
+	ctx, finish := trackAdd(ctx, rpc)
+	root, err := addMultipart(ctx, rpc, params, reader, w, outputTransform, verify, reserve)
+	finish(root, err)
+	return root, err
+}
+
+func addMultipart(
+	ctx context.Context,
+	rpc *rpc.Client,
+	params *api.AddParams,
+	reader *multipart.Reader,
+	w http.ResponseWriter,
+	outputTransform func(*api.AddedOutput) interface{},
+	verify func() error,
+	reserve func(size uint64) (func(), error),
 ) (cid.Cid, error) {
 	var dags adder.ClusterDAGService
 	output := make(chan *api.AddedOutput, 200)
 
 	if params.Shard {
 		dags = sharding.New(rpc, params.PinOptions, output)
+	} else if params.ErasureDataShards > 0 {
+		ecdags, err := erasure.New(rpc, params.PinOptions, params.ErasureDataShards, params.ErasureParityShards, output)
+		if err != nil {
+			return cid.Undef, err
+		}
+		dags = ecdags
 	} else {
 		dags = single.New(rpc, params.PinOptions, params.Local)
 	}
@@ -68,6 +102,9 @@ func AddMultipartHTTPHandler(
 
 		enc := json.NewEncoder(w)
 		add := adder.New(dags, params, output)
+		add.SetVerifier(verify)
+		add.SetReserver(reserve)
+		add.SetBlockChecker(denylistChecker(rpc))
 		root, err := add.FromMultipart(ctx, reader)
 		if err != nil { // Send an error
 			logger.Error(err)
@@ -102,6 +139,9 @@ func AddMultipartHTTPHandler(
 		streamOutput(w, output, outputTransform)
 	}()
 	add := adder.New(dags, params, output)
+	add.SetVerifier(verify)
+	add.SetReserver(reserve)
+	add.SetBlockChecker(denylistChecker(rpc))
 	root, err := add.FromMultipart(ctx, reader)
 	if err != nil {
 		logger.Error(err)
@@ -112,6 +152,88 @@ func AddMultipartHTTPHandler(
 	return root, err
 }
 
+// trackAdd registers the add as an operation in the local peer, so that it
+// can be listed and canceled. The returned context is canceled when the
+// operation is canceled. The returned function must be called when the add
+// finishes. Adds are not tracked when the operation cannot be registered.
+func trackAdd(ctx context.Context, rpc *rpc.Client) (context.Context, func(cid.Cid, error)) {
+	var op api.Operation
+	err := rpc.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"StartOperation",
+		api.OperationAdd,
+		&op,
+	)
+	if err != nil {
+		logger.Warnf("cannot track the add as an operation: %s", err)
+		return ctx, func(cid.Cid, error) {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(operationPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				var status api.Operation
+				err := rpc.CallContext(ctx, "", "Cluster", "Operation", op.ID, &status)
+				if err == nil && status.Status == api.OperationCanceled {
+					logger.Infof("add operation %s canceled", op.ID)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	return ctx, func(root cid.Cid, addErr error) {
+		close(done)
+		cancel()
+		op.Cid = root
+		op.Error = ""
+		if addErr != nil {
+			op.Error = addErr.Error()
+		}
+		err := rpc.Call(
+			"",
+			"Cluster",
+			"FinishOperation",
+			&op,
+			&struct{}{},
+		)
+		if err != nil {
+			logger.Warnf("cannot finish the add operation %s: %s", op.ID, err)
+		}
+	}
+}
+
+// denylistChecker returns a block checker which rejects the blocks
+// denylisted in the local peer. Blocks are accepted when the check cannot be
+// done.
+func denylistChecker(rpc *rpc.Client) func(context.Context, cid.Cid) error {
+	return func(ctx context.Context, c cid.Cid) error {
+		err := rpc.CallContext(
+			ctx,
+			"",
+			"Cluster",
+			"DenylistCheck",
+			c,
+			&struct{}{},
+		)
+		if err != nil && !strings.HasPrefix(err.Error(), api.ErrDenylisted.Error()) {
+			logger.Warnf("cannot check the denylist for %s: %s", c, err)
+			return nil
+		}
+		return err
+	}
+}
+
 func streamOutput(w http.ResponseWriter, output chan *api.AddedOutput, transform func(*api.AddedOutput) interface{}) {
 	flusher, flush := w.(http.Flusher)
 	enc := json.NewEncoder(w)
@@ -0,0 +1,321 @@
+// Package encryption implements the encryption of content before it is
+// added to cluster, so that the blocks pinned by the peers and their IPFS
+// daemons cannot be read without the key.
+//
+// Every file is encrypted as a stream with AES-256-GCM, in segments of
+// SegmentSize bytes. The stream starts with a header carrying a random
+// nonce, from which a key for the file is derived, so that the same key
+// can be used for any number of files. Segments are sealed with a nonce
+// made of their position and a flag marking the last one, so they cannot
+// be reordered or truncated without notice.
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	files "github.com/ipfs/go-ipfs-files"
+	hkdf "golang.org/x/crypto/hkdf"
+)
+
+// Scheme identifies the encryption format in the pin metadata.
+const Scheme = "aes-256-gcm-stream"
+
+// KeySize is the size of encryption keys.
+const KeySize = 32
+
+// SegmentSize is the size of the plaintext segments of the stream.
+const SegmentSize = 64 * 1024
+
+const nonceSize = 16
+
+var magic = []byte("ICE1")
+
+// ErrWrongKey is returned when decrypting with a key which is not the one
+// used to encrypt the content.
+var ErrWrongKey = errors.New("the key does not match the one used to encrypt")
+
+// ParseKey decodes a base64 or hex-encoded key.
+func ParseKey(s string) ([]byte, error) {
+	key, err := hex.DecodeString(s)
+	if err != nil {
+		key, err = base64.StdEncoding.DecodeString(s)
+	}
+	if err != nil {
+		return nil, errors.New("the encryption key must be hex or base64-encoded")
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("the encryption key must have %d bytes", KeySize)
+	}
+	return key, nil
+}
+
+// Fingerprint returns an identifier of the key, which allows to detect
+// that a wrong key is used without revealing it.
+func Fingerprint(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Prepare enables the encryption of the content added with the given
+// parameters. The pin metadata records the scheme, the given key name and
+// the key fingerprint so that the content can be decrypted later.
+func Prepare(params *api.AddParams, keyName string, key []byte) error {
+	if len(key) != KeySize {
+		return fmt.Errorf("the encryption key must have %d bytes", KeySize)
+	}
+	if params.Format == "car" {
+		return errors.New("CAR uploads cannot be encrypted")
+	}
+	if params.NoCopy {
+		return errors.New("nocopy uploads cannot be encrypted")
+	}
+	params.Encrypt = keyName
+	params.EncryptionKey = key
+	if params.Metadata == nil {
+		params.Metadata = make(map[string]string)
+	}
+	params.Metadata[api.EncryptionMetaKey] = api.Encryption{
+		Scheme:      Scheme,
+		KeyName:     keyName,
+		Fingerprint: Fingerprint(key),
+	}.String()
+	return nil
+}
+
+// Check verifies that the given key can decrypt content encrypted as
+// described.
+func Check(enc api.Encryption, key []byte) error {
+	if enc.Scheme != Scheme {
+		return fmt.Errorf("unsupported encryption scheme %q", enc.Scheme)
+	}
+	if Fingerprint(key) != enc.Fingerprint {
+		return ErrWrongKey
+	}
+	return nil
+}
+
+func newAEAD(key, nonce []byte) (cipher.AEAD, error) {
+	fileKey := make([]byte, KeySize)
+	kdf := hkdf.New(sha256.New, key, nonce, []byte(Scheme))
+	if _, err := io.ReadFull(kdf, fileKey); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func segmentNonce(n uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], n)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// encryptReader encrypts the data read from a reader.
+type encryptReader struct {
+	src  *bufio.Reader
+	key  []byte
+	aead cipher.AEAD
+
+	seg  uint64
+	done bool
+	out  bytes.Buffer
+	in   []byte
+}
+
+// NewReader returns a reader of the encrypted stream of the data read from
+// src.
+func NewReader(src io.Reader, key []byte) io.Reader {
+	return &encryptReader{
+		src: bufio.NewReaderSize(src, SegmentSize),
+		key: key,
+		in:  make([]byte, SegmentSize),
+	}
+}
+
+func (er *encryptReader) Read(p []byte) (int, error) {
+	for er.out.Len() == 0 {
+		if er.done {
+			return 0, io.EOF
+		}
+		if err := er.next(); err != nil {
+			return 0, err
+		}
+	}
+	return er.out.Read(p)
+}
+
+func (er *encryptReader) next() error {
+	if er.aead == nil {
+		nonce := make([]byte, nonceSize)
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+		aead, err := newAEAD(er.key, nonce)
+		if err != nil {
+			return err
+		}
+		er.aead = aead
+		er.out.Write(magic)
+		er.out.Write(nonce)
+	}
+
+	n, err := io.ReadFull(er.src, er.in)
+	switch err {
+	case nil, io.ErrUnexpectedEOF, io.EOF:
+	default:
+		return err
+	}
+	// The segment is the last one when nothing follows it.
+	_, err = er.src.Peek(1)
+	last := err == io.EOF
+	if err != nil && !last {
+		return err
+	}
+	er.out.Write(er.aead.Seal(nil, segmentNonce(er.seg, last), er.in[:n], nil))
+	er.seg++
+	er.done = last
+	return nil
+}
+
+// decryptReader decrypts an encrypted stream.
+type decryptReader struct {
+	src  *bufio.Reader
+	key  []byte
+	aead cipher.AEAD
+
+	seg  uint64
+	done bool
+	out  []byte
+	in   []byte
+}
+
+// NewDecryptReader returns a reader of the data encrypted in the stream
+// read from src. It fails when the stream has been modified.
+func NewDecryptReader(src io.Reader, key []byte) io.Reader {
+	return &decryptReader{
+		src: bufio.NewReaderSize(src, SegmentSize+aes.BlockSize),
+		key: key,
+	}
+}
+
+func (dr *decryptReader) Read(p []byte) (int, error) {
+	for len(dr.out) == 0 {
+		if dr.done {
+			return 0, io.EOF
+		}
+		if err := dr.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, dr.out)
+	dr.out = dr.out[n:]
+	return n, nil
+}
+
+func (dr *decryptReader) next() error {
+	if dr.aead == nil {
+		header := make([]byte, len(magic)+nonceSize)
+		if _, err := io.ReadFull(dr.src, header); err != nil {
+			return errors.New("the encrypted stream has no header")
+		}
+		if !bytes.Equal(header[:len(magic)], magic) {
+			return errors.New("not an encrypted stream")
+		}
+		aead, err := newAEAD(dr.key, header[len(magic):])
+		if err != nil {
+			return err
+		}
+		dr.aead = aead
+		dr.in = make([]byte, SegmentSize+aead.Overhead())
+	}
+
+	n, err := io.ReadFull(dr.src, dr.in)
+	switch err {
+	case nil, io.ErrUnexpectedEOF, io.EOF:
+	default:
+		return err
+	}
+	_, err = dr.src.Peek(1)
+	last := err == io.EOF
+	if err != nil && !last {
+		return err
+	}
+	out, err := dr.aead.Open(dr.in[:0], segmentNonce(dr.seg, last), dr.in[:n], nil)
+	if err != nil {
+		return fmt.Errorf("error decrypting segment %d: the stream is truncated or modified", dr.seg)
+	}
+	dr.out = out
+	dr.seg++
+	dr.done = last
+	return nil
+}
+
+// Directory returns a directory with the same entries as the given one, in
+// which the contents of all files are encrypted. Names and symlinks are not
+// encrypted.
+func Directory(dir files.Directory, key []byte) files.Directory {
+	return &encryptedDir{Directory: dir, key: key}
+}
+
+type encryptedDir struct {
+	files.Directory
+	key []byte
+}
+
+// Size is unknown, as encryption adds a variable overhead.
+func (d *encryptedDir) Size() (int64, error) {
+	return 0, files.ErrNotSupported
+}
+
+func (d *encryptedDir) Entries() files.DirIterator {
+	return &encryptedIterator{DirIterator: d.Directory.Entries(), key: d.key}
+}
+
+type encryptedIterator struct {
+	files.DirIterator
+	key []byte
+}
+
+func (it *encryptedIterator) Node() files.Node {
+	return encryptNode(it.DirIterator.Node(), it.key)
+}
+
+func encryptNode(n files.Node, key []byte) files.Node {
+	switch n := n.(type) {
+	case *files.Symlink:
+		return n
+	case files.File:
+		return files.NewReaderFile(&readCloser{
+			Reader: NewReader(n, key),
+			Closer: n,
+		})
+	case files.Directory:
+		return Directory(n, key)
+	default:
+		return n
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
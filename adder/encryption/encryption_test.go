@@ -0,0 +1,142 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, KeySize)
+}
+
+func encrypt(t *testing.T, data, key []byte) []byte {
+	t.Helper()
+	enc, err := ioutil.ReadAll(NewReader(bytes.NewReader(data), key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enc
+}
+
+func TestStream(t *testing.T) {
+	key := testKey(1)
+	for _, size := range []int{0, 1, 1000, SegmentSize, 2*SegmentSize + 5, 3 * SegmentSize} {
+		data := make([]byte, size)
+		rand.Read(data)
+
+		enc := encrypt(t, data, key)
+		if size > 16 && bytes.Contains(enc, data) {
+			t.Errorf("%d bytes: data is not encrypted", size)
+		}
+		if bytes.Equal(enc, encrypt(t, data, key)) {
+			t.Errorf("%d bytes: encrypting twice gives the same stream", size)
+		}
+
+		dec, err := ioutil.ReadAll(NewDecryptReader(bytes.NewReader(enc), key))
+		if err != nil {
+			t.Fatalf("%d bytes: %s", size, err)
+		}
+		if !bytes.Equal(dec, data) {
+			t.Errorf("%d bytes: decrypted data does not match", size)
+		}
+
+		if _, err := ioutil.ReadAll(NewDecryptReader(bytes.NewReader(enc), testKey(2))); err == nil {
+			t.Errorf("%d bytes: expected an error with the wrong key", size)
+		}
+	}
+}
+
+func TestStreamModified(t *testing.T) {
+	key := testKey(1)
+	data := make([]byte, 3*SegmentSize)
+	rand.Read(data)
+	enc := encrypt(t, data, key)
+	segment := SegmentSize + 16
+
+	decrypt := func(enc []byte) error {
+		_, err := ioutil.ReadAll(NewDecryptReader(bytes.NewReader(enc), key))
+		return err
+	}
+
+	// Truncated at a segment boundary.
+	if err := decrypt(enc[:len(enc)-segment]); err == nil {
+		t.Error("expected an error decrypting a truncated stream")
+	}
+
+	flipped := append([]byte{}, enc...)
+	flipped[len(flipped)/2] ^= 1
+	if err := decrypt(flipped); err == nil {
+		t.Error("expected an error decrypting a modified stream")
+	}
+
+	header := len(magic) + nonceSize
+	swapped := append([]byte{}, enc[:header]...)
+	swapped = append(swapped, enc[header+segment:header+2*segment]...)
+	swapped = append(swapped, enc[header:header+segment]...)
+	swapped = append(swapped, enc[header+2*segment:]...)
+	if err := decrypt(swapped); err == nil {
+		t.Error("expected an error decrypting reordered segments")
+	}
+
+	if err := decrypt([]byte("not encrypted at all")); err == nil {
+		t.Error("expected an error decrypting plain data")
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	key := testKey(3)
+	for _, s := range []string{hex.EncodeToString(key), base64.StdEncoding.EncodeToString(key)} {
+		k, err := ParseKey(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(k, key) {
+			t.Error("parsed the wrong key")
+		}
+	}
+
+	for _, s := range []string{"", "abcd", "not a key", hex.EncodeToString(key[:16])} {
+		if _, err := ParseKey(s); err == nil {
+			t.Errorf("expected an error parsing %q", s)
+		}
+	}
+}
+
+func TestPrepare(t *testing.T) {
+	key := testKey(4)
+	params := api.DefaultAddParams()
+	if err := Prepare(params, "team", key); err != nil {
+		t.Fatal(err)
+	}
+	if params.Encrypt != "team" || !bytes.Equal(params.EncryptionKey, key) {
+		t.Error("encryption was not enabled in the params")
+	}
+
+	enc, err := api.ParseEncryption(params.Metadata[api.EncryptionMetaKey])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enc.Scheme != Scheme || enc.KeyName != "team" {
+		t.Errorf("unexpected encryption metadata: %+v", enc)
+	}
+	if err := Check(enc, key); err != nil {
+		t.Error(err)
+	}
+	if err := Check(enc, testKey(5)); err != ErrWrongKey {
+		t.Error("expected ErrWrongKey:", err)
+	}
+
+	if err := Prepare(params, "team", key[:10]); err == nil {
+		t.Error("expected an error with a short key")
+	}
+	params.Format = "car"
+	if err := Prepare(params, "team", key); err == nil {
+		t.Error("expected an error encrypting a CAR upload")
+	}
+}
@@ -0,0 +1,355 @@
+// Package erasure implements an experimental ClusterDAGService which
+// erasure-codes content while it's being added. The blocks of the DAG are
+// serialized into a stream which is cut in stripes. Every stripe is split
+// into data chunks and Reed-Solomon parity chunks are computed for it. The
+// chunks of every data and parity shard are put on a different peer and
+// tracked by a shard pin, so that the content can be reconstructed from
+// any set of shards as large as the number of data shards.
+package erasure
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/adder"
+	"github.com/ipfs/ipfs-cluster/adder/sharding"
+	"github.com/ipfs/ipfs-cluster/api"
+
+	humanize "github.com/dustin/go-humanize"
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log/v2"
+	merkledag "github.com/ipfs/go-merkledag"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+)
+
+var logger = logging.Logger("erasuredags")
+
+// ChunkSize is the size of the blocks in which the shards are stored. The
+// chunks of the last stripe may be smaller.
+var ChunkSize = 1024 * 1024
+
+// DAGService is an implementation of a ClusterDAGService which
+// erasure-codes content while adding, creating a Cluster DAG which tracks
+// the data and parity shards.
+type DAGService struct {
+	adder.BaseDAGService
+
+	rpcClient *rpc.Client
+
+	pinOpts api.PinOptions
+	output  chan<- *api.AddedOutput
+
+	addedSet *cid.Set
+	codec    *codec
+
+	// serialized blocks not yet encoded
+	buf     []byte
+	lastCid cid.Cid
+	shards  []*shard
+
+	startTime time.Time
+	totalSize uint64
+}
+
+// shard collects the chunks of one of the data or parity shards.
+type shard struct {
+	allocation peer.ID
+	ba         *adder.BlockAdder
+	links      map[string]cid.Cid
+	size       uint64
+}
+
+// New returns a new ClusterDAGService, which encodes the added content in
+// the given number of data and parity shards and uses the given rpc client
+// to perform Allocate, IPFSBlockPut and Pin requests to other cluster
+// components.
+func New(rpc *rpc.Client, opts api.PinOptions, data, parity int, out chan<- *api.AddedOutput) (*DAGService, error) {
+	c, err := newCodec(data, parity)
+	if err != nil {
+		return nil, err
+	}
+	// use a default value for this regardless of what is provided.
+	opts.Mode = api.PinModeRecursive
+	return &DAGService{
+		rpcClient: rpc,
+		pinOpts:   opts,
+		output:    out,
+		addedSet:  cid.NewSet(),
+		codec:     c,
+		startTime: time.Now(),
+	}, nil
+}
+
+// Add serializes the given node and encodes the stripes which are
+// complete.
+func (dgs *DAGService) Add(ctx context.Context, node ipld.Node) error {
+	// FIXME: This will grow in memory
+	if !dgs.addedSet.Visit(node.Cid()) {
+		return nil
+	}
+
+	dgs.lastCid = node.Cid()
+	dgs.buf = appendRecord(dgs.buf, node.Cid(), node.RawData())
+	stripe := dgs.codec.data * ChunkSize
+	for len(dgs.buf) >= stripe {
+		if err := dgs.encodeStripe(ctx, dgs.buf[:stripe]); err != nil {
+			return err
+		}
+		dgs.buf = append(dgs.buf[:0], dgs.buf[stripe:]...)
+	}
+	return nil
+}
+
+// AddMany calls Add for every given node.
+func (dgs *DAGService) AddMany(ctx context.Context, nodes []ipld.Node) error {
+	for _, node := range nodes {
+		err := dgs.Add(ctx, node)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Finalize encodes the last stripe, pins the shards, creates the cluster
+// DAG and pins it along with the meta pin for the root node of the
+// content. The meta pin carries the erasure coding parameters in its
+
+// SetExpectedSize sets the size of the added content, which is recorded in
+// the cluster DAG and meta pins.
+func (dgs *DAGService) SetExpectedSize(size uint64) {
+	dgs.pinOpts.ExpectedSize = size
+}
+
+// metadata.
+func (dgs *DAGService) Finalize(ctx context.Context, dataRoot cid.Cid) (cid.Cid, error) {
+	if len(dgs.buf) > 0 {
+		if err := dgs.encodeStripe(ctx, dgs.buf); err != nil {
+			return dataRoot, err
+		}
+		dgs.buf = nil
+	}
+	if err := dgs.allocate(ctx); err != nil {
+		return dataRoot, err
+	}
+
+	if !dgs.lastCid.Equals(dataRoot) {
+		logger.Warnf("the last added CID (%s) is not the IPFS data root (%s). This is only normal when adding a single file without wrapping in directory.", dgs.lastCid, dataRoot)
+	}
+
+	clusterDAGLinks := make(map[string]cid.Cid, len(dgs.shards))
+	var prev cid.Cid
+	for i, sh := range dgs.shards {
+		shardCid, err := dgs.flushShard(ctx, i, sh, prev)
+		if err != nil {
+			return dataRoot, err
+		}
+		clusterDAGLinks[strconv.Itoa(i)] = shardCid
+		prev = shardCid
+	}
+
+	clusterDAGNodes, err := sharding.MakeDAG(ctx, clusterDAGLinks)
+	if err != nil {
+		return dataRoot, err
+	}
+
+	// PutDAG to ourselves
+	err = adder.NewBlockAdder(dgs.rpcClient, []peer.ID{""}).AddMany(ctx, clusterDAGNodes)
+	if err != nil {
+		return dataRoot, err
+	}
+
+	clusterDAG := clusterDAGNodes[0].Cid()
+
+	dgs.sendOutput(&api.AddedOutput{
+		Name: fmt.Sprintf("%s-clusterDAG", dgs.pinOpts.Name),
+		Cid:  clusterDAG,
+		Size: dgs.totalSize,
+	})
+
+	clusterDAGPin := api.PinWithOpts(clusterDAG, dgs.pinOpts)
+	clusterDAGPin.ReplicationFactorMin = -1
+	clusterDAGPin.ReplicationFactorMax = -1
+	clusterDAGPin.MaxDepth = 0 // pin direct
+	clusterDAGPin.Name = fmt.Sprintf("%s-clusterDAG", dgs.pinOpts.Name)
+	clusterDAGPin.Type = api.ClusterDAGType
+	clusterDAGPin.Reference = &dataRoot
+	err = adder.Pin(ctx, dgs.rpcClient, clusterDAGPin)
+	if err != nil {
+		return dataRoot, err
+	}
+
+	ec := api.ErasureCoding{
+		DataShards:   dgs.codec.data,
+		ParityShards: dgs.codec.parity,
+		Size:         dgs.totalSize,
+	}
+	metaPin := api.PinWithOpts(dataRoot, dgs.pinOpts)
+	metaPin.Type = api.MetaType
+	metaPin.Reference = &clusterDAG
+	metaPin.MaxDepth = 0 // irrelevant. Meta-pins are not pinned
+	metaPin.Metadata = make(map[string]string, len(dgs.pinOpts.Metadata)+1)
+	for k, v := range dgs.pinOpts.Metadata {
+		metaPin.Metadata[k] = v
+	}
+	metaPin.Metadata[api.ErasureCodingMetaKey] = ec.String()
+	err = adder.Pin(ctx, dgs.rpcClient, metaPin)
+	if err != nil {
+		return dataRoot, err
+	}
+
+	dgs.logStats(metaPin.Cid, clusterDAG)
+	return dataRoot, nil
+}
+
+// allocate assigns a peer to every shard, trying to use a different peer
+// for each of them.
+func (dgs *DAGService) allocate(ctx context.Context) error {
+	if dgs.shards != nil {
+		return nil
+	}
+
+	n := dgs.codec.data + dgs.codec.parity
+	opts := dgs.pinOpts
+	opts.ReplicationFactorMin = 1
+	opts.ReplicationFactorMax = n
+	allocs, err := adder.BlockAllocate(ctx, dgs.rpcClient, opts)
+	if err != nil {
+		return err
+	}
+	if len(allocs) == 0 {
+		return fmt.Errorf("no peers allocated for the shards of '%s'", dgs.pinOpts.Name)
+	}
+	if len(allocs) < n {
+		logger.Warnf("only %d peers for %d shards of '%s': losing a peer loses several shards", len(allocs), n, dgs.pinOpts.Name)
+	}
+
+	dgs.shards = make([]*shard, n)
+	for i := range dgs.shards {
+		p := allocs[i%len(allocs)]
+		dgs.shards[i] = &shard{
+			allocation: p,
+			ba:         adder.NewBlockAdder(dgs.rpcClient, []peer.ID{p}),
+			links:      make(map[string]cid.Cid),
+		}
+	}
+	return nil
+}
+
+// encodeStripe splits the given data in data chunks, computes the parity
+// chunks and sends every chunk to the peer of its shard. The last stripe
+// is padded with zeros.
+func (dgs *DAGService) encodeStripe(ctx context.Context, b []byte) error {
+	if err := dgs.allocate(ctx); err != nil {
+		return err
+	}
+
+	size := (len(b) + dgs.codec.data - 1) / dgs.codec.data
+	data := make([][]byte, dgs.codec.data)
+	for i := range data {
+		chunk := make([]byte, size)
+		if start := i * size; start < len(b) {
+			copy(chunk, b[start:])
+		}
+		data[i] = chunk
+	}
+	chunks := append(data, dgs.codec.encode(data)...)
+
+	for i, chunk := range chunks {
+		sh := dgs.shards[i]
+		node := merkledag.NewRawNode(chunk)
+		if err := sh.ba.Add(ctx, node); err != nil {
+			return err
+		}
+		sh.links[strconv.Itoa(len(sh.links))] = node.Cid()
+		sh.size += uint64(len(chunk))
+	}
+	dgs.totalSize += uint64(len(b))
+	return nil
+}
+
+// flushShard puts the DAG of a shard on its peer and pins it.
+func (dgs *DAGService) flushShard(ctx context.Context, n int, sh *shard, prev cid.Cid) (cid.Cid, error) {
+	nodes, err := sharding.MakeDAG(ctx, sh.links)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if err := sh.ba.AddMany(ctx, nodes); err != nil {
+		return cid.Undef, err
+	}
+
+	rootCid := nodes[0].Cid()
+	pin := api.PinWithOpts(rootCid, dgs.pinOpts)
+	pin.Name = fmt.Sprintf("%s-shard-%d", dgs.pinOpts.Name, n)
+	// parity shards replace replication.
+	pin.ReplicationFactorMin = 1
+	pin.ReplicationFactorMax = 1
+	pin.Allocations = []peer.ID{sh.allocation}
+	pin.Type = api.ShardType
+	pin.Reference = &prev
+	pin.MaxDepth = 1
+	pin.ShardSize = sh.size
+	if len(nodes) > 1 { // using an indirect graph
+		pin.MaxDepth = 2
+	}
+
+	kind := "data"
+	if n >= dgs.codec.data {
+		kind = "parity"
+	}
+	logger.Infof("%s shard #%d (%s) completed. Total size: %s. Chunks: %d",
+		kind,
+		n,
+		rootCid,
+		humanize.Bytes(sh.size),
+		len(sh.links),
+	)
+	dgs.sendOutput(&api.AddedOutput{
+		Name: fmt.Sprintf("shard-%d", n),
+		Cid:  rootCid,
+		Size: sh.size,
+	})
+
+	return rootCid, adder.Pin(ctx, dgs.rpcClient, pin)
+}
+
+func (dgs *DAGService) logStats(metaPin, clusterDAGPin cid.Cid) {
+	duration := time.Since(dgs.startTime)
+	seconds := uint64(duration) / uint64(time.Second)
+	var rate string
+	if seconds == 0 {
+		rate = "∞ B"
+	} else {
+		rate = humanize.Bytes(dgs.totalSize / seconds)
+	}
+
+	statsFmt := `erasure coding session successful:
+CID: %s
+ClusterDAG: %s
+Shards: %d data + %d parity
+Total size: %s
+Total time: %s
+Ingest Rate: %s/s
+`
+
+	logger.Infof(
+		statsFmt,
+		metaPin,
+		clusterDAGPin,
+		dgs.codec.data,
+		dgs.codec.parity,
+		humanize.Bytes(dgs.totalSize),
+		duration,
+		rate,
+	)
+}
+
+func (dgs *DAGService) sendOutput(ao *api.AddedOutput) {
+	if dgs.output != nil {
+		dgs.output <- ao
+	}
+}
@@ -0,0 +1,231 @@
+package erasure
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"mime/multipart"
+	"sync"
+	"testing"
+
+	adder "github.com/ipfs/ipfs-cluster/adder"
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+
+	cid "github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+)
+
+func init() {
+	logging.SetLogLevel("erasuredags", "INFO")
+	logging.SetLogLevel("adder", "INFO")
+}
+
+func TestCodec(t *testing.T) {
+	c, err := newCodec(4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([][]byte, 4)
+	for i := range data {
+		data[i] = make([]byte, 100)
+		rand.Read(data[i])
+	}
+	shards := append(append([][]byte{}, data...), c.encode(data)...)
+
+	// Any 3 missing shards can be recovered.
+	for _, missing := range [][]int{{0, 1, 2}, {1, 3, 5}, {4, 5, 6}, {0, 3, 6}} {
+		damaged := append([][]byte{}, shards...)
+		for _, m := range missing {
+			damaged[m] = nil
+		}
+		if err := c.reconstruct(damaged); err != nil {
+			t.Fatal(err)
+		}
+		for i := range data {
+			if !bytes.Equal(damaged[i], data[i]) {
+				t.Errorf("shard %d was not reconstructed when missing %v", i, missing)
+			}
+		}
+	}
+
+	damaged := append([][]byte{}, shards...)
+	damaged[0], damaged[1], damaged[2], damaged[3] = nil, nil, nil, nil
+	if err := c.reconstruct(damaged); err == nil {
+		t.Error("expected an error with too few shards")
+	}
+
+	if _, err := newCodec(200, 57); err == nil {
+		t.Error("expected an error with too many shards")
+	}
+}
+
+type testRPC struct {
+	blocks sync.Map
+	pins   sync.Map
+}
+
+func (rpcs *testRPC) BlockPut(ctx context.Context, in *api.NodeWithMeta, out *struct{}) error {
+	rpcs.blocks.Store(in.Cid.String(), in.Data)
+	return nil
+}
+
+func (rpcs *testRPC) Pin(ctx context.Context, in *api.Pin, out *api.Pin) error {
+	rpcs.pins.Store(in.Cid.String(), in)
+	*out = *in
+	return nil
+}
+
+func (rpcs *testRPC) BlockAllocate(ctx context.Context, in *api.Pin, out *[]peer.ID) error {
+	// it does not matter since we use host == nil for RPC, so it uses the
+	// local one in all cases
+	*out = []peer.ID{test.PeerID1, test.PeerID2, test.PeerID3}
+	return nil
+}
+
+func (rpcs *testRPC) blockGet(ctx context.Context, c cid.Cid) ([]byte, error) {
+	bI, ok := rpcs.blocks.Load(c.String())
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return bI.([]byte), nil
+}
+
+func (rpcs *testRPC) pinGet(c cid.Cid) *api.Pin {
+	pI, ok := rpcs.pins.Load(c.String())
+	if !ok {
+		return nil
+	}
+	return pI.(*api.Pin)
+}
+
+func makeAdder(t *testing.T, params *api.AddParams) (*adder.Adder, *testRPC) {
+	rpcObj := &testRPC{}
+	server := rpc.NewServer(nil, "mock")
+	err := server.RegisterName("Cluster", rpcObj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.RegisterName("IPFSConnector", rpcObj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.NewClientWithServer(nil, "mock", server)
+
+	out := make(chan *api.AddedOutput, 1)
+
+	dags, err := New(client, params.PinOptions, params.ErasureDataShards, params.ErasureParityShards, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	add := adder.New(dags, params, out)
+
+	go func() {
+		for v := range out {
+			t.Logf("Output: Name: %s. Cid: %s. Size: %d", v.Name, v.Cid, v.Size)
+		}
+	}()
+
+	return add, rpcObj
+}
+
+func TestFromMultipart(t *testing.T) {
+	defer func(n int) { ChunkSize = n }(ChunkSize)
+	ChunkSize = 16 * 1024
+
+	sth := test.NewShardingTestHelper()
+	defer sth.Clean(t)
+
+	p := api.DefaultAddParams()
+	p.Name = "testingFile"
+	p.ErasureDataShards = 3
+	p.ErasureParityShards = 2
+
+	add, rpcObj := makeAdder(t, p)
+
+	mr, closer := sth.GetTreeMultiReader(t)
+	defer closer.Close()
+	r := multipart.NewReader(mr, mr.Boundary())
+
+	rootCid, err := add.FromMultipart(context.Background(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rootCid.String() != test.ShardingDirBalancedRootCID {
+		t.Fatal("bad root CID")
+	}
+
+	metaPin := rpcObj.pinGet(rootCid)
+	if metaPin == nil || metaPin.Type != api.MetaType {
+		t.Fatal("expected a meta pin")
+	}
+	ec, err := api.ParseErasureCoding(metaPin.Metadata[api.ErasureCodingMetaKey])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ec.DataShards != 3 || ec.ParityShards != 2 || ec.Size == 0 {
+		t.Fatalf("unexpected erasure coding: %+v", ec)
+	}
+	clusterDAGPin := rpcObj.pinGet(*metaPin.Reference)
+	if clusterDAGPin == nil || clusterDAGPin.Type != api.ClusterDAGType {
+		t.Fatal("expected a cluster DAG pin")
+	}
+
+	ctx := context.Background()
+	links, err := Shards(ctx, rpcObj.blockGet, clusterDAGPin.Cid, ec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 5 {
+		t.Fatalf("expected 5 shards, got %d", len(links))
+	}
+	chunks := make([][]cid.Cid, len(links))
+	for i, l := range links {
+		pin := rpcObj.pinGet(l)
+		if pin == nil || pin.Type != api.ShardType || len(pin.Allocations) != 1 || pin.ReplicationFactorMax != 1 {
+			t.Fatalf("unexpected shard pin: %+v", pin)
+		}
+		chunks[i], err = ShardChunks(ctx, rpcObj.blockGet, l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(chunks[i]) < 2 {
+			t.Fatal("expected several stripes")
+		}
+	}
+
+	decode := func(shards ...int) (map[string]struct{}, error) {
+		use := make([][]cid.Cid, len(chunks))
+		for _, s := range shards {
+			use[s] = chunks[s]
+		}
+		blocks := make(map[string]struct{})
+		_, err := Decode(ctx, ec, use, rpcObj.blockGet, func(n *api.NodeWithMeta) error {
+			blocks[n.Cid.String()] = struct{}{}
+			return nil
+		})
+		return blocks, err
+	}
+
+	for _, shards := range [][]int{{0, 1, 2}, {0, 3, 4}, {2, 3, 4}} {
+		blocks, err := decode(shards...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(blocks) != len(test.ShardingDirCids) {
+			t.Errorf("expected %d blocks from shards %v, got %d", len(test.ShardingDirCids), shards, len(blocks))
+		}
+		for _, ci := range test.ShardingDirCids {
+			if _, ok := blocks[ci]; !ok {
+				t.Errorf("block %s missing when decoding shards %v", ci, shards)
+			}
+		}
+	}
+
+	if _, err := decode(1, 4); err == nil {
+		t.Error("expected an error decoding 2 shards")
+	}
+}
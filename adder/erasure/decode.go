@@ -0,0 +1,222 @@
+package erasure
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/ipfs/ipfs-cluster/adder/sharding"
+	"github.com/ipfs/ipfs-cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// The encoded stream is a sequence of records, one per block of the
+// original DAG: the length of the CID, the CID, the length of the data and
+// the data, lengths being unsigned varints.
+
+func appendRecord(buf []byte, c cid.Cid, data []byte) []byte {
+	var lenBuf [binary.MaxVarintLen64]byte
+	cidBytes := c.Bytes()
+	n := binary.PutUvarint(lenBuf[:], uint64(len(cidBytes)))
+	buf = append(buf, lenBuf[:n]...)
+	buf = append(buf, cidBytes...)
+	n = binary.PutUvarint(lenBuf[:], uint64(len(data)))
+	buf = append(buf, lenBuf[:n]...)
+	return append(buf, data...)
+}
+
+// readRecord parses the record at the start of buf. It returns the number
+// of bytes read, which is 0 when the record is not complete.
+func readRecord(buf []byte) (*api.NodeWithMeta, int, error) {
+	read := 0
+	next := func() ([]byte, bool, error) {
+		l, n := binary.Uvarint(buf[read:])
+		if n < 0 {
+			return nil, false, errors.New("bad record length")
+		}
+		if n == 0 || uint64(len(buf[read+n:])) < l {
+			return nil, false, nil
+		}
+		b := buf[read+n : read+n+int(l)]
+		read += n + int(l)
+		return b, true, nil
+	}
+
+	cidBytes, ok, err := next()
+	if !ok || err != nil {
+		return nil, 0, err
+	}
+	data, ok, err := next()
+	if !ok || err != nil {
+		return nil, 0, err
+	}
+
+	c, err := cid.Cast(cidBytes)
+	if err != nil {
+		return nil, 0, fmt.Errorf("bad record CID: %w", err)
+	}
+	sum, err := c.Prefix().Sum(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !sum.Equals(c) {
+		return nil, 0, fmt.Errorf("the data of %s does not match its CID", c)
+	}
+	return &api.NodeWithMeta{
+		Cid:  c,
+		Data: append([]byte{}, data...),
+	}, read, nil
+}
+
+// BlockGetter returns the raw data of a block.
+type BlockGetter func(ctx context.Context, c cid.Cid) ([]byte, error)
+
+// Shards returns the CIDs of the data and parity shards linked from the
+// cluster DAG of erasure-coded content, in order.
+func Shards(ctx context.Context, get BlockGetter, clusterDAG cid.Cid, ec api.ErasureCoding) ([]cid.Cid, error) {
+	raw, err := get(ctx, clusterDAG)
+	if err != nil {
+		return nil, fmt.Errorf("error reading clusterDAG block: %w", err)
+	}
+	node, err := sharding.CborDataToNode(raw, "cbor")
+	if err != nil {
+		return nil, fmt.Errorf("error parsing clusterDAG block: %w", err)
+	}
+
+	n := ec.DataShards + ec.ParityShards
+	if len(node.Links()) != n {
+		return nil, fmt.Errorf("clusterDAG %s has %d shards, expected %d", clusterDAG, len(node.Links()), n)
+	}
+	return orderedLinks(node)
+}
+
+// ShardChunks returns the CIDs of the chunks of an erasure-coded shard, in
+// order, reading the shard DAG with the given BlockGetter.
+func ShardChunks(ctx context.Context, get BlockGetter, shard cid.Cid) ([]cid.Cid, error) {
+	raw, err := get(ctx, shard)
+	if err != nil {
+		return nil, err
+	}
+	node, err := sharding.CborDataToNode(raw, "cbor")
+	if err != nil {
+		return nil, err
+	}
+
+	links, err := orderedLinks(node)
+	if err != nil {
+		return nil, err
+	}
+
+	var chunks []cid.Cid
+	for _, l := range links {
+		if l.Type() != cid.DagCBOR {
+			chunks = append(chunks, l)
+			continue
+		}
+		// indirect node
+		sub, err := ShardChunks(ctx, get, l)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, sub...)
+	}
+	return chunks, nil
+}
+
+// orderedLinks returns the links of a cluster DAG node, which are named
+// after their position.
+func orderedLinks(node ipld.Node) ([]cid.Cid, error) {
+	links := make([]cid.Cid, len(node.Links()))
+	for i := range links {
+		l, _, err := node.ResolveLink([]string{strconv.Itoa(i)})
+		if err != nil {
+			return nil, fmt.Errorf("link %d of %s: %w", i, node.Cid(), err)
+		}
+		links[i] = l.Cid
+	}
+	return links, nil
+}
+
+// Decode reconstructs the blocks of erasure-coded content and calls put
+// with every one of them. chunks has the chunk CIDs of every data and
+// parity shard, or nil for the shards which should not be used: at least
+// ec.DataShards shards must be given. It returns the number of blocks
+// reconstructed.
+func Decode(ctx context.Context, ec api.ErasureCoding, chunks [][]cid.Cid, get BlockGetter, put func(*api.NodeWithMeta) error) (uint64, error) {
+	c, err := newCodec(ec.DataShards, ec.ParityShards)
+	if err != nil {
+		return 0, err
+	}
+	if len(chunks) != ec.DataShards+ec.ParityShards {
+		return 0, fmt.Errorf("expected %d shards, got %d", ec.DataShards+ec.ParityShards, len(chunks))
+	}
+
+	stripes := -1
+	for i, cs := range chunks {
+		if cs == nil {
+			continue
+		}
+		if stripes >= 0 && len(cs) != stripes {
+			return 0, fmt.Errorf("shard %d has %d chunks, expected %d", i, len(cs), stripes)
+		}
+		stripes = len(cs)
+	}
+
+	var blocks uint64
+	var pending []byte
+	remaining := ec.Size
+	for s := 0; s < stripes && remaining > 0; s++ {
+		shards := make([][]byte, len(chunks))
+		size := -1
+		for i, cs := range chunks {
+			if cs == nil {
+				continue
+			}
+			b, err := get(ctx, cs[s])
+			if err != nil {
+				return blocks, fmt.Errorf("error reading chunk %d of shard %d: %w", s, i, err)
+			}
+			if size >= 0 && len(b) != size {
+				return blocks, fmt.Errorf("chunk %d of shard %d has a wrong size", s, i)
+			}
+			size = len(b)
+			shards[i] = b
+		}
+		if err := c.reconstruct(shards); err != nil {
+			return blocks, err
+		}
+
+		for _, d := range shards[:ec.DataShards] {
+			if uint64(len(d)) > remaining {
+				d = d[:remaining] // padding
+			}
+			remaining -= uint64(len(d))
+			pending = append(pending, d...)
+		}
+
+		for {
+			node, n, err := readRecord(pending)
+			if err != nil {
+				return blocks, err
+			}
+			if n == 0 {
+				break
+			}
+			if err := put(node); err != nil {
+				return blocks, err
+			}
+			blocks++
+			pending = pending[n:]
+		}
+		pending = append([]byte{}, pending...)
+	}
+
+	if remaining > 0 || len(pending) > 0 {
+		return blocks, errors.New("the erasure-coded data is truncated")
+	}
+	return blocks, nil
+}
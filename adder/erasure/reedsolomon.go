@@ -0,0 +1,202 @@
+package erasure
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/ipfs-cluster/api"
+)
+
+// Reed-Solomon coding over GF(2^8). The encoding matrix is systematic: the
+// first rows are the identity, so data shards are stored as they are, and
+// the parity rows form a Cauchy matrix, so that any set of rows as large
+// as the number of data shards can be inverted.
+
+// gfPoly is the primitive polynomial x^8 + x^4 + x^3 + x^2 + 1.
+const gfPoly = 0x11d
+
+var (
+	gfExp [510]byte
+	gfLog [256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfExp[i+255] = byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= gfPoly
+		}
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfInv(a byte) byte {
+	return gfExp[255-int(gfLog[a])]
+}
+
+// mulAdd sets dst[i] ^= c * src[i].
+func mulAdd(dst, src []byte, c byte) {
+	if c == 0 {
+		return
+	}
+	logc := int(gfLog[c])
+	for i, b := range src {
+		if b != 0 {
+			dst[i] ^= gfExp[logc+int(gfLog[b])]
+		}
+	}
+}
+
+// codec encodes data shards into parity shards and reconstructs data
+// shards from any large enough set of shards.
+type codec struct {
+	data   int
+	parity int
+	// parity rows of the encoding matrix.
+	cauchy [][]byte
+}
+
+func newCodec(data, parity int) (*codec, error) {
+	ec := api.ErasureCoding{DataShards: data, ParityShards: parity}
+	if err := ec.Validate(); err != nil {
+		return nil, err
+	}
+	cauchy := make([][]byte, parity)
+	for i := range cauchy {
+		cauchy[i] = make([]byte, data)
+		for j := range cauchy[i] {
+			// data+i and j never collide, so this is never 0.
+			cauchy[i][j] = gfInv(byte(data+i) ^ byte(j))
+		}
+	}
+	return &codec{data: data, parity: parity, cauchy: cauchy}, nil
+}
+
+// row returns the row of the encoding matrix for the given shard.
+func (c *codec) row(shard int) []byte {
+	if shard >= c.data {
+		return c.cauchy[shard-c.data]
+	}
+	row := make([]byte, c.data)
+	row[shard] = 1
+	return row
+}
+
+// encode computes the parity shards from the data shards. All the shards
+// must have the same size.
+func (c *codec) encode(data [][]byte) [][]byte {
+	parity := make([][]byte, c.parity)
+	for i := range parity {
+		parity[i] = make([]byte, len(data[0]))
+		for j, d := range data {
+			mulAdd(parity[i], d, c.cauchy[i][j])
+		}
+	}
+	return parity
+}
+
+// reconstruct fills in the missing (nil) data shards from the given
+// shards, of which there must be at least as many as data shards. Parity
+// shards are not reconstructed.
+func (c *codec) reconstruct(shards [][]byte) error {
+	if len(shards) != c.data+c.parity {
+		return fmt.Errorf("expected %d shards, got %d", c.data+c.parity, len(shards))
+	}
+
+	var missing bool
+	for _, s := range shards[:c.data] {
+		if s == nil {
+			missing = true
+			break
+		}
+	}
+	if !missing {
+		return nil
+	}
+
+	var rows []int
+	for i, s := range shards {
+		if s != nil {
+			rows = append(rows, i)
+		}
+		if len(rows) == c.data {
+			break
+		}
+	}
+	if len(rows) < c.data {
+		return fmt.Errorf("%d shards are needed, only %d are available", c.data, len(rows))
+	}
+
+	m := make([][]byte, c.data)
+	for i, r := range rows {
+		m[i] = append([]byte{}, c.row(r)...)
+	}
+	inv, err := invert(m)
+	if err != nil {
+		return err
+	}
+
+	size := len(shards[rows[0]])
+	for d := 0; d < c.data; d++ {
+		if shards[d] != nil {
+			continue
+		}
+		out := make([]byte, size)
+		for i, r := range rows {
+			mulAdd(out, shards[r], inv[d][i])
+		}
+		shards[d] = out
+	}
+	return nil
+}
+
+// invert returns the inverse of a square matrix using Gauss-Jordan
+// elimination. The given matrix is modified.
+func invert(m [][]byte) ([][]byte, error) {
+	n := len(m)
+	inv := make([][]byte, n)
+	for i := range inv {
+		inv[i] = make([]byte, n)
+		inv[i][i] = 1
+	}
+
+	for col := 0; col < n; col++ {
+		pivot := -1
+		for r := col; r < n; r++ {
+			if m[r][col] != 0 {
+				pivot = r
+				break
+			}
+		}
+		if pivot < 0 {
+			return nil, errors.New("singular matrix")
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		inv[col], inv[pivot] = inv[pivot], inv[col]
+
+		c := gfInv(m[col][col])
+		for j := 0; j < n; j++ {
+			m[col][j] = gfMul(m[col][j], c)
+			inv[col][j] = gfMul(inv[col][j], c)
+		}
+		for r := 0; r < n; r++ {
+			if r == col || m[r][col] == 0 {
+				continue
+			}
+			f := m[r][col]
+			mulAdd(m[r], m[col], f)
+			mulAdd(inv[r], inv[col], f)
+		}
+	}
+	return inv, nil
+}
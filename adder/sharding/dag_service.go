@@ -76,6 +76,13 @@ func (dgs *DAGService) Add(ctx context.Context, node ipld.Node) error {
 }
 
 // Finalize finishes sharding, creates the cluster DAG and pins it along
+
+// SetExpectedSize sets the size of the added content, which is recorded in
+// the cluster DAG and meta pins.
+func (dgs *DAGService) SetExpectedSize(size uint64) {
+	dgs.pinOpts.ExpectedSize = size
+}
+
 // with the meta pin for the root node of the content.
 func (dgs *DAGService) Finalize(ctx context.Context, dataRoot cid.Cid) (cid.Cid, error) {
 	lastCid, err := dgs.flushCurrentShard(ctx)
@@ -87,7 +94,7 @@ func (dgs *DAGService) Finalize(ctx context.Context, dataRoot cid.Cid) (cid.Cid,
 		logger.Warnf("the last added CID (%s) is not the IPFS data root (%s). This is only normal when adding a single file without wrapping in directory.", lastCid, dataRoot)
 	}
 
-	clusterDAGNodes, err := makeDAG(ctx, dgs.shards)
+	clusterDAGNodes, err := MakeDAG(ctx, dgs.shards)
 	if err != nil {
 		return dataRoot, err
 	}
@@ -153,6 +160,23 @@ func (dgs *DAGService) Finalize(ctx context.Context, dataRoot cid.Cid) (cid.Cid,
 	return dataRoot, nil
 }
 
+// Abort unpins the shards which have been flushed, as they are pinned
+// while the content is added.
+func (dgs *DAGService) Abort(ctx context.Context) error {
+	var lastErr error
+	for n, shardCid := range dgs.shards {
+		pin := api.PinCid(shardCid)
+		pin.Type = api.ShardType
+		if err := adder.Unpin(ctx, dgs.rpcClient, pin); err != nil {
+			logger.Errorf("error unpinning shard %s (%s): %s", n, shardCid, err)
+			lastErr = err
+			continue
+		}
+		delete(dgs.shards, n)
+	}
+	return lastErr
+}
+
 // ingests a block to the current shard. If it get's full, it
 // Flushes the shard and retries with a new one.
 func (dgs *DAGService) ingestBlock(ctx context.Context, n ipld.Node) error {
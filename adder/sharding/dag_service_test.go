@@ -38,6 +38,11 @@ func (rpcs *testRPC) Pin(ctx context.Context, in *api.Pin, out *api.Pin) error {
 	return nil
 }
 
+func (rpcs *testRPC) LogUnpin(ctx context.Context, in *api.Pin, out *struct{}) error {
+	rpcs.pins.Delete(in.Cid.String())
+	return nil
+}
+
 func (rpcs *testRPC) BlockAllocate(ctx context.Context, in *api.Pin, out *[]peer.ID) error {
 	if in.ReplicationFactorMin > 1 {
 		return errors.New("we can only replicate to 1 peer")
@@ -75,6 +80,10 @@ func makeAdder(t *testing.T, params *api.AddParams) (*adder.Adder, *testRPC) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	err = server.RegisterName("Consensus", rpcObj)
+	if err != nil {
+		t.Fatal(err)
+	}
 	client := rpc.NewClientWithServer(nil, "mock", server)
 
 	out := make(chan *api.AddedOutput, 1)
@@ -267,3 +276,40 @@ func TestFromMultipart_Errors(t *testing.T) {
 		f.Close()
 	}
 }
+
+func TestFromMultipart_AbortUnpinsShards(t *testing.T) {
+	sth := test.NewShardingTestHelper()
+	defer sth.Clean(t)
+
+	p := api.DefaultAddParams()
+	p.ShardSize = 1024 * 300
+	p.Name = "testingFile"
+	p.Shard = true
+	p.ReplicationFactorMin = 1
+	p.ReplicationFactorMax = 2
+
+	add, rpcObj := makeAdder(t, p)
+	add.SetVerifier(func() error {
+		shards := 0
+		rpcObj.pins.Range(func(k, v interface{}) bool {
+			shards++
+			return true
+		})
+		if shards == 0 {
+			t.Error("expected some shards to be pinned while adding")
+		}
+		return errors.New("corrupted")
+	})
+
+	mr, closer := sth.GetTreeMultiReader(t)
+	defer closer.Close()
+	r := multipart.NewReader(mr, mr.Boundary())
+
+	if _, err := add.FromMultipart(context.Background(), r); err == nil {
+		t.Fatal("expected an error")
+	}
+	rpcObj.pins.Range(func(k, v interface{}) bool {
+		t.Error("the shards should have been unpinned:", k)
+		return true
+	})
+}
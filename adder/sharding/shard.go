@@ -79,7 +79,7 @@ func (sh *shard) Allocations() []peer.ID {
 // shard.
 func (sh *shard) Flush(ctx context.Context, shardN int, prev cid.Cid) (cid.Cid, error) {
 	logger.Debugf("shard %d: flush", shardN)
-	nodes, err := makeDAG(ctx, sh.dagNode)
+	nodes, err := MakeDAG(ctx, sh.dagNode)
 	if err != nil {
 		return cid.Undef, err
 	}
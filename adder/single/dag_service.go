@@ -65,6 +65,12 @@ func (dgs *DAGService) Add(ctx context.Context, node ipld.Node) error {
 	return dgs.ba.Add(ctx, node)
 }
 
+// SetExpectedSize sets the size of the added content, which is recorded in
+// the root pin.
+func (dgs *DAGService) SetExpectedSize(size uint64) {
+	dgs.pinOpts.ExpectedSize = size
+}
+
 // Finalize pins the last Cid added to this DAGService.
 func (dgs *DAGService) Finalize(ctx context.Context, root cid.Cid) (cid.Cid, error) {
 	// Cluster pin the result
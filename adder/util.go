@@ -138,6 +138,21 @@ func Pin(ctx context.Context, rpc *rpc.Client, pin *api.Pin) error {
 	)
 }
 
+// Unpin helps removing pins which were sent with Pin from the shared state
+// when an add fails. Shards cannot be unpinned through the Cluster, so the
+// consensus layer is used directly.
+func Unpin(ctx context.Context, rpc *rpc.Client, pin *api.Pin) error {
+	logger.Debugf("adder unpinning %s", pin.Cid)
+	return rpc.CallContext(
+		ctx,
+		"",
+		"Consensus",
+		"LogUnpin",
+		pin,
+		&struct{}{},
+	)
+}
+
 // ErrDAGNotFound is returned whenever we try to get a block from the DAGService.
 var ErrDAGNotFound = errors.New("dagservice: block not found")
 
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 
 	cid "github.com/ipfs/go-cid"
 	peer "github.com/libp2p/go-libp2p-core/peer"
@@ -11,6 +13,7 @@ import (
 	"go.opencensus.io/trace"
 
 	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/informer/disk"
 )
 
 // This file gathers allocation logic used when pinning or re-pinning
@@ -108,6 +111,117 @@ func (c *Cluster) allocate(ctx context.Context, hash cid.Cid, currentPin *api.Pi
 	return newAllocs, nil
 }
 
+// resolveExpectedSize sets the ExpectedSize of the pin by asking IPFS for
+// the DAG size, when not set already and ResolveDAGSize is enabled. Errors
+// are only logged, as the size is not required for pinning.
+func (c *Cluster) resolveExpectedSize(ctx context.Context, pin *api.Pin) {
+	if pin.ExpectedSize > 0 || !c.config.ResolveDAGSize || pin.IsPinEverywhere() {
+		return
+	}
+
+	ctx, span := trace.StartSpan(ctx, "cluster/resolveExpectedSize")
+	defer span.End()
+
+	size, err := c.ipfs.DAGSize(ctx, pin.Cid)
+	if err != nil {
+		logger.Warnf("could not obtain the size of %s: %s", pin.Cid, err)
+		return
+	}
+	pin.ExpectedSize = size
+}
+
+// setupExcludedAllocations adds the peers excluded by
+// cluster.allocation_exclusions for the metadata of the pin to its
+// ExcludeAllocations. Excluded peers cannot be user allocations.
+func (c *Cluster) setupExcludedAllocations(pin *api.Pin) error {
+	var excluded []peer.ID
+	add := func(peers []peer.ID) {
+		for _, p := range peers {
+			if !containsPeer(excluded, p) {
+				excluded = append(excluded, p)
+			}
+		}
+	}
+	add(pin.ExcludeAllocations)
+	for k, v := range pin.Metadata {
+		add(c.config.AllocationExclusions[k+"="+v])
+	}
+	sort.Slice(excluded, func(i, j int) bool {
+		return excluded[i] < excluded[j]
+	})
+	pin.ExcludeAllocations = excluded
+
+	for _, p := range pin.UserAllocations {
+		if containsPeer(excluded, p) {
+			return fmt.Errorf("peer %s is excluded from the allocations of %s", p, pin.Cid)
+		}
+	}
+	return nil
+}
+
+// lowSpacePeers returns the peers for which the last freespace metric is
+// smaller than the given size. Peers without freespace metrics are not
+// included.
+func (c *Cluster) lowSpacePeers(ctx context.Context, size uint64) []peer.ID {
+	if size == 0 {
+		return nil
+	}
+
+	var peers []peer.ID
+	for _, m := range c.monitor.LatestMetrics(ctx, disk.MetricFreeSpace.String()) {
+		free, err := strconv.ParseUint(m.Value, 10, 64)
+		if err != nil {
+			continue
+		}
+		if free < size {
+			logger.Debugf("%s has not enough free space (%d < %d)", m.Peer, free, size)
+			peers = append(peers, m.Peer)
+		}
+	}
+	return peers
+}
+
+// checkFreeSpace returns an error wrapping api.ErrInsufficientFreeSpace
+// when cluster.min_free_space is set and fewer peers than the minimum
+// replication factor of the pin have that much free space. At least one
+// peer is needed for pins replicated everywhere. Excluded peers are not
+// counted. When no peer reports freespace metrics (i.e. the disk informer
+// is not used) the check cannot be done and the pin is allowed.
+func (c *Cluster) checkFreeSpace(ctx context.Context, pin *api.Pin) error {
+	minFree := c.config.MinFreeSpace
+	if minFree == 0 {
+		return nil
+	}
+
+	needed := pin.ReplicationFactorMin
+	if needed < 1 {
+		needed = 1
+	}
+	metrics := c.monitor.LatestMetrics(ctx, disk.MetricFreeSpace.String())
+	if len(metrics) == 0 {
+		logger.Warnf("min_free_space is set but no peer reports %s metrics: not checking free space", disk.MetricFreeSpace)
+		return nil
+	}
+
+	found := 0
+	for _, m := range metrics {
+		if containsPeer(pin.ExcludeAllocations, m.Peer) {
+			continue
+		}
+		free, err := strconv.ParseUint(m.Value, 10, 64)
+		if err != nil {
+			continue
+		}
+		if free >= minFree {
+			found++
+		}
+	}
+	if found < needed {
+		return fmt.Errorf("%w: %d peers have %d bytes free, %d needed", api.ErrInsufficientFreeSpace, found, minFree, needed)
+	}
+	return nil
+}
+
 // Given metrics from all informers, split them into 3 MetricsSet:
 // - Those corresponding to currently allocated peers
 // - Those corresponding to priority allocations
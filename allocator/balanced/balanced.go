@@ -5,12 +5,17 @@
 // For example, allocating by ["tag:region", "disk"] the resulting peer
 // candidate order will balanced between regions and ordered by the value of
 // the weight of the disk metric.
+//
+// The weights of the metrics of each peer are multiplied by the value of its
+// "weight_by" metric, when available, so that operators can make some peers
+// attract more pins than others.
 package balanced
 
 import (
 	"context"
 	"fmt"
 	"sort"
+	"strconv"
 
 	cid "github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log/v2"
@@ -70,11 +75,12 @@ type partition struct {
 // Returns a partitionedMetric which has partitions and subpartitions based
 // on the metrics and values given by the "by" slice. The partitions
 // are ordered based on the cumulative weight.
-func partitionMetrics(set api.MetricsSet, by []string) *partitionedMetric {
+// Metric weights are multiplied by the weights of their peers, when given.
+func partitionMetrics(set api.MetricsSet, by []string, peerWeights map[peer.ID]float64) *partitionedMetric {
 	rootMetric := by[0]
 	pnedMetric := &partitionedMetric{
 		metricName: rootMetric,
-		partitions: partitionValues(set[rootMetric]),
+		partitions: partitionValues(set[rootMetric], peerWeights),
 	}
 
 	// For sorting based on weight (more to less)
@@ -109,7 +115,7 @@ func partitionMetrics(set api.MetricsSet, by []string) *partitionedMetric {
 			}
 		}
 
-		partition.sub = partitionMetrics(filteredSet, by[1:])
+		partition.sub = partitionMetrics(filteredSet, by[1:], peerWeights)
 		// Add the weight of our subpartitions
 		for _, subp := range partition.sub.partitions {
 			partition.weight += subp.weight
@@ -119,7 +125,7 @@ func partitionMetrics(set api.MetricsSet, by []string) *partitionedMetric {
 	return pnedMetric
 }
 
-func partitionValues(metrics []*api.Metric) []*partition {
+func partitionValues(metrics []*api.Metric, peerWeights map[peer.ID]float64) []*partition {
 	partitions := []*partition{}
 
 	if len(metrics) <= 0 {
@@ -130,6 +136,11 @@ func partitionValues(metrics []*api.Metric) []*partition {
 	partitionsByValue := make(map[string]*partition)
 
 	for _, m := range metrics {
+		weight := m.GetWeight()
+		if w, ok := peerWeights[m.Peer]; ok {
+			weight = int64(float64(weight) * w)
+		}
+
 		// Sometimes two metrics have the same value / weight, but we
 		// still want to put them in different partitions. Otherwise
 		// their weights get added and they form a bucket and
@@ -148,7 +159,7 @@ func partitionValues(metrics []*api.Metric) []*partition {
 		if !m.Partitionable {
 			partitions = append(partitions, &partition{
 				value:  m.Value,
-				weight: m.GetWeight(),
+				weight: weight,
 				peers: map[peer.ID]bool{
 					m.Peer: false,
 				},
@@ -159,11 +170,11 @@ func partitionValues(metrics []*api.Metric) []*partition {
 		// Any other case, we partition by value.
 		if p, ok := partitionsByValue[m.Value]; ok {
 			p.peers[m.Peer] = false
-			p.weight += m.GetWeight()
+			p.weight += weight
 		} else {
 			partitionsByValue[m.Value] = &partition{
 				value:  m.Value,
-				weight: m.GetWeight(),
+				weight: weight,
 				peers: map[peer.ID]bool{
 					m.Peer: false,
 				},
@@ -267,8 +278,9 @@ func (a *Allocator) Allocate(
 	//
 	// Otherwise, the sorting might be funny.
 
-	candidatePartition := partitionMetrics(candidates, a.config.AllocateBy)
-	priorityPartition := partitionMetrics(priority, a.config.AllocateBy)
+	peerWeights := a.peerWeights(ctx)
+	candidatePartition := partitionMetrics(candidates, a.config.AllocateBy, peerWeights)
+	priorityPartition := partitionMetrics(priority, a.config.AllocateBy, peerWeights)
 
 	logger.Debugf("Balanced allocator partitions:\n%s\n", printPartition(candidatePartition, 0))
 
@@ -278,6 +290,40 @@ func (a *Allocator) Allocate(
 	return append(first, last...), nil
 }
 
+// peerWeights returns the weights of the peers which publish the WeightBy
+// metric. They are obtained separately from the metrics used for
+// allocation because the peers without them can still be allocated.
+func (a *Allocator) peerWeights(ctx context.Context) map[peer.ID]float64 {
+	if a.config.WeightBy == "" || a.rpcClient == nil {
+		return nil
+	}
+
+	var metrics []*api.Metric
+	err := a.rpcClient.CallContext(
+		ctx,
+		"",
+		"PeerMonitor",
+		"LatestMetrics",
+		a.config.WeightBy,
+		&metrics,
+	)
+	if err != nil {
+		logger.Warnf("error obtaining %s metrics: %s", a.config.WeightBy, err)
+		return nil
+	}
+
+	peerWeights := make(map[peer.ID]float64, len(metrics))
+	for _, m := range metrics {
+		w, err := strconv.ParseFloat(m.Value, 64)
+		if err != nil || w <= 0 {
+			logger.Debugf("ignoring bad %s metric from %s: %s", m.Name, m.Peer, m.Value)
+			continue
+		}
+		peerWeights[m.Peer] = w
+	}
+	return peerWeights
+}
+
 // Metrics returns the names of the metrics that have been registered
 // with this allocator.
 func (a *Allocator) Metrics() []string {
@@ -8,6 +8,7 @@ import (
 	api "github.com/ipfs/ipfs-cluster/api"
 	"github.com/ipfs/ipfs-cluster/test"
 	peer "github.com/libp2p/go-libp2p-core/peer"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
 )
 
 func makeMetric(name, value string, weight int64, peer peer.ID, partitionable bool) *api.Metric {
@@ -141,3 +142,61 @@ func TestAllocate(t *testing.T) {
 		}
 	}
 }
+
+type weightsRPCService struct {
+	metrics []*api.Metric
+}
+
+func (mock *weightsRPCService) LatestMetrics(ctx context.Context, in string, out *[]*api.Metric) error {
+	if in == DefaultWeightBy {
+		*out = mock.metrics
+	}
+	return nil
+}
+
+func TestAllocateWithWeights(t *testing.T) {
+	alloc, err := New(&Config{
+		AllocateBy: []string{"freespace"},
+		WeightBy:   DefaultWeightBy,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := rpc.NewServer(nil, "mock")
+	err = s.RegisterName("PeerMonitor", &weightsRPCService{
+		metrics: []*api.Metric{
+			makeMetric(DefaultWeightBy, "3", 0, test.PeerID1, false),
+			makeMetric(DefaultWeightBy, "0.5", 0, test.PeerID3, false),
+			makeMetric(DefaultWeightBy, "bad", 0, test.PeerID4, false),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alloc.SetClient(rpc.NewClientWithServer(nil, "mock", s))
+
+	candidates := api.MetricsSet{
+		"freespace": []*api.Metric{
+			makeMetric("freespace", "100", 100, test.PeerID1, false), // 300
+			makeMetric("freespace", "200", 200, test.PeerID2, false), // 200, no weight
+			makeMetric("freespace", "500", 500, test.PeerID3, false), // 250
+			makeMetric("freespace", "150", 150, test.PeerID4, false), // 150, bad weight
+		},
+	}
+
+	peers, err := alloc.Allocate(context.Background(), test.Cid1, nil, candidates, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []peer.ID{test.PeerID1, test.PeerID3, test.PeerID2, test.PeerID4}
+	if len(peers) != len(expected) {
+		t.Fatalf("wrong number of peers: %s", peers)
+	}
+	for i, p := range peers {
+		if p != expected[i] {
+			t.Errorf("wrong id in pos %d: %s", i, p)
+		}
+	}
+}
@@ -14,6 +14,7 @@ const envConfigKey = "cluster_balanced"
 // These are the default values for a Config.
 var (
 	DefaultAllocateBy = []string{"tag:group", "freespace"}
+	DefaultWeightBy   = "weight"
 )
 
 // Config allows to initialize the Allocator.
@@ -21,10 +22,15 @@ type Config struct {
 	config.Saver
 
 	AllocateBy []string
+	// WeightBy is the name of the metric with the preference weight of
+	// each peer. The weights of the metrics of a peer are multiplied by
+	// it. Peers without it have a weight of 1.
+	WeightBy string
 }
 
 type jsonConfig struct {
 	AllocateBy []string `json:"allocate_by"`
+	WeightBy   string   `json:"weight_by"`
 }
 
 // ConfigKey returns a human-friendly identifier for this
@@ -36,6 +42,7 @@ func (cfg *Config) ConfigKey() string {
 // Default initializes this Config with sensible values.
 func (cfg *Config) Default() error {
 	cfg.AllocateBy = DefaultAllocateBy
+	cfg.WeightBy = DefaultWeightBy
 	return nil
 }
 
@@ -80,6 +87,9 @@ func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
 	if len(jcfg.AllocateBy) > 0 {
 		cfg.AllocateBy = jcfg.AllocateBy
 	}
+	if jcfg.WeightBy != "" {
+		cfg.WeightBy = jcfg.WeightBy
+	}
 
 	return cfg.Validate()
 }
@@ -94,6 +104,7 @@ func (cfg *Config) ToJSON() ([]byte, error) {
 func (cfg *Config) toJSONConfig() *jsonConfig {
 	return &jsonConfig{
 		AllocateBy: cfg.AllocateBy,
+		WeightBy:   cfg.WeightBy,
 	}
 }
 
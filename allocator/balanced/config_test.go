@@ -7,7 +7,8 @@ import (
 
 var cfgJSON = []byte(`
 {
-      "allocate_by": ["tag", "disk"]
+      "allocate_by": ["tag", "disk"],
+      "weight_by": "preference"
 }
 `)
 
@@ -31,7 +32,7 @@ func TestToJSON(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(cfg.AllocateBy) != 2 {
+	if len(cfg.AllocateBy) != 2 || cfg.WeightBy != "preference" {
 		t.Error("configuration was lost in serialization/deserialization")
 	}
 }
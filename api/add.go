@@ -1,12 +1,15 @@
 package api
 
 import (
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"net/url"
 	"strconv"
+	"strings"
 
 	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-core/peer"
 )
 
 // DefaultShardSize is the shard size for params objects created with DefaultParams().
@@ -45,10 +48,166 @@ type AddParams struct {
 	Shard          bool
 	StreamChannels bool
 	Format         string // selects with adder
+	// ErasureDataShards, when set, enables the experimental erasure
+	// coding mode: the content is encoded into this number of data
+	// shards plus ErasureParityShards parity shards, which are spread
+	// over different peers instead of being replicated. Any
+	// ErasureDataShards of them are enough to reconstruct the content.
+	ErasureDataShards   int
+	ErasureParityShards int
+	// Encrypt, when set, names the key with which the content of the
+	// files is encrypted before it is chunked: a key configured in the
+	// peer or UserEncryptionKey. The key itself is EncryptionKey, which
+	// is never sent as a parameter.
+	Encrypt       string
+	EncryptionKey []byte `json:"-" codec:"-"`
+	// ExpectedCid, when defined, causes the add operation to fail when
+	// the resulting root CID is different.
+	ExpectedCid cid.Cid
+	// Checksum, when set, requires the request body, or else each of
+	// its file parts, to come with its SHA-256 digest (see
+	// ContentDigestHeader), which is verified before the content is
+	// pinned. Clients compute the digest of the body while uploading.
+	Checksum bool
 
 	IPFSAddParams
 }
 
+// ReshardedFromMetaKey is the metadata key set on pins resulting from a
+// reshard operation. Its value is the CID of the pin that was replaced.
+const ReshardedFromMetaKey = "resharded_from"
+
+// ErasureCodingMetaKey is the metadata key set on the meta pins of
+// erasure-coded content. Its value is the ErasureCoding string.
+const ErasureCodingMetaKey = "erasure_coding"
+
+// EncryptionMetaKey is the metadata key set on the pins of encrypted
+// content. Its value is the Encryption string.
+const EncryptionMetaKey = "encryption"
+
+// UserEncryptionKey is the key name which selects a key supplied with the
+// request to encrypt or decrypt content, instead of a key configured in
+// the peer.
+const UserEncryptionKey = "user"
+
+// Encryption describes how some content was encrypted: the scheme, the
+// name of the key and a fingerprint of it.
+type Encryption struct {
+	Scheme      string
+	KeyName     string
+	Fingerprint string
+}
+
+// String returns the Encryption as "<scheme>:<key name>:<fingerprint>".
+func (e Encryption) String() string {
+	return e.Scheme + ":" + e.KeyName + ":" + e.Fingerprint
+}
+
+// ParseEncryption parses the string representation of an Encryption.
+func ParseEncryption(s string) (Encryption, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return Encryption{}, fmt.Errorf("invalid encryption %q", s)
+	}
+	return Encryption{
+		Scheme:      parts[0],
+		KeyName:     parts[1],
+		Fingerprint: parts[2],
+	}, nil
+}
+
+// MaxErasureShards is the maximum number of data and parity shards of
+// erasure-coded content.
+const MaxErasureShards = 256
+
+// ContentDigestHeader is the header, or trailer, which carries the SHA-256
+// digest of the body of add requests, as "sha-256=:<base64 digest>:"
+// (RFC 9530). When present, the add operation fails without pinning if
+// the received body does not match it. With the checksum parameter, it
+// can also be set on each part of the body.
+const ContentDigestHeader = "Content-Digest"
+
+// ErrContentDigestMismatch is returned when the body of an add request does
+// not match its digest.
+var ErrContentDigestMismatch = errors.New("the content digest does not match")
+
+// FormatContentDigest returns the Content-Digest value for the given
+// SHA-256 sum.
+func FormatContentDigest(sum []byte) string {
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum) + ":"
+}
+
+// ParseContentDigest returns the SHA-256 sum in a Content-Digest value.
+// Other algorithms are ignored.
+func ParseContentDigest(v string) ([]byte, error) {
+	for _, d := range strings.Split(v, ",") {
+		d = strings.TrimSpace(d)
+		if !strings.HasPrefix(d, "sha-256=:") || !strings.HasSuffix(d, ":") || len(d) < len("sha-256=::") {
+			continue
+		}
+		sum, err := base64.StdEncoding.DecodeString(d[len("sha-256=:") : len(d)-1])
+		if err != nil || len(sum) != 32 {
+			return nil, fmt.Errorf("invalid sha-256 digest in %q", v)
+		}
+		return sum, nil
+	}
+	return nil, fmt.Errorf("no sha-256 digest in %q", v)
+}
+
+// ErasureCoding describes how some content was erasure-coded: the number
+// of data and parity shards and the size of the encoded data.
+type ErasureCoding struct {
+	DataShards   int
+	ParityShards int
+	Size         uint64
+}
+
+// String returns the ErasureCoding as "<data>+<parity>:<size>".
+func (ec ErasureCoding) String() string {
+	return fmt.Sprintf("%d+%d:%d", ec.DataShards, ec.ParityShards, ec.Size)
+}
+
+// Validate checks the number of shards.
+func (ec ErasureCoding) Validate() error {
+	if ec.DataShards <= 0 || ec.ParityShards <= 0 {
+		return errors.New("erasure coding needs at least one data and one parity shard")
+	}
+	if ec.DataShards+ec.ParityShards > MaxErasureShards {
+		return fmt.Errorf("erasure coding supports at most %d shards", MaxErasureShards)
+	}
+	return nil
+}
+
+// ParseErasureCoding parses the string representation of an ErasureCoding.
+func ParseErasureCoding(s string) (ErasureCoding, error) {
+	var ec ErasureCoding
+	_, err := fmt.Sscanf(s, "%d+%d:%d", &ec.DataShards, &ec.ParityShards, &ec.Size)
+	if err != nil {
+		return ec, fmt.Errorf("invalid erasure coding %q", s)
+	}
+	return ec, ec.Validate()
+}
+
+// ErasureReconstruction is the result of reconstructing erasure-coded
+// content in the IPFS daemon of a peer.
+type ErasureReconstruction struct {
+	Cid     cid.Cid `json:"cid" codec:"c"`
+	Peer    peer.ID `json:"peer" codec:"p"`
+	Blocks  uint64  `json:"blocks" codec:"b,omitempty"`
+	Size    uint64  `json:"size" codec:"s,omitempty"`
+	Decoded bool    `json:"decoded" codec:"d,omitempty"`
+	// Shard indexes. Data shards come first.
+	UsedShards    []int `json:"used_shards" codec:"u,omitempty"`
+	MissingShards []int `json:"missing_shards" codec:"m,omitempty"`
+}
+
+// ReshardRequest carries the CID of an existing pin and the parameters with
+// which its content should be re-added.
+type ReshardRequest struct {
+	Cid    cid.Cid    `json:"cid" codec:"c"`
+	Params *AddParams `json:"params" codec:"p"`
+}
+
 // DefaultAddParams returns a AddParams object with standard defaults
 func DefaultAddParams() *AddParams {
 	return &AddParams{
@@ -117,6 +276,9 @@ func AddParamsFromQuery(query url.Values) (*AddParams, error) {
 	}
 	params.PinOptions = *opts
 	params.PinUpdate = cid.Undef // hardcode as does not make sense for adding
+	if query.Get("shard-size") == "" {
+		params.ShardSize = DefaultShardSize
+	}
 
 	layout := query.Get("layout")
 	switch layout {
@@ -139,11 +301,20 @@ func AddParamsFromQuery(query url.Values) (*AddParams, error) {
 
 	format := query.Get("format")
 	switch format {
-	case "car", "unixfs", "":
+	case "car", "unixfs":
+		params.Format = format
+	case "":
 	default:
 		return nil, errors.New("format parameter is invalid")
 	}
-	params.Format = format
+
+	if v := query.Get("expected-cid"); v != "" {
+		expected, err := cid.Decode(v)
+		if err != nil {
+			return nil, errors.New("expected-cid parameter is invalid")
+		}
+		params.ExpectedCid = expected
+	}
 
 	err = parseBoolParam(query, "local", &params.Local)
 	if err != nil {
@@ -168,6 +339,29 @@ func AddParamsFromQuery(query url.Values) (*AddParams, error) {
 		return nil, err
 	}
 
+	err = parseIntParam(query, "erasure-data-shards", &params.ErasureDataShards)
+	if err != nil {
+		return nil, err
+	}
+	err = parseIntParam(query, "erasure-parity-shards", &params.ErasureParityShards)
+	if err != nil {
+		return nil, err
+	}
+	if params.ErasureDataShards != 0 || params.ErasureParityShards != 0 {
+		if params.Shard {
+			return nil, errors.New("shard and erasure coding cannot be used together")
+		}
+		ec := ErasureCoding{DataShards: params.ErasureDataShards, ParityShards: params.ErasureParityShards}
+		if err := ec.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	params.Encrypt = query.Get("encrypt")
+	if strings.Contains(params.Encrypt, ":") {
+		return nil, errors.New("encrypt parameter is invalid")
+	}
+
 	err = parseBoolParam(query, "progress", &params.Progress)
 	if err != nil {
 		return nil, err
@@ -201,10 +395,26 @@ func AddParamsFromQuery(query url.Values) (*AddParams, error) {
 		return nil, err
 	}
 
+	err = parseBoolParam(query, "checksum", &params.Checksum)
+	if err != nil {
+		return nil, err
+	}
+
 	return params, nil
 }
 
-// ToQueryString returns a url query string (key=value&key2=value2&...)
+// String returns the parameters in the format of "%+v", without the
+// encryption key, so that they can be logged.
+func (p AddParams) String() string {
+	type addParams AddParams // without this method
+	q := addParams(p)
+	q.EncryptionKey = nil
+	return fmt.Sprintf("%+v", q)
+}
+
+// ToQueryString returns a url query string (key=value&key2=value2&...).
+// Parameters with their default values are left out, so that the server
+// can apply its own defaults to them.
 func (p *AddParams) ToQueryString() (string, error) {
 	pinOptsQuery, err := p.PinOptions.ToQuery()
 	if err != nil {
@@ -214,20 +424,51 @@ func (p *AddParams) ToQueryString() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	query.Set("shard", fmt.Sprintf("%t", p.Shard))
-	query.Set("local", fmt.Sprintf("%t", p.Local))
-	query.Set("recursive", fmt.Sprintf("%t", p.Recursive))
-	query.Set("layout", p.Layout)
-	query.Set("chunker", p.Chunker)
-	query.Set("raw-leaves", fmt.Sprintf("%t", p.RawLeaves))
-	query.Set("hidden", fmt.Sprintf("%t", p.Hidden))
-	query.Set("wrap-with-directory", fmt.Sprintf("%t", p.Wrap))
-	query.Set("progress", fmt.Sprintf("%t", p.Progress))
-	query.Set("cid-version", fmt.Sprintf("%d", p.CidVersion))
-	query.Set("hash", p.HashFun)
-	query.Set("stream-channels", fmt.Sprintf("%t", p.StreamChannels))
-	query.Set("nocopy", fmt.Sprintf("%t", p.NoCopy))
-	query.Set("format", p.Format)
+	if p.ShardSize == DefaultShardSize {
+		query.Del("shard-size")
+	}
+
+	defaults := DefaultAddParams()
+	setBool := func(k string, v, def bool) {
+		if v != def {
+			query.Set(k, fmt.Sprintf("%t", v))
+		}
+	}
+	setString := func(k, v, def string) {
+		if v != "" && v != def {
+			query.Set(k, v)
+		}
+	}
+	setBool("shard", p.Shard, defaults.Shard)
+	setBool("local", p.Local, defaults.Local)
+	setBool("recursive", p.Recursive, defaults.Recursive)
+	setString("layout", p.Layout, defaults.Layout)
+	setString("chunker", p.Chunker, defaults.Chunker)
+	// raw-leaves defaults to true with CIDv1.
+	setBool("raw-leaves", p.RawLeaves, p.CidVersion > 0)
+	setBool("hidden", p.Hidden, defaults.Hidden)
+	setBool("wrap-with-directory", p.Wrap, defaults.Wrap)
+	setBool("progress", p.Progress, defaults.Progress)
+	if p.CidVersion != defaults.CidVersion {
+		query.Set("cid-version", fmt.Sprintf("%d", p.CidVersion))
+	}
+	setString("hash", p.HashFun, defaults.HashFun)
+	setBool("stream-channels", p.StreamChannels, defaults.StreamChannels)
+	setBool("nocopy", p.NoCopy, defaults.NoCopy)
+	setString("format", p.Format, defaults.Format)
+	if p.ErasureDataShards > 0 {
+		query.Set("erasure-data-shards", fmt.Sprintf("%d", p.ErasureDataShards))
+		query.Set("erasure-parity-shards", fmt.Sprintf("%d", p.ErasureParityShards))
+	}
+	if p.Encrypt != "" {
+		query.Set("encrypt", p.Encrypt)
+	}
+	if p.ExpectedCid.Defined() {
+		query.Set("expected-cid", p.ExpectedCid.String())
+	}
+	if p.Checksum {
+		query.Set("checksum", "true")
+	}
 	return query.Encode(), nil
 }
 
@@ -246,5 +487,10 @@ func (p *AddParams) Equals(p2 *AddParams) bool {
 		p.HashFun == p2.HashFun &&
 		p.StreamChannels == p2.StreamChannels &&
 		p.NoCopy == p2.NoCopy &&
-		p.Format == p2.Format
+		p.Format == p2.Format &&
+		p.ErasureDataShards == p2.ErasureDataShards &&
+		p.ErasureParityShards == p2.ErasureParityShards &&
+		p.Encrypt == p2.Encrypt &&
+		p.ExpectedCid.Equals(p2.ExpectedCid) &&
+		p.Checksum == p2.Checksum
 }
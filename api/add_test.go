@@ -1,8 +1,14 @@
 package api
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
 	"net/url"
+	"strings"
 	"testing"
+
+	cid "github.com/ipfs/go-cid"
 )
 
 func TestAddParams_FromQuery(t *testing.T) {
@@ -28,6 +34,106 @@ func TestAddParams_FromQuery(t *testing.T) {
 	}
 }
 
+func TestAddParams_FromQueryErasure(t *testing.T) {
+	q, err := url.ParseQuery("erasure-data-shards=4&erasure-parity-shards=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := AddParamsFromQuery(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ErasureDataShards != 4 || p.ErasureParityShards != 2 {
+		t.Error("did not parse the erasure coding parameters")
+	}
+
+	for _, qStr := range []string{
+		"erasure-data-shards=4",
+		"erasure-data-shards=200&erasure-parity-shards=100",
+		"erasure-data-shards=4&erasure-parity-shards=2&shard=true",
+	} {
+		q, _ := url.ParseQuery(qStr)
+		if _, err := AddParamsFromQuery(q); err == nil {
+			t.Errorf("expected an error parsing %s", qStr)
+		}
+	}
+
+	ec := ErasureCoding{DataShards: 4, ParityShards: 2, Size: 1234}
+	ec2, err := ParseErasureCoding(ec.String())
+	if err != nil || ec2 != ec {
+		t.Error("erasure coding string did not round trip:", ec2, err)
+	}
+	if _, err := ParseErasureCoding("4+0:1"); err == nil {
+		t.Error("expected an error without parity shards")
+	}
+}
+
+func TestAddParams_FromQueryEncrypt(t *testing.T) {
+	q, _ := url.ParseQuery("encrypt=team")
+	p, err := AddParamsFromQuery(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Encrypt != "team" {
+		t.Error("did not parse the encrypt parameter")
+	}
+
+	q, _ = url.ParseQuery("encrypt=a:b")
+	if _, err := AddParamsFromQuery(q); err == nil {
+		t.Error("expected an error with an invalid key name")
+	}
+
+	enc := Encryption{Scheme: "aes-256-gcm-stream", KeyName: "team", Fingerprint: "abcd"}
+	enc2, err := ParseEncryption(enc.String())
+	if err != nil || enc2 != enc {
+		t.Error("encryption string did not round trip:", enc2, err)
+	}
+	if _, err := ParseEncryption("aes-256-gcm-stream:team"); err == nil {
+		t.Error("expected an error parsing an incomplete encryption")
+	}
+}
+
+func TestAddParams_StringRedactsKey(t *testing.T) {
+	p := DefaultAddParams()
+	p.Encrypt = UserEncryptionKey
+	p.EncryptionKey = []byte("0123456789abcdef0123456789abcdef")
+
+	for _, f := range []string{"%v", "%+v", "%s"} {
+		for _, v := range []interface{}{p, *p} {
+			out := fmt.Sprintf(f, v)
+			if strings.Contains(out, fmt.Sprint(p.EncryptionKey)) || strings.Contains(out, string(p.EncryptionKey)) {
+				t.Errorf("%s: the encryption key was not redacted: %s", f, out)
+			}
+			if !strings.Contains(out, "Encrypt:user") {
+				t.Errorf("%s: the parameters were not formatted: %s", f, out)
+			}
+		}
+	}
+	if len(p.EncryptionKey) == 0 {
+		t.Error("formatting should not modify the parameters")
+	}
+}
+
+func TestContentDigest(t *testing.T) {
+	sum := sha256.Sum256([]byte("hello"))
+	v := FormatContentDigest(sum[:])
+	for _, s := range []string{v, "sha-512=:AAAA:, " + v} {
+		sum2, err := ParseContentDigest(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(sum2, sum[:]) {
+			t.Errorf("%s: digest did not round trip", s)
+		}
+	}
+
+	for _, s := range []string{"", "sha-512=:AAAA:", "sha-256=:AAAA:", "sha-256=:not base64:", "sha-256=::"} {
+		if _, err := ParseContentDigest(s); err == nil {
+			t.Errorf("expected an error parsing %q", s)
+		}
+	}
+}
+
 func TestAddParams_FromQueryRawLeaves(t *testing.T) {
 	qStr := "cid-version=1"
 
@@ -82,6 +188,11 @@ func TestAddParams_ToQueryString(t *testing.T) {
 	p.Name = "something"
 	p.RawLeaves = true
 	p.ShardSize = 1020
+	p.ErasureDataShards = 5
+	p.ErasureParityShards = 3
+	p.Encrypt = "team"
+	p.ExpectedCid, _ = cid.Decode("QmP63DkAFEnDYNjDYBpyNDfttu1fvUw99x1brscPzpqmmq")
+	p.Checksum = true
 	qstr, err := p.ToQueryString()
 	if err != nil {
 		t.Fatal(err)
@@ -100,3 +211,33 @@ func TestAddParams_ToQueryString(t *testing.T) {
 		t.Error("generated and parsed params should be equal")
 	}
 }
+
+func TestAddParams_ToQueryStringDefaults(t *testing.T) {
+	// Default values are not sent so that server defaults apply.
+	qstr, err := DefaultAddParams().ToQueryString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qstr != "" {
+		t.Error("expected an empty query with the default params:", qstr)
+	}
+
+	p := DefaultAddParams()
+	p.CidVersion = 1
+	p.RawLeaves = false
+	qstr, err = p.ToQueryString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := url.ParseQuery(qstr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2, err := AddParamsFromQuery(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Equals(p2) {
+		t.Error("generated and parsed params should be equal:", qstr)
+	}
+}
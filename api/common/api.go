@@ -39,11 +39,10 @@ import (
 	p2phttp "github.com/libp2p/go-libp2p-http"
 	noise "github.com/libp2p/go-libp2p-noise"
 	libp2ptls "github.com/libp2p/go-libp2p-tls"
+	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
 
-	handlers "github.com/gorilla/handlers"
 	mux "github.com/gorilla/mux"
-	"github.com/rs/cors"
 	"go.opencensus.io/plugin/ochttp"
 	"go.opencensus.io/plugin/ochttp/propagation/tracecontext"
 	"go.opencensus.io/trace"
@@ -87,6 +86,12 @@ type API struct {
 
 	httpListeners  []net.Listener
 	libp2pListener net.Listener
+	// boundListeners are the HTTP listeners opened for the configured
+	// listen addresses, indexed by multiaddress. systemdListeners are
+	// the rest.
+	boundListeners   map[string]*tlsListener
+	systemdListeners []net.Listener
+	cors             *corsHandler
 
 	shutdownLock sync.Mutex
 	shutdown     bool
@@ -95,10 +100,15 @@ type API struct {
 
 // Route defines a REST endpoint supported by this API.
 type Route struct {
+	// Name identifies the route. It is used to refer to it in the
+	// configured policies.
 	Name        string
 	Method      string
 	Pattern     string
 	HandlerFunc http.HandlerFunc
+	// UnlimitedBody disables the MaxBodyBytes limit for the route
+	// (i.e. for file uploads).
+	UnlimitedBody bool
 }
 
 type logWriter struct {
@@ -126,7 +136,9 @@ func NewAPIWithHost(ctx context.Context, cfg *Config, h host.Host, routes func(*
 	// Our handler is a gorilla router wrapped with:
 	// - a custom strictSlashHandler that uses 307 redirects (#1415)
 	// - the cors handler,
-	// - the basic auth handler.
+	// - the basic auth handler,
+	// - the concurrent requests limiter,
+	// - the libp2p peer allowlist and rate limiter.
 	//
 	// Requests will need to have valid credentials first, except
 	// cors-preflight requests (OPTIONS). Then requests are handled by
@@ -134,14 +146,14 @@ func NewAPIWithHost(ctx context.Context, cfg *Config, h host.Host, routes func(*
 	// redirected if the path ends with a "/". Finally they hit one of our
 	// routes and handlers.
 	router := mux.NewRouter()
-	handler := basicAuthHandler(
+	corsH := newCORSHandler(cfg.CorsOptions(), strictSlashHandler(router))
+	var handler http.Handler = basicAuthHandler(
 		cfg.BasicAuthCredentials,
-		cors.New(*cfg.CorsOptions()).
-			Handler(
-				strictSlashHandler(router),
-			),
+		corsH,
 		cfg.Logger,
 	)
+	handler = LimitConcurrency(handler, cfg.MaxConcurrentRequests)
+	handler = libp2pAccessHandler(cfg.Libp2pAccess, handler)
 	if cfg.Tracing {
 		handler = &ochttp.Handler{
 			IsPublicEndpoint: true,
@@ -162,8 +174,9 @@ func NewAPIWithHost(ctx context.Context, cfg *Config, h host.Host, routes func(*
 		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
 		WriteTimeout:      cfg.WriteTimeout,
 		IdleTimeout:       cfg.IdleTimeout,
-		Handler:           handlers.LoggingHandler(writer, handler),
+		Handler:           requestLogHandler(writer, cfg.RequestLog, router, requestIDHandler(handler)),
 		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		ConnContext:       libp2pConnContext,
 	}
 
 	// See: https://github.com/ipfs/go-ipfs/issues/5168
@@ -183,6 +196,9 @@ func NewAPIWithHost(ctx context.Context, cfg *Config, h host.Host, routes func(*
 		router:   router,
 		routes:   routes,
 		rpcReady: make(chan struct{}, 2),
+		cors:     corsH,
+
+		boundListeners: make(map[string]*tlsListener),
 	}
 
 	// Set up api.httpListeners if enabled
@@ -206,30 +222,44 @@ func NewAPIWithHost(ctx context.Context, cfg *Config, h host.Host, routes func(*
 }
 
 func (api *API) setupHTTP() error {
-	if len(api.config.HTTPListenAddr) == 0 {
+	if len(api.config.HTTPListenAddr) == 0 && len(api.config.SystemdSockets) == 0 {
 		return nil
 	}
 
-	for _, listenMAddr := range api.config.HTTPListenAddr {
-		n, addr, err := manet.DialArgs(listenMAddr)
-		if err != nil {
-			return err
-		}
+	listeners, err := SystemdListeners(api.config.SystemdSockets)
+	if err != nil {
+		return err
+	}
 
-		var l net.Listener
+	for _, l := range listeners {
 		if api.config.TLS != nil {
-			l, err = tls.Listen(n, addr, api.config.TLS)
-		} else {
-			l, err = net.Listen(n, addr)
+			l = tls.NewListener(l, api.config.TLS)
 		}
+		api.httpListeners = append(api.httpListeners, l)
+		api.systemdListeners = append(api.systemdListeners, l)
+	}
+
+	for _, listenMAddr := range api.config.HTTPListenAddr {
+		l, err := api.listen(api.config, listenMAddr)
 		if err != nil {
 			return err
 		}
 		api.httpListeners = append(api.httpListeners, l)
+		api.boundListeners[listenMAddr.String()] = l
 	}
 	return nil
 }
 
+// listen opens an HTTP listener on the given address with the TLS
+// configuration that cfg sets for it.
+func (api *API) listen(cfg *Config, addr ma.Multiaddr) (*tlsListener, error) {
+	l, err := Listen(addr, api.config.UnixSocketMode)
+	if err != nil {
+		return nil, err
+	}
+	return newTLSListener(l, cfg.ListenerTLSConfig(addr)), nil
+}
+
 func (api *API) setupLibp2p() error {
 	// Make new host. Override any provided existing one
 	// if we have config for a custom one.
@@ -263,19 +293,23 @@ func (api *API) setupLibp2p() error {
 
 func (api *API) addRoutes() {
 	for _, route := range api.routes(api.rpcClient) {
+		var h http.Handler = route.HandlerFunc
+		if !route.UnlimitedBody {
+			h = LimitBody(h, api.config.MaxBodyBytes)
+		}
 		api.router.
 			Methods(route.Method).
 			Path(route.Pattern).
 			Name(route.Name).
 			Handler(
 				ochttp.WithRouteTag(
-					http.HandlerFunc(route.HandlerFunc),
+					metricsHandler(api.config.ConfigKey, route.Name, api.policyHandler(route.Name, api.libp2pRouteHandler(route.Name, h))),
 					"/"+route.Name,
 				),
 			)
 	}
 	api.router.NotFoundHandler = ochttp.WithRouteTag(
-		http.HandlerFunc(api.notFoundHandler),
+		metricsHandler(api.config.ConfigKey, "NotFound", http.HandlerFunc(api.notFoundHandler)),
 		"/notfound",
 	)
 }
@@ -321,7 +355,25 @@ func basicAuthHandler(credentials map[string]string, h http.Handler, lggr *loggi
 			http.Error(w, resp, http.StatusUnauthorized)
 			return
 		}
-		h.ServeHTTP(w, r)
+		setLoggedUser(r.Context(), username)
+		h.ServeHTTP(w, r.WithContext(types.ContextWithRequestUser(r.Context(), username)))
+	}
+	return http.HandlerFunc(wrap)
+}
+
+// requestIDHandler sets the ID of every request, either the one given by the
+// client in the X-Request-ID header or a new one, in the request context and
+// headers and in the response headers.
+func requestIDHandler(h http.Handler) http.Handler {
+	wrap := func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !types.ValidRequestID(id) {
+			id = types.NewRequestID()
+			// Headers are shared with the request logger.
+			r.Header.Set(RequestIDHeader, id)
+		}
+		w.Header().Set(RequestIDHeader, id)
+		h.ServeHTTP(w, r.WithContext(types.ContextWithRequestID(r.Context(), id)))
 	}
 	return http.HandlerFunc(wrap)
 }
@@ -379,7 +431,11 @@ func (api *API) runHTTPServer(ctx context.Context, l net.Listener) {
 	case <-api.ctx.Done():
 		return
 	}
+	api.serveHTTP(l)
+}
 
+// serveHTTP serves requests on the given listener until it is closed.
+func (api *API) serveHTTP(l net.Listener) {
 	maddr, err := manet.FromNetAddr(l.Addr())
 	if err != nil {
 		api.config.Logger.Error(err)
@@ -542,11 +598,17 @@ func (api *API) SendResponse(
 		}
 		w.WriteHeader(status)
 
+		reqID := w.Header().Get(RequestIDHeader)
 		errorResp := types.Error{
-			Code:    status,
-			Message: err.Error(),
+			Code:      status,
+			Message:   err.Error(),
+			RequestID: reqID,
+		}
+		lggr := &api.config.Logger.SugaredLogger
+		if reqID != "" {
+			lggr = lggr.With("request_id", reqID)
 		}
-		api.config.Logger.Errorf("sending error response: %d: %s", status, err.Error())
+		lggr.Errorf("sending error response: %d: %s", status, err.Error())
 
 		if err := enc.Encode(errorResp); err != nil {
 			api.config.Logger.Error(err)
@@ -595,6 +657,9 @@ func (api *API) SetHeaders(w http.ResponseWriter) {
 // on a random port (0). Returns error when the HTTP endpoint
 // is not enabled.
 func (api *API) HTTPAddresses() ([]string, error) {
+	api.shutdownLock.Lock()
+	defer api.shutdownLock.Unlock()
+
 	if len(api.httpListeners) == 0 {
 		return nil, ErrHTTPEndpointNotEnabled
 	}
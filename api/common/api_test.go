@@ -2,9 +2,11 @@ package common
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"math/rand"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"os"
@@ -42,6 +44,7 @@ func routes(c *rpc.Client) []Route {
 				w.Header().Add("Content-Type", "application/json")
 				w.Write([]byte(`{ "thisis": "atest" }`))
 			},
+			false,
 		},
 	}
 
@@ -141,6 +144,165 @@ func TestHTTPSTestEndpoint(t *testing.T) {
 	test.HTTPSEndPoint(t, httpstf)
 }
 
+func TestHTTPListenTLS(t *testing.T) {
+	ctx := context.Background()
+	cfg := newDefaultTestConfig(t)
+	var err error
+	cfg.TLS, err = newTLSConfig(SSLCertFile, SSLKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.PathSSLCertFile = SSLCertFile
+	cfg.PathSSLKeyFile = SSLKeyFile
+
+	tlsAddr, _ := ma.NewMultiaddr("/ip4/127.0.0.1/tcp/0")
+	plainAddr, _ := ma.NewMultiaddr("/ip4/0.0.0.0/tcp/0")
+	cfg.HTTPListenAddr = []ma.Multiaddr{tlsAddr, plainAddr}
+	cfg.HTTPListenTLS = map[string]*ListenerTLS{
+		plainAddr.String(): {DisableTLS: true},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	rest, err := NewAPI(ctx, cfg, routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rest.Shutdown(ctx)
+	rest.SetClient(rpctest.NewMockRPCClient(t))
+
+	addrs, err := rest.HTTPAddresses()
+	if err != nil || len(addrs) != 2 {
+		t.Fatal("expected two listeners:", addrs, err)
+	}
+
+	get := func(url string, https bool) error {
+		c := test.HTTPClient(t, nil, https)
+		resp, err := c.Get(url)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+		}
+		return nil
+	}
+
+	if err := get("https://"+addrs[0]+"/test", true); err != nil {
+		t.Error("expected https on the first address:", err)
+	}
+	if err := get("http://"+addrs[0]+"/test", false); err == nil {
+		t.Error("expected plain http to fail on the first address")
+	}
+	if err := get("http://"+addrs[1]+"/test", false); err != nil {
+		t.Error("expected plain http on the second address:", err)
+	}
+}
+
+func TestRebind(t *testing.T) {
+	ctx := context.Background()
+	cfg := newDefaultTestConfig(t)
+	cfg.HTTPListenAddr = []ma.Multiaddr{ma.StringCast("/ip4/127.0.0.1/tcp/0")}
+	rest, err := NewAPI(ctx, cfg, routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rest.Shutdown(ctx)
+	rest.SetClient(rpctest.NewMockRPCClient(t))
+
+	get := func(url string, https bool) error {
+		c := test.HTTPClient(t, nil, https)
+		resp, err := c.Get(url)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+
+	before, _ := rest.HTTPAddresses()
+	settings := rest.Listeners()
+	if settings.API != cfg.ConfigKey || len(settings.HTTPListenMultiaddress) != 1 {
+		t.Fatalf("unexpected listener settings: %+v", settings)
+	}
+
+	// Keep the first listener and add another one.
+	settings.HTTPListenMultiaddress = append(settings.HTTPListenMultiaddress, "/ip4/0.0.0.0/tcp/0")
+	settings.CORSAllowedOrigins = []string{"neworigin"}
+	if err := rest.Rebind(ctx, settings); err != nil {
+		t.Fatal(err)
+	}
+	addrs, _ := rest.HTTPAddresses()
+	if len(addrs) != 2 || addrs[0] != before[0] {
+		t.Fatal("expected the first listener to be kept:", before, addrs)
+	}
+	if err := get("http://"+addrs[1]+"/test", false); err != nil {
+		t.Error("expected the new listener to serve requests:", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodOptions, "http://"+addrs[0]+"/test", nil)
+	req.Header.Set("Origin", "neworigin")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("Access-Control-Allow-Origin") != "neworigin" {
+		t.Error("expected the new CORS options to apply")
+	}
+
+	// Serve only TLS on the second address.
+	settings.HTTPListenMultiaddress = settings.HTTPListenMultiaddress[1:]
+	settings.SSLCertFile = SSLCertFile
+	settings.SSLKeyFile = SSLKeyFile
+	if err := rest.Rebind(ctx, settings); err != nil {
+		t.Fatal(err)
+	}
+	tlsAddrs, _ := rest.HTTPAddresses()
+	if len(tlsAddrs) != 1 || tlsAddrs[0] != addrs[1] {
+		t.Fatal("expected the second listener to be kept:", tlsAddrs)
+	}
+	if err := get("http://"+addrs[0]+"/test", false); err == nil {
+		t.Error("expected the removed listener to be closed")
+	}
+	// The test certificate is only valid for 127.0.0.1.
+	_, port, _ := net.SplitHostPort(tlsAddrs[0])
+	if err := get("https://127.0.0.1:"+port+"/test", true); err != nil {
+		t.Error("expected https on the kept listener:", err)
+	}
+	if cfg.PathSSLCertFile != SSLCertFile || len(cfg.HTTPListenAddr) != 1 {
+		t.Error("expected the configuration to be updated")
+	}
+
+	// The certificate is loaded again with the same paths, without
+	// closing the listener.
+	tl := rest.boundListeners[cfg.HTTPListenAddr[0].String()]
+	oldCert, _ := tl.cert.Load().(*tls.Certificate)
+	if err := rest.Rebind(ctx, settings); err != nil {
+		t.Fatal(err)
+	}
+	newCert, _ := tl.cert.Load().(*tls.Certificate)
+	if rest.boundListeners[cfg.HTTPListenAddr[0].String()] != tl || newCert == nil || newCert == oldCert {
+		t.Error("expected the certificate to be reloaded on the same listener")
+	}
+	if err := get("https://127.0.0.1:"+port+"/test", true); err != nil {
+		t.Error("expected https after reloading the certificate:", err)
+	}
+
+	settings.HTTPListenMultiaddress = nil
+	if err := rest.Rebind(ctx, settings); err == nil {
+		t.Error("expected an error without listen addresses")
+	}
+	settings.HTTPListenMultiaddress = []string{"/ip4/0.0.0.0/tcp/0"}
+	settings.API = "other"
+	if err := rest.Rebind(ctx, settings); err == nil {
+		t.Error("expected an error with settings for another API")
+	}
+}
+
 func TestAPILogging(t *testing.T) {
 	ctx := context.Background()
 	cfg := newDefaultTestConfig(t)
@@ -224,6 +386,45 @@ func TestNotFoundHandler(t *testing.T) {
 	test.BothEndpoints(t, tf)
 }
 
+func TestRequestID(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	c := test.HTTPClient(t, test.MakeHost(t, rest), false)
+	get := func(path, id string) *http.Response {
+		req, err := http.NewRequest("GET", test.HTTPURL(rest)+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != "" {
+			req.Header.Set(RequestIDHeader, id)
+		}
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := get("/test", "")
+	resp.Body.Close()
+	if !api.ValidRequestID(resp.Header.Get(RequestIDHeader)) {
+		t.Error("expected a generated request ID")
+	}
+
+	resp = get("/notfound", "my-request")
+	defer resp.Body.Close()
+	if id := resp.Header.Get(RequestIDHeader); id != "my-request" {
+		t.Error("expected the given request ID. Got:", id)
+	}
+	var errResp api.Error
+	test.ProcessResp(t, resp, nil, &errResp)
+	if errResp.RequestID != "my-request" {
+		t.Error("expected the request ID in the error response. Got:", errResp.RequestID)
+	}
+}
+
 func TestCORS(t *testing.T) {
 	ctx := context.Background()
 	rest := testAPI(t)
@@ -0,0 +1,169 @@
+package common
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	types "github.com/ipfs/ipfs-cluster/api"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Responses smaller than this are sent uncompressed.
+const minCompressSize = 1024
+
+// SendCacheableResponse sends the response object like SendResponse but
+// supports conditional requests and compression. It is meant for large
+// listings which clients poll regularly.
+//
+// The response carries an ETag which changes whenever the listed state
+// changes. Handlers which can tell the version of the state they list
+// should call NotModified first, which sets the ETag and answers
+// conditional requests without building the response. Otherwise, the ETag
+// is derived from the body. Requests with a matching If-None-Match header
+// get a 304 Not Modified without body. Otherwise the body is compressed
+// with zstd or gzip when the client accepts them. Errors are sent as with
+// SendResponse.
+func (api *API) SendCacheableResponse(w http.ResponseWriter, r *http.Request, err error, resp interface{}) {
+	if err != nil || resp == nil {
+		w.Header().Del("ETag")
+		api.SendResponse(w, SetStatusAutomatically, err, resp)
+		return
+	}
+
+	body, err := json.Marshal(resp)
+	if err != nil {
+		w.Header().Del("ETag")
+		api.SendResponse(w, http.StatusInternalServerError, err, nil)
+		return
+	}
+	body = append(body, '\n')
+
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		sum := sha256.Sum256(body)
+		etag = weakETag(sum[:16])
+		if api.NotModified(w, r, etag) {
+			return
+		}
+	}
+	api.SetHeaders(w)
+
+	encoding := ""
+	if len(body) >= minCompressSize {
+		encoding = negotiateEncoding(r.Header.Get("Accept-Encoding"))
+	}
+
+	var out io.Writer = w
+	switch encoding {
+	case "zstd":
+		zw, err := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			api.SendResponse(w, http.StatusInternalServerError, err, nil)
+			return
+		}
+		defer zw.Close()
+		out = zw
+	case "gzip":
+		gw := gzip.NewWriter(w)
+		defer gw.Close()
+		out = gw
+	default:
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	}
+
+	if encoding != "" {
+		w.Header().Set("Content-Encoding", encoding)
+	}
+	w.WriteHeader(http.StatusOK)
+	if _, err := out.Write(body); err != nil {
+		api.config.Logger.Error(err)
+	}
+}
+
+// NotModified sets the given ETag in the response and, when the request
+// has a matching If-None-Match header, sends a 304 Not Modified and returns
+// true.
+func (api *API) NotModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	w.Header().Add("Vary", "Accept-Encoding")
+	if !etagMatches(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	api.SetHeaders(w)
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// VersionETag returns an ETag for the response to the given request when
+// it is built from the given version of the state. The version must change
+// whenever the state does. Responses with different query parameters or
+// for different authenticated users get different ETags.
+func VersionETag(r *http.Request, version string) string {
+	user := types.RequestUserFromContext(r.Context())
+	h := sha256.New()
+	for _, part := range []string{version, r.URL.Path, r.URL.RawQuery, user} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return weakETag(h.Sum(nil)[:16])
+}
+
+// Weak, since the same ETag is used for every content encoding.
+func weakETag(sum []byte) string {
+	return `W/"` + hex.EncodeToString(sum) + `"`
+}
+
+// etagMatches performs the weak comparison of If-None-Match values against
+// the given ETag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateEncoding returns the preferred content encoding among the ones
+// supported (zstd, gzip) and accepted in the given Accept-Encoding header,
+// or an empty string.
+func negotiateEncoding(acceptEncoding string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		ok := true
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(param[2:], 64)
+				ok = err == nil && q > 0
+			}
+		}
+		accepted[name] = ok
+	}
+
+	for _, enc := range []string{"zstd", "gzip"} {
+		if ok, found := accepted[enc]; found {
+			if ok {
+				return enc
+			}
+			continue
+		}
+		if accepted["*"] {
+			return enc
+		}
+	}
+	return ""
+}
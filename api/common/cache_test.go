@@ -0,0 +1,165 @@
+package common
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	types "github.com/ipfs/ipfs-cluster/api"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	testcases := map[string]string{
+		"":                       "",
+		"gzip":                   "gzip",
+		"gzip, deflate, br":      "gzip",
+		"gzip, zstd":             "zstd",
+		"zstd;q=0, gzip;q=0.5":   "gzip",
+		"*":                      "zstd",
+		"*, zstd;q=0":            "gzip",
+		"identity":               "",
+		"GZIP":                   "gzip",
+		"gzip;q=0, deflate;q=1 ": "",
+	}
+	for header, expected := range testcases {
+		if enc := negotiateEncoding(header); enc != expected {
+			t.Errorf("%q: expected %q but got %q", header, expected, enc)
+		}
+	}
+}
+
+func TestSendCacheableResponse(t *testing.T) {
+	cfg := newDefaultTestConfig(t)
+	api := &API{config: cfg}
+
+	large := make([]string, 500)
+	for i := range large {
+		large[i] = "QmP63DkAFEnDYNjDYBpyNDfttu1fvUw99x1brscPzpqmmq"
+	}
+
+	send := func(resp interface{}, headers map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/allocations", nil)
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		api.SendCacheableResponse(w, r, nil, resp)
+		return w
+	}
+
+	decode := func(body io.Reader) []string {
+		var res []string
+		if err := json.NewDecoder(body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	w := send(large, nil)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatal("expected a 200 response with an ETag")
+	}
+	if w.Header().Get("Content-Encoding") != "" {
+		t.Error("the response should not be compressed")
+	}
+	if len(decode(w.Body)) != len(large) {
+		t.Error("bad response body")
+	}
+
+	w = send(large, map[string]string{"If-None-Match": etag})
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Error("expected a 304 without body")
+	}
+
+	w = send(large[1:], map[string]string{"If-None-Match": etag})
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Error("a different response should have a different ETag")
+	}
+
+	w = send(large, map[string]string{"Accept-Encoding": "gzip"})
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatal("expected a gzip response")
+	}
+	gr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decode(gr)) != len(large) {
+		t.Error("bad gzip response body")
+	}
+
+	w = send(large, map[string]string{"Accept-Encoding": "zstd"})
+	if w.Header().Get("Content-Encoding") != "zstd" {
+		t.Fatal("expected a zstd response")
+	}
+	zr, err := zstd.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	if len(decode(zr)) != len(large) {
+		t.Error("bad zstd response body")
+	}
+
+	w = send([]string{"abc"}, map[string]string{"Accept-Encoding": "gzip"})
+	if w.Header().Get("Content-Encoding") != "" || strings.TrimSpace(w.Body.String()) != `["abc"]` {
+		t.Error("small responses should not be compressed")
+	}
+}
+
+func TestSendCacheableResponseVersionETag(t *testing.T) {
+	cfg := newDefaultTestConfig(t)
+	api := &API{config: cfg}
+
+	send := func(version, ifNoneMatch string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/allocations?filter=pin", nil)
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		if api.NotModified(w, r, VersionETag(r, version)) {
+			return w
+		}
+		api.SendCacheableResponse(w, r, nil, []string{"abc"})
+		return w
+	}
+
+	w := send("1", "")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatal("expected a 200 response with an ETag")
+	}
+
+	w = send("1", etag)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Error("expected a 304 without body for the same version")
+	}
+
+	w = send("2", etag)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Error("a new version should have a different ETag")
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/allocations", nil)
+	if VersionETag(r, "1") == etag {
+		t.Error("a different query should have a different ETag")
+	}
+
+	// Only the authenticated user changes the ETag.
+	r = httptest.NewRequest(http.MethodGet, "/allocations?filter=pin", nil)
+	r.SetBasicAuth(validUserName, "x")
+	if VersionETag(r, "1") != etag {
+		t.Error("an unauthenticated user should not change the ETag")
+	}
+	r = r.WithContext(types.ContextWithRequestUser(r.Context(), validUserName))
+	if VersionETag(r, "1") == etag {
+		t.Error("a different user should have a different ETag")
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	ipfsconfig "github.com/ipfs/go-ipfs-config"
@@ -20,6 +22,8 @@ import (
 	"github.com/kelseyhightower/envconfig"
 	"github.com/rs/cors"
 
+	"github.com/ipfs/ipfs-cluster/adder/encryption"
+	types "github.com/ipfs/ipfs-cluster/api"
 	"github.com/ipfs/ipfs-cluster/config"
 )
 
@@ -42,13 +46,30 @@ type Config struct {
 	EnvConfigKey  string
 	Logger        *logging.ZapEventLogger
 	RequestLogger *logging.ZapEventLogger
+	// RouteNames are the names of the routes of the API. The routes named
+	// in the configuration must be among them, unless it is empty.
+	RouteNames []string
 
 	// Listen address for the HTTP REST API endpoint.
 	HTTPListenAddr []ma.Multiaddr
 
+	// UnixSocketMode sets the permissions of the Unix domain sockets
+	// in HTTPListenAddr (i.e. /unix/run/cluster.sock). 0 leaves them
+	// as created.
+	UnixSocketMode os.FileMode
+
+	// SystemdSockets are the names of the sockets passed by systemd
+	// socket activation which are served in addition to HTTPListenAddr.
+	SystemdSockets []string
+
 	// TLS configuration for the HTTP listener
 	TLS *tls.Config
 
+	// HTTPListenTLS overrides the TLS configuration for some of the
+	// addresses in HTTPListenAddr, indexed by multiaddress. They may use
+	// their own certificate or serve plain HTTP.
+	HTTPListenTLS map[string]*ListenerTLS
+
 	// pathSSLCertFile is a path to a certificate file used to secure the
 	// HTTP API endpoint. We track it so we can write it in the JSON.
 	PathSSLCertFile string
@@ -74,6 +95,15 @@ type Config struct {
 	// accepted by the server
 	MaxHeaderBytes int
 
+	// Maximum size of request bodies in bytes, except for routes
+	// handling uploads. 0 means no limit.
+	MaxBodyBytes int64
+
+	// Maximum number of requests served at the same time. Requests over
+	// the limit are rejected with 429 Too Many Requests. 0 means no
+	// limit.
+	MaxConcurrentRequests int
+
 	// Listen address for the Libp2p REST API endpoint.
 	Libp2pListenAddr []ma.Multiaddr
 
@@ -92,6 +122,11 @@ type Config struct {
 	// default value is empty.
 	HTTPLogFile string
 
+	// RequestLog configures the format of the HTTP API logs and the
+	// sampling of requests to busy routes. When nil, requests are logged
+	// in the Common Log Format.
+	RequestLog *RequestLogConfig
+
 	// Headers provides customization for the headers returned
 	// by the API on existing routes.
 	Headers map[string][]string
@@ -104,19 +139,139 @@ type Config struct {
 	CORSAllowCredentials bool
 	CORSMaxAge           time.Duration
 
+	// AddParams sets server-side values for the parameters of add
+	// requests.
+	AddParams *AddParamsConfig
+
+	// UserAddParams sets server-side values for the parameters of add
+	// requests made by the given basic-auth users. They take precedence
+	// over AddParams.
+	UserAddParams map[string]*AddParamsConfig
+
+	// PinProfiles are named sets of pin and add query parameters (i.e.
+	// "replication": "5" or "expire-in": "168h"), which requests can
+	// select with the "profile" parameter. Parameters set in the request
+	// take precedence over those in the profile.
+	PinProfiles map[string]map[string]string
+
+	// UserPinProfiles sets the profile used by the pin and add requests
+	// of the given basic-auth users when they do not select one.
+	UserPinProfiles map[string]string
+
+	// PinProfileMetadata limits the metadata of the pin and add requests
+	// using the given pin profiles, in addition to the pin_metadata
+	// policy of the cluster.
+	PinProfileMetadata map[string]*types.MetadataPolicy
+
+	// EncryptionKeys are the named keys with which add requests can
+	// encrypt content (with the "encrypt" parameter) and with which it is
+	// decrypted. They are 32-byte keys, hex or base64-encoded.
+	EncryptionKeys map[string]string
+
+	// Tenancy, when enabled, places the pins added by each basic-auth
+	// user in their own namespace and enforces quotas on them.
+	Tenancy *TenancyConfig
+
+	// Policies restricts the routes that each user can use and the
+	// parameters of their requests.
+	Policies *PolicyConfig
+
+	// Libp2pAccess restricts the peers which can use the API over the
+	// libp2p transport, their request rate and the routes served there.
+	Libp2pAccess *Libp2pAccessConfig
+
+	// Federation configures the clusters aggregated in the /federation
+	// routes of the API.
+	Federation *FederationConfig
+
 	// Tracing flag used to skip tracing specific paths when not enabled.
 	Tracing bool
 }
 
+// AddParamsConfig provides values for add parameters which are used when
+// the requests do not set them.
+type AddParamsConfig struct {
+	// Defaults contains add query parameters and their values, i.e.
+	// "chunker": "size-1048576" or "cid-version": "1".
+	Defaults map[string]string `json:"defaults"`
+	// Enforce makes requests setting any of the parameters in Defaults to
+	// a different value fail.
+	Enforce bool `json:"enforce"`
+}
+
+// IsEmpty returns true when no parameters are set.
+func (apc *AddParamsConfig) IsEmpty() bool {
+	return apc == nil || len(apc.Defaults) == 0
+}
+
+// TenancyConfig configures multi-tenancy. When enabled, every basic-auth
+// user, except admins, owns a namespace named after the user. Pins added by
+// a user belong to their namespace, and users can only list, inspect and
+// modify the pins in it.
+type TenancyConfig struct {
+	Enabled bool `json:"enabled"`
+	// AdminUsers can see and modify all pins, regardless of their
+	// namespace, and perform cluster-wide operations.
+	AdminUsers []string `json:"admin_users"`
+	// DefaultQuota applies to the namespaces without an entry in Quotas.
+	DefaultQuota *Quota `json:"default_quota,omitempty"`
+	// Quotas sets the quota for specific namespaces.
+	Quotas map[string]*Quota `json:"quotas,omitempty"`
+}
+
+// Quota limits the pins in a namespace. Zero values mean no limit.
+type Quota struct {
+	// MaxPins is the maximum number of pins.
+	MaxPins int `json:"max_pins"`
+	// MaxBytes is the maximum sum of the expected sizes of the pins.
+	MaxBytes uint64 `json:"max_bytes"`
+}
+
+// IsEnabled returns true when tenancy is configured and enabled.
+func (tc *TenancyConfig) IsEnabled() bool {
+	return tc != nil && tc.Enabled
+}
+
+// Namespace returns the namespace for the given user. It returns false when
+// the user is not restricted to a namespace because tenancy is disabled or
+// because it is an admin.
+func (tc *TenancyConfig) Namespace(user string) (string, bool) {
+	if !tc.IsEnabled() {
+		return "", false
+	}
+	for _, admin := range tc.AdminUsers {
+		if admin == user {
+			return "", false
+		}
+	}
+	return user, true
+}
+
+// QuotaFor returns the quota for the given namespace, or nil if it has none.
+func (tc *TenancyConfig) QuotaFor(ns string) *Quota {
+	if !tc.IsEnabled() {
+		return nil
+	}
+	if q, ok := tc.Quotas[ns]; ok {
+		return q
+	}
+	return tc.DefaultQuota
+}
+
 type jsonConfig struct {
-	HTTPListenMultiaddress ipfsconfig.Strings `json:"http_listen_multiaddress"`
-	SSLCertFile            string             `json:"ssl_cert_file,omitempty"`
-	SSLKeyFile             string             `json:"ssl_key_file,omitempty"`
-	ReadTimeout            string             `json:"read_timeout"`
-	ReadHeaderTimeout      string             `json:"read_header_timeout"`
-	WriteTimeout           string             `json:"write_timeout"`
-	IdleTimeout            string             `json:"idle_timeout"`
-	MaxHeaderBytes         int                `json:"max_header_bytes"`
+	HTTPListenMultiaddress ipfsconfig.Strings      `json:"http_listen_multiaddress"`
+	UnixSocketMode         string                  `json:"unix_socket_mode,omitempty"`
+	SystemdSockets         []string                `json:"systemd_sockets,omitempty"`
+	SSLCertFile            string                  `json:"ssl_cert_file,omitempty"`
+	SSLKeyFile             string                  `json:"ssl_key_file,omitempty"`
+	HTTPListenTLS          map[string]*ListenerTLS `json:"http_listen_tls,omitempty"`
+	ReadTimeout            string                  `json:"read_timeout"`
+	ReadHeaderTimeout      string                  `json:"read_header_timeout"`
+	WriteTimeout           string                  `json:"write_timeout"`
+	IdleTimeout            string                  `json:"idle_timeout"`
+	MaxHeaderBytes         int                     `json:"max_header_bytes"`
+	MaxBodyBytes           *int64                  `json:"max_body_bytes,omitempty"`
+	MaxConcurrentRequests  *int                    `json:"max_concurrent_requests,omitempty"`
 
 	Libp2pListenMultiaddress ipfsconfig.Strings `json:"libp2p_listen_multiaddress,omitempty"`
 	ID                       string             `json:"id,omitempty"`
@@ -124,6 +279,7 @@ type jsonConfig struct {
 
 	BasicAuthCredentials map[string]string   `json:"basic_auth_credentials"  hidden:"true"`
 	HTTPLogFile          string              `json:"http_log_file"`
+	RequestLog           *RequestLogConfig   `json:"request_log,omitempty"`
 	Headers              map[string][]string `json:"headers"`
 
 	CORSAllowedOrigins   []string `json:"cors_allowed_origins"`
@@ -132,6 +288,24 @@ type jsonConfig struct {
 	CORSExposedHeaders   []string `json:"cors_exposed_headers"`
 	CORSAllowCredentials bool     `json:"cors_allow_credentials"`
 	CORSMaxAge           string   `json:"cors_max_age"`
+
+	AddParams     *AddParamsConfig            `json:"add_params,omitempty"`
+	UserAddParams map[string]*AddParamsConfig `json:"user_add_params,omitempty"`
+
+	PinProfiles     map[string]map[string]string `json:"pin_profiles,omitempty"`
+	UserPinProfiles map[string]string            `json:"user_pin_profiles,omitempty"`
+
+	PinProfileMetadata map[string]*types.MetadataPolicy `json:"pin_profile_metadata,omitempty"`
+
+	EncryptionKeys map[string]string `json:"encryption_keys,omitempty" hidden:"true"`
+
+	Tenancy  *TenancyConfig `json:"tenancy,omitempty"`
+	Policies *PolicyConfig  `json:"policies,omitempty"`
+
+	Libp2pAccess *Libp2pAccessConfig `json:"libp2p_access,omitempty"`
+
+	// Hidden as it holds the credentials for other clusters.
+	Federation *FederationConfig `json:"federation,omitempty" hidden:"true"`
 }
 
 // GetHTTPLogPath gets full path of the file where http logs should be
@@ -180,6 +354,10 @@ func (cfg *Config) Validate() error {
 		return errors.New(cfg.ConfigKey + ".idle_timeout invalid")
 	case cfg.MaxHeaderBytes < minMaxHeaderBytes:
 		return fmt.Errorf(cfg.ConfigKey+".max_header_bytes must be not less then %d", minMaxHeaderBytes)
+	case cfg.MaxBodyBytes < 0:
+		return errors.New(cfg.ConfigKey + ".max_body_bytes is invalid")
+	case cfg.MaxConcurrentRequests < 0:
+		return errors.New(cfg.ConfigKey + ".max_concurrent_requests is invalid")
 	case cfg.BasicAuthCredentials != nil && len(cfg.BasicAuthCredentials) == 0:
 		return errors.New(cfg.ConfigKey + ".basic_auth_creds should be null or have at least one entry")
 	case (cfg.PathSSLCertFile != "" || cfg.PathSSLKeyFile != "") && cfg.TLS == nil:
@@ -188,9 +366,198 @@ func (cfg *Config) Validate() error {
 		return errors.New(cfg.ConfigKey + ".cors_max_age is invalid")
 	}
 
+	if err := cfg.validateListenTLS(); err != nil {
+		return err
+	}
+
+	if err := cfg.validateAddParams(); err != nil {
+		return err
+	}
+
+	if err := cfg.validatePinProfiles(); err != nil {
+		return err
+	}
+
+	if err := cfg.validateEncryptionKeys(); err != nil {
+		return err
+	}
+
+	if err := cfg.validateTenancy(); err != nil {
+		return err
+	}
+
+	if cfg.Policies != nil && len(cfg.Policies.UserRoles) > 0 && len(cfg.BasicAuthCredentials) == 0 {
+		return errors.New(cfg.ConfigKey + ".policies.user_roles requires basic_auth_credentials")
+	}
+	if err := cfg.Policies.validate(); err != nil {
+		return fmt.Errorf("%s.policies: %w", cfg.ConfigKey, err)
+	}
+
+	if err := cfg.Libp2pAccess.validate(cfg.RouteNames); err != nil {
+		return fmt.Errorf("%s.libp2p_access: %w", cfg.ConfigKey, err)
+	}
+
+	if err := cfg.Federation.validate(); err != nil {
+		return fmt.Errorf("%s.federation: %w", cfg.ConfigKey, err)
+	}
+
+	if err := cfg.RequestLog.validate(); err != nil {
+		return fmt.Errorf("%s.request_log: %w", cfg.ConfigKey, err)
+	}
+
 	return cfg.validateLibp2p()
 }
 
+func (cfg *Config) validateAddParams() error {
+	check := func(key string, apc *AddParamsConfig) error {
+		if apc.IsEmpty() {
+			return nil
+		}
+		q := url.Values{}
+		for k, v := range apc.Defaults {
+			q.Set(k, v)
+		}
+		if _, err := types.AddParamsFromQuery(q); err != nil {
+			return fmt.Errorf("%s.%s: %w", cfg.ConfigKey, key, err)
+		}
+		return nil
+	}
+
+	if err := check("add_params", cfg.AddParams); err != nil {
+		return err
+	}
+	for user, apc := range cfg.UserAddParams {
+		if err := check("user_add_params."+user, apc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *Config) validatePinProfiles() error {
+	for name, params := range cfg.PinProfiles {
+		q := url.Values{}
+		for k, v := range params {
+			q.Set(k, v)
+		}
+		if _, err := types.AddParamsFromQuery(q); err != nil {
+			return fmt.Errorf("%s.pin_profiles.%s: %w", cfg.ConfigKey, name, err)
+		}
+	}
+	for user, name := range cfg.UserPinProfiles {
+		if _, ok := cfg.PinProfiles[name]; !ok {
+			return fmt.Errorf("%s.user_pin_profiles.%s: unknown profile %q", cfg.ConfigKey, user, name)
+		}
+	}
+	for name, mp := range cfg.PinProfileMetadata {
+		if _, ok := cfg.PinProfiles[name]; !ok {
+			return fmt.Errorf("%s.pin_profile_metadata: unknown profile %q", cfg.ConfigKey, name)
+		}
+		if err := mp.Validate(); err != nil {
+			return fmt.Errorf("%s.pin_profile_metadata.%s: %w", cfg.ConfigKey, name, err)
+		}
+	}
+	return nil
+}
+
+func (cfg *Config) validateEncryptionKeys() error {
+	for name, key := range cfg.EncryptionKeys {
+		if name == "" || name == types.UserEncryptionKey || strings.Contains(name, ":") {
+			return fmt.Errorf("%s.encryption_keys: invalid key name %q", cfg.ConfigKey, name)
+		}
+		if _, err := encryption.ParseKey(key); err != nil {
+			return fmt.Errorf("%s.encryption_keys.%s: %w", cfg.ConfigKey, name, err)
+		}
+	}
+	return nil
+}
+
+func (cfg *Config) validateTenancy() error {
+	if !cfg.Tenancy.IsEnabled() {
+		return nil
+	}
+	if len(cfg.BasicAuthCredentials) == 0 {
+		return errors.New(cfg.ConfigKey + ".tenancy requires basic_auth_credentials")
+	}
+	check := func(key string, q *Quota) error {
+		if q != nil && q.MaxPins < 0 {
+			return fmt.Errorf("%s.tenancy.%s.max_pins is invalid", cfg.ConfigKey, key)
+		}
+		return nil
+	}
+	if err := check("default_quota", cfg.Tenancy.DefaultQuota); err != nil {
+		return err
+	}
+	for ns, q := range cfg.Tenancy.Quotas {
+		if err := check("quotas."+ns, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddParamsFor returns the default add parameters for the given user,
+// merging AddParams and UserAddParams. The second map indicates which of
+// those parameters are enforced and cannot be changed by the request.
+func (cfg *Config) AddParamsFor(user string) (defaults map[string]string, enforced map[string]bool) {
+	defaults = make(map[string]string)
+	enforced = make(map[string]bool)
+	merge := func(apc *AddParamsConfig) {
+		if apc.IsEmpty() {
+			return
+		}
+		for k, v := range apc.Defaults {
+			defaults[k] = v
+			enforced[k] = apc.Enforce
+		}
+	}
+	merge(cfg.AddParams)
+	merge(cfg.UserAddParams[user])
+	return
+}
+
+// PinProfileFor returns the parameters of the pin profile with the given
+// name or, when empty, of the default profile of the given user. It returns
+// nil when no profile applies, and an error when the profile does not exist.
+func (cfg *Config) PinProfileFor(user, name string) (map[string]string, error) {
+	params, _, err := cfg.pinProfile(user, name)
+	return params, err
+}
+
+// PinProfileMetadataFor returns the metadata policy of the pin profile
+// selected like in PinProfileFor, or nil when it has none.
+func (cfg *Config) PinProfileMetadataFor(user, name string) (*types.MetadataPolicy, error) {
+	_, name, err := cfg.pinProfile(user, name)
+	if err != nil || name == "" {
+		return nil, err
+	}
+	return cfg.PinProfileMetadata[name], nil
+}
+
+// EncryptionKey returns the encryption key with the given name, or an
+// error when it is not configured.
+func (cfg *Config) EncryptionKey(name string) ([]byte, error) {
+	key, ok := cfg.EncryptionKeys[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption key %q", name)
+	}
+	return encryption.ParseKey(key)
+}
+
+func (cfg *Config) pinProfile(user, name string) (map[string]string, string, error) {
+	if name == "" {
+		name = cfg.UserPinProfiles[user]
+	}
+	if name == "" {
+		return nil, "", nil
+	}
+	params, ok := cfg.PinProfiles[name]
+	if !ok {
+		return nil, "", fmt.Errorf("unknown pin profile %q", name)
+	}
+	return params, name, nil
+}
+
 func (cfg *Config) validateLibp2p() error {
 	if cfg.ID != "" || cfg.PrivateKey != nil || len(cfg.Libp2pListenAddr) > 0 {
 		// if one is set, all should be
@@ -237,7 +604,38 @@ func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
 	// Other options
 	cfg.BasicAuthCredentials = jcfg.BasicAuthCredentials
 	cfg.HTTPLogFile = jcfg.HTTPLogFile
+	if jcfg.RequestLog != nil {
+		cfg.RequestLog = jcfg.RequestLog
+	}
 	cfg.Headers = jcfg.Headers
+	if !jcfg.AddParams.IsEmpty() {
+		cfg.AddParams = jcfg.AddParams
+	}
+	if len(jcfg.UserAddParams) > 0 {
+		cfg.UserAddParams = jcfg.UserAddParams
+	}
+	if len(jcfg.PinProfiles) > 0 {
+		cfg.PinProfiles = jcfg.PinProfiles
+	}
+	if len(jcfg.UserPinProfiles) > 0 {
+		cfg.UserPinProfiles = jcfg.UserPinProfiles
+	}
+	if len(jcfg.PinProfileMetadata) > 0 {
+		cfg.PinProfileMetadata = jcfg.PinProfileMetadata
+	}
+	cfg.EncryptionKeys = jcfg.EncryptionKeys
+	if jcfg.Tenancy != nil {
+		cfg.Tenancy = jcfg.Tenancy
+	}
+	if !jcfg.Policies.IsEmpty() {
+		cfg.Policies = jcfg.Policies
+	}
+	if !jcfg.Libp2pAccess.IsEmpty() {
+		cfg.Libp2pAccess = jcfg.Libp2pAccess
+	}
+	if !jcfg.Federation.IsEmpty() {
+		cfg.Federation = jcfg.Federation
+	}
 
 	return cfg.Validate()
 }
@@ -253,18 +651,42 @@ func (cfg *Config) loadHTTPOptions(jcfg *jsonConfig) error {
 			}
 			cfg.HTTPListenAddr = append(cfg.HTTPListenAddr, httpAddr)
 		}
+	} else if len(jcfg.SystemdSockets) > 0 {
+		// Only listen on the sockets from systemd.
+		cfg.HTTPListenAddr = nil
 	}
 
+	if jcfg.UnixSocketMode != "" {
+		mode, err := ParseFileMode(jcfg.UnixSocketMode)
+		if err != nil {
+			return fmt.Errorf("error parsing %s.unix_socket_mode: %s", cfg.ConfigKey, err)
+		}
+		cfg.UnixSocketMode = mode
+	}
+	cfg.SystemdSockets = jcfg.SystemdSockets
+
 	err := cfg.tlsOptions(jcfg)
 	if err != nil {
 		return err
 	}
 
+	err = cfg.listenTLSOptions(jcfg)
+	if err != nil {
+		return err
+	}
+
 	if jcfg.MaxHeaderBytes == 0 {
 		cfg.MaxHeaderBytes = defaultMaxHeaderBytes
 	} else {
 		cfg.MaxHeaderBytes = jcfg.MaxHeaderBytes
 	}
+	// Missing limits keep their defaults. 0 means no limit.
+	if jcfg.MaxBodyBytes != nil {
+		cfg.MaxBodyBytes = *jcfg.MaxBodyBytes
+	}
+	if jcfg.MaxConcurrentRequests != nil {
+		cfg.MaxConcurrentRequests = *jcfg.MaxConcurrentRequests
+	}
 
 	// CORS
 	cfg.CORSAllowedOrigins = jcfg.CORSAllowedOrigins
@@ -316,6 +738,170 @@ func (cfg *Config) tlsOptions(jcfg *jsonConfig) error {
 	return nil
 }
 
+// ListenerTLS is the TLS configuration of one of the HTTP listen
+// addresses, which replaces the one given by ssl_cert_file and
+// ssl_key_file.
+type ListenerTLS struct {
+	SSLCertFile string `json:"ssl_cert_file,omitempty"`
+	SSLKeyFile  string `json:"ssl_key_file,omitempty"`
+	// DisableTLS serves plain HTTP on the address.
+	DisableTLS bool `json:"disable_tls,omitempty"`
+
+	// TLS is loaded from the certificate and key files.
+	TLS *tls.Config `json:"-"`
+}
+
+func (cfg *Config) listenTLSOptions(jcfg *jsonConfig) error {
+	if len(jcfg.HTTPListenTLS) == 0 {
+		cfg.HTTPListenTLS = nil
+		return nil
+	}
+
+	cfg.HTTPListenTLS = make(map[string]*ListenerTLS, len(jcfg.HTTPListenTLS))
+	for addr, ltls := range jcfg.HTTPListenTLS {
+		if ltls == nil {
+			return fmt.Errorf("%s.http_listen_tls: %s has no configuration", cfg.ConfigKey, addr)
+		}
+		// Keys are compared with the listen addresses in their
+		// canonical form.
+		maddr, err := ma.NewMultiaddr(addr)
+		if err != nil {
+			return fmt.Errorf("error parsing %s.http_listen_tls: %s", cfg.ConfigKey, err)
+		}
+		if err := cfg.loadListenerTLS(ltls); err != nil {
+			return fmt.Errorf("%s.http_listen_tls: %s: %w", cfg.ConfigKey, addr, err)
+		}
+		cfg.HTTPListenTLS[maddr.String()] = ltls
+	}
+	return nil
+}
+
+// loadListenerTLS loads the certificate of a listener, when it serves TLS
+// with its own one.
+func (cfg *Config) loadListenerTLS(ltls *ListenerTLS) error {
+	if ltls.SSLCertFile+ltls.SSLKeyFile == "" || ltls.DisableTLS {
+		return nil
+	}
+	cert := ltls.SSLCertFile
+	key := ltls.SSLKeyFile
+	if !filepath.IsAbs(cert) {
+		cert = filepath.Join(cfg.BaseDir, cert)
+	}
+	if !filepath.IsAbs(key) {
+		key = filepath.Join(cfg.BaseDir, key)
+	}
+	tlsCfg, err := newTLSConfig(cert, key)
+	if err != nil {
+		return err
+	}
+	ltls.TLS = tlsCfg
+	return nil
+}
+
+func (cfg *Config) validateListenTLS() error {
+	listening := make(map[string]struct{}, len(cfg.HTTPListenAddr))
+	for _, addr := range cfg.HTTPListenAddr {
+		listening[addr.String()] = struct{}{}
+	}
+	for addr, ltls := range cfg.HTTPListenTLS {
+		if _, ok := listening[addr]; !ok {
+			return fmt.Errorf("%s.http_listen_tls: %s is not in http_listen_multiaddress", cfg.ConfigKey, addr)
+		}
+		if ltls == nil || ltls.DisableTLS {
+			continue
+		}
+		if ltls.TLS == nil {
+			return fmt.Errorf("%s.http_listen_tls: %s: missing TLS configuration", cfg.ConfigKey, addr)
+		}
+	}
+	return nil
+}
+
+// ListenerTLSConfig returns the TLS configuration for the given HTTP listen
+// address, or nil when it serves plain HTTP.
+func (cfg *Config) ListenerTLSConfig(addr ma.Multiaddr) *tls.Config {
+	ltls, ok := cfg.HTTPListenTLS[addr.String()]
+	switch {
+	case !ok || ltls == nil:
+		return cfg.TLS
+	case ltls.DisableTLS:
+		return nil
+	default:
+		return ltls.TLS
+	}
+}
+
+// Listeners returns the settings of the HTTP listeners of the API which can
+// be changed at runtime.
+func (cfg *Config) Listeners() *types.APIListeners {
+	addrs := make([]string, 0, len(cfg.HTTPListenAddr))
+	for _, addr := range cfg.HTTPListenAddr {
+		addrs = append(addrs, addr.String())
+	}
+	return &types.APIListeners{
+		API:                    cfg.ConfigKey,
+		HTTPListenMultiaddress: addrs,
+		SSLCertFile:            cfg.PathSSLCertFile,
+		SSLKeyFile:             cfg.PathSSLKeyFile,
+		CORSAllowedOrigins:     cfg.CORSAllowedOrigins,
+		CORSAllowedMethods:     cfg.CORSAllowedMethods,
+		CORSAllowedHeaders:     cfg.CORSAllowedHeaders,
+		CORSExposedHeaders:     cfg.CORSExposedHeaders,
+		CORSAllowCredentials:   cfg.CORSAllowCredentials,
+		CORSMaxAge:             cfg.CORSMaxAge,
+	}
+}
+
+// listenersConfig returns a configuration with the given listener settings,
+// loading the TLS certificates. The addresses which are kept keep their
+// http_listen_tls options, with their certificates loaded again. Only the
+// fields which can be changed at runtime are set.
+func (cfg *Config) listenersConfig(l *types.APIListeners) (*Config, error) {
+	if len(l.HTTPListenMultiaddress) == 0 {
+		return nil, errors.New(cfg.ConfigKey + ".http_listen_multiaddress cannot be empty")
+	}
+	if l.CORSMaxAge < 0 {
+		return nil, errors.New(cfg.ConfigKey + ".cors_max_age is invalid")
+	}
+
+	newCfg := &Config{
+		ConfigKey:            cfg.ConfigKey,
+		Logger:               cfg.Logger,
+		CORSAllowedOrigins:   l.CORSAllowedOrigins,
+		CORSAllowedMethods:   l.CORSAllowedMethods,
+		CORSAllowedHeaders:   l.CORSAllowedHeaders,
+		CORSExposedHeaders:   l.CORSExposedHeaders,
+		CORSAllowCredentials: l.CORSAllowCredentials,
+		CORSMaxAge:           l.CORSMaxAge,
+	}
+	newCfg.BaseDir = cfg.BaseDir
+
+	for _, addr := range l.HTTPListenMultiaddress {
+		maddr, err := ma.NewMultiaddr(addr)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s.http_listen_multiaddress: %s", cfg.ConfigKey, err)
+		}
+		newCfg.HTTPListenAddr = append(newCfg.HTTPListenAddr, maddr)
+		if ltls, ok := cfg.HTTPListenTLS[maddr.String()]; ok && ltls != nil {
+			reloaded := *ltls
+			reloaded.TLS = nil
+			if err := newCfg.loadListenerTLS(&reloaded); err != nil {
+				return nil, fmt.Errorf("%s.http_listen_tls: %s: %w", cfg.ConfigKey, maddr, err)
+			}
+			if newCfg.HTTPListenTLS == nil {
+				newCfg.HTTPListenTLS = make(map[string]*ListenerTLS)
+			}
+			newCfg.HTTPListenTLS[maddr.String()] = &reloaded
+		}
+	}
+
+	err := newCfg.tlsOptions(&jsonConfig{SSLCertFile: l.SSLCertFile, SSLKeyFile: l.SSLKeyFile})
+	if err != nil {
+		return nil, err
+	}
+	return newCfg, nil
+}
+
 func (cfg *Config) loadLibp2pOptions(jcfg *jsonConfig) error {
 	if addresses := jcfg.Libp2pListenMultiaddress; len(addresses) > 0 {
 		cfg.Libp2pListenAddr = make([]ma.Multiaddr, 0, len(addresses))
@@ -381,17 +967,25 @@ func (cfg *Config) toJSONConfig() (jcfg *jsonConfig, err error) {
 		libp2pAddresses = append(libp2pAddresses, addr.String())
 	}
 
+	maxBodyBytes := cfg.MaxBodyBytes
+	maxConcurrentRequests := cfg.MaxConcurrentRequests
+
 	jcfg = &jsonConfig{
 		HTTPListenMultiaddress: httpAddresses,
+		SystemdSockets:         cfg.SystemdSockets,
 		SSLCertFile:            cfg.PathSSLCertFile,
 		SSLKeyFile:             cfg.PathSSLKeyFile,
+		HTTPListenTLS:          cfg.HTTPListenTLS,
 		ReadTimeout:            cfg.ReadTimeout.String(),
 		ReadHeaderTimeout:      cfg.ReadHeaderTimeout.String(),
 		WriteTimeout:           cfg.WriteTimeout.String(),
 		IdleTimeout:            cfg.IdleTimeout.String(),
 		MaxHeaderBytes:         cfg.MaxHeaderBytes,
+		MaxBodyBytes:           &maxBodyBytes,
+		MaxConcurrentRequests:  &maxConcurrentRequests,
 		BasicAuthCredentials:   cfg.BasicAuthCredentials,
 		HTTPLogFile:            cfg.HTTPLogFile,
+		RequestLog:             cfg.RequestLog,
 		Headers:                cfg.Headers,
 		CORSAllowedOrigins:     cfg.CORSAllowedOrigins,
 		CORSAllowedMethods:     cfg.CORSAllowedMethods,
@@ -399,6 +993,20 @@ func (cfg *Config) toJSONConfig() (jcfg *jsonConfig, err error) {
 		CORSExposedHeaders:     cfg.CORSExposedHeaders,
 		CORSAllowCredentials:   cfg.CORSAllowCredentials,
 		CORSMaxAge:             cfg.CORSMaxAge.String(),
+		AddParams:              cfg.AddParams,
+		UserAddParams:          cfg.UserAddParams,
+		PinProfiles:            cfg.PinProfiles,
+		UserPinProfiles:        cfg.UserPinProfiles,
+		PinProfileMetadata:     cfg.PinProfileMetadata,
+		EncryptionKeys:         cfg.EncryptionKeys,
+		Tenancy:                cfg.Tenancy,
+		Policies:               cfg.Policies,
+		Libp2pAccess:           cfg.Libp2pAccess,
+		Federation:             cfg.Federation,
+	}
+
+	if cfg.UnixSocketMode != 0 {
+		jcfg.UnixSocketMode = fmt.Sprintf("%04o", cfg.UnixSocketMode)
 	}
 
 	if cfg.ID != "" {
@@ -463,6 +1071,14 @@ func newTLSConfig(certFile, keyFile string) (*tls.Config, error) {
 	if err != nil {
 		return nil, errors.New("Error loading TLS certficate/key: " + err.Error())
 	}
+	cfg := tlsServerConfig()
+	cfg.Certificates = []tls.Certificate{cert}
+	return cfg, nil
+}
+
+// tlsServerConfig returns the TLS settings of the HTTP listeners, without
+// certificates.
+func tlsServerConfig() *tls.Config {
 	// based on https://github.com/denji/golang-tls
 	return &tls.Config{
 		MinVersion:               tls.VersionTLS12,
@@ -474,6 +1090,5 @@ func newTLSConfig(certFile, keyFile string) (*tls.Config, error) {
 			tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
 			tls.TLS_RSA_WITH_AES_256_CBC_SHA,
 		},
-		Certificates: []tls.Certificate{cert},
-	}, nil
+	}
 }
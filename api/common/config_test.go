@@ -4,9 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	rpctest "github.com/ipfs/ipfs-cluster/test"
+
 	logging "github.com/ipfs/go-log/v2"
 	crypto "github.com/libp2p/go-libp2p-core/crypto"
 	peer "github.com/libp2p/go-libp2p-core/peer"
@@ -21,6 +24,8 @@ var (
 	DefaultWriteTimeout       = 0 * time.Second
 	DefaultIdleTimeout        = 120 * time.Second
 	DefaultMaxHeaderBytes     = minMaxHeaderBytes
+	DefaultMaxBodyBytes       = int64(1 << 20)
+	DefaultMaxConcurrent      = 512
 	DefaultHTTPListenAddrs    = []string{"/ip4/127.0.0.1/tcp/9094"}
 	DefaultHeaders            = map[string][]string{}
 	DefaultCORSAllowedOrigins = []string{"*"}
@@ -54,6 +59,8 @@ func defaultFunc(cfg *Config) error {
 	cfg.WriteTimeout = DefaultWriteTimeout
 	cfg.IdleTimeout = DefaultIdleTimeout
 	cfg.MaxHeaderBytes = DefaultMaxHeaderBytes
+	cfg.MaxBodyBytes = DefaultMaxBodyBytes
+	cfg.MaxConcurrentRequests = DefaultMaxConcurrent
 
 	// libp2p
 	cfg.ID = ""
@@ -63,8 +70,18 @@ func defaultFunc(cfg *Config) error {
 	// Auth
 	cfg.BasicAuthCredentials = nil
 
+	// Add
+	cfg.AddParams = nil
+	cfg.UserAddParams = nil
+	cfg.PinProfiles = nil
+	cfg.UserPinProfiles = nil
+	cfg.Tenancy = nil
+	cfg.Policies = nil
+	cfg.Federation = nil
+
 	// Logs
 	cfg.HTTPLogFile = ""
+	cfg.RequestLog = nil
 
 	// Headers
 	cfg.Headers = DefaultHeaders
@@ -125,6 +142,17 @@ func TestLoadEmptyJSON(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	if cfg.MaxBodyBytes != DefaultMaxBodyBytes || cfg.MaxConcurrentRequests != DefaultMaxConcurrent {
+		t.Error("missing limits should keep their defaults")
+	}
+
+	err = cfg.LoadJSON([]byte(`{"max_body_bytes": 0, "max_concurrent_requests": 0}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MaxBodyBytes != 0 || cfg.MaxConcurrentRequests != 0 {
+		t.Error("limits set to 0 should be disabled")
+	}
 }
 
 func TestLoadJSON(t *testing.T) {
@@ -169,6 +197,329 @@ func TestLoadJSON(t *testing.T) {
 		t.Error("expected error with empty basic auth map")
 	}
 
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.AddParams = &AddParamsConfig{
+		Defaults: map[string]string{"cid-version": "abc"},
+	}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with bad add_params")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.AddParams = &AddParamsConfig{
+		Defaults: map[string]string{"cid-version": "1"},
+		Enforce:  true,
+	}
+	j.UserAddParams = map[string]*AddParamsConfig{
+		"user1": {Defaults: map[string]string{"cid-version": "0"}},
+	}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults, enforced := cfg.AddParamsFor("user1")
+	if defaults["cid-version"] != "0" || enforced["cid-version"] {
+		t.Error("expected user1 add params to take precedence")
+	}
+	defaults, enforced = cfg.AddParamsFor("user2")
+	if defaults["cid-version"] != "1" || !enforced["cid-version"] {
+		t.Error("expected enforced add params")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.PinProfiles = map[string]map[string]string{
+		"archive": {"replication": "5"},
+		"cache":   {"replication": "1", "expire-in": "168h"},
+	}
+	j.UserPinProfiles = map[string]string{"user1": "cache"}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := cfg.PinProfileFor("user1", ""); p["expire-in"] != "168h" {
+		t.Error("expected the default profile of user1")
+	}
+	if p, _ := cfg.PinProfileFor("user1", "archive"); p["replication"] != "5" {
+		t.Error("expected the selected profile")
+	}
+	if p, err := cfg.PinProfileFor("user2", ""); p != nil || err != nil {
+		t.Error("expected no profile for user2")
+	}
+	if _, err := cfg.PinProfileFor("user2", "other"); err == nil {
+		t.Error("expected an error with an unknown profile")
+	}
+
+	j.UserPinProfiles = map[string]string{"user1": "other"}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with an unknown user pin profile")
+	}
+
+	j.UserPinProfiles = nil
+	j.PinProfiles["cache"]["expire-in"] = "7 days"
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with a bad pin profile")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.EncryptionKeys = map[string]string{"team": strings.Repeat("ab", 32)}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, err := cfg.EncryptionKey("team"); err != nil || len(key) != 32 {
+		t.Error("expected the team encryption key:", err)
+	}
+	if _, err := cfg.EncryptionKey("other"); err == nil {
+		t.Error("expected an error with an unknown encryption key")
+	}
+
+	for _, keys := range []map[string]string{
+		{"team": "abcd"},
+		{"user": strings.Repeat("ab", 32)},
+		{"a:b": strings.Repeat("ab", 32)},
+	} {
+		j.EncryptionKeys = keys
+		tst, _ = json.Marshal(j)
+		if err := cfg.LoadJSON(tst); err == nil {
+			t.Errorf("expected error with encryption keys %v", keys)
+		}
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.BasicAuthCredentials = nil
+	j.Tenancy = &TenancyConfig{Enabled: true}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error enabling tenancy without basic auth")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.BasicAuthCredentials = map[string]string{"user1": "pass", "admin": "pass"}
+	j.Tenancy = &TenancyConfig{
+		Enabled:      true,
+		AdminUsers:   []string{"admin"},
+		DefaultQuota: &Quota{MaxPins: 10},
+		Quotas:       map[string]*Quota{"user1": {MaxBytes: 1024}},
+	}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ns, restricted := cfg.Tenancy.Namespace("user1"); ns != "user1" || !restricted {
+		t.Error("user1 should be restricted to its namespace")
+	}
+	if _, restricted := cfg.Tenancy.Namespace("admin"); restricted {
+		t.Error("admins should not be restricted")
+	}
+	if q := cfg.Tenancy.QuotaFor("user1"); q.MaxBytes != 1024 || q.MaxPins != 0 {
+		t.Error("expected the user1 quota")
+	}
+	if q := cfg.Tenancy.QuotaFor("user2"); q.MaxPins != 10 {
+		t.Error("expected the default quota")
+	}
+
+	j.Tenancy.Quotas["user1"].MaxPins = -1
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with negative max_pins")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.Federation = &FederationConfig{
+		Name: "local",
+		Clusters: []*FederatedCluster{
+			{Name: "eu", URL: "https://eu.example.org:9094"},
+		},
+	}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Federation.Clusters) != 1 || cfg.Federation.Clusters[0].URL != "https://eu.example.org:9094" {
+		t.Error("expected a federated cluster")
+	}
+
+	j.Federation.Clusters = append(j.Federation.Clusters, &FederatedCluster{Name: "local", URL: "http://localhost:9094"})
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with a repeated cluster name")
+	}
+
+	j.Federation.Clusters[1] = &FederatedCluster{Name: "us", URL: "localhost:9094"}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with a federated cluster without an http URL")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	negBody := int64(-1)
+	j.MaxBodyBytes = &negBody
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with negative max_body_bytes")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	negRequests := -1
+	j.MaxConcurrentRequests = &negRequests
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with negative max_concurrent_requests")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.UnixSocketMode = "abc"
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with a bad unix_socket_mode")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.HTTPListenMultiaddress = nil
+	j.UnixSocketMode = "0660"
+	j.SystemdSockets = []string{"cluster-restapi"}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.HTTPListenAddr) != 0 || cfg.UnixSocketMode != 0660 || len(cfg.SystemdSockets) != 1 {
+		t.Error("expected only systemd sockets to be used")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.HTTPListenMultiaddress = []string{"/ip4/127.0.0.1/tcp/9094", "/ip6/::1/tcp/9094", "/ip6/::/tcp/9095"}
+	j.HTTPListenTLS = map[string]*ListenerTLS{
+		"/ip6/::1/tcp/9094": {DisableTLS: true},
+		"/ip6/::/tcp/9095":  {SSLCertFile: "test/server.crt", SSLKeyFile: "test/server.key"},
+	}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.HTTPListenAddr) != 3 {
+		t.Fatal("expected three listen addresses")
+	}
+	if cfg.ListenerTLSConfig(cfg.HTTPListenAddr[0]) != cfg.TLS {
+		t.Error("expected the default TLS configuration for the first address")
+	}
+	if cfg.ListenerTLSConfig(cfg.HTTPListenAddr[1]) != nil {
+		t.Error("expected plain HTTP on the second address")
+	}
+	if tlsCfg := cfg.ListenerTLSConfig(cfg.HTTPListenAddr[2]); tlsCfg == nil || tlsCfg == cfg.TLS {
+		t.Error("expected a separate TLS configuration for the third address")
+	}
+
+	j.HTTPListenTLS = map[string]*ListenerTLS{
+		"/ip4/10.0.0.1/tcp/9094": {DisableTLS: true},
+	}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with http_listen_tls for an address which is not listened on")
+	}
+
+	j.HTTPListenTLS = map[string]*ListenerTLS{
+		"/ip4/127.0.0.1/tcp/9094": {SSLCertFile: "abc", SSLKeyFile: "def"},
+	}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with a bad certificate in http_listen_tls")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.BasicAuthCredentials = map[string]string{"user1": "pass"}
+	j.Policies = &PolicyConfig{
+		Roles:     map[string]*Role{"admin": {Allow: []string{"*"}}},
+		UserRoles: map[string][]string{"user1": {"operator"}},
+	}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with an unknown role in policies")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.Libp2pAccess = &Libp2pAccessConfig{
+		AllowedPeers:   []peer.ID{rpctest.PeerID1},
+		RateLimit:      0.5,
+		DisabledRoutes: []string{"PeerRemove"},
+	}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Libp2pAccess.AllowedPeers) != 1 || cfg.Libp2pAccess.RateLimit != 0.5 ||
+		!cfg.Libp2pAccess.routeDisabled("PeerRemove") || cfg.Libp2pAccess.routeDisabled("Pin") {
+		t.Errorf("unexpected libp2p_access: %+v", cfg.Libp2pAccess)
+	}
+
+	j.Libp2pAccess = &Libp2pAccessConfig{RateLimit: -1}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with a negative libp2p_access.rate_limit")
+	}
+
+	cfg.RouteNames = []string{"Pin", "PeerRemove"}
+	j.Libp2pAccess = &Libp2pAccessConfig{DisabledRoutes: []string{"PeerRemove"}}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err != nil {
+		t.Error("expected a known route to be accepted:", err)
+	}
+	j.Libp2pAccess = &Libp2pAccessConfig{DisabledRoutes: []string{"PeerRemoval"}}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with an unknown route in libp2p_access.disabled_routes")
+	}
+	cfg.RouteNames = nil
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.RequestLog = &RequestLogConfig{Format: "xml"}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with an unknown request_log format")
+	}
+
 	j = &jsonConfig{}
 	json.Unmarshal(cfgJSON, j)
 	j.SSLCertFile = "abc"
@@ -0,0 +1,62 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// FederationConfig configures a read-only view which aggregates the APIs of
+// several clusters. Every object in the view is labelled with the name of
+// the cluster it comes from.
+type FederationConfig struct {
+	// Name labels the objects of the cluster of this peer. When empty,
+	// the view only includes the Clusters below, which allows running
+	// a peer as a standalone aggregator.
+	Name string `json:"name,omitempty"`
+	// Clusters are the other clusters in the view.
+	Clusters []*FederatedCluster `json:"clusters"`
+}
+
+// FederatedCluster is a cluster which is part of a federated view, reached
+// through its REST API.
+type FederatedCluster struct {
+	Name string `json:"name"`
+	// URL of the REST API, i.e. "https://cluster.example.org:9094".
+	URL string `json:"url"`
+	// Username and Password are sent as basic-auth credentials,
+	// when set.
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// IsEmpty returns true when no clusters are part of the view.
+func (fc *FederationConfig) IsEmpty() bool {
+	return fc == nil || (fc.Name == "" && len(fc.Clusters) == 0)
+}
+
+func (fc *FederationConfig) validate() error {
+	if fc.IsEmpty() {
+		return nil
+	}
+
+	names := make(map[string]struct{})
+	if fc.Name != "" {
+		names[fc.Name] = struct{}{}
+	}
+	for _, c := range fc.Clusters {
+		if c == nil || c.Name == "" {
+			return errors.New("clusters must have a name")
+		}
+		if _, ok := names[c.Name]; ok {
+			return fmt.Errorf("cluster name %q is used more than once", c.Name)
+		}
+		names[c.Name] = struct{}{}
+
+		u, err := url.Parse(c.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%s: %q is not an http or https URL", c.Name, c.URL)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	gostream "github.com/libp2p/go-libp2p-gostream"
+)
+
+// Libp2pAccessConfig restricts the requests made over the libp2p transport
+// of the API. They do not affect the HTTP listeners.
+type Libp2pAccessConfig struct {
+	// AllowedPeers lists the peers which can use the API over libp2p.
+	// When empty, any peer can.
+	AllowedPeers []peer.ID `json:"allowed_peers,omitempty"`
+	// RateLimit is the number of requests per second that each peer can
+	// make. 0 means no limit.
+	RateLimit float64 `json:"rate_limit,omitempty"`
+	// RateBurst is the number of requests that a peer can make at once
+	// before being limited. It defaults to the rate limit, rounded up.
+	RateBurst int `json:"rate_burst,omitempty"`
+	// DisabledRoutes lists the routes, by name (i.e. "PeerRemove"),
+	// which are only served over HTTP.
+	DisabledRoutes []string `json:"disabled_routes,omitempty"`
+}
+
+// IsEmpty returns true when the libp2p transport is not restricted.
+func (lac *Libp2pAccessConfig) IsEmpty() bool {
+	return lac == nil ||
+		(len(lac.AllowedPeers) == 0 && lac.RateLimit == 0 && len(lac.DisabledRoutes) == 0)
+}
+
+// validate checks the configuration. When routes are given, disabled
+// routes must be among them.
+func (lac *Libp2pAccessConfig) validate(routes []string) error {
+	if lac == nil {
+		return nil
+	}
+	if lac.RateLimit < 0 || math.IsInf(lac.RateLimit, 0) || math.IsNaN(lac.RateLimit) {
+		return errors.New("rate_limit is invalid")
+	}
+	if lac.RateBurst < 0 {
+		return errors.New("rate_burst is invalid")
+	}
+	known := make(map[string]struct{}, len(routes))
+	for _, r := range routes {
+		known[r] = struct{}{}
+	}
+	for _, r := range lac.DisabledRoutes {
+		if r == "" {
+			return errors.New("disabled_routes has an empty route")
+		}
+		if _, ok := known[r]; len(known) > 0 && !ok {
+			return fmt.Errorf("disabled_routes has an unknown route: %s", r)
+		}
+	}
+	return nil
+}
+
+func (lac *Libp2pAccessConfig) routeDisabled(route string) bool {
+	if lac == nil {
+		return false
+	}
+	for _, r := range lac.DisabledRoutes {
+		if r == route {
+			return true
+		}
+	}
+	return false
+}
+
+type libp2pPeerKey struct{}
+
+// libp2pConnContext marks the requests received over the libp2p transport
+// with the ID of the peer that sends them.
+func libp2pConnContext(ctx context.Context, c net.Conn) context.Context {
+	if c.RemoteAddr().Network() != gostream.Network {
+		return ctx
+	}
+	pid, err := peer.Decode(c.RemoteAddr().String())
+	if err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, libp2pPeerKey{}, pid)
+}
+
+// Libp2pPeer returns the ID of the peer which sent a request over the
+// libp2p transport. It returns false for requests received over HTTP.
+func Libp2pPeer(r *http.Request) (peer.ID, bool) {
+	pid, ok := r.Context().Value(libp2pPeerKey{}).(peer.ID)
+	return pid, ok
+}
+
+// libp2pAccessHandler wraps a handler so that requests over libp2p from
+// peers which are not allowed, or over their rate limit, are rejected.
+func libp2pAccessHandler(lac *Libp2pAccessConfig, h http.Handler) http.Handler {
+	if lac == nil || (len(lac.AllowedPeers) == 0 && lac.RateLimit == 0) {
+		return h
+	}
+
+	allowed := make(map[peer.ID]struct{}, len(lac.AllowedPeers))
+	for _, pid := range lac.AllowedPeers {
+		allowed[pid] = struct{}{}
+	}
+	var limiter *peerRateLimiter
+	if lac.RateLimit > 0 {
+		limiter = newPeerRateLimiter(lac.RateLimit, lac.RateBurst)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pid, ok := Libp2pPeer(r)
+		if !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if _, ok := allowed[pid]; len(allowed) > 0 && !ok {
+			sendLimitError(w, http.StatusForbidden, "peer not allowed to use the API over libp2p")
+			return
+		}
+		if limiter != nil && !limiter.allow(pid, time.Now()) {
+			w.Header().Set("Retry-After", "1")
+			sendLimitError(w, http.StatusTooManyRequests, "too many requests from this peer")
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// libp2pRouteHandler wraps the handler for a route so that it is not
+// served over libp2p when it is disabled there.
+func (api *API) libp2pRouteHandler(route string, h http.Handler) http.Handler {
+	if !api.config.Libp2pAccess.routeDisabled(route) {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := Libp2pPeer(r); ok {
+			api.SendResponse(w, http.StatusForbidden, fmt.Errorf("%s is not available over libp2p", route), nil)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// peerRateLimiter keeps a token bucket for every peer.
+type peerRateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[peer.ID]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Buckets which are full again are dropped when there are more than this.
+const maxIdleRateBuckets = 1024
+
+func newPeerRateLimiter(rate float64, burst int) *peerRateLimiter {
+	b := float64(burst)
+	if burst == 0 {
+		b = math.Ceil(rate)
+	}
+	return &peerRateLimiter{
+		rate:    rate,
+		burst:   b,
+		buckets: make(map[peer.ID]*tokenBucket),
+	}
+}
+
+func (rl *peerRateLimiter) allow(pid peer.ID, now time.Time) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if len(rl.buckets) > maxIdleRateBuckets {
+		for p, b := range rl.buckets {
+			if b.tokens+now.Sub(b.last).Seconds()*rl.rate >= rl.burst {
+				delete(rl.buckets, p)
+			}
+		}
+	}
+
+	b, ok := rl.buckets[pid]
+	if !ok {
+		b = &tokenBucket{tokens: rl.burst, last: now}
+		rl.buckets[pid] = b
+	}
+	b.tokens = math.Min(rl.burst, b.tokens+now.Sub(b.last).Seconds()*rl.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,122 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/api/common/test"
+	rpctest "github.com/ipfs/ipfs-cluster/test"
+
+	libp2p "github.com/libp2p/go-libp2p"
+	host "github.com/libp2p/go-libp2p-core/host"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	peerstore "github.com/libp2p/go-libp2p-core/peerstore"
+)
+
+func libp2pRequest(pid peer.ID) *http.Request {
+	r := httptest.NewRequest("GET", "/", nil)
+	return r.WithContext(context.WithValue(r.Context(), libp2pPeerKey{}, pid))
+}
+
+func TestLibp2pAccessHandler(t *testing.T) {
+	h := libp2pAccessHandler(&Libp2pAccessConfig{
+		AllowedPeers: []peer.ID{rpctest.PeerID1, rpctest.PeerID2},
+		RateLimit:    1,
+		RateBurst:    2,
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(r *http.Request) int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if code := serve(httptest.NewRequest("GET", "/", nil)); code != http.StatusOK {
+		t.Error("HTTP requests should not be restricted:", code)
+	}
+	if code := serve(libp2pRequest(rpctest.PeerID3)); code != http.StatusForbidden {
+		t.Error("expected 403 for a peer which is not allowed:", code)
+	}
+
+	for i := 0; i < 2; i++ {
+		if code := serve(libp2pRequest(rpctest.PeerID1)); code != http.StatusOK {
+			t.Error("expected the burst to be allowed:", code)
+		}
+	}
+	if code := serve(libp2pRequest(rpctest.PeerID1)); code != http.StatusTooManyRequests {
+		t.Error("expected 429 over the rate limit:", code)
+	}
+	// Limits are per peer.
+	if code := serve(libp2pRequest(rpctest.PeerID2)); code != http.StatusOK {
+		t.Error("expected another peer not to be limited:", code)
+	}
+}
+
+func TestPeerRateLimiter(t *testing.T) {
+	rl := newPeerRateLimiter(2, 0)
+	now := time.Now()
+	if !rl.allow(rpctest.PeerID1, now) || !rl.allow(rpctest.PeerID1, now) {
+		t.Fatal("expected a burst of 2 requests")
+	}
+	if rl.allow(rpctest.PeerID1, now) {
+		t.Error("expected the third request to be limited")
+	}
+	if !rl.allow(rpctest.PeerID1, now.Add(500*time.Millisecond)) {
+		t.Error("expected a token after half a second")
+	}
+	if rl.allow(rpctest.PeerID1, now.Add(500*time.Millisecond)) {
+		t.Error("expected a single token after half a second")
+	}
+}
+
+func TestLibp2pAccess(t *testing.T) {
+	ctx := context.Background()
+	client, err := libp2p.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	cfg := newDefaultTestConfig(t)
+	cfg.Libp2pAccess = &Libp2pAccessConfig{
+		AllowedPeers:   []peer.ID{client.ID()},
+		DisabledRoutes: []string{"Test"},
+	}
+	rest := testAPIwithConfig(t, cfg, "libp2p access")
+	defer rest.Shutdown(ctx)
+	client.Peerstore().AddAddrs(rest.Host().ID(), rest.Host().Addrs(), peerstore.PermanentAddrTTL)
+
+	get := func(h host.Host) *api.Error {
+		resp, err := test.HTTPClient(t, h, false).Get(test.P2pURL(rest) + "/test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var errResp api.Error
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		return &errResp
+	}
+
+	other := test.MakeHost(t, rest)
+	defer other.Close()
+	if errResp := get(other); errResp.Code != http.StatusForbidden {
+		t.Error("expected 403 for a peer which is not allowed:", errResp.Code)
+	}
+
+	errResp := get(client)
+	if errResp.Code != http.StatusForbidden || !strings.Contains(errResp.Message, "not available over libp2p") {
+		t.Errorf("expected 403 for a route disabled over libp2p: %+v", errResp)
+	}
+
+	r := make(map[string]string)
+	test.MakeGet(t, rest, test.HTTPURL(rest)+"/test", &r)
+	if r["thisis"] != "atest" {
+		t.Error("expected the route to be served over HTTP")
+	}
+}
@@ -0,0 +1,59 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	types "github.com/ipfs/ipfs-cluster/api"
+)
+
+// LimitConcurrency wraps a handler so that at most max requests are served
+// at the same time. Requests beyond that get a 429 Too Many Requests
+// response. A max of 0 or less means no limit.
+func LimitConcurrency(h http.Handler, max int) http.Handler {
+	if max <= 0 {
+		return h
+	}
+
+	slots := make(chan struct{}, max)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+			h.ServeHTTP(w, r)
+		default:
+			w.Header().Set("Retry-After", "1")
+			sendLimitError(w, http.StatusTooManyRequests, "too many concurrent requests")
+		}
+	})
+}
+
+// LimitBody wraps a handler so that request bodies larger than max bytes
+// are rejected. Requests declaring a larger Content-Length get a 413
+// Request Entity Too Large response, while reading beyond the limit from
+// bodies of unknown size fails. A max of 0 or less means no limit.
+func LimitBody(h http.Handler, max int64) http.Handler {
+	if max <= 0 {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > max {
+			sendLimitError(w, http.StatusRequestEntityTooLarge,
+				fmt.Sprintf("request body larger than %d bytes", max))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, max)
+		h.ServeHTTP(w, r)
+	})
+}
+
+func sendLimitError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(types.Error{
+		Code:    status,
+		Message: msg,
+	})
+}
@@ -0,0 +1,71 @@
+package common
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLimitConcurrency(t *testing.T) {
+	block := make(chan struct{})
+	started := make(chan struct{})
+	h := LimitConcurrency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-block
+	}), 1)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+	<-started
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusTooManyRequests {
+		t.Error("expected 429:", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("expected a Retry-After header")
+	}
+
+	close(block)
+	<-done
+
+	// The slot is free again.
+	go func() { <-started }()
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK {
+		t.Error("expected 200:", w.Code)
+	}
+}
+
+func TestLimitBody(t *testing.T) {
+	var readErr error
+	h := LimitBody(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = ioutil.ReadAll(r.Body)
+	}), 10)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/", bytes.NewReader(make([]byte, 20))))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Error("expected 413:", w.Code)
+	}
+
+	// Unknown length
+	r := httptest.NewRequest("POST", "/", bytes.NewReader(make([]byte, 20)))
+	r.ContentLength = -1
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if readErr == nil {
+		t.Error("expected an error reading a large body")
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", bytes.NewReader(make([]byte, 5))))
+	if readErr != nil {
+		t.Error("small bodies should be read:", readErr)
+	}
+}
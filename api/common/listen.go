@@ -0,0 +1,158 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// Listen opens a listener on the given multiaddress. For Unix domain
+// sockets (/unix/path), stale socket files left behind by previous runs are
+// removed and, when mode is not 0, the permissions of the socket file are
+// set to it. In that case the socket is created accessible only by its
+// owner, so that it never has the default permissions before they are set.
+func Listen(addr ma.Multiaddr, mode os.FileMode) (net.Listener, error) {
+	n, a, err := manet.DialArgs(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if n != "unix" {
+		return net.Listen(n, a)
+	}
+
+	if err := removeStaleSocket(a); err != nil {
+		return nil, err
+	}
+	if mode == 0 {
+		return net.Listen(n, a)
+	}
+
+	restore := restrictUmask()
+	l, err := net.Listen(n, a)
+	restore()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(a, mode); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("setting permissions of %s: %w", a, err)
+	}
+	return l, nil
+}
+
+// removeStaleSocket removes the socket file at the given path when nothing
+// is listening on it.
+func removeStaleSocket(path string) error {
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use", path)
+	}
+	return os.Remove(path)
+}
+
+// ParseFileMode parses file permissions in octal notation (i.e. "0660").
+func ParseFileMode(s string) (os.FileMode, error) {
+	m, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return 0, err
+	}
+	if m > 0777 {
+		return 0, fmt.Errorf("invalid file mode %s", s)
+	}
+	return os.FileMode(m), nil
+}
+
+// The environment variables set by systemd for socket activated services.
+// See sd_listen_fds(3).
+const (
+	systemdListenPID     = "LISTEN_PID"
+	systemdListenFDs     = "LISTEN_FDS"
+	systemdListenFDNames = "LISTEN_FDNAMES"
+	systemdFirstFD       = 3
+)
+
+var (
+	systemdOnce  sync.Once
+	systemdFiles map[string][]*os.File
+	systemdErr   error
+)
+
+// loadSystemdFiles reads the file descriptors passed by systemd, indexed by
+// their name (FileDescriptorName= in the socket unit, which defaults to the
+// unit name).
+func loadSystemdFiles() {
+	systemdFiles = make(map[string][]*os.File)
+
+	pid, err := strconv.Atoi(os.Getenv(systemdListenPID))
+	if err != nil || pid != os.Getpid() {
+		return
+	}
+	nfds, err := strconv.Atoi(os.Getenv(systemdListenFDs))
+	if err != nil {
+		systemdErr = fmt.Errorf("bad %s: %w", systemdListenFDs, err)
+		return
+	}
+	names := strings.Split(os.Getenv(systemdListenFDNames), ":")
+
+	for i := 0; i < nfds; i++ {
+		fd := systemdFirstFD + i
+		name := "unknown"
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		systemdFiles[name] = append(systemdFiles[name], os.NewFile(uintptr(fd), name))
+	}
+}
+
+// SystemdListeners returns listeners for the sockets passed by systemd
+// socket activation with the given names. It errors if any of them has not
+// been passed.
+func SystemdListeners(names []string) ([]net.Listener, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	systemdOnce.Do(loadSystemdFiles)
+	if systemdErr != nil {
+		return nil, systemdErr
+	}
+	if len(systemdFiles) == 0 {
+		return nil, errors.New("no sockets were passed by systemd")
+	}
+
+	var listeners []net.Listener
+	for _, name := range names {
+		files, ok := systemdFiles[name]
+		if !ok {
+			return nil, fmt.Errorf("systemd socket %q not found", name)
+		}
+		for _, f := range files {
+			// FileListener duplicates the descriptor, so the
+			// original can be used again.
+			l, err := net.FileListener(f)
+			if err != nil {
+				return nil, fmt.Errorf("systemd socket %q: %w", name, err)
+			}
+			listeners = append(listeners, l)
+		}
+	}
+	return listeners, nil
+}
@@ -0,0 +1,92 @@
+package common
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func TestListenUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.sock")
+	addr, err := ma.NewMultiaddr("/unix" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := Listen(addr, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Error("unexpected socket permissions:", fi.Mode().Perm())
+	}
+
+	if _, err := Listen(addr, 0); err == nil {
+		t.Error("expected an error listening on a socket in use")
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	l.Close()
+
+	// Leave a stale socket file behind.
+	ul, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ul.SetUnlinkOnClose(false)
+	ul.Close()
+
+	l, err = Listen(addr, 0)
+	if err != nil {
+		t.Fatal("stale sockets should be replaced:", err)
+	}
+	l.Close()
+
+	if err := ioutil.WriteFile(path, []byte("abc"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Listen(addr, 0); err == nil {
+		t.Error("expected an error when the path is not a socket")
+	}
+}
+
+func TestParseFileMode(t *testing.T) {
+	m, err := ParseFileMode("0660")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m != 0660 {
+		t.Error("unexpected mode:", m)
+	}
+
+	for _, s := range []string{"abc", "0999", "7777"} {
+		if _, err := ParseFileMode(s); err == nil {
+			t.Error("expected an error parsing", s)
+		}
+	}
+}
+
+func TestSystemdListeners(t *testing.T) {
+	l, err := SystemdListeners(nil)
+	if err != nil || l != nil {
+		t.Error("no listeners should be returned when none are requested")
+	}
+
+	// This process was not started by systemd.
+	if _, err := SystemdListeners([]string{"cluster-restapi"}); err == nil {
+		t.Error("expected an error without systemd sockets")
+	}
+}
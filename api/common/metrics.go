@@ -0,0 +1,67 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/observations"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// statusRecorder is an http.ResponseWriter which remembers the status code
+// of the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(code int) {
+	if sr.status == 0 {
+		sr.status = code
+	}
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	return sr.ResponseWriter.Write(b)
+}
+
+func (sr *statusRecorder) Flush() {
+	if f, ok := sr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// metricsHandler records the latency of the requests to a route, tagged with
+// the response status code so that error rates can be derived from it.
+func metricsHandler(apiName, route string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sr := &statusRecorder{ResponseWriter: w}
+		h.ServeHTTP(sr, r)
+		if sr.status == 0 {
+			sr.status = http.StatusOK
+		}
+		recordRequestLatency(r.Context(), apiName, route, sr.status, time.Since(start))
+	})
+}
+
+func recordRequestLatency(ctx context.Context, apiName, route string, status int, latency time.Duration) {
+	ctx, err := tag.New(
+		ctx,
+		tag.Upsert(observations.APIKey, apiName),
+		tag.Upsert(observations.RouteKey, route),
+		tag.Upsert(observations.StatusKey, strconv.Itoa(status)),
+	)
+	if err != nil {
+		return
+	}
+	stats.Record(ctx, observations.APIRequestLatency.M(float64(latency)/float64(time.Millisecond)))
+}
@@ -0,0 +1,47 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/observations"
+
+	"go.opencensus.io/stats/view"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	if err := view.Register(observations.APIRequestLatencyView); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(observations.APIRequestLatencyView)
+
+	h := metricsHandler("testapi", "Test", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	for _, url := range []string{"/test", "/test", "/test?fail=1"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", url, nil))
+	}
+
+	rows, err := view.RetrieveData(observations.APIRequestLatencyView.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int64)
+	for _, row := range rows {
+		var status string
+		for _, tg := range row.Tags {
+			if tg.Key == observations.StatusKey {
+				status = tg.Value
+			}
+		}
+		counts[status] = row.Data.(*view.DistributionData).Count
+	}
+	if counts["200"] != 2 || counts["500"] != 1 {
+		t.Errorf("unexpected request counts by status: %v", counts)
+	}
+}
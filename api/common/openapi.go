@@ -0,0 +1,329 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	types "github.com/ipfs/ipfs-cluster/api"
+
+	mux "github.com/gorilla/mux"
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+// The OpenAPI document of an API is generated from the routes registered in
+// its router, so every route is always included. Each route is described by
+// the RouteSpec with its name, and the schemas of the request and response
+// bodies are obtained by reflection from example values, following their
+// JSON tags.
+
+const openAPIVersion = "3.0.3"
+
+// Param describes a query parameter of a route.
+type Param struct {
+	Name        string
+	Description string
+	// Type is the JSON schema type of the parameter. Defaults to
+	// "string".
+	Type string
+}
+
+// RouteSpec describes a route in the OpenAPI document. Path parameters are
+// obtained from the route pattern.
+type RouteSpec struct {
+	Summary string
+	Query   []Param
+	// Request is a value of the type of the JSON body of the request, or
+	// nil when it takes no body.
+	Request interface{}
+	// RequestContentType is set for non-JSON request bodies, which are
+	// described as binary.
+	RequestContentType string
+	// Response is a value of the type of the JSON response body, or nil
+	// when the route returns no content.
+	Response interface{}
+	// ResponseContentType is set for non-JSON responses, which are
+	// described as binary.
+	ResponseContentType string
+}
+
+// Known types which marshal to JSON in their own way.
+var openAPIKnownTypes = map[reflect.Type]map[string]interface{}{
+	reflect.TypeOf(cid.Cid{}): {
+		"type":       "object",
+		"properties": map[string]interface{}{"/": map[string]interface{}{"type": "string"}},
+	},
+	reflect.TypeOf(peer.ID("")):          {"type": "string"},
+	reflect.TypeOf(time.Time{}):          {"type": "string", "format": "date-time"},
+	reflect.TypeOf(time.Duration(0)):     {"type": "integer", "format": "int64"},
+	reflect.TypeOf(types.Multiaddr{}):    {"type": "string"},
+	reflect.TypeOf(json.RawMessage{}):    {},
+	reflect.TypeOf((*error)(nil)).Elem(): {"type": "string"},
+}
+
+var jsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+type schemaGenerator struct {
+	schemas map[string]interface{}
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	if s, ok := openAPIKnownTypes[t]; ok {
+		return s
+	}
+	if t.Kind() == reflect.Ptr {
+		return g.schema(t.Elem())
+	}
+	if t.Implements(jsonMarshaler) || reflect.PtrTo(t).Implements(jsonMarshaler) {
+		return marshalerSchema(t)
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := t.Name()
+		if _, ok := g.schemas[name]; !ok {
+			// Placeholder for recursive types.
+			g.schemas[name] = nil
+			g.schemas[name] = g.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	props := make(map[string]interface{})
+	g.addFields(t, props)
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": props,
+	}
+}
+
+func (g *schemaGenerator) addFields(t reflect.Type, props map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addFields(ft, props)
+				continue
+			}
+		}
+		if f.PkgPath != "" { // unexported
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.schema(f.Type)
+	}
+}
+
+// marshalerSchema finds out the JSON type of a value with a custom
+// marshaler from its zero value.
+func marshalerSchema(t reflect.Type) (s map[string]interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			s = map[string]interface{}{}
+		}
+	}()
+
+	b, err := json.Marshal(reflect.New(t).Interface())
+	if err != nil || len(b) == 0 {
+		return map[string]interface{}{}
+	}
+	switch b[0] {
+	case '"':
+		return map[string]interface{}{"type": "string"}
+	case '{':
+		return map[string]interface{}{"type": "object"}
+	case '[':
+		return map[string]interface{}{"type": "array"}
+	case 't', 'f':
+		return map[string]interface{}{"type": "boolean"}
+	case 'n':
+		return map[string]interface{}{}
+	default:
+		return map[string]interface{}{"type": "number"}
+	}
+}
+
+var pathParamRegexp = regexp.MustCompile(`\{([^}:]+)(?::([^}]*))?\}`)
+var enumRegexp = regexp.MustCompile(`^[\w-]+(\|[\w-]+)*$`)
+
+// openAPIPath converts a route pattern to an OpenAPI path and returns its
+// parameters.
+func openAPIPath(tpl string) (string, []interface{}) {
+	var params []interface{}
+	for _, m := range pathParamRegexp.FindAllStringSubmatch(tpl, -1) {
+		schema := map[string]interface{}{"type": "string"}
+		if m[2] != "" && enumRegexp.MatchString(m[2]) {
+			schema["enum"] = strings.Split(m[2], "|")
+		}
+		params = append(params, map[string]interface{}{
+			"name":     m[1],
+			"in":       "path",
+			"required": true,
+			"schema":   schema,
+		})
+	}
+	return pathParamRegexp.ReplaceAllString(tpl, "{$1}"), params
+}
+
+func bodyContent(g *schemaGenerator, contentType string, v interface{}) map[string]interface{} {
+	if contentType != "" {
+		return map[string]interface{}{
+			contentType: map[string]interface{}{
+				"schema": map[string]interface{}{"type": "string", "format": "binary"},
+			},
+		}
+	}
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{
+			"schema": g.schema(reflect.TypeOf(v)),
+		},
+	}
+}
+
+// OpenAPISpec returns the OpenAPI document describing the routes of this
+// API with the given RouteSpecs. Routes without a RouteSpec are included
+// with their name only.
+func (api *API) OpenAPISpec(title, version string, specs map[string]RouteSpec) (map[string]interface{}, error) {
+	g := &schemaGenerator{schemas: make(map[string]interface{})}
+	paths := make(map[string]map[string]interface{})
+
+	err := api.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		name := route.GetName()
+		spec := specs[name]
+
+		path, params := openAPIPath(tpl)
+		for _, q := range spec.Query {
+			typ := q.Type
+			if typ == "" {
+				typ = "string"
+			}
+			params = append(params, map[string]interface{}{
+				"name":        q.Name,
+				"in":          "query",
+				"description": q.Description,
+				"schema":      map[string]interface{}{"type": typ},
+			})
+		}
+
+		op := map[string]interface{}{
+			"operationId": name,
+			"summary":     spec.Summary,
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+		if spec.Request != nil || spec.RequestContentType != "" {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  bodyContent(g, spec.RequestContentType, spec.Request),
+			}
+		}
+
+		responses := map[string]interface{}{
+			"default": map[string]interface{}{
+				"description": "Error",
+				"content":     bodyContent(g, "", types.Error{}),
+			},
+		}
+		if spec.Response != nil || spec.ResponseContentType != "" {
+			responses["200"] = map[string]interface{}{
+				"description": "OK",
+				"content":     bodyContent(g, spec.ResponseContentType, spec.Response),
+			}
+		} else {
+			responses["204"] = map[string]interface{}{"description": "No Content"}
+		}
+		op["responses"] = responses
+
+		if paths[path] == nil {
+			paths[path] = make(map[string]interface{})
+		}
+		for _, m := range methods {
+			paths[path][strings.ToLower(m)] = op
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	doc := map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info": map[string]interface{}{
+			"title":   title,
+			"version": version,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": g.schemas,
+		},
+	}
+	if api.config.BasicAuthCredentials != nil {
+		doc["components"].(map[string]interface{})["securitySchemes"] = map[string]interface{}{
+			"basicAuth": map[string]interface{}{
+				"type":   "http",
+				"scheme": "basic",
+			},
+		}
+		doc["security"] = []interface{}{
+			map[string]interface{}{"basicAuth": []string{}},
+		}
+	}
+	return doc, nil
+}
+
+// SendOpenAPISpec writes the OpenAPI document of this API.
+func (api *API) SendOpenAPISpec(w http.ResponseWriter, title, version string, specs map[string]RouteSpec) {
+	doc, err := api.OpenAPISpec(title, version, specs)
+	if err != nil {
+		err = fmt.Errorf("error generating the API spec: %w", err)
+	}
+	api.SendResponse(w, SetStatusAutomatically, err, doc)
+}
@@ -0,0 +1,201 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	types "github.com/ipfs/ipfs-cluster/api"
+)
+
+// PolicyConfig maps API users to roles which determine the routes that they
+// can use. When no policies are configured, every authenticated user can use
+// every route.
+type PolicyConfig struct {
+	// Roles defines the available roles by name.
+	Roles map[string]*Role `json:"roles"`
+	// UserRoles assigns roles to basic-auth users. It requires basic
+	// auth to be enabled.
+	UserRoles map[string][]string `json:"user_roles"`
+	// DefaultRoles are used for users without an entry in UserRoles
+	// (including unauthenticated requests, when basic auth is disabled).
+	DefaultRoles []string `json:"default_roles,omitempty"`
+}
+
+// Role defines the routes allowed to the users with it. Routes are
+// identified by their name (i.e. "Pin", "PeerRemove", "StatusAll"), and "*"
+// matches all of them.
+type Role struct {
+	// Allow lists the routes that can be used.
+	Allow []string `json:"allow"`
+	// Deny lists the routes that cannot be used, even if they are
+	// allowed.
+	Deny []string `json:"deny,omitempty"`
+	// Constraints restricts the requests to the given routes.
+	Constraints map[string]*RouteConstraints `json:"constraints,omitempty"`
+}
+
+// RouteConstraints restricts the parameters of the requests to a route.
+type RouteConstraints struct {
+	// MaxReplication, when set, is the highest replication factor that
+	// requests can set. Requests that do not set replication-max get it
+	// set to this value.
+	MaxReplication int `json:"max_replication,omitempty"`
+	// ForbiddenParams lists query parameters that requests cannot set.
+	// Parameters with empty, "0" or "false" values are not considered
+	// set, as clients may send them with their default values.
+	ForbiddenParams []string `json:"forbidden_params,omitempty"`
+}
+
+// IsEmpty returns true when no policies are configured.
+func (pc *PolicyConfig) IsEmpty() bool {
+	return pc == nil || len(pc.Roles) == 0
+}
+
+func (pc *PolicyConfig) validate() error {
+	if pc.IsEmpty() {
+		return nil
+	}
+
+	check := func(roles []string) error {
+		for _, r := range roles {
+			if _, ok := pc.Roles[r]; !ok {
+				return fmt.Errorf("unknown role %q", r)
+			}
+		}
+		return nil
+	}
+	for user, roles := range pc.UserRoles {
+		if err := check(roles); err != nil {
+			return fmt.Errorf("user_roles.%s: %w", user, err)
+		}
+	}
+	if err := check(pc.DefaultRoles); err != nil {
+		return fmt.Errorf("default_roles: %w", err)
+	}
+
+	for name, role := range pc.Roles {
+		for route, rc := range role.Constraints {
+			if rc.MaxReplication < 0 {
+				return fmt.Errorf("roles.%s.constraints.%s.max_replication is invalid", name, route)
+			}
+		}
+	}
+	return nil
+}
+
+func (role *Role) allows(route string) bool {
+	for _, d := range role.Deny {
+		if d == "*" || d == route {
+			return false
+		}
+	}
+	for _, a := range role.Allow {
+		if a == "*" || a == route {
+			return true
+		}
+	}
+	return false
+}
+
+// apply checks the request against the constraints for the route and
+// returns its query with the default values that they require. The request
+// is not modified.
+func (role *Role) apply(route string, r *http.Request) (string, error) {
+	rc, ok := role.Constraints[route]
+	if !ok {
+		rc, ok = role.Constraints["*"]
+	}
+	if !ok {
+		return r.URL.RawQuery, nil
+	}
+
+	q := r.URL.Query() // a copy
+	for _, p := range rc.ForbiddenParams {
+		if isSetParam(q.Get(p)) {
+			return "", fmt.Errorf("the %s parameter cannot be set", p)
+		}
+	}
+
+	if rc.MaxReplication > 0 {
+		if rpl := q.Get("replication"); rpl != "" {
+			q.Set("replication-min", rpl)
+			q.Set("replication-max", rpl)
+			q.Del("replication")
+		}
+		for _, k := range []string{"replication-min", "replication-max"} {
+			v := q.Get(k)
+			if v == "" {
+				continue
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return "", fmt.Errorf("invalid %s: %w", k, err)
+			}
+			if n < 0 || n > rc.MaxReplication {
+				return "", fmt.Errorf("%s cannot be larger than %d", k, rc.MaxReplication)
+			}
+		}
+		if v := q.Get("replication-max"); v == "" || v == "0" {
+			q.Set("replication-max", strconv.Itoa(rc.MaxReplication))
+		}
+	}
+
+	return q.Encode(), nil
+}
+
+// isSetParam returns false for the values which leave a parameter with its
+// default.
+func isSetParam(v string) bool {
+	switch v {
+	case "", "0", "false":
+		return false
+	default:
+		return true
+	}
+}
+
+// authorize returns an error when none of the roles of the user allow the
+// request to the given route. Otherwise, it sets the query required by the
+// first role that allows it. The roles are those of the user authenticated
+// by basic auth, never those of a user named in a request which was not
+// authenticated.
+func (pc *PolicyConfig) authorize(route string, r *http.Request) error {
+	user := types.RequestUserFromContext(r.Context())
+	roles, ok := pc.UserRoles[user]
+	if !ok {
+		roles = pc.DefaultRoles
+	}
+
+	err := errors.New("access to this route is not allowed")
+	for _, name := range roles {
+		role := pc.Roles[name]
+		if !role.allows(route) {
+			continue
+		}
+		var query string
+		if query, err = role.apply(route, r); err == nil {
+			r.URL.RawQuery = query
+			return nil
+		}
+	}
+	return err
+}
+
+// policyHandler wraps the handler for a route so that requests are checked
+// against the configured policies.
+func (api *API) policyHandler(route string, h http.Handler) http.Handler {
+	policies := api.config.Policies
+	if policies.IsEmpty() {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := policies.authorize(route, r); err != nil {
+			api.SendResponse(w, http.StatusForbidden, err, nil)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
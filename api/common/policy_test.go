@@ -0,0 +1,197 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+)
+
+func testPolicies() *PolicyConfig {
+	return &PolicyConfig{
+		Roles: map[string]*Role{
+			"admin": {
+				Allow: []string{"*"},
+			},
+			"operator": {
+				Allow: []string{"*"},
+				Deny:  []string{"PeerRemove"},
+				Constraints: map[string]*RouteConstraints{
+					"Pin": {
+						MaxReplication:  3,
+						ForbiddenParams: []string{"user-allocations"},
+					},
+				},
+			},
+			"reader": {
+				Allow: []string{"StatusAll", "Allocations"},
+			},
+		},
+		UserRoles: map[string][]string{
+			adminUserName: {"admin"},
+			validUserName: {"operator"},
+		},
+		DefaultRoles: []string{"reader"},
+	}
+}
+
+func TestPolicyAuthorize(t *testing.T) {
+	pc := testPolicies()
+	if err := pc.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	newReq := func(query, user string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/pins/abc?"+query, nil)
+		return r.WithContext(api.ContextWithRequestUser(r.Context(), user))
+	}
+
+	testcases := []struct {
+		user  string
+		route string
+		query string
+		ok    bool
+	}{
+		{adminUserName, "PeerRemove", "", true},
+		{adminUserName, "Pin", "replication=10", true},
+		{validUserName, "PeerRemove", "", false},
+		{validUserName, "PeerAdd", "", true},
+		{validUserName, "Pin", "replication=3", true},
+		{validUserName, "Pin", "replication=4", false},
+		{validUserName, "Pin", "replication-min=1&replication-max=-1", false},
+		{validUserName, "Pin", "user-allocations=abc", false},
+		{validUserName, "Pin", "user-allocations=", true},
+		{validUserName, "Unpin", "replication=10", true},
+		{"", "StatusAll", "", true},
+		{"", "Pin", "", false},
+		{invalidUserName, "Allocations", "", true},
+	}
+
+	for _, tc := range testcases {
+		err := pc.authorize(tc.route, newReq(tc.query, tc.user))
+		if tc.ok && err != nil {
+			t.Errorf("%s should be allowed %s?%s: %s", tc.user, tc.route, tc.query, err)
+		}
+		if !tc.ok && err == nil {
+			t.Errorf("%s should not be allowed %s?%s", tc.user, tc.route, tc.query)
+		}
+	}
+
+	// The maximum replication is set when not given.
+	r := newReq("replication-min=1&replication-max=0", validUserName)
+	if err := pc.authorize("Pin", r); err != nil {
+		t.Fatal(err)
+	}
+	if q := r.URL.Query(); q.Get("replication-max") != "3" || q.Get("replication-min") != "1" {
+		t.Error("expected replication-max to be set:", q)
+	}
+
+	// Rejected requests are not modified.
+	r = newReq("replication=2&user-allocations=abc", validUserName)
+	if err := pc.authorize("Pin", r); err == nil {
+		t.Fatal("expected an error")
+	}
+	if r.URL.RawQuery != "replication=2&user-allocations=abc" {
+		t.Error("the query should not have been modified:", r.URL.RawQuery)
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	pc := testPolicies()
+	pc.UserRoles["abc"] = []string{"superuser"}
+	if err := pc.validate(); err == nil {
+		t.Error("expected an error for an unknown role")
+	}
+
+	pc = testPolicies()
+	pc.DefaultRoles = []string{"superuser"}
+	if err := pc.validate(); err == nil {
+		t.Error("expected an error for an unknown default role")
+	}
+
+	pc = testPolicies()
+	pc.Roles["operator"].Constraints["Pin"].MaxReplication = -1
+	if err := pc.validate(); err == nil {
+		t.Error("expected an error for a negative max_replication")
+	}
+
+	cfg := newDefaultTestConfig(t)
+	cfg.Policies = testPolicies()
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected an error for user_roles without basic auth")
+	}
+	cfg.Policies.UserRoles = nil
+	if err := cfg.Validate(); err != nil {
+		t.Error("default_roles should be usable without basic auth:", err)
+	}
+}
+
+func TestPolicyHandler(t *testing.T) {
+	cfg := newDefaultTestConfig(t)
+	cfg.BasicAuthCredentials = map[string]string{
+		validUserName: validUserPassword,
+		adminUserName: adminUserPassword,
+	}
+	cfg.Policies = &PolicyConfig{
+		Roles: map[string]*Role{
+			"admin": {Allow: []string{"*"}},
+			"none":  {Deny: []string{"*"}},
+		},
+		UserRoles: map[string][]string{
+			adminUserName: {"admin"},
+			validUserName: {"none"},
+		},
+	}
+	a := &API{config: cfg}
+	h := basicAuthHandler(
+		cfg.BasicAuthCredentials,
+		a.policyHandler("Test", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})),
+		cfg.Logger,
+	)
+
+	r := httptest.NewRequest(http.MethodGet, "/test", nil)
+	r.SetBasicAuth(validUserName, validUserPassword)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Error("expected 403:", w.Code)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/test", nil)
+	r.SetBasicAuth(adminUserName, adminUserPassword)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Error("expected 200:", w.Code)
+	}
+}
+
+func TestPolicyHandlerForgedUser(t *testing.T) {
+	// Without basic auth, a user named in the Authorization header is not
+	// authenticated and only gets the default roles. Such a configuration
+	// does not validate, but the handler must not trust the header anyway.
+	cfg := newDefaultTestConfig(t)
+	cfg.Policies = &PolicyConfig{
+		Roles: map[string]*Role{
+			"admin":  {Allow: []string{"*"}},
+			"reader": {Allow: []string{"StatusAll"}},
+		},
+		UserRoles:    map[string][]string{adminUserName: {"admin"}},
+		DefaultRoles: []string{"reader"},
+	}
+	a := &API{config: cfg}
+	h := basicAuthHandler(
+		cfg.BasicAuthCredentials,
+		a.policyHandler("PeerRemove", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})),
+		cfg.Logger,
+	)
+
+	r := httptest.NewRequest(http.MethodDelete, "/peers/abc", nil)
+	r.SetBasicAuth(adminUserName, "x")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Error("a forged user should not get its roles:", w.Code)
+	}
+}
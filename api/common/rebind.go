@@ -0,0 +1,206 @@
+package common
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	types "github.com/ipfs/ipfs-cluster/api"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/rs/cors"
+	"go.opencensus.io/trace"
+)
+
+// corsHandler applies CORS options which can be replaced while the API
+// runs.
+type corsHandler struct {
+	next http.Handler
+
+	mu sync.RWMutex
+	h  http.Handler
+}
+
+func newCORSHandler(opts *cors.Options, next http.Handler) *corsHandler {
+	ch := &corsHandler{next: next}
+	ch.set(opts)
+	return ch
+}
+
+func (ch *corsHandler) set(opts *cors.Options) {
+	h := cors.New(*opts).Handler(ch.next)
+	ch.mu.Lock()
+	ch.h = h
+	ch.mu.Unlock()
+}
+
+func (ch *corsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ch.mu.RLock()
+	h := ch.h
+	ch.mu.RUnlock()
+	h.ServeHTTP(w, r)
+}
+
+// Listeners returns the settings of the HTTP listeners of the API.
+func (api *API) Listeners() *types.APIListeners {
+	api.shutdownLock.Lock()
+	defer api.shutdownLock.Unlock()
+	return api.config.Listeners()
+}
+
+// tlsListener serves TLS with a certificate which can be replaced while it
+// runs, or plain HTTP when it has none. Established connections are not
+// affected by the changes.
+type tlsListener struct {
+	net.Listener
+	config *tls.Config
+	cert   atomic.Value // *tls.Certificate
+}
+
+func newTLSListener(l net.Listener, cfg *tls.Config) *tlsListener {
+	tl := &tlsListener{Listener: l}
+	tl.config = tlsServerConfig()
+	tl.config.GetCertificate = tl.getCertificate
+	tl.setTLS(cfg)
+	return tl
+}
+
+// setTLS replaces the certificate of the listener with the one in the
+// given configuration. With a nil configuration, it serves plain HTTP.
+func (tl *tlsListener) setTLS(cfg *tls.Config) {
+	var cert *tls.Certificate
+	if cfg != nil && len(cfg.Certificates) > 0 {
+		cert = &cfg.Certificates[0]
+	}
+	tl.cert.Store(cert)
+}
+
+func (tl *tlsListener) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert, _ := tl.cert.Load().(*tls.Certificate)
+	if cert == nil {
+		return nil, errors.New("no TLS certificate")
+	}
+	return cert, nil
+}
+
+// Accept waits for a connection and wraps it with TLS when the listener
+// has a certificate.
+func (tl *tlsListener) Accept() (net.Conn, error) {
+	conn, err := tl.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if cert, _ := tl.cert.Load().(*tls.Certificate); cert == nil {
+		return conn, nil
+	}
+	return tls.Server(conn, tl.config), nil
+}
+
+// Rebind replaces the HTTP listen addresses, the TLS certificate and the
+// CORS options of the running API. Listeners on new addresses are opened
+// before the ones on removed addresses are closed. Listeners on kept
+// addresses stay open: their certificates are loaded again from the
+// configured files, even when the paths do not change, and swapped without
+// interrupting them. Listeners from systemd sockets are never touched.
+//
+// The new settings are saved to the configuration.
+func (api *API) Rebind(ctx context.Context, settings *types.APIListeners) error {
+	_, span := trace.StartSpan(ctx, "api/Rebind")
+	defer span.End()
+
+	if settings.API != api.config.ConfigKey {
+		return fmt.Errorf("listener settings for %q given to %q", settings.API, api.config.ConfigKey)
+	}
+	newCfg, err := api.config.listenersConfig(settings)
+	if err != nil {
+		return err
+	}
+
+	api.shutdownLock.Lock()
+	defer api.shutdownLock.Unlock()
+	if api.shutdown {
+		return errors.New("the API is shut down")
+	}
+
+	bound := make(map[string]*tlsListener, len(newCfg.HTTPListenAddr))
+	var opened []*tlsListener
+	for _, addr := range newCfg.HTTPListenAddr {
+		key := addr.String()
+		if _, ok := bound[key]; ok {
+			continue
+		}
+		if old, ok := api.boundListeners[key]; ok {
+			bound[key] = old
+			continue
+		}
+		l, err := api.listen(newCfg, addr)
+		if err != nil {
+			for _, l := range opened {
+				l.Close()
+			}
+			return err
+		}
+		opened = append(opened, l)
+		bound[key] = l
+	}
+
+	for _, addr := range newCfg.HTTPListenAddr {
+		bound[addr.String()].setTLS(newCfg.ListenerTLSConfig(addr))
+	}
+	for key, l := range api.boundListeners {
+		if _, ok := bound[key]; !ok {
+			l.Close()
+		}
+	}
+	for _, l := range opened {
+		api.startServing(l)
+	}
+	api.setBoundListeners(bound, newCfg.HTTPListenAddr)
+
+	api.config.HTTPListenAddr = newCfg.HTTPListenAddr
+	api.config.HTTPListenTLS = newCfg.HTTPListenTLS
+	api.config.TLS = newCfg.TLS
+	api.config.PathSSLCertFile = newCfg.PathSSLCertFile
+	api.config.PathSSLKeyFile = newCfg.PathSSLKeyFile
+	api.config.CORSAllowedOrigins = newCfg.CORSAllowedOrigins
+	api.config.CORSAllowedMethods = newCfg.CORSAllowedMethods
+	api.config.CORSAllowedHeaders = newCfg.CORSAllowedHeaders
+	api.config.CORSExposedHeaders = newCfg.CORSExposedHeaders
+	api.config.CORSAllowCredentials = newCfg.CORSAllowCredentials
+	api.config.CORSMaxAge = newCfg.CORSMaxAge
+	api.cors.set(api.config.CorsOptions())
+	api.config.NotifySave()
+	return nil
+}
+
+// setBoundListeners replaces the listeners opened for the configured
+// addresses, which are kept after the systemd ones in the order of the
+// given addresses.
+func (api *API) setBoundListeners(bound map[string]*tlsListener, order []ma.Multiaddr) {
+	listeners := append([]net.Listener{}, api.systemdListeners...)
+	seen := make(map[string]struct{}, len(order))
+	for _, addr := range order {
+		key := addr.String()
+		l, ok := bound[key]
+		if _, dup := seen[key]; dup || !ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		listeners = append(listeners, l)
+	}
+	api.boundListeners = bound
+	api.httpListeners = listeners
+}
+
+func (api *API) startServing(l net.Listener) {
+	api.wg.Add(1)
+	go func() {
+		defer api.wg.Done()
+		api.serveHTTP(l)
+	}()
+}
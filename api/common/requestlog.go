@@ -0,0 +1,287 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	handlers "github.com/gorilla/handlers"
+	mux "github.com/gorilla/mux"
+)
+
+// Request log formats.
+const (
+	// RequestLogCommon is the Apache Common Log Format.
+	RequestLogCommon = "common"
+	// RequestLogJSON logs one JSON object per request.
+	RequestLogJSON = "json"
+)
+
+// Fields that can be included in JSON request logs.
+const (
+	RequestLogFieldTime      = "time"
+	RequestLogFieldRemote    = "remote"
+	RequestLogFieldUser      = "user"
+	RequestLogFieldMethod    = "method"
+	RequestLogFieldPath      = "path"
+	RequestLogFieldRoute     = "route"
+	RequestLogFieldStatus    = "status"
+	RequestLogFieldSize      = "size"
+	RequestLogFieldLatency   = "latency"
+	RequestLogFieldRequestID = "request_id"
+	RequestLogFieldUserAgent = "user_agent"
+)
+
+// DefaultRequestLogFields are the fields included in JSON request logs when
+// none are configured.
+var DefaultRequestLogFields = []string{
+	RequestLogFieldTime,
+	RequestLogFieldRemote,
+	RequestLogFieldUser,
+	RequestLogFieldMethod,
+	RequestLogFieldPath,
+	RequestLogFieldRoute,
+	RequestLogFieldStatus,
+	RequestLogFieldSize,
+	RequestLogFieldLatency,
+	RequestLogFieldRequestID,
+	RequestLogFieldUserAgent,
+}
+
+// RequestIDHeader is the header from which the request ID is logged.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestLogConfig configures how API requests are logged.
+type RequestLogConfig struct {
+	// Format is either "common" (default) or "json".
+	Format string `json:"format"`
+	// Fields lists the fields in JSON logs (see DefaultRequestLogFields).
+	// The latency is logged in milliseconds as "latency_ms".
+	Fields []string `json:"fields,omitempty"`
+	// Sampling maps route names (i.e. "StatusAll", "List") to N, so that
+	// only one in every N successful requests to that route is logged.
+	// Failed requests are always logged.
+	Sampling map[string]int `json:"sampling,omitempty"`
+}
+
+func (rlc *RequestLogConfig) validate() error {
+	if rlc == nil {
+		return nil
+	}
+
+	switch rlc.Format {
+	case "", RequestLogCommon, RequestLogJSON:
+	default:
+		return fmt.Errorf("unknown format %q", rlc.Format)
+	}
+
+	for _, f := range rlc.Fields {
+		if !isRequestLogField(f) {
+			return fmt.Errorf("unknown field %q", f)
+		}
+	}
+
+	for route, n := range rlc.Sampling {
+		if n < 1 {
+			return fmt.Errorf("sampling.%s must be at least 1", route)
+		}
+	}
+	return nil
+}
+
+func isRequestLogField(f string) bool {
+	for _, known := range DefaultRequestLogFields {
+		if f == known {
+			return true
+		}
+	}
+	return false
+}
+
+// requestLogger writes a log entry for every request, in the configured
+// format, skipping those which are sampled out.
+type requestLogger struct {
+	config *RequestLogConfig
+	router *mux.Router
+	fields []string
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// requestLogHandler wraps a handler so that requests are logged to the given
+// writer. Routes are resolved with the given router for sampling and for the
+// "route" field.
+func requestLogHandler(w io.Writer, rlc *RequestLogConfig, router *mux.Router, h http.Handler) http.Handler {
+	if rlc == nil {
+		return handlers.LoggingHandler(w, h)
+	}
+
+	fields := rlc.Fields
+	if len(fields) == 0 {
+		fields = DefaultRequestLogFields
+	}
+	rl := &requestLogger{
+		config: rlc,
+		router: router,
+		fields: fields,
+		counts: make(map[string]int),
+	}
+	logged := handlers.CustomLoggingHandler(w, h, rl.write)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logged.ServeHTTP(w, r.WithContext(withLoggedUser(r.Context())))
+	})
+}
+
+// The request logger wraps the basic auth handler and only sees requests
+// as they come in. The user authenticated by the basic auth handler is
+// passed back to it in a slot set in the request context.
+type loggedUserKey struct{}
+
+type loggedUser struct {
+	name string
+}
+
+func withLoggedUser(ctx context.Context) context.Context {
+	return context.WithValue(ctx, loggedUserKey{}, &loggedUser{})
+}
+
+// setLoggedUser records the authenticated user of the request for the
+// request log.
+func setLoggedUser(ctx context.Context, user string) {
+	if lu, ok := ctx.Value(loggedUserKey{}).(*loggedUser); ok {
+		lu.name = user
+	}
+}
+
+// authenticatedUser returns the user authenticated for the given request,
+// or an empty string.
+func authenticatedUser(r *http.Request) string {
+	if lu, ok := r.Context().Value(loggedUserKey{}).(*loggedUser); ok {
+		return lu.name
+	}
+	return ""
+}
+
+func (rl *requestLogger) route(r *http.Request) string {
+	var match mux.RouteMatch
+	if rl.router.Match(r, &match) && match.Route != nil {
+		return match.Route.GetName()
+	}
+	return ""
+}
+
+// sampled returns true when a successful request to the given route should
+// be logged.
+func (rl *requestLogger) sampled(route string) bool {
+	n, ok := rl.config.Sampling[route]
+	if !ok || n <= 1 {
+		return true
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	count := rl.counts[route]
+	rl.counts[route] = (count + 1) % n
+	return count == 0
+}
+
+func (rl *requestLogger) write(w io.Writer, params handlers.LogFormatterParams) {
+	route := rl.route(params.Request)
+	if params.StatusCode < 400 && !rl.sampled(route) {
+		return
+	}
+
+	var line []byte
+	if rl.config.Format == RequestLogJSON {
+		line = rl.jsonLine(params, route)
+	} else {
+		line = commonLogLine(params)
+	}
+	w.Write(append(line, '\n'))
+}
+
+func (rl *requestLogger) jsonLine(params handlers.LogFormatterParams, route string) []byte {
+	r := params.Request
+	entry := make(map[string]interface{}, len(rl.fields))
+	for _, f := range rl.fields {
+		switch f {
+		case RequestLogFieldTime:
+			entry[f] = params.TimeStamp.UTC().Format(time.RFC3339Nano)
+		case RequestLogFieldRemote:
+			entry[f] = remoteHost(r)
+		case RequestLogFieldUser:
+			if user := authenticatedUser(r); user != "" {
+				entry[f] = user
+			}
+		case RequestLogFieldMethod:
+			entry[f] = r.Method
+		case RequestLogFieldPath:
+			entry[f] = params.URL.RequestURI()
+		case RequestLogFieldRoute:
+			if route != "" {
+				entry[f] = route
+			}
+		case RequestLogFieldStatus:
+			entry[f] = params.StatusCode
+		case RequestLogFieldSize:
+			entry[f] = params.Size
+		case RequestLogFieldLatency:
+			entry["latency_ms"] = float64(time.Since(params.TimeStamp).Microseconds()) / 1000
+		case RequestLogFieldRequestID:
+			if id := r.Header.Get(RequestIDHeader); id != "" {
+				entry[f] = id
+			}
+		case RequestLogFieldUserAgent:
+			entry[f] = r.UserAgent()
+		}
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return []byte(fmt.Sprintf(`{"error": %q}`, err))
+	}
+	return line
+}
+
+// commonLogLine formats a request in the Apache Common Log Format. Unlike
+// the default request logger, it logs the authenticated basic-auth user.
+func commonLogLine(params handlers.LogFormatterParams) []byte {
+	r := params.Request
+	user := "-"
+	if u := authenticatedUser(r); u != "" {
+		user = u
+	}
+
+	uri := r.RequestURI
+	if uri == "" {
+		uri = params.URL.RequestURI()
+	}
+	quoted := strconv.Quote(uri)
+
+	line := fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %d",
+		remoteHost(r),
+		user,
+		params.TimeStamp.Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method,
+		quoted[1:len(quoted)-1],
+		r.Proto,
+		params.StatusCode,
+		params.Size,
+	)
+	return []byte(line)
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
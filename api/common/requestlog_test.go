@@ -0,0 +1,137 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	mux "github.com/gorilla/mux"
+)
+
+func testRequestLogRouter() *mux.Router {
+	router := mux.NewRouter()
+	router.Methods("GET").Path("/pins").Name("StatusAll").HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("[]"))
+		},
+	)
+	router.Methods("GET").Path("/fail").Name("Fail").HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		},
+	)
+	return router
+}
+
+func TestRequestLogJSON(t *testing.T) {
+	router := testRequestLogRouter()
+	buf := new(bytes.Buffer)
+	rlc := &RequestLogConfig{
+		Format: RequestLogJSON,
+		Fields: []string{
+			RequestLogFieldUser,
+			RequestLogFieldRoute,
+			RequestLogFieldStatus,
+			RequestLogFieldLatency,
+			RequestLogFieldRequestID,
+		},
+	}
+	if err := rlc.validate(); err != nil {
+		t.Fatal(err)
+	}
+	logger := newDefaultTestConfig(t).Logger
+	creds := map[string]string{validUserName: validUserPassword}
+	h := requestLogHandler(buf, rlc, router, basicAuthHandler(creds, router, logger))
+
+	r := httptest.NewRequest("GET", "/pins", nil)
+	r.SetBasicAuth(validUserName, validUserPassword)
+	r.Header.Set(RequestIDHeader, "abc")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if len(entry) != 5 {
+		t.Errorf("unexpected fields: %v", entry)
+	}
+	if entry["user"] != validUserName ||
+		entry["route"] != "StatusAll" ||
+		entry["status"] != float64(200) ||
+		entry["request_id"] != "abc" {
+		t.Errorf("unexpected entry: %v", entry)
+	}
+	if _, ok := entry["latency_ms"]; !ok {
+		t.Error("expected latency_ms")
+	}
+	if strings.Contains(buf.String(), validUserPassword) {
+		t.Error("the password should not be logged")
+	}
+
+	// Without basic auth, the user given by the client is not logged.
+	buf.Reset()
+	h = requestLogHandler(buf, rlc, router, basicAuthHandler(nil, router, logger))
+	r = httptest.NewRequest("GET", "/pins", nil)
+	r.SetBasicAuth(adminUserName, "x")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	entry = nil
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := entry["user"]; ok {
+		t.Errorf("an unauthenticated user should not be logged: %v", entry)
+	}
+}
+
+func TestRequestLogSampling(t *testing.T) {
+	router := testRequestLogRouter()
+	buf := new(bytes.Buffer)
+	rlc := &RequestLogConfig{
+		Sampling: map[string]int{
+			"StatusAll": 5,
+			"Fail":      5,
+		},
+	}
+	h := requestLogHandler(buf, rlc, router, router)
+
+	for i := 0; i < 10; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/pins", nil))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/fail", nil))
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	pins := 0
+	fails := 0
+	for _, l := range lines {
+		switch {
+		case strings.Contains(l, `"GET /pins HTTP/1.1" 200 2`):
+			pins++
+		case strings.Contains(l, `"GET /fail HTTP/1.1" 500 0`):
+			fails++
+		default:
+			t.Errorf("unexpected line: %s", l)
+		}
+	}
+	if pins != 2 {
+		t.Errorf("expected 2 sampled requests but got %d", pins)
+	}
+	if fails != 10 {
+		t.Errorf("failed requests should always be logged: got %d", fails)
+	}
+}
+
+func TestRequestLogConfigValidate(t *testing.T) {
+	invalid := []*RequestLogConfig{
+		{Format: "xml"},
+		{Format: RequestLogJSON, Fields: []string{"password"}},
+		{Sampling: map[string]int{"StatusAll": 0}},
+	}
+	for _, rlc := range invalid {
+		if err := rlc.validate(); err == nil {
+			t.Errorf("expected an error validating %+v", rlc)
+		}
+	}
+}
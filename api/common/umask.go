@@ -0,0 +1,26 @@
+//go:build !windows
+// +build !windows
+
+package common
+
+import (
+	"sync"
+	"syscall"
+)
+
+var umaskMu sync.Mutex
+
+// restrictUmask makes files created from now on inaccessible to group and
+// others, until the returned function is called to restore the previous
+// umask. The umask is process-wide: it only ever removes permissions
+// from files created concurrently, and calls are serialized so that the
+// original umask is always restored.
+func restrictUmask() func() {
+	umaskMu.Lock()
+	old := syscall.Umask(0077)
+	syscall.Umask(old | 0077)
+	return func() {
+		syscall.Umask(old)
+		umaskMu.Unlock()
+	}
+}
@@ -0,0 +1,6 @@
+package common
+
+// restrictUmask is not supported on Windows.
+func restrictUmask() func() {
+	return func() {}
+}
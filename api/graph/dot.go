@@ -1,10 +1,9 @@
-package main
+package graph
 
 import (
 	"errors"
 	"fmt"
 	"io"
-	"sort"
 
 	dot "github.com/kishansagathiya/go-dot"
 	peer "github.com/libp2p/go-libp2p-core/peer"
@@ -14,7 +13,7 @@ import (
 
 /*
    These functions are used to write an IPFS Cluster connectivity graph to a
-   graphviz-style dot file.  Input an api.ConnectGraphSerial object, makeDot
+   graphviz-style dot file.  Input an api.ConnectGraph object, WriteDot
    does some preprocessing and then passes all 3 link maps to a
    cluster-dotWriter which handles iterating over the link maps and writing
    dot file node and edge statements to make a dot-file graph.  Nodes are
@@ -40,35 +39,19 @@ const (
 
 var errUnknownNodeType = errors.New("unsupported node type. Expected cluster or ipfs")
 
-func makeDot(cg *api.ConnectGraph, w io.Writer, allIpfs bool) error {
-	ipfsEdges := make(map[string][]peer.ID)
-	for k, v := range cg.IPFSLinks {
-		ipfsEdges[k] = make([]peer.ID, 0)
-		for _, id := range v {
-			strPid := peer.Encode(id)
-
-			if _, ok := cg.IPFSLinks[strPid]; ok || allIpfs {
-				ipfsEdges[k] = append(ipfsEdges[k], id)
-			}
-			if allIpfs { // include all swarm peers in the graph
-				if _, ok := ipfsEdges[strPid]; !ok {
-					// if id in IPFSLinks this will be overwritten
-					// if id not in IPFSLinks this will stay blank
-					ipfsEdges[strPid] = make([]peer.ID, 0)
-				}
-			}
-		}
-	}
-
+// WriteDot writes the given graph to w in graphviz dot format. When allIpfs
+// is set, IPFS swarm peers not attached to any cluster peer are included.
+func WriteDot(cg *api.ConnectGraph, w io.Writer, allIpfs bool) error {
 	dW := dotWriter{
 		w:                w,
 		dotGraph:         dot.NewGraph("cluster"),
 		self:             peer.Encode(cg.ClusterID),
 		trustMap:         cg.ClusterTrustLinks,
 		idToPeername:     cg.IDtoPeername,
-		ipfsEdges:        ipfsEdges,
+		ipfsEdges:        ipfsEdges(cg, allIpfs),
 		clusterEdges:     cg.ClusterLinks,
 		clusterIpfsEdges: cg.ClustertoIPFS,
+		connections:      connections(cg),
 		clusterNodes:     make(map[string]*dot.VertexDescription),
 		ipfsNodes:        make(map[string]*dot.VertexDescription),
 	}
@@ -88,6 +71,18 @@ type dotWriter struct {
 	ipfsEdges        map[string][]peer.ID
 	clusterEdges     map[string][]peer.ID
 	clusterIpfsEdges map[string]peer.ID
+	connections      map[string]map[string]*api.ConnectionInfo
+}
+
+// labeledEdge is a dot edge with a label, which go-dot does not support.
+type labeledEdge struct {
+	from, to string
+	label    string
+}
+
+func (e *labeledEdge) Write(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "%s -> %s [ label=%q ]", e.from, e.to, e.label)
+	return err
 }
 
 func (dW *dotWriter) addSubGraph(sGraph dot.Graph, rank string) {
@@ -144,10 +139,6 @@ func (dW *dotWriter) addNode(graph *dot.Graph, id string, nT nodeType) error {
 	return nil
 }
 
-func shorten(id string) string {
-	return id[:2] + "*" + id[len(id)-6:]
-}
-
 func label(peername, id string) string {
 	return fmt.Sprintf("< <B> %s </B> <BR/> <B> %s </B> >", peername, id)
 }
@@ -206,6 +197,18 @@ func (dW *dotWriter) print() error {
 		for _, id := range v {
 			toNode := dW.clusterNodes[k]
 			fromNode := dW.clusterNodes[peer.Encode(id)]
+			if toNode == nil || fromNode == nil {
+				logger.Error("expected a node at this id")
+				continue
+			}
+			if info, ok := dW.connections[k][peer.Encode(id)]; ok {
+				dW.dotGraph.Body = append(dW.dotGraph.Body, &labeledEdge{
+					from:  toNode.ID,
+					to:    fromNode.ID,
+					label: connectionLabel(info),
+				})
+				continue
+			}
 			dW.dotGraph.AddEdge(toNode, fromNode, true, "")
 		}
 	}
@@ -253,14 +256,3 @@ func (dW *dotWriter) print() error {
 	}
 	return dW.dotGraph.Write(dW.w)
 }
-
-func sortedKeys(dict map[string][]peer.ID) []string {
-	keys := make([]string, len(dict))
-	i := 0
-	for k := range dict {
-		keys[i] = k
-		i++
-	}
-	sort.Strings(keys)
-	return keys
-}
@@ -1,4 +1,4 @@
-package main
+package graph
 
 import (
 	"bytes"
@@ -117,7 +117,7 @@ func TestSimpleIpfsGraphs(t *testing.T) {
 		},
 	}
 	buf := new(bytes.Buffer)
-	err := makeDot(&cg, buf, false)
+	err := WriteDot(&cg, buf, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -225,7 +225,7 @@ func TestIpfsAllGraphs(t *testing.T) {
 	}
 
 	buf := new(bytes.Buffer)
-	err := makeDot(&cg, buf, true)
+	err := WriteDot(&cg, buf, true)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,152 @@
+// Package graph renders the connectivity graph of an IPFS Cluster
+// (api.ConnectGraph) in formats understood by graph visualization tools:
+// graphviz dot and Mermaid.
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	logging "github.com/ipfs/go-log/v2"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+var logger = logging.Logger("graph")
+
+// Supported graph formats.
+const (
+	FormatJSON    = "json"
+	FormatDot     = "dot"
+	FormatMermaid = "mermaid"
+)
+
+// ErrUnknownFormat is returned when asking for an unsupported format.
+var ErrUnknownFormat = errors.New("unknown graph format. Expected json, dot or mermaid")
+
+// ContentType returns the MIME type for the given format.
+func ContentType(format string) string {
+	switch format {
+	case FormatDot:
+		return "text/vnd.graphviz"
+	case FormatMermaid:
+		return "text/plain; charset=utf-8"
+	default:
+		return "application/json"
+	}
+}
+
+// Write renders the graph in the given format, which must be dot or
+// mermaid. When allIpfs is set, IPFS swarm peers not attached to any cluster
+// peer are included.
+func Write(cg *api.ConnectGraph, w io.Writer, format string, allIpfs bool) error {
+	switch format {
+	case FormatDot:
+		return WriteDot(cg, w, allIpfs)
+	case FormatMermaid:
+		return WriteMermaid(cg, w, allIpfs)
+	default:
+		return ErrUnknownFormat
+	}
+}
+
+// ipfsEdges returns the links among the IPFS daemons to render. Only links
+// among the daemons attached to cluster peers are included, unless allIpfs
+// is set.
+func ipfsEdges(cg *api.ConnectGraph, allIpfs bool) map[string][]peer.ID {
+	edges := make(map[string][]peer.ID)
+	for k, v := range cg.IPFSLinks {
+		edges[k] = make([]peer.ID, 0)
+		for _, id := range v {
+			strPid := peer.Encode(id)
+
+			if _, ok := cg.IPFSLinks[strPid]; ok || allIpfs {
+				edges[k] = append(edges[k], id)
+			}
+			if allIpfs { // include all swarm peers in the graph
+				if _, ok := edges[strPid]; !ok {
+					// if id in IPFSLinks this will be overwritten
+					// if id not in IPFSLinks this will stay blank
+					edges[strPid] = make([]peer.ID, 0)
+				}
+			}
+		}
+	}
+	return edges
+}
+
+// connections indexes the connection information in the graph by origin
+// and destination peer.
+func connections(cg *api.ConnectGraph) map[string]map[string]*api.ConnectionInfo {
+	conns := make(map[string]map[string]*api.ConnectionInfo, len(cg.ClusterConnections))
+	for from, infos := range cg.ClusterConnections {
+		conns[from] = make(map[string]*api.ConnectionInfo, len(infos))
+		for _, info := range infos {
+			conns[from][peer.Encode(info.Peer)] = info
+		}
+	}
+	return conns
+}
+
+// connectionLabel describes a connection, i.e. "quic outbound 12ms".
+func connectionLabel(info *api.ConnectionInfo) string {
+	var parts []string
+	if info.Transport != "" {
+		parts = append(parts, info.Transport)
+	}
+	if info.Direction != "" {
+		parts = append(parts, info.Direction)
+	}
+	if info.Latency > 0 {
+		parts = append(parts, info.Latency.Round(time.Millisecond/10).String())
+	}
+	return strings.Join(parts, " ")
+}
+
+func shorten(id string) string {
+	return id[:2] + "*" + id[len(id)-6:]
+}
+
+func sortedKeys(dict map[string][]peer.ID) []string {
+	keys := make([]string, len(dict))
+	i := 0
+	for k := range dict {
+		keys[i] = k
+		i++
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// nodeIDs assigns the node identifiers used in the rendered graphs to
+// cluster and IPFS peers: Cx for cluster peers, Tx for trusted ones and Ix
+// for IPFS daemons. IPFS daemons which could not be contacted get the
+// identifier of their cluster peer as key.
+func nodeIDs(cg *api.ConnectGraph, ipfs map[string][]peer.ID) (cluster map[string]string, ipfsIDs map[string]string) {
+	self := peer.Encode(cg.ClusterID)
+	cluster = make(map[string]string)
+	ipfsIDs = make(map[string]string)
+
+	clusterKeys := sortedKeys(cg.ClusterLinks)
+	for _, k := range clusterKeys {
+		prefix := "C"
+		if k != self && cg.ClusterTrustLinks[k] {
+			prefix = "T"
+		}
+		cluster[k] = fmt.Sprintf("%s%d", prefix, len(cluster))
+	}
+	for _, k := range sortedKeys(ipfs) {
+		ipfsIDs[k] = fmt.Sprintf("I%d", len(ipfsIDs))
+	}
+	for _, k := range clusterKeys {
+		if _, ok := cg.ClustertoIPFS[k]; !ok {
+			ipfsIDs[k] = fmt.Sprintf("I%d", len(ipfsIDs))
+		}
+	}
+	return cluster, ipfsIDs
+}
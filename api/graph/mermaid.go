@@ -0,0 +1,108 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+// WriteMermaid writes the given graph to w as a Mermaid flowchart, which can
+// be embedded in Markdown documents. Nodes use the same identifiers and
+// colors as in WriteDot. When allIpfs is set, IPFS swarm peers not attached
+// to any cluster peer are included.
+func WriteMermaid(cg *api.ConnectGraph, w io.Writer, allIpfs bool) error {
+	var b strings.Builder
+	ipfs := ipfsEdges(cg, allIpfs)
+	clusterIDs, ipfsIDs := nodeIDs(cg, ipfs)
+	conns := connections(cg)
+	self := peer.Encode(cg.ClusterID)
+	clusterKeys := sortedKeys(cg.ClusterLinks)
+	ipfsKeys := sortedKeys(ipfs)
+
+	b.WriteString("flowchart TB\n")
+	b.WriteString("  classDef self fill:orange,stroke:black,stroke-width:3px\n")
+	b.WriteString("  classDef trusted fill:orange\n")
+	b.WriteString("  classDef cluster fill:darkorange\n")
+	b.WriteString("  classDef ipfs fill:turquoise\n")
+	b.WriteString("  classDef missing fill:firebrick\n")
+
+	b.WriteString("  subgraph Cluster\n")
+	for _, k := range clusterKeys {
+		class := "cluster"
+		switch {
+		case k == self:
+			class = "self"
+		case cg.ClusterTrustLinks[k]:
+			class = "trusted"
+		}
+		fmt.Fprintf(&b, "    %s[\"%s<br/>%s\"]:::%s\n", clusterIDs[k], mermaidEscape(cg.IDtoPeername[k]), shorten(k), class)
+	}
+	b.WriteString("  end\n")
+
+	b.WriteString("  subgraph IPFS\n")
+	for _, k := range ipfsKeys {
+		fmt.Fprintf(&b, "    %s[(\"IPFS<br/>%s\")]:::ipfs\n", ipfsIDs[k], shorten(k))
+	}
+	for _, k := range clusterKeys {
+		if _, ok := cg.ClustertoIPFS[k]; !ok {
+			fmt.Fprintf(&b, "    %s[(\"IPFS<br/>Errored\")]:::missing\n", ipfsIDs[k])
+		}
+	}
+	b.WriteString("  end\n")
+
+	// cluster peer connections
+	for _, k := range clusterKeys {
+		for _, id := range cg.ClusterLinks[k] {
+			to, ok := clusterIDs[peer.Encode(id)]
+			if !ok {
+				logger.Error("expected a node at this id")
+				continue
+			}
+			if info, ok := conns[k][peer.Encode(id)]; ok {
+				fmt.Fprintf(&b, "  %s -->|%s| %s\n", clusterIDs[k], connectionLabel(info), to)
+				continue
+			}
+			fmt.Fprintf(&b, "  %s --> %s\n", clusterIDs[k], to)
+		}
+	}
+
+	// cluster peers to their ipfs daemons
+	for _, k := range clusterKeys {
+		ipfsID, ok := cg.ClustertoIPFS[k]
+		if !ok {
+			fmt.Fprintf(&b, "  %s -.-> %s\n", clusterIDs[k], ipfsIDs[k])
+			continue
+		}
+		to, ok := ipfsIDs[peer.Encode(ipfsID)]
+		if !ok {
+			logger.Error("expected a node at this id")
+			continue
+		}
+		fmt.Fprintf(&b, "  %s --> %s\n", clusterIDs[k], to)
+	}
+
+	// swarm connections among ipfs daemons
+	for _, k := range ipfsKeys {
+		for _, id := range ipfs[k] {
+			to, ok := ipfsIDs[peer.Encode(id)]
+			if !ok {
+				logger.Error("expected a node here")
+				continue
+			}
+			fmt.Fprintf(&b, "  %s --> %s\n", ipfsIDs[k], to)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var mermaidReplacer = strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;")
+
+func mermaidEscape(s string) string {
+	return mermaidReplacer.Replace(s)
+}
@@ -0,0 +1,100 @@
+package graph
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+func testGraph() *api.ConnectGraph {
+	return &api.ConnectGraph{
+		ClusterID: pid1,
+		IDtoPeername: map[string]string{
+			peer.Encode(pid1): "peer1",
+			peer.Encode(pid2): `"peer2"`,
+		},
+		ClusterLinks: map[string][]peer.ID{
+			peer.Encode(pid1): {pid2},
+			peer.Encode(pid2): {pid1},
+		},
+		ClusterTrustLinks: map[string]bool{
+			peer.Encode(pid2): true,
+		},
+		IPFSLinks: map[string][]peer.ID{
+			peer.Encode(pid4): {pid5},
+		},
+		ClustertoIPFS: map[string]peer.ID{
+			peer.Encode(pid1): pid4,
+		},
+		ClusterConnections: map[string][]*api.ConnectionInfo{
+			peer.Encode(pid1): {
+				{
+					Peer:      pid2,
+					Direction: api.ConnectionOutbound,
+					Transport: "quic",
+					Latency:   12 * time.Millisecond,
+				},
+			},
+		},
+	}
+}
+
+func TestWriteMermaid(t *testing.T) {
+	buf := new(bytes.Buffer)
+	err := WriteMermaid(testGraph(), buf, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	t.Log(out)
+
+	expected := []string{
+		"flowchart TB\n",
+		`    C0["peer1<br/>Qm*eqhEhD"]:::self`,
+		`    T1["#quot;peer2#quot;<br/>Qm*cgHDQJ"]:::trusted`,
+		`    I0[("IPFS<br/>Qm*R3DZDV")]:::ipfs`,
+		`    I1[("IPFS<br/>Errored")]:::missing`,
+		"  C0 -->|quic outbound 12ms| T1\n",
+		"  T1 --> C0\n",
+		"  C0 --> I0\n",
+		"  T1 -.-> I1\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(out, e) {
+			t.Errorf("expected %q in the output", e)
+		}
+	}
+	// pid5 is not attached to a cluster peer
+	if strings.Contains(out, "I0 -->") {
+		t.Error("swarm peers outside the cluster should not be included")
+	}
+
+	buf.Reset()
+	err = WriteMermaid(testGraph(), buf, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "I1 --> I0") {
+		t.Error("expected all swarm peers to be included")
+	}
+}
+
+func TestWriteDotConnections(t *testing.T) {
+	buf := new(bytes.Buffer)
+	err := Write(testGraph(), buf, FormatDot, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `C0 -> T1 [ label="quic outbound 12ms" ]`) {
+		t.Error("expected a labeled edge:", buf.String())
+	}
+
+	if err := Write(testGraph(), buf, "png", false); err != ErrUnknownFormat {
+		t.Error("expected ErrUnknownFormat")
+	}
+}
@@ -0,0 +1,58 @@
+package ipfsproxy
+
+import (
+	"fmt"
+	"net/http"
+	gopath "path"
+	"strings"
+)
+
+const apiPrefix = "/api/v0"
+
+// normalizeCommand returns a command in the "pin/add" form.
+func normalizeCommand(c string) string {
+	return strings.Trim(gopath.Clean("/"+c), "/")
+}
+
+// requestCommand returns the IPFS API command of a request, or false when
+// the path is outside /api/v0.
+func requestCommand(r *http.Request) (string, bool) {
+	p := gopath.Clean(r.URL.Path)
+	if p != apiPrefix && !strings.HasPrefix(p, apiPrefix+"/") {
+		return "", false
+	}
+	return normalizeCommand(strings.TrimPrefix(p, apiPrefix)), true
+}
+
+// matchCommand returns true when cmd is one of the commands or one of
+// their subcommands.
+func matchCommand(cmd string, commands []string) bool {
+	for _, c := range commands {
+		c = normalizeCommand(c)
+		if cmd == c || strings.HasPrefix(cmd, c+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// filterCommands rejects the requests for commands which are not allowed
+// with a 403 error.
+func filterCommands(next http.Handler, allowed, denied []string) http.Handler {
+	if len(allowed) == 0 && len(denied) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cmd, isCmd := requestCommand(r)
+		switch {
+		case len(allowed) > 0 && (!isCmd || !matchCommand(cmd, allowed)):
+		case isCmd && matchCommand(cmd, denied):
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+		logger.Warnf("rejecting proxied request for %s: command not allowed", r.URL.Path)
+		ipfsErrorResponder(w, fmt.Sprintf("%s is not allowed by the cluster proxy", r.URL.Path), http.StatusForbidden)
+	})
+}
@@ -0,0 +1,57 @@
+package ipfsproxy
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMatchCommand(t *testing.T) {
+	commands := []string{"config", "/key/export/", "pin/ls"}
+	for cmd, exp := range map[string]bool{
+		"config":         true,
+		"config/replace": true,
+		"configure":      false,
+		"key/export":     true,
+		"key/list":       false,
+		"pin/ls":         true,
+		"pin/add":        false,
+	} {
+		if matchCommand(cmd, commands) != exp {
+			t.Errorf("%s: expected %t", cmd, exp)
+		}
+	}
+}
+
+func TestProxyCommandFilter(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{}
+	cfg.Default()
+	cfg.AllowedCommands = []string{"version", "id", "pin"}
+	cfg.DeniedCommands = []string{"pin/rm"}
+	proxy, mock := testIPFSProxyWithConfig(t, cfg)
+	defer mock.Close()
+	defer proxy.Shutdown(ctx)
+
+	base := strings.TrimSuffix(proxyURL(proxy), "/api/v0")
+	for path, code := range map[string]int{
+		"/api/v0/version":          http.StatusOK,
+		"/api/v0/id":               http.StatusOK,
+		"/api/v0/config/show":      http.StatusForbidden,
+		"/api/v0/shutdown":         http.StatusForbidden,
+		"/api/v0/pin/rm?arg=foo":   http.StatusForbidden,
+		"/api/v0/./shutdown":       http.StatusForbidden,
+		"/api/v0/version/../debug": http.StatusForbidden,
+		"/webui":                   http.StatusForbidden,
+	} {
+		res, err := http.Post(base+path, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != code {
+			t.Errorf("%s: expected %d, got %d", path, code, res.StatusCode)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"time"
 
@@ -11,6 +12,7 @@ import (
 	"github.com/kelseyhightower/envconfig"
 	ma "github.com/multiformats/go-multiaddr"
 
+	"github.com/ipfs/ipfs-cluster/api/common"
 	"github.com/ipfs/ipfs-cluster/config"
 )
 
@@ -36,8 +38,32 @@ const (
 	DefaultExtractHeadersPath = "/api/v0/version"
 	DefaultExtractHeadersTTL  = 5 * time.Minute
 	DefaultMaxHeaderBytes     = minMaxHeaderBytes
+	// The proxy forwards uploads to IPFS, so bodies are not limited by
+	// default.
+	DefaultMaxBodyBytes          = 0
+	DefaultMaxConcurrentRequests = 512
 )
 
+// DefaultFailoverCommands are the IPFS API commands which can be sent to
+// the failover nodes. They only read content, so any node returns the same
+// results.
+var DefaultFailoverCommands = []string{
+	"cat",
+	"get",
+	"ls",
+	"dag/get",
+	"dag/export",
+	"dag/stat",
+	"block/get",
+	"block/stat",
+	"object/get",
+	"object/stat",
+	"object/data",
+	"object/links",
+	"resolve",
+	"dns",
+}
+
 // Config allows to customize behaviour of IPFSProxy.
 // It implements the config.ComponentConfig interface.
 type Config struct {
@@ -46,12 +72,45 @@ type Config struct {
 	// Listen parameters for the IPFS Proxy.
 	ListenAddr []ma.Multiaddr
 
+	// UnixSocketMode sets the permissions of the Unix domain sockets in
+	// ListenAddr. 0 leaves them as created.
+	UnixSocketMode os.FileMode
+
+	// SystemdSockets are the names of the sockets passed by systemd
+	// socket activation on which the proxy is served, in addition to
+	// ListenAddr.
+	SystemdSockets []string
+
 	// Host/Port for the IPFS daemon.
 	NodeAddr ma.Multiaddr
 
 	// Should we talk to the IPFS API over HTTPS? (experimental, untested)
 	NodeHTTPS bool
 
+	// FailoverNodeAddrs are the IPFS API endpoints of other cluster
+	// peers' daemons. The FailoverCommands which are not hijacked are
+	// sent to them when the local daemon cannot be reached.
+	FailoverNodeAddrs []ma.Multiaddr
+
+	// FailoverCommands are the IPFS API commands (i.e. "cat" or
+	// "dag/get") which can be sent to the failover nodes. Only requests
+	// without a body are sent to them.
+	FailoverCommands []string
+
+	// LoadBalance distributes the FailoverCommands among the local
+	// daemon and the failover nodes in turns, instead of using the
+	// failover nodes only when the local daemon is down.
+	LoadBalance bool
+
+	// AllowedCommands, when set, are the only IPFS API commands served by
+	// the proxy. Requests outside /api/v0 are rejected too. A command
+	// includes its subcommands: "pin" allows "pin/add" and "pin/ls".
+	AllowedCommands []string
+
+	// DeniedCommands are IPFS API commands (and their subcommands) which
+	// are rejected, i.e. "config", "shutdown" or "key/export".
+	DeniedCommands []string
+
 	// LogFile is path of the file that would save Proxy API logs. If this
 	// path is empty, logs would be sent to standard output. This path
 	// should either be absolute or relative to cluster base directory. Its
@@ -71,6 +130,13 @@ type Config struct {
 	// accepted by the server
 	MaxHeaderBytes int
 
+	// Maximum size of request bodies in bytes. 0 means no limit.
+	MaxBodyBytes int64
+
+	// Maximum number of requests served at the same time. 0 means no
+	// limit.
+	MaxConcurrentRequests int
+
 	// Server-side amount of time a Keep-Alive connection will be
 	// kept idle before being reused
 	IdleTimeout time.Duration
@@ -99,9 +165,18 @@ type Config struct {
 
 type jsonConfig struct {
 	ListenMultiaddress ipfsconfig.Strings `json:"listen_multiaddress"`
+	UnixSocketMode     string             `json:"unix_socket_mode,omitempty"`
+	SystemdSockets     []string           `json:"systemd_sockets,omitempty"`
 	NodeMultiaddress   string             `json:"node_multiaddress"`
 	NodeHTTPS          bool               `json:"node_https,omitempty"`
 
+	FailoverNodeMultiaddresses ipfsconfig.Strings `json:"failover_node_multiaddresses,omitempty"`
+	FailoverCommands           []string           `json:"failover_commands,omitempty"`
+	LoadBalance                bool               `json:"load_balance,omitempty"`
+
+	AllowedCommands []string `json:"allowed_commands,omitempty"`
+	DeniedCommands  []string `json:"denied_commands,omitempty"`
+
 	LogFile string `json:"log_file"`
 
 	ReadTimeout       string `json:"read_timeout"`
@@ -110,6 +185,9 @@ type jsonConfig struct {
 	IdleTimeout       string `json:"idle_timeout"`
 	MaxHeaderBytes    int    `json:"max_header_bytes"`
 
+	MaxBodyBytes          *int64 `json:"max_body_bytes,omitempty"`
+	MaxConcurrentRequests *int   `json:"max_concurrent_requests,omitempty"`
+
 	ExtractHeadersExtra []string `json:"extract_headers_extra,omitempty"`
 	ExtractHeadersPath  string   `json:"extract_headers_path,omitempty"`
 	ExtractHeadersTTL   string   `json:"extract_headers_ttl,omitempty"`
@@ -150,11 +228,18 @@ func (cfg *Config) Default() error {
 	}
 	cfg.ListenAddr = proxy
 	cfg.NodeAddr = node
+	cfg.FailoverNodeAddrs = nil
+	cfg.FailoverCommands = DefaultFailoverCommands
+	cfg.LoadBalance = false
+	cfg.AllowedCommands = nil
+	cfg.DeniedCommands = nil
 	cfg.LogFile = ""
 	cfg.ReadTimeout = DefaultReadTimeout
 	cfg.ReadHeaderTimeout = DefaultReadHeaderTimeout
 	cfg.WriteTimeout = DefaultWriteTimeout
 	cfg.IdleTimeout = DefaultIdleTimeout
+	cfg.MaxBodyBytes = DefaultMaxBodyBytes
+	cfg.MaxConcurrentRequests = DefaultMaxConcurrentRequests
 	cfg.ExtractHeadersExtra = nil
 	cfg.ExtractHeadersPath = DefaultExtractHeadersPath
 	cfg.ExtractHeadersTTL = DefaultExtractHeadersTTL
@@ -183,7 +268,7 @@ func (cfg *Config) ApplyEnvVars() error {
 // at least in appearance.
 func (cfg *Config) Validate() error {
 	var err error
-	if len(cfg.ListenAddr) == 0 {
+	if len(cfg.ListenAddr) == 0 && len(cfg.SystemdSockets) == 0 {
 		err = errors.New("ipfsproxy.listen_multiaddress not set")
 	}
 	if cfg.NodeAddr == nil {
@@ -214,6 +299,26 @@ func (cfg *Config) Validate() error {
 		err = errors.New("ipfsproxy.extract_headers_ttl is invalid")
 	}
 
+	if cfg.MaxBodyBytes < 0 {
+		err = errors.New("ipfsproxy.max_body_bytes is invalid")
+	}
+
+	if cfg.MaxConcurrentRequests < 0 {
+		err = errors.New("ipfsproxy.max_concurrent_requests is invalid")
+	}
+
+	for _, cmds := range [][]string{cfg.AllowedCommands, cfg.DeniedCommands} {
+		for _, c := range cmds {
+			if normalizeCommand(c) == "" {
+				err = errors.New("ipfsproxy.allowed_commands and denied_commands cannot contain empty commands")
+			}
+		}
+	}
+
+	if cfg.LoadBalance && len(cfg.FailoverNodeAddrs) == 0 {
+		err = errors.New("ipfsproxy.load_balance needs failover_node_multiaddresses")
+	}
+
 	if cfg.MaxHeaderBytes < minMaxHeaderBytes {
 		err = fmt.Errorf("ipfsproxy.max_header_size must be greater or equal to %d", minMaxHeaderBytes)
 	}
@@ -248,7 +353,18 @@ func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
 			}
 			cfg.ListenAddr = append(cfg.ListenAddr, proxyAddr)
 		}
+	} else if len(jcfg.SystemdSockets) > 0 {
+		// Only listen on the sockets from systemd.
+		cfg.ListenAddr = nil
+	}
+	if jcfg.UnixSocketMode != "" {
+		mode, err := common.ParseFileMode(jcfg.UnixSocketMode)
+		if err != nil {
+			return fmt.Errorf("error parsing ipfsproxy.unix_socket_mode: %s", err)
+		}
+		cfg.UnixSocketMode = mode
 	}
+	cfg.SystemdSockets = jcfg.SystemdSockets
 	if jcfg.NodeMultiaddress != "" {
 		nodeAddr, err := ma.NewMultiaddr(jcfg.NodeMultiaddress)
 		if err != nil {
@@ -257,6 +373,20 @@ func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
 		cfg.NodeAddr = nodeAddr
 	}
 	config.SetIfNotDefault(jcfg.NodeHTTPS, &cfg.NodeHTTPS)
+	cfg.FailoverNodeAddrs = nil
+	for _, a := range jcfg.FailoverNodeMultiaddresses {
+		nodeAddr, err := ma.NewMultiaddr(a)
+		if err != nil {
+			return fmt.Errorf("error parsing ipfsproxy.failover_node_multiaddresses: %s", err)
+		}
+		cfg.FailoverNodeAddrs = append(cfg.FailoverNodeAddrs, nodeAddr)
+	}
+	if cmds := jcfg.FailoverCommands; len(cmds) > 0 {
+		cfg.FailoverCommands = cmds
+	}
+	config.SetIfNotDefault(jcfg.LoadBalance, &cfg.LoadBalance)
+	cfg.AllowedCommands = jcfg.AllowedCommands
+	cfg.DeniedCommands = jcfg.DeniedCommands
 
 	config.SetIfNotDefault(jcfg.LogFile, &cfg.LogFile)
 
@@ -277,6 +407,13 @@ func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
 	} else {
 		cfg.MaxHeaderBytes = jcfg.MaxHeaderBytes
 	}
+	// Missing limits keep their defaults. 0 means no limit.
+	if jcfg.MaxBodyBytes != nil {
+		cfg.MaxBodyBytes = *jcfg.MaxBodyBytes
+	}
+	if jcfg.MaxConcurrentRequests != nil {
+		cfg.MaxConcurrentRequests = *jcfg.MaxConcurrentRequests
+	}
 
 	if extra := jcfg.ExtractHeadersExtra; len(extra) > 0 {
 		cfg.ExtractHeadersExtra = extra
@@ -314,12 +451,29 @@ func (cfg *Config) toJSONConfig() (jcfg *jsonConfig, err error) {
 
 	// Set all configuration fields
 	jcfg.ListenMultiaddress = addresses
+	if cfg.UnixSocketMode != 0 {
+		jcfg.UnixSocketMode = fmt.Sprintf("%04o", cfg.UnixSocketMode)
+	}
+	jcfg.SystemdSockets = cfg.SystemdSockets
 	jcfg.NodeMultiaddress = cfg.NodeAddr.String()
+	for _, a := range cfg.FailoverNodeAddrs {
+		jcfg.FailoverNodeMultiaddresses = append(jcfg.FailoverNodeMultiaddresses, a.String())
+	}
+	if len(cfg.FailoverNodeAddrs) > 0 {
+		jcfg.FailoverCommands = cfg.FailoverCommands
+	}
+	jcfg.LoadBalance = cfg.LoadBalance
+	jcfg.AllowedCommands = cfg.AllowedCommands
+	jcfg.DeniedCommands = cfg.DeniedCommands
 	jcfg.ReadTimeout = cfg.ReadTimeout.String()
 	jcfg.ReadHeaderTimeout = cfg.ReadHeaderTimeout.String()
 	jcfg.WriteTimeout = cfg.WriteTimeout.String()
 	jcfg.IdleTimeout = cfg.IdleTimeout.String()
 	jcfg.MaxHeaderBytes = cfg.MaxHeaderBytes
+	maxBodyBytes := cfg.MaxBodyBytes
+	maxConcurrentRequests := cfg.MaxConcurrentRequests
+	jcfg.MaxBodyBytes = &maxBodyBytes
+	jcfg.MaxConcurrentRequests = &maxConcurrentRequests
 	jcfg.NodeHTTPS = cfg.NodeHTTPS
 	jcfg.LogFile = cfg.LogFile
 
@@ -11,6 +11,10 @@ var cfgJSON = []byte(`
 {
 	"listen_multiaddress": "/ip4/127.0.0.1/tcp/9095",
 	"node_multiaddress": "/ip4/127.0.0.1/tcp/5001",
+	"failover_node_multiaddresses": ["/ip4/10.0.0.2/tcp/5001"],
+	"failover_commands": ["cat", "dag/get"],
+	"load_balance": true,
+	"denied_commands": ["config", "shutdown", "key/export"],
 	"log_file": "",
 	"read_timeout": "10m0s",
 	"read_header_timeout": "5s",
@@ -37,6 +41,12 @@ func TestLoadJSON(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	if len(cfg.FailoverNodeAddrs) != 1 || len(cfg.FailoverCommands) != 2 || !cfg.LoadBalance {
+		t.Error("failover options were not parsed")
+	}
+	if len(cfg.DeniedCommands) != 3 || len(cfg.AllowedCommands) != 0 {
+		t.Error("command filters were not parsed")
+	}
 
 	j := &jsonConfig{}
 	json.Unmarshal(cfgJSON, j)
@@ -144,6 +154,18 @@ func TestDefault(t *testing.T) {
 	if cfg.Validate() == nil {
 		t.Fatal("expected error validating")
 	}
+
+	cfg.Default()
+	cfg.LoadBalance = true
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating: no failover nodes")
+	}
+
+	cfg.Default()
+	cfg.DeniedCommands = []string{"/"}
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating: empty command")
+	}
 }
 
 func TestApplyEnvVars(t *testing.T) {
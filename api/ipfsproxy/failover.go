@@ -0,0 +1,78 @@
+package ipfsproxy
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// failoverTransport sends the requests for the failover commands to the
+// local IPFS daemon and, when it cannot be reached, to the failover nodes
+// in order. With load balancing, every request starts with the next node
+// in turn. Other requests only go to the local daemon.
+type failoverTransport struct {
+	rt       http.RoundTripper
+	nodes    []*url.URL // the local daemon goes first
+	commands map[string]struct{}
+	balance  bool
+
+	next uint32
+}
+
+func newFailoverTransport(rt http.RoundTripper, nodes []*url.URL, commands []string, balance bool) *failoverTransport {
+	ft := &failoverTransport{
+		rt:       rt,
+		nodes:    nodes,
+		commands: make(map[string]struct{}, len(commands)),
+		balance:  balance,
+	}
+	for _, c := range commands {
+		ft.commands["/api/v0/"+strings.Trim(c, "/")] = struct{}{}
+	}
+	return ft
+}
+
+// canFailover returns true when the request can be sent to any node. A
+// request with a body cannot be sent again once it has been read.
+func (ft *failoverTransport) canFailover(req *http.Request) bool {
+	if len(ft.nodes) < 2 {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.ContentLength != 0 {
+		return false
+	}
+	_, ok := ft.commands[req.URL.Path]
+	return ok
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (ft *failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !ft.canFailover(req) {
+		return ft.rt.RoundTrip(req)
+	}
+
+	start := 0
+	if ft.balance {
+		start = int((atomic.AddUint32(&ft.next, 1) - 1) % uint32(len(ft.nodes)))
+	}
+
+	var err error
+	for i := range ft.nodes {
+		node := ft.nodes[(start+i)%len(ft.nodes)]
+		r := req.Clone(req.Context())
+		r.URL.Scheme = node.Scheme
+		r.URL.Host = node.Host
+
+		var resp *http.Response
+		resp, err = ft.rt.RoundTrip(r)
+		if err == nil {
+			return resp, nil
+		}
+		if req.Context().Err() != nil {
+			return nil, err
+		}
+		logger.Warnf("IPFS node %s unavailable for %s: %s", node.Host, req.URL.Path, err)
+	}
+	return nil, err
+}
@@ -0,0 +1,104 @@
+package ipfsproxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/test"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// downNodeAddr returns the address of a closed port.
+func downNodeAddr(t *testing.T) ma.Multiaddr {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().(*net.TCPAddr)
+	l.Close()
+	maddr, _ := ma.NewMultiaddr(fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", addr.Port))
+	return maddr
+}
+
+func TestProxyFailover(t *testing.T) {
+	ctx := context.Background()
+	mock := test.NewIpfsMock(t)
+	defer mock.Close()
+
+	cfg := &Config{}
+	cfg.Default()
+	mockAddr, _ := ma.NewMultiaddr(fmt.Sprintf("/ip4/%s/tcp/%d", mock.Addr, mock.Port))
+	cfg.NodeAddr = downNodeAddr(t)
+	cfg.FailoverNodeAddrs = []ma.Multiaddr{mockAddr}
+	cfg.FailoverCommands = []string{"version"}
+	cfg.ListenAddr = []ma.Multiaddr{ma.StringCast("/ip4/127.0.0.1/tcp/0")}
+
+	proxy, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Shutdown(ctx)
+	proxy.server.SetKeepAlivesEnabled(false)
+	proxy.SetClient(test.NewMockRPCClient(t))
+
+	res, err := http.Post(proxyURL(proxy)+"/version", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Error("version should have been served by the failover node:", res.Status)
+	}
+
+	res, err = http.Post(proxyURL(proxy)+"/id", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadGateway {
+		t.Error("id should only be sent to the local daemon:", res.Status)
+	}
+}
+
+func TestFailoverTransportLoadBalance(t *testing.T) {
+	var nodes []*url.URL
+	hits := make([]int, 2)
+	for i := range hits {
+		i := i
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits[i]++
+		}))
+		defer srv.Close()
+		u, _ := url.Parse(srv.URL)
+		nodes = append(nodes, u)
+	}
+
+	ft := newFailoverTransport(http.DefaultTransport, nodes, []string{"cat"}, true)
+	for i := 0; i < 4; i++ {
+		req, _ := http.NewRequest(http.MethodPost, nodes[0].String()+"/api/v0/cat?arg=x", nil)
+		res, err := ft.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+	if hits[0] != 2 || hits[1] != 2 {
+		t.Errorf("expected requests to be balanced: %v", hits)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, nodes[0].String()+"/api/v0/add", nil)
+	res, err := ft.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if hits[0] != 3 {
+		t.Error("other commands should go to the local daemon")
+	}
+}
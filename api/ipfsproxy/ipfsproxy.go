@@ -17,6 +17,7 @@ import (
 
 	"github.com/ipfs/ipfs-cluster/adder/adderutils"
 	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/api/common"
 	"github.com/ipfs/ipfs-cluster/rpcutil"
 
 	handlers "github.com/gorilla/handlers"
@@ -27,6 +28,7 @@ import (
 	path "github.com/ipfs/go-path"
 	peer "github.com/libp2p/go-libp2p-core/peer"
 	rpc "github.com/libp2p/go-libp2p-gorpc"
+	ma "github.com/multiformats/go-multiaddr"
 	madns "github.com/multiformats/go-multiaddr-dns"
 	manet "github.com/multiformats/go-multiaddr/net"
 
@@ -38,6 +40,20 @@ import (
 // DNSTimeout is used when resolving DNS multiaddresses in this module
 var DNSTimeout = 5 * time.Second
 
+// contentCommands are the proxied IPFS API commands which read content.
+// Their arguments are reported to the cluster peer as accessed.
+var contentCommands = map[string]struct{}{
+	"/api/v0/cat":        {},
+	"/api/v0/get":        {},
+	"/api/v0/ls":         {},
+	"/api/v0/dag/get":    {},
+	"/api/v0/dag/export": {},
+}
+
+// accessQueueSize is the number of accesses waiting to be reported to the
+// cluster peer. Accesses beyond it are not tracked.
+const accessQueueSize = 1024
+
 var (
 	logger      = logging.Logger("ipfsproxy")
 	proxyLogger = logging.Logger("ipfsproxylog")
@@ -65,6 +81,8 @@ type Server struct {
 
 	ipfsHeadersStore sync.Map
 
+	accessQueue chan cid.Cid
+
 	shutdownLock sync.Mutex
 	shutdown     bool
 	wg           sync.WaitGroup
@@ -105,57 +123,48 @@ func New(cfg *Config) (*Server, error) {
 		return nil, err
 	}
 
-	nodeMAddr := cfg.NodeAddr
-	// dns multiaddresses need to be resolved first
-	if madns.Matches(nodeMAddr) {
-		ctx, cancel := context.WithTimeout(context.Background(), DNSTimeout)
-		defer cancel()
-		resolvedAddrs, err := madns.Resolve(ctx, cfg.NodeAddr)
-		if err != nil {
-			logger.Error(err)
-			return nil, err
-		}
-		nodeMAddr = resolvedAddrs[0]
+	nodeScheme := "http"
+	if cfg.NodeHTTPS {
+		nodeScheme = "https"
 	}
-
-	_, nodeAddr, err := manet.DialArgs(nodeMAddr)
+	proxyURL, err := nodeURL(cfg.NodeAddr, nodeScheme)
 	if err != nil {
 		return nil, err
 	}
+	nodeHTTPAddr := proxyURL.String()
 
-	var listeners []net.Listener
-	for _, addr := range cfg.ListenAddr {
-		proxyNet, proxyAddr, err := manet.DialArgs(addr)
+	nodes := []*url.URL{proxyURL}
+	for _, addr := range cfg.FailoverNodeAddrs {
+		u, err := nodeURL(addr, nodeScheme)
 		if err != nil {
 			return nil, err
 		}
+		nodes = append(nodes, u)
+	}
 
-		l, err := net.Listen(proxyNet, proxyAddr)
+	listeners, err := common.SystemdListeners(cfg.SystemdSockets)
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range cfg.ListenAddr {
+		l, err := common.Listen(addr, cfg.UnixSocketMode)
 		if err != nil {
 			return nil, err
 		}
 		listeners = append(listeners, l)
 	}
 
-	nodeScheme := "http"
-	if cfg.NodeHTTPS {
-		nodeScheme = "https"
-	}
-	nodeHTTPAddr := fmt.Sprintf("%s://%s", nodeScheme, nodeAddr)
-	proxyURL, err := url.Parse(nodeHTTPAddr)
-	if err != nil {
-		return nil, err
-	}
-
 	var handler http.Handler
 	router := mux.NewRouter()
-	handler = router
+	handler = filterCommands(router, cfg.AllowedCommands, cfg.DeniedCommands)
+	handler = common.LimitBody(handler, cfg.MaxBodyBytes)
+	handler = common.LimitConcurrency(handler, cfg.MaxConcurrentRequests)
 
 	if cfg.Tracing {
 		handler = &ochttp.Handler{
 			IsPublicEndpoint: true,
 			Propagation:      &tracecontext.HTTPFormat{},
-			Handler:          router,
+			Handler:          handler,
 			StartOptions:     trace.StartOptions{SpanKind: trace.SpanKindServer},
 			FormatSpanName: func(req *http.Request) string {
 				return "proxy:" + req.Host + ":" + req.URL.Path + ":" + req.Method
@@ -189,7 +198,7 @@ func New(cfg *Config) (*Server, error) {
 	s.SetKeepAlivesEnabled(true) // A reminder that this can be changed
 
 	reverseProxy := httputil.NewSingleHostReverseProxy(proxyURL)
-	reverseProxy.Transport = http.DefaultTransport
+	reverseProxy.Transport = newFailoverTransport(http.DefaultTransport, nodes, cfg.FailoverCommands, cfg.LoadBalance)
 	ctx, cancel := context.WithCancel(context.Background())
 	proxy := &Server{
 		ctx:              ctx,
@@ -200,7 +209,8 @@ func New(cfg *Config) (*Server, error) {
 		rpcReady:         make(chan struct{}, 1),
 		listeners:        listeners,
 		server:           s,
-		ipfsRoundTripper: reverseProxy.Transport,
+		ipfsRoundTripper: http.DefaultTransport,
+		accessQueue:      make(chan cid.Cid, accessQueueSize),
 	}
 
 	// Ideally, we should only intercept POST requests, but
@@ -256,12 +266,33 @@ func New(cfg *Config) (*Server, error) {
 		Name("RepoGC")
 
 	// Everything else goes to the IPFS daemon.
-	router.PathPrefix("/").Handler(reverseProxy)
+	router.PathPrefix("/").Handler(proxy.trackAccesses(reverseProxy))
 
 	go proxy.run()
 	return proxy, nil
 }
 
+// nodeURL returns the URL of the IPFS API at the given multiaddress.
+func nodeURL(addr ma.Multiaddr, scheme string) (*url.URL, error) {
+	// dns multiaddresses need to be resolved first
+	if madns.Matches(addr) {
+		ctx, cancel := context.WithTimeout(context.Background(), DNSTimeout)
+		defer cancel()
+		resolvedAddrs, err := madns.Resolve(ctx, addr)
+		if err != nil {
+			logger.Error(err)
+			return nil, err
+		}
+		addr = resolvedAddrs[0]
+	}
+
+	_, hostPort, err := manet.DialArgs(addr)
+	if err != nil {
+		return nil, err
+	}
+	return url.Parse(fmt.Sprintf("%s://%s", scheme, hostPort))
+}
+
 // SetClient makes the component ready to perform RPC
 // requests.
 func (proxy *Server) SetClient(c *rpc.Client) {
@@ -303,6 +334,9 @@ func (proxy *Server) run() {
 	proxy.shutdownLock.Lock()
 	defer proxy.shutdownLock.Unlock()
 
+	proxy.wg.Add(1)
+	go proxy.reportAccesses()
+
 	// This launches the proxy
 	proxy.wg.Add(len(proxy.listeners))
 	for _, l := range proxy.listeners {
@@ -583,6 +617,8 @@ func (proxy *Server) addHandler(w http.ResponseWriter, r *http.Request) {
 		reader,
 		w,
 		outputTransform,
+		nil,
+		nil,
 	)
 
 	// any errors have been sent as Trailer
@@ -721,6 +757,62 @@ func (proxy *Server) repoGCHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// trackAccesses wraps a handler so that the CIDs read with content commands
+// are reported to the cluster peer before handling the request.
+func (proxy *Server) trackAccesses(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := contentCommands[r.URL.Path]; ok {
+			for _, arg := range r.URL.Query()["arg"] {
+				proxy.trackAccess(arg)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (proxy *Server) trackAccess(arg string) {
+	p, err := path.ParsePath(arg)
+	if err != nil {
+		return
+	}
+	// Only /ipfs/ paths (or bare CIDs) can be tracked.
+	ci, _, err := path.SplitAbsPath(p)
+	if err != nil {
+		return
+	}
+
+	select {
+	case proxy.accessQueue <- ci:
+	default:
+		logger.Debugf("access queue full: not tracking access to %s", ci)
+	}
+}
+
+// reportAccesses reports the queued accesses to the cluster peer until the
+// proxy is shut down.
+func (proxy *Server) reportAccesses() {
+	defer proxy.wg.Done()
+
+	for {
+		select {
+		case <-proxy.ctx.Done():
+			return
+		case ci := <-proxy.accessQueue:
+			err := proxy.rpcClient.CallContext(
+				proxy.ctx,
+				"",
+				"Cluster",
+				"TrackAccess",
+				ci,
+				&struct{}{},
+			)
+			if err != nil {
+				logger.Debugf("error tracking access to %s: %s", ci, err)
+			}
+		}
+	}
+}
+
 // slashHandler returns a handler which converts a /a/b/c/<argument> request
 // into an /a/b/c/<argument>?arg=<argument> one. And uses the given origHandler
 // for it. Our handlers expect that arguments are passed in the ?arg query
@@ -899,3 +899,19 @@ func TestProxyLogging(t *testing.T) {
 	}
 
 }
+
+func TestTrackAccessQueueFull(t *testing.T) {
+	proxy := &Server{
+		accessQueue: make(chan cid.Cid, 1),
+	}
+
+	proxy.trackAccess(test.Cid1.String())
+	proxy.trackAccess("/ipfs/" + test.Cid2.String())
+	proxy.trackAccess("not a cid")
+	if len(proxy.accessQueue) != 1 {
+		t.Fatal("expected one queued access")
+	}
+	if ci := <-proxy.accessQueue; !ci.Equals(test.Cid1) {
+		t.Error("unexpected queued access:", ci)
+	}
+}
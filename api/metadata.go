@@ -0,0 +1,237 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInvalidMetadata is returned when the metadata of a pin goes over the
+// configured limits or does not match the configured schema.
+var ErrInvalidMetadata = errors.New("invalid metadata")
+
+// MetadataPolicy limits the metadata of the pins submitted to the cluster.
+// Limits of 0 mean no limit. Sizes are in bytes.
+type MetadataPolicy struct {
+	// MaxKeys is the maximum number of metadata entries.
+	MaxKeys int `json:"max_keys"`
+	// MaxKeySize and MaxValueSize limit every key and value.
+	MaxKeySize   int `json:"max_key_size"`
+	MaxValueSize int `json:"max_value_size"`
+	// MaxSize limits the sum of the sizes of all keys and values.
+	MaxSize int `json:"max_size"`
+	// Schema, when set, is a JSON schema that the metadata must match.
+	Schema *MetadataSchema `json:"schema,omitempty"`
+}
+
+// IsEmpty returns true when the policy does not limit anything.
+func (mp *MetadataPolicy) IsEmpty() bool {
+	return mp == nil || (mp.MaxKeys == 0 && mp.MaxKeySize == 0 &&
+		mp.MaxValueSize == 0 && mp.MaxSize == 0 && mp.Schema == nil)
+}
+
+// Validate checks the limits and compiles the patterns of the schema.
+func (mp *MetadataPolicy) Validate() error {
+	if mp == nil {
+		return nil
+	}
+	if mp.MaxKeys < 0 || mp.MaxKeySize < 0 || mp.MaxValueSize < 0 || mp.MaxSize < 0 {
+		return errors.New("metadata limits cannot be negative")
+	}
+	if mp.Schema != nil {
+		return mp.Schema.compile()
+	}
+	return nil
+}
+
+// Check returns an error wrapping ErrInvalidMetadata describing the first
+// problem found with the given metadata. Validate must have been called.
+func (mp *MetadataPolicy) Check(meta map[string]string) error {
+	if mp == nil {
+		return nil
+	}
+	if mp.MaxKeys > 0 && len(meta) > mp.MaxKeys {
+		return fmt.Errorf("%w: %d keys, the limit is %d", ErrInvalidMetadata, len(meta), mp.MaxKeys)
+	}
+
+	size := 0
+	for _, k := range sortedKeys(meta) {
+		v := meta[k]
+		if mp.MaxKeySize > 0 && len(k) > mp.MaxKeySize {
+			return fmt.Errorf("%w: key %.32q is longer than %d bytes", ErrInvalidMetadata, k, mp.MaxKeySize)
+		}
+		if mp.MaxValueSize > 0 && len(v) > mp.MaxValueSize {
+			return fmt.Errorf("%w: value of %q is longer than %d bytes", ErrInvalidMetadata, k, mp.MaxValueSize)
+		}
+		size += len(k) + len(v)
+	}
+	if mp.MaxSize > 0 && size > mp.MaxSize {
+		return fmt.Errorf("%w: %d bytes, the limit is %d", ErrInvalidMetadata, size, mp.MaxSize)
+	}
+
+	if mp.Schema != nil {
+		if err := mp.Schema.check(meta); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidMetadata, err)
+		}
+	}
+	return nil
+}
+
+// MetadataSchema is the subset of JSON Schema which applies to pin
+// metadata: an object whose values are strings. Schemas using other
+// keywords are rejected when they are loaded, rather than being partially
+// enforced.
+type MetadataSchema struct {
+	Schema      string `json:"$schema,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Type can only be "object".
+	Type       string                          `json:"type,omitempty"`
+	Required   []string                        `json:"required,omitempty"`
+	Properties map[string]*MetadataValueSchema `json:"properties,omitempty"`
+	// AdditionalProperties set to false rejects the keys not listed in
+	// Properties.
+	AdditionalProperties *bool `json:"additionalProperties,omitempty"`
+	// PropertyNames constrains all the keys.
+	PropertyNames *MetadataValueSchema `json:"propertyNames,omitempty"`
+}
+
+// MetadataValueSchema constrains a metadata value, or the metadata keys when
+// used as PropertyNames. Lengths are in characters.
+type MetadataValueSchema struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Type can only be "string".
+	Type      string   `json:"type,omitempty"`
+	Enum      []string `json:"enum,omitempty"`
+	Pattern   string   `json:"pattern,omitempty"`
+	MinLength int      `json:"minLength,omitempty"`
+	MaxLength int      `json:"maxLength,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// UnmarshalJSON rejects the keywords which are not supported.
+func (ms *MetadataSchema) UnmarshalJSON(b []byte) error {
+	type schema MetadataSchema
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode((*schema)(ms)); err != nil {
+		return fmt.Errorf("unsupported metadata schema: %w", err)
+	}
+	return nil
+}
+
+// UnmarshalJSON rejects the keywords which are not supported.
+func (vs *MetadataValueSchema) UnmarshalJSON(b []byte) error {
+	type schema MetadataValueSchema
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	// The error is wrapped by the MetadataSchema.
+	return dec.Decode((*schema)(vs))
+}
+
+func (ms *MetadataSchema) compile() error {
+	if ms.Type != "" && ms.Type != "object" {
+		return fmt.Errorf("metadata schema: type must be \"object\", not %q", ms.Type)
+	}
+	for k, vs := range ms.Properties {
+		if vs == nil {
+			return fmt.Errorf("metadata schema: property %q has no schema", k)
+		}
+		if err := vs.compile(); err != nil {
+			return fmt.Errorf("metadata schema: property %q: %w", k, err)
+		}
+	}
+	if ms.PropertyNames != nil {
+		if err := ms.PropertyNames.compile(); err != nil {
+			return fmt.Errorf("metadata schema: propertyNames: %w", err)
+		}
+	}
+	return nil
+}
+
+func (vs *MetadataValueSchema) compile() error {
+	if vs.Type != "" && vs.Type != "string" {
+		return fmt.Errorf("type must be \"string\", not %q", vs.Type)
+	}
+	if vs.MinLength < 0 || vs.MaxLength < 0 {
+		return errors.New("lengths cannot be negative")
+	}
+	if vs.Pattern != "" {
+		re, err := regexp.Compile(vs.Pattern)
+		if err != nil {
+			return err
+		}
+		vs.pattern = re
+	}
+	return nil
+}
+
+func (ms *MetadataSchema) check(meta map[string]string) error {
+	for _, k := range ms.Required {
+		if _, ok := meta[k]; !ok {
+			return fmt.Errorf("%q is required", k)
+		}
+	}
+	for _, k := range sortedKeys(meta) {
+		if ms.PropertyNames != nil {
+			if err := ms.PropertyNames.check(k); err != nil {
+				return fmt.Errorf("key %.32q: %s", k, err)
+			}
+		}
+		vs, ok := ms.Properties[k]
+		if !ok {
+			if ms.AdditionalProperties != nil && !*ms.AdditionalProperties {
+				return fmt.Errorf("%q is not an allowed key", k)
+			}
+			continue
+		}
+		if err := vs.check(meta[k]); err != nil {
+			return fmt.Errorf("%q: %s", k, err)
+		}
+	}
+	return nil
+}
+
+func (vs *MetadataValueSchema) check(v string) error {
+	if len(vs.Enum) > 0 {
+		found := false
+		for _, e := range vs.Enum {
+			if v == e {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("must be one of %s", strings.Join(vs.Enum, ", "))
+		}
+	}
+	n := utf8.RuneCountInString(v)
+	if vs.MinLength > 0 && n < vs.MinLength {
+		return fmt.Errorf("shorter than %d characters", vs.MinLength)
+	}
+	if vs.MaxLength > 0 && n > vs.MaxLength {
+		return fmt.Errorf("longer than %d characters", vs.MaxLength)
+	}
+	if vs.pattern != nil && !vs.pattern.MatchString(v) {
+		return fmt.Errorf("does not match %s", vs.Pattern)
+	}
+	return nil
+}
+
+// sortedKeys makes the errors for metadata with several problems
+// predictable.
+func sortedKeys(meta map[string]string) []string {
+	keys := make([]string, 0, len(meta))
+	for k := range meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMetadataPolicyLimits(t *testing.T) {
+	mp := &MetadataPolicy{MaxKeys: 2, MaxKeySize: 4, MaxValueSize: 8, MaxSize: 12}
+	if err := mp.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mp.Check(map[string]string{"a": "12345678", "b": "1"}); err != nil {
+		t.Error(err)
+	}
+	for _, meta := range []map[string]string{
+		{"a": "1", "b": "2", "c": "3"},
+		{"toolong": "1"},
+		{"a": "123456789"},
+		{"abcd": "12345678", "b": "1"},
+	} {
+		if err := mp.Check(meta); !errors.Is(err, ErrInvalidMetadata) {
+			t.Errorf("expected ErrInvalidMetadata for %v: %v", meta, err)
+		}
+	}
+
+	if (&MetadataPolicy{MaxKeys: -1}).Validate() == nil {
+		t.Error("negative limits should not validate")
+	}
+	var empty *MetadataPolicy
+	if !empty.IsEmpty() || empty.Check(map[string]string{"a": "b"}) != nil {
+		t.Error("a nil policy should allow everything")
+	}
+}
+
+func TestMetadataPolicySchema(t *testing.T) {
+	var mp MetadataPolicy
+	err := json.Unmarshal([]byte(`{
+  "schema": {
+    "$schema": "http://json-schema.org/draft-07/schema#",
+    "type": "object",
+    "required": ["owner"],
+    "properties": {
+      "owner": {"type": "string", "pattern": "^[a-z]+$"},
+      "tier": {"enum": ["hot", "cold"]},
+      "note": {"minLength": 2, "maxLength": 4}
+    },
+    "additionalProperties": false,
+    "propertyNames": {"maxLength": 5}
+  }
+}`), &mp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mp.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mp.Check(map[string]string{"owner": "alice", "tier": "hot", "note": "ok"}); err != nil {
+		t.Error(err)
+	}
+	for _, meta := range []map[string]string{
+		{"tier": "hot"},
+		{"owner": "Alice"},
+		{"owner": "alice", "tier": "warm"},
+		{"owner": "alice", "note": "x"},
+		{"owner": "alice", "note": "toolong"},
+		{"owner": "alice", "other": "x"},
+	} {
+		err := mp.Check(meta)
+		if !errors.Is(err, ErrInvalidMetadata) {
+			t.Errorf("expected ErrInvalidMetadata for %v: %v", meta, err)
+		}
+	}
+	err = mp.Check(map[string]string{"owner": "Alice"})
+	if err == nil || !strings.Contains(err.Error(), `"owner"`) {
+		t.Error("the error should name the key:", err)
+	}
+}
+
+func TestMetadataSchemaUnsupported(t *testing.T) {
+	for _, s := range []string{
+		`{"schema": {"type": "object", "oneOf": []}}`,
+		`{"schema": {"properties": {"a": {"type": "string", "format": "email"}}}}`,
+	} {
+		var mp MetadataPolicy
+		if err := json.Unmarshal([]byte(s), &mp); err == nil {
+			t.Errorf("expected an error loading %s", s)
+		}
+	}
+
+	for _, schema := range []*MetadataSchema{
+		{Type: "array"},
+		{Properties: map[string]*MetadataValueSchema{"a": {Type: "number"}}},
+		{Properties: map[string]*MetadataValueSchema{"a": {Pattern: "("}}},
+	} {
+		mp := &MetadataPolicy{Schema: schema}
+		if mp.Validate() == nil {
+			t.Errorf("expected an error validating %+v", schema)
+		}
+	}
+}
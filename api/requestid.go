@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	logging "github.com/ipfs/go-log/v2"
+	"go.uber.org/zap"
+)
+
+// Every API request gets an ID, which is carried in its context so that
+// the components handling it can include it in their logs. Context values
+// do not cross RPC calls to other peers, so pins also carry the ID of the
+// request which submitted them, over RPC and in the Raft log. It is not
+// stored in the shared state.
+
+// MaxRequestIDLength is the maximum length of a request ID provided by a
+// client. Longer ones are replaced.
+const MaxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// NewRequestID returns a random request ID.
+func NewRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ValidRequestID returns true when the given ID, provided by a client, can
+// be used as the ID of a request: it is not empty, not too long and only
+// contains printable ASCII characters.
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > MaxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// ContextWithRequestID returns a context carrying the given request ID.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID carried by the context, or an
+// empty string.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+type requestUserKey struct{}
+
+// ContextWithRequestUser returns a context carrying the name of the
+// authenticated user which made the request.
+func ContextWithRequestUser(ctx context.Context, user string) context.Context {
+	if user == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestUserKey{}, user)
+}
+
+// RequestUserFromContext returns the authenticated user carried by the
+// context, or an empty string.
+func RequestUserFromContext(ctx context.Context) string {
+	user, _ := ctx.Value(requestUserKey{}).(string)
+	return user
+}
+
+// CopyRequestID returns dst carrying the request ID and the user of src, if
+// any. It is used when the work for a request happens on a context with a
+// different lifetime.
+func CopyRequestID(dst, src context.Context) context.Context {
+	dst = ContextWithRequestUser(dst, RequestUserFromContext(src))
+	return ContextWithRequestID(dst, RequestIDFromContext(src))
+}
+
+// PinWithRequestID returns a copy of the pin carrying the request ID of
+// the context. The pin is returned as is when there is none.
+func PinWithRequestID(ctx context.Context, pin *Pin) *Pin {
+	id := RequestIDFromContext(ctx)
+	if id == "" || pin == nil || pin.RequestID == id {
+		return pin
+	}
+	p := *pin
+	p.RequestID = id
+	return &p
+}
+
+// ContextWithPinRequestID returns a context carrying the request ID of the
+// pin, unless the context carries one already.
+func ContextWithPinRequestID(ctx context.Context, pin *Pin) context.Context {
+	if pin == nil || RequestIDFromContext(ctx) != "" {
+		return ctx
+	}
+	return ContextWithRequestID(ctx, pin.RequestID)
+}
+
+// RequestLogger returns the given logger with the request ID carried by
+// the context, if any, added to every line.
+func RequestLogger(ctx context.Context, l *logging.ZapEventLogger) *zap.SugaredLogger {
+	if id := RequestIDFromContext(ctx); id != "" {
+		return l.With("request_id", id)
+	}
+	return &l.SugaredLogger
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+	codec "github.com/ugorji/go/codec"
+)
+
+func TestRequestID(t *testing.T) {
+	id := NewRequestID()
+	if !ValidRequestID(id) {
+		t.Fatal("generated request IDs should be valid")
+	}
+	for _, bad := range []string{"", "with space", "new\nline", strings.Repeat("a", MaxRequestIDLength+1)} {
+		if ValidRequestID(bad) {
+			t.Errorf("%q should not be a valid request ID", bad)
+		}
+	}
+
+	ctx := ContextWithRequestID(context.Background(), id)
+	if RequestIDFromContext(ctx) != id {
+		t.Error("expected the request ID in the context")
+	}
+	copied := CopyRequestID(context.Background(), ctx)
+	if RequestIDFromContext(copied) != id {
+		t.Error("expected the request ID to be copied")
+	}
+	if RequestIDFromContext(context.Background()) != "" {
+		t.Error("expected no request ID")
+	}
+}
+
+func TestRequestUser(t *testing.T) {
+	ctx := ContextWithRequestUser(context.Background(), "alice")
+	ctx = ContextWithRequestID(ctx, "id1")
+	copied := CopyRequestID(context.Background(), ctx)
+	if RequestUserFromContext(copied) != "alice" {
+		t.Error("expected the user to be copied")
+	}
+	if RequestUserFromContext(context.Background()) != "" {
+		t.Error("expected no user")
+	}
+}
+
+func TestPinRequestID(t *testing.T) {
+	ci, _ := cid.Decode("QmXZrtE5jQwXNqCJMfHUTQkvhQ4ZAnqMnmzFMJfLewuabc")
+	pin := PinCid(ci)
+	ctx := ContextWithRequestID(context.Background(), "id1")
+
+	withID := PinWithRequestID(ctx, pin)
+	if withID.RequestID != "id1" || pin.RequestID != "" {
+		t.Fatal("expected a copy of the pin with the request ID")
+	}
+	if PinWithRequestID(context.Background(), pin) != pin {
+		t.Error("expected the same pin without a request ID")
+	}
+
+	// The ID crosses RPC calls with the pin, but it is not stored.
+	var buf bytes.Buffer
+	if err := codec.NewEncoder(&buf, &codec.MsgpackHandle{}).Encode(withID); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Pin
+	if err := codec.NewDecoder(&buf, &codec.MsgpackHandle{}).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.RequestID != "id1" {
+		t.Error("expected the request ID to be encoded")
+	}
+	pb, err := withID.ProtoMarshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored Pin
+	if err := stored.ProtoUnmarshal(pb); err != nil {
+		t.Fatal(err)
+	}
+	if stored.RequestID != "" {
+		t.Error("the request ID should not be stored")
+	}
+
+	if RequestIDFromContext(ContextWithPinRequestID(context.Background(), &decoded)) != "id1" {
+		t.Error("expected the request ID of the pin in the context")
+	}
+	other := ContextWithRequestID(context.Background(), "id2")
+	if RequestIDFromContext(ContextWithPinRequestID(other, &decoded)) != "id2" {
+		t.Error("the request ID of the context should be kept")
+	}
+}
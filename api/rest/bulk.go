@@ -0,0 +1,106 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	types "github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/api/common"
+)
+
+// Bulk operations act on the pins selected with "meta.<key>=<value>" query
+// arguments. Users restricted to a namespace only select the pins in their
+// namespace.
+
+// pinSelector returns the selector given in the query of the request.
+func (api *API) pinSelector(r *http.Request) types.PinSelector {
+	sel := types.PinSelectorFromQuery(r.URL.Query())
+	if ns, restricted := api.namespace(r); restricted {
+		sel.Namespace = ns
+	}
+	return sel
+}
+
+// withSelector serves the requests which select pins by their metadata
+// with the bulk handler and the rest with h.
+func (api *API) withSelector(bulk, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(types.PinSelectorFromQuery(r.URL.Query()).Metadata) > 0 {
+			bulk(w, r)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// bulkErrorStatus returns 400 Bad Request for bulk operations without
+// selector.
+func bulkErrorStatus(err error) int {
+	if err != nil && err.Error() == types.ErrEmptySelector.Error() {
+		return http.StatusBadRequest
+	}
+	return common.SetStatusAutomatically
+}
+
+func (api *API) unpinMatchingHandler(w http.ResponseWriter, r *http.Request) {
+	var pins []*types.Pin
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"UnpinMatching",
+		api.pinSelector(r),
+		&pins,
+	)
+	api.SendResponse(w, bulkErrorStatus(err), err, pins)
+}
+
+func (api *API) recoverMatchingHandler(w http.ResponseWriter, r *http.Request) {
+	var globalPinInfos []*types.GlobalPinInfo
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"RecoverMatching",
+		api.pinSelector(r),
+		&globalPinInfos,
+	)
+	api.SendResponse(w, bulkErrorStatus(err), err, globalPinInfos)
+}
+
+// replicateMatchingHandler sets the replication factors given with the
+// "replication", or "replication-min" and "replication-max", arguments on
+// the selected pins.
+func (api *API) replicateMatchingHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if v := q.Get("replication"); v != "" {
+		q.Set("replication-min", v)
+		q.Set("replication-max", v)
+	}
+	rmin, err := strconv.Atoi(q.Get("replication-min"))
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, errors.New("parameter replication-min is invalid"), nil)
+		return
+	}
+	rmax, err := strconv.Atoi(q.Get("replication-max"))
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, errors.New("parameter replication-max is invalid"), nil)
+		return
+	}
+
+	var pins []*types.Pin
+	err = api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"ReplicateMatching",
+		types.BulkReplication{
+			Selector:             api.pinSelector(r),
+			ReplicationFactorMin: rmin,
+			ReplicationFactorMax: rmax,
+		},
+		&pins,
+	)
+	api.SendResponse(w, bulkErrorStatus(err), err, pins)
+}
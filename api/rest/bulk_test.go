@@ -0,0 +1,96 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	test "github.com/ipfs/ipfs-cluster/api/common/test"
+	clustertest "github.com/ipfs/ipfs-cluster/test"
+)
+
+func TestAPIUnpinMatchingEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var pins []*api.Pin
+		test.MakeDelete(t, rest, url(rest)+"/pins?meta.project=x", &pins)
+		if len(pins) != 1 || pins[0].Metadata["project"] != "x" {
+			t.Error("unexpected pins:", pins)
+		}
+
+		errResp := api.Error{}
+		test.MakeDelete(t, rest, url(rest)+"/pins", &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("expected a bad request without selector:", errResp.Code)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIRecoverMatchingEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var gpis []*api.GlobalPinInfo
+		test.MakePost(t, rest, url(rest)+"/pins/recover?meta.project=x", []byte{}, &gpis)
+		if len(gpis) != 1 || !gpis[0].Cid.Equals(clustertest.Cid1) {
+			t.Error("unexpected statuses:", gpis)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIReplicateMatchingEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var pins []*api.Pin
+		test.MakePost(t, rest, url(rest)+"/pins/replicate?meta.project=x&replication=3", []byte{}, &pins)
+		if len(pins) != 1 || pins[0].ReplicationFactorMin != 3 || pins[0].ReplicationFactorMax != 3 {
+			t.Error("unexpected pins:", pins)
+		}
+
+		errResp := api.Error{}
+		test.MakePost(t, rest, url(rest)+"/pins/replicate?meta.project=x", []byte{}, &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("expected a bad request without replication factors:", errResp.Code)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPITenancyBulk(t *testing.T) {
+	ctx := context.Background()
+	rest := testTenancyAPI(t)
+	defer rest.Shutdown(ctx)
+
+	var pins []*api.Pin
+	status := tenancyRequest(t, rest, "DELETE", "/pins?meta.project=x", clustertest.Namespace1, validUserPassword, &pins)
+	if status != http.StatusOK {
+		t.Fatal("unexpected status:", status)
+	}
+	if len(pins) != 1 || pins[0].Namespace != clustertest.Namespace1 {
+		t.Error("expected the selection to be limited to the namespace:", pins)
+	}
+
+	// Recovering everything is still for admins only.
+	status = tenancyRequest(t, rest, "POST", "/pins/recover", clustertest.Namespace1, validUserPassword, nil)
+	if status != http.StatusForbidden {
+		t.Error("unexpected status:", status)
+	}
+	status = tenancyRequest(t, rest, "POST", "/pins/recover?meta.project=x", clustertest.Namespace1, validUserPassword, nil)
+	if status != http.StatusOK {
+		t.Error("unexpected status:", status)
+	}
+}
@@ -6,6 +6,7 @@ package client
 import (
 	"context"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"time"
@@ -36,6 +37,11 @@ var (
 	DefaultProxyPort = 9095
 	ResolveTimeout   = 30 * time.Second
 	DefaultPort      = 9094
+	// DefaultRetryBackoff is used when Config.Retries is set without a
+	// Config.RetryBackoff.
+	DefaultRetryBackoff = 250 * time.Millisecond
+	// MaxRetryBackoff caps the delay between retries.
+	MaxRetryBackoff = 10 * time.Second
 )
 
 var loggingFacility = "apiclient"
@@ -52,10 +58,21 @@ type Client interface {
 
 	// Peers requests ID information for all cluster peers.
 	Peers(context.Context) ([]*api.ID, error)
+	// Peer requests ID information for the peer with the given peer ID or
+	// peername.
+	Peer(ctx context.Context, name string) (*api.ID, error)
+	// PeerVersions returns the versions run by the cluster peers.
+	PeerVersions(ctx context.Context) (*api.PeerVersions, error)
 	// PeerAdd adds a new peer to the cluster.
 	PeerAdd(ctx context.Context, pid peer.ID) (*api.ID, error)
+	// PeerResources returns the resources used by the peer with the
+	// given peer ID or peername and by its IPFS daemon.
+	PeerResources(ctx context.Context, name string) (*api.PeerResources, error)
 	// PeerRm removes a current peer from the cluster
 	PeerRm(ctx context.Context, pid peer.ID) error
+	// PeerHandover transfers the allocations of a peer to a different
+	// one, as after rotating the identity of a peer.
+	PeerHandover(ctx context.Context, from, to peer.ID) (*api.PeerHandover, error)
 
 	// Add imports files to the cluster from the given paths.
 	Add(ctx context.Context, paths []string, params *api.AddParams, out chan<- *api.AddedOutput) error
@@ -65,11 +82,66 @@ type Client interface {
 	// Pin tracks a Cid with the given replication factor and a name for
 	// human-friendliness.
 	Pin(ctx context.Context, ci cid.Cid, opts api.PinOptions) (*api.Pin, error)
+	// PinDryRun validates and allocates a CID with the given options
+	// without pinning it, returning the pin that would be committed.
+	PinDryRun(ctx context.Context, ci cid.Cid, opts api.PinOptions) (*api.Pin, error)
 	// Unpin untracks a Cid from cluster.
 	Unpin(ctx context.Context, ci cid.Cid) (*api.Pin, error)
+	// Restore takes an unpinned item out of the trash before its grace
+	// period passes.
+	Restore(ctx context.Context, ci cid.Cid) (*api.Pin, error)
+	// Unprotect clears the protection of a pin so that it can be
+	// unpinned. It requires admin credentials when the API is
+	// multi-tenant.
+	Unprotect(ctx context.Context, ci cid.Cid) (*api.Pin, error)
+
+	// PinClone pins a Cid with the options of the existing pin for the
+	// "from" Cid, and the given name.
+	PinClone(ctx context.Context, ci cid.Cid, from cid.Cid, name string) (*api.Pin, error)
+	// PinWithTemplate pins a Cid with the options of a pin template, and
+	// the given name.
+	PinWithTemplate(ctx context.Context, ci cid.Cid, template string, name string) (*api.Pin, error)
+	// PinTemplates returns the pin templates stored in the cluster.
+	PinTemplates(ctx context.Context) ([]*api.PinTemplate, error)
+	// PinTemplate returns the pin template with the given name.
+	PinTemplate(ctx context.Context, name string) (*api.PinTemplate, error)
+	// PinTemplateSet creates or replaces a pin template. Pins made with
+	// it expire after expireIn, unless it is 0.
+	PinTemplateSet(ctx context.Context, name string, opts api.PinOptions, expireIn time.Duration) (*api.PinTemplate, error)
+	// PinTemplateRm removes a pin template.
+	PinTemplateRm(ctx context.Context, name string) error
+
+	// PinsetSnapshots returns the pinset snapshots taken in the peer.
+	PinsetSnapshots(ctx context.Context) ([]*api.PinsetSnapshot, error)
+	// PinsetSnapshot returns the pinset snapshot with the given name,
+	// including its pins.
+	PinsetSnapshot(ctx context.Context, name string) (*api.PinsetSnapshot, error)
+	// PinsetSnapshotCreate takes a snapshot of the pinset with the given
+	// name.
+	PinsetSnapshotCreate(ctx context.Context, name string) (*api.PinsetSnapshot, error)
+	// PinsetSnapshotRm removes a pinset snapshot.
+	PinsetSnapshotRm(ctx context.Context, name string) error
+	// PinsetRollback pins and unpins items until the pinset matches the
+	// snapshot with the given name. With dryRun, the changes are only
+	// returned.
+	PinsetRollback(ctx context.Context, name string, dryRun bool) (*api.PinsetRollback, error)
+
+	// Denylist returns the denylist entries of the peer.
+	Denylist(ctx context.Context) ([]*api.DenylistEntry, error)
+	// DenylistAdd adds rules to the denylist of the peer.
+	DenylistAdd(ctx context.Context, rules []string) error
+	// DenylistRm removes a rule from the denylist of the peer.
+	DenylistRm(ctx context.Context, rule string) error
+	// DenylistEnforce unpins the content matching the denylist of the
+	// peer and returns the unpinned pins.
+	DenylistEnforce(ctx context.Context) ([]*api.Pin, error)
 
 	// PinPath resolves given path into a cid and performs the pin operation.
 	PinPath(ctx context.Context, path string, opts api.PinOptions) (*api.Pin, error)
+	// PinUpdate pins to (a CID or an IPFS path) with the options and
+	// allocations of the existing pin from (a CID or an IPFS path), so
+	// that peers only fetch the blocks which changed.
+	PinUpdate(ctx context.Context, from, to string, opts api.PinOptions) (*api.Pin, error)
 	// UnpinPath resolves given path into a cid and performs the unpin operation.
 	// It returns api.Pin of the given cid before it is unpinned.
 	UnpinPath(ctx context.Context, path string) (*api.Pin, error)
@@ -79,13 +151,28 @@ type Client interface {
 	Allocations(ctx context.Context, filter api.PinType) ([]*api.Pin, error)
 	// Allocation returns the current allocations for a given Cid.
 	Allocation(ctx context.Context, ci cid.Cid) (*api.Pin, error)
+	// PinsByName returns the pins with the given name or, when prefix is
+	// true, with names starting with the given string.
+	PinsByName(ctx context.Context, name string, prefix bool) ([]*api.Pin, error)
+	// PinChanges returns the changes to the pinset after the given
+	// sequence number. A since of 0 returns only the current sequence
+	// number. Sequence numbers are specific to the peer answering the
+	// request.
+	PinChanges(ctx context.Context, since uint64) (*api.PinChanges, error)
 
 	// Status returns the current ipfs state for a given Cid. If local is true,
 	// the information affects only the current peer, otherwise the information
 	// is fetched from all cluster peers.
 	Status(ctx context.Context, ci cid.Cid, local bool) (*api.GlobalPinInfo, error)
+	// StatusCids returns the status of the given Cids, in the same order,
+	// with a single request.
+	StatusCids(ctx context.Context, cids []cid.Cid, local bool) ([]*api.GlobalPinInfo, error)
 	// StatusAll gathers Status() for all tracked items.
 	StatusAll(ctx context.Context, filter api.TrackerStatus, local bool) ([]*api.GlobalPinInfo, error)
+	// StatusAllStream is like StatusAll, but sends the items to the given
+	// channel as they are decoded, without holding the whole response in
+	// memory. The channel is closed when done.
+	StatusAllStream(ctx context.Context, filter api.TrackerStatus, local bool, out chan<- *api.GlobalPinInfo) error
 
 	// Recover retriggers pin or unpin ipfs operations for a Cid in error
 	// state.  If local is true, the operation is limited to the current
@@ -96,9 +183,48 @@ type Client interface {
 	// Otherwise, it happens everywhere.
 	RecoverAll(ctx context.Context, local bool) ([]*api.GlobalPinInfo, error)
 
+	// UnpinMatching unpins all the pins selected by their metadata.
+	UnpinMatching(ctx context.Context, sel api.PinSelector) ([]*api.Pin, error)
+	// RecoverMatching triggers Recover() operations on the pins selected
+	// by their metadata.
+	RecoverMatching(ctx context.Context, sel api.PinSelector) ([]*api.GlobalPinInfo, error)
+	// ReplicateMatching sets the replication factors of the pins
+	// selected by their metadata.
+	ReplicateMatching(ctx context.Context, r api.BulkReplication) ([]*api.Pin, error)
+
+	// Reshard re-adds the content of an existing pin with the given
+	// parameters and replaces the pin with the resulting one. The reshard
+	// runs in the background and the returned operation can be used to
+	// follow it.
+	Reshard(ctx context.Context, ci cid.Cid, params *api.AddParams) (*api.Operation, error)
+
+	// Shards returns the shards of a sharded pin along with their status.
+	Shards(ctx context.Context, ci cid.Cid) ([]*api.ShardInfo, error)
+	// RecoverShard triggers Recover() for one of the shards of a sharded
+	// pin, on every cluster peer.
+	RecoverShard(ctx context.Context, ci cid.Cid, shard cid.Cid) (*api.GlobalPinInfo, error)
+	// Reconstruct rebuilds the content of an erasure-coded pin in the
+	// IPFS daemon of the peer.
+	Reconstruct(ctx context.Context, ci cid.Cid) (*api.ErasureReconstruction, error)
+	// Decrypt writes the decrypted content of the file at the given path
+	// of an encrypted pin. The key is only needed for content encrypted
+	// with a key supplied by the user.
+	Decrypt(ctx context.Context, ci cid.Cid, path string, key []byte, w io.Writer) error
+
 	// Alerts returns information health events in the cluster (expired
 	// metrics etc.).
 	Alerts(ctx context.Context) ([]*api.Alert, error)
+	// StartupWarmup returns the progress of the reconciliation of the
+	// pinset with IPFS that the peer does when it starts.
+	StartupWarmup(ctx context.Context) (*api.StartupWarmup, error)
+	// LifecycleEvents returns the lifecycle events of the peer after the
+	// given sequence number. Sequence numbers are specific to the peer
+	// answering the request.
+	LifecycleEvents(ctx context.Context, since uint64) (*api.LifecycleEvents, error)
+	// ConsensusStats returns the internals of the consensus component of
+	// every peer, like the Raft indexes or the CRDT heads, and how far
+	// behind each peer is.
+	ConsensusStats(ctx context.Context) ([]*api.ConsensusStats, error)
 
 	// Version returns the ipfs-cluster peer's version.
 	Version(context.Context) (*api.Version, error)
@@ -110,6 +236,11 @@ type Client interface {
 	// GetConnectGraph returns an ipfs-cluster connection graph.
 	GetConnectGraph(context.Context) (*api.ConnectGraph, error)
 
+	// ConnectivityHistory returns the connectivity snapshots recorded by
+	// the contacted peer since the given time. A zero time returns all
+	// of them.
+	ConnectivityHistory(ctx context.Context, since time.Time) ([]*api.ConnectivitySnapshot, error)
+
 	// Metrics returns a map with the latest metrics of matching name
 	// for the current cluster peers.
 	Metrics(ctx context.Context, name string) ([]*api.Metric, error)
@@ -117,10 +248,64 @@ type Client interface {
 	// MetricNames returns the list of metric types.
 	MetricNames(ctx context.Context) ([]string, error)
 
+	// DetectorState returns the state of the failure detector for the
+	// latest metrics of every peer, as seen by the contacted peer.
+	DetectorState(ctx context.Context) ([]*api.DetectorState, error)
+
 	// RepoGC runs garbage collection on IPFS daemons of cluster peers and
 	// returns collected CIDs. If local is true, it would garbage collect
 	// only on contacted peer, otherwise on all peers' IPFS daemons.
 	RepoGC(ctx context.Context, local bool) (*api.GlobalRepoGC, error)
+	// Audit asks the peers a pin is allocated to to verify the blocks of
+	// its DAG in their IPFS daemons. When repair is true, corrupted
+	// blocks are removed and fetched again.
+	Audit(ctx context.Context, ci cid.Cid, repair bool) (*api.GlobalDAGAudit, error)
+	// ConsistencyCheck cross-checks the shared state, the pin trackers
+	// and the IPFS pins of all peers. When repair is true, the issues
+	// found are fixed.
+	ConsistencyCheck(ctx context.Context, repair bool) (*api.GlobalConsistencyReport, error)
+
+	// Operations returns the long-running operations (i.e. RecoverAll,
+	// RepoGC) started in the contacted peer.
+	Operations(ctx context.Context) ([]*api.Operation, error)
+	// Operation returns the operation with the given ID.
+	Operation(ctx context.Context, id string) (*api.Operation, error)
+	// CancelOperation cancels a running operation in the contacted peer.
+	CancelOperation(ctx context.Context, id string) (*api.Operation, error)
+
+	// RPCPolicy returns the RPC authorization policy of the contacted
+	// peer, as a map of RPC method names to endpoint types ("open",
+	// "trusted" or "closed").
+	RPCPolicy(ctx context.Context) (map[string]string, error)
+
+	// SetRPCPolicy changes the endpoint types of the given RPC methods in
+	// the contacted peer and returns the resulting policy. Changes are not
+	// persisted to the configuration.
+	SetRPCPolicy(ctx context.Context, changes map[string]string) (map[string]string, error)
+
+	// RotateSecret replaces the cluster secret in all peers. A new secret
+	// is generated when the given one is empty. The previous secret is
+	// still accepted during a transition window.
+	RotateSecret(ctx context.Context, secret string) (*api.SecretRotation, error)
+
+	// TrackerSettings returns the runtime settings of the pin tracker of
+	// the contacted peer.
+	TrackerSettings(ctx context.Context) (*api.TrackerSettings, error)
+
+	// SetTrackerSettings changes the runtime settings of the pin tracker
+	// of the contacted peer and returns the resulting ones. Zero values
+	// are left unchanged. Changes are not persisted to the configuration.
+	SetTrackerSettings(ctx context.Context, changes *api.TrackerSettings) (*api.TrackerSettings, error)
+
+	// APIListeners returns the listener settings of the APIs of the
+	// contacted peer which can be changed at runtime.
+	APIListeners(ctx context.Context) ([]*api.APIListeners, error)
+
+	// RebindAPI replaces the listen addresses, TLS certificate and CORS
+	// options of one of the APIs of the contacted peer without restarting
+	// it, and returns the resulting settings. The settings are saved to
+	// the configuration.
+	RebindAPI(ctx context.Context, settings *api.APIListeners) (*api.APIListeners, error)
 }
 
 // Config allows to configure the parameters to connect
@@ -165,6 +350,16 @@ type Config struct {
 	// hosts.
 	DisableKeepAlives bool
 
+	// Retries is the number of times a request is retried when it does
+	// not reach the API or the API is temporarily unavailable (see
+	// IsRetryable). Requests with a body, like adding content, are never
+	// retried. With a load-balancing client, the retries happen on every
+	// endpoint before failing over to the next one.
+	Retries int
+	// RetryBackoff is the time to wait before the first retry. It is
+	// doubled for every following one, up to MaxRetryBackoff.
+	RetryBackoff time.Duration
+
 	// LogLevel defines the verbosity of the logging facility
 	LogLevel string
 }
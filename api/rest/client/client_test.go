@@ -3,8 +3,14 @@ package client
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
 
 	"github.com/ipfs/ipfs-cluster/api/rest"
 	"github.com/ipfs/ipfs-cluster/test"
@@ -17,12 +23,16 @@ import (
 )
 
 func testAPI(t *testing.T) *rest.API {
+	cfg := rest.NewConfig()
+	cfg.Default()
+	return testAPIWithConfig(t, cfg)
+}
+
+func testAPIWithConfig(t *testing.T, cfg *rest.Config) *rest.API {
 	ctx := context.Background()
 	//logging.SetDebugLogging()
 	apiMAddr, _ := ma.NewMultiaddr("/ip4/127.0.0.1/tcp/0")
 
-	cfg := rest.NewConfig()
-	cfg.Default()
 	cfg.HTTPListenAddr = []ma.Multiaddr{apiMAddr}
 	secret := make(pnet.PSK, 32)
 
@@ -305,3 +315,66 @@ func TestIPFS(t *testing.T) {
 		t.Error("pin type unexpected")
 	}
 }
+
+func TestRetries(t *testing.T) {
+	ctx := context.Background()
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"code":503,"message":"busy"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"version":"1.0.0"}`))
+	}))
+	defer s.Close()
+
+	newClient := func(retries int) Client {
+		c, err := NewDefaultClient(&Config{
+			APIAddr:           ma.StringCast("/ip4/127.0.0.1/tcp/" + s.URL[strings.LastIndex(s.URL, ":")+1:]),
+			DisableKeepAlives: true,
+			Retries:           retries,
+			RetryBackoff:      time.Millisecond,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	_, err := newClient(1).Version(ctx)
+	if !IsRetryable(err) || ErrorCode(err) != http.StatusServiceUnavailable {
+		t.Fatal("expected a retryable error:", err)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	v, err := newClient(2).Version(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Version != "1.0.0" || atomic.LoadInt32(&calls) != 3 {
+		t.Error("expected success on the third attempt")
+	}
+}
+
+func TestErrorHelpers(t *testing.T) {
+	notFound := &api.Error{Code: http.StatusNotFound}
+	if !IsNotFound(notFound) || IsRetryable(notFound) || IsBadRequest(notFound) {
+		t.Error("bad classification for 404")
+	}
+	if !IsUnauthorized(&api.Error{Code: http.StatusForbidden}) {
+		t.Error("403 should be unauthorized")
+	}
+	if !IsRetryable(&api.Error{Code: 0}) {
+		t.Error("errors without a response should be retryable")
+	}
+	wrapped := fmt.Errorf("wrapped: %w", &api.Error{Code: http.StatusBadRequest})
+	if !IsBadRequest(wrapped) || ErrorCode(wrapped) != http.StatusBadRequest {
+		t.Error("wrapped errors should be classified")
+	}
+	if IsRetryable(fmt.Errorf("other")) || ErrorCode(fmt.Errorf("other")) != 0 {
+		t.Error("other errors are not retryable")
+	}
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ipfs/ipfs-cluster/api"
+)
+
+// All the errors returned by the client methods are *api.Error, with the
+// HTTP status code of the response, or 0 when the request did not reach the
+// API. These helpers allow checking them without inspecting the codes
+// directly.
+
+// ErrorCode returns the HTTP status code of an error returned by the
+// client. It returns 0 when the request did not obtain a response, or when
+// the error was not produced by the client.
+func ErrorCode(err error) int {
+	var apiErr *api.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return 0
+}
+
+// IsNotFound returns true when the requested item does not exist.
+func IsNotFound(err error) bool {
+	return ErrorCode(err) == http.StatusNotFound
+}
+
+// IsUnauthorized returns true when the credentials were rejected or are
+// not allowed to perform the request.
+func IsUnauthorized(err error) bool {
+	code := ErrorCode(err)
+	return code == http.StatusUnauthorized || code == http.StatusForbidden
+}
+
+// IsBadRequest returns true when the API rejected the parameters of the
+// request.
+func IsBadRequest(err error) bool {
+	return ErrorCode(err) == http.StatusBadRequest
+}
+
+// IsRetryable returns true when the request may succeed if it is retried:
+// it did not reach the API, or the API is overloaded or temporarily
+// unavailable.
+func IsRetryable(err error) bool {
+	var apiErr *api.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.Code {
+	case 0,
+		http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
@@ -2,7 +2,9 @@ package client
 
 import (
 	"context"
+	"io"
 	"sync/atomic"
+	"time"
 
 	cid "github.com/ipfs/go-cid"
 	shell "github.com/ipfs/go-ipfs-api"
@@ -135,6 +137,33 @@ func (lc *loadBalancingClient) Peers(ctx context.Context) ([]*api.ID, error) {
 	return peers, err
 }
 
+// Peer requests ID information for the peer with the given peer ID or
+// peername.
+func (lc *loadBalancingClient) Peer(ctx context.Context, name string) (*api.ID, error) {
+	var id *api.ID
+	call := func(c Client) error {
+		var err error
+		id, err = c.Peer(ctx, name)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return id, err
+}
+
+// PeerVersions returns the versions run by the cluster peers.
+func (lc *loadBalancingClient) PeerVersions(ctx context.Context) (*api.PeerVersions, error) {
+	var pvs *api.PeerVersions
+	call := func(c Client) error {
+		var err error
+		pvs, err = c.PeerVersions(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return pvs, err
+}
+
 // PeerAdd adds a new peer to the cluster.
 func (lc *loadBalancingClient) PeerAdd(ctx context.Context, pid peer.ID) (*api.ID, error) {
 	var id *api.ID
@@ -148,6 +177,33 @@ func (lc *loadBalancingClient) PeerAdd(ctx context.Context, pid peer.ID) (*api.I
 	return id, err
 }
 
+// PeerResources returns the resources used by a peer and by its IPFS
+// daemon.
+func (lc *loadBalancingClient) PeerResources(ctx context.Context, name string) (*api.PeerResources, error) {
+	var res *api.PeerResources
+	call := func(c Client) error {
+		var err error
+		res, err = c.PeerResources(ctx, name)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return res, err
+}
+
+// PeerHandover transfers the allocations of a peer to a different one.
+func (lc *loadBalancingClient) PeerHandover(ctx context.Context, from, to peer.ID) (*api.PeerHandover, error) {
+	var handover *api.PeerHandover
+	call := func(c Client) error {
+		var err error
+		handover, err = c.PeerHandover(ctx, from, to)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return handover, err
+}
+
 // PeerRm removes a current peer from the cluster.
 func (lc *loadBalancingClient) PeerRm(ctx context.Context, id peer.ID) error {
 	call := func(c Client) error {
@@ -171,6 +227,213 @@ func (lc *loadBalancingClient) Pin(ctx context.Context, ci cid.Cid, opts api.Pin
 	return pin, err
 }
 
+// PinDryRun validates and allocates a CID without pinning it.
+func (lc *loadBalancingClient) PinDryRun(ctx context.Context, ci cid.Cid, opts api.PinOptions) (*api.Pin, error) {
+	var pin *api.Pin
+	call := func(c Client) error {
+		var err error
+		pin, err = c.PinDryRun(ctx, ci, opts)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return pin, err
+}
+
+// PinClone pins a Cid with the options of an existing pin.
+func (lc *loadBalancingClient) PinClone(ctx context.Context, ci cid.Cid, from cid.Cid, name string) (*api.Pin, error) {
+	var pin *api.Pin
+	call := func(c Client) error {
+		var err error
+		pin, err = c.PinClone(ctx, ci, from, name)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return pin, err
+}
+
+// PinWithTemplate pins a Cid with the options of a pin template.
+func (lc *loadBalancingClient) PinWithTemplate(ctx context.Context, ci cid.Cid, template string, name string) (*api.Pin, error) {
+	var pin *api.Pin
+	call := func(c Client) error {
+		var err error
+		pin, err = c.PinWithTemplate(ctx, ci, template, name)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return pin, err
+}
+
+// PinTemplates returns the pin templates stored in the cluster.
+func (lc *loadBalancingClient) PinTemplates(ctx context.Context) ([]*api.PinTemplate, error) {
+	var tmpls []*api.PinTemplate
+	call := func(c Client) error {
+		var err error
+		tmpls, err = c.PinTemplates(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return tmpls, err
+}
+
+// PinTemplate returns the pin template with the given name.
+func (lc *loadBalancingClient) PinTemplate(ctx context.Context, name string) (*api.PinTemplate, error) {
+	var tmpl *api.PinTemplate
+	call := func(c Client) error {
+		var err error
+		tmpl, err = c.PinTemplate(ctx, name)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return tmpl, err
+}
+
+// PinTemplateSet creates or replaces a pin template.
+func (lc *loadBalancingClient) PinTemplateSet(ctx context.Context, name string, opts api.PinOptions, expireIn time.Duration) (*api.PinTemplate, error) {
+	var tmpl *api.PinTemplate
+	call := func(c Client) error {
+		var err error
+		tmpl, err = c.PinTemplateSet(ctx, name, opts, expireIn)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return tmpl, err
+}
+
+// PinTemplateRm removes a pin template.
+func (lc *loadBalancingClient) PinTemplateRm(ctx context.Context, name string) error {
+	call := func(c Client) error {
+		return c.PinTemplateRm(ctx, name)
+	}
+
+	return lc.retry(0, call)
+}
+
+// ConsistencyCheck cross-checks the shared state, the pin trackers and the
+// IPFS pins of all peers.
+func (lc *loadBalancingClient) ConsistencyCheck(ctx context.Context, repair bool) (*api.GlobalConsistencyReport, error) {
+	var report *api.GlobalConsistencyReport
+	call := func(c Client) error {
+		var err error
+		report, err = c.ConsistencyCheck(ctx, repair)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return report, err
+}
+
+// PinsetSnapshots returns the pinset snapshots taken in the peer.
+func (lc *loadBalancingClient) PinsetSnapshots(ctx context.Context) ([]*api.PinsetSnapshot, error) {
+	var snaps []*api.PinsetSnapshot
+	call := func(c Client) error {
+		var err error
+		snaps, err = c.PinsetSnapshots(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return snaps, err
+}
+
+// PinsetSnapshot returns the pinset snapshot with the given name.
+func (lc *loadBalancingClient) PinsetSnapshot(ctx context.Context, name string) (*api.PinsetSnapshot, error) {
+	var snap *api.PinsetSnapshot
+	call := func(c Client) error {
+		var err error
+		snap, err = c.PinsetSnapshot(ctx, name)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return snap, err
+}
+
+// PinsetSnapshotCreate takes a snapshot of the pinset.
+func (lc *loadBalancingClient) PinsetSnapshotCreate(ctx context.Context, name string) (*api.PinsetSnapshot, error) {
+	var snap *api.PinsetSnapshot
+	call := func(c Client) error {
+		var err error
+		snap, err = c.PinsetSnapshotCreate(ctx, name)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return snap, err
+}
+
+// PinsetSnapshotRm removes a pinset snapshot.
+func (lc *loadBalancingClient) PinsetSnapshotRm(ctx context.Context, name string) error {
+	call := func(c Client) error {
+		return c.PinsetSnapshotRm(ctx, name)
+	}
+
+	return lc.retry(0, call)
+}
+
+// PinsetRollback rolls the pinset back to a snapshot.
+func (lc *loadBalancingClient) PinsetRollback(ctx context.Context, name string, dryRun bool) (*api.PinsetRollback, error) {
+	var res *api.PinsetRollback
+	call := func(c Client) error {
+		var err error
+		res, err = c.PinsetRollback(ctx, name, dryRun)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return res, err
+}
+
+// Denylist returns the denylist entries of the peer.
+func (lc *loadBalancingClient) Denylist(ctx context.Context) ([]*api.DenylistEntry, error) {
+	var entries []*api.DenylistEntry
+	call := func(c Client) error {
+		var err error
+		entries, err = c.Denylist(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return entries, err
+}
+
+// DenylistAdd adds rules to the denylist of the peer.
+func (lc *loadBalancingClient) DenylistAdd(ctx context.Context, rules []string) error {
+	call := func(c Client) error {
+		return c.DenylistAdd(ctx, rules)
+	}
+
+	return lc.retry(0, call)
+}
+
+// DenylistRm removes a rule from the denylist of the peer.
+func (lc *loadBalancingClient) DenylistRm(ctx context.Context, rule string) error {
+	call := func(c Client) error {
+		return c.DenylistRm(ctx, rule)
+	}
+
+	return lc.retry(0, call)
+}
+
+// DenylistEnforce unpins the content matching the denylist of the peer and
+// returns the unpinned pins.
+func (lc *loadBalancingClient) DenylistEnforce(ctx context.Context) ([]*api.Pin, error) {
+	var pins []*api.Pin
+	call := func(c Client) error {
+		var err error
+		pins, err = c.DenylistEnforce(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return pins, err
+}
+
 // Unpin untracks a Cid from cluster.
 func (lc *loadBalancingClient) Unpin(ctx context.Context, ci cid.Cid) (*api.Pin, error) {
 	var pin *api.Pin
@@ -184,6 +447,32 @@ func (lc *loadBalancingClient) Unpin(ctx context.Context, ci cid.Cid) (*api.Pin,
 	return pin, err
 }
 
+// Restore takes an unpinned item out of the trash.
+func (lc *loadBalancingClient) Restore(ctx context.Context, ci cid.Cid) (*api.Pin, error) {
+	var pin *api.Pin
+	call := func(c Client) error {
+		var err error
+		pin, err = c.Restore(ctx, ci)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return pin, err
+}
+
+// Unprotect clears the protection of a pin so that it can be unpinned.
+func (lc *loadBalancingClient) Unprotect(ctx context.Context, ci cid.Cid) (*api.Pin, error) {
+	var pin *api.Pin
+	call := func(c Client) error {
+		var err error
+		pin, err = c.Unprotect(ctx, ci)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return pin, err
+}
+
 // PinPath allows to pin an element by the given IPFS path.
 func (lc *loadBalancingClient) PinPath(ctx context.Context, path string, opts api.PinOptions) (*api.Pin, error) {
 	var pin *api.Pin
@@ -197,6 +486,20 @@ func (lc *loadBalancingClient) PinPath(ctx context.Context, path string, opts ap
 	return pin, err
 }
 
+// PinUpdate pins to (a CID or an IPFS path) with the options and
+// allocations of the existing pin from (a CID or an IPFS path).
+func (lc *loadBalancingClient) PinUpdate(ctx context.Context, from, to string, opts api.PinOptions) (*api.Pin, error) {
+	var pin *api.Pin
+	call := func(c Client) error {
+		var err error
+		pin, err = c.PinUpdate(ctx, from, to, opts)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return pin, err
+}
+
 // UnpinPath allows to unpin an item by providing its IPFS path.
 // It returns the unpinned api.Pin information of the resolved Cid.
 func (lc *loadBalancingClient) UnpinPath(ctx context.Context, p string) (*api.Pin, error) {
@@ -238,6 +541,35 @@ func (lc *loadBalancingClient) Allocation(ctx context.Context, ci cid.Cid) (*api
 	return pin, err
 }
 
+// PinsByName returns the pins with the given name or, when prefix is true,
+// with names starting with the given string.
+func (lc *loadBalancingClient) PinsByName(ctx context.Context, name string, prefix bool) ([]*api.Pin, error) {
+	var pins []*api.Pin
+	call := func(c Client) error {
+		var err error
+		pins, err = c.PinsByName(ctx, name, prefix)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return pins, err
+}
+
+// PinChanges returns the changes to the pinset after the given sequence
+// number. As sequence numbers are specific to every peer, retries on other
+// peers will usually fail.
+func (lc *loadBalancingClient) PinChanges(ctx context.Context, since uint64) (*api.PinChanges, error) {
+	var changes *api.PinChanges
+	call := func(c Client) error {
+		var err error
+		changes, err = c.PinChanges(ctx, since)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return changes, err
+}
+
 // Status returns the current ipfs state for a given Cid. If local is true,
 // the information affects only the current peer, otherwise the information
 // is fetched from all cluster peers.
@@ -253,6 +585,20 @@ func (lc *loadBalancingClient) Status(ctx context.Context, ci cid.Cid, local boo
 	return pinInfo, err
 }
 
+// StatusCids returns the status of the given Cids, in the same order, with
+// a single request.
+func (lc *loadBalancingClient) StatusCids(ctx context.Context, cids []cid.Cid, local bool) ([]*api.GlobalPinInfo, error) {
+	var pinInfos []*api.GlobalPinInfo
+	call := func(c Client) error {
+		var err error
+		pinInfos, err = c.StatusCids(ctx, cids, local)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return pinInfos, err
+}
+
 // StatusAll gathers Status() for all tracked items. If a filter is
 // provided, only entries matching the given filter statuses
 // will be returned. A filter can be built by merging TrackerStatuses with
@@ -270,6 +616,29 @@ func (lc *loadBalancingClient) StatusAll(ctx context.Context, filter api.Tracker
 	return pinInfos, err
 }
 
+// StatusAllStream is like StatusAll but sends the items to the out channel
+// as they are decoded. The channel is closed when done.
+func (lc *loadBalancingClient) StatusAllStream(ctx context.Context, filter api.TrackerStatus, local bool, out chan<- *api.GlobalPinInfo) error {
+	defer close(out)
+
+	// Every attempt closes its own channel.
+	call := func(c Client) error {
+		ch := make(chan *api.GlobalPinInfo, 1)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for gpi := range ch {
+				out <- gpi
+			}
+		}()
+		err := c.StatusAllStream(ctx, filter, local, ch)
+		<-done
+		return err
+	}
+
+	return lc.retry(0, call)
+}
+
 // Recover retriggers pin or unpin ipfs operations for a Cid in error state.
 // If local is true, the operation is limited to the current peer, otherwise
 // it happens on every cluster peer.
@@ -285,6 +654,113 @@ func (lc *loadBalancingClient) Recover(ctx context.Context, ci cid.Cid, local bo
 	return pinInfo, err
 }
 
+// Reshard re-adds the content of an existing pin with the given parameters
+// and replaces the pin with the resulting one. The reshard runs in the
+// background and the returned operation can be used to follow it.
+func (lc *loadBalancingClient) Reshard(ctx context.Context, ci cid.Cid, params *api.AddParams) (*api.Operation, error) {
+	var op *api.Operation
+	call := func(c Client) error {
+		var err error
+		op, err = c.Reshard(ctx, ci, params)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return op, err
+}
+
+// Shards returns the shards of a sharded pin along with their status.
+func (lc *loadBalancingClient) Shards(ctx context.Context, ci cid.Cid) ([]*api.ShardInfo, error) {
+	var shards []*api.ShardInfo
+	call := func(c Client) error {
+		var err error
+		shards, err = c.Shards(ctx, ci)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return shards, err
+}
+
+// RecoverShard triggers Recover() for one of the shards of a sharded pin, on
+// every cluster peer.
+func (lc *loadBalancingClient) RecoverShard(ctx context.Context, ci cid.Cid, shard cid.Cid) (*api.GlobalPinInfo, error) {
+	var pinInfo *api.GlobalPinInfo
+	call := func(c Client) error {
+		var err error
+		pinInfo, err = c.RecoverShard(ctx, ci, shard)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return pinInfo, err
+}
+
+// Reconstruct rebuilds the content of an erasure-coded pin in the IPFS
+// daemon of the peer.
+func (lc *loadBalancingClient) Reconstruct(ctx context.Context, ci cid.Cid) (*api.ErasureReconstruction, error) {
+	var res *api.ErasureReconstruction
+	call := func(c Client) error {
+		var err error
+		res, err = c.Reconstruct(ctx, ci)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return res, err
+}
+
+// Decrypt writes the decrypted content of the file at the given path of an
+// encrypted pin.
+func (lc *loadBalancingClient) Decrypt(ctx context.Context, ci cid.Cid, path string, key []byte, w io.Writer) error {
+	call := func(c Client) error {
+		return c.Decrypt(ctx, ci, path, key, w)
+	}
+
+	return lc.retry(0, call)
+}
+
+// UnpinMatching unpins all the pins selected by their metadata.
+func (lc *loadBalancingClient) UnpinMatching(ctx context.Context, sel api.PinSelector) ([]*api.Pin, error) {
+	var pins []*api.Pin
+	call := func(c Client) error {
+		var err error
+		pins, err = c.UnpinMatching(ctx, sel)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return pins, err
+}
+
+// RecoverMatching triggers Recover() operations on the pins selected by
+// their metadata.
+func (lc *loadBalancingClient) RecoverMatching(ctx context.Context, sel api.PinSelector) ([]*api.GlobalPinInfo, error) {
+	var pinInfos []*api.GlobalPinInfo
+	call := func(c Client) error {
+		var err error
+		pinInfos, err = c.RecoverMatching(ctx, sel)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return pinInfos, err
+}
+
+// ReplicateMatching sets the replication factors of the pins selected by
+// their metadata.
+func (lc *loadBalancingClient) ReplicateMatching(ctx context.Context, r api.BulkReplication) ([]*api.Pin, error) {
+	var pins []*api.Pin
+	call := func(c Client) error {
+		var err error
+		pins, err = c.ReplicateMatching(ctx, r)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return pins, err
+}
+
 // RecoverAll triggers Recover() operations on all tracked items. If local is
 // true, the operation is limited to the current peer. Otherwise, it happens
 // everywhere.
@@ -313,6 +789,48 @@ func (lc *loadBalancingClient) Alerts(ctx context.Context) ([]*api.Alert, error)
 	return alerts, err
 }
 
+// StartupWarmup returns the progress of the startup warm-up of a peer.
+func (lc *loadBalancingClient) StartupWarmup(ctx context.Context) (*api.StartupWarmup, error) {
+	var warmup *api.StartupWarmup
+	call := func(c Client) error {
+		var err error
+		warmup, err = c.StartupWarmup(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return warmup, err
+}
+
+// LifecycleEvents returns the lifecycle events of the peer after the given
+// sequence number. As sequence numbers are specific to every peer, retries
+// on other peers return their own events.
+func (lc *loadBalancingClient) LifecycleEvents(ctx context.Context, since uint64) (*api.LifecycleEvents, error) {
+	var events *api.LifecycleEvents
+	call := func(c Client) error {
+		var err error
+		events, err = c.LifecycleEvents(ctx, since)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return events, err
+}
+
+// ConsensusStats returns the internals of the consensus component of every
+// peer.
+func (lc *loadBalancingClient) ConsensusStats(ctx context.Context) ([]*api.ConsensusStats, error) {
+	var stats []*api.ConsensusStats
+	call := func(c Client) error {
+		var err error
+		stats, err = c.ConsensusStats(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return stats, err
+}
+
 // Version returns the ipfs-cluster peer's version.
 func (lc *loadBalancingClient) Version(ctx context.Context) (*api.Version, error) {
 	var v *api.Version
@@ -339,6 +857,22 @@ func (lc *loadBalancingClient) GetConnectGraph(ctx context.Context) (*api.Connec
 	return graph, err
 }
 
+// ConnectivityHistory returns the connectivity snapshots recorded by the
+// contacted peer since the given time. Every peer records its own
+// snapshots, so successive calls may return different histories when
+// the request is retried on a different peer.
+func (lc *loadBalancingClient) ConnectivityHistory(ctx context.Context, since time.Time) ([]*api.ConnectivitySnapshot, error) {
+	var history []*api.ConnectivitySnapshot
+	call := func(c Client) error {
+		var err error
+		history, err = c.ConnectivityHistory(ctx, since)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return history, err
+}
+
 // Metrics returns a map with the latest valid metrics of the given name
 // for the current cluster peers.
 func (lc *loadBalancingClient) Metrics(ctx context.Context, name string) ([]*api.Metric, error) {
@@ -367,6 +901,20 @@ func (lc *loadBalancingClient) MetricNames(ctx context.Context) ([]string, error
 	return metricNames, err
 }
 
+// DetectorState returns the state of the failure detector for the latest
+// metrics of every peer.
+func (lc *loadBalancingClient) DetectorState(ctx context.Context) ([]*api.DetectorState, error) {
+	var states []*api.DetectorState
+	call := func(c Client) error {
+		var err error
+		states, err = c.DetectorState(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return states, err
+}
+
 // RepoGC runs garbage collection on IPFS daemons of cluster peers and
 // returns collected CIDs. If local is true, it would garbage collect
 // only on contacted peer, otherwise on all peers' IPFS daemons.
@@ -383,6 +931,157 @@ func (lc *loadBalancingClient) RepoGC(ctx context.Context, local bool) (*api.Glo
 	return repoGC, err
 }
 
+// Audit verifies the blocks of the DAG of a pin in the IPFS daemons it is
+// allocated to.
+func (lc *loadBalancingClient) Audit(ctx context.Context, ci cid.Cid, repair bool) (*api.GlobalDAGAudit, error) {
+	var audit *api.GlobalDAGAudit
+
+	call := func(c Client) error {
+		var err error
+		audit, err = c.Audit(ctx, ci, repair)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return audit, err
+}
+
+// Operations returns the long-running operations started in the contacted
+// peer.
+func (lc *loadBalancingClient) Operations(ctx context.Context) ([]*api.Operation, error) {
+	var ops []*api.Operation
+	call := func(c Client) error {
+		var err error
+		ops, err = c.Operations(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return ops, err
+}
+
+// Operation returns the operation with the given ID.
+func (lc *loadBalancingClient) Operation(ctx context.Context, id string) (*api.Operation, error) {
+	var op *api.Operation
+	call := func(c Client) error {
+		var err error
+		op, err = c.Operation(ctx, id)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return op, err
+}
+
+// CancelOperation cancels a running operation in the contacted peer.
+func (lc *loadBalancingClient) CancelOperation(ctx context.Context, id string) (*api.Operation, error) {
+	var op *api.Operation
+	call := func(c Client) error {
+		var err error
+		op, err = c.CancelOperation(ctx, id)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return op, err
+}
+
+// RPCPolicy returns the RPC authorization policy of the contacted peer.
+func (lc *loadBalancingClient) RPCPolicy(ctx context.Context) (map[string]string, error) {
+	var policy map[string]string
+	call := func(c Client) error {
+		var err error
+		policy, err = c.RPCPolicy(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return policy, err
+}
+
+// SetRPCPolicy changes the endpoint types of the given RPC methods in the
+// contacted peer and returns the resulting policy.
+func (lc *loadBalancingClient) SetRPCPolicy(ctx context.Context, changes map[string]string) (map[string]string, error) {
+	var policy map[string]string
+	call := func(c Client) error {
+		var err error
+		policy, err = c.SetRPCPolicy(ctx, changes)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return policy, err
+}
+
+// RotateSecret replaces the cluster secret in all peers.
+func (lc *loadBalancingClient) RotateSecret(ctx context.Context, secret string) (*api.SecretRotation, error) {
+	var rot *api.SecretRotation
+	call := func(c Client) error {
+		var err error
+		rot, err = c.RotateSecret(ctx, secret)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return rot, err
+}
+
+// TrackerSettings returns the runtime settings of the pin tracker of the
+// contacted peer.
+func (lc *loadBalancingClient) TrackerSettings(ctx context.Context) (*api.TrackerSettings, error) {
+	var settings *api.TrackerSettings
+	call := func(c Client) error {
+		var err error
+		settings, err = c.TrackerSettings(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return settings, err
+}
+
+// SetTrackerSettings changes the runtime settings of the pin tracker of the
+// contacted peer and returns the resulting ones.
+func (lc *loadBalancingClient) SetTrackerSettings(ctx context.Context, changes *api.TrackerSettings) (*api.TrackerSettings, error) {
+	var settings *api.TrackerSettings
+	call := func(c Client) error {
+		var err error
+		settings, err = c.SetTrackerSettings(ctx, changes)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return settings, err
+}
+
+// APIListeners returns the listener settings of the APIs of the contacted
+// peer which can be changed at runtime.
+func (lc *loadBalancingClient) APIListeners(ctx context.Context) ([]*api.APIListeners, error) {
+	var listeners []*api.APIListeners
+	call := func(c Client) error {
+		var err error
+		listeners, err = c.APIListeners(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return listeners, err
+}
+
+// RebindAPI replaces the listeners of one of the APIs of the contacted peer
+// and returns the resulting settings.
+func (lc *loadBalancingClient) RebindAPI(ctx context.Context, settings *api.APIListeners) (*api.APIListeners, error) {
+	var listeners *api.APIListeners
+	call := func(c Client) error {
+		var err error
+		listeners, err = c.RebindAPI(ctx, settings)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return listeners, err
+}
+
 // Add imports files to the cluster from the given paths. A path can
 // either be a local filesystem location or an web url (http:// or https://).
 // In the latter case, the destination will be downloaded with a GET request.
@@ -3,13 +3,19 @@ package client
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash"
+	"io"
+	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -43,6 +49,27 @@ func (c *defaultClient) Peers(ctx context.Context) ([]*api.ID, error) {
 	return ids, err
 }
 
+// Peer requests ID information for the peer with the given peer ID or
+// peername.
+func (c *defaultClient) Peer(ctx context.Context, name string) (*api.ID, error) {
+	ctx, span := trace.StartSpan(ctx, "client/Peer")
+	defer span.End()
+
+	var id api.ID
+	err := c.do(ctx, "GET", fmt.Sprintf("/peers/%s", url.PathEscape(name)), nil, nil, &id)
+	return &id, err
+}
+
+// PeerVersions returns the versions run by the cluster peers.
+func (c *defaultClient) PeerVersions(ctx context.Context) (*api.PeerVersions, error) {
+	ctx, span := trace.StartSpan(ctx, "client/PeerVersions")
+	defer span.End()
+
+	var pvs api.PeerVersions
+	err := c.do(ctx, "GET", "/peers/versions", nil, nil, &pvs)
+	return &pvs, err
+}
+
 type peerAddBody struct {
 	PeerID string `json:"peer_id"`
 }
@@ -64,6 +91,17 @@ func (c *defaultClient) PeerAdd(ctx context.Context, pid peer.ID) (*api.ID, erro
 	return &id, err
 }
 
+// PeerResources returns the resources used by the peer with the given peer
+// ID or peername and by its IPFS daemon.
+func (c *defaultClient) PeerResources(ctx context.Context, name string) (*api.PeerResources, error) {
+	ctx, span := trace.StartSpan(ctx, "client/PeerResources")
+	defer span.End()
+
+	var res api.PeerResources
+	err := c.do(ctx, "GET", fmt.Sprintf("/peers/%s/resources", url.PathEscape(name)), nil, nil, &res)
+	return &res, err
+}
+
 // PeerRm removes a current peer from the cluster
 func (c *defaultClient) PeerRm(ctx context.Context, id peer.ID) error {
 	ctx, span := trace.StartSpan(ctx, "client/PeerRm")
@@ -72,6 +110,23 @@ func (c *defaultClient) PeerRm(ctx context.Context, id peer.ID) error {
 	return c.do(ctx, "DELETE", fmt.Sprintf("/peers/%s", id.Pretty()), nil, nil, nil)
 }
 
+// PeerHandover transfers the allocations of a peer to a different one.
+func (c *defaultClient) PeerHandover(ctx context.Context, from, to peer.ID) (*api.PeerHandover, error) {
+	ctx, span := trace.StartSpan(ctx, "client/PeerHandover")
+	defer span.End()
+
+	var handover api.PeerHandover
+	err := c.do(
+		ctx,
+		"POST",
+		fmt.Sprintf("/peers/%s/handover?to=%s", from.Pretty(), to.Pretty()),
+		nil,
+		nil,
+		&handover,
+	)
+	return &handover, err
+}
+
 // Pin tracks a Cid with the given replication factor and a name for
 // human-friendliness.
 func (c *defaultClient) Pin(ctx context.Context, ci cid.Cid, opts api.PinOptions) (*api.Pin, error) {
@@ -101,6 +156,238 @@ func (c *defaultClient) Pin(ctx context.Context, ci cid.Cid, opts api.PinOptions
 	return &pin, nil
 }
 
+// PinDryRun validates and allocates a CID with the given options without
+// pinning it. The returned pin shows the peers that would be allocated.
+func (c *defaultClient) PinDryRun(ctx context.Context, ci cid.Cid, opts api.PinOptions) (*api.Pin, error) {
+	ctx, span := trace.StartSpan(ctx, "client/PinDryRun")
+	defer span.End()
+
+	query, err := opts.ToQuery()
+	if err != nil {
+		return nil, err
+	}
+	var pin api.Pin
+	err = c.do(
+		ctx,
+		"POST",
+		fmt.Sprintf(
+			"/pins/%s?%s&dry-run=true",
+			ci.String(),
+			query,
+		),
+		nil,
+		nil,
+		&pin,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &pin, nil
+}
+
+// PinClone pins a Cid with the options of the existing pin for the "from"
+// Cid, and the given name.
+func (c *defaultClient) PinClone(ctx context.Context, ci cid.Cid, from cid.Cid, name string) (*api.Pin, error) {
+	ctx, span := trace.StartSpan(ctx, "client/PinClone")
+	defer span.End()
+
+	var pin api.Pin
+	err := c.do(
+		ctx,
+		"POST",
+		fmt.Sprintf("/pins/%s/clone?from=%s&name=%s", ci, from, url.QueryEscape(name)),
+		nil,
+		nil,
+		&pin,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &pin, nil
+}
+
+// PinWithTemplate pins a Cid with the options of a pin template, and the
+// given name.
+func (c *defaultClient) PinWithTemplate(ctx context.Context, ci cid.Cid, template string, name string) (*api.Pin, error) {
+	ctx, span := trace.StartSpan(ctx, "client/PinWithTemplate")
+	defer span.End()
+
+	var pin api.Pin
+	err := c.do(
+		ctx,
+		"POST",
+		fmt.Sprintf("/pins/%s/clone?template=%s&name=%s", ci, url.QueryEscape(template), url.QueryEscape(name)),
+		nil,
+		nil,
+		&pin,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &pin, nil
+}
+
+// PinTemplates returns the pin templates stored in the cluster.
+func (c *defaultClient) PinTemplates(ctx context.Context) ([]*api.PinTemplate, error) {
+	ctx, span := trace.StartSpan(ctx, "client/PinTemplates")
+	defer span.End()
+
+	var tmpls []*api.PinTemplate
+	err := c.do(ctx, "GET", "/pintemplates", nil, nil, &tmpls)
+	return tmpls, err
+}
+
+// PinTemplate returns the pin template with the given name.
+func (c *defaultClient) PinTemplate(ctx context.Context, name string) (*api.PinTemplate, error) {
+	ctx, span := trace.StartSpan(ctx, "client/PinTemplate")
+	defer span.End()
+
+	var tmpl api.PinTemplate
+	err := c.do(ctx, "GET", "/pintemplates/"+url.PathEscape(name), nil, nil, &tmpl)
+	return &tmpl, err
+}
+
+// PinTemplateSet creates or replaces a pin template. Pins made with it
+// expire after expireIn, unless it is 0.
+func (c *defaultClient) PinTemplateSet(ctx context.Context, name string, opts api.PinOptions, expireIn time.Duration) (*api.PinTemplate, error) {
+	ctx, span := trace.StartSpan(ctx, "client/PinTemplateSet")
+	defer span.End()
+
+	opts.ExpireAt = time.Time{}
+	query, err := opts.ToQuery()
+	if err != nil {
+		return nil, err
+	}
+	if expireIn > 0 {
+		query += "&expire-in=" + expireIn.String()
+	}
+
+	var tmpl api.PinTemplate
+	err = c.do(
+		ctx,
+		"POST",
+		fmt.Sprintf("/pintemplates/%s?%s", url.PathEscape(name), query),
+		nil,
+		nil,
+		&tmpl,
+	)
+	return &tmpl, err
+}
+
+// PinTemplateRm removes a pin template.
+func (c *defaultClient) PinTemplateRm(ctx context.Context, name string) error {
+	ctx, span := trace.StartSpan(ctx, "client/PinTemplateRm")
+	defer span.End()
+
+	return c.do(ctx, "DELETE", "/pintemplates/"+url.PathEscape(name), nil, nil, nil)
+}
+
+// ConsistencyCheck cross-checks the shared state, the pin trackers and the
+// IPFS pins of all peers. With repair, the issues found are fixed.
+func (c *defaultClient) ConsistencyCheck(ctx context.Context, repair bool) (*api.GlobalConsistencyReport, error) {
+	ctx, span := trace.StartSpan(ctx, "client/ConsistencyCheck")
+	defer span.End()
+
+	var report api.GlobalConsistencyReport
+	err := c.do(ctx, "POST", fmt.Sprintf("/pinset/consistency?repair=%t", repair), nil, nil, &report)
+	return &report, err
+}
+
+// PinsetSnapshots returns the pinset snapshots taken in the peer.
+func (c *defaultClient) PinsetSnapshots(ctx context.Context) ([]*api.PinsetSnapshot, error) {
+	ctx, span := trace.StartSpan(ctx, "client/PinsetSnapshots")
+	defer span.End()
+
+	var snaps []*api.PinsetSnapshot
+	err := c.do(ctx, "GET", "/pinset/snapshots", nil, nil, &snaps)
+	return snaps, err
+}
+
+// PinsetSnapshot returns the pinset snapshot with the given name, including
+// its pins.
+func (c *defaultClient) PinsetSnapshot(ctx context.Context, name string) (*api.PinsetSnapshot, error) {
+	ctx, span := trace.StartSpan(ctx, "client/PinsetSnapshot")
+	defer span.End()
+
+	var snap api.PinsetSnapshot
+	err := c.do(ctx, "GET", "/pinset/snapshots/"+url.PathEscape(name), nil, nil, &snap)
+	return &snap, err
+}
+
+// PinsetSnapshotCreate takes a snapshot of the pinset with the given name.
+func (c *defaultClient) PinsetSnapshotCreate(ctx context.Context, name string) (*api.PinsetSnapshot, error) {
+	ctx, span := trace.StartSpan(ctx, "client/PinsetSnapshotCreate")
+	defer span.End()
+
+	var snap api.PinsetSnapshot
+	err := c.do(ctx, "POST", "/pinset/snapshots?name="+url.QueryEscape(name), nil, nil, &snap)
+	return &snap, err
+}
+
+// PinsetSnapshotRm removes a pinset snapshot.
+func (c *defaultClient) PinsetSnapshotRm(ctx context.Context, name string) error {
+	ctx, span := trace.StartSpan(ctx, "client/PinsetSnapshotRm")
+	defer span.End()
+
+	return c.do(ctx, "DELETE", "/pinset/snapshots/"+url.PathEscape(name), nil, nil, nil)
+}
+
+// PinsetRollback pins and unpins items until the pinset matches the
+// snapshot with the given name. With dryRun, the changes are only returned.
+func (c *defaultClient) PinsetRollback(ctx context.Context, name string, dryRun bool) (*api.PinsetRollback, error) {
+	ctx, span := trace.StartSpan(ctx, "client/PinsetRollback")
+	defer span.End()
+
+	var res api.PinsetRollback
+	err := c.do(
+		ctx,
+		"POST",
+		fmt.Sprintf("/pinset/snapshots/%s/rollback?dry-run=%t", url.PathEscape(name), dryRun),
+		nil,
+		nil,
+		&res,
+	)
+	return &res, err
+}
+
+// Denylist returns the denylist entries of the peer.
+func (c *defaultClient) Denylist(ctx context.Context) ([]*api.DenylistEntry, error) {
+	ctx, span := trace.StartSpan(ctx, "client/Denylist")
+	defer span.End()
+
+	var entries []*api.DenylistEntry
+	err := c.do(ctx, "GET", "/denylist", nil, nil, &entries)
+	return entries, err
+}
+
+// DenylistAdd adds rules to the denylist of the peer.
+func (c *defaultClient) DenylistAdd(ctx context.Context, rules []string) error {
+	ctx, span := trace.StartSpan(ctx, "client/DenylistAdd")
+	defer span.End()
+
+	body := strings.NewReader(strings.Join(rules, "\n") + "\n")
+	return c.do(ctx, "POST", "/denylist", nil, body, nil)
+}
+
+// DenylistRm removes a rule from the denylist of the peer.
+func (c *defaultClient) DenylistRm(ctx context.Context, rule string) error {
+	ctx, span := trace.StartSpan(ctx, "client/DenylistRm")
+	defer span.End()
+
+	return c.do(ctx, "DELETE", "/denylist?rule="+url.QueryEscape(rule), nil, nil, nil)
+}
+
+// DenylistEnforce unpins the content matching the denylist of the peer and
+// returns the unpinned pins.
+func (c *defaultClient) DenylistEnforce(ctx context.Context) ([]*api.Pin, error) {
+	ctx, span := trace.StartSpan(ctx, "client/DenylistEnforce")
+	defer span.End()
+
+	var pins []*api.Pin
+	err := c.do(ctx, "POST", "/denylist/enforce", nil, nil, &pins)
+	return pins, err
+}
+
 // Unpin untracks a Cid from cluster.
 func (c *defaultClient) Unpin(ctx context.Context, ci cid.Cid) (*api.Pin, error) {
 	ctx, span := trace.StartSpan(ctx, "client/Unpin")
@@ -113,6 +400,30 @@ func (c *defaultClient) Unpin(ctx context.Context, ci cid.Cid) (*api.Pin, error)
 	return &pin, nil
 }
 
+// Restore takes an unpinned item out of the trash.
+func (c *defaultClient) Restore(ctx context.Context, ci cid.Cid) (*api.Pin, error) {
+	ctx, span := trace.StartSpan(ctx, "client/Restore")
+	defer span.End()
+	var pin api.Pin
+	err := c.do(ctx, "POST", fmt.Sprintf("/pins/%s/restore", ci.String()), nil, nil, &pin)
+	if err != nil {
+		return nil, err
+	}
+	return &pin, nil
+}
+
+// Unprotect clears the protection of a pin so that it can be unpinned.
+func (c *defaultClient) Unprotect(ctx context.Context, ci cid.Cid) (*api.Pin, error) {
+	ctx, span := trace.StartSpan(ctx, "client/Unprotect")
+	defer span.End()
+	var pin api.Pin
+	err := c.do(ctx, "POST", fmt.Sprintf("/pins/%s/unprotect", ci.String()), nil, nil, &pin)
+	if err != nil {
+		return nil, err
+	}
+	return &pin, nil
+}
+
 // PinPath allows to pin an element by the given IPFS path.
 func (c *defaultClient) PinPath(ctx context.Context, path string, opts api.PinOptions) (*api.Pin, error) {
 	ctx, span := trace.StartSpan(ctx, "client/PinPath")
@@ -143,6 +454,40 @@ func (c *defaultClient) PinPath(ctx context.Context, path string, opts api.PinOp
 	return &pin, err
 }
 
+// PinUpdate pins to (a CID or an IPFS path) with the options and
+// allocations of the existing pin from (a CID or an IPFS path).
+func (c *defaultClient) PinUpdate(ctx context.Context, from, to string, opts api.PinOptions) (*api.Pin, error) {
+	ctx, span := trace.StartSpan(ctx, "client/PinUpdate")
+	defer span.End()
+
+	target := "/pins/" + to
+	if _, err := cid.Decode(to); err != nil {
+		ipfspath, err := gopath.ParsePath(to)
+		if err != nil {
+			return nil, err
+		}
+		target = "/pins" + ipfspath.String()
+	}
+	if _, err := cid.Decode(from); err != nil {
+		ipfspath, err := gopath.ParsePath(from)
+		if err != nil {
+			return nil, err
+		}
+		from = ipfspath.String()
+	}
+
+	opts.PinUpdate = cid.Undef
+	query, err := opts.ToQuery()
+	if err != nil {
+		return nil, err
+	}
+	query += "&pin-update=" + url.QueryEscape(from)
+
+	var pin api.Pin
+	err = c.do(ctx, "POST", fmt.Sprintf("%s?%s", target, query), nil, nil, &pin)
+	return &pin, err
+}
+
 // UnpinPath allows to unpin an item by providing its IPFS path.
 // It returns the unpinned api.Pin information of the resolved Cid.
 func (c *defaultClient) UnpinPath(ctx context.Context, p string) (*api.Pin, error) {
@@ -201,6 +546,33 @@ func (c *defaultClient) Allocation(ctx context.Context, ci cid.Cid) (*api.Pin, e
 	return &pin, err
 }
 
+// PinsByName returns the pins with the given name or, when prefix is true,
+// with names starting with the given string.
+func (c *defaultClient) PinsByName(ctx context.Context, name string, prefix bool) ([]*api.Pin, error) {
+	ctx, span := trace.StartSpan(ctx, "client/PinsByName")
+	defer span.End()
+
+	param := "name"
+	if prefix {
+		param = "name-prefix"
+	}
+
+	var pins []*api.Pin
+	err := c.do(ctx, "GET", fmt.Sprintf("/allocations?%s=%s", param, url.QueryEscape(name)), nil, nil, &pins)
+	return pins, err
+}
+
+// PinChanges returns the changes to the pinset after the given sequence
+// number.
+func (c *defaultClient) PinChanges(ctx context.Context, since uint64) (*api.PinChanges, error) {
+	ctx, span := trace.StartSpan(ctx, "client/PinChanges")
+	defer span.End()
+
+	var changes api.PinChanges
+	err := c.do(ctx, "GET", fmt.Sprintf("/allocations/changes?since=%d", since), nil, nil, &changes)
+	return &changes, err
+}
+
 // Status returns the current ipfs state for a given Cid. If local is true,
 // the information affects only the current peer, otherwise the information
 // is fetched from all cluster peers.
@@ -220,6 +592,32 @@ func (c *defaultClient) Status(ctx context.Context, ci cid.Cid, local bool) (*ap
 	return &gpi, err
 }
 
+// StatusCids returns the status of the given Cids, in the same order, with
+// a single request.
+func (c *defaultClient) StatusCids(ctx context.Context, cids []cid.Cid, local bool) ([]*api.GlobalPinInfo, error) {
+	ctx, span := trace.StartSpan(ctx, "client/StatusCids")
+	defer span.End()
+
+	cidStrs := make([]string, len(cids))
+	for i, ci := range cids {
+		cidStrs[i] = ci.String()
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.Encode(cidStrs)
+
+	var gpis []*api.GlobalPinInfo
+	err := c.do(
+		ctx,
+		"POST",
+		fmt.Sprintf("/pins/status?local=%t", local),
+		nil,
+		&buf,
+		&gpis,
+	)
+	return gpis, err
+}
+
 // StatusAll gathers Status() for all tracked items. If a filter is
 // provided, only entries matching the given filter statuses
 // will be returned. A filter can be built by merging TrackerStatuses with
@@ -250,6 +648,45 @@ func (c *defaultClient) StatusAll(ctx context.Context, filter api.TrackerStatus,
 	return gpis, err
 }
 
+// StatusAllStream is like StatusAll but sends the items to the out channel
+// as they are decoded. The channel is closed when done.
+func (c *defaultClient) StatusAllStream(ctx context.Context, filter api.TrackerStatus, local bool, out chan<- *api.GlobalPinInfo) error {
+	ctx, span := trace.StartSpan(ctx, "client/StatusAllStream")
+	defer span.End()
+
+	defer close(out)
+
+	filterStr := ""
+	if filter != api.TrackerStatusUndefined { // undefined filter means "all"
+		filterStr = filter.String()
+		if filterStr == "" {
+			return errors.New("invalid filter value")
+		}
+	}
+
+	handler := func(dec *json.Decoder) error {
+		var gpi api.GlobalPinInfo
+		if err := dec.Decode(&gpi); err != nil {
+			return err
+		}
+		select {
+		case out <- &gpi:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return c.doStream(
+		ctx,
+		"GET",
+		fmt.Sprintf("/pins?local=%t&filter=%s", local, url.QueryEscape(filterStr)),
+		nil,
+		nil,
+		handler,
+	)
+}
+
 // Recover retriggers pin or unpin ipfs operations for a Cid in error state.
 // If local is true, the operation is limited to the current peer, otherwise
 // it happens on every cluster peer.
@@ -274,6 +711,131 @@ func (c *defaultClient) RecoverAll(ctx context.Context, local bool) ([]*api.Glob
 	return gpis, err
 }
 
+// UnpinMatching unpins all the pins selected by their metadata.
+func (c *defaultClient) UnpinMatching(ctx context.Context, sel api.PinSelector) ([]*api.Pin, error) {
+	ctx, span := trace.StartSpan(ctx, "client/UnpinMatching")
+	defer span.End()
+
+	var pins []*api.Pin
+	err := c.do(ctx, "DELETE", "/pins?"+sel.ToQuery().Encode(), nil, nil, &pins)
+	return pins, err
+}
+
+// RecoverMatching triggers Recover() operations on the pins selected by
+// their metadata.
+func (c *defaultClient) RecoverMatching(ctx context.Context, sel api.PinSelector) ([]*api.GlobalPinInfo, error) {
+	ctx, span := trace.StartSpan(ctx, "client/RecoverMatching")
+	defer span.End()
+
+	var gpis []*api.GlobalPinInfo
+	err := c.do(ctx, "POST", "/pins/recover?"+sel.ToQuery().Encode(), nil, nil, &gpis)
+	return gpis, err
+}
+
+// ReplicateMatching sets the replication factors of the pins selected by
+// their metadata.
+func (c *defaultClient) ReplicateMatching(ctx context.Context, r api.BulkReplication) ([]*api.Pin, error) {
+	ctx, span := trace.StartSpan(ctx, "client/ReplicateMatching")
+	defer span.End()
+
+	q := r.Selector.ToQuery()
+	q.Set("replication-min", strconv.Itoa(r.ReplicationFactorMin))
+	q.Set("replication-max", strconv.Itoa(r.ReplicationFactorMax))
+	var pins []*api.Pin
+	err := c.do(ctx, "POST", "/pins/replicate?"+q.Encode(), nil, nil, &pins)
+	return pins, err
+}
+
+// Reshard re-adds the content of an existing pin with the given parameters
+// and replaces the pin with the resulting one. The previous CID is recorded
+// in the new pin metadata. The reshard runs in the background and the
+// returned operation can be used to follow it.
+func (c *defaultClient) Reshard(ctx context.Context, ci cid.Cid, params *api.AddParams) (*api.Operation, error) {
+	ctx, span := trace.StartSpan(ctx, "client/Reshard")
+	defer span.End()
+
+	queryStr, err := params.ToQueryString()
+	if err != nil {
+		return nil, err
+	}
+
+	var op api.Operation
+	err = c.do(
+		ctx,
+		"POST",
+		fmt.Sprintf("/pins/%s/reshard?%s", ci.String(), queryStr),
+		nil,
+		nil,
+		&op,
+	)
+	return &op, err
+}
+
+// Shards returns the shards of a sharded pin along with their status.
+func (c *defaultClient) Shards(ctx context.Context, ci cid.Cid) ([]*api.ShardInfo, error) {
+	ctx, span := trace.StartSpan(ctx, "client/Shards")
+	defer span.End()
+
+	var shards []*api.ShardInfo
+	err := c.do(ctx, "GET", fmt.Sprintf("/pins/%s/shards", ci.String()), nil, nil, &shards)
+	return shards, err
+}
+
+// Reconstruct rebuilds the content of an erasure-coded pin in the IPFS
+// daemon of the peer, from the data and parity shards which are available.
+func (c *defaultClient) Reconstruct(ctx context.Context, ci cid.Cid) (*api.ErasureReconstruction, error) {
+	ctx, span := trace.StartSpan(ctx, "client/Reconstruct")
+	defer span.End()
+
+	var res api.ErasureReconstruction
+	err := c.do(ctx, "POST", fmt.Sprintf("/pins/%s/reconstruct", ci.String()), nil, nil, &res)
+	return &res, err
+}
+
+// Decrypt writes the decrypted content of the file at the given path of an
+// encrypted pin. The key is only needed for content encrypted with a key
+// supplied by the user.
+func (c *defaultClient) Decrypt(ctx context.Context, ci cid.Cid, path string, key []byte, w io.Writer) error {
+	ctx, span := trace.StartSpan(ctx, "client/Decrypt")
+	defer span.End()
+
+	headers := make(map[string]string)
+	if len(key) > 0 {
+		headers["X-Encryption-Key"] = base64.StdEncoding.EncodeToString(key)
+	}
+	urlPath := fmt.Sprintf("/pins/%s/decrypt?path=%s", ci.String(), url.QueryEscape(path))
+
+	return c.withRetries(ctx, nil, func() error {
+		resp, err := c.doRequest(ctx, "GET", urlPath, headers, nil)
+		if err != nil {
+			return &api.Error{Code: 0, Message: err.Error()}
+		}
+		if resp.StatusCode != http.StatusOK {
+			return c.handleResponse(resp, nil)
+		}
+		defer resp.Body.Close()
+
+		if _, err := io.Copy(w, resp.Body); err != nil {
+			return &api.Error{Code: resp.StatusCode, Message: err.Error()}
+		}
+		if errTrailer := resp.Trailer.Get("X-Stream-Error"); errTrailer != "" {
+			return &api.Error{Code: 500, Message: errTrailer}
+		}
+		return nil
+	})
+}
+
+// RecoverShard triggers Recover() for one of the shards of a sharded pin, on
+// every cluster peer.
+func (c *defaultClient) RecoverShard(ctx context.Context, ci cid.Cid, shard cid.Cid) (*api.GlobalPinInfo, error) {
+	ctx, span := trace.StartSpan(ctx, "client/RecoverShard")
+	defer span.End()
+
+	var gpi api.GlobalPinInfo
+	err := c.do(ctx, "POST", fmt.Sprintf("/pins/%s/shards/%s/recover", ci.String(), shard.String()), nil, nil, &gpi)
+	return &gpi, err
+}
+
 // Alerts returns information health events in the cluster (expired metrics
 // etc.).
 func (c *defaultClient) Alerts(ctx context.Context) ([]*api.Alert, error) {
@@ -285,6 +847,39 @@ func (c *defaultClient) Alerts(ctx context.Context) ([]*api.Alert, error) {
 	return alerts, err
 }
 
+// StartupWarmup returns the progress of the reconciliation of the pinset
+// with IPFS that the peer does when it starts.
+func (c *defaultClient) StartupWarmup(ctx context.Context) (*api.StartupWarmup, error) {
+	ctx, span := trace.StartSpan(ctx, "client/StartupWarmup")
+	defer span.End()
+
+	var warmup api.StartupWarmup
+	err := c.do(ctx, "GET", "/health/warmup", nil, nil, &warmup)
+	return &warmup, err
+}
+
+// LifecycleEvents returns the lifecycle events of the peer after the given
+// sequence number.
+func (c *defaultClient) LifecycleEvents(ctx context.Context, since uint64) (*api.LifecycleEvents, error) {
+	ctx, span := trace.StartSpan(ctx, "client/LifecycleEvents")
+	defer span.End()
+
+	var events api.LifecycleEvents
+	err := c.do(ctx, "GET", fmt.Sprintf("/events?since=%d", since), nil, nil, &events)
+	return &events, err
+}
+
+// ConsensusStats returns the internals of the consensus component of every
+// peer, and how far behind each peer is.
+func (c *defaultClient) ConsensusStats(ctx context.Context) ([]*api.ConsensusStats, error) {
+	ctx, span := trace.StartSpan(ctx, "client/ConsensusStats")
+	defer span.End()
+
+	var stats []*api.ConsensusStats
+	err := c.do(ctx, "GET", "/health/consensus", nil, nil, &stats)
+	return stats, err
+}
+
 // Version returns the ipfs-cluster peer's version.
 func (c *defaultClient) Version(ctx context.Context) (*api.Version, error) {
 	ctx, span := trace.StartSpan(ctx, "client/Version")
@@ -306,6 +901,21 @@ func (c *defaultClient) GetConnectGraph(ctx context.Context) (*api.ConnectGraph,
 	return &graph, err
 }
 
+// ConnectivityHistory returns the connectivity snapshots recorded by the
+// contacted peer since the given time.
+func (c *defaultClient) ConnectivityHistory(ctx context.Context, since time.Time) ([]*api.ConnectivitySnapshot, error) {
+	ctx, span := trace.StartSpan(ctx, "client/ConnectivityHistory")
+	defer span.End()
+
+	path := "/health/graph/history"
+	if !since.IsZero() {
+		path += "?since=" + url.QueryEscape(since.Format(time.RFC3339))
+	}
+	var history []*api.ConnectivitySnapshot
+	err := c.do(ctx, "GET", path, nil, nil, &history)
+	return history, err
+}
+
 // Metrics returns a map with the latest valid metrics of the given name
 // for the current cluster peers.
 func (c *defaultClient) Metrics(ctx context.Context, name string) ([]*api.Metric, error) {
@@ -330,6 +940,17 @@ func (c *defaultClient) MetricNames(ctx context.Context) ([]string, error) {
 	return metricsNames, err
 }
 
+// DetectorState returns the state of the failure detector for the latest
+// metrics of every peer.
+func (c *defaultClient) DetectorState(ctx context.Context) ([]*api.DetectorState, error) {
+	ctx, span := trace.StartSpan(ctx, "client/DetectorState")
+	defer span.End()
+
+	var states []*api.DetectorState
+	err := c.do(ctx, "GET", "/monitor/detector", nil, nil, &states)
+	return states, err
+}
+
 // RepoGC runs garbage collection on IPFS daemons of cluster peers and
 // returns collected CIDs. If local is true, it would garbage collect
 // only on contacted peer, otherwise on all peers' IPFS daemons.
@@ -350,6 +971,146 @@ func (c *defaultClient) RepoGC(ctx context.Context, local bool) (*api.GlobalRepo
 	return &repoGC, err
 }
 
+// Audit verifies the blocks of the DAG of a pin in the IPFS daemons it is
+// allocated to.
+func (c *defaultClient) Audit(ctx context.Context, ci cid.Cid, repair bool) (*api.GlobalDAGAudit, error) {
+	ctx, span := trace.StartSpan(ctx, "client/Audit")
+	defer span.End()
+
+	var audit api.GlobalDAGAudit
+	err := c.do(
+		ctx,
+		"POST",
+		fmt.Sprintf("/pins/%s/audit?repair=%t", ci.String(), repair),
+		nil,
+		nil,
+		&audit,
+	)
+	return &audit, err
+}
+
+// Operations returns the long-running operations started in the contacted
+// peer.
+func (c *defaultClient) Operations(ctx context.Context) ([]*api.Operation, error) {
+	ctx, span := trace.StartSpan(ctx, "client/Operations")
+	defer span.End()
+
+	var ops []*api.Operation
+	err := c.do(ctx, "GET", "/operations", nil, nil, &ops)
+	return ops, err
+}
+
+// Operation returns the operation with the given ID.
+func (c *defaultClient) Operation(ctx context.Context, id string) (*api.Operation, error) {
+	ctx, span := trace.StartSpan(ctx, "client/Operation")
+	defer span.End()
+
+	var op api.Operation
+	err := c.do(ctx, "GET", fmt.Sprintf("/operations/%s", id), nil, nil, &op)
+	return &op, err
+}
+
+// CancelOperation cancels a running operation in the contacted peer.
+func (c *defaultClient) CancelOperation(ctx context.Context, id string) (*api.Operation, error) {
+	ctx, span := trace.StartSpan(ctx, "client/CancelOperation")
+	defer span.End()
+
+	var op api.Operation
+	err := c.do(ctx, "DELETE", fmt.Sprintf("/operations/%s", id), nil, nil, &op)
+	return &op, err
+}
+
+// RPCPolicy returns the RPC authorization policy of the contacted peer.
+func (c *defaultClient) RPCPolicy(ctx context.Context) (map[string]string, error) {
+	ctx, span := trace.StartSpan(ctx, "client/RPCPolicy")
+	defer span.End()
+
+	var policy map[string]string
+	err := c.do(ctx, "GET", "/rpc/policy", nil, nil, &policy)
+	return policy, err
+}
+
+// SetRPCPolicy changes the endpoint types of the given RPC methods in the
+// contacted peer and returns the resulting policy.
+func (c *defaultClient) SetRPCPolicy(ctx context.Context, changes map[string]string) (map[string]string, error) {
+	ctx, span := trace.StartSpan(ctx, "client/SetRPCPolicy")
+	defer span.End()
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.Encode(changes)
+
+	var policy map[string]string
+	err := c.do(ctx, "POST", "/rpc/policy", nil, &buf, &policy)
+	return policy, err
+}
+
+// RotateSecret replaces the cluster secret in all peers.
+func (c *defaultClient) RotateSecret(ctx context.Context, secret string) (*api.SecretRotation, error) {
+	ctx, span := trace.StartSpan(ctx, "client/RotateSecret")
+	defer span.End()
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.Encode(api.SecretRotation{Secret: secret})
+
+	var rot api.SecretRotation
+	err := c.do(ctx, "POST", "/secret/rotate", nil, &buf, &rot)
+	return &rot, err
+}
+
+// TrackerSettings returns the runtime settings of the pin tracker of the
+// contacted peer.
+func (c *defaultClient) TrackerSettings(ctx context.Context) (*api.TrackerSettings, error) {
+	ctx, span := trace.StartSpan(ctx, "client/TrackerSettings")
+	defer span.End()
+
+	var settings api.TrackerSettings
+	err := c.do(ctx, "GET", "/pintracker/settings", nil, nil, &settings)
+	return &settings, err
+}
+
+// SetTrackerSettings changes the runtime settings of the pin tracker of the
+// contacted peer and returns the resulting ones.
+func (c *defaultClient) SetTrackerSettings(ctx context.Context, changes *api.TrackerSettings) (*api.TrackerSettings, error) {
+	ctx, span := trace.StartSpan(ctx, "client/SetTrackerSettings")
+	defer span.End()
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.Encode(changes)
+
+	var settings api.TrackerSettings
+	err := c.do(ctx, "POST", "/pintracker/settings", nil, &buf, &settings)
+	return &settings, err
+}
+
+// APIListeners returns the listener settings of the APIs of the contacted
+// peer which can be changed at runtime.
+func (c *defaultClient) APIListeners(ctx context.Context) ([]*api.APIListeners, error) {
+	ctx, span := trace.StartSpan(ctx, "client/APIListeners")
+	defer span.End()
+
+	var listeners []*api.APIListeners
+	err := c.do(ctx, "GET", "/apis/listeners", nil, nil, &listeners)
+	return listeners, err
+}
+
+// RebindAPI replaces the listeners of one of the APIs of the contacted peer
+// and returns the resulting settings.
+func (c *defaultClient) RebindAPI(ctx context.Context, settings *api.APIListeners) (*api.APIListeners, error) {
+	ctx, span := trace.StartSpan(ctx, "client/RebindAPI")
+	defer span.End()
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.Encode(settings)
+
+	var listeners api.APIListeners
+	err := c.do(ctx, "POST", "/apis/listeners", nil, &buf, &listeners)
+	return &listeners, err
+}
+
 // WaitFor is a utility function that allows for a caller to wait until a CID
 // status target is reached (as given in StatusFilterParams).
 // It returns the final status for that CID and an error, if there was one.
@@ -606,6 +1367,9 @@ func (c *defaultClient) AddMultiFile(
 
 	headers := make(map[string]string)
 	headers["Content-Type"] = "multipart/form-data; boundary=" + multiFileR.Boundary()
+	if len(params.EncryptionKey) > 0 {
+		headers["X-Encryption-Key"] = base64.StdEncoding.EncodeToString(params.EncryptionKey)
+	}
 
 	// This method must run with StreamChannels set.
 	params.StreamChannels = true
@@ -629,12 +1393,50 @@ func (c *defaultClient) AddMultiFile(
 		return nil
 	}
 
+	var body io.Reader = multiFileR
+	if params.Checksum {
+		body = newDigestBody(multiFileR)
+	}
+
 	err = c.doStream(ctx,
 		"POST",
 		"/add?"+queryStr,
 		headers,
-		multiFileR,
+		body,
 		handler,
 	)
 	return err
 }
+
+// digestBody computes the SHA-256 digest of a request body while it is
+// sent, and then sends it in the api.ContentDigestHeader trailer.
+type digestBody struct {
+	r       io.Reader
+	sum     hash.Hash
+	trailer http.Header
+}
+
+func newDigestBody(r io.Reader) *digestBody {
+	return &digestBody{
+		r:   r,
+		sum: sha256.New(),
+		trailer: http.Header{
+			http.CanonicalHeaderKey(api.ContentDigestHeader): nil,
+		},
+	}
+}
+
+func (db *digestBody) Read(p []byte) (int, error) {
+	n, err := db.r.Read(p)
+	db.sum.Write(p[:n])
+	if err == io.EOF {
+		db.trailer.Set(api.ContentDigestHeader, api.FormatContentDigest(db.sum.Sum(nil)))
+	}
+	return n, err
+}
+
+// Trailer returns the trailers of the request, which are set once the body
+// has been read.
+func (db *digestBody) Trailer() http.Header {
+	return db.trailer
+}
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"sync"
@@ -8,6 +9,7 @@ import (
 	"time"
 
 	types "github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/api/common"
 	rest "github.com/ipfs/ipfs-cluster/api/rest"
 	test "github.com/ipfs/ipfs-cluster/test"
 
@@ -104,6 +106,119 @@ func TestPeersWithError(t *testing.T) {
 	testClients(t, api, testF)
 }
 
+func TestPeer(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		id, err := c.Peer(ctx, test.PeerName1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id.ID != test.PeerID1 {
+			t.Error("unexpected peer ID:", id.ID)
+		}
+
+		_, err = c.Peer(ctx, "nobody")
+		if err == nil {
+			t.Error("expected an error for an unknown peername")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestPeerResources(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		res, err := c.PeerResources(ctx, test.PeerName1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Peer != test.PeerID1 || res.IPFSRepoStat == nil || res.IPFSRepoStat.RepoSize != 1000 {
+			t.Errorf("unexpected resources: %+v", res)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestPeerHandover(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		handover, err := c.PeerHandover(ctx, test.PeerID1, test.PeerID2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if handover.From != test.PeerID1 || handover.To != test.PeerID2 || handover.Pins != 3 {
+			t.Errorf("unexpected handover: %+v", handover)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestRotateSecret(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		rot, err := c.RotateSecret(ctx, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rot.Secret == "" || len(rot.Peers) != 3 {
+			t.Errorf("unexpected rotation: %+v", rot)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestPeerVersions(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		pvs, err := c.PeerVersions(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pvs.Peers) != 2 || !pvs.RPCProtocolSkew {
+			t.Errorf("unexpected peer versions: %+v", pvs)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestConsensusStats(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		stats, err := c.ConsensusStats(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(stats) != 3 || stats[0].CRDT.Height != 10 {
+			t.Errorf("unexpected consensus stats: %+v", stats)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
 func TestPeerAdd(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
@@ -157,6 +272,239 @@ func TestPin(t *testing.T) {
 	testClients(t, api, testF)
 }
 
+func TestPinProfiles(t *testing.T) {
+	ctx := context.Background()
+	cfg := rest.NewConfig()
+	cfg.Default()
+	cfg.PinProfiles = map[string]map[string]string{
+		"archive": {
+			"replication-min": "2",
+			"replication-max": "3",
+			"mode":            "direct",
+			"shard-size":      "1000",
+		},
+	}
+	cfg.UserPinProfiles = map[string]string{"": "archive"}
+	api := testAPIWithConfig(t, cfg)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		// The profile applies to the options which are not set.
+		pin, err := c.Pin(ctx, test.Cid1, types.PinOptions{Name: "a"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pin.ReplicationFactorMin != 2 || pin.ReplicationFactorMax != 3 ||
+			pin.Mode != types.PinModeDirect || pin.ShardSize != 1000 {
+			t.Errorf("expected the profile options: %+v", pin.PinOptions)
+		}
+
+		pin, err = c.Pin(ctx, test.Cid1, types.PinOptions{
+			ReplicationFactorMin: 1,
+			ReplicationFactorMax: 1,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pin.ReplicationFactorMin != 1 || pin.ReplicationFactorMax != 1 || pin.Mode != types.PinModeDirect {
+			t.Errorf("expected the request options to win: %+v", pin.PinOptions)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestPinDryRun(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		opts := types.PinOptions{
+			ReplicationFactorMin: 2,
+			ReplicationFactorMax: 2,
+		}
+		pin, err := c.PinDryRun(ctx, test.Cid1, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pin.Allocations) != 2 {
+			t.Error("expected planned allocations")
+		}
+
+		_, err = c.PinDryRun(ctx, test.ErrorCid, opts)
+		if err == nil {
+			t.Error("expected an error")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestPinClone(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		pin, err := c.PinClone(ctx, test.Cid4, test.Cid2, "copy")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pin.Name != "copy" || pin.ReplicationFactorMin != 1 {
+			t.Error("unexpected cloned pin")
+		}
+
+		pin, err = c.PinWithTemplate(ctx, test.Cid4, test.PinTemplateName, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pin.ReplicationFactorMax != 3 || pin.ExpireAt.IsZero() {
+			t.Error("unexpected pin from template")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestDenylist(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		entries, err := c.Denylist(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Error("expected one entry")
+		}
+
+		rule := "/ipfs/" + test.Cid4.String()
+		err = c.DenylistAdd(ctx, []string{rule, "!/ipfs/" + test.Cid1.String()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.DenylistAdd(ctx, []string{"/ipns/example.org"}); err == nil {
+			t.Error("expected an error adding an unsupported rule")
+		}
+
+		if err := c.DenylistRm(ctx, rule); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.DenylistRm(ctx, "/ipfs/"+test.Cid1.String()); err == nil {
+			t.Error("expected an error removing an unknown rule")
+		}
+
+		pins, err := c.DenylistEnforce(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pins) != 1 {
+			t.Error("expected one unpinned pin")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestPinTemplates(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		tmpls, err := c.PinTemplates(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tmpls) != 1 {
+			t.Error("expected one template")
+		}
+
+		tmpl, err := c.PinTemplate(ctx, test.PinTemplateName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tmpl.Name != test.PinTemplateName {
+			t.Error("unexpected template")
+		}
+
+		opts := types.PinOptions{ReplicationFactorMin: 2, ReplicationFactorMax: 3}
+		_, err = c.PinTemplateSet(ctx, test.PinTemplateName, opts, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = c.PinTemplateRm(ctx, test.PinTemplateName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = c.PinTemplateRm(ctx, "other")
+		if err == nil {
+			t.Error("expected an error removing an unknown template")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestPinsetSnapshots(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		snaps, err := c.PinsetSnapshots(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(snaps) != 1 {
+			t.Error("expected one snapshot")
+		}
+
+		snap, err := c.PinsetSnapshot(ctx, test.PinsetSnapshotName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if snap.Name != test.PinsetSnapshotName || len(snap.Pins) != 1 {
+			t.Error("unexpected snapshot")
+		}
+
+		snap, err = c.PinsetSnapshotCreate(ctx, "other")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if snap.Name != "other" {
+			t.Error("unexpected snapshot name")
+		}
+		_, err = c.PinsetSnapshotCreate(ctx, test.PinsetSnapshotName)
+		if err == nil {
+			t.Error("expected an error creating an existing snapshot")
+		}
+
+		res, err := c.PinsetRollback(ctx, test.PinsetSnapshotName, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.DryRun || len(res.Pinned) != 1 || len(res.Unpinned) != 1 {
+			t.Errorf("unexpected rollback: %+v", res)
+		}
+
+		err = c.PinsetSnapshotRm(ctx, test.PinsetSnapshotName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = c.PinsetSnapshotRm(ctx, "other")
+		if err == nil {
+			t.Error("expected an error removing an unknown snapshot")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
 func TestUnpin(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
@@ -172,18 +520,148 @@ func TestUnpin(t *testing.T) {
 	testClients(t, api, testF)
 }
 
-type pathCase struct {
-	path        string
-	wantErr     bool
-	expectedCid string
-}
+func TestBulkOperations(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
 
-var pathTestCases = []pathCase{
-	{
-		test.CidResolved.String(),
-		false,
-		test.CidResolved.String(),
-	},
+	sel := types.PinSelector{Metadata: map[string]string{"project": "x"}}
+	testF := func(t *testing.T, c Client) {
+		pins, err := c.UnpinMatching(ctx, sel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pins) != 1 || pins[0].Metadata["project"] != "x" {
+			t.Error("unexpected pins:", pins)
+		}
+
+		gpis, err := c.RecoverMatching(ctx, sel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(gpis) != 1 {
+			t.Error("unexpected statuses:", gpis)
+		}
+
+		pins, err = c.ReplicateMatching(ctx, types.BulkReplication{
+			Selector:             sel,
+			ReplicationFactorMin: 2,
+			ReplicationFactorMax: 3,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pins) != 1 || pins[0].ReplicationFactorMin != 2 || pins[0].ReplicationFactorMax != 3 {
+			t.Error("unexpected pins:", pins)
+		}
+
+		_, err = c.UnpinMatching(ctx, types.PinSelector{})
+		if err == nil {
+			t.Error("expected an error without selector")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestRestore(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		pin, err := c.Restore(ctx, test.Cid1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pin.Cid.Equals(test.Cid1) {
+			t.Error("unexpected pin:", pin.Cid)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestAudit(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		audit, err := c.Audit(ctx, test.Cid1, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		peerAudit, ok := audit.PeerMap[peer.Encode(test.PeerID1)]
+		if !ok {
+			t.Fatal("expected an audit for the peer")
+		}
+		if !peerAudit.Repaired || peerAudit.Ok() {
+			t.Error("expected a repaired audit with corrupted blocks")
+		}
+
+		_, err = c.Audit(ctx, test.ErrorCid, false)
+		if err == nil {
+			t.Error("expected an error")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestConsistencyCheck(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		report, err := c.ConsistencyCheck(ctx, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		peerReport, ok := report.PeerMap[peer.Encode(test.PeerID1)]
+		if !ok {
+			t.Fatal("expected a report for the peer")
+		}
+		if len(peerReport.Issues) != 1 || !peerReport.Issues[0].Repaired ||
+			peerReport.Issues[0].Status != types.TrackerStatusUnexpectedlyUnpinned {
+			t.Errorf("unexpected issues: %+v", peerReport.Issues)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestUnprotect(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		pin, err := c.Unprotect(ctx, test.Cid1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pin.Cid.Equals(test.Cid1) {
+			t.Error("unexpected pin:", pin.Cid)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+type pathCase struct {
+	path        string
+	wantErr     bool
+	expectedCid string
+}
+
+var pathTestCases = []pathCase{
+	{
+		test.CidResolved.String(),
+		false,
+		test.CidResolved.String(),
+	},
 	{
 		test.PathIPFS1,
 		false,
@@ -211,283 +689,745 @@ var pathTestCases = []pathCase{
 	},
 }
 
-func TestPinPath(t *testing.T) {
+func TestPinPath(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	opts := types.PinOptions{
+		ReplicationFactorMin: 6,
+		ReplicationFactorMax: 7,
+		Name:                 "hello there",
+		UserAllocations:      []peer.ID{test.PeerID1, test.PeerID2},
+	}
+
+	testF := func(t *testing.T, c Client) {
+		for _, testCase := range pathTestCases {
+			ec, _ := cid.Decode(testCase.expectedCid)
+			resultantPin := types.PinWithOpts(ec, opts)
+			p := testCase.path
+			pin, err := c.PinPath(ctx, p, opts)
+			if err != nil {
+				if testCase.wantErr {
+					continue
+				}
+				t.Fatalf("unexpected error %s: %s", p, err)
+			}
+
+			if !pin.Equals(resultantPin) {
+				t.Errorf("expected different pin: %s", p)
+				t.Errorf("expected: %+v", resultantPin)
+				t.Errorf("actual: %+v", pin)
+			}
+
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestPinUpdate(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		pin, err := c.PinUpdate(ctx, test.Cid1.String(), test.Cid3.String(), types.PinOptions{Name: "v2"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pin.Cid.Equals(test.Cid3) || !pin.PinUpdate.Equals(test.Cid1) || pin.Name != "v2" {
+			t.Errorf("unexpected pin: %+v", pin)
+		}
+
+		pin, err = c.PinUpdate(ctx, test.PathIPNS1, test.PathIPFS1, types.PinOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pin.PinUpdate.Equals(test.Cid2) {
+			t.Errorf("expected the existing path to be resolved: %+v", pin)
+		}
+
+		_, err = c.PinUpdate(ctx, test.InvalidPath1, test.Cid3.String(), types.PinOptions{})
+		if err == nil {
+			t.Error("expected an error with an invalid path")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestUnpinPath(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		for _, testCase := range pathTestCases {
+			p := testCase.path
+			pin, err := c.UnpinPath(ctx, p)
+			if err != nil {
+				if testCase.wantErr {
+					continue
+				}
+				t.Fatalf("unepected error %s: %s", p, err)
+			}
+
+			if pin.Cid.String() != testCase.expectedCid {
+				t.Errorf("bad resolved Cid: %s, %s", p, pin.Cid)
+			}
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestAllocations(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		pins, err := c.Allocations(ctx, types.DataType|types.MetaType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pins) == 0 {
+			t.Error("should be some pins")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestAllocation(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		pin, err := c.Allocation(ctx, test.Cid1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pin.Cid.Equals(test.Cid1) {
+			t.Error("should be same pin")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestStatus(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		pin, err := c.Status(ctx, test.Cid1, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pin.Cid.Equals(test.Cid1) {
+			t.Error("should be same pin")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestStatusCids(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		pins, err := c.StatusCids(ctx, []cid.Cid{test.Cid2, test.Cid1}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pins) != 2 || !pins[0].Cid.Equals(test.Cid2) || !pins[1].Cid.Equals(test.Cid1) {
+			t.Errorf("unexpected status: %+v", pins)
+		}
+
+		_, err = c.StatusCids(ctx, []cid.Cid{test.ErrorCid}, true)
+		if err == nil {
+			t.Error("expected an error")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestStatusAll(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		pins, err := c.StatusAll(ctx, 0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(pins) == 0 {
+			t.Error("there should be some pins")
+		}
+
+		// With local true
+		pins, err = c.StatusAll(ctx, 0, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pins) != 2 {
+			t.Error("there should be two pins")
+		}
+
+		// With filter option
+		pins, err = c.StatusAll(ctx, types.TrackerStatusPinning, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pins) != 1 {
+			t.Error("there should be one pin")
+		}
+
+		pins, err = c.StatusAll(ctx, types.TrackerStatusPinned|types.TrackerStatusError, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pins) != 2 {
+			t.Error("there should be two pins")
+		}
+
+		_, err = c.StatusAll(ctx, 1<<25, false)
+		if err == nil {
+			t.Error("expected an error")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestStatusAllStream(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		out := make(chan *types.GlobalPinInfo, 10)
+		err := c.StatusAllStream(ctx, types.TrackerStatusPinned|types.TrackerStatusError, false, out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var pins []*types.GlobalPinInfo
+		for gpi := range out {
+			pins = append(pins, gpi)
+		}
+		if len(pins) != 2 {
+			t.Error("there should be two pins")
+		}
+
+		out = make(chan *types.GlobalPinInfo, 10)
+		err = c.StatusAllStream(ctx, 1<<25, false, out)
+		if err == nil {
+			t.Error("expected an error")
+		}
+		if _, ok := <-out; ok {
+			t.Error("expected a closed channel")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestRecover(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		pin, err := c.Recover(ctx, test.Cid1, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pin.Cid.Equals(test.Cid1) {
+			t.Error("should be same pin")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestReshard(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		params := types.DefaultAddParams()
+		params.RawLeaves = true
+		op, err := c.Reshard(ctx, test.Cid1, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if op.Type != types.OperationReshard || !op.Cid.Equals(test.Cid1) {
+			t.Error("expected a reshard operation for the pin")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestShards(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		shards, err := c.Shards(ctx, test.Cid1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(shards) != 2 {
+			t.Fatal("expected 2 shards")
+		}
+		if !shards[0].Cid.Equals(test.Cid2) {
+			t.Error("unexpected shard cid")
+		}
+
+		gpi, err := c.RecoverShard(ctx, test.Cid1, test.Cid3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !gpi.Cid.Equals(test.Cid3) {
+			t.Error("should have recovered the shard")
+		}
+
+		_, err = c.RecoverShard(ctx, test.Cid1, test.Cid4)
+		if err == nil {
+			t.Error("expected an error for a shard not in the pin")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestReconstruct(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		res, err := c.Reconstruct(ctx, test.Cid1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Cid.Equals(test.Cid1) || res.Peer != test.PeerID1 {
+			t.Error("unexpected reconstruction:", res)
+		}
+
+		_, err = c.Reconstruct(ctx, test.ErrorCid)
+		if err == nil {
+			t.Error("expected an error")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestDecrypt(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		var buf bytes.Buffer
+		err := c.Decrypt(ctx, test.Cid1, "", nil, &buf)
+		if err == nil {
+			t.Error("expected an error decrypting a pin which is not encrypted")
+		}
+		if buf.Len() > 0 {
+			t.Error("nothing should have been written")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestRecoverAll(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		_, err := c.RecoverAll(ctx, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = c.RecoverAll(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestAlerts(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
 	defer shutdown(api)
 
-	opts := types.PinOptions{
-		ReplicationFactorMin: 6,
-		ReplicationFactorMax: 7,
-		Name:                 "hello there",
-		UserAllocations:      []peer.ID{test.PeerID1, test.PeerID2},
-	}
-
 	testF := func(t *testing.T, c Client) {
-		for _, testCase := range pathTestCases {
-			ec, _ := cid.Decode(testCase.expectedCid)
-			resultantPin := types.PinWithOpts(ec, opts)
-			p := testCase.path
-			pin, err := c.PinPath(ctx, p, opts)
-			if err != nil {
-				if testCase.wantErr {
-					continue
-				}
-				t.Fatalf("unexpected error %s: %s", p, err)
-			}
-
-			if !pin.Equals(resultantPin) {
-				t.Errorf("expected different pin: %s", p)
-				t.Errorf("expected: %+v", resultantPin)
-				t.Errorf("actual: %+v", pin)
-			}
-
+		alerts, err := c.Alerts(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(alerts) != 1 {
+			t.Fatal("expected 1 alert")
+		}
+		pID2 := peer.Encode(test.PeerID2)
+		if alerts[0].Peer != test.PeerID2 {
+			t.Errorf("expected an alert from %s", pID2)
 		}
 	}
 
 	testClients(t, api, testF)
 }
 
-func TestUnpinPath(t *testing.T) {
+func TestStartupWarmup(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
 	defer shutdown(api)
 
 	testF := func(t *testing.T, c Client) {
-		for _, testCase := range pathTestCases {
-			p := testCase.path
-			pin, err := c.UnpinPath(ctx, p)
-			if err != nil {
-				if testCase.wantErr {
-					continue
-				}
-				t.Fatalf("unepected error %s: %s", p, err)
-			}
-
-			if pin.Cid.String() != testCase.expectedCid {
-				t.Errorf("bad resolved Cid: %s, %s", p, pin.Cid)
-			}
+		warmup, err := c.StartupWarmup(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if warmup.Peer != test.PeerID1 {
+			t.Error("expected the warm-up of the contacted peer")
+		}
+		if warmup.Operation == nil || warmup.Operation.Total != 5 {
+			t.Error("expected the warm-up operation")
 		}
 	}
 
 	testClients(t, api, testF)
 }
 
-func TestAllocations(t *testing.T) {
+func TestLifecycleEvents(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
 	defer shutdown(api)
 
 	testF := func(t *testing.T, c Client) {
-		pins, err := c.Allocations(ctx, types.DataType|types.MetaType)
+		events, err := c.LifecycleEvents(ctx, 0)
 		if err != nil {
 			t.Fatal(err)
 		}
-		if len(pins) == 0 {
-			t.Error("should be some pins")
+		if events.Sequence != 3 || len(events.Events) != 3 {
+			t.Error("expected all the events")
+		}
+
+		events, err = c.LifecycleEvents(ctx, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events.Events) != 0 {
+			t.Error("expected no new events")
 		}
 	}
 
 	testClients(t, api, testF)
 }
 
-func TestAllocation(t *testing.T) {
+func TestGetConnectGraph(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
 	defer shutdown(api)
 
 	testF := func(t *testing.T, c Client) {
-		pin, err := c.Allocation(ctx, test.Cid1)
+		cg, err := c.GetConnectGraph(ctx)
 		if err != nil {
 			t.Fatal(err)
 		}
-		if !pin.Cid.Equals(test.Cid1) {
-			t.Error("should be same pin")
+		if len(cg.IPFSLinks) != 3 || len(cg.ClusterLinks) != 3 ||
+			len(cg.ClustertoIPFS) != 3 {
+			t.Fatal("Bad graph")
 		}
 	}
 
 	testClients(t, api, testF)
 }
 
-func TestStatus(t *testing.T) {
+func TestMetrics(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
 	defer shutdown(api)
 
 	testF := func(t *testing.T, c Client) {
-		pin, err := c.Status(ctx, test.Cid1, false)
+		m, err := c.Metrics(ctx, "somemetricstype")
 		if err != nil {
 			t.Fatal(err)
 		}
-		if !pin.Cid.Equals(test.Cid1) {
-			t.Error("should be same pin")
+
+		if len(m) == 0 {
+			t.Fatal("No metrics found")
 		}
 	}
 
 	testClients(t, api, testF)
 }
 
-func TestStatusAll(t *testing.T) {
+func TestMetricNames(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
 	defer shutdown(api)
 
 	testF := func(t *testing.T, c Client) {
-		pins, err := c.StatusAll(ctx, 0, false)
+		m, err := c.MetricNames(ctx)
 		if err != nil {
 			t.Fatal(err)
 		}
 
-		if len(pins) == 0 {
-			t.Error("there should be some pins")
+		if len(m) == 0 {
+			t.Fatal("No metric names found")
 		}
+	}
 
-		// With local true
-		pins, err = c.StatusAll(ctx, 0, true)
+	testClients(t, api, testF)
+}
+
+func TestConnectivityHistory(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		history, err := c.ConnectivityHistory(ctx, time.Time{})
 		if err != nil {
 			t.Fatal(err)
 		}
-		if len(pins) != 2 {
-			t.Error("there should be two pins")
+		if len(history) != 2 {
+			t.Fatal("expected two snapshots")
 		}
 
-		// With filter option
-		pins, err = c.StatusAll(ctx, types.TrackerStatusPinning, false)
+		history, err = c.ConnectivityHistory(ctx, time.Now().Add(-30*time.Minute))
 		if err != nil {
 			t.Fatal(err)
 		}
-		if len(pins) != 1 {
-			t.Error("there should be one pin")
+		if len(history) != 1 {
+			t.Error("expected one snapshot")
 		}
+	}
 
-		pins, err = c.StatusAll(ctx, types.TrackerStatusPinned|types.TrackerStatusError, false)
+	testClients(t, api, testF)
+}
+
+func TestDetectorState(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		states, err := c.DetectorState(ctx)
 		if err != nil {
 			t.Fatal(err)
 		}
-		if len(pins) != 2 {
-			t.Error("there should be two pins")
-		}
 
-		_, err = c.StatusAll(ctx, 1<<25, false)
-		if err == nil {
-			t.Error("expected an error")
+		if len(states) != 2 {
+			t.Fatal("expected two detector states")
+		}
+		if states[1].Peer != test.PeerID2 || !states[1].Failed {
+			t.Error("unexpected detector state")
 		}
 	}
 
 	testClients(t, api, testF)
 }
 
-func TestRecover(t *testing.T) {
+func TestPinsByName(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
 	defer shutdown(api)
 
 	testF := func(t *testing.T, c Client) {
-		pin, err := c.Recover(ctx, test.Cid1, false)
+		pins, err := c.PinsByName(ctx, test.PinName3, false)
 		if err != nil {
 			t.Fatal(err)
 		}
-		if !pin.Cid.Equals(test.Cid1) {
-			t.Error("should be same pin")
+		if len(pins) != 1 || !pins[0].Cid.Equals(test.Cid3) {
+			t.Error("expected the pin with the given name")
+		}
+
+		pins, err = c.PinsByName(ctx, "test", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pins) != 1 {
+			t.Error("expected the pin with the given prefix")
 		}
 	}
 
 	testClients(t, api, testF)
 }
 
-func TestRecoverAll(t *testing.T) {
+func TestPinChanges(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
 	defer shutdown(api)
 
 	testF := func(t *testing.T, c Client) {
-		_, err := c.RecoverAll(ctx, true)
+		changes, err := c.PinChanges(ctx, 0)
 		if err != nil {
 			t.Fatal(err)
 		}
+		if changes.Sequence != test.PinSequence1 || len(changes.Changes) != 0 {
+			t.Error("expected only the current sequence number")
+		}
 
-		_, err = c.RecoverAll(ctx, false)
+		changes, err = c.PinChanges(ctx, test.PinSequence1-1)
 		if err != nil {
 			t.Fatal(err)
 		}
+		if len(changes.Changes) != 1 || !changes.Changes[0].Removed {
+			t.Error("expected the last change")
+		}
+
+		_, err = c.PinChanges(ctx, test.PinSequence1+1)
+		if err == nil {
+			t.Error("expected an error")
+		}
 	}
 
 	testClients(t, api, testF)
 }
 
-func TestAlerts(t *testing.T) {
+func TestOperations(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
 	defer shutdown(api)
 
 	testF := func(t *testing.T, c Client) {
-		alerts, err := c.Alerts(ctx)
+		ops, err := c.Operations(ctx)
 		if err != nil {
 			t.Fatal(err)
 		}
-		if len(alerts) != 1 {
-			t.Fatal("expected 1 alert")
+		if len(ops) != 1 {
+			t.Fatal("expected one operation")
 		}
-		pID2 := peer.Encode(test.PeerID2)
-		if alerts[0].Peer != test.PeerID2 {
-			t.Errorf("expected an alert from %s", pID2)
+
+		op, err := c.Operation(ctx, ops[0].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if op.Type != types.OperationRecoverAll {
+			t.Error("unexpected operation type")
+		}
+
+		op, err = c.CancelOperation(ctx, ops[0].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if op.Status != types.OperationCanceled {
+			t.Error("expected a canceled operation")
+		}
+
+		_, err = c.Operation(ctx, "abc")
+		if err == nil {
+			t.Error("expected an error for an unknown operation")
 		}
 	}
 
 	testClients(t, api, testF)
 }
 
-func TestGetConnectGraph(t *testing.T) {
+func TestRPCPolicy(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
 	defer shutdown(api)
 
 	testF := func(t *testing.T, c Client) {
-		cg, err := c.GetConnectGraph(ctx)
+		policy, err := c.RPCPolicy(ctx)
 		if err != nil {
 			t.Fatal(err)
 		}
-		if len(cg.IPFSLinks) != 3 || len(cg.ClusterLinks) != 3 ||
-			len(cg.ClustertoIPFS) != 3 {
-			t.Fatal("Bad graph")
+		if policy["Cluster.ID"] != "open" {
+			t.Error("expected Cluster.ID to be open")
+		}
+
+		policy, err = c.SetRPCPolicy(ctx, map[string]string{"Cluster.Pin": "trusted"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if policy["Cluster.Pin"] != "trusted" {
+			t.Error("expected Cluster.Pin to be trusted")
+		}
+
+		_, err = c.SetRPCPolicy(ctx, map[string]string{"Cluster.Abc": "open"})
+		if err == nil {
+			t.Error("expected an error for an unknown method")
 		}
 	}
 
 	testClients(t, api, testF)
 }
 
-func TestMetrics(t *testing.T) {
+func TestTrackerSettings(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
 	defer shutdown(api)
 
 	testF := func(t *testing.T, c Client) {
-		m, err := c.Metrics(ctx, "somemetricstype")
+		settings, err := c.TrackerSettings(ctx)
 		if err != nil {
 			t.Fatal(err)
 		}
+		if settings.ConcurrentPins != 10 {
+			t.Error("unexpected concurrent_pins:", settings.ConcurrentPins)
+		}
 
-		if len(m) == 0 {
-			t.Fatal("No metrics found")
+		settings, err = c.SetTrackerSettings(ctx, &types.TrackerSettings{ConcurrentPins: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if settings.ConcurrentPins != 2 {
+			t.Error("expected concurrent_pins to be changed")
 		}
 	}
 
 	testClients(t, api, testF)
 }
 
-func TestMetricNames(t *testing.T) {
+func TestAPIListeners(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
 	defer shutdown(api)
 
 	testF := func(t *testing.T, c Client) {
-		m, err := c.MetricNames(ctx)
+		listeners, err := c.APIListeners(ctx)
 		if err != nil {
 			t.Fatal(err)
 		}
+		if len(listeners) != 1 || listeners[0].API != "restapi" {
+			t.Fatal("unexpected listeners:", listeners)
+		}
 
-		if len(m) == 0 {
-			t.Fatal("No metric names found")
+		listeners[0].CORSAllowedOrigins = []string{"example.org"}
+		rebound, err := c.RebindAPI(ctx, listeners[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rebound.CORSAllowedOrigins) != 1 {
+			t.Error("expected the new CORS origins")
+		}
+
+		_, err = c.RebindAPI(ctx, &types.APIListeners{API: "other"})
+		if err == nil {
+			t.Error("expected an error rebinding an unknown API")
 		}
 	}
 
@@ -787,6 +1727,97 @@ func TestAddMultiFile(t *testing.T) {
 	testClients(t, api, testF)
 }
 
+func TestAddEnforcedParams(t *testing.T) {
+	ctx := context.Background()
+	cfg := rest.NewConfig()
+	cfg.Default()
+	cfg.AddParams = &common.AddParamsConfig{
+		Defaults: map[string]string{
+			"chunker":     "size-1000",
+			"cid-version": "1",
+		},
+		Enforce: true,
+	}
+	api := testAPIWithConfig(t, cfg)
+	defer shutdown(api)
+
+	sth := test.NewShardingTestHelper()
+	defer sth.Clean(t)
+
+	testF := func(t *testing.T, c Client) {
+		// Requests with the default parameters get the enforced values.
+		mfr, closer := sth.GetTreeMultiReader(t)
+		defer closer.Close()
+		out := make(chan *types.AddedOutput, 10)
+		go func() {
+			for range out {
+			}
+		}()
+		if err := c.AddMultiFile(ctx, mfr, types.DefaultAddParams(), out); err != nil {
+			t.Fatal("adding with the default parameters should work:", err)
+		}
+
+		mfr2, closer2 := sth.GetTreeMultiReader(t)
+		defer closer2.Close()
+		p := types.DefaultAddParams()
+		p.Chunker = "size-2000"
+		out2 := make(chan *types.AddedOutput, 10)
+		go func() {
+			for range out2 {
+			}
+		}()
+		if err := c.AddMultiFile(ctx, mfr2, p, out2); err == nil {
+			t.Error("changing an enforced parameter should fail")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestAddMultiFileChecksum(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer api.Shutdown(ctx)
+
+	sth := test.NewShardingTestHelper()
+	defer sth.Clean(t)
+
+	testF := func(t *testing.T, c Client) {
+		mfr, closer := sth.GetTreeMultiReader(t)
+		defer closer.Close()
+
+		p := types.DefaultAddParams()
+		p.ReplicationFactorMin = -1
+		p.ReplicationFactorMax = -1
+		p.Checksum = true
+
+		out := make(chan *types.AddedOutput, 1)
+		var last *types.AddedOutput
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for v := range out {
+				last = v
+			}
+		}()
+
+		// The API rejects requests with checksum=true which do not
+		// send a digest.
+		err := c.AddMultiFile(ctx, mfr, p, out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Wait()
+
+		if last == nil || last.Cid.String() != test.ShardingDirBalancedRootCID {
+			t.Error("bad root after adding:", last)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
 func TestRepoGC(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
@@ -0,0 +1,528 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	shell "github.com/ipfs/go-ipfs-api"
+	"github.com/ipfs/ipfs-cluster/api"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// Default values for PeerAwareOptions.
+var (
+	DefaultDiscoveryInterval = 30 * time.Second
+	DefaultHealthTimeout     = 5 * time.Second
+)
+
+// PeerAwareOptions configures a client created with NewPeerAwareClient.
+type PeerAwareOptions struct {
+	// Writers are the peers which receive the requests that modify the
+	// cluster (pinning, adding, peer management...), tried in the given
+	// order. When empty, or when none of them is healthy, those requests
+	// are sent to any healthy peer.
+	Writers []peer.ID
+	// DiscoveryInterval is how often the list of peers and their health
+	// is refreshed. The refresh happens in the background, triggered by
+	// requests.
+	DiscoveryInterval time.Duration
+	// HealthTimeout is the time given to every peer to answer the health
+	// check.
+	HealthTimeout time.Duration
+	// Retries is the number of peers a request is sent to before failing
+	// when they cannot be reached.
+	Retries int
+	// APIAddr returns the address of the REST API of a discovered peer. By
+	// default, libp2p seeds use the libp2p addresses of the peer and HTTP
+	// seeds use the IP of the peer with the port of the seed.
+	APIAddr func(seed *Config, id *api.ID) (ma.Multiaddr, error)
+}
+
+// peerPool keeps the clients for the healthy peers of a cluster. It is the
+// load balancing strategy for reads and writes.
+type peerPool struct {
+	seed    Client
+	seedCfg *Config
+	opts    PeerAwareOptions
+
+	mu      sync.RWMutex
+	clients map[peer.ID]*poolClient
+	readers []Client
+	writers []Client
+
+	lastDiscovery time.Time
+	discovering   int32
+	counter       uint32
+}
+
+type poolClient struct {
+	addr   string
+	client Client
+}
+
+// NewPeerAwareClient returns a client which discovers the peers of the
+// cluster through the one in the given configuration (the seed), checks
+// that their APIs are available, balances the read requests among the
+// healthy ones and sends the rest to the preferred opts.Writers. The seed is
+// used when no other peer is available. The configuration of the seed is
+// used for all peers, with their own API address.
+func NewPeerAwareClient(ctx context.Context, cfg *Config, opts PeerAwareOptions) (Client, error) {
+	seed, err := NewDefaultClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if opts.DiscoveryInterval <= 0 {
+		opts.DiscoveryInterval = DefaultDiscoveryInterval
+	}
+	if opts.HealthTimeout <= 0 {
+		opts.HealthTimeout = DefaultHealthTimeout
+	}
+	if opts.Retries <= 0 {
+		opts.Retries = 1
+	}
+	if opts.APIAddr == nil {
+		opts.APIAddr = defaultPeerAPIAddr
+	}
+
+	pool := &peerPool{
+		seed:    seed,
+		seedCfg: cfg,
+		opts:    opts,
+		clients: make(map[peer.ID]*poolClient),
+		readers: []Client{seed},
+		writers: []Client{seed},
+	}
+	pool.discover(ctx)
+
+	return &peerAwareClient{
+		Client: &loadBalancingClient{
+			strategy: &poolStrategy{pool: pool, writes: true},
+			retries:  opts.Retries,
+		},
+		reads: &loadBalancingClient{
+			strategy: &poolStrategy{pool: pool},
+			retries:  opts.Retries,
+		},
+	}, nil
+}
+
+// defaultPeerAPIAddr builds the API address of a peer from the address of
+// the seed.
+func defaultPeerAPIAddr(seed *Config, id *api.ID) (ma.Multiaddr, error) {
+	loopback := manet.IsIPLoopback(seed.APIAddr)
+
+	var candidates []ma.Multiaddr
+	for _, a := range id.Addresses {
+		if manet.IsIPLoopback(a.Value()) == loopback {
+			candidates = append(candidates, a.Value())
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no usable addresses for %s", id.ID)
+	}
+
+	if IsPeerAddress(seed.APIAddr) {
+		return candidates[0], nil
+	}
+
+	port, err := seed.APIAddr.ValueForProtocol(ma.P_TCP)
+	if err != nil {
+		return nil, err
+	}
+	tcp, err := ma.NewMultiaddr("/tcp/" + port)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range candidates {
+		ip := ma.Split(a)[0]
+		if _, err := manet.ToIP(ip); err == nil {
+			return ip.Encapsulate(tcp), nil
+		}
+	}
+	return nil, fmt.Errorf("no IP addresses for %s", id.ID)
+}
+
+// peerClient returns a client for the given peer, re-using the existing
+// one when its address has not changed.
+func (pool *peerPool) peerClient(id *api.ID) (Client, error) {
+	addr, err := pool.opts.APIAddr(pool.seedCfg, id)
+	if err != nil {
+		return nil, err
+	}
+
+	pool.mu.RLock()
+	pc, ok := pool.clients[id.ID]
+	pool.mu.RUnlock()
+	if ok && pc.addr == addr.String() {
+		return pc.client, nil
+	}
+
+	cfg := *pool.seedCfg
+	cfg.APIAddr = addr
+	cfg.ProxyAddr = nil
+	c, err := NewDefaultClient(&cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	pool.mu.Lock()
+	pool.clients[id.ID] = &poolClient{addr: addr.String(), client: c}
+	pool.mu.Unlock()
+	return c, nil
+}
+
+// discover obtains the list of peers and checks which ones are healthy.
+func (pool *peerPool) discover(ctx context.Context) {
+	if !atomic.CompareAndSwapInt32(&pool.discovering, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&pool.discovering, 0)
+
+	peers, err := pool.peers(ctx)
+	if err != nil {
+		logger.Warnf("error discovering the cluster peers: %s", err)
+		pool.mu.Lock()
+		pool.lastDiscovery = time.Now()
+		pool.mu.Unlock()
+		return
+	}
+
+	// Healthy peers by ID
+	var wg sync.WaitGroup
+	var healthyMu sync.Mutex
+	healthy := make(map[peer.ID]Client)
+	for _, id := range peers {
+		if id.Error != "" {
+			continue
+		}
+		c, err := pool.peerClient(id)
+		if err != nil {
+			logger.Debugf("not using %s: %s", id.ID, err)
+			continue
+		}
+		wg.Add(1)
+		go func(pid peer.ID, c Client) {
+			defer wg.Done()
+			if err := checkHealth(ctx, c, pid, pool.opts.HealthTimeout); err != nil {
+				logger.Debugf("%s is not healthy: %s", pid, err)
+				return
+			}
+			healthyMu.Lock()
+			healthy[pid] = c
+			healthyMu.Unlock()
+		}(id.ID, c)
+	}
+	wg.Wait()
+
+	pids := make([]peer.ID, 0, len(healthy))
+	for pid := range healthy {
+		pids = append(pids, pid)
+	}
+	sort.Slice(pids, func(i, j int) bool { return pids[i] < pids[j] })
+
+	readers := make([]Client, 0, len(pids))
+	for _, pid := range pids {
+		readers = append(readers, healthy[pid])
+	}
+
+	var writers []Client
+	for _, pid := range pool.opts.Writers {
+		if c, ok := healthy[pid]; ok {
+			writers = append(writers, c)
+		}
+	}
+	if len(writers) == 0 {
+		if len(pool.opts.Writers) > 0 && len(readers) > 0 {
+			logger.Warn("none of the preferred writers is healthy. Using any peer")
+		}
+		writers = readers
+	}
+
+	if len(readers) == 0 {
+		logger.Warn("no healthy cluster peers found. Using the seed peer")
+		readers = []Client{pool.seed}
+		writers = readers
+	}
+
+	pool.mu.Lock()
+	pool.readers = readers
+	pool.writers = writers
+	pool.lastDiscovery = time.Now()
+	pool.mu.Unlock()
+}
+
+// peers asks the known peers for the list of cluster peers, starting with
+// the seed.
+func (pool *peerPool) peers(ctx context.Context) ([]*api.ID, error) {
+	pool.mu.RLock()
+	candidates := append([]Client{pool.seed}, pool.readers...)
+	pool.mu.RUnlock()
+
+	err := errors.New("no peers to ask")
+	for _, c := range candidates {
+		var peers []*api.ID
+		tctx, cancel := context.WithTimeout(ctx, pool.opts.HealthTimeout)
+		peers, err = c.Peers(tctx)
+		cancel()
+		if err == nil {
+			return peers, nil
+		}
+	}
+	return nil, err
+}
+
+func checkHealth(ctx context.Context, c Client, pid peer.ID, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	id, err := c.ID(ctx)
+	if err != nil {
+		return err
+	}
+	if id.ID != pid {
+		return fmt.Errorf("the API belongs to %s", id.ID)
+	}
+	return nil
+}
+
+// maybeDiscover refreshes the peers in the background when the last
+// discovery is too old.
+func (pool *peerPool) maybeDiscover() {
+	pool.mu.RLock()
+	stale := time.Since(pool.lastDiscovery) > pool.opts.DiscoveryInterval
+	pool.mu.RUnlock()
+	if stale && atomic.LoadInt32(&pool.discovering) == 0 {
+		go pool.discover(context.Background())
+	}
+}
+
+// poolStrategy is a LBStrategy which uses the clients of a peerPool. Reads
+// go to all the healthy peers in a round robin fashion. Writes go to the
+// first healthy writer and fail over to the next ones.
+type poolStrategy struct {
+	pool   *peerPool
+	writes bool
+}
+
+// Next returns the next client to be used.
+func (s *poolStrategy) Next(count int) Client {
+	pool := s.pool
+	if count == 0 {
+		pool.maybeDiscover()
+	}
+
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+	if s.writes {
+		return pool.writers[count%len(pool.writers)]
+	}
+	i := atomic.AddUint32(&pool.counter, 1) % uint32(len(pool.readers))
+	return pool.readers[i]
+}
+
+// SetClients is a no-op: the clients are discovered.
+func (s *poolStrategy) SetClients(cl []Client) {}
+
+// peerAwareClient sends the requests which only read from the cluster to
+// any healthy peer. The rest go to the writers: this includes those which
+// modify the cluster and those about the settings or operations of a peer,
+// along with any method not listed here.
+type peerAwareClient struct {
+	Client
+	reads Client
+}
+
+// ID returns information about the cluster Peer.
+func (pc *peerAwareClient) ID(ctx context.Context) (*api.ID, error) {
+	return pc.reads.ID(ctx)
+}
+
+// Peers requests ID information for all cluster peers.
+func (pc *peerAwareClient) Peers(ctx context.Context) ([]*api.ID, error) {
+	return pc.reads.Peers(ctx)
+}
+
+// Peer requests ID information for the peer with the given peer ID or
+// peername.
+func (pc *peerAwareClient) Peer(ctx context.Context, name string) (*api.ID, error) {
+	return pc.reads.Peer(ctx, name)
+}
+
+// PeerVersions returns the versions run by the cluster peers.
+func (pc *peerAwareClient) PeerVersions(ctx context.Context) (*api.PeerVersions, error) {
+	return pc.reads.PeerVersions(ctx)
+}
+
+// PeerResources returns the resources used by the peer with the
+// given peer ID or peername and by its IPFS daemon.
+func (pc *peerAwareClient) PeerResources(ctx context.Context, name string) (*api.PeerResources, error) {
+	return pc.reads.PeerResources(ctx, name)
+}
+
+// PinDryRun validates and allocates a CID with the given options
+// without pinning it, returning the pin that would be committed.
+func (pc *peerAwareClient) PinDryRun(ctx context.Context, ci cid.Cid, opts api.PinOptions) (*api.Pin, error) {
+	return pc.reads.PinDryRun(ctx, ci, opts)
+}
+
+// PinTemplates returns the pin templates stored in the cluster.
+func (pc *peerAwareClient) PinTemplates(ctx context.Context) ([]*api.PinTemplate, error) {
+	return pc.reads.PinTemplates(ctx)
+}
+
+// PinTemplate returns the pin template with the given name.
+func (pc *peerAwareClient) PinTemplate(ctx context.Context, name string) (*api.PinTemplate, error) {
+	return pc.reads.PinTemplate(ctx, name)
+}
+
+// PinsetSnapshots returns the pinset snapshots taken in the peer.
+func (pc *peerAwareClient) PinsetSnapshots(ctx context.Context) ([]*api.PinsetSnapshot, error) {
+	return pc.reads.PinsetSnapshots(ctx)
+}
+
+// PinsetSnapshot returns the pinset snapshot with the given name,
+// including its pins.
+func (pc *peerAwareClient) PinsetSnapshot(ctx context.Context, name string) (*api.PinsetSnapshot, error) {
+	return pc.reads.PinsetSnapshot(ctx, name)
+}
+
+// Denylist returns the denylist entries of the peer.
+func (pc *peerAwareClient) Denylist(ctx context.Context) ([]*api.DenylistEntry, error) {
+	return pc.reads.Denylist(ctx)
+}
+
+// Allocations returns the consensus state listing all tracked items
+// and the peers that should be pinning them.
+func (pc *peerAwareClient) Allocations(ctx context.Context, filter api.PinType) ([]*api.Pin, error) {
+	return pc.reads.Allocations(ctx, filter)
+}
+
+// Allocation returns the current allocations for a given Cid.
+func (pc *peerAwareClient) Allocation(ctx context.Context, ci cid.Cid) (*api.Pin, error) {
+	return pc.reads.Allocation(ctx, ci)
+}
+
+// PinsByName returns the pins with the given name or, when prefix is
+// true, with names starting with the given string.
+func (pc *peerAwareClient) PinsByName(ctx context.Context, name string, prefix bool) ([]*api.Pin, error) {
+	return pc.reads.PinsByName(ctx, name, prefix)
+}
+
+// PinChanges returns the changes to the pinset after the given
+// sequence number. A since of 0 returns only the current sequence
+// number. Sequence numbers are specific to the peer answering the
+// request.
+func (pc *peerAwareClient) PinChanges(ctx context.Context, since uint64) (*api.PinChanges, error) {
+	return pc.reads.PinChanges(ctx, since)
+}
+
+// Status returns the current ipfs state for a given Cid. If local is true,
+// the information affects only the current peer, otherwise the information
+// is fetched from all cluster peers.
+func (pc *peerAwareClient) Status(ctx context.Context, ci cid.Cid, local bool) (*api.GlobalPinInfo, error) {
+	return pc.reads.Status(ctx, ci, local)
+}
+
+// StatusCids returns the status of the given Cids, in the same order,
+// with a single request.
+func (pc *peerAwareClient) StatusCids(ctx context.Context, cids []cid.Cid, local bool) ([]*api.GlobalPinInfo, error) {
+	return pc.reads.StatusCids(ctx, cids, local)
+}
+
+// StatusAll gathers Status() for all tracked items.
+func (pc *peerAwareClient) StatusAll(ctx context.Context, filter api.TrackerStatus, local bool) ([]*api.GlobalPinInfo, error) {
+	return pc.reads.StatusAll(ctx, filter, local)
+}
+
+// StatusAllStream is like StatusAll, but sends the items to the given
+// channel as they are decoded, without holding the whole response in
+// memory. The channel is closed when done.
+func (pc *peerAwareClient) StatusAllStream(ctx context.Context, filter api.TrackerStatus, local bool, out chan<- *api.GlobalPinInfo) error {
+	return pc.reads.StatusAllStream(ctx, filter, local, out)
+}
+
+// Shards returns the shards of a sharded pin along with their status.
+func (pc *peerAwareClient) Shards(ctx context.Context, ci cid.Cid) ([]*api.ShardInfo, error) {
+	return pc.reads.Shards(ctx, ci)
+}
+
+// Decrypt writes the decrypted content of the file at the given path
+// of an encrypted pin. The key is only needed for content encrypted
+// with a key supplied by the user.
+func (pc *peerAwareClient) Decrypt(ctx context.Context, ci cid.Cid, path string, key []byte, w io.Writer) error {
+	return pc.reads.Decrypt(ctx, ci, path, key, w)
+}
+
+// Alerts returns information health events in the cluster (expired
+// metrics etc.).
+func (pc *peerAwareClient) Alerts(ctx context.Context) ([]*api.Alert, error) {
+	return pc.reads.Alerts(ctx)
+}
+
+// StartupWarmup returns the progress of the reconciliation of the
+// pinset with IPFS that the peer does when it starts.
+func (pc *peerAwareClient) StartupWarmup(ctx context.Context) (*api.StartupWarmup, error) {
+	return pc.reads.StartupWarmup(ctx)
+}
+
+// LifecycleEvents returns the lifecycle events of the peer after the
+// given sequence number. Sequence numbers are specific to the peer
+// answering the request.
+func (pc *peerAwareClient) LifecycleEvents(ctx context.Context, since uint64) (*api.LifecycleEvents, error) {
+	return pc.reads.LifecycleEvents(ctx, since)
+}
+
+// ConsensusStats returns the internals of the consensus component of
+// every peer, like the Raft indexes or the CRDT heads, and how far
+// behind each peer is.
+func (pc *peerAwareClient) ConsensusStats(ctx context.Context) ([]*api.ConsensusStats, error) {
+	return pc.reads.ConsensusStats(ctx)
+}
+
+// Version returns the ipfs-cluster peer's version.
+func (pc *peerAwareClient) Version(ctx context.Context) (*api.Version, error) {
+	return pc.reads.Version(ctx)
+}
+
+// IPFS returns an instance of go-ipfs-api's Shell, pointing to a
+// Cluster's IPFS proxy endpoint.
+func (pc *peerAwareClient) IPFS(ctx context.Context) *shell.Shell {
+	return pc.reads.IPFS(ctx)
+}
+
+// GetConnectGraph returns an ipfs-cluster connection graph.
+func (pc *peerAwareClient) GetConnectGraph(ctx context.Context) (*api.ConnectGraph, error) {
+	return pc.reads.GetConnectGraph(ctx)
+}
+
+// ConnectivityHistory returns the connectivity snapshots recorded by
+// the contacted peer since the given time. A zero time returns all
+// of them.
+func (pc *peerAwareClient) ConnectivityHistory(ctx context.Context, since time.Time) ([]*api.ConnectivitySnapshot, error) {
+	return pc.reads.ConnectivityHistory(ctx, since)
+}
+
+// Metrics returns a map with the latest metrics of matching name
+// for the current cluster peers.
+func (pc *peerAwareClient) Metrics(ctx context.Context, name string) ([]*api.Metric, error) {
+	return pc.reads.Metrics(ctx, name)
+}
+
+// MetricNames returns the list of metric types.
+func (pc *peerAwareClient) MetricNames(ctx context.Context) ([]string, error) {
+	return pc.reads.MetricNames(ctx)
+}
+
+// DetectorState returns the state of the failure detector for the
+// latest metrics of every peer, as seen by the contacted peer.
+func (pc *peerAwareClient) DetectorState(ctx context.Context) ([]*api.DetectorState, error) {
+	return pc.reads.DetectorState(ctx)
+}
@@ -0,0 +1,115 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func peerAwarePool(t *testing.T, c Client) *peerPool {
+	pc, ok := c.(*peerAwareClient)
+	if !ok {
+		t.Fatal("expected a peerAwareClient")
+	}
+	return pc.Client.(*loadBalancingClient).strategy.(*poolStrategy).pool
+}
+
+func TestPeerAwareClient(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer shutdown(rest)
+
+	cfg := &Config{
+		APIAddr:           apiMAddr(rest),
+		DisableKeepAlives: true,
+	}
+	c, err := NewPeerAwareClient(ctx, cfg, PeerAwareOptions{
+		Writers: []peer.ID{test.PeerID1},
+		APIAddr: func(seed *Config, id *api.ID) (ma.Multiaddr, error) {
+			return seed.APIAddr, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := peerAwarePool(t, c)
+	if len(pool.readers) != 1 || pool.readers[0] == pool.seed {
+		t.Fatal("expected the discovered peer to be used")
+	}
+	if len(pool.writers) != 1 || pool.writers[0] != pool.readers[0] {
+		t.Error("expected the preferred writer to be used")
+	}
+
+	if _, err := c.ID(ctx); err != nil {
+		t.Error(err)
+	}
+	if _, err := c.Pin(ctx, test.Cid1, api.PinOptions{}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPeerAwareClientFallback(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer shutdown(rest)
+
+	// The mock peers have no addresses, so only the seed can be used.
+	cfg := &Config{
+		APIAddr:           apiMAddr(rest),
+		DisableKeepAlives: true,
+	}
+	c, err := NewPeerAwareClient(ctx, cfg, PeerAwareOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := peerAwarePool(t, c)
+	if len(pool.readers) != 1 || pool.readers[0] != pool.seed {
+		t.Fatal("expected the seed to be used")
+	}
+	if _, err := c.Pin(ctx, test.Cid1, api.PinOptions{}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDefaultPeerAPIAddr(t *testing.T) {
+	p2pAddr, _ := api.NewMultiaddr("/ip4/10.0.0.1/tcp/9096/p2p/" + test.PeerID2.Pretty())
+	loopbackAddr, _ := api.NewMultiaddr("/ip4/127.0.0.1/tcp/9096/p2p/" + test.PeerID2.Pretty())
+	id := &api.ID{
+		ID:        test.PeerID2,
+		Addresses: []api.Multiaddr{loopbackAddr, p2pAddr},
+	}
+
+	seed := &Config{APIAddr: ma.StringCast("/ip4/10.0.0.2/tcp/1234")}
+	addr, err := defaultPeerAPIAddr(seed, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr.String() != "/ip4/10.0.0.1/tcp/1234" {
+		t.Error("unexpected HTTP address:", addr)
+	}
+
+	seed = &Config{APIAddr: ma.StringCast("/ip4/10.0.0.2/tcp/9096/p2p/" + test.PeerID1.Pretty())}
+	addr, err = defaultPeerAPIAddr(seed, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !addr.Equal(p2pAddr.Value()) {
+		t.Error("unexpected libp2p address:", addr)
+	}
+
+	seed = &Config{APIAddr: ma.StringCast("/ip4/127.0.0.1/tcp/1234")}
+	addr, err = defaultPeerAPIAddr(seed, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr.String() != "/ip4/127.0.0.1/tcp/1234" {
+		t.Error("expected a loopback address for a loopback seed:", addr)
+	}
+}
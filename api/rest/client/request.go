@@ -1,12 +1,14 @@
 package client
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/ipfs/ipfs-cluster/api"
 
@@ -23,11 +25,13 @@ func (c *defaultClient) do(
 	obj interface{},
 ) error {
 
-	resp, err := c.doRequest(ctx, method, path, headers, body)
-	if err != nil {
-		return &api.Error{Code: 0, Message: err.Error()}
-	}
-	return c.handleResponse(resp, obj)
+	return c.withRetries(ctx, body, func() error {
+		resp, err := c.doRequest(ctx, method, path, headers, body)
+		if err != nil {
+			return &api.Error{Code: 0, Message: err.Error()}
+		}
+		return c.handleResponse(resp, obj)
+	})
 }
 
 func (c *defaultClient) doStream(
@@ -38,11 +42,46 @@ func (c *defaultClient) doStream(
 	outHandler responseDecoder,
 ) error {
 
-	resp, err := c.doRequest(ctx, method, path, headers, body)
-	if err != nil {
-		return &api.Error{Code: 0, Message: err.Error()}
+	// Retryable errors happen before anything is streamed.
+	return c.withRetries(ctx, body, func() error {
+		resp, err := c.doRequest(ctx, method, path, headers, body)
+		if err != nil {
+			return &api.Error{Code: 0, Message: err.Error()}
+		}
+		return c.handleStreamResponse(resp, outHandler)
+	})
+}
+
+// withRetries performs a request until it succeeds, fails with an error
+// which is not retryable or the configured retries are exhausted. Requests
+// with a body are performed only once, as it cannot be read again.
+func (c *defaultClient) withRetries(ctx context.Context, body io.Reader, req func() error) error {
+	err := req()
+	if body != nil {
+		return err
+	}
+
+	backoff := c.config.RetryBackoff
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
 	}
-	return c.handleStreamResponse(resp, outHandler)
+	for i := 0; i < c.config.Retries && IsRetryable(err); i++ {
+		logger.Debugf("retrying request in %s: %s", backoff, err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if backoff > MaxRetryBackoff {
+			backoff = MaxRetryBackoff
+		}
+		err = req()
+	}
+	return err
 }
 
 func (c *defaultClient) doRequest(
@@ -73,12 +112,19 @@ func (c *defaultClient) doRequest(
 		r.SetBasicAuth(c.config.Username, c.config.Password)
 	}
 
+	if id := api.RequestIDFromContext(ctx); id != "" {
+		r.Header.Set("X-Request-ID", id)
+	}
+
 	for k, v := range headers {
 		r.Header.Set(k, v)
 	}
 
 	if body != nil {
 		r.ContentLength = -1 // this lets go use "chunked".
+		if tb, ok := body.(interface{ Trailer() http.Header }); ok {
+			r.Trailer = tb.Trailer()
+		}
 	}
 
 	ctx = trace.NewContext(ctx, span)
@@ -138,17 +184,9 @@ func (c *defaultClient) handleStreamResponse(resp *http.Response, handler respon
 		}
 	}
 
-	dec := json.NewDecoder(resp.Body)
-	for {
-		err := handler(dec)
-		if err == io.EOF {
-			// we need to check trailers
-			break
-		}
-		if err != nil {
-			logger.Error(err)
-			return err
-		}
+	if err := decodeArrayOrStream(resp.Body, handler); err != nil {
+		logger.Error(err)
+		return err
 	}
 
 	errTrailer := resp.Trailer.Get("X-Stream-Error")
@@ -160,3 +198,56 @@ func (c *defaultClient) handleStreamResponse(resp *http.Response, handler respon
 	}
 	return nil
 }
+
+// decodeArrayOrStream calls the handler for every object in the given
+// input, which may be a JSON array of objects or a stream of objects, like
+// newline-delimited JSON. Arrays are decoded one item at a time.
+func decodeArrayOrStream(r io.Reader, handler responseDecoder) error {
+	br := bufio.NewReader(r)
+	first, err := peekNonSpace(br)
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(br)
+	if first != '[' {
+		for {
+			err := handler(dec)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := dec.Token(); err != nil { // [
+		return err
+	}
+	for dec.More() {
+		if err := handler(dec); err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token() // ]
+	return err
+}
+
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			br.ReadByte()
+		default:
+			return b[0], nil
+		}
+	}
+}
@@ -21,6 +21,9 @@ const (
 	DefaultWriteTimeout      = 0
 	DefaultIdleTimeout       = 120 * time.Second
 	DefaultMaxHeaderBytes    = minMaxHeaderBytes
+	// Only uploads to /add have large bodies, and they are not limited.
+	DefaultMaxBodyBytes          = 1 << 20 // 1MiB
+	DefaultMaxConcurrentRequests = 512
 )
 
 // Default values for Config.
@@ -64,6 +67,7 @@ func NewConfig() *Config {
 	cfg.Logger = logger
 	cfg.RequestLogger = apiLogger
 	cfg.DefaultFunc = defaultFunc
+	cfg.RouteNames = routeNames()
 	return &cfg
 }
 
@@ -97,6 +101,8 @@ func defaultFunc(cfg *common.Config) error {
 	cfg.WriteTimeout = DefaultWriteTimeout
 	cfg.IdleTimeout = DefaultIdleTimeout
 	cfg.MaxHeaderBytes = DefaultMaxHeaderBytes
+	cfg.MaxBodyBytes = DefaultMaxBodyBytes
+	cfg.MaxConcurrentRequests = DefaultMaxConcurrentRequests
 
 	// libp2p
 	cfg.ID = ""
@@ -106,8 +112,20 @@ func defaultFunc(cfg *common.Config) error {
 	// Auth
 	cfg.BasicAuthCredentials = nil
 
+	// Add
+	cfg.AddParams = nil
+	cfg.UserAddParams = nil
+	cfg.PinProfiles = nil
+	cfg.PinProfileMetadata = nil
+	cfg.EncryptionKeys = nil
+	cfg.UserPinProfiles = nil
+	cfg.Tenancy = nil
+	cfg.Policies = nil
+	cfg.Federation = nil
+
 	// Logs
 	cfg.HTTPLogFile = ""
+	cfg.RequestLog = nil
 
 	// Headers
 	cfg.Headers = DefaultHeaders
@@ -0,0 +1,91 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	types "github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/api/common"
+	"github.com/ipfs/ipfs-cluster/denylist"
+)
+
+// The denylist of the peer is managed under /denylist. New rules are posted
+// in the body, in the same format as the denylist files, and removed with
+// the "rule" parameter, as they are paths themselves.
+
+func (api *API) denylistHandler(w http.ResponseWriter, r *http.Request) {
+	var entries []*types.DenylistEntry
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"DenylistEntries",
+		struct{}{},
+		&entries,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, entries)
+}
+
+func (api *API) denylistAddHandler(w http.ResponseWriter, r *http.Request) {
+	entries, skipped, err := denylist.Parse(r.Body, "")
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+	if skipped > 0 {
+		api.SendResponse(w, http.StatusBadRequest, fmt.Errorf("%d rules are not supported", skipped), nil)
+		return
+	}
+	if len(entries) == 0 {
+		api.SendResponse(w, http.StatusBadRequest, errors.New("no rules given"), nil)
+		return
+	}
+
+	err = api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"DenylistAdd",
+		entries,
+		&struct{}{},
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, nil)
+}
+
+func (api *API) denylistRemoveHandler(w http.ResponseWriter, r *http.Request) {
+	rule := r.URL.Query().Get("rule")
+	if rule == "" {
+		api.SendResponse(w, http.StatusBadRequest, errors.New("the rule parameter is required"), nil)
+		return
+	}
+
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"DenylistRemove",
+		rule,
+		&struct{}{},
+	)
+	if err != nil && err.Error() == types.ErrDenylistEntryNotFound.Error() {
+		api.SendResponse(w, http.StatusNotFound, err, nil)
+		return
+	}
+	api.SendResponse(w, common.SetStatusAutomatically, err, nil)
+}
+
+// denylistEnforceHandler unpins the content matching the denylist and
+// returns the unpinned pins.
+func (api *API) denylistEnforceHandler(w http.ResponseWriter, r *http.Request) {
+	var pins []*types.Pin
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"DenylistEnforce",
+		struct{}{},
+		&pins,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, pins)
+}
@@ -0,0 +1,58 @@
+package rest
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	test "github.com/ipfs/ipfs-cluster/api/common/test"
+	clustertest "github.com/ipfs/ipfs-cluster/test"
+)
+
+func TestAPIDenylistEndpoints(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, u test.URLFunc) {
+		rule := "/ipfs/" + clustertest.Cid4.String()
+
+		var entries []*api.DenylistEntry
+		test.MakeGet(t, rest, u(rest)+"/denylist", &entries)
+		if len(entries) != 1 || entries[0].Rule != rule {
+			t.Error("unexpected entries: ", entries)
+		}
+
+		body := []byte("# comment\n" + rule + "\n!/ipfs/" + clustertest.Cid1.String() + "\n")
+		test.MakePost(t, rest, u(rest)+"/denylist", body, &struct{}{})
+
+		errResp := api.Error{}
+		test.MakePost(t, rest, u(rest)+"/denylist", []byte("/ipns/example.org\n"), &errResp)
+		if errResp.Code != 400 {
+			t.Error("expected a 400 with unsupported rules")
+		}
+
+		errResp = api.Error{}
+		test.MakePost(t, rest, u(rest)+"/denylist", []byte("# nothing\n"), &errResp)
+		if errResp.Code != 400 {
+			t.Error("expected a 400 without rules")
+		}
+
+		test.MakeDelete(t, rest, u(rest)+"/denylist?rule="+url.QueryEscape(rule), &struct{}{})
+
+		errResp = api.Error{}
+		test.MakeDelete(t, rest, u(rest)+"/denylist?rule="+url.QueryEscape("/ipfs/"+clustertest.Cid1.String()), &errResp)
+		if errResp.Code != 404 {
+			t.Error("expected a 404 removing an unknown rule")
+		}
+
+		var pins []*api.Pin
+		test.MakePost(t, rest, u(rest)+"/denylist/enforce", []byte{}, &pins)
+		if len(pins) != 1 || !pins[0].Cid.Equals(clustertest.Cid4) {
+			t.Error("unexpected unpinned pins: ", pins)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
@@ -0,0 +1,147 @@
+package rest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+
+	types "github.com/ipfs/ipfs-cluster/api"
+)
+
+// Checksummed uploads: add requests may carry the SHA-256 digest of their
+// body in the types.ContentDigestHeader header or, when the client computes
+// it while uploading, in a trailer with the same name. The body is hashed as
+// it is read and the digest verified once the adder has read all the files,
+// before pinning them.
+//
+// With the checksum parameter, each file part may also carry the digest of
+// its content in a types.ContentDigestHeader part header. Parts are
+// verified as soon as they have been read, so that a corrupted upload fails
+// without reading the rest of the body. Shards pinned while adding are
+// unpinned when the upload fails.
+
+// digestReader wraps the body of a request to compute its digest.
+type digestReader struct {
+	req      *http.Request
+	body     io.ReadCloser
+	hash     hash.Hash
+	expected []byte // from the header, nil when sent as a trailer
+}
+
+// newDigestReader wraps the body of the request when it declares a digest
+// in a header or trailer. Otherwise, it returns nil.
+func newDigestReader(r *http.Request) (*digestReader, error) {
+	var expected []byte
+	if v := r.Header.Get(types.ContentDigestHeader); v != "" {
+		sum, err := types.ParseContentDigest(v)
+		if err != nil {
+			return nil, err
+		}
+		expected = sum
+	} else if _, ok := r.Trailer[http.CanonicalHeaderKey(types.ContentDigestHeader)]; !ok {
+		return nil, nil
+	}
+
+	dr := &digestReader{
+		req:      r,
+		body:     r.Body,
+		hash:     sha256.New(),
+		expected: expected,
+	}
+	r.Body = dr
+	return dr, nil
+}
+
+func (dr *digestReader) Read(p []byte) (int, error) {
+	n, err := dr.body.Read(p)
+	dr.hash.Write(p[:n])
+	return n, err
+}
+
+func (dr *digestReader) Close() error {
+	return dr.body.Close()
+}
+
+// verify reads what is left of the body, which makes trailers available,
+// and compares its digest with the expected one.
+func (dr *digestReader) verify() error {
+	if _, err := io.Copy(ioutil.Discard, dr); err != nil {
+		return err
+	}
+
+	expected := dr.expected
+	if expected == nil {
+		v := dr.req.Trailer.Get(types.ContentDigestHeader)
+		if v == "" {
+			return fmt.Errorf("%w: the %s trailer is missing", types.ErrContentDigestMismatch, types.ContentDigestHeader)
+		}
+		sum, err := types.ParseContentDigest(v)
+		if err != nil {
+			return err
+		}
+		expected = sum
+	}
+
+	if !bytes.Equal(expected, dr.hash.Sum(nil)) {
+		return fmt.Errorf("%w: the upload may be corrupted", types.ErrContentDigestMismatch)
+	}
+	return nil
+}
+
+// verifyParts returns a multipart reader which copies the parts of r and
+// fails as soon as a part does not match the digest in its
+// types.ContentDigestHeader header. When required is set, every file part
+// must have one. The returned function must be called once done reading.
+func verifyParts(r *multipart.Reader, required bool) (*multipart.Reader, func()) {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(copyParts(mw, r, required))
+	}()
+	return multipart.NewReader(pr, mw.Boundary()), func() { pr.Close() }
+}
+
+func copyParts(mw *multipart.Writer, r *multipart.Reader, required bool) error {
+	for {
+		part, err := r.NextPart()
+		if err == io.EOF {
+			return mw.Close()
+		}
+		if err != nil {
+			return err
+		}
+
+		var expected []byte
+		if v := part.Header.Get(types.ContentDigestHeader); v != "" {
+			expected, err = types.ParseContentDigest(v)
+			if err != nil {
+				return err
+			}
+		} else if required && !isDirectoryPart(part) {
+			return fmt.Errorf("%w: the %s part has no %s header", types.ErrContentDigestMismatch, part.FileName(), types.ContentDigestHeader)
+		}
+
+		w, err := mw.CreatePart(part.Header)
+		if err != nil {
+			return err
+		}
+		sum := sha256.New()
+		if _, err := io.Copy(io.MultiWriter(w, sum), part); err != nil {
+			return err
+		}
+		if expected != nil && !bytes.Equal(expected, sum.Sum(nil)) {
+			return fmt.Errorf("%w: the %s part may be corrupted", types.ErrContentDigestMismatch, part.FileName())
+		}
+	}
+}
+
+func isDirectoryPart(part *multipart.Part) bool {
+	mediatype, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+	return mediatype == "multipart/form-data" || mediatype == "application/x-directory"
+}
@@ -0,0 +1,202 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ipfs/ipfs-cluster/adder/encryption"
+	types "github.com/ipfs/ipfs-cluster/api"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	files "github.com/ipfs/go-ipfs-files"
+	ipld "github.com/ipfs/go-ipld-format"
+	merkledag "github.com/ipfs/go-merkledag"
+	unixfile "github.com/ipfs/go-unixfs/file"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+)
+
+// Encrypted pins: add requests with the "encrypt" parameter encrypt the
+// files before chunking them, with one of the configured encryption keys
+// or, when the parameter is "user", with the key sent in the
+// EncryptionKeyHeader. GET /pins/{hash}/decrypt reads an encrypted file
+// from the IPFS daemon of the peer and responds with its decrypted
+// content.
+
+// EncryptionKeyHeader carries the key supplied by the user to encrypt or
+// decrypt content, hex or base64-encoded.
+const EncryptionKeyHeader = "X-Encryption-Key"
+
+// encryptionKey returns the key with the given name, which is taken from
+// the request when it is types.UserEncryptionKey.
+func (api *API) encryptionKey(r *http.Request, name string) ([]byte, error) {
+	if name != types.UserEncryptionKey {
+		return api.config.EncryptionKey(name)
+	}
+	hdr := r.Header.Get(EncryptionKeyHeader)
+	if hdr == "" {
+		return nil, fmt.Errorf("the %s header is required", EncryptionKeyHeader)
+	}
+	return encryption.ParseKey(hdr)
+}
+
+// prepareEncryption sets up the encryption of the content added with the
+// given parameters, when requested.
+func (api *API) prepareEncryption(r *http.Request, params *types.AddParams) error {
+	if params.Encrypt == "" {
+		return nil
+	}
+	key, err := api.encryptionKey(r, params.Encrypt)
+	if err != nil {
+		return err
+	}
+	return encryption.Prepare(params, params.Encrypt, key)
+}
+
+// decryptHandler takes the path of the file inside the pinned directory
+// from the "path" parameter.
+func (api *API) decryptHandler(w http.ResponseWriter, r *http.Request) {
+	pin := api.ParseCidOrFail(w, r)
+	if pin == nil || !api.checkOwnerOrFail(w, r, pin.Cid) {
+		return
+	}
+
+	var stored types.Pin
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PinGet",
+		pin.Cid,
+		&stored,
+	)
+	if err != nil {
+		api.SendResponse(w, http.StatusNotFound, err, nil)
+		return
+	}
+	encStr, ok := stored.Metadata[types.EncryptionMetaKey]
+	if !ok {
+		api.SendResponse(w, http.StatusBadRequest, errors.New("the pin is not encrypted"), nil)
+		return
+	}
+	enc, err := types.ParseEncryption(encStr)
+	if err != nil {
+		api.SendResponse(w, http.StatusInternalServerError, err, nil)
+		return
+	}
+	key, err := api.encryptionKey(r, enc.KeyName)
+	if err == nil {
+		err = encryption.Check(enc, key)
+	}
+	if err != nil {
+		api.SendResponse(w, http.StatusForbidden, err, nil)
+		return
+	}
+
+	f, err := unixfsPath(r.Context(), api.rpcClient, pin.Cid, r.URL.Query().Get("path"))
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+	defer f.Close()
+
+	api.SetHeaders(w)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Trailer", "X-Stream-Error")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, encryption.NewDecryptReader(f, key)); err != nil {
+		logger.Errorf("error decrypting %s: %s", pin.Cid, err)
+		w.Header().Set("X-Stream-Error", err.Error())
+	}
+}
+
+// unixfsPath returns the file at the given path of the UnixFS DAG with the
+// given root, reading it from the IPFS daemon of the peer.
+func unixfsPath(ctx context.Context, rpcClient *rpc.Client, root cid.Cid, path string) (files.File, error) {
+	dserv := merkledag.NewReadOnlyDagService(&rpcBlockGetter{rpcClient: rpcClient})
+	nd, err := dserv.Get(ctx, root)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s from ipfs: %w", root, err)
+	}
+	node, err := unixfile.NewUnixfsFile(ctx, dserv, nd)
+	if err != nil {
+		return nil, fmt.Errorf("error reading unixfs content: %w", err)
+	}
+
+	for _, name := range strings.Split(strings.Trim(path, "/"), "/") {
+		if name == "" {
+			continue
+		}
+		dir, ok := node.(files.Directory)
+		if !ok {
+			node.Close()
+			return nil, fmt.Errorf("%s: not a directory", path)
+		}
+		var next files.Node
+		it := dir.Entries()
+		for it.Next() {
+			if it.Name() == name {
+				next = it.Node()
+				break
+			}
+		}
+		if next == nil {
+			node.Close()
+			return nil, fmt.Errorf("%s: no such file", path)
+		}
+		node = next
+	}
+
+	f, ok := node.(files.File)
+	if !ok {
+		node.Close()
+		return nil, fmt.Errorf("%s: not a file", path)
+	}
+	return f, nil
+}
+
+// rpcBlockGetter is an ipld.NodeGetter which gets the blocks from the IPFS
+// daemon of the peer.
+type rpcBlockGetter struct {
+	rpcClient *rpc.Client
+}
+
+func (bg *rpcBlockGetter) Get(ctx context.Context, c cid.Cid) (ipld.Node, error) {
+	var data []byte
+	err := bg.rpcClient.CallContext(
+		ctx,
+		"",
+		"IPFSConnector",
+		"BlockGet",
+		c,
+		&data,
+	)
+	if err != nil {
+		return nil, err
+	}
+	b, err := blocks.NewBlockWithCid(data, c)
+	if err != nil {
+		return nil, err
+	}
+	return ipld.Decode(b)
+}
+
+func (bg *rpcBlockGetter) GetMany(ctx context.Context, cids []cid.Cid) <-chan *ipld.NodeOption {
+	out := make(chan *ipld.NodeOption, len(cids))
+	go func() {
+		defer close(out)
+		for _, c := range cids {
+			nd, err := bg.Get(ctx, c)
+			select {
+			case out <- &ipld.NodeOption{Node: nd, Err: err}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
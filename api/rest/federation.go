@@ -0,0 +1,227 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	types "github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/api/common"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+// federationTimeout limits how long the requests to every cluster of a
+// federated view can take.
+var federationTimeout = 30 * time.Second
+
+// federationUnavailableHeader lists the clusters of a federated view which
+// could not be reached, and whose objects are missing from the response.
+const federationUnavailableHeader = "X-Federation-Unavailable"
+
+var errNoFederation = errors.New("no federation is configured")
+
+// federationMembers returns the clusters in the federated view. The local
+// cluster, when included, has no URL.
+func (api *API) federationMembers() []*common.FederatedCluster {
+	fc := api.config.Federation
+	if fc.IsEmpty() {
+		return nil
+	}
+	var members []*common.FederatedCluster
+	if fc.Name != "" {
+		members = append(members, &common.FederatedCluster{Name: fc.Name})
+	}
+	return append(members, fc.Clusters...)
+}
+
+// federate calls f for every cluster in the federated view at the same
+// time, and returns the errors by cluster index.
+func (api *API) federate(ctx context.Context, f func(ctx context.Context, i int, fc *common.FederatedCluster) error) []error {
+	members := api.federationMembers()
+	errs := make([]error, len(members))
+	var wg sync.WaitGroup
+	for i, fc := range members {
+		wg.Add(1)
+		go func(i int, fc *common.FederatedCluster) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, federationTimeout)
+			defer cancel()
+			errs[i] = f(ctx, i, fc)
+			if errs[i] != nil {
+				logger.Warnf("federation: error querying %s: %s", fc.Name, errs[i])
+			}
+		}(i, fc)
+	}
+	wg.Wait()
+	return errs
+}
+
+// federatedGet requests a path from the REST API of a cluster and decodes
+// the JSON response into out.
+func federatedGet(ctx context.Context, fc *common.FederatedCluster, path string, query url.Values, out interface{}) error {
+	u := strings.TrimSuffix(fc.URL, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	if fc.Username != "" {
+		req.SetBasicAuth(fc.Username, fc.Password)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	dec := json.NewDecoder(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		var apiErr types.Error
+		if err := dec.Decode(&apiErr); err == nil && apiErr.Message != "" {
+			return fmt.Errorf("%d: %s", resp.StatusCode, apiErr.Message)
+		}
+		return fmt.Errorf("unexpected response status: %d", resp.StatusCode)
+	}
+	return dec.Decode(out)
+}
+
+// sendFederated sends the objects of the clusters which could be reached.
+// It fails when none could.
+func (api *API) sendFederated(w http.ResponseWriter, errs []error, resp interface{}) {
+	members := api.federationMembers()
+	var unavailable []string
+	for i, err := range errs {
+		if err != nil {
+			unavailable = append(unavailable, members[i].Name)
+		}
+	}
+	if len(unavailable) > 0 && len(unavailable) == len(members) {
+		api.SendResponse(w, http.StatusBadGateway, errors.New("no cluster in the federation could be reached"), nil)
+		return
+	}
+	if len(unavailable) > 0 {
+		w.Header().Set(federationUnavailableHeader, strings.Join(unavailable, ","))
+	}
+	api.SendResponse(w, common.SetStatusAutomatically, nil, resp)
+}
+
+func (api *API) federationPinsHandler(w http.ResponseWriter, r *http.Request) {
+	members := api.federationMembers()
+	if len(members) == 0 {
+		api.SendResponse(w, http.StatusNotFound, errNoFederation, nil)
+		return
+	}
+
+	filterStr := r.URL.Query().Get("filter")
+	filter := types.TrackerStatusFromString(filterStr)
+	if filter == types.TrackerStatusUndefined && filterStr != "" {
+		api.SendResponse(w, http.StatusBadRequest, errors.New("invalid filter value"), nil)
+		return
+	}
+	query := url.Values{}
+	if filterStr != "" {
+		query.Set("filter", filterStr)
+	}
+
+	results := make([][]*types.GlobalPinInfo, len(members))
+	errs := api.federate(r.Context(), func(ctx context.Context, i int, fc *common.FederatedCluster) error {
+		if fc.URL == "" {
+			return api.rpcClient.CallContext(ctx, "", "Cluster", "StatusAll", filter, &results[i])
+		}
+		return federatedGet(ctx, fc, "/pins", query, &results[i])
+	})
+
+	out := make([]types.FederatedGlobalPinInfo, 0)
+	for i, gpis := range results {
+		for _, gpi := range gpis {
+			out = append(out, types.FederatedGlobalPinInfo{Cluster: members[i].Name, GlobalPinInfo: gpi})
+		}
+	}
+	api.sendFederated(w, errs, out)
+}
+
+func (api *API) federationPeersHandler(w http.ResponseWriter, r *http.Request) {
+	members := api.federationMembers()
+	if len(members) == 0 {
+		api.SendResponse(w, http.StatusNotFound, errNoFederation, nil)
+		return
+	}
+
+	results := make([][]*types.ID, len(members))
+	errs := api.federate(r.Context(), func(ctx context.Context, i int, fc *common.FederatedCluster) error {
+		return api.federatedPeers(ctx, fc, &results[i])
+	})
+
+	out := make([]types.FederatedID, 0)
+	for i, ids := range results {
+		for _, id := range ids {
+			out = append(out, types.FederatedID{Cluster: members[i].Name, ID: id})
+		}
+	}
+	api.sendFederated(w, errs, out)
+}
+
+func (api *API) federatedPeers(ctx context.Context, fc *common.FederatedCluster, out *[]*types.ID) error {
+	if fc.URL == "" {
+		return api.rpcClient.CallContext(ctx, "", "Cluster", "Peers", struct{}{}, out)
+	}
+	return federatedGet(ctx, fc, "/peers", nil, out)
+}
+
+// federationHealthHandler summarizes the peers and alerts of every cluster.
+// Unlike the other routes, the clusters which cannot be reached are part of
+// the response, with an error.
+func (api *API) federationHealthHandler(w http.ResponseWriter, r *http.Request) {
+	members := api.federationMembers()
+	if len(members) == 0 {
+		api.SendResponse(w, http.StatusNotFound, errNoFederation, nil)
+		return
+	}
+
+	out := make([]types.FederatedHealth, len(members))
+	errs := api.federate(r.Context(), func(ctx context.Context, i int, fc *common.FederatedCluster) error {
+		var ids []*types.ID
+		if err := api.federatedPeers(ctx, fc, &ids); err != nil {
+			return err
+		}
+		var alerts []types.Alert
+		var err error
+		if fc.URL == "" {
+			err = api.rpcClient.CallContext(ctx, "", "Cluster", "Alerts", struct{}{}, &alerts)
+		} else {
+			err = federatedGet(ctx, fc, "/health/alerts", nil, &alerts)
+		}
+		if err != nil {
+			return err
+		}
+
+		withErrors := make([]peer.ID, 0)
+		for _, id := range ids {
+			if id.Error != "" {
+				withErrors = append(withErrors, id.ID)
+			}
+		}
+		out[i].Peers = len(ids)
+		out[i].PeersWithErrors = withErrors
+		out[i].Alerts = alerts
+		return nil
+	})
+
+	for i, err := range errs {
+		out[i].Cluster = members[i].Name
+		if err != nil {
+			out[i].Error = err.Error()
+		}
+	}
+	api.SendResponse(w, common.SetStatusAutomatically, nil, out)
+}
@@ -0,0 +1,128 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/api/common"
+	test "github.com/ipfs/ipfs-cluster/api/common/test"
+)
+
+func testFederationAPI(t *testing.T, remote *API) *API {
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.Federation = &common.FederationConfig{
+		Name: "local",
+		Clusters: []*common.FederatedCluster{
+			{Name: "remote", URL: test.HTTPURL(remote)},
+			{Name: "down", URL: "http://127.0.0.1:1"},
+		},
+	}
+	return testAPIwithConfig(t, cfg, "federation")
+}
+
+func federationGet(t *testing.T, rest *API, path string, resp interface{}) http.Header {
+	t.Helper()
+	httpResp, err := http.Get(test.HTTPURL(rest) + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		t.Fatalf("%s: unexpected status %d", path, httpResp.StatusCode)
+	}
+	if err := json.NewDecoder(httpResp.Body).Decode(resp); err != nil {
+		t.Fatal(err)
+	}
+	return httpResp.Header
+}
+
+func TestAPIFederationEndpoints(t *testing.T) {
+	ctx := context.Background()
+	remote := testAPI(t)
+	defer remote.Shutdown(ctx)
+	rest := testFederationAPI(t, remote)
+	defer rest.Shutdown(ctx)
+
+	t.Run("pins", func(t *testing.T) {
+		var resp []api.FederatedGlobalPinInfo
+		h := federationGet(t, rest, "/federation/pins", &resp)
+		if h.Get(federationUnavailableHeader) != "down" {
+			t.Errorf("expected the down cluster to be reported: %q", h.Get(federationUnavailableHeader))
+		}
+		counts := make(map[string]int)
+		for _, gpi := range resp {
+			if gpi.GlobalPinInfo == nil || !gpi.Cid.Defined() {
+				t.Fatal("expected pin information")
+			}
+			counts[gpi.Cluster]++
+		}
+		if len(counts) != 2 || counts["local"] != 3 || counts["remote"] != 3 {
+			t.Errorf("unexpected pins by cluster: %v", counts)
+		}
+	})
+
+	t.Run("peers", func(t *testing.T) {
+		var resp []api.FederatedID
+		federationGet(t, rest, "/federation/peers", &resp)
+		counts := make(map[string]int)
+		for _, id := range resp {
+			counts[id.Cluster]++
+		}
+		if counts["local"] == 0 || counts["local"] != counts["remote"] || counts["down"] != 0 {
+			t.Errorf("unexpected peers by cluster: %v", counts)
+		}
+	})
+
+	t.Run("health", func(t *testing.T) {
+		var resp []api.FederatedHealth
+		federationGet(t, rest, "/federation/health", &resp)
+		if len(resp) != 3 {
+			t.Fatalf("expected the health of the 3 clusters: %+v", resp)
+		}
+		for _, fh := range resp {
+			switch fh.Cluster {
+			case "local", "remote":
+				if fh.Error != "" || fh.Peers == 0 || len(fh.Alerts) != 1 {
+					t.Errorf("unexpected health for %s: %+v", fh.Cluster, fh)
+				}
+			case "down":
+				if fh.Error == "" {
+					t.Error("expected an error for the down cluster")
+				}
+			default:
+				t.Errorf("unexpected cluster %s", fh.Cluster)
+			}
+		}
+	})
+
+	t.Run("bad filter", func(t *testing.T) {
+		httpResp, err := http.Get(test.HTTPURL(rest) + "/federation/pins?filter=nope")
+		if err != nil {
+			t.Fatal(err)
+		}
+		httpResp.Body.Close()
+		if httpResp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected a bad request: %d", httpResp.StatusCode)
+		}
+	})
+}
+
+func TestAPIFederationNotConfigured(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		errResp := api.Error{}
+		test.MakeGet(t, rest, url(rest)+"/federation/pins", &errResp)
+		if errResp.Code != http.StatusNotFound {
+			t.Errorf("expected a 404 without federation: %+v", errResp)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
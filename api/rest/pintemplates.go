@@ -0,0 +1,189 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	types "github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/api/common"
+	"github.com/ipfs/ipfs-cluster/state"
+
+	mux "github.com/gorilla/mux"
+	cid "github.com/ipfs/go-cid"
+)
+
+// Pin templates are named sets of pin options. They are managed under
+// /pintemplates and, along with existing pins, can be used as the source of
+// the options of new pins with POST /pins/{hash}/clone.
+
+func (api *API) pinTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	var tmpls []*types.PinTemplate
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PinTemplates",
+		struct{}{},
+		&tmpls,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, tmpls)
+}
+
+func (api *API) pinTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := api.getPinTemplate(r, mux.Vars(r)["name"])
+	if err != nil && err.Error() == types.ErrPinTemplateNotFound.Error() {
+		api.SendResponse(w, http.StatusNotFound, err, nil)
+		return
+	}
+	api.SendResponse(w, common.SetStatusAutomatically, err, tmpl)
+}
+
+func (api *API) getPinTemplate(r *http.Request, name string) (*types.PinTemplate, error) {
+	var tmpl types.PinTemplate
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PinTemplate",
+		name,
+		&tmpl,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &tmpl, nil
+}
+
+// pinTemplateSetHandler takes the options of the template from the query,
+// like when pinning, with an expire-in duration as the expiry.
+func (api *API) pinTemplateSetHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	tmpl := types.PinTemplate{
+		Name: mux.Vars(r)["name"],
+	}
+	if err := tmpl.Options.FromQuery(query); err != nil {
+		api.SendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+	if expireIn := query.Get("expire-in"); expireIn != "" {
+		d, err := time.ParseDuration(expireIn)
+		if err != nil {
+			api.SendResponse(w, http.StatusBadRequest, errors.New("error parsing expire-in: "+err.Error()), nil)
+			return
+		}
+		tmpl.ExpireIn = d
+	}
+
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PinTemplateSet",
+		&tmpl,
+		&struct{}{},
+	)
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	saved, err := api.getPinTemplate(r, tmpl.Name)
+	api.SendResponse(w, common.SetStatusAutomatically, err, saved)
+}
+
+func (api *API) pinTemplateRemoveHandler(w http.ResponseWriter, r *http.Request) {
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PinTemplateRemove",
+		mux.Vars(r)["name"],
+		&struct{}{},
+	)
+	if err != nil && err.Error() == types.ErrPinTemplateNotFound.Error() {
+		api.SendResponse(w, http.StatusNotFound, err, nil)
+		return
+	}
+	api.SendResponse(w, common.SetStatusAutomatically, err, nil)
+}
+
+// pinCloneHandler pins a CID with the options of the pin given with "from"
+// or of the template given with "template". The name of the new pin is
+// taken from the "name" parameter.
+func (api *API) pinCloneHandler(w http.ResponseWriter, r *http.Request) {
+	ci, err := cid.Decode(mux.Vars(r)["hash"])
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, errors.New("error decoding Cid: "+err.Error()), nil)
+		return
+	}
+
+	query := r.URL.Query()
+	fromStr := query.Get("from")
+	template := query.Get("template")
+	if (fromStr == "") == (template == "") {
+		api.SendResponse(w, http.StatusBadRequest, errors.New("either from or template must be set"), nil)
+		return
+	}
+
+	var opts types.PinOptions
+	if fromStr != "" {
+		from, err := cid.Decode(fromStr)
+		if err != nil {
+			api.SendResponse(w, http.StatusBadRequest, errors.New("error decoding from Cid: "+err.Error()), nil)
+			return
+		}
+		if !api.checkOwnerOrFail(w, r, from) {
+			return
+		}
+		var source types.Pin
+		err = api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			"PinGet",
+			from,
+			&source,
+		)
+		if err != nil {
+			status := common.SetStatusAutomatically
+			if err.Error() == state.ErrNotFound.Error() {
+				status = http.StatusNotFound
+			}
+			api.SendResponse(w, status, err, nil)
+			return
+		}
+		opts = source.CloneOptions(time.Now())
+	} else {
+		tmpl, err := api.getPinTemplate(r, template)
+		if err != nil {
+			status := common.SetStatusAutomatically
+			if err.Error() == types.ErrPinTemplateNotFound.Error() {
+				status = http.StatusNotFound
+			}
+			api.SendResponse(w, status, err, nil)
+			return
+		}
+		opts = tmpl.PinOptions(time.Now())
+	}
+	opts.Name = query.Get("name")
+
+	pin := types.PinWithOpts(ci, opts)
+	pin.MaxDepth = -1 // For now, all pins are recursive
+	release, ok := api.checkPinOrFail(w, r, pin.Cid, &pin.PinOptions)
+	if !ok {
+		return
+	}
+	defer release()
+
+	var pinObj types.Pin
+	err = api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"Pin",
+		pin,
+		&pinObj,
+	)
+	api.SendResponse(w, pinErrorStatus(err), err, pinObj)
+}
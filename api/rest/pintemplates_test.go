@@ -0,0 +1,95 @@
+package rest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	test "github.com/ipfs/ipfs-cluster/api/common/test"
+	clustertest "github.com/ipfs/ipfs-cluster/test"
+)
+
+func TestAPIPinTemplatesEndpoints(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var tmpls []*api.PinTemplate
+		test.MakeGet(t, rest, url(rest)+"/pintemplates", &tmpls)
+		if len(tmpls) != 1 || tmpls[0].Name != clustertest.PinTemplateName {
+			t.Error("unexpected templates: ", tmpls)
+		}
+
+		var tmpl api.PinTemplate
+		test.MakeGet(t, rest, url(rest)+"/pintemplates/"+clustertest.PinTemplateName, &tmpl)
+		if tmpl.ExpireIn != 24*time.Hour || tmpl.Options.ReplicationFactorMax != 3 {
+			t.Error("unexpected template: ", tmpl)
+		}
+
+		errResp := api.Error{}
+		test.MakeGet(t, rest, url(rest)+"/pintemplates/other", &errResp)
+		if errResp.Code != 404 {
+			t.Error("expected a 404 for an unknown template")
+		}
+
+		test.MakePost(t, rest, url(rest)+"/pintemplates/"+clustertest.PinTemplateName+"?replication=2&expire-in=24h", []byte{}, &tmpl)
+		if tmpl.Name != clustertest.PinTemplateName {
+			t.Error("expected the saved template")
+		}
+
+		errResp = api.Error{}
+		test.MakePost(t, rest, url(rest)+"/pintemplates/"+clustertest.PinTemplateName+"?expire-in=abc", []byte{}, &errResp)
+		if errResp.Code != 400 {
+			t.Error("expected a 400 with a bad expire-in")
+		}
+
+		test.MakeDelete(t, rest, url(rest)+"/pintemplates/"+clustertest.PinTemplateName, &struct{}{})
+
+		errResp = api.Error{}
+		test.MakeDelete(t, rest, url(rest)+"/pintemplates/other", &errResp)
+		if errResp.Code != 404 {
+			t.Error("expected a 404 removing an unknown template")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIPinCloneEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var pin api.Pin
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.Cid4.String()+"/clone?from="+clustertest.Cid2.String()+"&name=copy", []byte{}, &pin)
+		if !pin.Cid.Equals(clustertest.Cid4) || pin.Name != "copy" || pin.ReplicationFactorMax != 1 {
+			t.Error("unexpected cloned pin: ", pin)
+		}
+
+		pin = api.Pin{}
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.Cid4.String()+"/clone?template="+clustertest.PinTemplateName, []byte{}, &pin)
+		if pin.ReplicationFactorMin != 2 || pin.Metadata["team"] != "a" {
+			t.Error("unexpected pin from template: ", pin)
+		}
+		if d := time.Until(pin.ExpireAt); d < 23*time.Hour || d > 24*time.Hour {
+			t.Error("expected the pin to expire in 24h: ", pin.ExpireAt)
+		}
+
+		errResp := api.Error{}
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.Cid4.String()+"/clone?template=other", []byte{}, &errResp)
+		if errResp.Code != 404 {
+			t.Error("expected a 404 with an unknown template")
+		}
+
+		errResp = api.Error{}
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.Cid4.String()+"/clone", []byte{}, &errResp)
+		if errResp.Code != 400 {
+			t.Error("expected a 400 without from or template")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
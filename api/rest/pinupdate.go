@@ -0,0 +1,45 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+// withPinUpdate allows the pin-update parameter of pin requests to be an
+// IPFS path, which is resolved to the CID of the pin being updated. The
+// pin being updated must belong to the namespace of the request, as the
+// new pin inherits its options.
+func (api *API) withPinUpdate(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		from := query.Get("pin-update")
+		if from == "" {
+			h(w, r)
+			return
+		}
+
+		ci, err := cid.Decode(from)
+		if err != nil {
+			if !strings.HasPrefix(from, "/") {
+				api.SendResponse(w, http.StatusBadRequest, errors.New("pin-update must be a CID or an IPFS path"), nil)
+				return
+			}
+			var ok bool
+			ci, ok = api.resolveOrFail(w, r, from)
+			if !ok {
+				return
+			}
+			query.Set("pin-update", ci.String())
+			r = r.Clone(r.Context())
+			r.URL.RawQuery = query.Encode()
+		}
+
+		if !api.checkOwnerOrFail(w, r, ci) {
+			return
+		}
+		h(w, r)
+	}
+}
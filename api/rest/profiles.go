@@ -0,0 +1,91 @@
+package rest
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	types "github.com/ipfs/ipfs-cluster/api"
+)
+
+// Pin profiles: the configuration can define named sets of pin and add
+// parameters. Requests to the pin and add endpoints select one with the
+// "profile" parameter, or get the default profile of their authenticated
+// user. The
+// parameters of the profile are added to the request, except those that the
+// request sets itself. The resulting metadata must then follow the metadata
+// policy of the profile, if any.
+
+// Parameters that override each other when parsing the pin options.
+var profileParamConflicts = map[string][]string{
+	"replication":     {"replication-min", "replication-max"},
+	"replication-min": {"replication"},
+	"replication-max": {"replication"},
+	"expire-in":       {"expire-at"},
+	"expire-at":       {"expire-in"},
+}
+
+// withPinProfile wraps the handlers of pin and add endpoints to apply the
+// selected pin profile to the request query.
+func (api *API) withPinProfile(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := types.RequestUserFromContext(r.Context())
+		query := r.URL.Query()
+		profile, err := api.config.PinProfileFor(user, query.Get("profile"))
+		if err != nil {
+			api.SendResponse(w, http.StatusBadRequest, err, nil)
+			return
+		}
+		policy, _ := api.config.PinProfileMetadataFor(user, query.Get("profile"))
+		if len(profile) == 0 && policy == nil {
+			h(w, r)
+			return
+		}
+
+		applyPinProfile(query, profile)
+		if err := policy.Check(queryMetadata(query)); err != nil {
+			api.SendResponse(w, http.StatusBadRequest, err, nil)
+			return
+		}
+		r = r.Clone(r.Context())
+		r.URL.RawQuery = query.Encode()
+		h(w, r)
+	}
+}
+
+// queryMetadata returns the pin metadata set by the "meta-" parameters.
+func queryMetadata(query url.Values) map[string]string {
+	meta := make(map[string]string)
+	for k := range query {
+		if key := strings.TrimPrefix(k, "meta-"); key != k && key != "" {
+			meta[key] = query.Get(k)
+		}
+	}
+	return meta
+}
+
+// applyPinProfile sets the parameters of a profile in the query, unless the
+// query sets them or parameters which would override them.
+func applyPinProfile(query url.Values, profile map[string]string) {
+	set := func(k string) bool {
+		if _, ok := query[k]; ok {
+			return true
+		}
+		for _, other := range profileParamConflicts[k] {
+			if _, ok := query[other]; ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	values := make(map[string]string, len(profile))
+	for k, v := range profile {
+		if !set(k) {
+			values[k] = v
+		}
+	}
+	for k, v := range values {
+		query.Set(k, v)
+	}
+}
@@ -0,0 +1,114 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	test "github.com/ipfs/ipfs-cluster/api/common/test"
+	clustertest "github.com/ipfs/ipfs-cluster/test"
+)
+
+func TestAPIPinProfiles(t *testing.T) {
+	ctx := context.Background()
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.CORSAllowedOrigins = []string{clientOrigin}
+	cfg.CORSAllowedMethods = []string{"GET", "POST", "DELETE"}
+	cfg.PinProfiles = map[string]map[string]string{
+		"archive": {
+			"replication": "3",
+			"expire-in":   "24h",
+			"meta-tier":   "cold",
+		},
+		"scratch": {
+			"replication-min": "1",
+			"replication-max": "2",
+		},
+	}
+	cfg.UserPinProfiles = map[string]string{
+		validUserName: "scratch",
+	}
+	cfg.PinProfileMetadata = map[string]*api.MetadataPolicy{
+		"archive": {
+			MaxKeys: 2,
+			Schema: &api.MetadataSchema{
+				Required: []string{"tier"},
+				Properties: map[string]*api.MetadataValueSchema{
+					"owner": {Pattern: "^[a-z]+$"},
+				},
+			},
+		},
+	}
+	rest := testAPIwithConfig(t, cfg, "pin profiles")
+	defer rest.Shutdown(ctx)
+
+	var query url.Values
+	h := rest.withPinProfile(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+	})
+	newReq := func(q, user string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/pins/"+clustertest.Cid1.String()+"?"+q, nil)
+		return r.WithContext(api.ContextWithRequestUser(r.Context(), user))
+	}
+
+	h(httptest.NewRecorder(), newReq("profile=archive&expire-at=2030-01-01T00:00:00Z&replication-min=2", ""))
+	if query.Get("meta-tier") != "cold" {
+		t.Error("profile parameters not applied:", query)
+	}
+	if query.Get("replication") != "" || query.Get("expire-in") != "" {
+		t.Error("profile parameters should not override the request:", query)
+	}
+
+	query = nil
+	h(httptest.NewRecorder(), newReq("replication-max=5", validUserName))
+	if query.Get("replication-min") != "1" || query.Get("replication-max") != "5" {
+		t.Error("user profile not applied:", query)
+	}
+
+	query = nil
+	h(httptest.NewRecorder(), newReq("", ""))
+	if len(query) != 0 {
+		t.Error("no profile should apply:", query)
+	}
+
+	// A user which was not authenticated does not get its profile.
+	query = nil
+	r := httptest.NewRequest(http.MethodPost, "/pins/"+clustertest.Cid1.String(), nil)
+	r.SetBasicAuth(validUserName, "")
+	h(httptest.NewRecorder(), r)
+	if len(query) != 0 {
+		t.Error("the user profile should not apply:", query)
+	}
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var pin api.Pin
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String()+"?profile=archive", []byte{}, &pin)
+		if pin.ReplicationFactorMin != 3 || pin.ReplicationFactorMax != 3 || pin.Metadata["tier"] != "cold" {
+			t.Error("profile not applied to the pin:", pin)
+		}
+
+		errResp := api.Error{}
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String()+"?profile=other", []byte{}, &errResp)
+		if errResp.Code != 400 {
+			t.Error("expected a 400 with an unknown profile")
+		}
+
+		errResp = api.Error{}
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String()+"?profile=archive&meta-owner=Bob", []byte{}, &errResp)
+		if errResp.Code != 400 {
+			t.Error("expected a 400 with metadata not matching the profile schema")
+		}
+
+		errResp = api.Error{}
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String()+"?profile=archive&meta-a=1&meta-b=2", []byte{}, &errResp)
+		if errResp.Code != 400 {
+			t.Error("expected a 400 with too many metadata keys")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
@@ -7,19 +7,27 @@
 package rest
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"math/rand"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ipfs/ipfs-cluster/adder/adderutils"
 	types "github.com/ipfs/ipfs-cluster/api"
 	"github.com/ipfs/ipfs-cluster/api/common"
+	"github.com/ipfs/ipfs-cluster/api/graph"
 	"github.com/ipfs/ipfs-cluster/state"
 
+	cid "github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log/v2"
 	"github.com/libp2p/go-libp2p-core/host"
 	peer "github.com/libp2p/go-libp2p-core/peer"
@@ -48,6 +56,9 @@ type API struct {
 
 	rpcClient *rpc.Client
 	config    *Config
+
+	// quotaLocks holds a *sync.Mutex for every namespace with a quota.
+	quotaLocks sync.Map
 }
 
 // NewAPI creates a new REST API component.
@@ -66,6 +77,16 @@ func NewAPIWithHost(ctx context.Context, cfg *Config, h host.Host) (*API, error)
 }
 
 // Routes returns endpoints supported by this API.
+// routeNames returns the names of the routes of the API.
+func routeNames() []string {
+	routes := new(API).routes(nil)
+	names := make([]string, 0, len(routes))
+	for _, r := range routes {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
 func (api *API) routes(c *rpc.Client) []common.Route {
 	api.rpcClient = c
 	return []common.Route{
@@ -93,19 +114,45 @@ func (api *API) routes(c *rpc.Client) []common.Route {
 			Name:        "PeerAdd",
 			Method:      "POST",
 			Pattern:     "/peers",
-			HandlerFunc: api.peerAddHandler,
+			HandlerFunc: api.adminOnly(api.peerAddHandler),
+		},
+		{
+			// Before "Peer", which would match it too.
+			Name:        "PeerVersions",
+			Method:      "GET",
+			Pattern:     "/peers/versions",
+			HandlerFunc: api.peerVersionsHandler,
+		},
+		{
+			Name:        "Peer",
+			Method:      "GET",
+			Pattern:     "/peers/{peer}",
+			HandlerFunc: api.peerHandler,
 		},
 		{
 			Name:        "PeerRemove",
 			Method:      "DELETE",
 			Pattern:     "/peers/{peer}",
-			HandlerFunc: api.peerRemoveHandler,
+			HandlerFunc: api.adminOnly(api.peerRemoveHandler),
+		},
+		{
+			Name:        "PeerResources",
+			Method:      "GET",
+			Pattern:     "/peers/{peer}/resources",
+			HandlerFunc: api.peerResourcesHandler,
 		},
 		{
-			Name:        "Add",
+			Name:        "PeerHandover",
 			Method:      "POST",
-			Pattern:     "/add",
-			HandlerFunc: api.addHandler,
+			Pattern:     "/peers/{peer}/handover",
+			HandlerFunc: api.adminOnly(api.peerHandoverHandler),
+		},
+		{
+			Name:          "Add",
+			Method:        "POST",
+			Pattern:       "/add",
+			HandlerFunc:   api.withPinProfile(api.addHandler),
+			UnlimitedBody: true,
 		},
 		{
 			Name:        "Allocations",
@@ -113,6 +160,12 @@ func (api *API) routes(c *rpc.Client) []common.Route {
 			Pattern:     "/allocations",
 			HandlerFunc: api.allocationsHandler,
 		},
+		{
+			Name:        "AllocationChanges",
+			Method:      "GET",
+			Pattern:     "/allocations/changes",
+			HandlerFunc: api.adminOnly(api.allocationChangesHandler),
+		},
 		{
 			Name:        "Allocation",
 			Method:      "GET",
@@ -125,6 +178,18 @@ func (api *API) routes(c *rpc.Client) []common.Route {
 			Pattern:     "/pins",
 			HandlerFunc: api.statusAllHandler,
 		},
+		{
+			Name:        "UnpinMatching",
+			Method:      "DELETE",
+			Pattern:     "/pins",
+			HandlerFunc: api.unpinMatchingHandler,
+		},
+		{
+			Name:        "Reshard",
+			Method:      "POST",
+			Pattern:     "/pins/{hash}/reshard",
+			HandlerFunc: api.reshardHandler,
+		},
 		{
 			Name:        "Recover",
 			Method:      "POST",
@@ -135,7 +200,43 @@ func (api *API) routes(c *rpc.Client) []common.Route {
 			Name:        "RecoverAll",
 			Method:      "POST",
 			Pattern:     "/pins/recover",
-			HandlerFunc: api.recoverAllHandler,
+			HandlerFunc: api.withSelector(api.recoverMatchingHandler, api.adminOnly(api.recoverAllHandler)),
+		},
+		{
+			Name:        "ReplicateMatching",
+			Method:      "POST",
+			Pattern:     "/pins/replicate",
+			HandlerFunc: api.replicateMatchingHandler,
+		},
+		{
+			Name:        "StatusCids",
+			Method:      "POST",
+			Pattern:     "/pins/status",
+			HandlerFunc: api.statusCidsHandler,
+		},
+		{
+			Name:        "Shards",
+			Method:      "GET",
+			Pattern:     "/pins/{hash}/shards",
+			HandlerFunc: api.shardsHandler,
+		},
+		{
+			Name:        "RecoverShard",
+			Method:      "POST",
+			Pattern:     "/pins/{hash}/shards/{shard}/recover",
+			HandlerFunc: api.recoverShardHandler,
+		},
+		{
+			Name:        "Reconstruct",
+			Method:      "POST",
+			Pattern:     "/pins/{hash}/reconstruct",
+			HandlerFunc: api.reconstructHandler,
+		},
+		{
+			Name:        "Decrypt",
+			Method:      "GET",
+			Pattern:     "/pins/{hash}/decrypt",
+			HandlerFunc: api.decryptHandler,
 		},
 		{
 			Name:        "Status",
@@ -147,13 +248,19 @@ func (api *API) routes(c *rpc.Client) []common.Route {
 			Name:        "Pin",
 			Method:      "POST",
 			Pattern:     "/pins/{hash}",
-			HandlerFunc: api.pinHandler,
+			HandlerFunc: api.withPinProfile(api.withPinUpdate(api.pinHandler)),
+		},
+		{
+			Name:        "PinClone",
+			Method:      "POST",
+			Pattern:     "/pins/{hash}/clone",
+			HandlerFunc: api.pinCloneHandler,
 		},
 		{
 			Name:        "PinPath",
 			Method:      "POST",
 			Pattern:     "/pins/{keyType:ipfs|ipns|ipld}/{path:.*}",
-			HandlerFunc: api.pinPathHandler,
+			HandlerFunc: api.withPinProfile(api.withPinUpdate(api.pinPathHandler)),
 		},
 		{
 			Name:        "Unpin",
@@ -167,11 +274,113 @@ func (api *API) routes(c *rpc.Client) []common.Route {
 			Pattern:     "/pins/{keyType:ipfs|ipns|ipld}/{path:.*}",
 			HandlerFunc: api.unpinPathHandler,
 		},
+		{
+			Name:        "Restore",
+			Method:      "POST",
+			Pattern:     "/pins/{hash}/restore",
+			HandlerFunc: api.restoreHandler,
+		},
+		{
+			Name:        "Unprotect",
+			Method:      "POST",
+			Pattern:     "/pins/{hash}/unprotect",
+			HandlerFunc: api.adminOnly(api.unprotectHandler),
+		},
+		{
+			Name:        "Audit",
+			Method:      "POST",
+			Pattern:     "/pins/{hash}/audit",
+			HandlerFunc: api.auditHandler,
+		},
+		{
+			Name:        "PinTemplates",
+			Method:      "GET",
+			Pattern:     "/pintemplates",
+			HandlerFunc: api.pinTemplatesHandler,
+		},
+		{
+			Name:        "PinTemplate",
+			Method:      "GET",
+			Pattern:     "/pintemplates/{name}",
+			HandlerFunc: api.pinTemplateHandler,
+		},
+		{
+			Name:        "PinTemplateSet",
+			Method:      "POST",
+			Pattern:     "/pintemplates/{name}",
+			HandlerFunc: api.adminOnly(api.pinTemplateSetHandler),
+		},
+		{
+			Name:        "PinTemplateRemove",
+			Method:      "DELETE",
+			Pattern:     "/pintemplates/{name}",
+			HandlerFunc: api.adminOnly(api.pinTemplateRemoveHandler),
+		},
+		{
+			Name:        "PinsetSnapshots",
+			Method:      "GET",
+			Pattern:     "/pinset/snapshots",
+			HandlerFunc: api.pinsetSnapshotsHandler,
+		},
+		{
+			Name:        "PinsetSnapshotCreate",
+			Method:      "POST",
+			Pattern:     "/pinset/snapshots",
+			HandlerFunc: api.adminOnly(api.pinsetSnapshotCreateHandler),
+		},
+		{
+			Name:        "PinsetSnapshot",
+			Method:      "GET",
+			Pattern:     "/pinset/snapshots/{name}",
+			HandlerFunc: api.adminOnly(api.pinsetSnapshotHandler),
+		},
+		{
+			Name:        "PinsetSnapshotRemove",
+			Method:      "DELETE",
+			Pattern:     "/pinset/snapshots/{name}",
+			HandlerFunc: api.adminOnly(api.pinsetSnapshotRemoveHandler),
+		},
+		{
+			Name:        "PinsetRollback",
+			Method:      "POST",
+			Pattern:     "/pinset/snapshots/{name}/rollback",
+			HandlerFunc: api.adminOnly(api.pinsetRollbackHandler),
+		},
+		{
+			Name:        "ConsistencyCheck",
+			Method:      "POST",
+			Pattern:     "/pinset/consistency",
+			HandlerFunc: api.adminOnly(api.consistencyCheckHandler),
+		},
+		{
+			Name:        "Denylist",
+			Method:      "GET",
+			Pattern:     "/denylist",
+			HandlerFunc: api.adminOnly(api.denylistHandler),
+		},
+		{
+			Name:        "DenylistAdd",
+			Method:      "POST",
+			Pattern:     "/denylist",
+			HandlerFunc: api.adminOnly(api.denylistAddHandler),
+		},
+		{
+			Name:        "DenylistRemove",
+			Method:      "DELETE",
+			Pattern:     "/denylist",
+			HandlerFunc: api.adminOnly(api.denylistRemoveHandler),
+		},
+		{
+			Name:        "DenylistEnforce",
+			Method:      "POST",
+			Pattern:     "/denylist/enforce",
+			HandlerFunc: api.adminOnly(api.denylistEnforceHandler),
+		},
 		{
 			Name:        "RepoGC",
 			Method:      "POST",
 			Pattern:     "/ipfs/gc",
-			HandlerFunc: api.repoGCHandler,
+			HandlerFunc: api.adminOnly(api.repoGCHandler),
 		},
 		{
 			Name:        "ConnectionGraph",
@@ -179,12 +388,48 @@ func (api *API) routes(c *rpc.Client) []common.Route {
 			Pattern:     "/health/graph",
 			HandlerFunc: api.graphHandler,
 		},
+		{
+			Name:        "ConnectivityHistory",
+			Method:      "GET",
+			Pattern:     "/health/graph/history",
+			HandlerFunc: api.graphHistoryHandler,
+		},
 		{
 			Name:        "Alerts",
 			Method:      "GET",
 			Pattern:     "/health/alerts",
 			HandlerFunc: api.alertsHandler,
 		},
+		{
+			Name:        "StartupWarmup",
+			Method:      "GET",
+			Pattern:     "/health/warmup",
+			HandlerFunc: api.startupWarmupHandler,
+		},
+		{
+			Name:        "ConsensusStats",
+			Method:      "GET",
+			Pattern:     "/health/consensus",
+			HandlerFunc: api.adminOnly(api.consensusStatsHandler),
+		},
+		{
+			Name:        "FederationPins",
+			Method:      "GET",
+			Pattern:     "/federation/pins",
+			HandlerFunc: api.adminOnly(api.federationPinsHandler),
+		},
+		{
+			Name:        "FederationPeers",
+			Method:      "GET",
+			Pattern:     "/federation/peers",
+			HandlerFunc: api.adminOnly(api.federationPeersHandler),
+		},
+		{
+			Name:        "FederationHealth",
+			Method:      "GET",
+			Pattern:     "/federation/health",
+			HandlerFunc: api.adminOnly(api.federationHealthHandler),
+		},
 		{
 			Name:        "Metrics",
 			Method:      "GET",
@@ -197,6 +442,96 @@ func (api *API) routes(c *rpc.Client) []common.Route {
 			Pattern:     "/monitor/metrics",
 			HandlerFunc: api.metricNamesHandler,
 		},
+		{
+			Name:        "DetectorState",
+			Method:      "GET",
+			Pattern:     "/monitor/detector",
+			HandlerFunc: api.detectorStateHandler,
+		},
+		{
+			Name:        "LifecycleEvents",
+			Method:      "GET",
+			Pattern:     "/events",
+			HandlerFunc: api.adminOnly(api.lifecycleEventsHandler),
+		},
+		{
+			Name:        "Operations",
+			Method:      "GET",
+			Pattern:     "/operations",
+			HandlerFunc: api.adminOnly(api.operationsHandler),
+		},
+		{
+			Name:        "Operation",
+			Method:      "GET",
+			Pattern:     "/operations/{id}",
+			HandlerFunc: api.adminOnly(api.operationHandler),
+		},
+		{
+			Name:        "CancelOperation",
+			Method:      "DELETE",
+			Pattern:     "/operations/{id}",
+			HandlerFunc: api.adminOnly(api.cancelOperationHandler),
+		},
+		{
+			Name:        "RPCPolicy",
+			Method:      "GET",
+			Pattern:     "/rpc/policy",
+			HandlerFunc: api.adminOnly(api.rpcPolicyHandler),
+		},
+		{
+			Name:        "SetRPCPolicy",
+			Method:      "POST",
+			Pattern:     "/rpc/policy",
+			HandlerFunc: api.adminOnly(api.setRPCPolicyHandler),
+		},
+		{
+			Name:        "RotateSecret",
+			Method:      "POST",
+			Pattern:     "/secret/rotate",
+			HandlerFunc: api.adminOnly(api.rotateSecretHandler),
+		},
+		{
+			Name:        "TrackerSettings",
+			Method:      "GET",
+			Pattern:     "/pintracker/settings",
+			HandlerFunc: api.adminOnly(api.trackerSettingsHandler),
+		},
+		{
+			Name:        "SetTrackerSettings",
+			Method:      "POST",
+			Pattern:     "/pintracker/settings",
+			HandlerFunc: api.adminOnly(api.setTrackerSettingsHandler),
+		},
+		{
+			Name:        "APIListeners",
+			Method:      "GET",
+			Pattern:     "/apis/listeners",
+			HandlerFunc: api.adminOnly(api.apiListenersHandler),
+		},
+		{
+			Name:        "RebindAPI",
+			Method:      "POST",
+			Pattern:     "/apis/listeners",
+			HandlerFunc: api.adminOnly(api.rebindAPIHandler),
+		},
+		{
+			Name:        "Spec",
+			Method:      "GET",
+			Pattern:     "/api/spec",
+			HandlerFunc: api.specHandler,
+		},
+		{
+			Name:        "WebUI",
+			Method:      "GET",
+			Pattern:     "/webui",
+			HandlerFunc: api.webUIHandler,
+		},
+		{
+			Name:        "WebUIFile",
+			Method:      "GET",
+			Pattern:     "/webui/{file}",
+			HandlerFunc: api.webUIHandler,
+		},
 	}
 }
 
@@ -229,16 +564,62 @@ func (api *API) versionHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (api *API) graphHandler(w http.ResponseWriter, r *http.Request) {
-	var graph types.ConnectGraph
+	queryValues := r.URL.Query()
+	format := queryValues.Get("format")
+	switch format {
+	case "", graph.FormatJSON, graph.FormatDot, graph.FormatMermaid:
+	default:
+		api.SendResponse(w, http.StatusBadRequest, graph.ErrUnknownFormat, nil)
+		return
+	}
+	allIpfs := queryValues.Get("all_ipfs") == "true"
+
+	var cg types.ConnectGraph
 	err := api.rpcClient.CallContext(
 		r.Context(),
 		"",
 		"Cluster",
 		"ConnectGraph",
 		struct{}{},
-		&graph,
+		&cg,
+	)
+	if err != nil || format == "" || format == graph.FormatJSON {
+		api.SendResponse(w, common.SetStatusAutomatically, err, cg)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := graph.Write(&cg, &buf, format, allIpfs); err != nil {
+		api.SendResponse(w, common.SetStatusAutomatically, err, nil)
+		return
+	}
+	api.SetHeaders(w)
+	w.Header().Set("Content-Type", graph.ContentType(format))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+func (api *API) graphHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if sinceStr := r.URL.Query().Get("since"); sinceStr != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			api.SendResponse(w, http.StatusBadRequest, errors.New("invalid since value. Expected an RFC3339 date"), nil)
+			return
+		}
+	}
+
+	var history []*types.ConnectivitySnapshot
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"ConnectivityHistory",
+		since,
+		&history,
 	)
-	api.SendResponse(w, common.SetStatusAutomatically, err, graph)
+	api.SendResponse(w, common.SetStatusAutomatically, err, history)
 }
 
 func (api *API) metricsHandler(w http.ResponseWriter, r *http.Request) {
@@ -270,6 +651,19 @@ func (api *API) metricNamesHandler(w http.ResponseWriter, r *http.Request) {
 	api.SendResponse(w, common.SetStatusAutomatically, err, metricNames)
 }
 
+func (api *API) detectorStateHandler(w http.ResponseWriter, r *http.Request) {
+	var states []*types.DetectorState
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"PeerMonitor",
+		"DetectorState",
+		struct{}{},
+		&states,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, states)
+}
+
 func (api *API) alertsHandler(w http.ResponseWriter, r *http.Request) {
 	var alerts []types.Alert
 	err := api.rpcClient.CallContext(
@@ -283,19 +677,105 @@ func (api *API) alertsHandler(w http.ResponseWriter, r *http.Request) {
 	api.SendResponse(w, common.SetStatusAutomatically, err, alerts)
 }
 
+func (api *API) startupWarmupHandler(w http.ResponseWriter, r *http.Request) {
+	var warmup types.StartupWarmup
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"StartupWarmup",
+		struct{}{},
+		&warmup,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, warmup)
+}
+
+func (api *API) consensusStatsHandler(w http.ResponseWriter, r *http.Request) {
+	var stats []*types.ConsensusStats
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"ConsensusStats",
+		struct{}{},
+		&stats,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, stats)
+}
+
 func (api *API) addHandler(w http.ResponseWriter, r *http.Request) {
+	// This wraps the body, so it must happen before reading it.
+	digest, err := newDigestReader(r)
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+
 	reader, err := r.MultipartReader()
 	if err != nil {
 		api.SendResponse(w, http.StatusBadRequest, err, nil)
 		return
 	}
 
-	params, err := types.AddParamsFromQuery(r.URL.Query())
+	query, err := api.addQuery(r)
+	if err != nil {
+		api.SendResponse(w, http.StatusForbidden, err, nil)
+		return
+	}
+
+	params, err := types.AddParamsFromQuery(query)
 	if err != nil {
 		api.SendResponse(w, http.StatusBadRequest, err, nil)
 		return
 	}
 
+	// Without a digest of the body, checksummed uploads need digests
+	// for every file part.
+	var verify func() error
+	if digest != nil {
+		verify = digest.verify
+	}
+	if params.Checksum {
+		var stop func()
+		reader, stop = verifyParts(reader, digest == nil)
+		defer stop()
+	}
+
+	if err := api.prepareEncryption(r, params); err != nil {
+		api.SendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	// The size of the content is not known until it is added. The quota
+	// is checked now with the expected size to fail early, and the actual
+	// size is charged before pinning.
+	var reserve func(uint64) (func(), error)
+	params.User = types.RequestUserFromContext(r.Context())
+	if ns, restricted := api.namespace(r); restricted {
+		params.Namespace = ns
+		if quota := api.config.Tenancy.QuotaFor(ns); quota != nil {
+			status, err := api.checkQuota(r.Context(), ns, quota, cid.Undef, params.ExpectedSize)
+			if err != nil {
+				api.SendResponse(w, status, err, nil)
+				return
+			}
+			reserve = api.addReserver(r.Context(), ns)
+		}
+	}
+
+	err = api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"CheckFreeSpace",
+		params.PinOptions,
+		&struct{}{},
+	)
+	if err != nil {
+		api.SendResponse(w, pinErrorStatus(err), err, nil)
+		return
+	}
+
 	api.SetHeaders(w)
 
 	// any errors sent as trailer
@@ -306,9 +786,40 @@ func (api *API) addHandler(w http.ResponseWriter, r *http.Request) {
 		reader,
 		w,
 		nil,
+		verify,
+		reserve,
 	)
 }
 
+// addQuery returns the query parameters of an add request with the
+// configured defaults for the authenticated user. It errors when the
+// request tries to change an enforced parameter.
+func (api *API) addQuery(r *http.Request) (url.Values, error) {
+	user := types.RequestUserFromContext(r.Context())
+	defaults, enforced := api.config.AddParamsFor(user)
+	query := r.URL.Query()
+
+	// "replication" sets both replication-min and replication-max.
+	if rpl := query.Get("replication"); rpl != "" {
+		for _, k := range []string{"replication-min", "replication-max"} {
+			if v, ok := defaults[k]; ok && enforced[k] && v != rpl {
+				return nil, fmt.Errorf("the %s parameter cannot be changed", k)
+			}
+		}
+	}
+
+	for k, v := range defaults {
+		current, ok := query[k]
+		switch {
+		case !ok:
+			query.Set(k, v)
+		case enforced[k] && (len(current) != 1 || current[0] != v):
+			return nil, fmt.Errorf("the %s parameter cannot be changed", k)
+		}
+	}
+	return query, nil
+}
+
 func (api *API) peerListHandler(w http.ResponseWriter, r *http.Request) {
 	var peers []*types.ID
 	err := api.rpcClient.CallContext(
@@ -323,6 +834,20 @@ func (api *API) peerListHandler(w http.ResponseWriter, r *http.Request) {
 	api.SendResponse(w, common.SetStatusAutomatically, err, peers)
 }
 
+func (api *API) peerVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	var pvs types.PeerVersions
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PeerVersions",
+		struct{}{},
+		&pvs,
+	)
+
+	api.SendResponse(w, common.SetStatusAutomatically, err, pvs)
+}
+
 func (api *API) peerAddHandler(w http.ResponseWriter, r *http.Request) {
 	dec := json.NewDecoder(r.Body)
 	defer r.Body.Close()
@@ -352,8 +877,101 @@ func (api *API) peerAddHandler(w http.ResponseWriter, r *http.Request) {
 	api.SendResponse(w, common.SetStatusAutomatically, err, &id)
 }
 
-func (api *API) peerRemoveHandler(w http.ResponseWriter, r *http.Request) {
-	if p := api.ParsePidOrFail(w, r); p != "" {
+// peerErrorStatus returns the status for requests addressing a peer by a
+// peername which is not known or which is used by several peers.
+func peerErrorStatus(err error) int {
+	if err != nil && strings.HasPrefix(err.Error(), types.ErrPeerNotFound.Error()) {
+		return http.StatusNotFound
+	}
+	if err != nil && strings.HasPrefix(err.Error(), types.ErrAmbiguousPeername.Error()) {
+		return http.StatusConflict
+	}
+	return common.SetStatusAutomatically
+}
+
+// parsePeerOrFail returns the ID of the peer given in the route, either as
+// a peer ID or as a peername, or makes the request fail.
+func (api *API) parsePeerOrFail(w http.ResponseWriter, r *http.Request) peer.ID {
+	name := mux.Vars(r)["peer"]
+	if pid, err := peer.Decode(name); err == nil {
+		return pid
+	}
+
+	var pid peer.ID
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"ResolvePeer",
+		name,
+		&pid,
+	)
+	if err != nil {
+		api.SendResponse(w, peerErrorStatus(err), err, nil)
+		return ""
+	}
+	return pid
+}
+
+func (api *API) peerHandler(w http.ResponseWriter, r *http.Request) {
+	var id types.ID
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"Peer",
+		mux.Vars(r)["peer"],
+		&id,
+	)
+	if err != nil {
+		api.SendResponse(w, peerErrorStatus(err), err, nil)
+		return
+	}
+	api.SendResponse(w, common.SetStatusAutomatically, nil, &id)
+}
+
+func (api *API) peerResourcesHandler(w http.ResponseWriter, r *http.Request) {
+	var res types.PeerResources
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"Resources",
+		mux.Vars(r)["peer"],
+		&res,
+	)
+	if err != nil {
+		api.SendResponse(w, peerErrorStatus(err), err, nil)
+		return
+	}
+	api.SendResponse(w, common.SetStatusAutomatically, nil, &res)
+}
+
+func (api *API) peerHandoverHandler(w http.ResponseWriter, r *http.Request) {
+	from := api.parsePeerOrFail(w, r)
+	if from == "" {
+		return
+	}
+	to, err := peer.Decode(r.URL.Query().Get("to"))
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, errors.New("error decoding the peer ID in the to parameter"), nil)
+		return
+	}
+
+	var handover types.PeerHandover
+	err = api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PeerHandover",
+		types.PeerHandover{From: from, To: to},
+		&handover,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, &handover)
+}
+
+func (api *API) peerRemoveHandler(w http.ResponseWriter, r *http.Request) {
+	if p := api.parsePeerOrFail(w, r); p != "" {
 		err := api.rpcClient.CallContext(
 			r.Context(),
 			"",
@@ -369,17 +987,28 @@ func (api *API) peerRemoveHandler(w http.ResponseWriter, r *http.Request) {
 func (api *API) pinHandler(w http.ResponseWriter, r *http.Request) {
 	if pin := api.ParseCidOrFail(w, r); pin != nil {
 		api.config.Logger.Debugf("rest api pinHandler: %s", pin.Cid)
+		release, ok := api.checkPinOrFail(w, r, pin.Cid, &pin.PinOptions)
+		if !ok {
+			return
+		}
+		defer release()
+		// With dry-run, the pin is validated and allocated but not
+		// committed, and the response shows where it would land.
+		method := "Pin"
+		if r.URL.Query().Get("dry-run") == "true" {
+			method = "PinDryRun"
+		}
 		// span.AddAttributes(trace.StringAttribute("cid", pin.Cid))
 		var pinObj types.Pin
 		err := api.rpcClient.CallContext(
 			r.Context(),
 			"",
 			"Cluster",
-			"Pin",
+			method,
 			pin,
 			&pinObj,
 		)
-		api.SendResponse(w, common.SetStatusAutomatically, err, pinObj)
+		api.SendResponse(w, pinErrorStatus(err), err, pinObj)
 		api.config.Logger.Debug("rest api pinHandler done")
 	}
 }
@@ -387,6 +1016,9 @@ func (api *API) pinHandler(w http.ResponseWriter, r *http.Request) {
 func (api *API) unpinHandler(w http.ResponseWriter, r *http.Request) {
 	if pin := api.ParseCidOrFail(w, r); pin != nil {
 		api.config.Logger.Debugf("rest api unpinHandler: %s", pin.Cid)
+		if !api.checkOwnerOrFail(w, r, pin.Cid) {
+			return
+		}
 		// span.AddAttributes(trace.StringAttribute("cid", pin.Cid))
 		var pinObj types.Pin
 		err := api.rpcClient.CallContext(
@@ -401,15 +1033,107 @@ func (api *API) unpinHandler(w http.ResponseWriter, r *http.Request) {
 			api.SendResponse(w, http.StatusNotFound, err, nil)
 			return
 		}
-		api.SendResponse(w, common.SetStatusAutomatically, err, pinObj)
+		api.SendResponse(w, pinErrorStatus(err), err, pinObj)
 		api.config.Logger.Debug("rest api unpinHandler done")
 	}
 }
 
+func (api *API) restoreHandler(w http.ResponseWriter, r *http.Request) {
+	if pin := api.ParseCidOrFail(w, r); pin != nil {
+		if !api.checkOwnerOrFail(w, r, pin.Cid) {
+			return
+		}
+		var pinObj types.Pin
+		err := api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			"Restore",
+			pin.Cid,
+			&pinObj,
+		)
+		if err != nil && err.Error() == state.ErrNotFound.Error() {
+			api.SendResponse(w, http.StatusNotFound, err, nil)
+			return
+		}
+		api.SendResponse(w, pinErrorStatus(err), err, pinObj)
+	}
+}
+
+func (api *API) unprotectHandler(w http.ResponseWriter, r *http.Request) {
+	if pin := api.ParseCidOrFail(w, r); pin != nil {
+		var pinObj types.Pin
+		err := api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			"Unprotect",
+			pin.Cid,
+			&pinObj,
+		)
+		if err != nil && err.Error() == state.ErrNotFound.Error() {
+			api.SendResponse(w, http.StatusNotFound, err, nil)
+			return
+		}
+		api.SendResponse(w, pinErrorStatus(err), err, pinObj)
+	}
+}
+
+func (api *API) auditHandler(w http.ResponseWriter, r *http.Request) {
+	if pin := api.ParseCidOrFail(w, r); pin != nil {
+		if !api.checkOwnerOrFail(w, r, pin.Cid) {
+			return
+		}
+		var audit types.GlobalDAGAudit
+		err := api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			"Audit",
+			types.DAGAuditRequest{
+				Cid:    pin.Cid,
+				Repair: r.URL.Query().Get("repair") == "true",
+			},
+			&audit,
+		)
+		if err != nil && err.Error() == state.ErrNotFound.Error() {
+			api.SendResponse(w, http.StatusNotFound, err, nil)
+			return
+		}
+		api.SendResponse(w, common.SetStatusAutomatically, err, audit)
+	}
+}
+
+// consistencyCheckHandler cross-checks the shared state, the pin trackers and
+// the IPFS pins of all peers. With repair, the issues found are fixed.
+func (api *API) consistencyCheckHandler(w http.ResponseWriter, r *http.Request) {
+	var report types.GlobalConsistencyReport
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"ConsistencyCheck",
+		r.URL.Query().Get("repair") == "true",
+		&report,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, report)
+}
+
 func (api *API) pinPathHandler(w http.ResponseWriter, r *http.Request) {
 	var pin types.Pin
 	if pinpath := api.ParsePinPathOrFail(w, r); pinpath != nil {
 		api.config.Logger.Debugf("rest api pinPathHandler: %s", pinpath.Path)
+		if _, restricted := api.namespace(r); restricted {
+			ci, ok := api.resolveOrFail(w, r, pinpath.Path)
+			if !ok {
+				return
+			}
+			release, ok := api.checkPinOrFail(w, r, ci, &pinpath.PinOptions)
+			if !ok {
+				return
+			}
+			defer release()
+		}
 		err := api.rpcClient.CallContext(
 			r.Context(),
 			"",
@@ -419,7 +1143,7 @@ func (api *API) pinPathHandler(w http.ResponseWriter, r *http.Request) {
 			&pin,
 		)
 
-		api.SendResponse(w, common.SetStatusAutomatically, err, pin)
+		api.SendResponse(w, pinErrorStatus(err), err, pin)
 		api.config.Logger.Debug("rest api pinPathHandler done")
 	}
 }
@@ -428,6 +1152,12 @@ func (api *API) unpinPathHandler(w http.ResponseWriter, r *http.Request) {
 	var pin types.Pin
 	if pinpath := api.ParsePinPathOrFail(w, r); pinpath != nil {
 		api.config.Logger.Debugf("rest api unpinPathHandler: %s", pinpath.Path)
+		if _, restricted := api.namespace(r); restricted {
+			ci, ok := api.resolveOrFail(w, r, pinpath.Path)
+			if !ok || !api.checkOwnerOrFail(w, r, ci) {
+				return
+			}
+		}
 		err := api.rpcClient.CallContext(
 			r.Context(),
 			"",
@@ -440,7 +1170,7 @@ func (api *API) unpinPathHandler(w http.ResponseWriter, r *http.Request) {
 			api.SendResponse(w, http.StatusNotFound, err, nil)
 			return
 		}
-		api.SendResponse(w, common.SetStatusAutomatically, err, pin)
+		api.SendResponse(w, pinErrorStatus(err), err, pin)
 		api.config.Logger.Debug("rest api unpinPathHandler done")
 	}
 }
@@ -458,15 +1188,36 @@ func (api *API) allocationsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var pins []*types.Pin
-	err := api.rpcClient.CallContext(
-		r.Context(),
-		"",
-		"Cluster",
-		"Pins",
-		struct{}{},
-		&pins,
-	)
+	if etag := api.pinsetETag(r); etag != "" && api.NotModified(w, r, etag) {
+		return
+	}
+
+	pins, named, err := api.namedPins(r)
+	switch {
+	case named:
+	case filter == types.AllType:
+		err = api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			"Pins",
+			struct{}{},
+			&pins,
+		)
+	default:
+		err = api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			"PinsByType",
+			filter,
+			&pins,
+		)
+	}
+
+	if ns, restricted := api.namespace(r); restricted {
+		pins = filterNamespace(pins, ns)
+	}
 
 	var outPins []*types.Pin
 
@@ -481,7 +1232,53 @@ func (api *API) allocationsHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	}
-	api.SendResponse(w, common.SetStatusAutomatically, err, outPins)
+	api.SendCacheableResponse(w, r, err, outPins)
+}
+
+// pinsetETag returns an ETag for listings of the pinset based on its
+// current sequence number (see PinChanges), or an empty string when it is
+// not available. It must be obtained before listing the pinset.
+func (api *API) pinsetETag(r *http.Request) string {
+	var changes types.PinChanges
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PinChanges",
+		uint64(0),
+		&changes,
+	)
+	if err != nil {
+		return ""
+	}
+	return common.VersionETag(r, strconv.FormatUint(changes.Sequence, 10))
+}
+
+func (api *API) allocationChangesHandler(w http.ResponseWriter, r *http.Request) {
+	var since uint64
+	if sinceStr := r.URL.Query().Get("since"); sinceStr != "" {
+		var err error
+		since, err = strconv.ParseUint(sinceStr, 10, 64)
+		if err != nil {
+			api.SendResponse(w, http.StatusBadRequest, errors.New("invalid since value"), nil)
+			return
+		}
+	}
+
+	var changes types.PinChanges
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PinChanges",
+		since,
+		&changes,
+	)
+	if err != nil && err.Error() == types.ErrChangesUnavailable.Error() {
+		api.SendResponse(w, http.StatusGone, err, nil)
+		return
+	}
+	api.SendResponse(w, common.SetStatusAutomatically, err, changes)
 }
 
 func (api *API) allocationHandler(w http.ResponseWriter, r *http.Request) {
@@ -499,6 +1296,10 @@ func (api *API) allocationHandler(w http.ResponseWriter, r *http.Request) {
 			api.SendResponse(w, http.StatusNotFound, err, nil)
 			return
 		}
+		if ns, restricted := api.namespace(r); restricted && pinResp.Namespace != ns {
+			api.SendResponse(w, http.StatusNotFound, errors.New("pin not found"), nil)
+			return
+		}
 		api.SendResponse(w, common.SetStatusAutomatically, nil, pinResp)
 	}
 }
@@ -516,7 +1317,19 @@ func (api *API) statusAllHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if local == "true" {
+	pins, named, err := api.namedPins(r)
+	if err != nil {
+		api.SendResponse(w, common.SetStatusAutomatically, err, nil)
+		return
+	}
+
+	if named {
+		globalPinInfos, err = api.namedPinsStatus(r.Context(), pins, filter, local == "true")
+		if err != nil {
+			api.SendResponse(w, common.SetStatusAutomatically, err, nil)
+			return
+		}
+	} else if local == "true" {
 		var pinInfos []*types.PinInfo
 
 		err := api.rpcClient.CallContext(
@@ -547,7 +1360,114 @@ func (api *API) statusAllHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	api.SendResponse(w, common.SetStatusAutomatically, nil, globalPinInfos)
+	if ns, restricted := api.namespace(r); restricted {
+		pins, err := api.namespacePins(r.Context(), ns)
+		if err != nil {
+			api.SendResponse(w, common.SetStatusAutomatically, err, nil)
+			return
+		}
+		owned := make(map[cid.Cid]struct{}, len(pins))
+		for _, p := range pins {
+			owned[p.Cid] = struct{}{}
+		}
+		filtered := make([]*types.GlobalPinInfo, 0, len(globalPinInfos))
+		for _, gpi := range globalPinInfos {
+			if _, ok := owned[gpi.Cid]; ok {
+				filtered = append(filtered, gpi)
+			}
+		}
+		globalPinInfos = filtered
+	}
+
+	api.SendCacheableResponse(w, r, nil, globalPinInfos)
+}
+
+// namedPins returns the pins with the name given in the "name" query
+// parameter, or with names starting with the "name-prefix" one. These are
+// looked up in the name index of the pinset. It returns false when neither
+// parameter is set.
+func (api *API) namedPins(r *http.Request) ([]*types.Pin, bool, error) {
+	queryValues := r.URL.Query()
+	method, name := "PinsByName", queryValues.Get("name")
+	if name == "" {
+		method, name = "PinsByNamePrefix", queryValues.Get("name-prefix")
+	}
+	if name == "" {
+		return nil, false, nil
+	}
+
+	var pins []*types.Pin
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		method,
+		name,
+		&pins,
+	)
+	return pins, true, err
+}
+
+// namedPinsStatus returns the status of the given pins, keeping those
+// for which any peer matches the filter.
+func (api *API) namedPinsStatus(ctx context.Context, pins []*types.Pin, filter types.TrackerStatus, local bool) ([]*types.GlobalPinInfo, error) {
+	globalPinInfos := make([]*types.GlobalPinInfo, 0, len(pins))
+	for _, p := range pins {
+		gpi := &types.GlobalPinInfo{}
+		if local {
+			var pinInfo types.PinInfo
+			err := api.rpcClient.CallContext(ctx, "", "Cluster", "StatusLocal", p.Cid, &pinInfo)
+			if err != nil {
+				return nil, err
+			}
+			gpi = pinInfo.ToGlobal()
+		} else {
+			err := api.rpcClient.CallContext(ctx, "", "Cluster", "Status", p.Cid, gpi)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		match := filter == types.TrackerStatusUndefined
+		for _, pi := range gpi.PeerMap {
+			match = match || pi.Status.Match(filter)
+		}
+		if match {
+			globalPinInfos = append(globalPinInfos, gpi)
+		}
+	}
+	return globalPinInfos, nil
+}
+
+// pinErrorStatus returns the status for pin requests rejected because the
+// pin name is in use (409 Conflict), by the pin validation hook (403
+// Forbidden), by the denylist (451 Unavailable For Legal Reasons) or for
+// lack of free space (507 Insufficient Storage), and for unpin requests
+// rejected because the pin is protected and restore requests for pins not
+// in the trash (409 Conflict).
+func pinErrorStatus(err error) int {
+	if err != nil && strings.HasPrefix(err.Error(), types.ErrDuplicatePinName.Error()) {
+		return http.StatusConflict
+	}
+	if err != nil && strings.HasPrefix(err.Error(), types.ErrPinProtected.Error()) {
+		return http.StatusConflict
+	}
+	if err != nil && strings.HasPrefix(err.Error(), types.ErrNotTrashed.Error()) {
+		return http.StatusConflict
+	}
+	if err != nil && strings.HasPrefix(err.Error(), types.ErrPinRejected.Error()) {
+		return http.StatusForbidden
+	}
+	if err != nil && strings.HasPrefix(err.Error(), types.ErrInvalidMetadata.Error()) {
+		return http.StatusBadRequest
+	}
+	if err != nil && strings.HasPrefix(err.Error(), types.ErrDenylisted.Error()) {
+		return http.StatusUnavailableForLegalReasons
+	}
+	if err != nil && strings.HasPrefix(err.Error(), types.ErrInsufficientFreeSpace.Error()) {
+		return http.StatusInsufficientStorage
+	}
+	return common.SetStatusAutomatically
 }
 
 func (api *API) statusHandler(w http.ResponseWriter, r *http.Request) {
@@ -555,6 +1475,9 @@ func (api *API) statusHandler(w http.ResponseWriter, r *http.Request) {
 	local := queryValues.Get("local")
 
 	if pin := api.ParseCidOrFail(w, r); pin != nil {
+		if !api.checkOwnerOrFail(w, r, pin.Cid) {
+			return
+		}
 		if local == "true" {
 			var pinInfo types.PinInfo
 			err := api.rpcClient.CallContext(
@@ -581,6 +1504,96 @@ func (api *API) statusHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// statusCidsHandler returns the status of the CIDs given as a JSON array
+// in the request body, in the same positions. Users restricted to a
+// namespace only get the status of the CIDs of their pins.
+func (api *API) statusCidsHandler(w http.ResponseWriter, r *http.Request) {
+	dec := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	var cidStrs []string
+	err := dec.Decode(&cidStrs)
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, errors.New("error decoding request body"), nil)
+		return
+	}
+	cids := make([]cid.Cid, 0, len(cidStrs))
+	for _, s := range cidStrs {
+		ci, err := cid.Decode(s)
+		if err != nil {
+			api.SendResponse(w, http.StatusBadRequest, fmt.Errorf("error decoding cid %q: %w", s, err), nil)
+			return
+		}
+		cids = append(cids, ci)
+	}
+
+	requested := cids
+	if ns, restricted := api.namespace(r); restricted {
+		pins, err := api.namespacePins(r.Context(), ns)
+		if err != nil {
+			api.SendResponse(w, common.SetStatusAutomatically, err, nil)
+			return
+		}
+		owned := make(map[cid.Cid]struct{}, len(pins))
+		for _, p := range pins {
+			owned[p.Cid] = struct{}{}
+		}
+		requested = make([]cid.Cid, 0, len(cids))
+		for _, ci := range cids {
+			if _, ok := owned[ci]; ok {
+				requested = append(requested, ci)
+			}
+		}
+	}
+
+	var globalPinInfos []*types.GlobalPinInfo
+	if r.URL.Query().Get("local") == "true" {
+		var pinInfos []*types.PinInfo
+		err = api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			"StatusCidsLocal",
+			requested,
+			&pinInfos,
+		)
+		globalPinInfos = pinInfosToGlobal(pinInfos)
+	} else {
+		err = api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			"StatusCids",
+			requested,
+			&globalPinInfos,
+		)
+	}
+	if err != nil {
+		api.SendResponse(w, common.SetStatusAutomatically, err, nil)
+		return
+	}
+	api.SendResponse(w, common.SetStatusAutomatically, nil, statusInPositions(cids, globalPinInfos))
+}
+
+// statusInPositions returns the given statuses at the positions of their
+// CIDs in the request. CIDs without a status, like those of other
+// namespaces, get an empty one.
+func statusInPositions(cids []cid.Cid, infos []*types.GlobalPinInfo) []*types.GlobalPinInfo {
+	byCid := make(map[cid.Cid]*types.GlobalPinInfo, len(infos))
+	for _, info := range infos {
+		byCid[info.Cid] = info
+	}
+	result := make([]*types.GlobalPinInfo, len(cids))
+	for i, ci := range cids {
+		info, ok := byCid[ci]
+		if !ok {
+			info = &types.GlobalPinInfo{Cid: ci}
+		}
+		result[i] = info
+	}
+	return result
+}
+
 func (api *API) recoverAllHandler(w http.ResponseWriter, r *http.Request) {
 	queryValues := r.URL.Query()
 	local := queryValues.Get("local")
@@ -609,11 +1622,53 @@ func (api *API) recoverAllHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// reshardHandler starts re-adding the content of an existing pin with the
+// add parameters given in the query and returns the reshard operation.
+func (api *API) reshardHandler(w http.ResponseWriter, r *http.Request) {
+	ci, err := cid.Decode(mux.Vars(r)["hash"])
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, errors.New("error decoding Cid: "+err.Error()), nil)
+		return
+	}
+	if !api.checkOwnerOrFail(w, r, ci) {
+		return
+	}
+
+	query, err := api.addQuery(r)
+	if err != nil {
+		api.SendResponse(w, http.StatusForbidden, err, nil)
+		return
+	}
+
+	params, err := types.AddParamsFromQuery(query)
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	var op types.Operation
+	err = api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"Reshard",
+		&types.ReshardRequest{
+			Cid:    ci,
+			Params: params,
+		},
+		&op,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, op)
+}
+
 func (api *API) recoverHandler(w http.ResponseWriter, r *http.Request) {
 	queryValues := r.URL.Query()
 	local := queryValues.Get("local")
 
 	if pin := api.ParseCidOrFail(w, r); pin != nil {
+		if !api.checkOwnerOrFail(w, r, pin.Cid) {
+			return
+		}
 		if local == "true" {
 			var pinInfo types.PinInfo
 			err := api.rpcClient.CallContext(
@@ -640,6 +1695,95 @@ func (api *API) recoverHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (api *API) shardsHandler(w http.ResponseWriter, r *http.Request) {
+	if pin := api.ParseCidOrFail(w, r); pin != nil {
+		if !api.checkOwnerOrFail(w, r, pin.Cid) {
+			return
+		}
+		var shards []*types.ShardInfo
+		err := api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			"Shards",
+			pin.Cid,
+			&shards,
+		)
+		api.SendResponse(w, common.SetStatusAutomatically, err, shards)
+	}
+}
+
+// reconstructHandler rebuilds an erasure-coded pin in the IPFS daemon of
+// this peer.
+func (api *API) reconstructHandler(w http.ResponseWriter, r *http.Request) {
+	if pin := api.ParseCidOrFail(w, r); pin != nil {
+		if !api.checkOwnerOrFail(w, r, pin.Cid) {
+			return
+		}
+		var res types.ErasureReconstruction
+		err := api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			"Reconstruct",
+			pin.Cid,
+			&res,
+		)
+		api.SendResponse(w, common.SetStatusAutomatically, err, res)
+	}
+}
+
+// recoverShardHandler triggers recover for a single shard of a sharded pin.
+func (api *API) recoverShardHandler(w http.ResponseWriter, r *http.Request) {
+	pin := api.ParseCidOrFail(w, r)
+	if pin == nil || !api.checkOwnerOrFail(w, r, pin.Cid) {
+		return
+	}
+
+	shard, err := cid.Decode(mux.Vars(r)["shard"])
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, errors.New("error decoding shard Cid: "+err.Error()), nil)
+		return
+	}
+
+	var shards []*types.ShardInfo
+	err = api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"Shards",
+		pin.Cid,
+		&shards,
+	)
+	if err != nil {
+		api.SendResponse(w, common.SetStatusAutomatically, err, nil)
+		return
+	}
+
+	found := false
+	for _, s := range shards {
+		if s.Cid.Equals(shard) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		api.SendResponse(w, http.StatusNotFound, errors.New("shard not found in the given pin"), nil)
+		return
+	}
+
+	var pinInfo types.GlobalPinInfo
+	err = api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"Recover",
+		shard,
+		&pinInfo,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, pinInfo)
+}
+
 func (api *API) repoGCHandler(w http.ResponseWriter, r *http.Request) {
 	queryValues := r.URL.Query()
 	local := queryValues.Get("local")
@@ -671,6 +1815,211 @@ func (api *API) repoGCHandler(w http.ResponseWriter, r *http.Request) {
 	api.SendResponse(w, common.SetStatusAutomatically, err, repoGC)
 }
 
+func (api *API) lifecycleEventsHandler(w http.ResponseWriter, r *http.Request) {
+	var since uint64
+	if sinceStr := r.URL.Query().Get("since"); sinceStr != "" {
+		var err error
+		since, err = strconv.ParseUint(sinceStr, 10, 64)
+		if err != nil {
+			api.SendResponse(w, http.StatusBadRequest, errors.New("invalid since value"), nil)
+			return
+		}
+	}
+
+	var events types.LifecycleEvents
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"LifecycleEvents",
+		since,
+		&events,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, events)
+}
+
+func (api *API) operationsHandler(w http.ResponseWriter, r *http.Request) {
+	var ops []*types.Operation
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"Operations",
+		struct{}{},
+		&ops,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, ops)
+}
+
+func (api *API) operationHandler(w http.ResponseWriter, r *http.Request) {
+	api.operationCall(w, r, "Operation")
+}
+
+func (api *API) cancelOperationHandler(w http.ResponseWriter, r *http.Request) {
+	api.operationCall(w, r, "CancelOperation")
+}
+
+func (api *API) operationCall(w http.ResponseWriter, r *http.Request, method string) {
+	id := mux.Vars(r)["id"]
+
+	var op types.Operation
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		method,
+		id,
+		&op,
+	)
+	if err != nil && err.Error() == types.ErrOperationNotFound.Error() {
+		api.SendResponse(w, http.StatusNotFound, err, nil)
+		return
+	}
+	api.SendResponse(w, common.SetStatusAutomatically, err, op)
+}
+
+func (api *API) rpcPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	var policy map[string]string
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"RPCPolicy",
+		struct{}{},
+		&policy,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, policy)
+}
+
+func (api *API) setRPCPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	dec := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	var changes map[string]string
+	err := dec.Decode(&changes)
+	if err != nil || len(changes) == 0 {
+		api.SendResponse(w, http.StatusBadRequest, errors.New("error decoding request body"), nil)
+		return
+	}
+
+	var policy map[string]string
+	err = api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"SetRPCPolicy",
+		changes,
+		&policy,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, policy)
+}
+
+func (api *API) rotateSecretHandler(w http.ResponseWriter, r *http.Request) {
+	dec := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	// The body is optional: a new secret is generated when not given.
+	var req types.SecretRotation
+	err := dec.Decode(&req)
+	if err != nil && err != io.EOF {
+		api.SendResponse(w, http.StatusBadRequest, errors.New("error decoding request body"), nil)
+		return
+	}
+
+	var rot types.SecretRotation
+	err = api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"RotateSecret",
+		req.Secret,
+		&rot,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, &rot)
+}
+
+func (api *API) trackerSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	var settings types.TrackerSettings
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"PinTracker",
+		"Settings",
+		struct{}{},
+		&settings,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, settings)
+}
+
+func (api *API) setTrackerSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	dec := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	var changes types.TrackerSettings
+	err := dec.Decode(&changes)
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, errors.New("error decoding request body"), nil)
+		return
+	}
+	if changes.ConcurrentPins < 0 {
+		api.SendResponse(w, http.StatusBadRequest, errors.New("concurrent_pins cannot be negative"), nil)
+		return
+	}
+
+	var settings types.TrackerSettings
+	err = api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"PinTracker",
+		"SetSettings",
+		&changes,
+		&settings,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, settings)
+}
+
+func (api *API) apiListenersHandler(w http.ResponseWriter, r *http.Request) {
+	var listeners []*types.APIListeners
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"APIListeners",
+		struct{}{},
+		&listeners,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, listeners)
+}
+
+func (api *API) rebindAPIHandler(w http.ResponseWriter, r *http.Request) {
+	dec := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	var settings types.APIListeners
+	err := dec.Decode(&settings)
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, errors.New("error decoding request body"), nil)
+		return
+	}
+	if settings.API == "" {
+		api.SendResponse(w, http.StatusBadRequest, errors.New("the api to rebind is missing"), nil)
+		return
+	}
+
+	// The response is sent on the connection which carried the request,
+	// even if its listener is closed.
+	var listeners types.APIListeners
+	err = api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"RebindAPI",
+		&settings,
+		&listeners,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, listeners)
+}
+
 func repoGCToGlobal(r *types.RepoGC) types.GlobalRepoGC {
 	return types.GlobalRepoGC{
 		PeerMap: map[string]*types.RepoGC{
@@ -1,14 +1,21 @@
 package rest
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/api/common"
 	test "github.com/ipfs/ipfs-cluster/api/common/test"
 	clustertest "github.com/ipfs/ipfs-cluster/test"
 
@@ -201,6 +208,279 @@ func TestAPIAddFileEndpointLocal(t *testing.T) {
 	test.BothEndpoints(t, tf)
 }
 
+func TestAPIAddFileEndpointNoFreeSpace(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	sth := clustertest.NewShardingTestHelper()
+	defer sth.Clean(t)
+	_, closer := sth.GetTreeMultiReader(t)
+	closer.Close()
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		body, closer := sth.GetTreeMultiReader(t)
+		defer closer.Close()
+		mpContentType := "multipart/form-data; boundary=" + body.Boundary()
+		errResp := api.Error{}
+		// The mock cluster does not have 5 peers with free space.
+		test.MakeStreamingPost(t, rest, url(rest)+"/add?replication-min=5&replication-max=5", body, mpContentType, &errResp)
+		if errResp.Code != http.StatusInsufficientStorage {
+			t.Error("expected a 507 error when there is not enough free space:", errResp.Code)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIAddFileEndpointChecksum(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	sth := clustertest.NewShardingTestHelper()
+	defer sth.Clean(t)
+	_, closer := sth.GetTreeMultiReader(t)
+	closer.Close()
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		h := test.MakeHost(t, rest)
+		defer h.Close()
+		c := test.HTTPClient(t, h, test.IsHTTPS(url(rest)))
+
+		mfr, closer := sth.GetTreeMultiReader(t)
+		defer closer.Close()
+		body, err := ioutil.ReadAll(mfr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(body)
+		digest := api.FormatContentDigest(sum[:])
+		badSum := sha256.Sum256([]byte("something else"))
+
+		// addBody returns the status code of the request and the
+		// error sent in the trailer, if any.
+		addBody := func(body []byte, boundary, query, header, trailer string) (int, string) {
+			req, _ := http.NewRequest(http.MethodPost, url(rest)+"/add?stream-channels=true&"+query, bytes.NewReader(body))
+			req.ContentLength = -1
+			req.Header.Set("Content-Type", "multipart/form-data; boundary="+boundary)
+			if header != "" {
+				req.Header.Set(api.ContentDigestHeader, header)
+			}
+			if trailer != "" {
+				req.Trailer = http.Header{api.ContentDigestHeader: []string{trailer}}
+			}
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			ioutil.ReadAll(resp.Body)
+			return resp.StatusCode, resp.Trailer.Get("X-Stream-Error")
+		}
+		add := func(query, header, trailer string) (int, string) {
+			return addBody(body, mfr.Boundary(), query, header, trailer)
+		}
+
+		// partsBody returns a body with a file part for each of the
+		// given digests, which are set in the part headers.
+		partsBody := func(digests ...string) ([]byte, string) {
+			var buf bytes.Buffer
+			mw := multipart.NewWriter(&buf)
+			for i, d := range digests {
+				h := make(textproto.MIMEHeader)
+				h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="file%d"`, i))
+				h.Set("Content-Type", "application/octet-stream")
+				if d != "" {
+					h.Set(api.ContentDigestHeader, d)
+				}
+				w, _ := mw.CreatePart(h)
+				w.Write([]byte(fmt.Sprintf("content %d", i)))
+			}
+			mw.Close()
+			return buf.Bytes(), mw.Boundary()
+		}
+		partDigest := func(i int) string {
+			sum := sha256.Sum256([]byte(fmt.Sprintf("content %d", i)))
+			return api.FormatContentDigest(sum[:])
+		}
+
+		if code, errMsg := add("checksum=true", digest, ""); code != http.StatusOK || errMsg != "" {
+			t.Errorf("expected success with the right digest header: %d %s", code, errMsg)
+		}
+		if code, errMsg := add("checksum=true", "", digest); code != http.StatusOK || errMsg != "" {
+			t.Errorf("expected success with the right digest trailer: %d %s", code, errMsg)
+		}
+		if _, errMsg := add("", api.FormatContentDigest(badSum[:]), ""); !strings.Contains(errMsg, api.ErrContentDigestMismatch.Error()) {
+			t.Errorf("expected a digest error with the wrong header: %s", errMsg)
+		}
+		if _, errMsg := add("", "", api.FormatContentDigest(badSum[:])); !strings.Contains(errMsg, api.ErrContentDigestMismatch.Error()) {
+			t.Errorf("expected a digest error with the wrong trailer: %s", errMsg)
+		}
+		if _, errMsg := add("checksum=true", "", ""); !strings.Contains(errMsg, api.ErrContentDigestMismatch.Error()) {
+			t.Errorf("expected a digest error without digests: %s", errMsg)
+		}
+
+		pb, boundary := partsBody(partDigest(0), partDigest(1))
+		if code, errMsg := addBody(pb, boundary, "checksum=true", "", ""); code != http.StatusOK || errMsg != "" {
+			t.Errorf("expected success with the right part digests: %d %s", code, errMsg)
+		}
+		pb, boundary = partsBody(partDigest(0), partDigest(0))
+		if _, errMsg := addBody(pb, boundary, "checksum=true", "", ""); !strings.Contains(errMsg, "file1 part may be corrupted") {
+			t.Errorf("expected a digest error with a wrong part digest: %s", errMsg)
+		}
+		pb, boundary = partsBody(partDigest(0), "")
+		if _, errMsg := addBody(pb, boundary, "checksum=true", "", ""); !strings.Contains(errMsg, "file1 part has no") {
+			t.Errorf("expected a digest error with a missing part digest: %s", errMsg)
+		}
+		if code, _ := add("", "md5=:abcd:", ""); code != http.StatusBadRequest {
+			t.Errorf("expected a 400 error with a bad digest header: %d", code)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIAddFileEndpointEncrypt(t *testing.T) {
+	ctx := context.Background()
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.CORSAllowedOrigins = []string{clientOrigin}
+	cfg.CORSAllowedMethods = []string{"GET", "POST", "DELETE"}
+	cfg.EncryptionKeys = map[string]string{
+		"team": strings.Repeat("ab", 32),
+	}
+	rest := testAPIwithConfig(t, cfg, "encryption")
+	defer rest.Shutdown(ctx)
+
+	sth := clustertest.NewShardingTestHelper()
+	defer sth.Clean(t)
+	_, closer := sth.GetTreeMultiReader(t)
+	closer.Close()
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		add := func(query string, out interface{}) {
+			body, closer := sth.GetTreeMultiReader(t)
+			defer closer.Close()
+			mpContentType := "multipart/form-data; boundary=" + body.Boundary()
+			test.MakeStreamingPost(t, rest, url(rest)+"/add?stream-channels=true&"+query, body, mpContentType, out)
+		}
+
+		resp := api.AddedOutput{}
+		add("encrypt=team", &resp)
+		if !resp.Cid.Defined() || resp.Cid.String() == clustertest.ShardingDirBalancedRootCID {
+			t.Error("expected the encrypted content to have a different CID:", resp.Cid)
+		}
+
+		for _, query := range []string{"encrypt=other", "encrypt=user", "encrypt=team&format=car"} {
+			errResp := api.Error{}
+			add(query, &errResp)
+			if errResp.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected a 400 error: %d", query, errResp.Code)
+			}
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIDecryptEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		errResp := api.Error{}
+		test.MakeGet(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String()+"/decrypt", &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("expected a 400 error for a pin which is not encrypted:", errResp.Code)
+		}
+
+		errResp = api.Error{}
+		test.MakeGet(t, rest, url(rest)+"/pins/"+clustertest.ErrorCid.String()+"/decrypt", &errResp)
+		if errResp.Code != http.StatusNotFound {
+			t.Error("expected a 404 error:", errResp.Code)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIAddQuery(t *testing.T) {
+	ctx := context.Background()
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.AddParams = &common.AddParamsConfig{
+		Defaults: map[string]string{
+			"chunker":         "size-1048576",
+			"replication-min": "2",
+		},
+		Enforce: true,
+	}
+	cfg.UserAddParams = map[string]*common.AddParamsConfig{
+		validUserName: {
+			Defaults: map[string]string{
+				"chunker": "rabin",
+			},
+		},
+	}
+	rest := testAPIwithConfig(t, cfg, "add params")
+	defer rest.Shutdown(ctx)
+
+	newReq := func(query, user string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/add?"+query, nil)
+		return r.WithContext(api.ContextWithRequestUser(r.Context(), user))
+	}
+
+	q, err := rest.addQuery(newReq("cid-version=1", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Get("chunker") != "size-1048576" || q.Get("replication-min") != "2" || q.Get("cid-version") != "1" {
+		t.Error("defaults not applied:", q)
+	}
+
+	if _, err := rest.addQuery(newReq("chunker=rabin", "")); err == nil {
+		t.Error("expected an error changing an enforced parameter")
+	}
+	if _, err := rest.addQuery(newReq("replication=3", "")); err == nil {
+		t.Error("expected an error changing an enforced replication factor")
+	}
+	if _, err := rest.addQuery(newReq("chunker=size-1048576", "")); err != nil {
+		t.Error("setting the enforced value should work:", err)
+	}
+
+	// The user defaults are not enforced.
+	q, err = rest.addQuery(newReq("", validUserName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Get("chunker") != "rabin" {
+		t.Error("user defaults not applied:", q)
+	}
+	q, err = rest.addQuery(newReq("chunker=size-100", validUserName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Get("chunker") != "size-100" {
+		t.Error("the user should be able to change the chunker")
+	}
+	if _, err := rest.addQuery(newReq("replication-min=1", validUserName)); err == nil {
+		t.Error("expected an error changing an enforced parameter")
+	}
+
+	// A user which was not authenticated gets the global defaults.
+	r := httptest.NewRequest(http.MethodPost, "/add", nil)
+	r.SetBasicAuth(validUserName, "")
+	q, err = rest.addQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Get("chunker") != "size-1048576" {
+		t.Error("an unauthenticated user should not get user defaults:", q)
+	}
+}
+
 func TestAPIAddFileEndpointShard(t *testing.T) {
 	ctx := context.Background()
 	rest := testAPI(t)
@@ -273,6 +553,129 @@ func TestAPIPeerRemoveEndpoint(t *testing.T) {
 
 	tf := func(t *testing.T, url test.URLFunc) {
 		test.MakeDelete(t, rest, url(rest)+"/peers/"+clustertest.PeerID1.Pretty(), &struct{}{})
+		test.MakeDelete(t, rest, url(rest)+"/peers/"+clustertest.PeerName1, &struct{}{})
+
+		errResp := api.Error{}
+		test.MakeDelete(t, rest, url(rest)+"/peers/"+clustertest.PeerName2, &errResp)
+		if errResp.Code != http.StatusConflict {
+			t.Error("expected a conflict for an ambiguous peername:", errResp.Code)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIPeerEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		for _, name := range []string{clustertest.PeerID1.Pretty(), clustertest.PeerName1} {
+			var id api.ID
+			test.MakeGet(t, rest, url(rest)+"/peers/"+name, &id)
+			if id.ID != clustertest.PeerID1 {
+				t.Errorf("%s: unexpected peer ID %s", name, id.ID)
+			}
+		}
+
+		errResp := api.Error{}
+		test.MakeGet(t, rest, url(rest)+"/peers/nobody", &errResp)
+		if errResp.Code != http.StatusNotFound {
+			t.Error("expected a not found error for an unknown peername:", errResp.Code)
+		}
+
+		errResp = api.Error{}
+		test.MakeGet(t, rest, url(rest)+"/peers/"+clustertest.PeerName2, &errResp)
+		if errResp.Code != http.StatusConflict {
+			t.Error("expected a conflict for an ambiguous peername:", errResp.Code)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIPeerResourcesEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var res api.PeerResources
+		test.MakeGet(t, rest, url(rest)+"/peers/"+clustertest.PeerName1+"/resources", &res)
+		if res.Peer != clustertest.PeerID1 || res.NumCPU != 4 || res.BandwidthTotalIn != 1024 {
+			t.Errorf("unexpected resources: %+v", res)
+		}
+
+		errResp := api.Error{}
+		test.MakeGet(t, rest, url(rest)+"/peers/nobody/resources", &errResp)
+		if errResp.Code != http.StatusNotFound {
+			t.Error("expected a not found error for an unknown peername:", errResp.Code)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIPeerHandoverEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var handover api.PeerHandover
+		test.MakePost(t, rest, url(rest)+"/peers/"+clustertest.PeerID1.Pretty()+"/handover?to="+clustertest.PeerID2.Pretty(), []byte{}, &handover)
+		if handover.From != clustertest.PeerID1 || handover.To != clustertest.PeerID2 || handover.Pins != 3 {
+			t.Errorf("unexpected handover: %+v", handover)
+		}
+
+		errResp := api.Error{}
+		test.MakePost(t, rest, url(rest)+"/peers/"+clustertest.PeerID1.Pretty()+"/handover?to=abc", []byte{}, &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("expected a bad request with an invalid peer ID:", errResp.Code)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIRotateSecretEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var rot api.SecretRotation
+		test.MakePost(t, rest, url(rest)+"/secret/rotate", []byte{}, &rot)
+		if rot.Secret == "" || len(rot.Peers) != 3 {
+			t.Errorf("unexpected rotation: %+v", rot)
+		}
+
+		secret := "c1a7d2d4b2ab6ea1d9b5b9c0e21fcf3a43a1b1d6aeea7a2a4d45b8b3d7cf2c10"
+		rot = api.SecretRotation{}
+		test.MakePost(t, rest, url(rest)+"/secret/rotate", []byte(`{"secret":"`+secret+`"}`), &rot)
+		if rot.Secret != secret {
+			t.Error("expected the given secret to be used:", rot.Secret)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIPeerVersionsEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var pvs api.PeerVersions
+		test.MakeGet(t, rest, url(rest)+"/peers/versions", &pvs)
+		if len(pvs.Peers) != 2 || pvs.Peers[0].IPFSVersion != clustertest.IPFSAgentVersion {
+			t.Errorf("unexpected peer versions: %+v", pvs)
+		}
+		if !pvs.RPCProtocolSkew || len(pvs.RPCProtocolVersions) != 2 {
+			t.Error("expected RPC protocol skew")
+		}
 	}
 
 	test.BothEndpoints(t, tf)
@@ -307,6 +710,87 @@ func TestConnectGraphEndpoint(t *testing.T) {
 		if cg.ClustertoIPFS[peer.Encode(pid1)] != pid4 {
 			t.Error("unexpected ipfs peer mapped to cluster peer 1 in graph")
 		}
+		conns := cg.ClusterConnections[peer.Encode(pid1)]
+		if len(conns) != 2 || conns[0].Transport != "quic" || conns[0].Latency != 5*time.Millisecond {
+			t.Error("unexpected cluster connections")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIGraphHistoryEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var resp []*api.ConnectivitySnapshot
+		test.MakeGet(t, rest, url(rest)+"/health/graph/history", &resp)
+		if len(resp) != 2 {
+			t.Fatal("expected two snapshots")
+		}
+		if len(resp[0].Unreachable) != 1 || len(resp[1].ClusterLinks) != 2 {
+			t.Error("unexpected snapshots")
+		}
+
+		since := time.Now().Add(-30 * time.Minute).UTC().Format(time.RFC3339)
+		resp = nil
+		test.MakeGet(t, rest, url(rest)+"/health/graph/history?since="+since, &resp)
+		if len(resp) != 1 {
+			t.Error("expected one snapshot")
+		}
+
+		errResp := api.Error{}
+		test.MakeGet(t, rest, url(rest)+"/health/graph/history?since=yesterday", &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("expected a bad request error")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIGraphEndpointFormats(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	getGraph := func(t *testing.T, url string) (*http.Response, string) {
+		h := test.MakeHost(t, rest)
+		defer h.Close()
+		c := test.HTTPClient(t, h, test.IsHTTPS(url))
+		httpResp, err := c.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer httpResp.Body.Close()
+		body, err := ioutil.ReadAll(httpResp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return httpResp, string(body)
+	}
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		resp, body := getGraph(t, url(rest)+"/health/graph?format=dot")
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/vnd.graphviz" {
+			t.Errorf("unexpected response: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		if !strings.HasPrefix(body, "digraph cluster {") || !strings.Contains(body, `label="quic outbound 5ms"`) {
+			t.Error("unexpected dot graph:", body)
+		}
+
+		resp, body = getGraph(t, url(rest)+"/health/graph?format=mermaid")
+		if resp.StatusCode != http.StatusOK || !strings.HasPrefix(body, "flowchart") {
+			t.Error("unexpected mermaid graph:", body)
+		}
+
+		errResp := api.Error{}
+		test.MakeGet(t, rest, url(rest)+"/health/graph?format=png", &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("expected a bad request error")
+		}
 	}
 
 	test.BothEndpoints(t, tf)
@@ -336,6 +820,60 @@ func TestAPIPinEndpoint(t *testing.T) {
 	test.BothEndpoints(t, tf)
 }
 
+func TestAPIPinUpdateEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var pin api.Pin
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String()+"?pin-update="+clustertest.Cid3.String(), []byte{}, &pin)
+		if !pin.PinUpdate.Equals(clustertest.Cid3) {
+			t.Error("unexpected pin-update: ", pin.PinUpdate)
+		}
+
+		// Paths are resolved (to Cid2 by the mock).
+		pin = api.Pin{}
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String()+"?pin-update=/ipns/example.org", []byte{}, &pin)
+		if !pin.PinUpdate.Equals(clustertest.Cid2) {
+			t.Error("expected the pin-update path to be resolved: ", pin.PinUpdate)
+		}
+
+		errResp := api.Error{}
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String()+"?pin-update=abcd", []byte{}, &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("expected a bad request: ", errResp.Code)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIPinDryRunEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var pin api.Pin
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String()+"?dry-run=true&replication=2", []byte{}, &pin)
+		if !pin.Cid.Equals(clustertest.Cid1) {
+			t.Error("unexpected pin cid: ", pin.Cid)
+		}
+		if len(pin.Allocations) != 2 || pin.Allocations[0] != clustertest.PeerID1 {
+			t.Error("expected planned allocations: ", pin.Allocations)
+		}
+
+		errResp := api.Error{}
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.ErrorCid.String()+"?dry-run=true", []byte{}, &errResp)
+		if errResp.Message != clustertest.ErrBadCid.Error() {
+			t.Error("expected different error: ", errResp.Message)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
 type pathCase struct {
 	path        string
 	opts        api.PinOptions
@@ -463,6 +1001,79 @@ func TestAPIUnpinEndpoint(t *testing.T) {
 	test.BothEndpoints(t, tf)
 }
 
+func TestAPIRestoreEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var pin api.Pin
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String()+"/restore", []byte{}, &pin)
+		if !pin.Cid.Equals(clustertest.Cid1) {
+			t.Error("unexpected pin:", pin.Cid)
+		}
+
+		errResp := api.Error{}
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.NotFoundCid.String()+"/restore", []byte{}, &errResp)
+		if errResp.Code != http.StatusNotFound {
+			t.Error("expected different error code: ", errResp.Code)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIAuditEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var audit api.GlobalDAGAudit
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String()+"/audit?repair=true", []byte{}, &audit)
+		if !audit.Cid.Equals(clustertest.Cid1) {
+			t.Error("unexpected audit:", audit.Cid)
+		}
+		peerAudit, ok := audit.PeerMap[peer.Encode(clustertest.PeerID1)]
+		if !ok {
+			t.Fatal("expected an audit for the peer")
+		}
+		if len(peerAudit.Corrupted) != 1 || !peerAudit.Repaired {
+			t.Error("expected a repaired corrupted block")
+		}
+
+		errResp := api.Error{}
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.NotFoundCid.String()+"/audit", []byte{}, &errResp)
+		if errResp.Code != http.StatusNotFound {
+			t.Error("expected different error code: ", errResp.Code)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIUnprotectEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var pin api.Pin
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String()+"/unprotect", []byte{}, &pin)
+		if !pin.Cid.Equals(clustertest.Cid1) {
+			t.Error("unexpected pin:", pin.Cid)
+		}
+
+		errResp := api.Error{}
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.NotFoundCid.String()+"/unprotect", []byte{}, &errResp)
+		if errResp.Code != http.StatusNotFound {
+			t.Error("expected different error code: ", errResp.Code)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
 func TestAPIUnpinEndpointWithPath(t *testing.T) {
 	ctx := context.Background()
 	rest := testAPI(t)
@@ -513,21 +1124,74 @@ func TestAPIAllocationsEndpoint(t *testing.T) {
 			t.Error("unexpected pin list: ", resp)
 		}
 
-		test.MakeGet(t, rest, url(rest)+"/allocations", &resp)
-		if len(resp) != 3 ||
-			!resp[0].Cid.Equals(clustertest.Cid1) || !resp[1].Cid.Equals(clustertest.Cid2) ||
-			!resp[2].Cid.Equals(clustertest.Cid3) {
-			t.Error("unexpected pin list: ", resp)
+		test.MakeGet(t, rest, url(rest)+"/allocations", &resp)
+		if len(resp) != 3 ||
+			!resp[0].Cid.Equals(clustertest.Cid1) || !resp[1].Cid.Equals(clustertest.Cid2) ||
+			!resp[2].Cid.Equals(clustertest.Cid3) {
+			t.Error("unexpected pin list: ", resp)
+		}
+
+		errResp := api.Error{}
+		test.MakeGet(t, rest, url(rest)+"/allocations?filter=invalid", &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("an invalid filter value should 400")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIAllocationsNotModified(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	for _, path := range []string{"/allocations", "/pins"} {
+		resp, err := http.Get(test.HTTPURL(rest) + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		etag := resp.Header.Get("ETag")
+		if resp.StatusCode != http.StatusOK || etag == "" {
+			t.Fatalf("%s: expected an ETag", path)
 		}
 
-		errResp := api.Error{}
-		test.MakeGet(t, rest, url(rest)+"/allocations?filter=invalid", &errResp)
-		if errResp.Code != http.StatusBadRequest {
-			t.Error("an invalid filter value should 400")
+		// The mock responses carry new timestamps on every request,
+		// so they never match a previous ETag.
+		req, _ := http.NewRequest(http.MethodGet, test.HTTPURL(rest)+path, nil)
+		req.Header.Set("If-None-Match", "*")
+		resp, err = http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotModified {
+			t.Errorf("%s: expected 304 but got %d", path, resp.StatusCode)
 		}
 	}
 
-	test.BothEndpoints(t, tf)
+	// The allocations ETag follows the pinset sequence number, which
+	// does not change in the mock.
+	get := func(path, etag string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, test.HTTPURL(rest)+path, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	etag := get("/allocations", "").Header.Get("ETag")
+	if resp := get("/allocations", etag); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("expected 304 for the same pinset but got %d", resp.StatusCode)
+	}
+	if resp := get("/allocations?filter=pin", etag); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 for a different query but got %d", resp.StatusCode)
+	}
 }
 
 func TestAPIAllocationEndpoint(t *testing.T) {
@@ -592,6 +1256,25 @@ func TestAPIMetricNamesEndpoint(t *testing.T) {
 	test.BothEndpoints(t, tf)
 }
 
+func TestAPIDetectorStateEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var resp []*api.DetectorState
+		test.MakeGet(t, rest, url(rest)+"/monitor/detector", &resp)
+		if len(resp) != 2 {
+			t.Fatal("expected two detector states")
+		}
+		if resp[0].Failed || !resp[1].Failed || !resp[1].Suppressed {
+			t.Error("unexpected detector states")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
 func TestAPIAlertsEndpoint(t *testing.T) {
 	ctx := context.Background()
 	rest := testAPI(t)
@@ -608,6 +1291,22 @@ func TestAPIAlertsEndpoint(t *testing.T) {
 	test.BothEndpoints(t, tf)
 }
 
+func TestAPIConsensusStatsEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var resp []*api.ConsensusStats
+		test.MakeGet(t, rest, url(rest)+"/health/consensus", &resp)
+		if len(resp) != 3 || resp[1].CRDT == nil || resp[1].SyncLag != 3 || resp[2].Error == "" {
+			t.Errorf("unexpected consensus stats: %+v", resp)
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
 func TestAPIStatusAllEndpoint(t *testing.T) {
 	ctx := context.Background()
 	rest := testAPI(t)
@@ -710,6 +1409,46 @@ func TestAPIStatusEndpoint(t *testing.T) {
 	test.BothEndpoints(t, tf)
 }
 
+func TestAPIStatusCidsEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		body := fmt.Sprintf(`["%s", "%s"]`, clustertest.Cid2, clustertest.Cid1)
+		var resp []*api.GlobalPinInfo
+		test.MakePost(t, rest, url(rest)+"/pins/status", []byte(body), &resp)
+		if len(resp) != 2 || !resp[0].Cid.Equals(clustertest.Cid2) || !resp[1].Cid.Equals(clustertest.Cid1) {
+			t.Fatalf("unexpected status response: %+v", resp)
+		}
+		if _, ok := resp[0].PeerMap[peer.Encode(clustertest.PeerID1)]; !ok {
+			t.Error("expected info for clustertest.PeerID1")
+		}
+
+		var resp2 []*api.GlobalPinInfo
+		test.MakePost(t, rest, url(rest)+"/pins/status?local=true", []byte(body), &resp2)
+		if len(resp2) != 2 || !resp2[0].Cid.Equals(clustertest.Cid2) {
+			t.Fatalf("unexpected local status response: %+v", resp2)
+		}
+		if _, ok := resp2[0].PeerMap[peer.Encode(clustertest.PeerID2)]; !ok {
+			t.Error("expected info for clustertest.PeerID2")
+		}
+
+		var errResp api.Error
+		test.MakePost(t, rest, url(rest)+"/pins/status", []byte(`["not a cid"]`), &errResp)
+		if errResp.Code != 400 {
+			t.Error("expected a 400 error with an invalid cid")
+		}
+		errResp = api.Error{}
+		test.MakePost(t, rest, url(rest)+"/pins/status", []byte(`{}`), &errResp)
+		if errResp.Code != 400 {
+			t.Error("expected a 400 error with an invalid body")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
 func TestAPIRecoverEndpoint(t *testing.T) {
 	ctx := context.Background()
 	rest := testAPI(t)
@@ -734,6 +1473,112 @@ func TestAPIRecoverEndpoint(t *testing.T) {
 	test.BothEndpoints(t, tf)
 }
 
+func TestAPIReshardEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var resp api.Operation
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String()+"/reshard?raw-leaves=true", []byte{}, &resp)
+		if resp.Type != api.OperationReshard || !resp.Cid.Equals(clustertest.Cid1) {
+			t.Error("expected a reshard operation for the pin")
+		}
+
+		errResp := api.Error{}
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String()+"/reshard?shard-size=abc", []byte{}, &errResp)
+		if errResp.Code != 400 {
+			t.Error("expected a bad request error")
+		}
+
+		errResp = api.Error{}
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.ErrorCid.String()+"/reshard", []byte{}, &errResp)
+		if errResp.Code != 500 {
+			t.Error("expected an error")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIShardsEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var resp []*api.ShardInfo
+		test.MakeGet(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String()+"/shards", &resp)
+		if len(resp) != 2 {
+			t.Fatal("expected 2 shards")
+		}
+		if !resp[0].Cid.Equals(clustertest.Cid2) || resp[0].Size == 0 {
+			t.Error("unexpected shard info")
+		}
+		if resp[1].Status == nil || !resp[1].Status.Cid.Equals(clustertest.Cid3) {
+			t.Error("expected shard status")
+		}
+
+		errResp := api.Error{}
+		test.MakeGet(t, rest, url(rest)+"/pins/"+clustertest.ErrorCid.String()+"/shards", &errResp)
+		if errResp.Code != 500 {
+			t.Error("expected an error")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIRecoverShardEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var resp api.GlobalPinInfo
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String()+"/shards/"+clustertest.Cid2.String()+"/recover", []byte{}, &resp)
+		if !resp.Cid.Equals(clustertest.Cid2) {
+			t.Error("expected the shard cid")
+		}
+
+		errResp := api.Error{}
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String()+"/shards/"+clustertest.Cid4.String()+"/recover", []byte{}, &errResp)
+		if errResp.Code != 404 {
+			t.Error("expected a not found error for a shard not in the pin")
+		}
+
+		errResp = api.Error{}
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String()+"/shards/abcd/recover", []byte{}, &errResp)
+		if errResp.Code != 400 {
+			t.Error("expected a bad request error")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIReconstructEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var resp api.ErasureReconstruction
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.Cid1.String()+"/reconstruct", []byte{}, &resp)
+		if !resp.Cid.Equals(clustertest.Cid1) || resp.Blocks != 3 || len(resp.UsedShards) != 2 {
+			t.Error("unexpected reconstruction:", resp)
+		}
+
+		errResp := api.Error{}
+		test.MakePost(t, rest, url(rest)+"/pins/"+clustertest.ErrorCid.String()+"/reconstruct", []byte{}, &errResp)
+		if errResp.Code != 500 {
+			t.Error("expected an error")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
 func TestAPIRecoverAllEndpoint(t *testing.T) {
 	ctx := context.Background()
 	rest := testAPI(t)
@@ -802,3 +1647,231 @@ func TestAPIIPFSGCEndpoint(t *testing.T) {
 
 	test.BothEndpoints(t, tf)
 }
+
+func TestAPIPinsByName(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var pins []*api.Pin
+		test.MakeGet(t, rest, url(rest)+"/allocations?name="+clustertest.PinName3, &pins)
+		if len(pins) != 1 || !pins[0].Cid.Equals(clustertest.Cid3) {
+			t.Error("expected the pin with the given name")
+		}
+
+		var none []*api.Pin
+		test.MakeGet(t, rest, url(rest)+"/allocations?name=testpin", &none)
+		if len(none) != 0 {
+			t.Error("names should match exactly")
+		}
+
+		var prefixed []*api.Pin
+		test.MakeGet(t, rest, url(rest)+"/allocations?name-prefix=testpin", &prefixed)
+		if len(prefixed) != 1 {
+			t.Error("expected the pin with the given prefix")
+		}
+
+		var gpis []*api.GlobalPinInfo
+		test.MakeGet(t, rest, url(rest)+"/pins?name="+clustertest.PinName3, &gpis)
+		if len(gpis) != 1 || !gpis[0].Cid.Equals(clustertest.Cid3) {
+			t.Error("expected the status of the pin with the given name")
+		}
+
+		var localGpis []*api.GlobalPinInfo
+		test.MakeGet(t, rest, url(rest)+"/pins?local=true&name-prefix=testpin", &localGpis)
+		if len(localGpis) != 1 {
+			t.Error("expected the local status of the pin with the given prefix")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIAllocationChangesEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var current api.PinChanges
+		test.MakeGet(t, rest, url(rest)+"/allocations/changes", &current)
+		if current.Sequence != clustertest.PinSequence1 || len(current.Changes) != 0 {
+			t.Error("expected only the current sequence number")
+		}
+
+		var changes api.PinChanges
+		test.MakeGet(t, rest, fmt.Sprintf("%s/allocations/changes?since=%d", url(rest), clustertest.PinSequence1-2), &changes)
+		if len(changes.Changes) != 2 {
+			t.Fatal("expected two changes")
+		}
+		if ch := changes.Changes[0]; ch.Removed || ch.Pin == nil || !ch.Pin.Cid.Equals(clustertest.Cid1) {
+			t.Error("expected the pin for Cid1")
+		}
+		if ch := changes.Changes[1]; !ch.Removed || !ch.Cid.Equals(clustertest.Cid2) {
+			t.Error("expected Cid2 to be removed")
+		}
+
+		var errResp api.Error
+		test.MakeGet(t, rest, url(rest)+"/allocations/changes?since=1", &errResp)
+		if errResp.Code != http.StatusGone {
+			t.Error("expected a 410 for unavailable changes")
+		}
+
+		test.MakeGet(t, rest, url(rest)+"/allocations/changes?since=abc", &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("expected a 400 for a bad sequence number")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPILifecycleEventsEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var all api.LifecycleEvents
+		test.MakeGet(t, rest, url(rest)+"/events", &all)
+		if all.Sequence != 3 || len(all.Events) != 3 {
+			t.Fatal("expected all the events")
+		}
+
+		var events api.LifecycleEvents
+		test.MakeGet(t, rest, url(rest)+"/events?since=2", &events)
+		if len(events.Events) != 1 {
+			t.Fatal("expected one event")
+		}
+		if ev := events.Events[0]; ev.Event != api.LifecycleEventPeerJoined || ev.Subject != clustertest.PeerID2 {
+			t.Error("unexpected event:", ev)
+		}
+
+		var errResp api.Error
+		test.MakeGet(t, rest, url(rest)+"/events?since=abc", &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("expected a 400 for a bad sequence number")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIOperationsEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var ops []*api.Operation
+		test.MakeGet(t, rest, url(rest)+"/operations", &ops)
+		if len(ops) != 1 || ops[0].ID != clustertest.OperationID1 {
+			t.Fatal("expected one operation")
+		}
+
+		var op api.Operation
+		test.MakeGet(t, rest, url(rest)+"/operations/"+clustertest.OperationID1, &op)
+		if op.Status != api.OperationRunning || op.Total != 2 {
+			t.Error("unexpected operation:", op)
+		}
+
+		var canceled api.Operation
+		test.MakeDelete(t, rest, url(rest)+"/operations/"+clustertest.OperationID1, &canceled)
+		if canceled.Status != api.OperationCanceled {
+			t.Error("expected a canceled operation")
+		}
+
+		var errResp api.Error
+		test.MakeDelete(t, rest, url(rest)+"/operations/abc", &errResp)
+		if errResp.Code != http.StatusNotFound {
+			t.Error("expected a 404 for an unknown operation")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIRPCPolicyEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var policy map[string]string
+		test.MakeGet(t, rest, url(rest)+"/rpc/policy", &policy)
+		if policy["Cluster.ID"] != "open" {
+			t.Error("expected Cluster.ID to be open")
+		}
+
+		var newPolicy map[string]string
+		test.MakePost(t, rest, url(rest)+"/rpc/policy", []byte(`{"Cluster.ID": "trusted"}`), &newPolicy)
+		if newPolicy["Cluster.ID"] != "trusted" {
+			t.Error("expected Cluster.ID to be trusted")
+		}
+
+		var errResp api.Error
+		test.MakePost(t, rest, url(rest)+"/rpc/policy", []byte(`{}`), &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("expected a bad request for an empty policy change")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPIListenersEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var listeners []*api.APIListeners
+		test.MakeGet(t, rest, url(rest)+"/apis/listeners", &listeners)
+		if len(listeners) != 1 || listeners[0].API != "restapi" {
+			t.Fatal("unexpected listeners:", listeners)
+		}
+
+		var rebound api.APIListeners
+		test.MakePost(t, rest, url(rest)+"/apis/listeners", []byte(`{"api": "restapi", "http_listen_multiaddress": ["/ip4/127.0.0.1/tcp/9095"]}`), &rebound)
+		if len(rebound.HTTPListenMultiaddress) != 1 || rebound.HTTPListenMultiaddress[0] != "/ip4/127.0.0.1/tcp/9095" {
+			t.Error("expected the new listen address:", rebound.HTTPListenMultiaddress)
+		}
+
+		var errResp api.Error
+		test.MakePost(t, rest, url(rest)+"/apis/listeners", []byte(`{"http_listen_multiaddress": []}`), &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("expected a bad request without api")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
+
+func TestAPITrackerSettingsEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var settings api.TrackerSettings
+		test.MakeGet(t, rest, url(rest)+"/pintracker/settings", &settings)
+		if settings.ConcurrentPins != 10 {
+			t.Error("unexpected concurrent_pins:", settings.ConcurrentPins)
+		}
+
+		var newSettings api.TrackerSettings
+		test.MakePost(t, rest, url(rest)+"/pintracker/settings", []byte(`{"concurrent_pins": 3}`), &newSettings)
+		if newSettings.ConcurrentPins != 3 {
+			t.Error("expected concurrent_pins to be changed")
+		}
+
+		var errResp api.Error
+		test.MakePost(t, rest, url(rest)+"/pintracker/settings", []byte(`{"concurrent_pins": -1}`), &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("expected a bad request for negative concurrent_pins")
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
@@ -0,0 +1,202 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	types "github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/api/common"
+
+	cid "github.com/ipfs/go-cid"
+
+	mux "github.com/gorilla/mux"
+)
+
+// Pinset snapshots are managed under /pinset/snapshots. Rolling back to a
+// snapshot, with POST /pinset/snapshots/{name}/rollback, pins and unpins
+// items until the pinset matches it. With multi-tenancy, the rollback is
+// refused when the pins it restores exceed the quota of their namespace.
+
+func snapshotErrorStatus(err error) int {
+	if err != nil && err.Error() == types.ErrPinsetSnapshotNotFound.Error() {
+		return http.StatusNotFound
+	}
+	return common.SetStatusAutomatically
+}
+
+func (api *API) pinsetSnapshotsHandler(w http.ResponseWriter, r *http.Request) {
+	var snaps []*types.PinsetSnapshot
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PinsetSnapshots",
+		struct{}{},
+		&snaps,
+	)
+	api.SendResponse(w, common.SetStatusAutomatically, err, snaps)
+}
+
+func (api *API) pinsetSnapshotHandler(w http.ResponseWriter, r *http.Request) {
+	var snap types.PinsetSnapshot
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PinsetSnapshot",
+		mux.Vars(r)["name"],
+		&snap,
+	)
+	api.SendResponse(w, snapshotErrorStatus(err), err, snap)
+}
+
+// pinsetSnapshotCreateHandler takes the name of the snapshot from the
+// "name" parameter.
+func (api *API) pinsetSnapshotCreateHandler(w http.ResponseWriter, r *http.Request) {
+	var snap types.PinsetSnapshot
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PinsetSnapshotCreate",
+		r.URL.Query().Get("name"),
+		&snap,
+	)
+	if err != nil {
+		api.SendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+	api.SendResponse(w, common.SetStatusAutomatically, nil, snap)
+}
+
+func (api *API) pinsetSnapshotRemoveHandler(w http.ResponseWriter, r *http.Request) {
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PinsetSnapshotRemove",
+		mux.Vars(r)["name"],
+		&struct{}{},
+	)
+	api.SendResponse(w, snapshotErrorStatus(err), err, nil)
+}
+
+func (api *API) pinsetRollbackHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	dryRun := r.URL.Query().Get("dry-run") == "true"
+	if !dryRun {
+		if status, err := api.checkRollbackQuotas(r.Context(), name); err != nil {
+			api.SendResponse(w, status, err, nil)
+			return
+		}
+	}
+
+	var res types.PinsetRollback
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PinsetRollback",
+		types.PinsetRollback{
+			Snapshot: name,
+			DryRun:   dryRun,
+		},
+		&res,
+	)
+	api.SendResponse(w, snapshotErrorStatus(err), err, res)
+}
+
+// checkRollbackQuotas returns an error when rolling back to the given
+// snapshot would place more pins in a namespace than its quota allows, as
+// pinning them would.
+func (api *API) checkRollbackQuotas(ctx context.Context, name string) (int, error) {
+	if !api.config.Tenancy.IsEnabled() {
+		return 0, nil
+	}
+
+	var plan types.PinsetRollback
+	err := api.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"PinsetRollback",
+		types.PinsetRollback{Snapshot: name, DryRun: true},
+		&plan,
+	)
+	if err != nil {
+		return snapshotErrorStatus(err), err
+	}
+	if len(plan.Pinned) == 0 {
+		return 0, nil
+	}
+
+	var snap types.PinsetSnapshot
+	err = api.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"PinsetSnapshot",
+		name,
+		&snap,
+	)
+	if err != nil {
+		return snapshotErrorStatus(err), err
+	}
+	var current []*types.Pin
+	err = api.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"Pins",
+		struct{}{},
+		&current,
+	)
+	if err != nil {
+		return common.SetStatusAutomatically, err
+	}
+
+	// Items which are pinned or unpinned by the rollback stop counting
+	// with their current options.
+	changed := cid.NewSet()
+	for _, ci := range plan.Pinned {
+		changed.Add(ci)
+	}
+	for _, ci := range plan.Unpinned {
+		changed.Add(ci)
+	}
+	restored := make(map[string][]*types.Pin)
+	for _, p := range snap.Pins {
+		if p.Namespace != "" && changed.Has(p.Cid) {
+			restored[p.Namespace] = append(restored[p.Namespace], p)
+		}
+	}
+
+	for ns, pins := range restored {
+		quota := api.config.Tenancy.QuotaFor(ns)
+		if quota == nil {
+			continue
+		}
+		count := 0
+		var total uint64
+		for _, p := range filterNamespace(current, ns) {
+			if changed.Has(p.Cid) {
+				continue
+			}
+			pins = append(pins, p)
+		}
+		for _, p := range pins {
+			if p.Type == types.DataType || p.Type == types.MetaType {
+				count++
+				total += p.ExpectedSize
+			}
+		}
+		if quota.MaxPins > 0 && count > quota.MaxPins {
+			return http.StatusForbidden, fmt.Errorf("rolling back to %s: namespace %s would exceed its quota of %d pins", name, ns, quota.MaxPins)
+		}
+		if quota.MaxBytes > 0 && total > quota.MaxBytes {
+			return http.StatusForbidden, fmt.Errorf("rolling back to %s: namespace %s would exceed its quota of %d bytes", name, ns, quota.MaxBytes)
+		}
+	}
+	return 0, nil
+}
@@ -0,0 +1,424 @@
+package rest
+
+import (
+	"net/http"
+
+	types "github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/api/common"
+	"github.com/ipfs/ipfs-cluster/version"
+)
+
+const specTitle = "IPFS Cluster REST API"
+
+var pinOptionsParams = []common.Param{
+	{Name: "name", Description: "name of the pin"},
+	{Name: "mode", Description: "recursive or direct"},
+	{Name: "replication", Description: "sets both replication-min and replication-max", Type: "integer"},
+	{Name: "replication-min", Description: "minimum replication factor", Type: "integer"},
+	{Name: "replication-max", Description: "maximum replication factor", Type: "integer"},
+	{Name: "shard-size", Type: "integer"},
+	{Name: "expected-size", Description: "expected size of the content in bytes", Type: "integer"},
+	{Name: "user-allocations", Description: "comma-separated list of peer IDs to allocate to"},
+	{Name: "exclude-allocations", Description: "comma-separated list of peer IDs which must never be allocated the pin"},
+	{Name: "expire-at", Description: "RFC3339 expiration date"},
+	{Name: "expire-in", Description: "duration after which the pin expires"},
+	{Name: "schedule-at", Description: "RFC3339 date at which the content starts being pinned"},
+	{Name: "verify-interval", Description: "interval to check that the content is still pinned"},
+	{Name: "pin-timeout", Description: "time without progress after which pinning fails"},
+	{Name: "unpin-timeout", Description: "time after which unpinning fails"},
+	{Name: "timeout-profile", Description: "name of a timeout profile of the IPFS connector"},
+	{Name: "provide-strategy", Description: "what the allocations announce to the DHT: roots, all or none"},
+	{Name: "ack", Description: "when the request returns: local, consensus (default) or replicated"},
+	{Name: "ack-peers", Description: "number of peers which must have the pin with the replicated ack", Type: "integer"},
+	{Name: "pin-update", Description: "CID or IPFS path of a pin from which this one is an update"},
+	{Name: "origins", Description: "comma-separated list of multiaddresses providing the content"},
+	{Name: "namespace"},
+	{Name: "protected", Description: "prevent unpinning and expiry until an admin unprotects the pin", Type: "boolean"},
+	{Name: "profile", Description: "name of a pin profile to apply"},
+	{Name: "meta-<key>", Description: "metadata value for <key>"},
+}
+
+var addParams = append([]common.Param{
+	{Name: "local", Description: "add only to the local IPFS daemon before pinning", Type: "boolean"},
+	{Name: "recursive", Type: "boolean"},
+	{Name: "hidden", Type: "boolean"},
+	{Name: "wrap-with-directory", Type: "boolean"},
+	{Name: "shard", Description: "shard the content across peers", Type: "boolean"},
+	{Name: "erasure-data-shards", Description: "erasure-code the content in this number of data shards (experimental)", Type: "integer"},
+	{Name: "erasure-parity-shards", Description: "number of parity shards of erasure-coded content", Type: "integer"},
+	{Name: "progress", Type: "boolean"},
+	{Name: "stream-channels", Description: "stream the output objects as they are produced", Type: "boolean"},
+	{Name: "nocopy", Type: "boolean"},
+	{Name: "raw-leaves", Type: "boolean"},
+	{Name: "cid-version", Type: "integer"},
+	{Name: "layout", Description: "balanced or trickle"},
+	{Name: "chunker"},
+	{Name: "hash"},
+	{Name: "format", Description: "unixfs or car"},
+	{Name: "expected-cid", Description: "fail unless the added content has this CID"},
+	{Name: "encrypt", Description: "encrypt the files with this configured key, or with the key in the X-Encryption-Key header when \"user\""},
+}, pinOptionsParams...)
+
+var selectorParam = common.Param{Name: "meta.<key>", Description: "selects the pins with this metadata value, or with any value when empty"}
+
+var localParam = common.Param{Name: "local", Description: "only query the peer serving the request", Type: "boolean"}
+
+var statusParams = []common.Param{
+	localParam,
+	{Name: "filter", Description: "comma-separated list of tracker statuses"},
+	{Name: "name", Description: "only pins with this name"},
+	{Name: "name-prefix", Description: "only pins with names starting with this prefix"},
+}
+
+// routeSpecs describes the routes of the REST API in its OpenAPI document.
+// Every route must have an entry.
+var routeSpecs = map[string]common.RouteSpec{
+	"ID": {
+		Summary:  "Information about the peer serving the request",
+		Response: types.ID{},
+	},
+	"Version": {
+		Summary:  "Version of the peer",
+		Response: types.Version{},
+	},
+	"Peers": {
+		Summary:  "Information about every cluster peer",
+		Response: []*types.ID{},
+	},
+	"PeerAdd": {
+		Summary:  "Add a peer to the cluster",
+		Request:  peerAddBody{},
+		Response: types.ID{},
+	},
+	"PeerVersions": {
+		Summary:  "Versions of cluster, the RPC protocol and IPFS run by the cluster peers",
+		Response: types.PeerVersions{},
+	},
+	"Peer": {
+		Summary:  "Information about the peer with the given peer ID or peername",
+		Response: types.ID{},
+	},
+	"PeerRemove": {
+		Summary: "Remove the peer with the given peer ID or peername from the cluster",
+	},
+	"PeerResources": {
+		Summary:  "Memory, CPU, bandwidth and storage used by the peer with the given peer ID or peername and by its IPFS daemon",
+		Response: types.PeerResources{},
+	},
+	"PeerHandover": {
+		Summary: "Transfer the allocations of the given peer to a different one, as after rotating the identity of a peer",
+		Query: []common.Param{
+			{Name: "to", Description: "peer ID receiving the allocations"},
+		},
+		Response: types.PeerHandover{},
+	},
+	"Add": {
+		Summary:            "Add content to IPFS and pin it in the cluster",
+		Query:              addParams,
+		RequestContentType: "multipart/form-data",
+		Response:           []*types.AddedOutput{},
+	},
+	"Allocations": {
+		Summary: "List the pins in the pinset",
+		Query: []common.Param{
+			{Name: "filter", Description: "comma-separated list of pin types"},
+			{Name: "name", Description: "only pins with this name"},
+			{Name: "name-prefix", Description: "only pins with names starting with this prefix"},
+		},
+		Response: []*types.Pin{},
+	},
+	"AllocationChanges": {
+		Summary:  "Changes to the pinset since a sequence number",
+		Query:    []common.Param{{Name: "since", Type: "integer"}},
+		Response: types.PinChanges{},
+	},
+	"Allocation": {
+		Summary:  "A pin in the pinset",
+		Response: types.Pin{},
+	},
+	"StatusAll": {
+		Summary:  "Status of all the pins",
+		Query:    statusParams,
+		Response: []*types.GlobalPinInfo{},
+	},
+	"Reshard": {
+		Summary:  "Shard an existing pin again with new options",
+		Query:    addParams,
+		Response: types.Operation{},
+	},
+	"Recover": {
+		Summary:  "Retry pinning or unpinning an item in error",
+		Query:    []common.Param{localParam},
+		Response: types.GlobalPinInfo{},
+	},
+	"RecoverAll": {
+		Summary:  "Retry all the pins and unpins in error, or those of the pins selected by metadata",
+		Query:    []common.Param{localParam, selectorParam},
+		Response: []*types.GlobalPinInfo{},
+	},
+	"UnpinMatching": {
+		Summary:  "Unpin all the pins selected by metadata",
+		Query:    []common.Param{selectorParam},
+		Response: []*types.Pin{},
+	},
+	"ReplicateMatching": {
+		Summary: "Set the replication factors of all the pins selected by metadata",
+		Query: []common.Param{
+			selectorParam,
+			{Name: "replication", Description: "sets both replication-min and replication-max", Type: "integer"},
+			{Name: "replication-min", Description: "minimum replication factor", Type: "integer"},
+			{Name: "replication-max", Description: "maximum replication factor", Type: "integer"},
+		},
+		Response: []*types.Pin{},
+	},
+	"StatusCids": {
+		Summary:  "Status of the pins with the CIDs in the request, in the same order",
+		Query:    []common.Param{localParam},
+		Request:  []string{},
+		Response: []*types.GlobalPinInfo{},
+	},
+	"Shards": {
+		Summary:  "Shards of a sharded pin",
+		Response: []*types.ShardInfo{},
+	},
+	"RecoverShard": {
+		Summary:  "Retry pinning a shard of a sharded pin",
+		Response: types.GlobalPinInfo{},
+	},
+	"Reconstruct": {
+		Summary:  "Rebuild erasure-coded content in the IPFS daemon of the peer",
+		Response: types.ErasureReconstruction{},
+	},
+	"Decrypt": {
+		Summary: "Decrypted content of an encrypted file, read from the IPFS daemon of the peer",
+		Query: []common.Param{
+			{Name: "path", Description: "path of the file inside the pinned directory"},
+		},
+		ResponseContentType: "application/octet-stream",
+	},
+	"Status": {
+		Summary:  "Status of a pin",
+		Query:    []common.Param{localParam},
+		Response: types.GlobalPinInfo{},
+	},
+	"Pin": {
+		Summary: "Pin a CID",
+		Query: append([]common.Param{
+			{Name: "dry-run", Description: "return the planned allocations without pinning", Type: "boolean"},
+		}, pinOptionsParams...),
+		Response: types.Pin{},
+	},
+	"PinClone": {
+		Summary: "Pin a CID with the options of another pin or of a template",
+		Query: []common.Param{
+			{Name: "from", Description: "CID of the pin to copy the options from"},
+			{Name: "template", Description: "name of the template to apply"},
+			{Name: "name", Description: "name of the new pin"},
+		},
+		Response: types.Pin{},
+	},
+	"PinPath": {
+		Summary:  "Pin the CID an IPFS path resolves to",
+		Query:    pinOptionsParams,
+		Response: types.Pin{},
+	},
+	"Unpin": {
+		Summary:  "Unpin a CID",
+		Response: types.Pin{},
+	},
+	"UnpinPath": {
+		Summary:  "Unpin the CID an IPFS path resolves to",
+		Response: types.Pin{},
+	},
+	"Restore": {
+		Summary:  "Take a pin out of the trash before it is unpinned",
+		Response: types.Pin{},
+	},
+	"Unprotect": {
+		Summary:  "Clear the protection of a pin so that it can be unpinned",
+		Response: types.Pin{},
+	},
+	"Audit": {
+		Summary:  "Verify the blocks of a pin in the IPFS daemons of its allocations",
+		Query:    []common.Param{{Name: "repair", Description: "remove corrupted blocks and fetch them again", Type: "boolean"}},
+		Response: types.GlobalDAGAudit{},
+	},
+	"PinTemplates": {
+		Summary:  "List the pin templates",
+		Response: []*types.PinTemplate{},
+	},
+	"PinTemplate": {
+		Summary:  "A pin template",
+		Response: types.PinTemplate{},
+	},
+	"PinTemplateSet": {
+		Summary:  "Create or replace a pin template with the options in the query",
+		Query:    pinOptionsParams,
+		Response: types.PinTemplate{},
+	},
+	"PinTemplateRemove": {
+		Summary: "Remove a pin template",
+	},
+	"PinsetSnapshots": {
+		Summary:  "List the pinset snapshots taken in this peer",
+		Response: []*types.PinsetSnapshot{},
+	},
+	"PinsetSnapshotCreate": {
+		Summary:  "Take a snapshot of the pinset",
+		Query:    []common.Param{{Name: "name", Description: "the name of the snapshot"}},
+		Response: types.PinsetSnapshot{},
+	},
+	"PinsetSnapshot": {
+		Summary:  "A pinset snapshot, with its pins",
+		Response: types.PinsetSnapshot{},
+	},
+	"PinsetSnapshotRemove": {
+		Summary: "Remove a pinset snapshot",
+	},
+	"PinsetRollback": {
+		Summary:  "Pin and unpin items until the pinset matches a snapshot",
+		Query:    []common.Param{{Name: "dry-run", Description: "return the changes without applying them", Type: "boolean"}},
+		Response: types.PinsetRollback{},
+	},
+	"ConsistencyCheck": {
+		Summary:  "Cross-check the shared state, the pin trackers and the IPFS pins of all peers",
+		Query:    []common.Param{{Name: "repair", Description: "fix the issues found, except for pins unknown to the cluster", Type: "boolean"}},
+		Response: types.GlobalConsistencyReport{},
+	},
+	"Denylist": {
+		Summary:  "List the denylist entries",
+		Response: []*types.DenylistEntry{},
+	},
+	"DenylistAdd": {
+		Summary:            "Add rules to the denylist, one per line",
+		RequestContentType: "text/plain",
+	},
+	"DenylistRemove": {
+		Summary: "Remove a rule from the denylist",
+		Query:   []common.Param{{Name: "rule", Description: "the rule to remove"}},
+	},
+	"DenylistEnforce": {
+		Summary:  "Unpin the pins matching the denylist",
+		Response: []*types.Pin{},
+	},
+	"RepoGC": {
+		Summary:  "Run garbage collection on the IPFS daemons",
+		Query:    []common.Param{localParam},
+		Response: types.GlobalRepoGC{},
+	},
+	"ConnectionGraph": {
+		Summary: "Connections between cluster peers and IPFS daemons",
+		Query: []common.Param{
+			{Name: "format", Description: "json, dot or mermaid"},
+			{Name: "all_ipfs", Description: "include all the IPFS daemons (dot and mermaid)", Type: "boolean"},
+		},
+		Response: types.ConnectGraph{},
+	},
+	"ConnectivityHistory": {
+		Summary:  "Snapshots of the connection graph",
+		Query:    []common.Param{{Name: "since", Description: "RFC3339 date"}},
+		Response: []*types.ConnectivitySnapshot{},
+	},
+	"Alerts": {
+		Summary:  "Alerts triggered by the peer monitor",
+		Response: []types.Alert{},
+	},
+	"StartupWarmup": {
+		Summary:  "Progress of the reconciliation of the pinset with IPFS done when the peer started",
+		Response: &types.StartupWarmup{},
+	},
+	"ConsensusStats": {
+		Summary:  "Internals of the consensus component of every peer and how far behind the others each one is",
+		Response: []types.ConsensusStats{},
+	},
+	"FederationPins": {
+		Summary:  "Status of the pins of every cluster in the federation, labelled with their cluster",
+		Query:    []common.Param{{Name: "filter", Description: "comma-separated list of tracker statuses"}},
+		Response: []types.FederatedGlobalPinInfo{},
+	},
+	"FederationPeers": {
+		Summary:  "Information about the peers of every cluster in the federation, labelled with their cluster",
+		Response: []types.FederatedID{},
+	},
+	"FederationHealth": {
+		Summary:  "Number of peers, peers with errors and alerts of every cluster in the federation",
+		Response: []types.FederatedHealth{},
+	},
+	"Metrics": {
+		Summary:  "Latest metrics with the given name from every peer",
+		Response: []*types.Metric{},
+	},
+	"MetricNames": {
+		Summary:  "Names of the metrics",
+		Response: []string{},
+	},
+	"DetectorState": {
+		Summary:  "State of the failure detector for every peer",
+		Response: []*types.DetectorState{},
+	},
+	"LifecycleEvents": {
+		Summary:  "Lifecycle events of the peer since a sequence number",
+		Query:    []common.Param{{Name: "since", Type: "integer"}},
+		Response: types.LifecycleEvents{},
+	},
+	"Operations": {
+		Summary:  "List the ongoing operations",
+		Response: []*types.Operation{},
+	},
+	"Operation": {
+		Summary:  "An ongoing operation",
+		Response: types.Operation{},
+	},
+	"CancelOperation": {
+		Summary:  "Cancel an ongoing operation",
+		Response: types.Operation{},
+	},
+	"RPCPolicy": {
+		Summary:  "The RPC policy of the peer",
+		Response: map[string]string{},
+	},
+	"SetRPCPolicy": {
+		Summary:  "Change the RPC policy of the peer",
+		Request:  map[string]string{},
+		Response: map[string]string{},
+	},
+	"RotateSecret": {
+		Summary:  "Rotate the cluster secret in all peers. The previous secret is accepted during a transition window. A new secret is generated unless one is given in the request",
+		Request:  types.SecretRotation{},
+		Response: types.SecretRotation{},
+	},
+	"TrackerSettings": {
+		Summary:  "Runtime settings of the pin tracker of the peer",
+		Response: types.TrackerSettings{},
+	},
+	"SetTrackerSettings": {
+		Summary:  "Change the runtime settings of the pin tracker. Zero values are left unchanged",
+		Request:  types.TrackerSettings{},
+		Response: types.TrackerSettings{},
+	},
+	"APIListeners": {
+		Summary:  "Listen addresses, TLS certificate and CORS options of the APIs of the peer which can be changed at runtime",
+		Response: []types.APIListeners{},
+	},
+	"RebindAPI": {
+		Summary:  "Replace the listeners of one of the APIs of the peer without restarting it. New listeners are opened before the old ones are closed and requests in progress are not interrupted. The settings are saved to the configuration",
+		Request:  types.APIListeners{},
+		Response: types.APIListeners{},
+	},
+	"WebUI": {
+		Summary:             "The web UI",
+		ResponseContentType: "text/html",
+	},
+	"WebUIFile": {
+		Summary:             "A file of the web UI",
+		ResponseContentType: "application/octet-stream",
+	},
+	"Spec": {
+		Summary:  "This OpenAPI document",
+		Response: map[string]interface{}{},
+	},
+}
+
+func (api *API) specHandler(w http.ResponseWriter, r *http.Request) {
+	api.SendOpenAPISpec(w, specTitle, version.Version.String(), routeSpecs)
+}
@@ -0,0 +1,58 @@
+package rest
+
+import (
+	"context"
+	"testing"
+
+	test "github.com/ipfs/ipfs-cluster/api/common/test"
+)
+
+func TestAPISpec(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url test.URLFunc) {
+		var spec struct {
+			OpenAPI    string                                       `json:"openapi"`
+			Paths      map[string]map[string]map[string]interface{} `json:"paths"`
+			Components struct {
+				Schemas map[string]struct {
+					Properties map[string]interface{} `json:"properties"`
+				} `json:"schemas"`
+			} `json:"components"`
+		}
+		test.MakeGet(t, rest, url(rest)+"/api/spec", &spec)
+
+		if spec.OpenAPI == "" {
+			t.Fatal("expected an OpenAPI document")
+		}
+		ops := 0
+		for path, methods := range spec.Paths {
+			for method, op := range methods {
+				ops++
+				if op["summary"] == "" {
+					t.Errorf("%s %s (%s) has no RouteSpec", method, path, op["operationId"])
+				}
+			}
+		}
+		if routes := len(rest.routes(rest.rpcClient)); ops != routes {
+			t.Errorf("expected %d operations, got %d", routes, ops)
+		}
+
+		if _, ok := spec.Paths["/pins/{keyType}/{path}"]["post"]; !ok {
+			t.Error("expected path parameters to be converted")
+		}
+		pin, ok := spec.Components.Schemas["Pin"]
+		if !ok {
+			t.Fatal("expected a Pin schema")
+		}
+		for _, prop := range []string{"cid", "allocations", "replication_factor_min"} {
+			if _, ok := pin.Properties[prop]; !ok {
+				t.Errorf("expected %s in the Pin schema", prop)
+			}
+		}
+	}
+
+	test.BothEndpoints(t, tf)
+}
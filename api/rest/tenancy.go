@@ -0,0 +1,225 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	types "github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/api/common"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+// Multi-tenancy: when enabled in the configuration, every basic-auth user
+// that is not an admin is restricted to the pins in their own namespace.
+// Pins added by restricted users are placed in their namespace, pins in
+// other namespaces are reported as not found, and listings only include
+// the pins in the namespace. The namespace quotas are checked when adding
+// or pinning new items. The check and the pin it allows happen while
+// holding a lock for the namespace, so that concurrent requests cannot
+// exceed the quota. Added content is charged with the actual size of the
+// DAG, which is recorded in the pin.
+
+var (
+	errNotAdmin       = errors.New("this operation is only available to admin users")
+	errOtherNamespace = errors.New("the item is pinned in a different namespace")
+	errNoExpectedSize = errors.New("an expected-size is required to enforce the namespace quota")
+)
+
+// namespace returns the namespace of the user making the request, and false
+// when the request is not restricted to one.
+func (api *API) namespace(r *http.Request) (string, bool) {
+	user, _, _ := r.BasicAuth()
+	return api.config.Tenancy.Namespace(user)
+}
+
+// adminOnly wraps handlers for cluster-wide operations which users
+// restricted to a namespace should not perform.
+func (api *API) adminOnly(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, restricted := api.namespace(r); restricted {
+			api.SendResponse(w, http.StatusForbidden, errNotAdmin, nil)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// checkOwnerOrFail sends a 404 and returns false when the request is
+// restricted to a namespace and the given cid is not pinned in it.
+func (api *API) checkOwnerOrFail(w http.ResponseWriter, r *http.Request, ci cid.Cid) bool {
+	ns, restricted := api.namespace(r)
+	if !restricted {
+		return true
+	}
+
+	var pin types.Pin
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PinGet",
+		ci,
+		&pin,
+	)
+	if err != nil || pin.Namespace != ns {
+		api.SendResponse(w, http.StatusNotFound, errors.New("pin not found"), nil)
+		return false
+	}
+	return true
+}
+
+// checkPinOrFail places the pin options in the namespace of the request and
+// verifies that pinning the given cid does not take over a pin from another
+// namespace or exceed the namespace quota. It sends an error response and
+// returns false otherwise. When it returns true, the returned function must
+// be called once the pin has been submitted. The authenticated user making
+// the request is recorded in the options in all cases.
+func (api *API) checkPinOrFail(w http.ResponseWriter, r *http.Request, ci cid.Cid, opts *types.PinOptions) (func(), bool) {
+	noop := func() {}
+	opts.User = types.RequestUserFromContext(r.Context())
+	ns, restricted := api.namespace(r)
+	if !restricted {
+		return noop, true
+	}
+	opts.Namespace = ns
+
+	var existing types.Pin
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PinGet",
+		ci,
+		&existing,
+	)
+	if err == nil && existing.Namespace != ns {
+		api.SendResponse(w, http.StatusConflict, errOtherNamespace, nil)
+		return nil, false
+	}
+
+	quota := api.config.Tenancy.QuotaFor(ns)
+	if quota == nil {
+		return noop, true
+	}
+	if quota.MaxBytes > 0 && opts.ExpectedSize == 0 {
+		api.SendResponse(w, http.StatusBadRequest, errNoExpectedSize, nil)
+		return nil, false
+	}
+
+	release, status, err := api.reserveQuota(r.Context(), ns, quota, ci, opts.ExpectedSize)
+	if err != nil {
+		api.SendResponse(w, status, err, nil)
+		return nil, false
+	}
+	return release, true
+}
+
+// addReserver returns the function which charges the added content to the
+// namespace of the request, or nil when there is no quota to enforce.
+func (api *API) addReserver(ctx context.Context, ns string) func(uint64) (func(), error) {
+	quota := api.config.Tenancy.QuotaFor(ns)
+	if quota == nil {
+		return nil
+	}
+	return func(size uint64) (func(), error) {
+		release, _, err := api.reserveQuota(ctx, ns, quota, cid.Undef, size)
+		return release, err
+	}
+}
+
+// reserveQuota locks the namespace and checks that adding an item with the
+// given cid and size does not exceed its quota. On success, the lock is
+// kept until the returned function is called, so that the item is pinned
+// before other items are checked.
+func (api *API) reserveQuota(ctx context.Context, ns string, quota *common.Quota, ci cid.Cid, size uint64) (func(), int, error) {
+	v, _ := api.quotaLocks.LoadOrStore(ns, &sync.Mutex{})
+	mu := v.(*sync.Mutex)
+	mu.Lock()
+	status, err := api.checkQuota(ctx, ns, quota, ci, size)
+	if err != nil {
+		mu.Unlock()
+		return nil, status, err
+	}
+	return mu.Unlock, 0, nil
+}
+
+// checkQuota returns an error when adding an item with the given cid and
+// size to the namespace would exceed the quota. Items that are already
+// pinned are not counted, as pinning them again only updates them.
+func (api *API) checkQuota(ctx context.Context, ns string, quota *common.Quota, ci cid.Cid, size uint64) (int, error) {
+	pins, err := api.namespacePins(ctx, ns)
+	if err != nil {
+		return common.SetStatusAutomatically, err
+	}
+
+	count := 1
+	total := size
+	for _, p := range pins {
+		if p.Type != types.DataType && p.Type != types.MetaType {
+			continue
+		}
+		if p.Cid.Equals(ci) {
+			continue
+		}
+		count++
+		total += p.ExpectedSize
+	}
+
+	if quota.MaxPins > 0 && count > quota.MaxPins {
+		return http.StatusForbidden, fmt.Errorf("namespace %s exceeds its quota of %d pins", ns, quota.MaxPins)
+	}
+	if quota.MaxBytes > 0 && total > quota.MaxBytes {
+		return http.StatusForbidden, fmt.Errorf("namespace %s exceeds its quota of %d bytes", ns, quota.MaxBytes)
+	}
+	return 0, nil
+}
+
+// namespacePins returns the pins in the given namespace.
+func (api *API) namespacePins(ctx context.Context, ns string) ([]*types.Pin, error) {
+	var pins []*types.Pin
+	err := api.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"Pins",
+		struct{}{},
+		&pins,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return filterNamespace(pins, ns), nil
+}
+
+func filterNamespace(pins []*types.Pin, ns string) []*types.Pin {
+	res := make([]*types.Pin, 0, len(pins))
+	for _, p := range pins {
+		if p.Namespace == ns {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// resolveOrFail resolves an IPFS path to a cid, sending an error response
+// and returning false when it cannot be resolved.
+func (api *API) resolveOrFail(w http.ResponseWriter, r *http.Request, path string) (cid.Cid, bool) {
+	var ci cid.Cid
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"IPFSConnector",
+		"Resolve",
+		path,
+		&ci,
+	)
+	if err != nil {
+		api.SendResponse(w, common.SetStatusAutomatically, err, nil)
+		return cid.Undef, false
+	}
+	return ci, true
+}
@@ -0,0 +1,227 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/api/common"
+	test "github.com/ipfs/ipfs-cluster/api/common/test"
+	clustertest "github.com/ipfs/ipfs-cluster/test"
+)
+
+const (
+	otherUserName     = "otherUserName"
+	otherUserPassword = "otherUserPassword"
+)
+
+func testTenancyAPI(t *testing.T) *API {
+	cfg := NewConfig()
+	cfg.Default()
+	cfg.BasicAuthCredentials = map[string]string{
+		clustertest.Namespace1: validUserPassword,
+		otherUserName:          otherUserPassword,
+		adminUserName:          adminUserPassword,
+	}
+	cfg.Tenancy = &common.TenancyConfig{
+		Enabled:      true,
+		AdminUsers:   []string{adminUserName},
+		DefaultQuota: &common.Quota{MaxBytes: 100},
+		Quotas: map[string]*common.Quota{
+			clustertest.Namespace1: {MaxPins: 2},
+		},
+	}
+	return testAPIwithConfig(t, cfg, "tenancy")
+}
+
+func tenancyRequest(t *testing.T, rest *API, method, path, user, password string, resp interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, test.HTTPURL(rest)+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth(user, password)
+	httpResp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer httpResp.Body.Close()
+	if resp != nil && httpResp.StatusCode < 300 {
+		if err := json.NewDecoder(httpResp.Body).Decode(resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return httpResp.StatusCode
+}
+
+func TestAPITenancyListings(t *testing.T) {
+	ctx := context.Background()
+	rest := testTenancyAPI(t)
+	defer rest.Shutdown(ctx)
+
+	var pins []*api.Pin
+	status := tenancyRequest(t, rest, "GET", "/allocations", clustertest.Namespace1, validUserPassword, &pins)
+	if status != http.StatusOK {
+		t.Fatal("unexpected status:", status)
+	}
+	if len(pins) != 1 || !pins[0].Cid.Equals(clustertest.Cid1) {
+		t.Error("expected only the pin in the namespace:", pins)
+	}
+
+	pins = nil
+	tenancyRequest(t, rest, "GET", "/allocations", adminUserName, adminUserPassword, &pins)
+	if len(pins) != 3 {
+		t.Error("admins should see all pins")
+	}
+
+	var gpis []*api.GlobalPinInfo
+	tenancyRequest(t, rest, "GET", "/pins", clustertest.Namespace1, validUserPassword, &gpis)
+	if len(gpis) != 1 || !gpis[0].Cid.Equals(clustertest.Cid1) {
+		t.Error("expected only the status of the pin in the namespace:", gpis)
+	}
+
+	status = tenancyRequest(t, rest, "GET", "/allocations/"+clustertest.Cid1.String(), clustertest.Namespace1, validUserPassword, nil)
+	if status != http.StatusOK {
+		t.Error("expected 200 for a pin in the namespace:", status)
+	}
+	status = tenancyRequest(t, rest, "GET", "/allocations/"+clustertest.Cid3.String(), clustertest.Namespace1, validUserPassword, nil)
+	if status != http.StatusNotFound {
+		t.Error("expected 404 for a pin in other namespace:", status)
+	}
+	status = tenancyRequest(t, rest, "GET", "/pins/"+clustertest.Cid3.String(), clustertest.Namespace1, validUserPassword, nil)
+	if status != http.StatusNotFound {
+		t.Error("expected 404 for the status of a pin in other namespace:", status)
+	}
+}
+
+func TestAPITenancyPinUnpin(t *testing.T) {
+	ctx := context.Background()
+	rest := testTenancyAPI(t)
+	defer rest.Shutdown(ctx)
+
+	var pin api.Pin
+	status := tenancyRequest(t, rest, "POST", "/pins/"+clustertest.Cid4.String()+"?namespace=abc", clustertest.Namespace1, validUserPassword, &pin)
+	if status != http.StatusOK {
+		t.Fatal("unexpected status:", status)
+	}
+	if pin.Namespace != clustertest.Namespace1 {
+		t.Error("the pin should have been placed in the user namespace:", pin.Namespace)
+	}
+
+	status = tenancyRequest(t, rest, "POST", "/pins/"+clustertest.Cid3.String(), clustertest.Namespace1, validUserPassword, nil)
+	if status != http.StatusConflict {
+		t.Error("expected 409 pinning an item from other namespace:", status)
+	}
+
+	status = tenancyRequest(t, rest, "DELETE", "/pins/"+clustertest.Cid3.String(), clustertest.Namespace1, validUserPassword, nil)
+	if status != http.StatusNotFound {
+		t.Error("expected 404 unpinning an item from other namespace:", status)
+	}
+	status = tenancyRequest(t, rest, "DELETE", "/pins/"+clustertest.Cid1.String(), clustertest.Namespace1, validUserPassword, nil)
+	if status != http.StatusOK {
+		t.Error("expected 200 unpinning an item in the namespace:", status)
+	}
+
+	status = tenancyRequest(t, rest, "POST", "/peers", clustertest.Namespace1, validUserPassword, nil)
+	if status != http.StatusForbidden {
+		t.Error("expected 403 for a cluster-wide operation:", status)
+	}
+	status = tenancyRequest(t, rest, "POST", "/ipfs/gc", otherUserName, otherUserPassword, nil)
+	if status != http.StatusForbidden {
+		t.Error("expected 403 for a cluster-wide operation:", status)
+	}
+}
+
+func TestAPITenancyQuotas(t *testing.T) {
+	ctx := context.Background()
+	rest := testTenancyAPI(t)
+	defer rest.Shutdown(ctx)
+
+	// Namespace1 has a pin already and a quota of 2 pins. Re-pinning
+	// existing items is not counted.
+	status := tenancyRequest(t, rest, "POST", "/pins/"+clustertest.Cid1.String(), clustertest.Namespace1, validUserPassword, nil)
+	if status != http.StatusOK {
+		t.Error("expected 200 re-pinning:", status)
+	}
+	cfg := rest.config.Tenancy
+	cfg.Quotas[clustertest.Namespace1].MaxPins = 1
+	status = tenancyRequest(t, rest, "POST", "/pins/"+clustertest.Cid4.String(), clustertest.Namespace1, validUserPassword, nil)
+	if status != http.StatusForbidden {
+		t.Error("expected 403 when exceeding the pin quota:", status)
+	}
+
+	// The other user gets the default byte quota.
+	status = tenancyRequest(t, rest, "POST", "/pins/"+clustertest.Cid4.String(), otherUserName, otherUserPassword, nil)
+	if status != http.StatusBadRequest {
+		t.Error("expected 400 without an expected-size:", status)
+	}
+	status = tenancyRequest(t, rest, "POST", "/pins/"+clustertest.Cid4.String()+"?expected-size=50", otherUserName, otherUserPassword, nil)
+	if status != http.StatusOK {
+		t.Error("expected 200 within the byte quota:", status)
+	}
+	status = tenancyRequest(t, rest, "POST", "/pins/"+clustertest.Cid4.String()+"?expected-size=500", otherUserName, otherUserPassword, nil)
+	if status != http.StatusForbidden {
+		t.Error("expected 403 when exceeding the byte quota:", status)
+	}
+}
+
+func TestAPITenancyRollbackQuota(t *testing.T) {
+	ctx := context.Background()
+	rest := testTenancyAPI(t)
+	defer rest.Shutdown(ctx)
+
+	// The snapshot restores a pin of 100 bytes in Namespace1.
+	path := "/pinset/snapshots/" + clustertest.PinsetSnapshotName + "/rollback"
+	status := tenancyRequest(t, rest, "POST", path, adminUserName, adminUserPassword, nil)
+	if status != http.StatusOK {
+		t.Error("expected 200 within the quota:", status)
+	}
+	rest.config.Tenancy.Quotas[clustertest.Namespace1].MaxBytes = 10
+	status = tenancyRequest(t, rest, "POST", path, adminUserName, adminUserPassword, nil)
+	if status != http.StatusForbidden {
+		t.Error("expected 403 when the rollback exceeds the byte quota:", status)
+	}
+	status = tenancyRequest(t, rest, "POST", path+"?dry-run=true", adminUserName, adminUserPassword, nil)
+	if status != http.StatusOK {
+		t.Error("expected 200 with a dry-run:", status)
+	}
+}
+
+func TestAPITenancyAddQuota(t *testing.T) {
+	ctx := context.Background()
+	rest := testTenancyAPI(t)
+	defer rest.Shutdown(ctx)
+
+	// The added content is charged with its actual size regardless of
+	// the expected one.
+	reserve := rest.addReserver(ctx, otherUserName)
+	if reserve == nil {
+		t.Fatal("expected a reserver with the default quota")
+	}
+	if _, err := reserve(500); err == nil {
+		t.Error("expected an error when exceeding the byte quota")
+	}
+	release, err := reserve(50)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reservations in the same namespace wait for the previous one.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if release, err := reserve(50); err == nil {
+			release()
+		}
+	}()
+	select {
+	case <-done:
+		t.Fatal("a concurrent reservation should wait")
+	case <-time.After(100 * time.Millisecond):
+	}
+	release()
+	<-done
+}
@@ -0,0 +1,36 @@
+package rest
+
+import (
+	"embed"
+	"errors"
+	"mime"
+	"net/http"
+	"path"
+
+	mux "github.com/gorilla/mux"
+)
+
+// The web UI is a set of static files which use the REST API from the
+// browser. Being served by the API itself, they are subject to the same
+// authentication as any other endpoint.
+
+//go:embed webui
+var webUIFiles embed.FS
+
+func (api *API) webUIHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["file"]
+	if name == "" {
+		name = "index.html"
+	}
+
+	b, err := webUIFiles.ReadFile(path.Join("webui", name))
+	if err != nil {
+		api.SendResponse(w, http.StatusNotFound, errors.New("file not found"), nil)
+		return
+	}
+	w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(name)))
+	w.Header().Set("Content-Security-Policy", "default-src 'self'")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
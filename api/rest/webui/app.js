@@ -0,0 +1,166 @@
+'use strict';
+
+// The web UI uses the REST API of the peer serving it. Requests carry the
+// credentials the browser was given for this page.
+
+function get(path) {
+  return fetch(path, { credentials: 'same-origin' }).then(function (resp) {
+    return resp.json().then(function (body) {
+      if (!resp.ok) {
+        throw new Error(body.message || resp.statusText);
+      }
+      return body;
+    });
+  });
+}
+
+function showError(err) {
+  document.getElementById('error').textContent = err ? err.message : '';
+}
+
+var pinTypes = { 2: 'pin', 4: 'meta-pin', 8: 'clusterdag-pin', 16: 'shard-pin' };
+
+function cidString(c) {
+  return c && c['/'] ? c['/'] : String(c || '');
+}
+
+function cell(row, text, cls) {
+  var td = document.createElement('td');
+  td.textContent = text;
+  if (cls) {
+    td.className = cls;
+  }
+  row.appendChild(td);
+  return td;
+}
+
+function loadPeer() {
+  get('/id').then(function (id) {
+    document.getElementById('peer').textContent =
+      (id.peername || id.id) + ' - ' + id.version;
+  }).catch(showError);
+}
+
+function loadPeers() {
+  get('/peers').then(function (peers) {
+    var tbody = document.getElementById('peers');
+    tbody.textContent = '';
+    peers.forEach(function (p) {
+      var row = document.createElement('tr');
+      cell(row, p.peername);
+      cell(row, p.id, 'cid');
+      cell(row, p.version);
+      cell(row, p.ipfs && p.ipfs.error ? p.ipfs.error : 'online',
+        p.ipfs && p.ipfs.error ? 'error' : 'ok');
+      cell(row, p.error || 'ok', p.error ? 'error' : 'ok');
+      tbody.appendChild(row);
+    });
+  }).catch(showError);
+
+  get('/health/alerts').then(function (alerts) {
+    var ul = document.getElementById('alerts');
+    ul.textContent = '';
+    (alerts || []).forEach(function (a) {
+      var li = document.createElement('li');
+      li.textContent = a.peer + ': ' + a.name;
+      ul.appendChild(li);
+    });
+    if (!ul.children.length) {
+      ul.textContent = 'None';
+    }
+  }).catch(showError);
+}
+
+function showPins(pins) {
+  var tbody = document.getElementById('pins');
+  tbody.textContent = '';
+  pins.forEach(function (p) {
+    var row = document.createElement('tr');
+    var c = cidString(p.cid);
+    var link = document.createElement('a');
+    link.textContent = c;
+    link.onclick = function () { showStatus(c); };
+    cell(row, '', 'cid').appendChild(link);
+    cell(row, p.name);
+    cell(row, pinTypes[p.type] || p.type);
+    cell(row, p.replication_factor_min + ' / ' + p.replication_factor_max);
+    cell(row, (p.allocations || []).length || 'everywhere');
+    tbody.appendChild(row);
+  });
+}
+
+function showStatus(c) {
+  get('/pins/' + encodeURIComponent(c)).then(function (gpi) {
+    var div = document.getElementById('status');
+    div.textContent = '';
+    var h = document.createElement('h3');
+    h.textContent = 'Status of ' + c;
+    div.appendChild(h);
+    var table = document.createElement('table');
+    Object.keys(gpi.peer_map || {}).forEach(function (pid) {
+      var info = gpi.peer_map[pid];
+      var row = document.createElement('tr');
+      cell(row, info.peername || pid);
+      cell(row, info.status, info.error ? 'error' : 'ok');
+      cell(row, info.error || '');
+      table.appendChild(row);
+    });
+    div.appendChild(table);
+  }).catch(showError);
+}
+
+function search(ev) {
+  if (ev) {
+    ev.preventDefault();
+  }
+  showError(null);
+  var q = document.getElementById('query').value.trim();
+  var path = '/allocations?filter=pin,meta-pin';
+  if (/^(Qm|baf)/.test(q)) {
+    path = '/allocations/' + encodeURIComponent(q);
+  } else if (q) {
+    path = '/allocations?name-prefix=' + encodeURIComponent(q);
+  }
+  get(path).then(function (res) {
+    showPins(Array.isArray(res) ? res : [res]);
+  }).catch(showError);
+}
+
+function add(ev) {
+  ev.preventDefault();
+  showError(null);
+  var file = document.getElementById('file').files[0];
+  var params = new URLSearchParams({ 'stream-channels': 'false' });
+  [['name', 'name'], ['rmin', 'replication-min'], ['rmax', 'replication-max']]
+    .forEach(function (f) {
+      var v = document.getElementById(f[0]).value;
+      if (v) {
+        params.set(f[1], v);
+      }
+    });
+
+  var body = new FormData();
+  body.append('file', file, file.name);
+  var out = document.getElementById('added');
+  out.textContent = 'Adding...';
+  fetch('/add?' + params.toString(), {
+    method: 'POST',
+    body: body,
+    credentials: 'same-origin'
+  }).then(function (resp) {
+    return resp.text();
+  }).then(function (text) {
+    out.textContent = text;
+    search();
+  }).catch(function (err) {
+    out.textContent = '';
+    showError(err);
+  });
+}
+
+document.getElementById('search').addEventListener('submit', search);
+document.getElementById('add').addEventListener('submit', add);
+loadPeer();
+loadPeers();
+search();
+setInterval(loadPeers, 30000);
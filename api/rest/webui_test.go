@@ -0,0 +1,49 @@
+package rest
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	test "github.com/ipfs/ipfs-cluster/api/common/test"
+)
+
+func TestAPIWebUI(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	c := test.HTTPClient(t, nil, false)
+	get := func(path string) (*http.Response, string) {
+		resp, err := c.Get(test.HTTPURL(rest) + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, string(body)
+	}
+
+	resp, body := get("/webui")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, "/webui/app.js") {
+		t.Errorf("unexpected index: %d %s", resp.StatusCode, body)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Error("unexpected content type:", ct)
+	}
+
+	resp, _ = get("/webui/app.js")
+	if resp.StatusCode != http.StatusOK {
+		t.Error("expected app.js to be served:", resp.StatusCode)
+	}
+
+	resp, _ = get("/webui/missing.js")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Error("expected a 404:", resp.StatusCode)
+	}
+}
@@ -77,6 +77,9 @@ const (
 	// The item is in the state and should be pinned, but
 	// it is however not pinned and not queued/pinning.
 	TrackerStatusUnexpectedlyUnpinned
+	// The item is in the state but it will not be pinned until the time
+	// it is scheduled at.
+	TrackerStatusScheduled
 )
 
 // Composite TrackerStatus.
@@ -104,6 +107,7 @@ var trackerStatusString = map[TrackerStatus]string{
 	TrackerStatusQueued:               "queued",
 	TrackerStatusSharded:              "sharded",
 	TrackerStatusUnexpectedlyUnpinned: "unexpectedly_unpinned",
+	TrackerStatusScheduled:            "scheduled",
 }
 
 // values autofilled in init()
@@ -258,6 +262,15 @@ type GlobalPinInfo struct {
 	PeerMap map[string]*PinInfoShort `json:"peer_map" codec:"pm,omitempty"`
 }
 
+// ShardInfo describes one of the shards of a sharded pin.
+type ShardInfo struct {
+	Cid         cid.Cid        `json:"cid" codec:"c"`
+	Name        string         `json:"name" codec:"n,omitempty"`
+	Size        uint64         `json:"size" codec:"s,omitempty"`
+	Allocations []peer.ID      `json:"allocations" codec:"a,omitempty"`
+	Status      *GlobalPinInfo `json:"status" codec:"st,omitempty"`
+}
+
 // String returns the string representation of a GlobalPinInfo.
 func (gpi *GlobalPinInfo) String() string {
 	str := fmt.Sprintf("Cid: %v\n", gpi.Cid.String())
@@ -328,6 +341,9 @@ type Version struct {
 // key of ClustertoIPFS or IPFSLinks. Finally iff id is a key of ClustertoIPFS
 // then id will be a key of IPFSLinks.  In the event of a SwarmPeers error
 // IPFSLinks[id] == [].
+//
+// ClusterConnections, when available, holds information about the
+// connections behind the ClusterLinks of every peer.
 type ConnectGraph struct {
 	ClusterID    peer.ID           `json:"cluster_id" codec:"id"`
 	IDtoPeername map[string]string `json:"id_to_peername" codec:"ip,omitempty"`
@@ -339,6 +355,50 @@ type ConnectGraph struct {
 	ClusterTrustLinks map[string]bool `json:"cluster_trust_links" codec:"ctl,omitempty"`
 	// cluster to ipfs links
 	ClustertoIPFS map[string]peer.ID `json:"cluster_to_ipfs" codec:"ci,omitempty"`
+	// details of the cluster to cluster links
+	ClusterConnections map[string][]*ConnectionInfo `json:"cluster_connections,omitempty" codec:"cc,omitempty"`
+}
+
+// ConnectivitySnapshot records which cluster peers were connected to each
+// other during a period of time, as seen by the peer taking it. Snapshots
+// are taken regularly, and consecutive snapshots showing the same
+// connectivity are merged into one, so From and To mark the first and last
+// time it was observed.
+type ConnectivitySnapshot struct {
+	From time.Time `json:"from" codec:"f,omitempty"`
+	To   time.Time `json:"to" codec:"t,omitempty"`
+	// Peers are the members of the peerset.
+	Peers []peer.ID `json:"peers" codec:"p,omitempty"`
+	// Unreachable are the peers which could not be contacted by the
+	// peer taking the snapshot.
+	Unreachable []peer.ID `json:"unreachable" codec:"u,omitempty"`
+	// ClusterLinks lists the cluster peers each reachable peer was
+	// connected to.
+	ClusterLinks map[string][]peer.ID `json:"cluster_links" codec:"cl,omitempty"`
+	// Partitions is the number of groups of peers, among the reachable
+	// ones, which were not connected to each other. More than 1 means
+	// the cluster was partitioned.
+	Partitions int `json:"partitions" codec:"n,omitempty"`
+}
+
+// Connection directions.
+const (
+	ConnectionInbound  = "inbound"
+	ConnectionOutbound = "outbound"
+)
+
+// ConnectionInfo describes a libp2p connection from a peer to another one.
+type ConnectionInfo struct {
+	Peer peer.ID `json:"peer" codec:"p,omitempty"`
+	// Direction is either "inbound" or "outbound". It is empty when
+	// unknown.
+	Direction string `json:"direction,omitempty" codec:"d,omitempty"`
+	// Transport is the transport of the connection (tcp, quic, ws,
+	// relay...).
+	Transport string `json:"transport,omitempty" codec:"t,omitempty"`
+	// Latency is an average of the latencies measured to the peer. It
+	// is 0 when unknown.
+	Latency time.Duration `json:"latency,omitempty" codec:"l,omitempty"`
 }
 
 // Multiaddr is a concrete type to wrap a Multiaddress so that it knows how to
@@ -402,14 +462,144 @@ type ID struct {
 	Error                 string      `json:"error" codec:"e,omitempty"`
 	IPFS                  *IPFSID     `json:"ipfs,omitempty" codec:"ip,omitempty"`
 	Peername              string      `json:"peername" codec:"pn,omitempty"`
+	// Labels set by the operator to describe the peer.
+	Labels map[string]string `json:"labels,omitempty" codec:"lb,omitempty"`
 	//PublicKey          crypto.PubKey
 }
 
+// ErrPeerNotFound is returned when no cluster peer has the given peer ID or
+// peername.
+var ErrPeerNotFound = errors.New("peer not found")
+
+// ErrAmbiguousPeername is returned when a peer is addressed by a peername
+// which is used by several peers.
+var ErrAmbiguousPeername = errors.New("peername used by several peers")
+
 // IPFSID is used to store information about the underlying IPFS daemon
 type IPFSID struct {
 	ID        peer.ID     `json:"id,omitempty" codec:"i,omitempty"`
 	Addresses []Multiaddr `json:"addresses" codec:"a,omitempty"`
 	Error     string      `json:"error" codec:"e,omitempty"`
+	// AgentVersion is the version string of the daemon, like
+	// "kubo/0.17.0/".
+	AgentVersion string `json:"agent_version,omitempty" codec:"av,omitempty"`
+}
+
+// PeerVersion holds the versions run by a cluster peer. When the peer
+// cannot be contacted, Error is set and the RPC protocol version is the
+// one announced by the peer, if known.
+type PeerVersion struct {
+	ID                 peer.ID     `json:"id" codec:"i,omitempty"`
+	Peername           string      `json:"peername" codec:"pn,omitempty"`
+	Version            string      `json:"version" codec:"v,omitempty"`
+	Commit             string      `json:"commit" codec:"c,omitempty"`
+	RPCProtocolVersion protocol.ID `json:"rpc_protocol_version" codec:"rv,omitempty"`
+	IPFSVersion        string      `json:"ipfs_version" codec:"iv,omitempty"`
+	Error              string      `json:"error" codec:"e,omitempty"`
+}
+
+// PeerVersions summarizes the versions run across the cluster peers. The
+// maps count the peers running each version. RPCProtocolSkew is set when
+// the peers do not all speak the same RPC protocol, in which case some of
+// them cannot work together.
+type PeerVersions struct {
+	Peers               []*PeerVersion `json:"peers" codec:"p,omitempty"`
+	Versions            map[string]int `json:"versions" codec:"v,omitempty"`
+	RPCProtocolVersions map[string]int `json:"rpc_protocol_versions" codec:"rv,omitempty"`
+	IPFSVersions        map[string]int `json:"ipfs_versions" codec:"iv,omitempty"`
+	RPCProtocolSkew     bool           `json:"rpc_protocol_skew" codec:"s,omitempty"`
+}
+
+// RaftStats are the internals of the Raft consensus component of a peer.
+type RaftStats struct {
+	State        string  `json:"state" codec:"s,omitempty"`
+	Leader       peer.ID `json:"leader" codec:"l,omitempty"`
+	Term         uint64  `json:"term" codec:"t,omitempty"`
+	LastIndex    uint64  `json:"last_index" codec:"li,omitempty"`
+	CommitIndex  uint64  `json:"commit_index" codec:"ci,omitempty"`
+	AppliedIndex uint64  `json:"applied_index" codec:"ai,omitempty"`
+	// FSMPending is the number of committed entries waiting to be
+	// applied to the state.
+	FSMPending uint64 `json:"fsm_pending" codec:"fp,omitempty"`
+}
+
+// CRDTStats are the internals of the CRDT consensus component of a peer.
+type CRDTStats struct {
+	// Heads is the number of heads of the Merkle-DAG, which grows when
+	// peers make concurrent updates that have not been merged yet.
+	Heads int `json:"heads" codec:"h,omitempty"`
+	// Height is the height of the highest head, or the depth of the
+	// DAG.
+	Height uint64 `json:"height" codec:"ht,omitempty"`
+	// QueuedUpdates is the number of pins and unpins waiting to be
+	// batched and broadcasted.
+	QueuedUpdates int `json:"queued_updates" codec:"q,omitempty"`
+}
+
+// ConsensusStats describe the consensus component of a peer. Only the field
+// for the component in use is set. SyncLag is how far behind the most
+// up-to-date peer its state is: in log entries for Raft and in DAG height
+// for CRDT. Error is set when the peer could not be contacted.
+type ConsensusStats struct {
+	Peer    peer.ID    `json:"peer" codec:"p,omitempty"`
+	Raft    *RaftStats `json:"raft,omitempty" codec:"r,omitempty"`
+	CRDT    *CRDTStats `json:"crdt,omitempty" codec:"c,omitempty"`
+	SyncLag uint64     `json:"sync_lag" codec:"sl,omitempty"`
+	Error   string     `json:"error,omitempty" codec:"e,omitempty"`
+}
+
+// PeerResources reports the resources used by a cluster peer and by its
+// IPFS daemon. IPFSError is set when the repo stat of the daemon could not
+// be obtained.
+type PeerResources struct {
+	Peer     peer.ID `json:"peer" codec:"p,omitempty"`
+	Peername string  `json:"peername" codec:"pn,omitempty"`
+	// MemorySys is the memory obtained from the OS by the process and
+	// MemoryHeap the memory allocated in the heap, in bytes.
+	MemorySys  uint64 `json:"memory_sys" codec:"ms,omitempty"`
+	MemoryHeap uint64 `json:"memory_heap" codec:"mh,omitempty"`
+	Goroutines int    `json:"goroutines" codec:"g,omitempty"`
+	NumCPU     int    `json:"num_cpu" codec:"nc,omitempty"`
+	// CPUTime is the user and system CPU time used by the process
+	// since it started.
+	CPUTime time.Duration `json:"cpu_time" codec:"ct,omitempty"`
+	// Bandwidth totals, in bytes, and rates, in bytes per second, of
+	// the libp2p host of the peer.
+	BandwidthTotalIn  int64   `json:"bandwidth_total_in" codec:"bi,omitempty"`
+	BandwidthTotalOut int64   `json:"bandwidth_total_out" codec:"bo,omitempty"`
+	BandwidthRateIn   float64 `json:"bandwidth_rate_in" codec:"ri,omitempty"`
+	BandwidthRateOut  float64 `json:"bandwidth_rate_out" codec:"ro,omitempty"`
+	// DatastoreSize is the disk usage of the cluster datastore, when the
+	// datastore can report it.
+	DatastoreSize uint64        `json:"datastore_size" codec:"ds,omitempty"`
+	IPFSRepoStat  *IPFSRepoStat `json:"ipfs_repo_stat,omitempty" codec:"rs,omitempty"`
+	IPFSError     string        `json:"ipfs_error,omitempty" codec:"ie,omitempty"`
+}
+
+// SecretRotation reports the outcome of a cluster secret rotation: the new
+// hex-encoded secret, the peers which switched to it and those which failed
+// to do so.
+type SecretRotation struct {
+	Secret string    `json:"secret" codec:"s,omitempty"`
+	Peers  []peer.ID `json:"peers" codec:"p,omitempty"`
+	Failed []peer.ID `json:"failed,omitempty" codec:"f,omitempty"`
+}
+
+// SecretRotationStep is sent to every peer during a cluster secret
+// rotation, asking it to perform one of the phases of the rotation with the
+// given hex-encoded secret.
+type SecretRotationStep struct {
+	Phase  string `json:"phase" codec:"ph,omitempty"`
+	Secret string `json:"secret" codec:"s,omitempty"`
+}
+
+// PeerHandover describes the transfer of the allocations of a peer to a
+// different one, as when a peer rotates its identity. Pins is the number of
+// pins whose allocations were transferred.
+type PeerHandover struct {
+	From peer.ID `json:"from" codec:"f,omitempty"`
+	To   peer.ID `json:"to" codec:"t,omitempty"`
+	Pins int     `json:"pins" codec:"n,omitempty"`
 }
 
 // PinType specifies which sort of Pin object we are dealing with.
@@ -564,6 +754,174 @@ func (pm PinMode) ToPinDepth() PinDepth {
 	}
 }
 
+// ProvideStrategy is a PinOption that indicates what the allocated peers
+// announce to the DHT once they have pinned something.
+type ProvideStrategy int
+
+// ProvideStrategy values
+const (
+	// ProvideStrategyDefault uses the strategy configured in the IPFS
+	// connector.
+	ProvideStrategyDefault ProvideStrategy = iota
+	// ProvideStrategyRoots announces the root CID only.
+	ProvideStrategyRoots
+	// ProvideStrategyAll announces every block in the DAG.
+	ProvideStrategyAll
+	// ProvideStrategyNone does not announce anything.
+	ProvideStrategyNone
+)
+
+// ProvideStrategyFromString converts a string to ProvideStrategy.
+func ProvideStrategyFromString(s string) (ProvideStrategy, error) {
+	switch s {
+	case "":
+		return ProvideStrategyDefault, nil
+	case "roots":
+		return ProvideStrategyRoots, nil
+	case "all":
+		return ProvideStrategyAll, nil
+	case "none":
+		return ProvideStrategyNone, nil
+	default:
+		return ProvideStrategyDefault, fmt.Errorf("unknown provide strategy: %s", s)
+	}
+}
+
+// String returns a human-readable value for ProvideStrategy.
+func (ps ProvideStrategy) String() string {
+	switch ps {
+	case ProvideStrategyRoots:
+		return "roots"
+	case ProvideStrategyAll:
+		return "all"
+	case ProvideStrategyNone:
+		return "none"
+	default:
+		return ""
+	}
+}
+
+// MarshalJSON converts the ProvideStrategy into a readable string in JSON.
+func (ps ProvideStrategy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ps.String())
+}
+
+// UnmarshalJSON takes a JSON value and parses it into ProvideStrategy.
+func (ps *ProvideStrategy) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+	*ps, err = ProvideStrategyFromString(s)
+	return err
+}
+
+// AckLevel is a PinOption that indicates when a pin request returns. It
+// only applies to the request: it is not stored with the pin.
+type AckLevel int
+
+// AckLevel values
+const (
+	// AckConsensus returns once the pin has been committed to the
+	// consensus layer.
+	AckConsensus AckLevel = iota
+	// AckLocal returns once this peer has validated and allocated the
+	// pin and written it to its copy of the shared state, without
+	// waiting for other peers. With raft, this is the same as
+	// AckConsensus.
+	AckLocal
+	// AckReplicated returns once AckPeers trusted peers, this one
+	// included, have the pin in their shared state.
+	AckReplicated
+)
+
+// AckLevelFromString converts a string to AckLevel.
+func AckLevelFromString(s string) (AckLevel, error) {
+	switch s {
+	case "", "consensus":
+		return AckConsensus, nil
+	case "local":
+		return AckLocal, nil
+	case "replicated":
+		return AckReplicated, nil
+	default:
+		return AckConsensus, fmt.Errorf("unknown acknowledgment level: %s", s)
+	}
+}
+
+// String returns a human-readable value for AckLevel.
+func (al AckLevel) String() string {
+	switch al {
+	case AckLocal:
+		return "local"
+	case AckReplicated:
+		return "replicated"
+	default:
+		return "consensus"
+	}
+}
+
+// MarshalJSON converts the AckLevel into a readable string in JSON.
+func (al AckLevel) MarshalJSON() ([]byte, error) {
+	return json.Marshal(al.String())
+}
+
+// UnmarshalJSON takes a JSON value and parses it into AckLevel.
+func (al *AckLevel) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+	*al, err = AckLevelFromString(s)
+	return err
+}
+
+// ErrDuplicatePinName is returned when pinning with a name which is already
+// used by a different pin and unique pin names are enforced.
+var ErrDuplicatePinName = errors.New("pin name already in use")
+
+// ErrPinRejected is returned when the pin validation hook does not accept a
+// pin.
+var ErrPinRejected = errors.New("pin rejected")
+
+// ErrInsufficientFreeSpace is returned when pinning or adding while too
+// few peers have the free space required by cluster.min_free_space.
+var ErrInsufficientFreeSpace = errors.New("not enough peers with free space")
+
+// ErrPinProtected is returned when unpinning a protected pin.
+var ErrPinProtected = errors.New("pin is protected")
+
+// ErrNotTrashed is returned when restoring a pin which is not in the trash.
+var ErrNotTrashed = errors.New("pin is not in the trash")
+
+// TrashedAtMetaKey is the metadata key set on unpinned pins while they are
+// kept in the trash, with the time they were unpinned in RFC3339 format.
+const TrashedAtMetaKey = "trashed_at"
+
+// Metadata keys which ask for the content of a pin to be published once it
+// is pinned: IPNSKeyMetaKey is the name of the IPFS key to publish it with
+// and DNSLinkMetaKey the domain whose DNSLink should point to it.
+const (
+	IPNSKeyMetaKey = "ipns-key"
+	DNSLinkMetaKey = "dnslink"
+)
+
+// IPNSTrackMetaKey is the metadata key of pins which follow an IPNS name.
+// When pinning an /ipns/ path, setting it to any value asks the cluster to
+// track the name, and the path is then stored as its value.
+const IPNSTrackMetaKey = "ipns-track"
+
+// Metadata keys which set the timeouts of a pin, for clients which can only
+// set metadata. The PinTimeout, UnpinTimeout and TimeoutProfile options take
+// precedence over them. Timeouts are durations, like "10m".
+const (
+	PinTimeoutMetaKey     = "pin-timeout"
+	UnpinTimeoutMetaKey   = "unpin-timeout"
+	TimeoutProfileMetaKey = "timeout-profile"
+)
+
 // PinOptions wraps user-defined options for Pins
 type PinOptions struct {
 	ReplicationFactorMin int               `json:"replication_factor_min" codec:"rn,omitempty"`
@@ -576,6 +934,38 @@ type PinOptions struct {
 	Metadata             map[string]string `json:"metadata" codec:"m,omitempty"`
 	PinUpdate            cid.Cid           `json:"pin_update,omitempty" codec:"pu,omitempty"`
 	Origins              []Multiaddr       `json:"origins" codec:"g,omitempty"`
+	ExpectedSize         uint64            `json:"expected_size,omitempty" codec:"es,omitempty"`
+	Namespace            string            `json:"namespace,omitempty" codec:"ns,omitempty"`
+	// Protected pins cannot be unpinned, nor expire, until the
+	// protection is cleared by an admin.
+	Protected bool `json:"protected,omitempty" codec:"pr,omitempty"`
+	// ExcludeAllocations lists peers which must never be allocated
+	// the pin. Pins replicated everywhere are not pinned by them either.
+	ExcludeAllocations []peer.ID `json:"exclude_allocations,omitempty" codec:"xa,omitempty"`
+	// ScheduleAt delays pinning the content until the given time. The
+	// pin is part of the shared state and allocated from the start.
+	ScheduleAt time.Time `json:"schedule_at,omitempty" codec:"sa,omitempty"`
+	// VerifyInterval makes the peers check that the content is still
+	// pinned in IPFS every so often, and pin it again when it is not.
+	VerifyInterval time.Duration `json:"verify_interval,omitempty" codec:"vi,omitempty"`
+	// ProvideStrategy overrides what the allocated peers announce to
+	// the DHT for this pin.
+	ProvideStrategy ProvideStrategy `json:"provide_strategy,omitempty" codec:"ps,omitempty"`
+	// Ack sets when the pin request returns. With AckReplicated,
+	// AckPeers is the number of peers which must have the pin.
+	Ack      AckLevel `json:"ack,omitempty" codec:"ak,omitempty"`
+	AckPeers int      `json:"ack_peers,omitempty" codec:"akp,omitempty"`
+	// PinTimeout and UnpinTimeout override the pin_timeout and
+	// unpin_timeout of the IPFS connector of the allocated peers.
+	// TimeoutProfile selects one of the timeout_profiles defined in
+	// their configuration. Explicit timeouts take precedence over the
+	// profile.
+	PinTimeout     time.Duration `json:"pin_timeout,omitempty" codec:"pt,omitempty"`
+	UnpinTimeout   time.Duration `json:"unpin_timeout,omitempty" codec:"ut,omitempty"`
+	TimeoutProfile string        `json:"timeout_profile,omitempty" codec:"tp,omitempty"`
+	// User is the authenticated user which submitted the pin through
+	// the REST API, if any. It is set by the API and not parsed from requests.
+	User string `json:"user,omitempty" codec:"us,omitempty"`
 }
 
 // Equals returns true if two PinOption objects are equivalent. po and po2 may
@@ -597,6 +987,14 @@ func (po *PinOptions) Equals(po2 *PinOptions) bool {
 		return false
 	}
 
+	if po.Namespace != po2.Namespace {
+		return false
+	}
+
+	if po.Protected != po2.Protected {
+		return false
+	}
+
 	if po.ReplicationFactorMax != po2.ReplicationFactorMax {
 		return false
 	}
@@ -624,10 +1022,35 @@ func (po *PinOptions) Equals(po2 *PinOptions) bool {
 		return false
 	}
 
+	excluded1 := PeersToStrings(po.ExcludeAllocations)
+	excluded2 := PeersToStrings(po2.ExcludeAllocations)
+	sort.Strings(excluded1)
+	sort.Strings(excluded2)
+	if strings.Join(excluded1, ",") != strings.Join(excluded2, ",") {
+		return false
+	}
+
 	if !po.ExpireAt.Equal(po2.ExpireAt) {
 		return false
 	}
 
+	if !po.ScheduleAt.Equal(po2.ScheduleAt) {
+		return false
+	}
+
+	if po.VerifyInterval != po2.VerifyInterval {
+		return false
+	}
+
+	if po.ProvideStrategy != po2.ProvideStrategy {
+		return false
+	}
+
+	if po.PinTimeout != po2.PinTimeout || po.UnpinTimeout != po2.UnpinTimeout ||
+		po.TimeoutProfile != po2.TimeoutProfile {
+		return false
+	}
+
 	for k, v := range po.Metadata {
 		v2 := po2.Metadata[k]
 		if k != "" && v != v2 {
@@ -635,7 +1058,8 @@ func (po *PinOptions) Equals(po2 *PinOptions) bool {
 		}
 	}
 
-	// deliberately ignore Update
+	// deliberately ignore Update and ExpectedSize, which may be resolved
+	// by the cluster during pinning.
 
 	lenOrigins1 := len(po.Origins)
 	lenOrigins2 := len(po2.Origins)
@@ -658,15 +1082,35 @@ func (po *PinOptions) Equals(po2 *PinOptions) bool {
 	return true
 }
 
-// ToQuery returns the PinOption as query arguments.
+// ToQuery returns the PinOption as query arguments. Only the options which
+// are set are included, so that the server can tell them apart from those
+// it should fill in with its defaults.
 func (po *PinOptions) ToQuery() (string, error) {
 	q := url.Values{}
-	q.Set("replication-min", fmt.Sprintf("%d", po.ReplicationFactorMin))
-	q.Set("replication-max", fmt.Sprintf("%d", po.ReplicationFactorMax))
-	q.Set("name", po.Name)
-	q.Set("mode", po.Mode.String())
-	q.Set("shard-size", fmt.Sprintf("%d", po.ShardSize))
-	q.Set("user-allocations", strings.Join(PeersToStrings(po.UserAllocations), ","))
+	if po.ReplicationFactorMin != 0 {
+		q.Set("replication-min", fmt.Sprintf("%d", po.ReplicationFactorMin))
+	}
+	if po.ReplicationFactorMax != 0 {
+		q.Set("replication-max", fmt.Sprintf("%d", po.ReplicationFactorMax))
+	}
+	if po.Name != "" {
+		q.Set("name", po.Name)
+	}
+	if po.Mode != PinModeRecursive {
+		q.Set("mode", po.Mode.String())
+	}
+	if po.Namespace != "" {
+		q.Set("namespace", po.Namespace)
+	}
+	if po.ShardSize > 0 {
+		q.Set("shard-size", fmt.Sprintf("%d", po.ShardSize))
+	}
+	if len(po.UserAllocations) > 0 {
+		q.Set("user-allocations", strings.Join(PeersToStrings(po.UserAllocations), ","))
+	}
+	if len(po.ExcludeAllocations) > 0 {
+		q.Set("exclude-allocations", strings.Join(PeersToStrings(po.ExcludeAllocations), ","))
+	}
 	if !po.ExpireAt.IsZero() {
 		v, err := po.ExpireAt.MarshalText()
 		if err != nil {
@@ -674,6 +1118,34 @@ func (po *PinOptions) ToQuery() (string, error) {
 		}
 		q.Set("expire-at", string(v))
 	}
+	if !po.ScheduleAt.IsZero() {
+		v, err := po.ScheduleAt.MarshalText()
+		if err != nil {
+			return "", err
+		}
+		q.Set("schedule-at", string(v))
+	}
+	if po.VerifyInterval > 0 {
+		q.Set("verify-interval", po.VerifyInterval.String())
+	}
+	if po.ProvideStrategy != ProvideStrategyDefault {
+		q.Set("provide-strategy", po.ProvideStrategy.String())
+	}
+	if po.Ack != AckConsensus {
+		q.Set("ack", po.Ack.String())
+	}
+	if po.AckPeers > 0 {
+		q.Set("ack-peers", fmt.Sprintf("%d", po.AckPeers))
+	}
+	if po.PinTimeout > 0 {
+		q.Set("pin-timeout", po.PinTimeout.String())
+	}
+	if po.UnpinTimeout > 0 {
+		q.Set("unpin-timeout", po.UnpinTimeout.String())
+	}
+	if po.TimeoutProfile != "" {
+		q.Set("timeout-profile", po.TimeoutProfile)
+	}
 	for k, v := range po.Metadata {
 		if k == "" {
 			continue
@@ -684,6 +1156,10 @@ func (po *PinOptions) ToQuery() (string, error) {
 		q.Set("pin-update", po.PinUpdate.String())
 	}
 
+	if po.ExpectedSize > 0 {
+		q.Set("expected-size", fmt.Sprintf("%d", po.ExpectedSize))
+	}
+
 	if len(po.Origins) > 0 {
 		origins := make([]string, len(po.Origins))
 		for i, o := range po.Origins {
@@ -692,6 +1168,10 @@ func (po *PinOptions) ToQuery() (string, error) {
 		q.Set("origins", strings.Join(origins, ","))
 	}
 
+	if po.Protected {
+		q.Set("protected", "true")
+	}
+
 	return q.Encode(), nil
 }
 
@@ -701,6 +1181,8 @@ func (po *PinOptions) FromQuery(q url.Values) error {
 
 	po.Mode = PinModeFromString(q.Get("mode"))
 
+	po.Namespace = q.Get("namespace")
+
 	rplStr := q.Get("replication")
 	if rplStr != "" { // override
 		q.Set("replication-min", rplStr)
@@ -725,10 +1207,26 @@ func (po *PinOptions) FromQuery(q url.Values) error {
 		po.ShardSize = shardSize
 	}
 
+	if v := q.Get("expected-size"); v != "" {
+		expectedSize, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return errors.New("parameter expected-size is invalid")
+		}
+		po.ExpectedSize = expectedSize
+	}
+
 	if allocs := q.Get("user-allocations"); allocs != "" {
 		po.UserAllocations = StringsToPeers(strings.Split(allocs, ","))
 	}
 
+	if excluded := q.Get("exclude-allocations"); excluded != "" {
+		peers := strings.Split(excluded, ",")
+		po.ExcludeAllocations = StringsToPeers(peers)
+		if len(po.ExcludeAllocations) != len(peers) {
+			return errors.New("parameter exclude-allocations is invalid")
+		}
+	}
+
 	if v := q.Get("expire-at"); v != "" {
 		var tm time.Time
 		err := tm.UnmarshalText([]byte(v))
@@ -747,6 +1245,57 @@ func (po *PinOptions) FromQuery(q url.Values) error {
 		po.ExpireAt = time.Now().Add(d)
 	}
 
+	if v := q.Get("schedule-at"); v != "" {
+		var tm time.Time
+		err := tm.UnmarshalText([]byte(v))
+		if err != nil {
+			return errors.Wrap(err, "schedule-at cannot be parsed")
+		}
+		po.ScheduleAt = tm
+	}
+
+	if v := q.Get("verify-interval"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return errors.Wrap(err, "verify-interval cannot be parsed")
+		}
+		if d < time.Second {
+			return errors.New("verify-interval duration too short")
+		}
+		po.VerifyInterval = d
+	}
+
+	po.ProvideStrategy, err = ProvideStrategyFromString(q.Get("provide-strategy"))
+	if err != nil {
+		return err
+	}
+
+	po.Ack, err = AckLevelFromString(q.Get("ack"))
+	if err != nil {
+		return err
+	}
+
+	err = parseIntParam(q, "ack-peers", &po.AckPeers)
+	if err != nil {
+		return err
+	}
+
+	if v := q.Get("pin-timeout"); v != "" {
+		po.PinTimeout, err = parseTimeout("pin-timeout", v)
+		if err != nil {
+			return err
+		}
+	}
+
+	if v := q.Get("unpin-timeout"); v != "" {
+		po.UnpinTimeout, err = parseTimeout("unpin-timeout", v)
+		if err != nil {
+			return err
+		}
+	}
+
+	po.TimeoutProfile = q.Get("timeout-profile")
+
 	po.Metadata = make(map[string]string)
 	for k := range q {
 		if !strings.HasPrefix(k, pinOptionsMetaPrefix) {
@@ -787,9 +1336,55 @@ func (po *PinOptions) FromQuery(q url.Values) error {
 		po.Origins = maOrigins
 	}
 
+	if v := q.Get("protected"); v != "" {
+		protected, err := strconv.ParseBool(v)
+		if err != nil {
+			return errors.New("parameter protected is invalid")
+		}
+		po.Protected = protected
+	}
+
 	return nil
 }
 
+// Timeouts returns the pin and unpin timeouts and the timeout profile of the
+// pin, as set by its options or, when not set, by its metadata. Zero values
+// mean that the defaults of the IPFS connector apply.
+func (po *PinOptions) Timeouts() (pinTimeout, unpinTimeout time.Duration, profile string, err error) {
+	pinTimeout = po.PinTimeout
+	if v := po.Metadata[PinTimeoutMetaKey]; pinTimeout == 0 && v != "" {
+		pinTimeout, err = parseTimeout(PinTimeoutMetaKey, v)
+		if err != nil {
+			return 0, 0, "", err
+		}
+	}
+	unpinTimeout = po.UnpinTimeout
+	if v := po.Metadata[UnpinTimeoutMetaKey]; unpinTimeout == 0 && v != "" {
+		unpinTimeout, err = parseTimeout(UnpinTimeoutMetaKey, v)
+		if err != nil {
+			return 0, 0, "", err
+		}
+	}
+	profile = po.TimeoutProfile
+	if profile == "" {
+		profile = po.Metadata[TimeoutProfileMetaKey]
+	}
+	return pinTimeout, unpinTimeout, profile, nil
+}
+
+// parseTimeout parses the value of a timeout option. Timeouts are kept
+// with a precision of seconds.
+func parseTimeout(name, v string) (time.Duration, error) {
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, errors.Wrap(err, name+" cannot be parsed")
+	}
+	if d < time.Second {
+		return 0, errors.New(name + " duration too short")
+	}
+	return d, nil
+}
+
 // PinDepth indicates how deep a pin should be pinned, with
 // -1 meaning "to the bottom", or "recursive".
 type PinDepth int
@@ -834,6 +1429,10 @@ type Pin struct {
 
 	// The time that the pin was submitted to the consensus layer.
 	Timestamp time.Time `json:"timestamp" codec:"i,omitempty"`
+
+	// RequestID identifies the API request which submitted the pin. It
+	// is carried over RPC and in the Raft log, but not stored.
+	RequestID string `json:"-" codec:"q,omitempty"`
 }
 
 // String is a string representation of a Pin.
@@ -905,6 +1504,15 @@ func (pin *Pin) ProtoMarshal() ([]byte, error) {
 		allocs[i] = bs
 	}
 
+	excluded := make([][]byte, len(pin.ExcludeAllocations))
+	for i, pid := range pin.ExcludeAllocations {
+		bs, err := pid.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		excluded[i] = bs
+	}
+
 	// Cursory google search says len=0 slices will be
 	// decoded as null, which is fine.
 	origins := make([][]byte, len(pin.Origins))
@@ -919,9 +1527,16 @@ func (pin *Pin) ProtoMarshal() ([]byte, error) {
 	}
 
 	var timestampProto uint64
+	var timestampNanosProto uint32
 	// Only set the protobuf field with non-zero times.
 	if !(pin.Timestamp.IsZero() || pin.Timestamp.Equal(unixZero)) {
 		timestampProto = uint64(pin.Timestamp.Unix())
+		timestampNanosProto = uint32(pin.Timestamp.Nanosecond())
+	}
+
+	var scheduleAtProto uint64
+	if !(pin.ScheduleAt.IsZero() || pin.ScheduleAt.Equal(unixZero)) {
+		scheduleAtProto = uint64(pin.ScheduleAt.Unix())
 	}
 
 	opts := &pb.PinOptions{
@@ -934,16 +1549,29 @@ func (pin *Pin) ProtoMarshal() ([]byte, error) {
 		ExpireAt:             expireAtProto,
 		// Mode:                 pin.Mode,
 		// UserAllocations:      pin.UserAllocations,
-		Origins: origins,
+		Origins:      origins,
+		ExpectedSize: pin.ExpectedSize,
+		Namespace:    pin.Namespace,
+		Protected:    pin.Protected,
+
+		ExcludeAllocations: excluded,
+		ScheduleAt:         scheduleAtProto,
+		VerifyInterval:     uint64(pin.VerifyInterval / time.Second),
+		ProvideStrategy:    uint32(pin.ProvideStrategy),
+		PinTimeout:         uint64(pin.PinTimeout / time.Second),
+		UnpinTimeout:       uint64(pin.UnpinTimeout / time.Second),
+		TimeoutProfile:     pin.TimeoutProfile,
+		User:               pin.User,
 	}
 
 	pbPin := &pb.Pin{
-		Cid:         pin.Cid.Bytes(),
-		Type:        convertPinType(pin.Type),
-		Allocations: allocs,
-		MaxDepth:    int32(pin.MaxDepth),
-		Options:     opts,
-		Timestamp:   timestampProto,
+		Cid:            pin.Cid.Bytes(),
+		Type:           convertPinType(pin.Type),
+		Allocations:    allocs,
+		MaxDepth:       int32(pin.MaxDepth),
+		Options:        opts,
+		Timestamp:      timestampProto,
+		TimestampNanos: timestampNanosProto,
 	}
 	if ref := pin.Reference; ref != nil {
 		pbPin.Reference = ref.Bytes()
@@ -990,7 +1618,7 @@ func (pin *Pin) ProtoUnmarshal(data []byte) error {
 
 	ts := pbPin.GetTimestamp()
 	if ts > 0 {
-		pin.Timestamp = time.Unix(int64(ts), 0)
+		pin.Timestamp = time.Unix(int64(ts), int64(pbPin.GetTimestampNanos()))
 	}
 
 	opts := pbPin.GetOptions()
@@ -998,12 +1626,36 @@ func (pin *Pin) ProtoUnmarshal(data []byte) error {
 	pin.ReplicationFactorMax = int(opts.GetReplicationFactorMax())
 	pin.Name = opts.GetName()
 	pin.ShardSize = opts.GetShardSize()
+	pin.ExpectedSize = opts.GetExpectedSize()
+	pin.Namespace = opts.GetNamespace()
+	pin.Protected = opts.GetProtected()
+
+	pbExcluded := opts.GetExcludeAllocations()
+	if len(pbExcluded) > 0 {
+		pin.ExcludeAllocations = make([]peer.ID, len(pbExcluded))
+		for i, pidb := range pbExcluded {
+			pid, err := peer.IDFromBytes(pidb)
+			if err != nil {
+				return err
+			}
+			pin.ExcludeAllocations[i] = pid
+		}
+	}
 
 	// pin.UserAllocations = opts.GetUserAllocations()
 	exp := opts.GetExpireAt()
 	if exp > 0 {
 		pin.ExpireAt = time.Unix(int64(exp), 0)
 	}
+	if sched := opts.GetScheduleAt(); sched > 0 {
+		pin.ScheduleAt = time.Unix(int64(sched), 0)
+	}
+	pin.VerifyInterval = time.Duration(opts.GetVerifyInterval()) * time.Second
+	pin.ProvideStrategy = ProvideStrategy(opts.GetProvideStrategy())
+	pin.PinTimeout = time.Duration(opts.GetPinTimeout()) * time.Second
+	pin.UnpinTimeout = time.Duration(opts.GetUnpinTimeout()) * time.Second
+	pin.TimeoutProfile = opts.GetTimeoutProfile()
+	pin.User = opts.GetUser()
 	pin.Metadata = opts.GetMetadata()
 	pinUpdate, err := cid.Cast(opts.GetPinUpdate())
 	if err == nil {
@@ -1079,8 +1731,15 @@ func (pin *Pin) Equals(pin2 *Pin) bool {
 }
 
 // IsRemotePin determines whether a Pin's ReplicationFactor has
-// been met, so as to either pin or unpin it from the peer.
+// been met, so as to either pin or unpin it from the peer. Peers in
+// ExcludeAllocations never pin it.
 func (pin *Pin) IsRemotePin(pid peer.ID) bool {
+	for _, p := range pin.ExcludeAllocations {
+		if p == pid {
+			return true
+		}
+	}
+
 	if pin.IsPinEverywhere() {
 		return false
 	}
@@ -1102,6 +1761,31 @@ func (pin *Pin) ExpiredAt(t time.Time) bool {
 	return pin.ExpireAt.Before(t)
 }
 
+// ScheduledAt returns whether the pin is still waiting to be pinned at the
+// given time.
+func (pin *Pin) ScheduledAt(t time.Time) bool {
+	if pin.ScheduleAt.IsZero() || pin.ScheduleAt.Equal(unixZero) {
+		return false
+	}
+
+	return pin.ScheduleAt.After(t)
+}
+
+// TrashedAt returns the time the pin was moved to the trash and true, or
+// false when it is not in the trash.
+func (pin *Pin) TrashedAt() (time.Time, bool) {
+	v, ok := pin.Metadata[TrashedAtMetaKey]
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		// Still in the trash, can be emptied right away.
+		return time.Time{}, true
+	}
+	return t, true
+}
+
 // NodeWithMeta specifies a block of data and a set of optional metadata fields
 // carrying information about the encoded ipld node
 type NodeWithMeta struct {
@@ -1133,6 +1817,14 @@ type Metric struct {
 	Weight        int64   `json:"weight" codec:"w,omitempty"`
 	Partitionable bool    `json:"partitionable" codec:"o,omitempty"`
 	ReceivedAt    int64   `json:"received_at" codec:"t,omitempty"` // ReceivedAt contains a UnixNano timestamp
+	// Interval is the time in nanoseconds between publications of the
+	// metric, as set by the peer monitor when publishing it.
+	Interval int64 `json:"interval,omitempty" codec:"i,omitempty"`
+	// Labels of the peer. They are only sent with ping metrics, and are
+	// set in the alerts about the peer.
+	Labels map[string]string `json:"labels,omitempty" codec:"lb,omitempty"`
+	// Peername of the peer. It is only sent with ping metrics.
+	Peername string `json:"peername,omitempty" codec:"pn,omitempty"`
 }
 
 // SetTTL sets Metric to expire after the given time.Duration
@@ -1195,10 +1887,55 @@ type Alert struct {
 	TriggeredAt time.Time `json:"triggered_at" codec:"r,omitempty"`
 }
 
+// FederatedGlobalPinInfo is the status of a pin in one of the clusters of
+// a federated view.
+type FederatedGlobalPinInfo struct {
+	Cluster string `json:"cluster"`
+	*GlobalPinInfo
+}
+
+// FederatedID is the information about a peer of one of the clusters of a
+// federated view.
+type FederatedID struct {
+	Cluster string `json:"cluster"`
+	*ID
+}
+
+// FederatedHealth summarizes the health of one of the clusters of a
+// federated view. Error is set when the cluster could not be reached.
+type FederatedHealth struct {
+	Cluster         string    `json:"cluster"`
+	Peers           int       `json:"peers"`
+	PeersWithErrors []peer.ID `json:"peers_with_errors"`
+	Alerts          []Alert   `json:"alerts"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// DetectorState describes how the failure detector of a peer monitor sees
+// the latest metric of a given name from a peer. Phi is the accrual
+// failure value, only calculated once enough samples have been received,
+// and compared against Threshold when the metric has expired. Suppressed is
+// set when new alerts for the peer and metric would be suppressed because
+// it alerted recently.
+type DetectorState struct {
+	Peer         peer.ID   `json:"peer" codec:"p,omitempty"`
+	Metric       string    `json:"metric" codec:"m,omitempty"`
+	Phi          float64   `json:"phi" codec:"h,omitempty"`
+	Threshold    float64   `json:"threshold" codec:"t,omitempty"`
+	Samples      int       `json:"samples" codec:"s,omitempty"`
+	LastReceived time.Time `json:"last_received" codec:"r,omitempty"`
+	Expired      bool      `json:"expired" codec:"e,omitempty"`
+	Failed       bool      `json:"failed" codec:"f,omitempty"`
+	Suppressed   bool      `json:"suppressed" codec:"u,omitempty"`
+	LastAlert    time.Time `json:"last_alert,omitempty" codec:"a,omitempty"`
+}
+
 // Error can be used by APIs to return errors.
 type Error struct {
 	Code    int    `json:"code" codec:"o,omitempty"`
 	Message string `json:"message" codec:"m,omitempty"`
+	// RequestID identifies the API request which failed.
+	RequestID string `json:"request_id,omitempty" codec:"r,omitempty"`
 }
 
 // Error implements the error interface and returns the error's message.
@@ -1231,3 +1968,436 @@ type RepoGC struct {
 type GlobalRepoGC struct {
 	PeerMap map[string]*RepoGC `json:"peer_map" codec:"pm,omitempty"`
 }
+
+// ErrChangesUnavailable is returned when the pinset changes since a
+// sequence number are no longer known. The full pinset must be listed
+// instead.
+var ErrChangesUnavailable = errors.New("changes since the given sequence number are not available")
+
+// PinChange describes a change to the pinset: a pin which was added or
+// updated, or a CID which was unpinned. Pin is set for the former.
+type PinChange struct {
+	Sequence uint64  `json:"sequence" codec:"s,omitempty"`
+	Cid      cid.Cid `json:"cid" codec:"c"`
+	Removed  bool    `json:"removed,omitempty" codec:"r,omitempty"`
+	Pin      *Pin    `json:"pin,omitempty" codec:"p,omitempty"`
+}
+
+// PinChanges carries the changes to the pinset since a sequence number,
+// along with the current sequence number to use in the next request.
+type PinChanges struct {
+	Sequence uint64       `json:"sequence" codec:"s,omitempty"`
+	Changes  []*PinChange `json:"changes" codec:"c,omitempty"`
+}
+
+// PinsetEventType identifies the kind of change described by a
+// PinsetEvent.
+type PinsetEventType string
+
+// Pinset event types.
+const (
+	PinsetEventAdded   PinsetEventType = "added"
+	PinsetEventUpdated PinsetEventType = "updated"
+	PinsetEventRemoved PinsetEventType = "removed"
+)
+
+// PinsetEvent is sent to the pinset webhooks by the peer which committed a
+// change to the pinset. User is the authenticated API user which submitted
+// the change, if any. Changes made by the peer itself (i.e. expired pins)
+// carry no user.
+type PinsetEvent struct {
+	Event     PinsetEventType `json:"event"`
+	Pin       *Pin            `json:"pin"`
+	User      string          `json:"user,omitempty"`
+	RequestID string          `json:"request_id,omitempty"`
+	Peer      peer.ID         `json:"peer"`
+	PeerName  string          `json:"peer_name"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// LifecycleEventType identifies a change in the lifecycle of a peer
+// described by a LifecycleEvent.
+type LifecycleEventType string
+
+// Lifecycle event types.
+const (
+	// A peer was added to the peerset. Subject is the peer.
+	LifecycleEventPeerJoined LifecycleEventType = "peer_joined"
+	// A peer was removed from the peerset. Subject is the peer.
+	LifecycleEventPeerLeft LifecycleEventType = "peer_left"
+	// The consensus leader changed (Raft only). Subject is the new
+	// leader.
+	LifecycleEventLeaderChanged LifecycleEventType = "leader_changed"
+	// The shared state is up to date and the peer is ready.
+	LifecycleEventConsensusReady LifecycleEventType = "consensus_ready"
+	// The first reconciliation of the pinset with IPFS after starting
+	// finished.
+	LifecycleEventTrackerWarmedUp LifecycleEventType = "tracker_warmed_up"
+	// An API component is serving requests. Details is the component.
+	LifecycleEventAPIStarted LifecycleEventType = "api_started"
+)
+
+// LifecycleEvent describes a change in the lifecycle of the peer which
+// emits it. Sequence numbers are local to that peer and restart from 1
+// when it starts.
+type LifecycleEvent struct {
+	Sequence  uint64             `json:"sequence" codec:"s,omitempty"`
+	Event     LifecycleEventType `json:"event" codec:"e"`
+	Peer      peer.ID            `json:"peer" codec:"p,omitempty"`
+	PeerName  string             `json:"peer_name" codec:"pn,omitempty"`
+	Subject   peer.ID            `json:"subject,omitempty" codec:"sb,omitempty"`
+	Details   string             `json:"details,omitempty" codec:"d,omitempty"`
+	Timestamp time.Time          `json:"timestamp" codec:"t,omitempty"`
+}
+
+// LifecycleEvents carries the lifecycle events of a peer since a sequence
+// number, along with the current sequence number to use in the next
+// request.
+type LifecycleEvents struct {
+	Sequence uint64            `json:"sequence" codec:"s,omitempty"`
+	Events   []*LifecycleEvent `json:"events" codec:"e,omitempty"`
+}
+
+// OperationType identifies the kind of long-running action tracked by an
+// Operation.
+type OperationType string
+
+// Tracked operation types.
+const (
+	OperationRecoverAll OperationType = "recover_all"
+	OperationRepoGC     OperationType = "repo_gc"
+	OperationRepin      OperationType = "repin"
+	OperationAdd        OperationType = "add"
+
+	OperationBulkUnpin     OperationType = "bulk_unpin"
+	OperationBulkRecover   OperationType = "bulk_recover"
+	OperationBulkReplicate OperationType = "bulk_replicate"
+
+	OperationAudit OperationType = "audit"
+
+	OperationGatewayWarmup OperationType = "gateway_warmup"
+
+	OperationRollback         OperationType = "rollback"
+	OperationConsistencyCheck OperationType = "consistency_check"
+	OperationStartupWarmup    OperationType = "startup_warmup"
+
+	OperationPeerHandover OperationType = "peer_handover"
+
+	OperationReshard OperationType = "reshard"
+)
+
+// OperationStatus is the state of an Operation.
+type OperationStatus string
+
+// Operation statuses.
+const (
+	OperationRunning  OperationStatus = "running"
+	OperationDone     OperationStatus = "done"
+	OperationFailed   OperationStatus = "failed"
+	OperationCanceled OperationStatus = "canceled"
+)
+
+// ErrOperationNotFound is returned when an operation ID is not known to a
+// peer.
+var ErrOperationNotFound = errors.New("operation not found")
+
+// Operation carries information about a long-running action triggered in a
+// cluster peer, like a RecoverAll or a RepoGC. Done and Total measure its
+// progress in units which depend on the operation type (peers contacted,
+// pins re-allocated...). Total is 0 when it is not known. Cid is set for
+// operations which concern a single pin.
+type Operation struct {
+	ID         string          `json:"id" codec:"i,omitempty"`
+	Type       OperationType   `json:"type" codec:"t,omitempty"`
+	Cid        cid.Cid         `json:"cid,omitempty" codec:"c,omitempty"`
+	Status     OperationStatus `json:"status" codec:"s,omitempty"`
+	Done       int             `json:"done" codec:"d,omitempty"`
+	Total      int             `json:"total" codec:"o,omitempty"`
+	StartedAt  time.Time       `json:"started_at" codec:"a,omitempty"`
+	FinishedAt time.Time       `json:"finished_at" codec:"f,omitempty"`
+	Error      string          `json:"error,omitempty" codec:"e,omitempty"`
+}
+
+// StartupWarmup describes the reconciliation of the pinset with IPFS done
+// by a peer when it starts. Recovered counts the items which were pinned or
+// unpinned again, at most Rate per second unless Rate is 0. Operation is
+// not set until the reconciliation starts, and counts the items checked.
+type StartupWarmup struct {
+	Peer      peer.ID    `json:"peer" codec:"p,omitempty"`
+	Peername  string     `json:"peername" codec:"pn,omitempty"`
+	Rate      int        `json:"rate" codec:"r,omitempty"`
+	Recovered int        `json:"recovered" codec:"rc,omitempty"`
+	Operation *Operation `json:"operation,omitempty" codec:"o,omitempty"`
+}
+
+// ErrPinTemplateNotFound is returned when a pin template does not exist.
+var ErrPinTemplateNotFound = errors.New("pin template not found")
+
+// PinTemplate is a named set of pin options, used to pin CIDs without
+// specifying the same options every time.
+type PinTemplate struct {
+	Name    string     `json:"name" codec:"n,omitempty"`
+	Options PinOptions `json:"options" codec:"o,omitempty"`
+	// ExpireIn makes the pins created with the template expire after the
+	// given time. Zero means that they do not expire.
+	ExpireIn time.Duration `json:"expire_in,omitempty" codec:"e,omitempty"`
+}
+
+// PinOptions returns the options for a pin created with the template at
+// the given time.
+func (pt *PinTemplate) PinOptions(now time.Time) PinOptions {
+	opts := copyPinOptions(pt.Options)
+	opts.ExpireAt = time.Time{}
+	if pt.ExpireIn > 0 {
+		opts.ExpireAt = now.Add(pt.ExpireIn)
+	}
+	return opts
+}
+
+// ErrPinsetSnapshotNotFound is returned when a pinset snapshot does not
+// exist.
+var ErrPinsetSnapshotNotFound = errors.New("pinset snapshot not found")
+
+// PinsetSnapshot is a named copy of the pinset, which the pinset can be
+// rolled back to.
+type PinsetSnapshot struct {
+	Name      string    `json:"name" codec:"n,omitempty"`
+	Timestamp time.Time `json:"timestamp" codec:"t,omitempty"`
+	NumPins   int       `json:"num_pins" codec:"c,omitempty"`
+	// Pins are only set when requesting a single snapshot.
+	Pins []*Pin `json:"pins,omitempty" codec:"p,omitempty"`
+}
+
+// PinsetRollback describes the rollback of the pinset to a snapshot: the
+// items pinned again, or pinned with the options they had in the snapshot,
+// and the items unpinned. With DryRun, nothing is changed.
+type PinsetRollback struct {
+	Snapshot string    `json:"snapshot" codec:"s,omitempty"`
+	DryRun   bool      `json:"dry_run,omitempty" codec:"d,omitempty"`
+	Pinned   []cid.Cid `json:"pinned" codec:"p,omitempty"`
+	Unpinned []cid.Cid `json:"unpinned" codec:"u,omitempty"`
+}
+
+// CloneOptions returns the options to pin a different CID in the same way
+// as this pin: same replication factors, mode, allocations and metadata.
+// When the pin expires, the clone expires after the same time, counting
+// from the given time. Name and PinUpdate are not copied.
+func (pin *Pin) CloneOptions(now time.Time) PinOptions {
+	opts := copyPinOptions(pin.PinOptions)
+	opts.ExpireAt = time.Time{}
+	if !(pin.ExpireAt.IsZero() || pin.ExpireAt.Equal(unixZero)) {
+		if ttl := pin.ExpireAt.Sub(pin.Timestamp); ttl > 0 {
+			opts.ExpireAt = now.Add(ttl)
+		}
+	}
+	return opts
+}
+
+// copyPinOptions returns a copy of the given options without a name, a
+// pin update or a schedule, which are specific to a pin.
+func copyPinOptions(po PinOptions) PinOptions {
+	opts := po
+	opts.Name = ""
+	opts.PinUpdate = cid.Undef
+	opts.ScheduleAt = time.Time{}
+	if po.UserAllocations != nil {
+		opts.UserAllocations = append([]peer.ID{}, po.UserAllocations...)
+	}
+	if po.ExcludeAllocations != nil {
+		opts.ExcludeAllocations = append([]peer.ID{}, po.ExcludeAllocations...)
+	}
+	if po.Origins != nil {
+		opts.Origins = append([]Multiaddr{}, po.Origins...)
+	}
+	if po.Metadata != nil {
+		opts.Metadata = make(map[string]string, len(po.Metadata))
+		for k, v := range po.Metadata {
+			opts.Metadata[k] = v
+		}
+	}
+	return opts
+}
+
+// DenylistEntry is a rule of the denylist, which prevents pinning matching
+// content. See the denylist package for the format of the rules.
+type DenylistEntry struct {
+	Rule   string `json:"rule" codec:"r,omitempty"`
+	Source string `json:"source,omitempty" codec:"s,omitempty"`
+}
+
+// ErrDenylisted is returned when pinning content matched by the denylist.
+var ErrDenylisted = errors.New("content is denylisted")
+
+// ErrDenylistEntryNotFound is returned when removing a rule which is not in
+// the denylist.
+var ErrDenylistEntryNotFound = errors.New("denylist entry not found")
+
+// TrackerSettings are the settings of the pin tracker of a peer which can be
+// modified at runtime.
+type TrackerSettings struct {
+	ConcurrentPins int `json:"concurrent_pins" codec:"c,omitempty"`
+}
+
+// APIListeners are the settings of one of the HTTP APIs of a peer which can
+// be changed at runtime: the listen addresses, the TLS certificate and the
+// CORS options. API is the name of the configuration section of the API
+// (i.e. "restapi"). An empty certificate and key serve plain HTTP.
+type APIListeners struct {
+	API                    string        `json:"api" codec:"a"`
+	HTTPListenMultiaddress []string      `json:"http_listen_multiaddress" codec:"l,omitempty"`
+	SSLCertFile            string        `json:"ssl_cert_file,omitempty" codec:"c,omitempty"`
+	SSLKeyFile             string        `json:"ssl_key_file,omitempty" codec:"k,omitempty"`
+	CORSAllowedOrigins     []string      `json:"cors_allowed_origins" codec:"co,omitempty"`
+	CORSAllowedMethods     []string      `json:"cors_allowed_methods" codec:"cm,omitempty"`
+	CORSAllowedHeaders     []string      `json:"cors_allowed_headers" codec:"ch,omitempty"`
+	CORSExposedHeaders     []string      `json:"cors_exposed_headers" codec:"ce,omitempty"`
+	CORSAllowCredentials   bool          `json:"cors_allow_credentials" codec:"cc,omitempty"`
+	CORSMaxAge             time.Duration `json:"cors_max_age" codec:"cx,omitempty"`
+}
+
+// PinSelector selects the pins affected by a bulk operation by their
+// metadata. A pin is selected when it has all the given keys, with the
+// given values or with any value when the value is empty. When Namespace
+// is set, only pins in that namespace are selected.
+type PinSelector struct {
+	Metadata  map[string]string `json:"metadata" codec:"m,omitempty"`
+	Namespace string            `json:"namespace,omitempty" codec:"n,omitempty"`
+}
+
+// ErrEmptySelector is returned by bulk operations when the PinSelector does
+// not include any metadata key, as it would select the whole pinset.
+var ErrEmptySelector = errors.New("at least one metadata key is needed to select pins")
+
+// pinSelectorMetaPrefix prefixes the query arguments which select pins by
+// their metadata: "meta.<key>=<value>".
+const pinSelectorMetaPrefix = "meta."
+
+// PinSelectorFromQuery returns the PinSelector given by the "meta.<key>"
+// arguments of a query.
+func PinSelectorFromQuery(q url.Values) PinSelector {
+	sel := PinSelector{Metadata: make(map[string]string)}
+	for k := range q {
+		key := strings.TrimPrefix(k, pinSelectorMetaPrefix)
+		if key == k || key == "" {
+			continue
+		}
+		sel.Metadata[key] = q.Get(k)
+	}
+	return sel
+}
+
+// ToQuery returns the metadata of the PinSelector as query arguments, the
+// inverse of PinSelectorFromQuery. The Namespace is not included.
+func (sel PinSelector) ToQuery() url.Values {
+	q := url.Values{}
+	for k, v := range sel.Metadata {
+		q.Set(pinSelectorMetaPrefix+k, v)
+	}
+	return q
+}
+
+// Match returns true when the pin is selected.
+func (sel PinSelector) Match(pin *Pin) bool {
+	if sel.Namespace != "" && pin.Namespace != sel.Namespace {
+		return false
+	}
+	for k, v := range sel.Metadata {
+		pv, ok := pin.Metadata[k]
+		if !ok || (v != "" && pv != v) {
+			return false
+		}
+	}
+	return true
+}
+
+// BulkReplication sets the replication factors of all the pins selected by
+// a PinSelector.
+type BulkReplication struct {
+	Selector             PinSelector `json:"selector" codec:"s"`
+	ReplicationFactorMin int         `json:"replication_factor_min" codec:"rn,omitempty"`
+	ReplicationFactorMax int         `json:"replication_factor_max" codec:"rx,omitempty"`
+}
+
+// DAGAuditRequest asks a peer to audit the DAG of a pin in its IPFS daemon.
+// When Repair is set, corrupted blocks are removed and fetched again.
+type DAGAuditRequest struct {
+	Cid    cid.Cid `json:"cid" codec:"c"`
+	Repair bool    `json:"repair,omitempty" codec:"r,omitempty"`
+}
+
+// DAGAudit is the result of checking the integrity of the blocks of a DAG
+// in the IPFS daemon of a peer. Every block is hashed again and compared
+// with the multihash of its CID. Corrupted blocks are not traversed, so
+// blocks below them are not audited.
+type DAGAudit struct {
+	Cid       cid.Cid   `json:"cid" codec:"c"`
+	Peer      peer.ID   `json:"peer" codec:"p,omitempty"`
+	Peername  string    `json:"peername" codec:"pn,omitempty"`
+	Blocks    int       `json:"blocks" codec:"b,omitempty"`
+	Corrupted []cid.Cid `json:"corrupted" codec:"co,omitempty"`
+	Missing   []cid.Cid `json:"missing" codec:"m,omitempty"`
+	// Repaired is set when the corrupted blocks were removed and the
+	// pin was queued to fetch them again.
+	Repaired bool   `json:"repaired,omitempty" codec:"r,omitempty"`
+	Error    string `json:"error,omitempty" codec:"e,omitempty"`
+}
+
+// Ok returns true when no corrupted or missing blocks were found.
+func (a *DAGAudit) Ok() bool {
+	return a.Error == "" && len(a.Corrupted) == 0 && len(a.Missing) == 0
+}
+
+// GlobalDAGAudit contains the DAGAudit of a pin by each of the peers it is
+// allocated to.
+type GlobalDAGAudit struct {
+	Cid     cid.Cid              `json:"cid" codec:"c"`
+	PeerMap map[string]*DAGAudit `json:"peer_map" codec:"pm,omitempty"`
+}
+
+// ConsistencyIssueType identifies the kind of inconsistency found by a
+// consistency check.
+type ConsistencyIssueType string
+
+// Consistency issue types.
+const (
+	// ConsistencyNotPinned: the item is allocated to the peer, but it is
+	// not pinned in IPFS and no pin operation is in progress.
+	ConsistencyNotPinned ConsistencyIssueType = "not_pinned"
+	// ConsistencyStrayOperation: the pin tracker has an operation which
+	// goes against the shared state, like pinning an item which is no
+	// longer allocated to the peer.
+	ConsistencyStrayOperation ConsistencyIssueType = "stray_operation"
+	// ConsistencyLeftoverPin: the item is pinned in IPFS but it is
+	// allocated to other peers.
+	ConsistencyLeftoverPin ConsistencyIssueType = "leftover_pin"
+	// ConsistencyUnknownPin: the item is pinned in IPFS but it is not in
+	// the shared state. It may have been pinned outside the cluster, so
+	// it is never repaired.
+	ConsistencyUnknownPin ConsistencyIssueType = "unknown_pin"
+)
+
+// ConsistencyIssue is an item for which the shared state, the pin tracker
+// and the IPFS daemon of a peer disagree. Status is the status reported by
+// the pin tracker.
+type ConsistencyIssue struct {
+	Cid      cid.Cid              `json:"cid" codec:"c"`
+	Type     ConsistencyIssueType `json:"type" codec:"t,omitempty"`
+	Status   TrackerStatus        `json:"status" codec:"st,omitempty"`
+	Repaired bool                 `json:"repaired,omitempty" codec:"r,omitempty"`
+}
+
+// ConsistencyReport is the result of cross-checking the shared state, the
+// pin tracker and the IPFS pins of a peer. Checked is the number of items
+// allocated to the peer.
+type ConsistencyReport struct {
+	Peer     peer.ID             `json:"peer" codec:"p,omitempty"`
+	Peername string              `json:"peername" codec:"pn,omitempty"`
+	Checked  int                 `json:"checked" codec:"ch,omitempty"`
+	Issues   []*ConsistencyIssue `json:"issues" codec:"i,omitempty"`
+	Error    string              `json:"error,omitempty" codec:"e,omitempty"`
+}
+
+// GlobalConsistencyReport contains the ConsistencyReport of every peer.
+type GlobalConsistencyReport struct {
+	PeerMap map[string]*ConsistencyReport `json:"peer_map" codec:"pm,omitempty"`
+}
@@ -158,6 +158,46 @@ func TestDupTags(t *testing.T) {
 	checkDupTags(t, "codec", typ, nil)
 }
 
+func TestPinCloneOptions(t *testing.T) {
+	ci, _ := cid.Decode("QmXZrtE5jQwXNqCJMfHUTQkvhQ4ZAnqMnmzFMJfLewuabc")
+	now := time.Now()
+	pin := PinWithOpts(ci, PinOptions{
+		Name:                 "original",
+		ReplicationFactorMin: 2,
+		ReplicationFactorMax: 3,
+		Metadata:             map[string]string{"a": "b"},
+		ExpireAt:             now.Add(time.Hour),
+		PinUpdate:            ci,
+	})
+	pin.Timestamp = now.Add(-time.Hour)
+
+	later := now.Add(time.Minute)
+	opts := pin.CloneOptions(later)
+	if opts.Name != "" || opts.PinUpdate != cid.Undef {
+		t.Error("name and pin update should not be copied")
+	}
+	if opts.ReplicationFactorMin != 2 || opts.ReplicationFactorMax != 3 {
+		t.Error("replication factors should be copied")
+	}
+	if !opts.ExpireAt.Equal(later.Add(2 * time.Hour)) {
+		t.Error("the clone should expire after the same time: ", opts.ExpireAt)
+	}
+	opts.Metadata["a"] = "c"
+	if pin.Metadata["a"] != "b" {
+		t.Error("metadata should be copied")
+	}
+
+	tmpl := PinTemplate{Options: pin.PinOptions, ExpireIn: time.Minute}
+	opts = tmpl.PinOptions(now)
+	if opts.Name != "" || !opts.ExpireAt.Equal(now.Add(time.Minute)) {
+		t.Error("unexpected template options")
+	}
+	tmpl.ExpireIn = 0
+	if opts = tmpl.PinOptions(now); !opts.ExpireAt.IsZero() {
+		t.Error("template pins should not expire")
+	}
+}
+
 func TestPinOptionsQuery(t *testing.T) {
 	testcases := []*PinOptions{
 		{
@@ -165,11 +205,25 @@ func TestPinOptionsQuery(t *testing.T) {
 			ReplicationFactorMin: 2,
 			Name:                 "abc",
 			ShardSize:            33,
+			ExpectedSize:         1024,
+			Namespace:            "tenant",
+			Protected:            true,
 			UserAllocations: StringsToPeers([]string{
 				"QmXZrtE5jQwXNqCJMfHUTQkvhQ4ZAnqMnmzFMJfLewuabc",
 				"QmUZ13osndQ5uL4tPWHXe3iBgBgq9gfewcBMSCAuMBsDJ6",
 			}),
-			ExpireAt: time.Now().Add(12 * time.Hour),
+			ExcludeAllocations: StringsToPeers([]string{
+				"QmPGDFvBkgWhvzEK9qaTWrWurSwqXNmhnK3hgELPdZZNPa",
+			}),
+			ExpireAt:        time.Now().Add(12 * time.Hour),
+			ScheduleAt:      time.Now().Add(time.Hour),
+			VerifyInterval:  24 * time.Hour,
+			ProvideStrategy: ProvideStrategyRoots,
+			Ack:             AckReplicated,
+			AckPeers:        2,
+			PinTimeout:      10 * time.Minute,
+			UnpinTimeout:    time.Hour,
+			TimeoutProfile:  "bulk",
 			Metadata: map[string]string{
 				"hello":  "bye",
 				"hello2": "bye2",
@@ -219,6 +273,12 @@ func TestPinOptionsQuery(t *testing.T) {
 			t.Errorf("%+v\n", tc)
 			t.Errorf("%+v\n", po2)
 		}
+		if tc.ExpectedSize != po2.ExpectedSize {
+			t.Error("expected the same ExpectedSize")
+		}
+		if tc.Ack != po2.Ack || tc.AckPeers != po2.AckPeers {
+			t.Error("expected the same acknowledgment options")
+		}
 	}
 }
 
@@ -282,3 +342,120 @@ func TestPinCodec(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestPinProtoOptions(t *testing.T) {
+	ci, _ := cid.Decode("QmXZrtE5jQwXNqCJMfHUTQkvhQ4ZAnqMnmzFMJfLewuabc")
+	pin := PinCid(ci)
+	pin.ExpectedSize = 12345
+	pin.Namespace = "tenant"
+	pin.Protected = true
+	excluded, _ := peer.Decode("QmPGDFvBkgWhvzEK9qaTWrWurSwqXNmhnK3hgELPdZZNPa")
+	pin.ExcludeAllocations = []peer.ID{excluded}
+	pin.ScheduleAt = time.Unix(1700000000, 0)
+	pin.VerifyInterval = 6 * time.Hour
+	pin.ProvideStrategy = ProvideStrategyNone
+	pin.PinTimeout = 90 * time.Second
+	pin.TimeoutProfile = "fast"
+	pin.User = "alice"
+	pin.Timestamp = time.Unix(1700000000, 123456789)
+	data, err := pin.ProtoMarshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var pin2 Pin
+	err = pin2.ProtoUnmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin2.ExpectedSize != 12345 {
+		t.Error("ExpectedSize was not preserved:", pin2.ExpectedSize)
+	}
+	if pin2.Namespace != "tenant" {
+		t.Error("Namespace was not preserved:", pin2.Namespace)
+	}
+	if !pin2.Protected {
+		t.Error("Protected was not preserved")
+	}
+	if len(pin2.ExcludeAllocations) != 1 || pin2.ExcludeAllocations[0] != excluded {
+		t.Error("ExcludeAllocations were not preserved:", pin2.ExcludeAllocations)
+	}
+	if !pin2.IsRemotePin(excluded) {
+		t.Error("excluded peers should not pin")
+	}
+	if !pin2.ScheduleAt.Equal(pin.ScheduleAt) || pin2.VerifyInterval != 6*time.Hour {
+		t.Error("the schedule was not preserved:", pin2.ScheduleAt, pin2.VerifyInterval)
+	}
+	if !pin2.ScheduledAt(pin.ScheduleAt.Add(-time.Second)) || pin2.ScheduledAt(pin.ScheduleAt) {
+		t.Error("the pin should be scheduled until its ScheduleAt time")
+	}
+	if pin2.ProvideStrategy != ProvideStrategyNone {
+		t.Error("ProvideStrategy was not preserved:", pin2.ProvideStrategy)
+	}
+	if pin2.PinTimeout != 90*time.Second || pin2.UnpinTimeout != 0 || pin2.TimeoutProfile != "fast" {
+		t.Error("the timeouts were not preserved:", pin2.PinTimeout, pin2.UnpinTimeout, pin2.TimeoutProfile)
+	}
+	if pin2.User != "alice" {
+		t.Error("User was not preserved:", pin2.User)
+	}
+	if !pin2.Timestamp.Equal(pin.Timestamp) {
+		t.Error("the timestamp was not preserved with full precision:", pin2.Timestamp)
+	}
+}
+
+func TestPinOptionsTimeouts(t *testing.T) {
+	po := &PinOptions{
+		PinTimeout: time.Minute,
+		Metadata: map[string]string{
+			PinTimeoutMetaKey:     "1h",
+			UnpinTimeoutMetaKey:   "2h",
+			TimeoutProfileMetaKey: "bulk",
+		},
+	}
+	pinTimeout, unpinTimeout, profile, err := po.Timeouts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pinTimeout != time.Minute || unpinTimeout != 2*time.Hour || profile != "bulk" {
+		t.Error("unexpected timeouts:", pinTimeout, unpinTimeout, profile)
+	}
+
+	po.Metadata[UnpinTimeoutMetaKey] = "10ms"
+	if _, _, _, err := po.Timeouts(); err == nil {
+		t.Error("expected an error with a too short timeout")
+	}
+
+	q := url.Values{}
+	q.Set("pin-timeout", "abc")
+	if err := (&PinOptions{}).FromQuery(q); err == nil {
+		t.Error("expected an error with an invalid pin-timeout")
+	}
+}
+
+func TestPinSelector(t *testing.T) {
+	q, _ := url.ParseQuery("meta.project=x&meta.owner=&name=abc&meta.=z")
+	sel := PinSelectorFromQuery(q)
+	if len(sel.Metadata) != 2 || sel.Metadata["project"] != "x" {
+		t.Fatal("unexpected selector:", sel.Metadata)
+	}
+	sel2 := PinSelectorFromQuery(sel.ToQuery())
+	if len(sel2.Metadata) != 2 || sel2.Metadata["owner"] != "" {
+		t.Error("the selector does not survive a query roundtrip:", sel2.Metadata)
+	}
+
+	ci, _ := cid.Decode("QmXZrtE5jQwXNqCJMfHUTQkvhQ4ZAnqMnmzFMJfLewuabc")
+	pin := PinCid(ci)
+	pin.Metadata = map[string]string{"project": "x", "owner": "me"}
+	if !sel.Match(pin) {
+		t.Error("expected a match")
+	}
+	pin.Metadata["project"] = "y"
+	if sel.Match(pin) {
+		t.Error("a different value should not match")
+	}
+	pin.Metadata["project"] = "x"
+	sel.Namespace = "tenant"
+	if sel.Match(pin) {
+		t.Error("a different namespace should not match")
+	}
+}
@@ -0,0 +1,232 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+
+	"go.opencensus.io/trace"
+)
+
+// Audits verify that the blocks of a pinned DAG stored by the IPFS daemons
+// are intact. Each peer the pin is allocated to reads the blocks of the DAG
+// from its daemon and hashes them again, down to the pin's depth. IPFS
+// does not do this when serving blocks, so corruption in the datastore
+// would otherwise go unnoticed until the content is requested.
+
+// Audit asks every peer a pin is allocated to to check the integrity of the
+// DAG in its IPFS daemon, and returns the results of each peer. When repair
+// is set, peers with corrupted blocks remove them and pin the DAG again so
+// that they are fetched from the network.
+func (c *Cluster) Audit(ctx context.Context, h cid.Cid, repair bool) (*api.GlobalDAGAudit, error) {
+	_, span := trace.StartSpan(ctx, "cluster/Audit")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	pin, err := c.PinGet(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	if err := auditable(pin); err != nil {
+		return nil, err
+	}
+
+	dests := pin.Allocations
+	if len(dests) == 0 { // allocated everywhere
+		dests, err = c.consensus.Peers(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	op := c.operations.start(ctx, api.OperationAudit, len(dests))
+	defer op.finish(nil)
+
+	results := c.broadcast(
+		op.ctx,
+		dests,
+		"Cluster",
+		"AuditLocal",
+		api.DAGAuditRequest{Cid: h, Repair: repair},
+		func() interface{} { return &api.DAGAudit{} },
+	)
+
+	gAudit := &api.GlobalDAGAudit{
+		Cid:     h,
+		PeerMap: make(map[string]*api.DAGAudit),
+	}
+	for res := range results {
+		op.progress(1)
+		if res.Err == nil {
+			gAudit.PeerMap[peer.Encode(res.Peer)] = res.Reply.(*api.DAGAudit)
+			continue
+		}
+
+		if rpc.IsAuthorizationError(res.Err) {
+			logger.Debug("rpc auth error:", res.Err)
+			continue
+		}
+
+		logger.Errorf("%s: error in broadcast response from %s: %s ", c.id, res.Peer, res.Err)
+		gAudit.PeerMap[peer.Encode(res.Peer)] = &api.DAGAudit{
+			Cid:      h,
+			Peer:     res.Peer,
+			Peername: peer.Encode(res.Peer),
+			Error:    res.Err.Error(),
+		}
+	}
+
+	if op.canceled() {
+		return nil, op.ctx.Err()
+	}
+	return gAudit, nil
+}
+
+// AuditLocal checks the integrity of the DAG of a pin in the IPFS daemon of
+// this peer. Failures to get or verify blocks are part of the returned
+// DAGAudit. An error is returned when the audit could not run.
+func (c *Cluster) AuditLocal(ctx context.Context, req api.DAGAuditRequest) (*api.DAGAudit, error) {
+	_, span := trace.StartSpan(ctx, "cluster/AuditLocal")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	pin, err := c.PinGet(ctx, req.Cid)
+	if err != nil {
+		return nil, err
+	}
+	if err := auditable(pin); err != nil {
+		return nil, err
+	}
+
+	audit := &api.DAGAudit{
+		Cid:       req.Cid,
+		Peer:      c.id,
+		Peername:  c.config.Peername,
+		Corrupted: []cid.Cid{},
+		Missing:   []cid.Cid{},
+	}
+	err = c.auditDAG(ctx, pin.Cid, pin.MaxDepth, audit)
+	if err != nil {
+		audit.Error = err.Error()
+		return audit, nil
+	}
+
+	log := api.RequestLogger(ctx, logger)
+	if len(audit.Corrupted) == 0 {
+		return audit, nil
+	}
+	log.Errorf("audit of %s: %d corrupted blocks", req.Cid, len(audit.Corrupted))
+
+	if req.Repair {
+		err = c.repairDAG(ctx, pin, audit.Corrupted)
+		if err != nil {
+			audit.Error = fmt.Sprintf("repairing: %s", err)
+			return audit, nil
+		}
+		log.Infof("audit of %s: corrupted blocks removed, pinning again", req.Cid)
+		audit.Repaired = true
+	}
+	return audit, nil
+}
+
+func auditable(pin *api.Pin) error {
+	switch pin.Type {
+	case api.DataType, api.ShardType:
+		return nil
+	case api.MetaType:
+		return errors.New("sharded pins are audited shard by shard")
+	default:
+		return fmt.Errorf("%s pins cannot be audited", pin.Type)
+	}
+}
+
+type auditItem struct {
+	cid   cid.Cid
+	depth int
+}
+
+// auditDAG walks the DAG from root down to maxDepth, reading every block
+// from the IPFS repo and checking it against its CID. Blocks are not
+// fetched from the network, so that missing blocks are reported rather
+// than downloaded. Corrupted and missing blocks are recorded in the audit.
+func (c *Cluster) auditDAG(ctx context.Context, root cid.Cid, maxDepth api.PinDepth, audit *api.DAGAudit) error {
+	visited := cid.NewSet()
+	queue := []auditItem{{cid: root}}
+	for len(queue) > 0 {
+		item := queue[0]
+		queue = queue[1:]
+		if !visited.Visit(item.cid) {
+			continue
+		}
+
+		data, err := c.ipfs.BlockGetLocal(ctx, item.cid)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			logger.Warnf("audit: cannot get block %s: %s", item.cid, err)
+			audit.Missing = append(audit.Missing, item.cid)
+			continue
+		}
+		audit.Blocks++
+
+		sum, err := item.cid.Prefix().Sum(data)
+		if err != nil {
+			return fmt.Errorf("cannot hash block %s: %w", item.cid, err)
+		}
+		if !sum.Equals(item.cid) {
+			logger.Errorf("audit: block %s is corrupted (hashes to %s)", item.cid, sum)
+			audit.Corrupted = append(audit.Corrupted, item.cid)
+			continue
+		}
+
+		if maxDepth >= 0 && item.depth >= int(maxDepth) {
+			continue
+		}
+		b, err := blocks.NewBlockWithCid(data, item.cid)
+		if err != nil {
+			return err
+		}
+		node, err := ipld.Decode(b)
+		if err != nil {
+			return fmt.Errorf("cannot decode block %s: %w", item.cid, err)
+		}
+		for _, l := range node.Links() {
+			queue = append(queue, auditItem{cid: l.Cid, depth: item.depth + 1})
+		}
+	}
+	return nil
+}
+
+// repairDAG removes the corrupted blocks of a pin from IPFS, which requires
+// unpinning it first, and tracks the pin again so that IPFS downloads
+// them from other providers. The pin is tracked again even when removing
+// the blocks fails, so that it is not left unpinned.
+func (c *Cluster) repairDAG(ctx context.Context, pin *api.Pin, corrupted []cid.Cid) error {
+	err := c.ipfs.Unpin(ctx, pin)
+	if err == nil {
+		for _, ci := range corrupted {
+			err = c.ipfs.BlockRm(ctx, ci)
+			if err != nil {
+				break
+			}
+		}
+	}
+
+	trackErr := c.tracker.Track(ctx, pin)
+	if err != nil {
+		if trackErr != nil {
+			logger.Errorf("error pinning %s again after a failed repair: %s", pin.Cid, trackErr)
+		}
+		return err
+	}
+	return trackErr
+}
@@ -0,0 +1,123 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+	merkledag "github.com/ipfs/go-merkledag"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+func TestClusterAudit(t *testing.T) {
+	ctx := context.Background()
+	cl, _, ipfs, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	leaf1 := merkledag.NewRawNode([]byte("leaf 1"))
+	leaf2 := merkledag.NewRawNode([]byte("leaf 2"))
+	root := merkledag.NodeWithData([]byte("root"))
+	if err := root.AddNodeLink("1", leaf1); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.AddNodeLink("2", leaf2); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []*merkledag.RawNode{leaf1, leaf2} {
+		ipfs.blocks.Store(n.Cid().String(), n.RawData())
+	}
+	ipfs.blocks.Store(root.Cid().String(), root.RawData())
+
+	_, err := cl.Pin(ctx, root.Cid(), api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	gAudit, err := cl.Audit(ctx, root.Cid(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	audit, ok := gAudit.PeerMap[peer.Encode(cl.id)]
+	if !ok {
+		t.Fatal("expected an audit for the peer")
+	}
+	if !audit.Ok() || audit.Blocks != 3 {
+		t.Fatalf("expected 3 good blocks: %+v", audit)
+	}
+
+	ipfs.blocks.Store(leaf2.Cid().String(), []byte("corrupted"))
+	audit, err = cl.AuditLocal(ctx, api.DAGAuditRequest{Cid: root.Cid(), Repair: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(audit.Corrupted) != 1 || !audit.Corrupted[0].Equals(leaf2.Cid()) {
+		t.Fatal("expected the corrupted leaf:", audit.Corrupted)
+	}
+	if !audit.Repaired {
+		t.Error("expected a repair:", audit.Error)
+	}
+	if _, ok := ipfs.blocks.Load(leaf2.Cid().String()); ok {
+		t.Error("the corrupted block should have been removed")
+	}
+
+	// The pin is tracked again.
+	time.Sleep(time.Second)
+	if _, ok := ipfs.pins.Load(root.Cid().String()); !ok {
+		t.Error("expected the root to be pinned again")
+	}
+
+	// Missing blocks are reported.
+	audit, err = cl.AuditLocal(ctx, api.DAGAuditRequest{Cid: root.Cid()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(audit.Missing) != 1 || audit.Blocks != 2 {
+		t.Errorf("expected a missing block: %+v", audit)
+	}
+
+	// Direct pins only include the root.
+	_, err = cl.Unpin(ctx, root.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cl.Pin(ctx, root.Cid(), api.PinOptions{Mode: api.PinModeDirect})
+	if err != nil {
+		t.Fatal(err)
+	}
+	audit, err = cl.AuditLocal(ctx, api.DAGAuditRequest{Cid: root.Cid()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !audit.Ok() || audit.Blocks != 1 {
+		t.Errorf("expected only the root block: %+v", audit)
+	}
+	pinDelay()
+
+	// Failed repairs pin the content again.
+	pin, err := cl.PinGet(ctx, root.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cl.ipfs = &blockRmFailConnector{IPFSConnector: ipfs}
+	if err := cl.repairDAG(ctx, pin, []cid.Cid{root.Cid()}); err == nil {
+		t.Error("expected the repair to fail")
+	}
+	time.Sleep(time.Second)
+	if _, ok := ipfs.pins.Load(root.Cid().String()); !ok {
+		t.Error("expected the root to be pinned again after a failed repair")
+	}
+}
+
+type blockRmFailConnector struct {
+	IPFSConnector
+}
+
+func (ipfs *blockRmFailConnector) BlockRm(ctx context.Context, c cid.Cid) error {
+	return errors.New("cannot remove blocks")
+}
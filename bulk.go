@@ -0,0 +1,174 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/state"
+
+	"go.opencensus.io/trace"
+)
+
+// Bulk operations act on all the pins selected by their metadata, so that
+// datasets made of many pins sharing a tag can be managed at once. Each of
+// them is tracked as an operation, which can be followed and canceled.
+
+// PinsMatching returns the data and meta pins selected by the given
+// selector.
+func (c *Cluster) PinsMatching(ctx context.Context, sel api.PinSelector) ([]*api.Pin, error) {
+	_, span := trace.StartSpan(ctx, "cluster/PinsMatching")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	if len(sel.Metadata) == 0 {
+		return nil, api.ErrEmptySelector
+	}
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pins []*api.Pin
+	if il, ok := cState.(state.IndexedLister); ok {
+		// The index narrows the list down to the pins with one of
+		// the keys. The rest of the selector is checked below.
+		keys := make([]string, 0, len(sel.Metadata))
+		for k := range sel.Metadata {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		pins, err = il.ListByMetadata(ctx, keys[0], sel.Metadata[keys[0]])
+	} else {
+		pins, err = cState.List(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	matching := make([]*api.Pin, 0)
+	for _, p := range pins {
+		if (p.Type == api.DataType || p.Type == api.MetaType) && sel.Match(p) {
+			matching = append(matching, p)
+		}
+	}
+	return matching, nil
+}
+
+// UnpinMatching unpins all the pins selected by the given selector and
+// returns them. Protected pins are skipped.
+func (c *Cluster) UnpinMatching(ctx context.Context, sel api.PinSelector) ([]*api.Pin, error) {
+	_, span := trace.StartSpan(ctx, "cluster/UnpinMatching")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	pins, err := c.PinsMatching(ctx, sel)
+	if err != nil {
+		return nil, err
+	}
+
+	op := c.operations.start(ctx, api.OperationBulkUnpin, len(pins))
+	unpinned := make([]*api.Pin, 0, len(pins))
+	for _, p := range pins {
+		if op.canceled() {
+			break
+		}
+		pin, err := c.Unpin(op.ctx, p.Cid)
+		op.progress(1)
+		if errors.Is(err, api.ErrPinProtected) {
+			logger.Infof("bulk unpin: skipping protected pin %s", p.Cid)
+			continue
+		}
+		if err != nil {
+			op.finish(err)
+			return unpinned, err
+		}
+		unpinned = append(unpinned, pin)
+	}
+	op.finish(nil)
+	return unpinned, nil
+}
+
+// RecoverMatching triggers a recover operation in all cluster peers for
+// the pins selected by the given selector.
+func (c *Cluster) RecoverMatching(ctx context.Context, sel api.PinSelector) ([]*api.GlobalPinInfo, error) {
+	_, span := trace.StartSpan(ctx, "cluster/RecoverMatching")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	pins, err := c.PinsMatching(ctx, sel)
+	if err != nil {
+		return nil, err
+	}
+
+	op := c.operations.start(ctx, api.OperationBulkRecover, len(pins))
+	infos := make([]*api.GlobalPinInfo, 0, len(pins))
+	for _, p := range pins {
+		if op.canceled() {
+			break
+		}
+		gpi, err := c.Recover(op.ctx, p.Cid)
+		op.progress(1)
+		if err != nil {
+			op.finish(err)
+			return infos, err
+		}
+		infos = append(infos, gpi)
+	}
+	op.finish(nil)
+	return infos, nil
+}
+
+// ReplicateMatching sets the replication factors of the data pins selected
+// by the selector of the given BulkReplication, allocating them again. It
+// returns the pins which changed.
+func (c *Cluster) ReplicateMatching(ctx context.Context, r api.BulkReplication) ([]*api.Pin, error) {
+	_, span := trace.StartSpan(ctx, "cluster/ReplicateMatching")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	if c.config.FollowerMode {
+		return nil, errFollowerMode
+	}
+	if r.ReplicationFactorMin == 0 || r.ReplicationFactorMax == 0 {
+		return nil, errors.New("both replication factors must be set")
+	}
+
+	pins, err := c.PinsMatching(ctx, r.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	op := c.operations.start(ctx, api.OperationBulkReplicate, len(pins))
+	updated := make([]*api.Pin, 0, len(pins))
+	for _, p := range pins {
+		if op.canceled() {
+			break
+		}
+		op.progress(1)
+		if p.Type != api.DataType ||
+			(p.ReplicationFactorMin == r.ReplicationFactorMin && p.ReplicationFactorMax == r.ReplicationFactorMax) {
+			continue
+		}
+
+		newPin := *p
+		newPin.ReplicationFactorMin = r.ReplicationFactorMin
+		newPin.ReplicationFactorMax = r.ReplicationFactorMax
+		newPin.Allocations = nil // force re-allocations
+		// planPin rather than pin, which would handle pins with a
+		// PinUpdate as new updates.
+		pin, err := c.planPin(op.ctx, &newPin, nil)
+		if err == nil {
+			err = c.logPin(op.ctx, pin)
+		}
+		if err != nil {
+			op.finish(err)
+			return updated, err
+		}
+		updated = append(updated, pin)
+	}
+	op.finish(nil)
+	return updated, nil
+}
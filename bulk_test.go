@@ -0,0 +1,88 @@
+package ipfscluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/state"
+	"github.com/ipfs/ipfs-cluster/test"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+func TestClusterBulkOperations(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	tagged := api.PinOptions{Metadata: map[string]string{"project": "x"}}
+	for _, c := range []cid.Cid{test.Cid1, test.Cid2} {
+		if _, err := cl.Pin(ctx, c, tagged); err != nil {
+			t.Fatal(err)
+		}
+	}
+	other := api.PinOptions{Metadata: map[string]string{"project": "y"}}
+	if _, err := cl.Pin(ctx, test.Cid3, other); err != nil {
+		t.Fatal(err)
+	}
+
+	sel := api.PinSelector{Metadata: map[string]string{"project": "x"}}
+	pins, err := cl.PinsMatching(ctx, sel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 2 {
+		t.Fatal("expected 2 matching pins:", len(pins))
+	}
+
+	if _, err := cl.PinsMatching(ctx, api.PinSelector{}); err != api.ErrEmptySelector {
+		t.Error("expected an error with an empty selector:", err)
+	}
+
+	gpis, err := cl.RecoverMatching(ctx, sel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gpis) != 2 {
+		t.Error("expected 2 recovered pins:", len(gpis))
+	}
+
+	updated, err := cl.ReplicateMatching(ctx, api.BulkReplication{
+		Selector:             sel,
+		ReplicationFactorMin: 1,
+		ReplicationFactorMax: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated) != 2 || updated[0].ReplicationFactorMax != 1 {
+		t.Error("expected 2 pins with the new replication factors")
+	}
+
+	unpinned, err := cl.UnpinMatching(ctx, sel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unpinned) != 2 {
+		t.Error("expected 2 unpinned pins:", len(unpinned))
+	}
+	if _, err := cl.PinGet(ctx, test.Cid1); err != state.ErrNotFound {
+		t.Error("expected the pin to be unpinned:", err)
+	}
+	if _, err := cl.PinGet(ctx, test.Cid3); err != nil {
+		t.Error("expected the pin with other metadata to be kept:", err)
+	}
+
+	// The startup warm-up is listed too when it runs after pinning.
+	var ops []*api.Operation
+	for _, op := range cl.operations.list() {
+		if op.Type != api.OperationStartupWarmup {
+			ops = append(ops, op)
+		}
+	}
+	if len(ops) != 3 {
+		t.Error("expected an operation for every bulk operation:", len(ops))
+	}
+}
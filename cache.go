@@ -0,0 +1,234 @@
+package ipfscluster
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/state"
+
+	cid "github.com/ipfs/go-cid"
+	trace "go.opencensus.io/trace"
+)
+
+// This file gathers the logic of the read-through cache, which makes the
+// cluster pin the content requested often from the IPFS daemon of a peer:
+//
+// * Accesses reported by the IPFS proxy with TrackAccess() are counted,
+//   along with the /ipfs/ paths found in the access log of the gateway.
+// * On every ReadThroughCache.Interval, each peer pins the content that it
+//   saw requested at least Threshold times, with a low replication factor
+//   and an expiry date TTL away, and resets its counts.
+// * Cache pins which keep being requested get their expiry extended. The
+//   others expire and are unpinned as usual.
+//
+// Content pinned otherwise is left untouched.
+
+// ReadThroughCacheMetaKey is the metadata key set on the pins made by the
+// read-through cache.
+const ReadThroughCacheMetaKey = "read_through_cache"
+
+// accessLogCidRegexp matches the CIDs in the paths of path gateways
+// (/ipfs/<cid>) and in the hosts of subdomain gateways (<cid>.ipfs.host).
+var accessLogCidRegexp = regexp.MustCompile(`/ipfs/([A-Za-z0-9]+)|([a-z0-9]+)\.ipfs\.`)
+
+// accessLogReader reads the lines appended to an access log since the
+// previous read.
+type accessLogReader struct {
+	path   string
+	offset int64
+}
+
+// newAccessLogReader returns a reader which skips the lines already in
+// the log.
+func newAccessLogReader(path string) *accessLogReader {
+	r := &accessLogReader{path: path}
+	if fi, err := os.Stat(path); err == nil {
+		r.offset = fi.Size()
+	}
+	return r
+}
+
+// readCids returns the CIDs found in the complete lines appended to the
+// log. It starts over when the log was truncated or rotated.
+func (r *accessLogReader) readCids() ([]cid.Cid, error) {
+	f, err := os.Open(r.path)
+	if os.IsNotExist(err) {
+		r.offset = 0
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() < r.offset {
+		r.offset = 0
+	}
+	if _, err := f.Seek(r.offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var cids []cid.Cid
+	br := bufio.NewReader(f)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err != nil {
+			// Partial lines are read again next time.
+			if err == io.EOF {
+				return cids, nil
+			}
+			return cids, err
+		}
+		r.offset += int64(len(line))
+		for _, m := range accessLogCidRegexp.FindAllSubmatch(bytes.TrimSpace(line), -1) {
+			s := m[1]
+			if len(s) == 0 {
+				s = m[2]
+			}
+			if ci, err := cid.Decode(string(s)); err == nil {
+				cids = append(cids, ci)
+			}
+		}
+	}
+}
+
+func (c *Cluster) watchReadThroughCache() {
+	ticker := time.NewTicker(c.config.ReadThroughCache.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.pinCached(c.ctx)
+		}
+	}
+}
+
+// cachedAccesses returns the access counts since the previous call, from
+// the IPFS proxy and the access log, and resets them.
+func (c *Cluster) cachedAccesses() map[string]uint64 {
+	counts := c.cacheHits.flush()
+
+	var cids []cid.Cid
+	c.accessLog, cids = readAccessLog(c.accessLog, c.config.GetAccessLogPath())
+	for _, ci := range cids {
+		counts[ci.String()]++
+	}
+	return counts
+}
+
+// readAccessLog returns the CIDs in the lines appended to the access log
+// at the given path since the reader last read it, and the reader to use
+// next time. A new reader is returned, which skips the existing lines,
+// when the path changed.
+func readAccessLog(r *accessLogReader, path string) (*accessLogReader, []cid.Cid) {
+	if path == "" {
+		return nil, nil
+	}
+	if r == nil || r.path != path {
+		return newAccessLogReader(path), nil
+	}
+	cids, err := r.readCids()
+	if err != nil {
+		logger.Warnf("error reading the access log %s: %s", path, err)
+	}
+	return r, cids
+}
+
+// pinCached pins the content which was accessed often enough since the
+// previous round, or extends the expiry of its cache pins.
+func (c *Cluster) pinCached(ctx context.Context) {
+	ctx, span := trace.StartSpan(ctx, "cluster/pinCached")
+	defer span.End()
+
+	cfg := c.config.ReadThroughCache
+	counts := c.cachedAccesses()
+	if c.config.FollowerMode {
+		return
+	}
+
+	var hot []string
+	for k, n := range counts {
+		if n >= cfg.Threshold {
+			hot = append(hot, k)
+		}
+	}
+	if len(hot) == 0 {
+		return
+	}
+	sort.Slice(hot, func(i, j int) bool {
+		return counts[hot[i]] > counts[hot[j]]
+	})
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		logger.Warn(err)
+		return
+	}
+
+	// Content pinned otherwise does not count towards MaxPins.
+	expireAt := time.Now().Add(cfg.TTL)
+	cached := 0
+	for _, k := range hot {
+		if cached >= cfg.MaxPins {
+			return
+		}
+		ci, err := cid.Decode(k)
+		if err != nil {
+			continue
+		}
+		ok, err := c.pinCachedCid(ctx, cState, ci, expireAt)
+		if err != nil {
+			logger.Warnf("error caching %s: %s", ci, err)
+		}
+		if ok {
+			cached++
+		}
+	}
+}
+
+// pinCachedCid pins a cid as a cache pin, or extends its cache pin. It
+// returns false when the cid is pinned otherwise.
+func (c *Cluster) pinCachedCid(ctx context.Context, cState state.ReadOnly, ci cid.Cid, expireAt time.Time) (bool, error) {
+	existing, err := cState.Get(ctx, ci)
+	switch {
+	case err == state.ErrNotFound:
+		cfg := c.config.ReadThroughCache
+		opts := api.PinOptions{
+			ReplicationFactorMin: cfg.ReplicationFactor,
+			ReplicationFactorMax: cfg.ReplicationFactor,
+			ExpireAt:             expireAt,
+			Metadata:             map[string]string{ReadThroughCacheMetaKey: "true"},
+		}
+		logger.Infof("caching %s until %s", ci, expireAt)
+		_, err := c.Pin(ctx, ci, opts)
+		return true, err
+	case err != nil:
+		return false, err
+	}
+
+	if existing.Metadata[ReadThroughCacheMetaKey] == "" {
+		return false, nil
+	}
+	if !existing.ExpireAt.Before(expireAt) {
+		return true, nil
+	}
+	pin := copyWithMetadata(existing)
+	pin.ExpireAt = expireAt
+	logger.Debugf("extending the cache of %s until %s", ci, expireAt)
+	_, _, err = c.pin(ctx, pin, nil)
+	return true, err
+}
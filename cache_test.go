@@ -0,0 +1,119 @@
+package ipfscluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+)
+
+func TestAccessLogReader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	appendLog := func(lines ...string) {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		for _, l := range lines {
+			fmt.Fprint(f, l)
+		}
+	}
+
+	appendLog(fmt.Sprintf("GET /ipfs/%s HTTP/1.1\n", test.Cid1))
+	r := newAccessLogReader(path)
+	appendLog(
+		fmt.Sprintf("GET /ipfs/%s/index.html HTTP/1.1\n", test.Cid2),
+		"GET /ipfs/notacid HTTP/1.1\n",
+		fmt.Sprintf("GET / HTTP/1.1 host=%s.ipfs.dweb.link\n", test.Cid4),
+		fmt.Sprintf("GET /ipfs/%s", test.Cid3), // incomplete
+	)
+	cids, err := r.readCids()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cids) != 2 || !cids[0].Equals(test.Cid2) || !cids[1].Equals(test.Cid4) {
+		t.Fatal("unexpected cids:", cids)
+	}
+
+	appendLog(" HTTP/1.1\n")
+	cids, _ = r.readCids()
+	if len(cids) != 1 || !cids[0].Equals(test.Cid3) {
+		t.Fatal("expected the completed line to be read:", cids)
+	}
+
+	// Rotated
+	os.WriteFile(path, []byte(fmt.Sprintf("GET /ipfs/%s HTTP/1.1\n", test.Cid1)), 0600)
+	cids, _ = r.readCids()
+	if len(cids) != 1 || !cids[0].Equals(test.Cid1) {
+		t.Fatal("expected the log to be read from the start:", cids)
+	}
+}
+
+func TestReadThroughCache(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	cl.config.ReadThroughCache = ReadThroughCacheConfig{
+		Interval:          time.Hour,
+		Threshold:         2,
+		ReplicationFactor: 1,
+		TTL:               time.Hour,
+		MaxPins:           1,
+	}
+
+	if _, err := cl.Pin(ctx, test.Cid3, api.PinOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	for i := 0; i < 3; i++ {
+		cl.TrackAccess(ctx, test.Cid1)
+		cl.TrackAccess(ctx, test.Cid3)
+	}
+	cl.TrackAccess(ctx, test.Cid2)
+	cl.TrackAccess(ctx, test.Cid2)
+	cl.pinCached(ctx)
+	pinDelay()
+
+	pin, err := cl.PinGet(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal("expected the most accessed cid to be cached:", err)
+	}
+	if pin.Metadata[ReadThroughCacheMetaKey] == "" || pin.ReplicationFactorMax != 1 || pin.ExpireAt.IsZero() {
+		t.Errorf("unexpected cache pin: %+v", pin)
+	}
+	if _, err := cl.PinGet(ctx, test.Cid2); err == nil {
+		t.Error("only MaxPins should be cached")
+	}
+	// The cache pin is extended, regular pins are left alone.
+	cl.config.ReadThroughCache.TTL = 3 * time.Hour
+	cl.TrackAccess(ctx, test.Cid1)
+	cl.TrackAccess(ctx, test.Cid1)
+	cl.TrackAccess(ctx, test.Cid3)
+	cl.TrackAccess(ctx, test.Cid3)
+	cl.pinCached(ctx)
+	pinDelay()
+
+	extended, err := cl.PinGet(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !extended.ExpireAt.After(pin.ExpireAt.Add(time.Hour)) {
+		t.Error("expected the cache pin to be extended:", pin.ExpireAt, extended.ExpireAt)
+	}
+	regular, err := cl.PinGet(ctx, test.Cid3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regular.ExpireAt.IsZero() || regular.Metadata[ReadThroughCacheMetaKey] != "" {
+		t.Error("regular pins should not become cache pins")
+	}
+}
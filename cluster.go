@@ -5,13 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"mime/multipart"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/ipfs/ipfs-cluster/adder"
+	"github.com/ipfs/ipfs-cluster/adder/erasure"
 	"github.com/ipfs/ipfs-cluster/adder/sharding"
 	"github.com/ipfs/ipfs-cluster/adder/single"
 	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/denylist"
 	"github.com/ipfs/ipfs-cluster/pstoremgr"
 	"github.com/ipfs/ipfs-cluster/rpcutil"
 	"github.com/ipfs/ipfs-cluster/state"
@@ -21,8 +24,10 @@ import (
 	cid "github.com/ipfs/go-cid"
 	ds "github.com/ipfs/go-datastore"
 	host "github.com/libp2p/go-libp2p-core/host"
+	metrics "github.com/libp2p/go-libp2p-core/metrics"
 	peer "github.com/libp2p/go-libp2p-core/peer"
 	peerstore "github.com/libp2p/go-libp2p-core/peerstore"
+	protocol "github.com/libp2p/go-libp2p-core/protocol"
 	rpc "github.com/libp2p/go-libp2p-gorpc"
 	dual "github.com/libp2p/go-libp2p-kad-dht/dual"
 	mdns "github.com/libp2p/go-libp2p/p2p/discovery/mdns"
@@ -57,6 +62,8 @@ type Cluster struct {
 	id        peer.ID
 	config    *Config
 	host      host.Host
+	bandwidth *metrics.BandwidthCounter
+	secrets   *secretRing
 	dht       *dual.DHT
 	discovery mdns.Service
 	datastore ds.Datastore
@@ -71,12 +78,54 @@ type Cluster struct {
 	tracker   PinTracker
 	monitor   PeerMonitor
 	allocator PinAllocator
-	informers []Informer
-	tracer    Tracer
+	informers  []Informer
+	publishers []EventPublisher
+	tracer     Tracer
 
 	alerts    []api.Alert
 	alertsMux sync.Mutex
 
+	// peernames of the current peers, as last seen in their ping metrics.
+	peernames    map[peer.ID]string
+	peernamesMux sync.Mutex
+
+	accesses      *accessCounter
+	cacheHits     *accessCounter
+	accessLog     *accessLogReader
+	popularityLog *accessLogReader
+
+	operations *operationTracker
+	repins     *repinTracker
+
+	connHistory *connectivityHistory
+
+	// RPC protocol versions of the peers which do not speak ours, as
+	// last alerted.
+	rpcSkew    map[peer.ID]protocol.ID
+	rpcSkewMux sync.Mutex
+
+	// events waiting to be sent to the webhooks and publishers.
+	events eventQueues
+
+	// latest lifecycle events of this peer.
+	lifecycle *lifecycleLog
+
+	// names published to IPNS and DNSLink. Nil when disabled.
+	names *namePublisher
+
+	// gateway warm-ups waiting to be picked up by watchGatewayWarmups.
+	gatewayWarmups chan *gatewayWarmup
+
+	// progress of the first recover after starting.
+	startup *startupWarmup
+
+	denylist    *denylist.Denylist
+	denylistMux sync.Mutex
+
+	// The RPC policy in use, which can be modified at runtime.
+	rpcPolicy    map[string]RPCEndpointType
+	rpcPolicyMux sync.RWMutex
+
 	doneCh  chan struct{}
 	readyCh chan struct{}
 	readyB  bool
@@ -110,6 +159,7 @@ func NewCluster(
 	monitor PeerMonitor,
 	allocator PinAllocator,
 	informers []Informer,
+	publishers []EventPublisher,
 	tracer Tracer,
 ) (*Cluster, error) {
 	err := cfg.Validate()
@@ -125,6 +175,16 @@ func NewCluster(
 		return nil, errors.New("no informers are passed")
 	}
 
+	dl, err := loadDenylist(ctx, cfg, datastore)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.FaultInjection.IPFS.enabled() {
+		logger.Warn("fault injection is enabled for IPFS calls")
+		ipfs = newFaultyConnector(ipfs, cfg.FaultInjection.IPFS)
+	}
+
 	ctx, cancel := context.WithCancel(ctx)
 
 	listenAddrs := ""
@@ -161,8 +221,21 @@ func NewCluster(
 		monitor:     monitor,
 		allocator:   allocator,
 		informers:   informers,
+		publishers:  publishers,
 		tracer:      tracer,
 		alerts:      []api.Alert{},
+		accesses:    newAccessCounter(),
+		cacheHits:   newAccessCounter(),
+		operations:  newOperationTracker(),
+		repins:      newRepinTracker(),
+		connHistory: newConnectivityHistory(cfg.ConnectivityHistorySize),
+		rpcSkew:     make(map[peer.ID]protocol.ID),
+		events:      newEventQueues(cfg, publishers),
+		lifecycle:   newLifecycleLog(),
+		names:       newNamePublisher(cfg.NamePublishing),
+		startup:     newStartupWarmup(cfg.StartupWarmupRate),
+		denylist:    dl,
+		rpcPolicy:   cfg.RPCPolicy,
 		peerManager: peerManager,
 		shutdownB:   false,
 		removed:     false,
@@ -171,6 +244,16 @@ func NewCluster(
 		readyB:      false,
 	}
 
+	if ch, ok := host.(*clusterHost); ok {
+		c.bandwidth = ch.bandwidth
+		c.secrets = ch.secrets
+	}
+
+	c.gatewayWarmups = make(chan *gatewayWarmup, gatewayWarmupQueueSize)
+	if err := c.loadGatewayWarmups(ctx); err != nil {
+		logger.Warnf("error loading the gateway warm-up results: %s", err)
+	}
+
 	// Import known cluster peers from peerstore file and config. Set
 	// a non permanent TTL.
 	c.peerManager.ImportPeersFromPeerstore(false, peerstore.AddressTTL)
@@ -196,6 +279,9 @@ func NewCluster(
 		return nil, err
 	}
 	c.setupRPCClients()
+	for _, a := range c.apis {
+		c.emitLifecycleEvent(api.LifecycleEventAPIStarted, "", fmt.Sprintf("%T", a))
+	}
 
 	// Note: It is very important to first call Add() once in a non-racy
 	// place
@@ -206,6 +292,14 @@ func NewCluster(
 		c.run()
 	}()
 
+	if c.events.enabled() {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.sendEvents()
+		}()
+	}
+
 	return c, nil
 }
 
@@ -216,17 +310,23 @@ func (c *Cluster) setupRPC() error {
 	}
 	c.rpcServer = rpcServer
 
+	var h host.Host = c.host
+	if rule := c.config.FaultInjection.RPC; rule.enabled() {
+		logger.Warn("fault injection is enabled for RPC requests")
+		h = &faultyHost{Host: c.host, rule: rule}
+	}
+
 	var rpcClient *rpc.Client
 	if c.config.Tracing {
 		csh := &ocgorpc.ClientHandler{}
 		rpcClient = rpc.NewClientWithServer(
-			c.host,
+			h,
 			version.RPCProtocol,
 			rpcServer,
 			rpc.WithClientStatsHandler(csh),
 		)
 	} else {
-		rpcClient = rpc.NewClientWithServer(c.host, version.RPCProtocol, rpcServer)
+		rpcClient = rpc.NewClientWithServer(h, version.RPCProtocol, rpcServer)
 	}
 	c.rpcClient = rpcClient
 	return nil
@@ -244,6 +344,9 @@ func (c *Cluster) setupRPCClients() {
 	for _, informer := range c.informers {
 		informer.SetClient(c.rpcClient)
 	}
+	for _, pub := range c.publishers {
+		pub.SetClient(c.rpcClient)
+	}
 }
 
 // watchPinset triggers recurrent operations that loop on the pinset.
@@ -255,8 +358,20 @@ func (c *Cluster) watchPinset() {
 
 	// Upon start, every item in the state that is not pinned will appear
 	// as PinError when doing a Status, we should proceed to recover
-	// (try pinning) all of those right away.
-	recoverTimer := time.NewTimer(0) // 0 so that it does an initial recover right away
+	// (try pinning) all of those right away. This is done by the startup
+	// warm-up, which is throttled and can take long, so it runs on its
+	// own and does not delay StateSync.
+	warmedUp := make(chan struct{})
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer close(warmedUp)
+		c.warmUpTracker(ctx)
+		if ctx.Err() == nil {
+			c.emitLifecycleEvent(api.LifecycleEventTrackerWarmedUp, "", "")
+		}
+	}()
+	recoverTimer := time.NewTimer(c.config.PinRecoverInterval)
 
 	// This prevents doing an StateSync while doing a RecoverAllLocal,
 	// which is intended behaviour as for very large pinsets
@@ -267,8 +382,13 @@ func (c *Cluster) watchPinset() {
 			c.StateSync(ctx)
 			stateSyncTimer.Reset(c.config.StateSyncInterval)
 		case <-recoverTimer.C:
-			logger.Debug("auto-triggering RecoverAllLocal()")
-			c.RecoverAllLocal(ctx)
+			select {
+			case <-warmedUp:
+				logger.Debug("auto-triggering RecoverAllLocal()")
+				c.RecoverAllLocal(ctx)
+			default:
+				logger.Debug("skipping RecoverAllLocal(): the startup warm-up is running")
+			}
 			recoverTimer.Reset(c.config.PinRecoverInterval)
 		case <-c.ctx.Done():
 			if !stateSyncTimer.Stop() {
@@ -302,11 +422,12 @@ func (c *Cluster) sendInformerMetrics(ctx context.Context, informer Informer) (t
 			continue
 		}
 		metric.Peer = c.id
+		err := c.monitor.PublishMetric(ctx, metric)
+		// the monitor may have adjusted the ttl.
 		ttl := metric.GetTTL()
 		if ttl > 0 && (ttl < minTTL || minTTL == 0) {
 			minTTL = ttl
 		}
-		err := c.monitor.PublishMetric(ctx, metric)
 
 		if multierr.AppendInto(&errors, err) {
 			logger.Warnf("error sending metric %s: %s", metric.Name, err)
@@ -380,9 +501,11 @@ func (c *Cluster) sendPingMetric(ctx context.Context) (*api.Metric, error) {
 	defer span.End()
 
 	metric := &api.Metric{
-		Name:  pingMetricName,
-		Peer:  c.id,
-		Valid: true,
+		Name:     pingMetricName,
+		Peer:     c.id,
+		Valid:    true,
+		Labels:   c.config.PeerLabels,
+		Peername: c.config.Peername,
 	}
 	metric.SetTTL(c.config.MonitorPingInterval * 2)
 	return metric, c.monitor.PublishMetric(ctx, metric)
@@ -413,15 +536,21 @@ func (c *Cluster) pushPingMetrics(ctx context.Context) {
 	ctx, span := trace.StartSpan(ctx, "cluster/pushPingMetrics")
 	defer span.End()
 
-	ticker := time.NewTicker(c.config.MonitorPingInterval)
+	timer := time.NewTimer(0)
 	for {
-		c.sendPingMetric(ctx)
-
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
+		case <-timer.C:
+		}
+
+		interval := c.config.MonitorPingInterval
+		// the monitor may publish the metric with a longer ttl
+		// and interval.
+		if m, _ := c.sendPingMetric(ctx); m != nil && m.Interval > 0 {
+			interval = time.Duration(m.Interval)
 		}
+		timer.Reset(interval)
 	}
 }
 
@@ -453,49 +582,45 @@ func (c *Cluster) alertsHandler() {
 			if c.config.FollowerMode {
 				continue
 			}
+			c.setAlertLabels(alrt)
 
 			logger.Warnf("metric alert for %s: Peer: %s.", alrt.Name, alrt.Peer)
-			c.alertsMux.Lock()
-			{
-				if len(c.alerts) > maxAlerts {
-					c.alerts = c.alerts[:0]
-				}
-
-				c.alerts = append(c.alerts, *alrt)
-			}
-			c.alertsMux.Unlock()
+			c.recordAlert(alrt)
 
 			if alrt.Name != pingMetricName {
 				continue // only handle ping alerts
 			}
 
-			if c.config.DisableRepinning {
-				logger.Debugf("repinning is disabled. Will not re-allocate pins on alerts")
-				return
-			}
+			c.rememberPeername(alrt.Peer, alrt.Peername)
+			c.handlePingAlert(alrt.Peer)
+		}
+	}
+}
 
-			cState, err := c.consensus.State(c.ctx)
-			if err != nil {
-				logger.Warn(err)
-				return
-			}
-			list, err := cState.List(c.ctx)
-			if err != nil {
-				logger.Warn(err)
-				return
-			}
+// recordAlert keeps an alert to be listed by Alerts and notifies it.
+func (c *Cluster) recordAlert(alrt *api.Alert) {
+	c.alertsMux.Lock()
+	{
+		if len(c.alerts) > maxAlerts {
+			c.alerts = c.alerts[:0]
+		}
 
-			distance, err := c.distances(c.ctx, alrt.Peer)
-			if err != nil {
-				logger.Warn(err)
-				return
-			}
+		c.alerts = append(c.alerts, *alrt)
+	}
+	c.alertsMux.Unlock()
+	c.notifyAlert(alrt)
+}
 
-			for _, pin := range list {
-				if containsPeer(pin.Allocations, alrt.Peer) && distance.isClosest(pin.Cid) {
-					c.repinFromPeer(c.ctx, alrt.Peer, pin)
-				}
-			}
+// setAlertLabels sets the labels of the peer in alerts for metrics other
+// than ping, using its latest ping metric. Ping alerts carry them already.
+func (c *Cluster) setAlertLabels(alrt *api.Alert) {
+	if alrt.Labels != nil {
+		return
+	}
+	for _, m := range c.monitor.LatestMetrics(c.ctx, pingMetricName) {
+		if m.Peer == alrt.Peer {
+			alrt.Labels = m.Labels
+			return
 		}
 	}
 }
@@ -506,6 +631,7 @@ func (c *Cluster) watchPeers() {
 	ticker := time.NewTicker(c.config.PeerWatchInterval)
 	defer ticker.Stop()
 
+	var watcher peersetWatcher
 	for {
 		select {
 		case <-c.ctx.Done():
@@ -533,6 +659,8 @@ func (c *Cluster) watchPeers() {
 				go c.Shutdown(c.ctx)
 				return
 			}
+			c.checkPeerset(&watcher, peers)
+			c.checkRPCProtocols(peers)
 		}
 	}
 }
@@ -636,6 +764,78 @@ func (c *Cluster) run() {
 		defer c.wg.Done()
 		c.reBootstrap()
 	}()
+
+	if c.config.ConnectivitySnapshotInterval > 0 {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.watchConnectivity()
+		}()
+	}
+
+	if c.config.Popularity.Interval > 0 {
+		if path := c.config.GetPopularityAccessLogPath(); path != "" {
+			c.popularityLog = newAccessLogReader(path)
+		}
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.watchPopularity()
+		}()
+	}
+
+	if c.config.IPNSTracking.Interval > 0 {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.watchIPNSTracking()
+		}()
+	}
+
+	if c.config.ReadThroughCache.Interval > 0 {
+		if path := c.config.GetAccessLogPath(); path != "" {
+			c.accessLog = newAccessLogReader(path)
+		}
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.watchReadThroughCache()
+		}()
+	}
+
+	if len(c.config.GatewayWarmup.Gateways) > 0 {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.watchGatewayWarmups()
+		}()
+	}
+
+	if c.config.StateBackup.Interval > 0 {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.watchStateBackup()
+		}()
+	}
+
+	if c.config.Tiering.Interval > 0 {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.watchTiering()
+		}()
+	}
+
+	if c.config.Denylist.UnpinMatches && c.denylist.Len() > 0 {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			if _, err := c.DenylistEnforce(c.ctx); err != nil {
+				logger.Errorf("error unpinning denylisted content: %s", err)
+			}
+		}()
+	}
 }
 
 func (c *Cluster) ready(timeout time.Duration) {
@@ -696,6 +896,7 @@ This might be due to one or several causes:
 	c.readyB = true
 	c.shutdownLock.Unlock()
 	logger.Info("** IPFS Cluster is READY **")
+	c.emitLifecycleEvent(api.LifecycleEventConsensusReady, "", "")
 }
 
 // Ready returns a channel which signals when this peer is
@@ -716,7 +917,7 @@ func (c *Cluster) Ready() <-chan struct{} {
 func (c *Cluster) Shutdown(ctx context.Context) error {
 	_, span := trace.StartSpan(ctx, "cluster/Shutdown")
 	defer span.End()
-	ctx = trace.NewContext(c.ctx, span)
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
 
 	c.shutdownLock.Lock()
 	defer c.shutdownLock.Unlock()
@@ -811,6 +1012,13 @@ func (c *Cluster) Shutdown(ctx context.Context) error {
 	c.cancel()
 	c.wg.Wait()
 
+	// Publishers are stopped once no more events are being sent.
+	for _, pub := range c.publishers {
+		if err := pub.Shutdown(ctx); err != nil {
+			logger.Errorf("error stopping event publisher: %s", err)
+		}
+	}
+
 	c.shutdownB = true
 	close(c.doneCh)
 	return nil
@@ -826,7 +1034,7 @@ func (c *Cluster) Done() <-chan struct{} {
 func (c *Cluster) ID(ctx context.Context) *api.ID {
 	_, span := trace.StartSpan(ctx, "cluster/ID")
 	defer span.End()
-	ctx = trace.NewContext(c.ctx, span)
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
 
 	// ignore error since it is included in response object
 	ipfsID, err := c.ipfs.ID(ctx)
@@ -873,6 +1081,7 @@ func (c *Cluster) ID(ctx context.Context) *api.ID {
 		RPCProtocolVersion:    version.RPCProtocol,
 		IPFS:                  ipfsID,
 		Peername:              c.config.Peername,
+		Labels:                c.config.PeerLabels,
 	}
 	if err != nil {
 		id.Error = err.Error()
@@ -894,7 +1103,7 @@ func (c *Cluster) ID(ctx context.Context) *api.ID {
 func (c *Cluster) PeerAdd(ctx context.Context, pid peer.ID) (*api.ID, error) {
 	_, span := trace.StartSpan(ctx, "cluster/PeerAdd")
 	defer span.End()
-	ctx = trace.NewContext(c.ctx, span)
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
 
 	// starting 10 nodes on the same box for testing
 	// causes deadlock and a global lock here
@@ -929,7 +1138,7 @@ func (c *Cluster) PeerAdd(ctx context.Context, pid peer.ID) (*api.ID, error) {
 func (c *Cluster) PeerRemove(ctx context.Context, pid peer.ID) error {
 	_, span := trace.StartSpan(ctx, "cluster/PeerRemove")
 	defer span.End()
-	ctx = trace.NewContext(c.ctx, span)
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
 
 	// We need to repin before removing the peer, otherwise, it won't
 	// be able to submit the pins.
@@ -941,6 +1150,7 @@ func (c *Cluster) PeerRemove(ctx context.Context, pid peer.ID) error {
 		logger.Error(err)
 		return err
 	}
+	c.forgetPeername(pid)
 	logger.Info("Peer removed ", pid.Pretty())
 	return nil
 }
@@ -952,7 +1162,7 @@ func (c *Cluster) PeerRemove(ctx context.Context, pid peer.ID) error {
 func (c *Cluster) Join(ctx context.Context, addr ma.Multiaddr) error {
 	_, span := trace.StartSpan(ctx, "cluster/Join")
 	defer span.End()
-	ctx = trace.NewContext(c.ctx, span)
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
 
 	logger.Debugf("Join(%s)", addr)
 
@@ -1073,11 +1283,13 @@ func (c *Cluster) distances(ctx context.Context, exclude peer.ID) (*distanceChec
 // looping through all the items. It is triggered automatically on
 // StateSyncInterval. Currently it:
 //   * Sends unpin for expired items for which this peer is "closest"
-//     (skipped for follower peers)
+//     (skipped for follower peers). Protected pins do not expire.
+//   * Unpins items in the trash whose grace period has passed, with the
+//     same conditions.
 func (c *Cluster) StateSync(ctx context.Context) error {
 	_, span := trace.StartSpan(ctx, "cluster/StateSync")
 	defer span.End()
-	ctx = trace.NewContext(c.ctx, span)
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
 
 	if c.config.FollowerMode {
 		return nil
@@ -1089,7 +1301,16 @@ func (c *Cluster) StateSync(ctx context.Context) error {
 	}
 
 	timeNow := time.Now()
-	clusterPins, err := cState.List(ctx)
+	var clusterPins, trashedPins []*api.Pin
+	if il, ok := cState.(state.IndexedLister); ok {
+		clusterPins, err = il.ListExpired(ctx, timeNow)
+		if err == nil {
+			trashedPins, err = il.ListByMetadata(ctx, api.TrashedAtMetaKey, "")
+		}
+	} else {
+		clusterPins, err = cState.List(ctx)
+		trashedPins = clusterPins
+	}
 	if err != nil {
 		return err
 	}
@@ -1106,6 +1327,13 @@ func (c *Cluster) StateSync(ctx context.Context) error {
 	// Unpin expired items when we are the closest peer to them.
 	for _, p := range clusterPins {
 		if p.ExpiredAt(timeNow) && distance.isClosest(p.Cid) {
+			if _, trashed := p.TrashedAt(); trashed {
+				continue // already unpinned
+			}
+			if c.checkUnprotected(p) != nil {
+				logger.Debugf("not unpinning %s: pin expired at %s but is protected", p.Cid, p.ExpireAt)
+				continue
+			}
 			logger.Infof("Unpinning %s: pin expired at %s", p.Cid, p.ExpireAt)
 			if _, err := c.Unpin(ctx, p.Cid); err != nil {
 				logger.Error(err)
@@ -1113,6 +1341,8 @@ func (c *Cluster) StateSync(ctx context.Context) error {
 		}
 	}
 
+	c.emptyTrash(ctx, trashedPins, distance, timeNow)
+	c.publishNames(ctx, cState, distance)
 	return nil
 }
 
@@ -1122,16 +1352,16 @@ func (c *Cluster) StateSync(ctx context.Context) error {
 func (c *Cluster) StatusAll(ctx context.Context, filter api.TrackerStatus) ([]*api.GlobalPinInfo, error) {
 	_, span := trace.StartSpan(ctx, "cluster/StatusAll")
 	defer span.End()
-	ctx = trace.NewContext(c.ctx, span)
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
 
-	return c.globalPinInfoSlice(ctx, "PinTracker", "StatusAll", filter)
+	return c.globalPinInfoSlice(ctx, nil, "PinTracker", "StatusAll", filter)
 }
 
 // StatusAllLocal returns the PinInfo for all the tracked Cids in this peer.
 func (c *Cluster) StatusAllLocal(ctx context.Context, filter api.TrackerStatus) []*api.PinInfo {
 	_, span := trace.StartSpan(ctx, "cluster/StatusAllLocal")
 	defer span.End()
-	ctx = trace.NewContext(c.ctx, span)
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
 
 	return c.tracker.StatusAll(ctx, filter)
 }
@@ -1142,7 +1372,7 @@ func (c *Cluster) StatusAllLocal(ctx context.Context, filter api.TrackerStatus)
 func (c *Cluster) Status(ctx context.Context, h cid.Cid) (*api.GlobalPinInfo, error) {
 	_, span := trace.StartSpan(ctx, "cluster/Status")
 	defer span.End()
-	ctx = trace.NewContext(c.ctx, span)
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
 
 	return c.globalPinInfoCid(ctx, "PinTracker", "Status", h)
 }
@@ -1151,11 +1381,66 @@ func (c *Cluster) Status(ctx context.Context, h cid.Cid) (*api.GlobalPinInfo, er
 func (c *Cluster) StatusLocal(ctx context.Context, h cid.Cid) *api.PinInfo {
 	_, span := trace.StartSpan(ctx, "cluster/StatusLocal")
 	defer span.End()
-	ctx = trace.NewContext(c.ctx, span)
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
 
 	return c.tracker.Status(ctx, h)
 }
 
+// StatusCids returns the GlobalPinInfo of the given Cids, in the same
+// order, as fetched from all current peers with a single request to each
+// of them.
+func (c *Cluster) StatusCids(ctx context.Context, cids []cid.Cid) ([]*api.GlobalPinInfo, error) {
+	_, span := trace.StartSpan(ctx, "cluster/StatusCids")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	return c.globalPinInfoCids(ctx, "PinTracker", "StatusCids", cids)
+}
+
+// StatusCidsLocal returns this peer's PinInfo for the given Cids, in the
+// same order.
+func (c *Cluster) StatusCidsLocal(ctx context.Context, cids []cid.Cid) []*api.PinInfo {
+	_, span := trace.StartSpan(ctx, "cluster/StatusCidsLocal")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	return trackerStatusCids(ctx, c.tracker, cids)
+}
+
+// trackerStatusCids returns the local status of the given Cids, in the same
+// order, listing the IPFS pins once when the tracker supports it.
+func trackerStatusCids(ctx context.Context, tracker PinTracker, cids []cid.Cid) []*api.PinInfo {
+	if bt, ok := tracker.(BatchStatusTracker); ok {
+		return bt.StatusCids(ctx, cids)
+	}
+	pinfos := make([]*api.PinInfo, len(cids))
+	done := make(map[cid.Cid]*api.PinInfo, len(cids))
+	for i, h := range cids {
+		pi, ok := done[h]
+		if !ok {
+			pi = tracker.Status(ctx, h)
+			done[h] = pi
+		}
+		pinfos[i] = pi
+	}
+	return pinfos
+}
+
+// uniqueCids returns the given Cids without duplicates, keeping the first
+// occurrence of each.
+func uniqueCids(cids []cid.Cid) []cid.Cid {
+	seen := make(map[cid.Cid]struct{}, len(cids))
+	unique := make([]cid.Cid, 0, len(cids))
+	for _, h := range cids {
+		if _, ok := seen[h]; ok {
+			continue
+		}
+		seen[h] = struct{}{}
+		unique = append(unique, h)
+	}
+	return unique
+}
+
 // used for RecoverLocal and SyncLocal.
 func (c *Cluster) localPinInfoOp(
 	ctx context.Context,
@@ -1186,9 +1471,12 @@ func (c *Cluster) localPinInfoOp(
 func (c *Cluster) RecoverAll(ctx context.Context) ([]*api.GlobalPinInfo, error) {
 	_, span := trace.StartSpan(ctx, "cluster/RecoverAll")
 	defer span.End()
-	ctx = trace.NewContext(c.ctx, span)
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
 
-	return c.globalPinInfoSlice(ctx, "Cluster", "RecoverAllLocal", nil)
+	op := c.operations.start(ctx, api.OperationRecoverAll, 0)
+	infos, err := c.globalPinInfoSlice(op.ctx, op, "Cluster", "RecoverAllLocal", nil)
+	op.finish(err)
+	return infos, err
 }
 
 // RecoverAllLocal triggers a RecoverLocal operation for all Cids tracked
@@ -1204,7 +1492,7 @@ func (c *Cluster) RecoverAll(ctx context.Context) ([]*api.GlobalPinInfo, error)
 func (c *Cluster) RecoverAllLocal(ctx context.Context) ([]*api.PinInfo, error) {
 	_, span := trace.StartSpan(ctx, "cluster/RecoverAllLocal")
 	defer span.End()
-	ctx = trace.NewContext(c.ctx, span)
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
 
 	return c.tracker.RecoverAll(ctx)
 }
@@ -1218,7 +1506,7 @@ func (c *Cluster) RecoverAllLocal(ctx context.Context) ([]*api.PinInfo, error) {
 func (c *Cluster) Recover(ctx context.Context, h cid.Cid) (*api.GlobalPinInfo, error) {
 	_, span := trace.StartSpan(ctx, "cluster/Recover")
 	defer span.End()
-	ctx = trace.NewContext(c.ctx, span)
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
 
 	return c.globalPinInfoCid(ctx, "PinTracker", "Recover", h)
 }
@@ -1232,11 +1520,61 @@ func (c *Cluster) Recover(ctx context.Context, h cid.Cid) (*api.GlobalPinInfo, e
 func (c *Cluster) RecoverLocal(ctx context.Context, h cid.Cid) (pInfo *api.PinInfo, err error) {
 	_, span := trace.StartSpan(ctx, "cluster/RecoverLocal")
 	defer span.End()
-	ctx = trace.NewContext(c.ctx, span)
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
 
 	return c.localPinInfoOp(ctx, h, c.tracker.Recover)
 }
 
+// Shards returns information about the shards of a sharded pin, including
+// their status on all current peers. The given Cid must correspond to a
+// MetaType or a ShardType pin.
+func (c *Cluster) Shards(ctx context.Context, h cid.Cid) ([]*api.ShardInfo, error) {
+	_, span := trace.StartSpan(ctx, "cluster/Shards")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	pin, err := c.PinGet(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+
+	var cids []cid.Cid
+	switch pin.Type {
+	case api.MetaType:
+		cids, err = c.cidsFromMetaPin(ctx, h)
+		if err != nil {
+			return nil, err
+		}
+	case api.ShardType:
+		cids = []cid.Cid{h}
+	default:
+		return nil, errors.New("not a sharded pin")
+	}
+
+	var shards []*api.ShardInfo
+	for _, ci := range cids {
+		shardPin, err := c.PinGet(ctx, ci)
+		if err != nil {
+			return nil, fmt.Errorf("error getting shard %s: %w", ci, err)
+		}
+		if shardPin.Type != api.ShardType {
+			continue
+		}
+		status, err := c.Status(ctx, ci)
+		if err != nil {
+			return nil, err
+		}
+		shards = append(shards, &api.ShardInfo{
+			Cid:         ci,
+			Name:        shardPin.Name,
+			Size:        shardPin.ShardSize,
+			Allocations: shardPin.Allocations,
+			Status:      status,
+		})
+	}
+	return shards, nil
+}
+
 // Pins returns the list of Cids managed by Cluster and which are part
 // of the current global state. This is the source of truth as to which
 // pins are managed and their allocation, but does not indicate if
@@ -1244,14 +1582,18 @@ func (c *Cluster) RecoverLocal(ctx context.Context, h cid.Cid) (pInfo *api.PinIn
 func (c *Cluster) Pins(ctx context.Context) ([]*api.Pin, error) {
 	_, span := trace.StartSpan(ctx, "cluster/Pins")
 	defer span.End()
-	ctx = trace.NewContext(c.ctx, span)
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
 
 	cState, err := c.consensus.State(ctx)
 	if err != nil {
 		logger.Error(err)
 		return nil, err
 	}
-	return cState.List(ctx)
+	pins, err := cState.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return withoutPinTemplates(pins), nil
 }
 
 // PinGet returns information for a single Cid managed by Cluster.
@@ -1263,7 +1605,7 @@ func (c *Cluster) Pins(ctx context.Context) ([]*api.Pin, error) {
 func (c *Cluster) PinGet(ctx context.Context, h cid.Cid) (*api.Pin, error) {
 	_, span := trace.StartSpan(ctx, "cluster/PinGet")
 	defer span.End()
-	ctx = trace.NewContext(c.ctx, span)
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
 
 	st, err := c.consensus.State(ctx)
 	if err != nil {
@@ -1301,11 +1643,46 @@ func (c *Cluster) Pin(ctx context.Context, h cid.Cid, opts api.PinOptions) (*api
 	_, span := trace.StartSpan(ctx, "cluster/Pin")
 	defer span.End()
 
-	ctx = trace.NewContext(c.ctx, span)
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
 	pin := api.PinWithOpts(h, opts)
 
+	if err := c.validatePin(ctx, pin); err != nil {
+		return nil, err
+	}
+
+	warm := c.wantsGatewayWarmup(ctx, pin)
 	result, _, err := c.pin(ctx, pin, []peer.ID{})
-	return result, err
+	if err != nil {
+		return result, err
+	}
+	if warm {
+		c.warmGateways(ctx, result)
+	}
+	return result, nil
+}
+
+// PinDryRun runs the same validation and allocation steps as Pin, but does
+// not commit the pin. It returns the pin that would be submitted to the
+// consensus layer, with the peers that would be allocated to it.
+func (c *Cluster) PinDryRun(ctx context.Context, h cid.Cid, opts api.PinOptions) (*api.Pin, error) {
+	_, span := trace.StartSpan(ctx, "cluster/PinDryRun")
+	defer span.End()
+
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+	pin := api.PinWithOpts(h, opts)
+
+	if c.config.FollowerMode {
+		return nil, errFollowerMode
+	}
+
+	if err := c.validatePin(ctx, pin); err != nil {
+		return nil, err
+	}
+
+	if update := pin.PinUpdate; update != cid.Undef && !update.Equals(pin.Cid) {
+		return c.planPinUpdate(ctx, update, pin.Cid, pin.PinOptions)
+	}
+	return c.planPin(ctx, pin, nil)
 }
 
 // sets the default replication factor in a pin when it's set to 0
@@ -1341,10 +1718,10 @@ func checkPinType(pin *api.Pin) error {
 			return errors.New("data pins should not reference other pins")
 		}
 	case api.ShardType:
-		if pin.MaxDepth != 1 {
-			return errors.New("must pin shards go depth 1")
+		// Shards with an indirect node are pinned to depth 2.
+		if pin.MaxDepth != 1 && pin.MaxDepth != 2 {
+			return errors.New("must pin shards go depth 1 or 2")
 		}
-		// FIXME: indirect shard pins could have max-depth 2
 		// FIXME: repinning a shard type will overwrite replication
 		//        factor from previous:
 		// if existing.ReplicationFactorMin != rplMin ||
@@ -1388,14 +1765,35 @@ func (c *Cluster) setupPin(ctx context.Context, pin, existing *api.Pin) error {
 		return err
 	}
 
+	err = c.setupExcludedAllocations(pin)
+	if err != nil {
+		return err
+	}
+
+	c.setupTier(pin, existing)
+
 	if !pin.ExpireAt.IsZero() && pin.ExpireAt.Before(time.Now()) {
 		return errors.New("pin.ExpireAt set before current time")
 	}
 
+	if !pin.ExpireAt.IsZero() && !pin.ScheduleAt.IsZero() && !pin.ScheduleAt.Before(pin.ExpireAt) {
+		return errors.New("pin.ScheduleAt must be before pin.ExpireAt")
+	}
+
+	if _, _, _, err := pin.Timeouts(); err != nil {
+		return err
+	}
+
 	if existing == nil {
 		return nil
 	}
 
+	// Re-pinning does not clear the protection of a pin. That needs
+	// Unprotect.
+	if existing.Protected {
+		pin.Protected = true
+	}
+
 	// If an pin CID is already pin, we do a couple more checks
 	if existing.Type != pin.Type {
 		msg := "cannot repin CID with different tracking method, "
@@ -1435,24 +1833,68 @@ func (c *Cluster) pin(
 		return pin, false, errors.New("bad pin object")
 	}
 
+	ack, err := takePinAck(pin)
+	if err != nil {
+		return pin, false, err
+	}
+
 	// Handle pin updates when the option is set
 	if update := pin.PinUpdate; update != cid.Undef && !update.Equals(pin.Cid) {
 		pin, err := c.PinUpdate(ctx, update, pin.Cid, pin.PinOptions)
+		if err == nil && ack.level == api.AckReplicated {
+			err = c.waitForReplicas(ctx, pin, ack.peers)
+		}
 		return pin, true, err
 	}
 
+	pin, err = c.planPin(ctx, pin, blacklist)
+	if err != nil {
+		return pin, false, err
+	}
+	if pin.Type == api.MetaType {
+		return pin, true, c.logPinAck(ctx, pin, ack)
+	}
+
+	// If this is true, replication factor should be -1.
+	if len(pin.Allocations) == 0 {
+		api.RequestLogger(ctx, logger).Infof("pinning %s everywhere:", pin.Cid)
+	} else {
+		api.RequestLogger(ctx, logger).Infof("pinning %s on %s:", pin.Cid, pin.Allocations)
+	}
+
+	return pin, true, c.logPinAck(ctx, pin, ack)
+}
+
+// planPin validates a pin and sets its replication factors and allocations,
+// but does not commit it. It returns the pin as it would be submitted to
+// the consensus layer.
+func (c *Cluster) planPin(ctx context.Context, pin *api.Pin, blacklist []peer.ID) (*api.Pin, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/planPin")
+	defer span.End()
+
 	existing, err := c.PinGet(ctx, pin.Cid)
 	if err != nil && err != state.ErrNotFound {
-		return pin, false, err
+		return pin, err
+	}
+
+	err = c.checkPinName(ctx, pin)
+	if err != nil {
+		return pin, err
 	}
 
 	// setup pin might produce some side-effects to our pin
 	err = c.setupPin(ctx, pin, existing)
 	if err != nil {
-		return pin, false, err
+		return pin, err
 	}
 	if pin.Type == api.MetaType {
-		return pin, true, c.consensus.LogPin(ctx, pin)
+		return pin, nil
+	}
+
+	if existing == nil && pin.Type == api.DataType {
+		if err := c.checkFreeSpace(ctx, pin); err != nil {
+			return pin, err
+		}
 	}
 
 	// We did not change ANY options and the pin exists so we just repin
@@ -1462,6 +1904,7 @@ func (c *Cluster) pin(
 	// without having to use recover, which is naturally expected.
 	if existing != nil &&
 		pin.PinOptions.Equals(&existing.PinOptions) &&
+		!isRestore(pin, existing) &&
 		len(blacklist) == 0 {
 		pin = existing
 	}
@@ -1473,6 +1916,13 @@ func (c *Cluster) pin(
 	// allocate() will check which peers are currently allocated
 	// and try to respect them.
 	if len(pin.Allocations) == 0 {
+		// Peers without space for the pin are not allocated.
+		c.resolveExpectedSize(ctx, pin)
+		tierExcluded := c.tierExcludedPeers(ctx, pin)
+		excluded := append(c.lowSpacePeers(ctx, pin.ExpectedSize), blacklist...)
+		excluded = append(excluded, pin.ExcludeAllocations...)
+		excluded = append(excluded, tierExcluded...)
+
 		// If replication factor is -1, this will return empty
 		// allocations.
 		allocs, err := c.allocate(
@@ -1481,23 +1931,25 @@ func (c *Cluster) pin(
 			existing,
 			pin.ReplicationFactorMin,
 			pin.ReplicationFactorMax,
-			blacklist,
+			excluded,
 			pin.UserAllocations,
 		)
 		if err != nil {
-			return pin, false, err
+			return pin, err
+		}
+		// allocate() keeps the current allocations when no new
+		// ones are needed. Peers which have been excluded since
+		// must not stay among them.
+		if len(allocs) > 0 && len(pin.ExcludeAllocations) > 0 {
+			allocs = peersSubtract(allocs, pin.ExcludeAllocations)
 		}
+		// Pins moving to another tier stay on their old peers until
+		// the new ones have pinned them (see applyTiering).
+		dropped := append(append([]peer.ID{}, blacklist...), pin.ExcludeAllocations...)
+		allocs = tierAllocations(allocs, existing, tierExcluded, dropped)
 		pin.Allocations = allocs
 	}
-
-	// If this is true, replication factor should be -1.
-	if len(pin.Allocations) == 0 {
-		logger.Infof("pinning %s everywhere:", pin.Cid)
-	} else {
-		logger.Infof("pinning %s on %s:", pin.Cid, pin.Allocations)
-	}
-
-	return pin, true, c.consensus.LogPin(ctx, pin)
+	return pin, nil
 }
 
 // Unpin removes a previously pinned Cid from Cluster. It returns
@@ -1506,24 +1958,38 @@ func (c *Cluster) pin(
 //
 // Unpin does not reflect the success or failure of underlying IPFS daemon
 // unpinning operations, which happen in async fashion.
+//
+// When UnpinGracePeriod is set, the pin is moved to the trash and only
+// unpinned once the grace period passes. Until then it can be restored.
 func (c *Cluster) Unpin(ctx context.Context, h cid.Cid) (*api.Pin, error) {
 	_, span := trace.StartSpan(ctx, "cluster/Unpin")
 	defer span.End()
-	ctx = trace.NewContext(c.ctx, span)
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
 
 	if c.config.FollowerMode {
 		return nil, errFollowerMode
 	}
 
-	logger.Info("IPFS cluster unpinning:", h)
+	api.RequestLogger(ctx, logger).Info("IPFS cluster unpinning:", h)
 	pin, err := c.PinGet(ctx, h)
 	if err != nil {
 		return nil, err
 	}
+	if err := c.checkUnprotected(pin); err != nil {
+		return pin, err
+	}
 
+	if c.config.UnpinGracePeriod > 0 && (pin.Type == api.DataType || pin.Type == api.MetaType) {
+		return c.trash(ctx, pin)
+	}
+	return c.unpin(ctx, pin)
+}
+
+// unpin removes a pin from the shared state.
+func (c *Cluster) unpin(ctx context.Context, pin *api.Pin) (*api.Pin, error) {
 	switch pin.Type {
 	case api.DataType:
-		return pin, c.consensus.LogUnpin(ctx, pin)
+		return pin, c.logUnpin(ctx, pin)
 	case api.ShardType:
 		err := "cannot unpin a shard directly. Unpin content root CID instead"
 		return pin, errors.New(err)
@@ -1533,7 +1999,7 @@ func (c *Cluster) Unpin(ctx context.Context, h cid.Cid) (*api.Pin, error) {
 		if err != nil {
 			return pin, err
 		}
-		return pin, c.consensus.LogUnpin(ctx, pin)
+		return pin, c.logUnpin(ctx, pin)
 	case api.ClusterDAGType:
 		err := "cannot unpin a Cluster DAG directly. Unpin content root CID instead"
 		return pin, errors.New(err)
@@ -1542,6 +2008,52 @@ func (c *Cluster) Unpin(ctx context.Context, h cid.Cid) (*api.Pin, error) {
 	}
 }
 
+// Unprotect clears the protection of a pin, so that it can be unpinned
+// and expire again. It returns the updated pin. Pins listed in the
+// protected_cids configuration cannot be unprotected.
+func (c *Cluster) Unprotect(ctx context.Context, h cid.Cid) (*api.Pin, error) {
+	_, span := trace.StartSpan(ctx, "cluster/Unprotect")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	if c.config.FollowerMode {
+		return nil, errFollowerMode
+	}
+
+	pin, err := c.PinGet(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	if c.protectedByConfig(h) {
+		return pin, fmt.Errorf("%w: %s is listed in protected_cids", api.ErrPinProtected, h)
+	}
+	if !pin.Protected {
+		return pin, nil
+	}
+
+	api.RequestLogger(ctx, logger).Info("removing protection of ", h)
+	pin.Protected = false
+	return pin, c.logPin(ctx, pin)
+}
+
+// checkUnprotected returns an error wrapping api.ErrPinProtected when the
+// pin cannot be unpinned.
+func (c *Cluster) checkUnprotected(pin *api.Pin) error {
+	if pin.Protected || c.protectedByConfig(pin.Cid) {
+		return fmt.Errorf("%w: %s", api.ErrPinProtected, pin.Cid)
+	}
+	return nil
+}
+
+func (c *Cluster) protectedByConfig(h cid.Cid) bool {
+	for _, ci := range c.config.ProtectedCIDs {
+		if ci.Equals(h) {
+			return true
+		}
+	}
+	return false
+}
+
 // unpinClusterDag unpins the clusterDAG metadata node and the shard metadata
 // nodes that it references.  It handles the case where multiple parents
 // reference the same metadata node, only unpinning those nodes without
@@ -1577,6 +2089,20 @@ func (c *Cluster) unpinClusterDag(metaPin *api.Pin) error {
 // significant speed when pinning items which are similar to previously pinned
 // content.
 func (c *Cluster) PinUpdate(ctx context.Context, from cid.Cid, to cid.Cid, opts api.PinOptions) (*api.Pin, error) {
+	updated, err := c.planPinUpdate(ctx, from, to, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = c.logPin(ctx, updated)
+	if err == nil && c.config.PinUpdateUnpin.Enabled {
+		c.expireReplacedVersions(ctx, updated)
+	}
+	return updated, err
+}
+
+// planPinUpdate returns the pin that PinUpdate would submit, which reuses
+// the options and allocations of the existing pin.
+func (c *Cluster) planPinUpdate(ctx context.Context, from cid.Cid, to cid.Cid, opts api.PinOptions) (*api.Pin, error) {
 	existing, err := c.PinGet(ctx, from)
 	if err != nil { // including when the existing pin is not found
 		return nil, err
@@ -1597,7 +2123,151 @@ func (c *Cluster) PinUpdate(ctx context.Context, from cid.Cid, to cid.Cid, opts
 	if !opts.ExpireAt.IsZero() && opts.ExpireAt.After(time.Now()) {
 		existing.ExpireAt = opts.ExpireAt
 	}
-	return existing, c.consensus.LogPin(ctx, existing)
+	if opts.Protected {
+		existing.Protected = true
+	}
+	if c.config.PinUpdateUnpin.Enabled {
+		existing = c.setPinUpdateChain(existing, from, pinUpdateChain(existing))
+	}
+	err = c.checkPinName(ctx, existing)
+	if err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+// checkPinName returns an error when unique pin names are enforced and the
+// name of the pin is used by a different pin, other than the one being
+// updated.
+func (c *Cluster) checkPinName(ctx context.Context, pin *api.Pin) error {
+	if !c.config.UniquePinNames || pin.Name == "" {
+		return nil
+	}
+
+	pins, err := c.PinsByName(ctx, pin.Name, false)
+	if err != nil {
+		return err
+	}
+	for _, p := range pins {
+		if p.Cid.Equals(pin.Cid) || p.Cid.Equals(pin.PinUpdate) {
+			continue
+		}
+		return fmt.Errorf("%w: %q is used by %s", api.ErrDuplicatePinName, pin.Name, p.Cid)
+	}
+	return nil
+}
+
+// PinChanges returns the changes to the pinset after the given sequence
+// number, along with the current sequence number, which can be used to ask
+// for the next changes. Sequence numbers are local to this peer. A since of
+// 0 returns just the current sequence number, which should be obtained
+// before listing the full pinset. When the changes are no longer known, it
+// returns api.ErrChangesUnavailable and the pinset should be listed again.
+func (c *Cluster) PinChanges(ctx context.Context, since uint64) (*api.PinChanges, error) {
+	_, span := trace.StartSpan(ctx, "cluster/PinChanges")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	cl, ok := cState.(state.ChangeLister)
+	if !ok {
+		return nil, api.ErrChangesUnavailable
+	}
+
+	changes, seq, err := cl.Changes(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ch := range changes {
+		if ch.Removed {
+			continue
+		}
+		pin, err := cState.Get(ctx, ch.Cid)
+		switch {
+		case err == state.ErrNotFound: // not committed or removed since
+			ch.Removed = true
+		case err != nil:
+			return nil, err
+		default:
+			ch.Pin = pin
+		}
+	}
+
+	return &api.PinChanges{
+		Sequence: seq,
+		Changes:  changes,
+	}, nil
+}
+
+// PinsByType returns the pins in the pinset of the types included in the
+// given filter.
+func (c *Cluster) PinsByType(ctx context.Context, filter api.PinType) ([]*api.Pin, error) {
+	_, span := trace.StartSpan(ctx, "cluster/PinsByType")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	if il, ok := cState.(state.IndexedLister); ok {
+		pins, err := il.ListByType(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		return withoutPinTemplates(pins), nil
+	}
+
+	pins, err := cState.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	found := make([]*api.Pin, 0)
+	for _, p := range pins {
+		if p.Type&filter > 0 && !isPinTemplate(p) {
+			found = append(found, p)
+		}
+	}
+	return found, nil
+}
+
+// PinsByName returns the pins in the pinset with the given name or, when
+// prefix is true, with names starting with the given string. When possible,
+// it uses an index of names instead of listing the full pinset.
+func (c *Cluster) PinsByName(ctx context.Context, name string, prefix bool) ([]*api.Pin, error) {
+	_, span := trace.StartSpan(ctx, "cluster/PinsByName")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	if nl, ok := cState.(state.NameLister); ok {
+		return nl.ListByName(ctx, name, prefix)
+	}
+
+	pins, err := cState.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	found := make([]*api.Pin, 0)
+	for _, p := range pins {
+		if p.Name == name || (prefix && strings.HasPrefix(p.Name, name)) {
+			found = append(found, p)
+		}
+	}
+	return found, nil
 }
 
 // PinPath pins an CID resolved from its IPFS Path. It returns the resolved
@@ -1606,11 +2276,18 @@ func (c *Cluster) PinPath(ctx context.Context, path string, opts api.PinOptions)
 	_, span := trace.StartSpan(ctx, "cluster/PinPath")
 	defer span.End()
 
-	ctx = trace.NewContext(c.ctx, span)
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+	if err := c.checkDenylist(path); err != nil {
+		return nil, err
+	}
 	ci, err := c.ipfs.Resolve(ctx, path)
 	if err != nil {
 		return nil, err
 	}
+	opts, err = c.setupIPNSTracking(path, ci, opts)
+	if err != nil {
+		return nil, err
+	}
 
 	return c.Pin(ctx, ci, opts)
 }
@@ -1621,7 +2298,7 @@ func (c *Cluster) UnpinPath(ctx context.Context, path string) (*api.Pin, error)
 	_, span := trace.StartSpan(ctx, "cluster/UnpinPath")
 	defer span.End()
 
-	ctx = trace.NewContext(c.ctx, span)
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
 	ci, err := c.ipfs.Resolve(ctx, path)
 	if err != nil {
 		return nil, err
@@ -1640,11 +2317,156 @@ func (c *Cluster) AddFile(reader *multipart.Reader, params *api.AddParams) (cid.
 	var dags adder.ClusterDAGService
 	if params.Shard {
 		dags = sharding.New(c.rpcClient, params.PinOptions, nil)
+	} else if params.ErasureDataShards > 0 {
+		ecdags, err := erasure.New(c.rpcClient, params.PinOptions, params.ErasureDataShards, params.ErasureParityShards, nil)
+		if err != nil {
+			return cid.Undef, err
+		}
+		dags = ecdags
 	} else {
 		dags = single.New(c.rpcClient, params.PinOptions, params.Local)
 	}
 	add := adder.New(dags, params, nil)
-	return add.FromMultipart(c.ctx, reader)
+	add.SetBlockChecker(func(ctx context.Context, ci cid.Cid) error {
+		return c.checkDenylist(ci.String())
+	})
+
+	op := c.operations.start(c.ctx, api.OperationAdd, 0)
+	ci, err := add.FromMultipart(op.ctx, reader)
+	op.finish(err)
+	return ci, err
+}
+
+// RPCPolicy returns the RPC policy in use by this peer, mapping every RPC
+// method to its endpoint type ("closed", "trusted" or "open").
+func (c *Cluster) RPCPolicy(ctx context.Context) map[string]string {
+	_, span := trace.StartSpan(ctx, "cluster/RPCPolicy")
+	defer span.End()
+
+	c.rpcPolicyMux.RLock()
+	defer c.rpcPolicyMux.RUnlock()
+
+	policy := make(map[string]string, len(c.rpcPolicy))
+	for method, t := range c.rpcPolicy {
+		policy[method] = t.String()
+	}
+	return policy
+}
+
+// SetRPCPolicy changes the endpoint type of the given RPC methods in the
+// policy used by this peer to authorize RPC requests from other peers. The
+// changes take effect immediately but are not saved to the configuration.
+// It returns the resulting policy.
+func (c *Cluster) SetRPCPolicy(ctx context.Context, changes map[string]string) (map[string]string, error) {
+	_, span := trace.StartSpan(ctx, "cluster/SetRPCPolicy")
+	defer span.End()
+
+	c.rpcPolicyMux.Lock()
+	policy, err := applyRPCPolicyOverrides(c.rpcPolicy, changes)
+	if err == nil {
+		c.rpcPolicy = policy
+	}
+	c.rpcPolicyMux.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	for method, t := range changes {
+		logger.Infof("RPC policy: %s is now %s", method, t)
+	}
+	return c.RPCPolicy(ctx), nil
+}
+
+// APIListeners returns the settings of the HTTP listeners of the APIs of
+// this peer which can be changed at runtime.
+func (c *Cluster) APIListeners(ctx context.Context) []*api.APIListeners {
+	_, span := trace.StartSpan(ctx, "cluster/APIListeners")
+	defer span.End()
+
+	listeners := make([]*api.APIListeners, 0, len(c.apis))
+	for _, a := range c.apis {
+		if ra, ok := a.(RebindableAPI); ok {
+			listeners = append(listeners, ra.Listeners())
+		}
+	}
+	return listeners
+}
+
+// RebindAPI changes the listen addresses, TLS certificate and CORS options of
+// the API of this peer named by the given settings, without restarting it.
+// Listeners which change are opened before the old ones are closed, and
+// requests in progress are not interrupted. The settings are saved to the
+// configuration. It returns the resulting settings.
+func (c *Cluster) RebindAPI(ctx context.Context, settings *api.APIListeners) (*api.APIListeners, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/RebindAPI")
+	defer span.End()
+
+	for _, a := range c.apis {
+		ra, ok := a.(RebindableAPI)
+		if !ok || ra.Listeners().API != settings.API {
+			continue
+		}
+		if err := ra.Rebind(ctx, settings); err != nil {
+			return nil, err
+		}
+		logger.Infof("%s: listening on %s", settings.API, strings.Join(settings.HTTPListenMultiaddress, ", "))
+		return ra.Listeners(), nil
+	}
+	return nil, fmt.Errorf("%q is not an API whose listeners can be changed", settings.API)
+}
+
+// Operations returns the long-running operations (like RecoverAll or RepoGC)
+// started in this peer, both running and recently finished.
+func (c *Cluster) Operations(ctx context.Context) []*api.Operation {
+	_, span := trace.StartSpan(ctx, "cluster/Operations")
+	defer span.End()
+
+	return c.operations.list()
+}
+
+// Operation returns the operation with the given ID.
+func (c *Cluster) Operation(ctx context.Context, id string) (*api.Operation, error) {
+	_, span := trace.StartSpan(ctx, "cluster/Operation")
+	defer span.End()
+
+	return c.operations.get(id)
+}
+
+// StartOperation registers a new running operation of the given type on
+// behalf of another component (i.e. an API doing an add) and returns it.
+// FinishOperation must be called when it is done.
+func (c *Cluster) StartOperation(ctx context.Context, typ api.OperationType) *api.Operation {
+	_, span := trace.StartSpan(ctx, "cluster/StartOperation")
+	defer span.End()
+
+	return c.operations.start(c.ctx, typ, 0).info()
+}
+
+// FinishOperation finishes an operation registered with StartOperation,
+// recording its resulting CID and error, if any.
+func (c *Cluster) FinishOperation(ctx context.Context, op *api.Operation) error {
+	_, span := trace.StartSpan(ctx, "cluster/FinishOperation")
+	defer span.End()
+
+	var err error
+	if op.Error != "" {
+		err = errors.New(op.Error)
+	}
+	return c.operations.finishOp(op.ID, op.Cid, err)
+}
+
+// CancelOperation cancels the running operation with the given ID and
+// returns it. Work already done by the operation is not undone.
+func (c *Cluster) CancelOperation(ctx context.Context, id string) (*api.Operation, error) {
+	_, span := trace.StartSpan(ctx, "cluster/CancelOperation")
+	defer span.End()
+
+	op, err := c.operations.cancelOp(id)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("canceled operation %s (%s)", op.ID, op.Type)
+	return op, nil
 }
 
 // Version returns the current IPFS Cluster version.
@@ -1656,7 +2478,7 @@ func (c *Cluster) Version() string {
 func (c *Cluster) Peers(ctx context.Context) []*api.ID {
 	_, span := trace.StartSpan(ctx, "cluster/Peers")
 	defer span.End()
-	ctx = trace.NewContext(c.ctx, span)
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
 
 	members, err := c.consensus.Peers(ctx)
 	if err != nil {
@@ -1862,7 +2684,10 @@ func (c *Cluster) globalPinInfoCid(ctx context.Context, comp, method string, h c
 	return gpin, nil
 }
 
-func (c *Cluster) globalPinInfoSlice(ctx context.Context, comp, method string, arg interface{}) ([]*api.GlobalPinInfo, error) {
+// globalPinInfoSlice broadcasts the given method to all peers and merges
+// the PinInfos they return. When op is not nil, its progress counts the
+// peers which have answered.
+func (c *Cluster) globalPinInfoSlice(ctx context.Context, op *operation, comp, method string, arg interface{}) ([]*api.GlobalPinInfo, error) {
 	ctx, span := trace.StartSpan(ctx, "cluster/globalPinInfoSlice")
 	defer span.End()
 
@@ -1884,23 +2709,22 @@ func (c *Cluster) globalPinInfoSlice(ctx context.Context, comp, method string, a
 			return nil, err
 		}
 	}
-	lenMembers := len(members)
 
-	replies := make([][]*api.PinInfo, lenMembers)
+	if op != nil {
+		op.setTotal(len(members))
+	}
 
 	// We don't have a good timeout proposal for this. Depending on the
 	// size of the state and the peformance of IPFS and the network, this
-	// may take moderately long.
-	ctxs, cancels := rpcutil.CtxsWithCancel(ctx, lenMembers)
-	defer rpcutil.MultiCancel(cancels)
-
-	errs := c.rpcClient.MultiCall(
-		ctxs,
+	// may take moderately long, so it is only limited per peer by the
+	// broadcast_timeout option.
+	results := c.broadcast(
+		ctx,
 		members,
 		comp,
 		method,
 		arg,
-		rpcutil.CopyPinInfoSliceToIfaces(replies),
+		func() interface{} { return &[]*api.PinInfo{} },
 	)
 
 	setPinInfo := func(p *api.PinInfo) {
@@ -1915,19 +2739,23 @@ func (c *Cluster) globalPinInfoSlice(ctx context.Context, comp, method string, a
 		info.Add(p)
 	}
 
+	// Merged as they arrive.
 	erroredPeers := make(map[peer.ID]string)
-	for i, r := range replies {
-		if e := errs[i]; e != nil { // This error must come from not being able to contact that cluster member
+	for res := range results {
+		if op != nil {
+			op.progress(1)
+		}
+		if e := res.Err; e != nil { // This error must come from not being able to contact that cluster member
 			if rpc.IsAuthorizationError(e) {
 				logger.Debug("rpc auth error", e)
 				continue
 			}
-			logger.Errorf("%s: error in broadcast response from %s: %s ", c.id, members[i], e)
-			erroredPeers[members[i]] = e.Error()
+			logger.Errorf("%s: error in broadcast response from %s: %s ", c.id, res.Peer, e)
+			erroredPeers[res.Peer] = e.Error()
 			continue
 		}
 
-		for _, pin := range r {
+		for _, pin := range *res.Reply.(*[]*api.PinInfo) {
 			setPinInfo(pin)
 		}
 	}
@@ -1955,6 +2783,109 @@ func (c *Cluster) globalPinInfoSlice(ctx context.Context, comp, method string, a
 	return infos, nil
 }
 
+// globalPinInfoCids broadcasts a request for the given Cids to all the
+// peers and merges their PinInfos in a GlobalPinInfo for every Cid, in the
+// order of the given Cids. Peers which cannot be contacted get a
+// ClusterError status for all of them.
+func (c *Cluster) globalPinInfoCids(ctx context.Context, comp, method string, cids []cid.Cid) ([]*api.GlobalPinInfo, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/globalPinInfoCids")
+	defer span.End()
+
+	// Repeated Cids share the same GlobalPinInfo and are only requested
+	// once.
+	infos := make([]*api.GlobalPinInfo, len(cids))
+	fullMap := make(map[cid.Cid]*api.GlobalPinInfo, len(cids))
+	for i, h := range cids {
+		info, ok := fullMap[h]
+		if !ok {
+			info = &api.GlobalPinInfo{Cid: h}
+			fullMap[h] = info
+		}
+		infos[i] = info
+	}
+	unique := uniqueCids(cids)
+	if len(unique) == 0 {
+		return infos, nil
+	}
+
+	var members []peer.ID
+	var err error
+	if c.config.FollowerMode {
+		members = []peer.ID{c.host.ID()}
+	} else {
+		members, err = c.consensus.Peers(ctx)
+		if err != nil {
+			logger.Error(err)
+			return nil, err
+		}
+	}
+
+	results := c.broadcast(
+		ctx,
+		members,
+		comp,
+		method,
+		unique,
+		func() interface{} { return &[]*api.PinInfo{} },
+	)
+
+	for res := range results {
+		if e := res.Err; e != nil {
+			if rpc.IsAuthorizationError(e) {
+				logger.Debug("rpc auth error", e)
+				continue
+			}
+			logger.Errorf("%s: error in broadcast response from %s: %s ", c.id, res.Peer, e)
+			for _, info := range fullMap {
+				info.Add(&api.PinInfo{
+					Cid:  info.Cid,
+					Name: info.Name,
+					Peer: res.Peer,
+					PinInfoShort: api.PinInfoShort{
+						PeerName: res.Peer.String(),
+						Status:   api.TrackerStatusClusterError,
+						TS:       time.Now(),
+						Error:    e.Error(),
+					},
+				})
+			}
+			continue
+		}
+
+		for _, pinfo := range *res.Reply.(*[]*api.PinInfo) {
+			if pinfo == nil {
+				continue
+			}
+			if info, ok := fullMap[pinfo.Cid]; ok {
+				if info.Name == "" {
+					info.Name = pinfo.Name
+				}
+				info.Add(pinfo)
+			}
+		}
+	}
+	return infos, nil
+}
+
+// broadcast calls the given method in all the given peers, applying the
+// broadcast_timeout and broadcast_concurrency options. See
+// rpcutil.Broadcast().
+func (c *Cluster) broadcast(ctx context.Context, dests []peer.ID, svcName, svcMethod string, arg interface{}, newReply func() interface{}) <-chan rpcutil.BroadcastResult {
+	return rpcutil.Broadcast(
+		ctx,
+		c.rpcClient,
+		dests,
+		svcName,
+		svcMethod,
+		arg,
+		newReply,
+		rpcutil.BroadcastOptions{
+			Timeout:     c.config.BroadcastTimeout,
+			Concurrency: c.config.BroadcastConcurrency,
+		},
+	)
+}
+
 func (c *Cluster) getIDForPeer(ctx context.Context, pid peer.ID) (*api.ID, error) {
 	ctx, span := trace.StartSpan(ctx, "cluster/getIDForPeer")
 	defer span.End()
@@ -2073,7 +3004,7 @@ func (c *Cluster) cidsFromMetaPin(ctx context.Context, h cid.Cid) ([]cid.Cid, er
 func (c *Cluster) RepoGC(ctx context.Context) (*api.GlobalRepoGC, error) {
 	_, span := trace.StartSpan(ctx, "cluster/RepoGC")
 	defer span.End()
-	ctx = trace.NewContext(c.ctx, span)
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
 
 	members, err := c.consensus.Peers(ctx)
 	if err != nil {
@@ -2081,38 +3012,45 @@ func (c *Cluster) RepoGC(ctx context.Context) (*api.GlobalRepoGC, error) {
 		return nil, err
 	}
 
+	op := c.operations.start(ctx, api.OperationRepoGC, len(members))
+	defer op.finish(nil)
+
+	results := c.broadcast(
+		op.ctx,
+		members,
+		"Cluster",
+		"RepoGCLocal",
+		struct{}{},
+		func() interface{} { return &api.RepoGC{} },
+	)
+
 	// to club `RepoGCLocal` responses of all peers into one
 	globalRepoGC := api.GlobalRepoGC{PeerMap: make(map[string]*api.RepoGC)}
-	for _, member := range members {
-		var repoGC api.RepoGC
-		err = c.rpcClient.CallContext(
-			ctx,
-			member,
-			"Cluster",
-			"RepoGCLocal",
-			struct{}{},
-			&repoGC,
-		)
-		if err == nil {
-			globalRepoGC.PeerMap[peer.Encode(member)] = &repoGC
+	for res := range results {
+		op.progress(1)
+		if res.Err == nil {
+			globalRepoGC.PeerMap[peer.Encode(res.Peer)] = res.Reply.(*api.RepoGC)
 			continue
 		}
 
-		if rpc.IsAuthorizationError(err) {
-			logger.Debug("rpc auth error:", err)
+		if rpc.IsAuthorizationError(res.Err) {
+			logger.Debug("rpc auth error:", res.Err)
 			continue
 		}
 
-		logger.Errorf("%s: error in broadcast response from %s: %s ", c.id, member, err)
+		logger.Errorf("%s: error in broadcast response from %s: %s ", c.id, res.Peer, res.Err)
 
-		globalRepoGC.PeerMap[peer.Encode(member)] = &api.RepoGC{
-			Peer:     member,
-			Peername: peer.Encode(member),
+		globalRepoGC.PeerMap[peer.Encode(res.Peer)] = &api.RepoGC{
+			Peer:     res.Peer,
+			Peername: peer.Encode(res.Peer),
 			Keys:     []api.IPFSRepoGC{},
-			Error:    err.Error(),
+			Error:    res.Err.Error(),
 		}
 	}
 
+	if op.canceled() {
+		return nil, op.ctx.Err()
+	}
 	return &globalRepoGC, nil
 }
 
@@ -2120,7 +3058,7 @@ func (c *Cluster) RepoGC(ctx context.Context) (*api.GlobalRepoGC, error) {
 func (c *Cluster) RepoGCLocal(ctx context.Context) (*api.RepoGC, error) {
 	_, span := trace.StartSpan(ctx, "cluster/RepoGCLocal")
 	defer span.End()
-	ctx = trace.NewContext(c.ctx, span)
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
 
 	resp, err := c.ipfs.RepoGC(ctx)
 	if err != nil {
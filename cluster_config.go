@@ -6,14 +6,19 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"time"
 
+	"github.com/ipfs/ipfs-cluster/api"
 	"github.com/ipfs/ipfs-cluster/config"
 
+	cid "github.com/ipfs/go-cid"
 	ipfsconfig "github.com/ipfs/go-ipfs-config"
+	peer "github.com/libp2p/go-libp2p-core/peer"
 	pnet "github.com/libp2p/go-libp2p-core/pnet"
 	ma "github.com/multiformats/go-multiaddr"
 
@@ -44,7 +49,62 @@ const (
 	DefaultConnMgrGracePeriod  = 2 * time.Minute
 	DefaultDialPeerTimeout     = 3 * time.Second
 	DefaultFollowerMode        = false
+	DefaultResolveDAGSize      = false
 	DefaultMDNSInterval        = 10 * time.Second
+
+	DefaultBroadcastTimeout     = 0
+	DefaultBroadcastConcurrency = 32
+
+	DefaultPinAckTimeout = 2 * time.Minute
+
+	DefaultSecretTransitionWindow = 10 * time.Minute
+
+	DefaultPopularityInterval      = 0
+	DefaultPopularityHotThreshold  = 1000
+	DefaultPopularityColdThreshold = 10
+
+	DefaultRepinGracePeriod = 0
+	DefaultRepinConcurrency = 1
+
+	DefaultConnectivitySnapshotInterval = 0
+	DefaultConnectivityHistorySize      = 1000
+
+	DefaultUnpinGracePeriod = 0
+
+	DefaultPinValidationTimeout = 10 * time.Second
+
+	DefaultPinWebhookTimeout = 10 * time.Second
+	DefaultPinWebhookRetries = 3
+
+	DefaultNamePublishingTimeout = 2 * time.Minute
+	DefaultDNSLinkTTL            = time.Minute
+
+	DefaultPinUpdateUnpinGracePeriod = 24 * time.Hour
+
+	DefaultIPNSTrackingInterval    = 10 * time.Minute
+	DefaultIPNSTrackingTimeout     = time.Minute
+	DefaultIPNSTrackingHistorySize = 10
+
+	DefaultReadThroughCacheInterval          = 0
+	DefaultReadThroughCacheThreshold         = 10
+	DefaultReadThroughCacheReplicationFactor = 1
+	DefaultReadThroughCacheTTL               = 24 * time.Hour
+	DefaultReadThroughCacheMaxPins           = 100
+
+	DefaultStateBackupInterval          = 0
+	DefaultStateBackupFullEvery         = 24
+	DefaultStateBackupKeep              = 3
+	DefaultStateBackupReplicationFactor = -1
+
+	DefaultTieringInterval  = 0
+	DefaultTieringTag       = "tier"
+	DefaultTieringHot       = "hot"
+	DefaultTieringCold      = "cold"
+	DefaultTieringColdAfter = 30 * 24 * time.Hour
+
+	DefaultGatewayWarmupConcurrency = 4
+	DefaultGatewayWarmupTimeout     = time.Minute
+	DefaultGatewayWarmupWaitTimeout = time.Hour
 )
 
 // ConnMgrConfig configures the libp2p host connection manager.
@@ -54,6 +114,433 @@ type ConnMgrConfig struct {
 	GracePeriod time.Duration
 }
 
+// PopularityConfig configures the adjustment of replication factors based on
+// how often the content is accessed.
+type PopularityConfig struct {
+	// Interval is the time between replication adjustments. Access
+	// counts are reset on every interval. A 0 interval disables the
+	// feature.
+	Interval time.Duration
+	// HotThreshold is the number of accesses in an interval, across
+	// all peers, from which the replication factor of a pin is increased
+	// by one.
+	HotThreshold uint64
+	// ColdThreshold is the number of accesses in an interval up to
+	// which a previously increased replication factor is lowered by one.
+	ColdThreshold uint64
+	// MaxReplication is the maximum replication factor that pins
+	// can reach by being popular.
+	MaxReplication int
+	// AccessLog is a file in which the gateway writes a line for every
+	// request, like ReadThroughCache.AccessLog. The /ipfs/<cid> paths
+	// found on the new lines count as accesses, so that content
+	// requested from the gateway, and not through the IPFS proxy, gets
+	// replicated too. It can be the same file as
+	// ReadThroughCache.AccessLog.
+	AccessLog string
+}
+
+// PinUpdateUnpinConfig configures the unpinning of the pins replaced by
+// newer versions with PinUpdate. Updated pins record the CIDs of the
+// versions they replace in their metadata (PinUpdateChainMetaKey), newest
+// first, and the versions beyond the ones kept are set to expire.
+type PinUpdateUnpinConfig struct {
+	// Enabled turns on the recording of update chains and the unpinning
+	// of replaced versions.
+	Enabled bool
+	// GracePeriod is how long replaced versions stay pinned before
+	// they expire.
+	GracePeriod time.Duration
+	// KeepVersions is the number of replaced versions which stay pinned.
+	// With 0, the previous version expires as soon as it is updated.
+	KeepVersions int
+}
+
+// IPNSTrackingConfig configures following the IPNS names of pins with the
+// api.IPNSTrackMetaKey metadata key. Every name is resolved by the peer
+// closest to it, which pins the new target with PinUpdate when it changes.
+type IPNSTrackingConfig struct {
+	// Interval is the time between resolutions of the tracked names. 0
+	// disables them.
+	Interval time.Duration
+	// Timeout limits how long resolving a name can take.
+	Timeout time.Duration
+	// HistorySize is the number of resolutions recorded in the
+	// metadata of the pins (IPNSHistoryMetaKey).
+	HistorySize int
+}
+
+// ReadThroughCacheConfig configures pinning the content which is requested
+// often from the local IPFS daemon, as reported by the IPFS proxy and read
+// from the access log of its gateway. Cache pins have a low replication
+// factor and expire unless they keep being requested.
+type ReadThroughCacheConfig struct {
+	// Interval is the time between rounds of cache pins. Access counts
+	// are reset on every interval. 0 disables the cache.
+	Interval time.Duration
+	// Threshold is the number of accesses in an interval from which
+	// the content is pinned.
+	Threshold uint64
+	// ReplicationFactor of the cache pins.
+	ReplicationFactor int
+	// TTL is how long cache pins last since they were last requested
+	// often enough.
+	TTL time.Duration
+	// MaxPins limits the number of cache pins submitted on every
+	// interval. The most accessed content is pinned first.
+	MaxPins int
+	// AccessLog is a file in which the gateway writes a line for every
+	// request, i.e. the access log of a reverse proxy in front of it.
+	// The /ipfs/<cid> paths found on the new lines are counted on every
+	// interval. Relative paths are relative to the configuration folder.
+	AccessLog string
+}
+
+// StateBackupConfig configures periodic backups of the shared state to
+// IPFS. Backups are added to IPFS and pinned in the cluster, so that the
+// pinset can be restored from them with "ipfs-cluster-service state
+// restore". Most backups only contain the changes since the previous one.
+type StateBackupConfig struct {
+	// Interval is the time between backups. 0 disables them.
+	Interval time.Duration
+	// FullEvery is the number of backups between full backups, which
+	// contain the whole pinset. The backups in between only contain the
+	// changes since the previous backup. With 1, all backups are full.
+	FullEvery int
+	// Keep is the number of full backups, with the backups which
+	// depend on them, which stay pinned.
+	Keep int
+	// ReplicationFactor of the backup pins.
+	ReplicationFactor int
+}
+
+// TieringConfig configures moving pins between tiers of peers. The tier of
+// each peer is the value of one of its tags (see the tags informer). New
+// pins are allocated to peers in the hot tier and moved to the cold tier
+// after some time, keeping their replication factor. When Popularity is
+// enabled, popular pins stay in, or move back to, the hot tier.
+type TieringConfig struct {
+	// Interval is the time between checks for pins to move. 0 disables
+	// tiering.
+	Interval time.Duration
+	// Tag is the name of the tag with the tier of each peer.
+	Tag string
+	// Hot is the tier of new pins.
+	Hot string
+	// Cold is the tier of the pins which have been in the hot tier for
+	// ColdAfter.
+	Cold string
+	// ColdAfter is the time pins stay in the hot tier.
+	ColdAfter time.Duration
+}
+
+// RepinConfig configures how the pins allocated to peers which go down are
+// re-allocated, when repinning is enabled.
+type RepinConfig struct {
+	// GracePeriod delays re-allocations after a peer is detected as
+	// down. Nothing is re-allocated if the peer recovers within this
+	// time, i.e. when it is restarted during planned maintenance.
+	GracePeriod time.Duration
+	// Concurrency is the number of pins re-allocated at the same time.
+	Concurrency int
+	// OnlyUnderReplicated limits re-allocations to pins with fewer
+	// healthy allocations than their replication_factor_min.
+	OnlyUnderReplicated bool
+}
+
+// PinValidationConfig configures an external hook which approves the pins
+// submitted to this peer before they are added to the pinset. The hook is
+// either an HTTP endpoint or a command.
+type PinValidationConfig struct {
+	// URL is the address of an HTTP endpoint which receives every pin
+	// as JSON in the body of a POST request.
+	URL string
+	// Command is run for every pin, receiving the pin as JSON on its
+	// standard input. The first element is the program. Ignored when
+	// URL is set.
+	Command []string
+	// Timeout limits how long the hook can take to answer.
+	Timeout time.Duration
+	// FailOpen accepts pins when the hook fails or cannot be
+	// reached. Otherwise they are rejected.
+	FailOpen bool
+}
+
+// PinWebhookConfig configures an HTTP endpoint which is notified of the
+// changes to the pinset committed by this peer. Every change is sent as an
+// api.PinsetEvent in the JSON body of a POST request.
+type PinWebhookConfig struct {
+	// URL is the address of the endpoint.
+	URL string
+	// Events limits the notifications to the given event types
+	// ("added", "updated", "removed"). All are sent when empty.
+	Events []api.PinsetEventType
+	// Timeout limits how long every request can take.
+	Timeout time.Duration
+	// Retries is how many times a failed notification is sent again
+	// before it is dropped.
+	Retries int
+}
+
+// LifecycleWebhookConfig configures an HTTP endpoint which is notified of
+// the lifecycle events of this peer. Every event is sent as an
+// api.LifecycleEvent in the JSON body of a POST request.
+type LifecycleWebhookConfig struct {
+	// URL is the address of the endpoint.
+	URL string
+	// Events limits the notifications to the given event types. All
+	// are sent when empty.
+	Events []api.LifecycleEventType
+	// Timeout limits how long every request can take.
+	Timeout time.Duration
+	// Retries is how many times a failed notification is sent again
+	// before it is dropped.
+	Retries int
+}
+
+// NamePublishingConfig configures the publication of pins with the
+// api.IPNSKeyMetaKey or api.DNSLinkMetaKey metadata keys, once they are
+// pinned in all their allocations. Every name is published by a single
+// peer (the closest to it), during StateSync. IPNS keys must be present in
+// the IPFS daemon of that peer. Only the keys and domains listed here are
+// published, as any user able to pin can set the metadata.
+type NamePublishingConfig struct {
+	// Enabled must be set by all peers for names to be published.
+	Enabled bool
+	// Timeout limits how long every publication can take.
+	Timeout time.Duration
+	// IPNSKeys are the IPNS keys that pins can be published with.
+	IPNSKeys []string
+	// DNSLinkDomains are the domains whose DNSLink records pins can
+	// update.
+	DNSLinkDomains []string
+	// DNSLink configures how DNSLink records are updated.
+	DNSLink DNSLinkConfig
+}
+
+// GatewayWarmupConfig configures the requests sent to IPFS gateways once
+// a new pin submitted to this peer is pinned, here when it is allocated to
+// this peer or in all its allocations otherwise, so that the gateways fetch
+// and cache the content before it is requested by users. The results can
+// be followed as "gateway_warmup" operations, and the last ones are kept
+// across restarts.
+type GatewayWarmupConfig struct {
+	// Gateways are URLs or URL templates. "{cid}" is replaced by the
+	// CIDv1 of the content, which allows subdomain gateways like
+	// "https://{cid}.ipfs.dweb.link/". Otherwise "/ipfs/<cid>" is
+	// appended to the URL (path gateways). No requests are sent when
+	// empty.
+	Gateways []string
+	// Concurrency is the number of gateway requests sent at the same
+	// time, for all the pins.
+	Concurrency int
+	// Timeout limits how long every request can take.
+	Timeout time.Duration
+	// WaitTimeout is how long to wait for the pin to be pinned before
+	// giving up.
+	WaitTimeout time.Duration
+}
+
+// DNSLinkConfig selects and configures the provider which updates DNSLink
+// records. See the dnslink package.
+type DNSLinkConfig struct {
+	// Provider is "rfc2136" or "http". DNSLink records are not updated
+	// when empty.
+	Provider string
+	// URL is the endpoint of the http provider.
+	URL string
+	// Server (host:port) and Zone are used by the rfc2136 provider,
+	// which signs updates with the TSIG key, if set.
+	Server        string
+	Zone          string
+	TSIGKeyName   string
+	TSIGSecret    string
+	TSIGAlgorithm string
+	// TTL of the records set by the rfc2136 provider.
+	TTL time.Duration
+}
+
+// DenylistConfig configures the denylist, which prevents pinning matching
+// content.
+type DenylistConfig struct {
+	// Files are denylists in the compact format of the Bad Bits
+	// denylist, loaded when the peer starts. Relative paths are
+	// relative to the configuration folder.
+	Files []string
+	// UnpinMatches unpins the pins matching the denylist when the peer
+	// starts and when entries are added.
+	UnpinMatches bool
+}
+
+// FaultRule describes the faults injected in one type of calls. Rates are
+// fractions of the calls between 0 and 1.
+type FaultRule struct {
+	// FailRate is the fraction of calls which fail without being made.
+	FailRate float64
+	// DelayRate is the fraction of calls which are delayed.
+	DelayRate float64
+	// Delay is how long delayed calls wait before being made.
+	Delay time.Duration
+}
+
+func (fr *FaultRule) enabled() bool {
+	return fr.FailRate > 0 || fr.DelayRate > 0
+}
+
+func (fr *FaultRule) validate(name string) error {
+	if fr.FailRate < 0 || fr.FailRate > 1 {
+		return fmt.Errorf("cluster.fault_injection.%s.fail_rate must be between 0 and 1", name)
+	}
+	if fr.DelayRate < 0 || fr.DelayRate > 1 {
+		return fmt.Errorf("cluster.fault_injection.%s.delay_rate must be between 0 and 1", name)
+	}
+	if fr.DelayRate > 0 && fr.Delay <= 0 {
+		return fmt.Errorf("cluster.fault_injection.%s.delay must be set when delay_rate is", name)
+	}
+	return nil
+}
+
+// FaultInjectionConfig configures artificial failures and delays in the
+// calls this peer makes, to test how the cluster copes with unreliable
+// daemons and networks in staging environments. It is disabled by default
+// and should never be enabled in production.
+type FaultInjectionConfig struct {
+	// IPFS applies to the calls to the IPFS daemon.
+	IPFS FaultRule
+	// RPC applies to the RPC requests sent to other peers. Calls to
+	// the local peer are not affected.
+	RPC FaultRule
+}
+
+func (fic *FaultInjectionConfig) enabled() bool {
+	return fic.IPFS.enabled() || fic.RPC.enabled()
+}
+
+func (pvc *PinValidationConfig) enabled() bool {
+	return pvc.URL != "" || len(pvc.Command) > 0
+}
+
+func (whc *PinWebhookConfig) validate() error {
+	u, err := url.Parse(whc.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return errors.New("cluster.pin_webhooks.url must be an http or https URL")
+	}
+	if whc.Timeout <= 0 {
+		return errors.New("cluster.pin_webhooks.timeout is invalid")
+	}
+	if whc.Retries < 0 {
+		return errors.New("cluster.pin_webhooks.retries cannot be negative")
+	}
+	for _, ev := range whc.Events {
+		switch ev {
+		case api.PinsetEventAdded, api.PinsetEventUpdated, api.PinsetEventRemoved:
+		default:
+			return fmt.Errorf("cluster.pin_webhooks.events: unknown event %q", ev)
+		}
+	}
+	return nil
+}
+
+func (whc *LifecycleWebhookConfig) validate() error {
+	u, err := url.Parse(whc.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return errors.New("cluster.lifecycle_webhooks.url must be an http or https URL")
+	}
+	if whc.Timeout <= 0 {
+		return errors.New("cluster.lifecycle_webhooks.timeout is invalid")
+	}
+	if whc.Retries < 0 {
+		return errors.New("cluster.lifecycle_webhooks.retries cannot be negative")
+	}
+	for _, ev := range whc.Events {
+		switch ev {
+		case api.LifecycleEventPeerJoined, api.LifecycleEventPeerLeft,
+			api.LifecycleEventLeaderChanged, api.LifecycleEventConsensusReady,
+			api.LifecycleEventTrackerWarmedUp, api.LifecycleEventAPIStarted:
+		default:
+			return fmt.Errorf("cluster.lifecycle_webhooks.events: unknown event %q", ev)
+		}
+	}
+	return nil
+}
+
+func (npc *NamePublishingConfig) validate() error {
+	if !npc.Enabled {
+		return nil
+	}
+	if npc.Timeout <= 0 {
+		return errors.New("cluster.name_publishing.timeout is invalid")
+	}
+
+	dl := npc.DNSLink
+	switch dl.Provider {
+	case "":
+	case "http":
+		u, err := url.Parse(dl.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return errors.New("cluster.name_publishing.dnslink.url must be an http or https URL")
+		}
+	case "rfc2136":
+		if dl.Server == "" || dl.Zone == "" {
+			return errors.New("cluster.name_publishing.dnslink.server and zone must be set")
+		}
+		if dl.TTL <= 0 {
+			return errors.New("cluster.name_publishing.dnslink.ttl is invalid")
+		}
+		if dl.TSIGKeyName != "" && dl.TSIGSecret == "" {
+			return errors.New("cluster.name_publishing.dnslink.tsig_secret must be set")
+		}
+	default:
+		return fmt.Errorf("cluster.name_publishing.dnslink.provider must be \"http\" or \"rfc2136\"")
+	}
+	return nil
+}
+
+func (gwc *GatewayWarmupConfig) validate() error {
+	if len(gwc.Gateways) == 0 {
+		return nil
+	}
+	for _, gw := range gwc.Gateways {
+		u, err := url.Parse(strings.ReplaceAll(gw, gatewayCidPlaceholder, "cid"))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("cluster.gateway_warmup.gateways: %q is not an http or https URL", gw)
+		}
+	}
+	if gwc.Concurrency <= 0 {
+		return errors.New("cluster.gateway_warmup.concurrency must be positive")
+	}
+	if gwc.Timeout <= 0 || gwc.WaitTimeout <= 0 {
+		return errors.New("cluster.gateway_warmup timeouts are invalid")
+	}
+	return nil
+}
+
+// wants returns true when the webhook is notified of the given event type.
+func (whc *PinWebhookConfig) wants(ev api.PinsetEventType) bool {
+	if len(whc.Events) == 0 {
+		return true
+	}
+	for _, e := range whc.Events {
+		if e == ev {
+			return true
+		}
+	}
+	return false
+}
+
+// wants returns true when the webhook is notified of the given event type.
+func (whc *LifecycleWebhookConfig) wants(ev api.LifecycleEventType) bool {
+	if len(whc.Events) == 0 {
+		return true
+	}
+	for _, e := range whc.Events {
+		if e == ev {
+			return true
+		}
+	}
+	return false
+}
+
 // Config is the configuration object containing customizable variables to
 // initialize the main ipfs-cluster component. It implements the
 // config.ComponentConfig interface.
@@ -63,6 +550,11 @@ type Config struct {
 	// User-defined peername for use as human-readable identifier.
 	Peername string
 
+	// PeerLabels are arbitrary labels describing this peer, like its
+	// region, provider or owner contact. They are shown along with the
+	// peer ID and in alerts about this peer.
+	PeerLabels map[string]string
+
 	// Cluster secret for private network. Peers will be in the same cluster if and
 	// only if they have the same ClusterSecret. The cluster secret must be exactly
 	// 64 characters and contain only hexadecimal characters (`[0-9a-f]`).
@@ -103,6 +595,12 @@ type Config struct {
 	// which will retry to pin/unpin items in error state.
 	PinRecoverInterval time.Duration
 
+	// StartupWarmupRate limits the number of items per second that are
+	// pinned or unpinned again by the first recover operation, which
+	// runs when the peer starts and reconciles the whole pinset with
+	// IPFS. 0 means no limit.
+	StartupWarmupRate int
+
 	// ReplicationFactorMax indicates the target number of nodes
 	// that should pin content. For exampe, a replication_factor of
 	// 3 will have cluster allocate each pinned hash to 3 peers if
@@ -121,6 +619,12 @@ type Config struct {
 	// possible.
 	ReplicationFactorMin int
 
+	// MinFreeSpace is the free space, in bytes, that peers must report
+	// for new pins and adds to be accepted. They are rejected right away
+	// when fewer peers than their replication_factor_min have more free
+	// space, according to the freespace metrics. 0 disables the check.
+	MinFreeSpace uint64
+
 	// MonitorPingInterval is the frequency with which a cluster peer pings
 	// the monitoring component. The ping metric has a TTL set to the double
 	// of this value.
@@ -143,11 +647,126 @@ type Config struct {
 	// when not wanting to rely on the monitoring system which needs a revamp.
 	DisableRepinning bool
 
+	// Repin configures re-allocations when repinning is enabled.
+	Repin RepinConfig
+
 	// FollowerMode disables broadcast requests from this peer
 	// (sync, recover, status) and disallows pinset management
 	// operations (Pin/Unpin).
 	FollowerMode bool
 
+	// ResolveDAGSize enables obtaining the size of the DAGs being pinned
+	// from IPFS, when not provided in the pin options. The size is used
+	// to avoid allocating to peers without enough free space.
+	ResolveDAGSize bool
+
+	// BroadcastTimeout limits how long a single peer can take to answer
+	// the requests sent to all peers in global operations like
+	// StatusAll, RecoverAll or RepoGC. Slower peers are reported as
+	// errored without delaying the rest. 0 means no timeout.
+	BroadcastTimeout time.Duration
+
+	// BroadcastConcurrency is the maximum number of peers contacted at
+	// the same time during global operations.
+	BroadcastConcurrency int
+
+	// PinAckTimeout limits how long a pin request with the replicated
+	// acknowledgment level waits for the pin to reach enough peers.
+	PinAckTimeout time.Duration
+
+	// SecretTransitionWindow is how long the previous cluster secret
+	// keeps being accepted by this peer after switching to a new one
+	// during a secret rotation, so that peers which have not switched yet
+	// can still connect.
+	SecretTransitionWindow time.Duration
+
+	// UniquePinNames makes this peer reject pins with a name already in
+	// use by a different CID in the pinset. The check happens in the peer
+	// submitting the pin, so concurrent pins with the same name in
+	// different peers may still succeed.
+	UniquePinNames bool
+
+	// ConnectivitySnapshotInterval is the time between snapshots of
+	// the connections among cluster peers, which can be retrieved
+	// later to find out when the cluster was partitioned. Every
+	// snapshot contacts all peers. 0 disables them.
+	ConnectivitySnapshotInterval time.Duration
+
+	// ConnectivityHistorySize is the maximum number of connectivity
+	// snapshots kept in memory. Consecutive snapshots with the same
+	// connectivity only count once.
+	ConnectivityHistorySize int
+
+	// Popularity configures increasing the replication factor of
+	// frequently accessed pins, and lowering it again when they are no
+	// longer accessed.
+	Popularity PopularityConfig
+
+	// PinValidation configures a hook which can reject pins or add
+	// metadata to them before they are committed.
+	PinValidation PinValidationConfig
+
+	// PinMetadata limits the size of the metadata of the pins submitted
+	// to this peer and can require it to match a JSON schema.
+	PinMetadata api.MetadataPolicy
+
+	// PinUpdateUnpin configures the unpinning of the pins replaced with
+	// PinUpdate.
+	PinUpdateUnpin PinUpdateUnpinConfig
+
+	// IPNSTracking configures re-resolving the IPNS names tracked by
+	// pins and updating them when their target changes.
+	IPNSTracking IPNSTrackingConfig
+
+	// ReadThroughCache configures pinning the content requested often
+	// from the local IPFS daemon.
+	ReadThroughCache ReadThroughCacheConfig
+
+	// StateBackup configures periodic backups of the shared state to
+	// IPFS.
+	StateBackup StateBackupConfig
+
+	// Tiering configures moving pins from hot to cold peers as they
+	// age.
+	Tiering TieringConfig
+
+	// AllocationExclusions maps pin metadata, as "key=value", to the
+	// peers which must never be allocated pins with that metadata. This
+	// peer adds them to the ExcludeAllocations of the pins it handles.
+	AllocationExclusions map[string][]peer.ID
+
+	// PinWebhooks are notified of the pins added, updated and removed
+	// by this peer once they are committed to the shared state.
+	PinWebhooks []PinWebhookConfig
+
+	// LifecycleWebhooks are notified of the lifecycle events of this
+	// peer.
+	LifecycleWebhooks []LifecycleWebhookConfig
+
+	// NamePublishing configures publishing pins to IPNS and DNSLink.
+	NamePublishing NamePublishingConfig
+
+	// GatewayWarmup configures requesting new pins from IPFS gateways.
+	GatewayWarmup GatewayWarmupConfig
+
+	// Denylist configures the content that cannot be pinned.
+	Denylist DenylistConfig
+
+	// FaultInjection configures failures and delays injected in IPFS
+	// and RPC calls for testing.
+	FaultInjection FaultInjectionConfig
+
+	// UnpinGracePeriod is how long unpinned items stay in the trash
+	// before they are actually unpinned. Items in the trash remain
+	// pinned in IPFS and can be restored. 0 disables the trash, so
+	// that items are unpinned right away.
+	UnpinGracePeriod time.Duration
+
+	// ProtectedCIDs lists CIDs which this peer never unpins, whether or
+	// not their pins are flagged as protected. Unlike the flag, this
+	// protection cannot be cleared through the API.
+	ProtectedCIDs []cid.Cid
+
 	// Peerstore file specifies the file on which we persist the
 	// libp2p host peerstore addresses. This file is regularly saved.
 	PeerstoreFile string
@@ -165,26 +784,56 @@ type Config struct {
 // saved using JSON. Most configuration keys are converted into simple types
 // like strings, and key names aim to be self-explanatory for the user.
 type configJSON struct {
-	ID                   string             `json:"id,omitempty"`
-	Peername             string             `json:"peername"`
-	PrivateKey           string             `json:"private_key,omitempty" hidden:"true"`
-	Secret               string             `json:"secret" hidden:"true"`
-	LeaveOnShutdown      bool               `json:"leave_on_shutdown"`
-	ListenMultiaddress   ipfsconfig.Strings `json:"listen_multiaddress"`
-	EnableRelayHop       bool               `json:"enable_relay_hop"`
-	ConnectionManager    *connMgrConfigJSON `json:"connection_manager"`
-	DialPeerTimeout      string             `json:"dial_peer_timeout"`
-	StateSyncInterval    string             `json:"state_sync_interval"`
-	PinRecoverInterval   string             `json:"pin_recover_interval"`
-	ReplicationFactorMin int                `json:"replication_factor_min"`
-	ReplicationFactorMax int                `json:"replication_factor_max"`
-	MonitorPingInterval  string             `json:"monitor_ping_interval"`
-	PeerWatchInterval    string             `json:"peer_watch_interval"`
-	MDNSInterval         string             `json:"mdns_interval"`
-	DisableRepinning     bool               `json:"disable_repinning"`
-	FollowerMode         bool               `json:"follower_mode,omitempty"`
-	PeerstoreFile        string             `json:"peerstore_file,omitempty"`
-	PeerAddresses        []string           `json:"peer_addresses"`
+	ID                           string                  `json:"id,omitempty"`
+	Peername                     string                  `json:"peername"`
+	PeerLabels                   map[string]string       `json:"peer_labels,omitempty"`
+	PrivateKey                   string                  `json:"private_key,omitempty" hidden:"true"`
+	Secret                       string                  `json:"secret" hidden:"true"`
+	LeaveOnShutdown              bool                    `json:"leave_on_shutdown"`
+	ListenMultiaddress           ipfsconfig.Strings      `json:"listen_multiaddress"`
+	EnableRelayHop               bool                    `json:"enable_relay_hop"`
+	ConnectionManager            *connMgrConfigJSON      `json:"connection_manager"`
+	DialPeerTimeout              string                  `json:"dial_peer_timeout"`
+	StateSyncInterval            string                  `json:"state_sync_interval"`
+	PinRecoverInterval           string                  `json:"pin_recover_interval"`
+	StartupWarmupRate            int                     `json:"startup_warmup_rate,omitempty"`
+	ReplicationFactorMin         int                     `json:"replication_factor_min"`
+	ReplicationFactorMax         int                     `json:"replication_factor_max"`
+	MinFreeSpace                 uint64                  `json:"min_free_space,omitempty"`
+	MonitorPingInterval          string                  `json:"monitor_ping_interval"`
+	PeerWatchInterval            string                  `json:"peer_watch_interval"`
+	MDNSInterval                 string                  `json:"mdns_interval"`
+	DisableRepinning             bool                    `json:"disable_repinning"`
+	Repin                        *repinConfigJSON        `json:"repin"`
+	FollowerMode                 bool                    `json:"follower_mode,omitempty"`
+	ResolveDAGSize               bool                    `json:"resolve_dag_size,omitempty"`
+	BroadcastTimeout             string                  `json:"broadcast_timeout"`
+	BroadcastConcurrency         int                     `json:"broadcast_concurrency"`
+	PinAckTimeout                string                  `json:"pin_ack_timeout"`
+	SecretTransitionWindow       string                  `json:"secret_transition_window"`
+	UniquePinNames               bool                    `json:"unique_pin_names,omitempty"`
+	ConnectivitySnapshotInterval string                  `json:"connectivity_snapshot_interval"`
+	ConnectivityHistorySize      int                     `json:"connectivity_history_size"`
+	Popularity                   *popularityConfigJSON   `json:"popularity"`
+	PinValidation                *pinValidationJSON      `json:"pin_validation"`
+	PinMetadata                  *api.MetadataPolicy     `json:"pin_metadata,omitempty"`
+	PinUpdateUnpin               *pinUpdateUnpinJSON     `json:"pin_update_unpin,omitempty"`
+	IPNSTracking                 *ipnsTrackingJSON       `json:"ipns_tracking"`
+	ReadThroughCache             *readThroughCacheJSON   `json:"read_through_cache,omitempty"`
+	StateBackup                  *stateBackupJSON        `json:"state_backup,omitempty"`
+	Tiering                      *tieringJSON            `json:"tiering,omitempty"`
+	AllocationExclusions         map[string][]string     `json:"allocation_exclusions,omitempty"`
+	PinWebhooks                  []*pinWebhookJSON       `json:"pin_webhooks,omitempty"`
+	LifecycleWebhooks            []*lifecycleWebhookJSON `json:"lifecycle_webhooks,omitempty"`
+	NamePublishing               *namePublishingJSON     `json:"name_publishing,omitempty"`
+	GatewayWarmup                *gatewayWarmupJSON      `json:"gateway_warmup,omitempty"`
+	Denylist                     *denylistJSON           `json:"denylist"`
+	FaultInjection               *faultInjectionJSON     `json:"fault_injection,omitempty"`
+	UnpinGracePeriod             string                  `json:"unpin_grace_period"`
+	ProtectedCIDs                []string                `json:"protected_cids,omitempty"`
+	RPCPolicy                    map[string]string       `json:"rpc_policy,omitempty"`
+	PeerstoreFile                string                  `json:"peerstore_file,omitempty"`
+	PeerAddresses                []string                `json:"peer_addresses"`
 }
 
 // connMgrConfigJSON configures the libp2p host connection manager.
@@ -194,6 +843,143 @@ type connMgrConfigJSON struct {
 	GracePeriod string `json:"grace_period"`
 }
 
+type ipnsTrackingJSON struct {
+	Interval    string `json:"interval"`
+	Timeout     string `json:"timeout"`
+	HistorySize int    `json:"history_size"`
+}
+
+type readThroughCacheJSON struct {
+	Interval          string `json:"interval"`
+	Threshold         uint64 `json:"threshold"`
+	ReplicationFactor int    `json:"replication_factor"`
+	TTL               string `json:"ttl"`
+	MaxPins           int    `json:"max_pins"`
+	AccessLog         string `json:"access_log,omitempty"`
+}
+
+type stateBackupJSON struct {
+	Interval          string `json:"interval"`
+	FullEvery         int    `json:"full_every"`
+	Keep              int    `json:"keep"`
+	ReplicationFactor int    `json:"replication_factor"`
+}
+
+type tieringJSON struct {
+	Interval  string `json:"interval"`
+	Tag       string `json:"tag"`
+	Hot       string `json:"hot"`
+	Cold      string `json:"cold"`
+	ColdAfter string `json:"cold_after"`
+}
+
+// popularityConfigJSON configures access-based replication.
+type popularityConfigJSON struct {
+	Interval       string `json:"interval"`
+	HotThreshold   uint64 `json:"hot_threshold"`
+	ColdThreshold  uint64 `json:"cold_threshold"`
+	MaxReplication int    `json:"max_replication"`
+	AccessLog      string `json:"access_log,omitempty"`
+}
+
+// repinConfigJSON configures re-allocations on peer failure.
+type repinConfigJSON struct {
+	GracePeriod         string `json:"grace_period"`
+	Concurrency         int    `json:"concurrency"`
+	OnlyUnderReplicated bool   `json:"only_under_replicated"`
+}
+
+type pinValidationJSON struct {
+	URL      string   `json:"url"`
+	Command  []string `json:"command,omitempty"`
+	Timeout  string   `json:"timeout"`
+	FailOpen bool     `json:"fail_open"`
+}
+
+type pinUpdateUnpinJSON struct {
+	Enabled      bool   `json:"enabled"`
+	GracePeriod  string `json:"grace_period"`
+	KeepVersions int    `json:"keep_versions"`
+}
+
+type pinWebhookJSON struct {
+	URL     string                `json:"url"`
+	Events  []api.PinsetEventType `json:"events,omitempty"`
+	Timeout string                `json:"timeout"`
+	Retries *int                  `json:"retries,omitempty"`
+}
+
+type lifecycleWebhookJSON struct {
+	URL     string                   `json:"url"`
+	Events  []api.LifecycleEventType `json:"events,omitempty"`
+	Timeout string                   `json:"timeout"`
+	Retries *int                     `json:"retries,omitempty"`
+}
+
+type gatewayWarmupJSON struct {
+	Gateways    []string `json:"gateways"`
+	Concurrency int      `json:"concurrency"`
+	Timeout     string   `json:"timeout"`
+	WaitTimeout string   `json:"wait_timeout"`
+}
+
+type namePublishingJSON struct {
+	Enabled        bool         `json:"enabled"`
+	Timeout        string       `json:"timeout"`
+	IPNSKeys       []string     `json:"ipns_keys,omitempty"`
+	DNSLinkDomains []string     `json:"dnslink_domains,omitempty"`
+	DNSLink        *dnslinkJSON `json:"dnslink,omitempty"`
+}
+
+type dnslinkJSON struct {
+	Provider      string `json:"provider"`
+	URL           string `json:"url,omitempty"`
+	Server        string `json:"server,omitempty"`
+	Zone          string `json:"zone,omitempty"`
+	TSIGKeyName   string `json:"tsig_key_name,omitempty"`
+	TSIGSecret    string `json:"tsig_secret,omitempty" hidden:"true"`
+	TSIGAlgorithm string `json:"tsig_algorithm,omitempty"`
+	TTL           string `json:"ttl,omitempty"`
+}
+
+type denylistJSON struct {
+	Files        []string `json:"files"`
+	UnpinMatches bool     `json:"unpin_matches"`
+}
+
+type faultInjectionJSON struct {
+	IPFS *faultRuleJSON `json:"ipfs,omitempty"`
+	RPC  *faultRuleJSON `json:"rpc,omitempty"`
+}
+
+type faultRuleJSON struct {
+	FailRate  float64 `json:"fail_rate"`
+	DelayRate float64 `json:"delay_rate"`
+	Delay     string  `json:"delay"`
+}
+
+func (frj *faultRuleJSON) load(name string, dst *FaultRule) error {
+	if frj == nil {
+		return nil
+	}
+	dst.FailRate = frj.FailRate
+	dst.DelayRate = frj.DelayRate
+	return config.ParseDurations("cluster",
+		&config.DurationOpt{Duration: frj.Delay, Dst: &dst.Delay, Name: "fault_injection." + name + ".delay"},
+	)
+}
+
+func newFaultRuleJSON(fr FaultRule) *faultRuleJSON {
+	if !fr.enabled() {
+		return nil
+	}
+	return &faultRuleJSON{
+		FailRate:  fr.FailRate,
+		DelayRate: fr.DelayRate,
+		Delay:     fr.Delay.String(),
+	}
+}
+
 // ConfigKey returns a human-readable string to identify
 // a cluster Config.
 func (cfg *Config) ConfigKey() string {
@@ -274,6 +1060,16 @@ func (cfg *Config) Validate() error {
 		return errors.New("cluster.pin_recover_interval is invalid")
 	}
 
+	for k := range cfg.PeerLabels {
+		if k == "" {
+			return errors.New("cluster.peer_labels cannot have empty keys")
+		}
+	}
+
+	if cfg.StartupWarmupRate < 0 {
+		return errors.New("cluster.startup_warmup_rate cannot be negative")
+	}
+
 	if cfg.MonitorPingInterval <= 0 {
 		return errors.New("cluster.monitoring_interval is invalid")
 	}
@@ -282,6 +1078,172 @@ func (cfg *Config) Validate() error {
 		return errors.New("cluster.peer_watch_interval is invalid")
 	}
 
+	if cfg.BroadcastTimeout < 0 {
+		return errors.New("cluster.broadcast_timeout is invalid")
+	}
+
+	if cfg.BroadcastConcurrency <= 0 {
+		return errors.New("cluster.broadcast_concurrency is invalid")
+	}
+
+	if cfg.PinAckTimeout <= 0 {
+		return errors.New("cluster.pin_ack_timeout is invalid")
+	}
+
+	if cfg.SecretTransitionWindow <= 0 {
+		return errors.New("cluster.secret_transition_window is invalid")
+	}
+
+	if cfg.ConnectivitySnapshotInterval < 0 {
+		return errors.New("cluster.connectivity_snapshot_interval is invalid")
+	}
+
+	if cfg.ConnectivityHistorySize <= 0 {
+		return errors.New("cluster.connectivity_history_size is invalid")
+	}
+
+	if cfg.UnpinGracePeriod < 0 {
+		return errors.New("cluster.unpin_grace_period is invalid")
+	}
+
+	if cfg.Repin.GracePeriod < 0 {
+		return errors.New("cluster.repin.grace_period is invalid")
+	}
+
+	if cfg.Repin.Concurrency <= 0 {
+		return errors.New("cluster.repin.concurrency is invalid")
+	}
+
+	if cfg.Popularity.Interval < 0 {
+		return errors.New("cluster.popularity.interval is invalid")
+	}
+
+	if cfg.Popularity.Interval > 0 {
+		if cfg.Popularity.MaxReplication <= 0 {
+			return errors.New("cluster.popularity.max_replication must be set when popularity is enabled")
+		}
+		if cfg.Popularity.ColdThreshold >= cfg.Popularity.HotThreshold {
+			return errors.New("cluster.popularity.cold_threshold must be lower than hot_threshold")
+		}
+	}
+
+	if cfg.PinValidation.enabled() {
+		if cfg.PinValidation.Timeout <= 0 {
+			return errors.New("cluster.pin_validation.timeout is invalid")
+		}
+		if cfg.PinValidation.URL != "" {
+			u, err := url.Parse(cfg.PinValidation.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				return errors.New("cluster.pin_validation.url must be an http or https URL")
+			}
+		}
+	}
+
+	if err := cfg.PinMetadata.Validate(); err != nil {
+		return fmt.Errorf("cluster.pin_metadata: %w", err)
+	}
+
+	if cfg.PinUpdateUnpin.GracePeriod < 0 {
+		return errors.New("cluster.pin_update_unpin.grace_period is invalid")
+	}
+	if cfg.PinUpdateUnpin.KeepVersions < 0 {
+		return errors.New("cluster.pin_update_unpin.keep_versions cannot be negative")
+	}
+
+	if cfg.IPNSTracking.Interval < 0 {
+		return errors.New("cluster.ipns_tracking.interval is invalid")
+	}
+	if cfg.IPNSTracking.Interval > 0 && cfg.IPNSTracking.Timeout <= 0 {
+		return errors.New("cluster.ipns_tracking.timeout is invalid")
+	}
+	if cfg.IPNSTracking.HistorySize <= 0 {
+		return errors.New("cluster.ipns_tracking.history_size must be positive")
+	}
+
+	if cfg.ReadThroughCache.Interval < 0 {
+		return errors.New("cluster.read_through_cache.interval is invalid")
+	}
+	if cfg.ReadThroughCache.Interval > 0 {
+		rtc := cfg.ReadThroughCache
+		if rtc.Threshold == 0 {
+			return errors.New("cluster.read_through_cache.threshold must be positive")
+		}
+		if rtc.ReplicationFactor == 0 || rtc.ReplicationFactor < -1 {
+			return errors.New("cluster.read_through_cache.replication_factor is invalid")
+		}
+		if rtc.TTL < rtc.Interval {
+			return errors.New("cluster.read_through_cache.ttl must be longer than the interval")
+		}
+		if rtc.MaxPins <= 0 {
+			return errors.New("cluster.read_through_cache.max_pins must be positive")
+		}
+	}
+
+	if cfg.StateBackup.Interval < 0 {
+		return errors.New("cluster.state_backup.interval is invalid")
+	}
+	if cfg.StateBackup.Interval > 0 {
+		sb := cfg.StateBackup
+		if sb.FullEvery <= 0 {
+			return errors.New("cluster.state_backup.full_every must be positive")
+		}
+		if sb.Keep <= 0 {
+			return errors.New("cluster.state_backup.keep must be positive")
+		}
+		if sb.ReplicationFactor == 0 || sb.ReplicationFactor < -1 {
+			return errors.New("cluster.state_backup.replication_factor is invalid")
+		}
+	}
+
+	if cfg.Tiering.Interval < 0 {
+		return errors.New("cluster.tiering.interval is invalid")
+	}
+	if cfg.Tiering.Interval > 0 {
+		tc := cfg.Tiering
+		if tc.Tag == "" || tc.Hot == "" || tc.Cold == "" {
+			return errors.New("cluster.tiering: tag, hot and cold must be set")
+		}
+		if tc.Hot == tc.Cold {
+			return errors.New("cluster.tiering: hot and cold must be different tiers")
+		}
+		if tc.ColdAfter <= 0 {
+			return errors.New("cluster.tiering.cold_after must be positive")
+		}
+	}
+
+	for meta := range cfg.AllocationExclusions {
+		if strings.Index(meta, "=") <= 0 {
+			return fmt.Errorf("cluster.allocation_exclusions: %q is not a key=value pair", meta)
+		}
+	}
+
+	for _, wh := range cfg.PinWebhooks {
+		if err := wh.validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, wh := range cfg.LifecycleWebhooks {
+		if err := wh.validate(); err != nil {
+			return err
+		}
+	}
+
+	if err := cfg.NamePublishing.validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.GatewayWarmup.validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.FaultInjection.IPFS.validate("ipfs"); err != nil {
+		return err
+	}
+	if err := cfg.FaultInjection.RPC.validate("rpc"); err != nil {
+		return err
+	}
+
 	rfMax := cfg.ReplicationFactorMax
 	rfMin := cfg.ReplicationFactorMin
 
@@ -316,6 +1278,38 @@ func isReplicationFactorValid(rplMin, rplMax int) error {
 	return nil
 }
 
+// applyRPCPolicyOverrides returns a copy of the given policy with the
+// endpoint types of the methods in overrides changed to the given ones.
+func applyRPCPolicyOverrides(policy map[string]RPCEndpointType, overrides map[string]string) (map[string]RPCEndpointType, error) {
+	res := make(map[string]RPCEndpointType, len(policy))
+	for k, v := range policy {
+		res[k] = v
+	}
+	for method, t := range overrides {
+		if _, ok := policy[method]; !ok {
+			return nil, fmt.Errorf("unknown RPC method %s", method)
+		}
+		endpointType, err := RPCEndpointTypeFromString(t)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", method, err)
+		}
+		res[method] = endpointType
+	}
+	return res, nil
+}
+
+// rpcPolicyOverrides returns the entries of the given policy which differ
+// from DefaultRPCPolicy.
+func rpcPolicyOverrides(policy map[string]RPCEndpointType) map[string]string {
+	overrides := make(map[string]string)
+	for method, t := range policy {
+		if def, ok := DefaultRPCPolicy[method]; !ok || def != t {
+			overrides[method] = t.String()
+		}
+	}
+	return overrides
+}
+
 func isRPCPolicyValid(p map[string]RPCEndpointType) error {
 	rpcComponents := []interface{}{
 		&ClusterRPCAPI{},
@@ -351,6 +1345,7 @@ func (cfg *Config) setDefaults() {
 		hostname = ""
 	}
 	cfg.Peername = hostname
+	cfg.PeerLabels = nil
 
 	listenAddrs := []ma.Multiaddr{}
 	for _, m := range DefaultListenAddrs {
@@ -374,10 +1369,78 @@ func (cfg *Config) setDefaults() {
 	cfg.PeerWatchInterval = DefaultPeerWatchInterval
 	cfg.MDNSInterval = DefaultMDNSInterval
 	cfg.DisableRepinning = DefaultDisableRepinning
+	cfg.Repin = RepinConfig{
+		GracePeriod: DefaultRepinGracePeriod,
+		Concurrency: DefaultRepinConcurrency,
+	}
 	cfg.FollowerMode = DefaultFollowerMode
+	cfg.ResolveDAGSize = DefaultResolveDAGSize
+	cfg.BroadcastTimeout = DefaultBroadcastTimeout
+	cfg.BroadcastConcurrency = DefaultBroadcastConcurrency
+	cfg.PinAckTimeout = DefaultPinAckTimeout
+	cfg.SecretTransitionWindow = DefaultSecretTransitionWindow
+	cfg.ConnectivitySnapshotInterval = DefaultConnectivitySnapshotInterval
+	cfg.ConnectivityHistorySize = DefaultConnectivityHistorySize
+	cfg.Popularity = PopularityConfig{
+		Interval:      DefaultPopularityInterval,
+		HotThreshold:  DefaultPopularityHotThreshold,
+		ColdThreshold: DefaultPopularityColdThreshold,
+	}
+	cfg.PinValidation = PinValidationConfig{
+		Timeout: DefaultPinValidationTimeout,
+	}
+	cfg.PinMetadata = api.MetadataPolicy{}
+	cfg.PinUpdateUnpin = PinUpdateUnpinConfig{
+		GracePeriod: DefaultPinUpdateUnpinGracePeriod,
+	}
+	cfg.IPNSTracking = IPNSTrackingConfig{
+		Interval:    DefaultIPNSTrackingInterval,
+		Timeout:     DefaultIPNSTrackingTimeout,
+		HistorySize: DefaultIPNSTrackingHistorySize,
+	}
+	cfg.ReadThroughCache = ReadThroughCacheConfig{
+		Interval:          DefaultReadThroughCacheInterval,
+		Threshold:         DefaultReadThroughCacheThreshold,
+		ReplicationFactor: DefaultReadThroughCacheReplicationFactor,
+		TTL:               DefaultReadThroughCacheTTL,
+		MaxPins:           DefaultReadThroughCacheMaxPins,
+	}
+	cfg.StateBackup = StateBackupConfig{
+		Interval:          DefaultStateBackupInterval,
+		FullEvery:         DefaultStateBackupFullEvery,
+		Keep:              DefaultStateBackupKeep,
+		ReplicationFactor: DefaultStateBackupReplicationFactor,
+	}
+	cfg.Tiering = TieringConfig{
+		Interval:  DefaultTieringInterval,
+		Tag:       DefaultTieringTag,
+		Hot:       DefaultTieringHot,
+		Cold:      DefaultTieringCold,
+		ColdAfter: DefaultTieringColdAfter,
+	}
+	cfg.AllocationExclusions = nil
+	cfg.PinWebhooks = nil
+	cfg.LifecycleWebhooks = nil
+	cfg.NamePublishing = NamePublishingConfig{
+		Timeout: DefaultNamePublishingTimeout,
+		DNSLink: DNSLinkConfig{
+			TTL: DefaultDNSLinkTTL,
+		},
+	}
+	cfg.GatewayWarmup = GatewayWarmupConfig{
+		Concurrency: DefaultGatewayWarmupConcurrency,
+		Timeout:     DefaultGatewayWarmupTimeout,
+		WaitTimeout: DefaultGatewayWarmupWaitTimeout,
+	}
+	cfg.Denylist = DenylistConfig{
+		Files: []string{},
+	}
 	cfg.PeerstoreFile = "" // empty so it gets omitted.
 	cfg.PeerAddresses = []ma.Multiaddr{}
-	cfg.RPCPolicy = DefaultRPCPolicy
+	cfg.UnpinGracePeriod = DefaultUnpinGracePeriod
+	cfg.ProtectedCIDs = []cid.Cid{}
+	// Copied, so that modifying the policy never changes the defaults.
+	cfg.RPCPolicy, _ = applyRPCPolicyOverrides(DefaultRPCPolicy, nil)
 }
 
 // LoadJSON receives a raw json-formatted configuration and
@@ -400,6 +1463,7 @@ func (cfg *Config) applyConfigJSON(jcfg *configJSON) error {
 	config.SetIfNotDefault(jcfg.PeerstoreFile, &cfg.PeerstoreFile)
 
 	config.SetIfNotDefault(jcfg.Peername, &cfg.Peername)
+	cfg.PeerLabels = jcfg.PeerLabels
 
 	clusterSecret, err := DecodeClusterSecret(jcfg.Secret)
 	if err != nil {
@@ -433,6 +1497,225 @@ func (cfg *Config) applyConfigJSON(jcfg *configJSON) error {
 		}
 	}
 
+	if repin := jcfg.Repin; repin != nil {
+		config.SetIfNotDefault(repin.Concurrency, &cfg.Repin.Concurrency)
+		cfg.Repin.OnlyUnderReplicated = repin.OnlyUnderReplicated
+		err = config.ParseDurations("cluster",
+			&config.DurationOpt{Duration: repin.GracePeriod, Dst: &cfg.Repin.GracePeriod, Name: "repin.grace_period"},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if pop := jcfg.Popularity; pop != nil {
+		cfg.Popularity.HotThreshold = pop.HotThreshold
+		cfg.Popularity.ColdThreshold = pop.ColdThreshold
+		cfg.Popularity.MaxReplication = pop.MaxReplication
+		cfg.Popularity.AccessLog = pop.AccessLog
+		err = config.ParseDurations("cluster",
+			&config.DurationOpt{Duration: pop.Interval, Dst: &cfg.Popularity.Interval, Name: "popularity.interval"},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if pv := jcfg.PinValidation; pv != nil {
+		cfg.PinValidation.URL = pv.URL
+		cfg.PinValidation.Command = pv.Command
+		cfg.PinValidation.FailOpen = pv.FailOpen
+		err = config.ParseDurations("cluster",
+			&config.DurationOpt{Duration: pv.Timeout, Dst: &cfg.PinValidation.Timeout, Name: "pin_validation.timeout"},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if jcfg.PinMetadata != nil {
+		cfg.PinMetadata = *jcfg.PinMetadata
+	}
+
+	if pu := jcfg.PinUpdateUnpin; pu != nil {
+		cfg.PinUpdateUnpin.Enabled = pu.Enabled
+		cfg.PinUpdateUnpin.KeepVersions = pu.KeepVersions
+		err = config.ParseDurations("cluster",
+			&config.DurationOpt{Duration: pu.GracePeriod, Dst: &cfg.PinUpdateUnpin.GracePeriod, Name: "pin_update_unpin.grace_period"},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if it := jcfg.IPNSTracking; it != nil {
+		config.SetIfNotDefault(it.HistorySize, &cfg.IPNSTracking.HistorySize)
+		err = config.ParseDurations("cluster",
+			&config.DurationOpt{Duration: it.Interval, Dst: &cfg.IPNSTracking.Interval, Name: "ipns_tracking.interval"},
+			&config.DurationOpt{Duration: it.Timeout, Dst: &cfg.IPNSTracking.Timeout, Name: "ipns_tracking.timeout"},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if rtc := jcfg.ReadThroughCache; rtc != nil {
+		config.SetIfNotDefault(rtc.Threshold, &cfg.ReadThroughCache.Threshold)
+		config.SetIfNotDefault(rtc.ReplicationFactor, &cfg.ReadThroughCache.ReplicationFactor)
+		config.SetIfNotDefault(rtc.MaxPins, &cfg.ReadThroughCache.MaxPins)
+		cfg.ReadThroughCache.AccessLog = rtc.AccessLog
+		err = config.ParseDurations("cluster",
+			&config.DurationOpt{Duration: rtc.Interval, Dst: &cfg.ReadThroughCache.Interval, Name: "read_through_cache.interval"},
+			&config.DurationOpt{Duration: rtc.TTL, Dst: &cfg.ReadThroughCache.TTL, Name: "read_through_cache.ttl"},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if sb := jcfg.StateBackup; sb != nil {
+		config.SetIfNotDefault(sb.FullEvery, &cfg.StateBackup.FullEvery)
+		config.SetIfNotDefault(sb.Keep, &cfg.StateBackup.Keep)
+		config.SetIfNotDefault(sb.ReplicationFactor, &cfg.StateBackup.ReplicationFactor)
+		err = config.ParseDurations("cluster",
+			&config.DurationOpt{Duration: sb.Interval, Dst: &cfg.StateBackup.Interval, Name: "state_backup.interval"},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if tc := jcfg.Tiering; tc != nil {
+		config.SetIfNotDefault(tc.Tag, &cfg.Tiering.Tag)
+		config.SetIfNotDefault(tc.Hot, &cfg.Tiering.Hot)
+		config.SetIfNotDefault(tc.Cold, &cfg.Tiering.Cold)
+		err = config.ParseDurations("cluster",
+			&config.DurationOpt{Duration: tc.Interval, Dst: &cfg.Tiering.Interval, Name: "tiering.interval"},
+			&config.DurationOpt{Duration: tc.ColdAfter, Dst: &cfg.Tiering.ColdAfter, Name: "tiering.cold_after"},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	cfg.AllocationExclusions = nil
+	for meta, peers := range jcfg.AllocationExclusions {
+		excluded := make([]peer.ID, 0, len(peers))
+		for _, s := range peers {
+			pid, err := peer.Decode(s)
+			if err != nil {
+				return fmt.Errorf("error parsing allocation_exclusions for %q: %s", meta, err)
+			}
+			excluded = append(excluded, pid)
+		}
+		if cfg.AllocationExclusions == nil {
+			cfg.AllocationExclusions = make(map[string][]peer.ID)
+		}
+		cfg.AllocationExclusions[meta] = excluded
+	}
+
+	cfg.PinWebhooks = nil
+	for _, wh := range jcfg.PinWebhooks {
+		webhook := PinWebhookConfig{
+			URL:     wh.URL,
+			Events:  wh.Events,
+			Timeout: DefaultPinWebhookTimeout,
+			Retries: DefaultPinWebhookRetries,
+		}
+		if wh.Retries != nil {
+			webhook.Retries = *wh.Retries
+		}
+		err = config.ParseDurations("cluster",
+			&config.DurationOpt{Duration: wh.Timeout, Dst: &webhook.Timeout, Name: "pin_webhooks.timeout"},
+		)
+		if err != nil {
+			return err
+		}
+		cfg.PinWebhooks = append(cfg.PinWebhooks, webhook)
+	}
+
+	cfg.LifecycleWebhooks = nil
+	for _, wh := range jcfg.LifecycleWebhooks {
+		webhook := LifecycleWebhookConfig{
+			URL:     wh.URL,
+			Events:  wh.Events,
+			Timeout: DefaultPinWebhookTimeout,
+			Retries: DefaultPinWebhookRetries,
+		}
+		if wh.Retries != nil {
+			webhook.Retries = *wh.Retries
+		}
+		err = config.ParseDurations("cluster",
+			&config.DurationOpt{Duration: wh.Timeout, Dst: &webhook.Timeout, Name: "lifecycle_webhooks.timeout"},
+		)
+		if err != nil {
+			return err
+		}
+		cfg.LifecycleWebhooks = append(cfg.LifecycleWebhooks, webhook)
+	}
+
+	if np := jcfg.NamePublishing; np != nil {
+		cfg.NamePublishing.Enabled = np.Enabled
+		cfg.NamePublishing.IPNSKeys = np.IPNSKeys
+		cfg.NamePublishing.DNSLinkDomains = np.DNSLinkDomains
+		err = config.ParseDurations("cluster",
+			&config.DurationOpt{Duration: np.Timeout, Dst: &cfg.NamePublishing.Timeout, Name: "name_publishing.timeout"},
+		)
+		if err != nil {
+			return err
+		}
+		if dl := np.DNSLink; dl != nil {
+			cfg.NamePublishing.DNSLink.Provider = dl.Provider
+			cfg.NamePublishing.DNSLink.URL = dl.URL
+			cfg.NamePublishing.DNSLink.Server = dl.Server
+			cfg.NamePublishing.DNSLink.Zone = dl.Zone
+			cfg.NamePublishing.DNSLink.TSIGKeyName = dl.TSIGKeyName
+			cfg.NamePublishing.DNSLink.TSIGSecret = dl.TSIGSecret
+			cfg.NamePublishing.DNSLink.TSIGAlgorithm = dl.TSIGAlgorithm
+			err = config.ParseDurations("cluster",
+				&config.DurationOpt{Duration: dl.TTL, Dst: &cfg.NamePublishing.DNSLink.TTL, Name: "name_publishing.dnslink.ttl"},
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if gw := jcfg.GatewayWarmup; gw != nil {
+		cfg.GatewayWarmup.Gateways = gw.Gateways
+		config.SetIfNotDefault(gw.Concurrency, &cfg.GatewayWarmup.Concurrency)
+		err = config.ParseDurations("cluster",
+			&config.DurationOpt{Duration: gw.Timeout, Dst: &cfg.GatewayWarmup.Timeout, Name: "gateway_warmup.timeout"},
+			&config.DurationOpt{Duration: gw.WaitTimeout, Dst: &cfg.GatewayWarmup.WaitTimeout, Name: "gateway_warmup.wait_timeout"},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if dl := jcfg.Denylist; dl != nil {
+		cfg.Denylist.Files = dl.Files
+		cfg.Denylist.UnpinMatches = dl.UnpinMatches
+	}
+
+	if fi := jcfg.FaultInjection; fi != nil {
+		if err := fi.IPFS.load("ipfs", &cfg.FaultInjection.IPFS); err != nil {
+			return err
+		}
+		if err := fi.RPC.load("rpc", &cfg.FaultInjection.RPC); err != nil {
+			return err
+		}
+	}
+
+	// rpc_policy only contains the entries that differ from the
+	// default policy.
+	if len(jcfg.RPCPolicy) > 0 {
+		cfg.RPCPolicy, err = applyRPCPolicyOverrides(DefaultRPCPolicy, jcfg.RPCPolicy)
+		if err != nil {
+			return fmt.Errorf("error parsing cluster.rpc_policy: %w", err)
+		}
+	}
+
 	rplMin := jcfg.ReplicationFactorMin
 	rplMax := jcfg.ReplicationFactorMax
 	config.SetIfNotDefault(rplMin, &cfg.ReplicationFactorMin)
@@ -445,6 +1728,11 @@ func (cfg *Config) applyConfigJSON(jcfg *configJSON) error {
 		&config.DurationOpt{Duration: jcfg.MonitorPingInterval, Dst: &cfg.MonitorPingInterval, Name: "monitor_ping_interval"},
 		&config.DurationOpt{Duration: jcfg.PeerWatchInterval, Dst: &cfg.PeerWatchInterval, Name: "peer_watch_interval"},
 		&config.DurationOpt{Duration: jcfg.MDNSInterval, Dst: &cfg.MDNSInterval, Name: "mdns_interval"},
+		&config.DurationOpt{Duration: jcfg.BroadcastTimeout, Dst: &cfg.BroadcastTimeout, Name: "broadcast_timeout"},
+		&config.DurationOpt{Duration: jcfg.PinAckTimeout, Dst: &cfg.PinAckTimeout, Name: "pin_ack_timeout"},
+		&config.DurationOpt{Duration: jcfg.SecretTransitionWindow, Dst: &cfg.SecretTransitionWindow, Name: "secret_transition_window"},
+		&config.DurationOpt{Duration: jcfg.ConnectivitySnapshotInterval, Dst: &cfg.ConnectivitySnapshotInterval, Name: "connectivity_snapshot_interval"},
+		&config.DurationOpt{Duration: jcfg.UnpinGracePeriod, Dst: &cfg.UnpinGracePeriod, Name: "unpin_grace_period"},
 	)
 	if err != nil {
 		return err
@@ -461,9 +1749,26 @@ func (cfg *Config) applyConfigJSON(jcfg *configJSON) error {
 		peerAddrs = append(peerAddrs, peerAddr)
 	}
 	cfg.PeerAddresses = peerAddrs
+
+	protected := []cid.Cid{}
+	for _, s := range jcfg.ProtectedCIDs {
+		ci, err := cid.Decode(s)
+		if err != nil {
+			return fmt.Errorf("error parsing protected_cids: %s", err)
+		}
+		protected = append(protected, ci)
+	}
+	cfg.ProtectedCIDs = protected
+
 	cfg.LeaveOnShutdown = jcfg.LeaveOnShutdown
 	cfg.DisableRepinning = jcfg.DisableRepinning
 	cfg.FollowerMode = jcfg.FollowerMode
+	cfg.ResolveDAGSize = jcfg.ResolveDAGSize
+	cfg.UniquePinNames = jcfg.UniquePinNames
+	config.SetIfNotDefault(jcfg.BroadcastConcurrency, &cfg.BroadcastConcurrency)
+	config.SetIfNotDefault(jcfg.StartupWarmupRate, &cfg.StartupWarmupRate)
+	config.SetIfNotDefault(jcfg.MinFreeSpace, &cfg.MinFreeSpace)
+	config.SetIfNotDefault(jcfg.ConnectivityHistorySize, &cfg.ConnectivityHistorySize)
 
 	return cfg.Validate()
 }
@@ -491,6 +1796,7 @@ func (cfg *Config) toConfigJSON() (jcfg *configJSON, err error) {
 
 	// Set all configuration fields
 	jcfg.Peername = cfg.Peername
+	jcfg.PeerLabels = cfg.PeerLabels
 	jcfg.Secret = EncodeProtectorKey(cfg.Secret)
 	jcfg.ReplicationFactorMin = cfg.ReplicationFactorMin
 	jcfg.ReplicationFactorMax = cfg.ReplicationFactorMax
@@ -509,16 +1815,153 @@ func (cfg *Config) toConfigJSON() (jcfg *configJSON, err error) {
 	jcfg.DialPeerTimeout = cfg.DialPeerTimeout.String()
 	jcfg.StateSyncInterval = cfg.StateSyncInterval.String()
 	jcfg.PinRecoverInterval = cfg.PinRecoverInterval.String()
+	jcfg.StartupWarmupRate = cfg.StartupWarmupRate
+	jcfg.MinFreeSpace = cfg.MinFreeSpace
 	jcfg.MonitorPingInterval = cfg.MonitorPingInterval.String()
 	jcfg.PeerWatchInterval = cfg.PeerWatchInterval.String()
 	jcfg.MDNSInterval = cfg.MDNSInterval.String()
 	jcfg.DisableRepinning = cfg.DisableRepinning
+	jcfg.Repin = &repinConfigJSON{
+		GracePeriod:         cfg.Repin.GracePeriod.String(),
+		Concurrency:         cfg.Repin.Concurrency,
+		OnlyUnderReplicated: cfg.Repin.OnlyUnderReplicated,
+	}
 	jcfg.PeerstoreFile = cfg.PeerstoreFile
 	jcfg.PeerAddresses = []string{}
 	for _, addr := range cfg.PeerAddresses {
 		jcfg.PeerAddresses = append(jcfg.PeerAddresses, addr.String())
 	}
 	jcfg.FollowerMode = cfg.FollowerMode
+	jcfg.ResolveDAGSize = cfg.ResolveDAGSize
+	jcfg.UniquePinNames = cfg.UniquePinNames
+	jcfg.BroadcastTimeout = cfg.BroadcastTimeout.String()
+	jcfg.BroadcastConcurrency = cfg.BroadcastConcurrency
+	jcfg.PinAckTimeout = cfg.PinAckTimeout.String()
+	jcfg.SecretTransitionWindow = cfg.SecretTransitionWindow.String()
+	jcfg.ConnectivitySnapshotInterval = cfg.ConnectivitySnapshotInterval.String()
+	jcfg.UnpinGracePeriod = cfg.UnpinGracePeriod.String()
+	jcfg.ConnectivityHistorySize = cfg.ConnectivityHistorySize
+	jcfg.Popularity = &popularityConfigJSON{
+		Interval:       cfg.Popularity.Interval.String(),
+		HotThreshold:   cfg.Popularity.HotThreshold,
+		ColdThreshold:  cfg.Popularity.ColdThreshold,
+		MaxReplication: cfg.Popularity.MaxReplication,
+		AccessLog:      cfg.Popularity.AccessLog,
+	}
+	jcfg.PinValidation = &pinValidationJSON{
+		URL:      cfg.PinValidation.URL,
+		Command:  cfg.PinValidation.Command,
+		Timeout:  cfg.PinValidation.Timeout.String(),
+		FailOpen: cfg.PinValidation.FailOpen,
+	}
+	if pm := cfg.PinMetadata; !pm.IsEmpty() {
+		jcfg.PinMetadata = &pm
+	}
+	if pu := cfg.PinUpdateUnpin; pu.Enabled {
+		jcfg.PinUpdateUnpin = &pinUpdateUnpinJSON{
+			Enabled:      pu.Enabled,
+			GracePeriod:  pu.GracePeriod.String(),
+			KeepVersions: pu.KeepVersions,
+		}
+	}
+	jcfg.IPNSTracking = &ipnsTrackingJSON{
+		Interval:    cfg.IPNSTracking.Interval.String(),
+		Timeout:     cfg.IPNSTracking.Timeout.String(),
+		HistorySize: cfg.IPNSTracking.HistorySize,
+	}
+	if rtc := cfg.ReadThroughCache; rtc.Interval > 0 {
+		jcfg.ReadThroughCache = &readThroughCacheJSON{
+			Interval:          rtc.Interval.String(),
+			Threshold:         rtc.Threshold,
+			ReplicationFactor: rtc.ReplicationFactor,
+			TTL:               rtc.TTL.String(),
+			MaxPins:           rtc.MaxPins,
+			AccessLog:         rtc.AccessLog,
+		}
+	}
+	if sb := cfg.StateBackup; sb.Interval > 0 {
+		jcfg.StateBackup = &stateBackupJSON{
+			Interval:          sb.Interval.String(),
+			FullEvery:         sb.FullEvery,
+			Keep:              sb.Keep,
+			ReplicationFactor: sb.ReplicationFactor,
+		}
+	}
+	if tc := cfg.Tiering; tc.Interval > 0 {
+		jcfg.Tiering = &tieringJSON{
+			Interval:  tc.Interval.String(),
+			Tag:       tc.Tag,
+			Hot:       tc.Hot,
+			Cold:      tc.Cold,
+			ColdAfter: tc.ColdAfter.String(),
+		}
+	}
+	for meta, peers := range cfg.AllocationExclusions {
+		if jcfg.AllocationExclusions == nil {
+			jcfg.AllocationExclusions = make(map[string][]string)
+		}
+		jcfg.AllocationExclusions[meta] = api.PeersToStrings(peers)
+	}
+	for _, wh := range cfg.PinWebhooks {
+		retries := wh.Retries
+		jcfg.PinWebhooks = append(jcfg.PinWebhooks, &pinWebhookJSON{
+			URL:     wh.URL,
+			Events:  wh.Events,
+			Timeout: wh.Timeout.String(),
+			Retries: &retries,
+		})
+	}
+	for _, wh := range cfg.LifecycleWebhooks {
+		retries := wh.Retries
+		jcfg.LifecycleWebhooks = append(jcfg.LifecycleWebhooks, &lifecycleWebhookJSON{
+			URL:     wh.URL,
+			Events:  wh.Events,
+			Timeout: wh.Timeout.String(),
+			Retries: &retries,
+		})
+	}
+	if np := cfg.NamePublishing; np.Enabled {
+		jcfg.NamePublishing = &namePublishingJSON{
+			Enabled:        np.Enabled,
+			Timeout:        np.Timeout.String(),
+			IPNSKeys:       np.IPNSKeys,
+			DNSLinkDomains: np.DNSLinkDomains,
+		}
+		if dl := np.DNSLink; dl.Provider != "" {
+			jcfg.NamePublishing.DNSLink = &dnslinkJSON{
+				Provider:      dl.Provider,
+				URL:           dl.URL,
+				Server:        dl.Server,
+				Zone:          dl.Zone,
+				TSIGKeyName:   dl.TSIGKeyName,
+				TSIGSecret:    dl.TSIGSecret,
+				TSIGAlgorithm: dl.TSIGAlgorithm,
+				TTL:           dl.TTL.String(),
+			}
+		}
+	}
+	if gw := cfg.GatewayWarmup; len(gw.Gateways) > 0 {
+		jcfg.GatewayWarmup = &gatewayWarmupJSON{
+			Gateways:    gw.Gateways,
+			Concurrency: gw.Concurrency,
+			Timeout:     gw.Timeout.String(),
+			WaitTimeout: gw.WaitTimeout.String(),
+		}
+	}
+	jcfg.Denylist = &denylistJSON{
+		Files:        cfg.Denylist.Files,
+		UnpinMatches: cfg.Denylist.UnpinMatches,
+	}
+	if cfg.FaultInjection.enabled() {
+		jcfg.FaultInjection = &faultInjectionJSON{
+			IPFS: newFaultRuleJSON(cfg.FaultInjection.IPFS),
+			RPC:  newFaultRuleJSON(cfg.FaultInjection.RPC),
+		}
+	}
+	for _, ci := range cfg.ProtectedCIDs {
+		jcfg.ProtectedCIDs = append(jcfg.ProtectedCIDs, ci.String())
+	}
+	jcfg.RPCPolicy = rpcPolicyOverrides(cfg.RPCPolicy)
 
 	return
 }
@@ -540,6 +1983,39 @@ func (cfg *Config) GetPeerstorePath() string {
 	return filepath.Join(cfg.BaseDir, filename)
 }
 
+// GetDenylistPaths returns the full paths of the denylist files. Relative
+// paths are joined with the BaseDir of the configuration, when set.
+func (cfg *Config) GetDenylistPaths() []string {
+	paths := make([]string, 0, len(cfg.Denylist.Files))
+	for _, f := range cfg.Denylist.Files {
+		if !filepath.IsAbs(f) && cfg.BaseDir != "" {
+			f = filepath.Join(cfg.BaseDir, f)
+		}
+		paths = append(paths, f)
+	}
+	return paths
+}
+
+// GetAccessLogPath returns the full path of the ReadThroughCache.AccessLog,
+// joined with the BaseDir of the configuration when relative.
+func (cfg *Config) GetAccessLogPath() string {
+	return cfg.accessLogPath(cfg.ReadThroughCache.AccessLog)
+}
+
+// GetPopularityAccessLogPath returns the full path of the
+// Popularity.AccessLog, joined with the BaseDir of the configuration when
+// relative.
+func (cfg *Config) GetPopularityAccessLogPath() string {
+	return cfg.accessLogPath(cfg.Popularity.AccessLog)
+}
+
+func (cfg *Config) accessLogPath(f string) string {
+	if f != "" && !filepath.IsAbs(f) && cfg.BaseDir != "" {
+		f = filepath.Join(cfg.BaseDir, f)
+	}
+	return f
+}
+
 // ToDisplayJSON returns JSON config as a string.
 func (cfg *Config) ToDisplayJSON() ([]byte, error) {
 	jcfg, err := cfg.toConfigJSON()
@@ -6,12 +6,18 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ipfs/ipfs-cluster/api"
+
 	ipfsconfig "github.com/ipfs/go-ipfs-config"
+	peer "github.com/libp2p/go-libp2p-core/peer"
 )
 
 var ccfgTestJSON = []byte(`
 {
         "peername": "testpeer",
+        "peer_labels": {
+            "region": "eu-west"
+        },
         "secret": "2588b80d5cb05374fa142aed6cbb047d1f4ef8ef15e37eba68c65b9d30df67ed",
         "leave_on_shutdown": true,
         "connection_manager": {
@@ -25,10 +31,114 @@ var ccfgTestJSON = []byte(`
         ],
         "state_sync_interval": "1m0s",
         "pin_recover_interval": "1m",
+        "startup_warmup_rate": 50,
+        "min_free_space": 1048576,
+        "allocation_exclusions": {
+            "jurisdiction=restricted": ["QmXZrtE5jQwXNqCJMfHUTQkvhQ4ZAnqMnmzFMJfLewuabc"]
+        },
         "replication_factor_min": 5,
         "replication_factor_max": 5,
         "monitor_ping_interval": "2s",
         "disable_repinning": true,
+        "connectivity_snapshot_interval": "5m",
+        "repin": {
+            "grace_period": "5m",
+            "concurrency": 4,
+            "only_under_replicated": true
+        },
+        "resolve_dag_size": true,
+        "popularity": {
+            "interval": "10m",
+            "hot_threshold": 50,
+            "cold_threshold": 0,
+            "max_replication": 4,
+            "access_log": "gateway.log"
+        },
+        "pin_validation": {
+            "url": "http://127.0.0.1:9999/validate",
+            "timeout": "3s",
+            "fail_open": true
+        },
+        "pin_metadata": {
+            "max_keys": 10,
+            "max_value_size": 256,
+            "schema": {
+                "type": "object",
+                "properties": {
+                    "tier": {"type": "string", "enum": ["hot", "cold"]}
+                }
+            }
+        },
+        "pin_webhooks": [
+            {
+                "url": "http://127.0.0.1:9999/pinset",
+                "events": ["added", "removed"],
+                "timeout": "5s"
+            },
+            {
+                "url": "https://example.org/hook",
+                "retries": 0
+            }
+        ],
+        "lifecycle_webhooks": [
+            {
+                "url": "http://127.0.0.1:9999/lifecycle",
+                "events": ["peer_joined", "peer_left"]
+            }
+        ],
+        "gateway_warmup": {
+            "gateways": ["https://ipfs.io", "https://{cid}.ipfs.dweb.link/"],
+            "wait_timeout": "10m"
+        },
+        "pin_update_unpin": {
+            "enabled": true,
+            "keep_versions": 2
+        },
+        "ipns_tracking": {
+            "interval": "1h",
+            "history_size": 5
+        },
+        "read_through_cache": {
+            "interval": "1m",
+            "threshold": 20,
+            "ttl": "6h",
+            "access_log": "access.log"
+        },
+        "state_backup": {
+            "interval": "1h",
+            "full_every": 12
+        },
+        "tiering": {
+            "interval": "10m",
+            "tag": "storage",
+            "cold_after": "168h"
+        },
+        "name_publishing": {
+            "enabled": true,
+            "timeout": "30s",
+            "ipns_keys": ["site"],
+            "dnslink_domains": ["example.org"],
+            "dnslink": {
+                "provider": "rfc2136",
+                "server": "127.0.0.1:53",
+                "zone": "example.org",
+                "tsig_key_name": "cluster",
+                "tsig_secret": "c2VjcmV0"
+            }
+        },
+        "denylist": {
+            "files": ["badbits.deny", "/etc/ipfs/extra.deny"],
+            "unpin_matches": true
+        },
+        "fault_injection": {
+            "rpc": {
+                "fail_rate": 0.1,
+                "delay_rate": 0.5,
+                "delay": "2s"
+            }
+        },
+        "unpin_grace_period": "24h",
+        "protected_cids": ["QmP63DkAFEnDYNjDYBpyNDfttu1fvUw99x1brscPzpqmmq"],
         "peer_addresses": [ "/ip4/127.0.0.1/tcp/1234/p2p/QmXZrtE5jQwXNqCJMfHUTQkvhQ4ZAnqMnmzFMJfLewuabc" ]
 }
 `)
@@ -72,6 +182,205 @@ func TestLoadJSON(t *testing.T) {
 		}
 	})
 
+	t.Run("expected connectivity history", func(t *testing.T) {
+		cfg := loadJSON(t)
+		if cfg.ConnectivitySnapshotInterval != 5*time.Minute ||
+			cfg.ConnectivityHistorySize != DefaultConnectivityHistorySize {
+			t.Error("unexpected connectivity history options")
+		}
+	})
+
+	t.Run("expected repin", func(t *testing.T) {
+		cfg := loadJSON(t)
+		repin := cfg.Repin
+		if repin.GracePeriod != 5*time.Minute ||
+			repin.Concurrency != 4 ||
+			!repin.OnlyUnderReplicated {
+			t.Errorf("unexpected repin config: %+v", repin)
+		}
+	})
+
+	t.Run("expected resolve_dag_size", func(t *testing.T) {
+		cfg := loadJSON(t)
+		if !cfg.ResolveDAGSize {
+			t.Error("expected resolve_dag_size to be true")
+		}
+	})
+
+	t.Run("expected popularity", func(t *testing.T) {
+		cfg := loadJSON(t)
+		pop := cfg.Popularity
+		if pop.Interval != 10*time.Minute ||
+			pop.HotThreshold != 50 ||
+			pop.ColdThreshold != 0 ||
+			pop.MaxReplication != 4 ||
+			pop.AccessLog != "gateway.log" {
+			t.Errorf("unexpected popularity config: %+v", pop)
+		}
+	})
+
+	t.Run("expected pin_validation", func(t *testing.T) {
+		cfg := loadJSON(t)
+		pv := cfg.PinValidation
+		if pv.URL != "http://127.0.0.1:9999/validate" ||
+			pv.Timeout != 3*time.Second ||
+			!pv.FailOpen {
+			t.Errorf("unexpected pin_validation config: %+v", pv)
+		}
+	})
+
+	t.Run("expected pin_metadata", func(t *testing.T) {
+		cfg := loadJSON(t)
+		pm := cfg.PinMetadata
+		if pm.MaxKeys != 10 || pm.MaxValueSize != 256 || pm.Schema == nil ||
+			len(pm.Schema.Properties["tier"].Enum) != 2 {
+			t.Errorf("unexpected pin_metadata config: %+v", pm)
+		}
+		if err := pm.Check(map[string]string{"tier": "warm"}); err == nil {
+			t.Error("expected the schema to be compiled and enforced")
+		}
+	})
+
+	t.Run("expected pin_webhooks", func(t *testing.T) {
+		cfg := loadJSON(t)
+		if len(cfg.PinWebhooks) != 2 {
+			t.Fatalf("expected 2 webhooks: %+v", cfg.PinWebhooks)
+		}
+		wh := cfg.PinWebhooks[0]
+		if wh.URL != "http://127.0.0.1:9999/pinset" ||
+			wh.Timeout != 5*time.Second ||
+			wh.Retries != DefaultPinWebhookRetries ||
+			wh.wants(api.PinsetEventUpdated) || !wh.wants(api.PinsetEventRemoved) {
+			t.Errorf("unexpected pin_webhooks config: %+v", wh)
+		}
+		wh = cfg.PinWebhooks[1]
+		if wh.Timeout != DefaultPinWebhookTimeout || wh.Retries != 0 || !wh.wants(api.PinsetEventUpdated) {
+			t.Errorf("unexpected pin_webhooks config: %+v", wh)
+		}
+	})
+
+	t.Run("expected lifecycle_webhooks", func(t *testing.T) {
+		cfg := loadJSON(t)
+		if len(cfg.LifecycleWebhooks) != 1 {
+			t.Fatalf("expected 1 webhook: %+v", cfg.LifecycleWebhooks)
+		}
+		wh := cfg.LifecycleWebhooks[0]
+		if wh.URL != "http://127.0.0.1:9999/lifecycle" ||
+			wh.Timeout != DefaultPinWebhookTimeout ||
+			wh.Retries != DefaultPinWebhookRetries ||
+			!wh.wants(api.LifecycleEventPeerLeft) || wh.wants(api.LifecycleEventAPIStarted) {
+			t.Errorf("unexpected lifecycle_webhooks config: %+v", wh)
+		}
+	})
+
+	t.Run("expected gateway_warmup", func(t *testing.T) {
+		cfg := loadJSON(t)
+		gw := cfg.GatewayWarmup
+		if len(gw.Gateways) != 2 || gw.Concurrency != DefaultGatewayWarmupConcurrency ||
+			gw.Timeout != DefaultGatewayWarmupTimeout || gw.WaitTimeout != 10*time.Minute {
+			t.Errorf("unexpected gateway_warmup config: %+v", gw)
+		}
+	})
+
+	t.Run("expected pin_update_unpin", func(t *testing.T) {
+		cfg := loadJSON(t)
+		pu := cfg.PinUpdateUnpin
+		if !pu.Enabled || pu.KeepVersions != 2 || pu.GracePeriod != DefaultPinUpdateUnpinGracePeriod {
+			t.Errorf("unexpected pin_update_unpin config: %+v", pu)
+		}
+	})
+
+	t.Run("expected ipns_tracking", func(t *testing.T) {
+		cfg := loadJSON(t)
+		it := cfg.IPNSTracking
+		if it.Interval != time.Hour || it.HistorySize != 5 || it.Timeout != DefaultIPNSTrackingTimeout {
+			t.Errorf("unexpected ipns_tracking config: %+v", it)
+		}
+	})
+
+	t.Run("expected read_through_cache", func(t *testing.T) {
+		cfg := loadJSON(t)
+		rtc := cfg.ReadThroughCache
+		if rtc.Interval != time.Minute || rtc.Threshold != 20 || rtc.TTL != 6*time.Hour || rtc.AccessLog != "access.log" {
+			t.Errorf("unexpected read_through_cache config: %+v", rtc)
+		}
+		if rtc.ReplicationFactor != DefaultReadThroughCacheReplicationFactor || rtc.MaxPins != DefaultReadThroughCacheMaxPins {
+			t.Errorf("expected the read_through_cache defaults: %+v", rtc)
+		}
+	})
+
+	t.Run("expected state_backup", func(t *testing.T) {
+		cfg := loadJSON(t)
+		sb := cfg.StateBackup
+		if sb.Interval != time.Hour || sb.FullEvery != 12 {
+			t.Errorf("unexpected state_backup config: %+v", sb)
+		}
+		if sb.Keep != DefaultStateBackupKeep || sb.ReplicationFactor != DefaultStateBackupReplicationFactor {
+			t.Errorf("expected the state_backup defaults: %+v", sb)
+		}
+	})
+
+	t.Run("expected tiering", func(t *testing.T) {
+		cfg := loadJSON(t)
+		tc := cfg.Tiering
+		if tc.Interval != 10*time.Minute || tc.Tag != "storage" || tc.ColdAfter != 7*24*time.Hour {
+			t.Errorf("unexpected tiering config: %+v", tc)
+		}
+		if tc.Hot != DefaultTieringHot || tc.Cold != DefaultTieringCold {
+			t.Errorf("expected the tiering defaults: %+v", tc)
+		}
+	})
+
+	t.Run("expected name_publishing", func(t *testing.T) {
+		cfg := loadJSON(t)
+		np := cfg.NamePublishing
+		if !np.Enabled || np.Timeout != 30*time.Second || len(np.IPNSKeys) != 1 || len(np.DNSLinkDomains) != 1 {
+			t.Errorf("unexpected name_publishing config: %+v", np)
+		}
+		dl := np.DNSLink
+		if dl.Provider != "rfc2136" || dl.Zone != "example.org" || dl.TSIGKeyName != "cluster" || dl.TTL != DefaultDNSLinkTTL {
+			t.Errorf("unexpected dnslink config: %+v", dl)
+		}
+	})
+
+	t.Run("expected denylist", func(t *testing.T) {
+		cfg := loadJSON(t)
+		if !cfg.Denylist.UnpinMatches {
+			t.Error("expected unpin_matches to be true")
+		}
+		cfg.BaseDir = "/base"
+		paths := cfg.GetDenylistPaths()
+		if len(paths) != 2 || paths[0] != "/base/badbits.deny" || paths[1] != "/etc/ipfs/extra.deny" {
+			t.Error("unexpected denylist paths:", paths)
+		}
+	})
+
+	t.Run("expected fault_injection", func(t *testing.T) {
+		cfg := loadJSON(t)
+		rpc := cfg.FaultInjection.RPC
+		if rpc.FailRate != 0.1 || rpc.DelayRate != 0.5 || rpc.Delay != 2*time.Second {
+			t.Errorf("unexpected rpc fault rule: %+v", rpc)
+		}
+		if cfg.FaultInjection.IPFS.enabled() {
+			t.Error("ipfs faults should be disabled")
+		}
+	})
+
+	t.Run("expected unpin_grace_period", func(t *testing.T) {
+		cfg := loadJSON(t)
+		if cfg.UnpinGracePeriod != 24*time.Hour {
+			t.Error("expected unpin_grace_period of 24h")
+		}
+	})
+
+	t.Run("expected protected_cids", func(t *testing.T) {
+		cfg := loadJSON(t)
+		if len(cfg.ProtectedCIDs) != 1 ||
+			cfg.ProtectedCIDs[0].String() != "QmP63DkAFEnDYNjDYBpyNDfttu1fvUw99x1brscPzpqmmq" {
+			t.Error("unexpected protected_cids:", cfg.ProtectedCIDs)
+		}
+	})
+
 	t.Run("expected pin_recover_interval", func(t *testing.T) {
 		cfg := loadJSON(t)
 		if cfg.PinRecoverInterval != time.Minute {
@@ -79,6 +388,44 @@ func TestLoadJSON(t *testing.T) {
 		}
 	})
 
+	t.Run("expected allocation_exclusions", func(t *testing.T) {
+		cfg := loadJSON(t)
+		excluded := cfg.AllocationExclusions["jurisdiction=restricted"]
+		if len(excluded) != 1 || excluded[0].String() != "QmXZrtE5jQwXNqCJMfHUTQkvhQ4ZAnqMnmzFMJfLewuabc" {
+			t.Error("expected one peer excluded for jurisdiction=restricted")
+		}
+
+		j := &configJSON{}
+		json.Unmarshal(ccfgTestJSON, j)
+		j.AllocationExclusions = map[string][]string{"a=b": {"abc"}}
+		tst, _ := json.Marshal(j)
+		cfg = &Config{}
+		if err := cfg.LoadJSON(tst); err == nil {
+			t.Error("expected an error decoding allocation_exclusions")
+		}
+	})
+
+	t.Run("expected peer_labels", func(t *testing.T) {
+		cfg := loadJSON(t)
+		if cfg.PeerLabels["region"] != "eu-west" {
+			t.Error("expected the region label")
+		}
+	})
+
+	t.Run("expected min_free_space", func(t *testing.T) {
+		cfg := loadJSON(t)
+		if cfg.MinFreeSpace != 1048576 {
+			t.Error("expected min_free_space of 1MiB")
+		}
+	})
+
+	t.Run("expected startup_warmup_rate", func(t *testing.T) {
+		cfg := loadJSON(t)
+		if cfg.StartupWarmupRate != 50 {
+			t.Error("expected startup_warmup_rate of 50")
+		}
+	})
+
 	t.Run("expected connection_manager", func(t *testing.T) {
 		cfg := loadJSON(t)
 		if cfg.ConnMgr.LowWater != 500 {
@@ -132,6 +479,13 @@ func TestLoadJSON(t *testing.T) {
 		}
 	})
 
+	t.Run("bad protected cids", func(t *testing.T) {
+		_, err := loadJSON2(t, func(j *configJSON) { j.ProtectedCIDs = []string{"abc"} })
+		if err == nil {
+			t.Error("expected error decoding protected_cids")
+		}
+	})
+
 	t.Run("bad secret", func(t *testing.T) {
 		_, err := loadJSON2(t, func(j *configJSON) { j.Secret = "abc" })
 		if err == nil {
@@ -205,6 +559,101 @@ func TestLoadJSON(t *testing.T) {
 			t.Error("default conn manager values not set")
 		}
 	})
+
+	t.Run("unique pin names", func(t *testing.T) {
+		cfg, err := loadJSON2(t, func(j *configJSON) { j.UniquePinNames = true })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cfg.UniquePinNames {
+			t.Error("expected unique_pin_names to be true")
+		}
+	})
+
+	t.Run("broadcast options", func(t *testing.T) {
+		cfg, err := loadJSON2(t, func(j *configJSON) {
+			j.BroadcastTimeout = "30s"
+			j.BroadcastConcurrency = 5
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.BroadcastTimeout != 30*time.Second || cfg.BroadcastConcurrency != 5 {
+			t.Error("expected broadcast_timeout and broadcast_concurrency to be set")
+		}
+
+		cfg, err = loadJSON2(t, func(j *configJSON) { j.BroadcastConcurrency = 0 })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.BroadcastConcurrency != DefaultBroadcastConcurrency {
+			t.Error("expected the default broadcast_concurrency")
+		}
+
+		if _, err := loadJSON2(t, func(j *configJSON) { j.BroadcastTimeout = "-1s" }); err == nil {
+			t.Error("expected an error with a negative broadcast_timeout")
+		}
+	})
+
+	t.Run("pin ack timeout", func(t *testing.T) {
+		cfg, err := loadJSON2(t, func(j *configJSON) { j.PinAckTimeout = "" })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.PinAckTimeout != DefaultPinAckTimeout {
+			t.Error("expected the default pin_ack_timeout")
+		}
+
+		if _, err := loadJSON2(t, func(j *configJSON) { j.PinAckTimeout = "0s" }); err == nil {
+			t.Error("expected an error with a 0 pin_ack_timeout")
+		}
+	})
+
+	t.Run("secret transition window", func(t *testing.T) {
+		cfg, err := loadJSON2(t, func(j *configJSON) { j.SecretTransitionWindow = "" })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.SecretTransitionWindow != DefaultSecretTransitionWindow {
+			t.Error("expected the default secret_transition_window")
+		}
+
+		if _, err := loadJSON2(t, func(j *configJSON) { j.SecretTransitionWindow = "-1m" }); err == nil {
+			t.Error("expected an error with a negative secret_transition_window")
+		}
+	})
+
+	t.Run("rpc policy overrides", func(t *testing.T) {
+		cfg, err := loadJSON2(
+			t,
+			func(j *configJSON) {
+				j.RPCPolicy = map[string]string{"Cluster.ID": "trusted"}
+			},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.RPCPolicy["Cluster.ID"] != RPCTrusted {
+			t.Error("expected Cluster.ID to be trusted")
+		}
+		if cfg.RPCPolicy["Cluster.Pin"] != DefaultRPCPolicy["Cluster.Pin"] {
+			t.Error("other methods should keep the default policy")
+		}
+		if DefaultRPCPolicy["Cluster.ID"] != RPCOpen {
+			t.Error("the default policy should not be modified")
+		}
+	})
+
+	t.Run("bad rpc policy", func(t *testing.T) {
+		_, err := loadJSON2(t, func(j *configJSON) { j.RPCPolicy = map[string]string{"Cluster.Abc": "open"} })
+		if err == nil {
+			t.Error("expected error for an unknown RPC method")
+		}
+		_, err = loadJSON2(t, func(j *configJSON) { j.RPCPolicy = map[string]string{"Cluster.ID": "abc"} })
+		if err == nil {
+			t.Error("expected error for an unknown endpoint type")
+		}
+	})
 }
 
 func TestToJSON(t *testing.T) {
@@ -222,6 +671,17 @@ func TestToJSON(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+
+	cfg.RPCPolicy["Cluster.ID"] = RPCClosed
+	newjson, err = cfg.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := &configJSON{}
+	json.Unmarshal(newjson, j)
+	if len(j.RPCPolicy) != 1 || j.RPCPolicy["Cluster.ID"] != "closed" {
+		t.Error("only policy overrides should be saved:", j.RPCPolicy)
+	}
 }
 
 func TestDefault(t *testing.T) {
@@ -275,4 +735,168 @@ func TestValidate(t *testing.T) {
 	if cfg.Validate() == nil {
 		t.Fatal("expected error validating")
 	}
+
+	cfg.Default()
+	cfg.PeerLabels = map[string]string{"": "x"}
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.StartupWarmupRate = -1
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.AllocationExclusions = map[string][]peer.ID{"=restricted": nil}
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.ConnectivityHistorySize = 0
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.Repin.Concurrency = 0
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.Repin.GracePeriod = -time.Second
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.Popularity.Interval = time.Minute
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating: max_replication is unset")
+	}
+
+	cfg.Popularity.MaxReplication = 3
+	cfg.Popularity.ColdThreshold = cfg.Popularity.HotThreshold
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.PinValidation.URL = "ftp://example.org"
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.PinValidation.Command = []string{"validate"}
+	cfg.PinValidation.Timeout = 0
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.PinMetadata.MaxSize = -1
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating: negative pin_metadata limit")
+	}
+
+	cfg.Default()
+	cfg.PinMetadata.Schema = &api.MetadataSchema{Type: "array"}
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating: unsupported schema type")
+	}
+
+	cfg.Default()
+	cfg.PinWebhooks = []PinWebhookConfig{{URL: "http://example.org", Timeout: time.Second, Events: []api.PinsetEventType{"pinned"}}}
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating: unknown event")
+	}
+
+	cfg.Default()
+	cfg.PinWebhooks = []PinWebhookConfig{{URL: "example.org", Timeout: time.Second}}
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.LifecycleWebhooks = []LifecycleWebhookConfig{{URL: "http://example.org", Timeout: time.Second, Events: []api.LifecycleEventType{"started"}}}
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating: unknown event")
+	}
+
+	cfg.Default()
+	cfg.GatewayWarmup.Gateways = []string{"ipfs.io"}
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating: gateway is not a URL")
+	}
+
+	cfg.Default()
+	cfg.GatewayWarmup.Gateways = []string{"https://{cid}.ipfs.dweb.link"}
+	cfg.GatewayWarmup.Concurrency = 0
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.PinUpdateUnpin.KeepVersions = -1
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.IPNSTracking.HistorySize = 0
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.ReadThroughCache.Interval = time.Hour
+	cfg.ReadThroughCache.TTL = time.Minute
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.StateBackup.Interval = time.Hour
+	cfg.StateBackup.FullEvery = 0
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.Tiering.Interval = time.Hour
+	cfg.Tiering.Cold = cfg.Tiering.Hot
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.NamePublishing.Enabled = true
+	cfg.NamePublishing.DNSLink.Provider = "route53"
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating: unknown provider")
+	}
+
+	cfg.Default()
+	cfg.NamePublishing.Enabled = true
+	cfg.NamePublishing.DNSLink.Provider = "rfc2136"
+	cfg.NamePublishing.DNSLink.Server = "127.0.0.1:53"
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating: no zone")
+	}
+
+	cfg.Default()
+	cfg.FaultInjection.IPFS.FailRate = 1.5
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.FaultInjection.RPC.DelayRate = 0.5
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating: delay is unset")
+	}
 }
@@ -15,6 +15,7 @@ import (
 	"github.com/ipfs/ipfs-cluster/allocator/balanced"
 	"github.com/ipfs/ipfs-cluster/api"
 	"github.com/ipfs/ipfs-cluster/config"
+	"github.com/ipfs/ipfs-cluster/informer/disk"
 	"github.com/ipfs/ipfs-cluster/informer/numpin"
 	"github.com/ipfs/ipfs-cluster/monitor/pubsubmon"
 	"github.com/ipfs/ipfs-cluster/pintracker/stateless"
@@ -53,6 +54,7 @@ type mockConnector struct {
 
 	pins   sync.Map
 	blocks sync.Map
+	names  sync.Map
 }
 
 func (ipfs *mockConnector) ID(ctx context.Context) (*api.IPFSID, error) {
@@ -69,8 +71,8 @@ func (ipfs *mockConnector) Pin(ctx context.Context, pin *api.Pin) error {
 	return nil
 }
 
-func (ipfs *mockConnector) Unpin(ctx context.Context, c cid.Cid) error {
-	ipfs.pins.Delete(c.String())
+func (ipfs *mockConnector) Unpin(ctx context.Context, pin *api.Pin) error {
+	ipfs.pins.Delete(pin.Cid.String())
 	return nil
 }
 
@@ -146,6 +148,31 @@ func (ipfs *mockConnector) BlockGet(ctx context.Context, c cid.Cid) ([]byte, err
 	return d.([]byte), nil
 }
 
+func (ipfs *mockConnector) BlockGetLocal(ctx context.Context, c cid.Cid) ([]byte, error) {
+	return ipfs.BlockGet(ctx, c)
+}
+
+func (ipfs *mockConnector) BlockRm(ctx context.Context, c cid.Cid) error {
+	if _, ok := ipfs.pins.Load(c.String()); ok {
+		return errors.New("block is pinned")
+	}
+	ipfs.blocks.Delete(c.String())
+	return nil
+}
+
+func (ipfs *mockConnector) DAGSize(ctx context.Context, c cid.Cid) (uint64, error) {
+	d, ok := ipfs.blocks.Load(c.String())
+	if !ok {
+		return 0, errors.New("block not found")
+	}
+	return uint64(len(d.([]byte))), nil
+}
+
+func (ipfs *mockConnector) NamePublish(ctx context.Context, c cid.Cid, key string) (string, error) {
+	ipfs.names.Store(key, c)
+	return test.PeerID1.String(), nil
+}
+
 type mockTracer struct {
 	mockComponent
 }
@@ -171,7 +198,7 @@ func testingCluster(t *testing.T) (*Cluster, *mockAPI, *mockConnector, PinTracke
 
 	store := makeStore(t, badgerCfg, levelDBCfg)
 	cons := makeConsensus(t, store, host, pubsub, dht, raftCfg, false, crdtCfg)
-	tracker := stateless.New(statelesstrackerCfg, ident.ID, clusterCfg.Peername, cons.State)
+	tracker := stateless.New(statelesstrackerCfg, ident.ID, clusterCfg.Peername, cons.State, store)
 
 	var peersF func(context.Context) ([]peer.ID, error)
 	if consensus == "raft" {
@@ -208,6 +235,7 @@ func testingCluster(t *testing.T) (*Cluster, *mockAPI, *mockConnector, PinTracke
 		mon,
 		alloc,
 		[]Informer{inf},
+		nil,
 		tracer,
 	)
 	if err != nil {
@@ -272,6 +300,7 @@ func TestClusterID(t *testing.T) {
 	cl, _, _, _ := testingCluster(t)
 	defer cleanState()
 	defer cl.Shutdown(ctx)
+	cl.config.PeerLabels = map[string]string{"region": "eu-west"}
 	id := cl.ID(ctx)
 	if len(id.Addresses) == 0 {
 		t.Error("expected more addresses")
@@ -282,11 +311,43 @@ func TestClusterID(t *testing.T) {
 	if id.Version != version.Version.String() {
 		t.Error("version should match current version")
 	}
+	if id.Labels["region"] != "eu-west" {
+		t.Error("expected the peer labels")
+	}
 	//if id.PublicKey == nil {
 	//	t.Error("publicKey should not be empty")
 	//}
 }
 
+func TestClusterAlertLabels(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	ping := &api.Metric{
+		Name:   pingMetricName,
+		Peer:   test.PeerID2,
+		Valid:  true,
+		Labels: map[string]string{"owner": "ops@example.org"},
+	}
+	ping.SetTTL(time.Minute)
+	if err := cl.monitor.LogMetric(ctx, ping); err != nil {
+		t.Fatal(err)
+	}
+
+	alrt := &api.Alert{
+		Metric: api.Metric{
+			Name: "freespace",
+			Peer: test.PeerID2,
+		},
+	}
+	cl.setAlertLabels(alrt)
+	if alrt.Labels["owner"] != "ops@example.org" {
+		t.Error("expected the labels of the peer in the alert:", alrt.Labels)
+	}
+}
+
 func TestClusterPin(t *testing.T) {
 	ctx := context.Background()
 	cl, _, _, _ := testingCluster(t)
@@ -320,6 +381,297 @@ func TestClusterPin(t *testing.T) {
 	}
 }
 
+func TestClusterPinDryRun(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	pin, err := cl.PinDryRun(ctx, test.Cid1, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pin.IsPinEverywhere() || len(pin.Allocations) != 0 {
+		t.Error("expected a pin everywhere without allocations")
+	}
+
+	if _, err := cl.PinGet(ctx, test.Cid1); err != state.ErrNotFound {
+		t.Error("a dry-run should not pin anything:", err)
+	}
+
+	// No peers have sent metrics yet, so allocations cannot be made.
+	opts := api.PinOptions{
+		ReplicationFactorMin: 1,
+		ReplicationFactorMax: 1,
+	}
+	if _, err := cl.PinDryRun(ctx, test.Cid1, opts); err == nil {
+		t.Error("expected an allocation error")
+	}
+}
+
+func TestClusterPinScheduled(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	opts := api.PinOptions{
+		ScheduleAt: time.Now().Add(time.Hour),
+		ExpireAt:   time.Now().Add(time.Minute),
+	}
+	if _, err := cl.Pin(ctx, test.Cid1, opts); err == nil {
+		t.Error("expected an error when the pin expires before its schedule")
+	}
+
+	opts.ExpireAt = time.Time{}
+	if _, err := cl.Pin(ctx, test.Cid1, opts); err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	// The pin is in the state, but not pinned yet.
+	if _, err := cl.PinGet(ctx, test.Cid1); err != nil {
+		t.Fatal(err)
+	}
+	if pi := cl.StatusLocal(ctx, test.Cid1); pi.Status != api.TrackerStatusScheduled {
+		t.Error("expected the pin to be scheduled:", pi.Status)
+	}
+}
+
+func TestClusterPinExcludeAllocations(t *testing.T) {
+	ctx := context.Background()
+	cl, _, ipfs, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	cl.config.AllocationExclusions = map[string][]peer.ID{
+		"jurisdiction=restricted": {cl.id},
+	}
+
+	_, err := cl.Pin(ctx, test.Cid1, api.PinOptions{
+		Metadata: map[string]string{"jurisdiction": "restricted"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cl.Pin(ctx, test.Cid2, api.PinOptions{
+		ExcludeAllocations: []peer.ID{test.PeerID2, test.PeerID2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	pin, err := cl.PinGet(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pin.ExcludeAllocations) != 1 || pin.ExcludeAllocations[0] != cl.id {
+		t.Error("expected the peer to be excluded by the metadata:", pin.ExcludeAllocations)
+	}
+	if !pin.IsPinEverywhere() {
+		t.Error("expected a pin everywhere")
+	}
+	if st := cl.tracker.Status(ctx, test.Cid1).Status; st != api.TrackerStatusRemote {
+		t.Error("the excluded peer should not pin the item:", st)
+	}
+	if _, ok := ipfs.pins.Load(test.Cid1.String()); ok {
+		t.Error("the item should not be pinned in IPFS")
+	}
+
+	pin, err = cl.PinGet(ctx, test.Cid2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pin.ExcludeAllocations) != 1 || pin.ExcludeAllocations[0] != test.PeerID2 {
+		t.Error("expected one excluded peer:", pin.ExcludeAllocations)
+	}
+	if st := cl.tracker.Status(ctx, test.Cid2).Status; st != api.TrackerStatusPinned {
+		t.Error("the item should be pinned everywhere else:", st)
+	}
+
+	_, err = cl.Pin(ctx, test.Cid3, api.PinOptions{
+		ReplicationFactorMin: 1,
+		ReplicationFactorMax: 1,
+		UserAllocations:      []peer.ID{cl.id},
+		Metadata:             map[string]string{"jurisdiction": "restricted"},
+	})
+	if err == nil {
+		t.Error("expected an error allocating to an excluded peer")
+	}
+}
+
+func TestClusterPinMinFreeSpace(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	cl.config.MinFreeSpace = 2000
+	logFreeSpace := func(free string) {
+		m := &api.Metric{
+			Name:  disk.MetricFreeSpace.String(),
+			Peer:  cl.id,
+			Value: free,
+			Valid: true,
+		}
+		m.SetTTL(time.Minute)
+		if err := cl.monitor.LogMetric(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Without freespace metrics the check is skipped.
+	_, err := cl.PinDryRun(ctx, test.Cid1, api.PinOptions{})
+	if err != nil {
+		t.Fatal("expected no error without freespace metrics:", err)
+	}
+
+	logFreeSpace("1000")
+	_, err = cl.PinDryRun(ctx, test.Cid1, api.PinOptions{})
+	if !errors.Is(err, api.ErrInsufficientFreeSpace) {
+		t.Fatal("expected a free space error:", err)
+	}
+
+	logFreeSpace("5000")
+	_, err = cl.Pin(ctx, test.Cid1, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	// Pins which exist already are not checked.
+	cl.config.MinFreeSpace = 10000
+	_, err = cl.Pin(ctx, test.Cid1, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterPinsByType(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	for _, c := range []cid.Cid{test.Cid1, test.Cid2} {
+		if _, err := cl.Pin(ctx, c, api.PinOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pins, err := cl.PinsByType(ctx, api.DataType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 2 {
+		t.Error("expected two data pins")
+	}
+
+	pins, err = cl.PinsByType(ctx, api.MetaType|api.ClusterDAGType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 0 {
+		t.Error("expected no sharded pins")
+	}
+}
+
+func TestClusterPinChanges(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	current, err := cl.PinChanges(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = cl.Pin(ctx, test.Cid1, api.PinOptions{Name: "abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cl.Pin(ctx, test.Cid2, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cl.Unpin(ctx, test.Cid2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := cl.PinChanges(ctx, current.Sequence)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes.Changes) != 2 {
+		t.Fatal("expected two changes")
+	}
+	if ch := changes.Changes[0]; ch.Removed || ch.Pin == nil || ch.Pin.Name != "abc" {
+		t.Error("expected the pin for Cid1")
+	}
+	if ch := changes.Changes[1]; !ch.Removed || !ch.Cid.Equals(test.Cid2) {
+		t.Error("expected Cid2 to be unpinned")
+	}
+
+	_, err = cl.PinChanges(ctx, changes.Sequence+1)
+	if err != api.ErrChangesUnavailable {
+		t.Error("expected ErrChangesUnavailable")
+	}
+}
+
+func TestClusterUniquePinNames(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	cl.config.UniquePinNames = true
+
+	_, err := cl.Pin(ctx, test.Cid1, api.PinOptions{Name: "abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Re-pinning the same CID with the name is fine.
+	_, err = cl.Pin(ctx, test.Cid1, api.PinOptions{Name: "abc", Metadata: map[string]string{"a": "b"}})
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = cl.Pin(ctx, test.Cid2, api.PinOptions{Name: "abc"})
+	if !errors.Is(err, api.ErrDuplicatePinName) {
+		t.Error("expected ErrDuplicatePinName:", err)
+	}
+
+	_, err = cl.Pin(ctx, test.Cid2, api.PinOptions{Name: "abcd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pins, err := cl.PinsByName(ctx, "abc", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 1 || !pins[0].Cid.Equals(test.Cid1) {
+		t.Error("expected one pin named abc")
+	}
+
+	pins, err = cl.PinsByName(ctx, "abc", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 2 {
+		t.Error("expected two pins with the abc prefix")
+	}
+
+	// Updates may keep the name of the updated pin.
+	_, err = cl.PinUpdate(ctx, test.Cid1, test.Cid3, api.PinOptions{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
 func TestPinExpired(t *testing.T) {
 	ctx := context.Background()
 	cl, _, _, _ := testingCluster(t)
@@ -416,6 +768,103 @@ func TestAddFile(t *testing.T) {
 	})
 }
 
+func TestReshard(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+	sth := test.NewShardingTestHelper()
+	defer sth.Clean(t)
+
+	params := api.DefaultAddParams()
+	params.Name = "testreshard"
+	params.Metadata = map[string]string{"a": "b"}
+	mfr, closer := sth.GetTreeMultiReader(t)
+	defer closer.Close()
+	r := multipart.NewReader(mfr, mfr.Boundary())
+	root, err := cl.AddFile(r, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	// reshard starts a reshard, waits for its operation to finish and
+	// returns the resulting pin.
+	reshard := func(t *testing.T, h cid.Cid, params *api.AddParams) *api.Pin {
+		op, err := cl.Reshard(ctx, h, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if op.Type != api.OperationReshard || !op.Cid.Equals(h) {
+			t.Fatal("unexpected reshard operation:", op)
+		}
+		for op.Status == api.OperationRunning {
+			time.Sleep(100 * time.Millisecond)
+			op, err = cl.Operation(ctx, op.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if op.Status != api.OperationDone {
+			t.Fatal("the reshard failed:", op.Error)
+		}
+		pin, err := cl.PinGet(ctx, op.Cid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pin
+	}
+
+	t.Run("same params", func(t *testing.T) {
+		pin := reshard(t, root, api.DefaultAddParams())
+		if !pin.Cid.Equals(root) {
+			t.Fatal("the root should not have changed")
+		}
+		if _, ok := pin.Metadata[api.ReshardedFromMetaKey]; ok {
+			t.Error("metadata should not have been changed")
+		}
+	})
+
+	t.Run("trickle", func(t *testing.T) {
+		newParams := api.DefaultAddParams()
+		newParams.Layout = "trickle"
+		pin := reshard(t, root, newParams)
+		if pin.Cid.String() != test.ShardingDirTrickleRootCID {
+			t.Fatal("unexpected root CID after resharding: ", pin.Cid)
+		}
+		if pin.Name != "testreshard" || pin.Metadata["a"] != "b" {
+			t.Error("pin options should have been kept")
+		}
+		if pin.Metadata[api.ReshardedFromMetaKey] != root.String() {
+			t.Error("the old CID should be recorded in the metadata")
+		}
+
+		if _, err := cl.PinGet(ctx, root); err == nil {
+			t.Error("the old pin should have been removed")
+		}
+	})
+
+	t.Run("not pinned", func(t *testing.T) {
+		_, err := cl.Reshard(ctx, test.Cid4, api.DefaultAddParams())
+		if err == nil {
+			t.Error("expected an error for an item not in the pinset")
+		}
+	})
+
+	t.Run("protected", func(t *testing.T) {
+		opts := api.PinOptions{Protected: true}
+		_, err := cl.Pin(ctx, test.Cid1, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pinDelay()
+		_, err = cl.Reshard(ctx, test.Cid1, api.DefaultAddParams())
+		if !errors.Is(err, api.ErrPinProtected) {
+			t.Error("expected ErrPinProtected:", err)
+		}
+	})
+}
+
 func TestUnpinShard(t *testing.T) {
 	ctx := context.Background()
 	cl, _, _, _ := testingCluster(t)
@@ -452,6 +901,26 @@ func TestUnpinShard(t *testing.T) {
 		pinnedCids = append(pinnedCids, l.Cid)
 	}
 
+	t.Run("list shards", func(t *testing.T) {
+		shards, err := cl.Shards(ctx, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(shards) != len(cDagNode.Links()) {
+			t.Fatalf("expected %d shards, got %d", len(cDagNode.Links()), len(shards))
+		}
+		for _, sh := range shards {
+			if sh.Size == 0 || sh.Status == nil {
+				t.Error("expected shard size and status")
+			}
+		}
+
+		_, err = cl.Shards(ctx, cDag.Cid)
+		if err == nil {
+			t.Error("expected an error listing shards of a non-sharded pin")
+		}
+	})
+
 	t.Run("unpin clusterdag should fail", func(t *testing.T) {
 		_, err := cl.Unpin(ctx, cDag.Cid)
 		if err == nil {
@@ -869,6 +1338,169 @@ func TestClusterUnpin(t *testing.T) {
 	}
 }
 
+func TestClusterUnpinProtected(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	c := test.Cid1
+	_, err := cl.Pin(ctx, c, api.PinOptions{Protected: true})
+	if err != nil {
+		t.Fatal("pin should have worked:", err)
+	}
+
+	_, err = cl.Unpin(ctx, c)
+	if !errors.Is(err, api.ErrPinProtected) {
+		t.Fatal("expected a protected pin error:", err)
+	}
+
+	// Re-pinning keeps the protection.
+	_, err = cl.Pin(ctx, c, api.PinOptions{Name: "renamed"})
+	if err != nil {
+		t.Fatal("pin should have worked:", err)
+	}
+	pin, err := cl.PinGet(ctx, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pin.Protected || pin.Name != "renamed" {
+		t.Error("expected a renamed protected pin")
+	}
+
+	pin, err = cl.Unprotect(ctx, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin.Protected {
+		t.Error("expected the protection to be cleared")
+	}
+	_, err = cl.Unpin(ctx, c)
+	if err != nil {
+		t.Error("unpin should have worked:", err)
+	}
+
+	// CIDs in the configuration cannot be unprotected.
+	cl.config.ProtectedCIDs = []cid.Cid{test.Cid2}
+	_, err = cl.Pin(ctx, test.Cid2, api.PinOptions{})
+	if err != nil {
+		t.Fatal("pin should have worked:", err)
+	}
+	_, err = cl.Unprotect(ctx, test.Cid2)
+	if !errors.Is(err, api.ErrPinProtected) {
+		t.Error("expected a protected pin error:", err)
+	}
+	_, err = cl.Unpin(ctx, test.Cid2)
+	if !errors.Is(err, api.ErrPinProtected) {
+		t.Error("expected a protected pin error:", err)
+	}
+}
+
+func TestClusterUnpinTrash(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	cl.config.UnpinGracePeriod = time.Hour
+	c := test.Cid1
+	_, err := cl.Pin(ctx, c, api.PinOptions{})
+	if err != nil {
+		t.Fatal("pin should have worked:", err)
+	}
+
+	_, err = cl.Restore(ctx, c)
+	if !errors.Is(err, api.ErrNotTrashed) {
+		t.Error("expected a not trashed error:", err)
+	}
+
+	// The trash metadata key is reserved.
+	trashOpts := api.PinOptions{Metadata: map[string]string{api.TrashedAtMetaKey: time.Now().UTC().Format(time.RFC3339)}}
+	_, err = cl.Pin(ctx, test.Cid2, trashOpts)
+	if !errors.Is(err, api.ErrInvalidMetadata) {
+		t.Error("expected an invalid metadata error:", err)
+	}
+
+	// Protected pins are not moved to the trash.
+	protected, err := cl.Pin(ctx, test.Cid2, api.PinOptions{Protected: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cl.trash(ctx, protected)
+	if !errors.Is(err, api.ErrPinProtected) {
+		t.Error("expected a protected pin error:", err)
+	}
+
+	_, err = cl.Unpin(ctx, c)
+	if err != nil {
+		t.Fatal("unpin should have worked:", err)
+	}
+	pin, err := cl.PinGet(ctx, c)
+	if err != nil {
+		t.Fatal("the pin should be kept in the trash:", err)
+	}
+	if _, trashed := pin.TrashedAt(); !trashed {
+		t.Fatal("expected the pin to be in the trash")
+	}
+
+	// Not unpinned before the grace period passes.
+	err = cl.StateSync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pin, err = cl.Restore(ctx, c)
+	if err != nil {
+		t.Fatal("restore should have worked:", err)
+	}
+	if _, trashed := pin.TrashedAt(); trashed {
+		t.Error("expected the pin to be restored")
+	}
+
+	// Pinning again restores too.
+	_, err = cl.Unpin(ctx, c)
+	if err != nil {
+		t.Fatal("unpin should have worked:", err)
+	}
+	_, err = cl.Pin(ctx, c, api.PinOptions{})
+	if err != nil {
+		t.Fatal("pin should have worked:", err)
+	}
+	pin, err = cl.PinGet(ctx, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, trashed := pin.TrashedAt(); trashed {
+		t.Error("expected the pin to be restored by pinning it")
+	}
+
+	_, err = cl.Unpin(ctx, c)
+	if err != nil {
+		t.Fatal("unpin should have worked:", err)
+	}
+	cl.config.UnpinGracePeriod = time.Nanosecond
+	time.Sleep(time.Second) // trash timestamps have second precision
+
+	// Items protected while in the trash are kept.
+	cl.config.ProtectedCIDs = []cid.Cid{c}
+	err = cl.StateSync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cl.PinGet(ctx, c); err != nil {
+		t.Error("protected items should not be unpinned from the trash:", err)
+	}
+
+	cl.config.ProtectedCIDs = nil
+	err = cl.StateSync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cl.PinGet(ctx, c)
+	if err != state.ErrNotFound {
+		t.Error("expected the pin to be unpinned after the grace period:", err)
+	}
+}
+
 func TestClusterUnpinPath(t *testing.T) {
 	ctx := context.Background()
 	cl, _, _, _ := testingCluster(t)
@@ -932,6 +1564,59 @@ func TestVersion(t *testing.T) {
 	}
 }
 
+func TestClusterRebindAPI(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	// The mock APIs cannot be rebound.
+	if listeners := cl.APIListeners(ctx); len(listeners) != 0 {
+		t.Error("expected no rebindable APIs:", listeners)
+	}
+	_, err := cl.RebindAPI(ctx, &api.APIListeners{
+		API:                    "restapi",
+		HTTPListenMultiaddress: []string{"/ip4/127.0.0.1/tcp/0"},
+	})
+	if err == nil {
+		t.Error("expected an error rebinding an unknown API")
+	}
+}
+
+func TestClusterSetRPCPolicy(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	policy := cl.RPCPolicy(ctx)
+	if policy["Cluster.ID"] != "open" {
+		t.Error("expected Cluster.ID to be open")
+	}
+
+	policy, err := cl.SetRPCPolicy(ctx, map[string]string{"Cluster.ID": "trusted"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy["Cluster.ID"] != "trusted" {
+		t.Error("expected Cluster.ID to be trusted")
+	}
+	if cl.RPCPolicy(ctx)["Cluster.ID"] != "trusted" {
+		t.Error("the policy should have been changed")
+	}
+
+	_, err = cl.SetRPCPolicy(ctx, map[string]string{
+		"Cluster.ID":  "closed",
+		"Cluster.Abc": "open",
+	})
+	if err == nil {
+		t.Error("expected an error for an unknown method")
+	}
+	if cl.RPCPolicy(ctx)["Cluster.ID"] != "trusted" {
+		t.Error("the policy should not change when there are errors")
+	}
+}
+
 func TestClusterRecoverAllLocal(t *testing.T) {
 	ctx := context.Background()
 	cl, _, _, _ := testingCluster(t)
@@ -979,6 +1664,92 @@ func TestClusterRepoGC(t *testing.T) {
 
 }
 
+func TestClusterOperations(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	_, err := cl.RepoGC(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ops := cl.Operations(ctx)
+	if len(ops) != 1 {
+		t.Fatal("expected one operation")
+	}
+	if ops[0].Type != api.OperationRepoGC || ops[0].Status != api.OperationDone {
+		t.Error("expected a finished repo_gc operation")
+	}
+	if ops[0].Done != 1 || ops[0].Total != 1 {
+		t.Error("expected the progress to include the only peer")
+	}
+
+	op, err := cl.Operation(ctx, ops[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op.ID != ops[0].ID {
+		t.Error("bad operation returned")
+	}
+
+	op, err = cl.CancelOperation(ctx, op.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op.Status != api.OperationDone {
+		t.Error("canceling a finished operation should have no effect")
+	}
+
+	_, err = cl.CancelOperation(ctx, "abc")
+	if err != api.ErrOperationNotFound {
+		t.Error("expected ErrOperationNotFound")
+	}
+}
+
+func TestClusterTrackedOperations(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	_, err := cl.RecoverAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops := cl.Operations(ctx)
+	if len(ops) != 1 || ops[0].Type != api.OperationRecoverAll {
+		t.Fatal("expected a recover_all operation")
+	}
+	if ops[0].Status != api.OperationDone || ops[0].Done != 1 || ops[0].Total != 1 {
+		t.Error("expected the progress to include the only peer:", ops[0])
+	}
+
+	op := cl.StartOperation(ctx, api.OperationAdd)
+	if op.Status != api.OperationRunning {
+		t.Error("expected a running operation")
+	}
+	op.Cid = test.Cid1
+	op.Error = "boom"
+	err = cl.FinishOperation(ctx, op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	op, err = cl.Operation(ctx, op.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op.Status != api.OperationFailed || op.Error != "boom" || !op.Cid.Equals(test.Cid1) {
+		t.Error("expected a failed add operation:", op)
+	}
+
+	err = cl.FinishOperation(ctx, &api.Operation{ID: "abc"})
+	if err != api.ErrOperationNotFound {
+		t.Error("expected ErrOperationNotFound")
+	}
+}
+
 func TestClusterRepoGCLocal(t *testing.T) {
 	ctx := context.Background()
 	cl, _, _, _ := testingCluster(t)
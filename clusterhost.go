@@ -12,6 +12,7 @@ import (
 	connmgr "github.com/libp2p/go-libp2p-connmgr"
 	crypto "github.com/libp2p/go-libp2p-core/crypto"
 	host "github.com/libp2p/go-libp2p-core/host"
+	metrics "github.com/libp2p/go-libp2p-core/metrics"
 	network "github.com/libp2p/go-libp2p-core/network"
 	corepnet "github.com/libp2p/go-libp2p-core/pnet"
 	routing "github.com/libp2p/go-libp2p-core/routing"
@@ -31,6 +32,16 @@ const dhtNamespace = "dht"
 
 var _ = libp2pquic.NewTransport
 
+// clusterHost is the libp2p host returned by newHost (and therefore by
+// NewClusterHost). It carries the bandwidth counter used by its transports
+// and, when using a cluster secret, the ring of secrets protecting them,
+// which NewCluster picks up when given such a host.
+type clusterHost struct {
+	host.Host
+	bandwidth *metrics.BandwidthCounter
+	secrets   *secretRing
+}
+
 func init() {
 	// Cluster peers should advertise their public IPs as soon as they
 	// learn about them. Default for this is 4, which prevents clusters
@@ -104,10 +115,16 @@ func NewClusterHost(
 // newHost creates a base cluster host without dht, pubsub, relay or nat etc.
 // mostly used for testing.
 func newHost(ctx context.Context, psk corepnet.PSK, priv crypto.PrivKey, opts ...libp2p.Option) (host.Host, error) {
+	bwc := metrics.NewBandwidthCounter()
 	finalOpts := []libp2p.Option{
 		libp2p.Identity(priv),
+		libp2p.BandwidthReporter(bwc),
+	}
+	var ring *secretRing
+	if len(psk) > 0 {
+		ring = newSecretRing(psk)
 	}
-	finalOpts = append(finalOpts, baseOpts(psk)...)
+	finalOpts = append(finalOpts, baseOpts(psk, ring)...)
 	finalOpts = append(finalOpts, opts...)
 
 	h, err := libp2p.New(
@@ -117,11 +134,18 @@ func newHost(ctx context.Context, psk corepnet.PSK, priv crypto.PrivKey, opts ..
 		return nil, err
 	}
 
-	return h, nil
+	return &clusterHost{
+		Host:      h,
+		bandwidth: bwc,
+		secrets:   ring,
+	}, nil
 }
 
-func baseOpts(psk corepnet.PSK) []libp2p.Option {
-	return []libp2p.Option{
+// baseOpts returns the options shared by all cluster hosts. When a secret
+// ring is given, the transports use it to protect connections instead of
+// the fixed psk, so that the cluster secret can be rotated.
+func baseOpts(psk corepnet.PSK, ring *secretRing) []libp2p.Option {
+	opts := []libp2p.Option{
 		libp2p.PrivateNetwork(psk),
 		libp2p.EnableNATService(),
 		libp2p.Security(noise.ID, noise.New),
@@ -129,9 +153,17 @@ func baseOpts(psk corepnet.PSK) []libp2p.Option {
 		// TODO: quic does not support private networks
 		// libp2p.DefaultTransports,
 		libp2p.NoTransports,
-		libp2p.Transport(tcp.NewTCPTransport),
-		libp2p.Transport(websocket.New),
 	}
+	if ring == nil {
+		return append(opts,
+			libp2p.Transport(tcp.NewTCPTransport),
+			libp2p.Transport(websocket.New),
+		)
+	}
+	return append(opts,
+		libp2p.Transport(ring.tcpTransport),
+		libp2p.Transport(ring.wsTransport),
+	)
 }
 
 func newDHT(ctx context.Context, h host.Host, store ds.Datastore, extraopts ...dual.Option) (*dual.DHT, error) {
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/api/rest/client"
+
+	cid "github.com/ipfs/go-cid"
+	files "github.com/ipfs/go-ipfs-files"
+	mh "github.com/multiformats/go-multihash"
+)
+
+/*
+   These functions run a load test against a cluster: they submit a number
+   of synthetic items at a given rate and measure how long each takes to be
+   accepted by the API ("submit") and to be pinned by all its allocations
+   ("pinned").
+
+   In "pin" mode, items are random CIDs with an identity multihash, which
+   IPFS can pin without fetching anything, so that only the cluster itself
+   is measured. In "add" mode, random content of the given size is uploaded
+   with "add".
+
+   Every item carries the benchMetaKey metadata key with the ID of the run,
+   so that they can be removed afterwards with "pin bulk rm".
+*/
+
+const benchMetaKey = "bench"
+
+// maxIdentitySize is the largest content that pin mode embeds in a CID.
+const maxIdentitySize = 128
+
+// benchParams configures a benchmark run.
+type benchParams struct {
+	ID          string
+	Mode        string // "pin" or "add"
+	Count       int
+	Rate        float64 // items submitted per second. 0 means no limit
+	Size        int
+	Concurrency int
+	Timeout     time.Duration // for every item to be pinned
+	Replication int
+}
+
+func (p *benchParams) validate() error {
+	switch p.Mode {
+	case "pin":
+		if p.Size > maxIdentitySize {
+			return fmt.Errorf("pin mode supports sizes up to %d bytes. Use add mode", maxIdentitySize)
+		}
+	case "add":
+	default:
+		return fmt.Errorf("unknown mode %q", p.Mode)
+	}
+	if p.Count < 1 || p.Concurrency < 1 || p.Size < 1 {
+		return errors.New("count, concurrency and size must be positive")
+	}
+	if p.Rate < 0 {
+		return errors.New("rate cannot be negative")
+	}
+	return nil
+}
+
+// benchSample is the result of a single benchmarked item.
+type benchSample struct {
+	Cid    cid.Cid
+	Submit time.Duration
+	Pinned time.Duration
+	Err    error
+}
+
+// latencyStats summarizes a set of durations.
+type latencyStats struct {
+	Min  time.Duration `json:"min"`
+	Mean time.Duration `json:"mean"`
+	P50  time.Duration `json:"p50"`
+	P90  time.Duration `json:"p90"`
+	P95  time.Duration `json:"p95"`
+	P99  time.Duration `json:"p99"`
+	Max  time.Duration `json:"max"`
+}
+
+// benchReport is the result of a benchmark run.
+type benchReport struct {
+	ID         string        `json:"id"`
+	Mode       string        `json:"mode"`
+	Count      int           `json:"count"`
+	Errors     int           `json:"errors"`
+	Duration   time.Duration `json:"duration"`
+	Throughput float64       `json:"throughput"` // pinned items per second
+	Submit     latencyStats  `json:"submit"`
+	Pinned     latencyStats  `json:"pinned"`
+	// FirstError is the error of the first failed item, if any.
+	FirstError string `json:"first_error,omitempty"`
+}
+
+// percentile returns the nearest-rank percentile p (0-100) of sorted
+// durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+func newLatencyStats(durations []time.Duration) latencyStats {
+	if len(durations) == 0 {
+		return latencyStats{}
+	}
+	sorted := make([]time.Duration, len(durations))
+	copy(sorted, durations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	return latencyStats{
+		Min:  sorted[0],
+		Mean: total / time.Duration(len(sorted)),
+		P50:  percentile(sorted, 50),
+		P90:  percentile(sorted, 90),
+		P95:  percentile(sorted, 95),
+		P99:  percentile(sorted, 99),
+		Max:  sorted[len(sorted)-1],
+	}
+}
+
+func newBenchReport(params *benchParams, samples []benchSample, elapsed time.Duration) *benchReport {
+	r := &benchReport{
+		ID:       params.ID,
+		Mode:     params.Mode,
+		Count:    len(samples),
+		Duration: elapsed,
+	}
+	var submit, pinned []time.Duration
+	for _, s := range samples {
+		if s.Err != nil {
+			if r.Errors == 0 {
+				r.FirstError = s.Err.Error()
+			}
+			r.Errors++
+			continue
+		}
+		submit = append(submit, s.Submit)
+		pinned = append(pinned, s.Pinned)
+	}
+	r.Submit = newLatencyStats(submit)
+	r.Pinned = newLatencyStats(pinned)
+	if elapsed > 0 {
+		r.Throughput = float64(len(pinned)) / elapsed.Seconds()
+	}
+	return r
+}
+
+// runBench submits params.Count items and waits for each of them to be
+// pinned.
+func runBench(ctx context.Context, c client.Client, params *benchParams) *benchReport {
+	var tick <-chan time.Time
+	if params.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / params.Rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	samples := make([]benchSample, params.Count)
+	slots := make(chan struct{}, params.Concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < params.Count; i++ {
+		if tick != nil && i > 0 {
+			<-tick
+		}
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			samples[i] = benchItem(ctx, c, params, i)
+		}(i)
+	}
+	wg.Wait()
+	return newBenchReport(params, samples, time.Since(start))
+}
+
+func benchItem(ctx context.Context, c client.Client, params *benchParams, i int) benchSample {
+	data := make([]byte, params.Size)
+	if _, err := rand.Read(data); err != nil {
+		return benchSample{Err: err}
+	}
+	opts := api.PinOptions{
+		Name:                 fmt.Sprintf("%s-%d", params.ID, i),
+		ReplicationFactorMin: params.Replication,
+		ReplicationFactorMax: params.Replication,
+		Metadata:             map[string]string{benchMetaKey: params.ID},
+	}
+
+	var s benchSample
+	start := time.Now()
+	switch params.Mode {
+	case "pin":
+		s.Cid, s.Err = benchPin(ctx, c, data, opts)
+	case "add":
+		s.Cid, s.Err = benchAdd(ctx, c, data, opts)
+	}
+	s.Submit = time.Since(start)
+	if s.Err != nil {
+		return s
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, params.Timeout)
+	defer cancel()
+	_, s.Err = client.WaitFor(waitCtx, c, client.StatusFilterParams{
+		Cid:       s.Cid,
+		Target:    api.TrackerStatusPinned,
+		CheckFreq: 100 * time.Millisecond,
+	})
+	s.Pinned = time.Since(start)
+	return s
+}
+
+func benchPin(ctx context.Context, c client.Client, data []byte, opts api.PinOptions) (cid.Cid, error) {
+	hash, err := mh.Sum(data, mh.IDENTITY, -1)
+	if err != nil {
+		return cid.Undef, err
+	}
+	ci := cid.NewCidV1(cid.Raw, hash)
+	_, err = c.Pin(ctx, ci, opts)
+	return ci, err
+}
+
+func benchAdd(ctx context.Context, c client.Client, data []byte, opts api.PinOptions) (cid.Cid, error) {
+	params := api.DefaultAddParams()
+	params.PinOptions = opts
+	params.CidVersion = 1
+	params.RawLeaves = true
+
+	dir := files.NewMapDirectory(map[string]files.Node{
+		opts.Name: files.NewBytesFile(data),
+	})
+	out := make(chan *api.AddedOutput, 1)
+	root := make(chan cid.Cid, 1)
+	go func() {
+		var last cid.Cid
+		for o := range out {
+			if o.Cid.Defined() {
+				last = o.Cid
+			}
+		}
+		root <- last
+	}()
+
+	err := c.AddMultiFile(ctx, files.NewMultiFileReader(dir, true), params, out)
+	ci := <-root
+	if err == nil && !ci.Defined() {
+		err = errors.New("add did not return a CID")
+	}
+	return ci, err
+}
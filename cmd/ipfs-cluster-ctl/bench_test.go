@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLatencyStats(t *testing.T) {
+	var durations []time.Duration
+	for i := 100; i > 0; i-- {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+
+	st := newLatencyStats(durations)
+	if st.Min != time.Millisecond || st.Max != 100*time.Millisecond {
+		t.Errorf("bad min/max: %+v", st)
+	}
+	if st.P50 != 50*time.Millisecond || st.P99 != 99*time.Millisecond {
+		t.Errorf("bad percentiles: %+v", st)
+	}
+	if st.Mean != 50500*time.Microsecond {
+		t.Errorf("bad mean: %s", st.Mean)
+	}
+	if durations[0] != 100*time.Millisecond {
+		t.Error("input should not be sorted in place")
+	}
+
+	if newLatencyStats(nil) != (latencyStats{}) {
+		t.Error("expected empty stats")
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{1, 2, 3, 4, 5}
+	cases := []struct {
+		p        float64
+		expected time.Duration
+	}{
+		{0, 1},
+		{20, 1},
+		{21, 2},
+		{50, 3},
+		{99, 5},
+		{100, 5},
+	}
+	for _, c := range cases {
+		if got := percentile(sorted, c.p); got != c.expected {
+			t.Errorf("p%v: expected %d, got %d", c.p, c.expected, got)
+		}
+	}
+}
+
+func TestNewBenchReport(t *testing.T) {
+	params := &benchParams{ID: "bench-1", Mode: "pin"}
+	samples := []benchSample{
+		{Submit: time.Millisecond, Pinned: 3 * time.Millisecond},
+		{Err: errors.New("pin error")},
+		{Submit: 2 * time.Millisecond, Pinned: 5 * time.Millisecond},
+	}
+
+	r := newBenchReport(params, samples, time.Second)
+	if r.Count != 3 || r.Errors != 1 || r.FirstError != "pin error" {
+		t.Errorf("bad counts: %+v", r)
+	}
+	if r.Throughput != 2 {
+		t.Errorf("expected 2 pinned/s: %f", r.Throughput)
+	}
+	if r.Submit.Max != 2*time.Millisecond || r.Pinned.Min != 3*time.Millisecond {
+		t.Errorf("failed items should not count: %+v", r)
+	}
+}
+
+func TestBenchParamsValidate(t *testing.T) {
+	p := &benchParams{Mode: "pin", Count: 1, Size: 32, Concurrency: 1}
+	if err := p.validate(); err != nil {
+		t.Error(err)
+	}
+	p.Size = 1024
+	if p.validate() == nil {
+		t.Error("pin mode should not accept large sizes")
+	}
+	p.Mode = "add"
+	if err := p.validate(); err != nil {
+		t.Error(err)
+	}
+	p.Mode = "other"
+	if p.validate() == nil {
+		t.Error("expected an error with an unknown mode")
+	}
+}
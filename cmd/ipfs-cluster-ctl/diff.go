@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+
+	"github.com/ipfs/ipfs-cluster/api"
+)
+
+/*
+   These functions compare a cluster pinset against a second pinset and
+   report the differences. The second pinset is either the pinset of another
+   cluster (fetched from a different API endpoint) or the list of pins from an
+   IPFS daemon, in which case only the cluster pins allocated to the
+   contacted peer are expected to be present.
+
+   "Missing" items are in the source but not in the target, "extra" items are
+   in the target but not in the source, and "degraded" items are present in
+   both but do not match (different pin mode, fewer allocations etc.).
+*/
+
+// diffEntry is a single difference between two pinsets.
+type diffEntry struct {
+	Cid    cid.Cid `json:"cid"`
+	Name   string  `json:"name,omitempty"`
+	Reason string  `json:"reason,omitempty"`
+}
+
+// pinsetDiff is the result of comparing two pinsets.
+type pinsetDiff struct {
+	Source   string      `json:"source"`
+	Target   string      `json:"target"`
+	Missing  []diffEntry `json:"missing"`
+	Extra    []diffEntry `json:"extra"`
+	Degraded []diffEntry `json:"degraded"`
+}
+
+// Empty returns true when both pinsets matched.
+func (d *pinsetDiff) Empty() bool {
+	return len(d.Missing)+len(d.Extra)+len(d.Degraded) == 0
+}
+
+func (d *pinsetDiff) sort() {
+	for _, entries := range [][]diffEntry{d.Missing, d.Extra, d.Degraded} {
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Cid.String() < entries[j].Cid.String()
+		})
+	}
+}
+
+func newPinsetDiff(source, target string) *pinsetDiff {
+	return &pinsetDiff{
+		Source:   source,
+		Target:   target,
+		Missing:  []diffEntry{},
+		Extra:    []diffEntry{},
+		Degraded: []diffEntry{},
+	}
+}
+
+// diffClusterPinsets compares the pinsets of two clusters.
+func diffClusterPinsets(source, target string, srcPins, dstPins []*api.Pin) *pinsetDiff {
+	d := newPinsetDiff(source, target)
+
+	dstMap := make(map[cid.Cid]*api.Pin, len(dstPins))
+	for _, p := range dstPins {
+		dstMap[p.Cid] = p
+	}
+
+	for _, src := range srcPins {
+		dst, ok := dstMap[src.Cid]
+		if !ok {
+			d.Missing = append(d.Missing, diffEntry{
+				Cid:  src.Cid,
+				Name: src.Name,
+			})
+			continue
+		}
+		delete(dstMap, src.Cid)
+
+		if reason := comparePins(src, dst); reason != "" {
+			d.Degraded = append(d.Degraded, diffEntry{
+				Cid:    src.Cid,
+				Name:   src.Name,
+				Reason: reason,
+			})
+		}
+	}
+
+	for _, dst := range dstMap {
+		d.Extra = append(d.Extra, diffEntry{
+			Cid:  dst.Cid,
+			Name: dst.Name,
+		})
+	}
+
+	d.sort()
+	return d
+}
+
+// comparePins returns a description of the first mismatch between two pins
+// with the same Cid, or an empty string if they match.
+func comparePins(src, dst *api.Pin) string {
+	if src.Type != dst.Type {
+		return fmt.Sprintf("type: %s != %s", src.Type, dst.Type)
+	}
+	if src.MaxDepth != dst.MaxDepth {
+		return fmt.Sprintf("max depth: %d != %d", src.MaxDepth, dst.MaxDepth)
+	}
+	if src.ReplicationFactorMin != dst.ReplicationFactorMin ||
+		src.ReplicationFactorMax != dst.ReplicationFactorMax {
+		return fmt.Sprintf(
+			"replication factor: %d:%d != %d:%d",
+			src.ReplicationFactorMin,
+			src.ReplicationFactorMax,
+			dst.ReplicationFactorMin,
+			dst.ReplicationFactorMax,
+		)
+	}
+	if !src.IsPinEverywhere() && len(dst.Allocations) < len(src.Allocations) {
+		return fmt.Sprintf(
+			"allocations: %d < %d",
+			len(dst.Allocations),
+			len(src.Allocations),
+		)
+	}
+	return ""
+}
+
+// diffIPFSPins compares the cluster pinset with the pins in the IPFS daemon
+// attached to the given peer. Only pins allocated to that peer are expected
+// to be pinned in IPFS. Indirect IPFS pins are ignored.
+func diffIPFSPins(source, target string, pid peer.ID, clusterPins []*api.Pin, ipfsPins map[string]api.IPFSPinStatus) *pinsetDiff {
+	d := newPinsetDiff(source, target)
+
+	ipfsMap := make(map[string]api.IPFSPinStatus, len(ipfsPins))
+	for k, st := range ipfsPins {
+		if st == api.IPFSPinStatusIndirect {
+			continue
+		}
+		// Normalize to the string representation used by cluster.
+		if c, err := cid.Decode(k); err == nil {
+			k = c.String()
+		}
+		ipfsMap[k] = st
+	}
+
+	for _, p := range clusterPins {
+		key := p.Cid.String()
+		st, ok := ipfsMap[key]
+		// MetaPins are never pinned in IPFS, but the user may
+		// have pinned the same Cid manually.
+		if p.Type == api.MetaType {
+			delete(ipfsMap, key)
+			continue
+		}
+		// Not our business, but remote items pinned in ipfs
+		// should not be reported as extra.
+		if p.IsRemotePin(pid) {
+			delete(ipfsMap, key)
+			continue
+		}
+		if !ok {
+			d.Missing = append(d.Missing, diffEntry{
+				Cid:  p.Cid,
+				Name: p.Name,
+			})
+			continue
+		}
+		delete(ipfsMap, key)
+
+		if !st.IsPinned(p.MaxDepth) {
+			d.Degraded = append(d.Degraded, diffEntry{
+				Cid:    p.Cid,
+				Name:   p.Name,
+				Reason: fmt.Sprintf("ipfs pin type does not match max depth %d", p.MaxDepth),
+			})
+		}
+	}
+
+	for k := range ipfsMap {
+		c, err := cid.Decode(k)
+		if err != nil {
+			logger.Warnf("ignoring unparseable ipfs pin %s: %s", k, err)
+			continue
+		}
+		d.Extra = append(d.Extra, diffEntry{
+			Cid: c,
+		})
+	}
+
+	d.sort()
+	return d
+}
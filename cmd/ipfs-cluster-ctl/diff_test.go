@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+func TestDiffClusterPinsets(t *testing.T) {
+	missing := api.PinCid(test.Cid1)
+	common := api.PinCid(test.Cid2)
+	extra := api.PinCid(test.Cid3)
+
+	degraded := api.PinCid(test.Cid4)
+	degraded.ReplicationFactorMin = 2
+	degraded.ReplicationFactorMax = 2
+	degraded.Allocations = []peer.ID{test.PeerID1, test.PeerID2}
+	degraded2 := *degraded
+	degraded2.Allocations = []peer.ID{test.PeerID1}
+
+	d := diffClusterPinsets(
+		"a", "b",
+		[]*api.Pin{missing, common, degraded},
+		[]*api.Pin{extra, common, &degraded2},
+	)
+
+	if d.Empty() {
+		t.Fatal("expected differences")
+	}
+	if len(d.Missing) != 1 || !d.Missing[0].Cid.Equals(test.Cid1) {
+		t.Error("expected Cid1 to be missing:", d.Missing)
+	}
+	if len(d.Extra) != 1 || !d.Extra[0].Cid.Equals(test.Cid3) {
+		t.Error("expected Cid3 to be extra:", d.Extra)
+	}
+	if len(d.Degraded) != 1 || !d.Degraded[0].Cid.Equals(test.Cid4) {
+		t.Error("expected Cid4 to be degraded:", d.Degraded)
+	}
+
+	d = diffClusterPinsets("a", "b", []*api.Pin{common}, []*api.Pin{common})
+	if !d.Empty() {
+		t.Error("expected no differences")
+	}
+}
+
+func TestDiffIPFSPins(t *testing.T) {
+	local := api.PinCid(test.Cid1)
+	local.ReplicationFactorMin = 1
+	local.ReplicationFactorMax = 1
+	local.Allocations = []peer.ID{test.PeerID1}
+
+	remote := api.PinCid(test.Cid2)
+	remote.ReplicationFactorMin = 1
+	remote.ReplicationFactorMax = 1
+	remote.Allocations = []peer.ID{test.PeerID2}
+
+	direct := api.PinCid(test.Cid3)
+	direct.ReplicationFactorMin = -1
+	direct.ReplicationFactorMax = -1
+	direct.MaxDepth = 0
+
+	everywhere := api.PinCid(test.Cid4)
+	everywhere.ReplicationFactorMin = -1
+	everywhere.ReplicationFactorMax = -1
+
+	ipfsPins := map[string]api.IPFSPinStatus{
+		test.Cid2.String():     api.IPFSPinStatusRecursive,
+		test.Cid3.String():     api.IPFSPinStatusRecursive,
+		test.Cid4.String():     api.IPFSPinStatusRecursive,
+		test.Cid5.String():     api.IPFSPinStatusRecursive,
+		test.SlowCid1.String(): api.IPFSPinStatusIndirect,
+	}
+
+	d := diffIPFSPins(
+		"cluster", "ipfs",
+		test.PeerID1,
+		[]*api.Pin{local, remote, direct, everywhere},
+		ipfsPins,
+	)
+
+	if len(d.Missing) != 1 || !d.Missing[0].Cid.Equals(test.Cid1) {
+		t.Error("expected Cid1 to be missing:", d.Missing)
+	}
+	if len(d.Extra) != 1 || !d.Extra[0].Cid.Equals(test.Cid5) {
+		t.Error("expected Cid5 to be extra:", d.Extra)
+	}
+	if len(d.Degraded) != 1 || !d.Degraded[0].Cid.Equals(test.Cid3) {
+		t.Error("expected Cid3 to be degraded:", d.Degraded)
+	}
+}
@@ -67,6 +67,40 @@ func textFormatObject(resp interface{}) {
 		textFormatPrintMetric(r)
 	case *api.Alert:
 		textFormatPrintAlert(r)
+	case *api.DetectorState:
+		textFormatPrintDetectorState(r)
+	case *api.StartupWarmup:
+		textFormatPrintStartupWarmup(r)
+	case *api.ConsensusStats:
+		textFormatPrintConsensusStats(r)
+	case *api.PeerResources:
+		textFormatPrintPeerResources(r)
+	case *api.ConnectivitySnapshot:
+		textFormatPrintConnectivitySnapshot(r)
+	case *api.ShardInfo:
+		textFormatPrintShardInfo(r)
+	case *api.ErasureReconstruction:
+		textFormatPrintErasureReconstruction(r)
+	case *api.Operation:
+		textFormatPrintOperation(r)
+	case *api.PinChanges:
+		textFormatPrintPinChanges(r)
+	case *api.LifecycleEvents:
+		textFormatPrintLifecycleEvents(r)
+	case *api.LifecycleEvent:
+		textFormatPrintLifecycleEvent(r)
+	case *api.PinTemplate:
+		textFormatPrintPinTemplate(r)
+	case *api.DenylistEntry:
+		textFormatPrintDenylistEntry(r)
+	case *api.PinsetSnapshot:
+		textFormatPrintPinsetSnapshot(r)
+	case *api.PinsetRollback:
+		textFormatPrintPinsetRollback(r)
+	case *api.SecretRotation:
+		textFormatPrintSecretRotation(r)
+	case *api.PeerHandover:
+		textFormatPrintPeerHandover(r)
 	case []*api.ID:
 		for _, item := range r {
 			textFormatObject(item)
@@ -93,6 +127,10 @@ func textFormatObject(resp interface{}) {
 		}
 	case *api.GlobalRepoGC:
 		textFormatPrintGlobalRepoGC(r)
+	case *api.GlobalDAGAudit:
+		textFormatPrintGlobalDAGAudit(r)
+	case *api.GlobalConsistencyReport:
+		textFormatPrintGlobalConsistencyReport(r)
 	case []string:
 		for _, item := range r {
 			textFormatObject(item)
@@ -101,6 +139,54 @@ func textFormatObject(resp interface{}) {
 		for _, item := range r {
 			textFormatObject(item)
 		}
+	case []*api.DetectorState:
+		for _, item := range r {
+			textFormatObject(item)
+		}
+	case []*api.ConnectivitySnapshot:
+		for _, item := range r {
+			textFormatObject(item)
+		}
+	case []*api.ConsensusStats:
+		for _, item := range r {
+			textFormatObject(item)
+		}
+	case []*api.ShardInfo:
+		for _, item := range r {
+			textFormatObject(item)
+		}
+	case []*api.Operation:
+		for _, item := range r {
+			textFormatObject(item)
+		}
+	case []*api.PinTemplate:
+		for _, item := range r {
+			textFormatObject(item)
+		}
+	case []*api.DenylistEntry:
+		for _, item := range r {
+			textFormatObject(item)
+		}
+	case []*api.PinsetSnapshot:
+		for _, item := range r {
+			textFormatObject(item)
+		}
+	case *pinsetDiff:
+		textFormatPrintPinsetDiff(r)
+	case *benchReport:
+		textFormatPrintBenchReport(r)
+	case map[string]string:
+		textFormatPrintRPCPolicy(r)
+	case *api.TrackerSettings:
+		textFormatPrintTrackerSettings(r)
+	case []*api.APIListeners:
+		for _, item := range r {
+			textFormatObject(item)
+		}
+	case *api.APIListeners:
+		textFormatPrintAPIListeners(r)
+	case *api.PeerVersions:
+		textFormatPrintPeerVersions(r)
 	default:
 		checkErr("", errors.New("unsupported type returned"))
 	}
@@ -118,6 +204,9 @@ func textFormatPrintID(obj *api.ID) {
 		obj.Peername,
 		len(obj.ClusterPeers)-1,
 	)
+	if len(obj.Labels) > 0 {
+		fmt.Printf("  > Labels: %s\n", formatLabels(obj.Labels))
+	}
 
 	addrs := make(sort.StringSlice, 0, len(obj.Addresses))
 	for _, a := range obj.Addresses {
@@ -224,6 +313,37 @@ func textFormatPrintPin(obj *api.Pin) {
 	}
 	fmt.Printf(" | Exp: %s", expireAt)
 
+	if obj.Namespace != "" {
+		fmt.Printf(" | Namespace: %s", obj.Namespace)
+	}
+
+	if obj.Protected {
+		fmt.Printf(" | Protected")
+	}
+
+	if !obj.ScheduleAt.IsZero() {
+		fmt.Printf(" | Scheduled: %s", obj.ScheduleAt.Format("2006-01-02 15:04:05"))
+	}
+
+	if obj.VerifyInterval > 0 {
+		fmt.Printf(" | Verify: %s", obj.VerifyInterval)
+	}
+
+	if obj.PinTimeout > 0 || obj.UnpinTimeout > 0 || obj.TimeoutProfile != "" {
+		fmt.Printf(" | Timeouts: %s/%s", obj.PinTimeout, obj.UnpinTimeout)
+		if obj.TimeoutProfile != "" {
+			fmt.Printf(" (%s)", obj.TimeoutProfile)
+		}
+	}
+
+	if obj.ProvideStrategy != api.ProvideStrategyDefault {
+		fmt.Printf(" | Provide: %s", obj.ProvideStrategy)
+	}
+
+	if trashedAt, ok := obj.TrashedAt(); ok {
+		fmt.Printf(" | Trashed: %s", trashedAt.Format("2006-01-02 15:04:05"))
+	}
+
 	added := "unknown"
 	if !obj.Timestamp.IsZero() {
 		added = obj.Timestamp.Format("2006-01-02 15:04:05")
@@ -249,16 +369,353 @@ func textFormatPrintMetric(obj *api.Metric) {
 		v = humanize.Bytes(uint64(obj.Weight))
 	}
 
-	fmt.Printf("%s | %s: %s | Expires in: %s\n", peer.Encode(obj.Peer), obj.Name, v, humanize.Time(time.Unix(0, obj.Expire)))
+	fmt.Printf("%s | %s: %s | Expires in: %s", peer.Encode(obj.Peer), obj.Name, v, humanize.Time(time.Unix(0, obj.Expire)))
+	if obj.Interval > 0 {
+		fmt.Printf(" | Interval: %s", time.Duration(obj.Interval))
+	}
+	fmt.Println()
 }
 
 func textFormatPrintAlert(obj *api.Alert) {
-	fmt.Printf("%s: %s. Expired at: %s. Triggered at: %s\n",
+	fmt.Printf("%s: %s. Expired at: %s. Triggered at: %s",
 		obj.Peer,
 		obj.Name,
 		humanize.Time(time.Unix(0, obj.Expire)),
 		humanize.Time(obj.TriggeredAt),
 	)
+	if len(obj.Labels) > 0 {
+		fmt.Printf(". Labels: %s", formatLabels(obj.Labels))
+	}
+	fmt.Println()
+}
+
+// formatLabels returns peer labels as a sorted list of key=value pairs.
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+func textFormatPrintDetectorState(obj *api.DetectorState) {
+	status := "OK"
+	switch {
+	case obj.Suppressed:
+		status = "SUPPRESSED"
+	case obj.Failed:
+		status = "FAILED"
+	case obj.Expired:
+		status = "EXPIRED"
+	}
+	fmt.Printf("%s | %s: %s | Phi: %.2f/%.2f | Samples: %d | Last received: %s",
+		peer.Encode(obj.Peer),
+		obj.Metric,
+		status,
+		obj.Phi,
+		obj.Threshold,
+		obj.Samples,
+		humanize.Time(obj.LastReceived),
+	)
+	if !obj.LastAlert.IsZero() {
+		fmt.Printf(" | Last alert: %s", humanize.Time(obj.LastAlert))
+	}
+	fmt.Println()
+}
+
+func textFormatPrintConsensusStats(obj *api.ConsensusStats) {
+	fmt.Printf("%s", peer.Encode(obj.Peer))
+	switch {
+	case obj.Error != "":
+		fmt.Printf(" | ERROR: %s\n", obj.Error)
+		return
+	case obj.Raft != nil:
+		r := obj.Raft
+		fmt.Printf(" | Raft: %s | Leader: %s | Term: %d | Last: %d | Commit: %d | Applied: %d | Pending: %d",
+			r.State, peer.Encode(r.Leader), r.Term, r.LastIndex, r.CommitIndex, r.AppliedIndex, r.FSMPending)
+	case obj.CRDT != nil:
+		cs := obj.CRDT
+		fmt.Printf(" | CRDT: %d heads | Height: %d | Queued: %d", cs.Heads, cs.Height, cs.QueuedUpdates)
+	}
+	fmt.Printf(" | Sync lag: %d\n", obj.SyncLag)
+}
+
+func textFormatPrintPeerResources(obj *api.PeerResources) {
+	fmt.Printf("%s | %s\n", peer.Encode(obj.Peer), obj.Peername)
+	fmt.Printf("  > Memory: %s (heap: %s)\n", humanize.Bytes(obj.MemorySys), humanize.Bytes(obj.MemoryHeap))
+	fmt.Printf("  > CPU time: %s | CPUs: %d | Goroutines: %d\n", obj.CPUTime, obj.NumCPU, obj.Goroutines)
+	fmt.Printf("  > Bandwidth: in %s (%s/s) | out %s (%s/s)\n",
+		humanize.Bytes(uint64(obj.BandwidthTotalIn)), humanize.Bytes(uint64(obj.BandwidthRateIn)),
+		humanize.Bytes(uint64(obj.BandwidthTotalOut)), humanize.Bytes(uint64(obj.BandwidthRateOut)))
+	fmt.Printf("  > Datastore: %s\n", humanize.Bytes(obj.DatastoreSize))
+	switch {
+	case obj.IPFSError != "":
+		fmt.Printf("  > IPFS ERROR: %s\n", obj.IPFSError)
+	case obj.IPFSRepoStat != nil:
+		fmt.Printf("  > IPFS repo: %s of %s\n",
+			humanize.Bytes(obj.IPFSRepoStat.RepoSize), humanize.Bytes(obj.IPFSRepoStat.StorageMax))
+	}
+}
+
+func textFormatPrintStartupWarmup(obj *api.StartupWarmup) {
+	rate := "unlimited"
+	if obj.Rate > 0 {
+		rate = fmt.Sprintf("%d/s", obj.Rate)
+	}
+	op := obj.Operation
+	if op == nil {
+		fmt.Printf("%s | NOT STARTED | Rate: %s\n", obj.Peername, rate)
+		return
+	}
+	fmt.Printf("%s | %s | %d/%d items | Recovered: %d | Rate: %s | Started: %s",
+		obj.Peername,
+		strings.ToUpper(string(op.Status)),
+		op.Done,
+		op.Total,
+		obj.Recovered,
+		rate,
+		humanize.Time(op.StartedAt),
+	)
+	if !op.FinishedAt.IsZero() {
+		fmt.Printf(" | Finished: %s", humanize.Time(op.FinishedAt))
+	}
+	fmt.Println()
+}
+
+func textFormatPrintConnectivitySnapshot(obj *api.ConnectivitySnapshot) {
+	status := "OK"
+	if obj.Partitions > 1 {
+		status = "PARTITIONED"
+	} else if len(obj.Unreachable) > 0 {
+		status = "DEGRADED"
+	}
+	fmt.Printf("%s - %s | %s | Peers: %d | Reachable: %d | Partitions: %d\n",
+		obj.From.Format(time.RFC3339),
+		obj.To.Format(time.RFC3339),
+		status,
+		len(obj.Peers),
+		len(obj.Peers)-len(obj.Unreachable),
+		obj.Partitions,
+	)
+	for _, p := range obj.Unreachable {
+		fmt.Printf("  > Unreachable: %s\n", peer.Encode(p))
+	}
+}
+
+func textFormatPrintOperation(obj *api.Operation) {
+	progress := fmt.Sprintf("%d", obj.Done)
+	if obj.Total > 0 {
+		progress = fmt.Sprintf("%d/%d", obj.Done, obj.Total)
+	}
+	fmt.Printf("%s | %s | %s | Progress: %s | Started: %s",
+		obj.ID,
+		obj.Type,
+		strings.ToUpper(string(obj.Status)),
+		progress,
+		humanize.Time(obj.StartedAt),
+	)
+	if obj.Cid.Defined() {
+		fmt.Printf(" | CID: %s", obj.Cid)
+	}
+	if !obj.FinishedAt.IsZero() {
+		fmt.Printf(" | Finished: %s", humanize.Time(obj.FinishedAt))
+	}
+	if obj.Error != "" {
+		fmt.Printf(" | ERROR: %s", obj.Error)
+	}
+	fmt.Println()
+}
+
+func textFormatPrintPinTemplate(obj *api.PinTemplate) {
+	opts := obj.Options
+	rpl := "default"
+	if opts.ReplicationFactorMin != 0 || opts.ReplicationFactorMax != 0 {
+		rpl = fmt.Sprintf("%d--%d", opts.ReplicationFactorMin, opts.ReplicationFactorMax)
+	}
+	fmt.Printf("%s | Repl. Factor: %s | Mode: %s", obj.Name, rpl, opts.Mode)
+	if len(opts.UserAllocations) > 0 {
+		fmt.Printf(" | Allocations: %s", api.PeersToStrings(opts.UserAllocations))
+	}
+	if len(opts.Metadata) > 0 {
+		keys := make([]string, 0, len(opts.Metadata))
+		for k, v := range opts.Metadata {
+			keys = append(keys, k+"="+v)
+		}
+		sort.Strings(keys)
+		fmt.Printf(" | Metadata: %s", strings.Join(keys, ","))
+	}
+	expireIn := "∞"
+	if obj.ExpireIn > 0 {
+		expireIn = obj.ExpireIn.String()
+	}
+	fmt.Printf(" | Exp: %s\n", expireIn)
+}
+
+func textFormatPrintDenylistEntry(obj *api.DenylistEntry) {
+	fmt.Printf("%s | Source: %s\n", obj.Rule, obj.Source)
+}
+
+func textFormatPrintPinsetSnapshot(obj *api.PinsetSnapshot) {
+	fmt.Printf("%s | %s | Pins: %d\n", obj.Name, obj.Timestamp.Format(time.RFC3339), obj.NumPins)
+}
+
+func textFormatPrintPinsetRollback(obj *api.PinsetRollback) {
+	if obj.DryRun {
+		fmt.Printf("Rolling back to %s would pin %d and unpin %d items\n", obj.Snapshot, len(obj.Pinned), len(obj.Unpinned))
+	} else {
+		fmt.Printf("Rolled back to %s: %d pinned, %d unpinned\n", obj.Snapshot, len(obj.Pinned), len(obj.Unpinned))
+	}
+	for _, ci := range obj.Pinned {
+		fmt.Printf("+ %s\n", ci)
+	}
+	for _, ci := range obj.Unpinned {
+		fmt.Printf("- %s\n", ci)
+	}
+}
+
+func textFormatPrintSecretRotation(obj *api.SecretRotation) {
+	fmt.Printf("New cluster secret: %s\n", obj.Secret)
+	fmt.Printf("Switched peers: %d\n", len(obj.Peers))
+	for _, p := range obj.Peers {
+		fmt.Printf("  - %s\n", p)
+	}
+	if len(obj.Failed) > 0 {
+		fmt.Printf("Failed peers (update their configuration manually): %d\n", len(obj.Failed))
+		for _, p := range obj.Failed {
+			fmt.Printf("  - %s\n", p)
+		}
+	}
+}
+
+func textFormatPrintPeerHandover(obj *api.PeerHandover) {
+	fmt.Printf("Handed over %d pins from %s to %s\n", obj.Pins, obj.From, obj.To)
+}
+
+func textFormatPrintPinChanges(obj *api.PinChanges) {
+	fmt.Printf("Sequence: %d\n", obj.Sequence)
+	for _, ch := range obj.Changes {
+		fmt.Printf("%d | ", ch.Sequence)
+		if ch.Removed || ch.Pin == nil {
+			fmt.Printf("%s | UNPINNED\n", ch.Cid)
+			continue
+		}
+		textFormatPrintPin(ch.Pin)
+	}
+}
+
+func textFormatPrintLifecycleEvents(obj *api.LifecycleEvents) {
+	fmt.Printf("Sequence: %d\n", obj.Sequence)
+	for _, ev := range obj.Events {
+		textFormatPrintLifecycleEvent(ev)
+	}
+}
+
+func textFormatPrintLifecycleEvent(obj *api.LifecycleEvent) {
+	fmt.Printf("%d | %s | %s", obj.Sequence, obj.Timestamp.Format(time.RFC3339), obj.Event)
+	if obj.Subject != "" {
+		fmt.Printf(" | %s", obj.Subject)
+	}
+	if obj.Details != "" {
+		fmt.Printf(" | %s", obj.Details)
+	}
+	fmt.Println()
+}
+
+func textFormatPrintShardInfo(obj *api.ShardInfo) {
+	allocs := make([]string, 0, len(obj.Allocations))
+	for _, a := range obj.Allocations {
+		allocs = append(allocs, a.String())
+	}
+	fmt.Printf("%s | %s | Size: %s | Allocations: [%s]\n",
+		obj.Cid,
+		obj.Name,
+		humanize.Bytes(obj.Size),
+		strings.Join(allocs, ", "),
+	)
+	if obj.Status != nil {
+		textFormatPrintGPInfo(obj.Status)
+	}
+}
+
+func textFormatPrintErasureReconstruction(obj *api.ErasureReconstruction) {
+	how := "from data shards"
+	if obj.Decoded {
+		how = "decoded"
+	}
+	fmt.Printf("%s | reconstructed on %s (%s) | Blocks: %d | Size: %s\n",
+		obj.Cid,
+		obj.Peer,
+		how,
+		obj.Blocks,
+		humanize.Bytes(obj.Size),
+	)
+	fmt.Printf("    > Used shards: %v\n", obj.UsedShards)
+	if len(obj.MissingShards) > 0 {
+		fmt.Printf("    > Missing shards: %v\n", obj.MissingShards)
+	}
+}
+
+func textFormatPrintRPCPolicy(obj map[string]string) {
+	methods := make(sort.StringSlice, 0, len(obj))
+	for m := range obj {
+		methods = append(methods, m)
+	}
+	methods.Sort()
+
+	for _, m := range methods {
+		fmt.Printf("%-35s | %s\n", m, obj[m])
+	}
+}
+
+func textFormatPrintTrackerSettings(obj *api.TrackerSettings) {
+	fmt.Printf("concurrent_pins: %d\n", obj.ConcurrentPins)
+}
+
+func textFormatPrintAPIListeners(obj *api.APIListeners) {
+	fmt.Printf("%s:\n", obj.API)
+	for _, addr := range obj.HTTPListenMultiaddress {
+		fmt.Printf("  - %s\n", addr)
+	}
+	if obj.SSLCertFile != "" {
+		fmt.Printf("  TLS: %s, %s\n", obj.SSLCertFile, obj.SSLKeyFile)
+	}
+	if len(obj.CORSAllowedOrigins) > 0 {
+		fmt.Printf("  CORS origins: %s\n", strings.Join(obj.CORSAllowedOrigins, ", "))
+	}
+}
+
+func textFormatPrintPeerVersions(obj *api.PeerVersions) {
+	for _, pv := range obj.Peers {
+		name := peer.Encode(pv.ID)
+		if pv.Peername != "" {
+			name = pv.Peername
+		}
+		fmt.Printf("%-15s | %s | %s | IPFS: %s", name, pv.Version, pv.RPCProtocolVersion, pv.IPFSVersion)
+		if pv.Error != "" {
+			fmt.Printf(" | ERROR: %s", pv.Error)
+		}
+		fmt.Println()
+	}
+
+	printCounts := func(title string, counts map[string]int) {
+		versions := make(sort.StringSlice, 0, len(counts))
+		for v := range counts {
+			versions = append(versions, v)
+		}
+		versions.Sort()
+		fmt.Printf("%s:\n", title)
+		for _, v := range versions {
+			fmt.Printf("  > %s: %d peers\n", v, counts[v])
+		}
+	}
+	fmt.Println()
+	printCounts("Cluster versions", obj.Versions)
+	printCounts("RPC protocols", obj.RPCProtocolVersions)
+	printCounts("IPFS versions", obj.IPFSVersions)
+	if obj.RPCProtocolSkew {
+		fmt.Println("\nWARNING: the peers do not all speak the same RPC protocol. Finish upgrading them to the same version.")
+	}
 }
 
 func textFormatPrintGlobalRepoGC(obj *api.GlobalRepoGC) {
@@ -293,6 +750,104 @@ func textFormatPrintGlobalRepoGC(obj *api.GlobalRepoGC) {
 	}
 }
 
+func textFormatPrintGlobalConsistencyReport(obj *api.GlobalConsistencyReport) {
+	peers := make(sort.StringSlice, 0, len(obj.PeerMap))
+	for peer := range obj.PeerMap {
+		peers = append(peers, peer)
+	}
+	peers.Sort()
+
+	for _, peer := range peers {
+		item := obj.PeerMap[peer]
+		if len(item.Peername) > 0 {
+			peer = item.Peername
+		}
+		switch {
+		case item.Error != "":
+			fmt.Printf("%-20s : ERROR: %s\n", peer, item.Error)
+			continue
+		case len(item.Issues) == 0:
+			fmt.Printf("%-20s : OK (%d items)\n", peer, item.Checked)
+			continue
+		}
+		fmt.Printf("%-20s : %d issues (%d items)\n", peer, len(item.Issues), item.Checked)
+		for _, issue := range item.Issues {
+			fmt.Printf("    > %s | %s | %s", issue.Cid, issue.Type, issue.Status)
+			if issue.Repaired {
+				fmt.Printf(" | REPAIRING")
+			}
+			fmt.Printf("\n")
+		}
+	}
+}
+
+func textFormatPrintGlobalDAGAudit(obj *api.GlobalDAGAudit) {
+	fmt.Printf("%s:\n", obj.Cid)
+	peers := make(sort.StringSlice, 0, len(obj.PeerMap))
+	for peer := range obj.PeerMap {
+		peers = append(peers, peer)
+	}
+	peers.Sort()
+
+	for _, peer := range peers {
+		item := obj.PeerMap[peer]
+		if len(item.Peername) > 0 {
+			peer = item.Peername
+		}
+		switch {
+		case item.Error != "":
+			fmt.Printf("    > %-20s : ERROR: %s\n", peer, item.Error)
+		case item.Ok():
+			fmt.Printf("    > %-20s : OK (%d blocks)\n", peer, item.Blocks)
+		default:
+			fmt.Printf("    > %-20s : %d corrupted, %d missing (%d blocks)", peer, len(item.Corrupted), len(item.Missing), item.Blocks)
+			if item.Repaired {
+				fmt.Printf(" | REPAIRING")
+			}
+			fmt.Printf("\n")
+		}
+		for _, c := range item.Corrupted {
+			fmt.Printf("      - corrupted: %s\n", c)
+		}
+		for _, c := range item.Missing {
+			fmt.Printf("      - missing: %s\n", c)
+		}
+	}
+}
+
+func textFormatPrintPinsetDiff(obj *pinsetDiff) {
+	printEntries := func(kind string, entries []diffEntry) {
+		for _, e := range entries {
+			fmt.Printf("%-8s | %s | %s", kind, e.Cid, e.Name)
+			if e.Reason != "" {
+				fmt.Printf(" | %s", e.Reason)
+			}
+			fmt.Println()
+		}
+	}
+	printEntries("MISSING", obj.Missing)
+	printEntries("EXTRA", obj.Extra)
+	printEntries("DEGRADED", obj.Degraded)
+}
+
+func textFormatPrintBenchReport(obj *benchReport) {
+	fmt.Printf("Run %s (%s): %d items, %d errors in %s (%.2f pinned/s)\n",
+		obj.ID, obj.Mode, obj.Count, obj.Errors, obj.Duration.Round(time.Millisecond), obj.Throughput)
+	fmt.Printf("%-8s %10s %10s %10s %10s %10s %10s %10s\n", "", "min", "mean", "p50", "p90", "p95", "p99", "max")
+	printStats := func(name string, st latencyStats) {
+		fmt.Printf("%-8s", name)
+		for _, d := range []time.Duration{st.Min, st.Mean, st.P50, st.P90, st.P95, st.P99, st.Max} {
+			fmt.Printf(" %10s", d.Round(time.Millisecond))
+		}
+		fmt.Println()
+	}
+	printStats("submit", obj.Submit)
+	printStats("pinned", obj.Pinned)
+	if obj.FirstError != "" {
+		fmt.Printf("First error: %s\n", obj.FirstError)
+	}
+}
+
 func textFormatPrintError(obj *api.Error) {
 	fmt.Printf("An error occurred:\n")
 	fmt.Printf("  Code: %d\n", obj.Code)
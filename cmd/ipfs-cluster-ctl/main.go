@@ -4,18 +4,23 @@ package main
 import (
 	"context"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/ipfs/ipfs-cluster/adder/encryption"
 	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/api/graph"
 	"github.com/ipfs/ipfs-cluster/api/rest/client"
 
 	cid "github.com/ipfs/go-cid"
+	shell "github.com/ipfs/go-ipfs-api"
 	logging "github.com/ipfs/go-log/v2"
 	peer "github.com/libp2p/go-libp2p-core/peer"
 	ma "github.com/multiformats/go-multiaddr"
@@ -41,6 +46,10 @@ var logger = logging.Logger("cluster-ctl")
 
 var globalClient client.Client
 
+// globalClientConfig is used as template for any additional clients (i.e.
+// "diff --other-host").
+var globalClientConfig *client.Config
+
 // Description provides a short summary of the functionality of this tool
 var Description = fmt.Sprintf(`
 %s is a tool to manage IPFS Cluster nodes.
@@ -177,33 +186,8 @@ requires authorization. implies --https, which you can disable with --force-http
 			checkErr("", errors.New("unsupported encoding"))
 		}
 
-		var configs []*client.Config
-		var err error
-		for _, addr := range strings.Split(c.String("host"), ",") {
-			multiaddr, err := ma.NewMultiaddr(addr)
-			checkErr("parsing host multiaddress", err)
-
-			if client.IsPeerAddress(multiaddr) && c.Bool("https") {
-				logger.Warn("Using libp2p-http for %s. The https flag will be ignored for this connection", addr)
-			}
-
-			var cfgs []*client.Config
-
-			// We can auto round-robin on DNS records when using
-			// libp2p-http or not using SSL. When using SSL we
-			// cannot use the resolve-IPs directly.
-			if client.IsPeerAddress(multiaddr) || !cfg.SSL {
-				cfgs, err = cfg.AsTemplateForResolvedAddress(ctx, multiaddr)
-			} else {
-				cfgs = cfg.AsTemplateFor([]ma.Multiaddr{multiaddr})
-			}
-			checkErr("creating configs", err)
-			configs = append(configs, cfgs...)
-		}
-
-		retries := len(configs)
-		globalClient, err = client.NewLBClient(&client.Failover{}, configs, retries)
-		checkErr("creating API client", err)
+		globalClientConfig = cfg
+		globalClient = newClient(ctx, cfg, c.String("host"))
 
 		// TODO: need to figure out best way to configure tracing for ctl
 		// leaving the following as it is still useful for local debugging.
@@ -256,6 +240,55 @@ This command provides a list of the ID information of all the peers in the Clust
 						return nil
 					},
 				},
+				{
+					Name:  "info",
+					Usage: "show the ID information of a peer",
+					Description: `
+This command shows the ID information of the peer with the given peer ID or
+peername. Peernames used by several peers are rejected: those peers must be
+given by their peer ID.
+`,
+					ArgsUsage: "<peer ID or peername>",
+					Flags:     []cli.Flag{},
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.Peer(ctx, c.Args().First())
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "resources",
+					Usage: "show the resources used by a peer",
+					Description: `
+This command shows the resources used by the peer with the given peer ID or
+peername: the memory and CPU time of the process, the traffic of its libp2p
+host and the size of its datastore, along with the repository usage of its
+IPFS daemon.
+`,
+					ArgsUsage: "<peer ID or peername>",
+					Flags:     []cli.Flag{},
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.PeerResources(ctx, c.Args().First())
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "versions",
+					Usage: "show the versions run by the cluster peers",
+					Description: `
+This command shows the cluster, RPC protocol and IPFS versions of every
+cluster peer, and how many peers run each of them. Peers which speak a
+different RPC protocol cannot work together: this happens during rolling
+upgrades and is also reported in the alerts of the peers.
+`,
+					Flags: []cli.Flag{},
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.PeerVersions(ctx)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
 				{
 					Name:  "rm",
 					Usage: "remove a peer from the Cluster",
@@ -265,17 +298,44 @@ automatically shut down. All other cluster peers should be online for the
 operation to succeed, otherwise some nodes may be left with an outdated list of
 cluster peers.
 `,
-					ArgsUsage: "<peer ID>",
+					ArgsUsage: "<peer ID or peername>",
 					Flags:     []cli.Flag{},
 					Action: func(c *cli.Context) error {
-						pid := c.Args().First()
-						p, err := peer.Decode(pid)
-						checkErr("parsing peer ID", err)
+						p := resolvePeer(ctx, c.Args().First())
 						cerr := globalClient.PeerRm(ctx, p)
 						formatResponse(c, nil, cerr)
 						return nil
 					},
 				},
+				{
+					Name:  "handover",
+					Usage: "transfer the allocations of a peer to a different one",
+					Description: `
+This command allocates the content pinned by a peer to a different one, which
+must be part of the cluster, instead. It completes the rotation of the
+identity of a peer ("ipfs-cluster-service identity rotate"): once the peer
+runs with its new identity, handing over the allocations of its previous ID
+to the new one lets it keep pinning the same content, which is otherwise
+re-allocated to other peers when the previous ID goes away.
+
+With CRDT consensus, the new ID must be trusted by the rest of peers (see
+"trusted_peers") for its updates to be accepted. With Raft consensus, the
+previous ID should be removed afterwards with "peers rm".
+`,
+					ArgsUsage: "<from peer ID> <to peer ID or peername>",
+					Flags:     []cli.Flag{},
+					Action: func(c *cli.Context) error {
+						if c.NArg() != 2 {
+							checkErr("parsing arguments", errors.New("a source and a destination peer are needed"))
+						}
+						from, err := peer.Decode(c.Args().Get(0))
+						checkErr("parsing peer ID", err)
+						to := resolvePeer(ctx, c.Args().Get(1))
+						resp, cerr := globalClient.PeerHandover(ctx, from, to)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
 			},
 		},
 		{
@@ -362,7 +422,11 @@ content.
 				},
 				cli.StringFlag{
 					Name:  "allocations, allocs",
-					Usage: "Optional comma-separated list of peer IDs",
+					Usage: "Optional comma-separated list of peer IDs or peernames",
+				},
+				cli.StringFlag{
+					Name:  "exclude-allocations",
+					Usage: "Optional comma-separated list of peer IDs or peernames which must never pin the content",
 				},
 				cli.BoolFlag{
 					Name:  "wait",
@@ -417,6 +481,31 @@ content.
 					Name:  "nocopy",
 					Usage: "Add the URL using filestore. Implies raw-leaves. (experimental)",
 				},
+				cli.StringFlag{
+					Name:  "expected-cid",
+					Usage: "Fail without pinning if the resulting CID is not this one",
+				},
+				cli.BoolFlag{
+					Name:  "checksum",
+					Usage: "Send a SHA-256 digest of the upload, verified by the peer before pinning",
+				},
+				cli.IntFlag{
+					Name:  "erasure-data-shards",
+					Usage: "Erasure-code the content in this number of data shards spread among peers (experimental)",
+				},
+				cli.IntFlag{
+					Name:  "erasure-parity-shards",
+					Usage: "Number of parity shards for erasure-coded content",
+					Value: 2,
+				},
+				cli.StringFlag{
+					Name:  "encrypt",
+					Usage: "Encrypt the files with this key configured in the peer",
+				},
+				cli.StringFlag{
+					Name:  "encryption-key",
+					Usage: "Encrypt the files with this hex or base64-encoded 32-byte key, which is not stored",
+				},
 
 				// TODO: Uncomment when sharding is supported.
 				// cli.BoolFlag{
@@ -469,13 +558,14 @@ content.
 
 				p.Metadata = parseMetadata(c.StringSlice("metadata"))
 				p.Name = name
-				if c.String("allocations") != "" {
-					p.UserAllocations = api.StringsToPeers(strings.Split(c.String("allocations"), ","))
-				}
+				p.UserAllocations = parsePeers(ctx, "allocations", c.String("allocations"))
+				p.ExcludeAllocations = parsePeers(ctx, "exclude-allocations", c.String("exclude-allocations"))
 				p.Format = c.String("format")
 				//p.Shard = shard
 				//p.ShardSize = c.Uint64("shard-size")
 				p.Shard = false
+				p.ErasureDataShards = c.Int("erasure-data-shards")
+				p.ErasureParityShards = c.Int("erasure-parity-shards")
 				p.Recursive = c.Bool("recursive")
 				p.Local = c.Bool("local")
 				p.Layout = c.String("layout")
@@ -495,6 +585,22 @@ content.
 				if p.NoCopy {
 					p.RawLeaves = true
 				}
+				if expected := c.String("expected-cid"); expected != "" {
+					ci, err := cid.Decode(expected)
+					checkErr("parsing expected-cid", err)
+					p.ExpectedCid = ci
+				}
+				p.Checksum = c.Bool("checksum")
+				p.Encrypt = c.String("encrypt")
+				if keyStr := c.String("encryption-key"); keyStr != "" {
+					if p.Encrypt != "" {
+						checkErr("", errors.New("use either --encrypt or --encryption-key"))
+					}
+					key, err := encryption.ParseKey(keyStr)
+					checkErr("parsing encryption-key", err)
+					p.Encrypt = api.UserEncryptionKey
+					p.EncryptionKey = key
+				}
 
 				// Prevent footgun
 				if p.Wrap && p.Format == "car" {
@@ -583,6 +689,36 @@ An optional allocations argument can be provided, allocations should be a
 comma-separated list of peer IDs on which we want to pin. Peers in allocations
 are prioritized over automatically-determined ones, but replication factors
 would still be respected.
+
+Peers given with --exclude-allocations are never allocated the pin. With a
+replication factor of -1, the content is pinned everywhere except on them.
+
+With --schedule-at, the pin is added to the cluster right away, but the
+peers only start pinning the content at the given time. Until then, its
+status is "scheduled". With --verify-interval, the peers check every so
+often that the content is still pinned and pin it again when it is not.
+
+--pin-timeout and --unpin-timeout override the timeouts used by the IPFS
+connectors of the allocated peers for this pin. --timeout-profile selects a
+set of timeouts from the "timeout_profiles" of their configuration instead.
+
+--provide-strategy sets what the allocated peers announce to the DHT once
+they have pinned the content: "roots", "all" blocks or "none". By default,
+the strategy configured in their IPFS connector applies.
+
+--ack sets when the command returns. With "consensus", the default, it is
+when the pin has been committed to the consensus layer. With "local", it is
+as soon as the peer has allocated the pin, which is then committed in the
+background. With "replicated", it is when --ack-peers trusted peers have
+the pin in their shared state.
+
+With --track and an /ipns/ path, the cluster resolves the name regularly
+and, when it points to a different CID, pins the new CID as an update of
+the previous one. The resolutions are recorded in the "ipns_history"
+metadata of the pin.
+
+With --dry-run, the pin is validated and allocated but not committed, and
+the command shows the peers that it would be allocated to. It requires a CID.
 `,
 					ArgsUsage: "<CID|Path>",
 					Flags: []cli.Flag{
@@ -603,7 +739,11 @@ would still be respected.
 						},
 						cli.StringFlag{
 							Name:  "allocations, allocs",
-							Usage: "Optional comma-separated list of peer IDs",
+							Usage: "Optional comma-separated list of peer IDs or peernames",
+						},
+						cli.StringFlag{
+							Name:  "exclude-allocations",
+							Usage: "Optional comma-separated list of peer IDs or peernames which must never pin the content",
 						},
 						cli.StringFlag{
 							Name:  "name, n",
@@ -619,10 +759,63 @@ would still be respected.
 							Name:  "expire-in",
 							Usage: "Duration after which pin should be unpinned automatically",
 						},
+						cli.StringFlag{
+							Name:  "schedule-at",
+							Usage: "RFC3339 time at which the content starts being pinned",
+						},
+						cli.DurationFlag{
+							Name:  "verify-interval",
+							Usage: "Check that the content is still pinned with this interval",
+						},
+						cli.DurationFlag{
+							Name:  "pin-timeout",
+							Usage: "Time without progress after which pinning fails",
+						},
+						cli.DurationFlag{
+							Name:  "unpin-timeout",
+							Usage: "Time after which unpinning fails",
+						},
+						cli.StringFlag{
+							Name:  "timeout-profile",
+							Usage: "Name of the timeout profile to use",
+						},
+						cli.StringFlag{
+							Name:  "provide-strategy",
+							Usage: "What to announce to the DHT: roots, all or none",
+						},
+						cli.StringFlag{
+							Name:  "ack",
+							Value: "consensus",
+							Usage: "When to return: local, consensus or replicated",
+						},
+						cli.IntFlag{
+							Name:  "ack-peers",
+							Usage: "Number of peers which must have the pin with --ack replicated",
+						},
+						cli.BoolFlag{
+							Name:  "track",
+							Usage: "Follow the IPNS name and pin its new targets when it changes",
+						},
+						cli.Uint64Flag{
+							Name:  "expected-size",
+							Usage: "Size hint in bytes, used to allocate to peers with enough free space",
+						},
+						cli.StringFlag{
+							Name:  "namespace",
+							Usage: "Namespace for the pin (admin users only when multi-tenancy is enabled)",
+						},
 						cli.StringSliceFlag{
 							Name:  "metadata",
 							Usage: "Pin metadata: key=value. Can be added multiple times",
 						},
+						cli.BoolFlag{
+							Name:  "protected",
+							Usage: "Prevents unpinning and expiry until cleared with \"pin unprotect\"",
+						},
+						cli.BoolFlag{
+							Name:  "dry-run",
+							Usage: "Show where the pin would be allocated without pinning",
+						},
 						cli.BoolFlag{
 							Name:  "no-status, ns",
 							Usage: "Prevents fetching pin status after pinning (faster, quieter)",
@@ -647,23 +840,24 @@ would still be respected.
 							rplMax = rpl
 						}
 
-						var userAllocs []peer.ID
-						if c.String("allocations") != "" {
-							allocs := strings.Split(c.String("allocations"), ",")
-							for i := range allocs {
-								allocs[i] = strings.TrimSpace(allocs[i])
-							}
-							userAllocs = api.StringsToPeers(allocs)
-							if len(userAllocs) != len(allocs) {
-								checkErr("decoding allocations", errors.New("some peer IDs could not be decoded"))
-							}
-						}
+						userAllocs := parsePeers(ctx, "allocations", c.String("allocations"))
+						excludedAllocs := parsePeers(ctx, "exclude-allocations", c.String("exclude-allocations"))
 						var expireAt time.Time
 						if expireIn := c.String("expire-in"); expireIn != "" {
 							d, err := time.ParseDuration(expireIn)
 							checkErr("parsing expire-in", err)
 							expireAt = time.Now().Add(d)
 						}
+						var scheduleAt time.Time
+						if v := c.String("schedule-at"); v != "" {
+							t, err := time.Parse(time.RFC3339, v)
+							checkErr("parsing schedule-at", err)
+							scheduleAt = t
+						}
+						provide, err := api.ProvideStrategyFromString(c.String("provide-strategy"))
+						checkErr("parsing provide-strategy", err)
+						ack, err := api.AckLevelFromString(c.String("ack"))
+						checkErr("parsing ack", err)
 
 						opts := api.PinOptions{
 							ReplicationFactorMin: rplMin,
@@ -671,8 +865,34 @@ would still be respected.
 							Name:                 c.String("name"),
 							Mode:                 api.PinModeFromString(c.String("mode")),
 							UserAllocations:      userAllocs,
+							ExcludeAllocations:   excludedAllocs,
 							ExpireAt:             expireAt,
+							ScheduleAt:           scheduleAt,
+							VerifyInterval:       c.Duration("verify-interval"),
+							PinTimeout:           c.Duration("pin-timeout"),
+							UnpinTimeout:         c.Duration("unpin-timeout"),
+							TimeoutProfile:       c.String("timeout-profile"),
+							ProvideStrategy:      provide,
+							Ack:                  ack,
+							AckPeers:             c.Int("ack-peers"),
 							Metadata:             parseMetadata(c.StringSlice("metadata")),
+							ExpectedSize:         c.Uint64("expected-size"),
+							Namespace:            c.String("namespace"),
+							Protected:            c.Bool("protected"),
+						}
+						if c.Bool("track") {
+							if !strings.HasPrefix(arg, "/ipns/") {
+								checkErr("tracking", errors.New("--track needs an /ipns/ path"))
+							}
+							opts.Metadata[api.IPNSTrackMetaKey] = "true"
+						}
+
+						if c.Bool("dry-run") {
+							ci, err := cid.Decode(arg)
+							checkErr("parsing cid", err)
+							pin, cerr := globalClient.PinDryRun(ctx, ci, opts)
+							formatResponse(c, pin, cerr)
+							return nil
 						}
 
 						pin, cerr := globalClient.PinPath(ctx, arg, opts)
@@ -699,6 +919,10 @@ trigger unpinning operations in all the IPFS nodes holding the content.
 When the request has succeeded, the command returns the status of the CID
 in the cluster. The CID should disappear from the list offered by "pin ls",
 although unpinning operations in the cluster may take longer or fail.
+
+When the peer has an unpin_grace_period, the CID is moved to the trash
+instead and stays pinned until the period passes. Use "pin restore" to take
+it out of the trash in the meantime.
 `,
 					ArgsUsage: "<CID|Path>",
 					Flags: []cli.Flag{
@@ -732,6 +956,68 @@ although unpinning operations in the cluster may take longer or fail.
 						return nil
 					},
 				},
+				{
+					Name:  "restore",
+					Usage: "Take an unpinned item out of the trash",
+					Description: `
+This command restores a CID unpinned while the peer has an unpin_grace_period,
+so that it is not unpinned when the period passes. Pinning the CID again has
+the same effect.
+`,
+					ArgsUsage: "<CID>",
+					Action: func(c *cli.Context) error {
+						ci, err := cid.Decode(c.Args().First())
+						checkErr("parsing cid", err)
+						pin, cerr := globalClient.Restore(ctx, ci)
+						formatResponse(c, pin, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "unprotect",
+					Usage: "Allow unpinning a protected item",
+					Description: `
+This command clears the protection of a pin added with "pin add --protected",
+so that it can be unpinned and expire again. It requires admin credentials
+when the REST API is multi-tenant. CIDs listed in the protected_cids
+configuration of the peer cannot be unprotected.
+`,
+					ArgsUsage: "<CID>",
+					Action: func(c *cli.Context) error {
+						ci, err := cid.Decode(c.Args().First())
+						checkErr("parsing cid", err)
+						pin, cerr := globalClient.Unprotect(ctx, ci)
+						formatResponse(c, pin, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "audit",
+					Usage: "Verify the blocks of a pinned item",
+					Description: `
+This command asks every peer the given CID is allocated to to read the blocks
+of its DAG from IPFS and hash them again, and lists the blocks which are
+corrupted or missing on each peer. The DAG is only walked down to the depth
+of the pin. Sharded pins must be audited shard by shard (see "pin shards").
+
+With --repair, peers remove the corrupted blocks and pin the CID again so that
+IPFS fetches them from the network.
+`,
+					ArgsUsage: "<CID>",
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "repair",
+							Usage: "remove corrupted blocks and fetch them again",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						ci, err := cid.Decode(c.Args().First())
+						checkErr("parsing cid", err)
+						audit, cerr := globalClient.Audit(ctx, ci, c.Bool("repair"))
+						formatResponse(c, audit, cerr)
+						return nil
+					},
+				},
 				{
 					Name:  "update",
 					Usage: "Pin a new item based on an existing one",
@@ -745,10 +1031,13 @@ The cluster peers will try to Pin the new item on IPFS using the "pin update"
 command. This is especially efficient when the content of two pins (their DAGs)
 are similar.
 
+Both items can be given as CIDs or IPFS paths, which are resolved by the
+cluster peer.
+
 Unlike the "pin update" command in the ipfs daemon, this will not unpin the
 existing item from the cluster. Please run "pin rm" for that.
 `,
-					ArgsUsage: "<existing-CID> <new-CID|Path>",
+					ArgsUsage: "<existing-CID|Path> <new-CID|Path>",
 					Flags: []cli.Flag{
 						cli.StringFlag{
 							Name:  "name, n",
@@ -776,9 +1065,9 @@ existing item from the cluster. Please run "pin rm" for that.
 					Action: func(c *cli.Context) error {
 						from := c.Args().Get(0)
 						to := c.Args().Get(1)
-
-						fromCid, err := cid.Decode(from)
-						checkErr("parsing from Cid", err)
+						if from == "" || to == "" {
+							checkErr("", errors.New("an existing and a new item are needed"))
+						}
 
 						var expireAt time.Time
 						if expireIn := c.String("expire-in"); expireIn != "" {
@@ -788,12 +1077,83 @@ existing item from the cluster. Please run "pin rm" for that.
 						}
 
 						opts := api.PinOptions{
-							PinUpdate: fromCid,
-							Name:      c.String("name"),
-							ExpireAt:  expireAt,
+							Name:     c.String("name"),
+							ExpireAt: expireAt,
+						}
+
+						pin, cerr := globalClient.PinUpdate(ctx, from, to, opts)
+						if cerr != nil {
+							formatResponse(c, nil, cerr)
+							return nil
 						}
+						handlePinResponseFormatFlags(
+							ctx,
+							c,
+							pin,
+							api.TrackerStatusPinned,
+						)
+						return nil
+					},
+				},
+				{
+					Name:  "clone",
+					Usage: "Pin an item with the options of an existing pin or a template",
+					Description: `
+This command pins a CID using the options of an existing pin (--from) or of a
+pin template (--template): replication factors, mode, allocations, metadata
+and expiry. When cloning a pin which expires, the new pin expires after the
+same time, counting from now. Templates can be managed with "pin template".
 
-						pin, cerr := globalClient.PinPath(ctx, to, opts)
+Unlike "pin update", the new pin goes through the allocation process. Only
+the name is not copied, and can be set with --name.
+`,
+					ArgsUsage: "<CID>",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "from",
+							Usage: "CID of the pin to take the options from",
+						},
+						cli.StringFlag{
+							Name:  "template, t",
+							Usage: "Name of the pin template to take the options from",
+						},
+						cli.StringFlag{
+							Name:  "name, n",
+							Value: "",
+							Usage: "Sets a name for the new pin",
+						},
+						cli.BoolFlag{
+							Name:  "no-status, ns",
+							Usage: "Prevents fetching pin status after pinning (faster, quieter)",
+						},
+						cli.BoolFlag{
+							Name:  "wait, w",
+							Usage: waitFlagDesc,
+						},
+						cli.DurationFlag{
+							Name:  "wait-timeout, wt",
+							Value: 0,
+							Usage: waitTimeoutFlagDesc,
+						},
+					},
+					Action: func(c *cli.Context) error {
+						ci, err := cid.Decode(c.Args().First())
+						checkErr("parsing cid", err)
+
+						var pin *api.Pin
+						var cerr error
+						switch {
+						case c.String("from") != "" && c.String("template") != "":
+							checkErr("", errors.New("only one of --from and --template can be used"))
+						case c.String("from") != "":
+							from, err := cid.Decode(c.String("from"))
+							checkErr("parsing from Cid", err)
+							pin, cerr = globalClient.PinClone(ctx, ci, from, c.String("name"))
+						case c.String("template") != "":
+							pin, cerr = globalClient.PinWithTemplate(ctx, ci, c.String("template"), c.String("name"))
+						default:
+							checkErr("", errors.New("either --from or --template must be provided"))
+						}
 						if cerr != nil {
 							formatResponse(c, nil, cerr)
 							return nil
@@ -807,6 +1167,258 @@ existing item from the cluster. Please run "pin rm" for that.
 						return nil
 					},
 				},
+				{
+					Name:  "bulk",
+					Usage: "Operate on all the pins selected by their metadata",
+					Description: `
+These commands act on all the pins with the metadata given with --metadata
+key=value, which can be repeated to select pins with all the given values.
+An empty value selects the pins with the key, whatever its value. Users
+restricted to a namespace only select pins in their namespace.
+`,
+					Subcommands: []cli.Command{
+						{
+							Name:  "rm",
+							Usage: "Unpin the selected pins",
+							Flags: []cli.Flag{metadataSelectorFlag},
+							Action: func(c *cli.Context) error {
+								resp, cerr := globalClient.UnpinMatching(ctx, parsePinSelector(c))
+								formatResponse(c, resp, cerr)
+								return nil
+							},
+						},
+						{
+							Name:  "recover",
+							Usage: "Recover the selected pins in all peers",
+							Flags: []cli.Flag{metadataSelectorFlag},
+							Action: func(c *cli.Context) error {
+								resp, cerr := globalClient.RecoverMatching(ctx, parsePinSelector(c))
+								formatResponse(c, resp, cerr)
+								return nil
+							},
+						},
+						{
+							Name:  "replicate",
+							Usage: "Set the replication factors of the selected pins",
+							Flags: []cli.Flag{
+								metadataSelectorFlag,
+								cli.IntFlag{
+									Name:  "replication, r",
+									Value: 0,
+									Usage: "Sets a custom replication factor (overrides -rmax and -rmin)",
+								},
+								cli.IntFlag{
+									Name:  "replication-min, rmin",
+									Value: 0,
+									Usage: "Sets the minimum replication factor",
+								},
+								cli.IntFlag{
+									Name:  "replication-max, rmax",
+									Value: 0,
+									Usage: "Sets the maximum replication factor",
+								},
+							},
+							Action: func(c *cli.Context) error {
+								rplMin := c.Int("replication-min")
+								rplMax := c.Int("replication-max")
+								if rpl := c.Int("replication"); rpl != 0 {
+									rplMin = rpl
+									rplMax = rpl
+								}
+								resp, cerr := globalClient.ReplicateMatching(ctx, api.BulkReplication{
+									Selector:             parsePinSelector(c),
+									ReplicationFactorMin: rplMin,
+									ReplicationFactorMax: rplMax,
+								})
+								formatResponse(c, resp, cerr)
+								return nil
+							},
+						},
+					},
+				},
+				{
+					Name:  "template",
+					Usage: "Manage pin templates",
+					Description: `
+Pin templates are named sets of pin options, which can be used to pin items
+with "pin clone --template". They are stored in the shared state, so all
+peers see the same templates.
+`,
+					Subcommands: []cli.Command{
+						{
+							Name:  "ls",
+							Usage: "List pin templates",
+							Action: func(c *cli.Context) error {
+								resp, cerr := globalClient.PinTemplates(ctx)
+								formatResponse(c, resp, cerr)
+								return nil
+							},
+						},
+						{
+							Name:      "show",
+							Usage:     "Show a pin template",
+							ArgsUsage: "<name>",
+							Action: func(c *cli.Context) error {
+								resp, cerr := globalClient.PinTemplate(ctx, c.Args().First())
+								formatResponse(c, resp, cerr)
+								return nil
+							},
+						},
+						{
+							Name:      "set",
+							Usage:     "Create or replace a pin template",
+							ArgsUsage: "<name>",
+							Flags: []cli.Flag{
+								cli.IntFlag{
+									Name:  "replication, r",
+									Value: 0,
+									Usage: "Sets a custom replication factor (overrides -rmax and -rmin)",
+								},
+								cli.IntFlag{
+									Name:  "replication-min, rmin",
+									Value: 0,
+									Usage: "Sets the minimum replication factor",
+								},
+								cli.IntFlag{
+									Name:  "replication-max, rmax",
+									Value: 0,
+									Usage: "Sets the maximum replication factor",
+								},
+								cli.StringFlag{
+									Name:  "mode",
+									Value: "recursive",
+									Usage: "Select a way to pin: recursive or direct",
+								},
+								cli.DurationFlag{
+									Name:  "expire-in",
+									Usage: "Duration after which the pins made with the template are unpinned",
+								},
+								cli.StringSliceFlag{
+									Name:  "metadata",
+									Usage: "Pin metadata: key=value. Can be added multiple times",
+								},
+							},
+							Action: func(c *cli.Context) error {
+								rplMin := c.Int("replication-min")
+								rplMax := c.Int("replication-max")
+								if rpl := c.Int("replication"); rpl != 0 {
+									rplMin = rpl
+									rplMax = rpl
+								}
+								opts := api.PinOptions{
+									ReplicationFactorMin: rplMin,
+									ReplicationFactorMax: rplMax,
+									Mode:                 api.PinModeFromString(c.String("mode")),
+									Metadata:             parseMetadata(c.StringSlice("metadata")),
+								}
+								resp, cerr := globalClient.PinTemplateSet(ctx, c.Args().First(), opts, c.Duration("expire-in"))
+								formatResponse(c, resp, cerr)
+								return nil
+							},
+						},
+						{
+							Name:      "rm",
+							Usage:     "Remove a pin template",
+							ArgsUsage: "<name>",
+							Action: func(c *cli.Context) error {
+								cerr := globalClient.PinTemplateRm(ctx, c.Args().First())
+								formatResponse(c, nil, cerr)
+								return nil
+							},
+						},
+					},
+				},
+				{
+					Name:  "reshard",
+					Usage: "Re-add a pinned item with different parameters",
+					Description: `
+This command re-adds the content of an existing pin using the given
+parameters, for example to convert it to raw-leaves, to use a different
+chunker or to shard it across several peers. The resulting DAG is pinned
+with the same options as the original one and then the original pin is
+removed.
+
+The new pin stores the CID of the original pin in its metadata, under the
+"` + api.ReshardedFromMetaKey + `" key. Protected pins cannot be resharded.
+
+The reshard may take a long time for large DAGs, so it runs in the background
+and this command returns the operation tracking it. Use "ipfs-cluster-ctl
+operations" to follow its progress or cancel it.
+`,
+					ArgsUsage: "<CID>",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "layout",
+							Value: defaultAddParams.Layout,
+							Usage: "Dag layout to use for dag generation: balanced or trickle",
+						},
+						cli.StringFlag{
+							Name:  "chunker, s",
+							Usage: "'size-<size>' or 'rabin-<min>-<avg>-<max>'",
+							Value: defaultAddParams.Chunker,
+						},
+						cli.BoolFlag{
+							Name:  "raw-leaves",
+							Usage: "Use raw blocks for leaves (experimental)",
+						},
+						cli.IntFlag{
+							Name:  "cid-version",
+							Usage: "CID version. Non default implies raw-leaves",
+							Value: defaultAddParams.CidVersion,
+						},
+						cli.StringFlag{
+							Name:  "hash",
+							Usage: "Hash function to use. Implies cid-version=1",
+							Value: defaultAddParams.HashFun,
+						},
+						cli.BoolFlag{
+							Name:  "shard",
+							Usage: "Break the DAG into shards distributed among peers",
+						},
+						cli.Uint64Flag{
+							Name:  "shard-size",
+							Value: defaultAddParams.ShardSize,
+							Usage: "Maximum size of each shard (in bytes)",
+						},
+						cli.IntFlag{
+							Name:  "erasure-data-shards",
+							Usage: "Erasure-code the DAG in this number of data shards spread among peers (experimental)",
+						},
+						cli.IntFlag{
+							Name:  "erasure-parity-shards",
+							Usage: "Number of parity shards for erasure-coded content",
+							Value: 2,
+						},
+					},
+					Action: func(c *cli.Context) error {
+						cidStr := c.Args().First()
+						ci, err := cid.Decode(cidStr)
+						checkErr("parsing cid", err)
+
+						p := api.DefaultAddParams()
+						p.Layout = c.String("layout")
+						p.Chunker = c.String("chunker")
+						p.RawLeaves = c.Bool("raw-leaves")
+						p.CidVersion = c.Int("cid-version")
+						p.HashFun = c.String("hash")
+						if p.HashFun != defaultAddParams.HashFun {
+							p.CidVersion = 1
+						}
+						if p.CidVersion > 0 {
+							p.RawLeaves = true
+						}
+						p.Shard = c.Bool("shard")
+						p.ShardSize = c.Uint64("shard-size")
+						if n := c.Int("erasure-data-shards"); n > 0 {
+							p.ErasureDataShards = n
+							p.ErasureParityShards = c.Int("erasure-parity-shards")
+						}
+
+						resp, cerr := globalClient.Reshard(ctx, ci, p)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
 				{
 					Name:  "ls",
 					Usage: "List items in the cluster pinset",
@@ -823,15 +1435,31 @@ The filter only takes effect when listing all pins. The possible values are:
   - meta-pin (sharded pins)
   - clusterdag-pin (sharding-dag root pins)
   - shard-pin (individual shard pins)
+
+The --name and --name-prefix flags list only the pins with the given name, or
+with names starting with the given prefix. They are looked up in an index
+and do not require listing the full pinset.
+
+The --output flag writes one row per pin either as "csv" or as an aligned
+"table". Use --columns to select and order the columns and --no-header to
+omit the header row.
 `,
 					ArgsUsage: "[CID]",
-					Flags: []cli.Flag{
+					Flags: append([]cli.Flag{
 						cli.StringFlag{
 							Name:  "filter",
 							Usage: "Comma separated list of pin types. See help above.",
 							Value: "all",
 						},
-					},
+						cli.StringFlag{
+							Name:  "name",
+							Usage: "only list pins with this name",
+						},
+						cli.StringFlag{
+							Name:  "name-prefix",
+							Usage: "only list pins with names starting with this prefix",
+						},
+					}, tabularFlags(pinColumns)...),
 					Action: func(c *cli.Context) error {
 						cidStr := c.Args().First()
 						if cidStr != "" {
@@ -839,6 +1467,12 @@ The filter only takes effect when listing all pins. The possible values are:
 							checkErr("parsing cid", err)
 							resp, cerr := globalClient.Allocation(ctx, ci)
 							formatResponse(c, resp, cerr)
+						} else if name := c.String("name"); name != "" {
+							resp, cerr := globalClient.PinsByName(ctx, name, false)
+							formatResponse(c, resp, cerr)
+						} else if prefix := c.String("name-prefix"); prefix != "" {
+							resp, cerr := globalClient.PinsByName(ctx, prefix, true)
+							formatResponse(c, resp, cerr)
 						} else {
 							var filter api.PinType
 							strFilter := strings.Split(c.String("filter"), ",")
@@ -852,41 +1486,171 @@ The filter only takes effect when listing all pins. The possible values are:
 						return nil
 					},
 				},
-			},
-		},
-		{
-			Name:  "status",
-			Usage: "Retrieve the status of tracked items",
-			Description: `
-This command retrieves the status of the CIDs tracked by IPFS
-Cluster, including which member is pinning them and any errors.
-If a CID is provided, the status will be only fetched for a single
-item.  Metadata CIDs are included in the status response
-
-When the --local flag is passed, it will only fetch the status from the
-contacted cluster peer. By default, status will be fetched from all peers.
+				{
+					Name:  "changes",
+					Usage: "List the changes to the pinset since a sequence number",
+					Description: `
+This command lists the pins which were added, updated or removed since the
+given sequence number, along with the current sequence number, which can be
+given to the next invocation. Without a sequence number, only the current one
+is shown. It should be obtained before listing the full pinset with "ls".
+
+Sequence numbers are specific to the peer answering the request. An error is
+returned when the changes since the given sequence number are no longer
+known, in which case the full pinset should be listed again.
+`,
+					ArgsUsage: "[sequence]",
+					Action: func(c *cli.Context) error {
+						var since uint64
+						if seqStr := c.Args().First(); seqStr != "" {
+							var err error
+							since, err = strconv.ParseUint(seqStr, 10, 64)
+							checkErr("parsing sequence number", err)
+						}
+						resp, cerr := globalClient.PinChanges(ctx, since)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "shards",
+					Usage: "List the shards of a sharded pin",
+					Description: `
+This command lists the shards which make up a sharded pin (a "meta-pin" or
+a "shard-pin"), along with their size, allocations and status.
+
+When the --recover flag is provided with the CID of one of the shards,
+a recover operation is triggered for that shard only, on every cluster
+peer, and its resulting status is returned.
+`,
+					ArgsUsage: "<CID>",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "recover",
+							Usage: "recover the given shard of the pin",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						cidStr := c.Args().First()
+						ci, err := cid.Decode(cidStr)
+						checkErr("parsing cid", err)
+
+						if shardStr := c.String("recover"); shardStr != "" {
+							shard, err := cid.Decode(shardStr)
+							checkErr("parsing shard cid", err)
+							resp, cerr := globalClient.RecoverShard(ctx, ci, shard)
+							formatResponse(c, resp, cerr)
+							return nil
+						}
+
+						resp, cerr := globalClient.Shards(ctx, ci)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "reconstruct",
+					Usage: "Rebuild erasure-coded content in the IPFS daemon of the peer",
+					Description: `
+This command rebuilds the DAG of content added with erasure coding (the
+--erasure-data-shards option of "add") in the IPFS daemon of the peer
+receiving the request, so that it can be retrieved from it. Only the shards
+which are pinned are used, and as many of them as data shards are needed.
+The rebuilt blocks are not pinned.
+`,
+					ArgsUsage: "<CID>",
+					Action: func(c *cli.Context) error {
+						cidStr := c.Args().First()
+						ci, err := cid.Decode(cidStr)
+						checkErr("parsing cid", err)
+
+						resp, cerr := globalClient.Reconstruct(ctx, ci)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "decrypt",
+					Usage: "Download and decrypt a file of an encrypted pin",
+					Description: `
+This command reads a file of content added with encryption (the --encrypt
+and --encryption-key options of "add") from the IPFS daemon of the peer
+receiving the request and writes it decrypted to the standard output, or to
+the given file. Use --path to select a file inside a directory. Content
+encrypted with a key supplied by the user needs that key.
+`,
+					ArgsUsage: "<CID>",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "path",
+							Usage: "path of the file inside the pinned directory",
+						},
+						cli.StringFlag{
+							Name:  "encryption-key",
+							Usage: "hex or base64-encoded key used to encrypt the content",
+						},
+						cli.StringFlag{
+							Name:  "output-file",
+							Usage: "write the file here instead of the standard output",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						cidStr := c.Args().First()
+						ci, err := cid.Decode(cidStr)
+						checkErr("parsing cid", err)
+
+						var key []byte
+						if keyStr := c.String("encryption-key"); keyStr != "" {
+							key, err = encryption.ParseKey(keyStr)
+							checkErr("parsing encryption-key", err)
+						}
+
+						w := io.Writer(os.Stdout)
+						if path := c.String("output-file"); path != "" {
+							f, err := os.Create(path)
+							checkErr("creating output file", err)
+							defer f.Close()
+							w = f
+						}
+						cerr := globalClient.Decrypt(ctx, ci, c.String("path"), key, w)
+						checkErr("decrypting", cerr)
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:  "status",
+			Usage: "Retrieve the status of tracked items",
+			Description: `
+This command retrieves the status of the CIDs tracked by IPFS
+Cluster, including which member is pinning them and any errors.
+If CIDs are provided, the status will be only fetched for those items, with
+a single request.  Metadata CIDs are included in the status response
+
+When the --local flag is passed, it will only fetch the status from the
+contacted cluster peer. By default, status will be fetched from all peers.
+
+The --output flag writes one row per CID and peer either as "csv" or as an
+aligned "table". Use --columns to select and order the columns and
+--no-header to omit the header row.
 
 When the --filter flag is passed, it will only fetch the peer information
 where status of the pin matches at least one of the filter values (a comma
 separated list). The following are valid status values:
 
 ` + trackerStatusAllString(),
-			ArgsUsage: "[CID]",
-			Flags: []cli.Flag{
+			ArgsUsage: "[CID...]",
+			Flags: append([]cli.Flag{
 				localFlag(),
 				cli.StringFlag{
 					Name:  "filter",
 					Usage: "comma-separated list of filters",
 				},
-			},
+			}, tabularFlags(statusColumns)...),
 			Action: func(c *cli.Context) error {
-				cidStr := c.Args().First()
-				if cidStr != "" {
-					ci, err := cid.Decode(cidStr)
-					checkErr("parsing cid", err)
-					resp, cerr := globalClient.Status(ctx, ci, c.Bool("local"))
-					formatResponse(c, resp, cerr)
-				} else {
+				switch c.NArg() {
+				case 0:
 					filterFlag := c.String("filter")
 					filter := api.TrackerStatusFromString(c.String("filter"))
 					if filter == api.TrackerStatusUndefined && filterFlag != "" {
@@ -894,6 +1658,20 @@ separated list). The following are valid status values:
 					}
 					resp, cerr := globalClient.StatusAll(ctx, filter, c.Bool("local"))
 					formatResponse(c, resp, cerr)
+				case 1:
+					ci, err := cid.Decode(c.Args().First())
+					checkErr("parsing cid", err)
+					resp, cerr := globalClient.Status(ctx, ci, c.Bool("local"))
+					formatResponse(c, resp, cerr)
+				default:
+					cids := make([]cid.Cid, 0, c.NArg())
+					for _, arg := range c.Args() {
+						ci, err := cid.Decode(arg)
+						checkErr("parsing cid", err)
+						cids = append(cids, ci)
+					}
+					resp, cerr := globalClient.StatusCids(ctx, cids, c.Bool("local"))
+					formatResponse(c, resp, cerr)
 				}
 				return nil
 			},
@@ -957,6 +1735,11 @@ to check that it matches the CLI version (shown by -v).
 					Description: `
 This command queries all connected cluster peers and their ipfs peers to generate a
 graph of the connections.  Output is a dot file encoding the cluster's connection state.
+
+The --format flag allows writing a Mermaid flowchart instead, which can be
+embedded in Markdown documents, or the raw graph in JSON. The links among
+cluster peers are labeled with the transport, direction and latency of the
+connections when known.
 `,
 					Flags: []cli.Flag{
 						cli.StringFlag{
@@ -964,6 +1747,11 @@ graph of the connections.  Output is a dot file encoding the cluster's connectio
 							Value: "",
 							Usage: "sets an output dot-file for the connectivity graph",
 						},
+						cli.StringFlag{
+							Name:  "format",
+							Value: graph.FormatDot,
+							Usage: "output format: dot, mermaid or json",
+						},
 						cli.BoolFlag{
 							Name:  "all-ipfs-peers",
 							Usage: "causes the graph to mark nodes for ipfs peers not directly in the cluster",
@@ -985,12 +1773,47 @@ graph of the connections.  Output is a dot file encoding the cluster's connectio
 							checkErr("creating output file", err)
 						}
 						defer w.Close()
-						err = makeDot(resp, w, c.Bool("all-ipfs-peers"))
+						format := c.String("format")
+						if format == graph.FormatJSON {
+							enc := json.NewEncoder(w)
+							enc.SetIndent("", "    ")
+							err = enc.Encode(resp)
+						} else {
+							err = graph.Write(resp, w, format, c.Bool("all-ipfs-peers"))
+						}
 						checkErr("printing graph", err)
 
 						return nil
 					},
 				},
+				{
+					Name:  "history",
+					Usage: "Show the connectivity history recorded by this peer",
+					Description: `
+This command shows how cluster peers were connected to each other over time,
+as recorded by the peer contacted, which helps finding out when the cluster
+was partitioned or peers were unreachable. Consecutive snapshots with the same
+connectivity are shown as a single period of time.
+
+Snapshots are only recorded when "connectivity_snapshot_interval" is set in
+the cluster configuration.
+`,
+					Flags: []cli.Flag{
+						cli.DurationFlag{
+							Name:  "since",
+							Usage: "only show the history for the given last period of time (i.e. 24h)",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						var since time.Time
+						if d := c.Duration("since"); d > 0 {
+							since = time.Now().Add(-d)
+						}
+						resp, cerr := globalClient.ConnectivityHistory(ctx, since)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
 				{
 					Name:  "metrics",
 					Usage: "List latest metrics logged by this peer",
@@ -1038,6 +1861,115 @@ trigger automatic repinnings if configured.
 						return nil
 					},
 				},
+				{
+					Name:  "detector",
+					Usage: "Show the failure detector state for every peer",
+					Description: `
+This command shows how the failure detector of this peer sees the latest
+metrics received from every cluster peer: the current phi value and the
+threshold above which the peer is considered failed, along with whether the
+metric has expired.
+
+Alerts for peers failing again shortly after a previous alert are
+suppressed when the monitor is configured with a flap window. Those peers
+are marked as "suppressed".
+`,
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.DetectorState(ctx)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "warmup",
+					Usage: "Show the progress of the startup warm-up of the peer",
+					Description: `
+This command shows the progress of the reconciliation of the pinset with IPFS
+that the contacted peer does when it starts: how many of the items tracked by
+the peer have been checked, and how many of those have been pinned or unpinned
+again.
+
+The rate at which items are pinned or unpinned again is limited by the
+"startup_warmup_rate" option in the cluster configuration.
+`,
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.StartupWarmup(ctx)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "events",
+					Usage: "List the lifecycle events of the peer",
+					Description: `
+This command lists the lifecycle events of the contacted peer after the given
+sequence number: peers joining or leaving the peerset, leader changes, the
+peer becoming ready, the end of the startup warm-up and the APIs starting.
+Without a sequence number, all the events kept by the peer are listed.
+
+With --follow, new events are listed as they happen, until interrupted.
+Sequence numbers are specific to the peer and restart when it restarts.
+`,
+					ArgsUsage: "[sequence]",
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "follow, f",
+							Usage: "keep listing new events",
+						},
+						cli.DurationFlag{
+							Name:  "interval",
+							Value: 2 * time.Second,
+							Usage: "how often to check for new events with --follow",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						var since uint64
+						if seqStr := c.Args().First(); seqStr != "" {
+							var err error
+							since, err = strconv.ParseUint(seqStr, 10, 64)
+							checkErr("parsing sequence number", err)
+						}
+						resp, cerr := globalClient.LifecycleEvents(ctx, since)
+						if !c.Bool("follow") {
+							formatResponse(c, resp, cerr)
+							return nil
+						}
+						for {
+							checkErr("listing lifecycle events", cerr)
+							if resp.Sequence < since {
+								// The peer restarted and numbers
+								// its events from 1 again.
+								since = 0
+							} else {
+								for _, ev := range resp.Events {
+									formatResponse(c, ev, nil)
+								}
+								since = resp.Sequence
+								time.Sleep(c.Duration("interval"))
+							}
+							resp, cerr = globalClient.LifecycleEvents(ctx, since)
+						}
+					},
+				},
+				{
+					Name:  "consensus",
+					Usage: "Show the consensus internals of every peer",
+					Description: `
+This command shows the internals of the consensus component of every peer
+in the peerset. For Raft, these are the state of the peer, the leader, the
+term and the log indexes. For CRDT, these are the number of heads of the
+DAG, its height and the number of updates waiting to be broadcasted.
+
+Every peer also comes with its sync lag: how far behind the most up-to-date
+peer it is, in log entries (Raft) or in DAG height (CRDT). Peers which are
+not in sync may see a different pinset than the rest of the cluster.
+`,
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.ConsensusStats(ctx)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
 			},
 		},
 		{
@@ -1066,6 +1998,623 @@ deamon, otherwise on all IPFS daemons.
 				},
 			},
 		},
+		{
+			Name:  "pinset",
+			Usage: "Take snapshots of the pinset, roll it back and check its consistency",
+			Description: `
+Pinset snapshots are named copies of the pinset. Rolling back to a snapshot
+pins the items which were removed since it was taken, or which were pinned
+again with different options, and unpins the items pinned since then.
+Protected pins are not unpinned and expired items are not restored.
+
+Snapshots are stored in the datastore of the peer that receives the requests,
+and are not shared with other peers.
+`,
+			Subcommands: []cli.Command{
+				{
+					Name:      "snapshot",
+					Usage:     "Take a snapshot of the pinset",
+					ArgsUsage: "<name>",
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.PinsetSnapshotCreate(ctx, c.Args().First())
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "snapshots",
+					Usage: "List the pinset snapshots",
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.PinsetSnapshots(ctx)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:      "rm-snapshot",
+					Usage:     "Remove a pinset snapshot",
+					ArgsUsage: "<name>",
+					Action: func(c *cli.Context) error {
+						cerr := globalClient.PinsetSnapshotRm(ctx, c.Args().First())
+						formatResponse(c, nil, cerr)
+						return nil
+					},
+				},
+				{
+					Name:      "rollback",
+					Usage:     "Roll the pinset back to a snapshot",
+					ArgsUsage: "<name>",
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "dry-run",
+							Usage: "show the changes without applying them",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.PinsetRollback(ctx, c.Args().First(), c.Bool("dry-run"))
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "check",
+					Usage: "Cross-check the shared state, the pin trackers and the IPFS pins",
+					Description: `
+This command asks every peer to compare the items allocated to it in the
+shared state with the operations of its pin tracker and with the pins of its
+IPFS daemon, and reports the items where they disagree:
+
+  - not_pinned: allocated to the peer, but not pinned in IPFS and not being
+    pinned
+  - stray_operation: the pin tracker is pinning an item which is not
+    allocated to the peer, or unpinning one which is
+  - leftover_pin: pinned in IPFS, but allocated to other peers
+  - unknown_pin: pinned in IPFS, but not part of the cluster pinset
+
+With --repair, the peers pin or unpin the items to fix the issues found,
+except for unknown pins, which may have been pinned outside the cluster.
+`,
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "repair",
+							Usage: "fix the issues found",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.ConsistencyCheck(ctx, c.Bool("repair"))
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:  "denylist",
+			Usage: "Manage the content that cannot be pinned",
+			Description: `
+The denylist prevents pinning matching content. Rules use the format of the
+Bad Bits denylist:
+
+  /ipfs/<cid>          denies the CID and any path under it
+  /ipfs/<cid>/<path>   denies the path and any path under it
+  //<sha256 hex>       denies the double-hashed CID or path
+  !<rule>              allows the matching content
+
+Rules added with these commands are stored in the datastore of the peer that
+receives the requests, and are not shared with other peers. Large lists are
+better loaded by every peer with the "denylist.files" option of the "cluster"
+section of the configuration.
+`,
+			Subcommands: []cli.Command{
+				{
+					Name:  "ls",
+					Usage: "List the denylist entries",
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.Denylist(ctx)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:      "add",
+					Usage:     "Add rules to the denylist",
+					ArgsUsage: "[<rule>]...",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "file",
+							Usage: "Read the rules from a denylist file",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						rules := []string(c.Args())
+						if path := c.String("file"); path != "" {
+							b, err := os.ReadFile(path)
+							checkErr("reading the denylist file", err)
+							rules = append(rules, strings.Split(string(b), "\n")...)
+						}
+						if len(rules) == 0 {
+							checkErr("", errors.New("at least one rule is required"))
+						}
+						cerr := globalClient.DenylistAdd(ctx, rules)
+						formatResponse(c, nil, cerr)
+						return nil
+					},
+				},
+				{
+					Name:      "rm",
+					Usage:     "Remove a rule from the denylist",
+					ArgsUsage: "<rule>",
+					Action: func(c *cli.Context) error {
+						rule := c.Args().First()
+						if rule == "" {
+							checkErr("", errors.New("a rule is required"))
+						}
+						cerr := globalClient.DenylistRm(ctx, rule)
+						formatResponse(c, nil, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "enforce",
+					Usage: "Unpin the pins matching the denylist",
+					Description: `
+This command unpins all the items in the pinset which match the denylist of
+the peer being contacted, and lists them. This happens automatically when the
+"denylist.unpin_matches" option is enabled.
+`,
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.DenylistEnforce(ctx)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:  "operations",
+			Usage: "List and cancel long-running operations",
+			Description: `
+Long-running actions in a cluster peer, like recovering all items, running
+"ipfs gc" or re-allocating pins after a peer goes down, are tracked as
+operations with an ID. These subcommands allow to list and follow the progress
+of the operations started in the peer being contacted, and to cancel them.
+`,
+			Subcommands: []cli.Command{
+				{
+					Name:  "ls",
+					Usage: "List running and recently finished operations",
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.Operations(ctx)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:      "status",
+					Usage:     "Show the progress of an operation",
+					ArgsUsage: "<operation ID>",
+					Action: func(c *cli.Context) error {
+						id := c.Args().First()
+						if id == "" {
+							checkErr("", errors.New("an operation ID is required"))
+						}
+						resp, cerr := globalClient.Operation(ctx, id)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "cancel",
+					Usage: "Cancel a running operation",
+					Description: `
+This command cancels a running operation. Work done by the operation before
+being canceled (i.e. items already recovered) is not undone.
+`,
+					ArgsUsage: "<operation ID>",
+					Action: func(c *cli.Context) error {
+						id := c.Args().First()
+						if id == "" {
+							checkErr("", errors.New("an operation ID is required"))
+						}
+						resp, cerr := globalClient.CancelOperation(ctx, id)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:        "rpc",
+			Usage:       "Manage the RPC authorization policy",
+			Description: "Manage the RPC authorization policy",
+			Subcommands: []cli.Command{
+				{
+					Name:  "policy",
+					Usage: "show or modify the RPC authorization policy of a peer",
+					Description: `
+This command shows the RPC authorization policy of the peer being contacted:
+which other peers may call each of its RPC methods. Endpoint types are:
+
+  - open: any peer may call the method
+  - trusted: only trusted peers may call the method
+  - closed: only the peer itself may call the method
+
+Arguments in the form "<method>=<type>" (i.e. "Cluster.ID=trusted") modify
+the policy of the given methods. Changes apply immediately but are not saved
+to the configuration. Use the "rpc_policy" option in the "cluster" section of
+the configuration to persist them.
+`,
+					ArgsUsage: "[<method>=<type>]...",
+					Action: func(c *cli.Context) error {
+						if !c.Args().Present() {
+							resp, cerr := globalClient.RPCPolicy(ctx)
+							formatResponse(c, resp, cerr)
+							return nil
+						}
+
+						changes := make(map[string]string)
+						for _, arg := range c.Args() {
+							parts := strings.SplitN(arg, "=", 2)
+							if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+								checkErr("parsing arguments", fmt.Errorf("bad policy change: %s", arg))
+							}
+							changes[parts[0]] = parts[1]
+						}
+						resp, cerr := globalClient.SetRPCPolicy(ctx, changes)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:        "secret",
+			Usage:       "Manage the cluster secret",
+			Description: "Manage the cluster secret",
+			Subcommands: []cli.Command{
+				{
+					Name:  "rotate",
+					Usage: "replace the cluster secret in all peers",
+					Description: `
+This command replaces the cluster secret in all the cluster peers without
+restarting them. A new secret is generated unless one is given with --secret.
+
+All peers first accept the new secret in addition to the current one. If any
+of them fails to do so, the rotation is aborted. Otherwise they all switch to
+the new secret and save it to their configuration. Each peer keeps accepting
+connections protected with the previous secret during the
+"secret_transition_window" set in its configuration.
+
+Peers which are not reachable during the rotation, as well as followers, need
+to have the new secret set in their configuration manually. The CLUSTER_SECRET
+environment variable, when used, must be updated too.
+`,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "secret",
+							Usage: "hex-encoded 32-byte secret to use instead of a random one",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.RotateSecret(ctx, c.String("secret"))
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:        "pintracker",
+			Usage:       "Manage the pin tracker of a peer",
+			Description: "Manage the pin tracker of a peer",
+			Subcommands: []cli.Command{
+				{
+					Name:  "settings",
+					Usage: "show or modify the runtime settings of the pin tracker",
+					Description: `
+This command shows the settings of the pin tracker of the peer being
+contacted which can be modified at runtime. Flags modify them. Changes apply
+immediately but are not saved to the configuration.
+
+Lowering --concurrent-pins lets the ongoing pin operations finish before
+stopping the extra workers.
+`,
+					Flags: []cli.Flag{
+						cli.IntFlag{
+							Name:  "concurrent-pins",
+							Usage: "number of pin operations sent to IPFS in parallel",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						if !c.IsSet("concurrent-pins") {
+							resp, cerr := globalClient.TrackerSettings(ctx)
+							formatResponse(c, resp, cerr)
+							return nil
+						}
+
+						n := c.Int("concurrent-pins")
+						if n <= 0 {
+							checkErr("parsing arguments", errors.New("--concurrent-pins must be positive"))
+						}
+						resp, cerr := globalClient.SetTrackerSettings(ctx, &api.TrackerSettings{ConcurrentPins: n})
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:        "apis",
+			Usage:       "Manage the APIs of a peer",
+			Description: "Manage the APIs of a peer",
+			Subcommands: []cli.Command{
+				{
+					Name:  "listeners",
+					Usage: "show the listeners of the APIs which can be changed at runtime",
+					Description: `
+This command shows the listen addresses, TLS certificate and CORS options of
+the APIs of the peer being contacted which can be changed with "apis rebind".
+`,
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.APIListeners(ctx)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:      "rebind",
+					Usage:     "change the listeners of an API without restarting the peer",
+					ArgsUsage: "<api>",
+					Description: `
+This command replaces the listen addresses, TLS certificate or CORS options
+of one of the APIs of the peer being contacted, named after its section in
+the configuration (i.e. "restapi"). Options which are not given are left as
+they are.
+
+Listeners on new addresses are opened before the ones on removed addresses
+are closed. Listeners whose address and certificate do not change are kept.
+Requests in progress are not interrupted. The new settings are saved to the
+configuration of the peer.
+`,
+					Flags: []cli.Flag{
+						cli.StringSliceFlag{
+							Name:  "listen",
+							Usage: "HTTP listen multiaddress. Can be repeated",
+						},
+						cli.StringFlag{
+							Name:  "ssl-cert-file",
+							Usage: "TLS certificate file",
+						},
+						cli.StringFlag{
+							Name:  "ssl-key-file",
+							Usage: "TLS private key file",
+						},
+						cli.BoolFlag{
+							Name:  "no-tls",
+							Usage: "serve plain HTTP",
+						},
+						cli.StringSliceFlag{
+							Name:  "cors-allowed-origins",
+							Usage: "allowed CORS origin. Can be repeated",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						name := c.Args().First()
+						if name == "" {
+							checkErr("parsing arguments", errors.New("an API name is needed"))
+						}
+						all, err := globalClient.APIListeners(ctx)
+						checkErr("retrieving the API listeners", err)
+
+						var settings *api.APIListeners
+						for _, l := range all {
+							if l.API == name {
+								settings = l
+							}
+						}
+						if settings == nil {
+							checkErr("", fmt.Errorf("%s is not an API whose listeners can be changed", name))
+						}
+
+						if c.IsSet("listen") {
+							settings.HTTPListenMultiaddress = c.StringSlice("listen")
+						}
+						if c.IsSet("ssl-cert-file") {
+							settings.SSLCertFile = c.String("ssl-cert-file")
+						}
+						if c.IsSet("ssl-key-file") {
+							settings.SSLKeyFile = c.String("ssl-key-file")
+						}
+						if c.Bool("no-tls") {
+							settings.SSLCertFile = ""
+							settings.SSLKeyFile = ""
+						}
+						if c.IsSet("cors-allowed-origins") {
+							settings.CORSAllowedOrigins = c.StringSlice("cors-allowed-origins")
+						}
+						resp, cerr := globalClient.RebindAPI(ctx, settings)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:  "bench",
+			Usage: "Measure how fast the cluster pins new items",
+			Description: `
+This command submits --count synthetic items to the cluster, at most --rate
+per second and --concurrency at a time, and measures for each of them the
+"submit" latency (until the API returns) and the "pinned" latency (until all
+their allocations report them as pinned). It then prints the minimum, mean,
+maximum and percentiles of both.
+
+In "pin" mode (default), items are random CIDs using an identity hash which
+embeds --size bytes of data (128 at most). IPFS does not need to fetch
+anything to pin them, so this mode measures the cluster alone. In "add" mode,
+files with --size random bytes are added through the cluster API.
+
+Items are named after the run ID and carry the metadata "bench=<run ID>", so
+they can be removed afterwards with "pin bulk rm --metadata bench=<run ID>",
+or right after the run with --cleanup.
+`,
+			ArgsUsage: " ",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "mode",
+					Value: "pin",
+					Usage: "type of operation: pin or add",
+				},
+				cli.IntFlag{
+					Name:  "count, n",
+					Value: 100,
+					Usage: "number of items to submit",
+				},
+				cli.Float64Flag{
+					Name:  "rate",
+					Value: 10,
+					Usage: "items submitted per second. 0 for no limit",
+				},
+				cli.IntFlag{
+					Name:  "size",
+					Value: 32,
+					Usage: "size in bytes of the content of every item",
+				},
+				cli.IntFlag{
+					Name:  "concurrency",
+					Value: 10,
+					Usage: "maximum number of items being submitted or waited for at the same time",
+				},
+				cli.DurationFlag{
+					Name:  "timeout",
+					Value: time.Minute,
+					Usage: "how long to wait for every item to be pinned",
+				},
+				cli.IntFlag{
+					Name:  "replication, r",
+					Value: 0,
+					Usage: "replication factor of the items. Default is cluster's",
+				},
+				cli.BoolFlag{
+					Name:  "cleanup",
+					Usage: "unpin the items of the run when it finishes",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				runID, err := uuid.NewRandom()
+				checkErr("generating run ID", err)
+				params := &benchParams{
+					ID:          "bench-" + runID.String()[:8],
+					Mode:        c.String("mode"),
+					Count:       c.Int("count"),
+					Rate:        c.Float64("rate"),
+					Size:        c.Int("size"),
+					Concurrency: c.Int("concurrency"),
+					Timeout:     c.Duration("timeout"),
+					Replication: c.Int("replication"),
+				}
+				checkErr("parsing arguments", params.validate())
+
+				report := runBench(ctx, globalClient, params)
+				if c.Bool("cleanup") {
+					_, cerr := globalClient.UnpinMatching(ctx, api.PinSelector{
+						Metadata: map[string]string{benchMetaKey: params.ID},
+					})
+					if cerr != nil {
+						fmt.Fprintf(os.Stderr, "error cleaning up: %s\n", cerr)
+					}
+				}
+				formatResponse(c, report, nil)
+				return nil
+			},
+		},
+		{
+			Name:  "diff",
+			Usage: "Compare the cluster pinset with another cluster or an IPFS daemon",
+			Description: `
+This command compares the pinset of the cluster peer being contacted with the
+pinset of a second cluster (--other-host) or with the pins of an IPFS daemon
+(--ipfs) and reports:
+
+  - missing: items in the cluster pinset which are not in the other pinset
+  - extra: items in the other pinset which are not in the cluster pinset
+  - degraded: items in both pinsets which do not match (pin type, depth,
+    replication factors, or fewer allocations in the other cluster)
+
+When comparing with IPFS, only the items allocated to the contacted cluster
+peer are expected to be pinned in IPFS. Indirect IPFS pins are ignored.
+
+Use "--enc json" for machine-readable output. The --filter flag works as in
+"pin ls".
+
+The command exits with status 3 when differences were found.
+`,
+			ArgsUsage: " ",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "other-host",
+					Usage: "API endpoint multiaddresses (comma-separated) of the cluster to compare with",
+				},
+				cli.StringFlag{
+					Name:  "ipfs",
+					Usage: "IPFS API endpoint (multiaddress or host:port) to compare with",
+				},
+				cli.StringFlag{
+					Name:  "filter",
+					Usage: "Comma separated list of pin types. See \"pin ls\" help.",
+					Value: "all",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				otherHost := c.String("other-host")
+				ipfsAddr := c.String("ipfs")
+				if (otherHost == "") == (ipfsAddr == "") {
+					checkErr("", errors.New("exactly one of --other-host or --ipfs must be provided"))
+				}
+
+				var filter api.PinType
+				for _, f := range strings.Split(c.String("filter"), ",") {
+					filter |= api.PinTypeFromString(f)
+				}
+
+				source := c.GlobalString("host")
+				srcPins, cerr := globalClient.Allocations(ctx, filter)
+				if cerr != nil {
+					formatResponse(c, nil, cerr)
+					return nil
+				}
+
+				var d *pinsetDiff
+				if otherHost != "" {
+					otherClient := newClient(ctx, globalClientConfig, otherHost)
+					dstPins, cerr := otherClient.Allocations(ctx, filter)
+					if cerr != nil {
+						formatResponse(c, nil, cerr)
+						return nil
+					}
+					d = diffClusterPinsets(source, otherHost, srcPins, dstPins)
+				} else {
+					id, cerr := globalClient.ID(ctx)
+					if cerr != nil {
+						formatResponse(c, nil, cerr)
+						return nil
+					}
+					ipfsPins, err := shell.NewShell(ipfsAddr).Pins()
+					checkErr("listing ipfs pins", err)
+					statuses := make(map[string]api.IPFSPinStatus, len(ipfsPins))
+					for k, v := range ipfsPins {
+						statuses[k] = api.IPFSPinStatusFromString(v.Type)
+					}
+					d = diffIPFSPins(source, ipfsAddr, id.ID, srcPins, statuses)
+				}
+
+				formatResponse(c, d, nil)
+				if !d.Empty() {
+					os.Exit(3)
+				}
+				return nil
+			},
+		},
 		{
 			Name:      "commands",
 			Usage:     "List all commands",
@@ -1081,6 +2630,38 @@ deamon, otherwise on all IPFS daemons.
 	app.Run(os.Args)
 }
 
+// newClient creates a load-balancing client for the given comma-separated
+// list of API endpoint multiaddresses, using cfg as template.
+func newClient(ctx context.Context, cfg *client.Config, hosts string) client.Client {
+	var configs []*client.Config
+	for _, addr := range strings.Split(hosts, ",") {
+		multiaddr, err := ma.NewMultiaddr(addr)
+		checkErr("parsing host multiaddress", err)
+
+		if client.IsPeerAddress(multiaddr) && cfg.SSL {
+			logger.Warn("Using libp2p-http for %s. The https flag will be ignored for this connection", addr)
+		}
+
+		var cfgs []*client.Config
+
+		// We can auto round-robin on DNS records when using
+		// libp2p-http or not using SSL. When using SSL we
+		// cannot use the resolve-IPs directly.
+		if client.IsPeerAddress(multiaddr) || !cfg.SSL {
+			cfgs, err = cfg.AsTemplateForResolvedAddress(ctx, multiaddr)
+		} else {
+			cfgs = cfg.AsTemplateFor([]ma.Multiaddr{multiaddr})
+		}
+		checkErr("creating configs", err)
+		configs = append(configs, cfgs...)
+	}
+
+	retries := len(configs)
+	lbClient, err := client.NewLBClient(&client.Failover{}, configs, retries)
+	checkErr("creating API client", err)
+	return lbClient
+}
+
 func localFlag() cli.BoolFlag {
 	return cli.BoolFlag{
 		Name:  "local",
@@ -1126,6 +2707,12 @@ func formatResponse(c *cli.Context, resp interface{}, err error) {
 		}
 	}
 
+	if output := c.String("output"); output != "" {
+		err := writeTabular(os.Stdout, output, resp, c.String("columns"), !c.Bool("no-header"))
+		checkErr("writing tabular output", err)
+		return
+	}
+
 	switch enc {
 	case "text":
 		textFormatObject(resp)
@@ -1208,6 +2795,40 @@ func waitFor(
 	return client.WaitFor(ctx, globalClient, fp)
 }
 
+var metadataSelectorFlag = cli.StringSliceFlag{
+	Name:  "metadata",
+	Usage: "Selects pins by metadata: key=value. Can be added multiple times",
+}
+
+func parsePinSelector(c *cli.Context) api.PinSelector {
+	return api.PinSelector{Metadata: parseMetadata(c.StringSlice("metadata"))}
+}
+
+// parsePeers parses a comma-separated list of peer IDs given with the
+// named flag.
+func parsePeers(ctx context.Context, flag, list string) []peer.ID {
+	if list == "" {
+		return nil
+	}
+	strs := strings.Split(list, ",")
+	peers := make([]peer.ID, 0, len(strs))
+	for _, s := range strs {
+		peers = append(peers, resolvePeer(ctx, strings.TrimSpace(s)))
+	}
+	return peers
+}
+
+// resolvePeer returns the peer ID given or the ID of the peer with the given
+// peername, which is looked up in the cluster.
+func resolvePeer(ctx context.Context, name string) peer.ID {
+	if pid, err := peer.Decode(name); err == nil {
+		return pid
+	}
+	id, err := globalClient.Peer(ctx, name)
+	checkErr("resolving peer "+name, err)
+	return id.ID
+}
+
 func parseMetadata(metadata []string) map[string]string {
 	metadataMap := make(map[string]string)
 	for _, str := range metadata {
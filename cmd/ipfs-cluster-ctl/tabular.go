@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	cli "github.com/urfave/cli"
+)
+
+/*
+   These functions write status and allocation listings as rows of selected
+   columns, either as CSV or as an aligned table, so that they can be piped
+   into spreadsheets and standard unix tools. Each command supporting
+   tabular output defines the available columns and how to obtain their
+   values. Status listings produce one row per peer and CID.
+*/
+
+// statusRow is a single row of status output: a CID as seen by a peer.
+type statusRow struct {
+	gpi  *api.GlobalPinInfo
+	peer string
+	info *api.PinInfoShort
+}
+
+type column struct {
+	name  string
+	value func(interface{}) string
+}
+
+var statusColumns = []column{
+	{"cid", func(r interface{}) string { return r.(statusRow).gpi.Cid.String() }},
+	{"name", func(r interface{}) string { return r.(statusRow).gpi.Name }},
+	{"peer", func(r interface{}) string { return r.(statusRow).peer }},
+	{"peername", func(r interface{}) string { return r.(statusRow).info.PeerName }},
+	{"status", func(r interface{}) string { return r.(statusRow).info.Status.String() }},
+	{"timestamp", func(r interface{}) string { return formatTabularTime(r.(statusRow).info.TS) }},
+	{"attempts", func(r interface{}) string { return strconv.Itoa(r.(statusRow).info.AttemptCount) }},
+	{"error", func(r interface{}) string { return r.(statusRow).info.Error }},
+}
+
+var pinColumns = []column{
+	{"cid", func(r interface{}) string { return r.(*api.Pin).Cid.String() }},
+	{"name", func(r interface{}) string { return r.(*api.Pin).Name }},
+	{"type", func(r interface{}) string { return r.(*api.Pin).Type.String() }},
+	{"mode", func(r interface{}) string { return r.(*api.Pin).Mode.String() }},
+	{"replication_min", func(r interface{}) string { return strconv.Itoa(r.(*api.Pin).ReplicationFactorMin) }},
+	{"replication_max", func(r interface{}) string { return strconv.Itoa(r.(*api.Pin).ReplicationFactorMax) }},
+	{"allocations", func(r interface{}) string {
+		pin := r.(*api.Pin)
+		if pin.IsPinEverywhere() {
+			return "everywhere"
+		}
+		allocs := api.PeersToStrings(pin.Allocations)
+		sort.Strings(allocs)
+		return strings.Join(allocs, " ")
+	}},
+	{"expire_at", func(r interface{}) string { return formatTabularTime(r.(*api.Pin).ExpireAt) }},
+	{"timestamp", func(r interface{}) string { return formatTabularTime(r.(*api.Pin).Timestamp) }},
+}
+
+// tabularFlags returns the flags for commands supporting tabular output.
+func tabularFlags(cols []column) []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "output, o",
+			Usage: "write tabular output instead of using --encoding [csv, table]",
+		},
+		cli.StringFlag{
+			Name:  "columns",
+			Usage: "comma-separated list of columns for tabular output: " + columnNames(cols),
+		},
+		cli.BoolFlag{
+			Name:  "no-header",
+			Usage: "do not write the column names in tabular output",
+		},
+	}
+}
+
+func columnNames(cols []column) string {
+	names := make([]string, 0, len(cols))
+	for _, col := range cols {
+		names = append(names, col.name)
+	}
+	return strings.Join(names, ",")
+}
+
+// selectColumns returns the columns matching the given comma-separated
+// names, in that order. All columns are returned when no names are given.
+func selectColumns(cols []column, names string) ([]column, error) {
+	if names == "" {
+		return cols, nil
+	}
+
+	var selected []column
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, col := range cols {
+			if col.name == name {
+				selected = append(selected, col)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown column %q. Available: %s", name, columnNames(cols))
+		}
+	}
+	return selected, nil
+}
+
+// tabularRows converts a response into rows and returns the columns that
+// apply to them.
+func tabularRows(resp interface{}) ([]interface{}, []column, error) {
+	var rows []interface{}
+	switch r := resp.(type) {
+	case *api.GlobalPinInfo:
+		return tabularRows([]*api.GlobalPinInfo{r})
+	case []*api.GlobalPinInfo:
+		for _, gpi := range r {
+			peers := make([]string, 0, len(gpi.PeerMap))
+			for p := range gpi.PeerMap {
+				peers = append(peers, p)
+			}
+			sort.Strings(peers)
+			for _, p := range peers {
+				rows = append(rows, statusRow{
+					gpi:  gpi,
+					peer: p,
+					info: gpi.PeerMap[p],
+				})
+			}
+		}
+		return rows, statusColumns, nil
+	case *api.Pin:
+		return tabularRows([]*api.Pin{r})
+	case []*api.Pin:
+		for _, pin := range r {
+			rows = append(rows, pin)
+		}
+		return rows, pinColumns, nil
+	default:
+		return nil, nil, fmt.Errorf("tabular output not supported for %T", resp)
+	}
+}
+
+// writeTabular writes the response to w in the given format ("csv" or
+// "table") with the selected columns.
+func writeTabular(w io.Writer, format string, resp interface{}, colNames string, header bool) error {
+	rows, cols, err := tabularRows(resp)
+	if err != nil {
+		return err
+	}
+
+	cols, err = selectColumns(cols, colNames)
+	if err != nil {
+		return err
+	}
+
+	var records [][]string
+	if header {
+		var names []string
+		for _, col := range cols {
+			names = append(names, col.name)
+		}
+		records = append(records, names)
+	}
+	for _, row := range rows {
+		record := make([]string, 0, len(cols))
+		for _, col := range cols {
+			record = append(record, col.value(row))
+		}
+		records = append(records, record)
+	}
+
+	switch format {
+	case "csv":
+		csvw := csv.NewWriter(w)
+		return csvw.WriteAll(records)
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, record := range records {
+			fmt.Fprintln(tw, strings.Join(record, "\t"))
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+func formatTabularTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
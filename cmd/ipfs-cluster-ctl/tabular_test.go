@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+func testGlobalPinInfo() *api.GlobalPinInfo {
+	return &api.GlobalPinInfo{
+		Cid:  test.Cid1,
+		Name: "a,b",
+		PeerMap: map[string]*api.PinInfoShort{
+			peer.Encode(test.PeerID2): {
+				PeerName: "peer2",
+				Status:   api.TrackerStatusPinError,
+				Error:    "boom",
+			},
+			peer.Encode(test.PeerID1): {
+				PeerName: "peer1",
+				Status:   api.TrackerStatusPinned,
+			},
+		},
+	}
+}
+
+func TestWriteTabularCSV(t *testing.T) {
+	var buf bytes.Buffer
+	err := writeTabular(&buf, "csv", []*api.GlobalPinInfo{testGlobalPinInfo()}, "cid,status,peer,name", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rows are sorted by peer ID
+	expected := "cid,status,peer,name\n" +
+		test.Cid1.String() + ",pin_error," + peer.Encode(test.PeerID2) + ",\"a,b\"\n" +
+		test.Cid1.String() + ",pinned," + peer.Encode(test.PeerID1) + ",\"a,b\"\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+
+	buf.Reset()
+	err = writeTabular(&buf, "csv", testGlobalPinInfo(), "peername", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "peer2\npeer1\n" {
+		t.Errorf("unexpected output without header: %s", buf.String())
+	}
+}
+
+func TestWriteTabularTable(t *testing.T) {
+	pin := api.PinCid(test.Cid1)
+	pin.ReplicationFactorMin = -1
+	pin.ReplicationFactorMax = -1
+
+	var buf bytes.Buffer
+	err := writeTabular(&buf, "table", []*api.Pin{pin}, "cid,allocations", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "cid                                             allocations\n" +
+		test.Cid1.String() + "  everywhere\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+}
+
+func TestWriteTabularErrors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeTabular(&buf, "csv", testGlobalPinInfo(), "cid,wrong", true); err == nil {
+		t.Error("expected an error for an unknown column")
+	}
+	if err := writeTabular(&buf, "xml", testGlobalPinInfo(), "", true); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+	if err := writeTabular(&buf, "csv", &api.ID{}, "", true); err == nil {
+		t.Error("expected an error for an unsupported type")
+	}
+}
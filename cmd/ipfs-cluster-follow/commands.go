@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"strings"
 	"time"
 
@@ -24,6 +23,7 @@ import (
 	"github.com/ipfs/ipfs-cluster/monitor/pubsubmon"
 	"github.com/ipfs/ipfs-cluster/observations"
 	"github.com/ipfs/ipfs-cluster/pintracker/stateless"
+	peer "github.com/libp2p/go-libp2p-core/peer"
 	"github.com/multiformats/go-multiaddr"
 	"github.com/pkg/errors"
 	cli "github.com/urfave/cli/v2"
@@ -69,7 +69,7 @@ func isInitialized(absPath string) bool {
 
 func listClustersCmd(c *cli.Context) error {
 	absPath, _, _ := buildPaths(c, "")
-	f, err := os.Open(absPath)
+	filteredDirs, err := listClusterNames(absPath)
 	if os.IsNotExist(err) {
 		printFirstStart()
 		return nil
@@ -78,21 +78,6 @@ func listClustersCmd(c *cli.Context) error {
 		return cli.Exit(err, 1)
 	}
 
-	dirs, err := f.Readdir(-1)
-	if err != nil {
-		return cli.Exit(errors.Wrapf(err, "reading %s", absPath), 1)
-	}
-
-	var filteredDirs []string
-	for _, d := range dirs {
-		if d.IsDir() {
-			configPath := filepath.Join(absPath, d.Name(), DefaultConfigFile)
-			if _, err := os.Stat(configPath); err == nil {
-				filteredDirs = append(filteredDirs, d.Name())
-			}
-		}
-	}
-
 	if len(filteredDirs) == 0 {
 		printFirstStart()
 		return nil
@@ -214,6 +199,14 @@ func initCluster(c *cli.Context, ignoreReinit bool, cfgURL string) error {
 	cfgHelper := cmdutils.NewConfigHelper(configPath, identityPath, "crdt", "")
 	cfgHelper.Manager().Shutdown()
 	cfgHelper.Manager().Source = cfgURL
+	if signer := c.String("source-signer"); signer != "" {
+		pid, err := peer.Decode(signer)
+		if err != nil {
+			return cli.Exit(errors.Wrap(err, "parsing --source-signer"), 1)
+		}
+		cfgHelper.Manager().SourceSigner = pid
+	}
+	cfgHelper.Manager().SourceRefreshInterval = c.Duration("source-refresh")
 	err := cfgHelper.Manager().Default()
 	if err != nil {
 		return cli.Exit(errors.Wrap(err, "error generating default config"), 1)
@@ -283,30 +276,74 @@ func runCmd(c *cli.Context) error {
 	// run some "list" command.
 	ipfscluster.SetFacilityLogLevel("restapilog", "error")
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// We are going to run a cluster peer and should do an
+	// oderly shutdown if we are interrupted: cancel default
+	// signal handling and leave things to HandleSignals.
+	signal.Stop(signalChan)
+	close(signalChan)
+
+	p, err := newFollower(ctx, clusterName, absPath, configPath, identityPath, nil)
+	if err != nil {
+		return err
+	}
+
+	return cmdutils.HandleSignals(ctx, cancel, p.Cluster, p.Host, p.DHT, p.Store)
+}
+
+// newFollower creates and starts a follower peer for the given cluster.  When
+// usedAddrs is not nil, listen addresses found in it are replaced by ones
+// using random ports so that several followers can run in the same process.
+// The addresses finally used are added to it.
+func newFollower(ctx context.Context, clusterName, absPath, configPath, identityPath string, usedAddrs map[string]struct{}) (_ *cmdutils.Peer, retErr error) {
 	cfgHelper, err := cmdutils.NewLoadedConfigHelper(configPath, identityPath)
 	if err != nil {
-		return cli.Exit(errors.Wrapf(err, "reading the configurations in %s", absPath), 1)
+		return nil, cli.Exit(errors.Wrapf(err, "reading the configurations in %s", absPath), 1)
 	}
 	cfgHelper.Manager().Shutdown()
 	cfgs := cfgHelper.Configs()
 
+	if usedAddrs != nil {
+		cfgs.Cluster.ListenAddr = uniqueListenAddrs(usedAddrs, cfgs.Cluster.ListenAddr)
+	}
+
 	stmgr, err := cmdutils.NewStateManager(cfgHelper.GetConsensus(), cfgHelper.GetDatastore(), cfgHelper.Identity(), cfgs)
 	if err != nil {
-		return cli.Exit(errors.Wrap(err, "creating state manager"), 1)
+		return nil, cli.Exit(errors.Wrap(err, "creating state manager"), 1)
 	}
 
 	store, err := stmgr.GetStore()
 	if err != nil {
-		return cli.Exit(errors.Wrap(err, "creating datastore"), 1)
+		return nil, cli.Exit(errors.Wrap(err, "creating datastore"), 1)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
 	host, pubsub, dht, err := ipfscluster.NewClusterHost(ctx, cfgHelper.Identity(), cfgs.Cluster, store)
 	if err != nil {
-		return cli.Exit(errors.Wrap(err, "error creating libp2p components"), 1)
+		store.Close()
+		return nil, cli.Exit(errors.Wrap(err, "error creating libp2p components"), 1)
 	}
 
+	// Release everything started so far when the peer cannot be
+	// created, as other followers may keep running in this process.
+	var restAPI *rest.API
+	var connector *ipfshttp.Connector
+	defer func() {
+		if retErr == nil {
+			return
+		}
+		if connector != nil {
+			connector.Shutdown(ctx)
+		}
+		if restAPI != nil {
+			restAPI.Shutdown(ctx)
+		}
+		dht.Close()
+		host.Close()
+		store.Close()
+	}()
+
 	// Always run followers in follower mode.
 	cfgs.Cluster.FollowerMode = true
 	// Do not let trusted peers GC this peer
@@ -319,32 +356,32 @@ func runCmd(c *cli.Context) error {
 	_ = apiCfg.Default()
 	listenSocket, err := socketAddress(absPath, clusterName)
 	if err != nil {
-		return cli.Exit(err, 1)
+		return nil, cli.Exit(err, 1)
 	}
 	apiCfg.HTTPListenAddr = []multiaddr.Multiaddr{listenSocket}
 	// Allow customization via env vars
 	err = apiCfg.ApplyEnvVars()
 	if err != nil {
-		return cli.Exit(errors.Wrap(err, "error applying environmental variables to restapi configuration"), 1)
+		return nil, cli.Exit(errors.Wrap(err, "error applying environmental variables to restapi configuration"), 1)
 	}
 
-	rest, err := rest.NewAPI(ctx, apiCfg)
+	restAPI, err = rest.NewAPI(ctx, apiCfg)
 	if err != nil {
-		return cli.Exit(errors.Wrap(err, "creating REST API component"), 1)
+		return nil, cli.Exit(errors.Wrap(err, "creating REST API component"), 1)
 	}
 
-	connector, err := ipfshttp.NewConnector(cfgs.Ipfshttp)
+	connector, err = ipfshttp.NewConnector(cfgs.Ipfshttp)
 	if err != nil {
-		return cli.Exit(errors.Wrap(err, "creating IPFS Connector component"), 1)
+		return nil, cli.Exit(errors.Wrap(err, "creating IPFS Connector component"), 1)
 	}
 
 	informer, err := disk.NewInformer(cfgs.Diskinf)
 	if err != nil {
-		return cli.Exit(errors.Wrap(err, "creating disk informer"), 1)
+		return nil, cli.Exit(errors.Wrap(err, "creating disk informer"), 1)
 	}
 	alloc, err := balanced.New(cfgs.BalancedAlloc)
 	if err != nil {
-		return cli.Exit(errors.Wrap(err, "creating metrics allocator"), 1)
+		return nil, cli.Exit(errors.Wrap(err, "creating metrics allocator"), 1)
 	}
 
 	crdtcons, err := crdt.New(
@@ -355,16 +392,14 @@ func runCmd(c *cli.Context) error {
 		store,
 	)
 	if err != nil {
-		store.Close()
-		return cli.Exit(errors.Wrap(err, "creating CRDT component"), 1)
+		return nil, cli.Exit(errors.Wrap(err, "creating CRDT component"), 1)
 	}
 
-	tracker := stateless.New(cfgs.Statelesstracker, host.ID(), cfgs.Cluster.Peername, crdtcons.State)
+	tracker := stateless.New(cfgs.Statelesstracker, host.ID(), cfgs.Cluster.Peername, crdtcons.State, store)
 
 	mon, err := pubsubmon.New(ctx, cfgs.Pubsubmon, pubsub, nil)
 	if err != nil {
-		store.Close()
-		return cli.Exit(errors.Wrap(err, "setting up PeerMonitor"), 1)
+		return nil, cli.Exit(errors.Wrap(err, "setting up PeerMonitor"), 1)
 	}
 
 	// Hardcode disabled tracing and metrics to avoid mistakenly
@@ -375,7 +410,7 @@ func runCmd(c *cli.Context) error {
 	cfgs.Tracing = &tracerCfg
 	tracer, err := observations.SetupTracing(&tracerCfg)
 	if err != nil {
-		return cli.Exit(errors.Wrap(err, "error setting up tracer"), 1)
+		return nil, cli.Exit(errors.Wrap(err, "error setting up tracer"), 1)
 	}
 
 	// This does nothing since we are not calling SetupMetrics anyways
@@ -385,12 +420,6 @@ func runCmd(c *cli.Context) error {
 	metricsCfg.EnableStats = false
 	cfgs.Metrics = &metricsCfg
 
-	// We are going to run a cluster peer and should do an
-	// oderly shutdown if we are interrupted: cancel default
-	// signal handling and leave things to HandleSignals.
-	signal.Stop(signalChan)
-	close(signalChan)
-
 	cluster, err := ipfscluster.NewCluster(
 		ctx,
 		host,
@@ -398,20 +427,26 @@ func runCmd(c *cli.Context) error {
 		cfgs.Cluster,
 		store,
 		crdtcons,
-		[]ipfscluster.API{rest},
+		[]ipfscluster.API{restAPI},
 		connector,
 		tracker,
 		mon,
 		alloc,
 		[]ipfscluster.Informer{informer},
+		nil,
 		tracer,
 	)
 	if err != nil {
-		store.Close()
-		return cli.Exit(errors.Wrap(err, "error creating cluster peer"), 1)
+		return nil, cli.Exit(errors.Wrap(err, "error creating cluster peer"), 1)
 	}
+	go cmdutils.WatchConfigSource(ctx, cfgHelper, crdtcons)
 
-	return cmdutils.HandleSignals(ctx, cancel, cluster, host, dht, store)
+	return &cmdutils.Peer{
+		Cluster: cluster,
+		Host:    host,
+		DHT:     dht,
+		Store:   store,
+	}, nil
 }
 
 // List
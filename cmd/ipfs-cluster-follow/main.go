@@ -8,6 +8,7 @@ import (
 	"os/user"
 	"path/filepath"
 	"syscall"
+	"time"
 
 	"github.com/ipfs/ipfs-cluster/api/rest/client"
 	"github.com/ipfs/ipfs-cluster/cmdutils"
@@ -38,6 +39,8 @@ var (
 	// The name of the identity file inside DefaultPath
 	DefaultIdentityFile = "identity.json"
 	DefaultGateway      = "127.0.0.1:8080"
+	// How often the configuration template is fetched while running
+	DefaultSourceRefresh = time.Hour
 )
 
 var (
@@ -88,6 +91,19 @@ List items in the pinset for a given cluster:
 
 $ %s <clusterName> list
 
+Display health information for all follower peers:
+
+$ %s all info
+
+Launch all follower peers in a single process (will stay running):
+
+$ %s all run
+
+Print a systemd unit file for a follower peer (or for all of them):
+
+$ %s <clusterName> systemd
+$ %s all systemd
+
 Getting help and usage info:
 
 $ %s --help
@@ -96,6 +112,7 @@ $ %s <clusterName> info --help
 $ %s <clusterName> init --help
 $ %s <clusterName> run --help
 $ %s <clusterName> list --help
+$ %s all --help
 
 `,
 	programName,
@@ -112,6 +129,11 @@ $ %s <clusterName> list --help
 	programName,
 	programName,
 	programName,
+	programName,
+	programName,
+	programName,
+	programName,
+	programName,
 )
 
 func init() {
@@ -170,6 +192,10 @@ func main() {
 		},
 	}
 
+	app.Commands = []*cli.Command{
+		allCommand(),
+	}
+
 	app.Action = func(c *cli.Context) error {
 		if !c.Args().Present() {
 			return listClustersCmd(c)
@@ -212,6 +238,11 @@ peer identity and leave things ready to run "%s %s run".
 An error will be returned if a configuration folder for a cluster peer with
 this name already exists. If you wish to re-initialize from scratch, delete
 this folder first.
+
+When --source-signer is given, the configuration is only accepted when the
+template URL plus ".sig" provides a valid signature of it by that peer. The
+template is fetched again every --source-refresh interval while running:
+changes to the trusted peers are applied immediately and the rest on restart.
 `, clusterName, programName, clusterName),
 				Action: initCmd,
 				Flags: []cli.Flag{
@@ -222,6 +253,15 @@ this folder first.
 						EnvVars: []string{"IPFS_GATEWAY"},
 						Hidden:  true,
 					},
+					&cli.StringFlag{
+						Name:  "source-signer",
+						Usage: "peer ID which must sign the configuration template",
+					},
+					&cli.DurationFlag{
+						Name:  "source-refresh",
+						Usage: "interval to fetch the configuration template while running",
+						Value: DefaultSourceRefresh,
+					},
 				},
 			},
 			{
@@ -255,6 +295,15 @@ The peer will stay running in the foreground until manually stopped.
 						EnvVars: []string{"IPFS_GATEWAY"},
 						Hidden:  true,
 					},
+					&cli.StringFlag{
+						Name:  "source-signer",
+						Usage: "peer ID which must sign the configuration template",
+					},
+					&cli.DurationFlag{
+						Name:  "source-refresh",
+						Usage: "interval to fetch the configuration template while running",
+						Value: DefaultSourceRefresh,
+					},
 				},
 			},
 			{
@@ -271,6 +320,19 @@ as obtained from the internal state on disk.
 `,
 				Action: listCmd,
 			},
+			{
+				Name:      "systemd",
+				Usage:     "prints a systemd unit file for this peer",
+				ArgsUsage: "",
+				Description: fmt.Sprintf(`
+This command prints a systemd service unit which runs "%s %s run".
+Save it to /etc/systemd/system/%s-%s.service (or to
+~/.config/systemd/user/ when using --user) and enable it with
+"systemctl enable --now %s-%s".
+`, programName, clusterName, programName, clusterName, programName, clusterName),
+				Action: systemdCmd,
+				Flags:  systemdFlags(),
+			},
 		}
 		return clusterApp.RunAsSubcommand(c)
 	}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	ipfscluster "github.com/ipfs/ipfs-cluster"
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/cmdutils"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/pkg/errors"
+	cli "github.com/urfave/cli/v2"
+)
+
+// allClustersCommand is used to work with all the configured followers at
+// once.
+const allClustersCommand = "all"
+
+const healthTimeout = 10 * time.Second
+
+const systemdUnitTemplate = `[Unit]
+Description=%s
+Wants=network-online.target
+After=network-online.target ipfs.service
+
+[Service]
+Type=simple
+Environment="IPFS_CLUSTER_PATH=%s"
+ExecStart=%s
+Restart=on-failure
+RestartSec=10s
+KillSignal=SIGINT
+TimeoutStopSec=5min
+%s
+[Install]
+WantedBy=%s
+`
+
+func systemdFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "user",
+			Usage: "generate a unit for the systemd user instance (systemctl --user)",
+		},
+	}
+}
+
+func allCommand() *cli.Command {
+	return &cli.Command{
+		Name:  allClustersCommand,
+		Usage: "manage all the configured follower peers at once",
+		Description: fmt.Sprintf(`
+These commands work with all the follower peers configured in the
+configuration folder. Note that "%s" cannot be used as cluster name.
+`, allClustersCommand),
+		Subcommands: []*cli.Command{
+			{
+				Name:   "info",
+				Usage:  "displays health information for all follower peers",
+				Action: infoAllCmd,
+				Description: `
+This command displays, for every configured follower peer, whether it is
+running, whether its IPFS daemon is online, how many items it tracks and how
+many of them are in error state.
+`,
+			},
+			{
+				Name:   "run",
+				Usage:  "runs all follower peers in a single process",
+				Action: runAllCmd,
+				Description: fmt.Sprintf(`
+This command runs all the configured follower peers in a single process,
+rather than having to launch "%s <clusterName> run" for each of them.
+
+Note that the followers do NOT share a libp2p host: each one keeps its own
+identity, configuration and host. A host can only join the private network
+of a single cluster secret, and the cluster RPC protocol can only be served
+once per host, so a process with N followers still opens N hosts with their
+own connections. What is saved is the process, the IPFS checks and the
+service management. When several followers are configured to listen on the
+same addresses, random ports are used for all but the first.
+
+When a follower fails to start, those already started are shut down and the
+command fails. Otherwise, the process stays running in the foreground until
+manually stopped, or until all the followers have shut down.
+`, programName),
+			},
+			{
+				Name:   "systemd",
+				Usage:  "prints a systemd unit file to run all follower peers",
+				Action: systemdAllCmd,
+				Flags:  systemdFlags(),
+				Description: fmt.Sprintf(`
+This command prints a systemd service unit which runs "%s %s run".
+Save it to /etc/systemd/system/%s.service (or to
+~/.config/systemd/user/ when using --user) and enable it with
+"systemctl enable --now %s".
+`, programName, allClustersCommand, programName, programName),
+			},
+		},
+	}
+}
+
+// listClusterNames returns the names of the clusters with a configuration
+// in the given folder.
+func listClusterNames(absPath string) ([]string, error) {
+	f, err := os.Open(absPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dirs, err := f.Readdir(-1)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %s", absPath)
+	}
+
+	var names []string
+	for _, d := range dirs {
+		if d.IsDir() {
+			configPath := filepath.Join(absPath, d.Name(), DefaultConfigFile)
+			if _, err := os.Stat(configPath); err == nil {
+				names = append(names, d.Name())
+			}
+		}
+	}
+	return names, nil
+}
+
+func infoAllCmd(c *cli.Context) error {
+	absPath, _, _ := buildPaths(c, "")
+	names, err := listClusterNames(absPath)
+	if os.IsNotExist(err) || (err == nil && len(names) == 0) {
+		printFirstStart()
+		return nil
+	}
+	if err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLUSTER\tPEER\tIPFS\tPINS\tERRORS")
+	for _, name := range names {
+		peerSt, ipfsSt, pins, pinErrors := followerHealth(c, name)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", name, peerSt, ipfsSt, pins, pinErrors)
+	}
+	return w.Flush()
+}
+
+// followerHealth returns printable values for the health of a follower
+// peer. IPFS and pin information is only available when the peer is running.
+func followerHealth(c *cli.Context, clusterName string) (peerSt, ipfsSt, pins, pinErrors string) {
+	peerSt, ipfsSt, pins, pinErrors = "offline", "unknown", "-", "-"
+
+	absPath, _, _ := buildPaths(c, clusterName)
+	client, err := getClient(absPath, clusterName)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), healthTimeout)
+	defer cancel()
+
+	id, err := client.ID(ctx)
+	if err != nil {
+		return
+	}
+	peerSt = "online"
+	if id.IPFS != nil && id.IPFS.Error == "" {
+		ipfsSt = "online"
+	} else {
+		ipfsSt = "offline"
+	}
+
+	gpis, err := client.StatusAll(ctx, 0, true)
+	if err != nil {
+		return
+	}
+	var errCount int
+	for _, gpi := range gpis {
+		for _, pinInfo := range gpi.PeerMap {
+			if pinInfo.Status.Match(api.TrackerStatusError) {
+				errCount++
+			}
+		}
+	}
+	return peerSt, ipfsSt, fmt.Sprint(len(gpis)), fmt.Sprint(errCount)
+}
+
+func runAllCmd(c *cli.Context) error {
+	absPath, _, _ := buildPaths(c, "")
+	names, err := listClusterNames(absPath)
+	if os.IsNotExist(err) || (err == nil && len(names) == 0) {
+		printFirstStart()
+		return cli.Exit("", 1)
+	}
+	if err != nil {
+		return cli.Exit(err, 1)
+	}
+
+	fmt.Printf("Starting IPFS Cluster follower peers for: %s.\nCTRL-C to stop them.\n", strings.Join(names, ", "))
+	if len(names) > 1 {
+		fmt.Printf("Note: each follower peer runs its own libp2p host (%d in total). Hosts cannot be shared between clusters.\n", len(names))
+	}
+	fmt.Println("Checking if IPFS is online (will wait for 2 minutes)...")
+	ctxIpfs, cancelIpfs := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancelIpfs()
+	err = cmdutils.WaitForIPFS(ctxIpfs)
+	if err != nil {
+		return cli.Exit("timed out waiting for IPFS to be available", 1)
+	}
+
+	setLogLevels(logLevel) // set to "info" by default.
+	ipfscluster.SetFacilityLogLevel("restapilog", "error")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signal.Stop(signalChan)
+	close(signalChan)
+
+	usedAddrs := make(map[string]struct{})
+	var peers []*cmdutils.Peer
+	for _, name := range names {
+		absPath, configPath, identityPath := buildPaths(c, name)
+		p, err := newFollower(ctx, name, absPath, configPath, identityPath, usedAddrs)
+		if err != nil {
+			cmdutils.ErrorOut("error starting follower peer for \"%s\": %s\n", name, err)
+			shutdownFollowers(ctx, peers)
+			return cli.Exit("not all follower peers could be started", 1)
+		}
+		peers = append(peers, p)
+	}
+
+	return cmdutils.HandleSignalsAll(ctx, cancel, peers)
+}
+
+// shutdownFollowers stops the given follower peers and releases their
+// components.
+func shutdownFollowers(ctx context.Context, peers []*cmdutils.Peer) {
+	for _, p := range peers {
+		if err := p.Cluster.Shutdown(ctx); err != nil {
+			cmdutils.ErrorOut("error shutting down cluster: %s\n", err)
+		}
+		p.DHT.Close()
+		p.Host.Close()
+		p.Store.Close()
+	}
+}
+
+// uniqueListenAddrs returns addrs if none of them are in use. Otherwise it
+// returns them with random ports. The resulting addresses are marked as used.
+func uniqueListenAddrs(used map[string]struct{}, addrs []multiaddr.Multiaddr) []multiaddr.Multiaddr {
+	for _, a := range addrs {
+		if _, ok := used[a.String()]; ok {
+			randAddrs, err := cmdutils.RandomizePorts(addrs)
+			if err != nil {
+				cmdutils.ErrorOut("error selecting random ports for %s: %s\n", addrs, err)
+				break
+			}
+			addrs = randAddrs
+			break
+		}
+	}
+
+	for _, a := range addrs {
+		used[a.String()] = struct{}{}
+	}
+	return addrs
+}
+
+func systemdCmd(c *cli.Context) error {
+	clusterName := c.String(clusterNameFlag)
+	absPath, _, _ := buildPaths(c, clusterName)
+	if !isInitialized(absPath) {
+		printNotInitialized(clusterName)
+		return cli.Exit("", 1)
+	}
+
+	return printSystemdUnit(
+		c,
+		fmt.Sprintf("IPFS Cluster follower peer for \"%s\"", clusterName),
+		clusterName+" run",
+	)
+}
+
+func systemdAllCmd(c *cli.Context) error {
+	return printSystemdUnit(
+		c,
+		"IPFS Cluster follower peers",
+		allClustersCommand+" run",
+	)
+}
+
+func printSystemdUnit(c *cli.Context, description, args string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return cli.Exit(errors.Wrap(err, "error finding the executable path"), 1)
+	}
+
+	configFolder, err := filepath.Abs(c.String("config"))
+	if err != nil {
+		return cli.Exit(errors.Wrap(err, "error getting absolute path for the configuration folder"), 1)
+	}
+
+	// System units need to run as the user owning the configuration
+	// folder. User units always do.
+	userLine := ""
+	wantedBy := "default.target"
+	if !c.Bool("user") {
+		wantedBy = "multi-user.target"
+		usr, err := user.Current()
+		if err != nil {
+			return cli.Exit(errors.Wrap(err, "error getting current user"), 1)
+		}
+		userLine = fmt.Sprintf("User=%s\n", usr.Username)
+	}
+
+	fmt.Printf(
+		systemdUnitTemplate,
+		description,
+		configFolder,
+		exe+" "+args,
+		userLine,
+		wantedBy,
+	)
+	return nil
+}
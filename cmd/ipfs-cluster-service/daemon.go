@@ -13,12 +13,16 @@ import (
 	"github.com/ipfs/ipfs-cluster/config"
 	"github.com/ipfs/ipfs-cluster/consensus/crdt"
 	"github.com/ipfs/ipfs-cluster/consensus/raft"
+	"github.com/ipfs/ipfs-cluster/eventbus"
 	"github.com/ipfs/ipfs-cluster/informer/disk"
 	"github.com/ipfs/ipfs-cluster/informer/tags"
+	"github.com/ipfs/ipfs-cluster/informer/weight"
 	"github.com/ipfs/ipfs-cluster/ipfsconn/ipfshttp"
 	"github.com/ipfs/ipfs-cluster/monitor/pubsubmon"
 	"github.com/ipfs/ipfs-cluster/observations"
+	"github.com/ipfs/ipfs-cluster/pintracker/remote"
 	"github.com/ipfs/ipfs-cluster/pintracker/stateless"
+	"github.com/ipfs/ipfs-cluster/plugins"
 	"go.opencensus.io/tag"
 
 	ds "github.com/ipfs/go-datastore"
@@ -167,6 +171,16 @@ func createCluster(
 		checkErr("creating numpin informer", err)
 		informers = append(informers, tagsinf)
 	}
+	if cfgMgr.IsLoadedFromJSON(config.Informer, cfgs.Weightinf.ConfigKey()) {
+		weightinf, err := weight.New(cfgs.Weightinf)
+		checkErr("creating weight informer", err)
+		informers = append(informers, weightinf)
+	}
+	if cfgMgr.IsLoadedFromJSON(config.Informer, cfgs.PluginInformers.ConfigKey()) {
+		pluginInfs, err := plugins.NewInformers(cfgs.PluginInformers)
+		checkErr("creating plugin informers", err)
+		informers = append(informers, pluginInfs...)
+	}
 
 	// For legacy compatibility we need to make the allocator
 	// automatically compatible with informers that have been loaded. For
@@ -175,11 +189,26 @@ func createCluster(
 	if !cfgMgr.IsLoadedFromJSON(config.Allocator, cfgs.BalancedAlloc.ConfigKey()) {
 		cfgs.BalancedAlloc.AllocateBy = []string{"freespace"}
 	}
-	alloc, err := balanced.New(cfgs.BalancedAlloc)
-	checkErr("creating allocator", err)
+	var alloc ipfscluster.PinAllocator
+	if cfgMgr.IsLoadedFromJSON(config.Allocator, cfgs.PluginAllocator.ConfigKey()) {
+		alloc, err = plugins.NewAllocator(cfgs.PluginAllocator)
+		checkErr("creating plugin allocator", err)
+	}
+	if alloc == nil {
+		alloc, err = balanced.New(cfgs.BalancedAlloc)
+		checkErr("creating allocator", err)
+	}
+
+	var publishers []ipfscluster.EventPublisher
+	if cfgs.Eventbus.Enabled() {
+		pub, err := eventbus.New(cfgs.Eventbus, host.ID().Pretty())
+		checkErr("creating event bus publisher", err)
+		publishers = append(publishers, pub)
+	}
 
 	ipfscluster.ReadyTimeout = cfgs.Raft.WaitForLeaderTimeout + 5*time.Second
 
+	cfgs.Metrics.ClusterPeername = cfgs.Cluster.Peername
 	err = observations.SetupMetrics(cfgs.Metrics)
 	checkErr("setting up Metrics", err)
 
@@ -204,8 +233,18 @@ func createCluster(
 		peersF = cons.Peers
 	}
 
-	tracker := stateless.New(cfgs.Statelesstracker, host.ID(), cfgs.Cluster.Peername, cons.State)
-	logger.Debug("stateless pintracker loaded")
+	var tracker ipfscluster.PinTracker
+	if cfgs.Remotetracker.Enabled() {
+		tracker, err = remote.New(cfgs.Remotetracker, host, cfgs.Cluster.Peername)
+		if err != nil {
+			store.Close()
+			checkErr("setting up remote PinTracker", err)
+		}
+		logger.Infof("using the remote pintracker at %s", cfgs.Remotetracker.Endpoint)
+	} else {
+		tracker = stateless.New(cfgs.Statelesstracker, host.ID(), cfgs.Cluster.Peername, cons.State, store)
+		logger.Debug("stateless pintracker loaded")
+	}
 
 	mon, err := pubsubmon.New(ctx, cfgs.Pubsubmon, pubsub, peersF)
 	if err != nil {
@@ -213,6 +252,9 @@ func createCluster(
 		checkErr("setting up PeerMonitor", err)
 	}
 
+	// Apply changes to the trusted peers from the configuration source.
+	go cmdutils.WatchConfigSource(ctx, cfgHelper, cons)
+
 	return ipfscluster.NewCluster(
 		ctx,
 		host,
@@ -226,6 +268,7 @@ func createCluster(
 		mon,
 		alloc,
 		informers,
+		publishers,
 		tracer,
 	)
 }
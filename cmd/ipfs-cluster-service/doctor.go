@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/cmdutils"
+	"github.com/ipfs/ipfs-cluster/pstoremgr"
+
+	fslock "github.com/ipfs/go-fs-lock"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+	cli "github.com/urfave/cli"
+)
+
+const (
+	doctorTimeout      = 10 * time.Second
+	doctorMaxClockSkew = 5 * time.Second
+)
+
+type findingLevel int
+
+const (
+	levelOK findingLevel = iota
+	levelWarning
+	levelError
+)
+
+func (l findingLevel) String() string {
+	switch l {
+	case levelWarning:
+		return "WARN"
+	case levelError:
+		return "ERROR"
+	default:
+		return "OK"
+	}
+}
+
+// finding is the result of a doctor check, with a suggestion of how to fix
+// it when it is a problem.
+type finding struct {
+	level findingLevel
+	check string
+	msg   string
+	fix   string
+}
+
+type findings []finding
+
+func (fs *findings) ok(check, msg string, a ...interface{}) {
+	*fs = append(*fs, finding{levelOK, check, fmt.Sprintf(msg, a...), ""})
+}
+
+func (fs *findings) warn(check, fix, msg string, a ...interface{}) {
+	*fs = append(*fs, finding{levelWarning, check, fmt.Sprintf(msg, a...), fix})
+}
+
+func (fs *findings) fail(check, fix, msg string, a ...interface{}) {
+	*fs = append(*fs, finding{levelError, check, fmt.Sprintf(msg, a...), fix})
+}
+
+func (fs findings) print(w io.Writer) (warnings, errors int) {
+	for _, f := range fs {
+		fmt.Fprintf(w, "%-5s %-10s %s\n", f.level, f.check, f.msg)
+		if f.fix != "" {
+			fmt.Fprintf(w, "%16s %s\n", "->", f.fix)
+		}
+		switch f.level {
+		case levelWarning:
+			warnings++
+		case levelError:
+			errors++
+		}
+	}
+	return
+}
+
+// doctor runs a number of checks on the configuration and the environment of
+// the peer and prints their results. It exits with an error when any check
+// fails.
+func doctor(c *cli.Context) error {
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+
+	fs := runDoctor(ctx, c.String("time-url"))
+	warnings, errors := fs.print(os.Stdout)
+	fmt.Printf("\n%d errors, %d warnings\n", errors, warnings)
+	if errors > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
+
+func runDoctor(ctx context.Context, timeURL string) findings {
+	var fs findings
+
+	cfgHelper, err := cmdutils.NewLoadedConfigHelper(configPath, identityPath)
+	if err != nil {
+		fs.fail("config", "fix the error or create a new configuration with \"init\"", "%s", err)
+		return fs
+	}
+	defer cfgHelper.Manager().Shutdown()
+	fs.ok("config", "configuration loaded from %s", configPath)
+
+	checkIdentity(&fs, cfgHelper)
+	checkConsensus(&fs, cfgHelper)
+	checkReplication(&fs, cfgHelper)
+	checkExposure(&fs, cfgHelper.Configs())
+
+	// The datastore and the ports are in use while the peer runs.
+	lk, err := fslock.Lock(locker.path, lockFileName)
+	if err != nil {
+		fs.warn("lock", "stop the peer to check its datastore and ports", "%s seems to be running: skipping datastore and port checks", programName)
+		checkPorts(&fs, cfgHelper.Configs(), false)
+	} else {
+		checkPorts(&fs, cfgHelper.Configs(), true)
+		checkDatastore(ctx, &fs, cfgHelper)
+		lk.Close()
+	}
+
+	ipfsDate := checkIPFS(ctx, &fs, cfgHelper.Configs().Ipfshttp.NodeAddr)
+	if ipfsDate != "" {
+		checkClockSkew(&fs, "the IPFS daemon", ipfsDate, time.Now())
+	}
+	if timeURL != "" {
+		date, err := httpDate(ctx, timeURL)
+		if err != nil {
+			fs.warn("clock", "check the --time-url", "cannot obtain the time from %s: %s", timeURL, err)
+		} else {
+			checkClockSkew(&fs, timeURL, date, time.Now())
+		}
+	}
+	return fs
+}
+
+func checkIdentity(fs *findings, cfgHelper *cmdutils.ConfigHelper) {
+	ident := cfgHelper.Identity()
+	if err := ident.Validate(); err != nil {
+		fs.fail("identity", "restore the identity file from a backup or create a new one with \"init\"", "%s: %s", identityPath, err)
+	} else {
+		fs.ok("identity", "peer ID %s", ident.ID)
+	}
+
+	if len(cfgHelper.Configs().Cluster.Secret) == 0 {
+		fs.warn("secret", "set the same cluster.secret in all peers to make the cluster private", "the cluster secret is empty: any peer can connect to this one")
+	} else {
+		fs.ok("secret", "cluster secret set")
+	}
+}
+
+func checkConsensus(fs *findings, cfgHelper *cmdutils.ConfigHelper) {
+	cfgs := cfgHelper.Configs()
+	consensus := cfgHelper.GetConsensus()
+	switch consensus {
+	case "":
+		fs.fail("consensus", "keep only the section of the consensus component in use", "the configuration must have exactly one of the crdt or raft sections")
+		return
+	case cfgs.Crdt.ConfigKey():
+		if cfgHelper.GetDatastore() == "" {
+			fs.fail("datastore", "keep only the section of the datastore in use", "the configuration must have exactly one of the badger or leveldb sections")
+		}
+		if !cfgs.Crdt.TrustAll && len(cfgs.Crdt.TrustedPeers) == 0 {
+			fs.warn("consensus", "add the IDs of the peers allowed to modify the pinset to crdt.trusted_peers", "crdt.trusted_peers is empty: only pinset updates from this peer are accepted")
+		}
+	}
+	fs.ok("consensus", "using %s", consensus)
+}
+
+// checkReplication warns when the minimum replication factor cannot be met
+// with the peers known to this peer.
+func checkReplication(fs *findings, cfgHelper *cmdutils.ConfigHelper) {
+	cfg := cfgHelper.Configs().Cluster
+	if cfg.ReplicationFactorMin <= 0 {
+		return
+	}
+
+	pm := pstoremgr.New(context.Background(), nil, cfg.GetPeerstorePath())
+	addrs := append(pm.LoadPeerstore(), cfg.PeerAddresses...)
+	peers := map[peer.ID]struct{}{
+		cfgHelper.Identity().ID: {},
+	}
+	for _, addr := range addrs {
+		if pinfo, err := peer.AddrInfoFromP2pAddr(addr); err == nil {
+			peers[pinfo.ID] = struct{}{}
+		}
+	}
+	if len(peers) < cfg.ReplicationFactorMin {
+		fs.warn("replication", "lower cluster.replication_factor_min or add more peers", "replication_factor_min is %d but only %d peers are known: pinning will fail until more peers join", cfg.ReplicationFactorMin, len(peers))
+	}
+}
+
+func isLocalAddr(addr ma.Multiaddr) bool {
+	if _, err := addr.ValueForProtocol(ma.P_UNIX); err == nil {
+		return true
+	}
+	return manet.IsIPLoopback(addr)
+}
+
+// checkExposure warns about APIs which can be reached from other hosts
+// without authentication.
+func checkExposure(fs *findings, cfgs *cmdutils.Configs) {
+	if cfgs.Restapi.BasicAuthCredentials == nil {
+		for _, addr := range cfgs.Restapi.HTTPListenAddr {
+			if !isLocalAddr(addr) {
+				fs.warn("restapi", "set restapi.basic_auth_credentials or listen on a local address", "the REST API listens on %s without authentication", addr)
+			}
+		}
+	}
+	for _, addr := range cfgs.Ipfsproxy.ListenAddr {
+		if !isLocalAddr(addr) {
+			fs.warn("ipfsproxy", "make ipfsproxy.listen_multiaddress a local address unless the proxy must be public", "the IPFS proxy listens on %s, giving access to the IPFS API", addr)
+		}
+	}
+}
+
+// checkPorts reports TCP ports used by more than one component and, when
+// listen is set, ports which are already in use by other processes.
+func checkPorts(fs *findings, cfgs *cmdutils.Configs, listen bool) {
+	components := []struct {
+		name  string
+		addrs []ma.Multiaddr
+	}{
+		{"cluster", cfgs.Cluster.ListenAddr},
+		{"restapi", cfgs.Restapi.HTTPListenAddr},
+		{"restapi", cfgs.Restapi.Libp2pListenAddr},
+		{"ipfsproxy", cfgs.Ipfsproxy.ListenAddr},
+	}
+
+	used := make(map[string]string)
+	problems := false
+	for _, comp := range components {
+		for _, addr := range comp.addrs {
+			port, err := addr.ValueForProtocol(ma.P_TCP)
+			if err != nil || port == "0" {
+				continue
+			}
+			if other, ok := used[port]; ok {
+				fs.fail("ports", "use a different port for each component", "TCP port %s is used by %s and %s", port, other, comp.name)
+				problems = true
+				continue
+			}
+			used[port] = comp.name
+
+			if !listen {
+				continue
+			}
+			l, err := manet.Listen(addr)
+			if err != nil {
+				fs.fail("ports", "stop the process using the port or change the "+comp.name+" listen address", "cannot listen on %s: %s", addr, err)
+				problems = true
+				continue
+			}
+			l.Close()
+		}
+	}
+	if !problems {
+		fs.ok("ports", "no port conflicts")
+	}
+}
+
+func checkDatastore(ctx context.Context, fs *findings, cfgHelper *cmdutils.ConfigHelper) {
+	fix := "restore the state with \"state import\" from the export of a healthy peer"
+	mgr, err := cmdutils.NewStateManagerWithHelper(cfgHelper)
+	if err != nil {
+		fs.fail("datastore", fix, "%s", err)
+		return
+	}
+	store, err := mgr.GetStore()
+	if err != nil {
+		fs.fail("datastore", "check the permissions and free space of the datastore folder", "cannot open the datastore: %s", err)
+		return
+	}
+	defer store.Close()
+
+	st, err := mgr.GetOfflineState(store)
+	if err != nil {
+		fs.fail("datastore", fix, "cannot read the state: %s", err)
+		return
+	}
+	pins, err := st.List(ctx)
+	if err != nil {
+		fs.fail("datastore", fix, "cannot list the pinset: %s", err)
+		return
+	}
+	fs.ok("datastore", "%d pins in the state", len(pins))
+}
+
+// checkIPFS contacts the IPFS API and returns the Date header of the
+// response, or an empty string when it is not reachable.
+func checkIPFS(ctx context.Context, fs *findings, nodeAddr ma.Multiaddr) string {
+	fix := "check that the IPFS daemon is running and that ipfshttp.node_multiaddress is correct"
+	network, addr, err := manet.DialArgs(nodeAddr)
+	if err != nil {
+		fs.fail("ipfs", fix, "bad IPFS address %s: %s", nodeAddr, err)
+		return ""
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			},
+		},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://ipfs/api/v0/version", nil)
+	if err != nil {
+		fs.fail("ipfs", fix, "%s", err)
+		return ""
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		fs.fail("ipfs", fix, "the IPFS API at %s is not reachable: %s", nodeAddr, err)
+		return ""
+	}
+	defer resp.Body.Close()
+
+	var v struct {
+		Version string
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&v) != nil {
+		fs.fail("ipfs", fix, "unexpected response from the IPFS API at %s: %s", nodeAddr, resp.Status)
+		return ""
+	}
+	fs.ok("ipfs", "IPFS %s reachable at %s", v.Version, nodeAddr)
+	return resp.Header.Get("Date")
+}
+
+func httpDate(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Header.Get("Date"), nil
+}
+
+// checkClockSkew compares the local time with the Date header obtained from
+// another host. Skewed clocks affect the expiration of pins and metrics.
+func checkClockSkew(fs *findings, source, date string, now time.Time) {
+	t, err := http.ParseTime(date)
+	if err != nil {
+		fs.warn("clock", "", "cannot parse the date from %s: %q", source, date)
+		return
+	}
+	skew := now.Sub(t)
+	if skew < 0 {
+		skew = -skew
+	}
+	// The Date header has a resolution of one second.
+	skew = skew.Truncate(time.Second)
+	if skew > doctorMaxClockSkew {
+		direction := "ahead of"
+		if now.Before(t) {
+			direction = "behind"
+		}
+		fs.warn("clock", "synchronize the clock with NTP", "the local clock is %s %s %s", skew, direction, source)
+		return
+	}
+	fs.ok("clock", "the local clock is in sync with %s", strings.TrimPrefix(source, "the "))
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	ipfscluster "github.com/ipfs/ipfs-cluster"
+	"github.com/ipfs/ipfs-cluster/api/ipfsproxy"
+	"github.com/ipfs/ipfs-cluster/api/rest"
+	"github.com/ipfs/ipfs-cluster/cmdutils"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func levels(fs findings) (warnings, errors int) {
+	for _, f := range fs {
+		switch f.level {
+		case levelWarning:
+			warnings++
+		case levelError:
+			errors++
+		}
+	}
+	return
+}
+
+func TestCheckClockSkew(t *testing.T) {
+	now := time.Now()
+
+	var fs findings
+	checkClockSkew(&fs, "test", now.UTC().Format(http.TimeFormat), now)
+	if w, _ := levels(fs); w != 0 {
+		t.Error("expected no warnings:", fs)
+	}
+
+	fs = nil
+	checkClockSkew(&fs, "test", now.Add(time.Minute).UTC().Format(http.TimeFormat), now)
+	if w, _ := levels(fs); w != 1 {
+		t.Error("expected a warning with a skewed clock:", fs)
+	}
+
+	fs = nil
+	checkClockSkew(&fs, "test", "yesterday", now)
+	if w, _ := levels(fs); w != 1 {
+		t.Error("expected a warning with a bad date:", fs)
+	}
+}
+
+func TestCheckPorts(t *testing.T) {
+	cfgs := &cmdutils.Configs{
+		Cluster:   &ipfscluster.Config{},
+		Restapi:   &rest.Config{},
+		Ipfsproxy: &ipfsproxy.Config{},
+	}
+	cfgs.Cluster.Default()
+	cfgs.Restapi.Default()
+	cfgs.Ipfsproxy.Default()
+
+	var fs findings
+	checkPorts(&fs, cfgs, false)
+	if _, e := levels(fs); e != 0 {
+		t.Error("expected no errors with the default configuration:", fs)
+	}
+
+	addr, _ := ma.NewMultiaddr("/ip4/127.0.0.1/tcp/9094")
+	cfgs.Ipfsproxy.ListenAddr = []ma.Multiaddr{addr}
+	fs = nil
+	checkPorts(&fs, cfgs, false)
+	if _, e := levels(fs); e != 1 {
+		t.Error("expected an error with a repeated port:", fs)
+	}
+}
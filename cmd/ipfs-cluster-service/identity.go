@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ipfs/ipfs-cluster/cmdutils"
+	"github.com/ipfs/ipfs-cluster/config"
+
+	cli "github.com/urfave/cli"
+)
+
+func identityRotate(c *cli.Context) error {
+	locker.lock()
+	defer locker.tryUnlock()
+
+	cfgHelper, err := cmdutils.NewLoadedConfigHelper(configPath, identityPath)
+	checkErr("loading configuration", err)
+	defer cfgHelper.Manager().Shutdown()
+	cfgs := cfgHelper.Configs()
+
+	prev, ident, backup, err := rotateIdentityFile(identityPath)
+	checkErr("rotating the identity", err)
+
+	out("The identity of this peer has been rotated:\n\n")
+	out("  previous ID: %s (saved in %s)\n", prev.ID, backup)
+	out("  new ID:      %s\n\n", ident.ID)
+	out("Once the peer runs with the new identity, keep the content allocated to it\n")
+	out("by running, from any peer:\n\n")
+	out("  ipfs-cluster-ctl peers handover %s %s\n\n", prev.ID, ident.ID)
+
+	switch cfgHelper.GetConsensus() {
+	case cfgs.Crdt.ConfigKey():
+		if !cfgs.Crdt.TrustAll {
+			out("Add the new ID to crdt.trusted_peers in the configuration of the rest of\n")
+			out("peers so that they accept its pinset updates.\n")
+		}
+	case cfgs.Raft.ConfigKey():
+		out("With Raft, remove the previous ID from the peerset (\"ipfs-cluster-ctl peers rm\"),\n")
+		out("clean up the state of this peer (\"state cleanup\") and start it with\n")
+		out("--bootstrap pointing to a current peer.\n")
+	}
+	if os.Getenv("CLUSTER_ID") != "" || os.Getenv("CLUSTER_PRIVATEKEY") != "" {
+		out("\nThe CLUSTER_ID and CLUSTER_PRIVATEKEY environment variables override the\n")
+		out("identity file and must be updated too.\n")
+	}
+	return nil
+}
+
+// rotateIdentityFile replaces the identity in the given file with a newly
+// generated one, keeping a copy of the previous identity next to it. It
+// returns both identities and the path of the copy.
+func rotateIdentityFile(path string) (*config.Identity, *config.Identity, string, error) {
+	prev := &config.Identity{}
+	if err := prev.LoadJSONFromFile(path); err != nil {
+		return nil, nil, "", err
+	}
+
+	backup := fmt.Sprintf("%s.%s", path, prev.ID)
+	if _, err := os.Stat(backup); err == nil {
+		return nil, nil, "", fmt.Errorf("%s already exists", backup)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, "", err
+	}
+	if err := prev.SaveJSON(backup); err != nil {
+		return nil, nil, "", err
+	}
+
+	ident, err := config.NewIdentity()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if err := ident.SaveJSON(path); err != nil {
+		return nil, nil, "", err
+	}
+	return prev, ident, backup, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/config"
+)
+
+func TestRotateIdentityFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultIdentityFile)
+	orig, err := config.NewIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := orig.SaveJSON(path); err != nil {
+		t.Fatal(err)
+	}
+
+	prev, ident, backup, err := rotateIdentityFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prev.ID != orig.ID || ident.ID == orig.ID {
+		t.Error("expected a new identity")
+	}
+
+	saved := &config.Identity{}
+	if err := saved.LoadJSONFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if !saved.Equals(ident) {
+		t.Error("expected the new identity to be saved")
+	}
+
+	saved = &config.Identity{}
+	if err := saved.LoadJSONFromFile(backup); err != nil {
+		t.Fatal(err)
+	}
+	if !saved.Equals(orig) {
+		t.Error("expected the previous identity to be kept")
+	}
+
+	// Rotating the original identity again would overwrite its copy.
+	if err := orig.SaveJSON(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := rotateIdentityFile(path); err == nil {
+		t.Error("expected an error when the copy of the previous identity exists")
+	}
+}
@@ -3,24 +3,31 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/user"
 	"path/filepath"
 	"strings"
+	"time"
 
 	ipfscluster "github.com/ipfs/ipfs-cluster"
 	"github.com/ipfs/ipfs-cluster/api"
 	"github.com/ipfs/ipfs-cluster/cmdutils"
+	"github.com/ipfs/ipfs-cluster/config"
+	"github.com/ipfs/ipfs-cluster/ipfsconn/ipfshttp"
 	"github.com/ipfs/ipfs-cluster/pstoremgr"
 	"github.com/ipfs/ipfs-cluster/version"
 	peer "github.com/libp2p/go-libp2p-core/peer"
 	ma "github.com/multiformats/go-multiaddr"
 
 	semver "github.com/blang/semver"
+	cid "github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log/v2"
 	cli "github.com/urfave/cli"
 )
@@ -33,6 +40,7 @@ const (
 	defaultLogLevel  = "info"
 	defaultConsensus = "crdt"
 	defaultDatastore = "badger"
+	defaultGateway   = "127.0.0.1:8080"
 )
 
 const (
@@ -233,7 +241,15 @@ does already exist, generate a new %s for %s.
 If the optional [source-url] is given, the generated configuration file
 will refer to it. The source configuration will be fetched from its source
 URL during the launch of the daemon. If not, a default standard configuration
-file will be created.
+file will be created. IPFS paths (/ipns/<name>/service.json) are fetched
+through the gateway given with --gateway.
+
+When --source-signer is set, the source configuration is only accepted if
+it is signed by the given peer. The signature must be served at the source
+URL with the ".sig" suffix, and can be produced with the "sign" command using
+the signer's identity. With --source-refresh, running peers fetch the source
+configuration periodically and apply changes to the CRDT trusted peers. Other
+changes are applied when the peer is restarted.
 
 In the latter case, a cluster secret will be generated as required
 by %s. Alternatively, this secret can be manually
@@ -260,7 +276,7 @@ the peer IDs in the given multiaddresses.
 				programName,
 				DefaultIdentityFile,
 			),
-			ArgsUsage: "[http-source-url]",
+			ArgsUsage: "[source-url]",
 			Flags: []cli.Flag{
 				cli.StringFlag{
 					Name:  "consensus",
@@ -288,6 +304,20 @@ the peer IDs in the given multiaddresses.
 					Name:  "randomports",
 					Usage: "configure random ports to listen on instead of defaults",
 				},
+				cli.StringFlag{
+					Name:   "gateway",
+					Usage:  "IPFS gateway used to fetch sources given as IPFS paths",
+					Value:  defaultGateway,
+					EnvVar: "IPFS_GATEWAY",
+				},
+				cli.StringFlag{
+					Name:  "source-signer",
+					Usage: "peer ID which must sign the source configuration",
+				},
+				cli.DurationFlag{
+					Name:  "source-refresh",
+					Usage: "interval to fetch the source configuration while running (0 disables)",
+				},
 			},
 			Action: func(c *cli.Context) error {
 				consensus := c.String("consensus")
@@ -339,7 +369,17 @@ the peer IDs in the given multiaddresses.
 				}
 
 				// Set url. If exists, it will be the only thing saved.
-				cfgHelper.Manager().Source = c.Args().First()
+				source := c.Args().First()
+				if strings.HasPrefix(source, "/ipns/") || strings.HasPrefix(source, "/ipfs/") {
+					source = fmt.Sprintf("http://%s%s", c.String("gateway"), source)
+				}
+				cfgHelper.Manager().Source = source
+				if signer := c.String("source-signer"); signer != "" {
+					pid, err := peer.Decode(signer)
+					checkErr("parsing --source-signer", err)
+					cfgHelper.Manager().SourceSigner = pid
+				}
+				cfgHelper.Manager().SourceRefreshInterval = c.Duration("source-refresh")
 
 				// Generate defaults for all registered components
 				err := cfgHelper.Manager().Default()
@@ -565,6 +605,65 @@ to import. If no argument is provided, stdin will be used.
 						return nil
 					},
 				},
+				{
+					Name:  "restore",
+					Usage: "load the state from a backup stored in IPFS",
+					Description: `
+This command reads a state backup taken by the cluster peers (see the
+"state_backup" section of the cluster configuration) from the IPFS daemon
+and replaces the existing pinset (state) with it. Differential backups are
+applied on top of the backups they depend on, which are read as well.
+
+State backups are pinned with the "state-backup" metadata key and named
+after the time they were taken, so that
+"ipfs-cluster-ctl pin ls --name-prefix cluster-state-backup-" lists them.
+`,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "from-cid",
+							Usage: "CID of the backup to restore",
+						},
+						cli.BoolFlag{
+							Name:  "force, f",
+							Usage: "skips confirmation prompt",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						locker.lock()
+						defer locker.tryUnlock()
+
+						from, err := cid.Decode(c.String("from-cid"))
+						checkErr("parsing --from-cid", err)
+
+						confirm := "The pinset (state) of this peer "
+						confirm += "will be replaced. Continue? [y/n]:"
+						if !c.Bool("force") && !yesNoPrompt(confirm) {
+							return nil
+						}
+
+						cfgHelper, err := cmdutils.NewLoadedConfigHelper(configPath, identityPath)
+						checkErr("loading configurations", err)
+						cfgHelper.Manager().Shutdown()
+
+						ctx := context.Background()
+						connector, err := ipfshttp.NewConnector(cfgHelper.Configs().Ipfshttp)
+						checkErr("creating IPFS Connector component", err)
+						pins, err := ipfscluster.RestoreStateBackup(ctx, connector, from)
+						connector.Shutdown(ctx)
+						checkErr("reading the backup", err)
+
+						var buf bytes.Buffer
+						enc := json.NewEncoder(&buf)
+						for _, pin := range pins {
+							checkErr("encoding the backup", enc.Encode(pin))
+						}
+
+						mgr := getStateManager()
+						checkErr("importing state", mgr.ImportState(&buf, api.PinOptions{}))
+						logger.Infof("state restored from %s with %d pins.  Make sure all peers have consistent states", from, len(pins))
+						return nil
+					},
+				},
 				{
 					Name:  "cleanup",
 					Usage: "remove persistent data",
@@ -599,6 +698,125 @@ to all effects. Peers may need to bootstrap and sync from scratch after this.
 				},
 			},
 		},
+		{
+			Name:      "sign",
+			Usage:     "Signs a configuration to be used as source by other peers",
+			ArgsUsage: "<config-file>",
+			Description: fmt.Sprintf(`
+This command signs the given configuration file with the key in the identity
+of this peer and writes the signature next to it, with the %q suffix. The
+signature must be published along with the configuration, so that peers
+initialized with "init --source-signer <ID of this peer>" can verify it.
+
+Any change to the configuration file requires signing it again.
+`, config.SourceSignatureSuffix),
+			Action: func(c *cli.Context) error {
+				if !c.Args().Present() {
+					return cli.NewExitError("a configuration file must be given", 1)
+				}
+				path := c.Args().First()
+
+				ident := &config.Identity{}
+				checkErr("loading the identity", ident.LoadJSONFromFile(identityPath))
+				cfg, err := ioutil.ReadFile(path)
+				checkErr("reading the configuration", err)
+				sig, err := config.SignSource(ident.PrivateKey, cfg)
+				checkErr("signing the configuration", err)
+				sigPath := path + config.SourceSignatureSuffix
+				checkErr("writing the signature", ioutil.WriteFile(sigPath, sig, 0644))
+				out("signature written to %s. Signer: %s\n", sigPath, ident.ID)
+				return nil
+			},
+		},
+		{
+			Name:  "identity",
+			Usage: "Manages the identity of the peer",
+			Subcommands: []cli.Command{
+				{
+					Name:  "rotate",
+					Usage: "replace the identity of the peer with a new one",
+					Description: `
+This command generates a new identity (peer ID and private key) for this peer,
+replacing the one in the identity file. The previous identity is kept in a
+copy of the file, suffixed with the previous peer ID. The peer must not be
+running.
+
+The content allocated to the previous peer ID is not moved automatically. Once
+the peer runs with its new identity, hand over those allocations with
+"ipfs-cluster-ctl peers handover <previous ID> <new ID>". Otherwise they are
+re-allocated to other peers when the previous ID goes away.
+`,
+					Action: identityRotate,
+				},
+			},
+		},
+		{
+			Name:  "doctor",
+			Usage: "Checks the configuration and environment of the peer",
+			Description: `
+This command runs a number of checks and prints their results, with
+suggestions to fix the problems found:
+
+  - the configuration and identity files can be loaded and are consistent
+  - the cluster secret is set and the consensus and datastore are defined
+  - the APIs are not exposed without authentication
+  - the listen ports are not in use by other components or processes
+  - the datastore can be opened and the pinset read
+  - the IPFS API is reachable
+  - the local clock is in sync with the IPFS daemon and, optionally, with
+    the HTTP server given with --time-url
+
+The datastore and port checks are skipped while the peer is running. The
+command exits with an error when any check fails.
+`,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "time-url",
+					Usage: "URL of an HTTP server to compare the local clock with",
+				},
+			},
+			Action: doctor,
+		},
+		{
+			Name:  "upgrade",
+			Usage: "Upgrades the peer without disrupting the cluster",
+			Description: `
+This command helps with rolling upgrades, where the peers of a cluster are
+upgraded one at a time. It connects to the REST API of the running peer and:
+
+  - checks that all the cluster peers respond
+  - waits for the ongoing pin and unpin operations of the peer to finish
+  - runs the command given with --exec (i.e. one that installs the new
+    version and restarts the service) with "sh -c"
+  - waits until the peer responds again and sees all the cluster peers
+
+It prints the versions before and after the upgrade and warns when the
+peers use different RPC protocol versions, which happens while the cluster
+is partially upgraded. Use --force to upgrade even when some peers do not
+respond or the operations do not finish in time.
+`,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "exec, e",
+					Usage: "command that upgrades and restarts the peer",
+				},
+				cli.DurationFlag{
+					Name:  "drain-timeout",
+					Value: 10 * time.Minute,
+					Usage: "how long to wait for the ongoing operations to finish",
+				},
+				cli.DurationFlag{
+					Name:  "rejoin-timeout",
+					Value: 5 * time.Minute,
+					Usage: "how long to wait for the peer to rejoin the cluster",
+				},
+				cli.BoolFlag{
+					Name:  "force, f",
+					Usage: "upgrade even when some peers are down or busy",
+				},
+			},
+			Action: upgrade,
+		},
 		{
 			Name:  "version",
 			Usage: "Prints the ipfs-cluster version",
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/api/rest"
+	"github.com/ipfs/ipfs-cluster/api/rest/client"
+	"github.com/ipfs/ipfs-cluster/cmdutils"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+	cli "github.com/urfave/cli"
+)
+
+const upgradePollInterval = 2 * time.Second
+
+// upgradeInProgress are the local pin states which are waited for before
+// upgrading a peer.
+const upgradeInProgress = api.TrackerStatusPinQueued |
+	api.TrackerStatusPinning |
+	api.TrackerStatusUnpinQueued |
+	api.TrackerStatusUnpinning
+
+func upgrade(c *cli.Context) error {
+	command := c.String("exec")
+	if command == "" {
+		return cli.NewExitError("--exec is required", 1)
+	}
+
+	cfgHelper, err := cmdutils.NewLoadedConfigHelper(configPath, identityPath)
+	checkErr("loading configuration", err)
+	clientCfg, err := upgradeClientConfig(cfgHelper.Configs().Restapi)
+	cfgHelper.Manager().Shutdown()
+	checkErr("configuring the REST API client", err)
+	cl, err := client.NewDefaultClient(clientCfg)
+	checkErr("creating the REST API client", err)
+
+	ctx := context.Background()
+	before, err := cl.PeerVersions(ctx)
+	checkErr("fetching the peer versions", err)
+	if down := failingPeers(before); len(down) > 0 && !c.Bool("force") {
+		return cli.NewExitError(fmt.Sprintf("some peers are not responding: %v. Use --force to upgrade anyway", down), 1)
+	}
+	id, err := cl.ID(ctx)
+	checkErr("fetching the peer ID", err)
+	out("upgrading %s (ipfs-cluster %s)\n", id.ID, id.Version)
+
+	out("waiting for the ongoing pin operations to finish...\n")
+	err = waitFor(ctx, c.Duration("drain-timeout"), func(ctx context.Context) error {
+		pending, err := cl.StatusAll(ctx, upgradeInProgress, true)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%d pin operations in progress", len(pending))
+		}
+		return nil
+	})
+	if err != nil && !c.Bool("force") {
+		return cli.NewExitError(fmt.Sprintf("the peer did not drain: %s. Use --force to upgrade anyway", err), 1)
+	}
+
+	out("running %q\n", command)
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return cli.NewExitError(fmt.Sprintf("the upgrade command failed: %s", err), 1)
+	}
+
+	out("waiting for the peer to rejoin the cluster...\n")
+	var after *api.PeerVersions
+	err = waitFor(ctx, c.Duration("rejoin-timeout"), func(ctx context.Context) error {
+		newID, err := cl.ID(ctx)
+		if err != nil {
+			return err
+		}
+		if newID.Error != "" {
+			return errors.New(newID.Error)
+		}
+		id = newID
+		after, err = cl.PeerVersions(ctx)
+		if err != nil {
+			return err
+		}
+		if down := failingPeers(after); len(down) > 0 {
+			return fmt.Errorf("peers not responding: %v", down)
+		}
+		return nil
+	})
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("the peer did not rejoin the cluster: %s", err), 1)
+	}
+
+	out("%s is running ipfs-cluster %s\n", id.ID, id.Version)
+	if after.RPCProtocolSkew {
+		out("WARNING: the peers use different RPC protocol versions: %v\n", after.RPCProtocolVersions)
+	}
+	return nil
+}
+
+// upgradeClientConfig returns the configuration to reach the local REST
+// API.
+func upgradeClientConfig(cfg *rest.Config) (*client.Config, error) {
+	if len(cfg.HTTPListenAddr) == 0 {
+		return nil, errors.New("the REST API has no HTTP listen addresses")
+	}
+	addr := cfg.HTTPListenAddr[0]
+	if manet.IsIPUnspecified(addr) {
+		_, tail := ma.SplitFirst(addr)
+		loopback, _ := ma.NewMultiaddr("/ip4/127.0.0.1")
+		addr = loopback
+		if tail != nil {
+			addr = loopback.Encapsulate(tail)
+		}
+	}
+
+	clientCfg := &client.Config{
+		APIAddr:      addr,
+		SSL:          cfg.TLS != nil,
+		NoVerifyCert: true,
+	}
+	for user, pass := range cfg.BasicAuthCredentials {
+		clientCfg.Username = user
+		clientCfg.Password = pass
+		break
+	}
+	return clientCfg, nil
+}
+
+// failingPeers returns the peers whose versions could not be retrieved.
+func failingPeers(pvs *api.PeerVersions) []string {
+	var down []string
+	for _, pv := range pvs.Peers {
+		if pv.Error != "" {
+			down = append(down, pv.ID.Pretty())
+		}
+	}
+	return down
+}
+
+// waitFor calls check until it succeeds or the timeout passes, returning
+// the last error.
+func waitFor(ctx context.Context, timeout time.Duration, check func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(upgradePollInterval)
+	defer ticker.Stop()
+	for {
+		err := check(ctx)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-ticker.C:
+		}
+	}
+}
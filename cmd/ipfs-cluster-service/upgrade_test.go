@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api/rest"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func TestUpgradeClientConfig(t *testing.T) {
+	cfg := rest.NewConfig()
+	cfg.Default()
+	addr, _ := ma.NewMultiaddr("/ip4/0.0.0.0/tcp/9094")
+	cfg.HTTPListenAddr = []ma.Multiaddr{addr}
+	cfg.BasicAuthCredentials = map[string]string{"user": "pass"}
+
+	clientCfg, err := upgradeClientConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clientCfg.APIAddr.String() != "/ip4/127.0.0.1/tcp/9094" {
+		t.Error("expected the unspecified address to be replaced:", clientCfg.APIAddr)
+	}
+	if clientCfg.Username != "user" || clientCfg.Password != "pass" {
+		t.Error("expected the basic auth credentials to be used")
+	}
+	if clientCfg.SSL {
+		t.Error("expected SSL to be disabled without TLS")
+	}
+
+	cfg.HTTPListenAddr = nil
+	if _, err := upgradeClientConfig(cfg); err == nil {
+		t.Error("expected an error without listen addresses")
+	}
+}
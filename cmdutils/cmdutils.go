@@ -123,7 +123,7 @@ func HandleSignals(
 		select {
 		case <-signalChan:
 			ctrlcCount++
-			handleCtrlC(ctx, cluster, ctrlcCount)
+			handleCtrlC(ctx, []*ipfscluster.Cluster{cluster}, ctrlcCount)
 		case <-cluster.Done():
 			cancel()
 			return multierr.Combine(
@@ -135,15 +135,77 @@ func HandleSignals(
 	}
 }
 
-func handleCtrlC(ctx context.Context, cluster *ipfscluster.Cluster, ctrlcCount int) {
+// Peer groups a cluster peer with the components that need to be closed
+// once it has shut down.
+type Peer struct {
+	Cluster *ipfscluster.Cluster
+	Host    host.Host
+	DHT     *dual.DHT
+	Store   datastore.Datastore
+}
+
+// HandleSignalsAll works like HandleSignals but for several cluster peers
+// running in the same process. All peers are shut down on the first signal.
+// It returns when all of them are done.
+func HandleSignalsAll(
+	ctx context.Context,
+	cancel context.CancelFunc,
+	peers []*Peer,
+) error {
+	signalChan := make(chan os.Signal, 20)
+	signal.Notify(
+		signalChan,
+		syscall.SIGINT,
+		syscall.SIGTERM,
+		syscall.SIGHUP,
+	)
+
+	clusters := make([]*ipfscluster.Cluster, 0, len(peers))
+	for _, p := range peers {
+		clusters = append(clusters, p.Cluster)
+	}
+
+	// A peer may also shut down on its own (i.e. removed from the
+	// cluster). Others keep running until all of them are done.
+	done := make(chan error, len(peers))
+	for _, p := range peers {
+		go func(p *Peer) {
+			<-p.Cluster.Done()
+			done <- multierr.Combine(
+				p.DHT.Close(),
+				p.Host.Close(),
+				p.Store.Close(),
+			)
+		}(p)
+	}
+
+	var ctrlcCount int
+	var err error
+	for remaining := len(peers); remaining > 0; {
+		select {
+		case <-signalChan:
+			ctrlcCount++
+			handleCtrlC(ctx, clusters, ctrlcCount)
+		case closeErr := <-done:
+			err = multierr.Append(err, closeErr)
+			remaining--
+		}
+	}
+	cancel()
+	return err
+}
+
+func handleCtrlC(ctx context.Context, clusters []*ipfscluster.Cluster, ctrlcCount int) {
 	switch ctrlcCount {
 	case 1:
-		go func() {
-			if err := cluster.Shutdown(ctx); err != nil {
-				ErrorOut("error shutting down cluster: %s", err)
-				os.Exit(1)
-			}
-		}()
+		for _, cluster := range clusters {
+			go func(cluster *ipfscluster.Cluster) {
+				if err := cluster.Shutdown(ctx); err != nil {
+					ErrorOut("error shutting down cluster: %s", err)
+					os.Exit(1)
+				}
+			}(cluster)
+		}
 	case 2:
 		ErrorOut(`
 
@@ -16,13 +16,17 @@ import (
 	"github.com/ipfs/ipfs-cluster/consensus/raft"
 	"github.com/ipfs/ipfs-cluster/datastore/badger"
 	"github.com/ipfs/ipfs-cluster/datastore/leveldb"
+	"github.com/ipfs/ipfs-cluster/eventbus"
 	"github.com/ipfs/ipfs-cluster/informer/disk"
 	"github.com/ipfs/ipfs-cluster/informer/numpin"
 	"github.com/ipfs/ipfs-cluster/informer/tags"
+	"github.com/ipfs/ipfs-cluster/informer/weight"
 	"github.com/ipfs/ipfs-cluster/ipfsconn/ipfshttp"
 	"github.com/ipfs/ipfs-cluster/monitor/pubsubmon"
 	"github.com/ipfs/ipfs-cluster/observations"
+	"github.com/ipfs/ipfs-cluster/pintracker/remote"
 	"github.com/ipfs/ipfs-cluster/pintracker/stateless"
+	"github.com/ipfs/ipfs-cluster/plugins"
 )
 
 // Configs carries config types used by a Cluster Peer.
@@ -34,13 +38,18 @@ type Configs struct {
 	Raft             *raft.Config
 	Crdt             *crdt.Config
 	Statelesstracker *stateless.Config
+	Remotetracker    *remote.Config
 	Pubsubmon        *pubsubmon.Config
 	BalancedAlloc    *balanced.Config
 	Diskinf          *disk.Config
 	Numpininf        *numpin.Config
 	Tagsinf          *tags.Config
+	Weightinf        *weight.Config
+	PluginInformers  *plugins.InformersConfig
+	PluginAllocator  *plugins.AllocatorConfig
 	Metrics          *observations.MetricsConfig
 	Tracing          *observations.TracingConfig
+	Eventbus         *eventbus.Config
 	Badger           *badger.Config
 	LevelDB          *leveldb.Config
 }
@@ -223,13 +232,18 @@ func (ch *ConfigHelper) init() {
 		Raft:             &raft.Config{},
 		Crdt:             &crdt.Config{},
 		Statelesstracker: &stateless.Config{},
+		Remotetracker:    &remote.Config{},
 		Pubsubmon:        &pubsubmon.Config{},
 		BalancedAlloc:    &balanced.Config{},
 		Diskinf:          &disk.Config{},
 		Numpininf:        &numpin.Config{},
 		Tagsinf:          &tags.Config{},
+		Weightinf:        &weight.Config{},
+		PluginInformers:  &plugins.InformersConfig{},
+		PluginAllocator:  &plugins.AllocatorConfig{},
 		Metrics:          &observations.MetricsConfig{},
 		Tracing:          &observations.TracingConfig{},
+		Eventbus:         &eventbus.Config{},
 		Badger:           &badger.Config{},
 		LevelDB:          &leveldb.Config{},
 	}
@@ -238,13 +252,18 @@ func (ch *ConfigHelper) init() {
 	man.RegisterComponent(config.API, cfgs.Ipfsproxy)
 	man.RegisterComponent(config.IPFSConn, cfgs.Ipfshttp)
 	man.RegisterComponent(config.PinTracker, cfgs.Statelesstracker)
+	man.RegisterComponent(config.PinTracker, cfgs.Remotetracker)
 	man.RegisterComponent(config.Monitor, cfgs.Pubsubmon)
 	man.RegisterComponent(config.Allocator, cfgs.BalancedAlloc)
 	man.RegisterComponent(config.Informer, cfgs.Diskinf)
 	// man.RegisterComponent(config.Informer, cfgs.Numpininf)
 	man.RegisterComponent(config.Informer, cfgs.Tagsinf)
+	man.RegisterComponent(config.Informer, cfgs.Weightinf)
+	man.RegisterComponent(config.Informer, cfgs.PluginInformers)
+	man.RegisterComponent(config.Allocator, cfgs.PluginAllocator)
 	man.RegisterComponent(config.Observations, cfgs.Metrics)
 	man.RegisterComponent(config.Observations, cfgs.Tracing)
+	man.RegisterComponent(config.Observations, cfgs.Eventbus)
 
 	registerDatastores := false
 
@@ -0,0 +1,146 @@
+package cmdutils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+var logger = logging.Logger("cmdutils")
+
+// TrustManager is implemented by consensus components which allow modifying
+// the set of trusted peers at runtime.
+type TrustManager interface {
+	Trust(context.Context, peer.ID) error
+	Distrust(context.Context, peer.ID) error
+}
+
+// sourceTemplate is a configuration obtained from a remote source, split in
+// the CRDT trusted peers and everything else.
+type sourceTemplate struct {
+	trustedPeers []string
+	rest         map[string]interface{}
+}
+
+func parseSourceTemplate(body []byte) (*sourceTemplate, error) {
+	tmpl := &sourceTemplate{}
+	if err := json.Unmarshal(body, &tmpl.rest); err != nil {
+		return nil, err
+	}
+
+	consensus, _ := tmpl.rest["consensus"].(map[string]interface{})
+	crdtCfg, _ := consensus["crdt"].(map[string]interface{})
+	peers, _ := crdtCfg["trusted_peers"].([]interface{})
+	for _, p := range peers {
+		if s, ok := p.(string); ok {
+			tmpl.trustedPeers = append(tmpl.trustedPeers, s)
+		}
+	}
+	delete(crdtCfg, "trusted_peers")
+	return tmpl, nil
+}
+
+func (tmpl *sourceTemplate) trustAll() bool {
+	for _, p := range tmpl.trustedPeers {
+		if p == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (tmpl *sourceTemplate) peerSet() map[peer.ID]struct{} {
+	set := make(map[peer.ID]struct{}, len(tmpl.trustedPeers))
+	for _, p := range tmpl.trustedPeers {
+		pid, err := peer.Decode(p)
+		if err != nil {
+			logger.Warnf("ignoring trusted peer %q from the configuration source: %s", p, err)
+			continue
+		}
+		set[pid] = struct{}{}
+	}
+	return set
+}
+
+// WatchConfigSource fetches the remote source of the configuration, if any,
+// every SourceRefreshInterval until the context is cancelled. Changes to the
+// CRDT trusted peers are applied with the given TrustManager, when not nil.
+// Other changes only take effect when the peer is restarted, so a warning is
+// logged instead.
+func WatchConfigSource(ctx context.Context, cfgHelper *ConfigHelper, trust TrustManager) {
+	mgr := cfgHelper.Manager()
+	if mgr.Source == "" || mgr.SourceRefreshInterval <= 0 {
+		return
+	}
+
+	last := mgr.SourceJSON()
+	current, err := parseSourceTemplate(last)
+	if err != nil {
+		logger.Errorf("not watching the configuration source: %s", err)
+		return
+	}
+
+	ticker := time.NewTicker(mgr.SourceRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		body, err := mgr.FetchSource()
+		if err != nil {
+			logger.Warnf("error refreshing the configuration from %s: %s", mgr.Source, err)
+			continue
+		}
+		if bytes.Equal(body, last) {
+			continue
+		}
+		updated, err := parseSourceTemplate(body)
+		if err != nil {
+			logger.Warnf("error parsing the configuration from %s: %s", mgr.Source, err)
+			continue
+		}
+
+		restart := !reflect.DeepEqual(current.rest, updated.rest)
+		if current.trustAll() || updated.trustAll() || trust == nil {
+			restart = restart || !reflect.DeepEqual(current.trustedPeers, updated.trustedPeers)
+		} else {
+			updateTrustedPeers(ctx, trust, current.peerSet(), updated.peerSet())
+		}
+		if restart {
+			logger.Warnf("the configuration at %s has changed. Restart the peer to apply the changes", mgr.Source)
+		}
+		last = body
+		current = updated
+	}
+}
+
+func updateTrustedPeers(ctx context.Context, trust TrustManager, old, new map[peer.ID]struct{}) {
+	for pid := range new {
+		if _, ok := old[pid]; ok {
+			continue
+		}
+		if err := trust.Trust(ctx, pid); err != nil {
+			logger.Errorf("error trusting %s: %s", pid, err)
+			continue
+		}
+		logger.Infof("%s is now a trusted peer", pid)
+	}
+	for pid := range old {
+		if _, ok := new[pid]; ok {
+			continue
+		}
+		if err := trust.Distrust(ctx, pid); err != nil {
+			logger.Errorf("error distrusting %s: %s", pid, err)
+			continue
+		}
+		logger.Infof("%s is no longer a trusted peer", pid)
+	}
+}
@@ -9,12 +9,12 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"path/filepath"
 	"sync"
 	"time"
 
 	logging "github.com/ipfs/go-log/v2"
+	peer "github.com/libp2p/go-libp2p-core/peer"
 )
 
 var logger = logging.Logger("config")
@@ -122,6 +122,14 @@ type Manager struct {
 	jsonCfg *jsonConfig
 	// stores original source if any
 	Source string
+	// SourceSigner, when set, is the peer which must sign the
+	// configuration obtained from the Source.
+	SourceSigner peer.ID
+	// SourceRefreshInterval specifies how often the Source should be
+	// fetched again while running. 0 disables refreshing.
+	SourceRefreshInterval time.Duration
+	// the configuration as obtained from the Source
+	sourceJSON []byte
 
 	sourceRedirs int // used avoid recursive source load
 
@@ -195,18 +203,20 @@ func (cfg *Manager) watchSave(save <-chan struct{}) {
 // saved using json. Most configuration keys are converted into simple types
 // like strings, and key names aim to be self-explanatory for the user.
 type jsonConfig struct {
-	Source       string           `json:"source,omitempty"`
-	Cluster      *json.RawMessage `json:"cluster,omitempty"`
-	Consensus    jsonSection      `json:"consensus,omitempty"`
-	API          jsonSection      `json:"api,omitempty"`
-	IPFSConn     jsonSection      `json:"ipfs_connector,omitempty"`
-	State        jsonSection      `json:"state,omitempty"`
-	PinTracker   jsonSection      `json:"pin_tracker,omitempty"`
-	Monitor      jsonSection      `json:"monitor,omitempty"`
-	Allocator    jsonSection      `json:"allocator,omitempty"`
-	Informer     jsonSection      `json:"informer,omitempty"`
-	Observations jsonSection      `json:"observations,omitempty"`
-	Datastore    jsonSection      `json:"datastore,omitempty"`
+	Source                string           `json:"source,omitempty"`
+	SourceSigner          string           `json:"source_signer,omitempty"`
+	SourceRefreshInterval string           `json:"source_refresh_interval,omitempty"`
+	Cluster               *json.RawMessage `json:"cluster,omitempty"`
+	Consensus             jsonSection      `json:"consensus,omitempty"`
+	API                   jsonSection      `json:"api,omitempty"`
+	IPFSConn              jsonSection      `json:"ipfs_connector,omitempty"`
+	State                 jsonSection      `json:"state,omitempty"`
+	PinTracker            jsonSection      `json:"pin_tracker,omitempty"`
+	Monitor               jsonSection      `json:"monitor,omitempty"`
+	Allocator             jsonSection      `json:"allocator,omitempty"`
+	Informer              jsonSection      `json:"informer,omitempty"`
+	Observations          jsonSection      `json:"observations,omitempty"`
+	Datastore             jsonSection      `json:"datastore,omitempty"`
 }
 
 func (jcfg *jsonConfig) getSection(i SectionType) *jsonSection {
@@ -360,20 +370,11 @@ func (cfg *Manager) LoadJSONFromFile(path string) error {
 func (cfg *Manager) LoadJSONFromHTTPSource(url string) error {
 	logger.Infof("loading configuration from %s", url)
 	cfg.Source = url
-	resp, err := http.Get(url)
-	if err != nil {
-		return fmt.Errorf("%w: %s", errFetchingSource, url)
-	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := cfg.FetchSource()
 	if err != nil {
 		return err
 	}
 
-	if resp.StatusCode >= 300 {
-		return fmt.Errorf("unsuccessful request (%d): %s", resp.StatusCode, body)
-	}
-
 	// Avoid recursively loading remote sources
 	if cfg.sourceRedirs > 0 {
 		return errSourceRedirect
@@ -386,6 +387,7 @@ func (cfg *Manager) LoadJSONFromHTTPSource(url string) error {
 	if err != nil {
 		return err
 	}
+	cfg.sourceJSON = body
 	return nil
 }
 
@@ -416,6 +418,11 @@ func (cfg *Manager) LoadJSON(bs []byte) error {
 	cfg.jsonCfg = jcfg
 	// Handle remote source
 	if jcfg.Source != "" {
+		if cfg.sourceRedirs == 0 {
+			if err := cfg.loadSourceOptions(jcfg); err != nil {
+				return err
+			}
+		}
 		return cfg.LoadJSONFromHTTPSource(jcfg.Source)
 	}
 
@@ -503,7 +510,14 @@ func (cfg *Manager) ToJSON() ([]byte, error) {
 	}
 
 	if cfg.Source != "" {
-		return DefaultJSONMarshal(&jsonConfig{Source: cfg.Source})
+		jcfg := &jsonConfig{Source: cfg.Source}
+		if cfg.SourceSigner != "" {
+			jcfg.SourceSigner = cfg.SourceSigner.String()
+		}
+		if cfg.SourceRefreshInterval > 0 {
+			jcfg.SourceRefreshInterval = cfg.SourceRefreshInterval.String()
+		}
+		return DefaultJSONMarshal(jcfg)
 	}
 
 	jcfg := cfg.jsonCfg
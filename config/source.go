@@ -0,0 +1,135 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	crypto "github.com/libp2p/go-libp2p-core/crypto"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+// Remote configuration sources can be signed. When a "source_signer" peer ID
+// is set along with the "source", the configuration is only accepted when
+// the document at the source URL plus SourceSignatureSuffix contains a valid
+// signature by that peer of the exact bytes of the configuration, encoded in
+// base64.
+
+// SourceSignatureSuffix is appended to the source URL to obtain the URL of
+// its signature.
+const SourceSignatureSuffix = ".sig"
+
+// Error when the signature of a Source-based configuration cannot be verified
+var errSourceSignature = errors.New("invalid configuration source signature")
+
+// IsErrSourceSignature reports whether this error happened because the
+// signature of a remote configuration source could not be verified.
+func IsErrSourceSignature(err error) bool {
+	return errors.Is(err, errSourceSignature)
+}
+
+// SignSource returns the base64-encoded signature of the given configuration
+// bytes using the given private key.
+func SignSource(key crypto.PrivKey, cfg []byte) ([]byte, error) {
+	sig, err := key.Sign(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(base64.StdEncoding.EncodeToString(sig)), nil
+}
+
+// VerifySource checks that sig is a valid base64-encoded signature of the
+// given configuration bytes by the given peer, whose ID must embed its
+// public key (as is the case for ed25519 keys).
+func VerifySource(signer peer.ID, cfg, sig []byte) error {
+	pubKey, err := signer.ExtractPublicKey()
+	if err != nil {
+		return fmt.Errorf("%w: cannot obtain the public key of %s: %s", errSourceSignature, signer, err)
+	}
+	rawSig, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(sig)))
+	if err != nil {
+		return fmt.Errorf("%w: %s", errSourceSignature, err)
+	}
+	ok, err := pubKey.Verify(cfg, rawSig)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errSourceSignature, err)
+	}
+	if !ok {
+		return fmt.Errorf("%w: not signed by %s", errSourceSignature, signer)
+	}
+	return nil
+}
+
+func fetchURL(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errFetchingSource, url)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("unsuccessful request (%d): %s", resp.StatusCode, body)
+	}
+	return body, nil
+}
+
+// FetchSource downloads the configuration from the Source URL and, when
+// SourceSigner is set, verifies its signature.
+func (cfg *Manager) FetchSource() ([]byte, error) {
+	body, err := fetchURL(cfg.Source)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.SourceSigner == "" {
+		return body, nil
+	}
+
+	sig, err := fetchURL(cfg.Source + SourceSignatureSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("%w: error fetching the signature: %s", errSourceSignature, err)
+	}
+	if err := VerifySource(cfg.SourceSigner, body, sig); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// SourceJSON returns the configuration as it was obtained from the Source
+// URL, if any.
+func (cfg *Manager) SourceJSON() []byte {
+	return cfg.sourceJSON
+}
+
+// loadSourceOptions reads the options which accompany the source in a local
+// configuration file.
+func (cfg *Manager) loadSourceOptions(jcfg *jsonConfig) error {
+	cfg.SourceSigner = ""
+	if jcfg.SourceSigner != "" {
+		signer, err := peer.Decode(jcfg.SourceSigner)
+		if err != nil {
+			return fmt.Errorf("error parsing source_signer: %s", err)
+		}
+		cfg.SourceSigner = signer
+	}
+
+	cfg.SourceRefreshInterval = 0
+	if jcfg.SourceRefreshInterval != "" {
+		interval, err := time.ParseDuration(jcfg.SourceRefreshInterval)
+		if err != nil {
+			return fmt.Errorf("error parsing source_refresh_interval: %s", err)
+		}
+		if interval < 0 {
+			return errors.New("source_refresh_interval cannot be negative")
+		}
+		cfg.SourceRefreshInterval = interval
+	}
+	return nil
+}
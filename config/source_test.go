@@ -0,0 +1,89 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoadFromSignedHTTPSource(t *testing.T) {
+	signer, err := NewIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig, err := SignSource(signer.PrivateKey, mockJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(mockJSON)
+	})
+	mux.HandleFunc("/config"+SourceSignatureSuffix, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(sig)
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	local := []byte(fmt.Sprintf(`{
+  "source": "%s/config",
+  "source_signer": "%s",
+  "source_refresh_interval": "1m0s"
+}`, s.URL, signer.ID))
+
+	cfgMgr := setupConfigManager()
+	if err := cfgMgr.LoadJSON(local); err != nil {
+		t.Fatal(err)
+	}
+	if cfgMgr.SourceSigner != signer.ID || cfgMgr.SourceRefreshInterval != time.Minute {
+		t.Error("source options not loaded")
+	}
+	if !bytes.Equal(cfgMgr.SourceJSON(), mockJSON) {
+		t.Error("expected the source configuration to be kept")
+	}
+
+	newJSON, err := cfgMgr.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(newJSON, local) {
+		t.Errorf("expected the source options to be saved. Got: %s", newJSON)
+	}
+
+	cfgMgr = setupConfigManager()
+	cfgMgr.SourceSigner = other.ID
+	err = cfgMgr.LoadJSONFromHTTPSource(s.URL + "/config")
+	if !IsErrSourceSignature(err) {
+		t.Error("expected a signature error with a different signer:", err)
+	}
+}
+
+func TestVerifySource(t *testing.T) {
+	ident, err := NewIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := SignSource(ident.PrivateKey, mockJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := VerifySource(ident.ID, mockJSON, append(sig, '\n')); err != nil {
+		t.Error(err)
+	}
+	if err := VerifySource(ident.ID, []byte("{}"), sig); !IsErrSourceSignature(err) {
+		t.Error("expected an error with modified content:", err)
+	}
+	if err := VerifySource(ident.ID, mockJSON, []byte("not base64")); !IsErrSourceSignature(err) {
+		t.Error("expected an error with a bad signature:", err)
+	}
+}
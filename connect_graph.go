@@ -1,10 +1,14 @@
 package ipfscluster
 
 import (
+	"context"
+
 	"github.com/ipfs/ipfs-cluster/api"
 	"github.com/ipfs/ipfs-cluster/rpcutil"
 
+	network "github.com/libp2p/go-libp2p-core/network"
 	peer "github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
 
 	"go.opencensus.io/trace"
 )
@@ -16,12 +20,13 @@ func (c *Cluster) ConnectGraph() (api.ConnectGraph, error) {
 	defer span.End()
 
 	cg := api.ConnectGraph{
-		ClusterID:         c.host.ID(),
-		IDtoPeername:      make(map[string]string),
-		IPFSLinks:         make(map[string][]peer.ID),
-		ClusterLinks:      make(map[string][]peer.ID),
-		ClusterTrustLinks: make(map[string]bool),
-		ClustertoIPFS:     make(map[string]peer.ID),
+		ClusterID:          c.host.ID(),
+		IDtoPeername:       make(map[string]string),
+		IPFSLinks:          make(map[string][]peer.ID),
+		ClusterLinks:       make(map[string][]peer.ID),
+		ClusterTrustLinks:  make(map[string]bool),
+		ClustertoIPFS:      make(map[string]peer.ID),
+		ClusterConnections: make(map[string][]*api.ConnectionInfo),
 	}
 	members, err := c.consensus.Peers(ctx)
 	if err != nil {
@@ -47,6 +52,25 @@ func (c *Cluster) ConnectGraph() (api.ConnectGraph, error) {
 		rpcutil.CopyIDSliceToIfaces(peers),
 	)
 
+	conns := make([][]*api.ConnectionInfo, len(members))
+	connCtxs, connCancels := rpcutil.CtxsWithCancel(ctx, len(members))
+	defer rpcutil.MultiCancel(connCancels)
+
+	connErrs := c.rpcClient.MultiCall(
+		connCtxs,
+		members,
+		"Cluster",
+		"Connections",
+		struct{}{},
+		rpcutil.CopyConnectionInfoSliceToIfaces(conns),
+	)
+	for i, err := range connErrs {
+		// Peers running older versions do not provide this.
+		if err == nil {
+			cg.ClusterConnections[peer.Encode(members[i])] = conns[i]
+		}
+	}
+
 	for i, err := range errs {
 		p := peer.Encode(members[i])
 		cg.ClusterLinks[p] = make([]peer.ID, 0)
@@ -114,3 +138,69 @@ func (c *Cluster) recordIPFSLinks(cg *api.ConnectGraph, pID *api.ID) {
 	}
 	cg.IPFSLinks[ipfsPid] = swarmPeers
 }
+
+// Connections returns information about the connections of this peer to the
+// other cluster peers.
+func (c *Cluster) Connections(ctx context.Context) ([]*api.ConnectionInfo, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/Connections")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	members, err := c.consensus.Peers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]*api.ConnectionInfo, 0, len(members))
+	for _, p := range members {
+		if p == c.id {
+			continue
+		}
+		conns := c.host.Network().ConnsToPeer(p)
+		if len(conns) == 0 {
+			continue
+		}
+		conn := conns[0]
+		info := &api.ConnectionInfo{
+			Peer:      p,
+			Transport: transportName(conn.RemoteMultiaddr()),
+			Latency:   c.host.Peerstore().LatencyEWMA(p),
+		}
+		switch conn.Stat().Direction {
+		case network.DirInbound:
+			info.Direction = api.ConnectionInbound
+		case network.DirOutbound:
+			info.Direction = api.ConnectionOutbound
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// transportName returns a short name for the transport used by a connection
+// to the given address.
+func transportName(addr ma.Multiaddr) string {
+	if addr == nil {
+		return ""
+	}
+	name := ""
+	for _, p := range addr.Protocols() {
+		switch p.Code {
+		case ma.P_CIRCUIT:
+			return "relay"
+		case ma.P_QUIC:
+			name = "quic"
+		case ma.P_WS, ma.P_WSS:
+			name = "ws"
+		case ma.P_TCP:
+			if name == "" {
+				name = "tcp"
+			}
+		case ma.P_UDP:
+			if name == "" {
+				name = "udp"
+			}
+		}
+	}
+	return name
+}
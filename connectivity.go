@@ -0,0 +1,224 @@
+package ipfscluster
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+
+	"go.opencensus.io/trace"
+)
+
+// connectivityHistory keeps the latest connectivity snapshots taken by this
+// peer, in memory, oldest first.
+type connectivityHistory struct {
+	mu        sync.Mutex
+	max       int
+	snapshots []*api.ConnectivitySnapshot
+}
+
+func newConnectivityHistory(max int) *connectivityHistory {
+	return &connectivityHistory{
+		max: max,
+	}
+}
+
+// add records a snapshot. When it shows the same connectivity as the last
+// one, the last one is extended instead.
+func (ch *connectivityHistory) add(snap *api.ConnectivitySnapshot) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	if n := len(ch.snapshots); n > 0 && sameConnectivity(ch.snapshots[n-1], snap) {
+		ch.snapshots[n-1].To = snap.To
+		return
+	}
+	ch.snapshots = append(ch.snapshots, snap)
+	if extra := len(ch.snapshots) - ch.max; extra > 0 {
+		ch.snapshots = append(ch.snapshots[:0:0], ch.snapshots[extra:]...)
+	}
+}
+
+// list returns copies of the snapshots which ended after the given time, or
+// all of them when it is zero.
+func (ch *connectivityHistory) list(since time.Time) []*api.ConnectivitySnapshot {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	snaps := make([]*api.ConnectivitySnapshot, 0, len(ch.snapshots))
+	for _, s := range ch.snapshots {
+		if !since.IsZero() && s.To.Before(since) {
+			continue
+		}
+		sCopy := *s
+		snaps = append(snaps, &sCopy)
+	}
+	return snaps
+}
+
+func sameConnectivity(a, b *api.ConnectivitySnapshot) bool {
+	if !samePeers(a.Peers, b.Peers) || !samePeers(a.Unreachable, b.Unreachable) {
+		return false
+	}
+	if len(a.ClusterLinks) != len(b.ClusterLinks) {
+		return false
+	}
+	for k, links := range a.ClusterLinks {
+		other, ok := b.ClusterLinks[k]
+		if !ok || !samePeers(links, other) {
+			return false
+		}
+	}
+	return true
+}
+
+// samePeers compares two sorted peer lists.
+func samePeers(a, b []peer.ID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sortPeers(peers []peer.ID) {
+	sort.Slice(peers, func(i, j int) bool { return peers[i] < peers[j] })
+}
+
+// countPartitions returns the number of groups of connected peers, taking
+// the links as bidirectional.
+func countPartitions(peers []peer.ID, links map[string][]peer.ID) int {
+	if len(peers) == 0 {
+		return 0
+	}
+
+	neighbours := make(map[peer.ID][]peer.ID)
+	for k, ls := range links {
+		from, err := peer.Decode(k)
+		if err != nil {
+			continue
+		}
+		for _, to := range ls {
+			neighbours[from] = append(neighbours[from], to)
+			neighbours[to] = append(neighbours[to], from)
+		}
+	}
+
+	inSet := make(map[peer.ID]bool, len(peers))
+	for _, p := range peers {
+		inSet[p] = true
+	}
+
+	visited := make(map[peer.ID]bool, len(peers))
+	partitions := 0
+	for _, p := range peers {
+		if visited[p] {
+			continue
+		}
+		partitions++
+		queue := []peer.ID{p}
+		visited[p] = true
+		for len(queue) > 0 {
+			cur := queue[0]
+			queue = queue[1:]
+			for _, n := range neighbours[cur] {
+				if inSet[n] && !visited[n] {
+					visited[n] = true
+					queue = append(queue, n)
+				}
+			}
+		}
+	}
+	return partitions
+}
+
+// watchConnectivity takes connectivity snapshots regularly.
+func (c *Cluster) watchConnectivity() {
+	ticker := time.NewTicker(c.config.ConnectivitySnapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			snap, err := c.connectivitySnapshot(c.ctx)
+			if err != nil {
+				logger.Warnf("error taking connectivity snapshot: %s", err)
+				continue
+			}
+			if snap.Partitions > 1 || len(snap.Unreachable) > 0 {
+				logger.Warnf("connectivity: %d partitions, %d unreachable peers", snap.Partitions, len(snap.Unreachable))
+			}
+			c.connHistory.add(snap)
+		}
+	}
+}
+
+// connectivitySnapshot asks every cluster peer which other cluster peers it
+// is connected to.
+func (c *Cluster) connectivitySnapshot(ctx context.Context) (*api.ConnectivitySnapshot, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/connectivitySnapshot")
+	defer span.End()
+
+	members, err := c.consensus.Peers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	snap := &api.ConnectivitySnapshot{
+		From:         now,
+		To:           now,
+		Peers:        members,
+		Unreachable:  []peer.ID{},
+		ClusterLinks: make(map[string][]peer.ID),
+	}
+	sortPeers(snap.Peers)
+
+	var reachable []peer.ID
+	results := c.broadcast(
+		ctx,
+		members,
+		"Cluster",
+		"Connections",
+		struct{}{},
+		func() interface{} { return &[]*api.ConnectionInfo{} },
+	)
+	for res := range results {
+		if res.Err != nil {
+			logger.Debugf("error getting connections from %s: %s", res.Peer, res.Err)
+			snap.Unreachable = append(snap.Unreachable, res.Peer)
+			continue
+		}
+		reachable = append(reachable, res.Peer)
+		conns := *res.Reply.(*[]*api.ConnectionInfo)
+		links := make([]peer.ID, 0, len(conns))
+		for _, conn := range conns {
+			links = append(links, conn.Peer)
+		}
+		sortPeers(links)
+		snap.ClusterLinks[peer.Encode(res.Peer)] = links
+	}
+	sortPeers(snap.Unreachable)
+	snap.Partitions = countPartitions(reachable, snap.ClusterLinks)
+	return snap, nil
+}
+
+// ConnectivityHistory returns the connectivity snapshots taken by this peer
+// since the given time, oldest first. A zero time returns all the snapshots.
+// Nothing is recorded unless connectivity_snapshot_interval is set.
+func (c *Cluster) ConnectivityHistory(ctx context.Context, since time.Time) []*api.ConnectivitySnapshot {
+	_, span := trace.StartSpan(ctx, "cluster/ConnectivityHistory")
+	defer span.End()
+
+	return c.connHistory.list(since)
+}
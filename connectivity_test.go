@@ -0,0 +1,96 @@
+package ipfscluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+func TestCountPartitions(t *testing.T) {
+	peers := []peer.ID{test.PeerID1, test.PeerID2, test.PeerID3}
+	links := map[string][]peer.ID{
+		peer.Encode(test.PeerID1): {test.PeerID2},
+		peer.Encode(test.PeerID3): {},
+	}
+	if n := countPartitions(peers, links); n != 2 {
+		t.Errorf("expected 2 partitions but got %d", n)
+	}
+
+	// links are taken as bidirectional
+	links[peer.Encode(test.PeerID3)] = []peer.ID{test.PeerID2}
+	if n := countPartitions(peers, links); n != 1 {
+		t.Errorf("expected 1 partition but got %d", n)
+	}
+
+	if n := countPartitions(nil, links); n != 0 {
+		t.Errorf("expected no partitions but got %d", n)
+	}
+}
+
+func TestConnectivityHistory(t *testing.T) {
+	ch := newConnectivityHistory(2)
+	start := time.Now()
+	snapshot := func(i int, unreachable ...peer.ID) *api.ConnectivitySnapshot {
+		ts := start.Add(time.Duration(i) * time.Minute)
+		return &api.ConnectivitySnapshot{
+			From:        ts,
+			To:          ts,
+			Peers:       []peer.ID{test.PeerID1, test.PeerID2},
+			Unreachable: unreachable,
+			ClusterLinks: map[string][]peer.ID{
+				peer.Encode(test.PeerID1): {test.PeerID2},
+			},
+			Partitions: 1,
+		}
+	}
+
+	ch.add(snapshot(0))
+	ch.add(snapshot(1))
+	snaps := ch.list(time.Time{})
+	if len(snaps) != 1 {
+		t.Fatal("equal snapshots should be merged")
+	}
+	if !snaps[0].From.Equal(start) || !snaps[0].To.Equal(start.Add(time.Minute)) {
+		t.Errorf("unexpected merged snapshot: %+v", snaps[0])
+	}
+
+	ch.add(snapshot(2, test.PeerID2))
+	ch.add(snapshot(3))
+	snaps = ch.list(time.Time{})
+	if len(snaps) != 2 {
+		t.Fatal("expected the oldest snapshot to be dropped")
+	}
+	if len(snaps[0].Unreachable) != 1 || len(snaps[1].Unreachable) != 0 {
+		t.Error("unexpected snapshots")
+	}
+
+	snaps = ch.list(start.Add(150 * time.Second))
+	if len(snaps) != 1 || !snaps[0].From.Equal(start.Add(3*time.Minute)) {
+		t.Error("expected only the last snapshot")
+	}
+}
+
+func TestClusterConnectivitySnapshot(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	snap, err := cl.connectivitySnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snap.Peers) != 1 || len(snap.Unreachable) != 0 || snap.Partitions != 1 {
+		t.Errorf("unexpected snapshot: %+v", snap)
+	}
+
+	cl.connHistory.add(snap)
+	if len(cl.ConnectivityHistory(ctx, time.Time{})) != 1 {
+		t.Error("expected one snapshot in the history")
+	}
+}
@@ -2,10 +2,12 @@ package crdt
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ipfs/go-cid"
@@ -36,6 +38,8 @@ var logger = logging.Logger("crdt")
 
 var (
 	blocksNs   = "b" // blockstore namespace
+	headsNs    = "h" // heads namespace, as used by go-ds-crdt
+	indexNs    = "i" // state index namespace
 	connMgrTag = "crdt"
 )
 
@@ -75,6 +79,13 @@ type Consensus struct {
 	crdt          *crdt.Datastore
 	ipfs          *ipfslite.Peer
 
+	// updated from the crdt hooks, which see local and remote changes.
+	index   *dsstate.Index
+	changes *dsstate.ChangeLog
+	// pins with unpin options, which the DeleteHook cannot read from
+	// the state anymore.
+	unpinOpts sync.Map
+
 	dht    routing.Routing
 	pubsub *pubsub.PubSub
 
@@ -83,6 +94,8 @@ type Consensus struct {
 	stateReady  chan struct{}
 	readyCh     chan struct{}
 	batchItemCh chan batchItem
+	// items in the batch being built. Accessed atomically.
+	batchSize int64
 
 	shutdownLock sync.RWMutex
 	shutdown     bool
@@ -134,6 +147,8 @@ func New(
 		store:       store,
 		ipfs:        ipfs,
 		namespace:   ns,
+		index:       dsstate.NewPersistentIndex(ctx, namespace.Wrap(store, ns.ChildString(indexNs))),
+		changes:     dsstate.NewChangeLog(),
 		pubsub:      pubsub,
 		rpcReady:    make(chan struct{}, 1),
 		readyCh:     make(chan struct{}, 1),
@@ -211,6 +226,9 @@ func (css *Consensus) setup() {
 			logger.Error(err)
 			return
 		}
+		css.index.Add(pin)
+		css.changes.Record(pin.Cid, false)
+		css.rememberUnpinOptions(pin)
 
 		// TODO: tracing for this context
 		err = css.rpcClient.CallContext(
@@ -241,7 +259,12 @@ func (css *Consensus) setup() {
 			return
 		}
 
+		css.index.Remove(c)
+		css.changes.Record(c, true)
 		pin := api.PinCid(c)
+		if v, ok := css.unpinOpts.LoadAndDelete(c); ok {
+			pin = v.(*api.Pin)
+		}
 
 		err = css.rpcClient.CallContext(
 			ctx,
@@ -283,6 +306,8 @@ func (css *Consensus) setup() {
 		logger.Errorf("error creating cluster state datastore: %s", err)
 		return
 	}
+	clusterState.SetIndex(css.index)
+	clusterState.SetChangeLog(css.changes)
 	css.state = clusterState
 
 	batchingState, err := dsstate.NewBatching(
@@ -309,6 +334,8 @@ func (css *Consensus) setup() {
 		go css.batchWorker()
 	}
 
+	go css.loadUnpinOptions()
+
 	// notifies State() it is safe to return
 	close(css.stateReady)
 	css.readyCh <- struct{}{}
@@ -335,6 +362,11 @@ func (css *Consensus) Shutdown(ctx context.Context) error {
 		crdt.Close()
 	}
 
+	// After closing crdt, so that no more changes are received.
+	if err := css.index.Close(ctx); err != nil {
+		logger.Errorf("error saving the state index: %s", err)
+	}
+
 	if css.config.hostShutdown {
 		css.host.Close()
 	}
@@ -479,6 +511,7 @@ func (css *Consensus) batchWorker() {
 			}
 
 			batchCurSize++
+			atomic.StoreInt64(&css.batchSize, int64(batchCurSize))
 
 			if batchCurSize < maxSize {
 				continue
@@ -496,6 +529,7 @@ func (css *Consensus) batchWorker() {
 				<-batchTimer.C
 			}
 			batchCurSize = 0
+			atomic.StoreInt64(&css.batchSize, 0)
 
 		case <-batchTimer.C:
 			// Commit
@@ -507,6 +541,7 @@ func (css *Consensus) batchWorker() {
 			// timer is expired at this point, it will have to be
 			// reset.
 			batchCurSize = 0
+			atomic.StoreInt64(&css.batchSize, 0)
 		}
 	}
 }
@@ -581,6 +616,43 @@ func (css *Consensus) State(ctx context.Context) (state.ReadOnly, error) {
 	}
 }
 
+// Stats returns the heads of the CRDT DAG of this peer and the updates
+// waiting to be broadcasted. The heads are read from the datastore, where
+// go-ds-crdt keeps them along with their height.
+func (css *Consensus) Stats(ctx context.Context) (*api.ConsensusStats, error) {
+	ctx, span := trace.StartSpan(ctx, "consensus/Stats")
+	defer span.End()
+
+	results, err := css.store.Query(ctx, query.Query{
+		Prefix: css.namespace.ChildString(headsNs).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	stats := &api.CRDTStats{
+		QueuedUpdates: len(css.batchItemCh) + int(atomic.LoadInt64(&css.batchSize)),
+	}
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		height, n := binary.Uvarint(r.Value)
+		if n <= 0 {
+			return nil, fmt.Errorf("error decoding the height of head %s", r.Key)
+		}
+		stats.Heads++
+		if height > stats.Height {
+			stats.Height = height
+		}
+	}
+	return &api.ConsensusStats{
+		Peer: css.host.ID(),
+		CRDT: stats,
+	}, nil
+}
+
 // Clean deletes all crdt-consensus datas from the datastore.
 func (css *Consensus) Clean(ctx context.Context) error {
 	return Clean(ctx, css.config, css.store)
@@ -630,6 +702,16 @@ func OfflineState(cfg *Config, store ds.Datastore) (state.BatchingState, error)
 	opts := crdt.DefaultOptions()
 	opts.Logger = logger
 
+	// The offline state is not indexed. Discard the saved index, which
+	// would miss the changes made to it.
+	err := dsstate.DiscardPersistentIndex(
+		context.Background(),
+		namespace.Wrap(batching, ds.NewKey(cfg.DatastoreNamespace).ChildString(indexNs)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
 	var blocksDatastore ds.Batching = namespace.Wrap(
 		batching,
 		ds.NewKey(cfg.DatastoreNamespace).ChildString(blocksNs),
@@ -661,3 +743,27 @@ func OfflineState(cfg *Config, store ds.Datastore) (state.BatchingState, error)
 	}
 	return dsstate.NewBatching(crdt, "", dsstate.DefaultHandle())
 }
+
+// rememberUnpinOptions keeps the pins which set unpin timeouts, so that
+// they can be untracked with them once removed from the state.
+func (css *Consensus) rememberUnpinOptions(pin *api.Pin) {
+	_, unpinTimeout, profile, _ := pin.Timeouts()
+	if unpinTimeout > 0 || profile != "" {
+		css.unpinOpts.Store(pin.Cid, pin)
+		return
+	}
+	css.unpinOpts.Delete(pin.Cid)
+}
+
+// loadUnpinOptions remembers the unpin options of the pins which were in
+// the state before starting, as the PutHook only sees new changes.
+func (css *Consensus) loadUnpinOptions() {
+	pins, err := css.state.List(css.ctx)
+	if err != nil {
+		logger.Errorf("error loading the unpin options of the pins: %s", err)
+		return
+	}
+	for _, pin := range pins {
+		css.rememberUnpinOptions(pin)
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/ipfs/ipfs-cluster/api"
 	"github.com/ipfs/ipfs-cluster/datastore/inmem"
+	"github.com/ipfs/ipfs-cluster/state"
 	"github.com/ipfs/ipfs-cluster/test"
 
 	cid "github.com/ipfs/go-cid"
@@ -134,6 +135,50 @@ func TestConsensusPin(t *testing.T) {
 	}
 }
 
+func TestConsensusPinsByName(t *testing.T) {
+	ctx := context.Background()
+	cc := testingConsensus(t, 1)
+	defer clean(t, cc)
+	defer cc.Shutdown(ctx)
+
+	st, err := cc.State(ctx)
+	if err != nil {
+		t.Fatal("error getting state:", err)
+	}
+	nl, ok := st.(state.NameLister)
+	if !ok {
+		t.Fatal("the state should support listing by name")
+	}
+	pins, err := nl.ListByName(ctx, "abc", false) // builds the index
+	if err != nil || len(pins) != 0 {
+		t.Fatal("expected no pins")
+	}
+
+	pin := testPin(test.Cid1)
+	pin.Name = "abc"
+	err = cc.LogPin(ctx, pin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(250 * time.Millisecond)
+
+	pins, _ = nl.ListByName(ctx, "ab", true)
+	if len(pins) != 1 || !pins[0].Cid.Equals(test.Cid1) {
+		t.Error("expected the named pin")
+	}
+
+	err = cc.LogUnpin(ctx, pin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(250 * time.Millisecond)
+
+	pins, _ = nl.ListByName(ctx, "abc", false)
+	if len(pins) != 0 {
+		t.Error("the unpinned pin should not be found")
+	}
+}
+
 func TestConsensusUnpin(t *testing.T) {
 	ctx := context.Background()
 	cc := testingConsensus(t, 1)
@@ -151,6 +196,38 @@ func TestConsensusUnpin(t *testing.T) {
 	}
 }
 
+func TestConsensusUnpinOptions(t *testing.T) {
+	ctx := context.Background()
+	cc := testingConsensus(t, 1)
+	defer clean(t, cc)
+	defer cc.Shutdown(ctx)
+
+	pin := testPin(test.Cid1)
+	pin.UnpinTimeout = time.Minute
+	if err := cc.LogPin(ctx, pin); err != nil {
+		t.Fatal(err)
+	}
+	if err := cc.LogPin(ctx, testPin(test.Cid2)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(250 * time.Millisecond)
+
+	if _, ok := cc.unpinOpts.Load(test.Cid1); !ok {
+		t.Error("the unpin options of the pin should be kept")
+	}
+	if _, ok := cc.unpinOpts.Load(test.Cid2); ok {
+		t.Error("pins without unpin options should not be kept")
+	}
+
+	if err := cc.LogUnpin(ctx, api.PinCid(test.Cid1)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(250 * time.Millisecond)
+	if _, ok := cc.unpinOpts.Load(test.Cid1); ok {
+		t.Error("the unpin options should be dropped once untracked")
+	}
+}
+
 func TestConsensusUpdate(t *testing.T) {
 	ctx := context.Background()
 	cc := testingConsensus(t, 1)
@@ -353,6 +430,53 @@ func TestOfflineState(t *testing.T) {
 	}
 }
 
+func TestConsensusStats(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{}
+	cfg.Default()
+	cfg.Batching.MaxBatchSize = 3
+	cfg.Batching.MaxBatchAge = time.Second
+
+	cc := testingConsensusWithCfg(t, 1, cfg)
+	defer clean(t, cc)
+	defer cc.Shutdown(ctx)
+
+	if _, err := cc.State(ctx); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := cc.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Peer != cc.host.ID() || stats.Raft != nil || stats.CRDT == nil {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if stats.CRDT.Heads != 0 || stats.CRDT.Height != 0 {
+		t.Errorf("expected an empty DAG: %+v", stats.CRDT)
+	}
+
+	for _, c := range []cid.Cid{test.Cid1, test.Cid2} {
+		if err := cc.LogPin(ctx, testPin(c)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(250 * time.Millisecond)
+	stats, _ = cc.Stats(ctx)
+	if stats.CRDT.QueuedUpdates != 2 || stats.CRDT.Heads != 0 {
+		t.Errorf("expected the pins to be batched: %+v", stats.CRDT)
+	}
+
+	// Commit by size
+	if err := cc.LogPin(ctx, testPin(test.Cid3)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(250 * time.Millisecond)
+	stats, _ = cc.Stats(ctx)
+	if stats.CRDT.QueuedUpdates != 0 || stats.CRDT.Heads != 1 || stats.CRDT.Height != 1 {
+		t.Errorf("expected the batch to be a new head: %+v", stats.CRDT)
+	}
+}
+
 func TestBatching(t *testing.T) {
 	ctx := context.Background()
 	cfg := &Config{}
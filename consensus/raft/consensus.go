@@ -351,9 +351,9 @@ func (cc *Consensus) commit(ctx context.Context, op *LogOp, rpcOp string, redire
 
 		switch op.Type {
 		case LogOpPin:
-			logger.Infof("pin committed to global state: %s", op.Cid.Cid)
+			api.RequestLogger(ctx, logger).Infof("pin committed to global state: %s", op.Cid.Cid)
 		case LogOpUnpin:
-			logger.Infof("unpin committed to global state: %s", op.Cid.Cid)
+			api.RequestLogger(ctx, logger).Infof("unpin committed to global state: %s", op.Cid.Cid)
 		}
 		break
 
@@ -369,6 +369,7 @@ func (cc *Consensus) LogPin(ctx context.Context, pin *api.Pin) error {
 	ctx, span := trace.StartSpan(ctx, "consensus/LogPin")
 	defer span.End()
 
+	pin = api.PinWithRequestID(ctx, pin)
 	op := cc.op(ctx, pin, LogOpPin)
 	err := cc.commit(ctx, op, "LogPin", pin)
 	if err != nil {
@@ -382,6 +383,7 @@ func (cc *Consensus) LogUnpin(ctx context.Context, pin *api.Pin) error {
 	ctx, span := trace.StartSpan(ctx, "consensus/LogUnpin")
 	defer span.End()
 
+	pin = api.PinWithRequestID(ctx, pin)
 	op := cc.op(ctx, pin, LogOpUnpin)
 	err := cc.commit(ctx, op, "LogUnpin", pin)
 	if err != nil {
@@ -540,6 +542,23 @@ func (cc *Consensus) Peers(ctx context.Context) ([]peer.ID, error) {
 	return peers, nil
 }
 
+// Stats returns the Raft internals of this peer.
+func (cc *Consensus) Stats(ctx context.Context) (*api.ConsensusStats, error) {
+	ctx, span := trace.StartSpan(ctx, "consensus/Stats")
+	defer span.End()
+
+	cc.shutdownLock.RLock()
+	defer cc.shutdownLock.RUnlock()
+
+	if cc.shutdown {
+		return nil, errors.New("consensus is shutdown")
+	}
+	return &api.ConsensusStats{
+		Peer: cc.host.ID(),
+		Raft: cc.raft.Stats(ctx),
+	}, nil
+}
+
 // OfflineState state returns a cluster state by reading the Raft data and
 // writing it to the given datastore which is then wrapped as a state.State.
 // Usually an in-memory datastore suffices. The given datastore should be
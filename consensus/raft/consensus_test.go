@@ -326,3 +326,31 @@ func TestRaftLatestSnapshot(t *testing.T) {
 		t.Fatal("Latest snapshot not read")
 	}
 }
+
+func TestConsensusStats(t *testing.T) {
+	ctx := context.Background()
+	cc := testingConsensus(t, 1)
+	defer cleanRaft(1)
+	defer cc.Shutdown(ctx)
+
+	err := cc.LogPin(ctx, testPin(test.Cid1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(250 * time.Millisecond)
+
+	stats, err := cc.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Peer != cc.host.ID() || stats.CRDT != nil || stats.Raft == nil {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	r := stats.Raft
+	if r.State != "Leader" || r.Leader != cc.host.ID() || r.Term == 0 {
+		t.Errorf("expected to be the leader: %+v", r)
+	}
+	if r.LastIndex == 0 || r.AppliedIndex != r.LastIndex || r.CommitIndex != r.LastIndex {
+		t.Errorf("expected the pin to be applied: %+v", r)
+	}
+}
@@ -56,6 +56,7 @@ func (op *LogOp) ApplyTo(cstate consensus.State) (consensus.State, error) {
 	}
 
 	pin := op.Cid
+	ctx = api.ContextWithPinRequestID(ctx, pin)
 	// We are about to pass "pin" it to go-routines that will make things
 	// with it (read its fields). However, as soon as ApplyTo is done, the
 	// next operation will be deserealized on top of "op". We nullify it
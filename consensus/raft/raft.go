@@ -7,8 +7,10 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 
+	"github.com/ipfs/ipfs-cluster/api"
 	"github.com/ipfs/ipfs-cluster/state"
 
 	host "github.com/libp2p/go-libp2p-core/host"
@@ -508,6 +510,28 @@ func (rw *raftWrapper) Leader(ctx context.Context) string {
 	return string(rw.raft.Leader())
 }
 
+// Stats returns the state of this Raft peer and its log indexes.
+func (rw *raftWrapper) Stats(ctx context.Context) *api.RaftStats {
+	_, span := trace.StartSpan(ctx, "consensus/raft/Stats")
+	defer span.End()
+
+	stats := rw.raft.Stats()
+	parse := func(key string) uint64 {
+		n, _ := strconv.ParseUint(stats[key], 10, 64)
+		return n
+	}
+	leader, _ := peer.Decode(rw.Leader(ctx))
+	return &api.RaftStats{
+		State:        stats["state"],
+		Leader:       leader,
+		Term:         parse("term"),
+		LastIndex:    parse("last_log_index"),
+		CommitIndex:  parse("commit_index"),
+		AppliedIndex: parse("applied_index"),
+		FSMPending:   parse("fsm_pending"),
+	}
+}
+
 func (rw *raftWrapper) Peers(ctx context.Context) ([]string, error) {
 	_, span := trace.StartSpan(ctx, "consensus/raft/Peers")
 	defer span.End()
@@ -0,0 +1,81 @@
+package ipfscluster
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/rpcutil"
+
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+	"go.opencensus.io/trace"
+)
+
+// ConsensusStats returns the internals of the consensus component of every
+// peer in the peerset, along with how far behind the most up-to-date peer
+// each of them is. Peers which see different pinsets because they have not
+// synced are the ones with a sync lag.
+func (c *Cluster) ConsensusStats(ctx context.Context) ([]*api.ConsensusStats, error) {
+	_, span := trace.StartSpan(ctx, "cluster/ConsensusStats")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	members, err := c.consensus.Peers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]*api.ConsensusStats, len(members))
+	ctxs, cancels := rpcutil.CtxsWithTimeout(ctx, len(members), 15*time.Second)
+	defer rpcutil.MultiCancel(cancels)
+
+	errs := c.rpcClient.MultiCall(
+		ctxs,
+		members,
+		"Consensus",
+		"Stats",
+		struct{}{},
+		rpcutil.CopyConsensusStatsToIfaces(stats),
+	)
+
+	finalStats := make([]*api.ConsensusStats, 0, len(members))
+	for i, err := range errs {
+		if rpc.IsAuthorizationError(err) {
+			continue
+		}
+		if err != nil {
+			stats[i] = &api.ConsensusStats{Error: err.Error()}
+		}
+		stats[i].Peer = members[i]
+		finalStats = append(finalStats, stats[i])
+	}
+	setSyncLag(finalStats)
+	return finalStats, nil
+}
+
+// setSyncLag compares the peers with the one furthest ahead: the Raft peer
+// with the highest log index or the CRDT peer with the highest DAG.
+func setSyncLag(stats []*api.ConsensusStats) {
+	progress := func(s *api.ConsensusStats) (uint64, uint64, bool) {
+		switch {
+		case s.Raft != nil:
+			return s.Raft.LastIndex, s.Raft.AppliedIndex, true
+		case s.CRDT != nil:
+			return s.CRDT.Height, s.CRDT.Height, true
+		default:
+			return 0, 0, false
+		}
+	}
+
+	var ahead uint64
+	for _, s := range stats {
+		if known, _, ok := progress(s); ok && known > ahead {
+			ahead = known
+		}
+	}
+	for _, s := range stats {
+		if _, current, ok := progress(s); ok && current < ahead {
+			s.SyncLag = ahead - current
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package ipfscluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+)
+
+func TestClusterConsensusStats(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	if _, err := cl.Pin(ctx, test.Cid1, api.PinOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	stats, err := cl.ConsensusStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 || stats[0].Peer != cl.id || stats[0].Error != "" {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if stats[0].Raft == nil && stats[0].CRDT == nil {
+		t.Error("expected the internals of the consensus component")
+	}
+	if stats[0].SyncLag != 0 {
+		t.Error("a single peer cannot lag")
+	}
+}
+
+func TestSetSyncLag(t *testing.T) {
+	stats := []*api.ConsensusStats{
+		{CRDT: &api.CRDTStats{Height: 10}},
+		{CRDT: &api.CRDTStats{Height: 6}},
+		{Error: "unreachable"},
+	}
+	setSyncLag(stats)
+	if stats[0].SyncLag != 0 || stats[1].SyncLag != 4 || stats[2].SyncLag != 0 {
+		t.Error("unexpected CRDT sync lag")
+	}
+
+	stats = []*api.ConsensusStats{
+		{Raft: &api.RaftStats{LastIndex: 20, AppliedIndex: 18}},
+		{Raft: &api.RaftStats{LastIndex: 15, AppliedIndex: 15}},
+	}
+	setSyncLag(stats)
+	if stats[0].SyncLag != 2 || stats[1].SyncLag != 5 {
+		t.Error("unexpected Raft sync lag")
+	}
+}
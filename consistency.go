@@ -0,0 +1,212 @@
+package ipfscluster
+
+import (
+	"context"
+	"sort"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+
+	"go.opencensus.io/trace"
+)
+
+// Consistency checks compare what each peer should be doing according to
+// the shared state with what its pin tracker is doing and what its IPFS
+// daemon has pinned. They disagree for a while after every change, so
+// items with operations in progress are only reported when the operation
+// goes against the state. Divergence which lasts, for example after a
+// crash, is what they are meant to find.
+
+// consistencyOpStatuses are the statuses of the items with an operation
+// in the pin tracker.
+const consistencyOpStatuses = api.TrackerStatusQueued |
+	api.TrackerStatusPinning | api.TrackerStatusUnpinning |
+	api.TrackerStatusPinError | api.TrackerStatusUnpinError
+
+const consistencyPinOpStatuses = api.TrackerStatusPinQueued |
+	api.TrackerStatusPinning | api.TrackerStatusPinError
+
+// ConsistencyCheck asks every peer to check the consistency of the shared
+// state, its pin tracker and its IPFS daemon, and returns the report of
+// each peer. When repair is set, peers fix the issues they find.
+func (c *Cluster) ConsistencyCheck(ctx context.Context, repair bool) (*api.GlobalConsistencyReport, error) {
+	_, span := trace.StartSpan(ctx, "cluster/ConsistencyCheck")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	members, err := c.consensus.Peers(ctx)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	op := c.operations.start(ctx, api.OperationConsistencyCheck, len(members))
+	defer op.finish(nil)
+
+	results := c.broadcast(
+		op.ctx,
+		members,
+		"Cluster",
+		"ConsistencyCheckLocal",
+		repair,
+		func() interface{} { return &api.ConsistencyReport{} },
+	)
+
+	global := &api.GlobalConsistencyReport{
+		PeerMap: make(map[string]*api.ConsistencyReport),
+	}
+	for res := range results {
+		op.progress(1)
+		if res.Err == nil {
+			global.PeerMap[peer.Encode(res.Peer)] = res.Reply.(*api.ConsistencyReport)
+			continue
+		}
+
+		if rpc.IsAuthorizationError(res.Err) {
+			logger.Debug("rpc auth error:", res.Err)
+			continue
+		}
+
+		logger.Errorf("%s: error in broadcast response from %s: %s ", c.id, res.Peer, res.Err)
+		global.PeerMap[peer.Encode(res.Peer)] = &api.ConsistencyReport{
+			Peer:     res.Peer,
+			Peername: peer.Encode(res.Peer),
+			Issues:   []*api.ConsistencyIssue{},
+			Error:    res.Err.Error(),
+		}
+	}
+
+	if op.canceled() {
+		return nil, op.ctx.Err()
+	}
+	return global, nil
+}
+
+// ConsistencyCheckLocal cross-checks the shared state, the pin tracker and
+// the IPFS pins of this peer. When repair is set, items which should be
+// pinned are tracked again, stray pin operations and leftover pins are
+// untracked and stray unpin operations are replaced by pin operations.
+// Pins unknown to the cluster are only reported.
+func (c *Cluster) ConsistencyCheckLocal(ctx context.Context, repair bool) (*api.ConsistencyReport, error) {
+	_, span := trace.StartSpan(ctx, "cluster/ConsistencyCheckLocal")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pins, err := cState.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ipfsPins := make(map[string]api.IPFSPinStatus)
+	for _, typ := range []string{"recursive", "direct"} {
+		m, err := c.ipfs.PinLs(ctx, typ)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range m {
+			ipfsPins[k] = v
+		}
+	}
+
+	ops := make(map[cid.Cid]*api.PinInfo)
+	for _, pi := range c.tracker.StatusAll(ctx, consistencyOpStatuses) {
+		ops[pi.Cid] = pi
+	}
+
+	report := &api.ConsistencyReport{
+		Peer:     c.id,
+		Peername: c.config.Peername,
+		Issues:   []*api.ConsistencyIssue{},
+	}
+	log := api.RequestLogger(ctx, logger)
+	addIssue := func(ci cid.Cid, typ api.ConsistencyIssueType, status api.TrackerStatus, fix func() error) {
+		issue := &api.ConsistencyIssue{Cid: ci, Type: typ, Status: status}
+		log.Warnf("consistency check: %s: %s (%s)", ci, typ, status)
+		if repair && fix != nil {
+			if err := fix(); err != nil {
+				log.Errorf("consistency check: error repairing %s: %s", ci, err)
+			} else {
+				issue.Repaired = true
+			}
+		}
+		report.Issues = append(report.Issues, issue)
+	}
+
+	inState := make(map[string]*api.Pin, len(pins))
+	expected := make(map[cid.Cid]struct{})
+	for _, p := range pins {
+		inState[p.Cid.String()] = p
+		if p.Type == api.MetaType || p.IsRemotePin(c.id) {
+			continue
+		}
+		pin := p
+		op, hasOp := ops[p.Cid]
+		switch {
+		case hasOp && !op.Status.Match(consistencyPinOpStatuses):
+			addIssue(p.Cid, api.ConsistencyStrayOperation, op.Status, func() error {
+				return c.tracker.Track(ctx, pin)
+			})
+		case hasOp:
+		case !ipfsPins[p.Cid.String()].IsPinned(p.MaxDepth):
+			// The tracker may leave out pins allocated here,
+			// which it reports as remote, and waits to pin
+			// those scheduled for later.
+			status := c.tracker.Status(ctx, p.Cid).Status
+			if status == api.TrackerStatusRemote || status == api.TrackerStatusScheduled {
+				continue
+			}
+			addIssue(p.Cid, api.ConsistencyNotPinned, status, func() error {
+				return c.tracker.Track(ctx, pin)
+			})
+		}
+		expected[p.Cid] = struct{}{}
+	}
+	report.Checked = len(expected)
+
+	for ci, op := range ops {
+		ci := ci
+		if _, ok := expected[ci]; ok || !op.Status.Match(consistencyPinOpStatuses) {
+			continue
+		}
+		addIssue(ci, api.ConsistencyStrayOperation, op.Status, func() error {
+			return c.tracker.Untrack(ctx, api.PinCid(ci))
+		})
+	}
+
+	for k := range ipfsPins {
+		ci, err := cid.Decode(k)
+		if err != nil {
+			continue
+		}
+		if _, ok := expected[ci]; ok {
+			continue
+		}
+		if _, ok := ops[ci]; ok {
+			continue
+		}
+		pin, ok := inState[k]
+		if !ok {
+			addIssue(ci, api.ConsistencyUnknownPin, api.TrackerStatusUnpinned, nil)
+			continue
+		}
+		addIssue(ci, api.ConsistencyLeftoverPin, c.tracker.Status(ctx, ci).Status, func() error {
+			return c.tracker.Untrack(ctx, pin)
+		})
+	}
+
+	sort.Slice(report.Issues, func(i, j int) bool {
+		a, b := report.Issues[i], report.Issues[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Cid.String() < b.Cid.String()
+	})
+	return report, nil
+}
@@ -0,0 +1,85 @@
+package ipfscluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+func TestClusterConsistencyCheck(t *testing.T) {
+	ctx := context.Background()
+	cl, _, ipfs, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	_, err := cl.Pin(ctx, test.Cid1, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cl.Pin(ctx, test.Cid2, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote := api.PinWithOpts(test.Cid3, api.PinOptions{
+		ReplicationFactorMin: 1,
+		ReplicationFactorMax: 1,
+	})
+	remote.Allocations = []peer.ID{test.PeerID2}
+	if err := cl.logPin(ctx, remote); err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	gReport, err := cl.ConsistencyCheck(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, ok := gReport.PeerMap[peer.Encode(cl.id)]
+	if !ok {
+		t.Fatal("expected a report for the peer")
+	}
+	if report.Checked != 2 || len(report.Issues) != 0 {
+		t.Fatalf("expected no issues: %+v", report)
+	}
+
+	// Divergence between IPFS and the shared state.
+	ipfs.pins.Delete(test.Cid1.String())
+	ipfs.pins.Store(test.Cid3.String(), api.PinDepth(-1))
+	ipfs.pins.Store(test.Cid4.String(), api.PinDepth(-1))
+
+	report, err = cl.ConsistencyCheckLocal(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issues := make(map[api.ConsistencyIssueType]*api.ConsistencyIssue)
+	for _, issue := range report.Issues {
+		issues[issue.Type] = issue
+	}
+	if len(report.Issues) != 3 {
+		t.Fatalf("expected 3 issues: %+v", report.Issues)
+	}
+	if issue := issues[api.ConsistencyNotPinned]; issue == nil || !issue.Cid.Equals(test.Cid1) || !issue.Repaired {
+		t.Errorf("expected a repaired not_pinned issue for Cid1: %+v", issue)
+	}
+	if issue := issues[api.ConsistencyLeftoverPin]; issue == nil || !issue.Cid.Equals(test.Cid3) || !issue.Repaired {
+		t.Errorf("expected a repaired leftover_pin issue for Cid3: %+v", issue)
+	}
+	if issue := issues[api.ConsistencyUnknownPin]; issue == nil || !issue.Cid.Equals(test.Cid4) || issue.Repaired {
+		t.Errorf("expected an unrepaired unknown_pin issue for Cid4: %+v", issue)
+	}
+	pinDelay()
+
+	if _, ok := ipfs.pins.Load(test.Cid1.String()); !ok {
+		t.Error("Cid1 should have been pinned again")
+	}
+	if _, ok := ipfs.pins.Load(test.Cid3.String()); ok {
+		t.Error("Cid3 should have been unpinned")
+	}
+	if _, ok := ipfs.pins.Load(test.Cid4.String()); !ok {
+		t.Error("Cid4 should not be unpinned")
+	}
+}
@@ -0,0 +1,8 @@
+package ipfscluster
+
+import "time"
+
+// processCPUTime is not supported on Windows.
+func processCPUTime() time.Duration {
+	return 0
+}
@@ -0,0 +1,231 @@
+package ipfscluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/denylist"
+
+	ds "github.com/ipfs/go-datastore"
+	namespace "github.com/ipfs/go-datastore/namespace"
+
+	"go.opencensus.io/trace"
+)
+
+// The entries added with the API are kept in the datastore of each peer,
+// like pin templates, under this namespace. They are not shared with other
+// peers. Instead, every peer checks its own denylist before pinning anything
+// allocated to it (see the Cluster.DenylistCheck RPC used by the pin
+// tracker), so pins submitted through peers with a different denylist are
+// not pinned here either. Added blocks are checked before they are ingested.
+var (
+	denylistNamespace = ds.NewKey("/denylist")
+	denylistKey       = ds.NewKey("entries")
+)
+
+// denylistAPISource is the source of the entries added with the API.
+const denylistAPISource = "api"
+
+// loadDenylist builds the denylist from the configured files and the entries
+// stored in the datastore.
+func loadDenylist(ctx context.Context, cfg *Config, store ds.Datastore) (*denylist.Denylist, error) {
+	dl := denylist.New()
+	for _, path := range cfg.GetDenylistPaths() {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("error loading denylist: %w", err)
+		}
+		entries, skipped, err := denylist.Parse(f, path)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error loading denylist %s: %w", path, err)
+		}
+		if skipped > 0 {
+			logger.Warnf("denylist %s: skipped %d unsupported rules", path, skipped)
+		}
+		if err := dl.Set(path, entries); err != nil {
+			return nil, err
+		}
+		logger.Infof("loaded %d denylist entries from %s", len(entries), path)
+	}
+
+	entries, err := storedDenylistEntries(ctx, namespace.Wrap(store, denylistNamespace))
+	if err != nil {
+		return nil, err
+	}
+	return dl, dl.Set(denylistAPISource, entries)
+}
+
+func storedDenylistEntries(ctx context.Context, store ds.Datastore) ([]*api.DenylistEntry, error) {
+	b, err := store.Get(ctx, denylistKey)
+	if err == ds.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []*api.DenylistEntry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("error decoding the stored denylist entries: %w", err)
+	}
+	return entries, nil
+}
+
+func (c *Cluster) denylistStore() ds.Datastore {
+	return namespace.Wrap(c.datastore, denylistNamespace)
+}
+
+// setDenylistEntries replaces the entries added with the API.
+func (c *Cluster) setDenylistEntries(ctx context.Context, entries []*api.DenylistEntry) error {
+	if err := c.denylist.Set(denylistAPISource, entries); err != nil {
+		return err
+	}
+	b, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return c.denylistStore().Put(ctx, denylistKey, b)
+}
+
+// checkDenylist returns an error wrapping api.ErrDenylisted when the given
+// IPFS path, or CID, is denylisted.
+func (c *Cluster) checkDenylist(path string) error {
+	if e, denied := c.denylist.MatchPath(path); denied {
+		return fmt.Errorf("%w: %s matches %s", api.ErrDenylisted, path, e.Rule)
+	}
+	return nil
+}
+
+// DenylistEntries returns the entries of the denylist of this peer, both
+// from the configured files and added with DenylistAdd.
+func (c *Cluster) DenylistEntries(ctx context.Context) ([]*api.DenylistEntry, error) {
+	_, span := trace.StartSpan(ctx, "cluster/DenylistEntries")
+	defer span.End()
+
+	entries := c.denylist.Entries()
+	if entries == nil {
+		entries = []*api.DenylistEntry{}
+	}
+	return entries, nil
+}
+
+// DenylistAdd adds the given rules to the denylist of this peer. Rules
+// which are already in the denylist are ignored. When the unpin_matches
+// option is enabled, the pins matching the new rules are unpinned.
+func (c *Cluster) DenylistAdd(ctx context.Context, entries []*api.DenylistEntry) error {
+	_, span := trace.StartSpan(ctx, "cluster/DenylistAdd")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	for _, e := range entries {
+		if err := denylist.ValidateRule(e.Rule); err != nil {
+			return err
+		}
+	}
+
+	c.denylistMux.Lock()
+	current, err := storedDenylistEntries(ctx, c.denylistStore())
+	if err != nil {
+		c.denylistMux.Unlock()
+		return err
+	}
+	rules := make(map[string]struct{}, len(current))
+	for _, e := range current {
+		rules[e.Rule] = struct{}{}
+	}
+	for _, e := range entries {
+		if _, ok := rules[e.Rule]; ok {
+			continue
+		}
+		rules[e.Rule] = struct{}{}
+		current = append(current, &api.DenylistEntry{
+			Rule:   e.Rule,
+			Source: denylistAPISource,
+		})
+	}
+	err = c.setDenylistEntries(ctx, current)
+	c.denylistMux.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if c.config.Denylist.UnpinMatches {
+		if _, err := c.DenylistEnforce(ctx); err != nil {
+			logger.Errorf("error unpinning denylisted content: %s", err)
+		}
+	}
+	return nil
+}
+
+// DenylistRemove removes a rule added with DenylistAdd. Rules loaded from
+// files cannot be removed.
+func (c *Cluster) DenylistRemove(ctx context.Context, rule string) error {
+	_, span := trace.StartSpan(ctx, "cluster/DenylistRemove")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	c.denylistMux.Lock()
+	defer c.denylistMux.Unlock()
+
+	current, err := storedDenylistEntries(ctx, c.denylistStore())
+	if err != nil {
+		return err
+	}
+	kept := make([]*api.DenylistEntry, 0, len(current))
+	for _, e := range current {
+		if e.Rule != rule {
+			kept = append(kept, e)
+		}
+	}
+	if len(kept) == len(current) {
+		return api.ErrDenylistEntryNotFound
+	}
+	return c.setDenylistEntries(ctx, kept)
+}
+
+// DenylistEnforce unpins all the pins in the pinset matching the denylist
+// of this peer and returns them.
+func (c *Cluster) DenylistEnforce(ctx context.Context) ([]*api.Pin, error) {
+	_, span := trace.StartSpan(ctx, "cluster/DenylistEnforce")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	if c.config.FollowerMode {
+		return nil, errFollowerMode
+	}
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pins, err := cState.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	unpinned := make([]*api.Pin, 0)
+	for _, pin := range pins {
+		// Shards and ClusterDAGs are unpinned along with their meta
+		// pin.
+		if pin.Type != api.DataType && pin.Type != api.MetaType {
+			continue
+		}
+		e, denied := c.denylist.Match(pin.Cid, "")
+		if !denied {
+			continue
+		}
+		if c.checkUnprotected(pin) != nil {
+			logger.Warnf("not unpinning %s: matches denylist rule %s but is protected", pin.Cid, e.Rule)
+			continue
+		}
+		logger.Infof("unpinning %s: matches denylist rule %s", pin.Cid, e.Rule)
+		if _, err := c.Unpin(ctx, pin.Cid); err != nil {
+			return unpinned, err
+		}
+		unpinned = append(unpinned, pin)
+	}
+	return unpinned, nil
+}
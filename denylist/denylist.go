@@ -0,0 +1,317 @@
+// Package denylist implements matching of CIDs and IPFS paths against
+// content denylists.
+//
+// Denylists use the compact format of the lists published for IPFS gateways,
+// like the Bad Bits denylist: one rule per line, with an optional header
+// terminated by a "---" line and comments starting with "#". The supported
+// rules are:
+//
+//	/ipfs/<cid>           the CID and any path under it
+//	/ipfs/<cid>/<path>    the path and any path under it
+//	<cid>                 same as /ipfs/<cid>
+//	//<sha256 hex>        a double-hashed rule: the hex-encoded sha256 of
+//	                      "<cidv1 base32>/<path>", where the path may be empty
+//
+// Rules prefixed by "!" allow matching content instead, taking precedence
+// over any rules that deny it.
+package denylist
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+const (
+	allowPrefix      = "!"
+	doubleHashPrefix = "//"
+	ipfsPrefix       = "/ipfs/"
+	headerEnd        = "---"
+)
+
+type ruleKind int
+
+const (
+	cidRule ruleKind = iota
+	pathRule
+	hashRule
+)
+
+type rule struct {
+	kind  ruleKind
+	allow bool
+	key   string
+}
+
+// parseRule parses a denylist rule. CID and path rules are keyed by the
+// multihash of the CID, so that they match any CID version.
+func parseRule(r string) (rule, error) {
+	var rl rule
+	r = strings.TrimSpace(r)
+	if strings.HasPrefix(r, allowPrefix) {
+		rl.allow = true
+		r = strings.TrimPrefix(r, allowPrefix)
+	}
+
+	switch {
+	case strings.HasPrefix(r, doubleHashPrefix):
+		h := strings.ToLower(strings.TrimPrefix(r, doubleHashPrefix))
+		b, err := hex.DecodeString(h)
+		if err != nil || len(b) != sha256.Size {
+			return rl, fmt.Errorf("invalid double-hash rule %q: only hex-encoded sha256 hashes are supported", r)
+		}
+		rl.kind = hashRule
+		rl.key = h
+		return rl, nil
+	case strings.HasPrefix(r, "/") && !strings.HasPrefix(r, ipfsPrefix):
+		return rl, fmt.Errorf("unsupported rule %q: only /ipfs/ paths are supported", r)
+	}
+
+	p := strings.TrimPrefix(r, ipfsPrefix)
+	cidStr, path := splitPath(p)
+	c, err := cid.Decode(cidStr)
+	if err != nil {
+		return rl, fmt.Errorf("invalid rule %q: %w", r, err)
+	}
+
+	path = strings.TrimSuffix(path, "*")
+	path = strings.Trim(path, "/")
+	if path == "" {
+		rl.kind = cidRule
+		rl.key = string(c.Hash())
+		return rl, nil
+	}
+	rl.kind = pathRule
+	rl.key = string(c.Hash()) + "/" + path
+	return rl, nil
+}
+
+func splitPath(p string) (string, string) {
+	i := strings.Index(p, "/")
+	if i < 0 {
+		return p, ""
+	}
+	return p[:i], p[i+1:]
+}
+
+// ValidateRule returns an error when the given rule is not supported.
+func ValidateRule(r string) error {
+	_, err := parseRule(r)
+	return err
+}
+
+// Parse reads a denylist and returns its entries, with the given source. It
+// also returns the number of rules which were skipped because they are not
+// supported.
+func Parse(r io.Reader, source string) ([]*api.DenylistEntry, int, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == headerEnd {
+			// Everything before was the header.
+			lines = lines[:0]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	entries := make([]*api.DenylistEntry, 0, len(lines))
+	skipped := 0
+	for _, line := range lines {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := ValidateRule(line); err != nil {
+			skipped++
+			continue
+		}
+		entries = append(entries, &api.DenylistEntry{
+			Rule:   line,
+			Source: source,
+		})
+	}
+	return entries, skipped, nil
+}
+
+type index struct {
+	cids   map[string]*api.DenylistEntry
+	paths  map[string]*api.DenylistEntry
+	hashes map[string]*api.DenylistEntry
+}
+
+func newIndex() *index {
+	return &index{
+		cids:   make(map[string]*api.DenylistEntry),
+		paths:  make(map[string]*api.DenylistEntry),
+		hashes: make(map[string]*api.DenylistEntry),
+	}
+}
+
+func (idx *index) add(rl rule, e *api.DenylistEntry) {
+	switch rl.kind {
+	case cidRule:
+		idx.cids[rl.key] = e
+	case pathRule:
+		idx.paths[rl.key] = e
+	case hashRule:
+		idx.hashes[rl.key] = e
+	}
+}
+
+// match returns the entry matching the given CID and path, or any of the
+// parent paths.
+func (idx *index) match(c cid.Cid, path string) *api.DenylistEntry {
+	mh := string(c.Hash())
+	if e, ok := idx.cids[mh]; ok {
+		return e
+	}
+
+	v1 := cid.NewCidV1(c.Type(), c.Hash()).String()
+	if e, ok := idx.hashes[doubleHash(v1, "")]; ok {
+		return e
+	}
+
+	var prefix string
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" {
+			continue
+		}
+		if prefix != "" {
+			prefix += "/"
+		}
+		prefix += segment
+		if e, ok := idx.paths[mh+"/"+prefix]; ok {
+			return e
+		}
+		if e, ok := idx.hashes[doubleHash(v1, prefix)]; ok {
+			return e
+		}
+	}
+	return nil
+}
+
+func doubleHash(v1 string, path string) string {
+	sum := sha256.Sum256([]byte(v1 + "/" + path))
+	return hex.EncodeToString(sum[:])
+}
+
+// Denylist holds the entries of one or more denylists, grouped by their
+// source, and matches content against them. It is safe for concurrent use.
+type Denylist struct {
+	mux     sync.RWMutex
+	sources map[string][]*api.DenylistEntry
+	deny    *index
+	allow   *index
+}
+
+// New returns an empty Denylist.
+func New() *Denylist {
+	return &Denylist{
+		sources: make(map[string][]*api.DenylistEntry),
+		deny:    newIndex(),
+		allow:   newIndex(),
+	}
+}
+
+// Set replaces the entries of the given source. Entries with unsupported
+// rules cause an error and nothing is changed.
+func (d *Denylist) Set(source string, entries []*api.DenylistEntry) error {
+	for _, e := range entries {
+		if err := ValidateRule(e.Rule); err != nil {
+			return err
+		}
+	}
+
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if len(entries) == 0 {
+		delete(d.sources, source)
+	} else {
+		d.sources[source] = entries
+	}
+	d.reindex()
+	return nil
+}
+
+func (d *Denylist) reindex() {
+	d.deny = newIndex()
+	d.allow = newIndex()
+	for _, entries := range d.sources {
+		for _, e := range entries {
+			rl, _ := parseRule(e.Rule)
+			if rl.allow {
+				d.allow.add(rl, e)
+			} else {
+				d.deny.add(rl, e)
+			}
+		}
+	}
+}
+
+// Entries returns the entries of all sources, sorted by source.
+func (d *Denylist) Entries() []*api.DenylistEntry {
+	d.mux.RLock()
+	defer d.mux.RUnlock()
+
+	sources := make([]string, 0, len(d.sources))
+	for s := range d.sources {
+		sources = append(sources, s)
+	}
+	sort.Strings(sources)
+
+	var entries []*api.DenylistEntry
+	for _, s := range sources {
+		entries = append(entries, d.sources[s]...)
+	}
+	return entries
+}
+
+// Len returns the number of entries.
+func (d *Denylist) Len() int {
+	d.mux.RLock()
+	defer d.mux.RUnlock()
+	n := 0
+	for _, entries := range d.sources {
+		n += len(entries)
+	}
+	return n
+}
+
+// Match returns the entry denying the given CID, or the given path under
+// it, and true when it is denied and no entry allows it.
+func (d *Denylist) Match(c cid.Cid, path string) (*api.DenylistEntry, bool) {
+	d.mux.RLock()
+	defer d.mux.RUnlock()
+
+	e := d.deny.match(c, path)
+	if e == nil || d.allow.match(c, path) != nil {
+		return nil, false
+	}
+	return e, true
+}
+
+// MatchPath is like Match, but takes an IPFS path like /ipfs/<cid>/<path>.
+// Paths which cannot be parsed never match.
+func (d *Denylist) MatchPath(p string) (*api.DenylistEntry, bool) {
+	p = strings.TrimPrefix(p, ipfsPrefix)
+	cidStr, path := splitPath(p)
+	c, err := cid.Decode(cidStr)
+	if err != nil {
+		return nil, false
+	}
+	return d.Match(c, path)
+}
@@ -0,0 +1,109 @@
+package denylist
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+func hashRuleFor(c cid.Cid, path string) string {
+	v1 := cid.NewCidV1(c.Type(), c.Hash()).String()
+	sum := sha256.Sum256([]byte(v1 + "/" + path))
+	return "//" + hex.EncodeToString(sum[:])
+}
+
+func TestParse(t *testing.T) {
+	list := `version: 1
+name: test
+---
+# a comment
+/ipfs/` + test.Cid1.String() + `
+/ipfs/` + test.Cid2.String() + `/docs/*
+` + hashRuleFor(test.Cid3, "") + `
+!/ipfs/` + test.Cid1.String() + `/public
+/ipns/example.org
+//notahash
+`
+	entries, skipped, err := Parse(strings.NewReader(list), "test.deny")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Fatal("expected 4 entries:", entries)
+	}
+	if skipped != 2 {
+		t.Error("expected 2 skipped rules:", skipped)
+	}
+	if entries[0].Source != "test.deny" {
+		t.Error("unexpected source:", entries[0].Source)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	d := New()
+	err := d.Set("test", []*api.DenylistEntry{
+		{Rule: "/ipfs/" + test.Cid1.String()},
+		{Rule: "/ipfs/" + test.Cid2.String() + "/docs"},
+		{Rule: hashRuleFor(test.Cid3, "")},
+		{Rule: hashRuleFor(test.Cid4, "a/b")},
+		{Rule: "!/ipfs/" + test.Cid1.String() + "/public"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Len() != 5 {
+		t.Error("unexpected number of entries:", d.Len())
+	}
+
+	cases := []struct {
+		c      cid.Cid
+		path   string
+		denied bool
+	}{
+		{test.Cid1, "", true},
+		{test.Cid1, "a/b", true},
+		{test.Cid1, "public/file", false},
+		{test.Cid2, "", false},
+		{test.Cid2, "docs", true},
+		{test.Cid2, "docs/x", true},
+		{test.Cid2, "other", false},
+		{test.Cid3, "", true},
+		{test.Cid4, "a", false},
+		{test.Cid4, "a/b/c", true},
+		{test.Cid5, "", false},
+	}
+	for _, tc := range cases {
+		if _, denied := d.Match(tc.c, tc.path); denied != tc.denied {
+			t.Errorf("%s/%s: expected denied=%t", tc.c, tc.path, tc.denied)
+		}
+	}
+
+	// CIDv0 and CIDv1 of the same content match the same rules.
+	v1 := cid.NewCidV1(test.Cid1.Type(), test.Cid1.Hash())
+	if _, denied := d.Match(v1, ""); !denied {
+		t.Error("the CIDv1 should be denied")
+	}
+
+	if _, denied := d.MatchPath("/ipfs/" + test.Cid2.String() + "/docs/index.html"); !denied {
+		t.Error("the path should be denied")
+	}
+	if _, denied := d.MatchPath("/ipns/example.org"); denied {
+		t.Error("unparseable paths should not be denied")
+	}
+
+	if err := d.Set("test", []*api.DenylistEntry{{Rule: "/ipns/example.org"}}); err == nil {
+		t.Error("expected an error with an unsupported rule")
+	}
+	if err := d.Set("test", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, denied := d.Match(test.Cid1, ""); denied || d.Len() != 0 {
+		t.Error("entries should have been removed")
+	}
+}
@@ -0,0 +1,111 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+)
+
+func TestLoadDenylist(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	list := "# test\n/ipfs/" + test.Cid1.String() + "\n/ipns/unsupported.org\n"
+	if err := os.WriteFile(filepath.Join(dir, "test.deny"), []byte(list), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &Config{}
+	cfg.Default()
+	cfg.SetBaseDir(dir)
+	cfg.Denylist.Files = []string{"test.deny"}
+
+	dl, err := loadDenylist(ctx, cfg, dssync.MutexWrap(ds.NewMapDatastore()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, denied := dl.Match(test.Cid1, ""); !denied || dl.Len() != 1 {
+		t.Error("expected the entries of the file to be loaded")
+	}
+
+	cfg.Denylist.Files = []string{"missing.deny"}
+	if _, err := loadDenylist(ctx, cfg, dssync.MutexWrap(ds.NewMapDatastore())); err == nil {
+		t.Error("expected an error with a missing file")
+	}
+}
+
+func TestClusterDenylist(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	if _, err := cl.Pin(ctx, test.Cid1, api.PinOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	entries := []*api.DenylistEntry{
+		{Rule: "/ipfs/" + test.Cid1.String()},
+		{Rule: "/ipfs/" + test.Cid2.String()},
+		{Rule: "/ipfs/" + test.Cid2.String()},
+	}
+	if err := cl.DenylistAdd(ctx, entries); err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.DenylistAdd(ctx, []*api.DenylistEntry{{Rule: "/ipns/example.org"}}); err == nil {
+		t.Error("expected an error adding an unsupported rule")
+	}
+
+	stored, _ := cl.DenylistEntries(ctx)
+	if len(stored) != 2 || stored[0].Source != denylistAPISource {
+		t.Error("unexpected entries:", stored)
+	}
+
+	_, err := cl.Pin(ctx, test.Cid2, api.PinOptions{})
+	if !errors.Is(err, api.ErrDenylisted) {
+		t.Error("expected ErrDenylisted:", err)
+	}
+	_, err = cl.PinPath(ctx, "/ipfs/"+test.Cid2.String()+"/a", api.PinOptions{})
+	if !errors.Is(err, api.ErrDenylisted) {
+		t.Error("expected ErrDenylisted pinning a path:", err)
+	}
+
+	unpinned, err := cl.DenylistEnforce(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unpinned) != 1 || !unpinned[0].Cid.Equals(test.Cid1) {
+		t.Error("expected Cid1 to be unpinned:", unpinned)
+	}
+	pinDelay()
+	if _, err := cl.PinGet(ctx, test.Cid1); err == nil {
+		t.Error("Cid1 should have been unpinned")
+	}
+
+	if err := cl.DenylistRemove(ctx, "/ipfs/"+test.Cid2.String()); err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.DenylistRemove(ctx, "/ipfs/"+test.Cid2.String()); err != api.ErrDenylistEntryNotFound {
+		t.Error("expected ErrDenylistEntryNotFound:", err)
+	}
+	if _, err := cl.Pin(ctx, test.Cid2, api.PinOptions{}); err != nil {
+		t.Error("Cid2 is no longer denylisted:", err)
+	}
+
+	// The entries are kept in the datastore.
+	dl, err := loadDenylist(ctx, cl.config, cl.datastore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dl.Len() != 1 {
+		t.Error("expected one stored entry:", dl.Len())
+	}
+}
@@ -0,0 +1,149 @@
+// Package dnslink provides ways of updating the DNSLink records of domains,
+// so that they point to content pinned in the cluster.
+//
+// The DNSLink of a domain is the TXT record of _dnslink.<domain>, with a
+// "dnslink=<path>" value, i.e. "dnslink=/ipfs/<cid>".
+package dnslink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Provider updates the DNSLink records of domains.
+type Provider interface {
+	// Update sets the DNSLink of the domain to the given path.
+	Update(ctx context.Context, domain, path string) error
+}
+
+// RecordName returns the name of the TXT record holding the DNSLink of a
+// domain.
+func RecordName(domain string) string {
+	return "_dnslink." + strings.TrimSuffix(domain, ".")
+}
+
+// Value returns the value of the TXT record for a DNSLink to the given
+// path.
+func Value(path string) string {
+	return "dnslink=" + path
+}
+
+// RFC2136 is a Provider which sends DNS UPDATE messages (RFC 2136) to the
+// primary server of the zone of the domains. The existing TXT records of
+// _dnslink.<domain> are replaced.
+type RFC2136 struct {
+	// Server is the address of the DNS server, as host:port.
+	Server string
+	// Zone contains the domains which can be updated.
+	Zone string
+	// TTL of the records.
+	TTL time.Duration
+	// TSIGKeyName, TSIGSecret (base64) and TSIGAlgorithm sign the
+	// updates, when the key name is set. The algorithm defaults to
+	// hmac-sha256.
+	TSIGKeyName   string
+	TSIGSecret    string
+	TSIGAlgorithm string
+}
+
+// Update sets the DNSLink of the domain to the given path.
+func (p *RFC2136) Update(ctx context.Context, domain, path string) error {
+	zone := dns.Fqdn(p.Zone)
+	name := dns.Fqdn(RecordName(domain))
+	if !dns.IsSubDomain(zone, name) {
+		return fmt.Errorf("%s is not in the %s zone", domain, zone)
+	}
+
+	rr := &dns.TXT{
+		Hdr: dns.RR_Header{
+			Name:   name,
+			Rrtype: dns.TypeTXT,
+			Class:  dns.ClassINET,
+			Ttl:    uint32(p.TTL.Seconds()),
+		},
+		Txt: []string{Value(path)},
+	}
+
+	m := new(dns.Msg)
+	m.SetUpdate(zone)
+	m.RemoveRRset([]dns.RR{rr})
+	m.Insert([]dns.RR{rr})
+
+	client := &dns.Client{}
+	if p.TSIGKeyName != "" {
+		keyName := dns.Fqdn(strings.ToLower(p.TSIGKeyName))
+		alg := dns.HmacSHA256
+		if p.TSIGAlgorithm != "" {
+			alg = dns.Fqdn(p.TSIGAlgorithm)
+		}
+		m.SetTsig(keyName, alg, 300, time.Now().Unix())
+		client.TsigSecret = map[string]string{keyName: p.TSIGSecret}
+	}
+
+	resp, _, err := client.ExchangeContext(ctx, m, p.Server)
+	if err != nil {
+		return err
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return fmt.Errorf("DNS update of %s failed: %s", name, dns.RcodeToString[resp.Rcode])
+	}
+	return nil
+}
+
+// HTTPRequest is the body of the requests sent by the HTTP provider.
+type HTTPRequest struct {
+	Domain string `json:"domain"`
+	Record string `json:"record"`
+	Value  string `json:"value"`
+}
+
+// HTTP is a Provider which sends every update as an HTTPRequest in the JSON
+// body of a POST request to an endpoint, which can update the records with
+// the API of any DNS provider.
+type HTTP struct {
+	URL string
+}
+
+// Update sets the DNSLink of the domain to the given path.
+func (p *HTTP) Update(ctx context.Context, domain, path string) error {
+	body, err := json.Marshal(HTTPRequest{
+		Domain: domain,
+		Record: RecordName(domain),
+		Value:  Value(path),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		out, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		msg := strings.TrimSpace(string(out))
+		if msg == "" {
+			return errors.New(resp.Status)
+		}
+		return fmt.Errorf("%s: %s", resp.Status, msg)
+	}
+	return nil
+}
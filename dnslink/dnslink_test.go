@@ -0,0 +1,108 @@
+package dnslink
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const testPath = "/ipfs/QmP63DkAFEnDYNjDYBpyNDfttu1fvUw99x1brscPzpqmmq"
+
+func TestHTTP(t *testing.T) {
+	reqs := make(chan HTTPRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req HTTPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Domain == "fail.example.org" {
+			http.Error(w, "no such zone", http.StatusNotFound)
+			return
+		}
+		reqs <- req
+	}))
+	defer srv.Close()
+
+	p := &HTTP{URL: srv.URL}
+	err := p.Update(context.Background(), "example.org", testPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := <-reqs
+	if req.Record != "_dnslink.example.org" || req.Value != "dnslink="+testPath {
+		t.Errorf("unexpected request: %+v", req)
+	}
+
+	err = p.Update(context.Background(), "fail.example.org", testPath)
+	if err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestRFC2136(t *testing.T) {
+	const keyName = "cluster."
+	const secret = "c2VjcmV0c2VjcmV0c2VjcmV0c2VjcmV0"
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates := make(chan *dns.Msg, 1)
+	srv := &dns.Server{
+		PacketConn: pc,
+		TsigSecret: map[string]string{keyName: secret},
+		// The default function rejects updates.
+		MsgAcceptFunc: func(dh dns.Header) dns.MsgAcceptAction { return dns.MsgAccept },
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			if r.IsTsig() == nil || w.TsigStatus() != nil {
+				m.Rcode = dns.RcodeNotAuth
+			} else {
+				updates <- r
+			}
+			w.WriteMsg(m)
+		}),
+	}
+	go srv.ActivateAndServe()
+	defer srv.Shutdown()
+
+	p := &RFC2136{
+		Server:      pc.LocalAddr().String(),
+		Zone:        "example.org",
+		TTL:         time.Minute,
+		TSIGKeyName: keyName,
+		TSIGSecret:  secret,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = p.Update(ctx, "www.example.org", testPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := <-updates
+	// The RRset is removed and the new record inserted.
+	if len(m.Ns) != 2 {
+		t.Fatalf("unexpected update: %s", m)
+	}
+	txt, ok := m.Ns[1].(*dns.TXT)
+	if !ok || txt.Hdr.Name != "_dnslink.www.example.org." || txt.Txt[0] != "dnslink="+testPath {
+		t.Errorf("unexpected record: %s", m.Ns[1])
+	}
+
+	p.TSIGSecret = "d3Jvbmd3cm9uZ3dyb25nd3Jvbmc="
+	err = p.Update(ctx, "www.example.org", testPath)
+	if err == nil {
+		t.Error("expected an error with a wrong key")
+	}
+
+	err = p.Update(ctx, "example.com", testPath)
+	if err == nil {
+		t.Error("expected an error for a domain out of the zone")
+	}
+}
@@ -0,0 +1,96 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/ipfs-cluster/adder/erasure"
+	"github.com/ipfs/ipfs-cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+	trace "go.opencensus.io/trace"
+)
+
+// Reconstruct rebuilds the DAG of an erasure-coded pin in the IPFS daemon
+// of this peer, so that the content can be retrieved from it. The blocks
+// are put in the IPFS daemon but not pinned.
+//
+// Only the shards that are pinned somewhere are used, preferring data
+// shards, which do not need decoding. It fails when there are fewer of
+// them than data shards. The shards which are not pinned anywhere are
+// reported as missing.
+func (c *Cluster) Reconstruct(ctx context.Context, h cid.Cid) (*api.ErasureReconstruction, error) {
+	_, span := trace.StartSpan(ctx, "cluster/Reconstruct")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	pin, err := c.PinGet(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	ecStr, ok := pin.Metadata[api.ErasureCodingMetaKey]
+	if pin.Type != api.MetaType || !ok || pin.Reference == nil {
+		return nil, errors.New("not an erasure-coded pin")
+	}
+	ec, err := api.ParseErasureCoding(ecStr)
+	if err != nil {
+		return nil, err
+	}
+
+	shards, err := erasure.Shards(ctx, c.ipfs.BlockGet, *pin.Reference, ec)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &api.ErasureReconstruction{
+		Cid:  h,
+		Peer: c.id,
+		Size: ec.Size,
+	}
+	chunks := make([][]cid.Cid, len(shards))
+	for i, sh := range shards {
+		status, err := c.Status(ctx, sh)
+		if err != nil {
+			return nil, err
+		}
+		if !pinnedSomewhere(status) {
+			res.MissingShards = append(res.MissingShards, i)
+			continue
+		}
+		if len(res.UsedShards) == ec.DataShards {
+			continue
+		}
+		chunks[i], err = erasure.ShardChunks(ctx, c.ipfs.BlockGet, sh)
+		if err != nil {
+			return nil, fmt.Errorf("error reading shard %d (%s): %w", i, sh, err)
+		}
+		if chunks[i] == nil {
+			chunks[i] = []cid.Cid{}
+		}
+		res.UsedShards = append(res.UsedShards, i)
+		res.Decoded = res.Decoded || i >= ec.DataShards
+	}
+	if len(res.UsedShards) < ec.DataShards {
+		return res, fmt.Errorf("%d shards are needed to reconstruct %s, only %d are pinned", ec.DataShards, h, len(res.UsedShards))
+	}
+
+	logger.Infof("reconstructing %s from shards %v", h, res.UsedShards)
+	res.Blocks, err = erasure.Decode(ctx, ec, chunks, c.ipfs.BlockGet, func(n *api.NodeWithMeta) error {
+		return c.ipfs.BlockPut(ctx, n)
+	})
+	if err != nil {
+		return res, fmt.Errorf("error reconstructing %s: %w", h, err)
+	}
+	logger.Infof("reconstructed %s: %d blocks", h, res.Blocks)
+	return res, nil
+}
+
+func pinnedSomewhere(gpi *api.GlobalPinInfo) bool {
+	for _, pi := range gpi.PeerMap {
+		if pi.Status == api.TrackerStatusPinned {
+			return true
+		}
+	}
+	return false
+}
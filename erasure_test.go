@@ -0,0 +1,106 @@
+package ipfscluster
+
+import (
+	"context"
+	"fmt"
+	"mime/multipart"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/adder/erasure"
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+func TestClusterReconstruct(t *testing.T) {
+	defer func(n int) { erasure.ChunkSize = n }(erasure.ChunkSize)
+	erasure.ChunkSize = 64 * 1024
+
+	ctx := context.Background()
+	cl, _, ipfs, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+	sth := test.NewShardingTestHelper()
+	defer sth.Clean(t)
+	// The shards are allocated, so the freespace metrics must be there.
+	waitForClustersHealthy(t, []*Cluster{cl})
+
+	params := api.DefaultAddParams()
+	params.Name = "testec"
+	params.ErasureDataShards = 3
+	params.ErasureParityShards = 2
+	mfr, closer := sth.GetTreeMultiReader(t)
+	defer closer.Close()
+	r := multipart.NewReader(mfr, mfr.Boundary())
+	root, err := cl.AddFile(r, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.String() != test.ShardingDirBalancedRootCID {
+		t.Fatal("unexpected root CID:", root)
+	}
+	pinDelay()
+
+	shards, err := cl.Shards(ctx, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shards) != 5 {
+		t.Fatalf("expected 5 shards, got %d", len(shards))
+	}
+	shardCids := make(map[string]cid.Cid)
+	for _, sh := range shards {
+		shardCids[sh.Name] = sh.Cid
+	}
+	loseShard := func(n int) {
+		ipfs.pins.Delete(shardCids[fmt.Sprintf("testec-shard-%d", n)].String())
+	}
+
+	forgetBlocks := func() {
+		for _, ci := range test.ShardingDirCids {
+			ipfs.blocks.Delete(ci)
+		}
+	}
+	checkBlocks := func() {
+		for _, ci := range test.ShardingDirCids {
+			c, _ := cid.Decode(ci)
+			if _, err := ipfs.BlockGet(ctx, c); err != nil {
+				t.Errorf("block %s was not reconstructed", ci)
+			}
+		}
+	}
+
+	forgetBlocks()
+	res, err := cl.Reconstruct(ctx, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Decoded || len(res.UsedShards) != 3 || len(res.MissingShards) != 0 ||
+		res.Blocks != uint64(len(test.ShardingDirCids)) {
+		t.Errorf("unexpected reconstruction: %+v", res)
+	}
+	checkBlocks()
+
+	// Lose two data shards.
+	forgetBlocks()
+	loseShard(0)
+	loseShard(1)
+	res, err = cl.Reconstruct(ctx, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Decoded || len(res.MissingShards) != 2 {
+		t.Errorf("unexpected reconstruction: %+v", res)
+	}
+	checkBlocks()
+
+	loseShard(4)
+	if _, err := cl.Reconstruct(ctx, root); err == nil {
+		t.Error("expected an error with 3 shards missing")
+	}
+
+	if _, err := cl.Reconstruct(ctx, shards[0].Cid); err == nil {
+		t.Error("expected an error for a pin which is not erasure-coded")
+	}
+}
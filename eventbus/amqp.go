@@ -0,0 +1,123 @@
+package eventbus
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// amqpBroker publishes to a topic exchange of an AMQP 0-9-1 broker, i.e.
+// RabbitMQ. It connects again on the next message when the connection
+// fails.
+type amqpBroker struct {
+	url      string
+	exchange string
+	timeout  time.Duration
+	mode     uint8
+
+	mu   sync.Mutex
+	conn *amqp.Connection
+	ch   *amqp.Channel
+}
+
+func newAMQPBroker(cfg *Config) (*amqpBroker, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Username != "" {
+		u.User = url.UserPassword(cfg.Username, cfg.Password)
+	}
+
+	mode := amqp.Transient
+	if cfg.QoS > 0 {
+		mode = amqp.Persistent
+	}
+
+	ab := &amqpBroker{
+		url:      u.String(),
+		exchange: cfg.Exchange,
+		timeout:  cfg.Timeout,
+		mode:     mode,
+	}
+
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	if err := ab.connect(); err != nil {
+		logger.Warnf("could not connect to the AMQP broker, retrying on the next event: %s", err)
+	}
+	return ab, nil
+}
+
+// connect opens a channel and declares the exchange. It must be called
+// with the lock held.
+func (ab *amqpBroker) connect() error {
+	conn, err := amqp.DialConfig(ab.url, amqp.Config{
+		Dial: amqp.DefaultDial(ab.timeout),
+	})
+	if err != nil {
+		return err
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	err = ch.ExchangeDeclare(ab.exchange, amqp.ExchangeTopic, true, false, false, false, nil)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	ab.conn = conn
+	ab.ch = ch
+	return nil
+}
+
+// disconnect closes the connection. It must be called with the lock held.
+func (ab *amqpBroker) disconnect() error {
+	if ab.conn == nil {
+		return nil
+	}
+	err := ab.conn.Close()
+	ab.conn = nil
+	ab.ch = nil
+	return err
+}
+
+// publish sends a message to the exchange with the topic as routing key.
+// The AMQP client does not support contexts: the deadline only applies
+// while connecting.
+func (ab *amqpBroker) publish(ctx context.Context, topic string, payload []byte) error {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if ab.ch == nil {
+		if err := ab.connect(); err != nil {
+			return err
+		}
+	}
+
+	err := ab.ch.Publish(ab.exchange, topic, false, false, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: ab.mode,
+		Timestamp:    time.Now(),
+		Body:         payload,
+	})
+	if err != nil {
+		ab.disconnect()
+	}
+	return err
+}
+
+func (ab *amqpBroker) close() error {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	return ab.disconnect()
+}
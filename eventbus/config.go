@@ -0,0 +1,221 @@
+package eventbus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/config"
+
+	"github.com/kelseyhightower/envconfig"
+)
+
+const configKey = "eventbus"
+const envConfigKey = "cluster_eventbus"
+
+// Default values for this Config.
+const (
+	DefaultPinsTopic      = "ipfs-cluster/pins"
+	DefaultAlertsTopic    = "ipfs-cluster/alerts"
+	DefaultLifecycleTopic = "ipfs-cluster/lifecycle"
+	DefaultQoS            = 1
+	DefaultExchange       = "ipfs-cluster"
+	DefaultTimeout        = 10 * time.Second
+)
+
+// Config configures the event bus publisher.
+type Config struct {
+	config.Saver
+
+	// URL is the address of the broker. The scheme selects the
+	// protocol: mqtt (or tcp), mqtts (or ssl), ws and wss for MQTT,
+	// amqp and amqps for AMQP 0-9-1. Publishing is disabled when
+	// empty.
+	URL string
+	// Username and Password authenticate with the broker, when set.
+	// They override the credentials in the URL.
+	Username string
+	Password string
+
+	// ClientID identifies the peer with MQTT brokers. The peer ID is
+	// used when empty.
+	ClientID string
+
+	// PinsTopic receives the changes to the pinset committed by the
+	// peer. AlertsTopic receives the alerts about the health of the
+	// peers. LifecycleTopic receives the lifecycle events of the peer.
+	// A topic is not published when set to an empty string. With
+	// AMQP, topics are the routing keys.
+	PinsTopic      string
+	AlertsTopic    string
+	LifecycleTopic string
+
+	// QoS is the MQTT quality of service (0, 1 or 2). With AMQP,
+	// messages are persistent when it is not 0.
+	QoS int
+
+	// Exchange is the AMQP topic exchange which messages are sent to.
+	// It is declared (durable) when the publisher connects.
+	Exchange string
+
+	// Timeout limits how long connecting and publishing every event can
+	// take.
+	Timeout time.Duration
+}
+
+type jsonConfig struct {
+	URL            string `json:"url"`
+	Username       string `json:"username,omitempty"`
+	Password       string `json:"password,omitempty" hidden:"true"`
+	ClientID       string `json:"client_id,omitempty"`
+	PinsTopic      string `json:"pins_topic"`
+	AlertsTopic    string `json:"alerts_topic"`
+	LifecycleTopic string `json:"lifecycle_topic"`
+	QoS            int    `json:"qos"`
+	Exchange       string `json:"exchange"`
+	Timeout        string `json:"timeout"`
+}
+
+// ConfigKey provides a human-friendly identifier for this type of Config.
+func (cfg *Config) ConfigKey() string {
+	return configKey
+}
+
+// Default sets the fields of this Config to sensible values.
+func (cfg *Config) Default() error {
+	cfg.URL = ""
+	cfg.Username = ""
+	cfg.Password = ""
+	cfg.ClientID = ""
+	cfg.PinsTopic = DefaultPinsTopic
+	cfg.AlertsTopic = DefaultAlertsTopic
+	cfg.LifecycleTopic = DefaultLifecycleTopic
+	cfg.QoS = DefaultQoS
+	cfg.Exchange = DefaultExchange
+	cfg.Timeout = DefaultTimeout
+	return nil
+}
+
+// ApplyEnvVars fills in any Config fields found
+// as environment variables.
+func (cfg *Config) ApplyEnvVars() error {
+	jcfg := cfg.toJSONConfig()
+
+	err := envconfig.Process(envConfigKey, jcfg)
+	if err != nil {
+		return err
+	}
+
+	return cfg.applyJSONConfig(jcfg)
+}
+
+// Enabled returns true when a broker URL is configured.
+func (cfg *Config) Enabled() bool {
+	return cfg.URL != ""
+}
+
+// protocol returns "mqtt" or "amqp" depending on the scheme of the URL.
+func (cfg *Config) protocol() (string, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "mqtt", "mqtts", "tcp", "ssl", "ws", "wss":
+		return "mqtt", nil
+	case "amqp", "amqps":
+		return "amqp", nil
+	default:
+		return "", fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+}
+
+// Validate checks that the fields of this Config have working values,
+// at least in appearance.
+func (cfg *Config) Validate() error {
+	if cfg.Timeout <= 0 {
+		return errors.New("eventbus.timeout is too low")
+	}
+
+	if cfg.QoS < 0 || cfg.QoS > 2 {
+		return errors.New("eventbus.qos must be 0, 1 or 2")
+	}
+
+	if !cfg.Enabled() {
+		return nil
+	}
+
+	proto, err := cfg.protocol()
+	if err != nil {
+		return fmt.Errorf("eventbus.url is invalid: %w", err)
+	}
+	if proto == "amqp" && cfg.Exchange == "" {
+		return errors.New("eventbus.exchange must be set for AMQP brokers")
+	}
+	return nil
+}
+
+// LoadJSON sets the fields of this Config to the values defined by the JSON
+// representation of it, as generated by ToJSON.
+func (cfg *Config) LoadJSON(raw []byte) error {
+	// Omitted fields keep their default values.
+	cfg.Default()
+	jcfg := cfg.toJSONConfig()
+	err := json.Unmarshal(raw, jcfg)
+	if err != nil {
+		logger.Error("Error unmarshaling eventbus config")
+		return err
+	}
+
+	return cfg.applyJSONConfig(jcfg)
+}
+
+func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
+	cfg.URL = jcfg.URL
+	cfg.Username = jcfg.Username
+	cfg.Password = jcfg.Password
+	cfg.ClientID = jcfg.ClientID
+	cfg.PinsTopic = jcfg.PinsTopic
+	cfg.AlertsTopic = jcfg.AlertsTopic
+	cfg.LifecycleTopic = jcfg.LifecycleTopic
+	cfg.QoS = jcfg.QoS
+	cfg.Exchange = jcfg.Exchange
+
+	err := config.ParseDurations(cfg.ConfigKey(),
+		&config.DurationOpt{Duration: jcfg.Timeout, Dst: &cfg.Timeout, Name: "timeout"},
+	)
+	if err != nil {
+		return err
+	}
+
+	return cfg.Validate()
+}
+
+// ToJSON generates a human-friendly JSON representation of this Config.
+func (cfg *Config) ToJSON() ([]byte, error) {
+	jcfg := cfg.toJSONConfig()
+
+	return config.DefaultJSONMarshal(jcfg)
+}
+
+func (cfg *Config) toJSONConfig() *jsonConfig {
+	return &jsonConfig{
+		URL:            cfg.URL,
+		Username:       cfg.Username,
+		Password:       cfg.Password,
+		ClientID:       cfg.ClientID,
+		PinsTopic:      cfg.PinsTopic,
+		AlertsTopic:    cfg.AlertsTopic,
+		LifecycleTopic: cfg.LifecycleTopic,
+		QoS:            cfg.QoS,
+		Exchange:       cfg.Exchange,
+		Timeout:        cfg.Timeout.String(),
+	}
+}
+
+// ToDisplayJSON returns JSON config as a string.
+func (cfg *Config) ToDisplayJSON() ([]byte, error) {
+	return config.DisplayJSON(cfg.toJSONConfig())
+}
@@ -0,0 +1,109 @@
+package eventbus
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+var cfgJSON = []byte(`
+{
+	"url": "amqp://127.0.0.1:5672/",
+	"username": "cluster",
+	"password": "secret",
+	"alerts_topic": "",
+	"qos": 0,
+	"timeout": "5s"
+}
+`)
+
+func TestLoadJSON(t *testing.T) {
+	cfg := &Config{}
+	err := cfg.LoadJSON(cfgJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Enabled() || cfg.Timeout != 5*time.Second || cfg.QoS != 0 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	if cfg.PinsTopic != DefaultPinsTopic || cfg.AlertsTopic != "" || cfg.Exchange != DefaultExchange {
+		t.Errorf("unexpected topics: %+v", cfg)
+	}
+
+	err = cfg.LoadJSON([]byte(`{"url": "http://127.0.0.1"}`))
+	if err == nil {
+		t.Error("expected an error with an unsupported scheme")
+	}
+
+	err = cfg.LoadJSON([]byte(`{"url": "amqp://127.0.0.1", "exchange": ""}`))
+	if err == nil {
+		t.Error("expected an error without an exchange")
+	}
+
+	err = cfg.LoadJSON([]byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Enabled() || cfg.QoS != DefaultQoS {
+		t.Errorf("expected a disabled default config: %+v", cfg)
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	cfg := &Config{}
+	cfg.LoadJSON(cfgJSON)
+	newjson, err := cfg.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = &Config{}
+	err = cfg.LoadJSON(newjson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Password != "secret" || cfg.AlertsTopic != "" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	display, err := cfg.ToDisplayJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jcfg jsonConfig
+	json.Unmarshal(display, &jcfg)
+	if jcfg.Password == "secret" {
+		t.Error("the password should be hidden")
+	}
+}
+
+func TestDefault(t *testing.T) {
+	cfg := &Config{}
+	cfg.Default()
+	if cfg.Validate() != nil {
+		t.Fatal("error validating")
+	}
+
+	cfg.QoS = 3
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.Timeout = 0
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+}
+
+func TestApplyEnvVars(t *testing.T) {
+	os.Setenv("CLUSTER_EVENTBUS_PINSTOPIC", "cluster.pins")
+	defer os.Unsetenv("CLUSTER_EVENTBUS_PINSTOPIC")
+	cfg := &Config{}
+	cfg.Default()
+	cfg.ApplyEnvVars()
+
+	if cfg.PinsTopic != "cluster.pins" {
+		t.Fatal("failed to override pins_topic with env var")
+	}
+}
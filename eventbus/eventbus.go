@@ -0,0 +1,133 @@
+// Package eventbus implements an ipfscluster.EventPublisher which sends the
+// changes to the pinset committed by the peer and the alerts about the health
+// of the cluster peers to an MQTT or AMQP message broker, as JSON messages.
+//
+// Pinset changes are published as api.PinsetEvent objects to the pins topic,
+// alerts as api.Alert objects to the alerts topic and the lifecycle events
+// of the peer as api.LifecycleEvent objects to the lifecycle topic.
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	logging "github.com/ipfs/go-log/v2"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+
+	"go.opencensus.io/trace"
+)
+
+var logger = logging.Logger("eventbus")
+
+// broker sends messages to the topics of a message broker.
+type broker interface {
+	publish(ctx context.Context, topic string, payload []byte) error
+	close() error
+}
+
+// Publisher sends cluster events to a message broker.
+type Publisher struct {
+	config *Config
+	broker broker
+
+	shutdownMu sync.Mutex
+	shutdown   bool
+}
+
+// New creates a Publisher for the broker in the configuration. The
+// clientID identifies the peer with MQTT brokers when the configuration
+// does not set one.
+func New(cfg *Config, clientID string) (*Publisher, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.Enabled() {
+		return nil, errors.New("eventbus.url is not set")
+	}
+
+	proto, err := cfg.protocol()
+	if err != nil {
+		return nil, err
+	}
+
+	var b broker
+	switch proto {
+	case "mqtt":
+		if cfg.ClientID != "" {
+			clientID = cfg.ClientID
+		}
+		b, err = newMQTTBroker(cfg, clientID)
+	case "amqp":
+		b, err = newAMQPBroker(cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Publisher{
+		config: cfg,
+		broker: b,
+	}, nil
+}
+
+// SetClient does nothing. The Publisher does not use the RPC API.
+func (pub *Publisher) SetClient(c *rpc.Client) {}
+
+// Shutdown disconnects from the broker.
+func (pub *Publisher) Shutdown(ctx context.Context) error {
+	pub.shutdownMu.Lock()
+	defer pub.shutdownMu.Unlock()
+
+	if pub.shutdown {
+		logger.Debug("already shutdown")
+		return nil
+	}
+
+	logger.Info("stopping event bus publisher")
+	pub.shutdown = true
+	return pub.broker.close()
+}
+
+// PublishPinsetEvent sends a change to the pinset to the pins topic.
+func (pub *Publisher) PublishPinsetEvent(ctx context.Context, ev *api.PinsetEvent) error {
+	ctx, span := trace.StartSpan(ctx, "eventbus/PublishPinsetEvent")
+	defer span.End()
+
+	return pub.publish(ctx, pub.config.PinsTopic, ev)
+}
+
+// PublishAlert sends an alert to the alerts topic.
+func (pub *Publisher) PublishAlert(ctx context.Context, alert *api.Alert) error {
+	ctx, span := trace.StartSpan(ctx, "eventbus/PublishAlert")
+	defer span.End()
+
+	return pub.publish(ctx, pub.config.AlertsTopic, alert)
+}
+
+// PublishLifecycleEvent sends a lifecycle event to the lifecycle topic.
+func (pub *Publisher) PublishLifecycleEvent(ctx context.Context, ev *api.LifecycleEvent) error {
+	ctx, span := trace.StartSpan(ctx, "eventbus/PublishLifecycleEvent")
+	defer span.End()
+
+	return pub.publish(ctx, pub.config.LifecycleTopic, ev)
+}
+
+func (pub *Publisher) publish(ctx context.Context, topic string, v interface{}) error {
+	if topic == "" {
+		return nil
+	}
+
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pub.config.Timeout)
+	defer cancel()
+	return pub.broker.publish(ctx, topic, payload)
+}
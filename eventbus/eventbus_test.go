@@ -0,0 +1,104 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+)
+
+type message struct {
+	topic   string
+	payload []byte
+}
+
+type mockBroker struct {
+	messages []message
+}
+
+func (mb *mockBroker) publish(ctx context.Context, topic string, payload []byte) error {
+	mb.messages = append(mb.messages, message{topic, payload})
+	return nil
+}
+
+func (mb *mockBroker) close() error { return nil }
+
+func TestPublisher(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{}
+	cfg.Default()
+	cfg.AlertsTopic = ""
+	mb := &mockBroker{}
+	pub := &Publisher{config: cfg, broker: mb}
+
+	ev := &api.PinsetEvent{
+		Event: api.PinsetEventAdded,
+		Pin:   api.PinCid(test.Cid1),
+		User:  "alice",
+		Peer:  test.PeerID1,
+	}
+	if err := pub.PublishPinsetEvent(ctx, ev); err != nil {
+		t.Fatal(err)
+	}
+	// The alerts topic is disabled.
+	if err := pub.PublishAlert(ctx, &api.Alert{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mb.messages) != 1 || mb.messages[0].topic != DefaultPinsTopic {
+		t.Fatalf("unexpected messages: %+v", mb.messages)
+	}
+	var got api.PinsetEvent
+	if err := json.Unmarshal(mb.messages[0].payload, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.User != "alice" || !got.Pin.Cid.Equals(test.Cid1) || got.Peer != test.PeerID1 {
+		t.Errorf("unexpected event: %+v", got)
+	}
+
+	lev := &api.LifecycleEvent{
+		Sequence: 1,
+		Event:    api.LifecycleEventPeerJoined,
+		Peer:     test.PeerID1,
+		Subject:  test.PeerID2,
+	}
+	if err := pub.PublishLifecycleEvent(ctx, lev); err != nil {
+		t.Fatal(err)
+	}
+	if len(mb.messages) != 2 || mb.messages[1].topic != DefaultLifecycleTopic {
+		t.Fatalf("unexpected messages: %+v", mb.messages)
+	}
+	var gotLev api.LifecycleEvent
+	if err := json.Unmarshal(mb.messages[1].payload, &gotLev); err != nil {
+		t.Fatal(err)
+	}
+	if gotLev.Event != api.LifecycleEventPeerJoined || gotLev.Subject != test.PeerID2 {
+		t.Errorf("unexpected lifecycle event: %+v", gotLev)
+	}
+
+	if err := pub.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAMQPUnreachable(t *testing.T) {
+	cfg := &Config{}
+	cfg.Default()
+	cfg.URL = "amqp://127.0.0.1:1/"
+	cfg.Timeout = time.Second
+
+	// The peer starts even if the broker is down.
+	pub, err := New(cfg, "peer1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pub.Shutdown(context.Background())
+
+	err = pub.PublishAlert(context.Background(), &api.Alert{})
+	if err == nil {
+		t.Error("expected an error publishing to an unreachable broker")
+	}
+}
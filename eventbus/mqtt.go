@@ -0,0 +1,57 @@
+package eventbus
+
+import (
+	"context"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// mqttBroker publishes to an MQTT broker. The client reconnects on its own
+// when the connection is lost.
+type mqttBroker struct {
+	client mqtt.Client
+	qos    byte
+}
+
+func newMQTTBroker(cfg *Config, clientID string) (*mqttBroker, error) {
+	opts := mqtt.NewClientOptions().
+		AddBroker(cfg.URL).
+		SetClientID(clientID).
+		SetConnectTimeout(cfg.Timeout).
+		SetAutoReconnect(true).
+		SetConnectRetry(true)
+	if cfg.Username != "" {
+		opts.SetUsername(cfg.Username)
+		opts.SetPassword(cfg.Password)
+	}
+
+	client := mqtt.NewClient(opts)
+	tok := client.Connect()
+	// With ConnectRetry, the first connection is retried in the
+	// background and messages are queued in the meantime.
+	if !tok.WaitTimeout(cfg.Timeout) {
+		logger.Warnf("could not connect to the MQTT broker yet, retrying in the background")
+	} else if err := tok.Error(); err != nil {
+		return nil, err
+	}
+
+	return &mqttBroker{
+		client: client,
+		qos:    byte(cfg.QoS),
+	}, nil
+}
+
+func (mb *mqttBroker) publish(ctx context.Context, topic string, payload []byte) error {
+	tok := mb.client.Publish(topic, mb.qos, false, payload)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-tok.Done():
+		return tok.Error()
+	}
+}
+
+func (mb *mqttBroker) close() error {
+	mb.client.Disconnect(250)
+	return nil
+}
@@ -0,0 +1,210 @@
+package ipfscluster
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+)
+
+// The changes to the pinset are sent to the pinset webhooks and to the
+// event publishers by the peer which commits them, so that every change is
+// sent once, and only for the pins which make up the logical pinset (data
+// and meta pins). Alerts are sent to the event publishers by every peer.
+// Lifecycle events are sent to the lifecycle webhooks and to the event
+// publishers by the peer they happen to.
+//
+// Every webhook and publisher has its own queue of events, delivered in
+// order by its own goroutine, so that a slow or unreachable one does not
+// delay the others. Events are dropped when the queue of a subscriber is
+// full, when delivery fails or times out, or when the peer shuts down, so
+// consumers should resync with the pinset or its changes feed from time to
+// time.
+
+// eventQueueSize is the number of events which can wait to be delivered to
+// every webhook or publisher.
+var eventQueueSize = 1024
+
+// eventPublishTimeout is the time given to a publisher to publish an event.
+// Webhooks use their own timeout.
+var eventPublishTimeout = 30 * time.Second
+
+// eventSubscriber is a webhook or a publisher along with the events waiting
+// to be delivered to it.
+type eventSubscriber struct {
+	name    string
+	queue   chan interface{}
+	wants   func(ev interface{}) bool
+	deliver func(ctx context.Context, ev interface{})
+}
+
+// eventQueues holds the subscribers to the events of the peer.
+type eventQueues []*eventSubscriber
+
+func newEventQueues(cfg *Config, publishers []EventPublisher) eventQueues {
+	var q eventQueues
+	for i := range cfg.PinWebhooks {
+		wh := &cfg.PinWebhooks[i]
+		q = append(q, &eventSubscriber{
+			name:  "pinset webhook " + wh.URL,
+			queue: make(chan interface{}, eventQueueSize),
+			wants: func(ev interface{}) bool {
+				pev, ok := ev.(*api.PinsetEvent)
+				return ok && wh.wants(pev.Event)
+			},
+			deliver: func(ctx context.Context, ev interface{}) {
+				sendPinWebhook(ctx, wh, ev.(*api.PinsetEvent))
+			},
+		})
+	}
+	for i := range cfg.LifecycleWebhooks {
+		wh := &cfg.LifecycleWebhooks[i]
+		q = append(q, &eventSubscriber{
+			name:  "lifecycle webhook " + wh.URL,
+			queue: make(chan interface{}, eventQueueSize),
+			wants: func(ev interface{}) bool {
+				lev, ok := ev.(*api.LifecycleEvent)
+				return ok && wh.wants(lev.Event)
+			},
+			deliver: func(ctx context.Context, ev interface{}) {
+				sendLifecycleWebhook(ctx, wh, ev.(*api.LifecycleEvent))
+			},
+		})
+	}
+	for _, pub := range publishers {
+		pub := pub
+		q = append(q, &eventSubscriber{
+			name:  fmt.Sprintf("publisher %T", pub),
+			queue: make(chan interface{}, eventQueueSize),
+			wants: func(ev interface{}) bool { return true },
+			deliver: func(ctx context.Context, ev interface{}) {
+				publishEvent(ctx, pub, ev)
+			},
+		})
+	}
+	return q
+}
+
+func (q eventQueues) enabled() bool {
+	return len(q) > 0
+}
+
+// push queues an event for the subscribers which want it, dropping it for
+// those whose queue is full. The description is used in the logs.
+func (q eventQueues) push(ev interface{}, desc string) {
+	for _, sub := range q {
+		if !sub.wants(ev) {
+			continue
+		}
+		select {
+		case sub.queue <- ev:
+		default:
+			logger.Errorf("event queue of %s is full: dropping %s", sub.name, desc)
+		}
+	}
+}
+
+// logPin commits a pin to the shared state and notifies the pinset
+// webhooks and publishers.
+func (c *Cluster) logPin(ctx context.Context, pin *api.Pin) error {
+	event := api.PinsetEventAdded
+	if c.events.enabled() && c.inPinset(ctx, pin) {
+		event = api.PinsetEventUpdated
+	}
+
+	err := c.consensus.LogPin(ctx, pin)
+	if err != nil {
+		return err
+	}
+	c.notifyPinset(ctx, event, pin)
+	return nil
+}
+
+// logUnpin removes a pin from the shared state and notifies the pinset
+// webhooks and publishers.
+func (c *Cluster) logUnpin(ctx context.Context, pin *api.Pin) error {
+	err := c.consensus.LogUnpin(ctx, pin)
+	if err != nil {
+		return err
+	}
+	c.notifyPinset(ctx, api.PinsetEventRemoved, pin)
+	return nil
+}
+
+// inPinset returns true when the pin is already in the shared state.
+func (c *Cluster) inPinset(ctx context.Context, pin *api.Pin) bool {
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		return false
+	}
+	ok, err := cState.Has(ctx, pin.Cid)
+	return err == nil && ok
+}
+
+// notifyPinset queues a pinset event.
+func (c *Cluster) notifyPinset(ctx context.Context, event api.PinsetEventType, pin *api.Pin) {
+	if !c.events.enabled() || (pin.Type != api.DataType && pin.Type != api.MetaType) {
+		return
+	}
+
+	ev := &api.PinsetEvent{
+		Event:     event,
+		Pin:       pin,
+		User:      api.RequestUserFromContext(ctx),
+		RequestID: api.RequestIDFromContext(ctx),
+		Peer:      c.id,
+		PeerName:  c.config.Peername,
+		Timestamp: time.Now(),
+	}
+	c.events.push(ev, fmt.Sprintf("%s event for %s", event, pin.Cid))
+}
+
+// notifyAlert queues an alert for the event publishers.
+func (c *Cluster) notifyAlert(alrt *api.Alert) {
+	c.events.push(alrt, fmt.Sprintf("alert for %s", alrt.Peer))
+}
+
+// sendEvents delivers the queued events to every subscriber until the
+// peer shuts down.
+func (c *Cluster) sendEvents() {
+	var wg sync.WaitGroup
+	for _, sub := range c.events {
+		wg.Add(1)
+		go func(sub *eventSubscriber) {
+			defer wg.Done()
+			for {
+				select {
+				case <-c.ctx.Done():
+					return
+				case ev := <-sub.queue:
+					sub.deliver(c.ctx, ev)
+				}
+			}
+		}(sub)
+	}
+	wg.Wait()
+}
+
+// publishEvent sends an event to a publisher, giving up after
+// eventPublishTimeout.
+func publishEvent(ctx context.Context, pub EventPublisher, ev interface{}) {
+	ctx, cancel := context.WithTimeout(ctx, eventPublishTimeout)
+	defer cancel()
+
+	switch ev := ev.(type) {
+	case *api.PinsetEvent:
+		if err := pub.PublishPinsetEvent(ctx, ev); err != nil {
+			logger.Errorf("error publishing %s event for %s: %s", ev.Event, ev.Pin.Cid, err)
+		}
+	case *api.Alert:
+		if err := pub.PublishAlert(ctx, ev); err != nil {
+			logger.Errorf("error publishing alert for %s: %s", ev.Peer, err)
+		}
+	case *api.LifecycleEvent:
+		if err := pub.PublishLifecycleEvent(ctx, ev); err != nil {
+			logger.Errorf("error publishing %s lifecycle event: %s", ev.Event, err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package ipfscluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+)
+
+type mockPublisher struct {
+	pinset    chan *api.PinsetEvent
+	alerts    chan *api.Alert
+	lifecycle chan *api.LifecycleEvent
+}
+
+func (mp *mockPublisher) SetClient(c *rpc.Client)            {}
+func (mp *mockPublisher) Shutdown(ctx context.Context) error { return nil }
+
+func (mp *mockPublisher) PublishPinsetEvent(ctx context.Context, ev *api.PinsetEvent) error {
+	mp.pinset <- ev
+	return nil
+}
+
+func (mp *mockPublisher) PublishAlert(ctx context.Context, alrt *api.Alert) error {
+	mp.alerts <- alrt
+	return nil
+}
+
+func (mp *mockPublisher) PublishLifecycleEvent(ctx context.Context, ev *api.LifecycleEvent) error {
+	select {
+	case mp.lifecycle <- ev:
+	default:
+	}
+	return nil
+}
+
+func TestClusterEventPublishers(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	pub := &mockPublisher{
+		pinset: make(chan *api.PinsetEvent, 10),
+		alerts: make(chan *api.Alert, 10),
+	}
+	cl.publishers = []EventPublisher{pub}
+	cl.events = newEventQueues(cl.config, cl.publishers)
+	cl.wg.Add(1)
+	go func() {
+		defer cl.wg.Done()
+		cl.sendEvents()
+	}()
+
+	_, err := cl.Pin(ctx, test.Cid1, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-pub.pinset:
+		if ev.Event != api.PinsetEventAdded || !ev.Pin.Cid.Equals(test.Cid1) {
+			t.Errorf("unexpected event: %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the pinset event")
+	}
+
+	alrt := &api.Alert{Metric: api.Metric{Name: pingMetricName, Peer: test.PeerID2}}
+	cl.notifyAlert(alrt)
+	select {
+	case got := <-pub.alerts:
+		if got.Peer != test.PeerID2 {
+			t.Errorf("unexpected alert: %+v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the alert")
+	}
+}
+
+// stuckPublisher never publishes anything until the context expires.
+type stuckPublisher struct {
+	mockPublisher
+}
+
+func (sp *stuckPublisher) PublishPinsetEvent(ctx context.Context, ev *api.PinsetEvent) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestClusterEventStuckPublisher(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	defer func(n int) { eventQueueSize = n }(eventQueueSize)
+	eventQueueSize = 1
+	defer func(d time.Duration) { eventPublishTimeout = d }(eventPublishTimeout)
+	eventPublishTimeout = time.Second
+
+	pub := &mockPublisher{
+		pinset: make(chan *api.PinsetEvent, 10),
+	}
+	cl.publishers = []EventPublisher{&stuckPublisher{}, pub}
+	cl.events = newEventQueues(cl.config, cl.publishers)
+	cl.wg.Add(1)
+	go func() {
+		defer cl.wg.Done()
+		cl.sendEvents()
+	}()
+
+	// The stuck publisher drops events once its queue is full, but the
+	// other one gets all of them.
+	for _, ci := range []cid.Cid{test.Cid1, test.Cid2, test.Cid3} {
+		_, err := cl.Pin(ctx, ci, api.PinOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case ev := <-pub.pinset:
+			if !ev.Pin.Cid.Equals(ci) {
+				t.Errorf("unexpected event: %+v", ev)
+			}
+		case <-time.After(500 * time.Millisecond):
+			t.Fatal("a stuck publisher should not delay the others")
+		}
+	}
+}
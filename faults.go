@@ -0,0 +1,191 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/version"
+
+	cid "github.com/ipfs/go-cid"
+	host "github.com/libp2p/go-libp2p-core/host"
+	network "github.com/libp2p/go-libp2p-core/network"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	protocol "github.com/libp2p/go-libp2p-core/protocol"
+)
+
+// Fault injection wraps the IPFS connector and the libp2p host used by the
+// RPC client so that a fraction of the calls fail or are delayed, as
+// configured in FaultInjectionConfig. Failing RPC streams makes other peers
+// look unreachable to this one, which triggers the same code paths as real
+// network problems (alerts, re-allocations, broadcast errors...).
+
+// errInjectedFault is returned by the calls failed on purpose.
+var errInjectedFault = errors.New("injected fault")
+
+// injectFault applies a FaultRule to a call: it waits for the delay, if the
+// call is selected for it, and returns an error if the call is selected to
+// fail.
+func injectFault(ctx context.Context, rule FaultRule, call string) error {
+	if rule.DelayRate > 0 && rand.Float64() < rule.DelayRate {
+		logger.Debugf("fault injection: delaying %s by %s", call, rule.Delay)
+		t := time.NewTimer(rule.Delay)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+	if rule.FailRate > 0 && rand.Float64() < rule.FailRate {
+		logger.Debugf("fault injection: failing %s", call)
+		return fmt.Errorf("%s: %w", call, errInjectedFault)
+	}
+	return nil
+}
+
+// faultyConnector is an IPFSConnector which injects faults in the calls to
+// the wrapped connector that reach the IPFS daemon.
+type faultyConnector struct {
+	IPFSConnector
+	rule FaultRule
+}
+
+func newFaultyConnector(ipfs IPFSConnector, rule FaultRule) *faultyConnector {
+	return &faultyConnector{
+		IPFSConnector: ipfs,
+		rule:          rule,
+	}
+}
+
+func (fc *faultyConnector) ID(ctx context.Context) (*api.IPFSID, error) {
+	if err := injectFault(ctx, fc.rule, "ipfs.ID"); err != nil {
+		return nil, err
+	}
+	return fc.IPFSConnector.ID(ctx)
+}
+
+func (fc *faultyConnector) Pin(ctx context.Context, pin *api.Pin) error {
+	if err := injectFault(ctx, fc.rule, "ipfs.Pin"); err != nil {
+		return err
+	}
+	return fc.IPFSConnector.Pin(ctx, pin)
+}
+
+func (fc *faultyConnector) Unpin(ctx context.Context, pin *api.Pin) error {
+	if err := injectFault(ctx, fc.rule, "ipfs.Unpin"); err != nil {
+		return err
+	}
+	return fc.IPFSConnector.Unpin(ctx, pin)
+}
+
+func (fc *faultyConnector) PinLsCid(ctx context.Context, pin *api.Pin) (api.IPFSPinStatus, error) {
+	if err := injectFault(ctx, fc.rule, "ipfs.PinLsCid"); err != nil {
+		return api.IPFSPinStatusError, err
+	}
+	return fc.IPFSConnector.PinLsCid(ctx, pin)
+}
+
+func (fc *faultyConnector) PinLs(ctx context.Context, typeFilter string) (map[string]api.IPFSPinStatus, error) {
+	if err := injectFault(ctx, fc.rule, "ipfs.PinLs"); err != nil {
+		return nil, err
+	}
+	return fc.IPFSConnector.PinLs(ctx, typeFilter)
+}
+
+func (fc *faultyConnector) ConnectSwarms(ctx context.Context) error {
+	if err := injectFault(ctx, fc.rule, "ipfs.ConnectSwarms"); err != nil {
+		return err
+	}
+	return fc.IPFSConnector.ConnectSwarms(ctx)
+}
+
+func (fc *faultyConnector) SwarmPeers(ctx context.Context) ([]peer.ID, error) {
+	if err := injectFault(ctx, fc.rule, "ipfs.SwarmPeers"); err != nil {
+		return nil, err
+	}
+	return fc.IPFSConnector.SwarmPeers(ctx)
+}
+
+func (fc *faultyConnector) RepoStat(ctx context.Context) (*api.IPFSRepoStat, error) {
+	if err := injectFault(ctx, fc.rule, "ipfs.RepoStat"); err != nil {
+		return nil, err
+	}
+	return fc.IPFSConnector.RepoStat(ctx)
+}
+
+func (fc *faultyConnector) RepoGC(ctx context.Context) (*api.RepoGC, error) {
+	if err := injectFault(ctx, fc.rule, "ipfs.RepoGC"); err != nil {
+		return nil, err
+	}
+	return fc.IPFSConnector.RepoGC(ctx)
+}
+
+func (fc *faultyConnector) Resolve(ctx context.Context, path string) (cid.Cid, error) {
+	if err := injectFault(ctx, fc.rule, "ipfs.Resolve"); err != nil {
+		return cid.Undef, err
+	}
+	return fc.IPFSConnector.Resolve(ctx, path)
+}
+
+func (fc *faultyConnector) BlockPut(ctx context.Context, nwm *api.NodeWithMeta) error {
+	if err := injectFault(ctx, fc.rule, "ipfs.BlockPut"); err != nil {
+		return err
+	}
+	return fc.IPFSConnector.BlockPut(ctx, nwm)
+}
+
+func (fc *faultyConnector) BlockGet(ctx context.Context, c cid.Cid) ([]byte, error) {
+	if err := injectFault(ctx, fc.rule, "ipfs.BlockGet"); err != nil {
+		return nil, err
+	}
+	return fc.IPFSConnector.BlockGet(ctx, c)
+}
+
+func (fc *faultyConnector) BlockGetLocal(ctx context.Context, c cid.Cid) ([]byte, error) {
+	if err := injectFault(ctx, fc.rule, "ipfs.BlockGetLocal"); err != nil {
+		return nil, err
+	}
+	return fc.IPFSConnector.BlockGetLocal(ctx, c)
+}
+
+func (fc *faultyConnector) BlockRm(ctx context.Context, c cid.Cid) error {
+	if err := injectFault(ctx, fc.rule, "ipfs.BlockRm"); err != nil {
+		return err
+	}
+	return fc.IPFSConnector.BlockRm(ctx, c)
+}
+
+func (fc *faultyConnector) DAGSize(ctx context.Context, c cid.Cid) (uint64, error) {
+	if err := injectFault(ctx, fc.rule, "ipfs.DAGSize"); err != nil {
+		return 0, err
+	}
+	return fc.IPFSConnector.DAGSize(ctx, c)
+}
+
+func (fc *faultyConnector) NamePublish(ctx context.Context, c cid.Cid, key string) (string, error) {
+	if err := injectFault(ctx, fc.rule, "ipfs.NamePublish"); err != nil {
+		return "", err
+	}
+	return fc.IPFSConnector.NamePublish(ctx, c, key)
+}
+
+// faultyHost is a libp2p host which injects faults when opening RPC
+// streams. It is given to the RPC client only: other protocols and
+// incoming requests are not affected.
+type faultyHost struct {
+	host.Host
+	rule FaultRule
+}
+
+func (fh *faultyHost) NewStream(ctx context.Context, p peer.ID, pids ...protocol.ID) (network.Stream, error) {
+	if len(pids) == 1 && pids[0] == version.RPCProtocol {
+		if err := injectFault(ctx, fh.rule, "rpc to "+p.String()); err != nil {
+			return nil, err
+		}
+	}
+	return fh.Host.NewStream(ctx, p, pids...)
+}
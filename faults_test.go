@@ -0,0 +1,62 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+	"github.com/ipfs/ipfs-cluster/version"
+)
+
+func TestFaultyConnector(t *testing.T) {
+	ctx := context.Background()
+	mock := &mockConnector{}
+
+	fc := newFaultyConnector(mock, FaultRule{FailRate: 1})
+	err := fc.Pin(ctx, api.PinCid(test.Cid1))
+	if !errors.Is(err, errInjectedFault) {
+		t.Fatal("expected an injected fault:", err)
+	}
+	if _, ok := mock.pins.Load(test.Cid1.String()); ok {
+		t.Fatal("failed calls should not reach the connector")
+	}
+
+	fc = newFaultyConnector(mock, FaultRule{DelayRate: 1, Delay: 100 * time.Millisecond})
+	start := time.Now()
+	err = fc.Pin(ctx, api.PinCid(test.Cid1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < 100*time.Millisecond {
+		t.Error("expected the call to be delayed")
+	}
+	if _, ok := mock.pins.Load(test.Cid1.String()); !ok {
+		t.Error("delayed calls should reach the connector")
+	}
+
+	fc = newFaultyConnector(mock, FaultRule{DelayRate: 1, Delay: time.Minute})
+	cctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_, err = fc.PinLs(cctx, "recursive")
+	if err != context.DeadlineExceeded {
+		t.Error("delays should stop when the context is done:", err)
+	}
+
+	// Calls without a rule are not affected.
+	fc = newFaultyConnector(mock, FaultRule{})
+	if _, err := fc.PinLsCid(ctx, api.PinCid(test.Cid1)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFaultyHost(t *testing.T) {
+	// Failed streams are never opened with the wrapped host.
+	fh := &faultyHost{rule: FaultRule{FailRate: 1}}
+	_, err := fh.NewStream(context.Background(), test.PeerID2, version.RPCProtocol)
+	if !errors.Is(err, errInjectedFault) {
+		t.Fatal("expected an injected fault:", err)
+	}
+}
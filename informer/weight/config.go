@@ -0,0 +1,129 @@
+package weight
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/config"
+	"github.com/kelseyhightower/envconfig"
+)
+
+const configKey = "weight"
+const envConfigKey = "cluster_weight"
+
+// Default values for weight Config
+const (
+	DefaultMetricTTL = 30 * time.Second
+	DefaultWeight    = 1.0
+)
+
+// Config is used to initialize an Informer and customize
+// the type and parameters of the metric it produces.
+type Config struct {
+	config.Saver
+
+	MetricTTL time.Duration
+	// Weight is the preference of this peer when allocating pins.
+	// The balanced allocator multiplies the weights of the metrics of
+	// this peer by it: peers with a weight of 2 are preferred over
+	// peers with a weight of 1 which have up to twice as much free space.
+	Weight float64
+}
+
+type jsonConfig struct {
+	MetricTTL string  `json:"metric_ttl"`
+	Weight    float64 `json:"weight"`
+}
+
+// ConfigKey returns a human-friendly identifier for this type of Metric.
+func (cfg *Config) ConfigKey() string {
+	return configKey
+}
+
+// Default initializes this Config with sensible values.
+func (cfg *Config) Default() error {
+	cfg.MetricTTL = DefaultMetricTTL
+	cfg.Weight = DefaultWeight
+	return nil
+}
+
+// ApplyEnvVars fills in any Config fields found
+// as environment variables.
+func (cfg *Config) ApplyEnvVars() error {
+	jcfg := cfg.toJSONConfig()
+
+	err := envconfig.Process(envConfigKey, jcfg)
+	if err != nil {
+		return err
+	}
+
+	return cfg.applyJSONConfig(jcfg)
+}
+
+// Validate checks that the fields of this Config have working values,
+// at least in appearance.
+func (cfg *Config) Validate() error {
+	if cfg.MetricTTL <= 0 {
+		return errors.New("weight.metric_ttl is invalid")
+	}
+
+	if cfg.Weight <= 0 {
+		return errors.New("weight.weight must be positive")
+	}
+
+	return nil
+}
+
+// LoadJSON reads the fields of this Config from a JSON byteslice as
+// generated by ToJSON.
+func (cfg *Config) LoadJSON(raw []byte) error {
+	jcfg := &jsonConfig{}
+	err := json.Unmarshal(raw, jcfg)
+	if err != nil {
+		logger.Error("Error unmarshaling weight informer config")
+		return err
+	}
+
+	cfg.Default()
+
+	return cfg.applyJSONConfig(jcfg)
+}
+
+func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
+	err := config.ParseDurations(
+		cfg.ConfigKey(),
+		&config.DurationOpt{Duration: jcfg.MetricTTL, Dst: &cfg.MetricTTL, Name: "metric_ttl"},
+	)
+	if err != nil {
+		return err
+	}
+
+	// When unset, leave default
+	if jcfg.Weight != 0 {
+		cfg.Weight = jcfg.Weight
+	}
+
+	return cfg.Validate()
+}
+
+// ToJSON generates a JSON-formatted human-friendly representation of this
+// Config.
+func (cfg *Config) ToJSON() (raw []byte, err error) {
+	jcfg := cfg.toJSONConfig()
+
+	raw, err = config.DefaultJSONMarshal(jcfg)
+	return
+}
+
+func (cfg *Config) toJSONConfig() *jsonConfig {
+	return &jsonConfig{
+		MetricTTL: cfg.MetricTTL.String(),
+		Weight:    cfg.Weight,
+	}
+}
+
+// ToDisplayJSON returns JSON config as a string.
+func (cfg *Config) ToDisplayJSON() ([]byte, error) {
+	return config.DisplayJSON(cfg.toJSONConfig())
+}
@@ -0,0 +1,76 @@
+// Package weight implements an ipfs-cluster informer which publishes the
+// allocation preference of the peer, as set in its configuration, as a
+// metric.
+package weight
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	logging "github.com/ipfs/go-log/v2"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+)
+
+var logger = logging.Logger("weightinfo")
+
+// MetricName specifies the name of our metric
+var MetricName = "weight"
+
+// Informer is a simple object to implement the ipfscluster.Informer
+// and Component interfaces.
+type Informer struct {
+	config *Config // set when created, readonly
+
+	mu        sync.Mutex // guards access to following fields
+	rpcClient *rpc.Client
+}
+
+// New returns an initialized informer using the given InformerConfig.
+func New(cfg *Config) (*Informer, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Informer{
+		config: cfg,
+	}, nil
+}
+
+// Name returns the name of this informer.
+func (w *Informer) Name() string {
+	return MetricName
+}
+
+// SetClient provides us with an rpc.Client which allows
+// contacting other components in the cluster.
+func (w *Informer) SetClient(c *rpc.Client) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.rpcClient = c
+}
+
+// Shutdown is called on cluster shutdown. We just invalidate
+// any metrics from this point.
+func (w *Informer) Shutdown(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.rpcClient = nil
+	return nil
+}
+
+// GetMetrics returns a single metric whose value is the configured weight.
+func (w *Informer) GetMetrics(ctx context.Context) []*api.Metric {
+	m := &api.Metric{
+		Name:          MetricName,
+		Value:         strconv.FormatFloat(w.config.Weight, 'f', -1, 64),
+		Valid:         true,
+		Partitionable: false,
+	}
+	m.SetTTL(w.config.MetricTTL)
+	return []*api.Metric{m}
+}
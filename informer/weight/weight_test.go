@@ -0,0 +1,25 @@
+package weight
+
+import (
+	"context"
+	"testing"
+)
+
+func Test(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{}
+	cfg.Default()
+	cfg.Weight = 1.5
+	inf, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inf.Shutdown(ctx)
+	m := inf.GetMetrics(ctx)
+	if len(m) != 1 || !m[0].Valid {
+		t.Fatal("metric should be valid")
+	}
+	if m[0].Name != MetricName || m[0].Value != "1.5" {
+		t.Error("bad metric:", m[0].Name, m[0].Value)
+	}
+}
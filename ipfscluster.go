@@ -62,6 +62,8 @@ type Consensus interface {
 	Trust(context.Context, peer.ID) error
 	// Distrust removes a peer from the "trusted" set.
 	Distrust(context.Context, peer.ID) error
+	// Stats returns the internals of the component, for debugging.
+	Stats(context.Context) (*api.ConsensusStats, error)
 }
 
 // API is a component which offers an API for Cluster. This is
@@ -70,13 +72,23 @@ type API interface {
 	Component
 }
 
+// RebindableAPI is an API whose HTTP listeners can be changed while it runs.
+type RebindableAPI interface {
+	API
+	// Listeners returns the current settings of the listeners.
+	Listeners() *api.APIListeners
+	// Rebind replaces the listeners without interrupting the requests
+	// being served.
+	Rebind(context.Context, *api.APIListeners) error
+}
+
 // IPFSConnector is a component which allows cluster to interact with
 // an IPFS daemon. This is a base component.
 type IPFSConnector interface {
 	Component
 	ID(context.Context) (*api.IPFSID, error)
 	Pin(context.Context, *api.Pin) error
-	Unpin(context.Context, cid.Cid) error
+	Unpin(context.Context, *api.Pin) error
 	PinLsCid(context.Context, *api.Pin) (api.IPFSPinStatus, error)
 	PinLs(ctx context.Context, typeFilter string) (map[string]api.IPFSPinStatus, error)
 	// ConnectSwarms make sure this peer's IPFS daemon is connected to
@@ -98,6 +110,18 @@ type IPFSConnector interface {
 	BlockPut(context.Context, *api.NodeWithMeta) error
 	// BlockGet retrieves the raw data of an IPFS block.
 	BlockGet(context.Context, cid.Cid) ([]byte, error)
+	// BlockGetLocal retrieves the raw data of an IPFS block only when
+	// it is in the IPFS repo, without fetching it from the network.
+	BlockGetLocal(context.Context, cid.Cid) ([]byte, error)
+	// BlockRm removes a block from the IPFS repo. Blocks which are part
+	// of a pinned DAG cannot be removed.
+	BlockRm(context.Context, cid.Cid) error
+	// DAGSize returns the cumulative size of the DAG with the given root,
+	// as reported by "object stat".
+	DAGSize(context.Context, cid.Cid) (uint64, error)
+	// NamePublish publishes the given CID to IPNS with the given key
+	// of the IPFS daemon and returns the IPNS name.
+	NamePublish(ctx context.Context, c cid.Cid, key string) (string, error)
 }
 
 // Peered represents a component which needs to be aware of the peers
@@ -116,9 +140,9 @@ type PinTracker interface {
 	// Track tells the tracker that a Cid is now under its supervision
 	// The tracker may decide to perform an IPFS pin.
 	Track(context.Context, *api.Pin) error
-	// Untrack tells the tracker that a Cid is to be forgotten. The tracker
-	// may perform an IPFS unpin operation.
-	Untrack(context.Context, cid.Cid) error
+	// Untrack tells the tracker that a pin is to be forgotten. The tracker
+	// may perform an IPFS unpin operation with the options of the pin.
+	Untrack(context.Context, *api.Pin) error
 	// StatusAll returns the list of pins with their local status. Takes a
 	// filter to specify which statuses to report.
 	StatusAll(context.Context, api.TrackerStatus) []*api.PinInfo
@@ -129,6 +153,20 @@ type PinTracker interface {
 	RecoverAll(context.Context) ([]*api.PinInfo, error)
 	// Recover retriggers a Pin/Unpin operation in a Cids with error status.
 	Recover(context.Context, cid.Cid) (*api.PinInfo, error)
+	// Settings returns the settings which can be modified at runtime.
+	Settings(context.Context) *api.TrackerSettings
+	// SetSettings modifies the runtime settings. Zero values are left
+	// unchanged. It returns the resulting settings.
+	SetSettings(context.Context, *api.TrackerSettings) (*api.TrackerSettings, error)
+}
+
+// BatchStatusTracker is a PinTracker which can get the status of several
+// Cids at once, more efficiently than calling Status for each of them.
+type BatchStatusTracker interface {
+	PinTracker
+	// StatusCids returns the local status of the given Cids, in the
+	// same order.
+	StatusCids(context.Context, []cid.Cid) []*api.PinInfo
 }
 
 // Informer provides Metric information from a peer. The metrics produced by
@@ -185,6 +223,9 @@ type PeerMonitor interface {
 	// a problem (i.e. metrics not arriving as expected). Alerts can be used
 	// to trigger self-healing measures or re-pinnings of content.
 	Alerts() <-chan *api.Alert
+	// DetectorState returns how the failure detector sees the latest
+	// metrics received from every peer.
+	DetectorState(ctx context.Context) []*api.DetectorState
 }
 
 // Tracer implements Component as a way
@@ -192,3 +233,14 @@ type PeerMonitor interface {
 type Tracer interface {
 	Component
 }
+
+// EventPublisher is a component which sends the events of a peer to an
+// external system, i.e. a message broker: the changes to the pinset
+// committed by the peer, the alerts about the health of the peers and the
+// lifecycle events of the peer.
+type EventPublisher interface {
+	Component
+	PublishPinsetEvent(context.Context, *api.PinsetEvent) error
+	PublishAlert(context.Context, *api.Alert) error
+	PublishLifecycleEvent(context.Context, *api.LifecycleEvent) error
+}
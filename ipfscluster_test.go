@@ -34,6 +34,7 @@ import (
 	"github.com/ipfs/ipfs-cluster/test"
 	"github.com/ipfs/ipfs-cluster/version"
 
+	cid "github.com/ipfs/go-cid"
 	ds "github.com/ipfs/go-datastore"
 	libp2p "github.com/libp2p/go-libp2p"
 	crypto "github.com/libp2p/go-libp2p-core/crypto"
@@ -222,7 +223,7 @@ func createComponents(
 
 	store := makeStore(t, badgerCfg, levelDBCfg)
 	cons := makeConsensus(t, store, host, pubsub, dht, raftCfg, staging, crdtCfg)
-	tracker := stateless.New(statelesstrackerCfg, ident.ID, clusterCfg.Peername, cons.State)
+	tracker := stateless.New(statelesstrackerCfg, ident.ID, clusterCfg.Peername, cons.State, store)
 
 	var peersF func(context.Context) ([]peer.ID, error)
 	if consensus == "raft" {
@@ -281,7 +282,7 @@ func makeConsensus(t *testing.T, store ds.Datastore, h host.Host, psub *pubsub.P
 }
 
 func createCluster(t *testing.T, host host.Host, dht *dual.DHT, clusterCfg *Config, store ds.Datastore, consensus Consensus, apis []API, ipfs IPFSConnector, tracker PinTracker, mon PeerMonitor, alloc PinAllocator, inf Informer, tracer Tracer) *Cluster {
-	cl, err := NewCluster(context.Background(), host, dht, clusterCfg, store, consensus, apis, ipfs, tracker, mon, alloc, []Informer{inf}, tracer)
+	cl, err := NewCluster(context.Background(), host, dht, clusterCfg, store, consensus, apis, ipfs, tracker, mon, alloc, []Informer{inf}, nil, tracer)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -339,7 +340,11 @@ func createHost(t *testing.T, priv crypto.PrivKey, clusterSecret []byte, listen
 		t.Fatal(err)
 	}
 
-	return routedhost.Wrap(h, d), psub, d
+	// Keep the clusterHost wrapper around the routed host so that the
+	// cluster finds its bandwidth counter.
+	ch := h.(*clusterHost)
+	ch.Host = routedhost.Wrap(ch.Host, d)
+	return ch, psub, d
 }
 
 func newTestDHT(ctx context.Context, h host.Host) (*dual.DHT, error) {
@@ -895,6 +900,54 @@ func TestClustersStatusAll(t *testing.T) {
 	runF(t, clusters, f)
 }
 
+func TestClustersStatusCids(t *testing.T) {
+	ctx := context.Background()
+	clusters, mock := createClusters(t)
+	defer shutdownClusters(t, clusters, mock)
+	h1 := test.Cid1
+	h2 := test.Cid2
+	clusters[0].Pin(ctx, h1, api.PinOptions{Name: "test"})
+	clusters[0].Pin(ctx, h2, api.PinOptions{})
+	pinDelay()
+
+	f := func(t *testing.T, c *Cluster) {
+		statuses, err := c.StatusCids(ctx, []cid.Cid{h2, test.Cid3, h1, h2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(statuses) != 4 {
+			t.Fatal("expected one status per requested cid:", len(statuses))
+		}
+		if !statuses[0].Cid.Equals(h2) || !statuses[1].Cid.Equals(test.Cid3) ||
+			!statuses[2].Cid.Equals(h1) || !statuses[3].Cid.Equals(h2) {
+			t.Error("statuses should be in the order of the request")
+		}
+		if statuses[2].Name != "test" {
+			t.Error("globalPinInfo should have the name")
+		}
+		if len(statuses[0].PeerMap) != nClusters {
+			t.Error("bad info in status")
+		}
+
+		pid := peer.Encode(c.host.ID())
+		if statuses[0].PeerMap[pid].Status != api.TrackerStatusPinned {
+			t.Error("the hash should have been pinned")
+		}
+		if statuses[1].PeerMap[pid].Status != api.TrackerStatusUnpinned {
+			t.Error("the hash should not be pinned")
+		}
+
+		local := c.StatusCidsLocal(ctx, []cid.Cid{h1, test.Cid3})
+		if len(local) != 2 {
+			t.Fatal("expected two local statuses")
+		}
+		if local[0].Status != api.TrackerStatusPinned || local[1].Status != api.TrackerStatusUnpinned {
+			t.Error("bad local statuses")
+		}
+	}
+	runF(t, clusters, f)
+}
+
 func TestClustersStatusAllWithErrors(t *testing.T) {
 	ctx := context.Background()
 	clusters, mock := createClusters(t)
@@ -1433,6 +1486,51 @@ func TestClustersReplicationFactorMin(t *testing.T) {
 	}
 }
 
+// This tests checks that excluding a peer from a sufficiently pinned item
+// removes it from the allocations even if no new ones are needed.
+func TestClustersReplicationExcludeCurrent(t *testing.T) {
+	ctx := context.Background()
+	if nClusters < 3 {
+		t.Skip("Need at least 3 peers")
+	}
+
+	clusters, mock := createClusters(t)
+	defer shutdownClusters(t, clusters, mock)
+	for _, c := range clusters {
+		c.config.ReplicationFactorMin = 1
+		c.config.ReplicationFactorMax = nClusters
+	}
+
+	ttlDelay()
+
+	h := test.Cid1
+	_, err := clusters[0].Pin(ctx, h, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	excluded := clusters[1].id
+	_, err = clusters[0].Pin(ctx, h, api.PinOptions{
+		ExcludeAllocations: []peer.ID{excluded},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	p, err := clusters[0].PinGet(ctx, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if containsPeer(p.Allocations, excluded) {
+		t.Error("the excluded peer should have been removed from the allocations")
+	}
+	if len(p.Allocations) != nClusters-1 {
+		t.Errorf("expected %d allocations, got %d", nClusters-1, len(p.Allocations))
+	}
+}
+
 // This tests checks that repinning something that has becomed
 // underpinned actually changes nothing if it's sufficiently pinned
 func TestClustersReplicationMinMaxNoRealloc(t *testing.T) {
@@ -1724,6 +1822,193 @@ func TestClustersReplicationNotEnoughPeers(t *testing.T) {
 	//t.Log(err)
 }
 
+func TestClustersReplicationExpectedSize(t *testing.T) {
+	ctx := context.Background()
+	clusters, mock := createClusters(t)
+	defer shutdownClusters(t, clusters, mock)
+	for _, c := range clusters {
+		c.config.ReplicationFactorMin = 1
+		c.config.ReplicationFactorMax = 1
+		c.config.ResolveDAGSize = true
+	}
+
+	ttlDelay()
+
+	// The ipfs mock reports 10GB of storage for all peers.
+	j := rand.Intn(nClusters)
+	_, err := clusters[j].Pin(ctx, test.Cid1, api.PinOptions{
+		ExpectedSize: 20 * 1000 * 1000 * 1000,
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(err.Error(), "not enough peers to allocate") {
+		t.Error("different error than expected")
+		t.Error(err)
+	}
+
+	// The size is resolved from IPFS.
+	pin, err := clusters[j].Pin(ctx, test.Cid2, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin.ExpectedSize != 1<<20 {
+		t.Error("expected the size to be resolved:", pin.ExpectedSize)
+	}
+	if len(pin.Allocations) != 1 {
+		t.Error("expected one allocation")
+	}
+}
+
+func TestClustersPopularity(t *testing.T) {
+	ctx := context.Background()
+	clusters, mock := createClusters(t)
+	defer shutdownClusters(t, clusters, mock)
+	for _, c := range clusters {
+		c.config.ReplicationFactorMin = 1
+		c.config.ReplicationFactorMax = 1
+		// The loop is not started, rounds are run manually.
+		c.config.Popularity = PopularityConfig{
+			Interval:       time.Hour,
+			HotThreshold:   10,
+			ColdThreshold:  0,
+			MaxReplication: 2,
+		}
+	}
+
+	ttlDelay()
+
+	h := test.Cid1
+	_, err := clusters[0].Pin(ctx, h, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	round := func() {
+		for _, c := range clusters {
+			c.sendPopularityMetric(ctx)
+		}
+		delay()
+		for _, c := range clusters {
+			c.adjustPopularReplication(ctx)
+		}
+		pinDelay()
+	}
+
+	// Accesses are added up across peers.
+	for i := 0; i < 5; i++ {
+		clusters[0].TrackAccess(ctx, h)
+		clusters[nClusters-1].TrackAccess(ctx, h)
+	}
+	round()
+
+	pin, err := clusters[0].PinGet(ctx, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin.ReplicationFactorMin != 2 || pin.ReplicationFactorMax != 2 {
+		t.Fatalf("expected replication to be increased: %d/%d", pin.ReplicationFactorMin, pin.ReplicationFactorMax)
+	}
+	if len(pin.Allocations) != 2 {
+		t.Error("expected 2 allocations")
+	}
+	if pin.Metadata[PopularityBoostMetaKey] != "1" {
+		t.Error("expected the boost to be recorded in the metadata")
+	}
+
+	// MaxReplication is not exceeded.
+	for i := 0; i < 10; i++ {
+		clusters[0].TrackAccess(ctx, h)
+	}
+	round()
+	pin, _ = clusters[0].PinGet(ctx, h)
+	if pin.ReplicationFactorMax != 2 {
+		t.Error("max_replication should not have been exceeded")
+	}
+
+	// No accesses: back to the original replication.
+	round()
+	pin, _ = clusters[0].PinGet(ctx, h)
+	if pin.ReplicationFactorMin != 1 || pin.ReplicationFactorMax != 1 {
+		t.Errorf("expected replication to be lowered: %d/%d", pin.ReplicationFactorMin, pin.ReplicationFactorMax)
+	}
+	if _, ok := pin.Metadata[PopularityBoostMetaKey]; ok {
+		t.Error("the boost should have been removed")
+	}
+	if len(pin.Allocations) != 1 {
+		t.Error("expected 1 allocation")
+	}
+}
+
+func TestClustersPopularityAccessLog(t *testing.T) {
+	ctx := context.Background()
+	clusters, mock := createClusters(t)
+	defer shutdownClusters(t, clusters, mock)
+	logPath := filepath.Join(t.TempDir(), "access.log")
+	for i, c := range clusters {
+		c.config.ReplicationFactorMin = 1
+		c.config.ReplicationFactorMax = 1
+		c.config.Popularity = PopularityConfig{
+			Interval:       time.Hour,
+			HotThreshold:   10,
+			ColdThreshold:  0,
+			MaxReplication: 2,
+		}
+		if i == 0 {
+			c.config.Popularity.AccessLog = logPath
+		}
+	}
+
+	ttlDelay()
+
+	h := test.Cid1
+	_, err := clusters[0].Pin(ctx, h, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	round := func() {
+		for _, c := range clusters {
+			c.sendPopularityMetric(ctx)
+		}
+		delay()
+		for _, c := range clusters {
+			c.adjustPopularReplication(ctx)
+		}
+		pinDelay()
+	}
+
+	// The first round starts reading the log from its end.
+	if err := os.WriteFile(logPath, []byte(fmt.Sprintf("GET /ipfs/%s HTTP/1.1\n", h)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	round()
+
+	// Only the gateway sees the accesses.
+	f, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(f, "GET /ipfs/%s/index.html HTTP/1.1\n", h)
+	}
+	f.Close()
+	round()
+
+	pin, err := clusters[0].PinGet(ctx, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin.ReplicationFactorMin != 2 || pin.ReplicationFactorMax != 2 {
+		t.Fatalf("expected replication to be increased: %d/%d", pin.ReplicationFactorMin, pin.ReplicationFactorMax)
+	}
+	if pin.Metadata[PopularityBoostMetaKey] != "1" {
+		t.Error("expected the boost to be recorded in the metadata")
+	}
+}
+
 func TestClustersRebalanceOnPeerDown(t *testing.T) {
 	ctx := context.Background()
 	if nClusters < 5 {
@@ -1876,6 +2161,18 @@ func TestClustersGraphConnected(t *testing.T) {
 		clusterIDs[id] = struct{}{}
 	}
 	validateClusterGraph(t, graph, clusterIDs, nClusters)
+
+	for id := range clusterIDs {
+		conns := graph.ClusterConnections[id]
+		if len(conns) != nClusters-1 {
+			t.Fatalf("expected %d connections for %s but got %d", nClusters-1, id, len(conns))
+		}
+		for _, conn := range conns {
+			if conn.Transport == "" || conn.Direction == "" {
+				t.Errorf("unexpected connection info: %+v", conn)
+			}
+		}
+	}
 }
 
 // Similar to the previous test we get a cluster graph report from a peer.
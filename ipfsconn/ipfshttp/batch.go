@@ -0,0 +1,139 @@
+package ipfshttp
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+// batcher coalesces the requests for single items made at the same time
+// into requests for several items. Items are grouped by key (i.e. the
+// arguments of the request), and each group is sent when it has size items
+// or after delay.
+//
+// Items whose context is canceled before their batch is sent are dropped
+// from it. When it is canceled while the batch is being sent, the item is
+// given to undo once the request succeeds (i.e. pins are unpinned), so that
+// a canceled pin does not land after the unpin that follows it.
+type batcher struct {
+	size  int
+	delay time.Duration
+	send  func(key string, cids []cid.Cid) error
+	undo  func(key string, cids []cid.Cid)
+
+	mu      sync.Mutex
+	pending map[string]*batch
+}
+
+type batch struct {
+	items    []*batchItem
+	sent     bool
+	finished bool
+	done     chan struct{}
+	err      error
+}
+
+type batchItem struct {
+	cid      cid.Cid
+	canceled bool
+}
+
+func newBatcher(size int, delay time.Duration, send func(key string, cids []cid.Cid) error, undo func(key string, cids []cid.Cid)) *batcher {
+	return &batcher{
+		size:    size,
+		delay:   delay,
+		send:    send,
+		undo:    undo,
+		pending: make(map[string]*batch),
+	}
+}
+
+// add queues an item and waits until the request including it is done. It
+// returns the number of items in that request and its error.
+func (b *batcher) add(ctx context.Context, key string, c cid.Cid) (int, error) {
+	item := &batchItem{cid: c}
+
+	b.mu.Lock()
+	bt, ok := b.pending[key]
+	if !ok {
+		bt = &batch{done: make(chan struct{})}
+		b.pending[key] = bt
+		time.AfterFunc(b.delay, func() { b.flush(key, bt) })
+	}
+	bt.items = append(bt.items, item)
+	if len(bt.items) >= b.size {
+		delete(b.pending, key)
+		bt.sent = true
+		go b.run(key, bt)
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-bt.done:
+		return len(bt.items), bt.err
+	case <-ctx.Done():
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case bt.finished:
+		return len(bt.items), bt.err
+	case !bt.sent:
+		for i, it := range bt.items {
+			if it == item {
+				bt.items = append(bt.items[:i], bt.items[i+1:]...)
+				break
+			}
+		}
+	default:
+		item.canceled = true
+	}
+	return 0, ctx.Err()
+}
+
+// flush sends the batch when it was not sent already for being full.
+func (b *batcher) flush(key string, bt *batch) {
+	b.mu.Lock()
+	if b.pending[key] != bt {
+		b.mu.Unlock()
+		return
+	}
+	delete(b.pending, key)
+	bt.sent = true
+	b.mu.Unlock()
+	b.run(key, bt)
+}
+
+func (b *batcher) run(key string, bt *batch) {
+	// Items are no longer removed once the batch is sent.
+	b.mu.Lock()
+	cids := make([]cid.Cid, len(bt.items))
+	for i, it := range bt.items {
+		cids[i] = it.cid
+	}
+	b.mu.Unlock()
+
+	var err error
+	if len(cids) > 0 {
+		err = b.send(key, cids)
+	}
+
+	b.mu.Lock()
+	bt.err = err
+	bt.finished = true
+	var canceled []cid.Cid
+	for _, it := range bt.items {
+		if it.canceled {
+			canceled = append(canceled, it.cid)
+		}
+	}
+	b.mu.Unlock()
+	close(bt.done)
+
+	if err == nil && len(canceled) > 0 && b.undo != nil {
+		b.undo(key, canceled)
+	}
+}
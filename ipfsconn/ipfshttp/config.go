@@ -4,10 +4,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"path/filepath"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
 
+	"github.com/ipfs/ipfs-cluster/api"
 	"github.com/ipfs/ipfs-cluster/config"
 
 	ma "github.com/multiformats/go-multiaddr"
@@ -25,16 +27,46 @@ const (
 	DefaultUnpinTimeout       = 3 * time.Hour
 	DefaultRepoGCTimeout      = 24 * time.Hour
 	DefaultUnpinDisable       = false
+	DefaultMaxIdleConns       = 100
+	DefaultPinBatchSize       = 0
+	DefaultPinBatchDelay      = 50 * time.Millisecond
+	DefaultProvideStrategy    = api.ProvideStrategyDefault
+	DefaultReprovideInterval  = time.Duration(0)
 )
 
+// TimeoutProfile is a named set of timeouts which pins can select with
+// their timeout_profile option. Unset timeouts keep the configured ones.
+type TimeoutProfile struct {
+	PinTimeout   time.Duration
+	UnpinTimeout time.Duration
+}
+
 // Config is used to initialize a Connector and allows to customize
 // its behaviour. It implements the config.ComponentConfig interface.
 type Config struct {
 	config.Saver
 
-	// Host/Port for the IPFS daemon.
+	// Host/Port for the IPFS daemon, or /unix/<path> for a daemon
+	// listening on a Unix domain socket.
 	NodeAddr ma.Multiaddr
 
+	// NodeHTTPS makes the connector talk to the IPFS API over HTTPS.
+	NodeHTTPS bool
+
+	// NodeCACertFile is a PEM file with the certificates of the
+	// authorities trusted, in addition to the system ones, when verifying
+	// the certificate of the IPFS API. Relative paths are relative to the
+	// configuration folder.
+	NodeCACertFile string
+
+	// NodeAuthorization is sent in the Authorization header of every
+	// request, i.e. "Bearer <token>" or "Basic <base64 credentials>" for
+	// daemons which restrict access to their API.
+	NodeAuthorization string
+
+	// NodeHeaders are additional headers sent with every request.
+	NodeHeaders map[string]string
+
 	// ConnectSwarmsDelay specifies how long to wait after startup before
 	// attempting to open connections from this peer's IPFS daemon to the
 	// IPFS daemons of other peers.
@@ -49,23 +81,66 @@ type Config struct {
 	// Unpin Operation timeout
 	UnpinTimeout time.Duration
 
+	// TimeoutProfiles are the timeouts which pins can select by name,
+	// i.e. short ones for a "fast" lane and long ones for "bulk" pins.
+	// Timeouts set by the pins themselves take precedence.
+	TimeoutProfiles map[string]TimeoutProfile
+
 	// RepoGC Operation timeout
 	RepoGCTimeout time.Duration
 	// Disables the unpin operation and returns an error.
 	UnpinDisable bool
 
+	// MaxIdleConns is the number of idle connections to the IPFS daemon
+	// which are kept open to be reused.
+	MaxIdleConns int
+
+	// PinBatchSize, when larger than 1, makes the pin and unpin requests
+	// made at the same time be sent to IPFS together, up to this many
+	// per request. Batches are sent when full or after PinBatchDelay.
+	PinBatchSize  int
+	PinBatchDelay time.Duration
+
+	// ProvideStrategy sets what is announced to the DHT once something
+	// is pinned, for the pins which do not set their own: the roots,
+	// all the blocks or nothing. When unset, announcing is left to IPFS
+	// entirely. The strategies only take effect when IPFS does not
+	// reprovide on its own (Reprovider.Interval set to "0") nor announce
+	// the blocks it fetches (Experimental.StrategicProviding).
+	ProvideStrategy api.ProvideStrategy
+
+	// ReprovideInterval is how often the pins allocated to this peer
+	// are announced again, as given by their strategies. 0 (the default)
+	// disables it.
+	ReprovideInterval time.Duration
+
 	// Tracing flag used to skip tracing specific paths when not enabled.
 	Tracing bool
 }
 
 type jsonConfig struct {
-	NodeMultiaddress   string `json:"node_multiaddress"`
-	ConnectSwarmsDelay string `json:"connect_swarms_delay"`
-	IPFSRequestTimeout string `json:"ipfs_request_timeout"`
-	PinTimeout         string `json:"pin_timeout"`
-	UnpinTimeout       string `json:"unpin_timeout"`
-	RepoGCTimeout      string `json:"repogc_timeout"`
-	UnpinDisable       bool   `json:"unpin_disable,omitempty"`
+	NodeMultiaddress   string                         `json:"node_multiaddress"`
+	NodeHTTPS          bool                           `json:"node_https,omitempty"`
+	NodeCACertFile     string                         `json:"node_ca_cert_file,omitempty"`
+	NodeAuthorization  string                         `json:"node_authorization,omitempty" hidden:"true"`
+	NodeHeaders        map[string]string              `json:"node_headers,omitempty"`
+	ConnectSwarmsDelay string                         `json:"connect_swarms_delay"`
+	IPFSRequestTimeout string                         `json:"ipfs_request_timeout"`
+	PinTimeout         string                         `json:"pin_timeout"`
+	UnpinTimeout       string                         `json:"unpin_timeout"`
+	TimeoutProfiles    map[string]*timeoutProfileJSON `json:"timeout_profiles,omitempty"`
+	RepoGCTimeout      string                         `json:"repogc_timeout"`
+	UnpinDisable       bool                           `json:"unpin_disable,omitempty"`
+	MaxIdleConns       int                            `json:"max_idle_conns"`
+	PinBatchSize       int                            `json:"pin_batch_size"`
+	PinBatchDelay      string                         `json:"pin_batch_delay"`
+	ProvideStrategy    string                         `json:"provide_strategy,omitempty"`
+	ReprovideInterval  string                         `json:"reprovide_interval"`
+}
+
+type timeoutProfileJSON struct {
+	PinTimeout   string `json:"pin_timeout,omitempty"`
+	UnpinTimeout string `json:"unpin_timeout,omitempty"`
 }
 
 // ConfigKey provides a human-friendly identifier for this type of Config.
@@ -77,12 +152,22 @@ func (cfg *Config) ConfigKey() string {
 func (cfg *Config) Default() error {
 	node, _ := ma.NewMultiaddr(DefaultNodeAddr)
 	cfg.NodeAddr = node
+	cfg.NodeHTTPS = false
+	cfg.NodeCACertFile = ""
+	cfg.NodeAuthorization = ""
+	cfg.NodeHeaders = nil
 	cfg.ConnectSwarmsDelay = DefaultConnectSwarmsDelay
 	cfg.IPFSRequestTimeout = DefaultIPFSRequestTimeout
 	cfg.PinTimeout = DefaultPinTimeout
 	cfg.UnpinTimeout = DefaultUnpinTimeout
+	cfg.TimeoutProfiles = nil
 	cfg.RepoGCTimeout = DefaultRepoGCTimeout
 	cfg.UnpinDisable = DefaultUnpinDisable
+	cfg.MaxIdleConns = DefaultMaxIdleConns
+	cfg.PinBatchSize = DefaultPinBatchSize
+	cfg.PinBatchDelay = DefaultPinBatchDelay
+	cfg.ProvideStrategy = DefaultProvideStrategy
+	cfg.ReprovideInterval = DefaultReprovideInterval
 
 	return nil
 }
@@ -111,6 +196,10 @@ func (cfg *Config) Validate() error {
 		err = errors.New("ipfshttp.node_multiaddress not set")
 	}
 
+	if cfg.NodeCACertFile != "" && !cfg.NodeHTTPS {
+		err = errors.New("ipfshttp.node_ca_cert_file needs node_https")
+	}
+
 	if cfg.ConnectSwarmsDelay < 0 {
 		err = errors.New("ipfshttp.connect_swarms_delay is invalid")
 	}
@@ -127,10 +216,36 @@ func (cfg *Config) Validate() error {
 		err = errors.New("ipfshttp.unpin_timeout invalid")
 	}
 
+	for name, prof := range cfg.TimeoutProfiles {
+		if name == "" || prof.PinTimeout < 0 || prof.UnpinTimeout < 0 {
+			err = fmt.Errorf("ipfshttp.timeout_profiles.%s invalid", name)
+		}
+	}
+
 	if cfg.RepoGCTimeout < 0 {
 		err = errors.New("ipfshttp.repogc_timeout invalid")
 	}
 
+	if cfg.MaxIdleConns < 0 {
+		err = errors.New("ipfshttp.max_idle_conns invalid")
+	}
+
+	if cfg.PinBatchSize < 0 {
+		err = errors.New("ipfshttp.pin_batch_size invalid")
+	}
+
+	if cfg.PinBatchDelay < 0 {
+		err = errors.New("ipfshttp.pin_batch_delay invalid")
+	}
+
+	if cfg.ProvideStrategy < api.ProvideStrategyDefault || cfg.ProvideStrategy > api.ProvideStrategyNone {
+		err = errors.New("ipfshttp.provide_strategy invalid")
+	}
+
+	if cfg.ReprovideInterval < 0 {
+		err = errors.New("ipfshttp.reprovide_interval invalid")
+	}
+
 	return err
 
 }
@@ -156,7 +271,17 @@ func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
 	}
 
 	cfg.NodeAddr = nodeAddr
+	cfg.NodeHTTPS = jcfg.NodeHTTPS
+	cfg.NodeCACertFile = jcfg.NodeCACertFile
+	cfg.NodeAuthorization = jcfg.NodeAuthorization
+	cfg.NodeHeaders = jcfg.NodeHeaders
 	cfg.UnpinDisable = jcfg.UnpinDisable
+	config.SetIfNotDefault(jcfg.MaxIdleConns, &cfg.MaxIdleConns)
+	cfg.PinBatchSize = jcfg.PinBatchSize
+	cfg.ProvideStrategy, err = api.ProvideStrategyFromString(jcfg.ProvideStrategy)
+	if err != nil {
+		return fmt.Errorf("error parsing provide_strategy: %s", err)
+	}
 
 	err = config.ParseDurations(
 		"ipfshttp",
@@ -165,11 +290,33 @@ func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
 		&config.DurationOpt{Duration: jcfg.PinTimeout, Dst: &cfg.PinTimeout, Name: "pin_timeout"},
 		&config.DurationOpt{Duration: jcfg.UnpinTimeout, Dst: &cfg.UnpinTimeout, Name: "unpin_timeout"},
 		&config.DurationOpt{Duration: jcfg.RepoGCTimeout, Dst: &cfg.RepoGCTimeout, Name: "repogc_timeout"},
+		&config.DurationOpt{Duration: jcfg.PinBatchDelay, Dst: &cfg.PinBatchDelay, Name: "pin_batch_delay"},
+		&config.DurationOpt{Duration: jcfg.ReprovideInterval, Dst: &cfg.ReprovideInterval, Name: "reprovide_interval"},
 	)
 	if err != nil {
 		return err
 	}
 
+	cfg.TimeoutProfiles = nil
+	for name, jprof := range jcfg.TimeoutProfiles {
+		if jprof == nil {
+			continue
+		}
+		var prof TimeoutProfile
+		err = config.ParseDurations(
+			"ipfshttp",
+			&config.DurationOpt{Duration: jprof.PinTimeout, Dst: &prof.PinTimeout, Name: "timeout_profiles." + name + ".pin_timeout"},
+			&config.DurationOpt{Duration: jprof.UnpinTimeout, Dst: &prof.UnpinTimeout, Name: "timeout_profiles." + name + ".unpin_timeout"},
+		)
+		if err != nil {
+			return err
+		}
+		if cfg.TimeoutProfiles == nil {
+			cfg.TimeoutProfiles = make(map[string]TimeoutProfile)
+		}
+		cfg.TimeoutProfiles[name] = prof
+	}
+
 	return cfg.Validate()
 }
 
@@ -196,16 +343,46 @@ func (cfg *Config) toJSONConfig() (jcfg *jsonConfig, err error) {
 
 	// Set all configuration fields
 	jcfg.NodeMultiaddress = cfg.NodeAddr.String()
+	jcfg.NodeHTTPS = cfg.NodeHTTPS
+	jcfg.NodeCACertFile = cfg.NodeCACertFile
+	jcfg.NodeAuthorization = cfg.NodeAuthorization
+	jcfg.NodeHeaders = cfg.NodeHeaders
 	jcfg.ConnectSwarmsDelay = cfg.ConnectSwarmsDelay.String()
 	jcfg.IPFSRequestTimeout = cfg.IPFSRequestTimeout.String()
 	jcfg.PinTimeout = cfg.PinTimeout.String()
 	jcfg.UnpinTimeout = cfg.UnpinTimeout.String()
+	for name, prof := range cfg.TimeoutProfiles {
+		jprof := &timeoutProfileJSON{}
+		if prof.PinTimeout > 0 {
+			jprof.PinTimeout = prof.PinTimeout.String()
+		}
+		if prof.UnpinTimeout > 0 {
+			jprof.UnpinTimeout = prof.UnpinTimeout.String()
+		}
+		if jcfg.TimeoutProfiles == nil {
+			jcfg.TimeoutProfiles = make(map[string]*timeoutProfileJSON)
+		}
+		jcfg.TimeoutProfiles[name] = jprof
+	}
 	jcfg.RepoGCTimeout = cfg.RepoGCTimeout.String()
 	jcfg.UnpinDisable = cfg.UnpinDisable
+	jcfg.MaxIdleConns = cfg.MaxIdleConns
+	jcfg.PinBatchSize = cfg.PinBatchSize
+	jcfg.PinBatchDelay = cfg.PinBatchDelay.String()
+	jcfg.ProvideStrategy = cfg.ProvideStrategy.String()
+	jcfg.ReprovideInterval = cfg.ReprovideInterval.String()
 
 	return
 }
 
+// getCACertPath returns the full path of NodeCACertFile.
+func (cfg *Config) getCACertPath() string {
+	if cfg.NodeCACertFile == "" || filepath.IsAbs(cfg.NodeCACertFile) || cfg.BaseDir == "" {
+		return cfg.NodeCACertFile
+	}
+	return filepath.Join(cfg.BaseDir, cfg.NodeCACertFile)
+}
+
 // ToDisplayJSON returns JSON config as a string.
 func (cfg *Config) ToDisplayJSON() ([]byte, error) {
 	jcfg, err := cfg.toJSONConfig()
@@ -5,16 +5,27 @@ import (
 	"os"
 	"testing"
 	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
 )
 
 var cfgJSON = []byte(`
 {
 	"node_multiaddress": "/ip4/127.0.0.1/tcp/5001",
+	"node_authorization": "Bearer token",
+	"node_headers": {"X-Tenant": "cluster"},
 	"connect_swarms_delay": "7s",
 	"ipfs_request_timeout": "5m0s",
 	"pin_timeout": "2m",
 	"unpin_timeout": "3h",
-	"repogc_timeout": "24h"
+	"repogc_timeout": "24h",
+	"pin_batch_size": 20,
+	"pin_batch_delay": "10ms",
+	"provide_strategy": "roots",
+	"reprovide_interval": "6h",
+	"timeout_profiles": {
+		"large": {"pin_timeout": "1h", "unpin_timeout": "10m"}
+	}
 }
 `)
 
@@ -24,6 +35,21 @@ func TestLoadJSON(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	if cfg.NodeAuthorization != "Bearer token" || cfg.NodeHeaders["X-Tenant"] != "cluster" {
+		t.Error("expected the authorization options to be parsed")
+	}
+	if cfg.PinBatchSize != 20 || cfg.PinBatchDelay != 10*time.Millisecond {
+		t.Error("expected the batching options to be parsed")
+	}
+	if cfg.MaxIdleConns != DefaultMaxIdleConns {
+		t.Error("expected the default max_idle_conns")
+	}
+	if cfg.ProvideStrategy != api.ProvideStrategyRoots || cfg.ReprovideInterval != 6*time.Hour {
+		t.Error("expected the provide options to be parsed")
+	}
+	if prof := cfg.TimeoutProfiles["large"]; prof.PinTimeout != time.Hour || prof.UnpinTimeout != 10*time.Minute {
+		t.Error("expected the timeout profiles to be parsed")
+	}
 
 	j := &jsonConfig{}
 	json.Unmarshal(cfgJSON, j)
@@ -33,6 +59,33 @@ func TestLoadJSON(t *testing.T) {
 	if err == nil {
 		t.Error("expected error in node_multiaddress")
 	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.PinBatchSize = -1
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error in pin_batch_size")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.ProvideStrategy = "everything"
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error in provide_strategy")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.TimeoutProfiles["large"].PinTimeout = "-1s"
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error in timeout_profiles")
+	}
 }
 
 func TestToJSON(t *testing.T) {
@@ -60,6 +113,18 @@ func TestDefault(t *testing.T) {
 	if cfg.Validate() == nil {
 		t.Fatal("expected error validating")
 	}
+
+	cfg.Default()
+	cfg.NodeCACertFile = "ca.pem"
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating: CA certificate without https")
+	}
+
+	cfg.Default()
+	cfg.TimeoutProfiles = map[string]TimeoutProfile{"": {PinTimeout: time.Minute}}
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating: unnamed timeout profile")
+	}
 }
 
 func TestApplyEnvVar(t *testing.T) {
@@ -4,11 +4,14 @@ package ipfshttp
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -54,14 +57,23 @@ type Connector struct {
 	ctx    context.Context
 	cancel func()
 
-	config   *Config
-	nodeAddr string
+	config     *Config
+	nodeScheme string
+	nodeAddr   string
+	headers    http.Header // sent with every request
 
 	rpcClient *rpc.Client
 	rpcReady  chan struct{}
 
 	client *http.Client // client to ipfs daemon
 
+	// coalesce pin/add and pin/rm requests when batching is enabled.
+	pinBatcher   *batcher
+	unpinBatcher *batcher
+
+	// pins waiting to be provided by the provide workers.
+	provideQueue chan *api.Pin
+
 	updateMetricMutex sync.Mutex
 	updateMetricCount int
 
@@ -93,9 +105,15 @@ type ipfsPinLsResp struct {
 	Keys map[string]ipfsPinType
 }
 
+type ipfsPinLsStreamResp struct {
+	Cid  string
+	Type string
+}
+
 type ipfsIDResp struct {
-	ID        string
-	Addresses []string
+	ID           string
+	Addresses    []string
+	AgentVersion string
 }
 
 type ipfsResolveResp struct {
@@ -121,6 +139,26 @@ type ipfsBlockPutResp struct {
 	Size int
 }
 
+type ipfsBlockStatResp struct {
+	Key  string
+	Size uint64
+}
+
+type ipfsBlockRmResp struct {
+	Hash  string
+	Error string
+}
+
+type ipfsObjectStatResp struct {
+	Hash           string
+	CumulativeSize uint64
+}
+
+type ipfsNamePublishResp struct {
+	Name  string
+	Value string
+}
+
 type ipfsPeer struct {
 	Peer string
 }
@@ -145,15 +183,37 @@ func NewConnector(cfg *Config) (*Connector, error) {
 		nodeMAddr = resolvedAddrs[0]
 	}
 
-	_, nodeAddr, err := manet.DialArgs(nodeMAddr)
+	network, nodeAddr, err := manet.DialArgs(nodeMAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := newTransport(cfg, network, nodeAddr)
 	if err != nil {
 		return nil, err
 	}
+	if network == "unix" {
+		// requests are sent to the socket, whatever the host.
+		nodeAddr = "unix"
+	}
 
-	c := &http.Client{} // timeouts are handled by context timeouts
+	nodeScheme := "http"
+	if cfg.NodeHTTPS {
+		nodeScheme = "https"
+	}
+
+	headers := make(http.Header)
+	for k, v := range cfg.NodeHeaders {
+		headers.Set(k, v)
+	}
+	if cfg.NodeAuthorization != "" {
+		headers.Set("Authorization", cfg.NodeAuthorization)
+	}
+
+	c := &http.Client{Transport: transport} // timeouts are handled by context timeouts
 	if cfg.Tracing {
 		c.Transport = &ochttp.Transport{
-			Base:           http.DefaultTransport,
+			Base:           transport,
 			Propagation:    &tracecontext.HTTPFormat{},
 			StartOptions:   trace.StartOptions{SpanKind: trace.SpanKindClient},
 			FormatSpanName: func(req *http.Request) string { return req.Host + ":" + req.URL.Path + ":" + req.Method },
@@ -164,18 +224,79 @@ func NewConnector(cfg *Config) (*Connector, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	ipfs := &Connector{
-		ctx:      ctx,
-		config:   cfg,
-		cancel:   cancel,
-		nodeAddr: nodeAddr,
-		rpcReady: make(chan struct{}, 1),
-		client:   c,
+		ctx:        ctx,
+		config:     cfg,
+		cancel:     cancel,
+		nodeScheme: nodeScheme,
+		nodeAddr:   nodeAddr,
+		headers:    headers,
+		rpcReady:   make(chan struct{}, 1),
+		client:     c,
+	}
+
+	ipfs.provideQueue = make(chan *api.Pin, provideQueueSize)
+	for i := 0; i < provideWorkers; i++ {
+		ipfs.wg.Add(1)
+		go ipfs.provideWorker()
+	}
+
+	if cfg.PinBatchSize > 1 {
+		ipfs.pinBatcher = newBatcher(cfg.PinBatchSize, cfg.PinBatchDelay, func(args string, cids []cid.Cid) error {
+			return ipfs.pinWithTimeout(ipfs.ctx, cids, args, cfg.PinTimeout)
+		}, func(_ string, cids []cid.Cid) {
+			// Pins canceled while being sent.
+			if err := ipfs.unpin(ipfs.ctx, cids, cfg.UnpinTimeout); err != nil {
+				logger.Warnf("error unpinning %d canceled pins: %s", len(cids), err)
+			}
+		})
+		ipfs.unpinBatcher = newBatcher(cfg.PinBatchSize, cfg.PinBatchDelay, func(_ string, cids []cid.Cid) error {
+			return ipfs.unpin(ipfs.ctx, cids, cfg.UnpinTimeout)
+		}, nil)
 	}
 
 	go ipfs.run()
 	return ipfs, nil
 }
 
+// newTransport returns the transport used to reach the IPFS daemon at the
+// given address, which can be a Unix domain socket, trusting the configured
+// certificate authorities for HTTPS.
+func newTransport(cfg *Config, network, addr string) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// All requests go to the same daemon, so the idle connections are
+	// kept for it rather than closed after the second one.
+	transport.MaxIdleConnsPerHost = cfg.MaxIdleConns
+	if cfg.MaxIdleConns > transport.MaxIdleConns {
+		transport.MaxIdleConns = cfg.MaxIdleConns
+	}
+
+	if network == "unix" {
+		var d net.Dialer
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return d.DialContext(ctx, "unix", addr)
+		}
+	}
+
+	if caFile := cfg.getCACertPath(); caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading ipfshttp.node_ca_cert_file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("ipfshttp.node_ca_cert_file contains no certificates")
+		}
+		transport.TLSClientConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			RootCAs:    pool,
+		}
+	}
+	return transport, nil
+}
+
 // connects all ipfs daemons when
 // we receive the rpcReady signal.
 func (ipfs *Connector) run() {
@@ -186,6 +307,14 @@ func (ipfs *Connector) run() {
 	ipfs.shutdownLock.Lock()
 	defer ipfs.shutdownLock.Unlock()
 
+	if ipfs.config.ProvideStrategy != api.ProvideStrategyDefault {
+		go ipfs.checkReprovider()
+	}
+	if ipfs.config.ReprovideInterval > 0 {
+		ipfs.wg.Add(1)
+		go ipfs.watchReprovide()
+	}
+
 	if ipfs.config.ConnectSwarmsDelay == 0 {
 		return
 	}
@@ -271,7 +400,8 @@ func (ipfs *Connector) ID(ctx context.Context) (*api.IPFSID, error) {
 	}
 
 	id := &api.IPFSID{
-		ID: pID,
+		ID:           pID,
+		AgentVersion: res.AgentVersion,
 	}
 
 	mAddrs := make([]api.Multiaddr, len(res.Addresses))
@@ -301,6 +431,46 @@ func pinArgs(maxDepth api.PinDepth) string {
 	return q.Encode()
 }
 
+// cidArgs returns the query arguments for a request about the given items.
+func cidArgs(cids []cid.Cid) string {
+	q := url.Values{}
+	for _, c := range cids {
+		q.Add("arg", c.String())
+	}
+	return q.Encode()
+}
+
+// timeouts returns the pin and unpin timeouts of a pin: the ones it sets,
+// those of its timeout profile or the configured ones, in that order.
+func (ipfs *Connector) timeouts(pin *api.Pin) (time.Duration, time.Duration) {
+	pinTimeout, unpinTimeout := ipfs.config.PinTimeout, ipfs.config.UnpinTimeout
+	pt, ut, profile, err := pin.Timeouts()
+	if err != nil {
+		logger.Warnf("using the default timeouts for %s: %s", pin.Cid, err)
+		return pinTimeout, unpinTimeout
+	}
+
+	if profile != "" {
+		prof, ok := ipfs.config.TimeoutProfiles[profile]
+		if !ok {
+			logger.Warnf("using the default timeouts for %s: unknown timeout profile %q", pin.Cid, profile)
+		}
+		if prof.PinTimeout > 0 {
+			pinTimeout = prof.PinTimeout
+		}
+		if prof.UnpinTimeout > 0 {
+			unpinTimeout = prof.UnpinTimeout
+		}
+	}
+	if pt > 0 {
+		pinTimeout = pt
+	}
+	if ut > 0 {
+		unpinTimeout = ut
+	}
+	return pinTimeout, unpinTimeout
+}
+
 // Pin performs a pin request against the configured IPFS
 // daemon.
 func (ipfs *Connector) Pin(ctx context.Context, pin *api.Pin) error {
@@ -358,22 +528,62 @@ func (ipfs *Connector) Pin(ctx context.Context, pin *api.Pin) error {
 			// As a side note, if PinUpdate == pin.Cid, we are
 			// somehow pinning an already pinned thing and we'd
 			// better use update for that
-			return ipfs.pinUpdate(ctx, from, pin.Cid)
+			err := ipfs.pinUpdate(ctx, from, pin.Cid)
+			if err == nil {
+				ipfs.announce(pin)
+			}
+			return err
 		}
 	}
 
+	// Items with their own timeouts are not batched with the rest.
+	pinTimeout, _ := ipfs.timeouts(pin)
+	if ipfs.pinBatcher != nil && pinTimeout == ipfs.config.PinTimeout {
+		err = ipfs.pinBatched(ctx, hash, maxDepth)
+	} else {
+		err = ipfs.pinWithTimeout(ctx, []cid.Cid{hash}, pinArgs(maxDepth), pinTimeout)
+	}
+	if err != nil {
+		return err
+	}
+
+	api.RequestLogger(ctx, logger).Info("IPFS Pin request succeeded: ", hash)
+	stats.Record(ctx, observations.Pins.M(1))
+	ipfs.announce(pin)
+	return nil
+}
+
+// pinBatched pins an item in the same request as the others pinned at the
+// same time with the same depth. When that request fails, the item is
+// pinned on its own so that a single bad item does not fail the rest.
+func (ipfs *Connector) pinBatched(ctx context.Context, hash cid.Cid, maxDepth api.PinDepth) error {
+	args := pinArgs(maxDepth)
+	n, err := ipfs.pinBatcher.add(ctx, args, hash)
+	if err == nil || n <= 1 || ctx.Err() != nil {
+		return err
+	}
+	logger.Debugf("batched pin of %d items failed, pinning %s alone: %s", n, hash, err)
+	return ipfs.pinWithTimeout(ctx, []cid.Cid{hash}, args, ipfs.config.PinTimeout)
+}
+
+// pinWithTimeout pins the given items with a single request, which is
+// canceled when there is no progress for the given timeout.
+func (ipfs *Connector) pinWithTimeout(ctx context.Context, hashes []cid.Cid, pinArgs string, timeout time.Duration) error {
+	ctx, cancelRequest := context.WithCancel(ctx)
+	defer cancelRequest()
+
 	// Pin request and timeout if there is no progress
 	outPins := make(chan int)
 	go func() {
 		var lastProgress int
 		lastProgressTime := time.Now()
 
-		ticker := time.NewTicker(ipfs.config.PinTimeout)
+		ticker := time.NewTicker(timeout)
 		defer ticker.Stop()
 		for {
 			select {
 			case <-ticker.C:
-				if time.Since(lastProgressTime) > ipfs.config.PinTimeout {
+				if time.Since(lastProgressTime) > timeout {
 					// timeout request
 					cancelRequest()
 					return
@@ -393,27 +603,19 @@ func (ipfs *Connector) Pin(ctx context.Context, pin *api.Pin) error {
 		}
 	}()
 
-	err = ipfs.pinProgress(ctx, hash, maxDepth, outPins)
-	if err != nil {
-		return err
-	}
-
-	logger.Info("IPFS Pin request succeeded: ", hash)
-	stats.Record(ctx, observations.Pins.M(1))
-	return nil
+	return ipfs.pinProgress(ctx, hashes, pinArgs, outPins)
 }
 
-// pinProgress pins an item and sends fetched node's progress on a
+// pinProgress pins the items and sends fetched node's progress on a
 // channel. Blocks until done or error. pinProgress will always close the out
 // channel.  pinProgress will not block on sending to the channel if it is full.
-func (ipfs *Connector) pinProgress(ctx context.Context, hash cid.Cid, maxDepth api.PinDepth, out chan<- int) error {
+func (ipfs *Connector) pinProgress(ctx context.Context, hashes []cid.Cid, pinArgs string, out chan<- int) error {
 	defer close(out)
 
 	ctx, span := trace.StartSpan(ctx, "ipfsconn/ipfshttp/pinsProgress")
 	defer span.End()
 
-	pinArgs := pinArgs(maxDepth)
-	path := fmt.Sprintf("pin/add?arg=%s&%s&progress=true", hash, pinArgs)
+	path := fmt.Sprintf("pin/add?%s&%s&progress=true", cidArgs(hashes), pinArgs)
 	res, err := ipfs.doPostCtx(ctx, ipfs.client, ipfs.apiURL(), path, "", nil)
 	if err != nil {
 		return err
@@ -465,7 +667,7 @@ func (ipfs *Connector) pinUpdate(ctx context.Context, from, to cid.Cid) error {
 
 // Unpin performs an unpin request against the configured IPFS
 // daemon.
-func (ipfs *Connector) Unpin(ctx context.Context, hash cid.Cid) error {
+func (ipfs *Connector) Unpin(ctx context.Context, pin *api.Pin) error {
 	ctx, span := trace.StartSpan(ctx, "ipfsconn/ipfshttp/Unpin")
 	defer span.End()
 
@@ -475,14 +677,23 @@ func (ipfs *Connector) Unpin(ctx context.Context, hash cid.Cid) error {
 
 	defer ipfs.updateInformerMetric(ctx)
 
-	path := fmt.Sprintf("pin/rm?arg=%s", hash)
-
-	ctx, cancel := context.WithTimeout(ctx, ipfs.config.UnpinTimeout)
-	defer cancel()
+	hash := pin.Cid
+	_, unpinTimeout := ipfs.timeouts(pin)
 
 	// We will call unpin in any case, if the CID is not pinned,
 	// then we ignore the error (although this is a bit flaky).
-	_, err := ipfs.postCtx(ctx, path, "", nil)
+	var err error
+	if ipfs.unpinBatcher != nil && unpinTimeout == ipfs.config.UnpinTimeout {
+		var n int
+		n, err = ipfs.unpinBatcher.add(ctx, "", hash)
+		// The whole request fails when one of the items is not
+		// pinned.
+		if err != nil && n > 1 && ctx.Err() == nil {
+			err = ipfs.unpin(ctx, []cid.Cid{hash}, unpinTimeout)
+		}
+	} else {
+		err = ipfs.unpin(ctx, []cid.Cid{hash}, unpinTimeout)
+	}
 	if err != nil {
 		ipfsErr, ok := err.(ipfsError)
 		if !ok || ipfsErr.Message != ipfspinner.ErrNotPinned.Error() {
@@ -492,39 +703,58 @@ func (ipfs *Connector) Unpin(ctx context.Context, hash cid.Cid) error {
 		return nil
 	}
 
-	logger.Info("IPFS Unpin request succeeded:", hash)
+	api.RequestLogger(ctx, logger).Info("IPFS Unpin request succeeded:", hash)
 	stats.Record(ctx, observations.Pins.M(-1))
 	return nil
 }
 
-// PinLs performs a "pin ls --type typeFilter" request against the configured
-// IPFS daemon and returns a map of cid strings and their status.
+// unpin unpins the given items with a single request.
+func (ipfs *Connector) unpin(ctx context.Context, hashes []cid.Cid, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	_, err := ipfs.postCtx(ctx, "pin/rm?"+cidArgs(hashes), "", nil)
+	return err
+}
+
+// PinLs performs a "pin ls --type typeFilter --stream" request against the
+// configured IPFS daemon and returns a map of cid strings and their status.
+// With --stream, the daemon sends the pins as it finds them instead of
+// building the whole list in memory first.
 func (ipfs *Connector) PinLs(ctx context.Context, typeFilter string) (map[string]api.IPFSPinStatus, error) {
 	ctx, span := trace.StartSpan(ctx, "ipfsconn/ipfshttp/PinLs")
 	defer span.End()
 
 	ctx, cancel := context.WithTimeout(ctx, ipfs.config.IPFSRequestTimeout)
 	defer cancel()
-	body, err := ipfs.postCtx(ctx, "pin/ls?type="+typeFilter, "", nil)
 
+	path := "pin/ls?stream=true&type=" + typeFilter
+	res, err := ipfs.doPostCtx(ctx, ipfs.client, ipfs.apiURL(), path, "", nil)
 	// Some error talking to the daemon
 	if err != nil {
 		return nil, err
 	}
+	defer res.Body.Close()
 
-	var res ipfsPinLsResp
-	err = json.Unmarshal(body, &res)
+	_, err = checkResponse(path, res)
 	if err != nil {
-		logger.Error("parsing pin/ls response")
-		logger.Error(string(body))
 		return nil, err
 	}
 
 	statusMap := make(map[string]api.IPFSPinStatus)
-	for k, v := range res.Keys {
-		statusMap[k] = api.IPFSPinStatusFromString(v.Type)
+	dec := json.NewDecoder(res.Body)
+	for {
+		var pin ipfsPinLsStreamResp
+		err := dec.Decode(&pin)
+		if err == io.EOF {
+			return statusMap, nil
+		}
+		if err != nil {
+			logger.Errorf("parsing pin/ls response: %s", err)
+			return nil, err
+		}
+		statusMap[pin.Cid] = api.IPFSPinStatusFromString(pin.Type)
 	}
-	return statusMap, nil
 }
 
 // PinLsCid performs a "pin ls <hash>" request. It will use "type=recursive" or
@@ -577,6 +807,9 @@ func (ipfs *Connector) doPostCtx(ctx context.Context, client *http.Client, apiUR
 		logger.Error("error creating POST request:", err)
 	}
 
+	for k, v := range ipfs.headers {
+		req.Header[k] = v
+	}
 	req.Header.Set("Content-Type", contentType)
 	req = req.WithContext(ctx)
 	res, err := ipfs.client.Do(req)
@@ -638,7 +871,7 @@ func (ipfs *Connector) postCtx(ctx context.Context, path string, contentType str
 // apiURL is a short-hand for building the url of the IPFS
 // daemon API.
 func (ipfs *Connector) apiURL() string {
-	return fmt.Sprintf("http://%s/api/v0", ipfs.nodeAddr)
+	return fmt.Sprintf("%s://%s/api/v0", ipfs.nodeScheme, ipfs.nodeAddr)
 }
 
 // ConnectSwarms requests the ipfs addresses of other peers and
@@ -946,6 +1179,108 @@ func (ipfs *Connector) BlockGet(ctx context.Context, c cid.Cid) ([]byte, error)
 	return ipfs.postCtx(ctx, url, "", nil)
 }
 
+// BlockGetLocal retrieves an ipfs block with the given cid from the
+// repository of the daemon. It fails when the block is not there instead
+// of fetching it from the network.
+func (ipfs *Connector) BlockGetLocal(ctx context.Context, c cid.Cid) ([]byte, error) {
+	ctx, span := trace.StartSpan(ctx, "ipfsconn/ipfshttp/BlockGetLocal")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, ipfs.config.IPFSRequestTimeout)
+	defer cancel()
+	url := "block/get?offline=true&arg=" + c.String()
+	return ipfs.postCtx(ctx, url, "", nil)
+}
+
+// BlockRm removes a block from the IPFS repo.
+func (ipfs *Connector) BlockRm(ctx context.Context, c cid.Cid) error {
+	ctx, span := trace.StartSpan(ctx, "ipfsconn/ipfshttp/BlockRm")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, ipfs.config.IPFSRequestTimeout)
+	defer cancel()
+	defer ipfs.updateInformerMetric(ctx)
+
+	body, err := ipfs.postCtx(ctx, "block/rm?arg="+c.String(), "", nil)
+	if err != nil {
+		return err
+	}
+
+	// Errors removing a block (i.e. because it is pinned) are
+	// reported in the response rather than with an error status.
+	var res ipfsBlockRmResp
+	err = json.Unmarshal(body, &res)
+	if err != nil {
+		return err
+	}
+	if res.Error != "" {
+		return fmt.Errorf("error removing %s: %s", c, res.Error)
+	}
+	return nil
+}
+
+// DAGSize returns the cumulative size of a DAG as reported by "object stat".
+// Only the root block needs to be fetched by IPFS. Raw blocks are supported
+// by using "block stat" instead.
+func (ipfs *Connector) DAGSize(ctx context.Context, c cid.Cid) (uint64, error) {
+	ctx, span := trace.StartSpan(ctx, "ipfsconn/ipfshttp/DAGSize")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, ipfs.config.IPFSRequestTimeout)
+	defer cancel()
+
+	switch c.Type() {
+	case cid.Raw:
+		res, err := ipfs.postCtx(ctx, "block/stat?arg="+c.String(), "", nil)
+		if err != nil {
+			return 0, err
+		}
+		var stat ipfsBlockStatResp
+		err = json.Unmarshal(res, &stat)
+		if err != nil {
+			return 0, err
+		}
+		return stat.Size, nil
+	case cid.DagProtobuf:
+		res, err := ipfs.postCtx(ctx, "object/stat?arg="+c.String(), "", nil)
+		if err != nil {
+			return 0, err
+		}
+		var stat ipfsObjectStatResp
+		err = json.Unmarshal(res, &stat)
+		if err != nil {
+			return 0, err
+		}
+		return stat.CumulativeSize, nil
+	default:
+		return 0, fmt.Errorf("cannot obtain the DAG size for codec %d", c.Type())
+	}
+}
+
+// NamePublish publishes a CID to IPNS with the given key, which must exist
+// in the IPFS daemon. It returns the IPNS name.
+func (ipfs *Connector) NamePublish(ctx context.Context, c cid.Cid, key string) (string, error) {
+	ctx, span := trace.StartSpan(ctx, "ipfsconn/ipfshttp/NamePublish")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, ipfs.config.IPFSRequestTimeout)
+	defer cancel()
+
+	q := url.Values{}
+	q.Set("arg", "/ipfs/"+c.String())
+	q.Set("key", key)
+	res, err := ipfs.postCtx(ctx, "name/publish?"+q.Encode(), "", nil)
+	if err != nil {
+		return "", err
+	}
+	var resp ipfsNamePublishResp
+	err = json.Unmarshal(res, &resp)
+	if err != nil {
+		return "", err
+	}
+	return resp.Name, nil
+}
+
 // // FetchRefs asks IPFS to download blocks recursively to the given depth.
 // // It discards the response, but waits until it completes.
 // func (ipfs *Connector) FetchRefs(ctx context.Context, c cid.Cid, maxDepth int) error {
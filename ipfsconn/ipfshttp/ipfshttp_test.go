@@ -3,10 +3,20 @@ package ipfshttp
 import (
 	"bytes"
 	"context"
+	"encoding/pem"
 	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
+	cid "github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log/v2"
 	ma "github.com/multiformats/go-multiaddr"
 
@@ -20,6 +30,10 @@ func init() {
 }
 
 func testIPFSConnector(t *testing.T) (*Connector, *test.IpfsMock) {
+	return testIPFSConnectorWithConfig(t, func(*Config) {})
+}
+
+func testIPFSConnectorWithConfig(t *testing.T, setCfg func(*Config)) (*Connector, *test.IpfsMock) {
 	mock := test.NewIpfsMock(t)
 	nodeMAddr := ma.StringCast(fmt.Sprintf("/ip4/%s/tcp/%d", mock.Addr, mock.Port))
 
@@ -27,6 +41,7 @@ func testIPFSConnector(t *testing.T) (*Connector, *test.IpfsMock) {
 	cfg.Default()
 	cfg.NodeAddr = nodeMAddr
 	cfg.ConnectSwarmsDelay = 0
+	setCfg(cfg)
 
 	ipfs, err := NewConnector(cfg)
 	if err != nil {
@@ -139,18 +154,72 @@ func TestPinUpdate(t *testing.T) {
 	}
 }
 
+// waitForCount waits for the mock to receive n requests to an endpoint.
+func waitForCount(t *testing.T, mock *test.IpfsMock, endp string, n int) {
+	t.Helper()
+	for i := 0; i < 50; i++ {
+		if mock.GetCount(endp) >= n {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if c := mock.GetCount(endp); c != n {
+		t.Errorf("expected %d %s requests: %d", n, endp, c)
+	}
+}
+
+func TestProvideStrategy(t *testing.T) {
+	ctx := context.Background()
+	ipfs, mock := testIPFSConnectorWithConfig(t, func(cfg *Config) {
+		cfg.ProvideStrategy = api.ProvideStrategyNone
+	})
+	defer mock.Close()
+	defer ipfs.Shutdown(ctx)
+
+	roots := api.PinCid(test.Cid1)
+	roots.ProvideStrategy = api.ProvideStrategyRoots
+	if err := ipfs.Pin(ctx, roots); err != nil {
+		t.Fatal(err)
+	}
+	waitForCount(t, mock, "routing/provide", 1)
+
+	// The configured strategy applies.
+	if err := ipfs.Pin(ctx, api.PinCid(test.Cid2)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	waitForCount(t, mock, "routing/provide", 1)
+
+	// None of the pins in the shared state set a strategy.
+	n, err := ipfs.reprovide(ctx)
+	if err != nil || n != 0 {
+		t.Fatal("expected nothing to be reprovided:", n, err)
+	}
+
+	// Only the pins allocated to this peer are reprovided.
+	ipfs.config.ProvideStrategy = api.ProvideStrategyAll
+	n, err = ipfs.reprovide(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Error("expected 2 pins to be reprovided:", n)
+	}
+	waitForCount(t, mock, "routing/provide", 3)
+}
+
 func TestIPFSUnpin(t *testing.T) {
 	ctx := context.Background()
 	ipfs, mock := testIPFSConnector(t)
 	defer mock.Close()
 	defer ipfs.Shutdown(ctx)
 	c := test.Cid1
-	err := ipfs.Unpin(ctx, c)
+	err := ipfs.Unpin(ctx, api.PinCid(c))
 	if err != nil {
 		t.Error("expected success unpinning non-pinned cid")
 	}
 	ipfs.Pin(ctx, api.PinCid(c))
-	err = ipfs.Unpin(ctx, c)
+	err = ipfs.Unpin(ctx, api.PinCid(c))
 	if err != nil {
 		t.Error("expected success unpinning pinned cid")
 	}
@@ -167,12 +236,221 @@ func TestIPFSUnpinDisabled(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	err = ipfs.Unpin(ctx, test.Cid1)
+	err = ipfs.Unpin(ctx, api.PinCid(test.Cid1))
 	if err == nil {
 		t.Fatal("pin should be disabled")
 	}
 }
 
+func TestIPFSTimeouts(t *testing.T) {
+	ctx := context.Background()
+	ipfs, mock := testIPFSConnectorWithConfig(t, func(cfg *Config) {
+		cfg.TimeoutProfiles = map[string]TimeoutProfile{
+			"large": {PinTimeout: time.Hour},
+		}
+	})
+	defer mock.Close()
+	defer ipfs.Shutdown(ctx)
+
+	check := func(pin *api.Pin, pinTimeout, unpinTimeout time.Duration) {
+		t.Helper()
+		pt, ut := ipfs.timeouts(pin)
+		if pt != pinTimeout || ut != unpinTimeout {
+			t.Errorf("unexpected timeouts: %s/%s", pt, ut)
+		}
+	}
+
+	pin := api.PinCid(test.Cid1)
+	check(pin, ipfs.config.PinTimeout, ipfs.config.UnpinTimeout)
+
+	pin.TimeoutProfile = "large"
+	check(pin, time.Hour, ipfs.config.UnpinTimeout)
+
+	pin.UnpinTimeout = time.Minute
+	check(pin, time.Hour, time.Minute)
+
+	pin.PinTimeout = 2 * time.Minute
+	check(pin, 2*time.Minute, time.Minute)
+
+	pin = api.PinCid(test.Cid1)
+	pin.TimeoutProfile = "unknown"
+	check(pin, ipfs.config.PinTimeout, ipfs.config.UnpinTimeout)
+
+	pin = api.PinCid(test.Cid1)
+	pin.Metadata = map[string]string{api.PinTimeoutMetaKey: "30s"}
+	check(pin, 30*time.Second, ipfs.config.UnpinTimeout)
+
+	if err := ipfs.Pin(ctx, pin); err != nil {
+		t.Fatal(err)
+	}
+	if err := ipfs.Unpin(ctx, pin); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testBatchingIPFSConnector(t *testing.T) (*Connector, *test.IpfsMock) {
+	return testIPFSConnectorWithConfig(t, func(cfg *Config) {
+		cfg.PinBatchSize = 3
+		cfg.PinBatchDelay = 100 * time.Millisecond
+	})
+}
+
+// pinAll pins the given items concurrently and returns the errors.
+func pinAll(ctx context.Context, ipfs *Connector, cids []cid.Cid) []error {
+	errs := make([]error, len(cids))
+	var wg sync.WaitGroup
+	for i, c := range cids {
+		wg.Add(1)
+		go func(i int, c cid.Cid) {
+			defer wg.Done()
+			errs[i] = ipfs.Pin(ctx, api.PinCid(c))
+		}(i, c)
+	}
+	wg.Wait()
+	return errs
+}
+
+func TestPinBatched(t *testing.T) {
+	ctx := context.Background()
+	ipfs, mock := testBatchingIPFSConnector(t)
+	defer mock.Close()
+	defer ipfs.Shutdown(ctx)
+
+	cids := []cid.Cid{test.Cid1, test.Cid2, test.Cid3}
+	for _, err := range pinAll(ctx, ipfs, cids) {
+		if err != nil {
+			t.Error("expected success pinning:", err)
+		}
+	}
+	if n := mock.GetCount("pin/add"); n != 1 {
+		t.Error("expected a single pin/add request:", n)
+	}
+
+	pins, err := ipfs.PinLs(ctx, "recursive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != len(cids) {
+		t.Error("expected all the items to be pinned:", pins)
+	}
+}
+
+func TestPinBatchedError(t *testing.T) {
+	ctx := context.Background()
+	ipfs, mock := testBatchingIPFSConnector(t)
+	defer mock.Close()
+	defer ipfs.Shutdown(ctx)
+
+	errs := pinAll(ctx, ipfs, []cid.Cid{test.Cid1, test.ErrorCid})
+	if errs[0] != nil {
+		t.Error("expected Cid1 to be pinned on its own:", errs[0])
+	}
+	if errs[1] == nil {
+		t.Error("expected an error pinning ErrorCid")
+	}
+	// The batch, and then each item on its own.
+	if n := mock.GetCount("pin/add"); n != 3 {
+		t.Error("expected 3 pin/add requests:", n)
+	}
+}
+
+func TestBatcherCanceled(t *testing.T) {
+	sent := make(chan []cid.Cid, 1)
+	release := make(chan struct{})
+	undone := make(chan []cid.Cid, 1)
+	b := newBatcher(10, 50*time.Millisecond, func(_ string, cids []cid.Cid) error {
+		sent <- cids
+		<-release
+		return nil
+	}, func(_ string, cids []cid.Cid) {
+		undone <- cids
+	})
+
+	// Items canceled before the batch is sent are dropped.
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	go func() {
+		_, err := b.add(ctx, "", test.Cid1)
+		errs <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	if err := <-errs; err != context.Canceled {
+		t.Fatal("expected a canceled error:", err)
+	}
+	go func() {
+		_, err := b.add(context.Background(), "", test.Cid2)
+		errs <- err
+	}()
+	cids := <-sent
+	if len(cids) != 1 || !cids[0].Equals(test.Cid2) {
+		t.Error("expected only the item which was not canceled:", cids)
+	}
+	close(release)
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+
+	// Items canceled while the batch is sent are undone.
+	release = make(chan struct{})
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		_, err := b.add(ctx, "", test.Cid3)
+		errs <- err
+	}()
+	<-sent
+	cancel()
+	if err := <-errs; err != context.Canceled {
+		t.Fatal("expected a canceled error:", err)
+	}
+	close(release)
+	select {
+	case cids := <-undone:
+		if len(cids) != 1 || !cids[0].Equals(test.Cid3) {
+			t.Error("expected the canceled item to be undone:", cids)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected the canceled item to be undone")
+	}
+}
+
+func TestIPFSUnpinBatched(t *testing.T) {
+	ctx := context.Background()
+	ipfs, mock := testBatchingIPFSConnector(t)
+	defer mock.Close()
+	defer ipfs.Shutdown(ctx)
+
+	cids := []cid.Cid{test.Cid1, test.Cid2, test.Cid3}
+	for _, err := range pinAll(ctx, ipfs, cids) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range cids {
+		wg.Add(1)
+		go func(c cid.Cid) {
+			defer wg.Done()
+			if err := ipfs.Unpin(ctx, api.PinCid(c)); err != nil {
+				t.Error(err)
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	if n := mock.GetCount("pin/rm"); n != 1 {
+		t.Error("expected a single pin/rm request:", n)
+	}
+	pins, err := ipfs.PinLs(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 0 {
+		t.Error("expected everything to be unpinned:", pins)
+	}
+}
+
 func TestIPFSPinLsCid(t *testing.T) {
 	ctx := context.Background()
 	ipfs, mock := testIPFSConnector(t)
@@ -239,6 +517,14 @@ func TestIPFSPinLs(t *testing.T) {
 	if !ipsMap[test.Cid1.String()].IsPinned(-1) || !ipsMap[test.Cid2.String()].IsPinned(-1) {
 		t.Error("c1 and c2 should appear pinned")
 	}
+
+	ipsMap, err = ipfs.PinLs(ctx, "direct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ipsMap) != 0 {
+		t.Error("expected no direct pins:", ipsMap)
+	}
 }
 
 func TestIPFSShutdown(t *testing.T) {
@@ -343,6 +629,61 @@ func TestBlockGet(t *testing.T) {
 	}
 }
 
+func TestBlockRm(t *testing.T) {
+	ctx := context.Background()
+	ipfs, mock := testIPFSConnector(t)
+	defer mock.Close()
+	defer ipfs.Shutdown(ctx)
+
+	err := ipfs.BlockPut(ctx, &api.NodeWithMeta{
+		Data: test.ShardData,
+		Cid:  test.ShardCid,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ipfs.BlockRm(ctx, test.ShardCid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ipfs.BlockGet(ctx, test.ShardCid)
+	if err == nil {
+		t.Error("expected the block to be removed")
+	}
+
+	err = ipfs.BlockRm(ctx, test.ShardCid)
+	if err == nil {
+		t.Error("expected an error removing a missing block")
+	}
+}
+
+func TestDAGSize(t *testing.T) {
+	ctx := context.Background()
+	ipfs, mock := testIPFSConnector(t)
+	defer mock.Close()
+	defer ipfs.Shutdown(ctx)
+
+	size, err := ipfs.DAGSize(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// See the ipfs mock implementation
+	if size != 1<<20 {
+		t.Error("expected 1MB of size:", size)
+	}
+
+	_, err = ipfs.DAGSize(ctx, test.ErrorCid)
+	if err == nil {
+		t.Error("expected an error")
+	}
+
+	_, err = ipfs.DAGSize(ctx, test.ShardCid)
+	if err == nil {
+		t.Error("expected an error for an unsupported codec")
+	}
+}
+
 func TestRepoStat(t *testing.T) {
 	ctx := context.Background()
 	ipfs, mock := testIPFSConnector(t)
@@ -461,3 +802,119 @@ func TestRepoGC(t *testing.T) {
 		t.Errorf("expected different error, expected: %s, found: %s\n", merkledag.ErrLinkNotFound, res.Keys[4].Error)
 	}
 }
+
+func TestNamePublish(t *testing.T) {
+	ctx := context.Background()
+	ipfs, mock := testIPFSConnector(t)
+	defer mock.Close()
+	defer ipfs.Shutdown(ctx)
+
+	name, err := ipfs.NamePublish(ctx, test.Cid1, "self")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// See the ipfs mock implementation
+	if name != test.PeerID1.String() {
+		t.Error("unexpected name:", name)
+	}
+
+	_, err = ipfs.NamePublish(ctx, test.ErrorCid, "self")
+	if err == nil {
+		t.Error("expected an error")
+	}
+}
+
+// authProxy forwards the requests with the expected Authorization header
+// to the mock.
+func authProxy(mock *test.IpfsMock, token string) http.Handler {
+	target, _ := url.Parse(fmt.Sprintf("http://%s:%d", mock.Addr, mock.Port))
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token || r.Header.Get("X-Tenant") != "cluster" {
+			http.Error(w, "unauthorized", http.StatusForbidden)
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	})
+}
+
+func TestUnixSocketAndAuthorization(t *testing.T) {
+	ctx := context.Background()
+	mock := test.NewIpfsMock(t)
+	defer mock.Close()
+
+	sock := filepath.Join(t.TempDir(), "api.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: authProxy(mock, "secret")}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	for token, ok := range map[string]bool{"secret": true, "wrong": false} {
+		cfg := &Config{}
+		cfg.Default()
+		cfg.NodeAddr = ma.StringCast("/unix" + sock)
+		cfg.ConnectSwarmsDelay = 0
+		cfg.NodeAuthorization = "Bearer " + token
+		cfg.NodeHeaders = map[string]string{"X-Tenant": "cluster"}
+
+		ipfs, err := NewConnector(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = ipfs.ID(ctx)
+		if ok && err != nil {
+			t.Error("expected the request to succeed:", err)
+		}
+		if !ok && err == nil {
+			t.Error("expected the request to be rejected")
+		}
+		ipfs.Shutdown(ctx)
+	}
+}
+
+func TestNodeHTTPS(t *testing.T) {
+	ctx := context.Background()
+	mock := test.NewIpfsMock(t)
+	defer mock.Close()
+
+	srv := httptest.NewTLSServer(authProxy(mock, "secret"))
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	u, _ := url.Parse(srv.URL)
+	cfg := &Config{}
+	cfg.Default()
+	cfg.NodeAddr = ma.StringCast("/ip4/127.0.0.1/tcp/" + u.Port())
+	cfg.ConnectSwarmsDelay = 0
+	cfg.NodeHTTPS = true
+	cfg.NodeCACertFile = caFile
+	cfg.NodeAuthorization = "Bearer secret"
+	cfg.NodeHeaders = map[string]string{"X-Tenant": "cluster"}
+
+	ipfs, err := NewConnector(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ipfs.Shutdown(ctx)
+	if _, err := ipfs.ID(ctx); err != nil {
+		t.Error(err)
+	}
+
+	cfg.NodeCACertFile = ""
+	untrusted, err := NewConnector(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer untrusted.Shutdown(ctx)
+	if _, err := untrusted.ID(ctx); err == nil {
+		t.Error("expected an error with an untrusted certificate")
+	}
+}
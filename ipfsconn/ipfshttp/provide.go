@@ -0,0 +1,171 @@
+package ipfshttp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+	trace "go.opencensus.io/trace"
+)
+
+// Announcements of new pins are queued and sent by a fixed number of
+// workers, so that pinning many things at once does not flood the IPFS
+// daemon with provide requests. They are dropped when the queue is full.
+var (
+	provideWorkers   = 4
+	provideQueueSize = 1024
+)
+
+// provideStrategy returns the strategy which applies to a pin: its own or,
+// when it has none, the configured one.
+func (ipfs *Connector) provideStrategy(pin *api.Pin) api.ProvideStrategy {
+	if pin.ProvideStrategy != api.ProvideStrategyDefault {
+		return pin.ProvideStrategy
+	}
+	return ipfs.config.ProvideStrategy
+}
+
+// announce queues a pin which was just pinned to be provided in the
+// background, as given by its strategy.
+func (ipfs *Connector) announce(pin *api.Pin) {
+	strategy := ipfs.provideStrategy(pin)
+	if strategy != api.ProvideStrategyRoots && strategy != api.ProvideStrategyAll {
+		return
+	}
+
+	select {
+	case ipfs.provideQueue <- pin:
+	default:
+		logger.Warnf("provide queue is full: not announcing %s", pin.Cid)
+	}
+}
+
+// provideWorker provides the queued pins until the connector shuts down.
+func (ipfs *Connector) provideWorker() {
+	defer ipfs.wg.Done()
+
+	for {
+		select {
+		case <-ipfs.ctx.Done():
+			return
+		case pin := <-ipfs.provideQueue:
+			strategy := ipfs.provideStrategy(pin)
+			ctx, cancel := context.WithTimeout(ipfs.ctx, ipfs.config.IPFSRequestTimeout)
+			err := ipfs.provide(ctx, pin.Cid, strategy == api.ProvideStrategyAll)
+			cancel()
+			if err != nil && ipfs.ctx.Err() == nil {
+				logger.Errorf("error providing %s: %s", pin.Cid, err)
+			}
+		}
+	}
+}
+
+// provide announces a CID to the DHT, along with all the blocks below it
+// when recursive is set. Daemons which do not know about routing/provide
+// are asked to dht/provide instead.
+func (ipfs *Connector) provide(ctx context.Context, c cid.Cid, recursive bool) error {
+	ctx, span := trace.StartSpan(ctx, "ipfsconn/ipfshttp/provide")
+	defer span.End()
+
+	args := fmt.Sprintf("?arg=%s&recursive=%t", c, recursive)
+	_, err := ipfs.postCtx(ctx, "routing/provide"+args, "", nil)
+	if err != nil && strings.Contains(err.Error(), "Code 404") {
+		_, err = ipfs.postCtx(ctx, "dht/provide"+args, "", nil)
+	}
+	if err != nil {
+		return err
+	}
+	logger.Debugf("provided %s (recursive: %t)", c, recursive)
+	return nil
+}
+
+// reprovide announces again the pins allocated to this peer whose
+// strategy is to provide them, so that their DHT records do not expire.
+// It returns how many were provided.
+func (ipfs *Connector) reprovide(ctx context.Context) (int, error) {
+	ctx, span := trace.StartSpan(ctx, "ipfsconn/ipfshttp/reprovide")
+	defer span.End()
+
+	var id api.ID
+	err := ipfs.rpcClient.CallContext(ctx, "", "Cluster", "ID", struct{}{}, &id)
+	if err != nil {
+		return 0, err
+	}
+	var pins []*api.Pin
+	err = ipfs.rpcClient.CallContext(ctx, "", "Cluster", "Pins", struct{}{}, &pins)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	provided := 0
+	for _, pin := range pins {
+		strategy := ipfs.provideStrategy(pin)
+		if strategy != api.ProvideStrategyRoots && strategy != api.ProvideStrategyAll {
+			continue
+		}
+		if pin.Type != api.DataType && pin.Type != api.ShardType {
+			continue
+		}
+		if pin.IsRemotePin(id.ID) || pin.ScheduledAt(now) {
+			continue
+		}
+
+		pctx, cancel := context.WithTimeout(ctx, ipfs.config.IPFSRequestTimeout)
+		err := ipfs.provide(pctx, pin.Cid, strategy == api.ProvideStrategyAll)
+		cancel()
+		if ctx.Err() != nil {
+			return provided, ctx.Err()
+		}
+		if err != nil {
+			logger.Errorf("error reproviding %s: %s", pin.Cid, err)
+			continue
+		}
+		provided++
+	}
+	return provided, nil
+}
+
+func (ipfs *Connector) watchReprovide() {
+	defer ipfs.wg.Done()
+
+	ticker := time.NewTicker(ipfs.config.ReprovideInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ipfs.ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := ipfs.reprovide(ipfs.ctx)
+			if err != nil && ipfs.ctx.Err() == nil {
+				logger.Errorf("error reproviding: %s", err)
+			}
+			if n > 0 {
+				logger.Infof("reprovided %d pins", n)
+			}
+		}
+	}
+}
+
+// checkReprovider warns when the IPFS daemon reprovides content on its
+// own, which would announce what the strategies leave out.
+func (ipfs *Connector) checkReprovider() {
+	v, err := ipfs.ConfigKey("Reprovider/Interval")
+	if err != nil {
+		return // logged, or not found
+	}
+	interval, ok := v.(string)
+	if !ok {
+		return
+	}
+	if interval == "" {
+		interval = "12h" // the IPFS default
+	}
+	if interval != "0" {
+		logger.Warnf("the IPFS daemon reprovides content every %q. Set its Reprovider.Interval to \"0\" for the provide strategies to take effect", interval)
+	}
+}
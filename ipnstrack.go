@@ -0,0 +1,235 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/state"
+
+	cid "github.com/ipfs/go-cid"
+	trace "go.opencensus.io/trace"
+)
+
+// Pins with the api.IPNSTrackMetaKey metadata key follow an IPNS name. On
+// every IPNSTracking.Interval, the peer closest to each name resolves it
+// again and, when it points somewhere else, pins the new target with
+// PinUpdate. The new pin takes over the tracking of the name, while the
+// previous one stays pinned as any other replaced version (see
+// PinUpdateUnpin). When several pins track the same name, the most recent
+// one is updated.
+
+// IPNSHistoryMetaKey is the metadata key used to store the resolutions of
+// the name tracked by a pin, newest first, as "<cid>@<unix time>" separated
+// by commas.
+const IPNSHistoryMetaKey = "ipns_history"
+
+const ipnsTrackTarget = "ipns-track:"
+
+// ipnsResolution is an entry in the resolution history of a name.
+type ipnsResolution struct {
+	Cid        cid.Cid
+	ResolvedAt time.Time
+}
+
+// ipnsHistory returns the resolutions recorded in the metadata of a pin.
+func ipnsHistory(pin *api.Pin) []ipnsResolution {
+	v := pin.Metadata[IPNSHistoryMetaKey]
+	if v == "" {
+		return nil
+	}
+
+	var history []ipnsResolution
+	for _, entry := range strings.Split(v, ",") {
+		i := strings.LastIndex(entry, "@")
+		if i < 0 {
+			continue
+		}
+		ci, err := cid.Decode(entry[:i])
+		if err != nil {
+			continue
+		}
+		ts, err := strconv.ParseInt(entry[i+1:], 10, 64)
+		if err != nil {
+			continue
+		}
+		history = append(history, ipnsResolution{Cid: ci, ResolvedAt: time.Unix(ts, 0)})
+	}
+	return history
+}
+
+// setIPNSHistory adds a resolution to the front of the given history and
+// stores it in the given metadata, keeping IPNSTracking.HistorySize
+// entries.
+func (c *Cluster) setIPNSHistory(meta map[string]string, res ipnsResolution, history []ipnsResolution) {
+	entries := []string{fmt.Sprintf("%s@%d", res.Cid, res.ResolvedAt.Unix())}
+	for _, h := range history {
+		if len(entries) >= c.config.IPNSTracking.HistorySize {
+			break
+		}
+		entries = append(entries, fmt.Sprintf("%s@%d", h.Cid, h.ResolvedAt.Unix()))
+	}
+	meta[IPNSHistoryMetaKey] = strings.Join(entries, ",")
+}
+
+// setupIPNSTracking prepares the options of a pin for an IPFS path which
+// asks for its name to be tracked: the name is stored in the metadata,
+// along with its first resolution.
+func (c *Cluster) setupIPNSTracking(p string, ci cid.Cid, opts api.PinOptions) (api.PinOptions, error) {
+	if opts.Metadata[api.IPNSTrackMetaKey] == "" {
+		return opts, nil
+	}
+
+	p = path.Clean(p)
+	if !strings.HasPrefix(p, "/ipns/") {
+		return opts, errors.New("only /ipns/ paths can be tracked")
+	}
+
+	meta := make(map[string]string, len(opts.Metadata)+1)
+	for k, v := range opts.Metadata {
+		meta[k] = v
+	}
+	meta[api.IPNSTrackMetaKey] = p
+	c.setIPNSHistory(meta, ipnsResolution{Cid: ci, ResolvedAt: time.Now()}, nil)
+	opts.Metadata = meta
+	return opts, nil
+}
+
+// trackedNames returns the most recent pin tracking every name, indexed by
+// name. Trashed pins, pins which are not data pins and values which are not
+// /ipns/ paths (i.e. set by hand) are ignored.
+func trackedNames(ctx context.Context, cState state.ReadOnly) (map[string]*api.Pin, error) {
+	var pins []*api.Pin
+	var err error
+	if il, ok := cState.(state.IndexedLister); ok {
+		pins, err = il.ListByMetadata(ctx, api.IPNSTrackMetaKey, "")
+	} else {
+		pins, err = cState.List(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]*api.Pin)
+	for _, p := range pins {
+		name := p.Metadata[api.IPNSTrackMetaKey]
+		if !strings.HasPrefix(name, "/ipns/") || p.Type != api.DataType {
+			continue
+		}
+		if _, trashed := p.TrashedAt(); trashed {
+			continue
+		}
+		if cur, ok := names[name]; ok && !p.Timestamp.After(cur.Timestamp) {
+			continue
+		}
+		names[name] = p
+	}
+	return names, nil
+}
+
+func (c *Cluster) watchIPNSTracking() {
+	ticker := time.NewTicker(c.config.IPNSTracking.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.resolveTrackedNames(c.ctx)
+		}
+	}
+}
+
+// resolveTrackedNames resolves the tracked names which this peer is
+// responsible for and updates the pins whose names point elsewhere.
+func (c *Cluster) resolveTrackedNames(ctx context.Context) {
+	ctx, span := trace.StartSpan(ctx, "cluster/resolveTrackedNames")
+	defer span.End()
+
+	if c.config.FollowerMode {
+		return
+	}
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		logger.Warn(err)
+		return
+	}
+	names, err := trackedNames(ctx, cState)
+	if err != nil {
+		logger.Warn(err)
+		return
+	}
+	if len(names) == 0 {
+		return
+	}
+
+	distance, err := c.distances(ctx, "")
+	if err != nil {
+		return // logged
+	}
+
+	for name, pin := range names {
+		if c.ctx.Err() != nil {
+			return
+		}
+		if !distance.isClosestKey(ipnsTrackTarget + name) {
+			continue
+		}
+		if _, err := c.updateTrackedName(ctx, name, pin); err != nil {
+			logger.Errorf("error updating the pin for %s: %s", name, err)
+		}
+	}
+}
+
+// updateTrackedName resolves a name and, when it no longer points to the
+// pin tracking it, pins the new target with PinUpdate. It returns the new
+// pin, or nil when the name did not change.
+func (c *Cluster) updateTrackedName(ctx context.Context, name string, pin *api.Pin) (*api.Pin, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/updateTrackedName")
+	defer span.End()
+
+	rctx, cancel := context.WithTimeout(ctx, c.config.IPNSTracking.Timeout)
+	ci, err := c.ipfs.Resolve(rctx, name)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	if ci.Equals(pin.Cid) {
+		return nil, nil
+	}
+	if err := c.checkDenylist("/ipfs/" + ci.String()); err != nil {
+		return nil, err
+	}
+
+	updated, err := c.planPinUpdate(ctx, pin.Cid, ci, api.PinOptions{})
+	if err != nil {
+		return nil, err
+	}
+	updated = copyWithMetadata(updated)
+	c.setIPNSHistory(updated.Metadata, ipnsResolution{Cid: ci, ResolvedAt: time.Now()}, ipnsHistory(pin))
+	logger.Infof("%s now points to %s: updating %s", name, ci, pin.Cid)
+	if err := c.logPin(ctx, updated); err != nil {
+		return nil, err
+	}
+	if c.config.PinUpdateUnpin.Enabled {
+		c.expireReplacedVersions(ctx, updated)
+	}
+
+	// The new pin tracks the name from now on.
+	old, err := c.PinGet(ctx, pin.Cid)
+	if err == nil && old.Metadata[api.IPNSTrackMetaKey] != "" {
+		old = copyWithMetadata(old)
+		delete(old.Metadata, api.IPNSTrackMetaKey)
+		if err := c.logPin(ctx, old); err != nil {
+			logger.Errorf("error untracking %s from %s: %s", name, pin.Cid, err)
+		}
+	}
+	return updated, nil
+}
@@ -0,0 +1,84 @@
+package ipfscluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+)
+
+func TestIPNSTracking(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	track := api.PinOptions{
+		Metadata: map[string]string{api.IPNSTrackMetaKey: "true"},
+	}
+	if _, err := cl.PinPath(ctx, "/ipfs/"+test.Cid1.String(), track); err == nil {
+		t.Error("expected an error tracking an /ipfs/ path")
+	}
+
+	// The mock resolves every path to CidResolved.
+	pin, err := cl.PinPath(ctx, "/ipns/example.com/", track)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pin.Cid.Equals(test.CidResolved) || pin.Metadata[api.IPNSTrackMetaKey] != "/ipns/example.com" {
+		t.Fatalf("unexpected pin: %s %v", pin.Cid, pin.Metadata)
+	}
+	if h := ipnsHistory(pin); len(h) != 1 || !h[0].Cid.Equals(test.CidResolved) {
+		t.Errorf("unexpected history: %v", h)
+	}
+
+	// A name which now points to CidResolved, tracked by Cid1.
+	opts := api.PinOptions{
+		Metadata: map[string]string{api.IPNSTrackMetaKey: "/ipns/example.org"},
+	}
+	if _, err := cl.Pin(ctx, test.Cid1, opts); err != nil {
+		t.Fatal(err)
+	}
+	cl.resolveTrackedNames(ctx)
+
+	updated, err := cl.PinGet(ctx, test.CidResolved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.PinUpdate != test.Cid1 || updated.Metadata[api.IPNSTrackMetaKey] != "/ipns/example.org" {
+		t.Errorf("expected an update from cid1: %s %v", updated.PinUpdate, updated.Metadata)
+	}
+	if h := ipnsHistory(updated); len(h) != 1 || !h[0].Cid.Equals(test.CidResolved) {
+		t.Errorf("unexpected history: %v", h)
+	}
+	old, err := cl.PinGet(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal("the previous version should stay pinned:", err)
+	}
+	if _, ok := old.Metadata[api.IPNSTrackMetaKey]; ok {
+		t.Error("the previous version should not track the name anymore")
+	}
+
+	// Nothing changes when the name still points to the same CID.
+	changed, err := cl.updateTrackedName(ctx, "/ipns/example.org", updated)
+	if err != nil || changed != nil {
+		t.Error("expected no update:", changed, err)
+	}
+}
+
+func TestIPNSHistory(t *testing.T) {
+	cl := &Cluster{config: &Config{}}
+	cl.config.IPNSTracking.HistorySize = 2
+
+	pin := api.PinCid(test.Cid1)
+	pin.Metadata = make(map[string]string)
+	cl.setIPNSHistory(pin.Metadata, ipnsResolution{Cid: test.Cid1}, nil)
+	cl.setIPNSHistory(pin.Metadata, ipnsResolution{Cid: test.Cid2}, ipnsHistory(pin))
+	cl.setIPNSHistory(pin.Metadata, ipnsResolution{Cid: test.Cid3}, ipnsHistory(pin))
+
+	h := ipnsHistory(pin)
+	if len(h) != 2 || !h[0].Cid.Equals(test.Cid3) || !h[1].Cid.Equals(test.Cid2) {
+		t.Errorf("unexpected history: %v", h)
+	}
+}
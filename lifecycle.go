@@ -0,0 +1,137 @@
+package ipfscluster
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	"go.opencensus.io/trace"
+)
+
+// Lifecycle events describe what happens to a peer while it runs: peers
+// joining and leaving the peerset, leader changes, the peer becoming
+// ready, the first recover after starting finishing and the APIs starting.
+// Every event is logged, kept in a bounded history which is served by the
+// API and queued for the lifecycle webhooks and the event publishers.
+
+// lifecycleHistorySize is the number of lifecycle events kept by the peer.
+var lifecycleHistorySize = 256
+
+// lifecycleLog keeps the latest lifecycle events, oldest first.
+type lifecycleLog struct {
+	mu     sync.Mutex
+	seq    uint64
+	events []*api.LifecycleEvent
+}
+
+func newLifecycleLog() *lifecycleLog {
+	return &lifecycleLog{}
+}
+
+// add sets the sequence number of the event and keeps it.
+func (ll *lifecycleLog) add(ev *api.LifecycleEvent) {
+	ll.mu.Lock()
+	defer ll.mu.Unlock()
+
+	ll.seq++
+	ev.Sequence = ll.seq
+	ll.events = append(ll.events, ev)
+	if extra := len(ll.events) - lifecycleHistorySize; extra > 0 {
+		ll.events = append(ll.events[:0], ll.events[extra:]...)
+	}
+}
+
+// since returns the events kept after the given sequence number.
+func (ll *lifecycleLog) since(seq uint64) *api.LifecycleEvents {
+	ll.mu.Lock()
+	defer ll.mu.Unlock()
+
+	evs := &api.LifecycleEvents{
+		Sequence: ll.seq,
+		Events:   make([]*api.LifecycleEvent, 0),
+	}
+	for _, ev := range ll.events {
+		if ev.Sequence > seq {
+			evs.Events = append(evs.Events, ev)
+		}
+	}
+	return evs
+}
+
+// LifecycleEvents returns the lifecycle events of this peer after the given
+// sequence number, along with the current sequence number, which can be
+// used to ask for the next events. Only the latest events are kept, so
+// gaps in the sequence numbers mean that some events were missed.
+func (c *Cluster) LifecycleEvents(ctx context.Context, since uint64) *api.LifecycleEvents {
+	_, span := trace.StartSpan(ctx, "cluster/LifecycleEvents")
+	defer span.End()
+
+	return c.lifecycle.since(since)
+}
+
+// emitLifecycleEvent records a lifecycle event of this peer and queues it
+// for the lifecycle webhooks and the event publishers.
+func (c *Cluster) emitLifecycleEvent(event api.LifecycleEventType, subject peer.ID, details string) {
+	ev := &api.LifecycleEvent{
+		Event:     event,
+		Peer:      c.id,
+		PeerName:  c.config.Peername,
+		Subject:   subject,
+		Details:   details,
+		Timestamp: time.Now(),
+	}
+	c.lifecycle.add(ev)
+
+	msg := fmt.Sprintf("lifecycle event %d: %s", ev.Sequence, ev.Event)
+	if subject != "" {
+		msg += " " + subject.Pretty()
+	}
+	if details != "" {
+		msg += " (" + details + ")"
+	}
+	logger.Info(msg)
+
+	c.events.push(ev, fmt.Sprintf("%s lifecycle event", ev.Event))
+}
+
+// peersetWatcher detects changes in the peerset and the consensus leader,
+// as seen in the successive checks done by watchPeers, and emits the
+// matching lifecycle events. The first check only sets the baseline
+// peerset, but reports the leader.
+type peersetWatcher struct {
+	peers  map[peer.ID]struct{}
+	leader peer.ID
+}
+
+func (c *Cluster) checkPeerset(w *peersetWatcher, peers []peer.ID) {
+	current := make(map[peer.ID]struct{}, len(peers))
+	for _, p := range peers {
+		current[p] = struct{}{}
+	}
+	if w.peers != nil {
+		for _, p := range peers {
+			if _, ok := w.peers[p]; !ok {
+				c.emitLifecycleEvent(api.LifecycleEventPeerJoined, p, "")
+			}
+		}
+		for p := range w.peers {
+			if _, ok := current[p]; !ok {
+				c.emitLifecycleEvent(api.LifecycleEventPeerLeft, p, "")
+			}
+		}
+	}
+	w.peers = current
+
+	// Only Raft has a leader. Errors mean that there is none, or
+	// none yet, which is not a change worth noticing.
+	leader, err := c.consensus.Leader(c.ctx)
+	if err != nil || leader == "" || leader == w.leader {
+		return
+	}
+	w.leader = leader
+	c.emitLifecycleEvent(api.LifecycleEventLeaderChanged, leader, "")
+}
@@ -0,0 +1,108 @@
+package ipfscluster
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+func TestLifecycleLog(t *testing.T) {
+	defer func(n int) { lifecycleHistorySize = n }(lifecycleHistorySize)
+	lifecycleHistorySize = 3
+
+	ll := newLifecycleLog()
+	for i := 0; i < 5; i++ {
+		ll.add(&api.LifecycleEvent{Event: api.LifecycleEventPeerJoined})
+	}
+
+	evs := ll.since(0)
+	if evs.Sequence != 5 || len(evs.Events) != 3 || evs.Events[0].Sequence != 3 {
+		t.Fatalf("expected the last 3 events: %+v", evs)
+	}
+	evs = ll.since(4)
+	if len(evs.Events) != 1 || evs.Events[0].Sequence != 5 {
+		t.Errorf("expected the last event: %+v", evs)
+	}
+	evs = ll.since(5)
+	if evs.Sequence != 5 || len(evs.Events) != 0 {
+		t.Errorf("expected no events: %+v", evs)
+	}
+}
+
+func TestClusterLifecycleEvents(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	evs := cl.LifecycleEvents(ctx, 0)
+	if len(evs.Events) < 3 {
+		t.Fatalf("expected the startup events: %+v", evs.Events)
+	}
+	for i, details := range []string{"*ipfscluster.mockAPI", "*ipfscluster.mockProxy"} {
+		if ev := evs.Events[i]; ev.Event != api.LifecycleEventAPIStarted || ev.Details != details {
+			t.Errorf("expected %s to start: %+v", details, ev)
+		}
+	}
+	if ev := evs.Events[2]; ev.Event != api.LifecycleEventConsensusReady || ev.Peer != cl.id {
+		t.Errorf("expected consensus to be ready: %+v", ev)
+	}
+
+	received := make(chan *api.LifecycleEvent, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev api.LifecycleEvent
+		if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		received <- &ev
+	}))
+	defer srv.Close()
+
+	cl.config.LifecycleWebhooks = []LifecycleWebhookConfig{
+		{URL: srv.URL, Timeout: time.Second, Events: []api.LifecycleEventType{api.LifecycleEventPeerLeft}},
+	}
+	cl.events = newEventQueues(cl.config, nil)
+	cl.wg.Add(1)
+	go func() {
+		defer cl.wg.Done()
+		cl.sendEvents()
+	}()
+
+	var watcher peersetWatcher
+	cl.checkPeerset(&watcher, []peer.ID{cl.id})
+	cl.checkPeerset(&watcher, []peer.ID{cl.id, test.PeerID2})
+	cl.checkPeerset(&watcher, []peer.ID{cl.id})
+
+	select {
+	case ev := <-received:
+		if ev.Event != api.LifecycleEventPeerLeft || ev.Subject != test.PeerID2 {
+			t.Errorf("unexpected event: %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the lifecycle webhook")
+	}
+
+	var joined, left bool
+	for _, ev := range cl.LifecycleEvents(ctx, evs.Sequence).Events {
+		switch {
+		case ev.Event == api.LifecycleEventPeerJoined && ev.Subject == test.PeerID2:
+			joined = true
+		case ev.Event == api.LifecycleEventPeerLeft && ev.Subject == test.PeerID2:
+			left = true
+		case ev.Event == api.LifecycleEventPeerJoined || ev.Event == api.LifecycleEventPeerLeft:
+			t.Errorf("unexpected event: %+v", ev)
+		}
+	}
+	if !joined || !left {
+		t.Error("expected the peer to join and leave")
+	}
+}
@@ -3,18 +3,23 @@ package metrics
 import (
 	"context"
 	"errors"
+	"math"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/ipfs/ipfs-cluster/api"
 	"github.com/ipfs/ipfs-cluster/observations"
 
+	logging "github.com/ipfs/go-log/v2"
 	peer "github.com/libp2p/go-libp2p-core/peer"
 
 	"go.opencensus.io/stats"
 	"go.opencensus.io/tag"
 )
 
+var logger = logging.Logger("monitor")
+
 // AlertChannelCap specifies how much buffer the alerts channel has.
 var AlertChannelCap = 256
 
@@ -38,8 +43,13 @@ type Checker struct {
 	metrics   *Store
 	threshold float64
 
+	// set before watching.
+	metricThresholds map[string]float64
+	flapWindow       time.Duration
+
 	failedPeersMu sync.Mutex
 	failedPeers   map[peer.ID]map[string]int
+	lastAlerts    map[peer.ID]map[string]time.Time
 }
 
 // NewChecker creates a Checker using the given
@@ -55,7 +65,49 @@ func NewChecker(ctx context.Context, metrics *Store, threshold float64) *Checker
 		metrics:     metrics,
 		threshold:   threshold,
 		failedPeers: make(map[peer.ID]map[string]int),
+		lastAlerts:  make(map[peer.ID]map[string]time.Time),
+	}
+}
+
+// SetMetricThresholds sets failure thresholds for the given metrics,
+// overriding the default threshold for them. It should be called before
+// checking any peers.
+func (mc *Checker) SetMetricThresholds(thresholds map[string]float64) {
+	mc.metricThresholds = thresholds
+}
+
+// SetFlapWindow enables flap suppression: a peer which fails again within
+// the given window since the last alert for the same metric is not alerted
+// about until the window has passed. This avoids repeated alerts (and the
+// re-allocations that follow) for peers which are briefly unresponsive
+// from time to time. It should be called before checking any peers.
+func (mc *Checker) SetFlapWindow(window time.Duration) {
+	mc.flapWindow = window
+}
+
+func (mc *Checker) thresholdFor(metric string) float64 {
+	if t, ok := mc.metricThresholds[metric]; ok {
+		return t
+	}
+	return mc.threshold
+}
+
+// suppressed returns whether new alerts for a peer and metric are
+// suppressed and when the last alert was sent. It must be called with the
+// failedPeersMu lock held.
+func (mc *Checker) suppressed(pid peer.ID, metricName string) (bool, time.Time) {
+	last, ok := mc.lastAlerts[pid][metricName]
+	if !ok {
+		return false, last
 	}
+	if time.Since(last) >= mc.flapWindow {
+		delete(mc.lastAlerts[pid], metricName)
+		if len(mc.lastAlerts[pid]) == 0 {
+			delete(mc.lastAlerts, pid)
+		}
+		return false, last
+	}
+	return true, last
 }
 
 // CheckPeers will trigger alerts based on the latest metrics from the given peerset
@@ -99,6 +151,18 @@ func (mc *Checker) alert(pid peer.ID, metricName string) error {
 		mc.failedPeers[pid] = make(map[string]int)
 	}
 	failedMetrics := mc.failedPeers[pid]
+
+	// A new failure shortly after the last alert: the peer is flapping.
+	if failedMetrics[metricName] == 0 && mc.flapWindow > 0 {
+		if suppressed, _ := mc.suppressed(pid, metricName); suppressed {
+			logger.Debugf("suppressing %s alert for flapping peer %s", metricName, pid)
+			if len(failedMetrics) == 0 {
+				delete(mc.failedPeers, pid)
+			}
+			return nil
+		}
+	}
+
 	lastMetric := mc.metrics.PeerLatest(metricName, pid)
 	if lastMetric == nil {
 		lastMetric = &api.Metric{
@@ -126,6 +190,12 @@ func (mc *Checker) alert(pid peer.ID, metricName string) error {
 	}
 	select {
 	case mc.alertCh <- alrt:
+		if mc.flapWindow > 0 {
+			if _, ok := mc.lastAlerts[pid]; !ok {
+				mc.lastAlerts[pid] = make(map[string]time.Time)
+			}
+			mc.lastAlerts[pid][metricName] = alrt.TriggeredAt
+		}
 		stats.RecordWithTags(
 			mc.ctx,
 			[]tag.Mutator{tag.Upsert(observations.RemotePeerKey, pid.Pretty())},
@@ -199,5 +269,46 @@ func (mc *Checker) failed(metric string, pid peer.ID) (float64, []float64, float
 	v := time.Now().UnixNano() - latest.ReceivedAt
 	dv := mc.metrics.Distribution(metric, pid)
 	phiv := phi(float64(v), dv)
-	return float64(v), dv, phiv, phiv >= mc.threshold
+	return float64(v), dv, phiv, phiv >= mc.thresholdFor(metric)
+}
+
+// DetectorState returns the state of the failure detector for the latest
+// metrics of every peer, sorted by peer and metric name.
+func (mc *Checker) DetectorState() []*api.DetectorState {
+	var states []*api.DetectorState
+	for _, m := range mc.metrics.AllMetrics() {
+		_, _, _, failed := mc.failed(m.Name, m.Peer)
+		st := &api.DetectorState{
+			Peer:         m.Peer,
+			Metric:       m.Name,
+			Threshold:    mc.thresholdFor(m.Name),
+			Samples:      len(mc.metrics.PeerMetricAll(m.Name, m.Peer)),
+			LastReceived: time.Unix(0, m.ReceivedAt),
+			Expired:      m.Expired(),
+			Failed:       failed,
+		}
+		if st.Samples >= accrualMetricsNum {
+			v := time.Now().UnixNano() - m.ReceivedAt
+			st.Phi = phi(float64(v), mc.metrics.Distribution(m.Name, m.Peer))
+			if math.IsInf(st.Phi, 1) { // not representable in JSON
+				st.Phi = math.MaxFloat64
+			}
+		}
+
+		mc.failedPeersMu.Lock()
+		if mc.flapWindow > 0 {
+			st.Suppressed, st.LastAlert = mc.suppressed(m.Peer, m.Name)
+		}
+		mc.failedPeersMu.Unlock()
+
+		states = append(states, st)
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].Peer != states[j].Peer {
+			return states[i].Peer < states[j].Peer
+		}
+		return states[i].Metric < states[j].Metric
+	})
+	return states
 }
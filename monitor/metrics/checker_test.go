@@ -222,6 +222,89 @@ func TestChecker_alert(t *testing.T) {
 	})
 }
 
+func TestChecker_flapWindow(t *testing.T) {
+	metrics := NewStore()
+	checker := NewChecker(context.Background(), metrics, 2.0)
+	checker.SetFlapWindow(time.Minute)
+
+	addExpired := func() {
+		metr := &api.Metric{
+			Name:  "ping",
+			Peer:  test.PeerID1,
+			Value: "1",
+			Valid: true,
+		}
+		metr.SetTTL(time.Millisecond)
+		metrics.Add(metr)
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	addExpired()
+	for i := 0; i <= MaxAlertThreshold; i++ {
+		checker.CheckPeers([]peer.ID{test.PeerID1})
+	}
+	for i := 0; i < MaxAlertThreshold; i++ {
+		select {
+		case <-checker.Alerts():
+		default:
+			t.Fatal("an alert should have been triggered")
+		}
+	}
+
+	// The peer comes back and fails again right away.
+	addExpired()
+	checker.CheckPeers([]peer.ID{test.PeerID1})
+	select {
+	case <-checker.Alerts():
+		t.Error("alerts for a flapping peer should be suppressed")
+	default:
+	}
+
+	states := checker.DetectorState()
+	if len(states) != 1 {
+		t.Fatal("expected one detector state")
+	}
+	st := states[0]
+	if !st.Failed || !st.Expired || !st.Suppressed || st.LastAlert.IsZero() {
+		t.Errorf("unexpected detector state: %+v", st)
+	}
+	if st.Threshold != 2.0 || st.Samples != 1 {
+		t.Errorf("unexpected detector state: %+v", st)
+	}
+}
+
+func TestChecker_metricThresholds(t *testing.T) {
+	metrics := NewStore()
+	checker := NewChecker(context.Background(), metrics, 2.0)
+	checker.SetMetricThresholds(map[string]float64{"ping": 5.0})
+
+	for _, name := range []string{"ping", "other"} {
+		metr := &api.Metric{
+			Name:  name,
+			Peer:  test.PeerID1,
+			Value: "1",
+			Valid: true,
+		}
+		metr.SetTTL(time.Minute)
+		metrics.Add(metr)
+	}
+
+	states := checker.DetectorState()
+	if len(states) != 2 {
+		t.Fatal("expected two detector states")
+	}
+	// sorted by metric name
+	if states[0].Metric != "other" || states[0].Threshold != 2.0 {
+		t.Errorf("unexpected detector state: %+v", states[0])
+	}
+	if states[1].Metric != "ping" || states[1].Threshold != 5.0 {
+		t.Errorf("unexpected detector state: %+v", states[1])
+	}
+	if states[1].Failed || states[1].Expired || states[1].Suppressed {
+		t.Errorf("unexpected detector state: %+v", states[1])
+	}
+}
+
 //////////////////
 // HELPER TESTS //
 //////////////////
@@ -3,6 +3,7 @@ package pubsubmon
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/ipfs/ipfs-cluster/config"
@@ -16,6 +17,10 @@ const envConfigKey = "cluster_pubsubmon"
 const (
 	DefaultCheckInterval    = 15 * time.Second
 	DefaultFailureThreshold = 3.0
+	DefaultBatchInterval    = 0
+	DefaultAdaptivePeers    = 0
+	DefaultMaxTTLFactor     = 4
+	DefaultFlapWindow       = 0
 )
 
 // Config allows to initialize a Monitor and customize some parameters.
@@ -27,11 +32,40 @@ type Config struct {
 	// The greater the threshold value the more leniency is granted.
 	// A value between 2.0 and 4.0 is suggested for the threshold.
 	FailureThreshold float64
+	// MetricFailureThresholds overrides FailureThreshold for the metrics
+	// with the given names.
+	MetricFailureThresholds map[string]float64
+	// FlapWindow, when set, suppresses alerts for peers failing again
+	// within this time since their last alert for the same metric. This
+	// prevents repeated re-allocations caused by peers which are slow or
+	// unreachable for short periods.
+	FlapWindow time.Duration
+
+	// BatchInterval, when set, makes the monitor accumulate the metrics
+	// to publish and send them together in a single message on this
+	// interval. Peers older than this feature cannot read batched
+	// metrics.
+	BatchInterval time.Duration
+	// MetricTTLs overrides the TTL of the metrics with the given names.
+	// Metrics are re-published every half TTL.
+	MetricTTLs map[string]time.Duration
+	// AdaptivePeers, when set, scales the TTL of published metrics with
+	// the size of the cluster: TTLs are multiplied by one more for every
+	// AdaptivePeers peers, up to MaxTTLFactor times.
+	AdaptivePeers int
+	// MaxTTLFactor limits how much AdaptivePeers can lengthen TTLs.
+	MaxTTLFactor int
 }
 
 type jsonConfig struct {
-	CheckInterval    string   `json:"check_interval"`
-	FailureThreshold *float64 `json:"failure_threshold"`
+	CheckInterval           string             `json:"check_interval"`
+	FailureThreshold        *float64           `json:"failure_threshold"`
+	MetricFailureThresholds map[string]float64 `json:"metric_failure_thresholds,omitempty"`
+	FlapWindow              string             `json:"flap_window"`
+	BatchInterval           string             `json:"batch_interval"`
+	MetricTTLs              map[string]string  `json:"metric_ttls,omitempty"`
+	AdaptivePeers           int                `json:"adaptive_peers"`
+	MaxTTLFactor            int                `json:"max_ttl_factor"`
 }
 
 // ConfigKey provides a human-friendly identifier for this type of Config.
@@ -43,6 +77,12 @@ func (cfg *Config) ConfigKey() string {
 func (cfg *Config) Default() error {
 	cfg.CheckInterval = DefaultCheckInterval
 	cfg.FailureThreshold = DefaultFailureThreshold
+	cfg.MetricFailureThresholds = nil
+	cfg.FlapWindow = DefaultFlapWindow
+	cfg.BatchInterval = DefaultBatchInterval
+	cfg.MetricTTLs = nil
+	cfg.AdaptivePeers = DefaultAdaptivePeers
+	cfg.MaxTTLFactor = DefaultMaxTTLFactor
 	return nil
 }
 
@@ -70,6 +110,34 @@ func (cfg *Config) Validate() error {
 		return errors.New("pubsubmon.failure_threshold too low")
 	}
 
+	for name, threshold := range cfg.MetricFailureThresholds {
+		if threshold <= 0 {
+			return fmt.Errorf("pubsubmon.metric_failure_thresholds: threshold for %s too low", name)
+		}
+	}
+
+	if cfg.FlapWindow < 0 {
+		return errors.New("pubsubmon.flap_window is invalid")
+	}
+
+	if cfg.BatchInterval < 0 {
+		return errors.New("pubsubmon.batch_interval is invalid")
+	}
+
+	for name, ttl := range cfg.MetricTTLs {
+		if ttl <= 0 {
+			return fmt.Errorf("pubsubmon.metric_ttls: invalid ttl for %s", name)
+		}
+	}
+
+	if cfg.AdaptivePeers < 0 {
+		return errors.New("pubsubmon.adaptive_peers is invalid")
+	}
+
+	if cfg.MaxTTLFactor < 1 {
+		return errors.New("pubsubmon.max_ttl_factor must be at least 1")
+	}
+
 	return nil
 }
 
@@ -95,6 +163,32 @@ func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
 		cfg.FailureThreshold = *jcfg.FailureThreshold
 	}
 
+	if len(jcfg.MetricFailureThresholds) > 0 {
+		cfg.MetricFailureThresholds = jcfg.MetricFailureThresholds
+	}
+
+	err := config.ParseDurations(configKey,
+		&config.DurationOpt{Duration: jcfg.FlapWindow, Dst: &cfg.FlapWindow, Name: "flap_window"},
+		&config.DurationOpt{Duration: jcfg.BatchInterval, Dst: &cfg.BatchInterval, Name: "batch_interval"},
+	)
+	if err != nil {
+		return err
+	}
+
+	if len(jcfg.MetricTTLs) > 0 {
+		cfg.MetricTTLs = make(map[string]time.Duration, len(jcfg.MetricTTLs))
+		for name, ttlStr := range jcfg.MetricTTLs {
+			ttl, err := time.ParseDuration(ttlStr)
+			if err != nil {
+				return fmt.Errorf("error parsing pubsubmon.metric_ttls.%s: %w", name, err)
+			}
+			cfg.MetricTTLs[name] = ttl
+		}
+	}
+
+	cfg.AdaptivePeers = jcfg.AdaptivePeers
+	config.SetIfNotDefault(jcfg.MaxTTLFactor, &cfg.MaxTTLFactor)
+
 	return cfg.Validate()
 }
 
@@ -106,10 +200,22 @@ func (cfg *Config) ToJSON() ([]byte, error) {
 }
 
 func (cfg *Config) toJSONConfig() *jsonConfig {
-	return &jsonConfig{
-		CheckInterval:    cfg.CheckInterval.String(),
-		FailureThreshold: &cfg.FailureThreshold,
+	jcfg := &jsonConfig{
+		CheckInterval:           cfg.CheckInterval.String(),
+		FailureThreshold:        &cfg.FailureThreshold,
+		MetricFailureThresholds: cfg.MetricFailureThresholds,
+		FlapWindow:              cfg.FlapWindow.String(),
+		BatchInterval:           cfg.BatchInterval.String(),
+		AdaptivePeers:           cfg.AdaptivePeers,
+		MaxTTLFactor:            cfg.MaxTTLFactor,
+	}
+	if len(cfg.MetricTTLs) > 0 {
+		jcfg.MetricTTLs = make(map[string]string, len(cfg.MetricTTLs))
+		for name, ttl := range cfg.MetricTTLs {
+			jcfg.MetricTTLs[name] = ttl.String()
+		}
 	}
+	return jcfg
 }
 
 // ToDisplayJSON returns JSON config as a string.
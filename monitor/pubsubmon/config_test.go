@@ -10,7 +10,16 @@ import (
 var cfgJSON = []byte(`
 {
       "check_interval": "15s",
-      "failure_threshold": 3.0
+      "failure_threshold": 3.0,
+      "metric_failure_thresholds": {
+          "ping": 4.0
+      },
+      "flap_window": "5m",
+      "batch_interval": "1s",
+      "metric_ttls": {
+          "ping": "1m"
+      },
+      "adaptive_peers": 50
 }
 `)
 
@@ -20,12 +29,34 @@ func TestLoadJSON(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	if cfg.BatchInterval != time.Second ||
+		cfg.MetricTTLs["ping"] != time.Minute ||
+		cfg.AdaptivePeers != 50 ||
+		cfg.MaxTTLFactor != DefaultMaxTTLFactor ||
+		cfg.MetricFailureThresholds["ping"] != 4.0 ||
+		cfg.FlapWindow != 5*time.Minute {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
 
 	j := &jsonConfig{}
 
 	json.Unmarshal(cfgJSON, j)
-	j.CheckInterval = "-10"
+	j.MetricTTLs = map[string]string{"ping": "abc"}
 	tst, _ := json.Marshal(j)
+	if err := cfg.LoadJSON(tst); err == nil {
+		t.Error("expected error decoding metric_ttls")
+	}
+
+	json.Unmarshal(cfgJSON, j)
+	j.MetricFailureThresholds = map[string]float64{"ping": 0}
+	tst, _ = json.Marshal(j)
+	if err := cfg.LoadJSON(tst); err == nil {
+		t.Error("expected error validating metric_failure_thresholds")
+	}
+
+	json.Unmarshal(cfgJSON, j)
+	j.CheckInterval = "-10"
+	tst, _ = json.Marshal(j)
 	err = cfg.LoadJSON(tst)
 	if err == nil {
 		t.Error("expected error decoding check_interval")
@@ -26,6 +26,10 @@ var logger = logging.Logger("monitor")
 // PubsubTopic specifies the topic used to publish Cluster metrics.
 var PubsubTopic = "monitor.metrics"
 
+// batchPrefix starts the messages carrying several metrics. Single metrics
+// are msgpack maps, which never start with it.
+var batchPrefix = []byte("/batch\n")
+
 var msgpackHandle = &gocodec.MsgpackHandle{}
 
 // Monitor is a component in charge of monitoring peers, logging
@@ -46,6 +50,13 @@ type Monitor struct {
 
 	config *Config
 
+	batchMux sync.Mutex
+	batch    []*api.Metric
+
+	ttlFactorMux       sync.Mutex
+	ttlFactor          int
+	ttlFactorUpdatedAt time.Time
+
 	shutdownLock sync.Mutex
 	shutdown     bool
 	wg           sync.WaitGroup
@@ -73,6 +84,8 @@ func New(
 
 	mtrs := metrics.NewStore()
 	checker := metrics.NewChecker(ctx, mtrs, cfg.FailureThreshold)
+	checker.SetMetricThresholds(cfg.MetricFailureThresholds)
+	checker.SetFlapWindow(cfg.FlapWindow)
 
 	topic, err := psub.Join(PubsubTopic)
 	if err != nil {
@@ -95,9 +108,10 @@ func New(
 		subscription: subscription,
 		peers:        peers,
 
-		metrics: mtrs,
-		checker: checker,
-		config:  cfg,
+		metrics:   mtrs,
+		checker:   checker,
+		config:    cfg,
+		ttlFactor: 1,
 	}
 
 	go mon.run()
@@ -109,6 +123,10 @@ func (mon *Monitor) run() {
 	case <-mon.rpcReady:
 		go mon.logFromPubsub()
 		go mon.checker.Watch(mon.ctx, mon.peers, mon.config.CheckInterval)
+		if mon.config.BatchInterval > 0 {
+			mon.wg.Add(1)
+			go mon.publishBatches()
+		}
 	case <-mon.ctx.Done():
 	}
 }
@@ -134,6 +152,11 @@ func (mon *Monitor) logFromPubsub() {
 			}
 
 			data := msg.GetData()
+			if bytes.HasPrefix(data, batchPrefix) {
+				mon.logBatch(ctx, data[len(batchPrefix):])
+				continue
+			}
+
 			buf := bytes.NewBuffer(data)
 			dec := gocodec.NewDecoder(buf, msgpackHandle)
 			metric := api.Metric{}
@@ -169,6 +192,22 @@ func (mon *Monitor) logFromPubsub() {
 	}
 }
 
+func (mon *Monitor) logBatch(ctx context.Context, data []byte) {
+	var batch []*api.Metric
+	dec := gocodec.NewDecoderBytes(data, msgpackHandle)
+	if err := dec.Decode(&batch); err != nil {
+		logger.Error(err)
+		return
+	}
+
+	for _, m := range batch {
+		debug("recieved", m)
+		if err := mon.LogMetric(ctx, m); err != nil {
+			logger.Error(err)
+		}
+	}
+}
+
 // SetClient saves the given rpc.Client  for later use
 func (mon *Monitor) SetClient(c *rpc.Client) {
 	mon.rpcClient = c
@@ -209,7 +248,11 @@ func (mon *Monitor) LogMetric(ctx context.Context, m *api.Metric) error {
 	return nil
 }
 
-// PublishMetric broadcasts a metric to all current cluster peers.
+// PublishMetric broadcasts a metric to all current cluster peers. The TTL
+// of the metric is adjusted according to the configuration, and its
+// Interval is set to half of it: callers are expected to publish the metric
+// again on that interval. When batching is enabled, the metric is queued
+// and sent along with others on the next batch.
 func (mon *Monitor) PublishMetric(ctx context.Context, m *api.Metric) error {
 	ctx, span := trace.StartSpan(ctx, "monitor/pubsub/PublishMetric")
 	defer span.End()
@@ -219,6 +262,16 @@ func (mon *Monitor) PublishMetric(ctx context.Context, m *api.Metric) error {
 		return nil
 	}
 
+	mon.adjustTTL(ctx, m)
+
+	if mon.config.BatchInterval > 0 {
+		debug("queue", m)
+		mon.batchMux.Lock()
+		mon.batch = append(mon.batch, m)
+		mon.batchMux.Unlock()
+		return nil
+	}
+
 	var b bytes.Buffer
 
 	enc := gocodec.NewEncoder(&b, msgpackHandle)
@@ -239,6 +292,94 @@ func (mon *Monitor) PublishMetric(ctx context.Context, m *api.Metric) error {
 	return nil
 }
 
+// publishBatches sends the queued metrics every BatchInterval.
+func (mon *Monitor) publishBatches() {
+	defer mon.wg.Done()
+
+	ticker := time.NewTicker(mon.config.BatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-mon.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		mon.batchMux.Lock()
+		batch := mon.batch
+		mon.batch = nil
+		mon.batchMux.Unlock()
+		if len(batch) == 0 {
+			continue
+		}
+
+		b := bytes.NewBuffer(append([]byte{}, batchPrefix...))
+		enc := gocodec.NewEncoder(b, msgpackHandle)
+		if err := enc.Encode(batch); err != nil {
+			logger.Error(err)
+			continue
+		}
+
+		logger.Debugf("publishing a batch of %d metrics", len(batch))
+		if err := mon.topic.Publish(mon.ctx, b.Bytes()); err != nil {
+			logger.Error(err)
+		}
+	}
+}
+
+// adjustTTL applies the configured TTL for the metric and scales it with
+// the size of the cluster when AdaptivePeers is set.
+func (mon *Monitor) adjustTTL(ctx context.Context, m *api.Metric) {
+	ttl := m.GetTTL()
+	configured, ok := mon.config.MetricTTLs[m.Name]
+	if ok {
+		ttl = configured
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	factor := mon.currentTTLFactor(ctx)
+	if ok || factor > 1 {
+		ttl *= time.Duration(factor)
+		m.SetTTL(ttl)
+	}
+	m.Interval = int64(ttl / 2)
+}
+
+// currentTTLFactor returns how many times metric TTLs are multiplied given
+// the current number of peers, which is checked at most every
+// CheckInterval.
+func (mon *Monitor) currentTTLFactor(ctx context.Context) int {
+	if mon.config.AdaptivePeers <= 0 || mon.peers == nil {
+		return 1
+	}
+
+	mon.ttlFactorMux.Lock()
+	defer mon.ttlFactorMux.Unlock()
+
+	if time.Since(mon.ttlFactorUpdatedAt) < mon.config.CheckInterval {
+		return mon.ttlFactor
+	}
+
+	peers, err := mon.peers(ctx)
+	if err != nil {
+		logger.Error(err)
+		return mon.ttlFactor
+	}
+
+	factor := 1 + len(peers)/mon.config.AdaptivePeers
+	if factor > mon.config.MaxTTLFactor {
+		factor = mon.config.MaxTTLFactor
+	}
+	if factor != mon.ttlFactor {
+		logger.Infof("%d peers: metric TTLs multiplied by %d", len(peers), factor)
+	}
+	mon.ttlFactor = factor
+	mon.ttlFactorUpdatedAt = time.Now()
+	return factor
+}
+
 // LatestMetrics returns last known VALID metrics of a given type. A metric
 // is only valid if it has not expired and belongs to a current cluster peer.
 func (mon *Monitor) LatestMetrics(ctx context.Context, name string) []*api.Metric {
@@ -267,6 +408,35 @@ func (mon *Monitor) Alerts() <-chan *api.Alert {
 	return mon.checker.Alerts()
 }
 
+// DetectorState returns the state of the failure detector for the latest
+// metrics of the current cluster peers.
+func (mon *Monitor) DetectorState(ctx context.Context) []*api.DetectorState {
+	ctx, span := trace.StartSpan(ctx, "monitor/pubsub/DetectorState")
+	defer span.End()
+
+	states := mon.checker.DetectorState()
+	if mon.peers == nil {
+		return states
+	}
+
+	peers, err := mon.peers(ctx)
+	if err != nil {
+		return []*api.DetectorState{}
+	}
+	peerMap := make(map[peer.ID]struct{}, len(peers))
+	for _, pid := range peers {
+		peerMap[pid] = struct{}{}
+	}
+
+	filtered := make([]*api.DetectorState, 0, len(states))
+	for _, st := range states {
+		if _, ok := peerMap[st.Peer]; ok {
+			filtered = append(filtered, st)
+		}
+	}
+	return filtered
+}
+
 // MetricNames lists all metric names.
 func (mon *Monitor) MetricNames(ctx context.Context) []string {
 	_, span := trace.StartSpan(ctx, "monitor/pubsub/MetricNames")
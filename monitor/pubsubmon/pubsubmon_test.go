@@ -60,6 +60,10 @@ func peers(ctx context.Context) ([]peer.ID, error) {
 }
 
 func testPeerMonitor(t *testing.T) (*Monitor, host.Host, func()) {
+	return testPeerMonitorWithConfig(t, func(cfg *Config) {})
+}
+
+func testPeerMonitorWithConfig(t *testing.T, cfgF func(cfg *Config)) (*Monitor, host.Host, func()) {
 	ctx := context.Background()
 	h, err := libp2p.New(
 		libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
@@ -83,6 +87,7 @@ func testPeerMonitor(t *testing.T) (*Monitor, host.Host, func()) {
 	cfg := &Config{}
 	cfg.Default()
 	cfg.CheckInterval = 2 * time.Second
+	cfgF(cfg)
 	mon, err := New(ctx, cfg, psub, peers)
 	if err != nil {
 		t.Fatal(err)
@@ -284,6 +289,57 @@ func TestPeerMonitorPublishMetric(t *testing.T) {
 	checkMetric(t, pm2)
 }
 
+func TestPeerMonitorPublishBatch(t *testing.T) {
+	ctx := context.Background()
+	cfgF := func(cfg *Config) {
+		cfg.BatchInterval = 100 * time.Millisecond
+		cfg.MetricTTLs = map[string]time.Duration{"test2": 20 * time.Second}
+		cfg.AdaptivePeers = 2 // 3 peers: ttls doubled
+	}
+	pm, host, shutdown := testPeerMonitorWithConfig(t, cfgF)
+	defer shutdown()
+	pm2, host2, shutdown2 := testPeerMonitorWithConfig(t, cfgF)
+	defer shutdown2()
+
+	time.Sleep(200 * time.Millisecond)
+
+	err := host.Connect(
+		context.Background(),
+		peer.AddrInfo{
+			ID:    host2.ID(),
+			Addrs: host2.Addrs(),
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	mf := newMetricFactory()
+	for _, name := range []string{"test", "test2"} {
+		if err := pm.PublishMetric(ctx, mf.newMetric(name, test.PeerID1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	time.Sleep(500 * time.Millisecond)
+
+	for name, interval := range map[string]time.Duration{
+		"test":  5 * time.Second,  // 5s ttl doubled
+		"test2": 20 * time.Second, // 20s ttl doubled
+	} {
+		latest := pm2.LatestMetrics(ctx, name)
+		if len(latest) != 1 {
+			t.Fatalf("expected one %s metric", name)
+		}
+		got := time.Duration(latest[0].Interval)
+		if got < interval-time.Second || got > interval {
+			t.Errorf("unexpected interval for %s: %s", name, got)
+		}
+	}
+}
+
 func TestPeerMonitorAlerts(t *testing.T) {
 	ctx := context.Background()
 	pm, _, shutdown := testPeerMonitor(t)
@@ -0,0 +1,265 @@
+package ipfscluster
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/dnslink"
+	"github.com/ipfs/ipfs-cluster/state"
+
+	cid "github.com/ipfs/go-cid"
+	trace "go.opencensus.io/trace"
+)
+
+// The names of pins carrying the api.IPNSKeyMetaKey or api.DNSLinkMetaKey
+// metadata keys are published during StateSync, once the pins are pinned
+// in all their allocations. Only the IPNS keys and DNSLink domains allowed
+// in the configuration are published, since any user can set pin metadata.
+// When several pins ask for the same name, the most recent one wins. Every name is published by the peer closest to it,
+// which remembers what it last published, so names are published again
+// when the responsible peer changes or restarts.
+
+const (
+	ipnsTarget    = "ipns:"
+	dnslinkTarget = "dnslink:"
+)
+
+// namePublisher keeps track of the names published by this peer.
+type namePublisher struct {
+	dnslink dnslink.Provider
+	allowed map[string]struct{} // targets
+
+	// running is set while a round of publications is in progress.
+	running int32
+
+	publishedMux sync.Mutex
+	published    map[string]cid.Cid
+}
+
+func newNamePublisher(cfg NamePublishingConfig) *namePublisher {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	var provider dnslink.Provider
+	dl := cfg.DNSLink
+	switch dl.Provider {
+	case "http":
+		provider = &dnslink.HTTP{URL: dl.URL}
+	case "rfc2136":
+		provider = &dnslink.RFC2136{
+			Server:        dl.Server,
+			Zone:          dl.Zone,
+			TTL:           dl.TTL,
+			TSIGKeyName:   dl.TSIGKeyName,
+			TSIGSecret:    dl.TSIGSecret,
+			TSIGAlgorithm: dl.TSIGAlgorithm,
+		}
+	}
+
+	allowed := make(map[string]struct{}, len(cfg.IPNSKeys)+len(cfg.DNSLinkDomains))
+	for _, key := range cfg.IPNSKeys {
+		allowed[ipnsTarget+key] = struct{}{}
+	}
+	for _, domain := range cfg.DNSLinkDomains {
+		allowed[dnslinkTarget+strings.TrimSuffix(domain, ".")] = struct{}{}
+	}
+
+	return &namePublisher{
+		dnslink:   provider,
+		allowed:   allowed,
+		published: make(map[string]cid.Cid),
+	}
+}
+
+func (np *namePublisher) isAllowed(target string) bool {
+	_, ok := np.allowed[target]
+	return ok
+}
+
+func (np *namePublisher) isPublished(target string, ci cid.Cid) bool {
+	np.publishedMux.Lock()
+	defer np.publishedMux.Unlock()
+	published, ok := np.published[target]
+	return ok && published.Equals(ci)
+}
+
+func (np *namePublisher) setPublished(target string, ci cid.Cid) {
+	np.publishedMux.Lock()
+	defer np.publishedMux.Unlock()
+	np.published[target] = ci
+}
+
+// listNamedPins returns the pins in the state with a name to publish.
+func listNamedPins(ctx context.Context, cState state.ReadOnly) ([]*api.Pin, error) {
+	il, ok := cState.(state.IndexedLister)
+	if !ok {
+		all, err := cState.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var pins []*api.Pin
+		for _, p := range all {
+			if p.Metadata[api.IPNSKeyMetaKey] != "" || p.Metadata[api.DNSLinkMetaKey] != "" {
+				pins = append(pins, p)
+			}
+		}
+		return pins, nil
+	}
+
+	pins, err := il.ListByMetadata(ctx, api.IPNSKeyMetaKey, "")
+	if err != nil {
+		return nil, err
+	}
+	dnslinkPins, err := il.ListByMetadata(ctx, api.DNSLinkMetaKey, "")
+	if err != nil {
+		return nil, err
+	}
+	return append(pins, dnslinkPins...), nil
+}
+
+// nameTargets returns the most recent pin for every name, indexed by
+// target ("ipns:<key>" or "dnslink:<domain>"). Trashed pins and pins which
+// are not data pins are ignored.
+func nameTargets(pins []*api.Pin) map[string]*api.Pin {
+	targets := make(map[string]*api.Pin)
+	add := func(target string, p *api.Pin) {
+		if cur, ok := targets[target]; ok && !p.Timestamp.After(cur.Timestamp) {
+			return
+		}
+		targets[target] = p
+	}
+
+	for _, p := range pins {
+		if p.Type != api.DataType {
+			continue
+		}
+		if _, trashed := p.TrashedAt(); trashed {
+			continue
+		}
+		if key := p.Metadata[api.IPNSKeyMetaKey]; key != "" {
+			add(ipnsTarget+key, p)
+		}
+		if domain := p.Metadata[api.DNSLinkMetaKey]; domain != "" {
+			add(dnslinkTarget+strings.TrimSuffix(domain, "."), p)
+		}
+	}
+	return targets
+}
+
+// publishNames publishes, in the background, the names which this peer is
+// responsible for and whose pins are now pinned cluster-wide. Nothing is
+// done while a previous round is still running.
+func (c *Cluster) publishNames(ctx context.Context, cState state.ReadOnly, distance *distanceChecker) {
+	np := c.names
+	if np == nil {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&np.running, 0, 1) {
+		logger.Debug("skipping name publication: still publishing")
+		return
+	}
+
+	pins, err := listNamedPins(ctx, cState)
+	if err != nil {
+		atomic.StoreInt32(&np.running, 0)
+		logger.Error(err)
+		return
+	}
+
+	targets := nameTargets(pins)
+	keys := make([]string, 0, len(targets))
+	for target, p := range targets {
+		if !np.isAllowed(target) {
+			logger.Debugf("not publishing %s to %s: not allowed by the configuration", p.Cid, target)
+			continue
+		}
+		if distance.isClosestKey(target) && !np.isPublished(target, p.Cid) {
+			keys = append(keys, target)
+		}
+	}
+	if len(keys) == 0 {
+		atomic.StoreInt32(&np.running, 0)
+		return
+	}
+	sort.Strings(keys)
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer atomic.StoreInt32(&np.running, 0)
+
+		for _, target := range keys {
+			if c.ctx.Err() != nil {
+				return
+			}
+			c.publishName(ctx, target, targets[target])
+		}
+	}()
+}
+
+// publishName publishes the pin under the given target when it is pinned
+// cluster-wide.
+func (c *Cluster) publishName(ctx context.Context, target string, pin *api.Pin) {
+	ctx, span := trace.StartSpan(ctx, "cluster/publishName")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, c.config.NamePublishing.Timeout)
+	defer cancel()
+
+	if !c.pinnedClusterWide(ctx, pin.Cid) {
+		return
+	}
+
+	var err error
+	switch {
+	case strings.HasPrefix(target, ipnsTarget):
+		key := strings.TrimPrefix(target, ipnsTarget)
+		var name string
+		name, err = c.ipfs.NamePublish(ctx, pin.Cid, key)
+		if err == nil {
+			logger.Infof("published %s to /ipns/%s (key %s)", pin.Cid, name, key)
+		}
+	case strings.HasPrefix(target, dnslinkTarget):
+		domain := strings.TrimPrefix(target, dnslinkTarget)
+		if c.names.dnslink == nil {
+			logger.Warnf("not updating the DNSLink of %s: no DNSLink provider configured", domain)
+			return
+		}
+		err = c.names.dnslink.Update(ctx, domain, "/ipfs/"+pin.Cid.String())
+		if err == nil {
+			logger.Infof("updated the DNSLink of %s to %s", domain, pin.Cid)
+		}
+	}
+	if err != nil {
+		logger.Errorf("error publishing %s to %s: %s", pin.Cid, target, err)
+		return
+	}
+	c.names.setPublished(target, pin.Cid)
+}
+
+// pinnedClusterWide returns true when the cid is pinned in all its
+// allocations.
+func (c *Cluster) pinnedClusterWide(ctx context.Context, ci cid.Cid) bool {
+	gpi, err := c.Status(ctx, ci)
+	if err != nil {
+		logger.Error(err)
+		return false
+	}
+
+	pinned := false
+	for _, pi := range gpi.PeerMap {
+		switch pi.Status {
+		case api.TrackerStatusPinned:
+			pinned = true
+		case api.TrackerStatusRemote:
+		default:
+			return false
+		}
+	}
+	return pinned
+}
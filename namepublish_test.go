@@ -0,0 +1,133 @@
+package ipfscluster
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/dnslink"
+	"github.com/ipfs/ipfs-cluster/test"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+func TestNameTargets(t *testing.T) {
+	now := time.Now()
+	pin := func(c cid.Cid, ts time.Time, meta map[string]string) *api.Pin {
+		p := api.PinWithOpts(c, api.PinOptions{Metadata: meta})
+		p.Timestamp = ts
+		return p
+	}
+
+	old := pin(test.Cid1, now.Add(-time.Hour), map[string]string{api.IPNSKeyMetaKey: "site"})
+	newer := pin(test.Cid2, now, map[string]string{api.IPNSKeyMetaKey: "site", api.DNSLinkMetaKey: "example.org."})
+	trashed := pin(test.Cid3, now.Add(time.Hour), map[string]string{
+		api.IPNSKeyMetaKey:   "site",
+		api.TrashedAtMetaKey: now.Format(time.RFC3339),
+	})
+
+	targets := nameTargets([]*api.Pin{newer, old, trashed})
+	if len(targets) != 2 {
+		t.Fatalf("expected 2 targets: %v", targets)
+	}
+	if !targets["ipns:site"].Cid.Equals(test.Cid2) {
+		t.Error("expected the most recent pin to win")
+	}
+	if !targets["dnslink:example.org"].Cid.Equals(test.Cid2) {
+		t.Error("expected a dnslink target for example.org")
+	}
+}
+
+func TestClusterPublishNames(t *testing.T) {
+	ctx := context.Background()
+	cl, _, ipfs, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	updates := make(chan dnslink.HTTPRequest, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req dnslink.HTTPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		updates <- req
+	}))
+	defer srv.Close()
+
+	cl.config.NamePublishing.Enabled = true
+	cl.config.NamePublishing.DNSLink = DNSLinkConfig{Provider: "http", URL: srv.URL}
+	cl.config.NamePublishing.IPNSKeys = []string{"site"}
+	cl.config.NamePublishing.DNSLinkDomains = []string{"example.org."}
+	cl.names = newNamePublisher(cl.config.NamePublishing)
+
+	_, err := cl.Pin(ctx, test.Cid1, api.PinOptions{
+		Metadata: map[string]string{api.IPNSKeyMetaKey: "site"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cl.Pin(ctx, test.Cid2, api.PinOptions{
+		Metadata: map[string]string{api.DNSLinkMetaKey: "example.org"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Names not in the configuration are not published.
+	_, err = cl.Pin(ctx, test.Cid3, api.PinOptions{
+		Metadata: map[string]string{
+			api.IPNSKeyMetaKey: "other",
+			api.DNSLinkMetaKey: "example.com",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	syncNames := func() {
+		t.Helper()
+		if err := cl.StateSync(ctx); err != nil {
+			t.Fatal(err)
+		}
+		for atomic.LoadInt32(&cl.names.running) != 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	syncNames()
+
+	v, ok := ipfs.names.Load("site")
+	if !ok || !v.(cid.Cid).Equals(test.Cid1) {
+		t.Errorf("expected %s to be published with the site key", test.Cid1)
+	}
+	select {
+	case req := <-updates:
+		if req.Domain != "example.org" || req.Value != "dnslink=/ipfs/"+test.Cid2.String() {
+			t.Errorf("unexpected DNSLink update: %+v", req)
+		}
+	default:
+		t.Error("expected a DNSLink update")
+	}
+	if _, ok := ipfs.names.Load("other"); ok {
+		t.Error("the other key should not have been published")
+	}
+	select {
+	case req := <-updates:
+		t.Errorf("unexpected DNSLink update: %+v", req)
+	default:
+	}
+
+	// Names are not published again.
+	ipfs.names.Delete("site")
+	syncNames()
+	if _, ok := ipfs.names.Load("site"); ok {
+		t.Error("the name should not have been published again")
+	}
+	select {
+	case req := <-updates:
+		t.Errorf("unexpected DNSLink update: %+v", req)
+	default:
+	}
+}
@@ -23,6 +23,7 @@ const (
 	DefaultEnableStats        = false
 	DefaultPrometheusEndpoint = "/ip4/127.0.0.1/tcp/8888"
 	DefaultReportingInterval  = 2 * time.Second
+	DefaultPushFormat         = PushFormatStatsd
 
 	DefaultEnableTracing       = false
 	DefaultJaegerAgentEndpoint = "/ip4/0.0.0.0/udp/6831"
@@ -30,6 +31,14 @@ const (
 	DefaultServiceName         = "cluster-daemon"
 )
 
+// Formats in which metrics can be pushed.
+const (
+	// PushFormatStatsd sends StatsD gauges, with DogStatsD tags.
+	PushFormatStatsd = "statsd"
+	// PushFormatInflux sends InfluxDB line protocol.
+	PushFormatInflux = "influx"
+)
+
 // MetricsConfig configures metrics collection.
 type MetricsConfig struct {
 	config.Saver
@@ -37,12 +46,28 @@ type MetricsConfig struct {
 	EnableStats        bool
 	PrometheusEndpoint ma.Multiaddr
 	ReportingInterval  time.Duration
+
+	// PushEndpoint is a UDP or TCP multiaddress to which metrics are
+	// sent every ReportingInterval, in addition to being served for
+	// Prometheus. Pushing is disabled when nil.
+	PushEndpoint ma.Multiaddr
+	// PushFormat is either PushFormatStatsd or PushFormatInflux.
+	PushFormat string
+	// Tags are added to every pushed metric, i.e. the cluster name
+	// or the region of the peer.
+	Tags map[string]string
+	// ClusterPeername is added to pushed metrics as the "peer" tag,
+	// unless Tags sets it.
+	ClusterPeername string
 }
 
 type jsonMetricsConfig struct {
-	EnableStats        bool   `json:"enable_stats"`
-	PrometheusEndpoint string `json:"prometheus_endpoint"`
-	ReportingInterval  string `json:"reporting_interval"`
+	EnableStats        bool              `json:"enable_stats"`
+	PrometheusEndpoint string            `json:"prometheus_endpoint"`
+	ReportingInterval  string            `json:"reporting_interval"`
+	PushEndpoint       string            `json:"push_endpoint"`
+	PushFormat         string            `json:"push_format"`
+	Tags               map[string]string `json:"tags,omitempty"`
 }
 
 // ConfigKey provides a human-friendly identifier for this type of Config.
@@ -56,6 +81,9 @@ func (cfg *MetricsConfig) Default() error {
 	endpointAddr, _ := ma.NewMultiaddr(DefaultPrometheusEndpoint)
 	cfg.PrometheusEndpoint = endpointAddr
 	cfg.ReportingInterval = DefaultReportingInterval
+	cfg.PushEndpoint = nil
+	cfg.PushFormat = DefaultPushFormat
+	cfg.Tags = nil
 
 	return nil
 }
@@ -83,6 +111,11 @@ func (cfg *MetricsConfig) Validate() error {
 		if cfg.ReportingInterval < 0 {
 			return errors.New("metrics.reporting_interval is invalid")
 		}
+		switch cfg.PushFormat {
+		case PushFormatStatsd, PushFormatInflux:
+		default:
+			return fmt.Errorf("metrics.push_format must be %q or %q", PushFormatStatsd, PushFormatInflux)
+		}
 	}
 	return nil
 }
@@ -119,6 +152,17 @@ func (cfg *MetricsConfig) loadMetricsOptions(jcfg *jsonMetricsConfig) error {
 	}
 	cfg.PrometheusEndpoint = endpointAddr
 
+	cfg.PushEndpoint = nil
+	if jcfg.PushEndpoint != "" {
+		pushAddr, err := ma.NewMultiaddr(jcfg.PushEndpoint)
+		if err != nil {
+			return fmt.Errorf("loadMetricsOptions: PushEndpoint multiaddr: %v", err)
+		}
+		cfg.PushEndpoint = pushAddr
+	}
+	config.SetIfNotDefault(jcfg.PushFormat, &cfg.PushFormat)
+	cfg.Tags = jcfg.Tags
+
 	return config.ParseDurations(
 		metricsConfigKey,
 		&config.DurationOpt{
@@ -137,11 +181,17 @@ func (cfg *MetricsConfig) ToJSON() ([]byte, error) {
 }
 
 func (cfg *MetricsConfig) toJSONConfig() *jsonMetricsConfig {
-	return &jsonMetricsConfig{
+	jcfg := &jsonMetricsConfig{
 		EnableStats:        cfg.EnableStats,
 		PrometheusEndpoint: cfg.PrometheusEndpoint.String(),
 		ReportingInterval:  cfg.ReportingInterval.String(),
+		PushFormat:         cfg.PushFormat,
+		Tags:               cfg.Tags,
+	}
+	if cfg.PushEndpoint != nil {
+		jcfg.PushEndpoint = cfg.PushEndpoint.String()
 	}
+	return jcfg
 }
 
 // ToDisplayJSON returns JSON config as a string.
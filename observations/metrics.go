@@ -12,9 +12,11 @@ var logger = logging.Logger("observations")
 
 var (
 	// taken from ocgrpc (https://github.com/census-instrumentation/opencensus-go/blob/master/plugin/ocgrpc/stats_common.go)
-	// latencyDistribution      = view.Distribution(0, 0.01, 0.05, 0.1, 0.3, 0.6, 0.8, 1, 2, 3, 4, 5, 6, 8, 10, 13, 16, 20, 25, 30, 40, 50, 65, 80, 100, 130, 160, 200, 250, 300, 400, 500, 650, 800, 1000, 2000, 5000, 10000, 20000, 50000, 100000)
+	latencyDistribution = view.Distribution(0, 0.01, 0.05, 0.1, 0.3, 0.6, 0.8, 1, 2, 3, 4, 5, 6, 8, 10, 13, 16, 20, 25, 30, 40, 50, 65, 80, 100, 130, 160, 200, 250, 300, 400, 500, 650, 800, 1000, 2000, 5000, 10000, 20000, 50000, 100000)
 	// bytesDistribution        = view.Distribution(0, 24, 32, 64, 128, 256, 512, 1024, 2048, 4096, 16384, 65536, 262144, 1048576)
 	messageCountDistribution = view.Distribution(1, 2, 4, 8, 16, 32, 64, 128, 256, 512, 1024, 2048, 4096, 8192, 16384, 32768, 65536)
+	// milliseconds, from 1ms to 1 day
+	queueWaitDistribution = view.Distribution(1, 10, 100, 500, 1000, 5000, 10000, 30000, 60000, 300000, 600000, 1800000, 3600000, 21600000, 86400000)
 )
 
 // attributes
@@ -26,6 +28,18 @@ var (
 var (
 	HostKey       = makeKey("host")
 	RemotePeerKey = makeKey("remote_peer")
+	OriginKey     = makeKey("origin")
+	APIKey        = makeKey("api")
+	RouteKey      = makeKey("route")
+	StatusKey     = makeKey("status")
+	RPCMethodKey  = makeKey("rpc_method")
+	ResultKey     = makeKey("result")
+)
+
+// Values of ResultKey.
+const (
+	ResultOK    = "ok"
+	ResultError = "error"
 )
 
 // metrics
@@ -38,6 +52,22 @@ var (
 	Peers = stats.Int64("cluster/peers", "Number of cluster peers", stats.UnitDimensionless)
 	// Alerts is the number of alerts that have been sent due to peers not sending "ping" heartbeats in time.
 	Alerts = stats.Int64("cluster/alerts", "Number of alerts triggered", stats.UnitDimensionless)
+	// PinnedSize is the cumulative size of the items pinned recursively in
+	// the IPFS daemon of the local peer.
+	PinnedSize = stats.Int64("pintracker/pinned_size", "Size of pinned content", stats.UnitBytes)
+	// PinQueueWait is the time pin operations spend queued before a worker
+	// takes them.
+	PinQueueWait = stats.Float64("pintracker/pin_queue_wait", "Time waited in the pin queue", stats.UnitMilliseconds)
+	// APIRequestLatency is the time taken by the HTTP APIs to serve a
+	// request, by API, route and response status code.
+	APIRequestLatency = stats.Float64("api/request_latency", "Latency of API requests", stats.UnitMilliseconds)
+	// RPCServerLatency is the time taken to serve an RPC request, local or
+	// remote, by method.
+	RPCServerLatency = stats.Float64("rpc/server_latency", "Latency of served RPC requests", stats.UnitMilliseconds)
+	// RPCCallLatency is the time taken by RPC requests made to other
+	// peers in broadcasts, by method and result, including the network
+	// round trip.
+	RPCCallLatency = stats.Float64("rpc/call_latency", "Latency of RPC requests to other peers", stats.UnitMilliseconds)
 )
 
 // views, which is just the aggregation of the metrics
@@ -66,11 +96,46 @@ var (
 		Aggregation: messageCountDistribution,
 	}
 
+	PinnedSizeView = &view.View{
+		Measure:     PinnedSize,
+		TagKeys:     []tag.Key{HostKey},
+		Aggregation: view.LastValue(),
+	}
+
+	PinQueueWaitView = &view.View{
+		Measure:     PinQueueWait,
+		TagKeys:     []tag.Key{HostKey, OriginKey},
+		Aggregation: queueWaitDistribution,
+	}
+
+	APIRequestLatencyView = &view.View{
+		Measure:     APIRequestLatency,
+		TagKeys:     []tag.Key{APIKey, RouteKey, StatusKey},
+		Aggregation: latencyDistribution,
+	}
+
+	RPCServerLatencyView = &view.View{
+		Measure:     RPCServerLatency,
+		TagKeys:     []tag.Key{RPCMethodKey},
+		Aggregation: latencyDistribution,
+	}
+
+	RPCCallLatencyView = &view.View{
+		Measure:     RPCCallLatency,
+		TagKeys:     []tag.Key{RPCMethodKey, ResultKey},
+		Aggregation: latencyDistribution,
+	}
+
 	DefaultViews = []*view.View{
 		PinsView,
 		TrackerPinsView,
 		PeersView,
 		AlertsView,
+		PinnedSizeView,
+		PinQueueWaitView,
+		APIRequestLatencyView,
+		RPCServerLatencyView,
+		RPCCallLatencyView,
 	}
 )
 
@@ -0,0 +1,217 @@
+package observations
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+
+	"go.opencensus.io/stats/view"
+)
+
+// Timeouts to connect and to send the metrics to the push endpoint, so that
+// an unresponsive endpoint does not hold the exports of the views.
+var (
+	pushDialTimeout  = 5 * time.Second
+	pushWriteTimeout = 5 * time.Second
+)
+
+// pushExporter is an OpenCensus view exporter which sends the views to a
+// StatsD or InfluxDB endpoint every reporting interval, for push-based
+// metrics stacks. Views are cumulative, so every value is sent as a gauge
+// (StatsD) or a field (InfluxDB) holding the current aggregate. Distributions
+// are sent as their count, sum, mean, min and max.
+type pushExporter struct {
+	endpoint ma.Multiaddr
+	format   string
+	tags     map[string]string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func newPushExporter(cfg *MetricsConfig) *pushExporter {
+	tags := make(map[string]string, len(cfg.Tags)+1)
+	if cfg.ClusterPeername != "" {
+		tags["peer"] = cfg.ClusterPeername
+	}
+	for k, v := range cfg.Tags {
+		tags[k] = v
+	}
+	return &pushExporter{
+		endpoint: cfg.PushEndpoint,
+		format:   cfg.PushFormat,
+		tags:     tags,
+	}
+}
+
+// ExportView implements view.Exporter.
+func (pe *pushExporter) ExportView(vd *view.Data) {
+	lines := pe.lines(vd)
+	if len(lines) == 0 {
+		return
+	}
+
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+	if pe.conn == nil {
+		dialer := manet.Dialer{Dialer: net.Dialer{Timeout: pushDialTimeout}}
+		conn, err := dialer.Dial(pe.endpoint)
+		if err != nil {
+			logger.Errorf("cannot connect to metrics push endpoint %s: %s", pe.endpoint, err)
+			return
+		}
+		pe.conn = conn
+	}
+	// One line per write, which makes one datagram per metric with UDP.
+	pe.conn.SetWriteDeadline(time.Now().Add(pushWriteTimeout))
+	for _, l := range lines {
+		if _, err := io.WriteString(pe.conn, l); err != nil {
+			logger.Errorf("error pushing metrics to %s: %s", pe.endpoint, err)
+			pe.conn.Close()
+			pe.conn = nil // dial again on the next export
+			return
+		}
+	}
+}
+
+// pushValue is a named value of a row of a view.
+type pushValue struct {
+	name  string
+	value float64
+	isInt bool
+}
+
+func rowValues(data view.AggregationData) []pushValue {
+	switch d := data.(type) {
+	case *view.CountData:
+		return []pushValue{{name: "count", value: float64(d.Value), isInt: true}}
+	case *view.SumData:
+		return []pushValue{{name: "sum", value: d.Value}}
+	case *view.LastValueData:
+		return []pushValue{{name: "value", value: d.Value}}
+	case *view.DistributionData:
+		return []pushValue{
+			{name: "count", value: float64(d.Count), isInt: true},
+			{name: "sum", value: d.Sum()},
+			{name: "mean", value: d.Mean},
+			{name: "min", value: d.Min},
+			{name: "max", value: d.Max},
+		}
+	default:
+		return nil
+	}
+}
+
+// lines formats the rows of a view in the format of the exporter.
+func (pe *pushExporter) lines(vd *view.Data) []string {
+	name := vd.View.Name
+	if name == "" { // views are named after their measure by default
+		name = vd.View.Measure.Name()
+	}
+	name = "ipfscluster_" + sanitizeMetricName(name)
+	var lines []string
+	for _, row := range vd.Rows {
+		tags := make(map[string]string, len(pe.tags)+len(row.Tags))
+		for k, v := range pe.tags {
+			tags[k] = v
+		}
+		for _, t := range row.Tags {
+			tags[t.Key.Name()] = t.Value
+		}
+		values := rowValues(row.Data)
+		if len(values) == 0 {
+			continue
+		}
+
+		switch pe.format {
+		case PushFormatInflux:
+			lines = append(lines, influxLine(name, tags, values, vd.End))
+		default:
+			for _, v := range values {
+				lines = append(lines, statsdLine(name+"."+v.name, tags, v.value))
+			}
+		}
+	}
+	return lines
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// statsdLine formats a gauge with DogStatsD tags:
+// "name:value|g|#key:value,key:value".
+func statsdLine(name string, tags map[string]string, value float64) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s:%g|g", name, value)
+	for i, k := range sortedKeys(tags) {
+		if i == 0 {
+			b.WriteString("|#")
+		} else {
+			b.WriteString(",")
+		}
+		b.WriteString(statsdEscaper.Replace(k))
+		b.WriteString(":")
+		b.WriteString(statsdEscaper.Replace(tags[k]))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// influxLine formats a point in InfluxDB line protocol:
+// "measurement,key=value field=value timestamp".
+func influxLine(name string, tags map[string]string, values []pushValue, t time.Time) string {
+	var b strings.Builder
+	b.WriteString(name)
+	for _, k := range sortedKeys(tags) {
+		if tags[k] == "" { // empty tag values are invalid
+			continue
+		}
+		b.WriteString(",")
+		b.WriteString(influxEscaper.Replace(k))
+		b.WriteString("=")
+		b.WriteString(influxEscaper.Replace(tags[k]))
+	}
+	for i, v := range values {
+		if i == 0 {
+			b.WriteString(" ")
+		} else {
+			b.WriteString(",")
+		}
+		if v.isInt {
+			fmt.Fprintf(&b, "%s=%di", v.name, int64(v.value))
+		} else {
+			fmt.Fprintf(&b, "%s=%g", v.name, v.value)
+		}
+	}
+	fmt.Fprintf(&b, " %d\n", t.UnixNano())
+	return b.String()
+}
+
+var (
+	statsdEscaper = strings.NewReplacer(",", "_", "|", "_", ":", "_", "\n", "_")
+	influxEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", "")
+)
+
+func sanitizeMetricName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
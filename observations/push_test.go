@@ -0,0 +1,103 @@
+package observations
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+func testViewData() *view.Data {
+	return &view.Data{
+		View: PinsView,
+		End:  time.Unix(10, 0),
+		Rows: []*view.Row{
+			{
+				Tags: []tag.Tag{{Key: HostKey, Value: "host a"}},
+				Data: &view.LastValueData{Value: 12},
+			},
+		},
+	}
+}
+
+func TestPushExporterLines(t *testing.T) {
+	cfg := &MetricsConfig{}
+	cfg.Default()
+	cfg.ClusterPeername = "peer1"
+	cfg.Tags = map[string]string{"region": "eu"}
+
+	pe := newPushExporter(cfg)
+	lines := pe.lines(testViewData())
+	expected := "ipfscluster_cluster_pin_count.value:12|g|#host:host a,peer:peer1,region:eu\n"
+	if len(lines) != 1 || lines[0] != expected {
+		t.Errorf("unexpected statsd lines: %q", lines)
+	}
+
+	pe.format = PushFormatInflux
+	lines = pe.lines(testViewData())
+	expected = `ipfscluster_cluster_pin_count,host=host\ a,peer=peer1,region=eu value=12 10000000000` + "\n"
+	if len(lines) != 1 || lines[0] != expected {
+		t.Errorf("unexpected influx lines: %q", lines)
+	}
+
+	pe.format = PushFormatStatsd
+	lines = pe.lines(&view.Data{
+		View: PinQueueWaitView,
+		Rows: []*view.Row{{Data: &view.DistributionData{Count: 2, Mean: 5, Min: 1, Max: 9}}},
+	})
+	if len(lines) != 5 || !strings.Contains(lines[0], ".count:2|g") {
+		t.Errorf("unexpected distribution lines: %q", lines)
+	}
+}
+
+func TestPushExporterUDP(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	cfg := &MetricsConfig{}
+	cfg.Default()
+	cfg.PushEndpoint, _ = ma.NewMultiaddr("/ip4/127.0.0.1/udp/" + strings.Split(conn.LocalAddr().String(), ":")[1])
+	pe := newPushExporter(cfg)
+	pe.ExportView(testViewData())
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(buf[:n]), ".value:12|g|#host:host a\n") {
+		t.Errorf("unexpected datagram: %q", buf[:n])
+	}
+}
+
+func TestMetricsConfigPush(t *testing.T) {
+	cfg := &MetricsConfig{}
+	err := cfg.LoadJSON([]byte(`{
+  "enable_stats": true,
+  "prometheus_endpoint": "/ip4/127.0.0.1/tcp/8888",
+  "reporting_interval": "2s",
+  "push_endpoint": "/ip4/127.0.0.1/udp/8089",
+  "push_format": "influx",
+  "tags": {"cluster": "c1"}
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.PushEndpoint == nil || cfg.PushFormat != PushFormatInflux || cfg.Tags["cluster"] != "c1" {
+		t.Error("push options not loaded")
+	}
+
+	cfg.PushFormat = "graphite"
+	if cfg.Validate() == nil {
+		t.Error("expected an error with an unknown push format")
+	}
+}
@@ -0,0 +1,72 @@
+package observations
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	rpcstats "github.com/libp2p/go-libp2p-gorpc/stats"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// RPCStatsHandler is a go-libp2p-gorpc stats.Handler which records the
+// latency of the RPC requests served by a peer, by method. Next, when set,
+// is another handler (i.e. the tracing one) which receives the same calls.
+type RPCStatsHandler struct {
+	Next rpcstats.Handler
+}
+
+// TagRPC implements stats.Handler.
+func (h *RPCStatsHandler) TagRPC(ctx context.Context, info *rpcstats.RPCTagInfo) context.Context {
+	if h.Next != nil {
+		ctx = h.Next.TagRPC(ctx, info)
+	}
+	if info == nil {
+		return ctx
+	}
+	tagged, err := tag.New(ctx, tag.Upsert(RPCMethodKey, RPCMethodName(info.FullMethodName)))
+	if err != nil {
+		return ctx
+	}
+	return tagged
+}
+
+// HandleRPC implements stats.Handler.
+func (h *RPCStatsHandler) HandleRPC(ctx context.Context, s rpcstats.RPCStats) {
+	if h.Next != nil {
+		h.Next.HandleRPC(ctx, s)
+	}
+	if end, ok := s.(*rpcstats.End); ok {
+		stats.Record(ctx, RPCServerLatency.M(durationMs(end.EndTime.Sub(end.BeginTime))))
+	}
+}
+
+// RPCMethodName turns a full gorpc method name ("/Service/Method") into
+// "Service.Method".
+func RPCMethodName(fullName string) string {
+	return strings.Replace(strings.TrimPrefix(fullName, "/"), "/", ".", 1)
+}
+
+// RecordRPCCall records the latency and result of an RPC request made to
+// another peer.
+func RecordRPCCall(ctx context.Context, svcName, svcMethod string, latency time.Duration, err error) {
+	result := ResultOK
+	if err != nil {
+		result = ResultError
+	}
+	ctx, terr := tag.New(
+		ctx,
+		tag.Upsert(RPCMethodKey, svcName+"."+svcMethod),
+		tag.Upsert(ResultKey, result),
+	)
+	if terr != nil {
+		return
+	}
+	stats.Record(ctx, RPCCallLatency.M(durationMs(latency)))
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,45 @@
+package observations
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	rpcstats "github.com/libp2p/go-libp2p-gorpc/stats"
+
+	"go.opencensus.io/stats/view"
+)
+
+func TestRPCStatsHandler(t *testing.T) {
+	if err := view.Register(RPCServerLatencyView, RPCCallLatencyView); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(RPCServerLatencyView, RPCCallLatencyView)
+
+	h := &RPCStatsHandler{}
+	ctx := h.TagRPC(context.Background(), &rpcstats.RPCTagInfo{FullMethodName: "/Cluster/Pins"})
+	now := time.Now()
+	h.HandleRPC(ctx, &rpcstats.End{BeginTime: now.Add(-time.Second), EndTime: now})
+
+	rows, err := view.RetrieveData(RPCServerLatencyView.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Tags[0].Value != "Cluster.Pins" {
+		t.Fatalf("unexpected rows: %v", rows)
+	}
+	if mean := rows[0].Data.(*view.DistributionData).Mean; mean != 1000 {
+		t.Error("expected a latency of 1000ms:", mean)
+	}
+
+	RecordRPCCall(context.Background(), "Cluster", "StatusLocal", time.Millisecond, nil)
+	RecordRPCCall(context.Background(), "Cluster", "StatusLocal", time.Millisecond, errors.New("failed"))
+	rows, err = view.RetrieveData(RPCCallLatencyView.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Error("expected a row for each result:", rows)
+	}
+}
@@ -79,6 +79,11 @@ func setupMetrics(cfg *MetricsConfig) error {
 	view.RegisterExporter(pe)
 	view.SetReportingPeriod(cfg.ReportingInterval)
 
+	if cfg.PushEndpoint != nil {
+		logger.Infof("pushing %s metrics to %s", cfg.PushFormat, cfg.PushEndpoint)
+		view.RegisterExporter(newPushExporter(cfg))
+	}
+
 	// register the metrics views of interest
 	if err := view.Register(DefaultViews...); err != nil {
 		return err
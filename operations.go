@@ -0,0 +1,218 @@
+package ipfscluster
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+
+	"github.com/google/uuid"
+)
+
+// Finished operations are kept around so that their result can be queried,
+// but only the most recent ones.
+const maxFinishedOperations = 100
+
+// operationTracker keeps track of the long-running operations started in
+// this peer and allows canceling them.
+type operationTracker struct {
+	mu  sync.Mutex
+	ops map[string]*operation
+}
+
+func newOperationTracker() *operationTracker {
+	return &operationTracker{
+		ops: make(map[string]*operation),
+	}
+}
+
+// operation is a tracked long-running action. Its context is canceled when
+// the operation is canceled.
+type operation struct {
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+	op     api.Operation
+}
+
+// start registers a new running operation of the given type and returns
+// it. The context of the operation derives from ctx and should be used for
+// all the work done as part of it. finish() must be called when done.
+func (ot *operationTracker) start(ctx context.Context, typ api.OperationType, total int) *operation {
+	ctx, cancel := context.WithCancel(ctx)
+	op := &operation{
+		ctx:    ctx,
+		cancel: cancel,
+		op: api.Operation{
+			ID:        uuid.New().String(),
+			Type:      typ,
+			Status:    api.OperationRunning,
+			Total:     total,
+			StartedAt: time.Now(),
+		},
+	}
+
+	ot.mu.Lock()
+	ot.ops[op.op.ID] = op
+	ot.prune()
+	ot.mu.Unlock()
+	return op
+}
+
+// restore adds a finished operation, like one from before a restart, so
+// that it is listed.
+func (ot *operationTracker) restore(info *api.Operation) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	op := &operation{
+		ctx:    ctx,
+		cancel: cancel,
+		op:     *info,
+	}
+
+	ot.mu.Lock()
+	ot.ops[info.ID] = op
+	ot.prune()
+	ot.mu.Unlock()
+}
+
+// prune removes the oldest finished operations beyond
+// maxFinishedOperations. It must be called with the lock held.
+func (ot *operationTracker) prune() {
+	var finished []*api.Operation
+	for _, op := range ot.ops {
+		if info := op.info(); info.Status != api.OperationRunning {
+			finished = append(finished, info)
+		}
+	}
+	if len(finished) <= maxFinishedOperations {
+		return
+	}
+
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].FinishedAt.Before(finished[j].FinishedAt)
+	})
+	for _, info := range finished[:len(finished)-maxFinishedOperations] {
+		delete(ot.ops, info.ID)
+	}
+}
+
+// list returns all the known operations, sorted by start time.
+func (ot *operationTracker) list() []*api.Operation {
+	ot.mu.Lock()
+	ops := make([]*api.Operation, 0, len(ot.ops))
+	for _, op := range ot.ops {
+		ops = append(ops, op.info())
+	}
+	ot.mu.Unlock()
+
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i].StartedAt.Before(ops[j].StartedAt)
+	})
+	return ops
+}
+
+func (ot *operationTracker) get(id string) (*api.Operation, error) {
+	ot.mu.Lock()
+	op, ok := ot.ops[id]
+	ot.mu.Unlock()
+	if !ok {
+		return nil, api.ErrOperationNotFound
+	}
+	return op.info(), nil
+}
+
+// cancelOp cancels a running operation. Canceling a finished operation has
+// no effect. It returns the operation information.
+func (ot *operationTracker) cancelOp(id string) (*api.Operation, error) {
+	ot.mu.Lock()
+	op, ok := ot.ops[id]
+	ot.mu.Unlock()
+	if !ok {
+		return nil, api.ErrOperationNotFound
+	}
+
+	op.mu.Lock()
+	if op.op.Status == api.OperationRunning {
+		op.op.Status = api.OperationCanceled
+		op.op.FinishedAt = time.Now()
+		op.cancel()
+	}
+	op.mu.Unlock()
+	return op.info(), nil
+}
+
+// finishOp finishes a running operation which was started on behalf of
+// another component, like an API doing an add, and records the pin it
+// produced.
+func (ot *operationTracker) finishOp(id string, ci cid.Cid, err error) error {
+	ot.mu.Lock()
+	op, ok := ot.ops[id]
+	ot.mu.Unlock()
+	if !ok {
+		return api.ErrOperationNotFound
+	}
+
+	if ci.Defined() {
+		op.setCid(ci)
+	}
+	op.finish(err)
+	return nil
+}
+
+func (op *operation) info() *api.Operation {
+	op.mu.Lock()
+	info := op.op
+	op.mu.Unlock()
+	return &info
+}
+
+// setCid records the pin the operation concerns.
+func (op *operation) setCid(ci cid.Cid) {
+	op.mu.Lock()
+	op.op.Cid = ci
+	op.mu.Unlock()
+}
+
+// setTotal sets the number of work units of the operation, for operations
+// which only know it after starting.
+func (op *operation) setTotal(total int) {
+	op.mu.Lock()
+	op.op.Total = total
+	op.mu.Unlock()
+}
+
+// progress increases the count of work units done.
+func (op *operation) progress(n int) {
+	op.mu.Lock()
+	op.op.Done += n
+	op.mu.Unlock()
+}
+
+// finish marks the operation as done, or as failed when err is not nil.
+// Operations which were canceled keep the canceled status.
+func (op *operation) finish(err error) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	defer op.cancel()
+
+	if op.op.Status != api.OperationRunning {
+		return
+	}
+	op.op.FinishedAt = time.Now()
+	if err != nil {
+		op.op.Status = api.OperationFailed
+		op.op.Error = err.Error()
+		return
+	}
+	op.op.Status = api.OperationDone
+}
+
+// canceled returns true when the operation has been canceled.
+func (op *operation) canceled() bool {
+	return op.ctx.Err() != nil
+}
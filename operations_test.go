@@ -0,0 +1,68 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+)
+
+func TestOperationTracker(t *testing.T) {
+	ot := newOperationTracker()
+
+	op := ot.start(context.Background(), api.OperationRepoGC, 3)
+	op.progress(1)
+	info, err := ot.get(op.op.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Status != api.OperationRunning || info.Done != 1 || info.Total != 3 {
+		t.Error("unexpected operation info:", info)
+	}
+
+	op2 := ot.start(context.Background(), api.OperationRecoverAll, 0)
+	op2.finish(errors.New("boom"))
+	info, _ = ot.get(op2.op.ID)
+	if info.Status != api.OperationFailed || info.Error != "boom" || info.FinishedAt.IsZero() {
+		t.Error("expected a failed operation:", info)
+	}
+
+	info, err = ot.cancelOp(op.op.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Status != api.OperationCanceled || !op.canceled() {
+		t.Error("the operation should be canceled")
+	}
+	op.finish(nil)
+	if info, _ := ot.get(op.op.ID); info.Status != api.OperationCanceled {
+		t.Error("finishing should not change the canceled status")
+	}
+
+	if _, err := ot.cancelOp("abc"); err != api.ErrOperationNotFound {
+		t.Error("expected ErrOperationNotFound")
+	}
+
+	ops := ot.list()
+	if len(ops) != 2 || ops[0].ID != op.op.ID {
+		t.Error("expected two operations sorted by start time")
+	}
+}
+
+func TestOperationTrackerPrune(t *testing.T) {
+	ot := newOperationTracker()
+	running := ot.start(context.Background(), api.OperationAdd, 0)
+	for i := 0; i < maxFinishedOperations+10; i++ {
+		ot.start(context.Background(), api.OperationRepin, 1).finish(nil)
+	}
+	ot.start(context.Background(), api.OperationRepin, 1) // triggers pruning
+
+	ops := ot.list()
+	if len(ops) != maxFinishedOperations+2 {
+		t.Error("unexpected number of operations:", len(ops))
+	}
+	if _, err := ot.get(running.op.ID); err != nil {
+		t.Error("running operations should not be pruned")
+	}
+}
@@ -0,0 +1,110 @@
+package ipfscluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+
+	"go.opencensus.io/trace"
+)
+
+// ResolvePeer returns the ID of the cluster peer addressed by name, which is
+// either a peer ID or the peername of one of the current peers. Peernames
+// are learned from the ping metrics of the peers and remembered until they
+// are removed, so that offline peers can be addressed by them.
+// Peernames are not guaranteed to be unique, so a peername used by several
+// peers is an error and those peers must be addressed by their ID.
+func (c *Cluster) ResolvePeer(ctx context.Context, name string) (peer.ID, error) {
+	_, span := trace.StartSpan(ctx, "cluster/ResolvePeer")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	if pid, err := peer.Decode(name); err == nil {
+		return pid, nil
+	}
+	if name == "" {
+		return "", api.ErrPeerNotFound
+	}
+
+	var matches []peer.ID
+	for pid, pname := range c.knownPeernames(ctx) {
+		if pname == name {
+			matches = append(matches, pid)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("%w: %s", api.ErrPeerNotFound, name)
+	case 1:
+		return matches[0], nil
+	default:
+		ids := make([]string, len(matches))
+		for i, pid := range matches {
+			ids[i] = peer.Encode(pid)
+		}
+		sort.Strings(ids)
+		logger.Warnf("peername %s is used by several peers: %s", name, strings.Join(ids, ", "))
+		return "", fmt.Errorf("%w: %s (%s)", api.ErrAmbiguousPeername, name, strings.Join(ids, ", "))
+	}
+}
+
+// rememberPeername records the peername announced by a peer.
+func (c *Cluster) rememberPeername(pid peer.ID, name string) {
+	if name == "" {
+		return
+	}
+	c.peernamesMux.Lock()
+	defer c.peernamesMux.Unlock()
+	if c.peernames == nil {
+		c.peernames = make(map[peer.ID]string)
+	}
+	c.peernames[pid] = name
+}
+
+// forgetPeername forgets the peername of a removed peer.
+func (c *Cluster) forgetPeername(pid peer.ID) {
+	c.peernamesMux.Lock()
+	defer c.peernamesMux.Unlock()
+	delete(c.peernames, pid)
+}
+
+// knownPeernames returns the peernames of the peers, updated with their
+// latest ping metrics.
+func (c *Cluster) knownPeernames(ctx context.Context) map[peer.ID]string {
+	for _, m := range c.monitor.LatestMetrics(ctx, pingMetricName) {
+		c.rememberPeername(m.Peer, m.Peername)
+	}
+	c.rememberPeername(c.id, c.config.Peername)
+
+	c.peernamesMux.Lock()
+	defer c.peernamesMux.Unlock()
+	names := make(map[peer.ID]string, len(c.peernames))
+	for pid, name := range c.peernames {
+		names[pid] = name
+	}
+	return names
+}
+
+// Peer returns the ID of the cluster peer addressed by name, as resolved by
+// ResolvePeer. When the peer cannot be contacted, the Error field of the ID
+// is set, like in the results of Peers.
+func (c *Cluster) Peer(ctx context.Context, name string) (*api.ID, error) {
+	_, span := trace.StartSpan(ctx, "cluster/Peer")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	pid, err := c.ResolvePeer(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if pid == c.id {
+		return c.ID(ctx), nil
+	}
+	id, _ := c.getIDForPeer(ctx, pid)
+	return id, nil
+}
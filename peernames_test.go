@@ -0,0 +1,71 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+)
+
+func TestClustersResolvePeer(t *testing.T) {
+	ctx := context.Background()
+	clusters, mocks := createClusters(t)
+	defer shutdownClusters(t, clusters, mocks)
+
+	if len(clusters) < 3 {
+		t.Skip("test needs at least 3 clusters")
+	}
+	waitForLeaderAndMetrics(t, clusters)
+
+	pid, err := clusters[0].ResolvePeer(ctx, clusters[1].id.Pretty())
+	if err != nil || pid != clusters[1].id {
+		t.Fatal("expected the peer ID to be resolved to itself:", pid, err)
+	}
+
+	pid, err = clusters[0].ResolvePeer(ctx, clusters[1].config.Peername)
+	if err != nil || pid != clusters[1].id {
+		t.Fatal("expected the peername to be resolved:", pid, err)
+	}
+
+	id, err := clusters[0].Peer(ctx, clusters[1].config.Peername)
+	if err != nil || id.ID != clusters[1].id || id.Peername != clusters[1].config.Peername {
+		t.Fatalf("unexpected peer: %+v %s", id, err)
+	}
+
+	_, err = clusters[0].ResolvePeer(ctx, "nobody")
+	if !errors.Is(err, api.ErrPeerNotFound) {
+		t.Error("expected a not found error:", err)
+	}
+
+	// Peernames are learned from the ping metrics.
+	logPing := func(c *Cluster, name string) {
+		m := &api.Metric{
+			Name:     pingMetricName,
+			Peer:     c.id,
+			Valid:    true,
+			Peername: name,
+		}
+		m.SetTTL(time.Minute)
+		if err := clusters[0].monitor.LogMetric(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logPing(clusters[2], clusters[1].config.Peername)
+	_, err = clusters[0].ResolvePeer(ctx, clusters[1].config.Peername)
+	if !errors.Is(err, api.ErrAmbiguousPeername) {
+		t.Error("expected an error for a peername used by two peers:", err)
+	}
+	logPing(clusters[2], clusters[2].config.Peername)
+
+	// Offline peers are still resolved once their metrics expire.
+	if err := clusters[1].Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * clusters[1].config.MonitorPingInterval)
+	pid, err = clusters[0].ResolvePeer(ctx, clusters[1].config.Peername)
+	if err != nil || pid != clusters[1].id {
+		t.Fatal("expected the peername of an offline peer to be resolved:", pid, err)
+	}
+}
@@ -0,0 +1,136 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/rpcutil"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	"go.opencensus.io/trace"
+)
+
+// pinAckPollInterval is how often the peers are asked whether they have a
+// pin submitted with AckReplicated.
+var pinAckPollInterval = 500 * time.Millisecond
+
+var errPinAckTimeout = errors.New("the pin was committed but not replicated to enough peers in time")
+
+// pinAck is the acknowledgment requested when submitting a pin.
+type pinAck struct {
+	level api.AckLevel
+	peers int
+}
+
+// takePinAck removes the acknowledgment options from a pin, as they are not
+// part of the shared state, and returns them.
+func takePinAck(pin *api.Pin) (pinAck, error) {
+	ack := pinAck{
+		level: pin.Ack,
+		peers: pin.AckPeers,
+	}
+	pin.Ack = api.AckConsensus
+	pin.AckPeers = 0
+
+	if ack.level == api.AckReplicated && ack.peers <= 0 {
+		return ack, errors.New("the replicated acknowledgment level needs ack-peers to be set")
+	}
+	return ack, nil
+}
+
+// logPinAck submits a pin to the consensus layer and returns when the
+// requested acknowledgment level is reached.
+func (c *Cluster) logPinAck(ctx context.Context, pin *api.Pin, ack pinAck) error {
+	switch ack.level {
+	case api.AckReplicated:
+		trusted, err := c.trustedPeers(ctx)
+		if err != nil {
+			return err
+		}
+		if len(trusted) < ack.peers {
+			return fmt.Errorf("cannot acknowledge the pin from %d peers: only %d trusted peers", ack.peers, len(trusted))
+		}
+		if err := c.logPin(ctx, pin); err != nil {
+			return err
+		}
+		return c.waitForReplicas(ctx, pin, ack.peers)
+	default:
+		return c.logPin(ctx, pin)
+	}
+}
+
+// trustedPeers returns the peers in the peerset which are trusted.
+func (c *Cluster) trustedPeers(ctx context.Context) ([]peer.ID, error) {
+	members, err := c.consensus.Peers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var trusted []peer.ID
+	for _, p := range members {
+		if c.consensus.IsTrustedPeer(ctx, p) {
+			trusted = append(trusted, p)
+		}
+	}
+	return trusted, nil
+}
+
+// waitForReplicas blocks until n trusted peers, this one included, have
+// the pin in their shared state, or until cluster.pin_ack_timeout.
+func (c *Cluster) waitForReplicas(ctx context.Context, pin *api.Pin, n int) error {
+	ctx, span := trace.StartSpan(ctx, "cluster/waitForReplicas")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, c.config.PinAckTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(pinAckPollInterval)
+	defer ticker.Stop()
+
+	for {
+		replicas := c.countReplicas(ctx, pin)
+		if replicas >= n {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %d of %d peers have %s", errPinAckTimeout, replicas, n, pin.Cid)
+		case <-ticker.C:
+		}
+	}
+}
+
+// countReplicas returns how many trusted peers have the pin, or a newer
+// version of it, in their shared state.
+func (c *Cluster) countReplicas(ctx context.Context, pin *api.Pin) int {
+	trusted, err := c.trustedPeers(ctx)
+	if err != nil {
+		logger.Error(err)
+		return 0
+	}
+
+	replies := make([]*api.Pin, len(trusted))
+	ctxs, cancels := rpcutil.CtxsWithTimeout(ctx, len(trusted), pinAckPollInterval*2)
+	defer rpcutil.MultiCancel(cancels)
+
+	errs := c.rpcClient.MultiCall(
+		ctxs,
+		trusted,
+		"Cluster",
+		"PinGet",
+		pin.Cid,
+		rpcutil.CopyPinsToIfaces(replies),
+	)
+
+	replicas := 0
+	for i, err := range errs {
+		if err == nil && !replies[i].Timestamp.Before(pin.Timestamp) {
+			replicas++
+		}
+	}
+	return replicas
+}
@@ -0,0 +1,86 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/state"
+	"github.com/ipfs/ipfs-cluster/test"
+)
+
+func TestClusterPinAck(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	t.Run("replicated", func(t *testing.T) {
+		opts := api.PinOptions{Ack: api.AckReplicated, AckPeers: 1}
+		pin, err := cl.Pin(ctx, test.Cid1, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pin.Ack != api.AckConsensus || pin.AckPeers != 0 {
+			t.Error("the acknowledgment options should not be part of the pin")
+		}
+		// No pinDelay(): the pin is in the state when Pin returns.
+		stored, err := cl.PinGet(ctx, test.Cid1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Ack != api.AckConsensus || stored.AckPeers != 0 {
+			t.Error("the acknowledgment options should not be stored")
+		}
+	})
+
+	t.Run("replicated without enough peers", func(t *testing.T) {
+		opts := api.PinOptions{Ack: api.AckReplicated, AckPeers: 2}
+		if _, err := cl.Pin(ctx, test.Cid2, opts); err == nil {
+			t.Fatal("expected an error with more ack peers than trusted peers")
+		}
+		pinDelay()
+		if _, err := cl.PinGet(ctx, test.Cid2); !errors.Is(err, state.ErrNotFound) {
+			t.Error("the pin should not have been committed")
+		}
+
+		opts.AckPeers = 0
+		if _, err := cl.Pin(ctx, test.Cid2, opts); err == nil {
+			t.Error("expected an error without ack peers")
+		}
+	})
+
+	t.Run("local", func(t *testing.T) {
+		_, err := cl.Pin(ctx, test.Cid3, api.PinOptions{Ack: api.AckLocal})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// No pinDelay(): the pin is in the local state when Pin
+		// returns.
+		if _, err := cl.PinGet(ctx, test.Cid3); err != nil {
+			t.Error("the pin should have been committed:", err)
+		}
+	})
+}
+
+func TestClustersPinAckReplicated(t *testing.T) {
+	ctx := context.Background()
+	clusters, mock := createClusters(t)
+	defer shutdownClusters(t, clusters, mock)
+
+	ttlDelay()
+
+	opts := api.PinOptions{Ack: api.AckReplicated, AckPeers: nClusters}
+	_, err := clusters[0].Pin(ctx, test.Cid1, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every peer has the pin as soon as Pin returns.
+	for _, c := range clusters {
+		if _, err := c.PinGet(ctx, test.Cid1); err != nil {
+			t.Errorf("%s does not have the pin: %s", c.id, err)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package ipfscluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/state"
+
+	cid "github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+
+	"go.opencensus.io/trace"
+)
+
+// Pin templates are kept in the shared state, so that all peers see the same
+// templates. Each template is stored as a MetaType pin, which trackers never
+// pin, with an identity CID derived from the template name and the template
+// itself encoded under the PinTemplateMetaKey metadata key. These pins are
+// left out of the pinset listings.
+const (
+	// PinTemplateMetaKey is the metadata key carrying the pin template
+	// stored in a pin.
+	PinTemplateMetaKey = "pin-template"
+
+	pinTemplateCidPrefix = "/pintemplates/"
+)
+
+var pinTemplateNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// pinTemplateCid returns the CID of the pin storing the template with the
+// given name.
+func pinTemplateCid(name string) (cid.Cid, error) {
+	hash, err := mh.Sum([]byte(pinTemplateCidPrefix+name), mh.IDENTITY, -1)
+	if err != nil {
+		return cid.Undef, err
+	}
+	return cid.NewCidV1(cid.Raw, hash), nil
+}
+
+// isPinTemplate returns true when the pin stores a pin template.
+func isPinTemplate(pin *api.Pin) bool {
+	if pin.Type != api.MetaType {
+		return false
+	}
+	_, ok := pin.Metadata[PinTemplateMetaKey]
+	return ok
+}
+
+// withoutPinTemplates removes the pins storing pin templates from the given
+// list.
+func withoutPinTemplates(pins []*api.Pin) []*api.Pin {
+	filtered := pins[:0]
+	for _, p := range pins {
+		if !isPinTemplate(p) {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+func decodePinTemplate(pin *api.Pin) (*api.PinTemplate, error) {
+	var tmpl api.PinTemplate
+	if err := json.Unmarshal([]byte(pin.Metadata[PinTemplateMetaKey]), &tmpl); err != nil {
+		return nil, fmt.Errorf("error decoding pin template %s: %w", pin.Name, err)
+	}
+	return &tmpl, nil
+}
+
+func validatePinTemplate(tmpl *api.PinTemplate) error {
+	if !pinTemplateNameRegexp.MatchString(tmpl.Name) {
+		return fmt.Errorf("invalid pin template name %q: only letters, digits, '.', '-' and '_' are allowed", tmpl.Name)
+	}
+	if tmpl.ExpireIn < 0 {
+		return errors.New("pin template expire_in cannot be negative")
+	}
+
+	// Zero replication factors mean the cluster defaults.
+	rplMin := tmpl.Options.ReplicationFactorMin
+	rplMax := tmpl.Options.ReplicationFactorMax
+	if rplMin == 0 && rplMax == 0 {
+		return nil
+	}
+	return isReplicationFactorValid(rplMin, rplMax)
+}
+
+// PinTemplateSet stores a pin template, replacing any existing template with
+// the same name.
+func (c *Cluster) PinTemplateSet(ctx context.Context, tmpl *api.PinTemplate) error {
+	_, span := trace.StartSpan(ctx, "cluster/PinTemplateSet")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	if c.config.FollowerMode {
+		return errFollowerMode
+	}
+
+	if err := validatePinTemplate(tmpl); err != nil {
+		return err
+	}
+
+	stored := *tmpl
+	stored.Options = tmpl.PinOptions(time.Time{})
+	b, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	ci, err := pinTemplateCid(tmpl.Name)
+	if err != nil {
+		return err
+	}
+	pin := api.PinCid(ci)
+	pin.Type = api.MetaType
+	pin.Name = pinTemplateCidPrefix + tmpl.Name
+	pin.Metadata = map[string]string{PinTemplateMetaKey: string(b)}
+	return c.consensus.LogPin(ctx, pin)
+}
+
+// pinTemplatePin returns the pin storing the template with the given name.
+func (c *Cluster) pinTemplatePin(ctx context.Context, name string) (*api.Pin, error) {
+	if !pinTemplateNameRegexp.MatchString(name) {
+		return nil, api.ErrPinTemplateNotFound
+	}
+	ci, err := pinTemplateCid(name)
+	if err != nil {
+		return nil, err
+	}
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pin, err := cState.Get(ctx, ci)
+	if err == state.ErrNotFound {
+		return nil, api.ErrPinTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !isPinTemplate(pin) {
+		return nil, api.ErrPinTemplateNotFound
+	}
+	return pin, nil
+}
+
+// PinTemplate returns the pin template with the given name.
+func (c *Cluster) PinTemplate(ctx context.Context, name string) (*api.PinTemplate, error) {
+	_, span := trace.StartSpan(ctx, "cluster/PinTemplate")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	pin, err := c.pinTemplatePin(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return decodePinTemplate(pin)
+}
+
+// PinTemplates returns all the pin templates, sorted by name.
+func (c *Cluster) PinTemplates(ctx context.Context) ([]*api.PinTemplate, error) {
+	_, span := trace.StartSpan(ctx, "cluster/PinTemplates")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pins []*api.Pin
+	if il, ok := cState.(state.IndexedLister); ok {
+		pins, err = il.ListByType(ctx, api.MetaType)
+	} else {
+		pins, err = cState.List(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tmpls := make([]*api.PinTemplate, 0)
+	for _, pin := range pins {
+		if !isPinTemplate(pin) {
+			continue
+		}
+		tmpl, err := decodePinTemplate(pin)
+		if err != nil {
+			logger.Error(err)
+			continue
+		}
+		tmpls = append(tmpls, tmpl)
+	}
+
+	sort.Slice(tmpls, func(i, j int) bool {
+		return tmpls[i].Name < tmpls[j].Name
+	})
+	return tmpls, nil
+}
+
+// PinTemplateRemove removes the pin template with the given name.
+func (c *Cluster) PinTemplateRemove(ctx context.Context, name string) error {
+	_, span := trace.StartSpan(ctx, "cluster/PinTemplateRemove")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	if c.config.FollowerMode {
+		return errFollowerMode
+	}
+
+	pin, err := c.pinTemplatePin(ctx, name)
+	if err != nil {
+		return err
+	}
+	return c.consensus.LogUnpin(ctx, pin)
+}
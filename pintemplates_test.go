@@ -0,0 +1,91 @@
+package ipfscluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+)
+
+func TestClusterPinTemplates(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	tmpl := &api.PinTemplate{
+		Name: "archive",
+		Options: api.PinOptions{
+			Name:                 "ignored",
+			ReplicationFactorMin: 1,
+			ReplicationFactorMax: 2,
+			Metadata:             map[string]string{"team": "a"},
+		},
+		ExpireIn: time.Hour,
+	}
+	if err := cl.PinTemplateSet(ctx, tmpl); err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.PinTemplateSet(ctx, &api.PinTemplate{Name: "backup"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := cl.PinTemplate(ctx, "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Options.Name != "" || got.ExpireIn != time.Hour || got.Options.Metadata["team"] != "a" {
+		t.Errorf("unexpected template: %+v", got)
+	}
+
+	tmpls, err := cl.PinTemplates(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tmpls) != 2 || tmpls[0].Name != "archive" || tmpls[1].Name != "backup" {
+		t.Error("expected two templates sorted by name")
+	}
+
+	// Templates are kept in the shared state, out of the pinset listings.
+	ci, err := pinTemplateCid("archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cl.PinGet(ctx, ci); err != nil {
+		t.Error("expected the template in the shared state:", err)
+	}
+	pins, err := cl.Pins(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 0 {
+		t.Error("templates should not be listed as pins:", pins)
+	}
+	if _, err := cl.Pin(ctx, test.Cid1, api.PinOptions{Metadata: map[string]string{PinTemplateMetaKey: "{}"}}); err == nil {
+		t.Error("pins should not carry the pin template metadata key")
+	}
+
+	if err := cl.PinTemplateRemove(ctx, "archive"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cl.PinTemplate(ctx, "archive"); err != api.ErrPinTemplateNotFound {
+		t.Error("expected the template to be removed:", err)
+	}
+	if err := cl.PinTemplateRemove(ctx, "archive"); err != api.ErrPinTemplateNotFound {
+		t.Error("expected not found removing it again:", err)
+	}
+
+	invalid := []*api.PinTemplate{
+		{Name: "a/b"},
+		{Name: ""},
+		{Name: "neg", ExpireIn: -time.Second},
+		{Name: "rpl", Options: api.PinOptions{ReplicationFactorMin: 3, ReplicationFactorMax: 2}},
+	}
+	for _, tmpl := range invalid {
+		if err := cl.PinTemplateSet(ctx, tmpl); err == nil {
+			t.Errorf("expected an error with %+v", tmpl)
+		}
+	}
+}
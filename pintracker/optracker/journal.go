@@ -0,0 +1,161 @@
+package optracker
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	namespace "github.com/ipfs/go-datastore/namespace"
+	query "github.com/ipfs/go-datastore/query"
+)
+
+var journalNamespace = ds.NewKey("/optracker")
+
+// Journal records the pin and unpin operations of an OperationTracker in a
+// datastore as they change, so that they can be restored after a restart.
+type Journal struct {
+	store ds.Datastore
+
+	// current holds the last operation tracked for every Cid. Updates
+	// from operations which have been replaced are not recorded.
+	mu      sync.Mutex
+	current map[cid.Cid]*Operation
+}
+
+// NewJournal returns a Journal which keeps the operations in the given
+// datastore, under its own namespace.
+func NewJournal(store ds.Datastore) *Journal {
+	return &Journal{
+		store:   namespace.Wrap(store, journalNamespace),
+		current: make(map[cid.Cid]*Operation),
+	}
+}
+
+type journalEntry struct {
+	Pin          []byte        `json:"pin"`
+	Type         OperationType `json:"type"`
+	Phase        Phase         `json:"phase"`
+	AttemptCount int           `json:"attempt_count"`
+	PriorityPin  bool          `json:"priority_pin,omitempty"`
+	Error        string        `json:"error,omitempty"`
+	TS           time.Time     `json:"timestamp"`
+}
+
+func journaled(typ OperationType) bool {
+	return typ == OperationPin || typ == OperationUnpin
+}
+
+// track records an operation which replaces any previous one for the same
+// Cid.
+func (j *Journal) track(op *Operation) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !journaled(op.Type()) {
+		if _, ok := j.current[op.Cid()]; ok {
+			delete(j.current, op.Cid())
+			j.delete(op.Cid())
+		}
+		return
+	}
+	j.current[op.Cid()] = op
+	j.put(op)
+}
+
+// update records the changes to an operation if it is still the current
+// one for its Cid.
+func (j *Journal) update(op *Operation) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.current[op.Cid()] != op {
+		return
+	}
+	j.put(op)
+}
+
+// remove forgets the operation if it is still the current one for its Cid.
+func (j *Journal) remove(op *Operation) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.current[op.Cid()] != op {
+		return
+	}
+	delete(j.current, op.Cid())
+	j.delete(op.Cid())
+}
+
+func (j *Journal) put(op *Operation) {
+	pin, err := op.Pin().ProtoMarshal()
+	if err != nil {
+		logger.Errorf("error encoding operation for %s: %s", op.Cid(), err)
+		return
+	}
+
+	op.mu.RLock()
+	entry := journalEntry{
+		Pin:          pin,
+		Type:         op.opType,
+		Phase:        op.phase,
+		AttemptCount: op.attemptCount,
+		PriorityPin:  op.priority,
+		Error:        op.error,
+		TS:           op.ts,
+	}
+	op.mu.RUnlock()
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		logger.Errorf("error encoding operation for %s: %s", op.Cid(), err)
+		return
+	}
+	err = j.store.Put(context.Background(), ds.NewKey(op.Cid().String()), b)
+	if err != nil {
+		logger.Errorf("error recording operation for %s: %s", op.Cid(), err)
+	}
+}
+
+func (j *Journal) delete(c cid.Cid) {
+	err := j.store.Delete(context.Background(), ds.NewKey(c.String()))
+	if err != nil {
+		logger.Errorf("error removing recorded operation for %s: %s", c, err)
+	}
+}
+
+// load returns the recorded operations, created with the given parent
+// context.
+func (j *Journal) load(ctx, parent context.Context) ([]*Operation, error) {
+	results, err := j.store.Query(ctx, query.Query{})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var ops []*Operation
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		var entry journalEntry
+		if err := json.Unmarshal(r.Value, &entry); err != nil {
+			logger.Errorf("error decoding recorded operation %s: %s", r.Key, err)
+			continue
+		}
+		pin := &api.Pin{}
+		if err := pin.ProtoUnmarshal(entry.Pin); err != nil {
+			logger.Errorf("error decoding recorded operation %s: %s", r.Key, err)
+			continue
+		}
+
+		op := NewOperation(parent, pin, entry.Type, entry.Phase)
+		op.attemptCount = entry.AttemptCount
+		op.priority = entry.PriorityPin
+		op.error = entry.Error
+		op.ts = entry.TS
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
@@ -67,6 +67,9 @@ type Operation struct {
 	priority     bool
 	error        string
 	ts           time.Time
+
+	// called after the RW fields change, when set.
+	onChange func(*Operation)
 }
 
 // NewOperation creates a new Operation.
@@ -108,6 +111,12 @@ func (op *Operation) String() string {
 	return b.String()
 }
 
+func (op *Operation) changed() {
+	if op.onChange != nil {
+		op.onChange(op)
+	}
+}
+
 // Cid returns the Cid associated to this operation.
 func (op *Operation) Cid() cid.Cid {
 	var c cid.Cid
@@ -149,6 +158,7 @@ func (op *Operation) SetPhase(ph Phase) {
 		op.ts = time.Now()
 	}
 	op.mu.Unlock()
+	op.changed()
 	span.End()
 }
 
@@ -169,6 +179,7 @@ func (op *Operation) IncAttempt() {
 	op.mu.Lock()
 	op.attemptCount++
 	op.mu.Unlock()
+	op.changed()
 }
 
 // PriorityPin returns true if the pin has been marked as priority pin.
@@ -185,6 +196,7 @@ func (op *Operation) SetPriorityPin(p bool) {
 	op.mu.Lock()
 	op.priority = p
 	op.mu.Unlock()
+	op.changed()
 }
 
 // Error returns any error message attached to the operation.
@@ -207,6 +219,7 @@ func (op *Operation) SetError(err error) {
 		op.ts = time.Now()
 	}
 	op.mu.Unlock()
+	op.changed()
 	span.End()
 }
 
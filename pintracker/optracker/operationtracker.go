@@ -31,6 +31,7 @@ type OperationTracker struct {
 
 	mu         sync.RWMutex
 	operations map[cid.Cid]*Operation
+	journal    *Journal
 }
 
 func (opt *OperationTracker) String() string {
@@ -67,7 +68,7 @@ func NewOperationTracker(ctx context.Context, pid peer.ID, peerName string) *Ope
 // If an operation exists it is of different type, it is
 // cancelled and the new one replaces it in the tracker.
 func (opt *OperationTracker) TrackNewOperation(ctx context.Context, pin *api.Pin, typ OperationType, ph Phase) *Operation {
-	ctx = trace.NewContext(opt.ctx, trace.FromContext(ctx))
+	ctx = api.CopyRequestID(trace.NewContext(opt.ctx, trace.FromContext(ctx)), ctx)
 	ctx, span := trace.StartSpan(ctx, "optracker/TrackNewOperation")
 	defer span.End()
 
@@ -90,9 +91,55 @@ func (opt *OperationTracker) TrackNewOperation(ctx context.Context, pin *api.Pin
 	}
 	logger.Debugf("'%s' on cid '%s' has been created with phase '%s'", typ, pin.Cid, ph)
 	opt.operations[pin.Cid] = op2
+	if opt.journal != nil {
+		op2.onChange = opt.journal.update
+		opt.journal.track(op2)
+	}
 	return op2
 }
 
+// Restore starts recording the operations in the given journal and tracks
+// the ones recorded there before, as they were last recorded. Operations
+// which were queued or in progress are returned, in the queued phase, so
+// that the caller queues them again after checking that the shared state
+// still requires them. Operations in error are kept so that they are still
+// reported.
+func (opt *OperationTracker) Restore(ctx context.Context, j *Journal) ([]*Operation, error) {
+	opt.mu.Lock()
+	defer opt.mu.Unlock()
+	opt.journal = j
+
+	ops, err := j.load(ctx, opt.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []*Operation
+	for _, op := range ops {
+		c := op.Cid()
+		if _, ok := opt.operations[c]; ok {
+			continue // newer than the recorded one
+		}
+		switch op.phase {
+		case PhaseDone:
+			j.delete(c)
+			continue
+		case PhaseInProgress:
+			op.phase = PhaseQueued
+		}
+		op.onChange = j.update
+		opt.operations[c] = op
+		j.track(op)
+		if op.phase == PhaseQueued {
+			pending = append(pending, op)
+		}
+	}
+	if len(ops) > 0 {
+		logger.Infof("restored %d operations, %d to be resumed", len(ops), len(pending))
+	}
+	return pending, nil
+}
+
 // Clean deletes an operation from the tracker if it is the one we are tracking
 // (compares pointers).
 func (opt *OperationTracker) Clean(ctx context.Context, op *Operation) {
@@ -101,6 +148,9 @@ func (opt *OperationTracker) Clean(ctx context.Context, op *Operation) {
 	op2, ok := opt.operations[op.Cid()]
 	if ok && op == op2 { // same pointer
 		delete(opt.operations, op.Cid())
+		if opt.journal != nil {
+			opt.journal.remove(op)
+		}
 	}
 }
 
@@ -222,6 +272,9 @@ func (opt *OperationTracker) CleanAllDone(ctx context.Context) {
 	for _, op := range opt.operations {
 		if op.Phase() == PhaseDone {
 			delete(opt.operations, op.Cid())
+			if opt.journal != nil {
+				opt.journal.remove(op)
+			}
 		}
 	}
 }
@@ -7,6 +7,7 @@ import (
 
 	cid "github.com/ipfs/go-cid"
 	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/datastore/inmem"
 	"github.com/ipfs/ipfs-cluster/test"
 )
 
@@ -257,3 +258,63 @@ func TestOperationTracker_filterOps(t *testing.T) {
 		}
 	})
 }
+
+func TestOperationTracker_Restore(t *testing.T) {
+	ctx := context.Background()
+	store := inmem.New()
+
+	opt := testOperationTracker(t)
+	if ops, err := opt.Restore(ctx, NewJournal(store)); err != nil || len(ops) != 0 {
+		t.Fatal("expected nothing to restore:", ops, err)
+	}
+
+	op1 := opt.TrackNewOperation(ctx, api.PinCid(test.Cid1), OperationPin, PhaseQueued)
+	op1.SetPhase(PhaseInProgress)
+	op1.IncAttempt()
+
+	// Changes to replaced operations are not recorded.
+	op2 := opt.TrackNewOperation(ctx, api.PinCid(test.Cid2), OperationPin, PhaseQueued)
+	opt.TrackNewOperation(ctx, api.PinCid(test.Cid2), OperationUnpin, PhaseQueued)
+	op2.SetPhase(PhaseInProgress)
+
+	op3 := opt.TrackNewOperation(ctx, api.PinCid(test.Cid3), OperationPin, PhaseQueued)
+	op3.SetError(errors.New("pin failed"))
+
+	op4 := opt.TrackNewOperation(ctx, api.PinCid(test.Cid4), OperationPin, PhaseQueued)
+	op4.SetPhase(PhaseDone)
+	opt.Clean(ctx, op4)
+
+	opt.TrackNewOperation(ctx, api.PinCid(test.Cid5), OperationRemote, PhaseInProgress)
+
+	opt2 := testOperationTracker(t)
+	ops, err := opt2.Restore(ctx, NewJournal(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 2 {
+		t.Fatal("expected 2 operations to resume:", ops)
+	}
+	for _, op := range ops {
+		if op.Phase() != PhaseQueued {
+			t.Error("expected resumed operations to be queued")
+		}
+	}
+
+	pi := opt2.Get(ctx, test.Cid1)
+	if pi.Status != api.TrackerStatusPinQueued || pi.AttemptCount != 1 {
+		t.Errorf("unexpected status for Cid1: %+v", pi)
+	}
+	if pi := opt2.Get(ctx, test.Cid2); pi.Status != api.TrackerStatusUnpinQueued {
+		t.Errorf("unexpected status for Cid2: %+v", pi)
+	}
+	pi = opt2.Get(ctx, test.Cid3)
+	if pi.Status != api.TrackerStatusPinError || pi.Error != "pin failed" {
+		t.Errorf("unexpected status for Cid3: %+v", pi)
+	}
+	if _, ok := opt2.GetExists(ctx, test.Cid4); ok {
+		t.Error("cleaned operations should not be restored")
+	}
+	if _, ok := opt2.GetExists(ctx, test.Cid5); ok {
+		t.Error("remote operations should not be restored")
+	}
+}
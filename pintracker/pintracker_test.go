@@ -75,7 +75,7 @@ func testStatelessPinTracker(t testing.TB) *stateless.Tracker {
 
 	cfg := &stateless.Config{}
 	cfg.Default()
-	spt := stateless.New(cfg, test.PeerID1, test.PeerName1, prefilledState)
+	spt := stateless.New(cfg, test.PeerID1, test.PeerName1, prefilledState, nil)
 	spt.SetClient(test.NewMockRPCClient(t))
 	return spt
 }
@@ -158,7 +158,7 @@ func TestPinTracker_Untrack(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := tt.args.tracker.Untrack(context.Background(), tt.args.c); (err != nil) != tt.wantErr {
+			if err := tt.args.tracker.Untrack(context.Background(), api.PinCid(tt.args.c)); (err != nil) != tt.wantErr {
 				t.Errorf("PinTracker.Untrack() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -460,7 +460,7 @@ func TestUntrackTrack(t *testing.T) {
 
 			time.Sleep(200 * time.Millisecond)
 
-			err = tt.args.tracker.Untrack(context.Background(), tt.args.c)
+			err = tt.args.tracker.Untrack(context.Background(), api.PinCid(tt.args.c))
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -511,7 +511,7 @@ func TestTrackUntrackWithCancel(t *testing.T) {
 
 			if pInfo.Status == api.TrackerStatusPinning {
 				go func() {
-					err = tt.args.tracker.Untrack(context.Background(), tt.args.c)
+					err = tt.args.tracker.Untrack(context.Background(), api.PinCid(tt.args.c))
 					if err != nil {
 						t.Error()
 						return
@@ -0,0 +1,144 @@
+package remote
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/config"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+
+	"github.com/kelseyhightower/envconfig"
+)
+
+const configKey = "remote"
+const envConfigKey = "cluster_remote"
+
+// Default values for this Config.
+const (
+	DefaultTimeout = time.Minute
+)
+
+// Config configures the remote pin tracker.
+type Config struct {
+	config.Saver
+
+	// Endpoint is the libp2p multiaddress of the external tracker,
+	// including its peer ID (/p2p/<id>). When unset, the peer uses
+	// the stateless tracker.
+	Endpoint ma.Multiaddr
+
+	// Timeout limits how long every call to the external tracker can
+	// take.
+	Timeout time.Duration
+}
+
+type jsonConfig struct {
+	Endpoint string `json:"endpoint"`
+	Timeout  string `json:"timeout"`
+}
+
+// ConfigKey provides a human-friendly identifier for this type of Config.
+func (cfg *Config) ConfigKey() string {
+	return configKey
+}
+
+// Default sets the fields of this Config to sensible values.
+func (cfg *Config) Default() error {
+	cfg.Endpoint = nil
+	cfg.Timeout = DefaultTimeout
+	return nil
+}
+
+// ApplyEnvVars fills in any Config fields found
+// as environment variables.
+func (cfg *Config) ApplyEnvVars() error {
+	jcfg := cfg.toJSONConfig()
+
+	err := envconfig.Process(envConfigKey, jcfg)
+	if err != nil {
+		return err
+	}
+
+	return cfg.applyJSONConfig(jcfg)
+}
+
+// Enabled returns true when an endpoint is configured.
+func (cfg *Config) Enabled() bool {
+	return cfg.Endpoint != nil
+}
+
+// Validate checks that the fields of this Config have working values,
+// at least in appearance.
+func (cfg *Config) Validate() error {
+	if cfg.Timeout <= 0 {
+		return errors.New("remote.timeout is too low")
+	}
+
+	if cfg.Endpoint != nil {
+		if _, err := peer.AddrInfoFromP2pAddr(cfg.Endpoint); err != nil {
+			return errors.New("remote.endpoint must be a multiaddress ending in /p2p/<peer ID>")
+		}
+	}
+	return nil
+}
+
+// LoadJSON sets the fields of this Config to the values defined by the JSON
+// representation of it, as generated by ToJSON.
+func (cfg *Config) LoadJSON(raw []byte) error {
+	jcfg := &jsonConfig{}
+	err := json.Unmarshal(raw, jcfg)
+	if err != nil {
+		logger.Error("Error unmarshaling remote tracker config")
+		return err
+	}
+
+	cfg.Default()
+
+	return cfg.applyJSONConfig(jcfg)
+}
+
+func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
+	if jcfg.Endpoint != "" {
+		endpoint, err := ma.NewMultiaddr(jcfg.Endpoint)
+		if err != nil {
+			return err
+		}
+		cfg.Endpoint = endpoint
+	} else {
+		cfg.Endpoint = nil
+	}
+
+	err := config.ParseDurations(cfg.ConfigKey(),
+		&config.DurationOpt{Duration: jcfg.Timeout, Dst: &cfg.Timeout, Name: "timeout"},
+	)
+	if err != nil {
+		return err
+	}
+
+	return cfg.Validate()
+}
+
+// ToJSON generates a human-friendly JSON representation of this Config.
+func (cfg *Config) ToJSON() ([]byte, error) {
+	jcfg := cfg.toJSONConfig()
+
+	return config.DefaultJSONMarshal(jcfg)
+}
+
+func (cfg *Config) toJSONConfig() *jsonConfig {
+	jcfg := &jsonConfig{
+		Timeout: cfg.Timeout.String(),
+	}
+	if cfg.Endpoint != nil {
+		jcfg.Endpoint = cfg.Endpoint.String()
+	}
+	return jcfg
+}
+
+// ToDisplayJSON returns JSON config as a string.
+func (cfg *Config) ToDisplayJSON() ([]byte, error) {
+	return config.DisplayJSON(cfg.toJSONConfig())
+}
@@ -0,0 +1,80 @@
+package remote
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+var cfgJSON = []byte(`
+{
+	"endpoint": "/ip4/127.0.0.1/tcp/9099/p2p/QmXZrtE5jQwXNqCJMfHUTQkvhQ4ZAnqMnmzFMJfLewuabc",
+	"timeout": "30s"
+}
+`)
+
+func TestLoadJSON(t *testing.T) {
+	cfg := &Config{}
+	err := cfg.LoadJSON(cfgJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Enabled() || cfg.Timeout != 30*time.Second {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	err = cfg.LoadJSON([]byte(`{"endpoint": "/ip4/127.0.0.1/tcp/9099"}`))
+	if err == nil {
+		t.Error("expected an error without a peer ID")
+	}
+
+	err = cfg.LoadJSON([]byte(`{"endpoint": ""}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Enabled() || cfg.Timeout != DefaultTimeout {
+		t.Errorf("expected a disabled default config: %+v", cfg)
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	cfg := &Config{}
+	cfg.LoadJSON(cfgJSON)
+	newjson, err := cfg.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = &Config{}
+	err = cfg.LoadJSON(newjson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Enabled() {
+		t.Error("the endpoint should be kept")
+	}
+}
+
+func TestDefault(t *testing.T) {
+	cfg := &Config{}
+	cfg.Default()
+	if cfg.Validate() != nil {
+		t.Fatal("error validating")
+	}
+
+	cfg.Timeout = 0
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+}
+
+func TestApplyEnvVars(t *testing.T) {
+	os.Setenv("CLUSTER_REMOTE_TIMEOUT", "40s")
+	defer os.Unsetenv("CLUSTER_REMOTE_TIMEOUT")
+	cfg := &Config{}
+	cfg.Default()
+	cfg.ApplyEnvVars()
+
+	if cfg.Timeout != 40*time.Second {
+		t.Fatal("failed to override timeout with env var")
+	}
+}
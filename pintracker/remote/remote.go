@@ -0,0 +1,222 @@
+// Package remote implements a PinTracker which forwards all calls to a
+// tracker running in an external process, so that tracking strategies can
+// be developed outside of this repository.
+//
+// The external process is a libp2p peer in the cluster's private network
+// (it must use the cluster secret) which serves the "PinTracker" RPC service
+// with the cluster RPC protocol. NewServer sets this up for any
+// implementation of ipfscluster.PinTracker. The external tracker can use the
+// RPC client it is given to call the RPC API of the cluster peer (i.e.
+// IPFSConnector.Pin); endpoints which are not open require the external
+// peer to be trusted by the cluster peer.
+package remote
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	ipfscluster "github.com/ipfs/ipfs-cluster"
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/version"
+
+	cid "github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+	host "github.com/libp2p/go-libp2p-core/host"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	peerstore "github.com/libp2p/go-libp2p-core/peerstore"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+
+	"go.opencensus.io/trace"
+)
+
+var logger = logging.Logger("pintracker")
+
+// Tracker is a PinTracker which forwards all calls to an external tracker.
+type Tracker struct {
+	config   *Config
+	peerID   peer.ID
+	peerName string
+	remote   peer.ID
+
+	rpcClient *rpc.Client
+
+	shutdownMu sync.Mutex
+	shutdown   bool
+}
+
+// New creates a remote tracker for the endpoint in the configuration. The
+// addresses of the endpoint are added to the peerstore of the given host,
+// which must be the cluster peer host.
+func New(cfg *Config, h host.Host, peerName string) (*Tracker, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.Enabled() {
+		return nil, errors.New("remote.endpoint is not set")
+	}
+
+	info, err := peer.AddrInfoFromP2pAddr(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	h.Peerstore().AddAddrs(info.ID, info.Addrs, peerstore.PermanentAddrTTL)
+
+	return &Tracker{
+		config:   cfg,
+		peerID:   h.ID(),
+		peerName: peerName,
+		remote:   info.ID,
+	}, nil
+}
+
+// SetClient makes the Tracker ready to perform RPC requests.
+func (rt *Tracker) SetClient(c *rpc.Client) {
+	rt.rpcClient = c
+}
+
+// Shutdown stops the tracker. The external tracker is not affected.
+func (rt *Tracker) Shutdown(ctx context.Context) error {
+	rt.shutdownMu.Lock()
+	defer rt.shutdownMu.Unlock()
+
+	if rt.shutdown {
+		logger.Debug("already shutdown")
+		return nil
+	}
+
+	logger.Info("stopping remote PinTracker")
+	rt.shutdown = true
+	return nil
+}
+
+func (rt *Tracker) call(ctx context.Context, method string, in, out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, rt.config.Timeout)
+	defer cancel()
+	return rt.rpcClient.CallContext(ctx, rt.remote, "PinTracker", method, in, out)
+}
+
+// errorPinInfo returns the PinInfo for a Cid whose status could not be
+// obtained from the external tracker.
+func (rt *Tracker) errorPinInfo(c cid.Cid, err error) *api.PinInfo {
+	return &api.PinInfo{
+		Cid:  c,
+		Peer: rt.peerID,
+		PinInfoShort: api.PinInfoShort{
+			PeerName: rt.peerName,
+			Status:   api.TrackerStatusClusterError,
+			TS:       time.Now(),
+			Error:    err.Error(),
+		},
+	}
+}
+
+// Track forwards the pin to the external tracker.
+func (rt *Tracker) Track(ctx context.Context, c *api.Pin) error {
+	ctx, span := trace.StartSpan(ctx, "tracker/remote/Track")
+	defer span.End()
+	return rt.call(ctx, "Track", c, &struct{}{})
+}
+
+// Untrack tells the external tracker to stop tracking a pin.
+func (rt *Tracker) Untrack(ctx context.Context, pin *api.Pin) error {
+	ctx, span := trace.StartSpan(ctx, "tracker/remote/Untrack")
+	defer span.End()
+	return rt.call(ctx, "Untrack", pin, &struct{}{})
+}
+
+// StatusAll returns the status of the items tracked by the external
+// tracker. Nothing is returned when it cannot be contacted.
+func (rt *Tracker) StatusAll(ctx context.Context, filter api.TrackerStatus) []*api.PinInfo {
+	ctx, span := trace.StartSpan(ctx, "tracker/remote/StatusAll")
+	defer span.End()
+
+	var pinInfos []*api.PinInfo
+	err := rt.call(ctx, "StatusAll", filter, &pinInfos)
+	if err != nil {
+		logger.Errorf("error getting the status from the remote tracker: %s", err)
+		return nil
+	}
+	return pinInfos
+}
+
+// Status returns the status of a Cid in the external tracker.
+func (rt *Tracker) Status(ctx context.Context, c cid.Cid) *api.PinInfo {
+	ctx, span := trace.StartSpan(ctx, "tracker/remote/Status")
+	defer span.End()
+
+	var pinInfo api.PinInfo
+	err := rt.call(ctx, "Status", c, &pinInfo)
+	if err != nil {
+		return rt.errorPinInfo(c, err)
+	}
+	return &pinInfo
+}
+
+// RecoverAll asks the external tracker to recover all the items in error.
+func (rt *Tracker) RecoverAll(ctx context.Context) ([]*api.PinInfo, error) {
+	ctx, span := trace.StartSpan(ctx, "tracker/remote/RecoverAll")
+	defer span.End()
+
+	var pinInfos []*api.PinInfo
+	err := rt.call(ctx, "RecoverAll", struct{}{}, &pinInfos)
+	return pinInfos, err
+}
+
+// Recover asks the external tracker to recover a Cid.
+func (rt *Tracker) Recover(ctx context.Context, c cid.Cid) (*api.PinInfo, error) {
+	ctx, span := trace.StartSpan(ctx, "tracker/remote/Recover")
+	defer span.End()
+
+	var pinInfo api.PinInfo
+	err := rt.call(ctx, "Recover", c, &pinInfo)
+	if err != nil {
+		return rt.errorPinInfo(c, err), err
+	}
+	return &pinInfo, nil
+}
+
+// Settings returns the runtime settings of the external tracker.
+func (rt *Tracker) Settings(ctx context.Context) *api.TrackerSettings {
+	ctx, span := trace.StartSpan(ctx, "tracker/remote/Settings")
+	defer span.End()
+
+	var settings api.TrackerSettings
+	err := rt.call(ctx, "Settings", struct{}{}, &settings)
+	if err != nil {
+		logger.Errorf("error getting the settings of the remote tracker: %s", err)
+	}
+	return &settings
+}
+
+// SetSettings modifies the runtime settings of the external tracker.
+func (rt *Tracker) SetSettings(ctx context.Context, s *api.TrackerSettings) (*api.TrackerSettings, error) {
+	ctx, span := trace.StartSpan(ctx, "tracker/remote/SetSettings")
+	defer span.End()
+
+	var settings api.TrackerSettings
+	err := rt.call(ctx, "SetSettings", s, &settings)
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// NewServer serves the given tracker to the cluster peer with the given ID
+// from host h, which must use the cluster secret. Requests from other peers
+// are rejected. The tracker is given an RPC client which can call the
+// cluster peer.
+func NewServer(h host.Host, tracker ipfscluster.PinTracker, clusterPeer peer.ID) (*rpc.Server, error) {
+	authF := func(pid peer.ID, svc, method string) bool {
+		return pid == clusterPeer
+	}
+	srv := rpc.NewServer(h, version.RPCProtocol, rpc.WithAuthorizeFunc(authF))
+	err := srv.RegisterName("PinTracker", ipfscluster.NewPinTrackerRPCAPI(tracker))
+	if err != nil {
+		return nil, err
+	}
+	tracker.SetClient(rpc.NewClientWithServer(h, version.RPCProtocol, srv))
+	return srv, nil
+}
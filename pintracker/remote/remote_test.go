@@ -0,0 +1,199 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+	"github.com/ipfs/ipfs-cluster/version"
+
+	cid "github.com/ipfs/go-cid"
+	libp2p "github.com/libp2p/go-libp2p"
+	host "github.com/libp2p/go-libp2p-core/host"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+)
+
+// mockTracker is an in-memory PinTracker, as an external process would
+// provide.
+type mockTracker struct {
+	mu     sync.Mutex
+	pins   map[cid.Cid]*api.Pin
+	client *rpc.Client
+}
+
+func (mt *mockTracker) SetClient(c *rpc.Client)            { mt.client = c }
+func (mt *mockTracker) Shutdown(ctx context.Context) error { return nil }
+
+func (mt *mockTracker) Track(ctx context.Context, pin *api.Pin) error {
+	if pin.Cid.Equals(test.ErrorCid) {
+		return errors.New("cannot track ErrorCid")
+	}
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	mt.pins[pin.Cid] = pin
+	return nil
+}
+
+func (mt *mockTracker) Untrack(ctx context.Context, pin *api.Pin) error {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	delete(mt.pins, pin.Cid)
+	return nil
+}
+
+func (mt *mockTracker) StatusAll(ctx context.Context, filter api.TrackerStatus) []*api.PinInfo {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	var pinInfos []*api.PinInfo
+	for c := range mt.pins {
+		pinInfos = append(pinInfos, mt.status(c))
+	}
+	return pinInfos
+}
+
+func (mt *mockTracker) Status(ctx context.Context, c cid.Cid) *api.PinInfo {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	return mt.status(c)
+}
+
+func (mt *mockTracker) status(c cid.Cid) *api.PinInfo {
+	st := api.TrackerStatusUnpinned
+	if _, ok := mt.pins[c]; ok {
+		st = api.TrackerStatusPinned
+	}
+	return &api.PinInfo{Cid: c, PinInfoShort: api.PinInfoShort{Status: st}}
+}
+
+func (mt *mockTracker) RecoverAll(ctx context.Context) ([]*api.PinInfo, error) {
+	return nil, nil
+}
+
+func (mt *mockTracker) Recover(ctx context.Context, c cid.Cid) (*api.PinInfo, error) {
+	return mt.Status(ctx, c), nil
+}
+
+func (mt *mockTracker) Settings(ctx context.Context) *api.TrackerSettings {
+	return &api.TrackerSettings{ConcurrentPins: 3}
+}
+
+func (mt *mockTracker) SetSettings(ctx context.Context, s *api.TrackerSettings) (*api.TrackerSettings, error) {
+	return s, nil
+}
+
+func newHost(t *testing.T) host.Host {
+	h, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func endpoint(t *testing.T, h host.Host) *Config {
+	addrs, err := peer.AddrInfoToP2pAddrs(&peer.AddrInfo{ID: h.ID(), Addrs: h.Addrs()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{}
+	cfg.Default()
+	cfg.Endpoint = addrs[0]
+	cfg.Timeout = 5 * time.Second
+	return cfg
+}
+
+func testRemoteTracker(t *testing.T) (*Tracker, *mockTracker) {
+	clusterHost := newHost(t)
+	externalHost := newHost(t)
+
+	mt := &mockTracker{pins: make(map[cid.Cid]*api.Pin)}
+	_, err := NewServer(externalHost, mt, clusterHost.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt, err := New(endpoint(t, externalHost), clusterHost, "peer1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt.SetClient(rpc.NewClient(clusterHost, version.RPCProtocol))
+	t.Cleanup(func() { rt.Shutdown(context.Background()) })
+	return rt, mt
+}
+
+func TestTracker(t *testing.T) {
+	ctx := context.Background()
+	rt, mt := testRemoteTracker(t)
+
+	if err := rt.Track(ctx, api.PinCid(test.Cid1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := mt.pins[test.Cid1]; !ok {
+		t.Fatal("the pin should be tracked by the external tracker")
+	}
+	if err := rt.Track(ctx, api.PinCid(test.ErrorCid)); err == nil {
+		t.Error("expected the error of the external tracker")
+	}
+
+	if st := rt.Status(ctx, test.Cid1).Status; st != api.TrackerStatusPinned {
+		t.Error("expected pinned status:", st)
+	}
+	if all := rt.StatusAll(ctx, api.TrackerStatusUndefined); len(all) != 1 {
+		t.Error("expected one item:", all)
+	}
+	if s := rt.Settings(ctx); s.ConcurrentPins != 3 {
+		t.Error("unexpected settings:", s)
+	}
+
+	if err := rt.Untrack(ctx, api.PinCid(test.Cid1)); err != nil {
+		t.Fatal(err)
+	}
+	if st := rt.Status(ctx, test.Cid1).Status; st != api.TrackerStatusUnpinned {
+		t.Error("expected unpinned status:", st)
+	}
+
+	if mt.client == nil {
+		t.Error("the external tracker should have an RPC client")
+	}
+}
+
+func TestTrackerUnreachable(t *testing.T) {
+	ctx := context.Background()
+	clusterHost := newHost(t)
+	externalHost := newHost(t)
+	cfg := endpoint(t, externalHost)
+	externalHost.Close()
+
+	rt, err := New(cfg, clusterHost, "peer1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt.SetClient(rpc.NewClient(clusterHost, version.RPCProtocol))
+
+	pinfo := rt.Status(ctx, test.Cid1)
+	if pinfo.Status != api.TrackerStatusClusterError || pinfo.Error == "" {
+		t.Errorf("expected a cluster error: %+v", pinfo)
+	}
+	if pinfo.Peer != clusterHost.ID() || pinfo.PeerName != "peer1" {
+		t.Error("the error should be reported by the cluster peer")
+	}
+}
+
+func TestServerAuthorization(t *testing.T) {
+	rt, _ := testRemoteTracker(t)
+
+	// Another peer cannot use the external tracker.
+	other := newHost(t)
+	info, _ := peer.AddrInfoFromP2pAddr(rt.config.Endpoint)
+	other.Peerstore().AddAddrs(info.ID, info.Addrs, time.Minute)
+	client := rpc.NewClient(other, version.RPCProtocol)
+	err := client.Call(info.ID, "PinTracker", "Track", api.PinCid(test.Cid1), &struct{}{})
+	if !rpc.IsAuthorizationError(err) {
+		t.Error("expected an authorization error:", err)
+	}
+}
@@ -19,6 +19,7 @@ const (
 	DefaultConcurrentPins        = 10
 	DefaultPriorityPinMaxAge     = 24 * time.Hour
 	DefaultPriorityPinMaxRetries = 5
+	DefaultPinnedSizeInterval    = 5 * time.Minute
 )
 
 // Config allows to initialize a Monitor and customize some parameters.
@@ -29,7 +30,8 @@ type Config struct {
 	MaxPinQueueSize int
 	// ConcurrentPins specifies how many pin requests can be sent to the ipfs
 	// daemon in parallel. If the pinning method is "refs", it might increase
-	// speed. Unpin requests are always processed one by one.
+	// speed. Unpin requests are always processed one by one. It can be
+	// modified at runtime with the pintracker settings API endpoint.
 	ConcurrentPins int
 
 	// PriorityPinMaxAge specifies the maximum age that a pin needs to
@@ -40,13 +42,45 @@ type Config struct {
 	// PriorityPinMaxRetries specifies the maximum amount of retries that
 	// a pin can have before it is moved to a non-prioritary queue.
 	PriorityPinMaxRetries int
+
+	// PinFilter, when set, limits which of the items allocated to this
+	// peer are pinned. The rest are considered remote.
+	PinFilter *PinFilter
+
+	// MaxPinSize is the maximum size in bytes of an item that this peer
+	// accepts to pin. 0 means no limit. Larger items are re-allocated to
+	// other peers when possible.
+	MaxPinSize uint64
+
+	// MaxTotalPinnedSize is the maximum cumulative size in bytes of the
+	// items pinned recursively in IPFS, after which this peer stops
+	// pinning new items. 0 means no limit. Items are re-allocated to other
+	// peers when possible.
+	MaxTotalPinnedSize uint64
+
+	// PinnedSizeInterval specifies how often the total pinned size is
+	// computed from the IPFS pins and recorded as a metric. 0 disables
+	// it, which is only allowed without MaxTotalPinnedSize.
+	PinnedSizeInterval time.Duration
+
+	// PersistOperations enables keeping the pin and unpin operations in
+	// the datastore as they progress. After a restart, queued and
+	// ongoing operations are resumed with their attempt counts, and
+	// errors are still reported, without waiting for the next state
+	// sync.
+	PersistOperations bool
 }
 
 type jsonConfig struct {
-	MaxPinQueueSize       int    `json:"max_pin_queue_size,omitempty"`
-	ConcurrentPins        int    `json:"concurrent_pins"`
-	PriorityPinMaxAge     string `json:"priority_pin_max_age"`
-	PriorityPinMaxRetries int    `json:"priority_pin_max_retries"`
+	MaxPinQueueSize       int        `json:"max_pin_queue_size,omitempty"`
+	ConcurrentPins        int        `json:"concurrent_pins"`
+	PriorityPinMaxAge     string     `json:"priority_pin_max_age"`
+	PriorityPinMaxRetries int        `json:"priority_pin_max_retries"`
+	PinFilter             *PinFilter `json:"pin_filter,omitempty"`
+	MaxPinSize            uint64     `json:"max_pin_size,omitempty"`
+	MaxTotalPinnedSize    uint64     `json:"max_total_pinned_size,omitempty"`
+	PinnedSizeInterval    string     `json:"pinned_size_interval,omitempty"`
+	PersistOperations     bool       `json:"persist_operations,omitempty"`
 }
 
 // ConfigKey provides a human-friendly identifier for this type of Config.
@@ -60,6 +94,11 @@ func (cfg *Config) Default() error {
 	cfg.ConcurrentPins = DefaultConcurrentPins
 	cfg.PriorityPinMaxAge = DefaultPriorityPinMaxAge
 	cfg.PriorityPinMaxRetries = DefaultPriorityPinMaxRetries
+	cfg.PinFilter = nil
+	cfg.MaxPinSize = 0
+	cfg.MaxTotalPinnedSize = 0
+	cfg.PinnedSizeInterval = DefaultPinnedSizeInterval
+	cfg.PersistOperations = false
 	return nil
 }
 
@@ -95,6 +134,14 @@ func (cfg *Config) Validate() error {
 		return errors.New("statelesstracker.priority_pin_max_retries is too low")
 	}
 
+	if cfg.PinnedSizeInterval < 0 {
+		return errors.New("statelesstracker.pinned_size_interval is invalid")
+	}
+
+	if cfg.MaxTotalPinnedSize > 0 && cfg.PinnedSizeInterval == 0 {
+		return errors.New("statelesstracker.max_total_pinned_size requires pinned_size_interval")
+	}
+
 	return nil
 }
 
@@ -122,6 +169,11 @@ func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
 			Dst:      &cfg.PriorityPinMaxAge,
 			Name:     "priority_pin_max_age",
 		},
+		&config.DurationOpt{
+			Duration: jcfg.PinnedSizeInterval,
+			Dst:      &cfg.PinnedSizeInterval,
+			Name:     "pinned_size_interval",
+		},
 	)
 	if err != nil {
 		return err
@@ -129,6 +181,13 @@ func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
 
 	config.SetIfNotDefault(jcfg.PriorityPinMaxRetries, &cfg.PriorityPinMaxRetries)
 
+	if !jcfg.PinFilter.IsEmpty() {
+		cfg.PinFilter = jcfg.PinFilter
+	}
+	cfg.MaxPinSize = jcfg.MaxPinSize
+	cfg.MaxTotalPinnedSize = jcfg.MaxTotalPinnedSize
+	cfg.PersistOperations = jcfg.PersistOperations
+
 	return cfg.Validate()
 }
 
@@ -144,10 +203,17 @@ func (cfg *Config) toJSONConfig() *jsonConfig {
 		ConcurrentPins:        cfg.ConcurrentPins,
 		PriorityPinMaxAge:     cfg.PriorityPinMaxAge.String(),
 		PriorityPinMaxRetries: cfg.PriorityPinMaxRetries,
+		MaxPinSize:            cfg.MaxPinSize,
+		MaxTotalPinnedSize:    cfg.MaxTotalPinnedSize,
+		PinnedSizeInterval:    cfg.PinnedSizeInterval.String(),
+		PersistOperations:     cfg.PersistOperations,
 	}
 	if cfg.MaxPinQueueSize != DefaultMaxPinQueueSize {
 		jCfg.MaxPinQueueSize = cfg.MaxPinQueueSize
 	}
+	if !cfg.PinFilter.IsEmpty() {
+		jCfg.PinFilter = cfg.PinFilter
+	}
 
 	return jCfg
 }
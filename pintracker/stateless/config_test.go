@@ -43,6 +43,68 @@ func TestLoadJSON(t *testing.T) {
 	if cfg.PriorityPinMaxRetries != 2 {
 		t.Error("expected 2 max retries")
 	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.PinFilter = &PinFilter{
+		Metadata: map[string]string{"a": "b"},
+		MaxSize:  1024,
+	}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err != nil {
+		t.Error("did not expect an error")
+	}
+	if cfg.PinFilter == nil || cfg.PinFilter.Metadata["a"] != "b" || cfg.PinFilter.MaxSize != 1024 {
+		t.Error("expected a pin filter")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.MaxPinSize = 1 << 20
+	j.MaxTotalPinnedSize = 1 << 30
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err != nil {
+		t.Error("did not expect an error")
+	}
+	if cfg.MaxPinSize != 1<<20 || cfg.MaxTotalPinnedSize != 1<<30 {
+		t.Error("expected size limits to be set")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.PinnedSizeInterval = "1m"
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err != nil {
+		t.Error("did not expect an error")
+	}
+	if cfg.PinnedSizeInterval != time.Minute {
+		t.Error("expected pinned_size_interval to be set")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.MaxTotalPinnedSize = 1 << 30
+	j.PinnedSizeInterval = "0s"
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected an error with max_total_pinned_size and no pinned_size_interval")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.PersistOperations = true
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err != nil {
+		t.Error("did not expect an error")
+	}
+	if !cfg.PersistOperations {
+		t.Error("expected persist_operations to be set")
+	}
 }
 
 func TestToJSON(t *testing.T) {
@@ -80,6 +142,7 @@ func TestDefault(t *testing.T) {
 func TestApplyEnvVars(t *testing.T) {
 	os.Setenv("CLUSTER_STATELESS_CONCURRENTPINS", "22")
 	os.Setenv("CLUSTER_STATELESS_PRIORITYPINMAXAGE", "72h")
+	os.Setenv("CLUSTER_STATELESS_PINFILTER_NAMEPREFIXES", "docs-,web-")
 	cfg := &Config{}
 	cfg.ApplyEnvVars()
 
@@ -90,4 +153,8 @@ func TestApplyEnvVars(t *testing.T) {
 	if cfg.PriorityPinMaxAge != 3*24*time.Hour {
 		t.Fatal("failed to override priority_pin_max_age with env var")
 	}
+
+	if cfg.PinFilter == nil || len(cfg.PinFilter.NamePrefixes) != 2 {
+		t.Fatal("failed to override pin_filter.name_prefixes with env var")
+	}
 }
@@ -0,0 +1,153 @@
+package stateless
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/observations"
+	"github.com/ipfs/ipfs-cluster/pintracker/optracker"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// defaultOrigin is the origin namespace of pins without one.
+const defaultOrigin = "default"
+
+type queuedOp struct {
+	op       *optracker.Operation
+	queuedAt time.Time
+}
+
+// fairQueue is a queue of pin operations which keeps a FIFO queue for every
+// origin (the namespace of the pin and the user which submitted it) and
+// hands out operations taking one from
+// each origin in turn, so that an origin submitting many pins does not
+// delay the rest until all of its pins are processed. Operations are
+// delivered on the channel returned by C() as workers become free.
+type fairQueue struct {
+	maxSize int
+	out     chan *optracker.Operation
+
+	mu      sync.Mutex
+	size    int
+	queues  map[string][]queuedOp
+	origins []string // origins with queued operations, in turn order
+	notify  chan struct{}
+}
+
+func newFairQueue(ctx context.Context, maxSize int) *fairQueue {
+	q := &fairQueue{
+		maxSize: maxSize,
+		out:     make(chan *optracker.Operation),
+		queues:  make(map[string][]queuedOp),
+		notify:  make(chan struct{}, 1),
+	}
+	go q.dispatch(ctx)
+	return q
+}
+
+func opNamespace(op *optracker.Operation) string {
+	if ns := op.Pin().Namespace; ns != "" {
+		return ns
+	}
+	return defaultOrigin
+}
+
+func opOrigin(op *optracker.Operation) string {
+	origin := opNamespace(op)
+	if user := op.Pin().User; user != "" {
+		origin += "/" + user
+	}
+	return origin
+}
+
+// push queues an operation. It returns false when the queue is full.
+func (q *fairQueue) push(op *optracker.Operation) bool {
+	q.mu.Lock()
+	if q.size >= q.maxSize {
+		q.mu.Unlock()
+		return false
+	}
+	origin := opOrigin(op)
+	if len(q.queues[origin]) == 0 {
+		q.origins = append(q.origins, origin)
+	}
+	q.queues[origin] = append(q.queues[origin], queuedOp{op: op, queuedAt: time.Now()})
+	q.size++
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// pop returns the first operation of the origin whose turn it is.
+func (q *fairQueue) pop() (queuedOp, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.origins) == 0 {
+		return queuedOp{}, false
+	}
+
+	origin := q.origins[0]
+	queue := q.queues[origin]
+	qop := queue[0]
+	queue[0] = queuedOp{}
+	queue = queue[1:]
+	q.origins = q.origins[1:]
+	if len(queue) == 0 {
+		delete(q.queues, origin)
+	} else {
+		q.queues[origin] = queue
+		q.origins = append(q.origins, origin) // back of the line
+	}
+	q.size--
+	return qop, true
+}
+
+// Len returns the number of queued operations.
+func (q *fairQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size
+}
+
+// C returns the channel on which operations are delivered.
+func (q *fairQueue) C() chan *optracker.Operation {
+	return q.out
+}
+
+func (q *fairQueue) dispatch(ctx context.Context) {
+	for {
+		qop, ok := q.pop()
+		if !ok {
+			select {
+			case <-q.notify:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case q.out <- qop.op:
+			recordQueueWait(ctx, opNamespace(qop.op), time.Since(qop.queuedAt))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// recordQueueWait records the time an operation was queued, tagged with its
+// namespace only: users are not bounded in number.
+func recordQueueWait(ctx context.Context, namespace string, wait time.Duration) {
+	ctx, err := tag.New(ctx, tag.Upsert(observations.OriginKey, namespace))
+	if err != nil {
+		return
+	}
+	stats.Record(ctx, observations.PinQueueWait.M(float64(wait)/float64(time.Millisecond)))
+}
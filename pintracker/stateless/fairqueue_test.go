@@ -0,0 +1,83 @@
+package stateless
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/pintracker/optracker"
+	"github.com/ipfs/ipfs-cluster/test"
+)
+
+func TestFairQueue(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	q := newFairQueue(ctx, 4)
+	newOp := func(ns string) *optracker.Operation {
+		pin := api.PinCid(test.Cid1)
+		pin.Namespace = ns
+		return optracker.NewOperation(ctx, pin, optracker.OperationPin, optracker.PhaseQueued)
+	}
+
+	// A bulk submitter queues three operations before an interactive one.
+	for i := 0; i < 3; i++ {
+		if !q.push(newOp("bulk")) {
+			t.Fatal("the queue should not be full")
+		}
+	}
+	if !q.push(newOp("")) {
+		t.Fatal("the queue should not be full")
+	}
+	if q.push(newOp("")) {
+		t.Error("the queue should be full")
+	}
+
+	expected := []string{"bulk", defaultOrigin, "bulk", "bulk"}
+	for i, origin := range expected {
+		select {
+		case op := <-q.C():
+			if got := opOrigin(op); got != origin {
+				t.Errorf("operation %d: expected origin %s, got %s", i, origin, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for an operation")
+		}
+	}
+}
+
+func TestFairQueueUsers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	q := newFairQueue(ctx, 4)
+	newOp := func(user string) *optracker.Operation {
+		pin := api.PinCid(test.Cid1)
+		pin.User = user
+		return optracker.NewOperation(ctx, pin, optracker.OperationPin, optracker.PhaseQueued)
+	}
+
+	// Two users without a namespace take turns too.
+	for i := 0; i < 3; i++ {
+		q.push(newOp("bulk"))
+	}
+	q.push(newOp("alice"))
+
+	expected := []string{
+		defaultOrigin + "/bulk",
+		defaultOrigin + "/alice",
+		defaultOrigin + "/bulk",
+		defaultOrigin + "/bulk",
+	}
+	for i, origin := range expected {
+		select {
+		case op := <-q.C():
+			if got := opOrigin(op); got != origin {
+				t.Errorf("operation %d: expected origin %s, got %s", i, origin, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for an operation")
+		}
+	}
+}
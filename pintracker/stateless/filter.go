@@ -0,0 +1,103 @@
+package stateless
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+	merkledag "github.com/ipfs/go-merkledag"
+)
+
+// blockGetTimeout bounds how long we wait for the root block of a DAG when
+// checking its size.
+const blockGetTimeout = time.Minute
+
+// PinFilter selects which of the items allocated to this peer are actually
+// pinned in IPFS. Items not matching the filter are tracked as remote. It is
+// meant for follower peers of clusters which pin everything everywhere,
+// as otherwise items may end up pinned in less peers than expected.
+//
+// All the conditions that are set must match.
+type PinFilter struct {
+	// Metadata contains key-value pairs that must all be present in the
+	// pin metadata.
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// NamePrefixes, when not empty, requires the pin name to start with
+	// one of them.
+	NamePrefixes []string `json:"name_prefixes,omitempty"`
+
+	// CidPrefixes, when not empty, requires the string representation of
+	// the pin CID to start with one of them.
+	CidPrefixes []string `json:"cid_prefixes,omitempty"`
+
+	// MaxSize, when not 0, is the maximum size (in bytes) of each DAG
+	// pinned. It applies to every item on its own: it is not a limit on
+	// the total size of the pinned items. The size is obtained from the
+	// root block before pinning. Items for which the size cannot be
+	// obtained are pinned.
+	MaxSize uint64 `json:"max_size,omitempty"`
+}
+
+// IsEmpty returns true when the filter has no conditions.
+func (f *PinFilter) IsEmpty() bool {
+	return f == nil || (len(f.Metadata) == 0 &&
+		len(f.NamePrefixes) == 0 &&
+		len(f.CidPrefixes) == 0 &&
+		f.MaxSize == 0)
+}
+
+// Match returns true when the given pin matches the metadata, name and CID
+// conditions. The MaxSize condition is not checked.
+func (f *PinFilter) Match(pin *api.Pin) bool {
+	if f.IsEmpty() {
+		return true
+	}
+
+	for k, v := range f.Metadata {
+		if pin.Metadata[k] != v {
+			return false
+		}
+	}
+
+	if len(f.NamePrefixes) > 0 && !hasAnyPrefix(pin.Name, f.NamePrefixes) {
+		return false
+	}
+
+	if len(f.CidPrefixes) > 0 && !hasAnyPrefix(pin.Cid.String(), f.CidPrefixes) {
+		return false
+	}
+	return true
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// cumulativeSize returns the size of the DAG with the given root block as
+// "ipfs object stat" does. It only supports dag-pb and raw blocks.
+func cumulativeSize(c cid.Cid, data []byte) (uint64, bool) {
+	switch c.Type() {
+	case cid.Raw:
+		return uint64(len(data)), true
+	case cid.DagProtobuf:
+		nd, err := merkledag.DecodeProtobuf(data)
+		if err != nil {
+			return 0, false
+		}
+		size, err := nd.Size()
+		if err != nil {
+			return 0, false
+		}
+		return size, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,181 @@
+package stateless
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/observations"
+
+	cid "github.com/ipfs/go-cid"
+	"go.opencensus.io/stats"
+)
+
+// pinnedSize keeps the sizes of the items pinned recursively in IPFS, as
+// reported by their root blocks, so that the total pinned size can be
+// checked without asking IPFS about every item on every pin. The sizes of
+// items being pinned are reserved until the pin finishes.
+type pinnedSize struct {
+	mu      sync.Mutex
+	known   bool
+	sizes   map[cid.Cid]uint64
+	total   uint64
+	pending map[cid.Cid]uint64
+}
+
+func newPinnedSize() *pinnedSize {
+	return &pinnedSize{
+		sizes:   make(map[cid.Cid]uint64),
+		pending: make(map[cid.Cid]uint64),
+	}
+}
+
+// watchPinnedSize updates the pinned size and records it every
+// PinnedSizeInterval until the tracker is shut down.
+func (spt *Tracker) watchPinnedSize() {
+	defer spt.wg.Done()
+
+	ticker := time.NewTicker(spt.config.PinnedSizeInterval)
+	defer ticker.Stop()
+	for {
+		if err := spt.updatePinnedSize(spt.ctx); err != nil {
+			logger.Errorf("error updating the pinned size: %s", err)
+		}
+
+		select {
+		case <-spt.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// updatePinnedSize lists the recursive pins in IPFS and sums their sizes.
+// Sizes are only obtained for items which were not known already. Items
+// whose size cannot be determined count as 0.
+func (spt *Tracker) updatePinnedSize(ctx context.Context) error {
+	var ipsMap map[string]api.IPFSPinStatus
+	err := spt.rpcClient.CallContext(
+		ctx,
+		"",
+		"IPFSConnector",
+		"PinLs",
+		"recursive",
+		&ipsMap,
+	)
+	if err != nil {
+		return err
+	}
+
+	spt.pinned.mu.Lock()
+	known := spt.pinned.sizes
+	spt.pinned.mu.Unlock()
+
+	sizes := make(map[cid.Cid]uint64, len(ipsMap))
+	var total uint64
+	for cidstr := range ipsMap {
+		c, err := cid.Decode(cidstr)
+		if err != nil {
+			logger.Error(err)
+			continue
+		}
+
+		spt.pinned.mu.Lock()
+		size, ok := known[c]
+		spt.pinned.mu.Unlock()
+		if !ok {
+			size, _ = spt.dagSize(ctx, api.PinCid(c))
+		}
+		sizes[c] = size
+		total += size
+	}
+
+	spt.pinned.mu.Lock()
+	spt.pinned.sizes = sizes
+	spt.pinned.total = total
+	spt.pinned.known = true
+	spt.pinned.mu.Unlock()
+
+	stats.Record(ctx, observations.PinnedSize.M(int64(total)))
+	return nil
+}
+
+// reservePinnedSize returns an error when pinning an item of the given
+// size would go over MaxTotalPinnedSize, counting the items being pinned.
+// Otherwise, it reserves the size until the pin finishes. Items already
+// pinned do not count twice.
+func (spt *Tracker) reservePinnedSize(ctx context.Context, c cid.Cid, size uint64) error {
+	maxTotal := spt.config.MaxTotalPinnedSize
+
+	spt.pinned.mu.Lock()
+	known := spt.pinned.known
+	spt.pinned.mu.Unlock()
+	if !known {
+		if err := spt.updatePinnedSize(ctx); err != nil {
+			logger.Warnf("cannot check the pinned size before pinning %s: %s", c, err)
+			return nil
+		}
+	}
+
+	spt.pinned.mu.Lock()
+	defer spt.pinned.mu.Unlock()
+
+	if _, ok := spt.pinned.sizes[c]; ok {
+		return nil
+	}
+
+	var pending uint64
+	for pc, s := range spt.pinned.pending {
+		if pc != c {
+			pending += s
+		}
+	}
+
+	if spt.pinned.total+pending+size > maxTotal {
+		return fmt.Errorf(
+			"%w (%d + %d + %d > %d bytes)",
+			ErrPinnedSizeExceeded,
+			spt.pinned.total,
+			pending,
+			size,
+			maxTotal,
+		)
+	}
+	spt.pinned.pending[c] = size
+	return nil
+}
+
+// finishPinnedSize releases the reservation for the given item and, when
+// it was pinned, adds its size to the total.
+func (spt *Tracker) finishPinnedSize(c cid.Cid, pinned bool) {
+	spt.pinned.mu.Lock()
+	defer spt.pinned.mu.Unlock()
+
+	size, ok := spt.pinned.pending[c]
+	if !ok {
+		return
+	}
+	delete(spt.pinned.pending, c)
+	if !pinned {
+		return
+	}
+	if _, ok := spt.pinned.sizes[c]; !ok {
+		spt.pinned.sizes[c] = size
+		spt.pinned.total += size
+	}
+}
+
+// removePinnedSize subtracts the size of an unpinned item from the total.
+func (spt *Tracker) removePinnedSize(c cid.Cid) {
+	spt.pinned.mu.Lock()
+	defer spt.pinned.mu.Unlock()
+
+	size, ok := spt.pinned.sizes[c]
+	if !ok {
+		return
+	}
+	delete(spt.pinned.sizes, c)
+	spt.pinned.total -= size
+}
@@ -0,0 +1,189 @@
+package stateless
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/pintracker/optracker"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+// cidTimers keeps at most one timer for every Cid.
+type cidTimers struct {
+	mu      sync.Mutex
+	timers  map[cid.Cid]*time.Timer
+	stopped bool
+}
+
+func newCidTimers() *cidTimers {
+	return &cidTimers{
+		timers: make(map[cid.Cid]*time.Timer),
+	}
+}
+
+// set runs f after d, replacing the timer for the Cid when there is one.
+// When replace is false, an existing timer is kept instead.
+func (ct *cidTimers) set(c cid.Cid, d time.Duration, replace bool, f func()) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	if ct.stopped {
+		return
+	}
+	if old, ok := ct.timers[c]; ok {
+		if !replace {
+			return
+		}
+		old.Stop()
+	}
+
+	var t *time.Timer
+	t = time.AfterFunc(d, func() {
+		ct.mu.Lock()
+		if ct.timers[c] != t {
+			ct.mu.Unlock()
+			return
+		}
+		delete(ct.timers, c)
+		ct.mu.Unlock()
+		f()
+	})
+	ct.timers[c] = t
+}
+
+func (ct *cidTimers) remove(c cid.Cid) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	if t, ok := ct.timers[c]; ok {
+		t.Stop()
+		delete(ct.timers, c)
+	}
+}
+
+func (ct *cidTimers) has(c cid.Cid) bool {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	_, ok := ct.timers[c]
+	return ok
+}
+
+// stop cancels all the timers. No more can be set afterwards.
+func (ct *cidTimers) stop() {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	for c, t := range ct.timers {
+		t.Stop()
+		delete(ct.timers, c)
+	}
+	ct.stopped = true
+}
+
+// setTimers sets the timers to pin the item at the time it is scheduled
+// at and to verify it regularly, as given by its options. When replace is
+// false, existing timers are kept, which is used when they may already
+// be set (i.e. when listing the pinset).
+func (spt *Tracker) setTimers(pin *api.Pin, replace bool) {
+	now := time.Now()
+
+	var untilPinned time.Duration
+	if pin.ScheduledAt(now) {
+		untilPinned = pin.ScheduleAt.Sub(now)
+		spt.scheduled.set(pin.Cid, untilPinned, replace, func() { spt.pinScheduled(pin.Cid) })
+	} else if replace {
+		spt.scheduled.remove(pin.Cid)
+	}
+
+	if pin.VerifyInterval > 0 {
+		spt.verified.set(pin.Cid, untilPinned+pin.VerifyInterval, replace, func() { spt.verify(pin.Cid) })
+	} else if replace {
+		spt.verified.remove(pin.Cid)
+	}
+}
+
+func (spt *Tracker) removeTimers(c cid.Cid) {
+	spt.scheduled.remove(c)
+	spt.verified.remove(c)
+}
+
+// statePin returns the pin for the Cid in the shared state when this peer
+// should pin it.
+func (spt *Tracker) statePin(ctx context.Context, c cid.Cid) (*api.Pin, bool) {
+	st, err := spt.getState(ctx)
+	if err != nil {
+		logger.Error(err)
+		return nil, false
+	}
+	pin, err := st.Get(ctx, c)
+	if err != nil {
+		return nil, false
+	}
+	if pin.Type == api.MetaType || spt.isRemote(pin) {
+		return nil, false
+	}
+	return pin, true
+}
+
+// pinScheduled pins an item once the time it was scheduled at comes.
+func (spt *Tracker) pinScheduled(c cid.Cid) {
+	ctx := spt.ctx
+	pin, ok := spt.statePin(ctx, c)
+	if !ok {
+		return
+	}
+	if pin.ScheduledAt(time.Now()) {
+		// The pin was scheduled again for later.
+		spt.setTimers(pin, false)
+		return
+	}
+	logger.Infof("pinning %s: scheduled at %s", c, pin.ScheduleAt)
+	if err := spt.enqueue(ctx, pin, optracker.OperationPin); err != nil {
+		logger.Errorf("error pinning scheduled item %s: %s", c, err)
+	}
+}
+
+// verify checks that an item with a verify interval is still pinned in
+// IPFS, and pins it again otherwise. The next check is set every time.
+func (spt *Tracker) verify(c cid.Cid) {
+	ctx := spt.ctx
+	pin, ok := spt.statePin(ctx, c)
+	if !ok || pin.VerifyInterval <= 0 {
+		return
+	}
+	defer spt.verified.set(c, pin.VerifyInterval, false, func() { spt.verify(c) })
+
+	if pin.ScheduledAt(time.Now()) {
+		return
+	}
+	// Ongoing and failed operations are handled by recovering them.
+	if _, ok := spt.optracker.GetExists(ctx, c); ok {
+		return
+	}
+
+	var ips api.IPFSPinStatus
+	err := spt.rpcClient.CallContext(
+		ctx,
+		"",
+		"IPFSConnector",
+		"PinLsCid",
+		pin,
+		&ips,
+	)
+	if err != nil {
+		logger.Errorf("error verifying %s: %s", c, err)
+		return
+	}
+	if ips.IsPinned(pin.MaxDepth) {
+		logger.Debugf("verified %s is pinned", c)
+		return
+	}
+
+	logger.Warnf("%s is not pinned in IPFS anymore: pinning it again", c)
+	if err := spt.enqueue(ctx, pin, optracker.OperationPin); err != nil {
+		logger.Errorf("error pinning %s again: %s", c, err)
+	}
+}
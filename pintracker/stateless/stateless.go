@@ -6,6 +6,8 @@ package stateless
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,6 +16,7 @@ import (
 	"github.com/ipfs/ipfs-cluster/state"
 
 	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
 	logging "github.com/ipfs/go-log/v2"
 	peer "github.com/libp2p/go-libp2p-core/peer"
 	rpc "github.com/libp2p/go-libp2p-gorpc"
@@ -27,6 +30,14 @@ var (
 	// ErrFullQueue is the error used when pin or unpin operation channel is full.
 	ErrFullQueue = errors.New("pin/unpin operation queue is full. Try increasing max_pin_queue_size")
 
+	// ErrPinTooLarge is returned when an item is larger than the
+	// max_pin_size limit.
+	ErrPinTooLarge = errors.New("item exceeds max_pin_size")
+
+	// ErrPinnedSizeExceeded is returned when pinning an item would go over
+	// the max_total_pinned_size limit.
+	ErrPinnedSizeExceeded = errors.New("max_total_pinned_size would be exceeded")
+
 	// items with this error should be recovered
 	errUnexpectedlyUnpinned = errors.New("the item should be pinned but it is not")
 )
@@ -49,43 +60,119 @@ type Tracker struct {
 	rpcClient *rpc.Client
 	rpcReady  chan struct{}
 
-	priorityPinCh chan *optracker.Operation
-	pinCh         chan *optracker.Operation
-	unpinCh       chan *optracker.Operation
+	priorityPinQ *fairQueue
+	pinQ         *fairQueue
+	unpinCh      chan *optracker.Operation
+
+	// operations restored from the journal, queued when the RPC client
+	// is set.
+	resumed []*optracker.Operation
+
+	// stop channels for the running pin workers.
+	workersMu  sync.Mutex
+	pinWorkers []chan struct{}
+
+	// items excluded by the PinFilter MaxSize condition.
+	oversizedMu sync.RWMutex
+	oversized   map[cid.Cid]struct{}
+
+	// timers to pin scheduled items and to verify pinned ones.
+	scheduled *cidTimers
+	verified  *cidTimers
+
+	// sizes of the items pinned in IPFS.
+	pinned *pinnedSize
 
 	shutdownMu sync.Mutex
 	shutdown   bool
 	wg         sync.WaitGroup
 }
 
-// New creates a new StatelessPinTracker.
-func New(cfg *Config, pid peer.ID, peerName string, getState func(ctx context.Context) (state.ReadOnly, error)) *Tracker {
+// New creates a new StatelessPinTracker. The datastore is used to persist
+// operations when enabled in the configuration. It can be nil otherwise.
+func New(cfg *Config, pid peer.ID, peerName string, getState func(ctx context.Context) (state.ReadOnly, error), store ds.Datastore) *Tracker {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	spt := &Tracker{
-		config:        cfg,
-		peerID:        pid,
-		peerName:      peerName,
-		ctx:           ctx,
-		cancel:        cancel,
-		getState:      getState,
-		optracker:     optracker.NewOperationTracker(ctx, pid, peerName),
-		rpcReady:      make(chan struct{}, 1),
-		priorityPinCh: make(chan *optracker.Operation, cfg.MaxPinQueueSize),
-		pinCh:         make(chan *optracker.Operation, cfg.MaxPinQueueSize),
-		unpinCh:       make(chan *optracker.Operation, cfg.MaxPinQueueSize),
-	}
-
-	for i := 0; i < spt.config.ConcurrentPins; i++ {
-		go spt.opWorker(spt.pin, spt.priorityPinCh, spt.pinCh)
-	}
-	go spt.opWorker(spt.unpin, spt.unpinCh, nil)
+		config:       cfg,
+		peerID:       pid,
+		peerName:     peerName,
+		ctx:          ctx,
+		cancel:       cancel,
+		getState:     getState,
+		optracker:    optracker.NewOperationTracker(ctx, pid, peerName),
+		rpcReady:     make(chan struct{}, 1),
+		priorityPinQ: newFairQueue(ctx, cfg.MaxPinQueueSize),
+		pinQ:         newFairQueue(ctx, cfg.MaxPinQueueSize),
+		unpinCh:      make(chan *optracker.Operation, cfg.MaxPinQueueSize),
+		oversized:    make(map[cid.Cid]struct{}),
+		scheduled:    newCidTimers(),
+		verified:     newCidTimers(),
+		pinned:       newPinnedSize(),
+	}
+
+	if cfg.PersistOperations {
+		if store == nil {
+			logger.Warn("persist_operations is enabled but there is no datastore")
+		} else {
+			resumed, err := spt.optracker.Restore(ctx, optracker.NewJournal(store))
+			if err != nil {
+				logger.Errorf("error restoring operations: %s", err)
+			}
+			spt.resumed = resumed
+		}
+	}
+
+	spt.setPinWorkers(spt.config.ConcurrentPins)
+	go spt.opWorker(spt.unpin, spt.unpinCh, nil, nil)
 	return spt
 }
 
+// setPinWorkers starts or stops pin workers until there are n of them.
+// Stopped workers finish their current operation first.
+func (spt *Tracker) setPinWorkers(n int) {
+	spt.workersMu.Lock()
+	defer spt.workersMu.Unlock()
+
+	for len(spt.pinWorkers) < n {
+		stop := make(chan struct{})
+		spt.pinWorkers = append(spt.pinWorkers, stop)
+		go spt.opWorker(spt.pin, spt.priorityPinQ.C(), spt.pinQ.C(), stop)
+	}
+	for len(spt.pinWorkers) > n {
+		last := len(spt.pinWorkers) - 1
+		close(spt.pinWorkers[last])
+		spt.pinWorkers = spt.pinWorkers[:last]
+	}
+}
+
+// Settings returns the settings of the tracker which can be modified at
+// runtime.
+func (spt *Tracker) Settings(ctx context.Context) *api.TrackerSettings {
+	spt.workersMu.Lock()
+	defer spt.workersMu.Unlock()
+	return &api.TrackerSettings{
+		ConcurrentPins: len(spt.pinWorkers),
+	}
+}
+
+// SetSettings modifies the runtime settings of the tracker. Unset (zero)
+// values are left unchanged. Changes are not saved to the configuration.
+func (spt *Tracker) SetSettings(ctx context.Context, settings *api.TrackerSettings) (*api.TrackerSettings, error) {
+	if settings.ConcurrentPins < 0 {
+		return nil, errors.New("concurrent_pins cannot be negative")
+	}
+	if n := settings.ConcurrentPins; n > 0 {
+		logger.Infof("setting concurrent_pins to %d", n)
+		spt.setPinWorkers(n)
+	}
+	return spt.Settings(ctx), nil
+}
+
 // receives a pin Function (pin or unpin) and channels.  Used for both pinning
 // and unpinning.
-func (spt *Tracker) opWorker(pinF func(*optracker.Operation) error, prioCh, normalCh chan *optracker.Operation) {
+// Workers exit when the stop channel is closed, which is never for nil.
+func (spt *Tracker) opWorker(pinF func(*optracker.Operation) error, prioCh, normalCh chan *optracker.Operation, stop chan struct{}) {
 
 	var op *optracker.Operation
 
@@ -108,6 +195,8 @@ func (spt *Tracker) opWorker(pinF func(*optracker.Operation) error, prioCh, norm
 			goto APPLY_OP
 		case <-spt.ctx.Done():
 			return
+		case <-stop:
+			return
 		}
 
 		// apply operations that came from some channel
@@ -134,6 +223,7 @@ func applyPinF(pinF func(*optracker.Operation) error, op *optracker.Operation) b
 			// we were cancelled. Move on.
 			return false
 		}
+		api.RequestLogger(op.Context(), logger).Errorf("%s for %s failed: %s", op.Type(), op.Cid(), err)
 		op.SetError(err)
 		op.Cancel()
 		return false
@@ -147,6 +237,25 @@ func (spt *Tracker) pin(op *optracker.Operation) error {
 	ctx, span := trace.StartSpan(op.Context(), "tracker/stateless/pin")
 	defer span.End()
 
+	if spt.exceedsMaxSize(ctx, op.Pin()) {
+		logger.Infof("%s exceeds the pin_filter max_size. Tracking as remote", op.Cid())
+		spt.oversizedMu.Lock()
+		spt.oversized[op.Cid()] = struct{}{}
+		spt.oversizedMu.Unlock()
+		return nil
+	}
+
+	if err := spt.checkDenylist(ctx, op.Cid()); err != nil {
+		api.RequestLogger(ctx, logger).Errorf("not pinning %s: %s", op.Cid(), err)
+		return err
+	}
+
+	if err := spt.checkSizeLimits(ctx, op.Pin()); err != nil {
+		api.RequestLogger(ctx, logger).Errorf("not pinning %s: %s", op.Cid(), err)
+		spt.reallocate(op.Pin())
+		return err
+	}
+
 	logger.Debugf("issuing pin call for %s", op.Cid())
 	err := spt.rpcClient.CallContext(
 		ctx,
@@ -156,6 +265,7 @@ func (spt *Tracker) pin(op *optracker.Operation) error {
 		op.Pin(),
 		&struct{}{},
 	)
+	spt.finishPinnedSize(op.Cid(), err == nil)
 	if err != nil {
 		return err
 	}
@@ -178,6 +288,7 @@ func (spt *Tracker) unpin(op *optracker.Operation) error {
 	if err != nil {
 		return err
 	}
+	spt.removePinnedSize(op.Cid())
 	return nil
 }
 
@@ -192,26 +303,34 @@ func (spt *Tracker) enqueue(ctx context.Context, c *api.Pin, typ optracker.Opera
 		return nil // the operation exists and must be queued already.
 	}
 
-	var ch chan *optracker.Operation
-
-	switch typ {
-	case optracker.OperationPin:
+	if typ == optracker.OperationPin {
 		isPriorityPin := time.Now().Before(c.Timestamp.Add(spt.config.PriorityPinMaxAge)) &&
 			op.AttemptCount() <= spt.config.PriorityPinMaxRetries
 		op.SetPriorityPin(isPriorityPin)
+	}
+	return spt.queue(op)
+}
 
-		if isPriorityPin {
-			ch = spt.priorityPinCh
+// queue puts an operation on the queue for its type, or sets an error on
+// it when the queue is full.
+func (spt *Tracker) queue(op *optracker.Operation) error {
+	queued := false
+	switch op.Type() {
+	case optracker.OperationPin:
+		if op.PriorityPin() {
+			queued = spt.priorityPinQ.push(op)
 		} else {
-			ch = spt.pinCh
+			queued = spt.pinQ.push(op)
 		}
 	case optracker.OperationUnpin:
-		ch = spt.unpinCh
+		select {
+		case spt.unpinCh <- op:
+			queued = true
+		default:
+		}
 	}
 
-	select {
-	case ch <- op:
-	default:
+	if !queued {
 		err := ErrFullQueue
 		op.SetError(err)
 		op.Cancel()
@@ -226,6 +345,58 @@ func (spt *Tracker) enqueue(ctx context.Context, c *api.Pin, typ optracker.Opera
 func (spt *Tracker) SetClient(c *rpc.Client) {
 	spt.rpcClient = c
 	spt.rpcReady <- struct{}{}
+
+	// Resume the operations from before the last shutdown, now that they
+	// can be performed. The state may not be available yet.
+	if len(spt.resumed) > 0 {
+		spt.wg.Add(1)
+		go spt.resume(spt.resumed)
+	}
+	spt.resumed = nil
+
+	if spt.config.PinnedSizeInterval > 0 {
+		spt.wg.Add(1)
+		go spt.watchPinnedSize()
+	}
+}
+
+// resume queues the operations restored from the journal which are still
+// needed according to the shared state: pins which are still allocated to
+// this peer and unpins of items which are no longer. The rest are dropped,
+// as the state changed while the peer was down.
+func (spt *Tracker) resume(ops []*optracker.Operation) {
+	defer spt.wg.Done()
+
+	ctx := spt.ctx
+	st, err := spt.getState(ctx)
+	if err != nil {
+		logger.Errorf("error getting the state to resume operations: %s", err)
+		return
+	}
+
+	dropped := 0
+	for _, op := range ops {
+		pinned := false
+		gpin, err := st.Get(ctx, op.Cid())
+		switch err {
+		case nil:
+			pinned = !spt.isRemote(gpin)
+		case state.ErrNotFound:
+		default:
+			logger.Errorf("error checking %s to resume its operation: %s", op.Cid(), err)
+			continue
+		}
+
+		if pinned != (op.Type() == optracker.OperationPin) {
+			spt.optracker.Clean(ctx, op)
+			dropped++
+			continue
+		}
+		spt.queue(op)
+	}
+	if dropped > 0 {
+		logger.Infof("dropped %d restored operations which the state no longer requires", dropped)
+	}
 }
 
 // Shutdown finishes the services provided by the StatelessPinTracker
@@ -244,6 +415,8 @@ func (spt *Tracker) Shutdown(ctx context.Context) error {
 	}
 
 	logger.Info("stopping StatelessPinTracker")
+	spt.scheduled.stop()
+	spt.verified.stop()
 	spt.cancel()
 	close(spt.rpcReady)
 	spt.wg.Wait()
@@ -269,7 +442,8 @@ func (spt *Tracker) Track(ctx context.Context, c *api.Pin) error {
 	// Trigger unpin whenever something remote is tracked
 	// Note, IPFSConn checks with pin/ls before triggering
 	// pin/rm.
-	if c.IsRemotePin(spt.peerID) {
+	if spt.isRemote(c) {
+		spt.removeTimers(c.Cid)
 		op := spt.optracker.TrackNewOperation(ctx, c, optracker.OperationRemote, optracker.PhaseInProgress)
 		if op == nil {
 			return nil // ongoing unpin
@@ -286,17 +460,27 @@ func (spt *Tracker) Track(ctx context.Context, c *api.Pin) error {
 		return nil
 	}
 
+	// Scheduled pins are pinned by their timer.
+	spt.setTimers(c, true)
+	if c.ScheduledAt(time.Now()) {
+		return nil
+	}
+
 	return spt.enqueue(ctx, c, optracker.OperationPin)
 }
 
 // Untrack tells the StatelessPinTracker to stop managing a Cid.
 // If the Cid is pinned locally, it will be unpinned.
-func (spt *Tracker) Untrack(ctx context.Context, c cid.Cid) error {
+func (spt *Tracker) Untrack(ctx context.Context, pin *api.Pin) error {
 	ctx, span := trace.StartSpan(ctx, "tracker/stateless/Untrack")
 	defer span.End()
 
-	logger.Debugf("untracking %s", c)
-	return spt.enqueue(ctx, api.PinCid(c), optracker.OperationUnpin)
+	logger.Debugf("untracking %s", pin.Cid)
+	spt.oversizedMu.Lock()
+	delete(spt.oversized, pin.Cid)
+	spt.oversizedMu.Unlock()
+	spt.removeTimers(pin.Cid)
+	return spt.enqueue(ctx, pin, optracker.OperationUnpin)
 }
 
 // StatusAll returns information for all Cids pinned to the local IPFS node.
@@ -334,10 +518,103 @@ func (spt *Tracker) Status(ctx context.Context, c cid.Cid) *api.PinInfo {
 	ctx, span := trace.StartSpan(ctx, "tracker/stateless/Status")
 	defer span.End()
 
+	pinInfo, gpin := spt.stateStatus(ctx, c)
+	if gpin == nil {
+		return pinInfo
+	}
+
+	// else attempt to get status from ipfs node
+	var ips api.IPFSPinStatus
+	err := spt.rpcClient.CallContext(
+		ctx,
+		"",
+		"IPFSConnector",
+		"PinLsCid",
+		gpin,
+		&ips,
+	)
+	if err != nil {
+		logger.Error(err)
+		addError(pinInfo, err)
+		return pinInfo
+	}
+	setIPFSStatus(pinInfo, gpin, ips)
+	return pinInfo
+}
+
+// StatusCids returns information for the given Cids, in the same order.
+// Unlike Status, it lists the IPFS pins of each type once for all of them,
+// instead of asking IPFS about each one.
+func (spt *Tracker) StatusCids(ctx context.Context, cids []cid.Cid) []*api.PinInfo {
+	ctx, span := trace.StartSpan(ctx, "tracker/stateless/StatusCids")
+	defer span.End()
+
+	pinfos := make([]*api.PinInfo, len(cids))
+	done := make(map[cid.Cid]*api.PinInfo, len(cids))
+	pending := make(map[*api.PinInfo]*api.Pin)
+	for i, c := range cids {
+		if pi, ok := done[c]; ok {
+			pinfos[i] = pi
+			continue
+		}
+		pi, gpin := spt.stateStatus(ctx, c)
+		if gpin != nil {
+			pending[pi] = gpin
+		}
+		done[c] = pi
+		pinfos[i] = pi
+	}
+	if len(pending) == 0 {
+		return pinfos
+	}
+
+	// Pins are listed by type, as listing indirect pins too means
+	// walking the DAGs of all the recursive pins.
+	listings := make(map[string]map[string]api.IPFSPinStatus)
+	errs := make(map[string]error)
+	for pi, gpin := range pending {
+		typ := "recursive"
+		if gpin.MaxDepth == 0 {
+			typ = "direct"
+		}
+		ipsMap, ok := listings[typ]
+		if !ok && errs[typ] == nil {
+			err := spt.rpcClient.CallContext(
+				ctx,
+				"",
+				"IPFSConnector",
+				"PinLs",
+				typ,
+				&ipsMap,
+			)
+			if err != nil {
+				logger.Error(err)
+				errs[typ] = err
+			} else {
+				listings[typ] = ipsMap
+			}
+		}
+		if err := errs[typ]; err != nil {
+			addError(pi, err)
+			continue
+		}
+		ips, ok := ipsMap[gpin.Cid.String()]
+		if !ok {
+			ips = api.IPFSPinStatusUnpinned
+		}
+		setIPFSStatus(pi, gpin, ips)
+	}
+	return pinfos
+}
+
+// stateStatus returns the status of a Cid according to the operation
+// tracker and the shared state. The pin is returned too when IPFS must be
+// asked for the status.
+func (spt *Tracker) stateStatus(ctx context.Context, c cid.Cid) (*api.PinInfo, *api.Pin) {
 	// check if c has an inflight operation or errorred operation in optracker
 	if oppi, ok := spt.optracker.GetExists(ctx, c); ok {
 		// if it does return the status of the operation
-		return oppi
+		return oppi, nil
 	}
 
 	pinInfo := &api.PinInfo{
@@ -358,18 +635,18 @@ func (spt *Tracker) Status(ctx context.Context, c cid.Cid) *api.PinInfo {
 	if err != nil {
 		logger.Error(err)
 		addError(pinInfo, err)
-		return pinInfo
+		return pinInfo, nil
 	}
 
 	gpin, err = st.Get(ctx, c)
 	if err == state.ErrNotFound {
 		pinInfo.Status = api.TrackerStatusUnpinned
-		return pinInfo
+		return pinInfo, nil
 	}
 	if err != nil {
 		logger.Error(err)
 		addError(pinInfo, err)
-		return pinInfo
+		return pinInfo, nil
 	}
 	// The pin IS in the state.
 	pinInfo.Name = gpin.Name
@@ -378,34 +655,25 @@ func (spt *Tracker) Status(ctx context.Context, c cid.Cid) *api.PinInfo {
 	// check if pin is a meta pin
 	if gpin.Type == api.MetaType {
 		pinInfo.Status = api.TrackerStatusSharded
-		return pinInfo
+		return pinInfo, nil
 	}
 
 	// check if pin is a remote pin
-	if gpin.IsRemotePin(spt.peerID) {
+	if spt.isRemote(gpin) {
 		pinInfo.Status = api.TrackerStatusRemote
-		return pinInfo
-	}
-
-	// else attempt to get status from ipfs node
-	var ips api.IPFSPinStatus
-	err = spt.rpcClient.CallContext(
-		ctx,
-		"",
-		"IPFSConnector",
-		"PinLsCid",
-		gpin,
-		&ips,
-	)
-	if err != nil {
-		logger.Error(err)
-		addError(pinInfo, err)
-		return pinInfo
+		return pinInfo, nil
 	}
+	return pinInfo, gpin
+}
 
+// setIPFSStatus sets the status of a pin which is in the state and
+// allocated here from its IPFS pin status.
+func setIPFSStatus(pinInfo *api.PinInfo, gpin *api.Pin, ips api.IPFSPinStatus) {
 	ipfsStatus := ips.ToTrackerStatus()
-	switch ipfsStatus {
-	case api.TrackerStatusUnpinned:
+	switch {
+	case ipfsStatus == api.TrackerStatusUnpinned && gpin.ScheduledAt(time.Now()):
+		pinInfo.Status = api.TrackerStatusScheduled
+	case ipfsStatus == api.TrackerStatusUnpinned:
 		// The item is in the state but not in IPFS:
 		// PinError. Should be pinned.
 		pinInfo.Status = api.TrackerStatusUnexpectedlyUnpinned
@@ -413,7 +681,6 @@ func (spt *Tracker) Status(ctx context.Context, c cid.Cid) *api.PinInfo {
 	default:
 		pinInfo.Status = ipfsStatus
 	}
-	return pinInfo
 }
 
 // RecoverAll attempts to recover all items tracked by this peer. It returns
@@ -533,7 +800,8 @@ func (spt *Tracker) ipfsStatusAll(ctx context.Context) (map[cid.Cid]*api.PinInfo
 // pins which should be meta or remote and leaving any ipfs pins that aren't
 // in the consensusState out. If incExtra is true, Remote and Sharded pins
 // will be added to the status slice. If a filter is provided, only statuses
-// matching the filter will be returned.
+// matching the filter will be returned. The timers of scheduled and
+// verified pins are set when missing, as they are lost on restarts.
 func (spt *Tracker) localStatus(ctx context.Context, incExtra bool, filter api.TrackerStatus) (map[cid.Cid]*api.PinInfo, error) {
 	ctx, span := trace.StartSpan(ctx, "tracker/stateless/localStatus")
 	defer span.End()
@@ -553,7 +821,8 @@ func (spt *Tracker) localStatus(ctx context.Context, incExtra bool, filter api.T
 	// tracker.
 	if filter.Match(
 		api.TrackerStatusPinned | api.TrackerStatusUnexpectedlyUnpinned |
-			api.TrackerStatusSharded | api.TrackerStatusRemote) {
+			api.TrackerStatusSharded | api.TrackerStatusRemote |
+			api.TrackerStatusScheduled) {
 		statePins, err = st.List(ctx)
 		if err != nil {
 			logger.Error(err)
@@ -571,6 +840,7 @@ func (spt *Tracker) localStatus(ctx context.Context, incExtra bool, filter api.T
 		}
 	}
 
+	now := time.Now()
 	pininfos := make(map[cid.Cid]*api.PinInfo, len(statePins))
 	for _, p := range statePins {
 		ipfsInfo, pinnedInIpfs := localpis[p.Cid]
@@ -594,16 +864,21 @@ func (spt *Tracker) localStatus(ctx context.Context, incExtra bool, filter api.T
 			}
 			pinInfo.Status = api.TrackerStatusSharded
 			pininfos[p.Cid] = &pinInfo
-		case p.IsRemotePin(spt.peerID):
+		case spt.isRemote(p):
 			if !incExtra || !filter.Match(api.TrackerStatusRemote) {
 				continue
 			}
 			pinInfo.Status = api.TrackerStatusRemote
 			pininfos[p.Cid] = &pinInfo
 		case pinnedInIpfs: // always false unless filter matches TrackerStatusPinnned
+			spt.setTimers(p, false)
 			ipfsInfo.Name = p.Name
 			ipfsInfo.TS = p.Timestamp
 			pininfos[p.Cid] = ipfsInfo
+		case p.ScheduledAt(now):
+			spt.setTimers(p, false)
+			pinInfo.Status = api.TrackerStatusScheduled
+			pininfos[p.Cid] = &pinInfo
 		default:
 			// report as UNEXPECTEDLY_UNPINNED for this peer.
 			// this will be overwritten if the operation tracker
@@ -630,6 +905,130 @@ func (spt *Tracker) OpContext(ctx context.Context, c cid.Cid) context.Context {
 	return spt.optracker.OpContext(ctx, c)
 }
 
+// isRemote returns true when the pin is not allocated to this peer or when
+// it has been left out by the PinFilter.
+func (spt *Tracker) isRemote(pin *api.Pin) bool {
+	if pin.IsRemotePin(spt.peerID) {
+		return true
+	}
+	if !spt.config.PinFilter.Match(pin) {
+		return true
+	}
+
+	spt.oversizedMu.RLock()
+	_, ok := spt.oversized[pin.Cid]
+	spt.oversizedMu.RUnlock()
+	return ok
+}
+
+// exceedsMaxSize returns true when PinFilter.MaxSize is set and the
+// cumulative size of the DAG as reported by its root block is larger.
+func (spt *Tracker) exceedsMaxSize(ctx context.Context, pin *api.Pin) bool {
+	if spt.config.PinFilter.IsEmpty() || spt.config.PinFilter.MaxSize == 0 {
+		return false
+	}
+
+	size, ok := spt.dagSize(ctx, pin)
+	return ok && size > spt.config.PinFilter.MaxSize
+}
+
+// checkDenylist asks the local peer whether the given CID is denylisted, so
+// that every peer enforces its own denylist on the pins it receives through
+// the shared state, regardless of the peer that submitted them.
+func (spt *Tracker) checkDenylist(ctx context.Context, c cid.Cid) error {
+	err := spt.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"DenylistCheck",
+		c,
+		&struct{}{},
+	)
+	if err == nil {
+		return nil
+	}
+	if strings.HasPrefix(err.Error(), api.ErrDenylisted.Error()) {
+		return err
+	}
+	logger.Warnf("cannot check the denylist before pinning %s: %s", c, err)
+	return nil
+}
+
+// checkSizeLimits returns an error when pinning the given item would go
+// over the MaxPinSize or MaxTotalPinnedSize limits.
+func (spt *Tracker) checkSizeLimits(ctx context.Context, pin *api.Pin) error {
+	maxPin := spt.config.MaxPinSize
+	maxTotal := spt.config.MaxTotalPinnedSize
+	if maxPin == 0 && maxTotal == 0 {
+		return nil
+	}
+
+	size, ok := spt.dagSize(ctx, pin)
+	if !ok {
+		return nil
+	}
+
+	if maxPin > 0 && size > maxPin {
+		return fmt.Errorf("%w (%d > %d bytes)", ErrPinTooLarge, size, maxPin)
+	}
+
+	if maxTotal == 0 {
+		return nil
+	}
+	return spt.reservePinnedSize(ctx, pin.Cid, size)
+}
+
+// reallocate asks the cluster to allocate the given pin to a different
+// peer. The request is made asynchronously, as it modifies the shared state
+// and will result in new tracking operations for this item.
+func (spt *Tracker) reallocate(pin *api.Pin) {
+	if pin.IsPinEverywhere() {
+		return
+	}
+
+	go func() {
+		err := spt.rpcClient.CallContext(
+			spt.ctx,
+			"",
+			"Cluster",
+			"RepinFromPeer",
+			pin,
+			&struct{}{},
+		)
+		if err != nil {
+			logger.Errorf("error re-allocating %s: %s", pin.Cid, err)
+		}
+	}()
+}
+
+// dagSize obtains the root block of the pin and returns the cumulative size
+// of the DAG. It returns false when it cannot be determined.
+func (spt *Tracker) dagSize(ctx context.Context, pin *api.Pin) (uint64, bool) {
+	ctx, cancel := context.WithTimeout(ctx, blockGetTimeout)
+	defer cancel()
+
+	var data []byte
+	err := spt.rpcClient.CallContext(
+		ctx,
+		"",
+		"IPFSConnector",
+		"BlockGet",
+		pin.Cid,
+		&data,
+	)
+	if err != nil {
+		logger.Warnf("cannot check the size of %s: %s", pin.Cid, err)
+		return 0, false
+	}
+
+	size, ok := cumulativeSize(pin.Cid, data)
+	if !ok {
+		logger.Warnf("cannot check the size of %s: unsupported codec", pin.Cid)
+		return 0, false
+	}
+	return size, true
+}
+
 func addError(pinInfo *api.PinInfo, err error) {
 	pinInfo.Error = err.Error()
 	pinInfo.Status = api.TrackerStatusClusterError
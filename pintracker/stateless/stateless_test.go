@@ -3,16 +3,21 @@ package stateless
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/ipfs/ipfs-cluster/api"
 	"github.com/ipfs/ipfs-cluster/datastore/inmem"
+	"github.com/ipfs/ipfs-cluster/pintracker/optracker"
 	"github.com/ipfs/ipfs-cluster/state"
 	"github.com/ipfs/ipfs-cluster/state/dsstate"
 	"github.com/ipfs/ipfs-cluster/test"
 
 	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-core/peer"
 	rpc "github.com/libp2p/go-libp2p-gorpc"
 )
 
@@ -20,6 +25,7 @@ var (
 	pinCancelCid      = test.Cid3
 	unpinCancelCid    = test.Cid2
 	pinErrCid         = test.ErrorCid
+	denylistedCid     = test.CidResolved
 	errPinCancelCid   = errors.New("should not have received rpc.IPFSPin operation")
 	errUnpinCancelCid = errors.New("should not have received rpc.IPFSUnpin operation")
 	pinOpts           = api.PinOptions{
@@ -64,7 +70,11 @@ func (mock *mockIPFS) Unpin(ctx context.Context, in *api.Pin, out *struct{}) err
 	return nil
 }
 
+// pinLsCalls counts the PinLs requests received by the mock.
+var pinLsCalls int32
+
 func (mock *mockIPFS) PinLs(ctx context.Context, in string, out *map[string]api.IPFSPinStatus) error {
+	atomic.AddInt32(&pinLsCalls, 1)
 	// Must be consistent with PinLsCid
 	m := map[string]api.IPFSPinStatus{
 		test.Cid1.String(): api.IPFSPinStatusRecursive,
@@ -85,6 +95,39 @@ func (mock *mockIPFS) PinLsCid(ctx context.Context, in *api.Pin, out *api.IPFSPi
 	return nil
 }
 
+func (mock *mockIPFS) BlockGet(ctx context.Context, in cid.Cid, out *[]byte) error {
+	switch in {
+	case test.Cid4: // raw block
+		*out = make([]byte, 100)
+		return nil
+	default:
+		return errors.New("block not found")
+	}
+}
+
+func (mock *mockIPFS) RepoStat(ctx context.Context, in struct{}, out *api.IPFSRepoStat) error {
+	*out = api.IPFSRepoStat{RepoSize: 1000, StorageMax: 10000}
+	return nil
+}
+
+// repinned receives the CIDs for which re-allocation is requested.
+var repinned = make(chan cid.Cid, 10)
+
+type mockCluster struct {
+}
+
+func (mock *mockCluster) RepinFromPeer(ctx context.Context, in *api.Pin, out *struct{}) error {
+	repinned <- in.Cid
+	return nil
+}
+
+func (mock *mockCluster) DenylistCheck(ctx context.Context, in cid.Cid, out *struct{}) error {
+	if in.Equals(denylistedCid) {
+		return fmt.Errorf("%w: %s", api.ErrDenylisted, in)
+	}
+	return nil
+}
+
 func mockRPCClient(t testing.TB) *rpc.Client {
 	t.Helper()
 
@@ -95,6 +138,10 @@ func mockRPCClient(t testing.TB) *rpc.Client {
 	if err != nil {
 		t.Fatal(err)
 	}
+	err = s.RegisterName("Cluster", &mockCluster{})
+	if err != nil {
+		t.Fatal(err)
+	}
 	return c
 }
 
@@ -127,7 +174,8 @@ func testStatelessPinTracker(t testing.TB, pins ...*api.Pin) *Tracker {
 	cfg.ConcurrentPins = 1
 	cfg.PriorityPinMaxAge = 10 * time.Second
 	cfg.PriorityPinMaxRetries = 1
-	spt := New(cfg, test.PeerID1, test.PeerName1, getStateFunc(t, pins...))
+	cfg.PinnedSizeInterval = 0
+	spt := New(cfg, test.PeerID1, test.PeerName1, getStateFunc(t, pins...), nil)
 	spt.SetClient(mockRPCClient(t))
 	return spt
 }
@@ -151,6 +199,55 @@ func TestStatelessPinTracker_Shutdown(t *testing.T) {
 	}
 }
 
+func TestSetSettings(t *testing.T) {
+	ctx := context.Background()
+	spt := testStatelessPinTracker(t)
+	defer spt.Shutdown(ctx)
+
+	if n := spt.Settings(ctx).ConcurrentPins; n != 1 {
+		t.Fatal("expected 1 pin worker, got", n)
+	}
+
+	settings, err := spt.SetSettings(ctx, &api.TrackerSettings{ConcurrentPins: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.ConcurrentPins != 4 {
+		t.Error("expected 4 pin workers, got", settings.ConcurrentPins)
+	}
+
+	settings, err = spt.SetSettings(ctx, &api.TrackerSettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.ConcurrentPins != 4 {
+		t.Error("zero values should not change the settings")
+	}
+
+	settings, err = spt.SetSettings(ctx, &api.TrackerSettings{ConcurrentPins: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.ConcurrentPins != 2 {
+		t.Error("expected 2 pin workers, got", settings.ConcurrentPins)
+	}
+
+	if _, err := spt.SetSettings(ctx, &api.TrackerSettings{ConcurrentPins: -1}); err == nil {
+		t.Error("expected an error with negative values")
+	}
+
+	// Pinning still works with the remaining workers.
+	pin := api.PinWithOpts(test.Cid1, pinOpts)
+	spt.getState = getStateFunc(t, pin)
+	if err := spt.Track(ctx, pin); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if st := spt.Status(ctx, test.Cid1).Status; st != api.TrackerStatusPinned {
+		t.Error("expected the item to be pinned, got", st)
+	}
+}
+
 func TestUntrackTrack(t *testing.T) {
 	ctx := context.Background()
 	spt := testStatelessPinTracker(t)
@@ -168,7 +265,7 @@ func TestUntrackTrack(t *testing.T) {
 
 	time.Sleep(time.Second / 2)
 
-	err = spt.Untrack(context.Background(), h1)
+	err = spt.Untrack(context.Background(), api.PinCid(h1))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -198,7 +295,7 @@ func TestTrackUntrackWithCancel(t *testing.T) {
 
 	if pInfo.Status == api.TrackerStatusPinning {
 		go func() {
-			err = spt.Untrack(ctx, slowPinCid)
+			err = spt.Untrack(ctx, api.PinCid(slowPinCid))
 			if err != nil {
 				t.Error(err)
 				return
@@ -253,7 +350,7 @@ func TestTrackUntrackWithNoCancel(t *testing.T) {
 		t.Fatal("fastPin should be tracked")
 	}
 	if fastPInfo.Status == api.TrackerStatusPinQueued {
-		err = spt.Untrack(ctx, fastPinCid)
+		err = spt.Untrack(ctx, api.PinCid(fastPinCid))
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -290,7 +387,7 @@ func TestUntrackTrackWithCancel(t *testing.T) {
 
 	// Untrack should cancel the ongoing request
 	// and unpin right away
-	err = spt.Untrack(ctx, slowPinCid)
+	err = spt.Untrack(ctx, api.PinCid(slowPinCid))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -348,12 +445,12 @@ func TestUntrackTrackWithNoCancel(t *testing.T) {
 
 	time.Sleep(3 * time.Second)
 
-	err = spt.Untrack(ctx, slowPin.Cid)
+	err = spt.Untrack(ctx, api.PinCid(slowPin.Cid))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	err = spt.Untrack(ctx, fastPin.Cid)
+	err = spt.Untrack(ctx, api.PinCid(fastPin.Cid))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -380,6 +477,60 @@ func TestUntrackTrackWithNoCancel(t *testing.T) {
 
 // TestStatusAll checks that StatusAll correctly reports tracked
 // items and mismatches between what's on IPFS and on the state.
+func TestPersistOperations(t *testing.T) {
+	ctx := context.Background()
+	store := inmem.New()
+	pin := api.PinWithOpts(test.Cid1, pinOpts)
+	errPin := api.PinWithOpts(pinErrCid, pinOpts)
+
+	// Operations left by a previous run.
+	opt := optracker.NewOperationTracker(ctx, test.PeerID1, test.PeerName1)
+	if _, err := opt.Restore(ctx, optracker.NewJournal(store)); err != nil {
+		t.Fatal(err)
+	}
+	op := opt.TrackNewOperation(ctx, pin, optracker.OperationPin, optracker.PhaseInProgress)
+	op.IncAttempt()
+	op = opt.TrackNewOperation(ctx, errPin, optracker.OperationPin, optracker.PhaseQueued)
+	op.SetError(errors.New("error pinning"))
+	// Not in the state anymore.
+	opt.TrackNewOperation(ctx, api.PinWithOpts(test.Cid2, pinOpts), optracker.OperationPin, optracker.PhaseQueued)
+
+	cfg := &Config{}
+	cfg.Default()
+	cfg.PersistOperations = true
+	getState := getStateFunc(t, pin, errPin)
+
+	spt := New(cfg, test.PeerID1, test.PeerName1, getState, store)
+	pInfo := spt.Status(ctx, test.Cid1)
+	if pInfo.Status != api.TrackerStatusPinQueued || pInfo.AttemptCount != 1 {
+		t.Errorf("expected the pin to be queued again: %+v", pInfo)
+	}
+	pInfo = spt.Status(ctx, pinErrCid)
+	if pInfo.Status != api.TrackerStatusPinError || pInfo.Error != "error pinning" {
+		t.Errorf("expected the pin error to be kept: %+v", pInfo)
+	}
+
+	spt.SetClient(mockRPCClient(t))
+	time.Sleep(200 * time.Millisecond)
+	if pInfo := spt.Status(ctx, test.Cid1); pInfo.Status != api.TrackerStatusPinned {
+		t.Errorf("expected the pin to be resumed: %+v", pInfo)
+	}
+	if _, ok := spt.optracker.GetExists(ctx, test.Cid2); ok {
+		t.Error("operations for items no longer in the state should be dropped")
+	}
+	spt.Shutdown(ctx)
+
+	spt = New(cfg, test.PeerID1, test.PeerName1, getState, store)
+	spt.SetClient(mockRPCClient(t))
+	defer spt.Shutdown(ctx)
+	if _, ok := spt.optracker.GetExists(ctx, test.Cid1); ok {
+		t.Error("finished operations should not be restored")
+	}
+	if pInfo := spt.Status(ctx, pinErrCid); pInfo.Status != api.TrackerStatusPinError {
+		t.Errorf("expected the pin error to be kept: %+v", pInfo)
+	}
+}
+
 func TestStatusAll(t *testing.T) {
 	ctx := context.Background()
 
@@ -474,6 +625,41 @@ func TestStatus(t *testing.T) {
 	}
 }
 
+func TestStatusCids(t *testing.T) {
+	ctx := context.Background()
+
+	normalPin := api.PinWithOpts(test.Cid1, pinOpts)
+	normalPin2 := api.PinWithOpts(test.Cid4, pinOpts)
+	spt := testStatelessPinTracker(t, normalPin, normalPin2)
+	defer spt.Shutdown(ctx)
+
+	before := atomic.LoadInt32(&pinLsCalls)
+	cids := []cid.Cid{test.Cid1, test.Cid4, test.Cid3, test.Cid1}
+	pinfos := spt.StatusCids(ctx, cids)
+	if n := atomic.LoadInt32(&pinLsCalls) - before; n != 1 {
+		t.Errorf("expected a single PinLs request, got %d", n)
+	}
+	if len(pinfos) != len(cids) {
+		t.Fatalf("expected %d results, got %d", len(cids), len(pinfos))
+	}
+	for i, pi := range pinfos {
+		if !pi.Cid.Equals(cids[i]) {
+			t.Errorf("result %d is for %s instead of %s", i, pi.Cid, cids[i])
+		}
+	}
+	expected := []api.TrackerStatus{
+		api.TrackerStatusPinned,
+		api.TrackerStatusUnexpectedlyUnpinned,
+		api.TrackerStatusUnpinned,
+		api.TrackerStatusPinned,
+	}
+	for i, st := range expected {
+		if pinfos[i].Status != st {
+			t.Errorf("%s: expected %s, got %s", cids[i], st, pinfos[i].Status)
+		}
+	}
+}
+
 // Test
 func TestAttemptCountAndPriority(t *testing.T) {
 	ctx := context.Background()
@@ -522,7 +708,7 @@ func TestAttemptCountAndPriority(t *testing.T) {
 		t.Errorf("errPin should have 3 attempts and not be priority: %+v", st)
 	}
 
-	err = spt.Untrack(ctx, pinErrCid)
+	err = spt.Untrack(ctx, api.PinCid(pinErrCid))
 	time.Sleep(200 * time.Millisecond) // let the pin be applied
 	if err != nil {
 		t.Fatal(err)
@@ -532,7 +718,7 @@ func TestAttemptCountAndPriority(t *testing.T) {
 		t.Errorf("errPin should have 1 attempt count to unpin: %+v", st)
 	}
 
-	err = spt.Untrack(ctx, pinErrCid)
+	err = spt.Untrack(ctx, api.PinCid(pinErrCid))
 	time.Sleep(200 * time.Millisecond) // let the pin be applied
 	if err != nil {
 		t.Fatal(err)
@@ -551,3 +737,309 @@ func BenchmarkTracker_localStatus(b *testing.B) {
 		tracker.localStatus(ctx, true, api.TrackerStatusUndefined)
 	}
 }
+
+func TestPinFilter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("metadata", func(t *testing.T) {
+		matching := api.PinWithOpts(test.Cid1, pinOpts)
+		matching.Metadata = map[string]string{"collection": "small"}
+		notMatching := api.PinWithOpts(test.Cid4, pinOpts)
+
+		spt := testStatelessPinTracker(t, matching, notMatching)
+		defer spt.Shutdown(ctx)
+		spt.config.PinFilter = &PinFilter{
+			Metadata: map[string]string{"collection": "small"},
+		}
+
+		if st := spt.Status(ctx, test.Cid1); st.Status != api.TrackerStatusPinned {
+			t.Error("cid1 should be pinned:", st.Status)
+		}
+		if st := spt.Status(ctx, test.Cid4); st.Status != api.TrackerStatusRemote {
+			t.Error("cid4 should be remote:", st.Status)
+		}
+
+		stAll := spt.StatusAll(ctx, api.TrackerStatusRemote)
+		if len(stAll) != 1 || stAll[0].Cid != test.Cid4 {
+			t.Error("expected cid4 as the only remote item")
+		}
+	})
+
+	t.Run("name and cid prefix", func(t *testing.T) {
+		f := &PinFilter{
+			NamePrefixes: []string{"docs-"},
+			CidPrefixes:  []string{"Qm"},
+		}
+		pin := api.PinWithOpts(test.Cid1, pinOpts)
+		pin.Name = "docs-website"
+		if !f.Match(pin) {
+			t.Error("pin should match")
+		}
+		pin.Name = "website"
+		if f.Match(pin) {
+			t.Error("pin should not match the name prefixes")
+		}
+		pin = api.PinWithOpts(test.Cid4, pinOpts)
+		pin.Name = "docs-website"
+		if f.Match(pin) {
+			t.Error("pin should not match the cid prefixes")
+		}
+	})
+
+	t.Run("max size", func(t *testing.T) {
+		spt := testStatelessPinTracker(t)
+		defer spt.Shutdown(ctx)
+		spt.config.PinFilter = &PinFilter{
+			MaxSize: 10,
+		}
+
+		// Size of Cid4 is 100 bytes.
+		oversized := api.PinWithOpts(test.Cid4, pinOpts)
+		err := spt.Track(ctx, oversized)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Size of Cid5 cannot be obtained, so it is pinned.
+		unknown := api.PinWithOpts(test.Cid5, pinOpts)
+		err = spt.Track(ctx, unknown)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		time.Sleep(200 * time.Millisecond)
+
+		if !spt.isRemote(oversized) {
+			t.Error("cid4 should be remote")
+		}
+		if spt.isRemote(unknown) {
+			t.Error("cid5 should not be remote")
+		}
+
+		err = spt.Untrack(ctx, api.PinCid(test.Cid4))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if spt.isRemote(oversized) {
+			t.Error("cid4 should have been forgotten")
+		}
+	})
+}
+
+func TestSizeLimits(t *testing.T) {
+	ctx := context.Background()
+
+	// Size of Cid4 is 100 bytes and the pinned size is 1000 bytes.
+	testLimit := func(t *testing.T, maxPin, maxTotal uint64, expected error) {
+		spt := testStatelessPinTracker(t)
+		defer spt.Shutdown(ctx)
+		spt.config.MaxPinSize = maxPin
+		spt.config.MaxTotalPinnedSize = maxTotal
+		spt.pinned.known = true
+		spt.pinned.sizes[test.Cid1] = 1000
+		spt.pinned.total = 1000
+
+		pin := api.PinWithOpts(test.Cid4, api.PinOptions{
+			ReplicationFactorMin: 1,
+			ReplicationFactorMax: 1,
+		})
+		pin.Allocations = []peer.ID{test.PeerID1}
+		err := spt.Track(ctx, pin)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		time.Sleep(200 * time.Millisecond)
+
+		st := spt.Status(ctx, test.Cid4)
+		if expected == nil {
+			// the mock IPFS does not report cid4 as pinned.
+			if st.Status == api.TrackerStatusPinError {
+				t.Fatal("cid4 should have been pinned:", st.Error)
+			}
+			return
+		}
+
+		if st.Status != api.TrackerStatusPinError {
+			t.Fatal("cid4 should be in pin_error:", st.Status)
+		}
+		if !strings.Contains(st.Error, expected.Error()) {
+			t.Error("unexpected error:", st.Error)
+		}
+		select {
+		case c := <-repinned:
+			if !c.Equals(test.Cid4) {
+				t.Error("unexpected cid re-allocated:", c)
+			}
+		case <-time.After(time.Second):
+			t.Error("cid4 should have been re-allocated")
+		}
+	}
+
+	t.Run("max pin size", func(t *testing.T) {
+		testLimit(t, 10, 0, ErrPinTooLarge)
+	})
+
+	t.Run("max total pinned size", func(t *testing.T) {
+		testLimit(t, 0, 1050, ErrPinnedSizeExceeded)
+	})
+
+	t.Run("within limits", func(t *testing.T) {
+		testLimit(t, 200, 2000, nil)
+	})
+}
+
+func TestPinnedSize(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("update", func(t *testing.T) {
+		spt := testStatelessPinTracker(t)
+		defer spt.Shutdown(ctx)
+
+		// Cid1 size is known already, Cid2 size cannot be determined
+		// and Cid3 is no longer pinned.
+		spt.pinned.sizes[test.Cid1] = 1000
+		spt.pinned.sizes[test.Cid3] = 500
+		err := spt.updatePinnedSize(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !spt.pinned.known || spt.pinned.total != 1000 {
+			t.Error("expected a pinned size of 1000 bytes:", spt.pinned.total)
+		}
+		if _, ok := spt.pinned.sizes[test.Cid3]; ok {
+			t.Error("cid3 should not count towards the pinned size")
+		}
+	})
+
+	t.Run("reservations", func(t *testing.T) {
+		spt := testStatelessPinTracker(t)
+		defer spt.Shutdown(ctx)
+		spt.config.MaxTotalPinnedSize = 1150
+
+		err := spt.reservePinnedSize(ctx, test.Cid4, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = spt.reservePinnedSize(ctx, test.Cid3, 1100)
+		if !errors.Is(err, ErrPinnedSizeExceeded) {
+			t.Error("expected ErrPinnedSizeExceeded with the reserved size:", err)
+		}
+
+		spt.finishPinnedSize(test.Cid4, true)
+		if spt.pinned.total != 100 || len(spt.pinned.pending) != 0 {
+			t.Error("expected cid4 to count towards the pinned size")
+		}
+		spt.removePinnedSize(test.Cid4)
+		if spt.pinned.total != 0 {
+			t.Error("expected cid4 to be removed from the pinned size")
+		}
+	})
+
+	t.Run("without limits", func(t *testing.T) {
+		cfg := &Config{}
+		cfg.Default()
+		cfg.PinnedSizeInterval = 50 * time.Millisecond
+		spt := New(cfg, test.PeerID1, test.PeerName1, getStateFunc(t), nil)
+		spt.SetClient(mockRPCClient(t))
+		defer spt.Shutdown(ctx)
+
+		time.Sleep(200 * time.Millisecond)
+		spt.pinned.mu.Lock()
+		known := spt.pinned.known
+		spt.pinned.mu.Unlock()
+		if !known {
+			t.Error("the pinned size should be computed without limits")
+		}
+	})
+}
+
+func TestDenylistedPin(t *testing.T) {
+	ctx := context.Background()
+	spt := testStatelessPinTracker(t)
+	defer spt.Shutdown(ctx)
+
+	pin := api.PinWithOpts(denylistedCid, pinOpts)
+	err := spt.Track(ctx, pin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	st := spt.Status(ctx, denylistedCid)
+	if st.Status != api.TrackerStatusPinError {
+		t.Fatal("a denylisted pin should be in pin_error:", st.Status)
+	}
+	if !strings.Contains(st.Error, api.ErrDenylisted.Error()) {
+		t.Error("unexpected error:", st.Error)
+	}
+}
+
+func TestScheduledPin(t *testing.T) {
+	ctx := context.Background()
+
+	opts := pinOpts
+	opts.ScheduleAt = time.Now().Add(2 * time.Second)
+	pin := api.PinWithOpts(test.SlowCid1, opts)
+	spt := testStatelessPinTracker(t, pin)
+	defer spt.Shutdown(ctx)
+
+	err := spt.Track(ctx, pin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if st := spt.Status(ctx, test.SlowCid1); st.Status != api.TrackerStatusScheduled {
+		t.Fatal("the pin should be scheduled:", st.Status)
+	}
+	stAll := spt.StatusAll(ctx, api.TrackerStatusScheduled)
+	if len(stAll) != 1 || stAll[0].Cid != test.SlowCid1 {
+		t.Fatal("expected the scheduled pin in StatusAll:", stAll)
+	}
+	// Scheduled pins are not recovered early.
+	if _, err := spt.Recover(ctx, test.SlowCid1); err != nil {
+		t.Fatal(err)
+	}
+	if st := spt.Status(ctx, test.SlowCid1); st.Status != api.TrackerStatusScheduled {
+		t.Fatal("recovering should not pin a scheduled item:", st.Status)
+	}
+
+	time.Sleep(2500 * time.Millisecond)
+	if st := spt.Status(ctx, test.SlowCid1); st.Status != api.TrackerStatusPinning {
+		t.Error("the pin should be pinning at its scheduled time:", st.Status)
+	}
+
+	spt.Untrack(ctx, api.PinCid(test.SlowCid1))
+	if spt.scheduled.has(test.SlowCid1) || spt.verified.has(test.SlowCid1) {
+		t.Error("untracking should remove the timers")
+	}
+}
+
+func TestVerifyInterval(t *testing.T) {
+	ctx := context.Background()
+
+	opts := pinOpts
+	opts.VerifyInterval = 2 * time.Second
+	// The mock IPFS never reports SlowCid1 as pinned.
+	pin := api.PinWithOpts(test.SlowCid1, opts)
+	spt := testStatelessPinTracker(t, pin)
+	defer spt.Shutdown(ctx)
+
+	err := spt.Track(ctx, pin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	if st := spt.Status(ctx, test.SlowCid1); st.Status != api.TrackerStatusUnexpectedlyUnpinned {
+		t.Fatal("the first pin should be done:", st.Status)
+	}
+
+	time.Sleep(time.Second)
+	if st := spt.Status(ctx, test.SlowCid1); st.Status != api.TrackerStatusPinning {
+		t.Error("the verification should pin the item again:", st.Status)
+	}
+	if !spt.verified.has(test.SlowCid1) {
+		t.Error("the next verification should be set")
+	}
+}
@@ -0,0 +1,85 @@
+package ipfscluster
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+// PinUpdateChainMetaKey is the metadata key used to store the CIDs of the
+// versions replaced by a pin through PinUpdate, newest first, when
+// PinUpdateUnpin is enabled. Only the versions kept and the last one set to
+// expire are recorded.
+const PinUpdateChainMetaKey = "pin_update_chain"
+
+// pinUpdateChain returns the versions recorded in the metadata of a pin.
+func pinUpdateChain(pin *api.Pin) []cid.Cid {
+	v := pin.Metadata[PinUpdateChainMetaKey]
+	if v == "" {
+		return nil
+	}
+
+	var chain []cid.Cid
+	for _, s := range strings.Split(v, ",") {
+		ci, err := cid.Decode(s)
+		if err != nil {
+			continue
+		}
+		chain = append(chain, ci)
+	}
+	return chain
+}
+
+// setPinUpdateChain records that the pin replaces from, which in turn
+// replaced the versions in prevChain.
+func (c *Cluster) setPinUpdateChain(pin *api.Pin, from cid.Cid, prevChain []cid.Cid) *api.Pin {
+	chain := []string{from.String()}
+	for _, ci := range prevChain {
+		if len(chain) > c.config.PinUpdateUnpin.KeepVersions {
+			break
+		}
+		if ci.Equals(pin.Cid) || ci.Equals(from) {
+			continue
+		}
+		chain = append(chain, ci.String())
+	}
+
+	pin = copyWithMetadata(pin)
+	pin.Metadata[PinUpdateChainMetaKey] = strings.Join(chain, ",")
+	return pin
+}
+
+// expireReplacedVersions sets the versions replaced by an updated pin to
+// expire after the grace period, except for the most recent ones which are
+// kept. Versions which already expire earlier are left alone.
+func (c *Cluster) expireReplacedVersions(ctx context.Context, updated *api.Pin) {
+	cfg := c.config.PinUpdateUnpin
+	chain := pinUpdateChain(updated)
+	if len(chain) <= cfg.KeepVersions {
+		return
+	}
+
+	expireAt := time.Now().Add(cfg.GracePeriod)
+	for _, ci := range chain[cfg.KeepVersions:] {
+		old, err := c.PinGet(ctx, ci)
+		if err != nil { // already unpinned
+			continue
+		}
+		if old.Type != api.DataType {
+			continue
+		}
+		if !old.ExpireAt.IsZero() && !old.ExpireAt.After(expireAt) {
+			continue
+		}
+
+		old.ExpireAt = expireAt
+		api.RequestLogger(ctx, logger).Infof("%s replaced by %s: expires at %s", ci, updated.Cid, expireAt)
+		if err := c.logPin(ctx, old); err != nil {
+			logger.Errorf("error setting the expiry of %s: %s", ci, err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package ipfscluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+)
+
+func TestPinUpdateUnpin(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	cl.config.PinUpdateUnpin.Enabled = true
+	cl.config.PinUpdateUnpin.KeepVersions = 1
+
+	_, err := cl.Pin(ctx, test.Cid1, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := cl.PinUpdate(ctx, test.Cid1, test.Cid2, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Metadata[PinUpdateChainMetaKey] != test.Cid1.String() {
+		t.Errorf("unexpected chain: %s", updated.Metadata[PinUpdateChainMetaKey])
+	}
+	pin, err := cl.PinGet(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pin.ExpireAt.IsZero() {
+		t.Error("the last replaced version should be kept")
+	}
+
+	updated, err = cl.PinUpdate(ctx, test.Cid2, test.Cid3, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := test.Cid2.String() + "," + test.Cid1.String(); updated.Metadata[PinUpdateChainMetaKey] != exp {
+		t.Errorf("unexpected chain: %s", updated.Metadata[PinUpdateChainMetaKey])
+	}
+	pin, err = cl.PinGet(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin.ExpireAt.IsZero() {
+		t.Error("the oldest version should expire")
+	}
+	pin, err = cl.PinGet(ctx, test.Cid2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pin.ExpireAt.IsZero() {
+		t.Error("the last replaced version should be kept")
+	}
+}
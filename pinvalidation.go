@@ -0,0 +1,173 @@
+package ipfscluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os/exec"
+	"strings"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	"go.opencensus.io/trace"
+)
+
+// pinValidationResponse is the answer expected from the pin validation
+// hook. Metadata entries are added to the pin when it is allowed, replacing
+// any entries with the same keys.
+type pinValidationResponse struct {
+	Allow    bool              `json:"allow"`
+	Reason   string            `json:"reason,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// reservedMetaKeys are the metadata keys which cluster sets on the pins it
+// manages itself. Pins submitted by users cannot carry them, as cluster
+// would act on those pins as if it had created them.
+var reservedMetaKeys = []string{
+	api.TrashedAtMetaKey,
+	StateBackupMetaKey,
+	StateBackupBaseMetaKey,
+	StateBackupPreviousMetaKey,
+	StateBackupSequenceMetaKey,
+	PinTemplateMetaKey,
+}
+
+// checkReservedMetadata returns an error wrapping api.ErrInvalidMetadata
+// when the given metadata uses a reserved key.
+func checkReservedMetadata(meta map[string]string) error {
+	for _, k := range reservedMetaKeys {
+		if _, ok := meta[k]; ok {
+			return fmt.Errorf("%w: key %q is reserved", api.ErrInvalidMetadata, k)
+		}
+	}
+	return nil
+}
+
+// validatePin checks that a pin is not denylisted and that its metadata
+// does not use reserved keys and follows the pin_metadata policy, then asks
+// the pin validation hook, when configured, whether it can be submitted. It returns an error wrapping
+// api.ErrInvalidMetadata or api.ErrPinRejected when the pin is not
+// accepted. Shard and ClusterDAG pins are only checked against the
+// denylist: the pinning of sharded content is validated with its meta pin.
+// The metadata added by the hook is not checked.
+func (c *Cluster) validatePin(ctx context.Context, pin *api.Pin) error {
+	if err := c.checkDenylist(pin.Cid.String()); err != nil {
+		return err
+	}
+
+	if pin.Type == api.ShardType || pin.Type == api.ClusterDAGType {
+		return nil
+	}
+
+	if err := checkReservedMetadata(pin.Metadata); err != nil {
+		return err
+	}
+	if err := c.config.PinMetadata.Check(pin.Metadata); err != nil {
+		return err
+	}
+
+	cfg := c.config.PinValidation
+	if !cfg.enabled() {
+		return nil
+	}
+
+	ctx, span := trace.StartSpan(ctx, "cluster/validatePin")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	resp, err := callPinValidationHook(ctx, &cfg, pin)
+	if err != nil {
+		if cfg.FailOpen {
+			logger.Warnf("pin validation hook failed, accepting %s: %s", pin.Cid, err)
+			return nil
+		}
+		return fmt.Errorf("pin validation hook failed: %w", err)
+	}
+
+	if !resp.Allow {
+		reason := resp.Reason
+		if reason == "" {
+			reason = "no reason given"
+		}
+		logger.Infof("pin validation hook rejected %s: %s", pin.Cid, reason)
+		return fmt.Errorf("%w: %s", api.ErrPinRejected, reason)
+	}
+
+	if len(resp.Metadata) > 0 {
+		// The metadata map may be shared with the caller.
+		meta := make(map[string]string, len(pin.Metadata)+len(resp.Metadata))
+		for k, v := range pin.Metadata {
+			meta[k] = v
+		}
+		for k, v := range resp.Metadata {
+			meta[k] = v
+		}
+		pin.Metadata = meta
+	}
+	return nil
+}
+
+func callPinValidationHook(ctx context.Context, cfg *PinValidationConfig, pin *api.Pin) (*pinValidationResponse, error) {
+	body, err := json.Marshal(pin)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []byte
+	if cfg.URL != "" {
+		out, err = postPinValidation(ctx, cfg.URL, body)
+	} else {
+		out, err = execPinValidation(ctx, cfg.Command, body)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var resp pinValidationResponse
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return nil, fmt.Errorf("error decoding the response: %w", err)
+	}
+	return &resp, nil
+}
+
+func postPinValidation(ctx context.Context, url string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	out, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(out)))
+	}
+	return out, nil
+}
+
+func execPinValidation(ctx context.Context, command []string, body []byte) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return out, err
+}
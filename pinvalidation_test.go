@@ -0,0 +1,145 @@
+package ipfscluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+)
+
+func TestClusterPinValidationURL(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var pin api.Pin
+		if err := json.NewDecoder(r.Body).Decode(&pin); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp := pinValidationResponse{Allow: true}
+		switch {
+		case pin.Cid.Equals(test.Cid2):
+			resp = pinValidationResponse{Reason: "blocked"}
+		case pin.Cid.Equals(test.Cid3):
+			http.Error(w, "scanner unavailable", http.StatusServiceUnavailable)
+			return
+		case pin.Metadata["scan"] == "":
+			resp.Metadata = map[string]string{"scan": "clean"}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	cl.config.PinValidation.URL = srv.URL
+
+	pin, err := cl.Pin(ctx, test.Cid1, api.PinOptions{Metadata: map[string]string{"a": "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin.Metadata["scan"] != "clean" || pin.Metadata["a"] != "b" {
+		t.Error("expected the pin to be annotated:", pin.Metadata)
+	}
+
+	_, err = cl.Pin(ctx, test.Cid2, api.PinOptions{})
+	if !errors.Is(err, api.ErrPinRejected) {
+		t.Error("expected ErrPinRejected:", err)
+	}
+	if _, err := cl.PinGet(ctx, test.Cid2); err == nil {
+		t.Error("rejected pin should not be in the pinset")
+	}
+
+	_, err = cl.PinDryRun(ctx, test.Cid2, api.PinOptions{})
+	if !errors.Is(err, api.ErrPinRejected) {
+		t.Error("dry runs should be validated too:", err)
+	}
+
+	if _, err := cl.Pin(ctx, test.Cid3, api.PinOptions{}); err == nil {
+		t.Error("expected an error when the hook fails")
+	}
+
+	cl.config.PinValidation.FailOpen = true
+	if _, err := cl.Pin(ctx, test.Cid3, api.PinOptions{}); err != nil {
+		t.Error("the pin should be accepted when failing open:", err)
+	}
+}
+
+func TestClusterPinValidationCommand(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	cl.config.PinValidation.Command = []string{
+		"sh", "-c", `grep -q '"name":"bad"' && echo '{"allow": false, "reason": "bad name"}' || echo '{"allow": true}'`,
+	}
+
+	if _, err := cl.Pin(ctx, test.Cid1, api.PinOptions{Name: "good"}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := cl.Pin(ctx, test.Cid2, api.PinOptions{Name: "bad"})
+	if !errors.Is(err, api.ErrPinRejected) {
+		t.Error("expected ErrPinRejected:", err)
+	}
+
+	cl.config.PinValidation.Command = []string{"sh", "-c", "echo broken >&2; exit 1"}
+	if _, err := cl.Pin(ctx, test.Cid3, api.PinOptions{}); err == nil {
+		t.Error("expected an error when the command fails")
+	}
+}
+
+func TestClusterPinMetadataPolicy(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	cl.config.PinMetadata = api.MetadataPolicy{
+		MaxKeys: 2,
+		Schema: &api.MetadataSchema{
+			Required: []string{"owner"},
+		},
+	}
+
+	_, err := cl.Pin(ctx, test.Cid1, api.PinOptions{Metadata: map[string]string{"a": "1", "b": "2", "owner": "me"}})
+	if !errors.Is(err, api.ErrInvalidMetadata) {
+		t.Error("expected ErrInvalidMetadata with too many keys:", err)
+	}
+	_, err = cl.PinDryRun(ctx, test.Cid1, api.PinOptions{Metadata: map[string]string{"a": "1"}})
+	if !errors.Is(err, api.ErrInvalidMetadata) {
+		t.Error("expected ErrInvalidMetadata without a required key:", err)
+	}
+	if _, err := cl.Pin(ctx, test.Cid1, api.PinOptions{Metadata: map[string]string{"owner": "me"}}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClusterPinReservedMetadata(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	opts := api.PinOptions{Metadata: map[string]string{StateBackupMetaKey: stateBackupFull}}
+	_, err := cl.Pin(ctx, test.Cid1, opts)
+	if !errors.Is(err, api.ErrInvalidMetadata) {
+		t.Error("expected ErrInvalidMetadata with a reserved key:", err)
+	}
+
+	var out api.Pin
+	err = cl.rpcClient.CallContext(ctx, "", "Cluster", "Pin", api.PinWithOpts(test.Cid1, opts), &out)
+	if err == nil {
+		t.Error("expected the Pin RPC to reject a reserved key")
+	}
+	if _, err := cl.PinGet(ctx, test.Cid1); err == nil {
+		t.Error("rejected pin should not be in the pinset")
+	}
+}
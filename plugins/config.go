@@ -0,0 +1,205 @@
+package plugins
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/ipfs-cluster/config"
+	"github.com/kelseyhightower/envconfig"
+)
+
+const informersConfigKey = "plugins"
+const informersEnvConfigKey = "cluster_plugins"
+
+const allocatorConfigKey = "plugin"
+const allocatorEnvConfigKey = "cluster_allocator_plugin"
+
+// Spec selects a registered informer or allocator factory by name and
+// carries the configuration passed to it.
+type Spec struct {
+	Name   string          `json:"name"`
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+func (spec *Spec) validate() error {
+	if spec == nil || spec.Name == "" {
+		return errors.New("plugin name is empty")
+	}
+	return nil
+}
+
+// InformersConfig configures the informer plugins, in the "informer"
+// section of the configuration.
+type InformersConfig struct {
+	config.Saver
+
+	// GoPlugins are the paths of Go plugin files to load.
+	GoPlugins []string
+	// Informers are the plugin informers used by the peer, in addition
+	// to the built-in ones.
+	Informers []*Spec
+}
+
+type informersJSONConfig struct {
+	GoPlugins []string `json:"go_plugins"`
+	Informers []*Spec  `json:"informers" ignored:"true"`
+}
+
+// ConfigKey returns a human-friendly identifier for this type of Config.
+func (cfg *InformersConfig) ConfigKey() string {
+	return informersConfigKey
+}
+
+// Default initializes this Config with sensible values: no plugins.
+func (cfg *InformersConfig) Default() error {
+	cfg.GoPlugins = []string{}
+	cfg.Informers = []*Spec{}
+	return nil
+}
+
+// ApplyEnvVars fills in any Config fields found as environment variables.
+func (cfg *InformersConfig) ApplyEnvVars() error {
+	jcfg := cfg.toJSONConfig()
+	err := envconfig.Process(informersEnvConfigKey, jcfg)
+	if err != nil {
+		return err
+	}
+	return cfg.applyJSONConfig(jcfg)
+}
+
+// Validate checks that the fields of this Config have working values.
+func (cfg *InformersConfig) Validate() error {
+	for i, spec := range cfg.Informers {
+		if err := spec.validate(); err != nil {
+			return fmt.Errorf("plugins.informers[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// LoadJSON reads the fields of this Config from a JSON byteslice as
+// generated by ToJSON.
+func (cfg *InformersConfig) LoadJSON(raw []byte) error {
+	jcfg := &informersJSONConfig{}
+	err := json.Unmarshal(raw, jcfg)
+	if err != nil {
+		logger.Error("Error unmarshaling plugins config")
+		return err
+	}
+
+	cfg.Default()
+	return cfg.applyJSONConfig(jcfg)
+}
+
+func (cfg *InformersConfig) applyJSONConfig(jcfg *informersJSONConfig) error {
+	cfg.GoPlugins = jcfg.GoPlugins
+	cfg.Informers = jcfg.Informers
+	return cfg.Validate()
+}
+
+// ToJSON generates a JSON-formatted human-friendly representation of this
+// Config.
+func (cfg *InformersConfig) ToJSON() ([]byte, error) {
+	return config.DefaultJSONMarshal(cfg.toJSONConfig())
+}
+
+func (cfg *InformersConfig) toJSONConfig() *informersJSONConfig {
+	return &informersJSONConfig{
+		GoPlugins: cfg.GoPlugins,
+		Informers: cfg.Informers,
+	}
+}
+
+// ToDisplayJSON returns JSON config as a string.
+func (cfg *InformersConfig) ToDisplayJSON() ([]byte, error) {
+	return config.DisplayJSON(cfg.toJSONConfig())
+}
+
+// AllocatorConfig configures an allocator plugin, in the "allocator"
+// section of the configuration. When it sets an allocator, it is used
+// instead of the balanced allocator.
+type AllocatorConfig struct {
+	config.Saver
+
+	// GoPlugins are the paths of Go plugin files to load.
+	GoPlugins []string
+	// Allocator is the plugin allocator used by the peer, if any.
+	Allocator *Spec
+}
+
+type allocatorJSONConfig struct {
+	GoPlugins []string `json:"go_plugins"`
+	Allocator *Spec    `json:"allocator,omitempty" ignored:"true"`
+}
+
+// ConfigKey returns a human-friendly identifier for this type of Config.
+func (cfg *AllocatorConfig) ConfigKey() string {
+	return allocatorConfigKey
+}
+
+// Default initializes this Config with sensible values: no allocator.
+func (cfg *AllocatorConfig) Default() error {
+	cfg.GoPlugins = []string{}
+	cfg.Allocator = nil
+	return nil
+}
+
+// ApplyEnvVars fills in any Config fields found as environment variables.
+func (cfg *AllocatorConfig) ApplyEnvVars() error {
+	jcfg := cfg.toJSONConfig()
+	err := envconfig.Process(allocatorEnvConfigKey, jcfg)
+	if err != nil {
+		return err
+	}
+	return cfg.applyJSONConfig(jcfg)
+}
+
+// Validate checks that the fields of this Config have working values.
+func (cfg *AllocatorConfig) Validate() error {
+	if cfg.Allocator == nil {
+		return nil
+	}
+	if err := cfg.Allocator.validate(); err != nil {
+		return fmt.Errorf("plugin.allocator: %w", err)
+	}
+	return nil
+}
+
+// LoadJSON reads the fields of this Config from a JSON byteslice as
+// generated by ToJSON.
+func (cfg *AllocatorConfig) LoadJSON(raw []byte) error {
+	jcfg := &allocatorJSONConfig{}
+	err := json.Unmarshal(raw, jcfg)
+	if err != nil {
+		logger.Error("Error unmarshaling allocator plugin config")
+		return err
+	}
+
+	cfg.Default()
+	return cfg.applyJSONConfig(jcfg)
+}
+
+func (cfg *AllocatorConfig) applyJSONConfig(jcfg *allocatorJSONConfig) error {
+	cfg.GoPlugins = jcfg.GoPlugins
+	cfg.Allocator = jcfg.Allocator
+	return cfg.Validate()
+}
+
+// ToJSON generates a JSON-formatted human-friendly representation of this
+// Config.
+func (cfg *AllocatorConfig) ToJSON() ([]byte, error) {
+	return config.DefaultJSONMarshal(cfg.toJSONConfig())
+}
+
+func (cfg *AllocatorConfig) toJSONConfig() *allocatorJSONConfig {
+	return &allocatorJSONConfig{
+		GoPlugins: cfg.GoPlugins,
+		Allocator: cfg.Allocator,
+	}
+}
+
+// ToDisplayJSON returns JSON config as a string.
+func (cfg *AllocatorConfig) ToDisplayJSON() ([]byte, error) {
+	return config.DisplayJSON(cfg.toJSONConfig())
+}
@@ -0,0 +1,113 @@
+package plugins
+
+import (
+	"os"
+	"testing"
+)
+
+var informersCfgJSON = []byte(`
+{
+    "go_plugins": ["/opt/cluster/gpus.so"],
+    "informers": [
+        {
+            "name": "exec",
+            "config": { "path": "/usr/local/bin/disk-health", "name": "health" }
+        }
+    ]
+}
+`)
+
+var allocatorCfgJSON = []byte(`
+{
+    "allocator": {
+        "name": "exec",
+        "config": { "path": "/usr/local/bin/allocate" }
+    }
+}
+`)
+
+func TestInformersLoadJSON(t *testing.T) {
+	cfg := &InformersConfig{}
+	err := cfg.LoadJSON(informersCfgJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.GoPlugins) != 1 || len(cfg.Informers) != 1 || cfg.Informers[0].Name != "exec" {
+		t.Fatal("config not parsed")
+	}
+
+	err = cfg.LoadJSON([]byte(`{"informers": [{"config": {}}]}`))
+	if err == nil {
+		t.Error("expected an error with an unnamed informer")
+	}
+}
+
+func TestInformersToJSON(t *testing.T) {
+	cfg := &InformersConfig{}
+	cfg.LoadJSON(informersCfgJSON)
+	newjson, err := cfg.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = &InformersConfig{}
+	err = cfg.LoadJSON(newjson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Informers) != 1 || len(cfg.Informers[0].Config) == 0 {
+		t.Error("informers lost in the JSON round trip")
+	}
+}
+
+func TestAllocatorLoadJSON(t *testing.T) {
+	cfg := &AllocatorConfig{}
+	err := cfg.LoadJSON(allocatorCfgJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Allocator == nil || cfg.Allocator.Name != "exec" {
+		t.Fatal("config not parsed")
+	}
+
+	newjson, err := cfg.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = &AllocatorConfig{}
+	err = cfg.LoadJSON(newjson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Allocator == nil {
+		t.Error("allocator lost in the JSON round trip")
+	}
+
+	err = cfg.LoadJSON([]byte(`{"allocator": {"name": ""}}`))
+	if err == nil {
+		t.Error("expected an error with an unnamed allocator")
+	}
+}
+
+func TestDefault(t *testing.T) {
+	icfg := &InformersConfig{}
+	icfg.Default()
+	if icfg.Validate() != nil {
+		t.Fatal("error validating")
+	}
+	acfg := &AllocatorConfig{}
+	acfg.Default()
+	if acfg.Validate() != nil || acfg.Allocator != nil {
+		t.Fatal("the default config should have no allocator")
+	}
+}
+
+func TestApplyEnvVars(t *testing.T) {
+	os.Setenv("CLUSTER_PLUGINS_GOPLUGINS", "/a.so,/b.so")
+	defer os.Unsetenv("CLUSTER_PLUGINS_GOPLUGINS")
+	cfg := &InformersConfig{}
+	cfg.Default()
+	cfg.ApplyEnvVars()
+	if len(cfg.GoPlugins) != 2 {
+		t.Fatal("failed to override go_plugins with env var")
+	}
+}
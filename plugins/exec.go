@@ -0,0 +1,395 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	ipfscluster "github.com/ipfs/ipfs-cluster"
+	"github.com/ipfs/ipfs-cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+)
+
+// ExecFactoryName is the name of the built-in informer and allocator
+// factories which use external binaries.
+const ExecFactoryName = "exec"
+
+// Default values for ExecConfig.
+const (
+	DefaultExecTimeout   = 10 * time.Second
+	DefaultExecMetricTTL = 30 * time.Second
+)
+
+// ExecConfig is the configuration of the "exec" informer and allocator.
+//
+// The program is started when the informer or allocator is created and
+// kept running. Requests are written to its standard input and responses
+// read from its standard output, one JSON object per line:
+//
+//	{"id": 1, "method": "get_metrics"}
+//	{"id": 1, "result": [{"name": "gpus", "value": "4", "valid": true}]}
+//
+// Informers receive "get_metrics" requests and answer with a list of
+// metrics, with the fields "name" (the informer name when empty),
+// "value", "valid", "weight" and "partitionable".
+//
+// Allocators receive a "metrics" request when they are created, answered
+// with the list of metric names they need, and "allocate" requests, whose
+// params have the "cid" being allocated and the "current", "candidates"
+// and "priority" metrics by metric name. They answer with the list of
+// peer IDs to allocate, in order of preference.
+//
+// Errors are answered with an "error" string instead of a "result". The
+// program is restarted when it exits or does not answer in time. Its
+// standard error is the one of the peer.
+type ExecConfig struct {
+	Path string   `json:"path"`
+	Args []string `json:"args,omitempty"`
+	// Name of the informer.
+	Name string `json:"name,omitempty"`
+	// TTL of the metrics of the informer.
+	MetricTTL string `json:"metric_ttl,omitempty"`
+	// Timeout is the maximum time to answer a request.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+func parseExecConfig(raw json.RawMessage) (*ExecConfig, time.Duration, error) {
+	var cfg ExecConfig
+	if len(raw) == 0 {
+		return nil, 0, errors.New("exec plugins need a configuration")
+	}
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return nil, 0, err
+	}
+	if cfg.Path == "" {
+		return nil, 0, errors.New("exec plugins need a path")
+	}
+	timeout := DefaultExecTimeout
+	if cfg.Timeout != "" {
+		t, err := time.ParseDuration(cfg.Timeout)
+		if err != nil || t <= 0 {
+			return nil, 0, fmt.Errorf("invalid timeout %q", cfg.Timeout)
+		}
+		timeout = t
+	}
+	return &cfg, timeout, nil
+}
+
+type execRequest struct {
+	ID     uint64      `json:"id"`
+	Method string      `json:"method"`
+	Params interface{} `json:"params,omitempty"`
+}
+
+type execResponse struct {
+	ID     uint64          `json:"id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// process runs an external program and makes requests to it.
+type process struct {
+	path    string
+	args    []string
+	timeout time.Duration
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *json.Decoder
+	lastID uint64
+}
+
+func newProcess(path string, args []string, timeout time.Duration) *process {
+	return &process{
+		path:    path,
+		args:    args,
+		timeout: timeout,
+	}
+}
+
+func (p *process) start() error {
+	cmd := exec.Command(p.path, p.args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error starting %s: %w", p.path, err)
+	}
+	logger.Infof("started %s (pid %d)", p.path, cmd.Process.Pid)
+	p.cmd = cmd
+	p.stdin = stdin
+	p.stdout = json.NewDecoder(bufio.NewReader(stdout))
+	return nil
+}
+
+// stop kills the program, giving it the given time to exit after closing
+// its input.
+func (p *process) stop(grace time.Duration) {
+	if p.cmd == nil {
+		return
+	}
+	p.stdin.Close()
+	exited := make(chan struct{})
+	go func() {
+		p.cmd.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-time.After(grace):
+		p.cmd.Process.Kill()
+		<-exited
+	}
+	p.cmd = nil
+}
+
+// call makes a request and decodes its result, restarting the program if
+// needed.
+func (p *process) call(ctx context.Context, method string, params, result interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cmd == nil {
+		if err := p.start(); err != nil {
+			return err
+		}
+	}
+
+	p.lastID++
+	req := execRequest{ID: p.lastID, Method: method, Params: params}
+	if err := json.NewEncoder(p.stdin).Encode(req); err != nil {
+		p.stop(0)
+		return fmt.Errorf("error sending %s request to %s: %w", method, p.path, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	var resp execResponse
+	done := make(chan error, 1)
+	go func() {
+		done <- p.stdout.Decode(&resp)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			p.stop(0)
+			return fmt.Errorf("error reading %s response from %s: %w", method, p.path, err)
+		}
+	case <-ctx.Done():
+		p.stop(0)
+		<-done
+		return fmt.Errorf("%s did not answer the %s request: %w", p.path, method, ctx.Err())
+	}
+
+	if resp.ID != req.ID {
+		p.stop(0)
+		return fmt.Errorf("%s answered request %d instead of %d", p.path, resp.ID, req.ID)
+	}
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Result, result)
+}
+
+func (p *process) shutdown() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stop(p.timeout)
+}
+
+// ExecInformer is an informer which gets its metrics from an external
+// program.
+type ExecInformer struct {
+	name      string
+	metricTTL time.Duration
+	proc      *process
+}
+
+type execMetric struct {
+	Name          string `json:"name"`
+	Value         string `json:"value"`
+	Valid         bool   `json:"valid"`
+	Weight        int64  `json:"weight"`
+	Partitionable bool   `json:"partitionable"`
+}
+
+// NewExecInformer creates an ExecInformer with the given ExecConfig.
+func NewExecInformer(raw json.RawMessage) (ipfscluster.Informer, error) {
+	cfg, timeout, err := parseExecConfig(raw)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Name == "" {
+		return nil, errors.New("exec informers need a name")
+	}
+	ttl := DefaultExecMetricTTL
+	if cfg.MetricTTL != "" {
+		ttl, err = time.ParseDuration(cfg.MetricTTL)
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid metric_ttl %q", cfg.MetricTTL)
+		}
+	}
+
+	proc := newProcess(cfg.Path, cfg.Args, timeout)
+	if err := proc.start(); err != nil {
+		return nil, err
+	}
+	return &ExecInformer{
+		name:      cfg.Name,
+		metricTTL: ttl,
+		proc:      proc,
+	}, nil
+}
+
+// Name returns the name of the informer, as configured.
+func (inf *ExecInformer) Name() string {
+	return inf.name
+}
+
+// SetClient does nothing, as the program cannot make RPC requests.
+func (inf *ExecInformer) SetClient(c *rpc.Client) {}
+
+// Shutdown stops the program.
+func (inf *ExecInformer) Shutdown(ctx context.Context) error {
+	inf.proc.shutdown()
+	return nil
+}
+
+// GetMetrics asks the program for its metrics. When it fails, it returns
+// an invalid metric with the name of the informer.
+func (inf *ExecInformer) GetMetrics(ctx context.Context) []*api.Metric {
+	var results []execMetric
+	err := inf.proc.call(ctx, "get_metrics", nil, &results)
+	if err == nil && len(results) == 0 {
+		err = errors.New("no metrics")
+	}
+	if err != nil {
+		logger.Errorf("informer %s: %s", inf.name, err)
+		m := &api.Metric{
+			Name:  inf.name,
+			Valid: false,
+		}
+		m.SetTTL(inf.metricTTL)
+		return []*api.Metric{m}
+	}
+
+	metrics := make([]*api.Metric, 0, len(results))
+	for _, r := range results {
+		m := &api.Metric{
+			Name:          r.Name,
+			Value:         r.Value,
+			Valid:         r.Valid,
+			Weight:        r.Weight,
+			Partitionable: r.Partitionable,
+		}
+		if m.Name == "" {
+			m.Name = inf.name
+		}
+		m.SetTTL(inf.metricTTL)
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+// ExecAllocator is an allocator which asks an external program where to
+// allocate pins.
+type ExecAllocator struct {
+	metrics []string
+	proc    *process
+}
+
+type allocateParams struct {
+	Cid        cid.Cid        `json:"cid"`
+	Current    api.MetricsSet `json:"current"`
+	Candidates api.MetricsSet `json:"candidates"`
+	Priority   api.MetricsSet `json:"priority"`
+}
+
+// NewExecAllocator creates an ExecAllocator with the given ExecConfig. It
+// asks the program for the metrics it needs.
+func NewExecAllocator(raw json.RawMessage) (ipfscluster.PinAllocator, error) {
+	cfg, timeout, err := parseExecConfig(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	proc := newProcess(cfg.Path, cfg.Args, timeout)
+	var metrics []string
+	if err := proc.call(context.Background(), "metrics", nil, &metrics); err != nil {
+		proc.shutdown()
+		return nil, fmt.Errorf("error getting the metrics of the allocator: %w", err)
+	}
+	return &ExecAllocator{
+		metrics: metrics,
+		proc:    proc,
+	}, nil
+}
+
+// SetClient does nothing, as the program cannot make RPC requests.
+func (alloc *ExecAllocator) SetClient(c *rpc.Client) {}
+
+// Shutdown stops the program.
+func (alloc *ExecAllocator) Shutdown(ctx context.Context) error {
+	alloc.proc.shutdown()
+	return nil
+}
+
+// Metrics returns the metrics that the program needs.
+func (alloc *ExecAllocator) Metrics() []string {
+	return alloc.metrics
+}
+
+// Allocate asks the program for the peers which should pin the given
+// content. Peers which are not among the candidates or priority peers, and
+// repeated peers, are left out of the answer.
+func (alloc *ExecAllocator) Allocate(ctx context.Context, c cid.Cid, current, candidates, priority api.MetricsSet) ([]peer.ID, error) {
+	var peers []peer.ID
+	err := alloc.proc.call(ctx, "allocate", allocateParams{
+		Cid:        c,
+		Current:    current,
+		Candidates: candidates,
+		Priority:   priority,
+	}, &peers)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := make(map[peer.ID]struct{})
+	for _, set := range []api.MetricsSet{candidates, priority} {
+		for _, metrics := range set {
+			for _, m := range metrics {
+				allowed[m.Peer] = struct{}{}
+			}
+		}
+	}
+	filtered := make([]peer.ID, 0, len(peers))
+	for _, p := range peers {
+		if _, ok := allowed[p]; !ok {
+			logger.Warnf("allocator %s: ignoring %s, which is not a candidate for %s", alloc.proc.path, p, c)
+			continue
+		}
+		delete(allowed, p)
+		filtered = append(filtered, p)
+	}
+	return filtered, nil
+}
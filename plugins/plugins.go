@@ -0,0 +1,166 @@
+// Package plugins allows to use informers and allocators which are not part
+// of ipfs-cluster.
+//
+// Informers and allocators are created by factories registered with a name
+// using RegisterInformer and RegisterAllocator. The configuration selects
+// them by that name and passes them their own configuration object. The
+// factories can come from:
+//
+//   - Go plugin files (built with "go build -buildmode=plugin" against the
+//     same version of ipfs-cluster), which call RegisterInformer or
+//     RegisterAllocator in their init() function. The configuration lists
+//     the plugin files to load.
+//   - External binaries, with the built-in "exec" factories. They run a
+//     program and talk to it over its standard input and output (see
+//     ExecConfig).
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"plugin"
+	"sort"
+	"sync"
+
+	ipfscluster "github.com/ipfs/ipfs-cluster"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var logger = logging.Logger("plugins")
+
+// InformerFactory creates an informer with the given configuration, which
+// is the "config" object of the informer in the plugins configuration.
+type InformerFactory func(cfg json.RawMessage) (ipfscluster.Informer, error)
+
+// AllocatorFactory creates an allocator with the given configuration, which
+// is the "config" object of the allocator in the plugin configuration.
+type AllocatorFactory func(cfg json.RawMessage) (ipfscluster.PinAllocator, error)
+
+var (
+	registryMux sync.RWMutex
+	informers   = make(map[string]InformerFactory)
+	allocators  = make(map[string]AllocatorFactory)
+	goPlugins   = make(map[string]bool)
+)
+
+func init() {
+	RegisterInformer(ExecFactoryName, NewExecInformer)
+	RegisterAllocator(ExecFactoryName, NewExecAllocator)
+}
+
+// RegisterInformer makes an informer factory available with the given
+// name. It panics when the name is already registered.
+func RegisterInformer(name string, factory InformerFactory) {
+	registryMux.Lock()
+	defer registryMux.Unlock()
+	if factory == nil {
+		panic("plugins: nil informer factory for " + name)
+	}
+	if _, ok := informers[name]; ok {
+		panic("plugins: informer registered twice: " + name)
+	}
+	informers[name] = factory
+}
+
+// RegisterAllocator makes an allocator factory available with the given
+// name. It panics when the name is already registered.
+func RegisterAllocator(name string, factory AllocatorFactory) {
+	registryMux.Lock()
+	defer registryMux.Unlock()
+	if factory == nil {
+		panic("plugins: nil allocator factory for " + name)
+	}
+	if _, ok := allocators[name]; ok {
+		panic("plugins: allocator registered twice: " + name)
+	}
+	allocators[name] = factory
+}
+
+// Registered returns the names of the registered informer and allocator
+// factories.
+func Registered() (informerNames, allocatorNames []string) {
+	registryMux.RLock()
+	defer registryMux.RUnlock()
+	for name := range informers {
+		informerNames = append(informerNames, name)
+	}
+	for name := range allocators {
+		allocatorNames = append(allocatorNames, name)
+	}
+	sort.Strings(informerNames)
+	sort.Strings(allocatorNames)
+	return
+}
+
+// LoadGoPlugins opens the given Go plugin files, which register their
+// factories when loaded. Files which were already loaded are skipped.
+func LoadGoPlugins(paths []string) error {
+	for _, path := range paths {
+		registryMux.RLock()
+		loaded := goPlugins[path]
+		registryMux.RUnlock()
+		if loaded {
+			continue
+		}
+
+		if _, err := plugin.Open(path); err != nil {
+			return fmt.Errorf("error loading plugin %s: %w", path, err)
+		}
+		registryMux.Lock()
+		goPlugins[path] = true
+		registryMux.Unlock()
+		logger.Infof("loaded plugin %s", path)
+	}
+	return nil
+}
+
+// NewInformers loads the Go plugins in the configuration and creates the
+// informers in it.
+func NewInformers(cfg *InformersConfig) ([]ipfscluster.Informer, error) {
+	if err := LoadGoPlugins(cfg.GoPlugins); err != nil {
+		return nil, err
+	}
+
+	var infs []ipfscluster.Informer
+	for _, spec := range cfg.Informers {
+		registryMux.RLock()
+		factory, ok := informers[spec.Name]
+		registryMux.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown informer plugin %q", spec.Name)
+		}
+		inf, err := factory(spec.Config)
+		if err != nil {
+			return nil, fmt.Errorf("error creating informer plugin %q: %w", spec.Name, err)
+		}
+		logger.Infof("using informer plugin %q (%s)", spec.Name, inf.Name())
+		infs = append(infs, inf)
+	}
+	return infs, nil
+}
+
+// NewAllocator loads the Go plugins in the configuration and creates the
+// allocator in it. It returns nil when the configuration sets no
+// allocator.
+func NewAllocator(cfg *AllocatorConfig) (ipfscluster.PinAllocator, error) {
+	if err := LoadGoPlugins(cfg.GoPlugins); err != nil {
+		return nil, err
+	}
+	if cfg.Allocator == nil {
+		return nil, nil
+	}
+
+	registryMux.RLock()
+	factory, ok := allocators[cfg.Allocator.Name]
+	registryMux.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown allocator plugin %q", cfg.Allocator.Name)
+	}
+	alloc, err := factory(cfg.Allocator.Config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating allocator plugin %q: %w", cfg.Allocator.Name, err)
+	}
+	logger.Infof("using allocator plugin %q", cfg.Allocator.Name)
+	return alloc, nil
+}
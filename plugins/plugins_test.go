@@ -0,0 +1,260 @@
+package plugins
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	ipfscluster "github.com/ipfs/ipfs-cluster"
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+// helperEnv makes the test binary act as an exec plugin (see helperPlugin).
+const helperEnv = "CLUSTER_PLUGINS_TEST_HELPER"
+
+func TestMain(m *testing.M) {
+	if mode := os.Getenv(helperEnv); mode != "" {
+		helperPlugin(mode)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// helperPlugin answers requests: "ok" answers them, "fail" answers with
+// errors and "hang" does not answer "get_metrics".
+func helperPlugin(mode string) {
+	in := bufio.NewScanner(os.Stdin)
+	out := json.NewEncoder(os.Stdout)
+	for in.Scan() {
+		var req struct {
+			ID     uint64          `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := json.Unmarshal(in.Bytes(), &req); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		resp := map[string]interface{}{"id": req.ID}
+		switch {
+		case mode == "fail":
+			resp["error"] = "failed"
+		case req.Method == "get_metrics" && mode == "hang":
+			time.Sleep(time.Minute)
+		case req.Method == "get_metrics":
+			resp["result"] = []map[string]interface{}{
+				{"value": "4", "valid": true},
+				{"name": "gpus_free", "value": "2", "valid": true, "partitionable": true},
+			}
+		case req.Method == "metrics":
+			resp["result"] = []string{"gpus"}
+		case req.Method == "allocate":
+			var params struct {
+				Current    api.MetricsSet `json:"current"`
+				Candidates api.MetricsSet `json:"candidates"`
+			}
+			json.Unmarshal(req.Params, &params)
+			// Candidates are answered twice, followed by the
+			// current peers, which are not valid answers.
+			var peers []peer.ID
+			for i := 0; i < 2; i++ {
+				for _, m := range params.Candidates["gpus"] {
+					peers = append(peers, m.Peer)
+				}
+			}
+			for _, m := range params.Current["gpus"] {
+				peers = append(peers, m.Peer)
+			}
+			resp["result"] = peers
+		default:
+			resp["error"] = "unknown method " + req.Method
+		}
+		out.Encode(resp)
+	}
+}
+
+func helperConfig(t *testing.T, mode string, extra string) json.RawMessage {
+	t.Helper()
+	os.Setenv(helperEnv, mode)
+	t.Cleanup(func() { os.Unsetenv(helperEnv) })
+	return json.RawMessage(fmt.Sprintf(`{"path": %q, "name": "gpus"%s}`, os.Args[0], extra))
+}
+
+func TestExecInformer(t *testing.T) {
+	ctx := context.Background()
+	inf, err := NewExecInformer(helperConfig(t, "ok", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inf.Shutdown(ctx)
+
+	if inf.Name() != "gpus" {
+		t.Error("wrong informer name")
+	}
+	// Twice, to check that the program keeps running.
+	for i := 0; i < 2; i++ {
+		metrics := inf.GetMetrics(ctx)
+		if len(metrics) != 2 {
+			t.Fatal("expected 2 metrics")
+		}
+		if metrics[0].Name != "gpus" || metrics[0].Value != "4" || !metrics[0].Valid {
+			t.Errorf("unexpected metric: %+v", metrics[0])
+		}
+		if metrics[1].Name != "gpus_free" || !metrics[1].Partitionable {
+			t.Errorf("unexpected metric: %+v", metrics[1])
+		}
+		if metrics[0].Expired() {
+			t.Error("metric should not be expired")
+		}
+	}
+
+	// The program is started again when it exits.
+	inf.(*ExecInformer).proc.cmd.Process.Kill()
+	inf.GetMetrics(ctx) // notices that it exited
+	if metrics := inf.GetMetrics(ctx); !metrics[0].Valid {
+		t.Error("the program was not restarted")
+	}
+}
+
+func TestExecInformerErrors(t *testing.T) {
+	ctx := context.Background()
+	inf, err := NewExecInformer(helperConfig(t, "fail", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inf.Shutdown(ctx)
+	metrics := inf.GetMetrics(ctx)
+	if len(metrics) != 1 || metrics[0].Valid || metrics[0].Name != "gpus" {
+		t.Errorf("expected an invalid metric: %+v", metrics)
+	}
+
+	hung, err := NewExecInformer(helperConfig(t, "hang", `, "timeout": "200ms"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hung.Shutdown(ctx)
+	start := time.Now()
+	metrics = hung.GetMetrics(ctx)
+	if len(metrics) != 1 || metrics[0].Valid {
+		t.Errorf("expected an invalid metric: %+v", metrics)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Error("the request did not time out")
+	}
+	if hung.(*ExecInformer).proc.cmd != nil {
+		t.Error("the program should have been stopped")
+	}
+}
+
+func TestExecConfigErrors(t *testing.T) {
+	for _, raw := range []string{
+		``,
+		`{}`,
+		`{"path": "/bin/true"}`,
+		`{"path": "/bin/true", "name": "a", "timeout": "-1s"}`,
+		`{"path": "/bin/true", "name": "a", "metric_ttl": "abc"}`,
+		`{"path": "/does/not/exist", "name": "a"}`,
+	} {
+		if _, err := NewExecInformer(json.RawMessage(raw)); err == nil {
+			t.Errorf("expected an error with %q", raw)
+		}
+	}
+}
+
+func TestExecAllocator(t *testing.T) {
+	ctx := context.Background()
+	alloc, err := NewExecAllocator(helperConfig(t, "ok", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer alloc.Shutdown(ctx)
+
+	if m := alloc.Metrics(); len(m) != 1 || m[0] != "gpus" {
+		t.Fatalf("unexpected allocator metrics: %v", m)
+	}
+
+	candidates := api.MetricsSet{
+		"gpus": []*api.Metric{
+			{Name: "gpus", Peer: test.PeerID1, Value: "1", Valid: true},
+			{Name: "gpus", Peer: test.PeerID2, Value: "2", Valid: true},
+		},
+	}
+	current := api.MetricsSet{
+		"gpus": []*api.Metric{
+			{Name: "gpus", Peer: test.PeerID3, Value: "3", Valid: true},
+		},
+	}
+	peers, err := alloc.Allocate(ctx, test.Cid1, current, candidates, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Repeated peers and peers which are not candidates are left out.
+	if len(peers) != 2 || peers[0] != test.PeerID1 || peers[1] != test.PeerID2 {
+		t.Errorf("unexpected allocation: %v", peers)
+	}
+
+	if _, err := NewExecAllocator(helperConfig(t, "fail", "")); err == nil {
+		t.Error("expected an error when the program fails")
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	factory := func(json.RawMessage) (ipfscluster.Informer, error) {
+		return NewExecInformer(helperConfig(t, "ok", ""))
+	}
+	RegisterInformer("test", factory)
+	t.Cleanup(func() {
+		registryMux.Lock()
+		delete(informers, "test")
+		registryMux.Unlock()
+	})
+	infNames, allocNames := Registered()
+	if len(infNames) != 2 || infNames[0] != ExecFactoryName || infNames[1] != "test" {
+		t.Errorf("unexpected informers: %v", infNames)
+	}
+	if len(allocNames) != 1 || allocNames[0] != ExecFactoryName {
+		t.Errorf("unexpected allocators: %v", allocNames)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic registering an informer twice")
+			}
+		}()
+		RegisterInformer("test", factory)
+	}()
+
+	cfg := &InformersConfig{Informers: []*Spec{{Name: "test"}}}
+	infs, err := NewInformers(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infs) != 1 || infs[0].Name() != "gpus" {
+		t.Error("informer not created")
+	}
+	infs[0].Shutdown(context.Background())
+
+	cfg.Informers = []*Spec{{Name: "unknown"}}
+	if _, err := NewInformers(cfg); err == nil {
+		t.Error("expected an error with an unknown informer")
+	}
+
+	alloc, err := NewAllocator(&AllocatorConfig{})
+	if err != nil || alloc != nil {
+		t.Error("expected no allocator")
+	}
+	if _, err := NewAllocator(&AllocatorConfig{Allocator: &Spec{Name: "unknown"}}); err == nil {
+		t.Error("expected an error with an unknown allocator")
+	}
+	if _, err := NewAllocator(&AllocatorConfig{GoPlugins: []string{"/does/not/exist.so"}}); err == nil {
+		t.Error("expected an error loading a missing plugin")
+	}
+}
@@ -0,0 +1,249 @@
+package ipfscluster
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+
+	"go.opencensus.io/trace"
+)
+
+// This file gathers the logic to adjust the replication factor of pins
+// depending on how often they are accessed:
+//
+// * Content accesses are reported to the local peer with TrackAccess()
+//   (i.e. by the IPFS proxy) and read from the access log of the gateway
+//   (Popularity.AccessLog), which serves most of the content requests.
+// * On every Popularity.Interval, every peer publishes the access counts of
+//   the last interval as a "popularity" metric and resets them.
+// * The peer closest to each pin adds up the counts from all the last
+//   popularity metrics and increases the replication factor of hot pins
+//   (up to Popularity.MaxReplication), or lowers it back for cold pins.
+//
+// The number of replicas added is tracked in the pin metadata, so the
+// replication factor is never lowered below the original one.
+
+const (
+	popularityMetricName = "popularity"
+	// Only the most accessed items are included in popularity metrics,
+	// to keep them small.
+	maxPopularityEntries = 1000
+)
+
+// PopularityBoostMetaKey is the metadata key used to store how many replicas
+// have been added to a pin because of its popularity.
+const PopularityBoostMetaKey = "popularity_boost"
+
+// accessCounter keeps the number of accesses to CIDs.
+type accessCounter struct {
+	mu     sync.Mutex
+	counts map[cid.Cid]uint64
+}
+
+func newAccessCounter() *accessCounter {
+	return &accessCounter{
+		counts: make(map[cid.Cid]uint64),
+	}
+}
+
+func (ac *accessCounter) add(ci cid.Cid) {
+	ac.mu.Lock()
+	ac.counts[ci]++
+	ac.mu.Unlock()
+}
+
+// flush returns the current counts for, at most, the maxPopularityEntries
+// most accessed CIDs and resets them.
+func (ac *accessCounter) flush() map[string]uint64 {
+	ac.mu.Lock()
+	counts := ac.counts
+	ac.counts = make(map[cid.Cid]uint64)
+	ac.mu.Unlock()
+
+	cids := make([]cid.Cid, 0, len(counts))
+	for ci := range counts {
+		cids = append(cids, ci)
+	}
+	sort.Slice(cids, func(i, j int) bool {
+		return counts[cids[i]] > counts[cids[j]]
+	})
+	if len(cids) > maxPopularityEntries {
+		cids = cids[:maxPopularityEntries]
+	}
+
+	res := make(map[string]uint64, len(cids))
+	for _, ci := range cids {
+		res[ci.String()] = counts[ci]
+	}
+	return res
+}
+
+// TrackAccess records an access to the content with the given CID. Access
+// counts are used to adjust the replication factor of the pins when
+// Popularity is enabled in the configuration, and to pin the content when
+// the ReadThroughCache is. Otherwise this does nothing.
+func (c *Cluster) TrackAccess(ctx context.Context, ci cid.Cid) {
+	if c.config.Popularity.Interval > 0 {
+		c.accesses.add(ci)
+	}
+	if c.config.ReadThroughCache.Interval > 0 {
+		c.cacheHits.add(ci)
+	}
+}
+
+// watchPopularity publishes popularity metrics and adjusts replication
+// factors on every Popularity.Interval.
+func (c *Cluster) watchPopularity() {
+	ticker := time.NewTicker(c.config.Popularity.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			// Using the counts published in the previous
+			// round gives time for the metrics of other
+			// peers to arrive.
+			c.adjustPopularReplication(c.ctx)
+			c.sendPopularityMetric(c.ctx)
+		}
+	}
+}
+
+// sendPopularityMetric publishes the access counts of the last interval,
+// including the gateway requests in the access log, and resets them.
+func (c *Cluster) sendPopularityMetric(ctx context.Context) (*api.Metric, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/sendPopularityMetric")
+	defer span.End()
+
+	var cids []cid.Cid
+	c.popularityLog, cids = readAccessLog(c.popularityLog, c.config.GetPopularityAccessLogPath())
+	for _, ci := range cids {
+		c.accesses.add(ci)
+	}
+
+	value, err := json.Marshal(c.accesses.flush())
+	if err != nil {
+		return nil, err
+	}
+
+	metric := &api.Metric{
+		Name:  popularityMetricName,
+		Peer:  c.id,
+		Value: string(value),
+		Valid: true,
+	}
+	metric.SetTTL(c.config.Popularity.Interval * 2)
+	return metric, c.monitor.PublishMetric(ctx, metric)
+}
+
+// popularity adds up the access counts in the latest popularity metrics
+// from all peers.
+func (c *Cluster) popularity(ctx context.Context) map[string]uint64 {
+	totals := make(map[string]uint64)
+	for _, m := range c.monitor.LatestMetrics(ctx, popularityMetricName) {
+		var counts map[string]uint64
+		if err := json.Unmarshal([]byte(m.Value), &counts); err != nil {
+			logger.Warnf("bad popularity metric from %s: %s", m.Peer, err)
+			continue
+		}
+		for k, v := range counts {
+			totals[k] += v
+		}
+	}
+	return totals
+}
+
+// adjustPopularReplication increases or lowers the replication factor of
+// the pins for which this peer is the closest, according to the number of
+// accesses reported in the latest popularity metrics.
+func (c *Cluster) adjustPopularReplication(ctx context.Context) {
+	ctx, span := trace.StartSpan(ctx, "cluster/adjustPopularReplication")
+	defer span.End()
+
+	if c.config.FollowerMode {
+		return
+	}
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		logger.Warn(err)
+		return
+	}
+	pins, err := cState.List(ctx)
+	if err != nil {
+		logger.Warn(err)
+		return
+	}
+
+	distance, err := c.distances(ctx, "")
+	if err != nil {
+		return // logged
+	}
+
+	counts := c.popularity(ctx)
+	for _, pin := range pins {
+		if !distance.isClosest(pin.Cid) {
+			continue
+		}
+		newPin, ok := c.popularityUpdate(pin, counts[pin.Cid.String()])
+		if !ok {
+			continue
+		}
+		logger.Infof(
+			"adjusting replication of %s to %d/%d (boost: %s)",
+			pin.Cid,
+			newPin.ReplicationFactorMin,
+			newPin.ReplicationFactorMax,
+			newPin.Metadata[PopularityBoostMetaKey],
+		)
+		if _, _, err := c.pin(ctx, newPin, nil); err != nil {
+			logger.Warnf("error adjusting replication of %s: %s", pin.Cid, err)
+		}
+	}
+}
+
+// popularityUpdate returns a copy of the pin with the replication factors
+// adjusted for the given number of accesses, and true when they changed.
+func (c *Cluster) popularityUpdate(pin *api.Pin, accesses uint64) (*api.Pin, bool) {
+	if pin.Type != api.DataType || pin.IsPinEverywhere() {
+		return nil, false
+	}
+
+	cfg := c.config.Popularity
+	boost, _ := strconv.Atoi(pin.Metadata[PopularityBoostMetaKey])
+
+	switch {
+	case accesses >= cfg.HotThreshold && pin.ReplicationFactorMax < cfg.MaxReplication:
+		boost++
+	case accesses <= cfg.ColdThreshold && boost > 0:
+		boost--
+	default:
+		return nil, false
+	}
+
+	newPin := *pin
+	newPin.Allocations = nil // force re-allocations
+	newPin.Metadata = make(map[string]string, len(pin.Metadata)+1)
+	for k, v := range pin.Metadata {
+		newPin.Metadata[k] = v
+	}
+	if boost > 0 {
+		newPin.Metadata[PopularityBoostMetaKey] = strconv.Itoa(boost)
+	} else {
+		delete(newPin.Metadata, PopularityBoostMetaKey)
+	}
+
+	oldBoost, _ := strconv.Atoi(pin.Metadata[PopularityBoostMetaKey])
+	newPin.ReplicationFactorMin += boost - oldBoost
+	newPin.ReplicationFactorMax += boost - oldBoost
+	return &newPin, true
+}
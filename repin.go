@@ -0,0 +1,198 @@
+package ipfscluster
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+
+	"go.opencensus.io/trace"
+)
+
+// This file gathers the logic to re-allocate the pins of peers which have
+// gone down, as configured by the RepinConfig:
+//
+// * When a ping alert for a peer arrives, the re-allocation is delayed by
+//   Repin.GracePeriod. If the peer pings again before that, nothing happens.
+// * The pins allocated to the peer for which this peer is the closest one
+//   are selected. With Repin.OnlyUnderReplicated, pins which still have at
+//   least their replication_factor_min healthy allocations are left alone.
+// * The selected pins are re-allocated by up to Repin.Concurrency workers,
+//   as part of a "repin" operation which can be canceled.
+
+// repinTracker keeps the peers with a delayed repin, so that repeated alerts
+// for a peer do not queue more than one.
+type repinTracker struct {
+	mu      sync.Mutex
+	pending map[peer.ID]struct{}
+}
+
+func newRepinTracker() *repinTracker {
+	return &repinTracker{
+		pending: make(map[peer.ID]struct{}),
+	}
+}
+
+// add marks a repin as pending for the given peer. It returns false if
+// there was one already.
+func (rt *repinTracker) add(p peer.ID) bool {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if _, ok := rt.pending[p]; ok {
+		return false
+	}
+	rt.pending[p] = struct{}{}
+	return true
+}
+
+func (rt *repinTracker) done(p peer.ID) {
+	rt.mu.Lock()
+	delete(rt.pending, p)
+	rt.mu.Unlock()
+}
+
+// handlePingAlert re-allocates the pins of a peer for which a ping alert
+// was received, according to the repin configuration.
+func (c *Cluster) handlePingAlert(p peer.ID) {
+	if c.config.DisableRepinning {
+		logger.Warnf("repinning is disabled. Pins allocated to %s will not be re-allocated", p)
+		return
+	}
+
+	grace := c.config.Repin.GracePeriod
+	if grace <= 0 {
+		c.repinFromAlert(c.ctx, p)
+		return
+	}
+
+	if !c.repins.add(p) {
+		logger.Debugf("repin of pins allocated to %s already scheduled", p)
+		return
+	}
+	logger.Infof("re-allocating pins from %s in %s unless it recovers", p, grace)
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer c.repins.done(p)
+
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		if c.healthyPeers(c.ctx)[p] {
+			logger.Infof("%s recovered within the repin grace period", p)
+			return
+		}
+		c.repinFromAlert(c.ctx, p)
+	}()
+}
+
+// healthyPeers returns the peers with a valid ping metric.
+func (c *Cluster) healthyPeers(ctx context.Context) map[peer.ID]bool {
+	healthy := make(map[peer.ID]bool)
+	for _, m := range c.monitor.LatestMetrics(ctx, pingMetricName) {
+		healthy[m.Peer] = true
+	}
+	return healthy
+}
+
+// underReplicated returns true when a pin has fewer healthy allocations
+// than its minimum replication factor.
+func underReplicated(pin *api.Pin, healthy map[peer.ID]bool) bool {
+	if pin.ReplicationFactorMin < 0 {
+		return false
+	}
+	n := 0
+	for _, p := range pin.Allocations {
+		if healthy[p] {
+			n++
+		}
+	}
+	return n < pin.ReplicationFactorMin
+}
+
+// repinFromAlert re-allocates the pins allocated to the given failed peer
+// for which this peer is the closest one.
+func (c *Cluster) repinFromAlert(ctx context.Context, p peer.ID) {
+	ctx, span := trace.StartSpan(ctx, "cluster/repinFromAlert")
+	defer span.End()
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		logger.Warn(err)
+		return
+	}
+	list, err := cState.List(ctx)
+	if err != nil {
+		logger.Warn(err)
+		return
+	}
+
+	distance, err := c.distances(ctx, p)
+	if err != nil {
+		logger.Warn(err)
+		return
+	}
+
+	var healthy map[peer.ID]bool
+	if c.config.Repin.OnlyUnderReplicated {
+		healthy = c.healthyPeers(ctx)
+		delete(healthy, p)
+	}
+
+	var toRepin []*api.Pin
+	skipped := 0
+	for _, pin := range list {
+		if !containsPeer(pin.Allocations, p) || !distance.isClosest(pin.Cid) {
+			continue
+		}
+		if healthy != nil && !underReplicated(pin, healthy) {
+			skipped++
+			continue
+		}
+		toRepin = append(toRepin, pin)
+	}
+	if skipped > 0 {
+		logger.Infof("%d pins allocated to %s are not under-replicated and will not be re-allocated", skipped, p)
+	}
+	if len(toRepin) == 0 {
+		return
+	}
+
+	op := c.operations.start(c.ctx, api.OperationRepin, len(toRepin))
+	defer op.finish(nil)
+
+	concurrency := c.config.Repin.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	pins := make(chan *api.Pin)
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for pin := range pins {
+				c.repinFromPeer(op.ctx, p, pin)
+				op.progress(1)
+			}
+		}()
+	}
+
+	for _, pin := range toRepin {
+		if op.canceled() {
+			break
+		}
+		pins <- pin
+	}
+	close(pins)
+	wg.Wait()
+}
@@ -0,0 +1,52 @@
+package ipfscluster
+
+import (
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+func TestUnderReplicated(t *testing.T) {
+	healthy := map[peer.ID]bool{
+		test.PeerID1: true,
+		test.PeerID2: true,
+	}
+
+	pin := api.PinCid(test.Cid1)
+	pin.Allocations = []peer.ID{test.PeerID1, test.PeerID2, test.PeerID3}
+
+	pin.ReplicationFactorMin = 2
+	if underReplicated(pin, healthy) {
+		t.Error("pin has two healthy allocations")
+	}
+
+	pin.ReplicationFactorMin = 3
+	if !underReplicated(pin, healthy) {
+		t.Error("pin should be under-replicated")
+	}
+
+	pin.ReplicationFactorMin = -1
+	if underReplicated(pin, healthy) {
+		t.Error("pins allocated everywhere are never under-replicated")
+	}
+}
+
+func TestRepinTracker(t *testing.T) {
+	rt := newRepinTracker()
+	if !rt.add(test.PeerID1) {
+		t.Fatal("expected repin to be scheduled")
+	}
+	if rt.add(test.PeerID1) {
+		t.Error("a repin for the peer was already pending")
+	}
+	if !rt.add(test.PeerID2) {
+		t.Error("expected repin to be scheduled for another peer")
+	}
+	rt.done(test.PeerID1)
+	if !rt.add(test.PeerID1) {
+		t.Error("expected repin to be scheduled again")
+	}
+}
@@ -0,0 +1,187 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/ipfs-cluster/adder"
+	"github.com/ipfs/ipfs-cluster/adder/erasure"
+	"github.com/ipfs/ipfs-cluster/adder/sharding"
+	"github.com/ipfs/ipfs-cluster/adder/single"
+	"github.com/ipfs/ipfs-cluster/api"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	files "github.com/ipfs/go-ipfs-files"
+	ipld "github.com/ipfs/go-ipld-format"
+	merkledag "github.com/ipfs/go-merkledag"
+	unixfile "github.com/ipfs/go-unixfs/file"
+	trace "go.opencensus.io/trace"
+)
+
+// Reshard re-adds the content of an existing pin using the given add
+// parameters (i.e. a different chunker, raw-leaves or sharding) and swaps
+// the pin to the resulting root.
+//
+// The new DAG is built by reading the current one from IPFS and keeps the
+// pin options (name, replication factors, metadata...) of the original
+// pin. The original pin is only removed once the new one has been
+// committed to the shared state, so the content stays pinned at all times.
+// The new pin records the CID it replaces in its metadata, under
+// api.ReshardedFromMetaKey. Protected pins cannot be resharded, as the
+// original pin could not be removed.
+//
+// The reshard runs in the background as an operation, which is returned
+// once the request has been validated. The operation CID is the original
+// one until the reshard finishes successfully, when it becomes the CID of
+// the new pin.
+func (c *Cluster) Reshard(ctx context.Context, h cid.Cid, params *api.AddParams) (*api.Operation, error) {
+	_, span := trace.StartSpan(ctx, "cluster/Reshard")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	pin, err := c.PinGet(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	if pin.Type != api.DataType && pin.Type != api.MetaType {
+		return nil, errors.New("only data pins and sharded (meta) pins can be resharded")
+	}
+	// Resharding unpins the original CID.
+	if err := c.checkUnprotected(pin); err != nil {
+		return nil, err
+	}
+
+	newParams := *params
+	shardSize := newParams.ShardSize
+	newParams.PinOptions = pin.PinOptions
+	newParams.ShardSize = shardSize
+	newParams.Metadata = make(map[string]string, len(pin.Metadata)+1)
+	for k, v := range pin.Metadata {
+		newParams.Metadata[k] = v
+	}
+	newParams.Metadata[api.ReshardedFromMetaKey] = h.String()
+	newParams.Wrap = false
+	newParams.Format = "unixfs"
+	// Encrypted content is re-added as it is, so it stays encrypted with
+	// the same key.
+	newParams.Encrypt = ""
+	newParams.EncryptionKey = nil
+
+	var dags adder.ClusterDAGService
+	if newParams.Shard {
+		dags = sharding.New(c.rpcClient, newParams.PinOptions, nil)
+	} else if newParams.ErasureDataShards > 0 {
+		ecdags, err := erasure.New(c.rpcClient, newParams.PinOptions, newParams.ErasureDataShards, newParams.ErasureParityShards, nil)
+		if err != nil {
+			return nil, err
+		}
+		dags = ecdags
+	} else {
+		dags = single.New(c.rpcClient, newParams.PinOptions, newParams.Local)
+	}
+
+	op := c.operations.start(ctx, api.OperationReshard, 0)
+	op.setCid(h)
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		newPin, err := c.reshard(op.ctx, pin, dags, &newParams)
+		if err == nil {
+			op.setCid(newPin.Cid)
+		}
+		op.finish(err)
+	}()
+	return op.info(), nil
+}
+
+func (c *Cluster) reshard(ctx context.Context, pin *api.Pin, dags adder.ClusterDAGService, params *api.AddParams) (*api.Pin, error) {
+	h := pin.Cid
+	dserv := merkledag.NewReadOnlyDagService(&blockGetter{ipfs: c.ipfs})
+	root, err := dserv.Get(ctx, h)
+	if err != nil {
+		logger.Errorf("error reading %s from ipfs for resharding: %s", h, err)
+		return nil, fmt.Errorf("error reading %s from ipfs: %w", h, err)
+	}
+	node, err := unixfile.NewUnixfsFile(ctx, dserv, root)
+	if err != nil {
+		logger.Errorf("error reading unixfs content of %s for resharding: %s", h, err)
+		return nil, fmt.Errorf("error reading unixfs content: %w", err)
+	}
+	defer node.Close()
+
+	add := adder.New(dags, params, nil)
+	dir := files.NewSliceDirectory([]files.DirEntry{files.FileEntry("", node)})
+	newRoot, err := add.FromFiles(ctx, dir)
+	if err != nil {
+		logger.Errorf("error re-adding %s: %s", h, err)
+		return nil, fmt.Errorf("error re-adding %s: %w", h, err)
+	}
+
+	if newRoot.Equals(h) {
+		// Nothing changed, but the pin has been updated with the new
+		// metadata. Put the original metadata back.
+		logger.Infof("resharding %s produced the same root", h)
+		newPin, err := c.PinGet(ctx, h)
+		if err != nil {
+			return nil, err
+		}
+		newPin.Metadata = pin.Metadata
+		return newPin, c.logPin(ctx, newPin)
+	}
+
+	// The original pin may have been protected while resharding. Do not
+	// keep both copies in that case.
+	current, err := c.PinGet(ctx, h)
+	if err == nil {
+		if err := c.checkUnprotected(current); err != nil {
+			logger.Errorf("%s was protected while resharding: removing the new pin %s", h, newRoot)
+			if _, uerr := c.Unpin(ctx, newRoot); uerr != nil {
+				logger.Errorf("error unpinning %s: %s", newRoot, uerr)
+			}
+			return nil, err
+		}
+	}
+
+	if _, err := c.Unpin(ctx, h); err != nil {
+		logger.Errorf("%s was resharded as %s but unpinning it failed: %s", h, newRoot, err)
+		return nil, fmt.Errorf("error unpinning %s after resharding it as %s: %w", h, newRoot, err)
+	}
+	logger.Infof("%s resharded as %s", h, newRoot)
+	return c.PinGet(ctx, newRoot)
+}
+
+// blockGetter is an ipld.NodeGetter which reads blocks from the IPFS
+// daemon.
+type blockGetter struct {
+	ipfs IPFSConnector
+}
+
+func (bg *blockGetter) Get(ctx context.Context, c cid.Cid) (ipld.Node, error) {
+	data, err := bg.ipfs.BlockGet(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	b, err := blocks.NewBlockWithCid(data, c)
+	if err != nil {
+		return nil, err
+	}
+	return ipld.Decode(b)
+}
+
+func (bg *blockGetter) GetMany(ctx context.Context, cids []cid.Cid) <-chan *ipld.NodeOption {
+	out := make(chan *ipld.NodeOption, len(cids))
+	go func() {
+		defer close(out)
+		for _, c := range cids {
+			nd, err := bg.Get(ctx, c)
+			select {
+			case out <- &ipld.NodeOption{Node: nd, Err: err}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
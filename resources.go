@@ -0,0 +1,83 @@
+package ipfscluster
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	ds "github.com/ipfs/go-datastore"
+	"go.opencensus.io/trace"
+)
+
+// Resources returns the resources used by the cluster peer addressed by
+// name, as resolved by ResolvePeer, and by its IPFS daemon.
+func (c *Cluster) Resources(ctx context.Context, name string) (*api.PeerResources, error) {
+	_, span := trace.StartSpan(ctx, "cluster/Resources")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	pid, err := c.ResolvePeer(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if pid == c.id {
+		return c.ResourcesLocal(ctx), nil
+	}
+
+	var res api.PeerResources
+	err = c.rpcClient.CallContext(
+		ctx,
+		pid,
+		"Cluster",
+		"ResourcesLocal",
+		struct{}{},
+		&res,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ResourcesLocal returns the resources used by this peer and by its IPFS
+// daemon.
+func (c *Cluster) ResourcesLocal(ctx context.Context) *api.PeerResources {
+	ctx, span := trace.StartSpan(ctx, "cluster/ResourcesLocal")
+	defer span.End()
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	res := &api.PeerResources{
+		Peer:       c.id,
+		Peername:   c.config.Peername,
+		MemorySys:  mem.Sys,
+		MemoryHeap: mem.HeapAlloc,
+		Goroutines: runtime.NumGoroutine(),
+		NumCPU:     runtime.NumCPU(),
+		CPUTime:    processCPUTime(),
+	}
+
+	if c.bandwidth != nil {
+		stats := c.bandwidth.GetBandwidthTotals()
+		res.BandwidthTotalIn = stats.TotalIn
+		res.BandwidthTotalOut = stats.TotalOut
+		res.BandwidthRateIn = stats.RateIn
+		res.BandwidthRateOut = stats.RateOut
+	}
+
+	size, err := ds.DiskUsage(ctx, c.datastore)
+	if err != nil {
+		logger.Errorf("error reading the size of the datastore: %s", err)
+	}
+	res.DatastoreSize = size
+
+	repoStat, err := c.ipfs.RepoStat(ctx)
+	if err != nil {
+		res.IPFSError = err.Error()
+	} else {
+		res.IPFSRepoStat = repoStat
+	}
+	return res
+}
@@ -0,0 +1,48 @@
+package ipfscluster
+
+import (
+	"context"
+	"testing"
+)
+
+func TestClusterResources(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	res, err := cl.Resources(ctx, cl.config.Peername)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Peer != cl.id || res.Peername != cl.config.Peername {
+		t.Errorf("expected the resources of the peer: %+v", res)
+	}
+	if res.MemorySys == 0 || res.Goroutines == 0 || res.NumCPU == 0 {
+		t.Errorf("expected the usage of the process: %+v", res)
+	}
+	if res.IPFSError != "" || res.IPFSRepoStat == nil {
+		t.Errorf("expected the repo stat of IPFS: %+v", res)
+	}
+
+	if _, err := cl.Resources(ctx, "nobody"); err == nil {
+		t.Error("expected an error with an unknown peer")
+	}
+}
+
+func TestClustersResources(t *testing.T) {
+	ctx := context.Background()
+	clusters, mock := createClusters(t)
+	defer shutdownClusters(t, clusters, mock)
+
+	res, err := clusters[0].Resources(ctx, clusters[1].id.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Peer != clusters[1].id {
+		t.Error("expected the resources of the remote peer:", res.Peer)
+	}
+	if res.BandwidthTotalIn == 0 || res.BandwidthTotalOut == 0 {
+		t.Errorf("expected the traffic of the remote peer: %+v", res)
+	}
+}
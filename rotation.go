@@ -0,0 +1,227 @@
+package ipfscluster
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	"go.opencensus.io/trace"
+)
+
+// Phases of a cluster secret rotation. All peers first stage the new
+// secret, accepting it along with the current one. Once every peer accepts
+// it, they switch to it and, after the transition window, stop accepting
+// the previous one. A failure while staging aborts the rotation.
+const (
+	secretPhaseStage  = "stage"
+	secretPhaseSwitch = "switch"
+	secretPhaseAbort  = "abort"
+)
+
+var errNoClusterSecret = errors.New("this peer does not use a cluster secret")
+
+// RotateSecret replaces the cluster secret in all the peers of the cluster
+// without restarting them. The given hex-encoded secret is used, or a new
+// one is generated when empty. Peers keep accepting connections protected
+// with the previous secret during the secret_transition_window configured
+// in each of them. Peers which are not reachable while rotating (and
+// followers) need to have the new secret set in their configuration.
+func (c *Cluster) RotateSecret(ctx context.Context, secret string) (*api.SecretRotation, error) {
+	_, span := trace.StartSpan(ctx, "cluster/RotateSecret")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	if c.secrets == nil {
+		return nil, errNoClusterSecret
+	}
+
+	var psk []byte
+	var err error
+	if secret == "" {
+		psk = make([]byte, 32)
+		_, err = rand.Read(psk)
+	} else {
+		psk, err = DecodeClusterSecret(secret)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(psk) == 0 {
+		return nil, errors.New("the new cluster secret cannot be empty")
+	}
+	secret = EncodeProtectorKey(psk)
+
+	members, err := c.consensus.Peers(ctx)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+
+	_, failed := c.rotateSecretPhase(ctx, members, secretPhaseStage, secret)
+	if len(failed) > 0 {
+		c.rotateSecretPhase(ctx, members, secretPhaseAbort, secret)
+		return nil, fmt.Errorf("secret rotation aborted: %d peers could not accept the new secret", len(failed))
+	}
+
+	switched, failed := c.rotateSecretPhase(ctx, members, secretPhaseSwitch, secret)
+	if len(failed) > 0 {
+		logger.Warnf("%d peers did not switch to the new cluster secret. Their configuration should be updated manually", len(failed))
+	}
+	return &api.SecretRotation{
+		Secret: secret,
+		Peers:  switched,
+		Failed: failed,
+	}, nil
+}
+
+// rotateSecretPhase asks the given peers to perform a phase of a secret
+// rotation and returns those which succeeded and those which failed.
+func (c *Cluster) rotateSecretPhase(ctx context.Context, members []peer.ID, phase, secret string) ([]peer.ID, []peer.ID) {
+	results := c.broadcast(
+		ctx,
+		members,
+		"Cluster",
+		"RotateSecretLocal",
+		api.SecretRotationStep{Phase: phase, Secret: secret},
+		func() interface{} { return &struct{}{} },
+	)
+
+	var ok, failed []peer.ID
+	for res := range results {
+		if res.Err != nil {
+			logger.Errorf("%s: error in secret rotation (%s) from %s: %s", c.id, phase, res.Peer, res.Err)
+			failed = append(failed, res.Peer)
+			continue
+		}
+		ok = append(ok, res.Peer)
+	}
+	return ok, failed
+}
+
+// RotateSecretLocal performs a phase of a cluster secret rotation in this
+// peer. When switching to the new secret, it is saved to the configuration
+// and the previous one is accepted until the secret_transition_window
+// expires.
+func (c *Cluster) RotateSecretLocal(ctx context.Context, step api.SecretRotationStep) error {
+	_, span := trace.StartSpan(ctx, "cluster/RotateSecretLocal")
+	defer span.End()
+
+	ring := c.secrets
+	if ring == nil {
+		return errNoClusterSecret
+	}
+
+	psk, err := DecodeClusterSecret(step.Secret)
+	if err != nil {
+		return err
+	}
+	if len(psk) == 0 {
+		return errors.New("the new cluster secret cannot be empty")
+	}
+
+	switch step.Phase {
+	case secretPhaseStage:
+		ring.accept(psk)
+	case secretPhaseAbort:
+		ring.forget(psk)
+	case secretPhaseSwitch:
+		previous := ring.sendKey()
+		ring.use(psk)
+		c.config.Secret = psk
+		c.config.NotifySave()
+		logger.Infof("switched to a new cluster secret. The previous one is accepted for %s", c.config.SecretTransitionWindow)
+
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			timer := time.NewTimer(c.config.SecretTransitionWindow)
+			defer timer.Stop()
+			select {
+			case <-c.ctx.Done():
+			case <-timer.C:
+				ring.forget(previous)
+				logger.Info("the previous cluster secret is no longer accepted")
+			}
+		}()
+	default:
+		return fmt.Errorf("unknown secret rotation phase: %s", step.Phase)
+	}
+	return nil
+}
+
+// PeerHandover transfers the allocations of a peer to a different one,
+// which must be part of the cluster. It is meant to be used after a peer
+// rotates its identity, so that the peer keeps the content allocated to it
+// under its previous ID, instead of having it re-allocated elsewhere once
+// the previous ID is gone.
+func (c *Cluster) PeerHandover(ctx context.Context, from, to peer.ID) (*api.PeerHandover, error) {
+	_, span := trace.StartSpan(ctx, "cluster/PeerHandover")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	if c.config.FollowerMode {
+		return nil, errFollowerMode
+	}
+	if from == to {
+		return nil, errors.New("cannot hand over the allocations of a peer to itself")
+	}
+
+	members, err := c.consensus.Peers(ctx)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+	if !containsPeer(members, to) {
+		return nil, fmt.Errorf("%s is not a cluster peer", to)
+	}
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pins, err := cState.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	handover := &api.PeerHandover{From: from, To: to}
+	op := c.operations.start(ctx, api.OperationPeerHandover, len(pins))
+	for _, p := range pins {
+		if op.canceled() {
+			break
+		}
+		op.progress(1)
+		if !containsPeer(p.Allocations, from) {
+			continue
+		}
+
+		newPin := *p
+		newPin.Allocations = make([]peer.ID, 0, len(p.Allocations))
+		for _, alloc := range p.Allocations {
+			switch {
+			case alloc != from:
+				newPin.Allocations = append(newPin.Allocations, alloc)
+			case !containsPeer(p.Allocations, to):
+				newPin.Allocations = append(newPin.Allocations, to)
+			}
+		}
+		newPin.Timestamp = time.Now()
+		if err := c.logPin(op.ctx, &newPin); err != nil {
+			op.finish(err)
+			return handover, err
+		}
+		handover.Pins++
+	}
+	canceled := op.canceled()
+	op.finish(nil)
+	if canceled {
+		return handover, op.ctx.Err()
+	}
+	logger.Infof("handed over %d pins from %s to %s", handover.Pins, from, to)
+	return handover, nil
+}
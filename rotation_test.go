@@ -0,0 +1,187 @@
+package ipfscluster
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+
+	libp2p "github.com/libp2p/go-libp2p"
+	crypto "github.com/libp2p/go-libp2p-core/crypto"
+	host "github.com/libp2p/go-libp2p-core/host"
+	network "github.com/libp2p/go-libp2p-core/network"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func newTestSecret(t *testing.T) []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		t.Fatal(err)
+	}
+	return secret
+}
+
+// reconnect closes the connections between two hosts and dials again.
+func reconnect(ctx context.Context, from, to host.Host) error {
+	from.Network().ClosePeer(to.ID())
+	to.Network().ClosePeer(from.ID())
+	ctx = network.WithForceDirectDial(ctx, "testing")
+	return from.Connect(ctx, peer.AddrInfo{ID: to.ID(), Addrs: to.Addrs()})
+}
+
+func TestSecretRing(t *testing.T) {
+	old := newTestSecret(t)
+	new := newTestSecret(t)
+	ring := newSecretRing(old)
+
+	ring.accept(new)
+	ring.accept(new)
+	if len(ring.acceptedKeys()) != 2 || !bytes.Equal(ring.sendKey(), old) {
+		t.Fatal("expected both secrets to be accepted and the old one in use")
+	}
+
+	ring.use(new)
+	ring.forget(new)
+	if !bytes.Equal(ring.sendKey(), new) || len(ring.acceptedKeys()) != 2 {
+		t.Fatal("the secret in use should not be forgotten")
+	}
+
+	ring.forget(old)
+	keys := ring.acceptedKeys()
+	if len(keys) != 1 || !bytes.Equal(keys[0], new) {
+		t.Error("expected only the new secret to be accepted")
+	}
+}
+
+func TestSecretRingConnections(t *testing.T) {
+	ctx := context.Background()
+	old := newTestSecret(t)
+	new := newTestSecret(t)
+	listen, _ := ma.NewMultiaddr("/ip4/127.0.0.1/tcp/0")
+
+	hosts := make([]host.Host, 2)
+	for i, secret := range [][]byte{old, new} {
+		priv, _, err := crypto.GenerateKeyPair(crypto.Ed25519, 256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, err := newHost(ctx, secret, priv, libp2p.ListenAddrs(listen))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer h.Close()
+		hosts[i] = h
+	}
+	ring0 := hosts[0].(*clusterHost).secrets
+	ring1 := hosts[1].(*clusterHost).secrets
+
+	if err := reconnect(ctx, hosts[0], hosts[1]); err == nil {
+		t.Fatal("hosts with different secrets should not connect")
+	}
+
+	// The second host accepts the old secret and replies with it.
+	ring1.accept(old)
+	if err := reconnect(ctx, hosts[0], hosts[1]); err != nil {
+		t.Fatal("expected the old secret to be accepted:", err)
+	}
+
+	// The first host switches while the second one still accepts both.
+	ring0.use(new)
+	if err := reconnect(ctx, hosts[0], hosts[1]); err != nil {
+		t.Fatal("expected the new secret to be accepted:", err)
+	}
+	if err := reconnect(ctx, hosts[1], hosts[0]); err != nil {
+		t.Fatal("expected the new secret to be accepted:", err)
+	}
+
+	ring0.forget(old)
+	ring1.use(new)
+	ring1.forget(old)
+	if err := reconnect(ctx, hosts[1], hosts[0]); err != nil {
+		t.Fatal("expected the hosts to connect with the new secret:", err)
+	}
+}
+
+func TestClustersRotateSecret(t *testing.T) {
+	ctx := context.Background()
+	clusters, mock := createClusters(t)
+	defer shutdownClusters(t, clusters, mock)
+	waitForLeaderAndMetrics(t, clusters)
+
+	if _, err := clusters[0].RotateSecret(ctx, "abc"); err == nil {
+		t.Error("expected an error with an invalid secret")
+	}
+
+	rot, err := clusters[0].RotateSecret(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rot.Peers) != nClusters || len(rot.Failed) != 0 {
+		t.Fatalf("expected all peers to switch: %+v", rot)
+	}
+
+	secret, err := DecodeClusterSecret(rot.Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range clusters {
+		if !bytes.Equal(c.config.Secret, secret) {
+			t.Errorf("%s: expected the new secret in the configuration", c.id)
+		}
+		ring := c.secrets
+		if !bytes.Equal(ring.sendKey(), secret) || len(ring.acceptedKeys()) != 2 {
+			t.Errorf("%s: expected the new secret in use and the old one accepted", c.id)
+		}
+	}
+
+	if err := reconnect(ctx, clusters[0].host, clusters[1].host); err != nil {
+		t.Fatal("expected the peers to connect with the new secret:", err)
+	}
+}
+
+func TestClustersPeerHandover(t *testing.T) {
+	ctx := context.Background()
+	clusters, mock := createClusters(t)
+	defer shutdownClusters(t, clusters, mock)
+	waitForLeaderAndMetrics(t, clusters)
+
+	from := clusters[1].id
+	to := clusters[0].id
+	opts := api.PinOptions{
+		ReplicationFactorMin: 1,
+		ReplicationFactorMax: 1,
+		UserAllocations:      []peer.ID{from},
+	}
+	if _, err := clusters[0].Pin(ctx, test.Cid1, opts); err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	if _, err := clusters[0].PeerHandover(ctx, from, from); err == nil {
+		t.Error("expected an error handing over to the same peer")
+	}
+	if _, err := clusters[0].PeerHandover(ctx, from, test.PeerID1); err == nil {
+		t.Error("expected an error handing over to an unknown peer")
+	}
+
+	handover, err := clusters[0].PeerHandover(ctx, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if handover.Pins != 1 {
+		t.Error("expected one pin to be handed over:", handover.Pins)
+	}
+	pinDelay()
+
+	pin, err := clusters[1].PinGet(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pin.Allocations) != 1 || pin.Allocations[0] != to {
+		t.Error("expected the pin to be allocated to the new peer:", pin.Allocations)
+	}
+}
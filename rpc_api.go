@@ -2,8 +2,11 @@ package ipfscluster
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/observations"
 	"github.com/ipfs/ipfs-cluster/state"
 	"github.com/ipfs/ipfs-cluster/version"
 
@@ -32,6 +35,36 @@ const (
 // RPCEndpointType controls how access is granted to an RPC endpoint
 type RPCEndpointType int
 
+// String returns the name of the endpoint type: "closed", "trusted" or
+// "open".
+func (t RPCEndpointType) String() string {
+	switch t {
+	case RPCClosed:
+		return "closed"
+	case RPCTrusted:
+		return "trusted"
+	case RPCOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// RPCEndpointTypeFromString parses the name of an endpoint type as returned
+// by String().
+func RPCEndpointTypeFromString(s string) (RPCEndpointType, error) {
+	switch s {
+	case "closed":
+		return RPCClosed, nil
+	case "trusted":
+		return RPCTrusted, nil
+	case "open":
+		return RPCOpen, nil
+	default:
+		return RPCClosed, fmt.Errorf("unknown RPC endpoint type %q", s)
+	}
+}
+
 // A trick to find where something is used (i.e. Cluster.Pin):
 // grep -R -B 3 '"Pin"' | grep -C 1 '"Cluster"'.
 // This does not cover globalPinInfo*(...) broadcasts nor redirects to leader
@@ -42,7 +75,9 @@ func newRPCServer(c *Cluster) (*rpc.Server, error) {
 	var s *rpc.Server
 
 	authF := func(pid peer.ID, svc, method string) bool {
-		endpointType, ok := c.config.RPCPolicy[svc+"."+method]
+		c.rpcPolicyMux.RLock()
+		endpointType, ok := c.rpcPolicy[svc+"."+method]
+		c.rpcPolicyMux.RUnlock()
 		if !ok {
 			return false
 		}
@@ -57,16 +92,16 @@ func newRPCServer(c *Cluster) (*rpc.Server, error) {
 		}
 	}
 
+	statsHandler := &observations.RPCStatsHandler{}
 	if c.config.Tracing {
-		s = rpc.NewServer(
-			c.host,
-			version.RPCProtocol,
-			rpc.WithServerStatsHandler(&ocgorpc.ServerHandler{}),
-			rpc.WithAuthorizeFunc(authF),
-		)
-	} else {
-		s = rpc.NewServer(c.host, version.RPCProtocol, rpc.WithAuthorizeFunc(authF))
+		statsHandler.Next = &ocgorpc.ServerHandler{}
 	}
+	s = rpc.NewServer(
+		c.host,
+		version.RPCProtocol,
+		rpc.WithServerStatsHandler(statsHandler),
+		rpc.WithAuthorizeFunc(authF),
+	)
 
 	cl := &ClusterRPCAPI{c}
 	err := s.RegisterName(RPCServiceID(cl), cl)
@@ -126,6 +161,12 @@ type PinTrackerRPCAPI struct {
 	tracker PinTracker
 }
 
+// NewPinTrackerRPCAPI returns the RPC service for the given PinTracker. It
+// allows to serve a tracker from a process other than the cluster peer.
+func NewPinTrackerRPCAPI(tracker PinTracker) *PinTrackerRPCAPI {
+	return &PinTrackerRPCAPI{tracker}
+}
+
 // IPFSConnectorRPCAPI is a go-libp2p-gorpc service which provides the
 // internal peer API for the IPFSConnector component.
 type IPFSConnectorRPCAPI struct {
@@ -160,10 +201,27 @@ func (rpcapi *ClusterRPCAPI) Pin(ctx context.Context, in *api.Pin, out *api.Pin)
 	// we do not call the Pin method directly since that method does not
 	// allow to pin other than regular DataType pins. The adder will
 	// however send Meta, Shard and ClusterDAG pins.
+	if err := rpcapi.c.validatePin(ctx, in); err != nil {
+		return err
+	}
+	warm := rpcapi.c.wantsGatewayWarmup(ctx, in)
 	pin, _, err := rpcapi.c.pin(ctx, in, []peer.ID{})
 	if err != nil {
 		return err
 	}
+	if warm {
+		rpcapi.c.warmGateways(ctx, pin)
+	}
+	*out = *pin
+	return nil
+}
+
+// PinDryRun runs Cluster.PinDryRun().
+func (rpcapi *ClusterRPCAPI) PinDryRun(ctx context.Context, in *api.Pin, out *api.Pin) error {
+	pin, err := rpcapi.c.PinDryRun(ctx, in.Cid, in.PinOptions)
+	if err != nil {
+		return err
+	}
 	*out = *pin
 	return nil
 }
@@ -178,6 +236,26 @@ func (rpcapi *ClusterRPCAPI) Unpin(ctx context.Context, in *api.Pin, out *api.Pi
 	return nil
 }
 
+// Unprotect runs Cluster.Unprotect().
+func (rpcapi *ClusterRPCAPI) Unprotect(ctx context.Context, in cid.Cid, out *api.Pin) error {
+	pin, err := rpcapi.c.Unprotect(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = *pin
+	return nil
+}
+
+// Restore runs Cluster.Restore().
+func (rpcapi *ClusterRPCAPI) Restore(ctx context.Context, in cid.Cid, out *api.Pin) error {
+	pin, err := rpcapi.c.Restore(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = *pin
+	return nil
+}
+
 // PinPath resolves path into a cid and runs Cluster.Pin().
 func (rpcapi *ClusterRPCAPI) PinPath(ctx context.Context, in *api.PinPath, out *api.Pin) error {
 	pin, err := rpcapi.c.PinPath(ctx, in.Path, in.PinOptions)
@@ -208,6 +286,214 @@ func (rpcapi *ClusterRPCAPI) Pins(ctx context.Context, in struct{}, out *[]*api.
 	return nil
 }
 
+// PinChanges runs Cluster.PinChanges().
+func (rpcapi *ClusterRPCAPI) PinChanges(ctx context.Context, in uint64, out *api.PinChanges) error {
+	changes, err := rpcapi.c.PinChanges(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = *changes
+	return nil
+}
+
+// LifecycleEvents runs Cluster.LifecycleEvents().
+func (rpcapi *ClusterRPCAPI) LifecycleEvents(ctx context.Context, in uint64, out *api.LifecycleEvents) error {
+	*out = *rpcapi.c.LifecycleEvents(ctx, in)
+	return nil
+}
+
+// ConsistencyCheck runs Cluster.ConsistencyCheck().
+func (rpcapi *ClusterRPCAPI) ConsistencyCheck(ctx context.Context, in bool, out *api.GlobalConsistencyReport) error {
+	res, err := rpcapi.c.ConsistencyCheck(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = *res
+	return nil
+}
+
+// ConsistencyCheckLocal runs Cluster.ConsistencyCheckLocal().
+func (rpcapi *ClusterRPCAPI) ConsistencyCheckLocal(ctx context.Context, in bool, out *api.ConsistencyReport) error {
+	res, err := rpcapi.c.ConsistencyCheckLocal(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = *res
+	return nil
+}
+
+// PinTemplate runs Cluster.PinTemplate().
+func (rpcapi *ClusterRPCAPI) PinTemplate(ctx context.Context, in string, out *api.PinTemplate) error {
+	tmpl, err := rpcapi.c.PinTemplate(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = *tmpl
+	return nil
+}
+
+// PinTemplates runs Cluster.PinTemplates().
+func (rpcapi *ClusterRPCAPI) PinTemplates(ctx context.Context, in struct{}, out *[]*api.PinTemplate) error {
+	tmpls, err := rpcapi.c.PinTemplates(ctx)
+	if err != nil {
+		return err
+	}
+	*out = tmpls
+	return nil
+}
+
+// PinTemplateSet runs Cluster.PinTemplateSet().
+func (rpcapi *ClusterRPCAPI) PinTemplateSet(ctx context.Context, in *api.PinTemplate, out *struct{}) error {
+	return rpcapi.c.PinTemplateSet(ctx, in)
+}
+
+// PinTemplateRemove runs Cluster.PinTemplateRemove().
+func (rpcapi *ClusterRPCAPI) PinTemplateRemove(ctx context.Context, in string, out *struct{}) error {
+	return rpcapi.c.PinTemplateRemove(ctx, in)
+}
+
+// PinsetSnapshotCreate runs Cluster.PinsetSnapshotCreate().
+func (rpcapi *ClusterRPCAPI) PinsetSnapshotCreate(ctx context.Context, in string, out *api.PinsetSnapshot) error {
+	snap, err := rpcapi.c.PinsetSnapshotCreate(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = *snap
+	return nil
+}
+
+// PinsetSnapshot runs Cluster.PinsetSnapshot().
+func (rpcapi *ClusterRPCAPI) PinsetSnapshot(ctx context.Context, in string, out *api.PinsetSnapshot) error {
+	snap, err := rpcapi.c.PinsetSnapshot(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = *snap
+	return nil
+}
+
+// PinsetSnapshots runs Cluster.PinsetSnapshots().
+func (rpcapi *ClusterRPCAPI) PinsetSnapshots(ctx context.Context, in struct{}, out *[]*api.PinsetSnapshot) error {
+	snaps, err := rpcapi.c.PinsetSnapshots(ctx)
+	if err != nil {
+		return err
+	}
+	*out = snaps
+	return nil
+}
+
+// PinsetSnapshotRemove runs Cluster.PinsetSnapshotRemove().
+func (rpcapi *ClusterRPCAPI) PinsetSnapshotRemove(ctx context.Context, in string, out *struct{}) error {
+	return rpcapi.c.PinsetSnapshotRemove(ctx, in)
+}
+
+// PinsetRollback runs Cluster.PinsetRollback() with the snapshot and the
+// dry-run flag of the given PinsetRollback.
+func (rpcapi *ClusterRPCAPI) PinsetRollback(ctx context.Context, in api.PinsetRollback, out *api.PinsetRollback) error {
+	res, err := rpcapi.c.PinsetRollback(ctx, in.Snapshot, in.DryRun)
+	if err != nil {
+		return err
+	}
+	*out = *res
+	return nil
+}
+
+// DenylistEntries runs Cluster.DenylistEntries().
+func (rpcapi *ClusterRPCAPI) DenylistEntries(ctx context.Context, in struct{}, out *[]*api.DenylistEntry) error {
+	entries, err := rpcapi.c.DenylistEntries(ctx)
+	if err != nil {
+		return err
+	}
+	*out = entries
+	return nil
+}
+
+// DenylistAdd runs Cluster.DenylistAdd().
+func (rpcapi *ClusterRPCAPI) DenylistAdd(ctx context.Context, in []*api.DenylistEntry, out *struct{}) error {
+	return rpcapi.c.DenylistAdd(ctx, in)
+}
+
+// DenylistRemove runs Cluster.DenylistRemove().
+func (rpcapi *ClusterRPCAPI) DenylistRemove(ctx context.Context, in string, out *struct{}) error {
+	return rpcapi.c.DenylistRemove(ctx, in)
+}
+
+// DenylistCheck returns an error when the given CID is denylisted in this
+// peer.
+func (rpcapi *ClusterRPCAPI) DenylistCheck(ctx context.Context, in cid.Cid, out *struct{}) error {
+	return rpcapi.c.checkDenylist(in.String())
+}
+
+// DenylistEnforce runs Cluster.DenylistEnforce().
+func (rpcapi *ClusterRPCAPI) DenylistEnforce(ctx context.Context, in struct{}, out *[]*api.Pin) error {
+	pins, err := rpcapi.c.DenylistEnforce(ctx)
+	if err != nil {
+		return err
+	}
+	*out = pins
+	return nil
+}
+
+// UnpinMatching runs Cluster.UnpinMatching().
+func (rpcapi *ClusterRPCAPI) UnpinMatching(ctx context.Context, in api.PinSelector, out *[]*api.Pin) error {
+	pins, err := rpcapi.c.UnpinMatching(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = pins
+	return nil
+}
+
+// RecoverMatching runs Cluster.RecoverMatching().
+func (rpcapi *ClusterRPCAPI) RecoverMatching(ctx context.Context, in api.PinSelector, out *[]*api.GlobalPinInfo) error {
+	infos, err := rpcapi.c.RecoverMatching(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = infos
+	return nil
+}
+
+// ReplicateMatching runs Cluster.ReplicateMatching().
+func (rpcapi *ClusterRPCAPI) ReplicateMatching(ctx context.Context, in api.BulkReplication, out *[]*api.Pin) error {
+	pins, err := rpcapi.c.ReplicateMatching(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = pins
+	return nil
+}
+
+// PinsByType runs Cluster.PinsByType().
+func (rpcapi *ClusterRPCAPI) PinsByType(ctx context.Context, in api.PinType, out *[]*api.Pin) error {
+	pins, err := rpcapi.c.PinsByType(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = pins
+	return nil
+}
+
+// PinsByName runs Cluster.PinsByName() for an exact name.
+func (rpcapi *ClusterRPCAPI) PinsByName(ctx context.Context, in string, out *[]*api.Pin) error {
+	pins, err := rpcapi.c.PinsByName(ctx, in, false)
+	if err != nil {
+		return err
+	}
+	*out = pins
+	return nil
+}
+
+// PinsByNamePrefix runs Cluster.PinsByName() for a name prefix.
+func (rpcapi *ClusterRPCAPI) PinsByNamePrefix(ctx context.Context, in string, out *[]*api.Pin) error {
+	pins, err := rpcapi.c.PinsByName(ctx, in, true)
+	if err != nil {
+		return err
+	}
+	*out = pins
+	return nil
+}
+
 // PinGet runs Cluster.PinGet().
 func (rpcapi *ClusterRPCAPI) PinGet(ctx context.Context, in cid.Cid, out *api.Pin) error {
 	pin, err := rpcapi.c.PinGet(ctx, in)
@@ -242,6 +528,73 @@ func (rpcapi *ClusterRPCAPI) PeerAdd(ctx context.Context, in peer.ID, out *api.I
 	return nil
 }
 
+// ConnectivityHistory runs Cluster.ConnectivityHistory().
+func (rpcapi *ClusterRPCAPI) ConnectivityHistory(ctx context.Context, in time.Time, out *[]*api.ConnectivitySnapshot) error {
+	*out = rpcapi.c.ConnectivityHistory(ctx, in)
+	return nil
+}
+
+// ConsensusStats runs Cluster.ConsensusStats().
+func (rpcapi *ClusterRPCAPI) ConsensusStats(ctx context.Context, in struct{}, out *[]*api.ConsensusStats) error {
+	stats, err := rpcapi.c.ConsensusStats(ctx)
+	if err != nil {
+		return err
+	}
+	*out = stats
+	return nil
+}
+
+// Resources runs Cluster.Resources().
+func (rpcapi *ClusterRPCAPI) Resources(ctx context.Context, in string, out *api.PeerResources) error {
+	res, err := rpcapi.c.Resources(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = *res
+	return nil
+}
+
+// ResourcesLocal runs Cluster.ResourcesLocal().
+func (rpcapi *ClusterRPCAPI) ResourcesLocal(ctx context.Context, in struct{}, out *api.PeerResources) error {
+	*out = *rpcapi.c.ResourcesLocal(ctx)
+	return nil
+}
+
+// RotateSecret runs Cluster.RotateSecret().
+func (rpcapi *ClusterRPCAPI) RotateSecret(ctx context.Context, in string, out *api.SecretRotation) error {
+	rot, err := rpcapi.c.RotateSecret(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = *rot
+	return nil
+}
+
+// RotateSecretLocal runs Cluster.RotateSecretLocal().
+func (rpcapi *ClusterRPCAPI) RotateSecretLocal(ctx context.Context, in api.SecretRotationStep, out *struct{}) error {
+	return rpcapi.c.RotateSecretLocal(ctx, in)
+}
+
+// PeerHandover runs Cluster.PeerHandover().
+func (rpcapi *ClusterRPCAPI) PeerHandover(ctx context.Context, in api.PeerHandover, out *api.PeerHandover) error {
+	handover, err := rpcapi.c.PeerHandover(ctx, in.From, in.To)
+	if err != nil {
+		return err
+	}
+	*out = *handover
+	return nil
+}
+
+// Connections runs Cluster.Connections().
+func (rpcapi *ClusterRPCAPI) Connections(ctx context.Context, in struct{}, out *[]*api.ConnectionInfo) error {
+	conns, err := rpcapi.c.Connections(ctx)
+	if err != nil {
+		return err
+	}
+	*out = conns
+	return nil
+}
+
 // ConnectGraph runs Cluster.GetConnectGraph().
 func (rpcapi *ClusterRPCAPI) ConnectGraph(ctx context.Context, in struct{}, out *api.ConnectGraph) error {
 	graph, err := rpcapi.c.ConnectGraph()
@@ -257,6 +610,32 @@ func (rpcapi *ClusterRPCAPI) PeerRemove(ctx context.Context, in peer.ID, out *st
 	return rpcapi.c.PeerRemove(ctx, in)
 }
 
+// ResolvePeer runs Cluster.ResolvePeer().
+func (rpcapi *ClusterRPCAPI) ResolvePeer(ctx context.Context, in string, out *peer.ID) error {
+	pid, err := rpcapi.c.ResolvePeer(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = pid
+	return nil
+}
+
+// Peer runs Cluster.Peer().
+func (rpcapi *ClusterRPCAPI) Peer(ctx context.Context, in string, out *api.ID) error {
+	id, err := rpcapi.c.Peer(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = *id
+	return nil
+}
+
+// PeerVersions runs Cluster.PeerVersions().
+func (rpcapi *ClusterRPCAPI) PeerVersions(ctx context.Context, in struct{}, out *api.PeerVersions) error {
+	*out = *rpcapi.c.PeerVersions(ctx)
+	return nil
+}
+
 // Join runs Cluster.Join().
 func (rpcapi *ClusterRPCAPI) Join(ctx context.Context, in api.Multiaddr, out *struct{}) error {
 	return rpcapi.c.Join(ctx, in.Value())
@@ -296,6 +675,22 @@ func (rpcapi *ClusterRPCAPI) StatusLocal(ctx context.Context, in cid.Cid, out *a
 	return nil
 }
 
+// StatusCids runs Cluster.StatusCids().
+func (rpcapi *ClusterRPCAPI) StatusCids(ctx context.Context, in []cid.Cid, out *[]*api.GlobalPinInfo) error {
+	pinfos, err := rpcapi.c.StatusCids(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = pinfos
+	return nil
+}
+
+// StatusCidsLocal runs Cluster.StatusCidsLocal().
+func (rpcapi *ClusterRPCAPI) StatusCidsLocal(ctx context.Context, in []cid.Cid, out *[]*api.PinInfo) error {
+	*out = rpcapi.c.StatusCidsLocal(ctx, in)
+	return nil
+}
+
 // RecoverAll runs Cluster.RecoverAll().
 func (rpcapi *ClusterRPCAPI) RecoverAll(ctx context.Context, in struct{}, out *[]*api.GlobalPinInfo) error {
 	pinfos, err := rpcapi.c.RecoverAll(ctx)
@@ -336,6 +731,137 @@ func (rpcapi *ClusterRPCAPI) RecoverLocal(ctx context.Context, in cid.Cid, out *
 	return nil
 }
 
+// RepinFromPeer re-allocates the given pin to peers other than this one. It
+// is used by the pintracker when it cannot pin an item. It does nothing when
+// repinning is disabled or in follower mode.
+func (rpcapi *ClusterRPCAPI) RepinFromPeer(ctx context.Context, in *api.Pin, out *struct{}) error {
+	if rpcapi.c.config.FollowerMode || rpcapi.c.config.DisableRepinning {
+		logger.Debugf("cannot repin: %s stays allocated to this peer", in.Cid)
+		return nil
+	}
+	rpcapi.c.repinFromPeer(ctx, rpcapi.c.id, in)
+	return nil
+}
+
+// Shards runs Cluster.Shards().
+func (rpcapi *ClusterRPCAPI) Shards(ctx context.Context, in cid.Cid, out *[]*api.ShardInfo) error {
+	shards, err := rpcapi.c.Shards(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = shards
+	return nil
+}
+
+// Reshard runs Cluster.Reshard().
+func (rpcapi *ClusterRPCAPI) Reshard(ctx context.Context, in *api.ReshardRequest, out *api.Operation) error {
+	op, err := rpcapi.c.Reshard(ctx, in.Cid, in.Params)
+	if err != nil {
+		return err
+	}
+	*out = *op
+	return nil
+}
+
+// Reconstruct runs Cluster.Reconstruct().
+func (rpcapi *ClusterRPCAPI) Reconstruct(ctx context.Context, in cid.Cid, out *api.ErasureReconstruction) error {
+	res, err := rpcapi.c.Reconstruct(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = *res
+	return nil
+}
+
+// TrackAccess runs Cluster.TrackAccess().
+func (rpcapi *ClusterRPCAPI) TrackAccess(ctx context.Context, in cid.Cid, out *struct{}) error {
+	rpcapi.c.TrackAccess(ctx, in)
+	return nil
+}
+
+// RPCPolicy runs Cluster.RPCPolicy().
+func (rpcapi *ClusterRPCAPI) RPCPolicy(ctx context.Context, in struct{}, out *map[string]string) error {
+	*out = rpcapi.c.RPCPolicy(ctx)
+	return nil
+}
+
+// SetRPCPolicy runs Cluster.SetRPCPolicy().
+func (rpcapi *ClusterRPCAPI) SetRPCPolicy(ctx context.Context, in map[string]string, out *map[string]string) error {
+	policy, err := rpcapi.c.SetRPCPolicy(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = policy
+	return nil
+}
+
+// APIListeners runs Cluster.APIListeners().
+func (rpcapi *ClusterRPCAPI) APIListeners(ctx context.Context, in struct{}, out *[]*api.APIListeners) error {
+	*out = rpcapi.c.APIListeners(ctx)
+	return nil
+}
+
+// RebindAPI runs Cluster.RebindAPI().
+func (rpcapi *ClusterRPCAPI) RebindAPI(ctx context.Context, in *api.APIListeners, out *api.APIListeners) error {
+	listeners, err := rpcapi.c.RebindAPI(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = *listeners
+	return nil
+}
+
+// Operations runs Cluster.Operations().
+func (rpcapi *ClusterRPCAPI) Operations(ctx context.Context, in struct{}, out *[]*api.Operation) error {
+	*out = rpcapi.c.Operations(ctx)
+	return nil
+}
+
+// Operation runs Cluster.Operation().
+func (rpcapi *ClusterRPCAPI) Operation(ctx context.Context, in string, out *api.Operation) error {
+	op, err := rpcapi.c.Operation(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = *op
+	return nil
+}
+
+// CancelOperation runs Cluster.CancelOperation().
+func (rpcapi *ClusterRPCAPI) CancelOperation(ctx context.Context, in string, out *api.Operation) error {
+	op, err := rpcapi.c.CancelOperation(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = *op
+	return nil
+}
+
+// StartOperation runs Cluster.StartOperation().
+func (rpcapi *ClusterRPCAPI) StartOperation(ctx context.Context, in api.OperationType, out *api.Operation) error {
+	*out = *rpcapi.c.StartOperation(ctx, in)
+	return nil
+}
+
+// FinishOperation runs Cluster.FinishOperation().
+func (rpcapi *ClusterRPCAPI) FinishOperation(ctx context.Context, in *api.Operation, out *struct{}) error {
+	return rpcapi.c.FinishOperation(ctx, in)
+}
+
+// CheckFreeSpace returns an error wrapping api.ErrInsufficientFreeSpace
+// when new pins with the given options would be rejected for lack of free
+// space. Adds check it before reading any content.
+func (rpcapi *ClusterRPCAPI) CheckFreeSpace(ctx context.Context, in api.PinOptions, out *struct{}) error {
+	pin := api.PinWithOpts(cid.Undef, in)
+	if err := rpcapi.c.setupReplicationFactor(pin); err != nil {
+		return err
+	}
+	if err := rpcapi.c.setupExcludedAllocations(pin); err != nil {
+		return err
+	}
+	return rpcapi.c.checkFreeSpace(ctx, pin)
+}
+
 // BlockAllocate returns allocations for blocks. This is used in the adders.
 // It's different from pin allocations when ReplicationFactor < 0.
 func (rpcapi *ClusterRPCAPI) BlockAllocate(ctx context.Context, in *api.Pin, out *[]peer.ID) error {
@@ -353,14 +879,23 @@ func (rpcapi *ClusterRPCAPI) BlockAllocate(ctx context.Context, in *api.Pin, out
 		return err
 	}
 
+	if existing == nil {
+		if err := rpcapi.c.checkFreeSpace(ctx, in); err != nil {
+			return err
+		}
+	}
+
 	// Return the current peer list.
 	if in.ReplicationFactorMin < 0 {
 		// Returned metrics are Valid and belong to current
 		// Cluster peers.
 		metrics := rpcapi.c.monitor.LatestMetrics(ctx, pingMetricName)
-		peers := make([]peer.ID, len(metrics))
-		for i, m := range metrics {
-			peers[i] = m.Peer
+		peers := make([]peer.ID, 0, len(metrics))
+		for _, m := range metrics {
+			if containsPeer(in.ExcludeAllocations, m.Peer) {
+				continue
+			}
+			peers = append(peers, m.Peer)
 		}
 
 		*out = peers
@@ -373,8 +908,8 @@ func (rpcapi *ClusterRPCAPI) BlockAllocate(ctx context.Context, in *api.Pin, out
 		existing,
 		in.ReplicationFactorMin,
 		in.ReplicationFactorMax,
-		[]peer.ID{},        // blacklist
-		in.UserAllocations, // prio list
+		in.ExcludeAllocations, // blacklist
+		in.UserAllocations,    // prio list
 	)
 
 	if err != nil {
@@ -405,6 +940,26 @@ func (rpcapi *ClusterRPCAPI) RepoGCLocal(ctx context.Context, in struct{}, out *
 	return nil
 }
 
+// Audit runs Cluster.Audit().
+func (rpcapi *ClusterRPCAPI) Audit(ctx context.Context, in api.DAGAuditRequest, out *api.GlobalDAGAudit) error {
+	res, err := rpcapi.c.Audit(ctx, in.Cid, in.Repair)
+	if err != nil {
+		return err
+	}
+	*out = *res
+	return nil
+}
+
+// AuditLocal runs Cluster.AuditLocal().
+func (rpcapi *ClusterRPCAPI) AuditLocal(ctx context.Context, in api.DAGAuditRequest, out *api.DAGAudit) error {
+	res, err := rpcapi.c.AuditLocal(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = *res
+	return nil
+}
+
 // SendInformerMetric runs Cluster.sendInformerMetric().
 func (rpcapi *ClusterRPCAPI) SendInformerMetrics(ctx context.Context, in struct{}, out *struct{}) error {
 	_, err := rpcapi.c.sendInformerMetrics(ctx, rpcapi.c.informers[0])
@@ -426,6 +981,12 @@ func (rpcapi *ClusterRPCAPI) Alerts(ctx context.Context, in struct{}, out *[]api
 	return nil
 }
 
+// StartupWarmup runs Cluster.StartupWarmup().
+func (rpcapi *ClusterRPCAPI) StartupWarmup(ctx context.Context, in struct{}, out *api.StartupWarmup) error {
+	*out = *rpcapi.c.StartupWarmup(ctx)
+	return nil
+}
+
 /*
    Tracker component methods
 */
@@ -434,6 +995,7 @@ func (rpcapi *ClusterRPCAPI) Alerts(ctx context.Context, in struct{}, out *[]api
 func (rpcapi *PinTrackerRPCAPI) Track(ctx context.Context, in *api.Pin, out *struct{}) error {
 	ctx, span := trace.StartSpan(ctx, "rpc/tracker/Track")
 	defer span.End()
+	ctx = api.ContextWithPinRequestID(ctx, in)
 	return rpcapi.tracker.Track(ctx, in)
 }
 
@@ -441,7 +1003,8 @@ func (rpcapi *PinTrackerRPCAPI) Track(ctx context.Context, in *api.Pin, out *str
 func (rpcapi *PinTrackerRPCAPI) Untrack(ctx context.Context, in *api.Pin, out *struct{}) error {
 	ctx, span := trace.StartSpan(ctx, "rpc/tracker/Untrack")
 	defer span.End()
-	return rpcapi.tracker.Untrack(ctx, in.Cid)
+	ctx = api.ContextWithPinRequestID(ctx, in)
+	return rpcapi.tracker.Untrack(ctx, in)
 }
 
 // StatusAll runs PinTracker.StatusAll().
@@ -461,6 +1024,14 @@ func (rpcapi *PinTrackerRPCAPI) Status(ctx context.Context, in cid.Cid, out *api
 	return nil
 }
 
+// StatusCids returns the PinTracker status of each of the given Cids.
+func (rpcapi *PinTrackerRPCAPI) StatusCids(ctx context.Context, in []cid.Cid, out *[]*api.PinInfo) error {
+	ctx, span := trace.StartSpan(ctx, "rpc/tracker/StatusCids")
+	defer span.End()
+	*out = trackerStatusCids(ctx, rpcapi.tracker, in)
+	return nil
+}
+
 // RecoverAll runs PinTracker.RecoverAll().f
 func (rpcapi *PinTrackerRPCAPI) RecoverAll(ctx context.Context, in struct{}, out *[]*api.PinInfo) error {
 	ctx, span := trace.StartSpan(ctx, "rpc/tracker/RecoverAll")
@@ -482,6 +1053,26 @@ func (rpcapi *PinTrackerRPCAPI) Recover(ctx context.Context, in cid.Cid, out *ap
 	return err
 }
 
+// Settings runs PinTracker.Settings().
+func (rpcapi *PinTrackerRPCAPI) Settings(ctx context.Context, in struct{}, out *api.TrackerSettings) error {
+	ctx, span := trace.StartSpan(ctx, "rpc/tracker/Settings")
+	defer span.End()
+	*out = *rpcapi.tracker.Settings(ctx)
+	return nil
+}
+
+// SetSettings runs PinTracker.SetSettings().
+func (rpcapi *PinTrackerRPCAPI) SetSettings(ctx context.Context, in *api.TrackerSettings, out *api.TrackerSettings) error {
+	ctx, span := trace.StartSpan(ctx, "rpc/tracker/SetSettings")
+	defer span.End()
+	settings, err := rpcapi.tracker.SetSettings(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = *settings
+	return nil
+}
+
 /*
    IPFS Connector component methods
 */
@@ -495,7 +1086,7 @@ func (rpcapi *IPFSConnectorRPCAPI) Pin(ctx context.Context, in *api.Pin, out *st
 
 // Unpin runs IPFSConnector.Unpin().
 func (rpcapi *IPFSConnectorRPCAPI) Unpin(ctx context.Context, in *api.Pin, out *struct{}) error {
-	return rpcapi.ipfs.Unpin(ctx, in.Cid)
+	return rpcapi.ipfs.Unpin(ctx, in)
 }
 
 // PinLsCid runs IPFSConnector.PinLsCid().
@@ -581,6 +1172,7 @@ func (rpcapi *IPFSConnectorRPCAPI) Resolve(ctx context.Context, in string, out *
 func (rpcapi *ConsensusRPCAPI) LogPin(ctx context.Context, in *api.Pin, out *struct{}) error {
 	ctx, span := trace.StartSpan(ctx, "rpc/consensus/LogPin")
 	defer span.End()
+	ctx = api.ContextWithPinRequestID(ctx, in)
 	return rpcapi.cons.LogPin(ctx, in)
 }
 
@@ -588,6 +1180,7 @@ func (rpcapi *ConsensusRPCAPI) LogPin(ctx context.Context, in *api.Pin, out *str
 func (rpcapi *ConsensusRPCAPI) LogUnpin(ctx context.Context, in *api.Pin, out *struct{}) error {
 	ctx, span := trace.StartSpan(ctx, "rpc/consensus/LogUnpin")
 	defer span.End()
+	ctx = api.ContextWithPinRequestID(ctx, in)
 	return rpcapi.cons.LogUnpin(ctx, in)
 }
 
@@ -615,6 +1208,16 @@ func (rpcapi *ConsensusRPCAPI) Peers(ctx context.Context, in struct{}, out *[]pe
 	return nil
 }
 
+// Stats runs Consensus.Stats().
+func (rpcapi *ConsensusRPCAPI) Stats(ctx context.Context, in struct{}, out *api.ConsensusStats) error {
+	stats, err := rpcapi.cons.Stats(ctx)
+	if err != nil {
+		return err
+	}
+	*out = *stats
+	return nil
+}
+
 /*
    PeerMonitor
 */
@@ -630,3 +1233,9 @@ func (rpcapi *PeerMonitorRPCAPI) MetricNames(ctx context.Context, in struct{}, o
 	*out = rpcapi.mon.MetricNames(ctx)
 	return nil
 }
+
+// DetectorState runs PeerMonitor.DetectorState().
+func (rpcapi *PeerMonitorRPCAPI) DetectorState(ctx context.Context, in struct{}, out *[]*api.DetectorState) error {
+	*out = rpcapi.mon.DetectorState(ctx)
+	return nil
+}
@@ -7,41 +7,102 @@ package ipfscluster
 // without missing any endpoint.
 var DefaultRPCPolicy = map[string]RPCEndpointType{
 	// Cluster methods
-	"Cluster.BlockAllocate":        RPCClosed,
-	"Cluster.ConnectGraph":         RPCClosed,
-	"Cluster.ID":                   RPCOpen,
-	"Cluster.Join":                 RPCClosed,
-	"Cluster.PeerAdd":              RPCOpen, // Used by Join()
-	"Cluster.PeerRemove":           RPCTrusted,
-	"Cluster.Peers":                RPCTrusted, // Used by ConnectGraph()
-	"Cluster.Pin":                  RPCClosed,
-	"Cluster.PinGet":               RPCClosed,
-	"Cluster.PinPath":              RPCClosed,
-	"Cluster.Pins":                 RPCClosed, // Used in stateless tracker, ipfsproxy, restapi
-	"Cluster.Recover":              RPCClosed,
-	"Cluster.RecoverAll":           RPCClosed,
-	"Cluster.RecoverAllLocal":      RPCTrusted,
-	"Cluster.RecoverLocal":         RPCTrusted,
-	"Cluster.RepoGC":               RPCClosed,
-	"Cluster.RepoGCLocal":          RPCTrusted,
-	"Cluster.SendInformerMetrics":  RPCClosed,
-	"Cluster.SendInformersMetrics": RPCClosed,
-	"Cluster.Alerts":               RPCClosed,
-	"Cluster.Status":               RPCClosed,
-	"Cluster.StatusAll":            RPCClosed,
-	"Cluster.StatusAllLocal":       RPCClosed,
-	"Cluster.StatusLocal":          RPCClosed,
-	"Cluster.Unpin":                RPCClosed,
-	"Cluster.UnpinPath":            RPCClosed,
-	"Cluster.Version":              RPCOpen,
+	"Cluster.APIListeners":          RPCClosed,
+	"Cluster.Alerts":                RPCClosed,
+	"Cluster.Audit":                 RPCClosed,
+	"Cluster.AuditLocal":            RPCTrusted,
+	"Cluster.BlockAllocate":         RPCClosed,
+	"Cluster.CancelOperation":       RPCClosed,
+	"Cluster.CheckFreeSpace":        RPCClosed,
+	"Cluster.ConnectGraph":          RPCClosed,
+	"Cluster.Connections":           RPCTrusted, // Used by ConnectGraph()
+	"Cluster.ConnectivityHistory":   RPCClosed,
+	"Cluster.ConsensusStats":        RPCClosed,
+	"Cluster.ConsistencyCheck":      RPCClosed,
+	"Cluster.ConsistencyCheckLocal": RPCTrusted,
+	"Cluster.DenylistAdd":           RPCClosed,
+	"Cluster.DenylistCheck":         RPCClosed,
+	"Cluster.DenylistEnforce":       RPCClosed,
+	"Cluster.DenylistEntries":       RPCClosed,
+	"Cluster.DenylistRemove":        RPCClosed,
+	"Cluster.FinishOperation":       RPCClosed,
+	"Cluster.ID":                    RPCOpen,
+	"Cluster.Join":                  RPCClosed,
+	"Cluster.LifecycleEvents":       RPCClosed,
+	"Cluster.Operation":             RPCClosed,
+	"Cluster.Operations":            RPCClosed,
+	"Cluster.Peer":                  RPCClosed,
+	"Cluster.PeerAdd":               RPCOpen, // Used by Join()
+	"Cluster.PeerHandover":          RPCClosed,
+	"Cluster.PeerRemove":            RPCTrusted,
+	"Cluster.PeerVersions":          RPCClosed,
+	"Cluster.Peers":                 RPCTrusted, // Used by ConnectGraph()
+	"Cluster.Pin":                   RPCClosed,
+	"Cluster.PinChanges":            RPCClosed,
+	"Cluster.PinDryRun":             RPCClosed,
+	"Cluster.PinGet":                RPCTrusted, // Used by Pin() with replicated acknowledgments
+	"Cluster.PinPath":               RPCClosed,
+	"Cluster.PinTemplate":           RPCClosed,
+	"Cluster.PinTemplateRemove":     RPCClosed,
+	"Cluster.PinTemplateSet":        RPCClosed,
+	"Cluster.PinTemplates":          RPCClosed,
+	"Cluster.Pins":                  RPCClosed, // Used in stateless tracker, ipfsproxy, restapi
+	"Cluster.PinsByName":            RPCClosed,
+	"Cluster.PinsByNamePrefix":      RPCClosed,
+	"Cluster.PinsByType":            RPCClosed,
+	"Cluster.PinsetRollback":        RPCClosed,
+	"Cluster.PinsetSnapshot":        RPCClosed,
+	"Cluster.PinsetSnapshotCreate":  RPCClosed,
+	"Cluster.PinsetSnapshotRemove":  RPCClosed,
+	"Cluster.PinsetSnapshots":       RPCClosed,
+	"Cluster.RPCPolicy":             RPCClosed,
+	"Cluster.RebindAPI":             RPCClosed,
+	"Cluster.Reconstruct":           RPCClosed,
+	"Cluster.Recover":               RPCClosed,
+	"Cluster.RecoverAll":            RPCClosed,
+	"Cluster.RecoverAllLocal":       RPCTrusted,
+	"Cluster.RecoverLocal":          RPCTrusted,
+	"Cluster.RecoverMatching":       RPCClosed,
+	"Cluster.RepinFromPeer":         RPCClosed,
+	"Cluster.ReplicateMatching":     RPCClosed,
+	"Cluster.RepoGC":                RPCClosed,
+	"Cluster.RepoGCLocal":           RPCTrusted,
+	"Cluster.Reshard":               RPCClosed,
+	"Cluster.ResolvePeer":           RPCClosed,
+	"Cluster.Resources":             RPCClosed,
+	"Cluster.ResourcesLocal":        RPCTrusted,
+	"Cluster.Restore":               RPCClosed,
+	"Cluster.RotateSecret":          RPCClosed,
+	"Cluster.RotateSecretLocal":     RPCTrusted,
+	"Cluster.SendInformerMetrics":   RPCClosed,
+	"Cluster.SendInformersMetrics":  RPCClosed,
+	"Cluster.SetRPCPolicy":          RPCClosed,
+	"Cluster.Shards":                RPCClosed,
+	"Cluster.StartOperation":        RPCClosed,
+	"Cluster.StartupWarmup":         RPCClosed,
+	"Cluster.Status":                RPCClosed,
+	"Cluster.StatusAll":             RPCClosed,
+	"Cluster.StatusAllLocal":        RPCClosed,
+	"Cluster.StatusCids":            RPCClosed,
+	"Cluster.StatusCidsLocal":       RPCClosed,
+	"Cluster.StatusLocal":           RPCClosed,
+	"Cluster.TrackAccess":           RPCClosed,
+	"Cluster.Unpin":                 RPCClosed,
+	"Cluster.UnpinMatching":         RPCClosed,
+	"Cluster.UnpinPath":             RPCClosed,
+	"Cluster.Unprotect":             RPCClosed,
+	"Cluster.Version":               RPCOpen,
 
 	// PinTracker methods
-	"PinTracker.Recover":    RPCTrusted, // Called in broadcast from Recover()
-	"PinTracker.RecoverAll": RPCClosed,  // Broadcast in RecoverAll unimplemented
-	"PinTracker.Status":     RPCTrusted,
-	"PinTracker.StatusAll":  RPCTrusted,
-	"PinTracker.Track":      RPCClosed,
-	"PinTracker.Untrack":    RPCClosed,
+	"PinTracker.Recover":     RPCTrusted, // Called in broadcast from Recover()
+	"PinTracker.RecoverAll":  RPCClosed,  // Broadcast in RecoverAll unimplemented
+	"PinTracker.SetSettings": RPCClosed,
+	"PinTracker.Settings":    RPCClosed,
+	"PinTracker.Status":      RPCTrusted,
+	"PinTracker.StatusAll":   RPCTrusted,
+	"PinTracker.StatusCids":  RPCTrusted,
+	"PinTracker.Track":       RPCClosed,
+	"PinTracker.Untrack":     RPCClosed,
 
 	// IPFSConnector methods
 	"IPFSConnector.BlockGet":   RPCClosed,
@@ -61,8 +122,10 @@ var DefaultRPCPolicy = map[string]RPCEndpointType{
 	"Consensus.LogUnpin": RPCTrusted, // Called by Raft/redirect to leader
 	"Consensus.Peers":    RPCClosed,
 	"Consensus.RmPeer":   RPCTrusted, // Called by Raft/redirect to leader
+	"Consensus.Stats":    RPCTrusted, // Used by ConsensusStats()
 
 	// PeerMonitor methods
+	"PeerMonitor.DetectorState": RPCClosed,
 	"PeerMonitor.LatestMetrics": RPCClosed,
 	"PeerMonitor.MetricNames":   RPCClosed,
 }
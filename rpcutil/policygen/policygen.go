@@ -24,9 +24,11 @@ func rpcTypeStr(t cluster.RPCEndpointType) string {
 }
 
 var comments = map[string]string{
+	"Cluster.Connections":      "Used by ConnectGraph()",
 	"Cluster.PeerAdd":          "Used by Join()",
 	"Cluster.Peers":            "Used by ConnectGraph()",
 	"Cluster.Pins":             "Used in stateless tracker, ipfsproxy, restapi",
+	"Cluster.PinGet":           "Used by Pin() with replicated acknowledgments",
 	"PinTracker.Recover":       "Called in broadcast from Recover()",
 	"PinTracker.RecoverAll":    "Broadcast in RecoverAll unimplemented",
 	"Pintracker.Status":        "Called in broadcast from Status()",
@@ -38,6 +40,7 @@ var comments = map[string]string{
 	"Consensus.LogPin":         "Called by Raft/redirect to leader",
 	"Consensus.LogUnpin":       "Called by Raft/redirect to leader",
 	"Consensus.RmPeer":         "Called by Raft/redirect to leader",
+	"Consensus.Stats":          "Used by ConsensusStats()",
 }
 
 func main() {
@@ -6,11 +6,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/observations"
 
 	peer "github.com/libp2p/go-libp2p-core/peer"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
 )
 
 // CtxsWithTimeout returns n contexts, derived from the given parent
@@ -55,6 +58,83 @@ func MultiCancel(cancels []context.CancelFunc) {
 	}
 }
 
+// BroadcastOptions control how Broadcast() contacts the destination peers.
+type BroadcastOptions struct {
+	// Timeout is applied to every call independently. 0 means no timeout
+	// other than the one of the parent context.
+	Timeout time.Duration
+	// Concurrency is the maximum number of calls in flight at the same
+	// time. 0 means no limit.
+	Concurrency int
+}
+
+// BroadcastResult is the outcome of a call made by Broadcast() to one of
+// the destination peers.
+type BroadcastResult struct {
+	Peer  peer.ID
+	Reply interface{}
+	Err   error
+}
+
+// Broadcast calls the given method on every destination peer and sends
+// the results on the returned channel as they arrive, so that slow peers
+// do not delay handling the responses of others. newReply returns a new
+// reply object (a pointer) for every call. The channel is closed once all
+// calls have finished.
+func Broadcast(
+	ctx context.Context,
+	client *rpc.Client,
+	dests []peer.ID,
+	svcName, svcMethod string,
+	args interface{},
+	newReply func() interface{},
+	opts BroadcastOptions,
+) <-chan BroadcastResult {
+
+	results := make(chan BroadcastResult, len(dests))
+
+	var slots chan struct{}
+	if opts.Concurrency > 0 {
+		slots = make(chan struct{}, opts.Concurrency)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(dests))
+	for _, dest := range dests {
+		go func(dest peer.ID) {
+			defer wg.Done()
+			reply := newReply()
+
+			if slots != nil {
+				select {
+				case slots <- struct{}{}:
+					defer func() { <-slots }()
+				case <-ctx.Done():
+					results <- BroadcastResult{Peer: dest, Reply: reply, Err: ctx.Err()}
+					return
+				}
+			}
+
+			callCtx, cancel := ctx, context.CancelFunc(func() {})
+			if opts.Timeout > 0 {
+				callCtx, cancel = context.WithTimeout(ctx, opts.Timeout)
+			}
+			defer cancel()
+
+			start := time.Now()
+			err := client.CallContext(callCtx, dest, svcName, svcMethod, args, reply)
+			observations.RecordRPCCall(ctx, svcName, svcMethod, time.Since(start), err)
+			results <- BroadcastResult{Peer: dest, Reply: reply, Err: err}
+		}(dest)
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
 // The copy functions below are used in calls to Cluster.multiRPC()
 
 // CopyPIDsToIfaces converts a peer.ID slice to an empty interface
@@ -91,6 +171,17 @@ func CopyIDSliceToIfaces(in [][]*api.ID) []interface{} {
 	return ifaces
 }
 
+// CopyConnectionInfoSliceToIfaces converts an api.ConnectionInfo slice of
+// slices to an empty interface slice using pointers to each elements of the
+// original slice. Useful to handle gorpc.MultiCall() replies.
+func CopyConnectionInfoSliceToIfaces(in [][]*api.ConnectionInfo) []interface{} {
+	ifaces := make([]interface{}, len(in))
+	for i := range in {
+		ifaces[i] = &in[i]
+	}
+	return ifaces
+}
+
 // CopyPinInfoToIfaces converts an api.PinInfo slice to
 // an empty interface slice using pointers to each elements of
 // the original slice. Useful to handle gorpc.MultiCall() replies.
@@ -103,6 +194,18 @@ func CopyPinInfoToIfaces(in []*api.PinInfo) []interface{} {
 	return ifaces
 }
 
+// CopyPinsToIfaces converts an api.Pin slice to
+// an empty interface slice using pointers to each elements of
+// the original slice. Useful to handle gorpc.MultiCall() replies.
+func CopyPinsToIfaces(in []*api.Pin) []interface{} {
+	ifaces := make([]interface{}, len(in))
+	for i := range in {
+		in[i] = &api.Pin{}
+		ifaces[i] = in[i]
+	}
+	return ifaces
+}
+
 // CopyPinInfoSliceToIfaces converts an api.PinInfo slice of slices
 // to an empty interface slice using pointers to each elements of the original
 // slice. Useful to handle gorpc.MultiCall() replies.
@@ -126,6 +229,18 @@ func CopyRepoGCSliceToIfaces(in []*api.RepoGC) []interface{} {
 	return ifaces
 }
 
+// CopyConsensusStatsToIfaces converts an api.ConsensusStats slice to
+// an empty interface slice using pointers to each elements of
+// the original slice. Useful to handle gorpc.MultiCall() replies.
+func CopyConsensusStatsToIfaces(in []*api.ConsensusStats) []interface{} {
+	ifaces := make([]interface{}, len(in))
+	for i := range in {
+		in[i] = &api.ConsensusStats{}
+		ifaces[i] = in[i]
+	}
+	return ifaces
+}
+
 // CopyEmptyStructToIfaces converts an empty struct slice to an empty interface
 // slice using pointers to each elements of the original slice.
 // Useful to handle gorpc.MultiCall() replies.
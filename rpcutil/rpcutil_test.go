@@ -0,0 +1,102 @@
+package rpcutil
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	libp2p "github.com/libp2p/go-libp2p"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+)
+
+type testService struct {
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (s *testService) Wait(ctx context.Context, in time.Duration, out *int) error {
+	s.mu.Lock()
+	s.inFlight++
+	if s.inFlight > s.maxInFlight {
+		s.maxInFlight = s.inFlight
+	}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.inFlight--
+		s.mu.Unlock()
+	}()
+
+	select {
+	case <-time.After(in):
+		*out = 1
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func testClient(t *testing.T) (*rpc.Client, *testService, peer.ID) {
+	h, err := libp2p.New(libp2p.NoListenAddrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { h.Close() })
+
+	srv := rpc.NewServer(h, "/rpcutil/test")
+	svc := &testService{}
+	if err := srv.RegisterName("Test", svc); err != nil {
+		t.Fatal(err)
+	}
+	return rpc.NewClientWithServer(h, "/rpcutil/test", srv), svc, h.ID()
+}
+
+func TestBroadcast(t *testing.T) {
+	ctx := context.Background()
+	client, svc, pid := testClient(t)
+	dests := []peer.ID{pid, pid, pid, pid, pid}
+	newReply := func() interface{} { return new(int) }
+
+	results := Broadcast(ctx, client, dests, "Test", "Wait", 20*time.Millisecond, newReply, BroadcastOptions{
+		Concurrency: 2,
+	})
+	n := 0
+	for res := range results {
+		if res.Err != nil {
+			t.Error(res.Err)
+		}
+		if *res.Reply.(*int) != 1 || res.Peer != pid {
+			t.Error("unexpected result")
+		}
+		n++
+	}
+	if n != len(dests) {
+		t.Errorf("expected %d results but got %d", len(dests), n)
+	}
+	if svc.maxInFlight != 2 {
+		t.Errorf("expected 2 concurrent calls but got %d", svc.maxInFlight)
+	}
+}
+
+func TestBroadcastTimeout(t *testing.T) {
+	ctx := context.Background()
+	client, _, pid := testClient(t)
+	newReply := func() interface{} { return new(int) }
+
+	start := time.Now()
+	results := Broadcast(ctx, client, []peer.ID{pid, pid}, "Test", "Wait", time.Minute, newReply, BroadcastOptions{
+		Timeout: 50 * time.Millisecond,
+	})
+	for res := range results {
+		if res.Err == nil {
+			t.Error("expected a timeout error")
+		}
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Error("calls were not timed out")
+	}
+}
@@ -0,0 +1,272 @@
+package ipfscluster
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"sync"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	corepnet "github.com/libp2p/go-libp2p-core/pnet"
+	sec "github.com/libp2p/go-libp2p-core/sec"
+	pnet "github.com/libp2p/go-libp2p-pnet"
+	tptu "github.com/libp2p/go-libp2p-transport-upgrader"
+	tcp "github.com/libp2p/go-tcp-transport"
+	websocket "github.com/libp2p/go-ws-transport"
+)
+
+// The private network protocol starts every connection direction with a
+// random nonce. The first bytes protected by the secret are always the
+// multistream-select header, which both ends send before negotiating the
+// security protocol. We use it to find out which secret the other end
+// used.
+const pnetNonceSize = 24
+
+var multistreamHeader = []byte("\x13/multistream/1.0.0\n")
+
+var errSecretMismatch = corepnet.NewError("the connection is not protected by any of the accepted cluster secrets")
+
+// secretRing holds the cluster secrets used by the transports of a host:
+// the one protecting the traffic sent by this peer and all those accepted
+// on the traffic received from others. Several secrets are only accepted
+// during a secret rotation.
+type secretRing struct {
+	mu       sync.RWMutex
+	send     corepnet.PSK
+	accepted []corepnet.PSK
+}
+
+func newSecretRing(psk corepnet.PSK) *secretRing {
+	return &secretRing{
+		send:     psk,
+		accepted: []corepnet.PSK{psk},
+	}
+}
+
+func (r *secretRing) sendKey() corepnet.PSK {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.send
+}
+
+func (r *secretRing) acceptedKeys() []corepnet.PSK {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	keys := make([]corepnet.PSK, len(r.accepted))
+	copy(keys, r.accepted)
+	return keys
+}
+
+// accept makes the ring accept the given secret, in addition to the
+// current ones.
+func (r *secretRing) accept(psk corepnet.PSK) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, k := range r.accepted {
+		if bytes.Equal(k, psk) {
+			return
+		}
+	}
+	r.accepted = append(r.accepted, psk)
+}
+
+// use makes the ring protect new connections with the given secret. The
+// previous one is still accepted.
+func (r *secretRing) use(psk corepnet.PSK) {
+	r.accept(psk)
+	r.mu.Lock()
+	r.send = psk
+	r.mu.Unlock()
+}
+
+// forget stops accepting the given secret, unless it is the one in use.
+func (r *secretRing) forget(psk corepnet.PSK) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if bytes.Equal(r.send, psk) {
+		return
+	}
+	accepted := r.accepted[:0]
+	for _, k := range r.accepted {
+		if !bytes.Equal(k, psk) {
+			accepted = append(accepted, k)
+		}
+	}
+	r.accepted = accepted
+}
+
+// protect makes the given upgrader use the ring for the private network
+// instead of a fixed secret. The upgrader is shared by all the transports
+// of a host (relayed connections included), so it is modified in place.
+func (r *secretRing) protect(u *tptu.Upgrader) *tptu.Upgrader {
+	if _, ok := u.Secure.(*secretMuxer); !ok {
+		u.PSK = nil
+		u.Secure = &secretMuxer{SecureMuxer: u.Secure, ring: r}
+	}
+	return u
+}
+
+func (r *secretRing) tcpTransport(u *tptu.Upgrader) (*tcp.TcpTransport, error) {
+	return tcp.NewTCPTransport(r.protect(u))
+}
+
+func (r *secretRing) wsTransport(u *tptu.Upgrader) *websocket.WebsocketTransport {
+	return websocket.New(r.protect(u))
+}
+
+// secretMuxer wraps the raw connections with the secrets in the ring
+// before securing them.
+type secretMuxer struct {
+	sec.SecureMuxer
+	ring *secretRing
+}
+
+func (m *secretMuxer) SecureInbound(ctx context.Context, insecure net.Conn, p peer.ID) (sec.SecureConn, bool, error) {
+	conn, err := newSecretConn(insecure, m.ring, true)
+	if err != nil {
+		return nil, false, err
+	}
+	return m.SecureMuxer.SecureInbound(ctx, conn, p)
+}
+
+func (m *secretMuxer) SecureOutbound(ctx context.Context, insecure net.Conn, p peer.ID) (sec.SecureConn, bool, error) {
+	conn, err := newSecretConn(insecure, m.ring, false)
+	if err != nil {
+		return nil, false, err
+	}
+	return m.SecureMuxer.SecureOutbound(ctx, conn, p)
+}
+
+// secretConn is a private network connection which accepts any of the
+// secrets in a ring for the incoming traffic. Outbound connections protect
+// the outgoing traffic with the secret in use by the ring. Inbound
+// connections reply with the same secret used by the other end, so that
+// peers which have not switched secrets yet can still connect. Anything
+// written before finding out which one is kept until then.
+type secretConn struct {
+	net.Conn
+	ring *secretRing
+
+	rmu     sync.Mutex
+	r       net.Conn
+	pending []byte
+
+	wmu      sync.Mutex
+	w        net.Conn
+	werr     error
+	buffered []byte
+}
+
+func newSecretConn(insecure net.Conn, ring *secretRing, inbound bool) (*secretConn, error) {
+	sc := &secretConn{
+		Conn: insecure,
+		ring: ring,
+	}
+	if !inbound {
+		w, err := pnet.NewProtectedConn(ring.sendKey(), insecure)
+		if err != nil {
+			return nil, err
+		}
+		sc.w = w
+	}
+	return sc, nil
+}
+
+// setWriter sets the writer of inbound connections once the secret used by
+// the other end is known, flushing anything written until then.
+func (sc *secretConn) setWriter(w net.Conn, err error) error {
+	sc.wmu.Lock()
+	defer sc.wmu.Unlock()
+	if sc.w != nil || sc.werr != nil {
+		return nil
+	}
+	if err != nil {
+		sc.werr = err
+		return err
+	}
+	sc.w = w
+	if len(sc.buffered) > 0 {
+		_, err = w.Write(sc.buffered)
+		sc.buffered = nil
+	}
+	return err
+}
+
+func (sc *secretConn) Read(out []byte) (int, error) {
+	sc.rmu.Lock()
+	defer sc.rmu.Unlock()
+
+	if sc.r == nil {
+		if err := sc.detect(); err != nil {
+			_ = sc.setWriter(nil, err)
+			return 0, err
+		}
+	}
+
+	if len(sc.pending) > 0 {
+		n := copy(out, sc.pending)
+		sc.pending = sc.pending[n:]
+		return n, nil
+	}
+	return sc.r.Read(out)
+}
+
+// detect reads the nonce and the multistream header sent by the other end
+// and finds the accepted secret that decrypts it.
+func (sc *secretConn) detect() error {
+	head := make([]byte, pnetNonceSize+len(multistreamHeader))
+	if _, err := io.ReadFull(sc.Conn, head); err != nil {
+		return err
+	}
+
+	for _, key := range sc.ring.acceptedKeys() {
+		r, err := pnet.NewProtectedConn(key, &replayConn{Conn: sc.Conn, buf: head})
+		if err != nil {
+			return err
+		}
+		plain := make([]byte, len(multistreamHeader))
+		if _, err := io.ReadFull(r, plain); err != nil {
+			return err
+		}
+		if !bytes.Equal(plain, multistreamHeader) {
+			continue
+		}
+
+		sc.r = r
+		sc.pending = plain
+		w, err := pnet.NewProtectedConn(key, sc.Conn)
+		return sc.setWriter(w, err)
+	}
+	return errSecretMismatch
+}
+
+func (sc *secretConn) Write(in []byte) (int, error) {
+	sc.wmu.Lock()
+	defer sc.wmu.Unlock()
+	switch {
+	case sc.werr != nil:
+		return 0, sc.werr
+	case sc.w == nil:
+		sc.buffered = append(sc.buffered, in...)
+		return len(in), nil
+	default:
+		return sc.w.Write(in)
+	}
+}
+
+// replayConn returns the contents of buf before reading from the
+// connection.
+type replayConn struct {
+	net.Conn
+	buf []byte
+}
+
+func (rc *replayConn) Read(out []byte) (int, error) {
+	if len(rc.buf) > 0 {
+		n := copy(out, rc.buf)
+		rc.buf = rc.buf[n:]
+		return n, nil
+	}
+	return rc.Conn.Read(out)
+}
@@ -0,0 +1,314 @@
+package ipfscluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	namespace "github.com/ipfs/go-datastore/namespace"
+	query "github.com/ipfs/go-datastore/query"
+
+	"go.opencensus.io/trace"
+)
+
+// Pinset snapshots are kept in the datastore of the peer that takes them,
+// under this namespace. Rolling back to a snapshot changes the shared
+// pinset, but the snapshots themselves are not shared with other peers.
+var pinsetSnapshotsNamespace = ds.NewKey("/pinsetsnapshots")
+
+func (c *Cluster) pinsetSnapshotStore() ds.Datastore {
+	return namespace.Wrap(c.datastore, pinsetSnapshotsNamespace)
+}
+
+// PinsetSnapshotCreate stores a copy of the current pinset under the given
+// name, which must not be used by another snapshot. The returned snapshot
+// does not include the pins.
+func (c *Cluster) PinsetSnapshotCreate(ctx context.Context, name string) (*api.PinsetSnapshot, error) {
+	_, span := trace.StartSpan(ctx, "cluster/PinsetSnapshotCreate")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	if !pinTemplateNameRegexp.MatchString(name) {
+		return nil, fmt.Errorf("invalid pinset snapshot name %q: only letters, digits, '.', '-' and '_' are allowed", name)
+	}
+	store := c.pinsetSnapshotStore()
+	exists, err := store.Has(ctx, ds.NewKey(name))
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("pinset snapshot %q already exists", name)
+	}
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// All pins are kept, including shards, so that sharded DAGs can be
+	// restored. Pin templates are not part of the pinset.
+	pins, err := cState.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pins = withoutPinTemplates(pins)
+
+	snap := &api.PinsetSnapshot{
+		Name:      name,
+		Timestamp: time.Now(),
+		NumPins:   len(pins),
+		Pins:      pins,
+	}
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Put(ctx, ds.NewKey(name), b); err != nil {
+		return nil, err
+	}
+	logger.Infof("pinset snapshot %s taken with %d pins", name, len(pins))
+	snap.Pins = nil
+	return snap, nil
+}
+
+// PinsetSnapshot returns the snapshot with the given name, including its
+// pins.
+func (c *Cluster) PinsetSnapshot(ctx context.Context, name string) (*api.PinsetSnapshot, error) {
+	_, span := trace.StartSpan(ctx, "cluster/PinsetSnapshot")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	if !pinTemplateNameRegexp.MatchString(name) {
+		return nil, api.ErrPinsetSnapshotNotFound
+	}
+
+	b, err := c.pinsetSnapshotStore().Get(ctx, ds.NewKey(name))
+	if err == ds.ErrNotFound {
+		return nil, api.ErrPinsetSnapshotNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snap api.PinsetSnapshot
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+// PinsetSnapshots returns all the pinset snapshots, oldest first, without
+// their pins.
+func (c *Cluster) PinsetSnapshots(ctx context.Context) ([]*api.PinsetSnapshot, error) {
+	_, span := trace.StartSpan(ctx, "cluster/PinsetSnapshots")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	results, err := c.pinsetSnapshotStore().Query(ctx, query.Query{})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	snaps := make([]*api.PinsetSnapshot, 0)
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		var snap api.PinsetSnapshot
+		if err := json.Unmarshal(r.Value, &snap); err != nil {
+			logger.Errorf("error decoding pinset snapshot %s: %s", r.Key, err)
+			continue
+		}
+		snap.Pins = nil
+		snaps = append(snaps, &snap)
+	}
+
+	sort.Slice(snaps, func(i, j int) bool {
+		return snaps[i].Timestamp.Before(snaps[j].Timestamp)
+	})
+	return snaps, nil
+}
+
+// PinsetSnapshotRemove removes the snapshot with the given name.
+func (c *Cluster) PinsetSnapshotRemove(ctx context.Context, name string) error {
+	_, span := trace.StartSpan(ctx, "cluster/PinsetSnapshotRemove")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	if !pinTemplateNameRegexp.MatchString(name) {
+		return api.ErrPinsetSnapshotNotFound
+	}
+	store := c.pinsetSnapshotStore()
+	exists, err := store.Has(ctx, ds.NewKey(name))
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return api.ErrPinsetSnapshotNotFound
+	}
+	return store.Delete(ctx, ds.NewKey(name))
+}
+
+// PinsetRollback makes the pinset match the snapshot with the given name.
+// The items of the snapshot which are missing from the pinset, or which
+// have different options, are pinned as they were, and the items which are
+// not in the snapshot are unpinned. Expired items are not restored and
+// protected pins are not unpinned. With dryRun, the changes are only
+// returned.
+func (c *Cluster) PinsetRollback(ctx context.Context, name string, dryRun bool) (*api.PinsetRollback, error) {
+	_, span := trace.StartSpan(ctx, "cluster/PinsetRollback")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	if c.config.FollowerMode && !dryRun {
+		return nil, errFollowerMode
+	}
+
+	snap, err := c.PinsetSnapshot(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pins, err := cState.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pins = withoutPinTemplates(pins)
+
+	current := make(map[cid.Cid]*api.Pin, len(pins))
+	for _, p := range pins {
+		current[p.Cid] = p
+	}
+	inSnapshot := make(map[cid.Cid]struct{}, len(snap.Pins))
+
+	// Shards and cluster DAGs are restored as they were, before the
+	// meta pins referencing them.
+	var toRestore, toPin, toUnpin []*api.Pin
+	now := time.Now()
+	for _, p := range snap.Pins {
+		inSnapshot[p.Cid] = struct{}{}
+		if p.ExpiredAt(now) {
+			continue
+		}
+		cur, ok := current[p.Cid]
+		switch p.Type {
+		case api.DataType, api.MetaType:
+			if !ok || cur.Type != p.Type || !cur.PinOptions.Equals(&p.PinOptions) {
+				toPin = append(toPin, p)
+			}
+		default:
+			if !ok {
+				toRestore = append(toRestore, p)
+			}
+		}
+	}
+	for _, p := range pins {
+		if _, ok := inSnapshot[p.Cid]; ok {
+			continue
+		}
+		if p.Type == api.DataType || p.Type == api.MetaType {
+			toUnpin = append(toUnpin, p)
+		}
+	}
+
+	result := &api.PinsetRollback{
+		Snapshot: name,
+		DryRun:   dryRun,
+		Pinned:   make([]cid.Cid, 0, len(toPin)),
+		Unpinned: make([]cid.Cid, 0, len(toUnpin)),
+	}
+	if dryRun {
+		for _, p := range toPin {
+			result.Pinned = append(result.Pinned, p.Cid)
+		}
+		for _, p := range toUnpin {
+			result.Unpinned = append(result.Unpinned, p.Cid)
+		}
+		return result, nil
+	}
+
+	op := c.operations.start(ctx, api.OperationRollback, len(toPin)+len(toUnpin))
+	for _, p := range toRestore {
+		if err := c.logPin(op.ctx, p); err != nil {
+			op.finish(err)
+			return result, err
+		}
+	}
+	for _, p := range toPin {
+		if op.canceled() {
+			op.finish(nil)
+			return result, nil
+		}
+		err := c.restorePin(op.ctx, p)
+		op.progress(1)
+		if err != nil {
+			op.finish(err)
+			return result, err
+		}
+		result.Pinned = append(result.Pinned, p.Cid)
+	}
+	for _, p := range toUnpin {
+		if op.canceled() {
+			op.finish(nil)
+			return result, nil
+		}
+		_, err := c.Unpin(op.ctx, p.Cid)
+		op.progress(1)
+		if errors.Is(err, api.ErrPinProtected) {
+			logger.Infof("rollback: skipping protected pin %s", p.Cid)
+			continue
+		}
+		if err != nil {
+			op.finish(err)
+			return result, err
+		}
+		result.Unpinned = append(result.Unpinned, p.Cid)
+	}
+	op.finish(nil)
+	logger.Infof("pinset rolled back to %s: %d pinned, %d unpinned", name, len(result.Pinned), len(result.Unpinned))
+	return result, nil
+}
+
+// restorePin pins an item of a snapshot again. It is validated like new
+// pins, as the denylist or the validation policies may have changed since
+// the snapshot was taken. Data pins are allocated again, as the peers they
+// were allocated to may be gone, and need free space like new pins.
+func (c *Cluster) restorePin(ctx context.Context, p *api.Pin) error {
+	newPin := copyWithMetadata(p)
+	// State backups are pinned by the cluster with reserved metadata
+	// keys, which are rejected for user pins. Items in the trash are
+	// validated without the trash key and restored to the trash.
+	if _, ok := p.Metadata[StateBackupMetaKey]; !ok {
+		trashedAt, trashed := newPin.Metadata[api.TrashedAtMetaKey]
+		delete(newPin.Metadata, api.TrashedAtMetaKey)
+		if err := c.validatePin(ctx, newPin); err != nil {
+			return fmt.Errorf("cannot restore %s: %w", p.Cid, err)
+		}
+		if trashed {
+			newPin.Metadata[api.TrashedAtMetaKey] = trashedAt
+		}
+	}
+	if p.Type != api.DataType {
+		return c.logPin(ctx, newPin)
+	}
+
+	newPin.PinUpdate = cid.Undef
+	newPin.Allocations = nil
+	// planPin rather than pin, which would handle pins with a PinUpdate
+	// as new updates.
+	pin, err := c.planPin(ctx, newPin, nil)
+	if err != nil {
+		return err
+	}
+	return c.logPin(ctx, pin)
+}
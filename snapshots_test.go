@@ -0,0 +1,124 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/state"
+	"github.com/ipfs/ipfs-cluster/test"
+)
+
+func TestClusterPinsetRollback(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	_, err := cl.Pin(ctx, test.Cid1, api.PinOptions{Name: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cl.Pin(ctx, test.Cid2, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := cl.PinsetSnapshotCreate(ctx, "before")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.NumPins != 2 || snap.Pins != nil {
+		t.Errorf("unexpected snapshot: %+v", snap)
+	}
+	if _, err := cl.PinsetSnapshotCreate(ctx, "before"); err == nil {
+		t.Error("expected an error taking a snapshot with an existing name")
+	}
+	if _, err := cl.PinsetSnapshotCreate(ctx, "a/b"); err == nil {
+		t.Error("expected an error with an invalid name")
+	}
+
+	// The bulk operation gone wrong.
+	_, err = cl.Unpin(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cl.Pin(ctx, test.Cid2, api.PinOptions{Name: "renamed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cl.Pin(ctx, test.Cid3, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := cl.PinsetRollback(ctx, "before", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Pinned) != 2 || len(res.Unpinned) != 1 || !res.Unpinned[0].Equals(test.Cid3) {
+		t.Errorf("unexpected dry-run rollback: %+v", res)
+	}
+	if _, err := cl.PinGet(ctx, test.Cid3); err != nil {
+		t.Error("dry-run should not unpin")
+	}
+
+	res, err = cl.PinsetRollback(ctx, "before", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Pinned) != 2 || len(res.Unpinned) != 1 {
+		t.Errorf("unexpected rollback: %+v", res)
+	}
+	pin, err := cl.PinGet(ctx, test.Cid1)
+	if err != nil || pin.Name != "a" {
+		t.Error("the unpinned item should be pinned again")
+	}
+	pin, err = cl.PinGet(ctx, test.Cid2)
+	if err != nil || pin.Name != "" {
+		t.Error("the options of the snapshot should be restored")
+	}
+	if _, err := cl.PinGet(ctx, test.Cid3); err != state.ErrNotFound {
+		t.Error("the item pinned after the snapshot should be unpinned")
+	}
+
+	res, err = cl.PinsetRollback(ctx, "before", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Pinned)+len(res.Unpinned) != 0 {
+		t.Errorf("the pinset should match the snapshot: %+v", res)
+	}
+
+	// Restored pins are validated like new ones.
+	if _, err := cl.Unpin(ctx, test.Cid1); err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.DenylistAdd(ctx, []*api.DenylistEntry{{Rule: "/ipfs/" + test.Cid1.String()}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cl.PinsetRollback(ctx, "before", false); !errors.Is(err, api.ErrDenylisted) {
+		t.Error("expected ErrDenylisted restoring a denylisted pin:", err)
+	}
+	if _, err := cl.PinGet(ctx, test.Cid1); err != state.ErrNotFound {
+		t.Error("the denylisted item should not be restored")
+	}
+	if err := cl.DenylistRemove(ctx, "/ipfs/"+test.Cid1.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	snaps, err := cl.PinsetSnapshots(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != 1 || snaps[0].Name != "before" || snaps[0].Pins != nil {
+		t.Errorf("unexpected snapshots: %+v", snaps)
+	}
+	if err := cl.PinsetSnapshotRemove(ctx, "before"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cl.PinsetRollback(ctx, "before", false); err != api.ErrPinsetSnapshotNotFound {
+		t.Error("expected a not found error:", err)
+	}
+}
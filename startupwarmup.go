@@ -0,0 +1,135 @@
+package ipfscluster
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	"go.opencensus.io/trace"
+)
+
+// When a peer starts, the first recover operation checks the whole pinset
+// against IPFS and queues every item which is not pinned as expected. With
+// large pinsets, this is throttled by cluster.startup_warmup_rate so that
+// IPFS is not asked to pin everything at once.
+
+// recoverStatuses are the statuses of the items which are pinned or
+// unpinned again by a recover.
+const recoverStatuses = api.TrackerStatusPinError | api.TrackerStatusUnpinError |
+	api.TrackerStatusUnexpectedlyUnpinned
+
+// startupWarmup keeps the progress of the warm-up.
+type startupWarmup struct {
+	rate int
+
+	mu        sync.Mutex
+	op        *operation
+	empty     *api.Operation // set instead of op when there was nothing to do
+	recovered int
+}
+
+func newStartupWarmup(rate int) *startupWarmup {
+	return &startupWarmup{rate: rate}
+}
+
+func (sw *startupWarmup) start(op *operation) {
+	sw.mu.Lock()
+	sw.op = op
+	sw.mu.Unlock()
+}
+
+func (sw *startupWarmup) addRecovered() {
+	sw.mu.Lock()
+	sw.recovered++
+	sw.mu.Unlock()
+}
+
+// StartupWarmup returns the progress of the reconciliation of the pinset
+// with IPFS that this peer does when it starts.
+func (c *Cluster) StartupWarmup(ctx context.Context) *api.StartupWarmup {
+	_, span := trace.StartSpan(ctx, "cluster/StartupWarmup")
+	defer span.End()
+
+	sw := c.startup
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	warmup := &api.StartupWarmup{
+		Peer:      c.id,
+		Peername:  c.config.Peername,
+		Rate:      sw.rate,
+		Recovered: sw.recovered,
+	}
+	switch {
+	case sw.op != nil:
+		warmup.Operation = sw.op.info()
+	case sw.empty != nil:
+		op := *sw.empty
+		warmup.Operation = &op
+	}
+	return warmup
+}
+
+// warmUpTracker recovers all the items tracked by this peer, like
+// RecoverAllLocal, at the configured rate. It is tracked as an operation.
+func (c *Cluster) warmUpTracker(ctx context.Context) {
+	ctx, span := trace.StartSpan(ctx, "cluster/warmUpTracker")
+	defer span.End()
+
+	// The status of all items is needed to count them, and it is
+	// obtained with a single request to IPFS.
+	statuses := c.tracker.StatusAll(ctx, api.TrackerStatusUndefined)
+	if len(statuses) == 0 {
+		// Not worth listing among the operations.
+		now := time.Now()
+		c.startup.mu.Lock()
+		c.startup.empty = &api.Operation{
+			Type:       api.OperationStartupWarmup,
+			Status:     api.OperationDone,
+			StartedAt:  now,
+			FinishedAt: now,
+		}
+		c.startup.mu.Unlock()
+		return
+	}
+	op := c.operations.start(ctx, api.OperationStartupWarmup, len(statuses))
+	c.startup.start(op)
+	logger.Infof("startup warm-up: reconciling %d items with IPFS", len(statuses))
+
+	var tick <-chan time.Time
+	if c.startup.rate > 0 {
+		interval := time.Second / time.Duration(c.startup.rate)
+		if interval <= 0 {
+			interval = time.Nanosecond
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for _, st := range statuses {
+		if !st.Status.Match(recoverStatuses) {
+			op.progress(1)
+			continue
+		}
+		if tick != nil {
+			select {
+			case <-tick:
+			case <-op.ctx.Done():
+			}
+		}
+		if op.canceled() {
+			break
+		}
+		_, err := c.tracker.Recover(op.ctx, st.Cid)
+		op.progress(1)
+		if err != nil {
+			logger.Errorf("startup warm-up: error recovering %s: %s", st.Cid, err)
+			continue
+		}
+		c.startup.addRecovered()
+	}
+	op.finish(nil)
+	logger.Infof("startup warm-up: finished")
+}
@@ -0,0 +1,57 @@
+package ipfscluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+func TestClusterStartupWarmup(t *testing.T) {
+	ctx := context.Background()
+	cl, _, ipfs, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	for _, ci := range []cid.Cid{test.Cid1, test.Cid2, test.Cid3} {
+		_, err := cl.Pin(ctx, ci, api.PinOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	pinDelay()
+
+	ipfs.pins.Delete(test.Cid1.String())
+	ipfs.pins.Delete(test.Cid2.String())
+
+	cl.startup = newStartupWarmup(4)
+	warmup := cl.StartupWarmup(ctx)
+	if warmup.Operation != nil || warmup.Rate != 4 {
+		t.Fatalf("unexpected warm-up before starting: %+v", warmup)
+	}
+
+	start := time.Now()
+	cl.warmUpTracker(ctx)
+	if time.Since(start) < 400*time.Millisecond {
+		t.Error("the warm-up should have been throttled")
+	}
+
+	warmup = cl.StartupWarmup(ctx)
+	if warmup.Peer != cl.id {
+		t.Error("expected the warm-up of the peer")
+	}
+	if warmup.Recovered != 2 {
+		t.Errorf("expected 2 items recovered, got %d", warmup.Recovered)
+	}
+	op := warmup.Operation
+	if op == nil || op.Type != api.OperationStartupWarmup {
+		t.Fatalf("expected a warm-up operation: %+v", op)
+	}
+	if op.Status != api.OperationDone || op.Done != 3 || op.Total != 3 {
+		t.Errorf("unexpected warm-up progress: %+v", op)
+	}
+}
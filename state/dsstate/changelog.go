@@ -0,0 +1,107 @@
+package dsstate
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+// DefaultMaxChanges is the number of changes kept by a ChangeLog. Asking
+// for changes older than that requires listing the full state.
+var DefaultMaxChanges = 10000
+
+// ChangeLog records the CIDs added to and removed from a state, each with
+// an increasing sequence number, so that it is possible to ask which pins
+// changed since a given sequence number.
+//
+// Only the most recent changes are kept, in memory. The sequence starts at
+// the creation time in nanoseconds, so that sequence numbers obtained
+// before a restart are older than any change kept by a new ChangeLog.
+type ChangeLog struct {
+	mu sync.Mutex
+	// changes after first are all in the log.
+	first   uint64
+	seq     uint64
+	max     int
+	changes []*api.PinChange
+}
+
+// NewChangeLog returns an empty ChangeLog.
+func NewChangeLog() *ChangeLog {
+	start := uint64(time.Now().UnixNano())
+	return &ChangeLog{
+		first: start,
+		seq:   start,
+		max:   DefaultMaxChanges,
+	}
+}
+
+// Record logs a change to the given CID.
+func (cl *ChangeLog) Record(c cid.Cid, removed bool) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	cl.seq++
+	cl.changes = append(cl.changes, &api.PinChange{
+		Sequence: cl.seq,
+		Cid:      c,
+		Removed:  removed,
+	})
+	if extra := len(cl.changes) - cl.max; extra > 0 {
+		cl.first = cl.changes[extra-1].Sequence
+		cl.changes = append(cl.changes[:0:0], cl.changes[extra:]...)
+	}
+}
+
+// reset forgets all changes, i.e. when the whole state is replaced.
+func (cl *ChangeLog) reset() {
+	cl.mu.Lock()
+	cl.seq++
+	cl.first = cl.seq
+	cl.changes = nil
+	cl.mu.Unlock()
+}
+
+// Since returns the changes with sequence numbers higher than since, with
+// only the latest change for every CID, along with the current sequence
+// number. A since of 0 returns no changes, only the current sequence
+// number. It returns api.ErrChangesUnavailable when the changes since that
+// sequence number are not known.
+func (cl *ChangeLog) Since(since uint64) ([]*api.PinChange, uint64, error) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if since == 0 {
+		return []*api.PinChange{}, cl.seq, nil
+	}
+	if since < cl.first || since > cl.seq {
+		return nil, cl.seq, api.ErrChangesUnavailable
+	}
+
+	i := sort.Search(len(cl.changes), func(i int) bool {
+		return cl.changes[i].Sequence > since
+	})
+
+	latest := make(map[cid.Cid]int)
+	var changes []*api.PinChange
+	for _, ch := range cl.changes[i:] {
+		chCopy := *ch
+		if j, ok := latest[ch.Cid]; ok {
+			changes[j] = nil
+		}
+		latest[ch.Cid] = len(changes)
+		changes = append(changes, &chCopy)
+	}
+
+	result := make([]*api.PinChange, 0, len(latest))
+	for _, ch := range changes {
+		if ch != nil {
+			result = append(result, ch)
+		}
+	}
+	return result, cl.seq, nil
+}
@@ -5,6 +5,7 @@ package dsstate
 import (
 	"context"
 	"io"
+	"time"
 
 	"github.com/ipfs/ipfs-cluster/api"
 	"github.com/ipfs/ipfs-cluster/state"
@@ -20,6 +21,9 @@ import (
 )
 
 var _ state.State = (*State)(nil)
+var _ state.NameLister = (*State)(nil)
+var _ state.IndexedLister = (*State)(nil)
+var _ state.ChangeLister = (*State)(nil)
 var _ state.BatchingState = (*BatchingState)(nil)
 
 var logger = logging.Logger("dsstate")
@@ -33,6 +37,8 @@ type State struct {
 	dsWrite     ds.Write
 	codecHandle codec.Handle
 	namespace   ds.Key
+	index       *Index
+	changes     *ChangeLog
 	// version     int
 }
 
@@ -59,6 +65,8 @@ func New(dstore ds.Datastore, namespace string, handle codec.Handle) (*State, er
 		dsWrite:     dstore,
 		codecHandle: handle,
 		namespace:   ds.NewKey(namespace),
+		index:       NewIndex(),
+		changes:     NewChangeLog(),
 	}
 
 	return st, nil
@@ -73,7 +81,13 @@ func (st *State) Add(ctx context.Context, c *api.Pin) error {
 	if err != nil {
 		return err
 	}
-	return st.dsWrite.Put(ctx, st.key(c.Cid), ps)
+	err = st.dsWrite.Put(ctx, st.key(c.Cid), ps)
+	if err != nil {
+		return err
+	}
+	st.index.Add(c)
+	st.changes.Record(c.Cid, false)
+	return nil
 }
 
 // Rm removes an existing Pin. It is a no-op when the
@@ -83,10 +97,12 @@ func (st *State) Rm(ctx context.Context, c cid.Cid) error {
 	defer span.End()
 
 	err := st.dsWrite.Delete(ctx, st.key(c))
-	if err == ds.ErrNotFound {
-		return nil
+	if err != nil && err != ds.ErrNotFound {
+		return err
 	}
-	return err
+	st.index.Remove(c)
+	st.changes.Record(c, true)
+	return nil
 }
 
 // Get returns a Pin from the store and whether it
@@ -171,6 +187,83 @@ func (st *State) List(ctx context.Context) ([]*api.Pin, error) {
 	return pins, nil
 }
 
+// ListByName returns the pins with the given name or, when prefix is true,
+// with names starting with the given string.
+func (st *State) ListByName(ctx context.Context, name string, prefix bool) ([]*api.Pin, error) {
+	ctx, span := trace.StartSpan(ctx, "state/dsstate/ListByName")
+	defer span.End()
+
+	return st.listIndexed(ctx, st.index.byNameF(name, prefix))
+}
+
+// ListByType returns the pins of the types included in the given filter.
+func (st *State) ListByType(ctx context.Context, filter api.PinType) ([]*api.Pin, error) {
+	ctx, span := trace.StartSpan(ctx, "state/dsstate/ListByType")
+	defer span.End()
+
+	return st.listIndexed(ctx, st.index.byTypeF(filter))
+}
+
+// ListByMetadata returns the pins with the given metadata key and value,
+// or with any value for that key when the value is empty.
+func (st *State) ListByMetadata(ctx context.Context, key, value string) ([]*api.Pin, error) {
+	ctx, span := trace.StartSpan(ctx, "state/dsstate/ListByMetadata")
+	defer span.End()
+
+	return st.listIndexed(ctx, st.index.byMetadataF(key, value))
+}
+
+// ListExpired returns the pins which have expired at the given time,
+// sorted by expiry date.
+func (st *State) ListExpired(ctx context.Context, t time.Time) ([]*api.Pin, error) {
+	ctx, span := trace.StartSpan(ctx, "state/dsstate/ListExpired")
+	defer span.End()
+
+	return st.listIndexed(ctx, st.index.expiredF(t))
+}
+
+// listIndexed returns the pins selected from the state indexes, which are
+// built on the first call.
+func (st *State) listIndexed(ctx context.Context, selectF func() []cid.Cid) ([]*api.Pin, error) {
+	cids, err := st.index.lookup(ctx, st.List, selectF)
+	if err != nil {
+		return nil, err
+	}
+
+	pins := make([]*api.Pin, 0, len(cids))
+	for _, c := range cids {
+		p, err := st.Get(ctx, c)
+		if err == state.ErrNotFound { // i.e. not committed yet
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		pins = append(pins, p)
+	}
+	return pins, nil
+}
+
+// Changes returns the changes to the state after the given sequence number,
+// along with the current sequence number.
+func (st *State) Changes(ctx context.Context, since uint64) ([]*api.PinChange, uint64, error) {
+	_, span := trace.StartSpan(ctx, "state/dsstate/Changes")
+	defer span.End()
+
+	return st.changes.Since(since)
+}
+
+// SetChangeLog replaces the ChangeLog used by the state, like SetIndex.
+func (st *State) SetChangeLog(cl *ChangeLog) {
+	st.changes = cl
+}
+
+// SetIndex replaces the Index used by the state. This allows sharing an
+// index which receives updates from elsewhere.
+func (st *State) SetIndex(idx *Index) {
+	st.index = idx
+}
+
 // Migrate migrates an older state version to the current one.
 // This is a no-op for now.
 func (st *State) Migrate(ctx context.Context, r io.Reader) error {
@@ -223,6 +316,10 @@ func (st *State) Marshal(w io.Writer) error {
 // Unmarshal does not empty the existing store from any values
 // before unmarshaling from the given reader.
 func (st *State) Unmarshal(r io.Reader) error {
+	// Entries are written directly to the datastore.
+	defer st.index.reset()
+	defer st.changes.reset()
+
 	dec := codec.NewDecoder(r, st.codecHandle)
 	for {
 		var entry serialEntry
@@ -312,6 +409,8 @@ func NewBatching(dstore ds.Batching, namespace string, handle codec.Handle) (*Ba
 		dsWrite:     batch,
 		codecHandle: handle,
 		namespace:   ds.NewKey(namespace),
+		index:       NewIndex(),
+		changes:     NewChangeLog(),
 	}
 
 	bst := &BatchingState{}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"testing"
+	"time"
 
 	"github.com/ipfs/ipfs-cluster/api"
 	"github.com/ipfs/ipfs-cluster/datastore/inmem"
@@ -13,6 +14,8 @@ import (
 )
 
 var testCid1, _ = cid.Decode("QmP63DkAFEnDYNjDYBpyNDfttu1fvUw99x1brscPzpqmmq")
+var testCid2, _ = cid.Decode("QmP63DkAFEnDYNjDYBpyNDfttu1fvUw99x1brscPzpqmma")
+var testCid3, _ = cid.Decode("QmP63DkAFEnDYNjDYBpyNDfttu1fvUw99x1brscPzpqmmb")
 var testPeerID1, _ = peer.Decode("QmXZrtE5jQwXNqCJMfHUTQkvhQ4ZAnqMnmzFMJfLewuabc")
 
 var c = &api.Pin{
@@ -137,3 +140,268 @@ func TestMarshalUnmarshal(t *testing.T) {
 		t.Error("expected different cid")
 	}
 }
+
+func TestListByName(t *testing.T) {
+	ctx := context.Background()
+	st := newState(t)
+	st.Add(ctx, c)
+
+	c2 := api.PinWithOpts(testCid2, api.PinOptions{Name: "test2"})
+	st.Add(ctx, c2)
+	st.Add(ctx, api.PinCid(testCid3))
+
+	pins, err := st.ListByName(ctx, "test", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 1 || !pins[0].Cid.Equals(c.Cid) {
+		t.Error("expected the pin named test")
+	}
+
+	pins, _ = st.ListByName(ctx, "test", true)
+	if len(pins) != 2 {
+		t.Error("expected two pins with the test prefix")
+	}
+
+	// Renames and removals are reflected in the index.
+	c2.Name = "other"
+	st.Add(ctx, c2)
+	st.Rm(ctx, c.Cid)
+	if pins, _ := st.ListByName(ctx, "test", true); len(pins) != 0 {
+		t.Error("expected no pins with the test prefix")
+	}
+	if pins, _ := st.ListByName(ctx, "other", false); len(pins) != 1 {
+		t.Error("expected the renamed pin")
+	}
+
+	buf := new(bytes.Buffer)
+	st.Add(ctx, c)
+	if err := st.Marshal(buf); err != nil {
+		t.Fatal(err)
+	}
+	st2 := newState(t)
+	st2.ListByName(ctx, "test", false) // builds the index
+	if err := st2.Unmarshal(buf); err != nil {
+		t.Fatal(err)
+	}
+	if pins, _ := st2.ListByName(ctx, "test", false); len(pins) != 1 {
+		t.Error("the index should be rebuilt after unmarshaling")
+	}
+}
+
+func TestListIndexed(t *testing.T) {
+	ctx := context.Background()
+	st := newState(t)
+	now := time.Now()
+
+	p1 := api.PinWithOpts(testCid1, api.PinOptions{
+		Metadata: map[string]string{"owner": "alice"},
+		ExpireAt: now.Add(-time.Minute),
+	})
+	p2 := api.PinWithOpts(testCid2, api.PinOptions{
+		Metadata: map[string]string{"owner": "bob"},
+		ExpireAt: now.Add(-time.Hour),
+	})
+	p3 := api.PinWithOpts(testCid3, api.PinOptions{
+		ExpireAt: now.Add(time.Hour),
+	})
+	p3.Type = api.MetaType
+	for _, p := range []*api.Pin{p1, p2, p3} {
+		st.Add(ctx, p)
+	}
+
+	if pins, _ := st.ListByType(ctx, api.MetaType); len(pins) != 1 || !pins[0].Cid.Equals(testCid3) {
+		t.Error("expected the meta pin")
+	}
+	if pins, _ := st.ListByType(ctx, api.DataType|api.MetaType); len(pins) != 3 {
+		t.Error("expected all pins")
+	}
+
+	if pins, _ := st.ListByMetadata(ctx, "owner", "bob"); len(pins) != 1 || !pins[0].Cid.Equals(testCid2) {
+		t.Error("expected the pin owned by bob")
+	}
+	if pins, _ := st.ListByMetadata(ctx, "owner", ""); len(pins) != 2 {
+		t.Error("expected two pins with an owner")
+	}
+
+	pins, err := st.ListExpired(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 2 || !pins[0].Cid.Equals(testCid2) || !pins[1].Cid.Equals(testCid1) {
+		t.Error("expected the expired pins sorted by expiry")
+	}
+
+	// Updates replace the indexed values.
+	p2.Metadata = nil
+	p2.ExpireAt = time.Time{}
+	st.Add(ctx, p2)
+	if pins, _ := st.ListByMetadata(ctx, "owner", ""); len(pins) != 1 {
+		t.Error("expected one pin with an owner")
+	}
+	if pins, _ := st.ListExpired(ctx, now); len(pins) != 1 {
+		t.Error("expected one expired pin")
+	}
+
+	st.Rm(ctx, testCid3)
+	if pins, _ := st.ListByType(ctx, api.MetaType); len(pins) != 0 {
+		t.Error("expected no meta pins")
+	}
+}
+
+func TestPersistentIndex(t *testing.T) {
+	ctx := context.Background()
+	store := inmem.New()
+	idxStore := inmem.New()
+
+	st, err := New(store, "", DefaultHandle())
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := NewPersistentIndex(ctx, idxStore)
+	st.SetIndex(idx)
+	p2 := api.PinWithOpts(testCid2, api.PinOptions{
+		Name:     "test2",
+		ExpireAt: time.Now().Add(-time.Minute),
+	})
+	st.Add(ctx, c)
+	st.Add(ctx, p2)
+	if pins, _ := st.ListByName(ctx, "test", true); len(pins) != 2 {
+		t.Fatal("expected two pins with the test prefix")
+	}
+	if err := idx.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The saved index is loaded and does not need the state.
+	idx = NewPersistentIndex(ctx, idxStore)
+	st.SetIndex(idx)
+	cids, err := idx.lookup(ctx, nil, idx.byNameF("test", true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cids) != 2 {
+		t.Error("expected the saved index to be loaded")
+	}
+	if pins, _ := st.ListExpired(ctx, time.Now()); len(pins) != 1 || !pins[0].Cid.Equals(testCid2) {
+		t.Error("expected the expired pin from the saved index")
+	}
+
+	// Only changes are saved.
+	st.Rm(ctx, testCid2)
+	if len(idx.dirty) != 1 {
+		t.Error("expected one changed pin")
+	}
+	if err := idx.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	idx = NewPersistentIndex(ctx, idxStore)
+	if cids, _ := idx.lookup(ctx, nil, idx.byNameF("test", true)); len(cids) != 1 {
+		t.Error("expected the removal to be saved")
+	}
+
+	// An index which was not closed is not loaded again.
+	idx = NewPersistentIndex(ctx, idxStore)
+	if idx.built {
+		t.Error("the index should be rebuilt when it was not closed")
+	}
+
+	// Nor one which is discarded.
+	idx.lookup(ctx, st.List, idx.byNameF("test", true))
+	idx.Close(ctx)
+	if err := DiscardPersistentIndex(ctx, idxStore); err != nil {
+		t.Fatal(err)
+	}
+	if idx = NewPersistentIndex(ctx, idxStore); idx.built {
+		t.Error("the discarded index should not be loaded")
+	}
+}
+
+func TestIndexChangesWhileBuilding(t *testing.T) {
+	ctx := context.Background()
+	idx := NewIndex()
+
+	list := func(ctx context.Context) ([]*api.Pin, error) {
+		// Changes received while the state is listed.
+		idx.Remove(testCid1)
+		idx.Add(api.PinWithOpts(testCid3, api.PinOptions{Name: "test3"}))
+		return []*api.Pin{c, api.PinWithOpts(testCid2, api.PinOptions{Name: "test2"})}, nil
+	}
+	cids, err := idx.lookup(ctx, list, idx.byNameF("test", true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cids) != 2 {
+		t.Fatal("expected two pins")
+	}
+	for _, ci := range cids {
+		if ci.Equals(testCid1) {
+			t.Error("the removed pin should not be indexed")
+		}
+	}
+}
+
+func TestChanges(t *testing.T) {
+	ctx := context.Background()
+	st := newState(t)
+
+	_, start, err := st.Changes(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st.Add(ctx, c)
+	st.Add(ctx, api.PinCid(testCid2))
+	st.Rm(ctx, testCid1)
+
+	changes, seq, err := st.Changes(ctx, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq != start+3 {
+		t.Error("expected three changes")
+	}
+	if len(changes) != 2 {
+		t.Fatal("expected the latest change for two cids")
+	}
+	if !changes[0].Cid.Equals(testCid2) || changes[0].Removed {
+		t.Error("expected testCid2 to be added first")
+	}
+	if !changes[1].Cid.Equals(testCid1) || !changes[1].Removed || changes[1].Sequence != seq {
+		t.Error("expected testCid1 to be removed last")
+	}
+
+	if changes, _, _ := st.Changes(ctx, seq); len(changes) != 0 {
+		t.Error("expected no changes")
+	}
+
+	if _, _, err := st.Changes(ctx, seq+1); err != api.ErrChangesUnavailable {
+		t.Error("expected ErrChangesUnavailable for future sequence numbers")
+	}
+
+	// Old changes are forgotten.
+	st.changes.max = 2
+	st.Add(ctx, c)
+	if _, _, err := st.Changes(ctx, start); err != api.ErrChangesUnavailable {
+		t.Error("expected ErrChangesUnavailable for forgotten changes")
+	}
+	changes, _, err = st.Changes(ctx, seq-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Removed {
+		t.Error("expected testCid1 to be added again")
+	}
+
+	buf := new(bytes.Buffer)
+	if err := st.Marshal(buf); err != nil {
+		t.Fatal(err)
+	}
+	_, seq, _ = st.Changes(ctx, 0)
+	if err := st.Unmarshal(buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := st.Changes(ctx, seq); err != api.ErrChangesUnavailable {
+		t.Error("changes should be unavailable after unmarshaling")
+	}
+}
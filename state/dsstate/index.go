@@ -0,0 +1,548 @@
+package dsstate
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	query "github.com/ipfs/go-datastore/query"
+	codec "github.com/ugorji/go/codec"
+)
+
+// Keys used by persistent indexes in their datastore.
+var (
+	indexRecordsKey  = ds.NewKey("/pins")
+	indexCompleteKey = ds.NewKey("/complete")
+)
+
+// Index keeps in-memory secondary indexes of the pins in a state: by name,
+// by pin type, by metadata key and value and by expiry date. They allow
+// filtered listings without going through the full state.
+//
+// The index is built from the state on the first lookup and kept up to date
+// by the State as pins are added and removed. When the underlying datastore
+// can be modified without going through the State (i.e. a replicated crdt
+// datastore), changes must be reported to the index with Add() and
+// Remove(). Changes reported while the index is being built are applied
+// once it is ready.
+//
+// Persistent indexes (see NewPersistentIndex) are saved to a datastore
+// when closed and loaded from it when created, so that they do not need to
+// be built from the full state after a restart.
+type Index struct {
+	// buildMu serializes builds and resets. Lookups and updates only
+	// take mu, so they are not blocked while the state is listed.
+	buildMu sync.Mutex
+
+	mu       sync.Mutex
+	built    bool
+	building bool
+	// changes received while building, nil for removals.
+	pending map[cid.Cid]*indexEntry
+
+	entries  map[cid.Cid]indexEntry
+	byName   map[string]cidSet
+	names    []string // sorted keys of byName
+	byType   map[api.PinType]cidSet
+	byMeta   map[string]map[string]cidSet
+	expiries []expiry // sorted by expiry date
+
+	// Only for persistent indexes: the CIDs which changed since the
+	// index was loaded. Nil when the saved index must be fully
+	// rewritten.
+	store ds.Datastore
+	dirty cidSet
+}
+
+type cidSet map[cid.Cid]struct{}
+
+// indexEntry holds the indexed fields of a pin, so that it can be removed
+// from the indexes without reading it. It is also the persisted form of
+// each pin in persistent indexes.
+type indexEntry struct {
+	Name     string            `codec:"n,omitempty"`
+	Type     api.PinType       `codec:"t,omitempty"`
+	Metadata map[string]string `codec:"m,omitempty"`
+	ExpireAt time.Time         `codec:"e,omitempty"`
+}
+
+type expiry struct {
+	at time.Time
+	c  cid.Cid
+}
+
+func (e expiry) before(other expiry) bool {
+	if !e.at.Equal(other.at) {
+		return e.at.Before(other.at)
+	}
+	return e.c.KeyString() < other.c.KeyString()
+}
+
+func newIndexEntry(pin *api.Pin) indexEntry {
+	e := indexEntry{
+		Name:     pin.Name,
+		Type:     pin.Type,
+		Metadata: pin.Metadata,
+	}
+	// Zero and unix-zero times mean no expiry (see Pin.ExpiredAt).
+	if !pin.ExpireAt.IsZero() && pin.ExpireAt.Unix() != 0 {
+		e.ExpireAt = pin.ExpireAt
+	}
+	return e
+}
+
+// NewIndex returns an empty, unbuilt Index.
+func NewIndex() *Index {
+	return &Index{}
+}
+
+// NewPersistentIndex returns an Index which is saved to the given datastore
+// by Close(). When the datastore holds an index saved by a previous Close(),
+// it is loaded and ready to use. Otherwise the index is built from the state
+// on the first lookup.
+//
+// The saved index is discarded until the next Close(), so that an index
+// which missed changes (i.e. after a crash) is never loaded.
+func NewPersistentIndex(ctx context.Context, store ds.Datastore) *Index {
+	idx := &Index{
+		store: store,
+	}
+
+	complete, err := store.Has(ctx, indexCompleteKey)
+	if err != nil {
+		logger.Warnf("cannot read the saved state index: %s", err)
+		return idx
+	}
+	if !complete {
+		return idx
+	}
+
+	if err := store.Delete(ctx, indexCompleteKey); err != nil {
+		logger.Warnf("cannot read the saved state index: %s", err)
+		return idx
+	}
+
+	entries, err := idx.loadEntries(ctx)
+	if err != nil {
+		logger.Warnf("cannot read the saved state index: %s", err)
+		return idx
+	}
+	idx.init()
+	for c, e := range entries {
+		idx.add(c, e)
+	}
+	idx.built = true
+	idx.dirty = make(cidSet)
+	logger.Debugf("state indexes loaded: %d pins", len(idx.entries))
+	return idx
+}
+
+// DiscardPersistentIndex makes sure that the index saved in the given
+// datastore is not loaded by NewPersistentIndex, i.e. because the state is
+// modified without updating the index.
+func DiscardPersistentIndex(ctx context.Context, store ds.Datastore) error {
+	err := store.Delete(ctx, indexCompleteKey)
+	if err != nil && err != ds.ErrNotFound {
+		return err
+	}
+	return nil
+}
+
+// Add indexes a pin which has been added or updated.
+func (idx *Index) Add(pin *api.Pin) {
+	e := newIndexEntry(pin)
+	idx.update(pin.Cid, &e)
+}
+
+// Remove removes a pin from the indexes.
+func (idx *Index) Remove(c cid.Cid) {
+	idx.update(c, nil)
+}
+
+func (idx *Index) update(c cid.Cid, e *indexEntry) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	switch {
+	case idx.building:
+		idx.pending[c] = e
+		return
+	case !idx.built:
+		return
+	}
+
+	if e != nil {
+		idx.add(c, *e)
+	} else {
+		idx.remove(c)
+	}
+	if idx.dirty != nil {
+		idx.dirty.add(c)
+	}
+}
+
+// reset drops the indexes so that they are built again on the next lookup.
+func (idx *Index) reset() {
+	idx.buildMu.Lock()
+	defer idx.buildMu.Unlock()
+
+	idx.mu.Lock()
+	idx.built = false
+	idx.entries = nil
+	idx.byName = nil
+	idx.names = nil
+	idx.byType = nil
+	idx.byMeta = nil
+	idx.expiries = nil
+	idx.dirty = nil
+	idx.mu.Unlock()
+}
+
+// Close saves a persistent index to its datastore, so that it can be
+// loaded by NewPersistentIndex. Only the pins which changed since the index
+// was loaded are written. It is a no-op for other indexes and for indexes
+// which have not been built.
+func (idx *Index) Close(ctx context.Context) error {
+	idx.buildMu.Lock()
+	defer idx.buildMu.Unlock()
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if idx.store == nil || !idx.built {
+		return nil
+	}
+
+	var toWrite cidSet
+	if idx.dirty != nil {
+		toWrite = idx.dirty
+	} else {
+		// Rewrite everything, removing the entries of pins which
+		// are gone.
+		saved, err := idx.loadEntries(ctx)
+		if err != nil {
+			return err
+		}
+		toWrite = make(cidSet, len(idx.entries)+len(saved))
+		for c := range saved {
+			toWrite.add(c)
+		}
+		for c := range idx.entries {
+			toWrite.add(c)
+		}
+	}
+
+	var w ds.Write = idx.store
+	batching, ok := idx.store.(ds.Batching)
+	var batch ds.Batch
+	if ok {
+		b, err := batching.Batch(ctx)
+		if err != nil {
+			return err
+		}
+		batch = b
+		w = b
+	}
+
+	handle := DefaultHandle()
+	for c := range toWrite {
+		k := indexRecordsKey.Child(cidToDsKey(c))
+		e, ok := idx.entries[c]
+		if !ok {
+			if err := w.Delete(ctx, k); err != nil && err != ds.ErrNotFound {
+				return err
+			}
+			continue
+		}
+		var buf bytes.Buffer
+		if err := codec.NewEncoder(&buf, handle).Encode(e); err != nil {
+			return err
+		}
+		if err := w.Put(ctx, k, buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	if batch != nil {
+		if err := batch.Commit(ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := idx.store.Put(ctx, indexCompleteKey, nil); err != nil {
+		return err
+	}
+	idx.dirty = make(cidSet)
+	logger.Debugf("state indexes saved: %d pins written", len(toWrite))
+	return nil
+}
+
+// loadEntries reads the index saved in the datastore.
+func (idx *Index) loadEntries(ctx context.Context) (map[cid.Cid]indexEntry, error) {
+	results, err := idx.store.Query(ctx, query.Query{
+		Prefix: indexRecordsKey.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	handle := DefaultHandle()
+	entries := make(map[cid.Cid]indexEntry)
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		c, err := dsKeyToCid(ds.NewKey(ds.NewKey(r.Key).BaseNamespace()))
+		if err != nil {
+			logger.Warn("bad index key (ignoring). key: ", r.Key, "error: ", err)
+			continue
+		}
+		var e indexEntry
+		if err := codec.NewDecoderBytes(r.Value, handle).Decode(&e); err != nil {
+			return nil, err
+		}
+		entries[c] = e
+	}
+	return entries, nil
+}
+
+func (s cidSet) add(c cid.Cid) {
+	s[c] = struct{}{}
+}
+
+func (idx *Index) init() {
+	idx.entries = make(map[cid.Cid]indexEntry)
+	idx.byName = make(map[string]cidSet)
+	idx.names = nil
+	idx.byType = make(map[api.PinType]cidSet)
+	idx.byMeta = make(map[string]map[string]cidSet)
+	idx.expiries = nil
+}
+
+func (idx *Index) add(c cid.Cid, e indexEntry) {
+	idx.remove(c)
+
+	idx.entries[c] = e
+
+	if e.Name != "" {
+		if idx.byName[e.Name] == nil {
+			idx.byName[e.Name] = make(cidSet)
+			i := sort.SearchStrings(idx.names, e.Name)
+			idx.names = append(idx.names, "")
+			copy(idx.names[i+1:], idx.names[i:])
+			idx.names[i] = e.Name
+		}
+		idx.byName[e.Name].add(c)
+	}
+
+	if idx.byType[e.Type] == nil {
+		idx.byType[e.Type] = make(cidSet)
+	}
+	idx.byType[e.Type].add(c)
+
+	for k, v := range e.Metadata {
+		values, ok := idx.byMeta[k]
+		if !ok {
+			values = make(map[string]cidSet)
+			idx.byMeta[k] = values
+		}
+		if values[v] == nil {
+			values[v] = make(cidSet)
+		}
+		values[v].add(c)
+	}
+
+	if !e.ExpireAt.IsZero() {
+		exp := expiry{at: e.ExpireAt, c: c}
+		i := sort.Search(len(idx.expiries), func(i int) bool {
+			return !idx.expiries[i].before(exp)
+		})
+		idx.expiries = append(idx.expiries, expiry{})
+		copy(idx.expiries[i+1:], idx.expiries[i:])
+		idx.expiries[i] = exp
+	}
+}
+
+func (idx *Index) remove(c cid.Cid) {
+	e, ok := idx.entries[c]
+	if !ok {
+		return
+	}
+	delete(idx.entries, c)
+
+	removeFrom := func(m map[string]cidSet, key string) bool {
+		if set, ok := m[key]; ok {
+			delete(set, c)
+			if len(set) == 0 {
+				delete(m, key)
+				return true
+			}
+		}
+		return false
+	}
+
+	if removeFrom(idx.byName, e.Name) {
+		i := sort.SearchStrings(idx.names, e.Name)
+		if i < len(idx.names) && idx.names[i] == e.Name {
+			idx.names = append(idx.names[:i], idx.names[i+1:]...)
+		}
+	}
+
+	if set, ok := idx.byType[e.Type]; ok {
+		delete(set, c)
+		if len(set) == 0 {
+			delete(idx.byType, e.Type)
+		}
+	}
+
+	for k, v := range e.Metadata {
+		values := idx.byMeta[k]
+		removeFrom(values, v)
+		if len(values) == 0 {
+			delete(idx.byMeta, k)
+		}
+	}
+
+	if !e.ExpireAt.IsZero() {
+		exp := expiry{at: e.ExpireAt, c: c}
+		i := sort.Search(len(idx.expiries), func(i int) bool {
+			return !idx.expiries[i].before(exp)
+		})
+		if i < len(idx.expiries) && idx.expiries[i].c.Equals(c) {
+			idx.expiries = append(idx.expiries[:i], idx.expiries[i+1:]...)
+		}
+	}
+}
+
+// build builds the indexes with the given list function when needed. The
+// state is listed without holding the index lock, and the changes received
+// meanwhile are applied on top of the listing.
+func (idx *Index) build(ctx context.Context, list func(context.Context) ([]*api.Pin, error)) error {
+	idx.buildMu.Lock()
+	defer idx.buildMu.Unlock()
+
+	idx.mu.Lock()
+	if idx.built {
+		idx.mu.Unlock()
+		return nil
+	}
+	idx.building = true
+	idx.pending = make(map[cid.Cid]*indexEntry)
+	idx.mu.Unlock()
+
+	pins, err := list(ctx)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	pending := idx.pending
+	idx.building = false
+	idx.pending = nil
+	if err != nil {
+		return err
+	}
+
+	idx.init()
+	for _, p := range pins {
+		if _, ok := pending[p.Cid]; !ok {
+			idx.add(p.Cid, newIndexEntry(p))
+		}
+	}
+	for c, e := range pending {
+		if e != nil {
+			idx.add(c, *e)
+		}
+	}
+	idx.built = true
+	logger.Debugf("state indexes built: %d pins", len(idx.entries))
+	return nil
+}
+
+// lookup builds the indexes with the given list function when needed and
+// returns the CIDs selected by the given function, which is called with
+// the lock held.
+func (idx *Index) lookup(ctx context.Context, list func(context.Context) ([]*api.Pin, error), selectF func() []cid.Cid) ([]cid.Cid, error) {
+	if err := idx.build(ctx, list); err != nil {
+		return nil, err
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return selectF(), nil
+}
+
+func (s cidSet) appendTo(cids []cid.Cid) []cid.Cid {
+	for c := range s {
+		cids = append(cids, c)
+	}
+	return cids
+}
+
+// byNameF selects the pins with the given name, or with names starting with
+// it when prefix is true.
+func (idx *Index) byNameF(name string, prefix bool) func() []cid.Cid {
+	return func() []cid.Cid {
+		if !prefix {
+			return idx.byName[name].appendTo(nil)
+		}
+
+		var cids []cid.Cid
+		for i := sort.SearchStrings(idx.names, name); i < len(idx.names); i++ {
+			n := idx.names[i]
+			if !strings.HasPrefix(n, name) {
+				break
+			}
+			cids = idx.byName[n].appendTo(cids)
+		}
+		return cids
+	}
+}
+
+// byTypeF selects the pins of the types included in the filter.
+func (idx *Index) byTypeF(filter api.PinType) func() []cid.Cid {
+	return func() []cid.Cid {
+		var cids []cid.Cid
+		for t, set := range idx.byType {
+			if t&filter > 0 {
+				cids = set.appendTo(cids)
+			}
+		}
+		return cids
+	}
+}
+
+// byMetadataF selects the pins with the given metadata key and value, or
+// with any value when value is empty.
+func (idx *Index) byMetadataF(key, value string) func() []cid.Cid {
+	return func() []cid.Cid {
+		values := idx.byMeta[key]
+		if value != "" {
+			return values[value].appendTo(nil)
+		}
+		var cids []cid.Cid
+		for _, set := range values {
+			cids = set.appendTo(cids)
+		}
+		return cids
+	}
+}
+
+// expiredF selects the pins which expired at the given time, sorted by
+// expiry date.
+func (idx *Index) expiredF(t time.Time) func() []cid.Cid {
+	return func() []cid.Cid {
+		var cids []cid.Cid
+		for _, exp := range idx.expiries {
+			if !exp.at.Before(t) {
+				break
+			}
+			cids = append(cids, exp.c)
+		}
+		return cids
+	}
+}
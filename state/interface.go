@@ -7,6 +7,7 @@ import (
 	"context"
 	"errors"
 	"io"
+	"time"
 
 	"github.com/ipfs/ipfs-cluster/api"
 
@@ -42,6 +43,38 @@ type ReadOnly interface {
 	Get(context.Context, cid.Cid) (*api.Pin, error)
 }
 
+// NameLister is implemented by states which can look up pins by name without
+// listing the full state.
+type NameLister interface {
+	// ListByName returns the pins with the given name or, when prefix is
+	// true, with names starting with the given string.
+	ListByName(ctx context.Context, name string, prefix bool) ([]*api.Pin, error)
+}
+
+// IndexedLister is implemented by states which keep secondary indexes of
+// pins, so that filtered listings do not need to go through the full state.
+type IndexedLister interface {
+	NameLister
+	// ListByType returns the pins of the types included in the filter.
+	ListByType(ctx context.Context, filter api.PinType) ([]*api.Pin, error)
+	// ListByMetadata returns the pins with the given metadata key and
+	// value, or with any value for that key when the value is empty.
+	ListByMetadata(ctx context.Context, key, value string) ([]*api.Pin, error)
+	// ListExpired returns the pins which have expired at the given time.
+	ListExpired(ctx context.Context, t time.Time) ([]*api.Pin, error)
+}
+
+// ChangeLister is implemented by states which keep track of the changes to
+// the pinset.
+type ChangeLister interface {
+	// Changes returns the changes after the given sequence number, along
+	// with the current one. Only the latest change to every CID is
+	// returned. A since of 0 returns just the current sequence number.
+	// When the changes are not known, it returns
+	// api.ErrChangesUnavailable.
+	Changes(ctx context.Context, since uint64) ([]*api.PinChange, uint64, error)
+}
+
 // WriteOnly represents the write side of a State.
 type WriteOnly interface {
 	// Add adds a pin to the State
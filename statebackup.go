@@ -0,0 +1,375 @@
+package ipfscluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/adder"
+	"github.com/ipfs/ipfs-cluster/adder/single"
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/state"
+
+	cid "github.com/ipfs/go-cid"
+	files "github.com/ipfs/go-ipfs-files"
+	merkledag "github.com/ipfs/go-merkledag"
+	unixfile "github.com/ipfs/go-unixfs/file"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	trace "go.opencensus.io/trace"
+)
+
+// On every StateBackup.Interval, the peer closest to stateBackupTarget adds
+// a backup of the pinset to IPFS and pins it. Full backups contain all the
+// pins. Differential backups contain the pins added or changed since the
+// previous backup, whose CID they record, and the CIDs of the pins removed
+// since then. Restoring a backup follows this lineage back to the last full
+// backup and applies the differential ones on top of it.
+//
+// Backup pins are recognized by their StateBackupMetaKey metadata and are
+// not included in the backups themselves. They are named after the time
+// they were taken, with a precision finer than the pin timestamps, so
+// that they sort in order.
+
+// Metadata keys of the backup pins.
+const (
+	// StateBackupMetaKey is set to the kind of backup, "full" or
+	// "diff".
+	StateBackupMetaKey = "state-backup"
+	// StateBackupBaseMetaKey is the CID of the full backup which
+	// differential backups depend on.
+	StateBackupBaseMetaKey = "state-backup-base"
+	// StateBackupPreviousMetaKey is the CID of the backup which
+	// differential backups are applied to.
+	StateBackupPreviousMetaKey = "state-backup-previous"
+	// StateBackupSequenceMetaKey is the number of differential backups
+	// since the full one.
+	StateBackupSequenceMetaKey = "state-backup-sequence"
+)
+
+const (
+	stateBackupFull = "full"
+	stateBackupDiff = "diff"
+
+	stateBackupVersion = 1
+	stateBackupTarget  = "state-backup"
+	stateBackupPrefix  = "cluster-state-backup-"
+	stateBackupLayout  = "20060102T150405.000000000Z"
+)
+
+// stateBackup is the document added to IPFS by every backup.
+type stateBackup struct {
+	Version   int       `json:"version"`
+	Kind      string    `json:"kind"`
+	Timestamp time.Time `json:"timestamp"`
+	// Previous is the backup which a differential backup applies to.
+	Previous string `json:"previous,omitempty"`
+	// Pins are all the pins in full backups, and the pins added or
+	// changed in differential backups.
+	Pins []*api.Pin `json:"pins"`
+	// Removed are the CIDs of the pins removed since the previous
+	// backup.
+	Removed []cid.Cid `json:"removed,omitempty"`
+}
+
+func (c *Cluster) watchStateBackup() {
+	ticker := time.NewTicker(c.config.StateBackup.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := c.backupState(c.ctx); err != nil {
+				logger.Errorf("error backing up the state: %s", err)
+			}
+		}
+	}
+}
+
+// backupState takes a backup if this peer is responsible for it. It
+// returns the CID of the backup, or cid.Undef when no backup was taken.
+func (c *Cluster) backupState(ctx context.Context) (cid.Cid, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/backupState")
+	defer span.End()
+
+	if c.config.FollowerMode {
+		return cid.Undef, nil
+	}
+	distance, err := c.distances(ctx, "")
+	if err != nil {
+		return cid.Undef, err
+	}
+	if !distance.isClosestKey(stateBackupTarget) {
+		return cid.Undef, nil
+	}
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		return cid.Undef, err
+	}
+	backups, err := stateBackupPins(ctx, cState)
+	if err != nil {
+		return cid.Undef, err
+	}
+	all, err := cState.List(ctx)
+	if err != nil {
+		return cid.Undef, err
+	}
+	pins := make([]*api.Pin, 0, len(all))
+	for _, p := range all {
+		if _, ok := p.Metadata[StateBackupMetaKey]; !ok {
+			pins = append(pins, p)
+		}
+	}
+
+	backup := &stateBackup{
+		Version:   stateBackupVersion,
+		Kind:      stateBackupFull,
+		Timestamp: time.Now(),
+		Pins:      pins,
+	}
+	meta := map[string]string{StateBackupMetaKey: stateBackupFull}
+
+	if len(backups) > 0 {
+		latest := backups[len(backups)-1]
+		seq, _ := strconv.Atoi(latest.Metadata[StateBackupSequenceMetaKey])
+		if seq+1 < c.config.StateBackup.FullEvery {
+			previous, err := RestoreStateBackup(ctx, c.ipfs, latest.Cid)
+			if err != nil {
+				logger.Warnf("taking a full backup: error reading the previous backup %s: %s", latest.Cid, err)
+			} else {
+				changed, removed := diffPins(previous, pins)
+				if len(changed) == 0 && len(removed) == 0 {
+					logger.Debug("no changes to back up since the previous backup")
+					return cid.Undef, nil
+				}
+				base := latest.Metadata[StateBackupBaseMetaKey]
+				if base == "" {
+					base = latest.Cid.String()
+				}
+				backup.Kind = stateBackupDiff
+				backup.Previous = latest.Cid.String()
+				backup.Pins = changed
+				backup.Removed = removed
+				meta = map[string]string{
+					StateBackupMetaKey:         stateBackupDiff,
+					StateBackupBaseMetaKey:     base,
+					StateBackupPreviousMetaKey: latest.Cid.String(),
+					StateBackupSequenceMetaKey: strconv.Itoa(seq + 1),
+				}
+			}
+		}
+	}
+
+	b, err := json.Marshal(backup)
+	if err != nil {
+		return cid.Undef, err
+	}
+	params := api.DefaultAddParams()
+	params.Name = stateBackupPrefix + backup.Timestamp.UTC().Format(stateBackupLayout)
+	params.ReplicationFactorMin = c.config.StateBackup.ReplicationFactor
+	params.ReplicationFactorMax = c.config.StateBackup.ReplicationFactor
+	params.Metadata = meta
+	dags := &stateBackupDAGService{
+		DAGService: single.New(c.rpcClient, params.PinOptions, false),
+		c:          c,
+		pinOpts:    params.PinOptions,
+	}
+	add := adder.New(dags, params, nil)
+	dir := files.NewSliceDirectory([]files.DirEntry{files.FileEntry("", files.NewBytesFile(b))})
+	root, err := add.FromFiles(ctx, dir)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("error adding the backup: %w", err)
+	}
+	logger.Infof("%s state backup %s taken with %d pins and %d removals", backup.Kind, root, len(backup.Pins), len(backup.Removed))
+
+	if backup.Kind == stateBackupFull {
+		c.pruneStateBackups(ctx, backups)
+	}
+	return root, nil
+}
+
+// stateBackupDAGService adds the backups like the single DAGService, but
+// commits their pins without going through the Cluster.Pin RPC, which
+// rejects the reserved backup metadata keys.
+type stateBackupDAGService struct {
+	*single.DAGService
+
+	c       *Cluster
+	pinOpts api.PinOptions
+}
+
+// SetExpectedSize records the size of the backup in its pin.
+func (dgs *stateBackupDAGService) SetExpectedSize(size uint64) {
+	dgs.pinOpts.ExpectedSize = size
+}
+
+// Finalize pins the backup.
+func (dgs *stateBackupDAGService) Finalize(ctx context.Context, root cid.Cid) (cid.Cid, error) {
+	pin := api.PinWithOpts(root, dgs.pinOpts)
+	pin.Mode = api.PinModeRecursive
+	_, _, err := dgs.c.pin(ctx, pin, []peer.ID{})
+	return root, err
+}
+
+// pruneStateBackups unpins the backups which depend on full backups older
+// than the last StateBackup.Keep ones, after a new full backup was taken.
+// The given backups do not include it.
+func (c *Cluster) pruneStateBackups(ctx context.Context, backups []*api.Pin) {
+	var fulls []cid.Cid
+	for _, p := range backups {
+		if p.Metadata[StateBackupMetaKey] == stateBackupFull {
+			fulls = append(fulls, p.Cid)
+		}
+	}
+	keep := c.config.StateBackup.Keep - 1
+	if len(fulls) <= keep {
+		return
+	}
+	kept := make(map[string]struct{}, keep)
+	for _, ci := range fulls[len(fulls)-keep:] {
+		kept[ci.String()] = struct{}{}
+	}
+
+	for _, p := range backups {
+		base := p.Metadata[StateBackupBaseMetaKey]
+		if base == "" {
+			base = p.Cid.String()
+		}
+		if _, ok := kept[base]; ok {
+			continue
+		}
+		if _, err := c.Unpin(ctx, p.Cid); err != nil {
+			logger.Errorf("error unpinning the old state backup %s: %s", p.Cid, err)
+			continue
+		}
+		logger.Infof("unpinned the old state backup %s", p.Cid)
+	}
+}
+
+// stateBackupPins returns the backup pins in the state, oldest first.
+func stateBackupPins(ctx context.Context, cState state.ReadOnly) ([]*api.Pin, error) {
+	var pins []*api.Pin
+	var err error
+	if il, ok := cState.(state.IndexedLister); ok {
+		pins, err = il.ListByMetadata(ctx, StateBackupMetaKey, "")
+	} else {
+		pins, err = cState.List(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	backups := make([]*api.Pin, 0, len(pins))
+	for _, p := range pins {
+		if _, ok := p.Metadata[StateBackupMetaKey]; ok {
+			backups = append(backups, p)
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Name < backups[j].Name
+	})
+	return backups, nil
+}
+
+// diffPins returns the pins of current which are not in previous or which
+// changed, and the CIDs of the pins of previous which are not in current.
+func diffPins(previous, current []*api.Pin) ([]*api.Pin, []cid.Cid) {
+	prev := make(map[cid.Cid][]byte, len(previous))
+	for _, p := range previous {
+		b, _ := json.Marshal(p)
+		prev[p.Cid] = b
+	}
+
+	var changed []*api.Pin
+	for _, p := range current {
+		b, _ := json.Marshal(p)
+		if old, ok := prev[p.Cid]; !ok || !bytes.Equal(old, b) {
+			changed = append(changed, p)
+		}
+		delete(prev, p.Cid)
+	}
+	var removed []cid.Cid
+	for ci := range prev {
+		removed = append(removed, ci)
+	}
+	return changed, removed
+}
+
+// readStateBackup reads a backup document from IPFS.
+func readStateBackup(ctx context.Context, ipfs IPFSConnector, h cid.Cid) (*stateBackup, error) {
+	dserv := merkledag.NewReadOnlyDagService(&blockGetter{ipfs: ipfs})
+	root, err := dserv.Get(ctx, h)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s from ipfs: %w", h, err)
+	}
+	node, err := unixfile.NewUnixfsFile(ctx, dserv, root)
+	if err != nil {
+		return nil, fmt.Errorf("error reading unixfs content: %w", err)
+	}
+	defer node.Close()
+	f, ok := node.(files.File)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a state backup", h)
+	}
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s from ipfs: %w", h, err)
+	}
+
+	var backup stateBackup
+	if err := json.Unmarshal(b, &backup); err != nil {
+		return nil, fmt.Errorf("%s is not a state backup: %w", h, err)
+	}
+	if backup.Version != stateBackupVersion {
+		return nil, fmt.Errorf("unsupported state backup version %d", backup.Version)
+	}
+	return &backup, nil
+}
+
+// RestoreStateBackup reads the state backup with the given CID, and the
+// backups it depends on, from IPFS and returns the pinset it contains.
+func RestoreStateBackup(ctx context.Context, ipfs IPFSConnector, h cid.Cid) ([]*api.Pin, error) {
+	var chain []*stateBackup
+	next := h
+	for {
+		backup, err := readStateBackup(ctx, ipfs, next)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, backup)
+		if backup.Kind == stateBackupFull {
+			break
+		}
+		previous, err := cid.Decode(backup.Previous)
+		if backup.Kind != stateBackupDiff || err != nil {
+			return nil, fmt.Errorf("state backup %s is invalid", next)
+		}
+		next = previous
+	}
+
+	pins := make(map[cid.Cid]*api.Pin)
+	for i := len(chain) - 1; i >= 0; i-- {
+		for _, p := range chain[i].Pins {
+			pins[p.Cid] = p
+		}
+		for _, ci := range chain[i].Removed {
+			delete(pins, ci)
+		}
+	}
+
+	list := make([]*api.Pin, 0, len(pins))
+	for _, p := range pins {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Cid.KeyString() < list[j].Cid.KeyString()
+	})
+	return list, nil
+}
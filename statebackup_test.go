@@ -0,0 +1,136 @@
+package ipfscluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+func TestClusterStateBackup(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+	cl.config.StateBackup.FullEvery = 3
+	cl.config.StateBackup.Keep = 1
+
+	backup := func() cid.Cid {
+		t.Helper()
+		h, err := cl.backupState(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pinDelay()
+		return h
+	}
+	restore := func(h cid.Cid) map[string]*api.Pin {
+		t.Helper()
+		pins, err := RestoreStateBackup(ctx, cl.ipfs, h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := make(map[string]*api.Pin, len(pins))
+		for _, p := range pins {
+			m[p.Cid.String()] = p
+		}
+		return m
+	}
+	kind := func(h cid.Cid) string {
+		t.Helper()
+		p, err := cl.PinGet(ctx, h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p.Metadata[StateBackupMetaKey]
+	}
+
+	_, err := cl.Pin(ctx, test.Cid1, api.PinOptions{Name: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cl.Pin(ctx, test.Cid2, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	full := backup()
+	if kind(full) != stateBackupFull {
+		t.Fatal("the first backup should be full")
+	}
+	if pins := restore(full); len(pins) != 2 || pins[test.Cid1.String()].Name != "a" {
+		t.Fatalf("unexpected pins in the full backup: %v", pins)
+	}
+
+	if h := backup(); h != cid.Undef {
+		t.Error("nothing should be backed up without changes")
+	}
+
+	_, err = cl.Unpin(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cl.Pin(ctx, test.Cid2, api.PinOptions{Name: "renamed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cl.Pin(ctx, test.Cid3, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	diff := backup()
+	if kind(diff) != stateBackupDiff {
+		t.Fatal("the second backup should be differential")
+	}
+	b, err := readStateBackup(ctx, cl.ipfs, diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Previous != full.String() || len(b.Pins) != 2 || len(b.Removed) != 1 {
+		t.Errorf("unexpected differential backup: %+v", b)
+	}
+	pins := restore(diff)
+	if len(pins) != 2 || pins[test.Cid2.String()].Name != "renamed" || pins[test.Cid3.String()] == nil {
+		t.Errorf("unexpected restored pins: %v", pins)
+	}
+
+	_, err = cl.Pin(ctx, test.Cid4, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+	if kind(backup()) != stateBackupDiff {
+		t.Fatal("the third backup should be differential")
+	}
+
+	_, err = cl.Pin(ctx, test.Cid5, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+	newFull := backup()
+	if kind(newFull) != stateBackupFull {
+		t.Fatal("the fourth backup should be full")
+	}
+	if pins := restore(newFull); len(pins) != 4 {
+		t.Errorf("unexpected pins in the new full backup: %v", pins)
+	}
+
+	// Only the last full backup is kept.
+	cState, err := cl.consensus.State(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backups, err := stateBackupPins(ctx, cState)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 || !backups[0].Cid.Equals(newFull) {
+		t.Errorf("the old backups were not unpinned: %v", backups)
+	}
+}
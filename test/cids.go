@@ -35,6 +35,22 @@ var (
 	PeerID8, _     = peer.Decode("12D3KooWFBFCDQzAkQSwPZLV883pKdsmb6urQ3sMjfJHUxn5GCVv")
 	PeerID9, _     = peer.Decode("12D3KooWKuJ8LPTyHbyX4nt4C7uWmUobzFsiceTVoFw7HpmoNakM")
 
+	// Namespace1 is the namespace of the mock pin for Cid1.
+	Namespace1 = "namespace1"
+
+	// PinName3 is the name of the mock pin for Cid3.
+	PinName3 = "testpin3"
+
+	// PinSequence1 is the current pinset sequence number of the mock
+	// cluster. Cid1 was pinned and Cid2 unpinned in the last changes.
+	PinSequence1 uint64 = 100
+
+	// OperationID1 is the ID of the operation known to the mock cluster.
+	OperationID1 = "0b3a8ac4-6c4e-4f4e-9b8e-6f1d0c9a2e11"
+	// OperationID2 is the ID given to operations started in the mock
+	// cluster.
+	OperationID2 = "5d2c7e1a-93b0-4c1e-8f27-2a6b4d9e0c53"
+
 	PeerName1 = "TestPeer1"
 	PeerName2 = "TestPeer2"
 	PeerName3 = "TestPeer3"
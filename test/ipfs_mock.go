@@ -31,6 +31,7 @@ const (
 	IpfsCustomHeaderValue = "42"
 	IpfsACAOrigin         = "myorigin"
 	IpfsErrFromNotPinned  = "'from' cid was not recursively pinned already"
+	IPFSAgentVersion      = "kubo/0.17.0/"
 )
 
 // IpfsMock is an ipfs daemon mock which should sustain the functionality used by ipfscluster.
@@ -62,14 +63,20 @@ type mockPinLsResp struct {
 	Keys map[string]mockPinType
 }
 
+type mockPinLsStreamResp struct {
+	Cid  string
+	Type string
+}
+
 type ipfsErr struct {
 	Code    int
 	Message string
 }
 
 type mockIDResp struct {
-	ID        string
-	Addresses []string
+	ID           string
+	Addresses    []string
+	AgentVersion string
 }
 
 type mockRepoStatResp struct {
@@ -101,6 +108,26 @@ type mockBlockPutResp struct {
 	Key string
 }
 
+type mockBlockStatResp struct {
+	Key  string
+	Size int
+}
+
+type mockBlockRmResp struct {
+	Hash  string
+	Error string `json:",omitempty"`
+}
+
+type mockObjectStatResp struct {
+	Hash           string
+	CumulativeSize uint64
+}
+
+type mockNamePublishResp struct {
+	Name  string
+	Value string
+}
+
 type mockRepoGCResp struct {
 	Key   cid.Cid `json:",omitempty"`
 	Error string  `json:",omitempty"`
@@ -180,32 +207,36 @@ func (m *IpfsMock) handler(w http.ResponseWriter, r *http.Request) {
 				"/ip4/0.0.0.0/tcp/1234",
 				"/ip6/::/tcp/1234",
 			},
+			AgentVersion: IPFSAgentVersion,
 		}
 		j, _ := json.Marshal(resp)
 		w.Write(j)
 	case "pin/add":
-		arg, ok := extractCid(r.URL)
+		// Several items can be pinned at once. None are pinned when
+		// one of them fails.
+		args, cids, ok := extractCids(r.URL)
 		if !ok {
 			goto ERROR
 		}
-		if arg == ErrorCid.String() {
-			goto ERROR
-		}
-		c, err := cid.Decode(arg)
-		if err != nil {
-			goto ERROR
+		slow := false
+		for _, c := range cids {
+			if c.Equals(ErrorCid) {
+				goto ERROR
+			}
+			slow = slow || c.Equals(SlowCid1)
 		}
 		mode := extractMode(r.URL)
 		opts := api.PinOptions{
 			Mode: mode,
 		}
-		pinObj := api.PinWithOpts(c, opts)
-		m.pinMap.Add(ctx, pinObj)
+		for _, c := range cids {
+			m.pinMap.Add(ctx, api.PinWithOpts(c, opts))
+		}
 		resp := mockPinResp{
-			Pins: []string{arg},
+			Pins: args,
 		}
 
-		if c.Equals(SlowCid1) {
+		if slow {
 			for i := 0; i <= 10; i++ {
 				time.Sleep(1 * time.Second)
 				resp.Progress = i
@@ -217,17 +248,20 @@ func (m *IpfsMock) handler(w http.ResponseWriter, r *http.Request) {
 			w.Write(j)
 		}
 	case "pin/rm":
-		arg, ok := extractCid(r.URL)
+		args, cids, ok := extractCids(r.URL)
 		if !ok {
 			goto ERROR
 		}
-		c, err := cid.Decode(arg)
-		if err != nil {
-			goto ERROR
+		for _, c := range cids {
+			if c.Equals(ErrorCid) {
+				goto ERROR
+			}
+		}
+		for _, c := range cids {
+			m.pinMap.Rm(ctx, c)
 		}
-		m.pinMap.Rm(ctx, c)
 		resp := mockPinResp{
-			Pins: []string{arg},
+			Pins: args,
 		}
 		j, _ := json.Marshal(resp)
 		w.Write(j)
@@ -270,11 +304,23 @@ func (m *IpfsMock) handler(w http.ResponseWriter, r *http.Request) {
 	case "pin/ls":
 		arg, ok := extractCid(r.URL)
 		if !ok {
-			rMap := make(map[string]mockPinType)
 			pins, err := m.pinMap.List(ctx)
 			if err != nil {
 				goto ERROR
 			}
+			if r.URL.Query().Get("stream") == "true" {
+				typeFilter := r.URL.Query().Get("type")
+				enc := json.NewEncoder(w)
+				for _, p := range pins {
+					mode := p.Mode.String()
+					if typeFilter != "" && typeFilter != "all" && typeFilter != mode {
+						continue
+					}
+					enc.Encode(mockPinLsStreamResp{p.Cid.String(), mode})
+				}
+				break
+			}
+			rMap := make(map[string]mockPinType)
 			for _, p := range pins {
 				rMap[p.Cid.String()] = mockPinType{p.Mode.String()}
 			}
@@ -395,6 +441,57 @@ func (m *IpfsMock) handler(w http.ResponseWriter, r *http.Request) {
 			goto ERROR
 		}
 		w.Write(data)
+	case "block/rm":
+		arg := r.URL.Query().Get("arg")
+		resp := mockBlockRmResp{Hash: arg}
+		if _, ok := m.BlockStore[arg]; !ok {
+			resp.Error = "blockstore: block not found"
+		}
+		delete(m.BlockStore, arg)
+		j, _ := json.Marshal(resp)
+		w.Write(j)
+	case "block/stat":
+		arg := r.URL.Query().Get("arg")
+		data, ok := m.BlockStore[arg]
+		if !ok {
+			goto ERROR
+		}
+		j, _ := json.Marshal(mockBlockStatResp{
+			Key:  arg,
+			Size: len(data),
+		})
+		w.Write(j)
+	case "object/stat":
+		arg := r.URL.Query().Get("arg")
+		if arg == "" || arg == ErrorCid.String() {
+			goto ERROR
+		}
+		// DAGs not in the blockstore are 1MB big.
+		size := uint64(1 << 20)
+		if data, ok := m.BlockStore[arg]; ok {
+			size = uint64(len(data))
+		}
+		j, _ := json.Marshal(mockObjectStatResp{
+			Hash:           arg,
+			CumulativeSize: size,
+		})
+		w.Write(j)
+	case "name/publish":
+		arg := r.URL.Query().Get("arg")
+		key := r.URL.Query().Get("key")
+		if arg == "" || arg == "/ipfs/"+ErrorCid.String() || key == "" {
+			goto ERROR
+		}
+		// The name is the peer ID for the "self" key.
+		name := PeerID1.String()
+		if key != "self" {
+			name = PeerID2.String()
+		}
+		j, _ := json.Marshal(mockNamePublishResp{
+			Name:  name,
+			Value: arg,
+		})
+		w.Write(j)
 	case "repo/gc":
 		// It assumes `/repo/gc` with parameter `stream-errors=true`
 		enc := json.NewEncoder(w)
@@ -469,6 +566,11 @@ func (m *IpfsMock) handler(w http.ResponseWriter, r *http.Request) {
 		} else {
 			w.Write(j)
 		}
+	case "routing/provide":
+		if _, ok := extractCid(r.URL); !ok {
+			goto ERROR
+		}
+		w.Write([]byte("{\"Type\":4}"))
 	case "version":
 		w.Write([]byte("{\"Version\":\"m.o.c.k\"}"))
 	default:
@@ -508,6 +610,24 @@ func extractCid(u *url.URL) (string, bool) {
 	return "", false
 }
 
+// extractCids returns all the "arg" values of a request, which must be
+// CIDs.
+func extractCids(u *url.URL) ([]string, []cid.Cid, bool) {
+	args := u.Query()["arg"]
+	if len(args) == 0 {
+		return nil, nil, false
+	}
+	cids := make([]cid.Cid, len(args))
+	for i, arg := range args {
+		c, err := cid.Decode(arg)
+		if err != nil {
+			return nil, nil, false
+		}
+		cids[i] = c
+	}
+	return args, cids, true
+}
+
 func extractMode(u *url.URL) api.PinMode {
 	return api.PinModeFromString(u.Query().Get("type"))
 }
@@ -3,6 +3,7 @@ package test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
@@ -74,6 +75,25 @@ func (mock *mockCluster) Pin(ctx context.Context, in *api.Pin, out *api.Pin) err
 	return nil
 }
 
+func (mock *mockCluster) CheckFreeSpace(ctx context.Context, in api.PinOptions, out *struct{}) error {
+	// The mock cluster has 3 peers.
+	if in.ReplicationFactorMin > 3 {
+		return fmt.Errorf("%w: 3 peers have 1000 bytes free, %d needed", api.ErrInsufficientFreeSpace, in.ReplicationFactorMin)
+	}
+	return nil
+}
+
+func (mock *mockCluster) PinDryRun(ctx context.Context, in *api.Pin, out *api.Pin) error {
+	if in.Cid.Equals(ErrorCid) {
+		return ErrBadCid
+	}
+	*out = *in
+	out.ReplicationFactorMin = 2
+	out.ReplicationFactorMax = 2
+	out.Allocations = []peer.ID{PeerID1, PeerID2}
+	return nil
+}
+
 func (mock *mockCluster) Unpin(ctx context.Context, in *api.Pin, out *api.Pin) error {
 	if in.Cid.Equals(ErrorCid) {
 		return ErrBadCid
@@ -85,6 +105,28 @@ func (mock *mockCluster) Unpin(ctx context.Context, in *api.Pin, out *api.Pin) e
 	return nil
 }
 
+func (mock *mockCluster) Unprotect(ctx context.Context, in cid.Cid, out *api.Pin) error {
+	if in.Equals(ErrorCid) {
+		return ErrBadCid
+	}
+	if in.Equals(NotFoundCid) {
+		return state.ErrNotFound
+	}
+	*out = *api.PinCid(in)
+	return nil
+}
+
+func (mock *mockCluster) Restore(ctx context.Context, in cid.Cid, out *api.Pin) error {
+	if in.Equals(ErrorCid) {
+		return ErrBadCid
+	}
+	if in.Equals(NotFoundCid) {
+		return state.ErrNotFound
+	}
+	*out = *api.PinCid(in)
+	return nil
+}
+
 func (mock *mockCluster) PinPath(ctx context.Context, in *api.PinPath, out *api.Pin) error {
 	p, err := gopath.ParsePath(in.Path)
 	if err != nil {
@@ -124,14 +166,236 @@ func (mock *mockCluster) Pins(ctx context.Context, in struct{}, out *[]*api.Pin)
 		ReplicationFactorMax: -1,
 	}
 
+	pin1 := api.PinWithOpts(Cid1, opts)
+	pin1.Namespace = Namespace1
+
+	opts.Name = PinName3
+
 	*out = []*api.Pin{
-		api.PinWithOpts(Cid1, opts),
+		pin1,
 		api.PinCid(Cid2),
 		api.PinWithOpts(Cid3, opts),
 	}
 	return nil
 }
 
+func (mock *mockCluster) PinsByName(ctx context.Context, in string, out *[]*api.Pin) error {
+	var pins []*api.Pin
+	mock.Pins(ctx, struct{}{}, &pins)
+	*out = make([]*api.Pin, 0)
+	for _, p := range pins {
+		if p.Name == in {
+			*out = append(*out, p)
+		}
+	}
+	return nil
+}
+
+func (mock *mockCluster) PinsByNamePrefix(ctx context.Context, in string, out *[]*api.Pin) error {
+	var pins []*api.Pin
+	mock.Pins(ctx, struct{}{}, &pins)
+	*out = make([]*api.Pin, 0)
+	for _, p := range pins {
+		if strings.HasPrefix(p.Name, in) {
+			*out = append(*out, p)
+		}
+	}
+	return nil
+}
+
+// PinTemplateName is the name of the only pin template known to the mock.
+const PinTemplateName = "default"
+
+func mockPinTemplate() *api.PinTemplate {
+	return &api.PinTemplate{
+		Name: PinTemplateName,
+		Options: api.PinOptions{
+			ReplicationFactorMin: 2,
+			ReplicationFactorMax: 3,
+			Metadata:             map[string]string{"team": "a"},
+		},
+		ExpireIn: 24 * time.Hour,
+	}
+}
+
+func (mock *mockCluster) PinTemplate(ctx context.Context, in string, out *api.PinTemplate) error {
+	if in != PinTemplateName {
+		return api.ErrPinTemplateNotFound
+	}
+	*out = *mockPinTemplate()
+	return nil
+}
+
+func (mock *mockCluster) PinTemplates(ctx context.Context, in struct{}, out *[]*api.PinTemplate) error {
+	*out = []*api.PinTemplate{mockPinTemplate()}
+	return nil
+}
+
+func (mock *mockCluster) PinTemplateSet(ctx context.Context, in *api.PinTemplate, out *struct{}) error {
+	if in.Name == "" {
+		return errors.New("invalid pin template name")
+	}
+	return nil
+}
+
+func (mock *mockCluster) PinTemplateRemove(ctx context.Context, in string, out *struct{}) error {
+	if in != PinTemplateName {
+		return api.ErrPinTemplateNotFound
+	}
+	return nil
+}
+
+// PinsetSnapshotName is the name of the only pinset snapshot known to the
+// mock.
+const PinsetSnapshotName = "before-cleanup"
+
+func mockPinsetSnapshot() *api.PinsetSnapshot {
+	return &api.PinsetSnapshot{
+		Name:      PinsetSnapshotName,
+		Timestamp: time.Now(),
+		NumPins:   1,
+	}
+}
+
+func (mock *mockCluster) PinsetSnapshotCreate(ctx context.Context, in string, out *api.PinsetSnapshot) error {
+	if in == PinsetSnapshotName {
+		return errors.New("pinset snapshot already exists")
+	}
+	snap := mockPinsetSnapshot()
+	snap.Name = in
+	*out = *snap
+	return nil
+}
+
+func (mock *mockCluster) PinsetSnapshot(ctx context.Context, in string, out *api.PinsetSnapshot) error {
+	if in != PinsetSnapshotName {
+		return api.ErrPinsetSnapshotNotFound
+	}
+	snap := mockPinsetSnapshot()
+	pin := api.PinCid(Cid1)
+	pin.Namespace = Namespace1
+	pin.ExpectedSize = 100
+	snap.Pins = []*api.Pin{pin}
+	*out = *snap
+	return nil
+}
+
+func (mock *mockCluster) PinsetSnapshots(ctx context.Context, in struct{}, out *[]*api.PinsetSnapshot) error {
+	*out = []*api.PinsetSnapshot{mockPinsetSnapshot()}
+	return nil
+}
+
+func (mock *mockCluster) PinsetSnapshotRemove(ctx context.Context, in string, out *struct{}) error {
+	if in != PinsetSnapshotName {
+		return api.ErrPinsetSnapshotNotFound
+	}
+	return nil
+}
+
+func (mock *mockCluster) PinsetRollback(ctx context.Context, in api.PinsetRollback, out *api.PinsetRollback) error {
+	if in.Snapshot != PinsetSnapshotName {
+		return api.ErrPinsetSnapshotNotFound
+	}
+	*out = api.PinsetRollback{
+		Snapshot: in.Snapshot,
+		DryRun:   in.DryRun,
+		Pinned:   []cid.Cid{Cid1},
+		Unpinned: []cid.Cid{Cid2},
+	}
+	return nil
+}
+
+func (mock *mockCluster) DenylistEntries(ctx context.Context, in struct{}, out *[]*api.DenylistEntry) error {
+	*out = []*api.DenylistEntry{
+		{Rule: "/ipfs/" + Cid4.String(), Source: "api"},
+	}
+	return nil
+}
+
+func (mock *mockCluster) DenylistAdd(ctx context.Context, in []*api.DenylistEntry, out *struct{}) error {
+	for _, e := range in {
+		if e.Rule == "" {
+			return errors.New("invalid rule")
+		}
+	}
+	return nil
+}
+
+func (mock *mockCluster) DenylistRemove(ctx context.Context, in string, out *struct{}) error {
+	if in != "/ipfs/"+Cid4.String() {
+		return api.ErrDenylistEntryNotFound
+	}
+	return nil
+}
+
+func (mock *mockCluster) DenylistCheck(ctx context.Context, in cid.Cid, out *struct{}) error {
+	if in.Equals(Cid4) {
+		return api.ErrDenylisted
+	}
+	return nil
+}
+
+func (mock *mockCluster) DenylistEnforce(ctx context.Context, in struct{}, out *[]*api.Pin) error {
+	*out = []*api.Pin{api.PinCid(Cid4)}
+	return nil
+}
+
+// matchingPin returns a pin carrying the selector metadata and namespace,
+// so that tests can check the selector which was received.
+func matchingPin(sel api.PinSelector) (*api.Pin, error) {
+	if len(sel.Metadata) == 0 {
+		return nil, api.ErrEmptySelector
+	}
+	pin := api.PinCid(Cid1)
+	pin.Metadata = sel.Metadata
+	pin.Namespace = sel.Namespace
+	return pin, nil
+}
+
+func (mock *mockCluster) UnpinMatching(ctx context.Context, in api.PinSelector, out *[]*api.Pin) error {
+	pin, err := matchingPin(in)
+	if err != nil {
+		return err
+	}
+	*out = []*api.Pin{pin}
+	return nil
+}
+
+func (mock *mockCluster) RecoverMatching(ctx context.Context, in api.PinSelector, out *[]*api.GlobalPinInfo) error {
+	if _, err := matchingPin(in); err != nil {
+		return err
+	}
+	var gpi api.GlobalPinInfo
+	if err := mock.Status(ctx, Cid1, &gpi); err != nil {
+		return err
+	}
+	*out = []*api.GlobalPinInfo{&gpi}
+	return nil
+}
+
+func (mock *mockCluster) ReplicateMatching(ctx context.Context, in api.BulkReplication, out *[]*api.Pin) error {
+	pin, err := matchingPin(in.Selector)
+	if err != nil {
+		return err
+	}
+	pin.ReplicationFactorMin = in.ReplicationFactorMin
+	pin.ReplicationFactorMax = in.ReplicationFactorMax
+	*out = []*api.Pin{pin}
+	return nil
+}
+
+func (mock *mockCluster) PinsByType(ctx context.Context, in api.PinType, out *[]*api.Pin) error {
+	var pins []*api.Pin
+	mock.Pins(ctx, struct{}{}, &pins)
+	*out = make([]*api.Pin, 0)
+	for _, p := range pins {
+		if p.Type&in > 0 {
+			*out = append(*out, p)
+		}
+	}
+	return nil
+}
+
 func (mock *mockCluster) PinGet(ctx context.Context, in cid.Cid, out *api.Pin) error {
 	switch in.String() {
 	case ErrorCid.String():
@@ -140,6 +404,9 @@ func (mock *mockCluster) PinGet(ctx context.Context, in cid.Cid, out *api.Pin) e
 		p := api.PinCid(in)
 		p.ReplicationFactorMin = -1
 		p.ReplicationFactorMax = -1
+		if in.Equals(Cid1) {
+			p.Namespace = Namespace1
+		}
 		*out = *p
 		return nil
 	case Cid2.String(): // This is a remote pin
@@ -197,6 +464,118 @@ func (mock *mockCluster) PeerRemove(ctx context.Context, in peer.ID, out *struct
 	return nil
 }
 
+func (mock *mockCluster) ResolvePeer(ctx context.Context, in string, out *peer.ID) error {
+	if pid, err := peer.Decode(in); err == nil {
+		*out = pid
+		return nil
+	}
+	switch in {
+	case PeerName1:
+		*out = PeerID1
+	case PeerName2:
+		return fmt.Errorf("%w: %s", api.ErrAmbiguousPeername, in)
+	default:
+		return fmt.Errorf("%w: %s", api.ErrPeerNotFound, in)
+	}
+	return nil
+}
+
+func (mock *mockCluster) Peer(ctx context.Context, in string, out *api.ID) error {
+	var pid peer.ID
+	if err := mock.ResolvePeer(ctx, in, &pid); err != nil {
+		return err
+	}
+	return mock.ID(ctx, struct{}{}, out)
+}
+
+func (mock *mockCluster) Resources(ctx context.Context, in string, out *api.PeerResources) error {
+	var pid peer.ID
+	if err := mock.ResolvePeer(ctx, in, &pid); err != nil {
+		return err
+	}
+	*out = api.PeerResources{
+		Peer:             pid,
+		MemorySys:        64 << 20,
+		Goroutines:       100,
+		NumCPU:           4,
+		BandwidthTotalIn: 1024,
+		IPFSRepoStat: &api.IPFSRepoStat{
+			RepoSize:   1000,
+			StorageMax: 10000,
+		},
+	}
+	return nil
+}
+
+func (mock *mockCluster) RotateSecret(ctx context.Context, in string, out *api.SecretRotation) error {
+	if in == "" {
+		in = "2588b80d5cb05374fa142aed6cbb047d1f4ef8ef15e37eba68c65b9d30df67ed"
+	}
+	*out = api.SecretRotation{
+		Secret: in,
+		Peers:  []peer.ID{PeerID1, PeerID2, PeerID3},
+	}
+	return nil
+}
+
+func (mock *mockCluster) RotateSecretLocal(ctx context.Context, in api.SecretRotationStep, out *struct{}) error {
+	return nil
+}
+
+func (mock *mockCluster) PeerHandover(ctx context.Context, in api.PeerHandover, out *api.PeerHandover) error {
+	if in.From == in.To {
+		return errors.New("cannot hand over the allocations of a peer to itself")
+	}
+	*out = api.PeerHandover{
+		From: in.From,
+		To:   in.To,
+		Pins: 3,
+	}
+	return nil
+}
+
+func (mock *mockCluster) PeerVersions(ctx context.Context, in struct{}, out *api.PeerVersions) error {
+	*out = api.PeerVersions{
+		Peers: []*api.PeerVersion{
+			{
+				ID:                 PeerID1,
+				Version:            "0.0.mock",
+				RPCProtocolVersion: "/ipfscluster/0.12/rpc",
+				IPFSVersion:        IPFSAgentVersion,
+			},
+			{
+				ID:                 PeerID2,
+				RPCProtocolVersion: "/ipfscluster/0.13/rpc",
+				Error:              "protocol not supported",
+			},
+		},
+		Versions:            map[string]int{"0.0.mock": 1},
+		RPCProtocolVersions: map[string]int{"/ipfscluster/0.12/rpc": 1, "/ipfscluster/0.13/rpc": 1},
+		IPFSVersions:        map[string]int{IPFSAgentVersion: 1},
+		RPCProtocolSkew:     true,
+	}
+	return nil
+}
+
+func (mock *mockCluster) ConsensusStats(ctx context.Context, in struct{}, out *[]*api.ConsensusStats) error {
+	*out = []*api.ConsensusStats{
+		{
+			Peer: PeerID1,
+			CRDT: &api.CRDTStats{Heads: 1, Height: 10},
+		},
+		{
+			Peer:    PeerID2,
+			CRDT:    &api.CRDTStats{Heads: 2, Height: 7, QueuedUpdates: 3},
+			SyncLag: 3,
+		},
+		{
+			Peer:  PeerID3,
+			Error: "peer not reachable",
+		},
+	}
+	return nil
+}
+
 func (mock *mockCluster) ConnectGraph(ctx context.Context, in struct{}, out *api.ConnectGraph) error {
 	*out = api.ConnectGraph{
 		ClusterID: PeerID1,
@@ -215,6 +594,50 @@ func (mock *mockCluster) ConnectGraph(ctx context.Context, in struct{}, out *api
 			peer.Encode(PeerID2): PeerID5,
 			peer.Encode(PeerID3): PeerID6,
 		},
+		ClusterConnections: map[string][]*api.ConnectionInfo{
+			peer.Encode(PeerID1): {
+				{Peer: PeerID2, Direction: api.ConnectionOutbound, Transport: "quic", Latency: 5 * time.Millisecond},
+				{Peer: PeerID3, Direction: api.ConnectionInbound, Transport: "tcp", Latency: 12 * time.Millisecond},
+			},
+		},
+	}
+	return nil
+}
+
+func (mock *mockCluster) ConnectivityHistory(ctx context.Context, in time.Time, out *[]*api.ConnectivitySnapshot) error {
+	now := time.Now()
+	snaps := []*api.ConnectivitySnapshot{
+		{
+			From:         now.Add(-2 * time.Hour),
+			To:           now.Add(-time.Hour),
+			Peers:        []peer.ID{PeerID1, PeerID2},
+			Unreachable:  []peer.ID{PeerID2},
+			ClusterLinks: map[string][]peer.ID{peer.Encode(PeerID1): {}},
+			Partitions:   1,
+		},
+		{
+			From:  now.Add(-time.Hour),
+			To:    now,
+			Peers: []peer.ID{PeerID1, PeerID2},
+			ClusterLinks: map[string][]peer.ID{
+				peer.Encode(PeerID1): {PeerID2},
+				peer.Encode(PeerID2): {PeerID1},
+			},
+			Partitions: 1,
+		},
+	}
+	*out = make([]*api.ConnectivitySnapshot, 0, len(snaps))
+	for _, s := range snaps {
+		if in.IsZero() || !s.To.Before(in) {
+			*out = append(*out, s)
+		}
+	}
+	return nil
+}
+
+func (mock *mockCluster) Connections(ctx context.Context, in struct{}, out *[]*api.ConnectionInfo) error {
+	*out = []*api.ConnectionInfo{
+		{Peer: PeerID2, Direction: api.ConnectionOutbound, Transport: "quic", Latency: 5 * time.Millisecond},
 	}
 	return nil
 }
@@ -291,10 +714,209 @@ func (mock *mockCluster) Status(ctx context.Context, in cid.Cid, out *api.Global
 	return nil
 }
 
+func (mock *mockCluster) Shards(ctx context.Context, in cid.Cid, out *[]*api.ShardInfo) error {
+	if in.Equals(ErrorCid) {
+		return ErrBadCid
+	}
+	var shards []*api.ShardInfo
+	for i, ci := range []cid.Cid{Cid2, Cid3} {
+		var status api.GlobalPinInfo
+		mock.Status(ctx, ci, &status)
+		shards = append(shards, &api.ShardInfo{
+			Cid:         ci,
+			Name:        fmt.Sprintf("shard-%d", i),
+			Size:        1024,
+			Allocations: []peer.ID{PeerID1},
+			Status:      &status,
+		})
+	}
+	*out = shards
+	return nil
+}
+
+func (mock *mockCluster) Reshard(ctx context.Context, in *api.ReshardRequest, out *api.Operation) error {
+	if in.Cid.Equals(ErrorCid) {
+		return ErrBadCid
+	}
+	*out = api.Operation{
+		ID:        OperationID2,
+		Type:      api.OperationReshard,
+		Cid:       in.Cid,
+		Status:    api.OperationRunning,
+		StartedAt: time.Now(),
+	}
+	return nil
+}
+
+func (mock *mockCluster) Reconstruct(ctx context.Context, in cid.Cid, out *api.ErasureReconstruction) error {
+	if in.Equals(ErrorCid) {
+		return ErrBadCid
+	}
+	*out = api.ErasureReconstruction{
+		Cid:        in,
+		Peer:       PeerID1,
+		Blocks:     3,
+		Size:       1024,
+		UsedShards: []int{0, 1},
+	}
+	return nil
+}
+
+func (mock *mockCluster) TrackAccess(ctx context.Context, in cid.Cid, out *struct{}) error {
+	return nil
+}
+
+func (mock *mockCluster) RPCPolicy(ctx context.Context, in struct{}, out *map[string]string) error {
+	*out = map[string]string{
+		"Cluster.ID":  "open",
+		"Cluster.Pin": "closed",
+	}
+	return nil
+}
+
+func (mock *mockCluster) SetRPCPolicy(ctx context.Context, in map[string]string, out *map[string]string) error {
+	policy := map[string]string{
+		"Cluster.ID":  "open",
+		"Cluster.Pin": "closed",
+	}
+	for k, v := range in {
+		if _, ok := policy[k]; !ok {
+			return errors.New("unknown RPC method " + k)
+		}
+		policy[k] = v
+	}
+	*out = policy
+	return nil
+}
+
+func (mock *mockCluster) APIListeners(ctx context.Context, in struct{}, out *[]*api.APIListeners) error {
+	*out = []*api.APIListeners{
+		{
+			API:                    "restapi",
+			HTTPListenMultiaddress: []string{"/ip4/127.0.0.1/tcp/9094"},
+		},
+	}
+	return nil
+}
+
+func (mock *mockCluster) RebindAPI(ctx context.Context, in *api.APIListeners, out *api.APIListeners) error {
+	if in.API != "restapi" {
+		return errors.New("unknown API " + in.API)
+	}
+	*out = *in
+	return nil
+}
+
+func (mock *mockCluster) PinChanges(ctx context.Context, in uint64, out *api.PinChanges) error {
+	if in > PinSequence1 || (in != 0 && in < PinSequence1-10) {
+		return api.ErrChangesUnavailable
+	}
+	var pin api.Pin
+	mock.PinGet(ctx, Cid1, &pin)
+	changes := []*api.PinChange{
+		{Sequence: PinSequence1 - 1, Cid: Cid1, Pin: &pin},
+		{Sequence: PinSequence1, Cid: Cid2, Removed: true},
+	}
+	*out = api.PinChanges{
+		Sequence: PinSequence1,
+		Changes:  make([]*api.PinChange, 0),
+	}
+	for _, ch := range changes {
+		if in != 0 && ch.Sequence > in {
+			out.Changes = append(out.Changes, ch)
+		}
+	}
+	return nil
+}
+
+func (mock *mockCluster) LifecycleEvents(ctx context.Context, in uint64, out *api.LifecycleEvents) error {
+	events := []*api.LifecycleEvent{
+		{Sequence: 1, Event: api.LifecycleEventAPIStarted, Peer: PeerID1, Details: "*rest.API"},
+		{Sequence: 2, Event: api.LifecycleEventConsensusReady, Peer: PeerID1},
+		{Sequence: 3, Event: api.LifecycleEventPeerJoined, Peer: PeerID1, Subject: PeerID2},
+	}
+	*out = api.LifecycleEvents{
+		Sequence: 3,
+		Events:   make([]*api.LifecycleEvent, 0),
+	}
+	for _, ev := range events {
+		if ev.Sequence > in {
+			out.Events = append(out.Events, ev)
+		}
+	}
+	return nil
+}
+
+func (mock *mockCluster) Operations(ctx context.Context, in struct{}, out *[]*api.Operation) error {
+	var op api.Operation
+	mock.Operation(ctx, OperationID1, &op)
+	*out = []*api.Operation{&op}
+	return nil
+}
+
+func (mock *mockCluster) Operation(ctx context.Context, in string, out *api.Operation) error {
+	if in != OperationID1 {
+		return api.ErrOperationNotFound
+	}
+	*out = api.Operation{
+		ID:        OperationID1,
+		Type:      api.OperationRecoverAll,
+		Status:    api.OperationRunning,
+		Done:      1,
+		Total:     2,
+		StartedAt: time.Now(),
+	}
+	return nil
+}
+
+func (mock *mockCluster) CancelOperation(ctx context.Context, in string, out *api.Operation) error {
+	err := mock.Operation(ctx, in, out)
+	if err != nil {
+		return err
+	}
+	out.Status = api.OperationCanceled
+	out.FinishedAt = time.Now()
+	return nil
+}
+
+func (mock *mockCluster) StartOperation(ctx context.Context, in api.OperationType, out *api.Operation) error {
+	*out = api.Operation{
+		ID:        OperationID2,
+		Type:      in,
+		Status:    api.OperationRunning,
+		StartedAt: time.Now(),
+	}
+	return nil
+}
+
+func (mock *mockCluster) FinishOperation(ctx context.Context, in *api.Operation, out *struct{}) error {
+	if in.ID != OperationID2 {
+		return api.ErrOperationNotFound
+	}
+	return nil
+}
+
 func (mock *mockCluster) StatusLocal(ctx context.Context, in cid.Cid, out *api.PinInfo) error {
 	return (&mockPinTracker{}).Status(ctx, in, out)
 }
 
+func (mock *mockCluster) StatusCids(ctx context.Context, in []cid.Cid, out *[]*api.GlobalPinInfo) error {
+	gpis := make([]*api.GlobalPinInfo, 0, len(in))
+	for _, c := range in {
+		var gpi api.GlobalPinInfo
+		if err := mock.Status(ctx, c, &gpi); err != nil {
+			return err
+		}
+		gpis = append(gpis, &gpi)
+	}
+	*out = gpis
+	return nil
+}
+
+func (mock *mockCluster) StatusCidsLocal(ctx context.Context, in []cid.Cid, out *[]*api.PinInfo) error {
+	return (&mockPinTracker{}).StatusCids(ctx, in, out)
+}
+
 func (mock *mockCluster) RecoverAll(ctx context.Context, in struct{}, out *[]*api.GlobalPinInfo) error {
 	return mock.StatusAll(ctx, api.TrackerStatusUndefined, out)
 }
@@ -319,6 +941,69 @@ func (mock *mockCluster) BlockAllocate(ctx context.Context, in *api.Pin, out *[]
 	return nil
 }
 
+func (mock *mockCluster) Audit(ctx context.Context, in api.DAGAuditRequest, out *api.GlobalDAGAudit) error {
+	localAudit := &api.DAGAudit{}
+	err := mock.AuditLocal(ctx, in, localAudit)
+	if err != nil {
+		return err
+	}
+	*out = api.GlobalDAGAudit{
+		Cid: in.Cid,
+		PeerMap: map[string]*api.DAGAudit{
+			peer.Encode(PeerID1): localAudit,
+		},
+	}
+	return nil
+}
+
+func (mock *mockCluster) ConsistencyCheck(ctx context.Context, in bool, out *api.GlobalConsistencyReport) error {
+	report := &api.ConsistencyReport{}
+	err := mock.ConsistencyCheckLocal(ctx, in, report)
+	if err != nil {
+		return err
+	}
+	*out = api.GlobalConsistencyReport{
+		PeerMap: map[string]*api.ConsistencyReport{
+			peer.Encode(PeerID1): report,
+		},
+	}
+	return nil
+}
+
+func (mock *mockCluster) ConsistencyCheckLocal(ctx context.Context, in bool, out *api.ConsistencyReport) error {
+	*out = api.ConsistencyReport{
+		Peer:    PeerID1,
+		Checked: 2,
+		Issues: []*api.ConsistencyIssue{
+			{
+				Cid:      Cid1,
+				Type:     api.ConsistencyNotPinned,
+				Status:   api.TrackerStatusUnexpectedlyUnpinned,
+				Repaired: in,
+			},
+		},
+	}
+	return nil
+}
+
+func (mock *mockCluster) AuditLocal(ctx context.Context, in api.DAGAuditRequest, out *api.DAGAudit) error {
+	if in.Cid.Equals(ErrorCid) {
+		return ErrBadCid
+	}
+	if in.Cid.Equals(NotFoundCid) {
+		return state.ErrNotFound
+	}
+	*out = api.DAGAudit{
+		Cid:       in.Cid,
+		Peer:      PeerID1,
+		Blocks:    2,
+		Corrupted: []cid.Cid{Cid2},
+		Missing:   []cid.Cid{},
+		Repaired:  in.Repair,
+	}
+	return nil
+}
+
 func (mock *mockCluster) RepoGC(ctx context.Context, in struct{}, out *api.GlobalRepoGC) error {
 	localrepoGC := &api.RepoGC{}
 	_ = mock.RepoGCLocal(ctx, struct{}{}, localrepoGC)
@@ -359,6 +1044,24 @@ func (mock *mockCluster) SendInformerMetrics(ctx context.Context, in struct{}, o
 	return nil
 }
 
+func (mock *mockCluster) StartupWarmup(ctx context.Context, in struct{}, out *api.StartupWarmup) error {
+	*out = api.StartupWarmup{
+		Peer:      PeerID1,
+		Peername:  PeerName1,
+		Rate:      10,
+		Recovered: 2,
+		Operation: &api.Operation{
+			ID:        "warmup",
+			Type:      api.OperationStartupWarmup,
+			Status:    api.OperationRunning,
+			Done:      3,
+			Total:     5,
+			StartedAt: time.Now().Add(-time.Second),
+		},
+	}
+	return nil
+}
+
 func (mock *mockCluster) Alerts(ctx context.Context, in struct{}, out *[]api.Alert) error {
 	*out = []api.Alert{
 		api.Alert{
@@ -431,6 +1134,19 @@ func (mock *mockPinTracker) Status(ctx context.Context, in cid.Cid, out *api.Pin
 	return nil
 }
 
+func (mock *mockPinTracker) StatusCids(ctx context.Context, in []cid.Cid, out *[]*api.PinInfo) error {
+	pinInfos := make([]*api.PinInfo, 0, len(in))
+	for _, c := range in {
+		var pi api.PinInfo
+		if err := mock.Status(ctx, c, &pi); err != nil {
+			return err
+		}
+		pinInfos = append(pinInfos, &pi)
+	}
+	*out = pinInfos
+	return nil
+}
+
 func (mock *mockPinTracker) RecoverAll(ctx context.Context, in struct{}, out *[]*api.PinInfo) error {
 	*out = make([]*api.PinInfo, 0)
 	return nil
@@ -448,6 +1164,22 @@ func (mock *mockPinTracker) Recover(ctx context.Context, in cid.Cid, out *api.Pi
 	return nil
 }
 
+func (mock *mockPinTracker) Settings(ctx context.Context, in struct{}, out *api.TrackerSettings) error {
+	*out = api.TrackerSettings{ConcurrentPins: 10}
+	return nil
+}
+
+func (mock *mockPinTracker) SetSettings(ctx context.Context, in *api.TrackerSettings, out *api.TrackerSettings) error {
+	if in.ConcurrentPins < 0 {
+		return errors.New("concurrent_pins cannot be negative")
+	}
+	*out = *in
+	if out.ConcurrentPins == 0 {
+		out.ConcurrentPins = 10
+	}
+	return nil
+}
+
 /* PeerMonitor methods */
 
 // LatestMetrics runs PeerMonitor.LatestMetrics().
@@ -471,6 +1203,32 @@ func (mock *mockPeerMonitor) MetricNames(ctx context.Context, in struct{}, out *
 	return nil
 }
 
+// DetectorState runs PeerMonitor.DetectorState().
+func (mock *mockPeerMonitor) DetectorState(ctx context.Context, in struct{}, out *[]*api.DetectorState) error {
+	*out = []*api.DetectorState{
+		{
+			Peer:         PeerID1,
+			Metric:       "ping",
+			Threshold:    3,
+			Samples:      10,
+			LastReceived: time.Now(),
+		},
+		{
+			Peer:         PeerID2,
+			Metric:       "ping",
+			Phi:          5,
+			Threshold:    3,
+			Samples:      10,
+			LastReceived: time.Now().Add(-time.Minute),
+			Expired:      true,
+			Failed:       true,
+			Suppressed:   true,
+			LastAlert:    time.Now().Add(-time.Minute),
+		},
+	}
+	return nil
+}
+
 /* IPFSConnector methods */
 
 func (mock *mockIPFSConnector) Pin(ctx context.Context, in *api.Pin, out *struct{}) error {
@@ -558,3 +1316,11 @@ func (mock *mockConsensus) Peers(ctx context.Context, in struct{}, out *[]peer.I
 	*out = []peer.ID{PeerID1, PeerID2, PeerID3}
 	return nil
 }
+
+func (mock *mockConsensus) Stats(ctx context.Context, in struct{}, out *api.ConsensusStats) error {
+	*out = api.ConsensusStats{
+		Peer: PeerID1,
+		CRDT: &api.CRDTStats{Heads: 1, Height: 10},
+	}
+	return nil
+}
@@ -0,0 +1,364 @@
+// Package testutils provides an in-memory IPFS Cluster for the integration
+// tests of applications which use the cluster APIs or the client library,
+// without running IPFS or the cluster daemons.
+//
+// The cluster is made of real cluster peers, using the CRDT consensus over
+// libp2p on the loopback interface, with in-memory datastores. Every peer
+// talks to its own mock IPFS daemon (see test.IpfsMock) and exposes the
+// REST API on a random local port.
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	ipfscluster "github.com/ipfs/ipfs-cluster"
+	"github.com/ipfs/ipfs-cluster/allocator/balanced"
+	"github.com/ipfs/ipfs-cluster/api/rest"
+	"github.com/ipfs/ipfs-cluster/api/rest/client"
+	"github.com/ipfs/ipfs-cluster/config"
+	"github.com/ipfs/ipfs-cluster/consensus/crdt"
+	"github.com/ipfs/ipfs-cluster/datastore/inmem"
+	"github.com/ipfs/ipfs-cluster/informer/disk"
+	"github.com/ipfs/ipfs-cluster/ipfsconn/ipfshttp"
+	"github.com/ipfs/ipfs-cluster/monitor/pubsubmon"
+	"github.com/ipfs/ipfs-cluster/observations"
+	"github.com/ipfs/ipfs-cluster/pintracker/stateless"
+	"github.com/ipfs/ipfs-cluster/test"
+
+	logging "github.com/ipfs/go-log/v2"
+	host "github.com/libp2p/go-libp2p-core/host"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	peerstore "github.com/libp2p/go-libp2p-core/peerstore"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+var logger = logging.Logger("testutils")
+
+// HealthyTimeout is how long NewCluster waits for all peers to see each
+// other.
+var HealthyTimeout = 30 * time.Second
+
+// Options configure the cluster created by NewCluster.
+type Options struct {
+	// Peers is the number of cluster peers. Defaults to 3.
+	Peers int
+	// ReplicationFactorMin and ReplicationFactorMax are the default
+	// replication factors of the cluster. Default to -1 (everywhere).
+	ReplicationFactorMin int
+	ReplicationFactorMax int
+	// Configure, when set, is called with the configuration of every
+	// peer before it is created, and can modify it.
+	Configure func(i int, cfg *ipfscluster.Config)
+}
+
+// Peer is a peer of an in-memory cluster.
+type Peer struct {
+	*ipfscluster.Cluster
+
+	// IPFS is the mock IPFS daemon of the peer.
+	IPFS *test.IpfsMock
+	// APIAddr is the address of the REST API of the peer.
+	APIAddr ma.Multiaddr
+
+	host    host.Host
+	monitor *pubsubmon.Monitor
+	metrics []string // expected by waitForHealthy
+}
+
+// Client returns a REST API client for the peer.
+func (p *Peer) Client() (client.Client, error) {
+	return client.NewDefaultClient(&client.Config{APIAddr: p.APIAddr})
+}
+
+// Cluster is an in-memory cluster.
+type Cluster struct {
+	Peers []*Peer
+}
+
+// NewCluster starts a cluster with the given options and waits until all
+// its peers see each other. The test fails if the cluster cannot be created.
+// Shutdown must be called to stop it.
+func NewCluster(t *testing.T, opts Options) *Cluster {
+	t.Helper()
+	if opts.Peers <= 0 {
+		opts.Peers = 3
+	}
+	if opts.ReplicationFactorMin == 0 {
+		opts.ReplicationFactorMin = -1
+	}
+	if opts.ReplicationFactorMax == 0 {
+		opts.ReplicationFactorMax = -1
+	}
+
+	ctx := context.Background()
+	c := &Cluster{}
+	cfg := newClusterConfig(opts)
+	peers := make([]*Peer, opts.Peers)
+	for i := range peers {
+		pcfg := *cfg
+		pcfg.Peername = fmt.Sprintf("peer_%d", i)
+		if opts.Configure != nil {
+			opts.Configure(i, &pcfg)
+		}
+
+		p, err := newPeer(ctx, t, &pcfg)
+		if err != nil {
+			c.Shutdown(ctx)
+			t.Fatal(err)
+		}
+		c.Peers = append(c.Peers, p)
+
+		if i > 0 {
+			err = p.Join(ctx, peerAddr(c.Peers[0].host))
+			if err != nil {
+				c.Shutdown(ctx)
+				t.Fatal(err)
+			}
+		}
+		<-p.Ready()
+	}
+
+	for _, p := range c.Peers {
+		for _, p2 := range c.Peers {
+			if p == p2 {
+				continue
+			}
+			p.host.Peerstore().AddAddrs(p2.host.ID(), p2.host.Addrs(), peerstore.PermanentAddrTTL)
+			if _, err := p.host.Network().DialPeer(ctx, p2.host.ID()); err != nil {
+				logger.Warn(err)
+			}
+		}
+	}
+
+	if err := c.waitForHealthy(ctx); err != nil {
+		c.Shutdown(ctx)
+		t.Fatal(err)
+	}
+	return c
+}
+
+// Shutdown stops all the peers of the cluster and their IPFS mocks.
+func (c *Cluster) Shutdown(ctx context.Context) {
+	for _, p := range c.Peers {
+		if err := p.Shutdown(ctx); err != nil {
+			logger.Error(err)
+		}
+		p.IPFS.Close()
+	}
+}
+
+// waitForHealthy waits until every peer has valid metrics from all the
+// peers: pings, which make up the peerset, and informer metrics, so that
+// pins can be allocated to any of them.
+func (c *Cluster) waitForHealthy(ctx context.Context) error {
+	timer := time.NewTimer(HealthyTimeout)
+	defer timer.Stop()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if c.healthy(ctx) {
+			return nil
+		}
+
+		select {
+		case <-timer.C:
+			return fmt.Errorf("timed out waiting for %d peers to be healthy", len(c.Peers))
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Cluster) healthy(ctx context.Context) bool {
+	for _, p := range c.Peers {
+		for _, name := range p.metrics {
+			valid := 0
+			for _, m := range p.monitor.LatestMetrics(ctx, name) {
+				if !m.Expired() {
+					valid++
+				}
+			}
+			if valid < len(c.Peers) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// newClusterConfig returns the configuration shared by all peers, with
+// intervals short enough for tests.
+func newClusterConfig(opts Options) *ipfscluster.Config {
+	cfg := &ipfscluster.Config{}
+	cfg.Default()
+	listen, _ := ma.NewMultiaddr("/ip4/127.0.0.1/tcp/0")
+	cfg.ListenAddr = []ma.Multiaddr{listen}
+	cfg.ReplicationFactorMin = opts.ReplicationFactorMin
+	cfg.ReplicationFactorMax = opts.ReplicationFactorMax
+	cfg.MonitorPingInterval = time.Second
+	cfg.PeerWatchInterval = time.Second
+	cfg.StateSyncInterval = time.Minute
+	cfg.PinRecoverInterval = time.Minute
+	cfg.MDNSInterval = 0
+	cfg.DisableRepinning = false
+	return cfg
+}
+
+func newPeer(ctx context.Context, t *testing.T, cfg *ipfscluster.Config) (*Peer, error) {
+	mock := test.NewIpfsMock(t)
+	return newPeerWithMock(ctx, cfg, mock)
+}
+
+// newPeerWithMock creates the components of a peer as the cluster daemon
+// does, replacing the datastore with an in-memory one. On error, the
+// components created so far and the IPFS mock are shut down.
+func newPeerWithMock(ctx context.Context, cfg *ipfscluster.Config, mock *test.IpfsMock) (p *Peer, err error) {
+	var cleanups []func()
+	defer func() {
+		if err == nil {
+			return
+		}
+		for i := len(cleanups) - 1; i >= 0; i-- {
+			cleanups[i]()
+		}
+		mock.Close()
+	}()
+
+	ident, err := config.NewIdentity()
+	if err != nil {
+		return nil, err
+	}
+	store := inmem.New()
+	cleanups = append(cleanups, func() { store.Close() })
+
+	h, psub, dht, err := ipfscluster.NewClusterHost(ctx, ident, cfg, store)
+	if err != nil {
+		return nil, err
+	}
+	cleanups = append(cleanups, func() {
+		dht.Close()
+		h.Close()
+	})
+
+	apiCfg := rest.NewConfig()
+	apiCfg.Default()
+	apiAddr, _ := ma.NewMultiaddr("/ip4/127.0.0.1/tcp/0")
+	apiCfg.HTTPListenAddr = []ma.Multiaddr{apiAddr}
+	api, err := rest.NewAPI(ctx, apiCfg)
+	if err != nil {
+		return nil, err
+	}
+	cleanups = append(cleanups, func() { api.Shutdown(ctx) })
+	addrs, err := api.HTTPAddresses()
+	if err != nil {
+		return nil, err
+	}
+	_, port, err := net.SplitHostPort(addrs[0])
+	if err != nil {
+		return nil, err
+	}
+	tcpAddr, err := ma.NewMultiaddr("/ip4/127.0.0.1/tcp/" + port)
+	if err != nil {
+		return nil, err
+	}
+
+	ipfsCfg := &ipfshttp.Config{}
+	ipfsCfg.Default()
+	ipfsCfg.NodeAddr, _ = ma.NewMultiaddr(fmt.Sprintf("/ip4/%s/tcp/%d", mock.Addr, mock.Port))
+	connector, err := ipfshttp.NewConnector(ipfsCfg)
+	if err != nil {
+		return nil, err
+	}
+	cleanups = append(cleanups, func() { connector.Shutdown(ctx) })
+
+	diskCfg := &disk.Config{}
+	diskCfg.Default()
+	diskCfg.MetricTTL = 900 * time.Millisecond
+	inf, err := disk.NewInformer(diskCfg)
+	if err != nil {
+		return nil, err
+	}
+	cleanups = append(cleanups, func() { inf.Shutdown(ctx) })
+
+	allocCfg := &balanced.Config{}
+	allocCfg.Default()
+	allocCfg.AllocateBy = []string{inf.Name()}
+	alloc, err := balanced.New(allocCfg)
+	if err != nil {
+		return nil, err
+	}
+	cleanups = append(cleanups, func() { alloc.Shutdown(ctx) })
+
+	crdtCfg := &crdt.Config{}
+	crdtCfg.Default()
+	crdtCfg.ClusterName = "testutils"
+	crdtCfg.RebroadcastInterval = 250 * time.Millisecond
+	cons, err := crdt.New(h, dht, psub, crdtCfg, store)
+	if err != nil {
+		return nil, err
+	}
+	cleanups = append(cleanups, func() { cons.Shutdown(ctx) })
+
+	trackerCfg := &stateless.Config{}
+	trackerCfg.Default()
+	tracker := stateless.New(trackerCfg, h.ID(), cfg.Peername, cons.State, store)
+	cleanups = append(cleanups, func() { tracker.Shutdown(ctx) })
+
+	monCfg := &pubsubmon.Config{}
+	monCfg.Default()
+	monCfg.CheckInterval = 800 * time.Millisecond
+	mon, err := pubsubmon.New(ctx, monCfg, psub, nil)
+	if err != nil {
+		return nil, err
+	}
+	cleanups = append(cleanups, func() { mon.Shutdown(ctx) })
+
+	tracingCfg := &observations.TracingConfig{}
+	tracingCfg.Default()
+	tracer, err := observations.SetupTracing(tracingCfg)
+	if err != nil {
+		return nil, err
+	}
+	if tracer != nil {
+		cleanups = append(cleanups, func() { tracer.Shutdown(ctx) })
+	}
+
+	cl, err := ipfscluster.NewCluster(
+		ctx,
+		h,
+		dht,
+		cfg,
+		store,
+		cons,
+		[]ipfscluster.API{api},
+		connector,
+		tracker,
+		mon,
+		alloc,
+		[]ipfscluster.Informer{inf},
+		nil,
+		tracer,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Peer{
+		Cluster: cl,
+		IPFS:    mock,
+		APIAddr: tcpAddr,
+		host:    h,
+		monitor: mon,
+		metrics: []string{crdtCfg.PeersetMetric, inf.Name()},
+	}, nil
+}
+
+func peerAddr(h host.Host) ma.Multiaddr {
+	info := peer.AddrInfo{ID: h.ID(), Addrs: h.Addrs()}
+	addrs, _ := peer.AddrInfoToP2pAddrs(&info)
+	return addrs[0]
+}
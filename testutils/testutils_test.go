@@ -0,0 +1,54 @@
+package testutils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/api/rest/client"
+	"github.com/ipfs/ipfs-cluster/test"
+)
+
+func TestNewCluster(t *testing.T) {
+	ctx := context.Background()
+	c := NewCluster(t, Options{Peers: 3})
+	defer c.Shutdown(ctx)
+
+	cl, err := c.Peers[0].Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peers, err := cl.Peers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peers) != 3 {
+		t.Fatalf("expected 3 peers, got %d", len(peers))
+	}
+
+	_, err = cl.Pin(ctx, test.Cid1, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	_, err = client.WaitFor(wctx, cl, client.StatusFilterParams{
+		Cid:       test.Cid1,
+		Target:    api.TrackerStatusPinned,
+		CheckFreq: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The pin is visible from the other peers.
+	pin, err := c.Peers[2].PinGet(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pin.Allocations) != 0 {
+		t.Error("expected a pin allocated everywhere:", pin.Allocations)
+	}
+}
@@ -0,0 +1,306 @@
+package ipfscluster
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/rpcutil"
+
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	"go.opencensus.io/trace"
+)
+
+// This file gathers the logic to move pins between tiers of peers:
+//
+// * The tier of each peer is the value of its "tag:<Tiering.Tag>" metric,
+//   as set in the tags informer.
+// * The tier of a pin is recorded in its metadata (TierMetaKey). New data
+//   pins are in the Tiering.Hot tier. Pins are only allocated to peers in
+//   their tier, unless there are none.
+// * On every Tiering.Interval, the peer closest to each pin moves it to the
+//   Tiering.Cold tier once it has been in the hot one for
+//   Tiering.ColdAfter. When Popularity is enabled, pins accessed at least
+//   Popularity.HotThreshold times in the last interval stay in, or go back
+//   to, the hot tier. Moving a pin re-allocates it with the same
+//   replication factors.
+// * While a pin moves, it stays allocated to its peers in the old tier
+//   too. They are dropped on a later interval, once all the peers in the
+//   new tier report the pin as pinned, so that its replication does not
+//   drop during the move.
+//
+// Users can choose the tier of a pin by setting its TierMetaKey metadata.
+
+// Metadata keys of the pins managed by tiering.
+const (
+	// TierMetaKey is the tier of the pin.
+	TierMetaKey = "tier"
+	// TierSinceMetaKey is the time, in RFC3339 format, when the pin
+	// entered its tier.
+	TierSinceMetaKey = "tier_since"
+)
+
+func (c *Cluster) watchTiering() {
+	ticker := time.NewTicker(c.config.Tiering.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.applyTiering(c.ctx)
+		}
+	}
+}
+
+// setupTier sets the tier of data pins when tiering is enabled. Pins
+// without one keep the tier of the existing pin, or go to the hot tier.
+func (c *Cluster) setupTier(pin, existing *api.Pin) {
+	if c.config.Tiering.Interval <= 0 || pin.Type != api.DataType || pin.IsPinEverywhere() {
+		return
+	}
+
+	tier := pin.Metadata[TierMetaKey]
+	since := pin.Metadata[TierSinceMetaKey]
+	if tier == "" {
+		tier = c.config.Tiering.Hot
+		if existing != nil && existing.Metadata[TierMetaKey] != "" {
+			tier = existing.Metadata[TierMetaKey]
+		}
+	}
+	if since == "" {
+		if existing != nil && existing.Metadata[TierMetaKey] == tier {
+			since = existing.Metadata[TierSinceMetaKey]
+		}
+		if since == "" {
+			since = time.Now().UTC().Format(time.RFC3339)
+		}
+	}
+
+	meta := make(map[string]string, len(pin.Metadata)+2)
+	for k, v := range pin.Metadata {
+		meta[k] = v
+	}
+	meta[TierMetaKey] = tier
+	meta[TierSinceMetaKey] = since
+	pin.Metadata = meta
+}
+
+// tierPeers returns the tier of each peer which has one.
+func (c *Cluster) tierPeers(ctx context.Context) map[peer.ID]string {
+	tiers := make(map[peer.ID]string)
+	for _, m := range c.monitor.LatestMetrics(ctx, "tag:"+c.config.Tiering.Tag) {
+		tiers[m.Peer] = m.Value
+	}
+	return tiers
+}
+
+// inTier returns whether any peer is in the given tier.
+func inTier(tiers map[peer.ID]string, tier string) bool {
+	for _, t := range tiers {
+		if t == tier {
+			return true
+		}
+	}
+	return false
+}
+
+// tierExcludedPeers returns the peers which are not in the tier of the pin,
+// which should not be allocated. It returns nothing when no peer is in the
+// tier, so that the pin can still be allocated. User allocations are never
+// excluded.
+func (c *Cluster) tierExcludedPeers(ctx context.Context, pin *api.Pin) []peer.ID {
+	tier := pin.Metadata[TierMetaKey]
+	if c.config.Tiering.Interval <= 0 || tier == "" {
+		return nil
+	}
+
+	tiers := c.tierPeers(ctx)
+	if !inTier(tiers, tier) {
+		logger.Warnf("no peers in the %s tier: allocating %s to any peer", tier, pin.Cid)
+		return nil
+	}
+
+	peers, err := c.consensus.Peers(ctx)
+	if err != nil {
+		logger.Warn(err)
+		return nil
+	}
+	var excluded []peer.ID
+	for _, p := range peers {
+		if tiers[p] == tier || containsPeer(pin.UserAllocations, p) {
+			continue
+		}
+		excluded = append(excluded, p)
+	}
+	return excluded
+}
+
+// tierAllocations returns the allocations for a pin given those obtained
+// with the peers outside its tier excluded. The current allocations outside
+// the tier, unless blacklisted, are kept alongside the new ones until
+// applyTiering sees the pin pinned in its tier.
+func tierAllocations(allocs []peer.ID, existing *api.Pin, tierExcluded, blacklist []peer.ID) []peer.ID {
+	if len(allocs) == 0 || len(tierExcluded) == 0 {
+		return allocs
+	}
+
+	result := peersSubtract(allocs, tierExcluded)
+	if existing == nil {
+		return result
+	}
+	for _, p := range existing.Allocations {
+		if containsPeer(tierExcluded, p) && !containsPeer(blacklist, p) && !containsPeer(result, p) {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// applyTiering moves the pins for which this peer is the closest to the
+// tier where they belong, and completes the moves of those which are
+// already pinned in their tier.
+func (c *Cluster) applyTiering(ctx context.Context) {
+	ctx, span := trace.StartSpan(ctx, "cluster/applyTiering")
+	defer span.End()
+
+	if c.config.FollowerMode {
+		return
+	}
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		logger.Warn(err)
+		return
+	}
+	pins, err := cState.List(ctx)
+	if err != nil {
+		logger.Warn(err)
+		return
+	}
+
+	distance, err := c.distances(ctx, "")
+	if err != nil {
+		return // logged
+	}
+
+	var counts map[string]uint64
+	if c.config.Popularity.Interval > 0 {
+		counts = c.popularity(ctx)
+	}
+	tiers := c.tierPeers(ctx)
+	now := time.Now()
+	for _, pin := range pins {
+		if !distance.isClosest(pin.Cid) {
+			continue
+		}
+		newPin, ok := c.tierUpdate(pin, counts[pin.Cid.String()], now)
+		if !ok {
+			c.finishTierMove(ctx, pin, tiers)
+			continue
+		}
+		logger.Infof("moving %s to the %s tier", pin.Cid, newPin.Metadata[TierMetaKey])
+		if _, _, err := c.pin(ctx, newPin, nil); err != nil {
+			logger.Warnf("error moving %s to the %s tier: %s", pin.Cid, newPin.Metadata[TierMetaKey], err)
+		}
+	}
+}
+
+// finishTierMove drops the allocations of a pin which are outside its tier
+// once all the peers in the tier which are allocated have pinned it, and
+// they are enough to satisfy its minimum replication factor.
+func (c *Cluster) finishTierMove(ctx context.Context, pin *api.Pin, tiers map[peer.ID]string) {
+	tier := pin.Metadata[TierMetaKey]
+	if pin.Type != api.DataType || pin.IsPinEverywhere() || tier == "" || !inTier(tiers, tier) {
+		return
+	}
+
+	var kept, leaving []peer.ID
+	for _, p := range pin.Allocations {
+		if tiers[p] == tier || containsPeer(pin.UserAllocations, p) {
+			kept = append(kept, p)
+		} else {
+			leaving = append(leaving, p)
+		}
+	}
+	if len(leaving) == 0 || len(kept) < pin.ReplicationFactorMin || !c.pinnedOn(ctx, pin.Cid, kept) {
+		return
+	}
+
+	newPin := *pin
+	newPin.Allocations = kept
+	newPin.Timestamp = time.Now()
+	logger.Infof("%s is pinned in the %s tier: dropping %s", pin.Cid, tier, leaving)
+	if err := c.logPin(ctx, &newPin); err != nil {
+		logger.Warnf("error dropping %s from the previous tier: %s", pin.Cid, err)
+	}
+}
+
+// pinnedOn returns whether all the given peers report the item as pinned.
+func (c *Cluster) pinnedOn(ctx context.Context, h cid.Cid, peers []peer.ID) bool {
+	if len(peers) == 0 {
+		return false
+	}
+
+	replies := make([]*api.PinInfo, len(peers))
+	ctxs, cancels := rpcutil.CtxsWithTimeout(ctx, len(peers), 15*time.Second)
+	defer rpcutil.MultiCancel(cancels)
+
+	errs := c.rpcClient.MultiCall(
+		ctxs,
+		peers,
+		"PinTracker",
+		"Status",
+		h,
+		rpcutil.CopyPinInfoToIfaces(replies),
+	)
+	for i, err := range errs {
+		if err != nil || replies[i].Status != api.TrackerStatusPinned {
+			return false
+		}
+	}
+	return true
+}
+
+// tierUpdate returns a copy of the pin in the tier where it belongs given
+// its age and number of accesses, and true when the tier changed. Pins
+// without a tier are considered hot since they were pinned.
+func (c *Cluster) tierUpdate(pin *api.Pin, accesses uint64, now time.Time) (*api.Pin, bool) {
+	if pin.Type != api.DataType || pin.IsPinEverywhere() {
+		return nil, false
+	}
+
+	cfg := c.config.Tiering
+	popular := c.config.Popularity.Interval > 0 && accesses >= c.config.Popularity.HotThreshold
+
+	tier := pin.Metadata[TierMetaKey]
+	if tier == "" {
+		tier = cfg.Hot
+	}
+	since, err := time.Parse(time.RFC3339, pin.Metadata[TierSinceMetaKey])
+	if err != nil {
+		since = pin.Timestamp
+	}
+
+	var newTier string
+	switch {
+	case tier == cfg.Hot && !popular && now.Sub(since) >= cfg.ColdAfter:
+		newTier = cfg.Cold
+	case tier == cfg.Cold && popular:
+		newTier = cfg.Hot
+	default:
+		return nil, false
+	}
+
+	newPin := *pin
+	newPin.Allocations = nil // force re-allocations
+	newPin.Metadata = make(map[string]string, len(pin.Metadata)+2)
+	for k, v := range pin.Metadata {
+		newPin.Metadata[k] = v
+	}
+	newPin.Metadata[TierMetaKey] = newTier
+	newPin.Metadata[TierSinceMetaKey] = now.UTC().Format(time.RFC3339)
+	return &newPin, true
+}
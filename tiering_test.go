@@ -0,0 +1,167 @@
+package ipfscluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+func TestTierUpdate(t *testing.T) {
+	cfg := &Config{}
+	cfg.Default()
+	cfg.Tiering.Interval = time.Minute
+	cfg.Tiering.ColdAfter = time.Hour
+	c := &Cluster{config: cfg}
+
+	now := time.Now()
+	makePin := func(tier string, since time.Time) *api.Pin {
+		pin := api.PinCid(test.Cid1)
+		pin.ReplicationFactorMin = 1
+		pin.ReplicationFactorMax = 2
+		pin.Timestamp = since
+		pin.Metadata = map[string]string{"a": "b"}
+		if tier != "" {
+			pin.Metadata[TierMetaKey] = tier
+			pin.Metadata[TierSinceMetaKey] = since.UTC().Format(time.RFC3339)
+		}
+		return pin
+	}
+
+	if _, ok := c.tierUpdate(makePin("hot", now.Add(-time.Minute)), 0, now); ok {
+		t.Error("recent pins should stay hot")
+	}
+	newPin, ok := c.tierUpdate(makePin("hot", now.Add(-2*time.Hour)), 0, now)
+	if !ok || newPin.Metadata[TierMetaKey] != "cold" {
+		t.Fatal("old pins should become cold")
+	}
+	if newPin.Metadata["a"] != "b" || newPin.ReplicationFactorMax != 2 || newPin.Allocations != nil {
+		t.Error("moving pins should keep their options and reset their allocations")
+	}
+	if _, ok := c.tierUpdate(makePin("", now.Add(-2*time.Hour)), 0, now); !ok {
+		t.Error("old pins without tier should become cold")
+	}
+	if _, ok := c.tierUpdate(makePin("cold", now.Add(-2*time.Hour)), 1000, now); ok {
+		t.Error("cold pins should stay cold without popularity")
+	}
+
+	cfg.Popularity.Interval = time.Minute
+	if _, ok := c.tierUpdate(makePin("hot", now.Add(-2*time.Hour)), cfg.Popularity.HotThreshold, now); ok {
+		t.Error("popular pins should stay hot")
+	}
+	newPin, ok = c.tierUpdate(makePin("cold", now.Add(-2*time.Hour)), cfg.Popularity.HotThreshold, now)
+	if !ok || newPin.Metadata[TierMetaKey] != "hot" {
+		t.Error("popular pins should become hot")
+	}
+
+	everywhere := makePin("hot", now.Add(-2*time.Hour))
+	everywhere.ReplicationFactorMin = -1
+	everywhere.ReplicationFactorMax = -1
+	if _, ok := c.tierUpdate(everywhere, 0, now); ok {
+		t.Error("pins allocated everywhere should not be moved")
+	}
+}
+
+func TestClustersTiering(t *testing.T) {
+	ctx := context.Background()
+	clusters, mock := createClusters(t)
+	defer shutdownClusters(t, clusters, mock)
+
+	hot := make(map[peer.ID]bool)
+	for i, c := range clusters {
+		c.config.Tiering.Interval = time.Minute
+		tier := "cold"
+		if i < 2 {
+			tier = "hot"
+			hot[c.id] = true
+		}
+		m := &api.Metric{
+			Name:  "tag:" + c.config.Tiering.Tag,
+			Peer:  c.id,
+			Value: tier,
+			Valid: true,
+		}
+		m.SetTTL(time.Minute)
+		if err := c.monitor.PublishMetric(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	delay()
+
+	checkTier := func(tier string) {
+		t.Helper()
+		pin, err := clusters[0].PinGet(ctx, test.Cid1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pin.Metadata[TierMetaKey] != tier || pin.Metadata[TierSinceMetaKey] == "" {
+			t.Errorf("the pin should be in the %s tier: %v", tier, pin.Metadata)
+		}
+		if len(pin.Allocations) != 2 {
+			t.Fatal("the pin should keep 2 allocations:", pin.Allocations)
+		}
+		for _, p := range pin.Allocations {
+			if hot[p] != (tier == "hot") {
+				t.Errorf("%s is not a %s peer", p, tier)
+			}
+		}
+	}
+
+	_, err := clusters[0].Pin(ctx, test.Cid1, api.PinOptions{
+		ReplicationFactorMin: 2,
+		ReplicationFactorMax: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+	checkTier("hot")
+
+	for _, c := range clusters {
+		c.applyTiering(ctx)
+	}
+	pinDelay()
+	checkTier("hot")
+
+	for _, c := range clusters {
+		c.config.Tiering.ColdAfter = time.Nanosecond
+		c.applyTiering(ctx)
+	}
+	pinDelay()
+
+	// The hot peers keep the pin until the cold ones have pinned it.
+	pin, err := clusters[0].PinGet(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin.Metadata[TierMetaKey] != "cold" || len(pin.Allocations) != 4 {
+		t.Fatal("the pin should be allocated to both tiers while moving:", pin.Allocations)
+	}
+
+	for _, c := range clusters {
+		c.applyTiering(ctx)
+	}
+	pinDelay()
+	checkTier("cold")
+}
+
+func TestTierAllocations(t *testing.T) {
+	existing := api.PinCid(test.Cid1)
+	existing.Allocations = []peer.ID{test.PeerID1, test.PeerID2, test.PeerID3}
+	tierExcluded := []peer.ID{test.PeerID1, test.PeerID2}
+
+	// No new allocations were needed: the current ones are returned.
+	allocs := tierAllocations(existing.Allocations, existing, tierExcluded, []peer.ID{test.PeerID2})
+	if len(allocs) != 2 || allocs[0] != test.PeerID3 || allocs[1] != test.PeerID1 {
+		t.Error("expected the peer in the tier and the non-blacklisted leaving peer:", allocs)
+	}
+
+	allocs = tierAllocations([]peer.ID{test.PeerID3, test.PeerID4}, nil, tierExcluded, nil)
+	if len(allocs) != 2 {
+		t.Error("new pins should only be allocated in their tier:", allocs)
+	}
+}
@@ -0,0 +1,101 @@
+package ipfscluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+	"go.opencensus.io/trace"
+)
+
+// When UnpinGracePeriod is set, unpinned items are not removed from the
+// shared state right away. They are moved to the trash instead, by setting
+// the api.TrashedAtMetaKey metadata key. Items in the trash keep their
+// allocations, so they stay pinned in IPFS, until StateSync finds that
+// their grace period has passed and unpins them for real.
+
+// trash moves a pin to the trash. Protected pins cannot be trashed. Pins
+// already in the trash are returned unchanged, so that unpinning them again
+// does not extend their grace period.
+func (c *Cluster) trash(ctx context.Context, pin *api.Pin) (*api.Pin, error) {
+	if err := c.checkUnprotected(pin); err != nil {
+		return pin, err
+	}
+	if _, trashed := pin.TrashedAt(); trashed {
+		return pin, nil
+	}
+
+	trashed := copyWithMetadata(pin)
+	trashed.Metadata[api.TrashedAtMetaKey] = time.Now().UTC().Format(time.RFC3339)
+	api.RequestLogger(ctx, logger).Infof("moving %s to the trash for %s", pin.Cid, c.config.UnpinGracePeriod)
+	return trashed, c.logPin(ctx, trashed)
+}
+
+// Restore takes an item out of the trash before its grace period passes, so
+// that it is not unpinned. It returns the restored pin.
+func (c *Cluster) Restore(ctx context.Context, h cid.Cid) (*api.Pin, error) {
+	_, span := trace.StartSpan(ctx, "cluster/Restore")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	if c.config.FollowerMode {
+		return nil, errFollowerMode
+	}
+
+	pin, err := c.PinGet(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	if _, trashed := pin.TrashedAt(); !trashed {
+		return pin, fmt.Errorf("%w: %s", api.ErrNotTrashed, h)
+	}
+
+	restored := copyWithMetadata(pin)
+	delete(restored.Metadata, api.TrashedAtMetaKey)
+	api.RequestLogger(ctx, logger).Info("restoring from the trash: ", h)
+	return restored, c.logPin(ctx, restored)
+}
+
+// emptyTrash unpins the given items in the trash whose grace period has
+// passed at the given time, when this peer is the closest to them. Items
+// protected since they were trashed are kept.
+func (c *Cluster) emptyTrash(ctx context.Context, pins []*api.Pin, distance *distanceChecker, now time.Time) {
+	for _, p := range pins {
+		trashedAt, trashed := p.TrashedAt()
+		if !trashed || now.Before(trashedAt.Add(c.config.UnpinGracePeriod)) {
+			continue
+		}
+		if !distance.isClosest(p.Cid) {
+			continue
+		}
+		if c.checkUnprotected(p) != nil {
+			logger.Warnf("not unpinning %s: in the trash since %s but is protected", p.Cid, trashedAt)
+			continue
+		}
+		logger.Infof("unpinning %s: in the trash since %s", p.Cid, trashedAt)
+		if _, err := c.unpin(ctx, p); err != nil {
+			logger.Error(err)
+		}
+	}
+}
+
+// isRestore returns true when pin, submitted for an item in the trash,
+// does not carry the trash metadata key and therefore takes the item out
+// of the trash.
+func isRestore(pin, existing *api.Pin) bool {
+	_, wasTrashed := existing.TrashedAt()
+	_, trashed := pin.TrashedAt()
+	return wasTrashed && !trashed
+}
+
+func copyWithMetadata(pin *api.Pin) *api.Pin {
+	newPin := *pin
+	newPin.Metadata = make(map[string]string, len(pin.Metadata)+1)
+	for k, v := range pin.Metadata {
+		newPin.Metadata[k] = v
+	}
+	return &newPin
+}
@@ -97,7 +97,12 @@ type distanceChecker struct {
 }
 
 func (dc distanceChecker) isClosest(ci cid.Cid) bool {
-	ciHash := convertKey(ci.KeyString())
+	return dc.isClosestKey(ci.KeyString())
+}
+
+// isClosestKey returns true when the local peer is the closest to the key.
+func (dc distanceChecker) isClosestKey(key string) bool {
+	ciHash := convertKey(key)
 	localPeerHash := dc.convertPeerID(dc.local)
 	myDistance := xor(ciHash, localPeerHash)
 
@@ -0,0 +1,141 @@
+package ipfscluster
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/version"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	protocol "github.com/libp2p/go-libp2p-core/protocol"
+
+	"go.opencensus.io/trace"
+)
+
+// rpcProtocolAlertName is the name of the alerts raised for peers which do
+// not speak the RPC protocol of this peer. Their value is the protocol
+// which they announce.
+const rpcProtocolAlertName = "rpc_protocol"
+
+// PeerVersions returns the versions of cluster, of the RPC protocol and of
+// IPFS run by every cluster peer, along with how many peers run each of
+// them.
+func (c *Cluster) PeerVersions(ctx context.Context) *api.PeerVersions {
+	_, span := trace.StartSpan(ctx, "cluster/PeerVersions")
+	defer span.End()
+	ctx = api.CopyRequestID(trace.NewContext(c.ctx, span), ctx)
+
+	pvs := &api.PeerVersions{
+		Versions:            make(map[string]int),
+		RPCProtocolVersions: make(map[string]int),
+		IPFSVersions:        make(map[string]int),
+	}
+	for _, id := range c.Peers(ctx) {
+		pv := &api.PeerVersion{
+			ID:                 id.ID,
+			Peername:           id.Peername,
+			Version:            id.Version,
+			Commit:             id.Commit,
+			RPCProtocolVersion: id.RPCProtocolVersion,
+			Error:              id.Error,
+		}
+		if id.IPFS != nil {
+			pv.IPFSVersion = id.IPFS.AgentVersion
+		}
+		// Peers which cannot be contacted may be running a protocol
+		// which we do not speak.
+		if pv.RPCProtocolVersion == "" {
+			pv.RPCProtocolVersion = c.rpcProtocolOf(id.ID)
+		}
+		pvs.Peers = append(pvs.Peers, pv)
+
+		if pv.Version != "" {
+			pvs.Versions[pv.Version]++
+		}
+		if pv.RPCProtocolVersion != "" {
+			pvs.RPCProtocolVersions[string(pv.RPCProtocolVersion)]++
+		}
+		if pv.IPFSVersion != "" {
+			pvs.IPFSVersions[pv.IPFSVersion]++
+		}
+	}
+	pvs.RPCProtocolSkew = len(pvs.RPCProtocolVersions) > 1
+	return pvs
+}
+
+// rpcProtocolOf returns the cluster RPC protocol announced by a peer, as seen
+// in the peerstore. It prefers ours when the peer speaks several. It is
+// empty when the peer has not been identified.
+func (c *Cluster) rpcProtocolOf(pid peer.ID) protocol.ID {
+	if pid == c.id {
+		return version.RPCProtocol
+	}
+	protos, err := c.host.Peerstore().GetProtocols(pid)
+	if err != nil {
+		return ""
+	}
+
+	var rpcProtos []string
+	for _, p := range protos {
+		if p == string(version.RPCProtocol) {
+			return version.RPCProtocol
+		}
+		if strings.HasPrefix(p, "/ipfscluster/") && strings.HasSuffix(p, "/rpc") {
+			rpcProtos = append(rpcProtos, p)
+		}
+	}
+	if len(rpcProtos) == 0 {
+		return ""
+	}
+	sort.Strings(rpcProtos)
+	return protocol.ID(rpcProtos[0])
+}
+
+// checkRPCProtocols raises an alert for every peer which announces a
+// different RPC protocol than ours, once until it changes. RPC requests
+// between those peers fail, so this is usually a peer which has been
+// upgraded (or not) during a rolling upgrade.
+func (c *Cluster) checkRPCProtocols(peers []peer.ID) {
+	c.rpcSkewMux.Lock()
+	defer c.rpcSkewMux.Unlock()
+
+	current := make(map[peer.ID]struct{}, len(peers))
+	var mismatched []*api.Alert
+	for _, pid := range peers {
+		current[pid] = struct{}{}
+		proto := c.rpcProtocolOf(pid)
+		if proto == "" || proto == version.RPCProtocol {
+			delete(c.rpcSkew, pid)
+			continue
+		}
+		if c.rpcSkew[pid] == proto {
+			continue
+		}
+		c.rpcSkew[pid] = proto
+
+		alrt := &api.Alert{
+			Metric: api.Metric{
+				Name:  rpcProtocolAlertName,
+				Peer:  pid,
+				Value: string(proto),
+				Valid: true,
+			},
+			TriggeredAt: time.Now(),
+		}
+		c.setAlertLabels(alrt)
+		mismatched = append(mismatched, alrt)
+	}
+	for pid := range c.rpcSkew {
+		if _, ok := current[pid]; !ok {
+			delete(c.rpcSkew, pid)
+		}
+	}
+
+	for _, alrt := range mismatched {
+		logger.Warnf("peer %s speaks the RPC protocol %s instead of %s: upgrade the peers to the same version", alrt.Peer, alrt.Value, version.RPCProtocol)
+		c.recordAlert(alrt)
+	}
+}
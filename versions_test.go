@@ -0,0 +1,71 @@
+package ipfscluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/test"
+	"github.com/ipfs/ipfs-cluster/version"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+func TestClustersPeerVersions(t *testing.T) {
+	ctx := context.Background()
+	clusters, mocks := createClusters(t)
+	defer shutdownClusters(t, clusters, mocks)
+	waitForLeaderAndMetrics(t, clusters)
+
+	pvs := clusters[0].PeerVersions(ctx)
+	if len(pvs.Peers) != nClusters {
+		t.Fatal("expected versions for every peer:", len(pvs.Peers))
+	}
+	if pvs.RPCProtocolSkew {
+		t.Error("all the peers speak the same RPC protocol")
+	}
+	if n := pvs.Versions[version.Version.String()]; n != nClusters {
+		t.Error("expected every peer to run the same version:", pvs.Versions)
+	}
+	if n := pvs.IPFSVersions[test.IPFSAgentVersion]; n != nClusters {
+		t.Error("expected the IPFS version of every peer:", pvs.IPFSVersions)
+	}
+}
+
+func TestClustersRPCProtocolAlert(t *testing.T) {
+	clusters, mocks := createClusters(t)
+	defer shutdownClusters(t, clusters, mocks)
+	if len(clusters) < 2 {
+		t.Skip("test needs at least 2 clusters")
+	}
+
+	c := clusters[0]
+	other := clusters[1].id
+	peers := []peer.ID{c.id, other}
+	c.checkRPCProtocols(peers)
+
+	countAlerts := func() int {
+		n := 0
+		for _, a := range c.Alerts() {
+			if a.Name == rpcProtocolAlertName && a.Peer == other {
+				n++
+			}
+		}
+		return n
+	}
+	if n := countAlerts(); n != 0 {
+		t.Fatal("expected no alerts while the protocols match:", n)
+	}
+
+	err := c.host.Peerstore().SetProtocols(other, "/ipfscluster/0.99/rpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proto := c.rpcProtocolOf(other); proto != "/ipfscluster/0.99/rpc" {
+		t.Fatal("unexpected RPC protocol:", proto)
+	}
+	c.checkRPCProtocols(peers)
+	c.checkRPCProtocols(peers)
+	if n := countAlerts(); n != 1 {
+		t.Error("expected a single alert for the mismatched peer:", n)
+	}
+}
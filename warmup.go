@@ -0,0 +1,310 @@
+package ipfscluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/state"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	namespace "github.com/ipfs/go-datastore/namespace"
+	query "github.com/ipfs/go-datastore/query"
+	trace "go.opencensus.io/trace"
+)
+
+// gatewayCidPlaceholder is replaced by the CID in gateway URL templates.
+const gatewayCidPlaceholder = "{cid}"
+
+// gatewayWarmupPollInterval is how often the status of the pins is checked
+// while waiting for them to be pinned before warming up the gateways.
+var gatewayWarmupPollInterval = 5 * time.Second
+
+// gatewayURL returns the URL to request the content from a gateway.
+// Subdomain gateways need a case-insensitive CID, so the placeholder is
+// replaced by the CIDv1 (base32) of the content.
+func gatewayURL(gateway string, ci cid.Cid) string {
+	if strings.Contains(gateway, gatewayCidPlaceholder) {
+		v1 := cid.NewCidV1(ci.Type(), ci.Hash())
+		return strings.ReplaceAll(gateway, gatewayCidPlaceholder, v1.String())
+	}
+	return strings.TrimSuffix(gateway, "/") + "/ipfs/" + ci.String()
+}
+
+// gatewayWarmupQueueSize is the number of warm-ups which can wait for
+// their pin to be pinned. New ones fail when it is reached.
+const gatewayWarmupQueueSize = 1024
+
+// The results of the last warm-ups are kept in the datastore, under this
+// namespace, so that they are still listed as operations after a restart.
+var gatewayWarmupNamespace = ds.NewKey("/gatewaywarmup")
+
+// gatewayWarmup is a warm-up waiting for its pin to be pinned or for its
+// gateway requests to finish.
+type gatewayWarmup struct {
+	ctx      context.Context
+	op       *operation
+	pin      *api.Pin
+	deadline time.Time
+
+	mu      sync.Mutex
+	pending int
+	failed  []string
+}
+
+// gatewayFetch is a request of a warm-up to one gateway.
+type gatewayFetch struct {
+	w   *gatewayWarmup
+	url string
+}
+
+// wantsGatewayWarmup returns true when gateways are configured and the
+// given data pin is not in the state yet. It must be called before the pin
+// is submitted: re-pins are not warmed up again.
+func (c *Cluster) wantsGatewayWarmup(ctx context.Context, pin *api.Pin) bool {
+	if len(c.config.GatewayWarmup.Gateways) == 0 || pin.Type != api.DataType {
+		return false
+	}
+	_, err := c.PinGet(ctx, pin.Cid)
+	return err == state.ErrNotFound
+}
+
+// warmGateways queues a gateway_warmup operation for a new pin. The
+// operation waits for the pin to be pinned and then requests it from every
+// gateway.
+func (c *Cluster) warmGateways(ctx context.Context, pin *api.Pin) {
+	cfg := c.config.GatewayWarmup
+	op := c.operations.start(c.ctx, api.OperationGatewayWarmup, len(cfg.Gateways))
+	op.setCid(pin.Cid)
+	w := &gatewayWarmup{
+		ctx:      api.CopyRequestID(op.ctx, ctx),
+		op:       op,
+		pin:      pin,
+		deadline: time.Now().Add(cfg.WaitTimeout),
+		pending:  len(cfg.Gateways),
+	}
+
+	select {
+	case c.gatewayWarmups <- w:
+	default:
+		c.finishGatewayWarmup(w, errors.New("too many gateway warm-ups waiting"))
+	}
+}
+
+// watchGatewayWarmups checks the queued warm-ups every
+// gatewayWarmupPollInterval and hands the requests of those whose pin is
+// pinned to GatewayWarmup.Concurrency workers.
+func (c *Cluster) watchGatewayWarmups() {
+	cfg := c.config.GatewayWarmup
+	fetches := make(chan *gatewayFetch)
+	c.wg.Add(cfg.Concurrency)
+	for i := 0; i < cfg.Concurrency; i++ {
+		go func() {
+			defer c.wg.Done()
+			for {
+				select {
+				case <-c.ctx.Done():
+					return
+				case f := <-fetches:
+					c.fetchGatewayWarmup(f)
+				}
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(gatewayWarmupPollInterval)
+	defer ticker.Stop()
+
+	var waiting []*gatewayWarmup
+	var ready []*gatewayFetch
+	for {
+		in := c.gatewayWarmups
+		if len(waiting) >= gatewayWarmupQueueSize {
+			in = nil
+		}
+		var out chan *gatewayFetch
+		var next *gatewayFetch
+		if len(ready) > 0 {
+			out = fetches
+			next = ready[0]
+		}
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case w := <-in:
+			waiting = append(waiting, w)
+		case out <- next:
+			ready = ready[1:]
+		case <-ticker.C:
+			waiting, ready = c.pollGatewayWarmups(waiting, ready)
+		}
+	}
+}
+
+// pollGatewayWarmups queues the requests of the waiting warm-ups whose pin
+// is pinned, and fails those which waited for longer than
+// GatewayWarmup.WaitTimeout. It returns the warm-ups which must keep
+// waiting.
+func (c *Cluster) pollGatewayWarmups(waiting []*gatewayWarmup, ready []*gatewayFetch) ([]*gatewayWarmup, []*gatewayFetch) {
+	now := time.Now()
+	keep := waiting[:0]
+	for _, w := range waiting {
+		switch {
+		case w.op.canceled():
+			c.finishGatewayWarmup(w, w.ctx.Err())
+		case c.pinnedForWarmup(w.ctx, w.pin):
+			for _, gw := range c.config.GatewayWarmup.Gateways {
+				ready = append(ready, &gatewayFetch{w: w, url: gatewayURL(gw, w.pin.Cid)})
+			}
+		case now.After(w.deadline):
+			c.finishGatewayWarmup(w, fmt.Errorf("%s was not pinned after %s", w.pin.Cid, c.config.GatewayWarmup.WaitTimeout))
+		default:
+			keep = append(keep, w)
+		}
+	}
+	return keep, ready
+}
+
+// pinnedForWarmup returns true when the pin is pinned by this peer, or by
+// all its allocations when this peer is not one of them.
+func (c *Cluster) pinnedForWarmup(ctx context.Context, pin *api.Pin) bool {
+	if pin.IsPinEverywhere() || containsPeer(pin.Allocations, c.id) {
+		return c.tracker.Status(ctx, pin.Cid).Status == api.TrackerStatusPinned
+	}
+	return c.pinnedOn(ctx, pin.Cid, pin.Allocations)
+}
+
+// fetchGatewayWarmup performs a gateway request and finishes the warm-up
+// when it was the last one.
+func (c *Cluster) fetchGatewayWarmup(f *gatewayFetch) {
+	w := f.w
+	ctx, span := trace.StartSpan(w.ctx, "cluster/fetchGatewayWarmup")
+	err := fetchFromGateway(ctx, f.url, c.config.GatewayWarmup.Timeout)
+	span.End()
+	w.op.progress(1)
+
+	w.mu.Lock()
+	if err != nil {
+		logger.Warnf("gateway warm-up of %s failed: %s", w.pin.Cid, err)
+		w.failed = append(w.failed, fmt.Sprintf("%s: %s", f.url, err))
+	} else {
+		logger.Debugf("gateway warm-up: fetched %s", f.url)
+	}
+	w.pending--
+	done := w.pending == 0
+	failed := w.failed
+	w.mu.Unlock()
+
+	if !done {
+		return
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		err = fmt.Errorf("%d/%d gateways failed: %s", len(failed), len(c.config.GatewayWarmup.Gateways), strings.Join(failed, "; "))
+	} else {
+		err = nil
+	}
+	c.finishGatewayWarmup(w, err)
+}
+
+// finishGatewayWarmup finishes the operation of a warm-up and stores its
+// result.
+func (c *Cluster) finishGatewayWarmup(w *gatewayWarmup, err error) {
+	w.op.finish(err)
+	if err := c.saveGatewayWarmup(c.ctx, w.op.info()); err != nil {
+		logger.Errorf("error saving the gateway warm-up result: %s", err)
+	}
+}
+
+func (c *Cluster) gatewayWarmupStore() ds.Datastore {
+	return namespace.Wrap(c.datastore, gatewayWarmupNamespace)
+}
+
+// saveGatewayWarmup stores the result of a finished warm-up and removes the
+// oldest ones beyond maxFinishedOperations. Keys sort by finish time.
+func (c *Cluster) saveGatewayWarmup(ctx context.Context, info *api.Operation) error {
+	b, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	store := c.gatewayWarmupStore()
+	key := ds.NewKey(fmt.Sprintf("%020d-%s", info.FinishedAt.UnixNano(), info.ID))
+	if err := store.Put(ctx, key, b); err != nil {
+		return err
+	}
+
+	results, err := store.Query(ctx, query.Query{
+		KeysOnly: true,
+		Orders:   []query.Order{query.OrderByKey{}},
+	})
+	if err != nil {
+		return err
+	}
+	entries, err := results.Rest()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(entries)-maxFinishedOperations; i++ {
+		if err := store.Delete(ctx, ds.NewKey(entries[i].Key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadGatewayWarmups lists the stored results of the last warm-ups as
+// finished operations.
+func (c *Cluster) loadGatewayWarmups(ctx context.Context) error {
+	results, err := c.gatewayWarmupStore().Query(ctx, query.Query{})
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+
+	for r := range results.Next() {
+		if r.Error != nil {
+			return r.Error
+		}
+		var info api.Operation
+		if err := json.Unmarshal(r.Value, &info); err != nil {
+			logger.Warnf("ignoring the gateway warm-up result %s: %s", r.Key, err)
+			continue
+		}
+		c.operations.restore(&info)
+	}
+	return nil
+}
+
+// fetchFromGateway requests a URL and reads the whole response, so that the
+// gateway fetches all the content.
+func fetchFromGateway(ctx context.Context, u string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New(resp.Status)
+	}
+	_, err = io.Copy(ioutil.Discard, resp.Body)
+	return err
+}
@@ -0,0 +1,129 @@
+package ipfscluster
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+func TestGatewayURL(t *testing.T) {
+	v1 := cid.NewCidV1(test.Cid1.Type(), test.Cid1.Hash()).String()
+
+	u := gatewayURL("https://ipfs.io/", test.Cid1)
+	if u != "https://ipfs.io/ipfs/"+test.Cid1.String() {
+		t.Error("unexpected path gateway URL:", u)
+	}
+	u = gatewayURL("https://{cid}.ipfs.dweb.link/", test.Cid1)
+	if u != "https://"+v1+".ipfs.dweb.link/" {
+		t.Error("unexpected subdomain gateway URL:", u)
+	}
+}
+
+func TestClusterGatewayWarmup(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	defer func(d time.Duration) { gatewayWarmupPollInterval = d }(gatewayWarmupPollInterval)
+	gatewayWarmupPollInterval = 50 * time.Millisecond
+
+	paths := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.Path
+		if strings.HasPrefix(r.URL.Path, "/broken") {
+			http.Error(w, "unavailable", http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("content"))
+	}))
+	defer srv.Close()
+
+	cl.config.GatewayWarmup.Gateways = []string{
+		srv.URL,
+		srv.URL + "/sub/{cid}",
+		srv.URL + "/broken",
+	}
+	cl.config.GatewayWarmup.Concurrency = 2
+	// Not started by run() as there were no gateways.
+	cl.wg.Add(1)
+	go func() {
+		defer cl.wg.Done()
+		cl.watchGatewayWarmups()
+	}()
+
+	_, err := cl.Pin(ctx, test.Cid1, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var op *api.Operation
+	deadline := time.Now().Add(10 * time.Second)
+	for op == nil || op.Status == api.OperationRunning {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the warm-up")
+		}
+		time.Sleep(50 * time.Millisecond)
+		for _, o := range cl.Operations(ctx) {
+			if o.Type == api.OperationGatewayWarmup {
+				op = o
+			}
+		}
+	}
+
+	if !op.Cid.Equals(test.Cid1) || op.Done != 3 || op.Total != 3 {
+		t.Errorf("unexpected operation: %+v", op)
+	}
+	if op.Status != api.OperationFailed || !strings.Contains(op.Error, "1/3 gateways failed") {
+		t.Errorf("expected the broken gateway to be reported: %+v", op)
+	}
+
+	close(paths)
+	var requested []string
+	for p := range paths {
+		requested = append(requested, p)
+	}
+	if len(requested) != 3 {
+		t.Fatalf("expected 3 requests: %v", requested)
+	}
+	v1 := cid.NewCidV1(test.Cid1.Type(), test.Cid1.Hash()).String()
+	joined := strings.Join(requested, " ")
+	if !strings.Contains(joined, "/ipfs/"+test.Cid1.String()) || !strings.Contains(joined, "/sub/"+v1) {
+		t.Errorf("unexpected requests: %v", requested)
+	}
+
+	// Re-pins are not warmed up again.
+	if _, err := cl.Pin(ctx, test.Cid1, api.PinOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	warmups := 0
+	for _, o := range cl.Operations(ctx) {
+		if o.Type == api.OperationGatewayWarmup {
+			warmups++
+		}
+	}
+	if warmups != 1 {
+		t.Errorf("expected a single warm-up: %d", warmups)
+	}
+
+	// The result is kept after a restart.
+	cl.operations = newOperationTracker()
+	if err := cl.loadGatewayWarmups(ctx); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := cl.Operation(ctx, op.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Status != api.OperationFailed || restored.Error != op.Error || !restored.Cid.Equals(test.Cid1) {
+		t.Errorf("unexpected restored operation: %+v", restored)
+	}
+}
@@ -0,0 +1,93 @@
+package ipfscluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	"go.opencensus.io/trace"
+)
+
+// pinWebhookRetryDelay is how long to wait before sending a failed
+// notification again.
+var pinWebhookRetryDelay = time.Second
+
+func sendPinWebhook(ctx context.Context, wh *PinWebhookConfig, ev *api.PinsetEvent) {
+	ctx, span := trace.StartSpan(ctx, "cluster/sendPinWebhook")
+	defer span.End()
+
+	body, err := json.Marshal(ev)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	err = sendWebhook(ctx, wh.URL, wh.Timeout, wh.Retries, body)
+	if err != nil && ctx.Err() == nil {
+		logger.Errorf("pinset webhook %s failed, dropping %s event for %s: %s", wh.URL, ev.Event, ev.Pin.Cid, err)
+	}
+}
+
+func sendLifecycleWebhook(ctx context.Context, wh *LifecycleWebhookConfig, ev *api.LifecycleEvent) {
+	ctx, span := trace.StartSpan(ctx, "cluster/sendLifecycleWebhook")
+	defer span.End()
+
+	body, err := json.Marshal(ev)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	err = sendWebhook(ctx, wh.URL, wh.Timeout, wh.Retries, body)
+	if err != nil && ctx.Err() == nil {
+		logger.Errorf("lifecycle webhook %s failed, dropping %s event: %s", wh.URL, ev.Event, err)
+	}
+}
+
+// sendWebhook posts the body to the URL, retrying the given number of
+// times when it fails.
+func sendWebhook(ctx context.Context, url string, timeout time.Duration, retries int, body []byte) error {
+	for attempt := 0; ; attempt++ {
+		err := postWebhook(ctx, url, timeout, body)
+		if err == nil || attempt >= retries {
+			return err
+		}
+		logger.Debugf("webhook %s failed, retrying: %s", url, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pinWebhookRetryDelay):
+		}
+	}
+}
+
+func postWebhook(ctx context.Context, url string, timeout time.Duration, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		out, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
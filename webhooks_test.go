@@ -0,0 +1,109 @@
+package ipfscluster
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+)
+
+func webhookServer(t *testing.T, failures int32) (*httptest.Server, chan *api.PinsetEvent) {
+	events := make(chan *api.PinsetEvent, 10)
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var ev api.PinsetEvent
+		if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		events <- &ev
+	}))
+	t.Cleanup(srv.Close)
+	return srv, events
+}
+
+func nextEvent(t *testing.T, events chan *api.PinsetEvent) *api.PinsetEvent {
+	t.Helper()
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a pinset event")
+		return nil
+	}
+}
+
+func TestClusterPinWebhooks(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	defer func(d time.Duration) { pinWebhookRetryDelay = d }(pinWebhookRetryDelay)
+	pinWebhookRetryDelay = 10 * time.Millisecond
+
+	all, allEvents := webhookServer(t, 2)
+	removals, removalEvents := webhookServer(t, 0)
+	cl.config.PinWebhooks = []PinWebhookConfig{
+		{URL: all.URL, Timeout: time.Second, Retries: 2},
+		{URL: removals.URL, Timeout: time.Second, Events: []api.PinsetEventType{api.PinsetEventRemoved}},
+	}
+	cl.events = newEventQueues(cl.config, nil)
+	cl.wg.Add(1)
+	go func() {
+		defer cl.wg.Done()
+		cl.sendEvents()
+	}()
+
+	uctx := api.ContextWithRequestUser(ctx, "alice")
+	_, err := cl.Pin(uctx, test.Cid1, api.PinOptions{Name: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := nextEvent(t, allEvents)
+	if ev.Event != api.PinsetEventAdded || !ev.Pin.Cid.Equals(test.Cid1) {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+	if ev.User != "alice" || ev.Peer != cl.id {
+		t.Errorf("expected the submitting user and peer: %+v", ev)
+	}
+
+	_, err = cl.Pin(ctx, test.Cid1, api.PinOptions{Name: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev = nextEvent(t, allEvents)
+	if ev.Event != api.PinsetEventUpdated || ev.Pin.Name != "b" || ev.User != "" {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+
+	_, err = cl.Unpin(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev = nextEvent(t, allEvents)
+	if ev.Event != api.PinsetEventRemoved {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+
+	// Only the removal is sent to the second webhook.
+	ev = nextEvent(t, removalEvents)
+	if ev.Event != api.PinsetEventRemoved || !ev.Pin.Cid.Equals(test.Cid1) {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+	select {
+	case ev := <-removalEvents:
+		t.Errorf("unexpected event: %+v", ev)
+	default:
+	}
+}